@@ -2,15 +2,49 @@
 Chunkers package for extracting code elements from different languages
 """
 
-from .base_chunker import BaseChunker, CodeChunk
+from .base_chunker import (
+    BaseChunker, CodeChunk, assign_byte_ranges, assign_symbol_ids, parse_metadata, qualified_name,
+    repo_filepath, stored_chunk_id
+)
 from .cpp_chunker import CppChunker
 from .python_chunker import PythonChunker
 from .javascript_chunker import JavaScriptChunker
 from .mojom_chunker import MojomChunker
 from .gn_chunker import GnChunker
+from .go_chunker import GoChunker
+from .go_imports import IMPORT_CLASSES, find_module_path, uses_dependency
+from .go_package_linker import link_go_packages
+from .go_tests import TEST_KINDS, go_test_function, tag_go_tests
+from .cpp_linker import link_cpp_declarations
+from .rust_chunker import RustChunker
+from .zig_chunker import ZigChunker
+from .java_chunker import JavaChunker
+from .kotlin_chunker import KotlinChunker
+from .scala_chunker import ScalaChunker
+from .csharp_chunker import CSharpChunker
+from .csharp_linker import link_csharp_partials
+from .ruby_chunker import RubyChunker
+from .lua_chunker import LuaChunker
+from .php_chunker import PhpChunker
+from .elixir_chunker import ElixirChunker
+from .dart_chunker import DartChunker
+from .swift_chunker import SwiftChunker
+from .swift_linker import link_swift_extensions
+from .protobuf_chunker import ProtobufChunker
+from .markdown_chunker import MarkdownChunker
+from .html_chunker import HtmlChunker
+from .bash_chunker import BashChunker
+from .sql_chunker import SqlChunker
+from .yaml_chunker import YamlChunker
+from .json_chunker import JsonChunker
+from .token_splitter import (
+    OVERLAP_STRATEGIES, split_oversized_chunks, stitch_parts, get_token_counter, parse_overlap_strategy
+)
+from .granularity import Granularity, apply_granularity, is_excluded_symbol, is_small_symbol, parse_granularity
 from .tree_sitter_chunker import GenericTreeSitterChunker
 from .adaptive_chunker import AdaptiveChunker
 from .fallback_chunker import FallbackChunker
+from .text_chunker import TEXT_KIND, TextChunker
 from .file_architectures import FileArchitecture, get_architecture, get_extraction_strategy
 
 __all__ = [
@@ -19,9 +53,57 @@ __all__ = [
     'JavaScriptChunker',
     'MojomChunker',
     'GnChunker',
+    'GoChunker',
+    'IMPORT_CLASSES',
+    'find_module_path',
+    'uses_dependency',
+    'link_go_packages',
+    'TEST_KINDS',
+    'go_test_function',
+    'tag_go_tests',
+    'link_cpp_declarations',
+    'RustChunker',
+    'ZigChunker',
+    'JavaChunker',
+    'KotlinChunker',
+    'ScalaChunker',
+    'CSharpChunker',
+    'link_csharp_partials',
+    'RubyChunker',
+    'LuaChunker',
+    'PhpChunker',
+    'ElixirChunker',
+    'DartChunker',
+    'SwiftChunker',
+    'link_swift_extensions',
+    'ProtobufChunker',
+    'MarkdownChunker',
+    'HtmlChunker',
+    'BashChunker',
+    'SqlChunker',
+    'YamlChunker',
+    'JsonChunker',
+    'assign_byte_ranges',
+    'assign_symbol_ids',
+    'parse_metadata',
+    'qualified_name',
+    'repo_filepath',
+    'stored_chunk_id',
+    'OVERLAP_STRATEGIES',
+    'split_oversized_chunks',
+    'stitch_parts',
+    'get_token_counter',
+    'parse_overlap_strategy',
+    'Granularity',
+    'apply_granularity',
+    'is_excluded_symbol',
+    'is_small_symbol',
+    'parse_granularity',
     'GenericTreeSitterChunker',
     'AdaptiveChunker',
     'FallbackChunker',
+    'TEXT_KIND',
+    'TextChunker',
     'FileArchitecture',
     'get_architecture',
     'get_extraction_strategy',
@@ -58,6 +58,7 @@ class AdaptiveChunker(BaseChunker):
         if self.ts_chunker:
             try:
                 chunks = self.ts_chunker.extract_chunks(code, filepath)
+                self.diagnostics = self.ts_chunker.diagnostics
                 if chunks:
                     self.logger.debug(f"{filepath}: Used tree-sitter chunking ({len(chunks)} chunks)")
                     return chunks
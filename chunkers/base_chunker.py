@@ -3,7 +3,11 @@
 Base chunker class defining the interface for all language-specific chunkers
 """
 
+import ast
+import hashlib
+import json
 from abc import ABC, abstractmethod
+from collections import defaultdict
 from typing import List, Dict, Optional
 from dataclasses import dataclass
 
@@ -21,11 +25,44 @@ class CodeChunk:
     signature: Optional[str] = None
     namespace: Optional[str] = None
     parent_class: Optional[str] = None
+    parent: Optional[str] = None  # qualified name of the enclosing symbol
+    doc: Optional[str] = None  # docstring / doc comment, kept apart from the body
     metadata: Optional[Dict] = None
+    symbol_id: Optional[str] = None  # shared by all parts of a split symbol
+    part_index: int = 0
+    part_count: int = 1
+    context: Optional[str] = None  # prepended to the content when split (e.g. the file's imports)
+    byte_start: Optional[int] = None  # UTF-8 offset of the content in the source file
+    byte_end: Optional[int] = None  # exclusive; set by assign_byte_ranges when the chunker does not
+    kind: str = 'source'  # 'test' for chunks of test files (e.g. Go _test.go); see go_tests.TEST_KINDS
+    repo: Optional[str] = None  # label of the indexed root, in indexes of several repositories
+    modified: Optional[float] = None  # last change of the file (epoch seconds), for the recency boost
+    generated: bool = False  # from a generated file (see utils.generated_code)
+    
+    @property
+    def qualified_name(self) -> str:
+        """Name with its enclosing symbol (AdminUser.Authenticate)"""
+        return f"{self.parent}.{self.name}" if self.parent else self.name
+    
+    @property
+    def location(self) -> str:
+        """File path qualified with its repository label (see repo_filepath)"""
+        return repo_filepath(self.repo, self.filepath)
+    
+    def default_symbol_id(self) -> str:
+        """
+        Identity of the symbol for chunks assign_symbol_ids has not seen:
+        file, qualified name and first line
+        """
+        return f"{self.location}:{self.qualified_name}:{self.line_start}"
+    
+    def chunk_id(self) -> str:
+        """Database id of the chunk, derived from its symbol_id (see assign_symbol_ids)"""
+        return stored_chunk_id(self.symbol_id or self.default_symbol_id(), self.part_index, self.part_count)
     
     def to_dict(self) -> Dict:
         """Convert to dictionary for database storage"""
-        return {
+        stored = {
             'type': self.type,
             'name': self.name,
             'content': self.content,
@@ -36,8 +73,160 @@ class CodeChunk:
             'signature': self.signature or '',
             'namespace': self.namespace or '',
             'parent_class': self.parent_class or '',
-            'metadata': str(self.metadata) if self.metadata else ''
+            'parent': self.parent or '',
+            'doc': self.doc or '',
+            'symbol_id': self.symbol_id or self.default_symbol_id(),
+            'part_index': self.part_index,
+            'part_count': self.part_count,
+            'kind': self.kind,
+            'repo': self.repo or '',
+            'metadata': json.dumps(self.metadata, default=str) if self.metadata else ''
         }
+        # Stores reject None values, so unlocated chunks simply lack the offsets
+        if self.byte_start is not None and self.byte_end is not None:
+            stored['byte_start'] = self.byte_start
+            stored['byte_end'] = self.byte_end
+        if self.modified is not None:
+            stored['modified'] = self.modified
+        if self.generated:
+            stored['generated'] = True
+        return stored
+
+
+def repo_filepath(repo: Optional[str], filepath: str) -> str:
+    """
+    A file path that is unambiguous across the roots of an index: 'repo:path'
+    for a labeled repository, the path alone for an unlabeled root
+    """
+    return f"{repo}:{filepath}" if repo else filepath
+
+
+def assign_byte_ranges(chunks: List[CodeChunk], code: str) -> List[CodeChunk]:
+    """
+    Fill in byte_start/byte_end for chunks whose chunker did not set them
+    
+    The content is matched against the source at its first line; content
+    that is not a verbatim slice of the file falls back to the whole lines
+    line_start..line_end.
+    """
+    lines = code.split('\n')
+    line_starts, line_bytes = [], []
+    chars = size = 0
+    for line in lines:
+        line_starts.append(chars)
+        line_bytes.append(size)
+        chars += len(line) + 1
+        size += len(line.encode('utf-8')) + 1
+    
+    def to_bytes(line: int, offset: int) -> int:
+        """UTF-8 offset of a character offset that lies on the given line"""
+        return line_bytes[line] + len(code[line_starts[line]:offset].encode('utf-8'))
+    
+    for chunk in chunks:
+        if chunk.byte_start is not None and chunk.byte_end is not None:
+            continue
+        first = min(max(chunk.line_start, 1), len(lines)) - 1
+        last = min(max(chunk.line_end, chunk.line_start, 1), len(lines)) - 1
+        
+        head = chunk.content.split('\n', 1)[0]
+        start = code.find(head, line_starts[first], line_starts[first] + len(lines[first]))
+        if chunk.content and start != -1 and code.startswith(chunk.content, start):
+            end = start + len(chunk.content)
+            end_line = first + chunk.content.count('\n')
+        else:
+            start, end, end_line = line_starts[first], line_starts[last] + len(lines[last]), last
+        chunk.byte_start = to_bytes(first, start)
+        chunk.byte_end = to_bytes(end_line, end)
+    return chunks
+
+
+def assign_symbol_ids(chunks: List[CodeChunk]) -> List[CodeChunk]:
+    """
+    Give the chunks of one file stable symbol_ids: path:QualifiedName
+    (repo:path:QualifiedName for a file of a labeled repository)
+    
+    Names that occur more than once in the file (overloads, redeclarations,
+    repeated headings) get '~' and a hash of their signature appended, plus
+    '.2', '.3'... in source order for identical signatures. Line numbers are
+    not part of the id, so editing unrelated code keeps it; renaming the
+    symbol changes it. Chunks that already have a symbol_id keep it, and the
+    parts of one chunk a chunker split itself (TextChunker's blocks) share one.
+    """
+    groups = defaultdict(list)
+    for chunk in chunks:
+        if not chunk.symbol_id:
+            groups[f"{chunk.location}:{chunk.qualified_name}"].append(chunk)
+    
+    for key, group in groups.items():
+        if all(chunk.part_count > 1 for chunk in group) and len(group) == group[0].part_count:
+            for chunk in group:
+                chunk.symbol_id = key
+            continue
+        if len(group) == 1:
+            group[0].symbol_id = key
+            continue
+        seen = defaultdict(int)
+        for chunk in group:
+            shape = ' '.join((chunk.signature or chunk.content.split('\n', 1)[0]).split())
+            digest = hashlib.sha1(shape.encode('utf-8')).hexdigest()[:8]
+            seen[digest] += 1
+            suffix = digest if seen[digest] == 1 else f"{digest}.{seen[digest]}"
+            chunk.symbol_id = f"{key}~{suffix}"
+    return chunks
+
+
+def stored_chunk_id(symbol_id: str, part_index: int = 0, part_count: int = 1) -> str:
+    """Database id of a chunk: its symbol_id, with '#<part>' for parts of split symbols"""
+    return f"{symbol_id}#{part_index}" if part_count > 1 else symbol_id
+
+
+def parse_metadata(value) -> Dict:
+    """
+    Decode the 'metadata' field of a stored chunk back into a dict
+    Accepts JSON (current format) and Python literals (older indexes)
+    """
+    if isinstance(value, dict):
+        return value
+    if not value:
+        return {}
+    try:
+        parsed = json.loads(value)
+    except (TypeError, ValueError):
+        try:
+            parsed = ast.literal_eval(value)
+        except (ValueError, SyntaxError):
+            return {}
+    return parsed if isinstance(parsed, dict) else {}
+
+
+def qualified_name(metadata: Dict) -> str:
+    """Qualified name of a stored chunk (e.g. AdminUser.Authenticate)"""
+    name = metadata.get('name', '')
+    return f"{metadata['parent']}.{name}" if metadata.get('parent') else name
+
+
+def parse_error(line: int, message: str) -> Dict:
+    """A parse_error diagnostic: the file was only partly understood"""
+    return {'kind': 'parse_error', 'line': line, 'message': message}
+
+
+def tree_sitter_diagnostics(root, limit: int = 5) -> List[Dict]:
+    """
+    parse_error diagnostics for the ERROR and MISSING nodes of a tree-sitter tree
+    tree-sitter recovers on its own, so the declarations around them are still extracted
+    """
+    diagnostics = []
+    stack = [root] if root.has_error else []
+    while stack and len(diagnostics) < limit:
+        node = stack.pop()
+        line = node.start_point[0] + 1
+        if node.type == 'ERROR':
+            diagnostics.append(parse_error(line, "syntax error"))
+        elif node.is_missing:
+            diagnostics.append(parse_error(line, f"missing '{node.type}'"))
+        elif node.has_error:
+            stack.extend(reversed(node.children))
+    return diagnostics
 
 
 class BaseChunker(ABC):
@@ -45,16 +234,21 @@ class BaseChunker(ABC):
     
     def __init__(self, language: str):
         self.language = language
+        # Problems met by the last extract_chunks call, e.g. parse_error (see parse_error())
+        self.diagnostics: List[Dict] = []
     
     @abstractmethod
     def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
         """
         Extract code chunks from source code
         
+        Chunkers recover from syntax errors: the declarations that did parse are
+        returned, and what could not be parsed is reported in self.diagnostics.
+        
         Args:
             code: Source code content
             filepath: Relative path to the file
-            
+        
         Returns:
             List of CodeChunk objects
         """
@@ -68,16 +262,19 @@ class BaseChunker(ABC):
         """Extract text from code using byte offsets"""
         return code[start_byte:end_byte]
     
-    def _should_include_chunk(self, chunk: CodeChunk, min_size: int = 10, max_size: int = 10000) -> bool:
-        """Filter chunks based on size and quality"""
+    def _should_include_chunk(self, chunk: CodeChunk, min_size: int = 10, max_size: Optional[int] = None) -> bool:
+        """
+        Filter chunks based on size and quality
+        Oversized symbols are kept by default: the indexer splits them by token budget
+        """
         content_len = len(chunk.content)
         
         # Size filters
-        if content_len < min_size or content_len > max_size:
+        if content_len < min_size or (max_size is not None and content_len > max_size):
             return False
         
         # Quality filters
         if chunk.name == 'unknown' or chunk.name == 'anonymous':
             return False
-            
+        
         return True
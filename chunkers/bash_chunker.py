@@ -0,0 +1,250 @@
+#!/usr/bin/env python3
+"""
+Shell script chunker using tree-sitter for accurate parsing
+Supports .sh and .bash files
+
+Function definitions (name() { ... }, function name { ... }) and top-level
+variable assignments are read from the tree-sitter-bash tree, in which quoted
+strings, command substitutions and heredoc bodies are nodes of their own, so a
+'}' or ')' inside them can't end a function early. The '#' comment block
+directly above a definition becomes its doc, and the files a script sources
+are recorded as its dependencies.
+"""
+
+import re
+from typing import Dict, List, Optional
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+# Commands that declare variables (local only exists inside functions)
+DECLARATION_COMMANDS = {'declare', 'typeset', 'export', 'readonly'}
+SOURCE_COMMANDS = {'source', '.'}
+
+VARIABLE_NAME = re.compile(r'^[A-Za-z_][A-Za-z0-9_]*$')
+
+# Nodes that keep their statements at the level they appear in (a && X=1)
+STATEMENT_LISTS = ('list', 'ERROR')
+
+# Signatures show a variable's whole declaration if it is this short
+MAX_SIGNATURE_LENGTH = 100
+
+
+def unquote_word(word: str) -> str:
+    """A word with its quotes removed ("$DIR"/lib.sh -> $DIR/lib.sh); expansions are kept as written"""
+    result = []
+    i = 0
+    quote = None
+    while i < len(word):
+        ch = word[i]
+        if quote == "'":
+            if ch == "'":
+                quote = None
+            else:
+                result.append(ch)
+        elif ch == '\\' and i + 1 < len(word):
+            if quote != '"' or word[i + 1] in '$`"\\':
+                i += 1
+            result.append(word[i])
+        elif ch == '"':
+            quote = None if quote == '"' else '"'
+        elif ch == "'" and quote is None:
+            quote = "'"
+        else:
+            result.append(ch)
+        i += 1
+    return ''.join(result)
+
+
+class BashChunker(BaseChunker):
+    """Extracts functions and top-level variables from shell scripts"""
+    
+    def __init__(self):
+        super().__init__('bash')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('bash')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all functions and top-level variables, in source order"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._doc_lines = self._comment_lines(tree.root_node)
+        self._sources: Dict[Optional[str], List[str]] = {}
+        
+        chunks: List[CodeChunk] = []
+        self._walk(tree.root_node, None, True, chunks)
+        
+        # A function depends on the files the script sources at the top and on those it sources itself
+        shared = self._sources.get(None, [])
+        for chunk in chunks:
+            own = self._sources.get(chunk.qualified_name, []) if chunk.type == 'function' else []
+            sources = list(dict.fromkeys(shared + own))
+            if sources:
+                chunk.metadata['sources'] = sources
+        
+        chunks.sort(key=lambda c: (c.line_start, c.line_end))
+        return [c for c in chunks if self._should_include_chunk(c, min_size=1)]
+    
+    def _walk(self, node: Node, parent: Optional[str], top_level: bool, chunks: List[CodeChunk]):
+        """
+        Find the definitions among the statements under node
+        top_level is False inside functions and compound commands, where
+        assignments are not the script's variables
+        """
+        for child in node.named_children:
+            if child.type == 'function_definition':
+                chunk = self._function_chunk(child, parent)
+                if chunk is None:
+                    continue
+                chunks.append(chunk)
+                body = child.child_by_field_name('body')
+                if body is not None:
+                    self._walk(body, chunk.qualified_name, False, chunks)
+            elif child.type in ('variable_assignment', 'variable_assignments', 'declaration_command'):
+                if top_level and parent is None:
+                    chunks.extend(self._variable_chunks(child))
+                else:
+                    self._walk(child, parent, False, chunks)
+            elif child.type == 'command':
+                name = child.child_by_field_name('name')
+                if name is None and top_level and parent is None:
+                    # A command of nothing but assignments: RETRIES=3 TIMEOUT=5
+                    chunks.extend(self._variable_chunks(child))
+                    continue
+                arguments = child.children_by_field_name('argument')
+                if name is not None and self._text(name) in SOURCE_COMMANDS and arguments:
+                    self._sources.setdefault(parent, []).append(unquote_word(self._text(arguments[0])))
+                # NAME=value before a command only sets it for that command
+                self._walk(child, parent, False, chunks)
+            elif child.type in STATEMENT_LISTS:
+                self._walk(child, parent, top_level, chunks)
+            elif child.type != 'comment':
+                # Compound commands, pipelines, redirected statements, substitutions
+                self._walk(child, parent, False, chunks)
+    
+    def _function_chunk(self, node: Node, parent: Optional[str]) -> Optional[CodeChunk]:
+        name = node.child_by_field_name('name')
+        body = node.child_by_field_name('body')
+        if name is None or body is None:
+            return None
+        header = self._source[node.start_byte:body.start_byte].decode('utf8', errors='replace')
+        signature = re.sub(r'\s*\(\s*\)', '()', ' '.join(header.split()))
+        metadata = {'keyword': node.children[0].type == 'function'}
+        if body.type == 'subshell':
+            metadata['subshell'] = True  # runs in a subshell: its changes don't reach the caller
+        
+        return CodeChunk(
+            type='function',
+            name=self._text(name),
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=signature,
+            parent=parent,
+            doc=self._doc_above(self._line(node)),
+            metadata=metadata
+        )
+    
+    def _variable_chunks(self, node: Node) -> List[CodeChunk]:
+        """Variables a top-level assignment or declaration command defines"""
+        flags = ''
+        command = None
+        if node.type == 'declaration_command':
+            command = self._text(node.children[0])
+            if command not in DECLARATION_COMMANDS:
+                return []
+            names = []
+            for child in node.named_children:
+                text = self._text(child)
+                if child.type == 'word' and text.startswith(('-', '+')) and not names:
+                    flags += text.lstrip('-+') if text.startswith('-') else ''
+                elif child.type in ('variable_assignment', 'variable_name'):
+                    names.append(child)
+            if 'f' in flags or 'F' in flags:
+                return []  # declares functions
+        elif node.type == 'variable_assignment':
+            names = [node]
+        else:
+            names = [c for c in node.named_children if c.type == 'variable_assignment']
+        
+        chunks = []
+        for declared in names:
+            is_assignment = declared.type == 'variable_assignment'
+            name_node = declared.child_by_field_name('name') if is_assignment else declared
+            name = self._text(name_node) if name_node is not None else ''
+            if not VARIABLE_NAME.match(name):
+                continue
+            
+            metadata: Dict = {}
+            if is_assignment:
+                value = declared.child_by_field_name('value')
+                metadata['value'] = self._text(value) if value is not None else ''
+                if any(c.type == '+=' for c in declared.children):
+                    metadata['append'] = True
+            if command == 'export' or 'x' in flags:
+                metadata['exported'] = True
+            if command == 'readonly' or 'r' in flags:
+                metadata['readonly'] = True
+            if 'a' in flags or 'A' in flags or metadata.get('value', '').startswith('('):
+                metadata['array'] = 'associative' if 'A' in flags else 'indexed'
+            
+            content = self._text(node) if command else self._text(declared)
+            signature = content if len(content) <= MAX_SIGNATURE_LENGTH and '\n' not in content \
+                else f"{command + ' ' if command else ''}{name}=..."
+            chunks.append(CodeChunk(
+                type='variable',
+                name=name,
+                content=content,
+                filepath=self._filepath,
+                language=self.language,
+                line_start=self._line(node if command else declared),
+                line_end=self._line_end(declared),
+                signature=signature,
+                doc=self._doc_above(self._line(node)),
+                metadata=metadata
+            ))
+        return chunks
+    
+    def _comment_lines(self, root: Node) -> Dict[int, str]:
+        """Text after '#' of the comments that have a line to themselves, by line (the #! line excluded)"""
+        lines = {}
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type == 'comment':
+                line = self._line(node)
+                text = self._text(node)[1:].rstrip('\r\n')
+                line_start = self._source.rfind(b'\n', 0, node.start_byte) + 1
+                own_line = not self._source[line_start:node.start_byte].strip()
+                if own_line and not (line == 1 and text.startswith('!')):
+                    lines[line] = text
+            else:
+                stack.extend(node.children)
+        return lines
+    
+    def _doc_above(self, line: int) -> Optional[str]:
+        """The block of comment lines directly above a line, without the '#' markers"""
+        lines = []
+        line -= 1
+        while line in self._doc_lines:
+            text = self._doc_lines[line]
+            lines.append(text[1:] if text.startswith(' ') else text)
+            line -= 1
+        lines = [text for text in reversed(lines) if not text.lstrip().startswith('shellcheck ')]
+        while lines and not lines[-1].strip():
+            lines.pop()
+        return '\n'.join(lines).strip('\n') or None
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
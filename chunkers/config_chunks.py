@@ -0,0 +1,183 @@
+#!/usr/bin/env python3
+"""
+Chunks of configuration files, shared by the YAML and JSON chunkers
+A file is chunked by document (the '---' sections of a YAML stream) and,
+within a document, by top-level key or list item. A mapping longer than
+MAX_KEY_LINES is chunked by its own keys instead, so the services of a
+compose file or the jobs of a workflow get a chunk each. Every chunk
+records its key path ('services.web', 'jobs.build.steps[2]').
+
+A document with an apiVersion and a kind is a Kubernetes manifest: it stays
+one chunk, named by its metadata.name, with its kind, namespace, labels and
+container images in the metadata, so "the deployment for the auth service"
+finds it and `--filter "resource=Deployment/auth*"` selects it.
+"""
+
+from bisect import bisect_right
+from dataclasses import dataclass, field
+from fnmatch import fnmatchcase
+from typing import Dict, List, Optional
+
+from .base_chunker import CodeChunk
+
+
+# Mappings longer than this are chunked by their keys
+MAX_KEY_LINES = 40
+
+# Keys whose value names a list item (an Ansible play, a pipeline step)
+ITEM_NAME_KEYS = ('name', 'id', 'key', 'title')
+
+# Lists of containers in a pod spec
+CONTAINER_KEYS = ('initContainers', 'containers', 'ephemeralContainers')
+
+
+@dataclass
+class ConfigNode:
+    """A key or list item of a document, with the span of its text in the file"""
+    key: str  # the key, or '[i]' for a list item
+    start: int  # character offsets of the entry, from its key to the end of its value
+    end: int
+    children: List['ConfigNode'] = field(default_factory=list)  # entries of a mapping or list value
+    sequence: bool = False  # the children are list items
+    doc: Optional[str] = None  # comment block above the entry
+
+
+def key_path(path: List[str]) -> str:
+    """Dotted key path, list indexes appended to their key: spec.containers[0].image"""
+    text = ''
+    for key in path:
+        text += key if key.startswith('[') or not text else '.' + key
+    return text
+
+
+def is_manifest(value) -> bool:
+    """A Kubernetes object: a mapping with an apiVersion and a kind"""
+    return isinstance(value, dict) and isinstance(value.get('apiVersion'), str) and isinstance(value.get('kind'), str)
+
+
+def manifest_metadata(value: Dict) -> Dict:
+    """Kind, name, namespace, labels and container images of a Kubernetes object"""
+    meta = value.get('metadata') if isinstance(value.get('metadata'), dict) else {}
+    name = meta.get('name') or meta.get('generateName')
+    metadata = {'api_version': value['apiVersion'], 'kind': value['kind']}
+    if name:
+        metadata['name'] = str(name)
+    if meta.get('namespace'):
+        metadata['namespace'] = str(meta['namespace'])
+    if isinstance(meta.get('labels'), dict) and meta['labels']:
+        metadata['labels'] = {str(k): str(v) for k, v in meta['labels'].items()}
+    images = container_images(value.get('spec'))
+    if images:
+        metadata['images'] = images
+    metadata['resource'] = f"{value['kind']}/{name}" if name else value['kind']
+    return metadata
+
+
+def container_images(spec) -> List[str]:
+    """Images of the containers anywhere in a spec (pods, pod templates, job templates)"""
+    images: List[str] = []
+    stack = [spec]
+    while stack:
+        value = stack.pop()
+        if isinstance(value, list):
+            stack.extend(reversed(value))
+        elif isinstance(value, dict):
+            for key, item in value.items():
+                if key in CONTAINER_KEYS and isinstance(item, list):
+                    images.extend(c['image'] for c in item
+                                  if isinstance(c, dict) and isinstance(c.get('image'), str) and c['image'] not in images)
+                else:
+                    stack.append(item)
+    return images
+
+
+def matches_resource(metadata: Dict, pattern: str) -> bool:
+    """Whether a manifest's Kind/name (or its kind alone) matches an fnmatch pattern"""
+    resource = metadata.get('resource')
+    return bool(resource) and (fnmatchcase(resource, pattern) or fnmatchcase(metadata.get('kind', ''), pattern))
+
+
+def _child_value(value, key: str):
+    """Value of a key or list item ('[i]') of a parsed value; None when it has none"""
+    if key.startswith('[') and isinstance(value, list):
+        index = int(key[1:-1])
+        return value[index] if index < len(value) else None
+    return value.get(key) if isinstance(value, dict) else None
+
+
+class ConfigChunkBuilder:
+    """Turns the entry trees of a file's documents into chunks"""
+    
+    def __init__(self, code: str, filepath: str, language: str, documents: int = 1):
+        self.code = code
+        self.filepath = filepath
+        self.language = language
+        self.documents = documents
+        self.line_starts = [0] + [i + 1 for i, ch in enumerate(code) if ch == '\n']
+        self.chunks: List[CodeChunk] = []
+    
+    def line_of(self, offset: int) -> int:
+        return bisect_right(self.line_starts, offset)
+    
+    def document(self, node: ConfigNode, value, index: int = 0):
+        """
+        Chunks of one document: a manifest whole, otherwise its keys or items
+        (a bare scalar document is one chunk)
+        """
+        extra = {'document': index + 1} if self.documents > 1 else {}
+        items = next((child for child in node.children if child.key == 'items' and child.sequence), None)
+        if is_manifest(value) and value['kind'].endswith('List') and items and isinstance(value.get('items'), list):
+            # kubectl's List of objects: a manifest per object
+            for child, item in zip(items.children, value['items']):
+                if is_manifest(item):
+                    self._manifest(child, item, dict(extra, key_path=key_path(['items', child.key])))
+        elif is_manifest(value):
+            self._manifest(node, value, extra)
+        elif node.children:
+            for child in node.children:
+                self._entry(child, _child_value(value, child.key), [], node.sequence, extra)
+        elif node.end > node.start:
+            name = f"document {index + 1}" if self.documents > 1 else self.filepath.rsplit('/', 1)[-1]
+            self._add('document', name, node, dict(extra))
+    
+    def _manifest(self, node: ConfigNode, value: Dict, extra: Dict):
+        metadata = dict(manifest_metadata(value), **extra)
+        name = metadata.get('name', metadata['kind'])
+        self._add('manifest', name, node, metadata, namespace=metadata.get('namespace'),
+                  signature=f"{metadata['kind']} {name}")
+    
+    def _entry(self, node: ConfigNode, value, parent: List[str], item: bool, extra: Dict):
+        path = parent + [node.key]
+        # Long mappings, and long lists of mappings, are chunked by their entries
+        if (node.children and self.line_of(node.end) - self.line_of(node.start) >= MAX_KEY_LINES
+                and (not node.sequence or all(child.children for child in node.children))):
+            for child in node.children:
+                self._entry(child, _child_value(value, child.key), path, node.sequence, extra)
+            return
+        name = node.key
+        if item:
+            named = next((value[key] for key in ITEM_NAME_KEYS
+                          if isinstance(value, dict) and isinstance(value.get(key), (str, int))), None)
+            name = str(named) if named is not None else node.key
+        metadata = dict(extra, key_path=key_path(path))
+        if is_manifest(value):
+            metadata.update(manifest_metadata(value))
+        self._add('item' if item else 'key', name, node, metadata, parent=key_path(parent) or None)
+    
+    def _add(self, type: str, name: str, node: ConfigNode, metadata: Dict, namespace: Optional[str] = None,
+             parent: Optional[str] = None, signature: Optional[str] = None):
+        content = self.code[node.start:node.end]
+        self.chunks.append(CodeChunk(
+            type=type,
+            name=name,
+            content=content,
+            filepath=self.filepath,
+            language=self.language,
+            line_start=self.line_of(node.start),
+            line_end=self.line_of(node.start) + content.count('\n'),
+            signature=signature or content.split('\n', 1)[0].strip(),
+            namespace=namespace,
+            parent=parent,
+            doc=node.doc,
+            metadata=metadata,
+        ))
@@ -1,102 +1,214 @@
 #!/usr/bin/env python3
 """
-C++ code chunker using tree-sitter for accurate parsing
-Supports .cc, .cpp, .h, .hpp files
+C and C++ code chunker using tree-sitter for accurate parsing
+Supports .c, .cc, .cpp, .cxx, .h, .hpp files
+
+tree-sitter recovers from what it cannot parse, so macro-heavy code never
+fails a whole file. Of each #if/#elif/#else chain only the first branch that
+is not '#if 0' is chunked, with its condition recorded in the metadata.
 """
 
-from typing import List, Optional
+import re
+from typing import Dict, List, Optional, Tuple
 from tree_sitter import Language, Parser, Node
 import tree_sitter_cpp
 
-from .base_chunker import BaseChunker, CodeChunk
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+CLASS_SPECIFIERS = {'class_specifier': 'class', 'struct_specifier': 'struct', 'union_specifier': 'union'}
+
+PREPROC_CONDITIONALS = ('preproc_if', 'preproc_ifdef', 'preproc_elif', 'preproc_elifdef')
+
+# Declarators wrapping the one that holds the name: *f(), &f(), f() [[attr]]
+WRAPPING_DECLARATORS = ('pointer_declarator', 'reference_declarator', 'attributed_declarator')
+
+NAME_NODES = ('identifier', 'field_identifier', 'type_identifier', 'namespace_identifier',
+              'destructor_name', 'operator_name')
+
+
+def comment_text(comments: List[str]) -> str:
+    """Text of // or /* */ comments without their markers"""
+    lines = []
+    for text in comments:
+        if text.startswith('//'):
+            lines.append(text.lstrip('/!<')[1:] if text.lstrip('/!<').startswith(' ') else text.lstrip('/!<'))
+            continue
+        for line in text[2:-2].lstrip('*!').splitlines():
+            line = line.strip()
+            if line.startswith('*'):
+                line = line[1:]
+                line = line[1:] if line.startswith(' ') else line
+            lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def split_top_level(text: str) -> List[str]:
+    """Split on commas outside of <>, () and {}: 'public A<B, C>, D' -> ['public A<B, C>', 'D']"""
+    parts, depth, current = [], 0, ''
+    for char in text:
+        if char in '<({[':
+            depth += 1
+        elif char in '>)}]':
+            depth -= 1
+        elif char == ',' and depth == 0:
+            parts.append(current.strip())
+            current = ''
+            continue
+        current += char
+    if current.strip():
+        parts.append(current.strip())
+    return parts
 
 
 class CppChunker(BaseChunker):
-    """Extracts functions, classes, namespaces, and declarations from C++ code"""
+    """Extracts functions, classes, namespaces, enums, macros and declarations from C and C++ code"""
     
-    def __init__(self):
-        super().__init__('cpp')
-        CPP_LANGUAGE = Language(tree_sitter_cpp.language())
-        self.parser = Parser(CPP_LANGUAGE)
+    def __init__(self, language: str = 'cpp'):
+        super().__init__(language)
+        if language == 'c':
+            from tree_sitter_language_pack import get_parser
+            self.parser = get_parser('c')
+        else:
+            CPP_LANGUAGE = Language(tree_sitter_cpp.language())
+            self.parser = Parser(CPP_LANGUAGE)
     
     def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
-        """Extract all C++ code elements"""
+        """Extract all C/C++ code elements"""
         tree = self.parser.parse(bytes(code, "utf8"))
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
         chunks = []
         
-        # Track current namespace context
-        namespace_stack = []
-        
-        def traverse(node: Node, current_namespace: str = ''):
-            nonlocal chunks
-            
+        def traverse(node: Node, current_namespace: str = '', conditions: Tuple[str, ...] = ()):
             # Extract namespaces
             if node.type == "namespace_definition":
                 namespace_name = self._extract_namespace_name(node, code)
                 new_namespace = f"{current_namespace}::{namespace_name}" if current_namespace else namespace_name
-                namespace_stack.append(namespace_name)
-                
-                # Traverse children with updated namespace
-                for child in node.children:
-                    traverse(child, new_namespace)
-                
-                namespace_stack.pop()
-                return
-            
-            # Extract functions
-            elif node.type == "function_definition":
-                chunk = self._extract_function(node, code, filepath, current_namespace)
-                if chunk and self._should_include_chunk(chunk):
-                    chunks.append(chunk)
-            
-            # Extract classes and structs
-            elif node.type in ["class_specifier", "struct_specifier"]:
-                chunk = self._extract_class(node, code, filepath, current_namespace)
-                if chunk and self._should_include_chunk(chunk):
-                    chunks.append(chunk)
-                    
-                # Also extract methods within the class
-                class_name = self._extract_class_name(node, code)
                 body = node.child_by_field_name("body")
                 if body:
                     for child in body.children:
-                        if child.type == "function_definition":
-                            method_chunk = self._extract_function(
-                                child, code, filepath, current_namespace, parent_class=class_name
-                            )
-                            if method_chunk and self._should_include_chunk(method_chunk):
-                                chunks.append(method_chunk)
+                        traverse(child, new_namespace, conditions)
+                return
+            
+            # Only the live branch of preprocessor conditionals
+            if node.type in PREPROC_CONDITIONALS:
+                children, condition = self._live_branch(node, code)
+                for child in children:
+                    traverse(child, current_namespace, conditions + ((condition,) if condition else ()))
+                return
             
-            # Extract enums
-            elif node.type == "enum_specifier":
-                chunk = self._extract_enum(node, code, filepath, current_namespace)
-                if chunk and self._should_include_chunk(chunk):
-                    chunks.append(chunk)
+            extracted = self._extract_declaration(node, code, filepath, current_namespace)
+            if extracted is not None:
+                for chunk in extracted:
+                    if conditions:
+                        chunk.metadata['conditions'] = list(conditions) + chunk.metadata.get('conditions', [])
+                    if self._should_include_chunk(chunk):
+                        chunks.append(chunk)
+                return
             
-            # Continue traversing
-            for child in node.children:
-                if child.type not in ["namespace_definition"]:  # Avoid double-processing namespaces
-                    traverse(child, current_namespace)
+            # Continue traversing (translation unit, extern "C" blocks, recovered ERROR nodes...)
+            for child in node.named_children:
+                traverse(child, current_namespace, conditions)
         
         traverse(tree.root_node)
         
-        # Extract preprocessor macros (not in AST, use regex)
-        macro_chunks = self._extract_macros(code, filepath)
+        # Extract preprocessor macros
+        macro_chunks = self._extract_macros(tree.root_node, code, filepath)
         chunks.extend(macro_chunks)
         
         return chunks
     
-    def _extract_function(self, node: Node, code: str, filepath: str, 
-                         namespace: str = '', parent_class: str = '') -> Optional[CodeChunk]:
-        """Extract function definition"""
-        func_text = self._extract_text(code, node.start_byte, node.end_byte)
+    def _extract_declaration(self, node: Node, code: str, filepath: str, namespace: str,
+                             template: Optional[Node] = None) -> Optional[List[CodeChunk]]:
+        """Chunks of a namespace-level declaration, or None for nodes that only contain declarations"""
+        if node.type == "template_declaration":
+            inner = self._templated(node)
+            return self._extract_declaration(inner, code, filepath, namespace, node) if inner else []
+        
+        # Extract functions (out-of-line Widget::Paint definitions become methods of Widget)
+        if node.type == "function_definition":
+            chunk = self._extract_function(node, code, filepath, namespace, template=template)
+            return [chunk] if chunk else []
+        
+        # Extract prototypes, and classes declared along with a variable
+        if node.type == "declaration":
+            if self._function_declarator(node):
+                chunk = self._extract_function(node, code, filepath, namespace, template=template)
+                return [chunk] if chunk else []
+            specifier = node.child_by_field_name("type")
+            if specifier is not None and specifier.child_by_field_name("body"):
+                return self._extract_declaration(specifier, code, filepath, namespace, template)
+            return []
+        
+        # Extract classes, structs and unions
+        if node.type in CLASS_SPECIFIERS and node.child_by_field_name("body"):
+            return self._extract_class(node, code, filepath, namespace, outer=template)
+        
+        # Extract enums
+        if node.type == "enum_specifier" and node.child_by_field_name("body"):
+            return [self._extract_enum(node, code, filepath, namespace)]
+        
+        # typedef struct {...} Name;
+        if node.type == "type_definition":
+            specifier = node.child_by_field_name("type")
+            declarator = node.child_by_field_name("declarator")
+            if specifier is None or declarator is None or not specifier.child_by_field_name("body"):
+                return []
+            _, name = self._declarator_path(declarator, code)
+            if specifier.type in CLASS_SPECIFIERS:
+                return self._extract_class(specifier, code, filepath, namespace, outer=node, typedef=name)
+            if specifier.type == "enum_specifier":
+                return [self._extract_enum(specifier, code, filepath, namespace, outer=node, typedef=name)]
+            return []
+        
+        return None
+    
+    def _extract_function(self, node: Node, code: str, filepath: str, namespace: str = '',
+                          parent_class: str = '', template: Optional[Node] = None,
+                          access: Optional[str] = None) -> Optional[CodeChunk]:
+        """Extract a function definition or declaration (prototype)"""
+        outer = template or node
+        func_text = self._extract_text(code, outer.start_byte, outer.end_byte)
         
         # Get function name
-        declarator = node.child_by_field_name("declarator")
-        func_name = self._extract_function_name(declarator, code)
+        declarator = self._function_declarator(node)
+        if declarator is None:
+            return None
+        scopes, func_name = self._declarator_path(declarator.child_by_field_name("declarator"), code)
+        
+        return_type = node.child_by_field_name("type")
+        if not parent_class and scopes:
+            parent_class = re.sub(r'<.*', '', scopes[-1])
+        
+        # Macro-defined functions: TEST_F(WidgetTest, CreatesChild) { ... }
+        metadata: Dict = {}
+        if return_type is None and not parent_class and re.fullmatch(r'[A-Z][A-Z0-9_]*', func_name):
+            if node.type != "function_definition":
+                return None
+            params = declarator.child_by_field_name("parameters")
+            metadata['macro'] = func_name
+            func_name += self._extract_text(code, params.start_byte, params.end_byte)
+        else:
+            metadata['params'] = self._extract_params(declarator, code)
+            if return_type is not None:
+                metadata['returns'] = self._extract_return_type(node, declarator, code)
+        
+        metadata['definition'] = node.type == "function_definition"
+        metadata.update(self._function_clause(node, declarator, code))
+        if parent_class:
+            if func_name == parent_class:
+                metadata['constructor'] = True
+            elif func_name == f"~{parent_class}":
+                metadata['destructor'] = True
+        if access:
+            metadata['access'] = access
         
         # Get signature
-        signature = self._extract_signature(node, code)
+        signature = self._extract_signature(node, declarator, code)
+        if template is not None:
+            template_text = self._template_text(template, code)
+            metadata['template'] = template_text
+            signature = f"{template_text} {signature}"
         
         return CodeChunk(
             type='method' if parent_class else 'function',
@@ -104,122 +216,330 @@ class CppChunker(BaseChunker):
             content=func_text,
             filepath=filepath,
             language=self.language,
-            line_start=node.start_point[0] + 1,
-            line_end=node.end_point[0] + 1,
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
             signature=signature,
             namespace=namespace,
-            parent_class=parent_class
+            parent_class=parent_class,
+            parent=parent_class or None,
+            doc=self._extract_doc(outer, code),
+            metadata=metadata
         )
     
-    def _extract_class(self, node: Node, code: str, filepath: str, namespace: str = '') -> Optional[CodeChunk]:
-        """Extract class or struct definition"""
-        class_text = self._extract_text(code, node.start_byte, node.end_byte)
-        class_name = self._extract_class_name(node, code)
+    def _extract_class(self, node: Node, code: str, filepath: str, namespace: str = '',
+                       outer: Optional[Node] = None, typedef: Optional[str] = None,
+                       parent_class: str = '', access: Optional[str] = None) -> List[CodeChunk]:
+        """Extract a class, struct or union definition and its members"""
+        outer = outer or node
+        class_text = self._extract_text(code, outer.start_byte, outer.end_byte)
+        class_name = self._extract_class_name(node, code) if node.child_by_field_name("name") else typedef
+        if not class_name:
+            return []
+        kind = CLASS_SPECIFIERS[node.type]
         
         # Extract base classes
         bases = []
         for child in node.children:
             if child.type == "base_class_clause":
                 base_text = self._extract_text(code, child.start_byte, child.end_byte)
-                bases.append(base_text)
+                bases.extend(' '.join(base.split()) for base in split_top_level(base_text.lstrip(':')))
         
-        # Extract member count
+        # Extract members, with the access they are declared under
         methods = []
+        fields = []
         members = []
+        current_access = 'private' if kind == 'class' else 'public'
         body = node.child_by_field_name("body")
-        if body:
-            for child in body.children:
-                if child.type == "function_definition":
-                    method_name = self._extract_function_name(child, code)
-                    methods.append(method_name)
-                elif child.type == "field_declaration":
-                    member_text = self._extract_text(code, child.start_byte, child.end_byte).strip()
-                    members.append(member_text)
+        for child, template, conditions in self._class_items(body.named_children if body else [], code):
+            if child.type == "access_specifier":
+                current_access = self._extract_text(code, child.start_byte, child.end_byte).strip().rstrip(':')
+                continue
+            
+            nested = []
+            specifier = child.child_by_field_name("type")
+            if child.type == "function_definition" or (child.type in ("declaration", "field_declaration")
+                                                       and self._function_declarator(child)):
+                method = self._extract_function(child, code, filepath, namespace, class_name, template,
+                                                current_access)
+                if method is None or self._is_member_call(child, method):
+                    continue  # DISALLOW_COPY_AND_ASSIGN(Widget);
+                methods.append(method.name)
+                nested = [method]
+            elif child.type in ("field_declaration", "declaration") and specifier is not None \
+                    and specifier.child_by_field_name("body"):
+                # Nested types: struct Geometry {...};
+                if specifier.type in CLASS_SPECIFIERS:
+                    nested = self._extract_class(specifier, code, filepath, namespace, template or child,
+                                                 parent_class=class_name, access=current_access)
+                elif specifier.type == "enum_specifier":
+                    nested = [self._extract_enum(specifier, code, filepath, namespace, child,
+                                                 parent_class=class_name, access=current_access)]
+            elif child.type in CLASS_SPECIFIERS and child.child_by_field_name("body"):
+                nested = self._extract_class(child, code, filepath, namespace, template, parent_class=class_name,
+                                             access=current_access)
+            elif child.type == "field_declaration":
+                for declarator in child.children_by_field_name("declarator"):
+                    _, field_name = self._declarator_path(declarator, code)
+                    if field_name:
+                        fields.append(field_name)
+            
+            for member in nested:
+                if conditions:
+                    member.metadata['conditions'] = list(conditions) + member.metadata.get('conditions', [])
+                members.append(member)
         
         metadata = {
             'base_classes': bases,
             'methods': methods,
-            'members_count': len(members)
+            'fields': fields,
+            'members_count': len(fields)
         }
+        if outer.type == "template_declaration":
+            metadata['template'] = self._template_text(outer, code)
+        if access:
+            metadata['access'] = access
         
-        return CodeChunk(
-            type='class' if node.type == "class_specifier" else 'struct',
+        signature = f"{kind} {class_name}"
+        if bases:
+            signature += f" : {', '.join(bases)}"
+        if 'template' in metadata:
+            signature = f"{metadata['template']} {signature}"
+        
+        chunk = CodeChunk(
+            type=kind,
             name=class_name,
             content=class_text,
             filepath=filepath,
             language=self.language,
-            line_start=node.start_point[0] + 1,
-            line_end=node.end_point[0] + 1,
-            signature=f"{'class' if node.type == 'class_specifier' else 'struct'} {class_name}",
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
+            signature=signature,
             namespace=namespace,
+            parent_class=parent_class or None,
+            parent=parent_class or None,
+            doc=self._extract_doc(outer, code),
             metadata=metadata
         )
+        return [chunk] + members
     
-    def _extract_enum(self, node: Node, code: str, filepath: str, namespace: str = '') -> Optional[CodeChunk]:
+    def _extract_enum(self, node: Node, code: str, filepath: str, namespace: str = '',
+                      outer: Optional[Node] = None, typedef: Optional[str] = None,
+                      parent_class: str = '', access: Optional[str] = None) -> CodeChunk:
         """Extract enum definition"""
-        enum_text = self._extract_text(code, node.start_byte, node.end_byte)
+        outer = outer or node
+        enum_text = self._extract_text(code, outer.start_byte, outer.end_byte)
         
         name_node = node.child_by_field_name("name")
-        enum_name = self._extract_text(code, name_node.start_byte, name_node.end_byte) if name_node else "anonymous_enum"
+        enum_name = self._extract_text(code, name_node.start_byte, name_node.end_byte) if name_node else typedef
+        
+        enumerators = []
+        body = node.child_by_field_name("body")
+        for child in body.named_children:
+            if child.type == "enumerator":
+                name = child.child_by_field_name("name")
+                enumerators.append(self._extract_text(code, name.start_byte, name.end_byte))
+        
+        scoped = any(child.type in ("class", "struct") for child in node.children)
+        metadata = {'enumerators': enumerators, 'scoped': scoped}
+        if access:
+            metadata['access'] = access
         
         return CodeChunk(
             type='enum',
-            name=enum_name,
+            name=enum_name or "anonymous_enum",
             content=enum_text,
             filepath=filepath,
             language=self.language,
-            line_start=node.start_point[0] + 1,
-            line_end=node.end_point[0] + 1,
-            namespace=namespace
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
+            signature=f"enum {'class ' if scoped else ''}{enum_name or ''}".strip(),
+            namespace=namespace,
+            parent_class=parent_class or None,
+            parent=parent_class or None,
+            doc=self._extract_doc(outer, code),
+            metadata=metadata
         )
     
-    def _extract_macros(self, code: str, filepath: str) -> List[CodeChunk]:
-        """Extract #define macros using regex"""
-        import re
+    def _extract_macros(self, root: Node, code: str, filepath: str) -> List[CodeChunk]:
+        """#define macros with a value (all branches, since each configuration defines its own)"""
         chunks = []
-        
-        macro_pattern = r'^#define\s+(\w+)(?:\([^)]*\))?\s+(.*)$'
-        for i, line in enumerate(code.split('\n'), 1):
-            match = re.match(macro_pattern, line.strip())
-            if match:
-                macro_name = match.group(1)
-                full_line = line.strip()
-                
-                chunks.append(CodeChunk(
-                    type='macro',
-                    name=macro_name,
-                    content=full_line,
-                    filepath=filepath,
-                    language=self.language,
-                    line_start=i,
-                    line_end=i
-                ))
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type not in ("preproc_def", "preproc_function_def"):
+                stack.extend(reversed(node.named_children))
+                continue
+            # Without a value it is an include guard or a feature flag
+            if node.child_by_field_name("value") is None:
+                continue
+            
+            name_node = node.child_by_field_name("name")
+            metadata = {}
+            params = node.child_by_field_name("parameters")
+            if params is not None:
+                metadata['params'] = [self._extract_text(code, p.start_byte, p.end_byte) for p in params.named_children]
+            # The directive ends with its newline
+            text = self._extract_text(code, node.start_byte, node.end_byte).rstrip()
+            
+            chunks.append(CodeChunk(
+                type='macro',
+                name=self._extract_text(code, name_node.start_byte, name_node.end_byte),
+                content=text,
+                filepath=filepath,
+                language=self.language,
+                line_start=node.start_point[0] + 1,
+                line_end=node.start_point[0] + 1 + text.count('\n'),
+                signature=text.split('\n', 1)[0].rstrip('\\').strip(),
+                doc=self._extract_doc(node, code),
+                metadata=metadata
+            ))
         
         return chunks
     
-    def _extract_function_name(self, declarator_node: Optional[Node], code: str) -> str:
-        """Extract function name from declarator node"""
-        if not declarator_node:
-            return "unknown"
-        
-        # Handle different declarator types
-        if declarator_node.type == "function_declarator":
-            inner = declarator_node.child_by_field_name("declarator")
-            if inner:
-                if inner.type == "identifier":
-                    return self._extract_text(code, inner.start_byte, inner.end_byte)
-                elif inner.type == "field_identifier":
-                    return self._extract_text(code, inner.start_byte, inner.end_byte)
-                elif inner.type == "qualified_identifier":
-                    # Get the last part of qualified name
-                    name_node = inner.child_by_field_name("name")
-                    if name_node:
-                        return self._extract_text(code, name_node.start_byte, name_node.end_byte)
-                return self._extract_function_name(inner, code)
-        elif declarator_node.type == "identifier":
-            return self._extract_text(code, declarator_node.start_byte, declarator_node.end_byte)
-        
-        return self._extract_text(code, declarator_node.start_byte, declarator_node.end_byte)
+    def _live_branch(self, node: Node, code: str) -> Tuple[List[Node], Optional[str]]:
+        """
+        The declarations of the first branch of an #if/#ifdef chain that is not '#if 0',
+        and the condition they are compiled under (None for include guards and #else)
+        """
+        while node is not None:
+            if node.type == "preproc_else":
+                return list(node.named_children), None
+            alternative = node.child_by_field_name("alternative")
+            condition_node = node.child_by_field_name("condition") or node.child_by_field_name("name")
+            body = [child for child in node.named_children if child != alternative and child != condition_node]
+            condition = self._extract_text(code, condition_node.start_byte, condition_node.end_byte).strip() \
+                if condition_node else ''
+            if node.type in ("preproc_ifdef", "preproc_elifdef"):
+                negated = self._extract_text(code, node.start_byte, node.end_byte).lstrip('#').lstrip() \
+                    .startswith(('ifndef', 'elifndef'))
+                # #ifndef WIDGET_H_ / #define WIDGET_H_ guards the whole file
+                guard = body[0].child_by_field_name("name") if body and body[0].type == "preproc_def" else None
+                if negated and guard and self._extract_text(code, guard.start_byte, guard.end_byte) == condition:
+                    return body, None
+                condition = f"{'!' if negated else ''}defined({condition})"
+            if condition not in ('0', 'false'):
+                return body, condition
+            node = alternative
+        return [], None
+    
+    def _class_items(self, children: List[Node], code: str, conditions: Tuple[str, ...] = ()):
+        """(member, template, conditions) triples of a class body, through the live branch of #if blocks"""
+        for child in children:
+            if child.type in PREPROC_CONDITIONALS:
+                branch, condition = self._live_branch(child, code)
+                yield from self._class_items(branch, code, conditions + ((condition,) if condition else ()))
+            elif child.type == "template_declaration":
+                inner = self._templated(child)
+                if inner is not None:
+                    yield inner, child, conditions
+            else:
+                yield child, None, conditions
+    
+    def _templated(self, node: Node) -> Optional[Node]:
+        """The declaration a template<...> header applies to"""
+        for child in reversed(node.named_children):
+            if child.type != "template_parameter_list":
+                return child
+        return None
+    
+    def _function_declarator(self, node: Node) -> Optional[Node]:
+        """The function_declarator of a function, unless the declaration is a function pointer variable"""
+        declarator = node.child_by_field_name("declarator")
+        while declarator is not None and declarator.type in WRAPPING_DECLARATORS:
+            inner = declarator.child_by_field_name("declarator")
+            declarator = inner if inner is not None else (declarator.named_children or [None])[-1]
+        if declarator is None or declarator.type != "function_declarator":
+            return None
+        inner = declarator.child_by_field_name("declarator")
+        if inner is None or inner.type == "parenthesized_declarator":
+            return None  # void (*callback)(int);
+        return declarator
+    
+    def _declarator_path(self, node: Optional[Node], code: str) -> Tuple[List[str], str]:
+        """Scopes and name of a declarator: Widget::operator== -> (['Widget'], 'operator==')"""
+        scopes = []
+        while node is not None:
+            if node.type in NAME_NODES:
+                return scopes, self._extract_text(code, node.start_byte, node.end_byte)
+            if node.type == "qualified_identifier":
+                scope = node.child_by_field_name("scope")
+                if scope is not None:
+                    scopes.append(self._extract_text(code, scope.start_byte, scope.end_byte))
+                node = node.child_by_field_name("name")
+            elif node.type == "template_function" or node.type == "template_method":
+                node = node.child_by_field_name("name")
+            else:
+                inner = node.child_by_field_name("declarator")
+                node = inner if inner is not None else (node.named_children or [None])[0]
+        return scopes, ''
+    
+    def _extract_params(self, declarator: Node, code: str) -> List[Dict]:
+        """Parameters of a function declarator as {'name', 'type'[, 'default']}; (void) has none"""
+        params = []
+        parameters = declarator.child_by_field_name("parameters")
+        for param in parameters.children if parameters else []:
+            if param.type == "variadic_parameter_declaration" or param.type == "...":
+                params.append({'name': '...', 'type': '...'})
+                continue
+            if param.type not in ("parameter_declaration", "optional_parameter_declaration"):
+                continue
+            name_node = param.child_by_field_name("declarator")
+            default = param.child_by_field_name("default_value")
+            _, name = self._declarator_path(name_node, code) if name_node else ([], '')
+            end = default.start_byte if default else param.end_byte
+            text = self._extract_text(code, param.start_byte, end).rstrip().rstrip('=')
+            if name:
+                start = text.rfind(name)
+                param_type = text[:start] + text[start + len(name):]
+            else:
+                param_type = text
+            entry = {'name': name, 'type': ' '.join(param_type.split())}
+            if default:
+                entry['default'] = self._extract_text(code, default.start_byte, default.end_byte)
+            params.append(entry)
+        if len(params) == 1 and params[0] == {'name': '', 'type': 'void'}:
+            return []
+        return params
+    
+    def _extract_return_type(self, node: Node, declarator: Node, code: str) -> str:
+        """Return type with qualifiers and the pointer or reference of the declarator: 'const char *'"""
+        start = self._signature_start(node)
+        head = self._extract_text(code, start, declarator.start_byte)
+        return ' '.join(head.split())
+    
+    def _function_clause(self, node: Node, declarator: Node, code: str) -> Dict:
+        """What follows the parameter list: = 0, = default, = delete"""
+        tail = self._extract_text(code, declarator.end_byte, node.end_byte)
+        match = re.match(r'\s*=\s*(0|default|delete)\b', tail)
+        if not match:
+            return {}
+        return {{'0': 'pure_virtual', 'default': 'defaulted', 'delete': 'deleted'}[match.group(1)]: True}
+    
+    def _is_member_call(self, node: Node, method: CodeChunk) -> bool:
+        """A macro invocation in a class body (DISALLOW_COPY_AND_ASSIGN(Widget);), not a declaration"""
+        return (node.child_by_field_name("type") is None and not method.metadata.get('constructor')
+                and not method.metadata.get('destructor') and bool(re.fullmatch(r'[A-Z][A-Z0-9_]*', method.name)))
+    
+    def _extract_doc(self, node: Node, code: str) -> Optional[str]:
+        """Comments directly above a declaration (no blank line in between) become its doc"""
+        comments = []
+        next_start = node.start_byte
+        sibling = node.prev_sibling
+        while sibling is not None and sibling.type == "comment":
+            between = code[sibling.end_byte:next_start]
+            if between.strip() or between.count('\n') > 1:
+                break
+            comments.insert(0, sibling)
+            next_start = sibling.start_byte
+            sibling = sibling.prev_sibling
+        # A comment sharing its line with earlier code belongs to that code
+        if comments:
+            line_begin = code.rfind('\n', 0, comments[0].start_byte) + 1
+            if code[line_begin:comments[0].start_byte].strip():
+                comments = comments[1:]
+        if not comments:
+            return None
+        return comment_text([self._extract_text(code, c.start_byte, c.end_byte) for c in comments]) or None
     
     def _extract_class_name(self, node: Node, code: str) -> str:
         """Extract class name"""
@@ -235,14 +555,26 @@ class CppChunker(BaseChunker):
             return self._extract_text(code, name_node.start_byte, name_node.end_byte)
         return "anonymous"
     
-    def _extract_signature(self, func_node: Node, code: str) -> str:
-        """Extract function signature"""
-        return_type = func_node.child_by_field_name("type")
-        declarator = func_node.child_by_field_name("declarator")
-        
-        if return_type and declarator:
-            ret_text = self._extract_text(code, return_type.start_byte, return_type.end_byte)
-            decl_text = self._extract_text(code, declarator.start_byte, declarator.end_byte)
-            return f"{ret_text} {decl_text}"
-        
-        return "unknown signature"
+    def _template_text(self, node: Node, code: str) -> str:
+        """The template<...> header of a template declaration"""
+        params = node.child_by_field_name("parameters")
+        end = params.end_byte if params else node.start_byte
+        return ' '.join(self._extract_text(code, node.start_byte, end).split())
+    
+    def _signature_start(self, func_node: Node) -> int:
+        """Start of the return type, including cv-qualifiers before it"""
+        start = func_node.child_by_field_name("type").start_byte
+        for child in func_node.children:
+            if child.start_byte >= start:
+                break
+            if child.type == "type_qualifier":
+                return child.start_byte
+        return start
+    
+    def _extract_signature(self, func_node: Node, declarator: Node, code: str) -> str:
+        """Extract function signature: return type and declarator, without the body"""
+        start = self._signature_start(func_node) if func_node.child_by_field_name("type") else None
+        outer = func_node.child_by_field_name("declarator")
+        if start is None:
+            start = outer.start_byte
+        return ' '.join(self._extract_text(code, start, outer.end_byte).split())
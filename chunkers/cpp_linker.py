@@ -0,0 +1,93 @@
+#!/usr/bin/env python3
+"""
+Cross-file analysis for C and C++ chunks
+Links function declarations (usually in headers) to their definitions
+(usually in the matching source file)
+"""
+
+import os
+import re
+from collections import defaultdict
+from typing import Dict, List, Tuple
+
+from .base_chunker import CodeChunk
+
+
+CALLABLE_KINDS = ('function', 'method')
+
+
+def link_cpp_declarations(chunks: List[CodeChunk]) -> List[CodeChunk]:
+    """
+    Link C/C++ declarations and definitions across files
+    
+    A declaration and a definition match when they share their class, name and
+    parameter types. When several definitions match, the one in the same
+    namespace wins, then the one in a file with the same stem (widget.h and
+    widget.cc), then the one in the same directory; remaining ties are left
+    unlinked. Declarations get 'defined_at' and definitions 'declared_at',
+    each a reference {'filepath', 'line', 'symbol_id'}.
+    
+    Args:
+        chunks: C/C++ chunks from any number of files
+    
+    Returns:
+        The same chunks, with link metadata filled in
+    """
+    declarations: Dict[Tuple, List[CodeChunk]] = defaultdict(list)
+    definitions: Dict[Tuple, List[CodeChunk]] = defaultdict(list)
+    for chunk in chunks:
+        metadata = chunk.metadata or {}
+        if chunk.type not in CALLABLE_KINDS or 'definition' not in metadata or metadata.get('macro'):
+            continue
+        if metadata.get('pure_virtual') or metadata.get('deleted'):
+            continue
+        key = (chunk.parent or '', chunk.name, param_types(metadata))
+        if metadata['definition']:
+            definitions[key].append(chunk)
+        else:
+            declarations[key].append(chunk)
+    
+    for key, decls in declarations.items():
+        candidates = definitions.get(key)
+        if not candidates:
+            continue
+        for decl in decls:
+            definition = best_match(decl, candidates)
+            if definition is None:
+                continue
+            decl.metadata['defined_at'] = symbol_ref(definition)
+            definition.metadata.setdefault('declared_at', symbol_ref(decl))
+    return chunks
+
+
+def param_types(metadata: Dict) -> Tuple[str, ...]:
+    """Parameter types as compared across files: 'const Foo &' and 'const Foo&' are the same"""
+    return tuple(re.sub(r'\s*([*&])\s*', r'\1', p.get('type', '')) for p in metadata.get('params', []))
+
+
+def best_match(decl: CodeChunk, candidates: List[CodeChunk]):
+    """The definition that most likely belongs to a declaration, or None if ambiguous"""
+    def score(definition: CodeChunk) -> int:
+        same_namespace = (definition.namespace or '') == (decl.namespace or '')
+        same_stem = file_stem(definition.filepath) == file_stem(decl.filepath)
+        same_dir = os.path.dirname(definition.filepath) == os.path.dirname(decl.filepath)
+        return same_namespace * 4 + same_stem * 2 + same_dir
+    
+    ranked = sorted(candidates, key=score, reverse=True)
+    if len(ranked) > 1 and score(ranked[0]) == score(ranked[1]):
+        return None
+    return ranked[0]
+
+
+def file_stem(filepath: str) -> str:
+    """Path without its extension: widget.h and widget.cc share 'ui/widget'"""
+    return os.path.splitext(filepath)[0]
+
+
+def symbol_ref(chunk: CodeChunk) -> Dict:
+    """Reference to a declaration or definition"""
+    return {
+        'filepath': chunk.filepath,
+        'line': chunk.line_start,
+        'symbol_id': chunk.symbol_id or chunk.default_symbol_id(),
+    }
@@ -0,0 +1,685 @@
+#!/usr/bin/env python3
+"""
+C# code chunker using tree-sitter for accurate parsing
+Supports .cs files
+
+Type declarations are walked recursively through their bodies; method bodies,
+lambdas and initializers are not entered. Block and file-scoped namespaces
+become the namespace of their members, /// XML doc comments fill the doc field
+and attributes are kept in the metadata. The parts of a partial type are
+linked across files afterwards (see csharp_linker).
+"""
+
+import re
+from bisect import bisect_right
+from dataclasses import dataclass
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+# Declaration nodes and the chunk type they become
+TYPE_DECLARATIONS = {
+    'class_declaration': 'class',
+    'struct_declaration': 'struct',
+    'interface_declaration': 'interface',
+    'enum_declaration': 'enum',
+    'record_declaration': 'record',
+    'record_struct_declaration': 'record',
+}
+
+METHOD_DECLARATIONS = (
+    'method_declaration', 'constructor_declaration', 'destructor_declaration',
+    'operator_declaration', 'conversion_operator_declaration',
+)
+
+# Events with add/remove accessors are chunked like properties, event fields like fields
+PROPERTY_DECLARATIONS = ('property_declaration', 'indexer_declaration', 'event_declaration')
+
+FIELD_DECLARATIONS = ('field_declaration', 'event_field_declaration')
+
+NAMESPACE_DECLARATIONS = ('namespace_declaration', 'file_scoped_namespace_declaration')
+
+# Modifiers of a parameter: ref int x, this string s, params object[] args
+PARAM_MODIFIERS = {'ref', 'out', 'in', 'params', 'this', 'scoped', 'readonly'}
+
+# Accessors of properties, indexers and events
+ACCESSORS = ('get', 'set', 'init', 'add', 'remove')
+
+# Attribute targets that apply to the assembly, not to the declaration that follows
+GLOBAL_ATTRIBUTE_TARGETS = ('assembly', 'module')
+
+
+@dataclass
+class CSharpComment:
+    """A comment with its source span"""
+    text: str
+    start: int
+    end: int
+    
+    @property
+    def is_doc(self) -> bool:
+        """/// XML doc lines, or a /** */ doc block"""
+        if self.text.startswith('///'):
+            return not self.text.startswith('////')
+        return self.text.startswith('/**') and self.text != '/**/'
+
+
+def xml_doc_text(comments: List[CSharpComment]) -> str:
+    """
+    Text of an XML doc comment: the <summary> and <remarks> prose, then one
+    '@tag name text' line per <param>, <typeparam>, <returns> and <exception>
+    """
+    lines = []
+    for comment in comments:
+        text = comment.text
+        if text.startswith('/**'):
+            for line in text[3:-2].splitlines():
+                line = line.strip()
+                lines.append(line[1:].strip() if line.startswith('*') else line)
+        else:
+            lines.append(text[3:].strip())
+    raw = '\n'.join(lines)
+    
+    # Inline references keep their target: <see cref="T:Auth.User"/> -> User, <paramref name="x"/> -> x
+    raw = re.sub(r'<(?:see|seealso)\s+(?:cref|href|langword)="(?:\w:)?([^"]*)"\s*/>',
+                 lambda m: m.group(1).rsplit('.', 1)[-1] if '(' not in m.group(1) else m.group(1), raw)
+    raw = re.sub(r'<(?:paramref|typeparamref)\s+name="([^"]*)"\s*/>', r'\1', raw)
+    raw = re.sub(r'<para\s*/?>|</para>', '\n\n', raw)
+    
+    def clean(body: str) -> str:
+        body = re.sub(r'</?\w+(?:\s+\w+="[^"]*")*\s*/?>', '', body)
+        paragraphs = re.split(r'\n\s*\n', body)
+        return '\n\n'.join(' '.join(p.split()) for p in paragraphs if p.strip())
+    
+    prose, tags = [], []
+    sections = list(re.finditer(r'<(\w+)((?:\s+\w+="[^"]*")*)\s*(?:/>|>(.*?)</\1>)', raw, re.DOTALL))
+    for section in sections:
+        tag, attributes, body = section.group(1), section.group(2), clean(section.group(3) or '')
+        target = re.search(r'(?:name|cref)="(?:\w:)?([^"]*)"', attributes or '')
+        if tag in ('param', 'typeparam', 'exception'):
+            if target:
+                tags.append(f"@{tag} {target.group(1)} {body}".rstrip())
+        elif tag in ('returns', 'value'):
+            tags.append(f"@{tag} {body}".rstrip())
+        elif tag == 'inheritdoc':
+            tags.append('@inheritdoc')
+        elif body:
+            prose.append(body)
+    if not sections:
+        prose.append(clean(raw))
+    return '\n\n'.join(block for block in ('\n\n'.join(prose), '\n'.join(tags)) if block)
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)>\]])|([(<\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def identifier(value: str) -> str:
+    """Name of a verbatim identifier (@class -> class)"""
+    return value[1:] if value.startswith('@') else value
+
+
+class CSharpChunker(BaseChunker):
+    """Extracts namespaces, classes, structs, interfaces, records, enums, methods, properties and fields from C# code"""
+    
+    def __init__(self):
+        super().__init__('csharp')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('csharp')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all C# code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._comments = self._collect_comments(tree.root_node)
+        self._comment_ends = [c.end for c in self._comments]
+        chunks: List[CodeChunk] = []
+        
+        self._parse_members(tree.root_node.children, [], [], None, chunks)
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Namespaces and members
+    # ------------------------------------------------------------------
+    
+    def _parse_members(self, children: List[Node], namespace: List[str], parents: List[str],
+                       owner: Optional[Dict], chunks: List[CodeChunk]):
+        """Extract the declarations among the children of a file, a namespace or a type body"""
+        for i, child in enumerate(children):
+            if child.type == 'ERROR':
+                self._parse_members(child.children, namespace, parents, owner, chunks)
+                if owner is not None:
+                    self._salvage_methods(child, namespace, parents, owner, chunks)
+            elif child.type in NAMESPACE_DECLARATIONS and owner is None:
+                if child.type == 'file_scoped_namespace_declaration':
+                    # namespace A.B; runs to the end of the file, whether or not the
+                    # grammar nests the declarations after it
+                    self._extract_namespace(child, children[i + 1:], namespace, chunks)
+                    return
+                self._extract_namespace(child, [], namespace, chunks)
+            elif child.type in TYPE_DECLARATIONS:
+                self._extract_type(child, namespace, parents, chunks)
+            elif child.type == 'delegate_declaration':
+                self._extract_delegate(child, namespace, parents, chunks)
+            elif owner is None:
+                pass  # using directives, global attributes and top-level statements (Program.cs)
+            elif child.type in METHOD_DECLARATIONS:
+                self._extract_method(child, namespace, parents, owner, chunks)
+            elif child.type in PROPERTY_DECLARATIONS:
+                self._extract_property(child, namespace, parents, chunks)
+            elif child.type in FIELD_DECLARATIONS:
+                self._extract_field(child, namespace, parents, chunks)
+    
+    def _extract_namespace(self, node: Node, following: List[Node], namespace: List[str], chunks: List[CodeChunk]):
+        """namespace A.B { ... } or the file-scoped namespace A.B; and the declarations that follow it"""
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        name = re.sub(r'\s+', '', self._text(name_node))
+        path = namespace + [name]
+        body = node.child_by_field_name('body')
+        before = len(chunks)
+        self._parse_members((body if body is not None else node).children + following, path, [], None, chunks)
+        
+        # Namespace chunks hold only the declaration line and a member list:
+        # the block itself is already covered by its members' chunks
+        semicolon = next((c for c in node.children if c.type == ';'), None)
+        header_end = body.start_byte + 1 if body is not None else semicolon.end_byte if semicolon is not None \
+            else name_node.end_byte
+        last = following[-1] if following else node
+        chunk = CodeChunk(
+            type='namespace',
+            name=name,
+            content=self._decode(node.start_byte, header_end),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(last),
+            signature=normalize_signature(self._decode(node.start_byte, name_node.end_byte)),
+            namespace='.'.join(namespace) or None,
+            metadata={'members': [c.name for c in chunks[before:]
+                                  if c.namespace == '.'.join(path) and not c.parent and c.type != 'namespace']}
+        )
+        if body is None:
+            chunk.metadata['file_scoped'] = True
+        self._attach_doc(chunk, node.start_byte, [])
+        chunks.append(chunk)
+    
+    def _extract_type(self, node: Node, namespace: List[str], parents: List[str], chunks: List[CodeChunk]):
+        kind = TYPE_DECLARATIONS[node.type]
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        name = identifier(self._text(name_node))
+        attributes, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        if kind == 'record' and (node.type == 'record_struct_declaration'
+                                 or any(c.type == 'struct' for c in node.children)):
+            metadata['record_struct'] = True
+        
+        type_params = node.child_by_field_name('type_parameters')
+        if type_params is not None:
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        params = next((c for c in node.named_children if c.type == 'parameter_list'), None)
+        if params is not None:
+            # Primary constructor: record Point(int X, int Y), class Service(ILogger log)
+            metadata['params'] = self._parse_params(params)
+        
+        # Base types, then generic constraints: class Repo<T> : IRepo<T> where T : IEntity, new()
+        base_list = next((c for c in node.named_children if c.type == 'base_list'), None)
+        if base_list is not None:
+            bases = [normalize_signature(self._text(c)) for c in base_list.named_children if c.type != 'comment']
+            bases = [re.sub(r'\(.*\)$', '', b) for b in bases]  # record Admin(string N) : User(N)
+            if kind == 'enum':
+                metadata['underlying_type'] = bases[0] if bases else ''
+            else:
+                metadata['bases'] = bases
+        constraints = self._parse_constraints(node)
+        if constraints:
+            metadata['constraints'] = constraints
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        body = node.child_by_field_name('body')
+        chunk = CodeChunk(
+            type=kind,
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._signature(node, body)),
+            namespace='.'.join(namespace) or None,
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node.start_byte, attributes)
+        chunks.append(chunk)
+        
+        if body is None:
+            return
+        if kind == 'enum':
+            # Success = 200, NotFound
+            metadata['constants'] = [identifier(self._text(m.child_by_field_name('name'))) for m in body.named_children
+                                     if m.type == 'enum_member_declaration' and m.child_by_field_name('name')]
+            return
+        owner = {'kind': kind, 'name': name}
+        before = len(chunks)
+        self._parse_members(body.children, namespace, parents + [name], owner, chunks)
+        members = [c for c in chunks[before:] if c.parent == '.'.join(parents + [name])]
+        metadata['methods'] = [c.name for c in members if c.type == 'method']
+        properties = [c.name for c in members if c.type == 'property']
+        if properties:
+            metadata['properties'] = properties
+    
+    def _extract_delegate(self, node: Node, namespace: List[str], parents: List[str], chunks: List[CodeChunk]):
+        """delegate TResult Handler<T>(T arg) where T : class;"""
+        name_node = node.child_by_field_name('name')
+        params = node.child_by_field_name('parameters')
+        if name_node is None or params is None:
+            return
+        attributes, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        type_params = node.child_by_field_name('type_parameters')
+        if type_params is not None:
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        returns = node.child_by_field_name('type')
+        metadata['returns'] = normalize_signature(self._text(returns)) if returns is not None else ''
+        metadata['params'] = self._parse_params(params)
+        constraints = self._parse_constraints(node)
+        if constraints:
+            metadata['constraints'] = constraints
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        chunk = CodeChunk(
+            type='delegate',
+            name=identifier(self._text(name_node)),
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._signature(node, None)),
+            namespace='.'.join(namespace) or None,
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node.start_byte, attributes)
+        chunks.append(chunk)
+    
+    def _extract_method(self, node: Node, namespace: List[str], parents: List[str], owner: Dict,
+                        chunks: List[CodeChunk]):
+        attributes, modifiers = self._modifiers(node)
+        params = node.child_by_field_name('parameters')
+        metadata: Dict = {}
+        returns = node.child_by_field_name('returns') or node.child_by_field_name('type')
+        
+        if node.type == 'operator_declaration':
+            # operator +, operator ==
+            keyword = next((k for k, c in enumerate(node.children) if c.type == 'operator'), None)
+            operator = node.child_by_field_name('operator') \
+                or (node.children[keyword + 1] if keyword is not None and keyword + 1 < len(node.children) else None)
+            if operator is None:
+                return
+            name = 'operator ' + self._text(operator)
+        elif node.type == 'conversion_operator_declaration':
+            # implicit operator string: the type converted to is its name
+            conversion = next((c.type for c in node.children if c.type in ('implicit', 'explicit')), None)
+            if conversion:
+                modifiers.append(conversion)
+            if returns is None:
+                return
+            name = 'operator ' + normalize_signature(self._text(returns))
+            returns = None
+        else:
+            name_node = node.child_by_field_name('name')
+            if name_node is None:
+                return
+            name = identifier(self._text(name_node))
+            type_params = node.child_by_field_name('type_parameters')
+            if type_params is not None:
+                metadata['type_params'] = normalize_signature(self._text(type_params))
+            interface = next((c for c in node.named_children if c.type == 'explicit_interface_specifier'), None)
+            if interface is not None:
+                # Explicit interface implementation: void IDisposable.Dispose()
+                metadata['explicit_interface'] = normalize_signature(self._text(interface).rstrip().rstrip('.'))
+            if node.type == 'destructor_declaration':
+                name = '~' + name
+                metadata['destructor'] = True
+        
+        if node.type == 'constructor_declaration':
+            metadata['constructor'] = True
+        elif returns is not None:
+            metadata['returns'] = normalize_signature(self._text(returns))
+        
+        metadata['params'] = self._parse_params(params) if params is not None else []
+        if metadata['params'] and metadata['params'][0].get('modifier') == 'this':
+            metadata['receiver'] = metadata['params'][0]['type']  # an extension method
+        constraints = self._parse_constraints(node)
+        if constraints:
+            metadata['constraints'] = constraints
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        body = self._body(node)
+        if body is None and owner['kind'] == 'interface' \
+                and not {'abstract', 'static', 'extern', 'partial'} & set(modifiers):
+            metadata['abstract'] = True
+        
+        chunk = CodeChunk(
+            type='method',
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._signature(node, body)),
+            namespace='.'.join(namespace) or None,
+            parent_class=parents[-1],
+            parent='.'.join(parents),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node.start_byte, attributes)
+        chunks.append(chunk)
+    
+    def _salvage_methods(self, error: Node, namespace: List[str], parents: List[str], owner: Dict,
+                         chunks: List[CodeChunk]):
+        """Methods the parser could not finish, like void Run(int x, [Attr at the end of a file"""
+        children = error.children
+        for k, child in enumerate(children):
+            if child.type != '(' or k == 0 or children[k - 1].type != 'identifier':
+                continue
+            first = k - 1
+            while first > 0 and children[first - 1].type not in (';', '{', '}', 'ERROR') + tuple(TYPE_DECLARATIONS) \
+                    + METHOD_DECLARATIONS + PROPERTY_DECLARATIONS + FIELD_DECLARATIONS:
+                first -= 1
+            _, modifiers = self._modifiers_of(children[first:k])
+            metadata: Dict = {'params': self._parse_param_groups(children[k + 1:])}
+            if modifiers:
+                metadata['modifiers'] = modifiers
+            parts = children[first:]
+            chunks.append(CodeChunk(
+                type='method',
+                name=identifier(self._text(children[k - 1])),
+                content=self._decode(parts[0].start_byte, parts[-1].end_byte),
+                filepath=self._filepath,
+                language=self.language,
+                line_start=self._line(parts[0]),
+                line_end=self._line_end(parts[-1]),
+                signature=normalize_signature(self._decode(self._signature_start(parts), parts[-1].end_byte)),
+                namespace='.'.join(namespace) or None,
+                parent_class=parents[-1],
+                parent='.'.join(parents),
+                metadata=metadata
+            ))
+    
+    def _extract_property(self, node: Node, namespace: List[str], parents: List[str], chunks: List[CodeChunk]):
+        attributes, modifiers = self._modifiers(node)
+        if node.type == 'event_declaration':
+            modifiers.append('event')
+        metadata: Dict = {}
+        accessors = []
+        accessor_list = node.child_by_field_name('accessors') \
+            or next((c for c in node.named_children if c.type == 'accessor_list'), None)
+        if accessor_list is not None:
+            # Accessors, each with its modifiers: { get; private set; }
+            for accessor in accessor_list.named_children:
+                keyword = next((c for c in accessor.children if c.type in ACCESSORS), None)
+                if accessor.type == 'accessor_declaration' and keyword is not None:
+                    accessors.append(' '.join(self._modifiers(accessor)[1] + [keyword.type]))
+            body = accessor_list
+        else:
+            body = next((c for c in node.named_children if c.type == 'arrow_expression_clause'), None)
+            accessors.append('get')
+            metadata['expression_bodied'] = True
+        
+        if node.type == 'indexer_declaration':
+            name = 'this'
+            params = node.child_by_field_name('parameters') \
+                or next((c for c in node.named_children if c.type == 'bracketed_parameter_list'), None)
+            metadata['params'] = self._parse_params(params) if params is not None else []
+        else:
+            name_node = node.child_by_field_name('name')
+            if name_node is None:
+                return
+            name = identifier(self._text(name_node))
+        interface = next((c for c in node.named_children if c.type == 'explicit_interface_specifier'), None)
+        if interface is not None:
+            metadata['explicit_interface'] = normalize_signature(self._text(interface).rstrip().rstrip('.'))
+        property_type = node.child_by_field_name('type')
+        if property_type is not None:
+            metadata['property_type'] = normalize_signature(self._text(property_type))
+        if accessors:
+            metadata['accessors'] = accessors
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        signature = normalize_signature(self._signature(node, body))
+        if accessor_list is not None and accessors:
+            signature += ' { ' + ' '.join(f"{a};" for a in accessors) + ' }'
+        chunk = CodeChunk(
+            type='property',
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=signature,
+            namespace='.'.join(namespace) or None,
+            parent_class=parents[-1],
+            parent='.'.join(parents),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node.start_byte, attributes)
+        chunks.append(chunk)
+    
+    def _extract_field(self, node: Node, namespace: List[str], parents: List[str], chunks: List[CodeChunk]):
+        declaration = next((c for c in node.named_children if c.type == 'variable_declaration'), None)
+        if declaration is None:
+            return
+        field_type = declaration.child_by_field_name('type')
+        declarators = [c for c in declaration.named_children if c.type == 'variable_declarator']
+        names = [self._declarator_name(d) for d in declarators]
+        names = [n for n in names if n is not None]
+        if field_type is None or not names:
+            return
+        attributes, modifiers = self._modifiers(node)
+        if node.type == 'event_field_declaration':
+            modifiers.append('event')
+        
+        # Type, then one or more declarators: int a = 1, b;
+        metadata: Dict = {'field_type': normalize_signature(self._text(field_type))}
+        if len(names) > 1:
+            metadata['names'] = [identifier(self._text(n)) for n in names]
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        chunk = CodeChunk(
+            type='constant' if 'const' in modifiers else 'field',
+            name=identifier(self._text(names[0])),
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(self._signature_start(node.children), names[0].end_byte)),
+            namespace='.'.join(namespace) or None,
+            parent_class=parents[-1],
+            parent='.'.join(parents),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node.start_byte, attributes)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _modifiers(self, node: Node) -> Tuple[List[str], List[str]]:
+        """Attributes and modifier keywords of a declaration"""
+        return self._modifiers_of(node.children)
+    
+    def _modifiers_of(self, children: List[Node]) -> Tuple[List[str], List[str]]:
+        attributes, modifiers = [], []
+        for child in children:
+            if child.type == 'attribute_list':
+                attributes.extend(self._attributes(child))
+            elif child.type == 'modifier':
+                modifiers.append(self._text(child))
+        return attributes, modifiers
+    
+    def _attributes(self, attribute_list: Node) -> List[str]:
+        """[Route("x"), Obsolete] as ['Route("x")', 'Obsolete']; [return: NotNull] keeps its target"""
+        target = next((c for c in attribute_list.named_children if c.type == 'attribute_target_specifier'), None)
+        target_name = self._text(target).rstrip(':').strip() if target is not None else None
+        if target_name in GLOBAL_ATTRIBUTE_TARGETS:
+            return []
+        attributes = []
+        for attribute in attribute_list.named_children:
+            if attribute.type == 'attribute':
+                text = normalize_signature(self._text(attribute))
+                attributes.append(f"{target_name}: {text}" if target_name else text)
+        return attributes
+    
+    def _parse_params(self, node: Node) -> List[Dict]:
+        """(name, type) pairs of a parameter list, with ref/out/in/params/this as their 'modifier'"""
+        inner = node.children
+        if inner and inner[0].type in ('(', '['):
+            inner = inner[1:]
+        return self._parse_param_groups(inner)
+    
+    def _parse_param_groups(self, children: List[Node]) -> List[Dict]:
+        params = []
+        groups: List[List[Node]] = [[]]
+        for child in children:
+            if child.type == ',':
+                groups.append([])
+            elif child.type in (')', ']'):
+                break
+            elif child.type in ('parameter', 'parameter_array'):
+                groups[-1].extend(child.children)
+            elif child.type != 'comment':
+                groups[-1].append(child)
+        for group in groups:
+            # Drop parameter attributes and defaults (= 0), and the pieces an unfinished parameter leaves;
+            # an attribute left unclosed runs to the end of the parameter
+            group = [c for c in group if c.type != 'attribute_list' and c.end_byte > c.start_byte]
+            group = group[:next((n for n, c in enumerate(group) if c.type == '['), len(group))]
+            eq = next((n for n, c in enumerate(group) if c.type in ('=', 'equals_value_clause')), len(group))
+            declarator = group[:eq]
+            modifiers = []
+            while len(declarator) > 2 and self._text(declarator[0]) in PARAM_MODIFIERS:
+                modifiers.append(self._text(declarator[0]))
+                declarator = declarator[1:]
+            if len(declarator) < 2 or declarator[-1].type != 'identifier':
+                continue
+            param = {
+                'name': identifier(self._text(declarator[-1])),
+                'type': normalize_signature(self._decode(declarator[0].start_byte, declarator[-2].end_byte))
+            }
+            if modifiers:
+                param['modifier'] = ' '.join(modifiers)
+            params.append(param)
+        return params
+    
+    def _parse_constraints(self, node: Node) -> Dict[str, List[str]]:
+        """where T : class, new() clauses of a declaration, by type parameter"""
+        constraints = {}
+        for clause in node.named_children:
+            if clause.type != 'type_parameter_constraints_clause':
+                continue
+            colon = next((k for k, c in enumerate(clause.children) if c.type == ':'), None)
+            target = clause.child_by_field_name('target') \
+                or next((c for c in clause.named_children if c.type == 'identifier'), None)
+            if colon is None or target is None:
+                continue
+            constraints[self._text(target)] = [normalize_signature(self._text(c)) for c in clause.children[colon + 1:]
+                                               if c.type not in (',', 'comment')]
+        return constraints
+    
+    def _declarator_name(self, declarator: Node) -> Optional[Node]:
+        return declarator.child_by_field_name('name') \
+            or next((c for c in declarator.named_children if c.type == 'identifier'), None)
+    
+    def _body(self, node: Node) -> Optional[Node]:
+        """The block or => expression of a member, if it has one"""
+        return next((c for c in node.named_children if c.type in ('block', 'arrow_expression_clause')), None)
+    
+    def _signature_start(self, children: List[Node]) -> int:
+        """Offset of the first modifier or declaration token after a run of attributes"""
+        for child in children:
+            if child.type not in ('attribute_list', 'comment'):
+                return child.start_byte
+        return children[-1].end_byte
+    
+    def _signature(self, node: Node, body: Optional[Node]) -> str:
+        """A declaration up to its body (or its ';'), without leading attributes"""
+        end = body.start_byte if body is not None else node.end_byte
+        text = self._decode(self._signature_start(node.children), end)
+        return text.rstrip().rstrip(';')
+    
+    def _collect_comments(self, root: Node) -> List[CSharpComment]:
+        comments = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type == 'comment':
+                comments.append(CSharpComment(self._text(node).rstrip('\r\n'), node.start_byte, node.end_byte))
+            else:
+                stack.extend(node.children)
+        return sorted(comments, key=lambda c: c.start)
+    
+    def _attach_doc(self, chunk: CodeChunk, start: int, attributes: List[str]):
+        """XML doc comment directly before the declaration (or its attributes); flags [Obsolete]"""
+        next_start = start
+        position = bisect_right(self._comment_ends, next_start) - 1
+        doc_comments = []
+        while position >= 0:
+            comment = self._comments[position]
+            if not comment.is_doc or self._source[comment.end:next_start].strip():
+                break
+            doc_comments.insert(0, comment)
+            if comment.text.startswith('/**'):
+                break
+            next_start = comment.start
+            position -= 1
+        if doc_comments:
+            chunk.doc = xml_doc_text(doc_comments) or None
+        
+        obsolete = next((a for a in attributes if re.match(r'(?:System\.)?Obsolete(?:Attribute)?\b', a)), None)
+        if obsolete:
+            message = re.search(r'"((?:[^"\\]|\\.)*)"', obsolete)
+            chunk.metadata['deprecated'] = message.group(1) if message else 'deprecated'
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
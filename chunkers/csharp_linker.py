@@ -0,0 +1,77 @@
+#!/usr/bin/env python3
+"""
+Cross-file analysis for C# chunks
+Joins the parts of a partial type (class Form1 in Form1.cs and Form1.Designer.cs)
+into one logical type
+"""
+
+from collections import defaultdict
+from typing import Dict, List, Tuple
+
+from .base_chunker import CodeChunk
+from .cpp_linker import symbol_ref
+
+
+PARTIAL_KINDS = ('class', 'struct', 'interface', 'record')
+
+# Member lists merged across the parts of a type
+MEMBER_LISTS = ('methods', 'properties')
+
+
+def link_csharp_partials(chunks: List[CodeChunk]) -> List[CodeChunk]:
+    """
+    Link the parts of C# partial types across files
+    
+    Parts match when they share their namespace and qualified name (nested
+    types included: Outer.Inner). Every part gets 'partial_parts', references
+    {'filepath', 'line', 'symbol_id'} to the other parts, and the member lists
+    of all of them; members of every part get 'owner', a reference to the
+    first part (by path and line), so they resolve to the same logical type
+    whichever file declares them.
+    
+    Args:
+        chunks: C# chunks from any number of files
+    
+    Returns:
+        The same chunks, with link metadata filled in
+    """
+    parts: Dict[Tuple, List[CodeChunk]] = defaultdict(list)
+    for chunk in chunks:
+        if chunk.type in PARTIAL_KINDS and 'partial' in (chunk.metadata or {}).get('modifiers', []):
+            parts[type_key(chunk.repo, chunk.namespace, chunk.qualified_name)].append(chunk)
+    
+    owners: Dict[Tuple, Dict] = {}
+    for key, group in parts.items():
+        group.sort(key=lambda c: (c.filepath, c.line_start))
+        if len(group) < 2:
+            continue
+        owners[key] = symbol_ref(group[0])
+        merged = {name: _union(c.metadata.get(name, []) for c in group) for name in MEMBER_LISTS}
+        for part in group:
+            part.metadata['partial_parts'] = [symbol_ref(other) for other in group if other is not part]
+            for name, members in merged.items():
+                if members:
+                    part.metadata[name] = members
+    
+    for chunk in chunks:
+        if not chunk.parent:
+            continue
+        owner = owners.get(type_key(chunk.repo, chunk.namespace, chunk.parent))
+        if owner is not None:
+            chunk.metadata['owner'] = owner
+    return chunks
+
+
+def type_key(repo, namespace, qualified_name) -> Tuple:
+    """Identity of a logical type across files"""
+    return (repo or '', namespace or '', qualified_name or '')
+
+
+def _union(lists) -> List[str]:
+    """Names of several lists in first-seen order, without duplicates (partial methods are declared twice)"""
+    seen = []
+    for names in lists:
+        for name in names:
+            if name not in seen:
+                seen.append(name)
+    return seen
@@ -0,0 +1,669 @@
+#!/usr/bin/env python3
+"""
+Dart code chunker using tree-sitter for accurate parsing
+Supports .dart files (Flutter included)
+
+The grammar leaves a declaration's signature and its body (or ';') side by
+side, so the children of a file or of a body are grouped into declarations
+at each body, ';' and type declaration; function bodies are not entered.
+Class, mixin, enum and extension bodies are walked for their members:
+methods, getters and setters, operators, fields and the unnamed, named and
+factory constructors. Classes extending a *Widget class are marked as
+widgets, and State<T> subclasses record the widget they are the state of.
+"""
+
+import re
+from bisect import bisect_right
+from dataclasses import dataclass
+from typing import Dict, List, Optional, Set, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+MEMBER_MODIFIERS = {
+    'static', 'const', 'final', 'late', 'external', 'covariant', 'factory', 'abstract', 'var', 'augment',
+}
+
+# Class modifiers only count before 'class' or 'mixin' (interface and sealed are identifiers elsewhere)
+CLASS_MODIFIERS = {'abstract', 'base', 'final', 'interface', 'sealed', 'mixin'}
+
+# Type declarations and the chunk type they become
+TYPE_DEFINITIONS = {
+    'class_definition': 'class',
+    'mixin_declaration': 'mixin',
+    'enum_declaration': 'enum',
+    'extension_declaration': 'extension',
+    'extension_type_declaration': 'type',
+}
+
+CONSTRUCTORS = (
+    'constructor_signature', 'constant_constructor_signature', 'factory_constructor_signature',
+    'redirecting_factory_constructor_signature',
+)
+
+SIGNATURES = ('function_signature', 'getter_signature', 'setter_signature', 'operator_signature') + CONSTRUCTORS
+
+# Names declared by a field or variable: a, b = 1
+DECLARATORS = ('initialized_identifier_list', 'static_final_declaration_list')
+
+# Nodes around a member's signature: modifiers, initializer lists and redirections are their children
+MEMBER_WRAPPERS = ('declaration', 'method_signature')
+
+DIRECTIVES = (
+    'library_name', 'import_or_export', 'library_import', 'library_export', 'part_directive',
+    'part_of_directive', 'script_tag',
+)
+
+BODIES = ('class_body', 'extension_body', 'enum_body')
+
+# Children ending an extension's or mixin's 'on' clause
+CLAUSE_ENDS = ('interfaces', '{', ';') + BODIES
+
+CLAUSE_KEYWORDS = ('extends', 'with', 'implements', 'on')
+
+PARAMETER_LISTS = (
+    'normal_formal_parameters', 'optional_formal_parameters', 'optional_positional_formal_parameters',
+    'named_formal_parameters',
+)
+
+# A parameter with its default value: [int x = 0] and {int x = 0}
+DEFAULT_PARAMETERS = ('default_formal_parameter', 'default_named_parameter')
+
+ANNOTATIONS = ('annotation', 'marker_annotation')
+
+COMMENTS = ('comment', 'documentation_comment')
+
+
+@dataclass
+class DartComment:
+    """A comment with its line span"""
+    text: str
+    start: int
+    end: int
+    line_start: int
+    line_end: int
+    
+    @property
+    def is_doc(self) -> bool:
+        return self.text.startswith('///') or (self.text.startswith('/**') and self.text != '/**/')
+
+
+def doc_text(comments: List[DartComment]) -> str:
+    """Text of a /// comment run or a /** */ comment without the markers and leading asterisks"""
+    lines = []
+    if comments[0].text.startswith('///'):
+        for comment in comments:
+            line = comment.text[3:]
+            lines.append((line[1:] if line.startswith(' ') else line).rstrip())
+    else:
+        for line in comments[0].text[3:-2].splitlines():
+            line = line.strip()
+            if line.startswith('*'):
+                line = line[1:]
+                line = line[1:] if line.startswith(' ') else line
+            lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)>\]?])|([(<\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def base_type(type_text: str) -> str:
+    """A type without its type arguments or nullability: List<T>? -> List"""
+    return re.split(r'[<?\s]', type_text, 1)[0]
+
+
+class DartChunker(BaseChunker):
+    """Extracts classes, mixins, extensions, enums, functions, methods, constructors and fields from Dart code"""
+    
+    def __init__(self):
+        super().__init__('dart')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('dart')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Dart code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        comments = self._collect_comments(tree.root_node)
+        self._docs_by_end = self._doc_comments(comments)
+        self._comment_ends = sorted(c.end for c in comments)
+        self._library = ''
+        chunks: List[CodeChunk] = []
+        
+        self._parse_members(tree.root_node, [], None, chunks)
+        
+        if self._library:
+            for chunk in chunks:
+                chunk.namespace = self._library
+                chunk.metadata['library'] = self._library
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Members
+    # ------------------------------------------------------------------
+    
+    def _parse_members(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        """Extract the declarations of a file or of a class, mixin, enum or extension body"""
+        group: List[Node] = []
+        for child in node.children:
+            if child.type in COMMENTS:
+                continue
+            if child.type == 'ERROR':
+                self._parse_members(child, parents, owner, chunks)
+                group = []
+            elif child.type in DIRECTIVES:
+                if child.type == 'library_name' and owner is None:
+                    name = next((c for c in child.named_children if c.type not in ANNOTATIONS + COMMENTS), None)
+                    if name is not None:
+                        self._library = re.sub(r'\s+', '', self._text(name))
+                group = []  # imports, exports and parts are not chunked
+            elif child.type == 'enum_constant':
+                name = self._name(child)
+                if name is not None and owner is not None:
+                    owner.setdefault('constants', []).append(self._text(name))
+                group = []
+            elif not group and child.type in ('{', '}', ',', ';'):
+                continue
+            else:
+                group.append(child)
+                if child.type in TYPE_DEFINITIONS or child.type in ('type_alias', 'function_body', ';'):
+                    self._extract_declaration(group, parents, owner, chunks)
+                    group = []
+    
+    def _extract_declaration(self, group: List[Node], parents: List[str], owner: Optional[Dict],
+                             chunks: List[CodeChunk]):
+        """One declaration from its annotations, modifiers, signature and body or ';'"""
+        last = group[-1]
+        if last.type in TYPE_DEFINITIONS:
+            self._extract_type(group, parents, chunks)
+            return
+        if last.type == 'type_alias':
+            self._extract_typedef(group, parents, chunks)
+            return
+        parts = self._flatten(group)
+        signature = next((p for p in parts if p.type in SIGNATURES), None)
+        if signature is not None:
+            self._extract_function(parts, signature, parents, owner, chunks)
+        elif any(p.type in DECLARATORS for p in parts):
+            self._extract_variable(parts, parents, owner, chunks)
+    
+    def _extract_type(self, group: List[Node], parents: List[str], chunks: List[CodeChunk]):
+        """Classes, mixins, enums, extensions and extension types"""
+        node = group[-1]
+        kind = TYPE_DEFINITIONS[node.type]
+        children = [c for c in node.children if c.type not in COMMENTS]
+        keyword = next((k for k, c in enumerate(children) if c.type in ('class', 'mixin', 'enum', 'extension')
+                        and not (c.type == 'mixin' and kind == 'class')), 0)
+        annotations, modifiers = self._modifiers(group[:-1] + children[:keyword], CLASS_MODIFIERS)
+        # class Named = Base with Mixin; keeps its header in a node of its own
+        header = next((c for c in children if c.type == 'mixin_application_class'), node)
+        metadata: Dict = {}
+        
+        name_node = self._name(header)
+        type_params = next((c for c in header.children if c.type == 'type_parameters'), None)
+        if type_params is not None:
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        representation = next((c for c in header.children if c.type == 'representation_declaration'), None)
+        if kind == 'type' and representation is not None:
+            metadata['extension_type'] = True
+            params = self._parse_params(representation)
+            if params:
+                metadata['representation'] = params[0]
+        if any(c.type == '=' for c in header.children):
+            metadata['mixin_application'] = True
+        self._clauses(header, kind, metadata)
+        
+        if name_node is not None:
+            name = self._text(name_node)
+        else:
+            # Unnamed extension: named after the type it extends
+            name = base_type(metadata.get('on') or 'extension')
+            metadata['unnamed'] = True
+        superclass = metadata.get('extends') or ''
+        if kind == 'class' and base_type(superclass).endswith('Widget'):
+            metadata['widget'] = True
+        elif kind == 'class' and base_type(superclass).endswith('State') and '<' in superclass:
+            metadata['state_of'] = normalize_signature(superclass[superclass.index('<') + 1:superclass.rindex('>')])
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        body = next((c for c in children if c.type in BODIES), None)
+        if body is not None:
+            header_end = body.start_byte
+        else:
+            semicolon = next((c for c in header.children if c.type == ';'), None)
+            header_end = semicolon.start_byte if semicolon is not None else node.end_byte
+        chunk = CodeChunk(
+            type=kind,
+            name=name,
+            content=self._span(group[0], node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(group[0]),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(self._signature_start(group[:-1] + children), header_end)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, group[0].start_byte, annotations)
+        chunks.append(chunk)
+        
+        if body is not None:
+            owner = {'kind': kind, 'name': name}
+            before = len(chunks)
+            self._parse_members(body, parents + [name], owner, chunks)
+            if owner.get('constants'):
+                metadata['constants'] = owner['constants']
+            members = [c for c in chunks[before:] if c.parent == '.'.join(parents + [name])]
+            metadata['methods'] = [c.name for c in members if c.type == 'method' and not c.metadata.get('constructor')]
+            constructors = [c.metadata['constructor_name'] for c in members if c.metadata.get('constructor')]
+            if constructors:
+                metadata['constructors'] = constructors
+            fields = [c.name for c in members if c.type in ('field', 'constant')]
+            if fields:
+                metadata['fields'] = fields
+    
+    def _clauses(self, node: Node, kind: str, metadata: Dict):
+        """The extends, with, implements and on clauses of a type header"""
+        children = [c for c in node.children if c.type not in COMMENTS]
+        for k, child in enumerate(children):
+            if child.type in ('superclass', 'mixin_application'):
+                # extends Base with Mixin, or the Base with Mixin of a mixin application
+                types = [c for c in child.children if c.type not in ('extends', 'mixins', 'interfaces') + COMMENTS]
+                if types:
+                    metadata['extends'] = normalize_signature(self._span(types[0], types[-1]))
+                self._clauses(child, kind, metadata)
+            elif child.type == 'mixins':
+                metadata['with'] = self._type_list(child.children)
+            elif child.type == 'interfaces':
+                metadata['implements'] = self._type_list(child.children)
+            elif child.type == 'on':
+                end = next((j for j in range(k + 1, len(children)) if children[j].type in CLAUSE_ENDS), len(children))
+                types = self._type_list(children[k + 1:end])
+                # A mixin may require several superclass constraints; an extension has one type
+                metadata['on'] = types[0] if kind == 'extension' and types else types
+    
+    def _extract_typedef(self, group: List[Node], parents: List[str], chunks: List[CodeChunk]):
+        """typedef Name<T> = Type; and the older typedef ReturnType Name(params);"""
+        node = group[-1]
+        children = [c for c in node.children if c.type not in COMMENTS]
+        types = [c.type for c in children]
+        metadata: Dict = {}
+        if '=' in types:
+            eq = types.index('=')
+            name_node = next((c for c in children[:eq] if c.type in ('type_identifier', 'identifier')), None)
+            aliased = [c for c in children[eq + 1:] if c.type != ';']
+            metadata['aliased'] = normalize_signature(self._span(aliased[0], aliased[-1])) if aliased else ''
+        else:
+            params = next((k for k, c in enumerate(children) if c.type in ('type_parameters', 'formal_parameter_list')),
+                          None)
+            name_node = children[params - 1] if params else None
+        if name_node is None or name_node.type not in ('type_identifier', 'identifier'):
+            return
+        name_index = children.index(name_node)
+        if name_index + 1 < len(children) and children[name_index + 1].type == 'type_parameters':
+            metadata['type_params'] = normalize_signature(self._text(children[name_index + 1]))
+        annotations, _ = self._modifiers(group[:-1] + children, set())
+        if annotations:
+            metadata['annotations'] = annotations
+        
+        keyword = next((c for c in children if c.type == 'typedef'), children[0])
+        header_end = next((c for c in reversed(children) if c.type != ';'), node).end_byte
+        chunk = CodeChunk(
+            type='alias',
+            name=self._text(name_node),
+            content=self._span(group[0], node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(group[0]),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(keyword.start_byte, header_end)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, group[0].start_byte, annotations)
+        chunks.append(chunk)
+    
+    def _extract_function(self, parts: List[Node], signature: Node, parents: List[str], owner: Optional[Dict],
+                          chunks: List[CodeChunk]):
+        """Functions, methods, getters, setters, operators and constructors"""
+        index = parts.index(signature)
+        head = [c for c in signature.children if c.type not in COMMENTS]
+        # const and factory open a constructor's own signature
+        lead = 0
+        while lead < len(head) - 1 and self._text(head[lead]) in MEMBER_MODIFIERS:
+            lead += 1
+        annotations, modifiers = self._modifiers(parts[:index] + head[:lead], MEMBER_MODIFIERS)
+        head = head[lead:]
+        metadata: Dict = {}
+        
+        end = next((k for k, c in enumerate(head) if c.type in ('type_parameters', 'formal_parameter_list')), len(head))
+        accessor = None
+        if signature.type == 'operator_signature':
+            keyword = next((k for k, c in enumerate(head) if c.type == 'operator'), None)
+            if keyword is None:
+                return
+            name = 'operator ' + ''.join(self._text(c) for c in head[keyword + 1:end])
+            path = [name]
+            type_end = keyword
+        else:
+            # name, or the Class.named of a constructor
+            start = end - 1
+            while start >= 2 and head[start - 1].type == '.' and head[start - 2].type == 'identifier':
+                start -= 2
+            if start < 0 or head[start].type != 'identifier':
+                return
+            path = [self._text(c) for c in head[start:end:2]]
+            name = path[-1]
+            type_end = start
+            accessor_index = next((k for k in range(start) if head[k].type in ('get', 'set')), None)
+            if accessor_index is not None:
+                accessor = head[accessor_index].type
+                type_end = accessor_index
+        
+        constructor_name = None
+        if owner is not None and owner['kind'] in ('class', 'enum', 'type') and accessor is None \
+                and signature.type != 'operator_signature':
+            if signature.type in CONSTRUCTORS or (type_end == 0 and len(path) == 1 and name == owner['name']):
+                constructor_name = '.'.join(path)
+        if constructor_name:
+            metadata['constructor'] = True
+            metadata['constructor_name'] = constructor_name
+            if 'factory' in modifiers:
+                metadata['factory'] = True
+            eq = next((k for k, c in enumerate(head) if c.type == '='), None)
+            if eq is not None and eq + 1 < len(head):
+                # A redirecting factory: = Other.named;
+                metadata['redirects_to'] = normalize_signature(self._span(head[eq + 1], head[-1]))
+        elif type_end > 0:
+            # The return type ends before the name, or before 'get' / 'set'
+            metadata['returns'] = normalize_signature(self._span(head[0], head[type_end - 1]))
+        if accessor:
+            metadata['accessor'] = accessor
+        
+        type_params = next((c for c in head if c.type == 'type_parameters'), None)
+        if type_params is not None and signature.type != 'operator_signature':
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        params = next((c for c in head if c.type == 'formal_parameter_list'), None)
+        if params is not None:
+            metadata['params'] = self._parse_params(params)
+        body = next((p for p in parts[index + 1:] if p.type == 'function_body'), None)
+        if body is not None:
+            markers = ''
+            for child in body.children:
+                if child.type in ('block', '=>'):
+                    break
+                if child.type not in COMMENTS:
+                    markers += self._text(child)
+            if markers in ('async', 'async*', 'sync*'):
+                metadata['async'] = markers
+        elif constructor_name is None and 'external' not in modifiers and owner is not None:
+            metadata['abstract'] = True
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        if body is not None:
+            header_end = parts[parts.index(body) - 1].end_byte
+        else:
+            header_end = next((p for p in reversed(parts) if p.type != ';'), signature).end_byte
+        chunk = CodeChunk(
+            type='method' if owner is not None else 'function',
+            name=name,
+            content=self._span(parts[0], parts[-1]),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(parts[0]),
+            line_end=self._line_end(parts[-1]),
+            signature=normalize_signature(self._decode(self._signature_start(parts), header_end)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, parts[0].start_byte, annotations)
+        chunks.append(chunk)
+    
+    def _extract_variable(self, parts: List[Node], parents: List[str], owner: Optional[Dict],
+                          chunks: List[CodeChunk]):
+        """Fields and top-level variables: [late] [final|const|var] [Type] a = 1, b;"""
+        index = next(k for k, p in enumerate(parts) if p.type in DECLARATORS)
+        names, first_name = [], None
+        for group in self._split_commas(parts[index].children):
+            name = group[0] if group[0].type == 'identifier' else \
+                next((c for c in group[0].children if c.type == 'identifier'), None)
+            if name is not None:
+                names.append(self._text(name))
+                first_name = first_name or name
+        if first_name is None:
+            return
+        annotations, modifiers = self._modifiers(parts[:index], MEMBER_MODIFIERS)
+        metadata: Dict = {}
+        
+        type_nodes = [p for p in parts[:index] if p.type not in ANNOTATIONS and self._text(p) not in MEMBER_MODIFIERS]
+        if type_nodes:
+            metadata['field_type'] = normalize_signature(self._span(type_nodes[0], type_nodes[-1]))
+        if len(names) > 1:
+            metadata['names'] = names
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        const = 'const' in modifiers
+        chunk = CodeChunk(
+            type='constant' if const else ('field' if owner is not None else 'variable'),
+            name=names[0],
+            content=self._span(parts[0], parts[-1]),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(parts[0]),
+            line_end=self._line_end(parts[-1]),
+            signature=normalize_signature(self._decode(self._signature_start(parts), first_name.end_byte)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, parts[0].start_byte, annotations)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _parse_params(self, node: Node, flag: Optional[str] = None) -> List[Dict]:
+        """
+        Parameters of a parameter list; those in {...} are 'named' and those in
+        [...] 'optional', this.x and super.x initialize a field or pass through
+        """
+        children = [c for c in node.children if c.type not in COMMENTS]
+        if children and children[0].type in ('{', '['):
+            flag = 'named' if children[0].type == '{' else 'optional'
+        params = []
+        for group in self._split_commas([c for c in children if c.type not in ('(', ')', '{', '}', '[', ']')]):
+            if group[0].type in PARAMETER_LISTS:
+                params.extend(self._parse_params(group[0], flag))
+                continue
+            if len(group) == 1 and group[0].type in DEFAULT_PARAMETERS:
+                group = [c for c in group[0].children if c.type not in COMMENTS]
+            param = self._parse_param(group, flag)
+            if param is not None:
+                params.append(param)
+        return params
+    
+    def _parse_param(self, group: List[Node], flag: Optional[str]) -> Optional[Dict]:
+        """A parameter from its nodes: [required] [modifiers] [Type] name [= default]"""
+        param: Dict = {}
+        default = next((k for k, c in enumerate(group) if c.type in ('=', ':')), None)
+        if default is not None:
+            if default + 1 < len(group):
+                param['default'] = normalize_signature(self._span(group[default + 1], group[-1]))
+            group = group[:default]
+        leaves = [leaf for node in group for leaf in self._leaves(node)]
+        values = [self._text(leaf) for leaf in leaves]
+        first = 0
+        while first < len(values) - 1 and (values[first] in MEMBER_MODIFIERS or values[first] == 'required'):
+            if values[first] == 'required':
+                param['required'] = True
+            first += 1
+        if first >= len(values):
+            return None
+        
+        name_index = len(values) - 1
+        if values[name_index] == ')' and name_index > first:
+            # Function-typed parameter: void onTap(int x)
+            depth = 0
+            for k in range(name_index, first - 1, -1):
+                depth += {')': 1, '(': -1}.get(values[k], 0)
+                if depth == 0:
+                    name_index = k - 1
+                    break
+            param['function'] = True
+        while name_index > first and values[name_index] == '?':
+            name_index -= 1
+        if name_index < first:
+            return None
+        type_end = name_index - 1
+        if name_index - 2 >= first and values[name_index - 1] == '.' and values[name_index - 2] in ('this', 'super'):
+            param['field' if values[name_index - 2] == 'this' else 'super'] = True
+            type_end = name_index - 3
+        entry = {'name': values[name_index]}
+        if type_end >= first:
+            entry['type'] = normalize_signature(self._span(leaves[first], leaves[type_end]))
+        if flag:
+            entry[flag] = True
+        entry.update(param)
+        return entry
+    
+    def _flatten(self, nodes: List[Node]) -> List[Node]:
+        """The nodes of a declaration with the declaration and method_signature wrappers opened"""
+        flat = []
+        for node in nodes:
+            if node.type in MEMBER_WRAPPERS:
+                flat.extend(self._flatten(node.children))
+            elif node.type not in COMMENTS:
+                flat.append(node)
+        return flat
+    
+    def _modifiers(self, nodes: List[Node], keywords: Set[str]) -> Tuple[List[str], List[str]]:
+        """Annotations and modifier keywords among the nodes before a declaration's name"""
+        annotations, modifiers = [], []
+        for node in nodes:
+            if node.type in ANNOTATIONS:
+                annotations.append(normalize_signature(self._text(node)))
+            elif self._text(node) in keywords:
+                modifiers.append(self._text(node))
+        return annotations, modifiers
+    
+    def _name(self, node: Node) -> Optional[Node]:
+        """The identifier naming a declaration, None for an unnamed extension"""
+        name = node.child_by_field_name('name')
+        if name is not None:
+            return name
+        for child in node.children:
+            if child.type in ('identifier', 'type_identifier'):
+                return child
+            if child.type in ('on', '=', 'superclass', 'interfaces', 'mixins', 'representation_declaration') + BODIES:
+                break
+        return None
+    
+    def _type_list(self, nodes: List[Node]) -> List[str]:
+        """Types of a comma-separated clause: with A, B<T>"""
+        nodes = [n for n in nodes if n.type not in CLAUSE_KEYWORDS + COMMENTS]
+        return [normalize_signature(self._span(g[0], g[-1])) for g in self._split_commas(nodes)]
+    
+    def _split_commas(self, nodes: List[Node]) -> List[List[Node]]:
+        """Nodes split at ',' children; empty groups dropped"""
+        groups, current = [], []
+        for node in nodes:
+            if node.type == ',':
+                groups.append(current)
+                current = []
+            elif node.type not in COMMENTS:
+                current.append(node)
+        groups.append(current)
+        return [g for g in groups if g]
+    
+    def _leaves(self, node: Node) -> List[Node]:
+        """The tokens of a node in source order; annotations, comments and empty MISSING nodes left out"""
+        if node.type in ANNOTATIONS or node.type in COMMENTS:
+            return []
+        if not node.children or node.type == 'string_literal':
+            return [node] if node.end_byte > node.start_byte else []
+        return [leaf for child in node.children for leaf in self._leaves(child)]
+    
+    def _signature_start(self, nodes: List[Node]) -> int:
+        """Offset of the first modifier or declaration token after a run of annotations"""
+        for node in nodes:
+            if node.type not in ANNOTATIONS and node.type not in COMMENTS:
+                return node.start_byte
+        return nodes[0].start_byte
+    
+    def _collect_comments(self, root: Node) -> List[DartComment]:
+        """Every comment of the file in source order"""
+        comments = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type in COMMENTS:
+                text = self._text(node).rstrip()
+                line = self._line(node)
+                comments.append(DartComment(text, node.start_byte, node.end_byte, line, line + text.count('\n')))
+            else:
+                stack.extend(node.children)
+        return sorted(comments, key=lambda c: c.start)
+    
+    def _doc_comments(self, comments: List[DartComment]) -> Dict[int, List[DartComment]]:
+        """Doc comments by their end offset: each /** */ comment and each run of /// lines"""
+        docs: Dict[int, List[DartComment]] = {}
+        run: List[DartComment] = []
+        for comment in comments:
+            if comment.text.startswith('///'):
+                if run and (comment.line_start != run[-1].line_end + 1
+                            or self._source[run[-1].end:comment.start].strip()):
+                    run = []
+                run.append(comment)
+                docs[comment.end] = list(run)
+            else:
+                run = []
+                if comment.is_doc:
+                    docs[comment.end] = [comment]
+        return docs
+    
+    def _attach_doc(self, chunk: CodeChunk, start: int, annotations: List[str]):
+        """Doc comment directly before the declaration (or its annotations); flags deprecation"""
+        position = bisect_right(self._comment_ends, start) - 1
+        doc = self._docs_by_end.get(self._comment_ends[position]) if position >= 0 else None
+        if doc and not self._source[doc[-1].end:start].strip():
+            chunk.doc = doc_text(doc)
+        for annotation in annotations:
+            if annotation == '@deprecated' or annotation.startswith('@Deprecated('):
+                message = re.search(r'\(\s*r?([\'"])(.*?)\1', annotation)
+                chunk.metadata['deprecated'] = message.group(2) if message else 'deprecated'
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
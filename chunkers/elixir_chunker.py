@@ -0,0 +1,537 @@
+#!/usr/bin/env python3
+"""
+Elixir code chunker using tree-sitter for accurate parsing
+Supports .ex and .exs files
+
+In the tree-sitter-elixir grammar every definition is a call: defmodule,
+def and friends are calls whose do ... end block (or do: keyword) is their
+body, and module attributes are @ operators applied to a call. The clauses
+of a function (def, defp, defmacro and friends) are one chunk per name and
+arity, with the matching @spec in its signature and @doc in its doc field;
+modules record their @moduledoc, functions, the modules they use, import,
+alias and require, their behaviours and the fields of their struct.
+"""
+
+import re
+import textwrap
+from bisect import bisect_right
+from dataclasses import dataclass
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error, tree_sitter_diagnostics
+
+
+# Definitions of functions and macros: keyword -> (chunk type, private)
+DEFINITIONS = {
+    'def': ('function', False), 'defp': ('function', True), 'defdelegate': ('function', False),
+    'defmacro': ('macro', False), 'defmacrop': ('macro', True),
+    'defguard': ('macro', False), 'defguardp': ('macro', True),
+}
+MODULE_DEFINITIONS = {'defmodule': 'module', 'defprotocol': 'protocol', 'defimpl': 'module'}
+
+# Module body directives and the metadata they fill
+DIRECTIVES = {'use': 'uses', 'import': 'imports', 'alias': 'aliases', 'require': 'requires'}
+STRUCT_DEFINITIONS = {'defstruct', 'defexception'}
+
+# Module attributes declaring types and callbacks, by the metadata they fill
+TYPE_ATTRIBUTES = {'type': 'types', 'typep': 'types', 'opaque': 'types',
+                   'callback': 'callbacks', 'macrocallback': 'callbacks'}
+
+
+@dataclass
+class ElixirComment:
+    """A # comment"""
+    text: str
+    start: int
+    end: int
+    line_start: int
+    line_end: int
+    own_line: bool  # nothing but whitespace before it on its line
+
+
+def comment_doc(comments: List[ElixirComment]) -> str:
+    """Text of a run of # comments, without the markers"""
+    lines = []
+    for comment in comments:
+        line = comment.text[1:]
+        lines.append((line[1:] if line.startswith(' ') else line).rstrip())
+    return '\n'.join(lines).strip()
+
+
+def string_value(kind: str, text: str) -> Optional[str]:
+    """Text of a string, heredoc or string sigil (~s, ~S) node; None for other nodes"""
+    if kind == 'sigil':
+        if text[1:2] not in ('s', 'S'):
+            return None
+        text = text[2:].rstrip('abcdefghijklmnopqrstuvwxyz')
+    elif kind != 'string':
+        return None
+    if text[:3] in ('"""', "'''"):
+        body = text[3:-3]
+        body = body[body.find('\n') + 1:] if '\n' in body else body
+        return textwrap.dedent(body).strip()
+    return re.sub(r'\\(["\'\\])', r'\1', text[1:-1].replace('\\n', '\n')).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,)\]}])|([(\[{])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+class ElixirChunker(BaseChunker):
+    """Extracts modules, protocols, functions and macros from Elixir code"""
+    
+    def __init__(self):
+        super().__init__('elixir')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('elixir')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Elixir code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._comments = self._collect_comments(tree.root_node)
+        self._comment_ends = [c.end for c in self._comments]
+        self._unclosed: List[Tuple[int, str]] = []  # (line, message) of definitions missing their 'end'
+        chunks: List[CodeChunk] = []
+        
+        top = self._frame('top', [], tree.root_node)
+        self._parse_body(tree.root_node, top, chunks)
+        self._emit_functions(top, chunks)
+        
+        # The missing 'end's are reported innermost first, as the definitions they close
+        missing = [d for d in self.diagnostics if d['message'] == "missing 'end'"]
+        for diagnostic, (line, message) in zip(missing, sorted(self._unclosed, reverse=True)):
+            diagnostic.update(parse_error(line, message))
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Blocks
+    # ------------------------------------------------------------------
+    
+    def _frame(self, kind: str, path: List[str], node: Node, **fields) -> Dict:
+        """A container (top level, module) collecting its definitions and attributes"""
+        frame = {'kind': kind, 'path': path, 'node': node, 'clauses': [], 'specs': {}, 'pending': {},
+                 'metadata': {}, 'doc': None}
+        frame.update(fields)
+        return frame
+    
+    def _parse_body(self, body: Node, container: Dict, chunks: List[CodeChunk]):
+        """Extract the statements of a file or of a module's do block"""
+        for child in body.named_children:
+            if child.type == 'ERROR':
+                self._parse_body(child, container, chunks)
+            elif child.type == 'unary_operator':
+                self._module_attribute(child, container)
+            elif child.type == 'call':
+                keyword = self._call_name(child)
+                if keyword in MODULE_DEFINITIONS:
+                    self._extract_module(child, keyword, container, chunks)
+                elif keyword in DEFINITIONS:
+                    self._extract_definition(child, keyword, container)
+                elif keyword in DIRECTIVES or keyword in STRUCT_DEFINITIONS:
+                    self._directive(child, keyword, container)
+            # Other expressions (a config call, a conditional definition) are not chunked
+    
+    def _note_unclosed(self, node: Node, keyword: str, name: str):
+        do_block = self._do_block(node)
+        if do_block is not None and do_block.children and do_block.children[-1].is_missing:
+            self._unclosed.append((self._line(node), f"missing 'end' for {keyword} {name}"))
+    
+    # ------------------------------------------------------------------
+    # Declarations
+    # ------------------------------------------------------------------
+    
+    def _extract_module(self, node: Node, keyword: str, container: Dict, chunks: List[CodeChunk]):
+        arguments = self._arguments(node)
+        if not arguments:
+            return
+        segments = self._segments(arguments[0])
+        if not segments:
+            return
+        
+        metadata: Dict = {}
+        path = container['path'] + segments if keyword == 'defmodule' and segments[0] != '__MODULE__' else segments
+        if path and path[0] == '__MODULE__':
+            path = container['path'] + path[1:]
+        if keyword == 'defimpl':
+            protocol = '.'.join(segments)
+            targets = []
+            for key, value in self._keywords(arguments):
+                if key == 'for':
+                    targets = self._alias_names(value, container)
+            targets = targets or ['.'.join(container['path'])]
+            metadata.update({'protocol': protocol, 'for': targets, 'implements': [protocol]})
+            path = segments + targets[0].split('.')
+        
+        frame = self._frame('module', path, node, name=path[-1], type=MODULE_DEFINITIONS[keyword],
+                            header_end=self._header_end(arguments, any_label=False), metadata=metadata)
+        self._take_leading(frame, container)
+        do_block = self._do_block(node)
+        if do_block is not None:
+            self._note_unclosed(node, keyword, path[-1])
+            self._parse_body(do_block, frame, chunks)
+        # defmodule Empty, do: nil has no definitions
+        self._emit_functions(frame, chunks)
+        self._emit_module(frame, chunks)
+    
+    def _extract_definition(self, node: Node, keyword: str, container: Dict):
+        arguments = self._arguments(node)
+        if not arguments:
+            return  # def used as a name: Kernel.def(...)
+        head = arguments[0]
+        
+        guard = None
+        if head.type == 'binary_operator' and self._operator(head) == 'when':
+            right = head.child_by_field_name('right')
+            guard = normalize_signature(self._text(right)) if right is not None else None
+            head = head.child_by_field_name('left') or head
+        params: List[Node] = []
+        if head.type == 'call':
+            target = head.child_by_field_name('target')
+            # def unquote(name)(args): a name computed at compile time
+            name = self._text(target) if target is not None else self._text(head)
+            params = self._arguments(head)
+        elif head.type == 'binary_operator':
+            # An operator: def left <~> right
+            name = self._operator(head)
+            params = [c for c in (head.child_by_field_name('left'), head.child_by_field_name('right')) if c]
+        elif head.type == 'identifier':
+            name = self._text(head)
+        else:
+            return
+        
+        kind, private = DEFINITIONS[keyword]
+        defaults = sum(1 for param in params if param.type == 'binary_operator' and self._operator(param) == '\\\\')
+        do_block = self._do_block(node)
+        clause = {'keyword': keyword, 'kind': kind, 'private': private, 'name': name, 'arity': len(params),
+                  'min_arity': len(params) - defaults, 'guard': guard, 'node': node,
+                  'header_end': self._header_end(arguments, any_label=keyword == 'defdelegate')}
+        if keyword == 'defdelegate':
+            for key, value in self._keywords(arguments):
+                if key in ('to', 'as'):
+                    clause['delegate_' + key] = normalize_signature(self._text(value))
+        self._take_leading(clause, container)
+        container['clauses'].append(clause)
+        if do_block is not None:
+            self._note_unclosed(node, keyword, name)
+    
+    def _module_attribute(self, node: Node, container: Dict):
+        """@moduledoc, @doc, @spec, @impl, @behaviour and the other module attributes"""
+        operand = node.child_by_field_name('operand')
+        if self._operator(node) != '@' or operand is None or operand.type != 'call':
+            return
+        name = self._call_name(operand)
+        arguments = self._arguments(operand)
+        if not name or not arguments:
+            return
+        value = arguments[0]
+        pending = container['pending']
+        pending.setdefault('first', node)
+        
+        if name in ('doc', 'moduledoc'):
+            if value.type == 'boolean' and self._text(value) == 'false':
+                text = False
+            else:
+                text = string_value(value.type, self._text(value)) if len(arguments) == 1 else None
+            if name == 'moduledoc':
+                container['doc'] = text
+                pending.pop('first', None)
+            elif text is not None:
+                pending['doc'] = text
+        elif name == 'spec':
+            spec = self._span(arguments[0], arguments[-1])
+            spec_name, arity = self._call_shape(value)
+            if spec_name:
+                container['specs'].setdefault((spec_name, arity), []).append(normalize_signature(f"@spec {spec}"))
+        elif name == 'impl':
+            pending['impl'] = True if self._text(value) == 'true' else normalize_signature(self._text(value))
+        elif name == 'deprecated':
+            pending['deprecated'] = string_value(value.type, self._text(value)) or \
+                normalize_signature(self._text(value))
+        elif name == 'behaviour':
+            container['metadata'].setdefault('behaviours', []).extend(self._alias_names(value, container))
+            pending.pop('first', None)
+        elif name in TYPE_ATTRIBUTES:
+            type_name, arity = self._call_shape(value)
+            if type_name:
+                container['metadata'].setdefault(TYPE_ATTRIBUTES[name], []).append(f"{type_name}/{arity}")
+        else:
+            container['metadata'].setdefault('attributes', []).append(name)
+            pending.pop('first', None)
+    
+    def _directive(self, node: Node, keyword: str, container: Dict):
+        """use, import, alias and require; defstruct and defexception"""
+        arguments = self._arguments(node)
+        metadata = container['metadata']
+        container['pending'].pop('first', None)
+        if keyword in STRUCT_DEFINITIONS:
+            # defstruct [:name, age: 0] or defstruct name: nil, age: 0
+            items = arguments[0].named_children if arguments and arguments[0].type == 'list' else arguments
+            fields = []
+            for item in items:
+                if item.type == 'keywords':
+                    fields.extend(key for key, _ in self._pairs(item))
+                elif item.type in ('atom', 'quoted_atom'):
+                    fields.append(self._text(item)[1:].strip('"'))
+            metadata['struct'] = fields
+            if keyword == 'defexception':
+                metadata['exception'] = True
+            return
+        if not arguments:
+            return
+        
+        names = self._alias_names(arguments[0], container)
+        if keyword == 'alias' and len(names) == 1:
+            for key, value in self._keywords(arguments):
+                if key == 'as':
+                    names = [f"{names[0]} as {normalize_signature(self._text(value))}"]
+        for name in names:
+            if name not in metadata.setdefault(DIRECTIVES[keyword], []):
+                metadata[DIRECTIVES[keyword]].append(name)
+    
+    def _take_leading(self, target: Dict, container: Dict):
+        """Hand the @doc, @impl and @deprecated just above a definition to it"""
+        pending = container['pending']
+        target['leading'] = pending.get('first', target['node'])
+        for key in ('doc', 'impl', 'deprecated'):
+            if key in pending:
+                target[key] = pending[key]
+        container['pending'] = {}
+    
+    # ------------------------------------------------------------------
+    # Expressions
+    # ------------------------------------------------------------------
+    
+    def _call_name(self, node: Node) -> Optional[str]:
+        """Name of a local call (def, alias, defstruct); None for remote calls (Kernel.def)"""
+        target = node.child_by_field_name('target')
+        return self._text(target) if target is not None and target.type == 'identifier' else None
+    
+    def _arguments(self, node: Node) -> List[Node]:
+        """The arguments of a call, without the parentheses, commas and comments"""
+        arguments = next((c for c in node.children if c.type == 'arguments'), None)
+        if arguments is None:
+            return []
+        return [c for c in arguments.named_children if c.type != 'comment']
+    
+    def _do_block(self, node: Node) -> Optional[Node]:
+        return next((c for c in node.children if c.type == 'do_block'), None)
+    
+    def _operator(self, node: Node) -> str:
+        operator = node.child_by_field_name('operator')
+        return self._text(operator) if operator is not None else ''
+    
+    def _pairs(self, keywords: Node) -> List[Tuple[str, Node]]:
+        """Keys and values of a keyword list: to: Repo, "as": :x"""
+        pairs = []
+        for pair in keywords.named_children:
+            key = pair.child_by_field_name('key')
+            value = pair.child_by_field_name('value')
+            if pair.type == 'pair' and key is not None and value is not None:
+                pairs.append((self._key(key), value))
+        return pairs
+    
+    def _key(self, key: Node) -> str:
+        """A keyword without its colon: to, "as" """
+        return self._text(key).strip().rstrip(':').strip('"')
+    
+    def _keywords(self, arguments: List[Node]) -> List[Tuple[str, Node]]:
+        """The keyword arguments of a call"""
+        return [pair for argument in arguments if argument.type == 'keywords' for pair in self._pairs(argument)]
+    
+    def _header_end(self, arguments: List[Node], any_label: bool) -> Node:
+        """
+        Last node of a definition's header, before its body: a do: keyword ends it;
+        with any_label, any keyword argument does (defdelegate f(x), to: M)
+        """
+        last = arguments[0]
+        for argument in arguments[1:]:
+            if argument.type != 'keywords':
+                last = argument
+                continue
+            if any_label:
+                break
+            for pair in argument.named_children:
+                key = pair.child_by_field_name('key')
+                if key is None:
+                    continue
+                if self._key(key) == 'do':
+                    return last
+                last = pair
+        return last
+    
+    def _segments(self, node: Node) -> List[str]:
+        """Segments of a module name: Foo.Bar, __MODULE__.Sub"""
+        if node.type not in ('alias', 'dot') and self._text(node) != '__MODULE__':
+            return []
+        text = re.sub(r'\s+', '', self._text(node))
+        return [segment for segment in text.split('.') if segment]
+    
+    def _alias_names(self, node: Node, container: Dict) -> List[str]:
+        """Module names in an argument: Foo.Bar, Foo.{Bar, Baz} and lists of them"""
+        if node.type in ('list', 'tuple'):
+            return [name for item in node.named_children for name in self._alias_names(item, container)]
+        if node.type == 'dot':
+            left, right = node.child_by_field_name('left'), node.child_by_field_name('right')
+            if left is not None and right is not None and right.type == 'tuple':
+                # Foo.{Bar, Baz}
+                prefixes = self._alias_names(left, container)
+                return [f"{prefixes[0]}.{name}" for name in self._alias_names(right, container)] if prefixes else []
+        segments = self._segments(node)
+        if not segments:
+            return []
+        if segments[0] == '__MODULE__':
+            segments = container['path'] + segments[1:]
+        return ['.'.join(segments)]
+    
+    def _call_shape(self, node: Node) -> Tuple[Optional[str], int]:
+        """Name and argument count of the call or type heading a @spec or @type: add(a, b) :: t"""
+        while node.type == 'binary_operator' and self._operator(node) in ('when', '::'):
+            left = node.child_by_field_name('left')
+            if left is None:
+                break
+            node = left
+        if node.type == 'call':
+            name = self._call_name(node)
+            return (name, len(self._arguments(node))) if name else (None, 0)
+        if node.type == 'identifier':
+            return self._text(node), 0
+        return None, 0
+    
+    # ------------------------------------------------------------------
+    # Chunks
+    # ------------------------------------------------------------------
+    
+    def _emit_functions(self, container: Dict, chunks: List[CodeChunk]):
+        """One chunk for each run of clauses with the same name and arity"""
+        groups: List[List[Dict]] = []
+        for clause in container['clauses']:
+            previous = groups[-1][0] if groups else None
+            if previous and (previous['kind'], previous['name'], previous['arity']) == \
+                    (clause['kind'], clause['name'], clause['arity']):
+                groups[-1].append(clause)
+            else:
+                groups.append([clause])
+        
+        metadata = container['metadata']
+        for group in groups:
+            chunk = self._emit_function(group, container)
+            chunks.append(chunk)
+            listing = 'functions' if chunk.type == 'function' else 'macros'
+            metadata.setdefault(listing, []).append(f"{chunk.name}/{chunk.metadata['arity']}")
+    
+    def _emit_function(self, clauses: List[Dict], container: Dict) -> CodeChunk:
+        first, last = clauses[0], clauses[-1]
+        path = container['path']
+        metadata: Dict = {'arity': first['arity']}
+        min_arity = min(clause['min_arity'] for clause in clauses)
+        if min_arity < first['arity']:
+            metadata['min_arity'] = min_arity
+        if first['private']:
+            metadata['visibility'] = 'private'
+        if len(clauses) > 1:
+            metadata['clauses'] = len(clauses)
+        guards = [clause['guard'] for clause in clauses if clause['guard']]
+        if guards:
+            metadata['guards'] = guards
+        if first['keyword'] in ('defguard', 'defguardp'):
+            metadata['defguard'] = True
+        for key in ('delegate_to', 'delegate_as'):
+            if key in first:
+                metadata[key] = first[key]
+        for clause in clauses:
+            if 'impl' in clause:
+                metadata['impl'] = clause['impl']
+            if 'deprecated' in clause:
+                metadata['deprecated'] = clause['deprecated']
+        
+        header = normalize_signature(self._span(first['node'], first['header_end']))
+        specs = container['specs'].get((first['name'], first['arity']), [])
+        chunk = CodeChunk(
+            type=first['kind'],
+            name=first['name'],
+            content=self._span(first['node'], last['node']),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first['node']),
+            line_end=self._line_end(last['node']),
+            signature='\n'.join(specs + [header]),
+            namespace='.'.join(path[:-1]) or None,
+            parent_class=path[-1] if path else None,
+            parent='.'.join(path) or None,
+            metadata=metadata
+        )
+        docs = [clause['doc'] for clause in clauses if 'doc' in clause]
+        self._attach_doc(chunk, first['leading'], docs[0] if docs else None)
+        return chunk
+    
+    def _emit_module(self, frame: Dict, chunks: List[CodeChunk]):
+        node = frame['node']
+        path = frame['path']
+        chunk = CodeChunk(
+            type=frame['type'],
+            name=frame['name'],
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._span(node, frame['header_end'])),
+            namespace='.'.join(path[:-1]) or None,
+            metadata=frame['metadata']
+        )
+        self._attach_doc(chunk, frame['leading'], frame['doc'])
+        chunks.append(chunk)
+    
+    def _collect_comments(self, root: Node) -> List[ElixirComment]:
+        comments = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type == 'comment':
+                line_start = self._source.rfind(b'\n', 0, node.start_byte) + 1
+                own_line = not self._source[line_start:node.start_byte].strip()
+                comments.append(ElixirComment(self._text(node), node.start_byte, node.end_byte,
+                                              self._line(node), self._line_end(node), own_line))
+            else:
+                stack.extend(node.children)
+        return sorted(comments, key=lambda c: c.start)
+    
+    def _attach_doc(self, chunk: CodeChunk, leading: Node, doc):
+        """@doc/@moduledoc text, or else # comment lines directly above the declaration; @doc false hides it"""
+        if doc is False:
+            chunk.metadata['hidden'] = True
+            return
+        if doc:
+            chunk.doc = doc
+            return
+        next_line = self._line(leading)
+        position = bisect_right(self._comment_ends, leading.start_byte) - 1
+        doc_comments = []
+        while position >= 0:
+            comment = self._comments[position]
+            if not comment.own_line or comment.line_end != next_line - 1:
+                break
+            doc_comments.insert(0, comment)
+            next_line = comment.line_start
+            position -= 1
+        if doc_comments:
+            chunk.doc = comment_doc(doc_comments) or None
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._source[first.start_byte:last.end_byte].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
@@ -0,0 +1,297 @@
+#!/usr/bin/env python3
+"""
+Call-graph extraction for Go chunks
+GoChunker records the call sites of each function and method body off its
+tree-sitter tree (call_sites); once every package is known they are resolved
+to indexed functions and methods: plain calls within the package, pkg.Func
+calls into other indexed packages, and method calls on receivers,
+parameters and locals whose type is statically visible. Calls that can't
+be resolved that way (interfaces, func values, chained selectors, cgo's
+C.xxx) are kept by name only.
+"""
+
+from collections import defaultdict
+from typing import TYPE_CHECKING, Callable, Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import CodeChunk
+from .go_imports import CGO_PACKAGE
+
+if TYPE_CHECKING:
+    from .go_package_linker import GoPackage
+
+
+GO_BUILTINS = {
+    'append', 'cap', 'clear', 'close', 'complex', 'copy', 'delete', 'imag', 'len',
+    'make', 'max', 'min', 'new', 'panic', 'print', 'println', 'real', 'recover',
+}
+
+GO_PREDECLARED_TYPES = {
+    'any', 'bool', 'byte', 'complex64', 'complex128', 'error', 'float32', 'float64',
+    'int', 'int8', 'int16', 'int32', 'int64', 'rune', 'string',
+    'uint', 'uint8', 'uint16', 'uint32', 'uint64', 'uintptr',
+}
+
+CALLABLE_KINDS = ('function', 'method')
+
+# Bound on alias chains (type A = B; type B = C) followed to a defined type
+MAX_ALIAS_DEPTH = 8
+
+# Statements that declare locals: x, err := ..., for k, v := range ..., switch v := x.(type)
+LOCAL_DECLARATIONS = {'short_var_declaration': 'left', 'range_clause': 'left', 'type_switch_statement': 'alias'}
+
+
+def link_go_calls(packages: Dict[Tuple[str, str], 'GoPackage']) -> None:
+    """
+    Record 'calls' on every Go function/method chunk and 'called_by' on the callees
+    
+    Each entry is a symbol reference: {'name', 'resolved': True, 'package',
+    'symbol_id', 'filepath', 'line'} for indexed callees, {'name', 'resolved': False}
+    for calls recorded by name only.
+    
+    Args:
+        packages: GoPackage resolvers keyed by (directory, package name)
+    """
+    by_name: Dict[str, List['GoPackage']] = defaultdict(list)
+    for package in packages.values():
+        by_name[package.name].append(package)
+    
+    for package in packages.values():
+        for chunk in package.chunks:
+            if chunk.type not in CALLABLE_KINDS:
+                continue
+            calls = CallExtractor(chunk, package, by_name).calls()
+            chunk.metadata = dict(chunk.metadata or {}, calls=[ref for ref, _ in calls])
+            chunk.metadata.pop('call_sites', None)
+            
+            caller_ref = symbol_ref(chunk, package)
+            for ref, callee in calls:
+                if callee is None:
+                    continue
+                called_by = callee.metadata.setdefault('called_by', [])
+                if all(entry['symbol_id'] != caller_ref['symbol_id'] for entry in called_by):
+                    called_by.append(caller_ref)
+
+
+def symbol_ref(chunk: CodeChunk, package: 'GoPackage') -> Dict:
+    """Reference to an indexed function or method"""
+    name = f"{chunk.parent_class}.{chunk.name}" if chunk.parent_class else chunk.name
+    return {
+        'name': name,
+        'resolved': True,
+        'package': package.name,
+        'symbol_id': chunk.symbol_id or chunk.default_symbol_id(),
+        'filepath': chunk.filepath,
+        'line': chunk.line_start,
+    }
+
+
+def call_sites(body: Node, text: Callable[[Node], str]) -> List[Dict]:
+    """
+    The calls and local declarations of a function body, in source order, for
+    CallExtractor: {'call': name} for f(...), {'call': name, 'on': 'x'} for
+    x.name(...), {'call': name, 'chained': True} for a.b.name(...);
+    {'define': [names], 'type': 'T'} for var x T and {'define': [names],
+    'value': {'composite': 'T'} or {'call': 'NewT'}} for x := T{...} or
+    x := NewT(...). A declaration comes after the calls in its own value.
+    
+    Args:
+        body: the block of a function_declaration or method_declaration
+        text: source text of a node
+    """
+    sites = []
+    stack: List = [body]
+    while stack:
+        node = stack.pop()
+        if isinstance(node, dict):
+            sites.append(node)
+            continue
+        if node.type == 'call_expression':
+            site = _call_site(node.child_by_field_name('function'), text)
+            if site:
+                sites.append(site)
+        elif node.type in LOCAL_DECLARATIONS or node.type == 'var_spec':
+            stack.append(_local_declaration(node, text))
+        stack.extend(reversed(node.named_children))
+    return sites
+
+
+def _call_site(function: Optional[Node], text: Callable[[Node], str]) -> Optional[Dict]:
+    """Call site of the callee expression of a call_expression; None for calls of func literals and results"""
+    if function is not None and function.type == 'index_expression':  # explicit instantiation: Map[int](...)
+        function = function.child_by_field_name('operand')
+    if function is None:
+        return None
+    if function.type == 'identifier':
+        return {'call': text(function)}
+    if function.type != 'selector_expression':
+        return None
+    operand, field = function.child_by_field_name('operand'), function.child_by_field_name('field')
+    if operand is None or field is None:
+        return None
+    if operand.type == 'identifier':
+        return {'call': text(field), 'on': text(operand)}
+    if operand.type == 'selector_expression':
+        return {'call': text(field), 'chained': True}
+    return None
+
+
+def _local_declaration(node: Node, text: Callable[[Node], str]) -> Dict:
+    """The define site of a var_spec, short_var_declaration, range_clause or type switch"""
+    if node.type == 'var_spec':
+        names = [text(name) for name in node.children_by_field_name('name')]
+        type_node = node.child_by_field_name('type')
+        return {'define': names, 'type': text(type_node) if type_node is not None else None}
+    
+    left = node.child_by_field_name(LOCAL_DECLARATIONS[node.type])
+    names = [text(child) for child in left.named_children if child.type == 'identifier'] if left is not None else []
+    site: Dict = {'define': names}
+    right = node.child_by_field_name('right') if node.type == 'short_var_declaration' else None
+    values = right.named_children if right is not None else []
+    value = values[0] if values else None
+    operator = value.child_by_field_name('operator') if value is not None and value.type == 'unary_expression' else None
+    if operator is not None and text(operator) == '&':
+        value = value.child_by_field_name('operand')
+    if value is None:
+        return site
+    
+    # x := T{...} or x := pkg.T{...}; x := NewT(...) or x := pkg.NewT(...)
+    if value.type == 'composite_literal':
+        type_node = value.child_by_field_name('type')
+        if type_node is not None and type_node.type in ('type_identifier', 'qualified_type'):
+            site['value'] = {'composite': text(type_node)}
+    elif value.type == 'call_expression':
+        callee = _call_site(value.child_by_field_name('function'), text)
+        if callee and not callee.get('chained'):
+            site['value'] = {'call': f"{callee['on']}.{callee['call']}" if 'on' in callee else callee['call']}
+    return site
+
+
+class CallExtractor:
+    """Resolves the calls made in one function or method body"""
+    
+    def __init__(self, chunk: CodeChunk, package: 'GoPackage', packages_by_name: Dict[str, List['GoPackage']]):
+        self.chunk = chunk
+        self.package = package
+        self.packages_by_name = packages_by_name
+        
+        # Statically known variable types as (package, type name): receiver,
+        # parameters, then locals as they are declared; None when unknown
+        metadata = chunk.metadata or {}
+        self.sites: List[Dict] = metadata.get('call_sites', [])
+        self.variables: Dict[str, Optional[Tuple['GoPackage', str]]] = {}
+        if metadata.get('receiver'):
+            self.variables[metadata['receiver']] = self._named_type(metadata.get('receiver_type', ''))
+        for param in metadata.get('params', []):
+            if param.get('name'):
+                self.variables[param['name']] = self._named_type(param.get('type', ''))
+    
+    def calls(self) -> List[Tuple[Dict, Optional[CodeChunk]]]:
+        """(reference, callee chunk or None) per distinct call, in order of appearance"""
+        seen = set()
+        calls = []
+        
+        for site in self.sites:
+            if 'define' in site:
+                self._define(site)
+                continue
+            
+            resolved = self._resolve(site)
+            if resolved is None:
+                continue
+            ref, callee = resolved
+            key = ref.get('symbol_id') or ref['name']
+            if key not in seen:
+                seen.add(key)
+                calls.append((ref, callee))
+        
+        return calls
+    
+    def _resolve(self, site: Dict) -> Optional[Tuple[Dict, Optional[CodeChunk]]]:
+        """Resolve the callee of a call site; None if this is not a call"""
+        name = site['call']
+        if site.get('chained'):
+            return _unresolved(name)
+        
+        operand = site.get('on')
+        if operand is None:
+            if name in GO_BUILTINS or name in GO_PREDECLARED_TYPES or name in self.package.types \
+                    or name in self.package.aliases:
+                return None  # builtins and type conversions are not calls
+            if name in self.variables:
+                return _unresolved(name)  # func-typed variable or parameter
+            callee = self.package.functions.get(name)
+            return (symbol_ref(callee, self.package), callee) if callee else _unresolved(name)
+        
+        # Method call on a variable of known type, through its method set
+        if operand in self.variables:
+            if self.variables[operand] is None:
+                return _unresolved(name)
+            package, type_name = self.variables[operand]
+            callee = package.method_chunk(type_name, name) if package else None
+            if callee:
+                return symbol_ref(callee, package), callee
+            # Interface values and unindexed types: the callee is dynamic or unknown
+            return _unresolved(f"{type_name}.{name}")
+        
+        # C functions of a cgo preamble are never indexed
+        if operand == CGO_PACKAGE:
+            return _unresolved(f"{operand}.{name}")
+        
+        # Qualified call into another indexed package: pkg.Func(...)
+        candidates = [p for p in self.packages_by_name.get(operand, []) if name in p.functions]
+        if len(candidates) == 1:
+            callee = candidates[0].functions[name]
+            return symbol_ref(callee, candidates[0]), callee
+        return _unresolved(f"{operand}.{name}")
+    
+    def _define(self, site: Dict):
+        """Record the type of locals declared as 'var x T', 'x := T{...}' or 'x := NewT(...)'"""
+        names = site['define']
+        for name in names:
+            self.variables[name] = self._named_type(site['type']) if site.get('type') else None
+        # x, err := ... assigns the first result to x
+        if names and site.get('value'):
+            self.variables[names[0]] = self._expression_type(site['value'])
+    
+    def _expression_type(self, value: Dict) -> Optional[Tuple['GoPackage', str]]:
+        """Static type of a declared value: composite literals and calls of indexed functions"""
+        qualifier, _, name = (value.get('composite') or value.get('call', '')).rpartition('.')
+        
+        # pkg.T{...} or pkg.NewT(...) resolve against that package
+        package = self.package
+        if qualifier:
+            candidates = self.packages_by_name.get(qualifier, [])
+            if len(candidates) != 1:
+                return None
+            package = candidates[0]
+        
+        if 'composite' in value and package.resolve_alias(name) in package.types:
+            return package, name
+        if 'call' in value and name in package.functions:
+            results = (package.functions[name].metadata or {}).get('results', [])
+            return self._named_type(results[0]['type'], package) if results else None
+        return None
+    
+    def _named_type(self, type_text: str, package: Optional['GoPackage'] = None,
+                    depth: int = 0) -> Optional[Tuple['GoPackage', str]]:
+        """
+        (package, type name) for a type expression, resolving 'pkg.T' qualifiers to
+        indexed packages and aliases of a type in another package to that type
+        """
+        package = package or self.package
+        name = type_text.strip().lstrip('*').strip().split('[', 1)[0]
+        if not name:
+            return None
+        if '.' in name:
+            qualifier, name = name.rsplit('.', 1)
+            candidates = self.packages_by_name.get(qualifier, [])
+            package = candidates[0] if len(candidates) == 1 else None
+        alias = package.aliases.get(package.resolve_alias(name)) if package else None
+        if alias and '.' in (alias.metadata.get('aliased') or '') and depth < MAX_ALIAS_DEPTH:
+            return self._named_type(alias.metadata['aliased'], package, depth + 1)
+        return package, name
+
+
+def _unresolved(name: str) -> Tuple[Dict, None]:
+    return {'name': name, 'resolved': False}, None
@@ -0,0 +1,839 @@
+#!/usr/bin/env python3
+"""
+Go code chunker using tree-sitter for accurate parsing
+Supports .go files
+
+tree-sitter recovers from syntax errors on its own, so a broken function
+does not fail the whole file. The call sites of function bodies are read off
+the tree for the package linker's call graph (go_call_graph.call_sites); the
+code of each chunk is then read by the Go lexer (go_lexer.py) for the imports,
+cgo names, error handling and templates it uses.
+"""
+
+import re
+from typing import List, Optional, Tuple, Dict
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error, tree_sitter_diagnostics
+from .go_call_graph import call_sites
+from .go_constants import BASIC_TYPES, Unevaluable, convert, evaluate_constant, format_value
+from .go_errors import error_handling, returns_error
+from .go_imports import CGO_PACKAGE, GoImport, cgo_references, default_package_name, referenced_imports
+from .go_lexer import GoToken, match_bracket, normalize_signature, normalize_type, tokenize_go
+from .go_struct_tags import parse_struct_tag
+from .go_templates import rendered_templates
+
+
+# Comment lines that are tool directives, not documentation (//go:generate, //nolint:all, //line)
+DIRECTIVE_PATTERN = re.compile(r'^(?:[a-z0-9]+:[a-z0-9]|line |extern |export )')
+
+# Top-level declarations of a Go file
+GO_DECLARATIONS = ('package_clause', 'import_declaration', 'function_declaration', 'method_declaration',
+                   'type_declaration', 'const_declaration', 'var_declaration')
+
+# Interface elements that declare a method (method_spec in older grammars)
+INTERFACE_METHODS = ('method_elem', 'method_spec')
+
+
+def doc_comment_text(comments: List[str]) -> str:
+    """Text of a doc comment group without comment markers or directive lines"""
+    lines = []
+    for comment in comments:
+        if comment.startswith('//'):
+            body = comment[2:]
+            if DIRECTIVE_PATTERN.match(body):
+                continue
+            lines.append(body[1:] if body.startswith(' ') else body)
+        else:
+            for line in comment[2:-2].splitlines():
+                line = line.strip()
+                lines.append(line[1:].lstrip() if line.startswith('*') else line)
+    return '\n'.join(lines).strip()
+
+
+def deprecation_notice(doc: str) -> Optional[str]:
+    """The message of a 'Deprecated: ...' paragraph in a doc comment, if any"""
+    for paragraph in re.split(r'\n\s*\n', doc):
+        if paragraph.startswith('Deprecated:'):
+            return ' '.join(paragraph[len('Deprecated:'):].split()) or 'deprecated'
+    return None
+
+
+def base_type_name(type_text: str) -> str:
+    """Strip pointers, package qualifiers and type arguments: '*pkg.List[T]' -> 'List'"""
+    name = type_text.strip().lstrip('*').strip()
+    name = name.split('[', 1)[0]
+    return name.rsplit('.', 1)[-1].strip()
+
+
+# Chunk types of const and var specs
+GO_VALUE_KINDS = ('const', 'var')
+
+# Chunks kept whatever their size
+GO_UNFILTERED_KINDS = GO_VALUE_KINDS + ('interface_method', 'package')
+
+# Signatures show a constant's expression when it has no known value, if it is this short
+MAX_SIGNATURE_EXPRESSION = 80
+
+
+class GoChunker(BaseChunker):
+    """Extracts functions, methods, type declarations, constants and variables from Go code"""
+    
+    def __init__(self, module_path: Optional[str] = None, package_clauses: bool = False):
+        """
+        Args:
+            module_path: Module path from the file's go.mod, to tell internal imports from third-party ones
+            package_clauses: Also extract the package clause with its doc comment, as a
+                'package' chunk for the package linker to summarize the package in
+        """
+        super().__init__('go')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('go')
+        self.module_path = module_path
+        self.package_clauses = package_clauses
+        self.imports: List[GoImport] = []
+        self.cgo = False
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """
+        Extract all Go code elements
+        
+        A declaration containing a syntax error keeps what parsed, with
+        'parse_error' in its metadata, and every problem is listed in
+        self.diagnostics.
+        
+        The file's import specs are kept in self.imports, and every chunk that
+        refers to an imported package lists it in its 'imports' metadata.
+        In a cgo file (self.cgo) the C names a chunk uses go into 'cgo_symbols'.
+        Functions and methods record 'returns_error' and, when their code makes,
+        wraps, returns or checks errors, how in 'error_handling' (see go_errors).
+        Chunks executing or parsing templates list them in 'templates' and
+        'template_files' (see go_templates).
+        """
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._comments_by_end_line = {self._line_end(c): c for c in self._comments(tree.root_node)}
+        self._const_specs: Dict[str, Tuple[CodeChunk, List[GoToken], int]] = {}
+        chunks = []
+        package = ''
+        self.imports = []
+        self.cgo = False
+        
+        for node in self._declarations(tree.root_node):
+            extracted = []
+            
+            if node.type == 'package_clause':
+                name = next((c for c in node.named_children if c.type == 'package_identifier'), None)
+                if name is not None:
+                    package = self._text(name)
+                    if self.package_clauses:
+                        extracted = [self._package_clause(node, package, filepath)]
+            
+            elif node.type in ('function_declaration', 'method_declaration'):
+                chunk = self._extract_func(node, filepath)
+                if chunk:
+                    self._attach_doc(chunk, self._doc_comment(self._line(node)))
+                    extracted.append(chunk)
+                else:
+                    self.diagnostics.append(parse_error(self._line(node), "malformed func declaration"))
+            
+            elif node.type == 'type_declaration':
+                extracted = self._extract_types(node, filepath)
+            
+            elif node.type in ('const_declaration', 'var_declaration'):
+                extracted = self._extract_values(node, filepath)
+            
+            elif node.type == 'import_declaration':
+                self.imports.extend(self._import_specs(node))
+            
+            else:
+                self.diagnostics.append(parse_error(self._line(node), "non-declaration statement outside function body"))
+            
+            if node.has_error:
+                error = self._error_message(node)
+                for chunk in extracted:
+                    chunk.metadata = dict(chunk.metadata or {}, parse_error=error)
+            chunks.extend(extracted)
+        
+        self.diagnostics.sort(key=lambda d: d['line'])
+        
+        # Constants may refer to types and constants declared further down the file
+        self._evaluate_constants(chunks)
+        
+        for chunk in chunks:
+            chunk.namespace = package
+            chunk_tokens = tokenize_go(chunk.content)[0]
+            references = referenced_imports(chunk_tokens, chunk, self.imports, self.module_path)
+            if references:
+                chunk.metadata = dict(chunk.metadata or {}, imports=references)
+            c_names = cgo_references(chunk_tokens, chunk) if self.cgo else []
+            if c_names:
+                chunk.metadata = dict(chunk.metadata or {}, cgo_symbols=c_names)
+            if chunk.type in ('function', 'method'):
+                patterns = error_handling(chunk_tokens, chunk, self.imports)
+                chunk.metadata = dict(chunk.metadata, returns_error=returns_error(chunk),
+                                      **({'error_handling': patterns} if patterns else {}))
+            templates = rendered_templates(chunk_tokens)
+            if templates:
+                chunk.metadata = dict(chunk.metadata or {}, **templates)
+        
+        # Constants of an iota group and interface method specs are often tiny
+        # (Len() int), so they skip the size filter
+        return [c for c in chunks if c.type in GO_UNFILTERED_KINDS or self._should_include_chunk(c)]
+    
+    def _declarations(self, root: Node):
+        """Top-level nodes of the file, looking into the ERROR nodes tree-sitter recovered with"""
+        for node in root.named_children:
+            if node.type == 'comment':
+                continue
+            if node.type == 'ERROR':
+                yield from (child for child in node.named_children if child.type in GO_DECLARATIONS)
+                continue
+            yield node
+    
+    def _error_message(self, node: Node) -> str:
+        """What is wrong in a declaration, for its parse_error metadata"""
+        found = tree_sitter_diagnostics(node, limit=1)
+        if not found:
+            return "syntax error"
+        return f"{found[0]['message']} on line {found[0]['line']}"
+    
+    # ------------------------------------------------------------------
+    # Package clause and imports
+    # ------------------------------------------------------------------
+    
+    def _package_clause(self, node: Node, package: str, filepath: str) -> CodeChunk:
+        """
+        The package clause, with the package doc comment above it; the package
+        linker turns one clause per package into its summary (see go_package_summary)
+        """
+        chunk = CodeChunk(
+            type='package',
+            name=package,
+            content=self._text(node),
+            filepath=filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=f"package {package}",
+            metadata={}
+        )
+        self._attach_doc(chunk, self._doc_comment(self._line(node)))
+        return chunk
+    
+    def _import_specs(self, node: Node) -> List[GoImport]:
+        """
+        Import specs of one import declaration: import "fmt", import log
+        "github.com/sirupsen/logrus", or a parenthesized group.
+        import "C" is left out, and makes the file a cgo file
+        """
+        specs = [c for c in node.named_children if c.type == 'import_spec']
+        for group in (c for c in node.named_children if c.type == 'import_spec_list'):
+            specs.extend(c for c in group.named_children if c.type == 'import_spec')
+        
+        imports = []
+        for spec in specs:
+            path_node = spec.child_by_field_name('path')
+            if path_node is None:
+                continue
+            path = self._text(path_node).strip('"`')
+            if path == CGO_PACKAGE:
+                self.cgo = True
+                continue
+            name_node = spec.child_by_field_name('name')
+            alias = self._text(name_node) if name_node is not None else None
+            if path:
+                imports.append(GoImport(path, alias or default_package_name(path), self._line(path_node), alias))
+        return imports
+    
+    # ------------------------------------------------------------------
+    # Functions and methods
+    # ------------------------------------------------------------------
+    
+    def _extract_func(self, node: Node, filepath: str) -> Optional[CodeChunk]:
+        """Extract a function or method declaration"""
+        name_node = node.child_by_field_name('name')
+        params_node = node.child_by_field_name('parameters')
+        if name_node is None or params_node is None:
+            return None
+        name = self._text(name_node)
+        
+        # Method receiver: func (r *T) Name(...)
+        receiver_node = node.child_by_field_name('receiver')
+        receiver = self._parse_params(receiver_node) if receiver_node is not None else []
+        
+        # Type parameters: func Name[T any](...)
+        type_params_node = node.child_by_field_name('type_parameters')
+        type_params = self._parse_type_params(type_params_node) if type_params_node is not None else []
+        
+        params = self._parse_params(params_node)
+        result_node = node.child_by_field_name('result')
+        results = self._parse_results(result_node)
+        
+        signature = "func "
+        metadata: Dict = {
+            'params': [{'name': n, 'type': t} for n, t in params],
+            'results': [{'name': n, 'type': t} for n, t in results],
+            'signature_key': self._signature_key(params, results),
+        }
+        if type_params:
+            metadata['type_params'] = type_params
+        # Resolved to 'calls' by the package linker, once every package is known
+        body_node = node.child_by_field_name('body')
+        if body_node is not None:
+            metadata['call_sites'] = call_sites(body_node, self._text)
+        
+        parent_class = ''
+        if receiver:
+            recv_name, recv_type = receiver[0]
+            parent_class = base_type_name(recv_type)
+            signature += f"{self._text(receiver_node)} "
+            metadata.update({
+                'receiver': recv_name,
+                'receiver_type': recv_type,
+                'pointer_receiver': recv_type.strip().startswith('*'),
+            })
+            # Methods of generic types name the type's parameters: (l *List[T])
+            recv_args = recv_type.split('[', 1)[1].rstrip(' ]') if '[' in recv_type else ''
+            if recv_args:
+                metadata['receiver_type_params'] = [arg.strip() for arg in recv_args.split(',')]
+        
+        signature += name
+        if type_params_node is not None:
+            signature += self._text(type_params_node)
+        signature += self._text(params_node)
+        if result_node is not None:
+            signature += f" {self._text(result_node)}"
+        
+        return CodeChunk(
+            type='method' if receiver else 'function',
+            name=name,
+            content=self._text(node),
+            filepath=filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(signature),
+            parent_class=parent_class,
+            parent=parent_class or None,
+            metadata=metadata
+        )
+    
+    def _parse_params(self, node: Node) -> List[Tuple[str, str]]:
+        """
+        Parse a parameter list into (name, type) pairs
+        
+        Go allows either all-named (a, b int) or all-unnamed (int, string) lists
+        """
+        params: List[Tuple[str, str]] = []
+        for decl in node.named_children:
+            if decl.type not in ('parameter_declaration', 'variadic_parameter_declaration'):
+                continue
+            type_node = decl.child_by_field_name('type')
+            type_text = self._text(type_node) if type_node is not None else ''
+            if decl.type == 'variadic_parameter_declaration':
+                type_text = f"...{type_text}"
+            names = decl.children_by_field_name('name')
+            if not names:
+                params.append(('', type_text))
+            params.extend((self._text(name), type_text) for name in names)
+        return params
+    
+    def _parse_results(self, node: Optional[Node]) -> List[Tuple[str, str]]:
+        """Parse a function result: a single type or a parenthesized list"""
+        if node is None:
+            return []
+        if node.type == 'parameter_list':
+            return self._parse_params(node)
+        return [('', self._text(node))]
+    
+    def _parse_type_params(self, node: Node) -> List[Dict]:
+        """
+        Parse a type parameter list: [K comparable, V any] or [T ~int | ~string]
+        Constraints are kept verbatim (unions, inline interfaces, ~ terms)
+        """
+        type_params = []
+        for decl in node.named_children:
+            if decl.type != 'type_parameter_declaration':
+                continue
+            constraint_node = decl.child_by_field_name('type')
+            constraint = self._text(constraint_node) if constraint_node is not None else ''
+            for name in decl.children_by_field_name('name'):
+                type_params.append({'name': self._text(name), 'constraint': constraint})
+        return type_params
+    
+    def _signature_key(self, params: List[Tuple[str, str]], results: List[Tuple[str, str]]) -> str:
+        """Name-independent signature used to compare methods: '(string, string) bool'"""
+        param_types = ', '.join(normalize_type(t) for _, t in params)
+        result_types = ', '.join(normalize_type(t) for _, t in results)
+        if len(results) > 1:
+            result_types = f"({result_types})"
+        return f"({param_types}) {result_types}".strip()
+    
+    # ------------------------------------------------------------------
+    # Type declarations
+    # ------------------------------------------------------------------
+    
+    def _extract_types(self, node: Node, filepath: str) -> List[CodeChunk]:
+        """Extract a type declaration or a grouped type ( ... ) block"""
+        group_doc = self._doc_comment(self._line(node))
+        specs = [c for c in node.named_children if c.type in ('type_spec', 'type_alias')]
+        if not any(c.type == '(' for c in node.children):
+            declared = self._extract_type_spec(specs[0], filepath, keyword=node) if specs else []
+            if declared:
+                self._attach_doc(declared[0], group_doc)
+            return declared
+        
+        chunks = []
+        for spec in specs:
+            declared = self._extract_type_spec(spec, filepath)
+            if declared:
+                # As in go/doc: a lone spec without its own comment takes the group's
+                doc = self._doc_comment(self._line(spec)) or (group_doc if len(specs) == 1 else '')
+                self._attach_doc(declared[0], doc)
+                chunks.extend(declared)
+        return chunks
+    
+    def _extract_type_spec(self, spec: Node, filepath: str, keyword: Optional[Node] = None) -> List[CodeChunk]:
+        """
+        Extract a single type spec: Name [TypeParams] [=] Type
+        
+        Returns:
+            The type's chunk, followed by one chunk per method an interface declares
+        """
+        name_node = spec.child_by_field_name('name')
+        type_node = spec.child_by_field_name('type')
+        if name_node is None or type_node is None:
+            return []
+        name = self._text(name_node)
+        
+        metadata: Dict = {}
+        method_specs: List[Node] = []
+        type_params_node = spec.child_by_field_name('type_parameters')
+        if type_params_node is not None:
+            type_params = self._parse_type_params(type_params_node)
+            if type_params:
+                metadata['type_params'] = type_params
+        
+        head = {'struct_type': 'struct', 'interface_type': 'interface'}.get(type_node.type)
+        # type A = B declares an alias: another name for B, not a new type
+        if spec.type == 'type_alias':
+            kind = 'alias'
+            metadata['aliased'] = normalize_type(self._text(type_node))
+        elif head == 'struct':
+            kind = 'struct'
+            metadata['fields'] = self._parse_struct_fields(type_node)
+        elif head == 'interface':
+            kind = 'interface'
+            methods, embeds, type_terms, method_specs = self._parse_interface(type_node)
+            metadata['methods'] = methods
+            metadata['embeds'] = embeds
+            if type_terms:
+                metadata['type_terms'] = type_terms
+        else:
+            kind = 'type'
+            metadata['underlying'] = normalize_type(self._text(type_node))
+        
+        first = keyword or spec
+        if head:
+            declared = self._source[spec.start_byte:type_node.start_byte].decode('utf8', errors='replace')
+            signature = f"type {declared.strip()} {head}"
+        else:
+            signature = f"type {self._text(spec)}"
+        
+        chunk = CodeChunk(
+            type=kind,
+            name=name,
+            content=self._source[first.start_byte:spec.end_byte].decode('utf8', errors='replace'),
+            filepath=filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(spec),
+            signature=normalize_signature(signature),
+            metadata=metadata
+        )
+        members = [self._interface_method_chunk(chunk, method, elem, filepath)
+                   for method, elem in zip(metadata.get('methods', []), method_specs)]
+        return [chunk] + members
+    
+    def _interface_method_chunk(self, iface: CodeChunk, method: Dict, elem: Node, filepath: str) -> CodeChunk:
+        """A method an interface requires, as a symbol of its own (Authenticator.Authenticate)"""
+        chunk = CodeChunk(
+            type='interface_method',
+            name=method['name'],
+            content=self._source[elem.start_byte:self._trailing_comment_end(elem)].decode('utf8', errors='replace'),
+            filepath=filepath,
+            language=self.language,
+            line_start=self._line(elem),
+            line_end=self._line_end(elem),
+            signature=method['signature'],
+            parent_class=iface.name,
+            parent=iface.name,
+            metadata={'signature_key': method['signature_key'], 'interface': iface.name}
+        )
+        self._attach_doc(chunk, self._doc_comment(self._line(elem)))
+        # A bare method spec does not say which contract it belongs to
+        chunk.context = f"type {iface.name} interface"
+        return chunk
+    
+    def _parse_struct_fields(self, struct_type: Node) -> List[Dict]:
+        """Parse the fields of a struct type, marking embedded fields and reading their tags"""
+        field_list = next((c for c in struct_type.named_children if c.type == 'field_declaration_list'), None)
+        if field_list is None:
+            return []
+        fields = []
+        
+        for field in field_list.named_children:
+            if field.type != 'field_declaration':
+                continue
+            type_node = field.child_by_field_name('type')
+            if type_node is None:
+                continue
+            tag_node = field.child_by_field_name('tag')
+            tag = self._text(tag_node) if tag_node is not None else None
+            names = field.children_by_field_name('name')
+            
+            # An embedded field is just a type name: [*][pkg.]Name[TypeArgs]
+            if not names:
+                type_text = self._source[field.start_byte:type_node.end_byte].decode('utf8', errors='replace')
+                fields.append({
+                    'name': base_type_name(type_text),
+                    'type': normalize_type(type_text),
+                    'embedded': True,
+                    'pointer': type_text.startswith('*'),
+                    'tag': tag,
+                    'tags': parse_struct_tag(tag) if tag else {},
+                })
+                continue
+            
+            # Named fields: a, b int
+            for name in names:
+                fields.append({
+                    'name': self._text(name),
+                    'type': normalize_type(self._text(type_node)),
+                    'embedded': False,
+                    'pointer': False,
+                    'tag': tag,
+                    'tags': parse_struct_tag(tag) if tag else {},
+                })
+        
+        return fields
+    
+    def _parse_interface(self, interface_type: Node) -> Tuple[List[Dict], List[str], List[str], List[Node]]:
+        """
+        Parse interface elements into methods, embedded interfaces and type-set terms
+        
+        Returns:
+            Tuple of (methods, embeds, type terms, the node of each method's spec)
+        """
+        methods = []
+        method_specs = []
+        embeds = []
+        type_terms = []  # ~int | ~string: only usable as a constraint
+        
+        for elem in interface_type.named_children:
+            if elem.type == 'comment':
+                continue
+            name_node = elem.child_by_field_name('name')
+            params_node = elem.child_by_field_name('parameters')
+            if elem.type in INTERFACE_METHODS and name_node is not None and params_node is not None:
+                params = self._parse_params(params_node)
+                results = self._parse_results(elem.child_by_field_name('result'))
+                methods.append({
+                    'name': self._text(name_node),
+                    'signature': normalize_signature(self._text(elem)),
+                    'signature_key': self._signature_key(params, results),
+                    'line': self._line(elem),
+                })
+                method_specs.append(elem)
+                continue
+            text = self._text(elem)
+            if '|' in text or '~' in text:
+                type_terms.append(text)
+            else:
+                embeds.append(normalize_type(text))
+        
+        return methods, embeds, type_terms, method_specs
+    
+    # ------------------------------------------------------------------
+    # Constants and variables
+    # ------------------------------------------------------------------
+    
+    def _extract_values(self, node: Node, filepath: str) -> List[CodeChunk]:
+        """
+        Extract a const or var declaration, one chunk per declared name
+        
+        In a const group a spec without '=' repeats the type and expression list
+        of the spec above it, and iota counts the specs of the group.
+        """
+        keyword = 'const' if node.type == 'const_declaration' else 'var'
+        group_doc = self._doc_comment(self._line(node))
+        spec_type = f"{keyword}_spec"
+        specs = [c for c in node.named_children if c.type == spec_type]
+        # Newer grammars wrap the specs of a var ( ... ) group in a var_spec_list
+        for group in (c for c in node.named_children if c.type == 'var_spec_list'):
+            specs.extend(c for c in group.named_children if c.type == spec_type)
+        grouped = any(c.type in ('(', 'var_spec_list') for c in node.children)
+        chunks = []
+        previous: Tuple[Optional[Node], List[Node]] = (None, [])
+        
+        for iota, spec in enumerate(specs):
+            names = [self._text(n) for n in spec.children_by_field_name('name')]
+            if not names:
+                continue
+            
+            type_node = spec.child_by_field_name('type')
+            value_node = spec.child_by_field_name('value')
+            expressions = [c for c in value_node.named_children if c.type != 'comment'] if value_node is not None else []
+            implicit = keyword == 'const' and value_node is None and type_node is None
+            if implicit:
+                type_node, expressions = previous
+            elif keyword == 'const':
+                previous = (type_node, expressions)
+            
+            # As in go/doc, specs without a comment of their own share the group's
+            doc = (self._doc_comment(self._line(spec)) or group_doc) if grouped else group_doc
+            first = spec if grouped else node
+            content = self._source[first.start_byte:self._trailing_comment_end(spec)].decode('utf8', errors='replace')
+            
+            for index, name in enumerate(names):
+                if name == '_':
+                    continue
+                expression = expressions[index] if index < len(expressions) else None
+                metadata: Dict = {}
+                # var conn, err = dial(addr): each name takes one result of the call
+                if keyword == 'var' and len(names) > 1 and len(expressions) == 1:
+                    expression = expressions[0]
+                    metadata['result'] = index
+                if type_node is not None:
+                    metadata['type'] = normalize_type(self._text(type_node))
+                if expression is not None:
+                    metadata['expression'] = self._text(expression)
+                if implicit:
+                    metadata['implicit'] = True
+                
+                # var cfg struct{...} and var cfg = struct{...}{...}: the struct has no name of its
+                # own, so it is described on the var under one derived from it (keywords are
+                # not identifiers, so cfg.struct cannot clash with a real symbol)
+                anonymous = self._anonymous_struct(type_node, expression) if keyword == 'var' else None
+                if anonymous:
+                    struct_type, pointer = anonymous
+                    metadata['struct_name'] = f"{name}.struct"
+                    metadata['fields'] = self._parse_struct_fields(struct_type)
+                    if type_node is None:
+                        struct_text = normalize_type(self._text(struct_type))
+                        metadata['type'] = f"*{struct_text}" if pointer else struct_text
+                elif keyword == 'var' and type_node is None and expression is not None:
+                    metadata.update(self._initializer(expression))
+                
+                chunk = CodeChunk(
+                    type=keyword,
+                    name=name,
+                    content=content,
+                    filepath=filepath,
+                    language=self.language,
+                    line_start=self._line(first),
+                    line_end=self._line_end(spec),
+                    metadata=metadata
+                )
+                self._attach_doc(chunk, doc)
+                chunk.signature = self._value_signature(chunk)
+                # A spec of a group (or one of several names) does not say what it declares on its own
+                if grouped or len(names) > 1:
+                    chunk.context = chunk.signature
+                if keyword == 'const':
+                    self._const_specs[name] = (chunk, self._expression_tokens(expression), iota)
+                chunks.append(chunk)
+        
+        return chunks
+    
+    def _anonymous_struct(self, type_node: Optional[Node], expression: Optional[Node]) -> Optional[Tuple[Node, bool]]:
+        """
+        The struct type of a var declared with an anonymous struct type, or
+        initialized with a composite literal of one ([&]struct{...}{...}), and
+        whether it is a pointer
+        """
+        if type_node is not None:
+            pointer = type_node.type == 'pointer_type'
+            inner = type_node.named_children[0] if pointer and type_node.named_children else type_node
+            return (inner, pointer) if inner.type == 'struct_type' else None
+        
+        if expression is None:
+            return None
+        pointer, literal = self._address_of(expression)
+        if literal.type != 'composite_literal':
+            return None
+        literal_type = literal.child_by_field_name('type')
+        if literal_type is not None and literal_type.type == 'struct_type':
+            return literal_type, pointer
+        return None
+    
+    def _initializer(self, expression: Node) -> Dict:
+        """
+        What a var without a type is initialized with: 'inferred_type' for a composite
+        literal (&Manager{...}, []string{...}) or new(T), 'initializer' for a call
+        (NewManager(store), sessions.New(), a conversion), whose type the package
+        linker infers
+        """
+        pointer, value = self._address_of(expression)
+        if value.type == 'composite_literal':
+            literal_type = value.child_by_field_name('type')
+            if literal_type is None:
+                return {}
+            prefix = normalize_type(self._text(literal_type))
+            return {'inferred_type': f"*{prefix}" if pointer else prefix}
+        
+        if value.type != 'call_expression' or pointer:
+            return {}
+        function = value.child_by_field_name('function')
+        arguments = value.child_by_field_name('arguments')
+        if function is None or arguments is None:
+            return {}
+        callee = self._text(function)
+        if callee == 'new':
+            args = [c for c in arguments.named_children if c.type != 'comment']
+            return {'inferred_type': '*' + normalize_type(self._text(args[0]))} if len(args) == 1 else {}
+        if re.fullmatch(r'\w+(\.\w+)?', callee):
+            return {'initializer': callee}
+        return {}
+    
+    def _address_of(self, expression: Node) -> Tuple[bool, Node]:
+        """Whether an expression takes an address (&T{...}), and the expression it is taken of"""
+        if expression.type == 'unary_expression':
+            operator = expression.child_by_field_name('operator')
+            operand = expression.child_by_field_name('operand')
+            if operator is not None and operand is not None and self._text(operator) == '&':
+                return True, operand
+        return False, expression
+    
+    def _expression_tokens(self, expression: Optional[Node]) -> List[GoToken]:
+        """Tokens of a constant expression for go_constants, without the semicolon ending it"""
+        if expression is None:
+            return []
+        tokens = tokenize_go(self._text(expression))[0]
+        while tokens and tokens[-1].kind == ';':
+            tokens.pop()
+        return tokens
+    
+    def _evaluate_constants(self, chunks: List[CodeChunk]):
+        """Compute the values of this file's constants and infer their types from conversions"""
+        local_types = {c.name: c.metadata.get('underlying', '') for c in chunks if c.type == 'type'}
+        local_types.update({c.name: c.metadata.get('aliased', '') for c in chunks if c.type == 'alias'})
+        local_types.update({c.name: '' for c in chunks if c.type in ('struct', 'interface')})
+        values: Dict[str, Optional[Tuple]] = {}
+        
+        def basic_type(name: str, depth: int = 0) -> Optional[str]:
+            if name in BASIC_TYPES:
+                return name
+            if name in local_types and depth < 8:
+                return basic_type(local_types[name], depth + 1)
+            return None
+        
+        def lookup(name: str) -> Optional[Tuple]:
+            if name in values:
+                return values[name]  # None while being evaluated: a cycle
+            if name not in self._const_specs:
+                return None
+            values[name] = None
+            chunk, expression, iota = self._const_specs[name]
+            # A declared type applies to the value: Read Mode = 1 << iota is a uint8 for Mode uint8
+            declared = basic_type(chunk.metadata.get('type', ''))
+            try:
+                value = evaluate_constant(expression, iota, lookup, basic_type)
+                values[name] = convert(value, declared) if declared else value
+            except Unevaluable:
+                pass
+            return values[name]
+        
+        for name, (chunk, expression, iota) in self._const_specs.items():
+            metadata = chunk.metadata
+            value = lookup(name)
+            if value is not None:
+                metadata['value'] = value[0]
+            if any(tok.value == 'iota' for tok in expression):
+                metadata['iota'] = iota
+            # const Max = Limit(10) (or ^uint32(0)) has the type it was converted to
+            conversion = expression[1:] if expression and expression[0].value in ('+', '-', '^') else expression
+            if 'type' not in metadata and len(conversion) > 2 and conversion[0].kind == 'ident' \
+                    and basic_type(conversion[0].value) and conversion[1].value == '(' \
+                    and match_bracket(conversion, 1) == len(conversion) - 1:
+                metadata['type'] = conversion[0].value
+            chunk.signature = self._value_signature(chunk)
+            if chunk.context:
+                chunk.context = chunk.signature
+    
+    def _value_signature(self, chunk: CodeChunk) -> str:
+        """'const Forbidden StatusCode = 2': the value if known, else a short expression"""
+        metadata = chunk.metadata
+        signature = f"{chunk.type} {chunk.name}"
+        if metadata.get('type'):
+            signature += f" {metadata['type']}"
+        if 'value' in metadata:
+            signature += f" = {format_value(metadata['value'])}"
+        elif metadata.get('expression') and '\n' not in metadata['expression'] \
+                and len(metadata['expression']) <= MAX_SIGNATURE_EXPRESSION:
+            signature += f" = {metadata['expression']}"
+        return normalize_signature(signature)
+    
+    def _trailing_comment_end(self, node: Node) -> int:
+        """End of a spec including a comment on the same line after it (Forbidden // 403)"""
+        comment = self._comments_by_end_line.get(self._line_end(node))
+        if comment is not None and self._line(comment) == self._line_end(node) and comment.start_byte >= node.end_byte:
+            return comment.end_byte
+        return node.end_byte
+    
+    # ------------------------------------------------------------------
+    # Doc comments
+    # ------------------------------------------------------------------
+    
+    def _comments(self, root: Node) -> List[Node]:
+        """Every comment of the file, in source order"""
+        comments = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type == 'comment':
+                comments.append(node)
+            else:
+                stack.extend(reversed(node.children))
+        return comments
+    
+    def _doc_comment(self, line: int) -> str:
+        """Doc comment of a declaration starting on line: the comment group directly above it"""
+        group = []
+        comment = self._comments_by_end_line.get(line - 1)
+        while comment is not None and self._starts_line(comment):
+            group.insert(0, self._text(comment))
+            comment = self._comments_by_end_line.get(self._line(comment) - 1)
+        return doc_comment_text(group)
+    
+    def _starts_line(self, comment: Node) -> bool:
+        """True if only whitespace precedes the comment on its line (not a trailing comment)"""
+        line_begin = self._source.rfind(b'\n', 0, comment.start_byte) + 1
+        return not self._source[line_begin:comment.start_byte].strip()
+    
+    def _attach_doc(self, chunk: CodeChunk, doc: str):
+        """Store a doc comment on its chunk, flagging the // Deprecated: convention"""
+        if not doc:
+            return
+        chunk.doc = doc
+        deprecated = deprecation_notice(doc)
+        if deprecated:
+            chunk.metadata = dict(chunk.metadata or {}, deprecated=deprecated)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _text(self, node: Node) -> str:
+        """Source text of a node"""
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        """1-based line a node starts on"""
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        """1-based line a node ends on"""
+        return node.end_point[0] + 1
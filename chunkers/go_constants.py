@@ -0,0 +1,301 @@
+#!/usr/bin/env python3
+"""
+Static evaluation of Go constant expressions
+Computes the values of const specs the way the compiler would for the common
+cases: integer, float, string, rune and bool literals, iota, references to
+other constants of the file, conversions to predeclared or local types, and
+the arithmetic, bitwise, shift and comparison operators. Anything else
+(function calls, constants of other packages, unsafe.Sizeof) leaves the
+value unknown.
+"""
+
+import ast
+import json
+import math
+import re
+from typing import TYPE_CHECKING, Callable, List, Optional, Tuple, Union
+
+if TYPE_CHECKING:
+    from .go_lexer import GoToken
+
+
+Value = Union[int, float, str, bool]
+
+# Width of the sized unsigned types: ^uint32(0) is 4294967295, not -1
+UNSIGNED_BITS = {'uint8': 8, 'byte': 8, 'uint16': 16, 'uint32': 32, 'uint64': 64, 'uint': 64, 'uintptr': 64}
+
+INTEGER_TYPES = {'int', 'int8', 'int16', 'int32', 'int64', 'rune'} | set(UNSIGNED_BITS)
+FLOAT_TYPES = {'float32', 'float64'}
+BASIC_TYPES = INTEGER_TYPES | FLOAT_TYPES | {'string', 'bool'}
+
+# Binary operator precedence (Go spec, "Operator precedence")
+PRECEDENCE = {
+    '||': 1, '&&': 2,
+    '==': 3, '!=': 3, '<': 3, '<=': 3, '>': 3, '>=': 3,
+    '+': 4, '-': 4, '|': 4, '^': 4,
+    '*': 5, '/': 5, '%': 5, '<<': 5, '>>': 5, '&': 5, '&^': 5,
+}
+
+# Larger shifts are almost certainly not meant to be evaluated (and would be slow)
+MAX_SHIFT = 1024
+
+
+class Unevaluable(Exception):
+    """The expression has no statically known value"""
+
+
+def evaluate_constant(tokens: List['GoToken'], iota: int,
+                      lookup: Callable[[str], Optional[Tuple[Value, int]]],
+                      basic_type: Callable[[str], Optional[str]]) -> Tuple[Value, int]:
+    """
+    Evaluate a constant expression
+    
+    Args:
+        tokens: Tokens of the expression
+        iota: Index of the spec in its const group
+        lookup: (value, unsigned width) of another constant, or None if unknown
+        basic_type: Predeclared type a type name stands for ('StatusCode' -> 'int'),
+            or None if the name is not a type
+    
+    Returns:
+        Tuple of (value, width of the unsigned type it has, or 0)
+    
+    Raises:
+        Unevaluable: If the value cannot be computed statically
+    """
+    parser = _Parser(tokens, iota, lookup, basic_type)
+    result = parser.expression(1)
+    if parser.pos != len(tokens):
+        raise Unevaluable(f"unexpected {tokens[parser.pos].value!r}")
+    return result
+
+
+def format_value(value: Value) -> str:
+    """A value as Go source: 42, 2.5, "text", true"""
+    if isinstance(value, bool):
+        return 'true' if value else 'false'
+    if isinstance(value, str):
+        return json.dumps(value, ensure_ascii=False)
+    return repr(value)
+
+
+def convert(result: Tuple[Value, int], basic: str) -> Tuple[Value, int]:
+    """
+    A constant converted to a predeclared type (or one declared with that type)
+    
+    Raises:
+        Unevaluable: If Go would reject the conversion
+    """
+    value, _ = result
+    if basic in INTEGER_TYPES:
+        if isinstance(value, float) and value.is_integer():
+            value = int(value)
+        if isinstance(value, bool) or not isinstance(value, int):
+            raise Unevaluable(f"{basic}({value!r})")
+        bits = UNSIGNED_BITS.get(basic, 0)
+        return _wrap(value, bits), bits
+    if basic in FLOAT_TYPES:
+        if isinstance(value, (bool, str)):
+            raise Unevaluable(f"{basic}({value!r})")
+        return float(value), 0
+    if basic == 'string' and isinstance(value, str):
+        return value, 0
+    if basic == 'bool' and isinstance(value, bool):
+        return value, 0
+    raise Unevaluable(f"{basic}({value!r})")
+
+
+class _Parser:
+    """Precedence-climbing evaluator over a token list"""
+    
+    def __init__(self, tokens, iota, lookup, basic_type):
+        self.tokens = tokens
+        self.pos = 0
+        self.iota = iota
+        self.lookup = lookup
+        self.basic_type = basic_type
+    
+    def expression(self, min_precedence: int) -> Tuple[Value, int]:
+        left = self.unary()
+        while self.pos < len(self.tokens):
+            op = self.tokens[self.pos].value
+            precedence = PRECEDENCE.get(op) if self.tokens[self.pos].kind == 'op' else None
+            if precedence is None or precedence < min_precedence:
+                break
+            self.pos += 1
+            right = self.expression(precedence + 1)
+            left = _binary(op, left, right)
+        return left
+    
+    def unary(self) -> Tuple[Value, int]:
+        tok = self._next()
+        if tok.kind == 'op' and tok.value in ('+', '-', '!', '^'):
+            value, bits = self.unary()
+            if tok.value == '!':
+                if not isinstance(value, bool):
+                    raise Unevaluable("! of a non-boolean")
+                return not value, 0
+            if isinstance(value, (bool, str)) or (tok.value == '^' and not isinstance(value, int)):
+                raise Unevaluable(f"{tok.value} of a {type(value).__name__}")
+            if tok.value == '+':
+                return value, bits
+            result = -value if tok.value == '-' else ~value
+            return _wrap(result, bits), bits
+        return self.primary(tok)
+    
+    def primary(self, tok) -> Tuple[Value, int]:
+        if tok.kind == 'number':
+            return _number(tok.value), 0
+        if tok.kind == 'string':
+            return _string(tok.value), 0
+        if tok.kind == 'rune':
+            text = _string(tok.value)
+            if len(text) != 1:
+                raise Unevaluable(f"rune literal {tok.value}")
+            return ord(text), 0
+        if tok.value == '(':
+            result = self.expression(1)
+            self._expect(')')
+            return result
+        if tok.kind != 'ident':
+            raise Unevaluable(f"unexpected {tok.value!r}")
+        
+        name = tok.value
+        following = self.tokens[self.pos].value if self.pos < len(self.tokens) else ''
+        if following == '.':
+            raise Unevaluable(f"qualified name {name}.")  # constants of other packages
+        if following == '(':
+            self.pos += 1
+            argument = self.expression(1)
+            self._expect(')')
+            return self._call(name, argument)
+        if name == 'iota':
+            return self.iota, 0
+        if name in ('true', 'false'):
+            return name == 'true', 0
+        
+        known = self.lookup(name)
+        if known is None:
+            raise Unevaluable(f"unknown constant {name}")
+        return known
+    
+    def _call(self, name: str, argument: Tuple[Value, int]) -> Tuple[Value, int]:
+        """A conversion T(x), or len of a constant string"""
+        value, _ = argument
+        if name == 'len' and isinstance(value, str):
+            return len(value.encode('utf-8')), 0
+        basic = self.basic_type(name)
+        if basic is None:
+            raise Unevaluable(f"call of {name}")
+        return convert(argument, basic)
+    
+    def _next(self):
+        if self.pos >= len(self.tokens):
+            raise Unevaluable("incomplete expression")
+        tok = self.tokens[self.pos]
+        self.pos += 1
+        return tok
+    
+    def _expect(self, value: str):
+        if self._next().value != value:
+            raise Unevaluable(f"expected {value!r}")
+
+
+def _binary(op: str, left: Tuple[Value, int], right: Tuple[Value, int]) -> Tuple[Value, int]:
+    """Apply a binary operator with Go's constant semantics"""
+    a, a_bits = left
+    b, b_bits = right
+    bits = max(a_bits, b_bits)
+    
+    if op in ('&&', '||'):
+        if not (isinstance(a, bool) and isinstance(b, bool)):
+            raise Unevaluable(f"{op} of non-booleans")
+        return (a and b) if op == '&&' else (a or b), 0
+    
+    if isinstance(a, str) or isinstance(b, str):
+        if not (isinstance(a, str) and isinstance(b, str)):
+            raise Unevaluable("mixed string operands")
+        if op == '+':
+            return a + b, 0
+        return _compare(op, a, b), 0
+    
+    if isinstance(a, bool) or isinstance(b, bool):
+        if isinstance(a, bool) and isinstance(b, bool) and op in ('==', '!='):
+            return (a == b) == (op == '=='), 0
+        raise Unevaluable(f"{op} of booleans")
+    
+    if op in PRECEDENCE and PRECEDENCE[op] == 3:
+        return _compare(op, a, b), 0
+    
+    if op in ('<<', '>>'):
+        if not isinstance(a, int) or not isinstance(b, int) or b < 0 or b > MAX_SHIFT:
+            raise Unevaluable(f"shift {a!r} {op} {b!r}")
+        return _wrap(a << b if op == '<<' else a >> b, a_bits), a_bits
+    
+    if op in ('&', '|', '^', '&^', '%'):
+        if not (isinstance(a, int) and isinstance(b, int)):
+            raise Unevaluable(f"{op} of non-integers")
+    
+    if isinstance(a, float) or isinstance(b, float):
+        if op == '/' and b == 0:
+            raise Unevaluable("division by zero")
+        result = {'+': a + b, '-': a - b, '*': a * b}.get(op) if op != '/' else a / b
+        if result is None or not math.isfinite(result):
+            raise Unevaluable(f"{op} of floats")
+        return result, 0
+    
+    if op in ('/', '%'):
+        if b == 0:
+            raise Unevaluable("division by zero")
+        quotient = abs(a) // abs(b) * (1 if (a < 0) == (b < 0) else -1)  # truncated, as in Go
+        return _wrap(quotient if op == '/' else a - b * quotient, bits), bits
+    
+    result = {
+        '+': lambda: a + b, '-': lambda: a - b, '*': lambda: a * b,
+        '&': lambda: a & b, '|': lambda: a | b, '^': lambda: a ^ b, '&^': lambda: a & ~b,
+    }[op]()
+    return _wrap(result, bits), bits
+
+
+def _compare(op: str, a, b) -> bool:
+    return {'==': a == b, '!=': a != b, '<': a < b, '<=': a <= b, '>': a > b, '>=': a >= b}[op]
+
+
+def _wrap(value: Value, bits: int) -> Value:
+    """Reduce an integer to the range of an unsigned type of the given width"""
+    if bits and isinstance(value, int):
+        return value & ((1 << bits) - 1)
+    return value
+
+
+def _number(text: str) -> Union[int, float]:
+    """Value of a Go integer or float literal"""
+    text = text.replace('_', '')
+    if text.endswith('i'):
+        raise Unevaluable("imaginary literal")
+    if re.fullmatch(r'0[0-7]+', text):
+        return int(text, 8)  # legacy octal: 0755
+    try:
+        return int(text, 0)
+    except ValueError:
+        pass
+    try:
+        value = float.fromhex(text) if text[:2].lower() == '0x' else float(text)
+    except ValueError:
+        raise Unevaluable(f"number {text}")
+    if not math.isfinite(value):
+        raise Unevaluable(f"number {text}")
+    return value
+
+
+def _string(literal: str) -> str:
+    """Value of a Go string or rune literal"""
+    if literal.startswith('`'):
+        return literal[1:-1].replace('\r', '')
+    try:
+        value = ast.literal_eval(literal if literal[0] == '"' else '"' + literal[1:-1].replace('"', '\\"') + '"')
+    except (ValueError, SyntaxError):
+        raise Unevaluable(f"literal {literal}")
+    if not isinstance(value, str):
+        raise Unevaluable(f"literal {literal}")
+    return value
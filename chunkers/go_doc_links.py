@@ -0,0 +1,138 @@
+#!/usr/bin/env python3
+"""
+Symbol references in Go doc comments
+Finds the symbols a doc comment points at and resolves them to indexed chunks:
+Go doc links ([AdminUser], [User.Name], [session.Manager], [*bytes.Buffer]) and
+bare mentions of known names (AdminUser, `users`, session.NewManager). Bare
+words only count when they are exported or written in backquotes, so a doc
+saying "returns the user" does not point at a var named user; doc links that
+name nothing indexed (stdlib, unindexed packages) are kept by name only.
+"""
+
+import re
+from collections import defaultdict
+from typing import TYPE_CHECKING, Dict, List, Optional, Tuple
+
+from .base_chunker import CodeChunk
+from .go_call_graph import symbol_ref
+
+if TYPE_CHECKING:
+    from .go_package_linker import GoPackage
+
+
+# Declaration kinds a doc comment can point at by name (methods are found through their type)
+DOC_TARGET_KINDS = ('struct', 'interface', 'type', 'alias', 'function', 'const', 'var')
+
+# [Name], [Name.Name], [pkg.Name.Name], [import/path.Name], optionally [*...]; not a
+# link definition ([text]: url) nor markdown text ([text](url))
+DOC_LINK = re.compile(r'(?<![\w\]])\[\*?([A-Za-z_][\w/.-]*)\](?![:(\w])')
+
+# Identifiers and selectors written in running text (Manager, session.NewManager)
+MENTION = re.compile(r'(?<![\w.])[A-Za-z_]\w*(?:\.[A-Za-z_]\w*){0,2}(?![\w])')
+
+BACKQUOTED = re.compile(r'`([^`\n]+)`')
+
+
+def link_doc_refs(packages: Dict[Tuple[str, str], 'GoPackage']) -> None:
+    """
+    Record 'doc_refs' on Go chunks whose doc comment refers to other symbols, and
+    'doc_referenced_by' on the symbols referred to
+    
+    Each 'doc_refs' entry is a symbol reference (see symbol_ref) with its 'form',
+    'link' for Go doc link syntax or 'mention' for a bare name; doc links that
+    resolve to no indexed chunk are {'name', 'resolved': False, 'form': 'link'}.
+    A chunk never refers to itself.
+    
+    Args:
+        packages: GoPackage resolvers keyed by (directory, package name)
+    """
+    by_name: Dict[str, List['GoPackage']] = defaultdict(list)
+    for package in packages.values():
+        by_name[package.name].append(package)
+    symbols = {id(package): _symbols(package) for package in packages.values()}
+    
+    def resolve(package: 'GoPackage', target: str) -> Optional[Tuple[CodeChunk, 'GoPackage']]:
+        """Chunk a doc target names, seen from a package: Name, Type.Method, pkg.Name, pkg.Type.Method"""
+        parts = target.split('.')
+        found = _lookup(package, symbols[id(package)], parts)
+        if found or len(parts) == 1:
+            return found
+        candidates = [other for other in by_name.get(parts[0], []) if other is not package]
+        matches = [_lookup(other, symbols[id(other)], parts[1:]) for other in candidates]
+        matches = [match for match in matches if match]
+        return matches[0] if len(matches) == 1 else None
+    
+    for package in packages.values():
+        for chunk in package.chunks:
+            if not chunk.doc:
+                continue
+            own_id = chunk.symbol_id or chunk.default_symbol_id()
+            refs: Dict[str, Tuple[Dict, Optional[CodeChunk]]] = {}
+            for target, form in doc_targets(chunk.doc):
+                if form == 'mention' and target == chunk.name:
+                    continue  # Go docs open with the name they document
+                resolved = resolve(package, target)
+                if resolved is None:
+                    if form == 'link' and target not in refs:
+                        refs[target] = ({'name': target, 'resolved': False, 'form': form}, None)
+                    continue
+                
+                referred, owner = resolved
+                ref = dict(symbol_ref(referred, owner), form=form)
+                if ref['symbol_id'] != own_id and (ref['symbol_id'] not in refs or form == 'link'):
+                    refs[ref['symbol_id']] = (ref, referred)
+            if not refs:
+                continue
+            
+            chunk.metadata = dict(chunk.metadata or {}, doc_refs=[ref for ref, _ in refs.values()])
+            referrer = symbol_ref(chunk, package)
+            for _, referred in refs.values():
+                if referred is None:
+                    continue
+                referred.metadata = referred.metadata if referred.metadata is not None else {}
+                referenced_by = referred.metadata.setdefault('doc_referenced_by', [])
+                if all(entry['symbol_id'] != referrer['symbol_id'] for entry in referenced_by):
+                    referenced_by.append(referrer)
+
+
+def doc_targets(doc: str) -> List[Tuple[str, str]]:
+    """
+    (target, form) per symbol name a doc comment writes, in order: doc links first
+    ('link'), then exported or backquoted names of the remaining text ('mention')
+    """
+    targets = []
+    for match in DOC_LINK.finditer(doc):
+        path = match.group(1).rstrip('.')
+        # An import path qualifies by its last element: [encoding/json.Marshal] -> json.Marshal
+        targets.append((path.rsplit('/', 1)[-1], 'link'))
+    text = DOC_LINK.sub(' ', doc)
+    
+    for match in BACKQUOTED.finditer(text):
+        for name in MENTION.findall(match.group(1)):
+            targets.append((name, 'mention'))
+    for name in MENTION.findall(BACKQUOTED.sub(' ', text)):
+        if name.rsplit('.', 1)[-1][:1].isupper():
+            targets.append((name, 'mention'))
+    return targets
+
+
+def _symbols(package: 'GoPackage') -> Dict[str, CodeChunk]:
+    """Chunks of a package's declarations by name (the first part of a split symbol)"""
+    symbols = {}
+    for chunk in package.chunks:
+        if chunk.type in DOC_TARGET_KINDS and chunk.part_index == 0:
+            symbols.setdefault(chunk.name, chunk)
+    return symbols
+
+
+def _lookup(package: 'GoPackage', symbols: Dict[str, CodeChunk],
+            parts: List[str]) -> Optional[Tuple[CodeChunk, 'GoPackage']]:
+    """Name or Type.Method within one package"""
+    if len(parts) == 1:
+        chunk = symbols.get(parts[0])
+    elif len(parts) == 2 and parts[0] in symbols:
+        chunk = package.method_chunk(package.resolve_alias(parts[0]), parts[1])
+    else:
+        chunk = None
+    return (chunk, package) if chunk else None
+
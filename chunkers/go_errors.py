@@ -0,0 +1,162 @@
+#!/usr/bin/env python3
+"""
+Error-handling patterns of Go functions
+Records whether a function or method returns an error, and how its body
+makes, wraps, returns and checks errors: errors.New and fmt.Errorf (a wrap
+when the format has %w), errors.Join, github.com/pkg/errors and
+golang.org/x/xerrors wrapping, composite literals of error types (&NotFoundError{}),
+sentinel errors in return statements (ErrNotFound, io.EOF) and errors.Is/As
+checks. Calls are resolved through the file's imports, so an aliased errors
+package counts and a local variable named errors does not. Error types and
+sentinels are recognized by the Go naming conventions (...Error, Err...).
+"""
+
+import re
+from typing import Dict, List, Optional
+
+from .base_chunker import CodeChunk
+from .go_imports import GoImport, local_names
+
+
+# Kinds of error-handling record, in the order they are described
+ERROR_PATTERNS = (
+    'new',       # makes an error: errors.New, fmt.Errorf without %w
+    'wrap',      # wraps an error: fmt.Errorf with %w, errors.Wrap, errors.WithMessage, ...
+    'join',      # joins errors: errors.Join
+    'type',      # builds a value of an error type: &NotFoundError{...}
+    'sentinel',  # returns a sentinel error: return nil, ErrNotFound
+    'check',     # inspects an error: errors.Is, errors.As
+)
+
+# Error functions by import path, with the kind of record each call makes
+# (fmt.Errorf and xerrors.Errorf are wraps when their format has %w)
+ERROR_FUNCTIONS = {
+    'errors': {'New': 'new', 'Join': 'join', 'Is': 'check', 'As': 'check'},
+    'fmt': {'Errorf': 'new'},
+    'github.com/pkg/errors': {
+        'New': 'new', 'Errorf': 'new', 'Wrap': 'wrap', 'Wrapf': 'wrap', 'WithMessage': 'wrap',
+        'WithMessagef': 'wrap', 'WithStack': 'wrap', 'Is': 'check', 'As': 'check',
+    },
+    'golang.org/x/xerrors': {'New': 'new', 'Errorf': 'new', 'Is': 'check', 'As': 'check'},
+}
+
+# Formatting functions whose %w verb wraps its operand
+WRAPPING_FORMATS = {('fmt', 'Errorf'), ('golang.org/x/xerrors', 'Errorf')}
+
+# Names of error types (PathError, parseErr) and of sentinel errors (ErrNotFound, errClosed, EOF)
+ERROR_TYPE_PATTERN = re.compile(r'^\w*(?:Error|Err)$')
+SENTINEL_PATTERN = re.compile(r'^(?:[Ee]rr[A-Z0-9_]\w*|EOF)$')
+
+# Tokens after which Name{ is a composite literal rather than a block (if x == y {)
+LITERAL_CONTEXT = {'&', 'return', '(', ',', '=', ':=', '{'}
+
+
+def returns_error(chunk: CodeChunk) -> bool:
+    """True if one of the function's results is an error"""
+    return any(result.get('type') == 'error' for result in (chunk.metadata or {}).get('results', []))
+
+
+def error_handling(tokens, chunk: CodeChunk, imports: List[GoImport]) -> List[Dict]:
+    """
+    How a function's code makes, wraps, returns and checks errors
+    
+    Args:
+        tokens: GoTokens of the function's code
+        chunk: The function's chunk (for its parameter names and first line)
+        imports: Import specs of its file
+    
+    Returns:
+        Records in source order, each with its 'kind' (see ERROR_PATTERNS) and
+        1-based 'line': {'kind', 'call', 'line'} for calls, with the 'format' or
+        message of a literal first argument and (checks) the 'target' compared
+        against; {'kind': 'type', 'type', 'line'}; {'kind': 'sentinel', 'name', 'line'}
+    """
+    shadowed = local_names(tokens, chunk)
+    packages = {imp.name: imp.path for imp in imports
+                if imp.path in ERROR_FUNCTIONS and imp.name not in shadowed}
+    offset = chunk.line_start - 1
+    records = []
+    in_return = False
+    for i, token in enumerate(tokens):
+        if token.kind == 'keyword' and token.value == 'return':
+            in_return = True
+        elif token.kind == ';':
+            in_return = False
+        # The member of a selector was taken with its package (pkg.Name) or is a field
+        if token.kind != 'ident' or (i > 0 and tokens[i - 1].value == '.'):
+            continue
+        line = token.line + offset
+        call = _error_call(tokens, i, packages)
+        if call is not None:
+            records.append(dict(call, line=line))
+            continue
+        name = _qualified(tokens, i)
+        end = i + (2 if '.' in name else 0)
+        simple = name.split('.')[-1]
+        start = i - 1
+        if end + 1 < len(tokens) and tokens[end + 1].value == '{' and ERROR_TYPE_PATTERN.match(simple) \
+                and (start < 0 or tokens[start].value in LITERAL_CONTEXT):
+            records.append({'kind': 'type', 'type': name, 'line': line})
+        elif in_return and SENTINEL_PATTERN.match(simple) and (end + 1 >= len(tokens) or tokens[end + 1].value != '('):
+            records.append({'kind': 'sentinel', 'name': name, 'line': line})
+    return records
+
+
+def uses_pattern(records: List[Dict], pattern_matches) -> bool:
+    """True if a record's kind, call, type or sentinel name satisfies pattern_matches"""
+    return any(isinstance(record, dict) and any(
+        pattern_matches(record[key]) for key in ('kind', 'call', 'type', 'name') if isinstance(record.get(key), str))
+        for record in records or [])
+
+
+def _qualified(tokens, i: int) -> str:
+    """tokens[i] as a name, qualified by what it selects from: 'ErrNotFound', 'io.EOF', 'fs.PathError'"""
+    if i + 2 < len(tokens) and tokens[i + 1].value == '.' and tokens[i + 2].kind == 'ident':
+        return f"{tokens[i].value}.{tokens[i + 2].value}"
+    return tokens[i].value
+
+
+def _error_call(tokens, i: int, packages: Dict[str, str]) -> Optional[Dict]:
+    """The record of an error function call starting at tokens[i] (errors.New(...)), or None"""
+    if tokens[i].value not in packages or i + 3 >= len(tokens) or tokens[i + 1].value != '.' \
+            or tokens[i + 3].value != '(':
+        return None
+    path, function = packages[tokens[i].value], tokens[i + 2].value
+    kind = ERROR_FUNCTIONS[path].get(function)
+    if kind is None:
+        return None
+    record = {'kind': kind, 'call': f"{tokens[i].value}.{function}"}
+    args = _arguments(tokens, i + 3)
+    # errors.Wrap(err, "message") and its kin take the error first
+    text = args[1 if kind == 'wrap' else 0] if len(args) > (1 if kind == 'wrap' else 0) else []
+    if len(text) == 1 and text[0].kind == 'string':
+        key = 'format' if function.endswith('f') else 'message'
+        record[key] = text[0].value[1:-1]
+        if (path, function) in WRAPPING_FORMATS and '%w' in record[key]:
+            record['kind'] = 'wrap'
+    if kind == 'check' and len(args) > 1:
+        target = ''.join(token.value for token in args[1])
+        if target:
+            record['target'] = target
+    return record
+
+
+def _arguments(tokens, open_index: int) -> List[List]:
+    """The top-level arguments of the call whose '(' is tokens[open_index], as token lists"""
+    args, current, depth = [], [], 0
+    for token in tokens[open_index + 1:]:
+        if token.value in ('(', '[', '{'):
+            depth += 1
+        elif token.value in (')', ']', '}'):
+            if depth == 0:
+                break
+            depth -= 1
+        elif token.value == ',' and depth == 0:
+            args.append(current)
+            current = []
+            continue
+        if token.kind != ';':
+            current.append(token)
+    if current:
+        args.append(current)
+    return args
@@ -0,0 +1,164 @@
+#!/usr/bin/env python3
+"""
+Import resolution for Go chunks
+Finds the imported packages each declaration of a file actually refers to
+(pkg.Name selectors), and classifies every package as standard library,
+internal (under the module path of the nearest go.mod) or third-party. Blank and dot imports are not tracked:
+nothing in the code names them. Neither is cgo's import "C": its C names
+(C.free, C.int) are listed apart, and the C preamble above it stays a comment.
+"""
+
+import re
+from dataclasses import dataclass
+from functools import lru_cache
+from pathlib import Path
+from typing import Dict, List, Optional, Set
+
+from .base_chunker import CodeChunk
+
+
+# How an imported package relates to the code importing it
+IMPORT_CLASSES = ('stdlib', 'internal', 'third_party')
+
+# cgo's pseudo-package: the C code of the preamble, not a dependency
+CGO_PACKAGE = 'C'
+
+# The module directive of a go.mod file
+MODULE_PATTERN = re.compile(r'^\s*module\s+"?([^\s"]+)"?', re.MULTILINE)
+
+# gopkg.in/yaml.v3 -> yaml, github.com/mattn/go-sqlite3 -> sqlite3
+VERSION_SUFFIX = re.compile(r'\.v\d+$')
+MAJOR_VERSION = re.compile(r'^v\d+$')
+
+
+@dataclass
+class GoImport:
+    """One import spec of a file"""
+    path: str
+    name: str  # what the file calls the package: the alias, or the default package name
+    line: int
+    alias: Optional[str] = None  # explicit name in the spec ('_' and '.' included)
+
+
+def default_package_name(path: str) -> str:
+    """
+    The package name an import path is referred to by without an alias,
+    by the usual conventions (the name in the package clause may differ)
+    """
+    parts = path.split('/')
+    last = parts[-1]
+    if MAJOR_VERSION.match(last) and len(parts) > 1:
+        last = parts[-2]
+    last = VERSION_SUFFIX.sub('', last)
+    if last.startswith('go-'):
+        last = last[3:]
+    if last.endswith('-go'):
+        last = last[:-3]
+    return re.sub(r'\W', '_', last)
+
+
+def classify_import(path: str, module_path: Optional[str] = None) -> str:
+    """'internal' under the module path, 'stdlib' for paths without a domain, otherwise 'third_party'"""
+    if module_path and (path == module_path or path.startswith(module_path + '/')):
+        return 'internal'
+    if '.' not in path.split('/')[0]:
+        return 'stdlib'
+    return 'third_party'
+
+
+@lru_cache(maxsize=1024)
+def find_module_path(directory: str) -> Optional[str]:
+    """Module path declared in the go.mod of directory or its nearest ancestor, if any"""
+    path = Path(directory).resolve()
+    for candidate in [path] + list(path.parents):
+        go_mod = candidate / 'go.mod'
+        if go_mod.is_file():
+            try:
+                match = MODULE_PATTERN.search(go_mod.read_text(encoding='utf-8', errors='ignore'))
+            except OSError:
+                return None
+            return match.group(1) if match else None
+    return None
+
+
+def local_names(tokens, chunk: CodeChunk) -> Set[str]:
+    """
+    Names a declaration binds that can shadow a package: parameters, results,
+    the receiver, var/const names and the left-hand side of :=
+    """
+    metadata = chunk.metadata or {}
+    names = {entry['name'] for key in ('params', 'results') for entry in metadata.get(key, []) if entry.get('name')}
+    if metadata.get('receiver'):
+        names.add(metadata['receiver'])
+    for i, token in enumerate(tokens):
+        if token.value == ':=':
+            j = i - 1
+            while j >= 0 and (tokens[j].kind == 'ident' or tokens[j].value == ','):
+                if tokens[j].kind == 'ident':
+                    names.add(tokens[j].value)
+                j -= 1
+        elif token.value in ('var', 'const') and i + 1 < len(tokens) and tokens[i + 1].kind == 'ident':
+            names.add(tokens[i + 1].value)
+    return names
+
+
+def referenced_imports(tokens, chunk: CodeChunk, imports: List[GoImport],
+                       module_path: Optional[str] = None) -> List[Dict]:
+    """
+    The imports a declaration refers to, as stored in its 'imports' metadata
+    
+    Args:
+        tokens: GoTokens of the declaration's code
+        chunk: The declaration's chunk (for its parameter names)
+        imports: Import specs of its file
+        module_path: Module path of the file, to tell internal packages apart
+    
+    Returns:
+        [{'path', 'name', 'class', 'symbols'}] in import order, where symbols
+        are the package members used (RWMutex for sync.RWMutex)
+    """
+    by_name = {imp.name: imp for imp in imports if imp.name not in ('_', '.')}
+    if not by_name:
+        return []
+    shadowed = local_names(tokens, chunk)
+    
+    used: Dict[str, Set[str]] = {}
+    for i in range(len(tokens) - 2):
+        token = tokens[i]
+        if (token.kind == 'ident' and token.value in by_name and token.value not in shadowed
+                and tokens[i + 1].value == '.' and tokens[i + 2].kind == 'ident'
+                and (i == 0 or tokens[i - 1].value != '.')):
+            used.setdefault(token.value, set()).add(tokens[i + 2].value)
+    
+    return [
+        {'path': imp.path, 'name': imp.name, 'class': classify_import(imp.path, module_path),
+         'symbols': sorted(used[imp.name])}
+        for imp in imports if imp.name in used
+    ]
+
+
+def cgo_references(tokens, chunk: CodeChunk) -> List[str]:
+    """C names a declaration of a cgo file uses (free for C.free), sorted"""
+    shadowed = local_names(tokens, chunk)
+    if CGO_PACKAGE in shadowed:
+        return []
+    return sorted({
+        tokens[i + 2].value for i in range(len(tokens) - 2)
+        if tokens[i].value == CGO_PACKAGE and tokens[i].kind == 'ident' and tokens[i + 1].value == '.'
+        and tokens[i + 2].kind == 'ident' and (i == 0 or tokens[i - 1].value != '.')
+    })
+
+
+def uses_dependency(imports: List[Dict], dependency: str) -> bool:
+    """
+    True if 'imports' metadata includes a dependency given as a package path
+    or name (sync, github.com/pkg/errors) or a member of one (sync.RWMutex,
+    errors.Wrap, github.com/pkg/errors.Wrap)
+    """
+    for entry in imports:
+        if dependency in (entry.get('path'), entry.get('name')):
+            return True
+        for prefix in (entry.get('path'), entry.get('name')):
+            if prefix and dependency.startswith(prefix + '.') and dependency[len(prefix) + 1:] in entry.get('symbols', []):
+                return True
+    return False
@@ -0,0 +1,138 @@
+#!/usr/bin/env python3
+"""
+Generic instantiations in Go chunks
+Finds the explicit instantiations of indexed generic types and functions
+(SessionManager[User]{...}, NewSessionManager[User](), var c *cache.LRU[string, int])
+and records their type arguments on the chunk using them and on the generic
+declaration. Best-effort: only type arguments written out are seen, not
+inferred ones (Map(users, name)), and instantiations with the enclosing
+declaration's own type parameters (the methods of SessionManager[T]) are not
+concrete, so they are left out.
+"""
+
+from collections import defaultdict
+from typing import TYPE_CHECKING, Dict, List, Optional, Tuple
+
+from .base_chunker import CodeChunk
+from .go_call_graph import symbol_ref
+from .go_lexer import match_bracket, normalize_type, split_top_level, tokenize_go
+
+if TYPE_CHECKING:
+    from .go_package_linker import GoPackage
+
+
+# Keywords and operators a type argument can be written with ([]*pkg.T, map[K]V, func(int) error)
+TYPE_KEYWORDS = {'map', 'chan', 'func', 'struct', 'interface'}
+TYPE_OPERATORS = {'.', '*', '[', ']', '(', ')', '{', '}', ',', ';', '<-', '~', '...'}
+
+
+def link_instantiations(packages: Dict[Tuple[str, str], 'GoPackage']) -> None:
+    """
+    Record 'instantiations' on Go chunks that instantiate an indexed generic, and
+    'instantiated_by' on the generic type or function
+    
+    Each instantiation is {'instance': 'SessionManager[User]', 'type_args': ['User'],
+    'line', 'generic': reference to the generic}; each 'instantiated_by' entry is a
+    reference to the chunk using it, with its 'instance', 'type_args' and 'line'.
+    Both are listed once per distinct instance, in order of appearance.
+    
+    Args:
+        packages: GoPackage resolvers keyed by (directory, package name)
+    """
+    by_name: Dict[str, List['GoPackage']] = defaultdict(list)
+    for package in packages.values():
+        by_name[package.name].append(package)
+    
+    for package in packages.values():
+        for chunk in package.chunks:
+            found = InstantiationFinder(chunk, package, by_name).instantiations()
+            if not found:
+                continue
+            records = []
+            for instance, type_args, line, generic, generic_package in found:
+                records.append({'instance': instance, 'type_args': type_args, 'line': line,
+                                'generic': symbol_ref(generic, generic_package)})
+                site = dict(symbol_ref(chunk, package), instance=instance, type_args=type_args, line=line)
+                generic.metadata = generic.metadata if generic.metadata is not None else {}
+                generic.metadata.setdefault('instantiated_by', []).append(site)
+            chunk.metadata = dict(chunk.metadata or {}, instantiations=records)
+
+
+class InstantiationFinder:
+    """Finds the explicit instantiations of indexed generics in one chunk"""
+    
+    def __init__(self, chunk: CodeChunk, package: 'GoPackage', packages_by_name: Dict[str, List['GoPackage']]):
+        self.chunk = chunk
+        self.package = package
+        self.packages_by_name = packages_by_name
+        self.tokens, _ = tokenize_go(chunk.content)
+        
+        # Type parameters in scope: the declaration's own, or its receiver type's
+        metadata = chunk.metadata or {}
+        self.own_params = {param['name'] for param in metadata.get('type_params', [])}
+        self.own_params.update(metadata.get('receiver_type_params', []))
+    
+    def instantiations(self) -> List[Tuple[str, List[str], int, CodeChunk, 'GoPackage']]:
+        """(instance, type arguments, line, generic chunk, its package) per distinct instance"""
+        tokens = self.tokens
+        seen = set()
+        found = []
+        for i in range(len(tokens) - 1):
+            if tokens[i].kind != 'ident' or tokens[i + 1].value != '[':
+                continue
+            resolved = self._generic(i)
+            if resolved is None:
+                continue
+            qualifier, generic, package = resolved
+            type_args = self._type_args(i + 1, match_bracket(tokens, i + 1), generic)
+            if type_args is None:
+                continue
+            instance = f"{qualifier}{tokens[i].value}[{', '.join(type_args)}]"
+            if instance not in seen:
+                seen.add(instance)
+                found.append((instance, type_args, self.chunk.line_start + tokens[i].line - 1, generic, package))
+        return found
+    
+    def _generic(self, index: int) -> Optional[Tuple[str, CodeChunk, 'GoPackage']]:
+        """(qualifier, chunk, package) of the generic named at tokens[index], if it is one"""
+        tokens = self.tokens
+        name = tokens[index].value
+        qualifier = ''
+        package = self.package
+        if index >= 2 and tokens[index - 1].value == '.' and tokens[index - 2].kind == 'ident':
+            if index >= 3 and tokens[index - 3].value == '.':
+                return None  # x.y.Z[...] is a field or method, not a package member
+            candidates = self.packages_by_name.get(tokens[index - 2].value, [])
+            if len(candidates) != 1 or candidates[0] is self.package:
+                return None
+            qualifier = f"{tokens[index - 2].value}."
+            package = candidates[0]
+        elif index >= 1 and tokens[index - 1].value in ('func', 'type', '.'):
+            return None  # the generic's own declaration, or a selector on an expression
+        
+        generic = package.types.get(name) or package.functions.get(name)
+        if generic is None or not (generic.metadata or {}).get('type_params'):
+            return None
+        return qualifier, generic, package
+    
+    def _type_args(self, open_index: int, close_index: int, generic: CodeChunk) -> Optional[List[str]]:
+        """Type arguments between the brackets, or None if they are not concrete types"""
+        tokens = self.tokens
+        inner = tokens[open_index + 1:close_index]
+        args = split_top_level(inner, ',')
+        params = generic.metadata['type_params']
+        # A function's trailing type arguments may be inferred; a type's never are
+        if not args or len(args) > len(params) or (generic.type != 'function' and len(args) != len(params)):
+            return None
+        for arg in args:
+            for tok in arg:
+                if tok.kind in ('number', 'string', 'rune'):
+                    return None
+                if tok.kind == 'keyword' and tok.value not in TYPE_KEYWORDS:
+                    return None
+                if tok.kind == 'op' and tok.value not in TYPE_OPERATORS:
+                    return None
+                if tok.kind == 'ident' and tok.value in self.own_params:
+                    return None
+        content = self.chunk.content
+        return [normalize_type(content[arg[0].start:arg[-1].end]) for arg in args]
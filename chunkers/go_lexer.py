@@ -0,0 +1,177 @@
+#!/usr/bin/env python3
+"""
+Go lexer for the passes that read the code of Go chunks
+Imports, cgo names, error handling, templates, constant expressions and the
+package linker work on these tokens; declarations themselves are parsed by
+tree-sitter in go_chunker.py.
+"""
+
+import re
+from bisect import bisect_right
+from dataclasses import dataclass
+from typing import List, Tuple
+
+
+GO_KEYWORDS = {
+    'break', 'case', 'chan', 'const', 'continue', 'default', 'defer', 'else',
+    'fallthrough', 'for', 'func', 'go', 'goto', 'if', 'import', 'interface',
+    'map', 'package', 'range', 'return', 'select', 'struct', 'switch', 'type', 'var',
+}
+
+# Operators, longest first so the scanner picks the longest match
+GO_OPERATORS = [
+    '<<=', '>>=', '&^=', '...', '&&', '||', '<-', '++', '--', '==', '!=', '<=',
+    '>=', ':=', '+=', '-=', '*=', '/=', '%=', '&=', '|=', '^=', '<<', '>>', '&^',
+] + list('+-*/%&|^<>=!()[]{},;.:~')
+
+# Tokens after which a newline inserts a semicolon (Go spec, "Semicolons")
+SEMICOLON_TRIGGERS = {'break', 'continue', 'fallthrough', 'return', '++', '--', ')', ']', '}'}
+
+TOKEN_PATTERN = re.compile(
+    r'(?P<ws>[ \t\r\f]+)'
+    r'|(?P<nl>\n)'
+    r'|(?P<line_comment>//[^\n]*)'
+    r'|(?P<block_comment>/\*.*?\*/)'
+    r'|(?P<raw_string>`[^`]*`)'
+    r'|(?P<string>"(?:[^"\\\n]|\\.)*")'
+    r'|(?P<rune>\'(?:[^\'\\\n]|\\.)+\')'
+    r'|(?P<number>(?:0[bB][01_]+|0[oO][0-7_]+|0[xX][0-9a-fA-F_]*(?:\.[0-9a-fA-F_]*)?(?:[pP][+-]?\d+)?'
+    r'|\d[\d_]*(?:\.[\d_]*)?(?:[eE][+-]?\d+)?|\.\d[\d_]*(?:[eE][+-]?\d+)?)i?)'
+    r'|(?P<ident>[^\W\d]\w*)'
+    r'|(?P<op>' + '|'.join(re.escape(op) for op in GO_OPERATORS) + r')',
+    re.DOTALL
+)
+
+
+@dataclass
+class GoToken:
+    """A single Go token with its source span"""
+    kind: str  # ident, keyword, number, string, rune, op, ;
+    value: str
+    start: int
+    end: int
+    line: int
+
+
+@dataclass
+class GoComment:
+    """A comment with its line span"""
+    text: str
+    start: int
+    end: int
+    line_start: int
+    line_end: int
+
+
+def tokenize_go(code: str) -> Tuple[List[GoToken], List[GoComment]]:
+    """
+    Tokenize Go source, applying automatic semicolon insertion
+    
+    Returns:
+        Tuple of (tokens, comments); comments are kept out of the token stream
+    """
+    line_starts = [0] + [m.end() for m in re.finditer('\n', code)]
+    
+    def line_of(offset: int) -> int:
+        return bisect_right(line_starts, offset)
+    
+    tokens: List[GoToken] = []
+    comments: List[GoComment] = []
+    
+    def newline(offset: int):
+        # Insert a semicolon if the previous token allows a statement to end here
+        if tokens and tokens[-1].kind != ';':
+            last = tokens[-1]
+            if last.kind in ('ident', 'number', 'string', 'rune') or last.value in SEMICOLON_TRIGGERS:
+                tokens.append(GoToken(';', '\n', offset, offset, line_of(offset)))
+    
+    pos = 0
+    length = len(code)
+    while pos < length:
+        match = TOKEN_PATTERN.match(code, pos)
+        if not match:
+            # Unknown character (stray byte, unterminated literal): skip it
+            pos += 1
+            continue
+        
+        kind = match.lastgroup
+        value = match.group(0)
+        start, end = match.span()
+        pos = end
+        
+        if kind == 'ws':
+            continue
+        if kind == 'nl':
+            newline(start)
+            continue
+        if kind in ('line_comment', 'block_comment'):
+            comments.append(GoComment(value, start, end, line_of(start), line_of(max(start, end - 1))))
+            if kind == 'block_comment' and '\n' in value:
+                newline(start)
+            continue
+        
+        if kind == 'raw_string':
+            kind = 'string'
+        elif kind == 'ident' and value in GO_KEYWORDS:
+            kind = 'keyword'
+        elif kind == 'op' and value == ';':
+            kind = ';'
+        
+        tokens.append(GoToken(kind, value, start, end, line_of(start)))
+    
+    newline(length)
+    return tokens, comments
+
+
+def split_top_level(tokens: List[GoToken], separator: str = ';') -> List[List[GoToken]]:
+    """Split a token list on a separator that appears outside any brackets"""
+    parts: List[List[GoToken]] = []
+    current: List[GoToken] = []
+    depth = 0
+    
+    for tok in tokens:
+        if tok.value in ('(', '[', '{'):
+            depth += 1
+        elif tok.value in (')', ']', '}'):
+            depth = max(0, depth - 1)
+        
+        if depth == 0 and (tok.kind == separator or tok.value == separator):
+            if current:
+                parts.append(current)
+            current = []
+        else:
+            current.append(tok)
+    
+    if current:
+        parts.append(current)
+    return parts
+
+
+def match_bracket(tokens: List[GoToken], index: int) -> int:
+    """Return the index of the bracket closing tokens[index] (or the last index if unbalanced)"""
+    pairs = {'(': ')', '[': ']', '{': '}'}
+    opening = tokens[index].value
+    closing = pairs[opening]
+    depth = 0
+    
+    for i in range(index, len(tokens)):
+        if tokens[i].value == opening:
+            depth += 1
+        elif tokens[i].value == closing:
+            depth -= 1
+            if depth == 0:
+                return i
+    return len(tokens) - 1
+
+
+def normalize_type(text: str) -> str:
+    """Canonical spacing for a Go type expression so signatures can be compared"""
+    text = re.sub(r'\s+', ' ', text.strip())
+    text = re.sub(r'\s*([\[\](){}*.,;])\s*', r'\1', text)
+    return text.replace(',', ', ').replace(';', '; ')
+
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    return re.sub(r'\s+', ' ', signature).strip()
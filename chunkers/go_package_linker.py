@@ -0,0 +1,441 @@
+#!/usr/bin/env python3
+"""
+Cross-file analysis for Go chunks
+Runs after every file of a package has been chunked and records relationships
+that no single file can answer (embedding, method sets, interface satisfaction)
+"""
+
+import os
+from collections import defaultdict
+from typing import Dict, List, Optional, Tuple
+
+from .base_chunker import CodeChunk
+from .go_call_graph import MAX_ALIAS_DEPTH, link_go_calls, symbol_ref
+from .go_doc_links import link_doc_refs
+from .go_instantiations import link_instantiations
+from .go_package_state import link_package_state
+from .go_package_summary import summarize_packages
+from .go_stringer import link_string_methods
+from .go_lexer import match_bracket, tokenize_go
+
+
+# Declaration kinds that name a type
+GO_TYPE_KINDS = ('struct', 'interface', 'type')
+
+# Bound on embedding depth when resolving method sets (also guards cycles)
+MAX_EMBED_DEPTH = 8
+
+
+def link_go_packages(chunks: List[CodeChunk]) -> List[CodeChunk]:
+    """
+    Link Go chunks across files
+    
+    Chunks are grouped into packages by directory and package name. Each
+    concrete type gets 'implements' (and 'implements_pointer_only' for
+    interfaces only satisfied by *T); each interface gets 'implemented_by'
+    and its 'method_set', embedded interfaces flattened in.
+    Structs also get the fields and methods promoted from embedded types,
+    functions and methods get their 'calls' and 'called_by' edges, and
+    constants are linked to their type's String method and the text it returns for
+    them (a switch, a table of strings or the output of stringer).
+    Aliases get 'alias_of', the type they name, which gets them as 'aliases'.
+    Methods get their receiver type as 'owner', which lists them as 'methods'.
+    Explicit instantiations of generics (SessionManager[User]) are recorded with
+    their type arguments as 'instantiations', and on the generic as 'instantiated_by'.
+    Package-level vars get the functions that set them as 'assigned_in' (which list
+    them as 'assigns'), and the type of the call they are initialized with.
+    Documented chunks get the symbols their doc links to or mentions as 'doc_refs',
+    which list those chunks as 'doc_referenced_by'.
+    Package clause chunks (GoChunker's package_clauses) become one summary chunk
+    per package, listing its exported API.
+    
+    Args:
+        chunks: Go chunks from any number of files
+    
+    Returns:
+        The same chunks, with relationship metadata filled in, less the package
+        clauses not kept as summaries
+    """
+    packages: Dict[Tuple[str, str], List[CodeChunk]] = defaultdict(list)
+    for chunk in chunks:
+        packages[(os.path.dirname(chunk.filepath), chunk.namespace or '')].append(chunk)
+    
+    resolvers = {key: GoPackage(key[1], members) for key, members in packages.items()}
+    packages_by_name: Dict[str, List[GoPackage]] = defaultdict(list)
+    for package in resolvers.values():
+        packages_by_name[package.name].append(package)
+    for package in resolvers.values():
+        link_aliases(package, packages_by_name)
+    
+    # Interfaces can be satisfied by types of any indexed package
+    interfaces = []
+    for package in resolvers.values():
+        for iface in package.interfaces.values():
+            interfaces.append((package, iface))
+    
+    for package in resolvers.values():
+        for type_name, type_chunk in package.types.items():
+            if type_chunk.type == 'interface':
+                continue
+            
+            value_set = package.method_set(type_name, pointer=False)
+            pointer_set = package.method_set(type_name, pointer=True)
+            implements = []
+            pointer_only = []
+            
+            for iface_package, iface in interfaces:
+                required = iface_package.interface_methods(iface.name)
+                if not required:
+                    continue  # every type satisfies an empty interface
+                if iface.metadata.get('type_terms'):
+                    continue  # constraint interfaces are not ordinary interface types
+                
+                label = iface.name if iface_package is package else f"{iface_package.name}.{iface.name}"
+                if _satisfies(value_set, required):
+                    implements.append(label)
+                elif _satisfies(pointer_set, required):
+                    implements.append(label)
+                    pointer_only.append(label)
+                else:
+                    continue
+                
+                implementer = type_name if iface_package is package else f"{package.name}.{type_name}"
+                iface.metadata.setdefault('implemented_by', []).append(implementer)
+            
+            metadata = type_chunk.metadata if type_chunk.metadata is not None else {}
+            if type_chunk.type == 'struct':
+                promoted_methods, promoted_fields, ambiguous = package.promotions(type_name)
+                metadata['promoted_fields'] = [
+                    {k: f[k] for k in ('name', 'type', 'from', 'via')} for f in promoted_fields
+                ]
+                metadata['promoted_methods'] = [
+                    {k: m[k] for k in ('name', 'signature_key', 'pointer_receiver', 'from', 'via')}
+                    for m in promoted_methods
+                ]
+                metadata['ambiguous_promotions'] = ambiguous
+            metadata['implements'] = sorted(implements)
+            metadata['implements_pointer_only'] = sorted(pointer_only)
+            type_chunk.metadata = metadata
+    
+    for _, iface in interfaces:
+        if 'implemented_by' in iface.metadata:
+            iface.metadata['implemented_by'] = sorted(set(iface.metadata['implemented_by']))
+    
+    for package, iface in interfaces:
+        method_set, unresolved = package.interface_method_set(iface.name, packages_by_name)
+        iface.metadata['method_set'] = method_set
+        if unresolved:
+            iface.metadata['unresolved_embeds'] = unresolved
+    
+    link_go_calls(resolvers)
+    link_instantiations(resolvers)
+    link_package_state(resolvers)
+    link_doc_refs(resolvers)
+    for package in resolvers.values():
+        link_receivers(package)
+        link_string_methods(package)
+    
+    dropped = {id(chunk) for chunk in summarize_packages(resolvers)}
+    return [chunk for chunk in chunks if id(chunk) not in dropped]
+
+
+def link_aliases(package: 'GoPackage', packages_by_name: Dict[str, List['GoPackage']]):
+    """
+    Point each alias at the type it names
+    
+    'alias_of' is a reference to the defined type at the end of the alias chain,
+    found in this package or, for pkg.T, in the one indexed package named pkg;
+    otherwise it only carries the aliased 'name' with 'resolved' False. A resolved
+    alias gets the target's 'underlying', and the target lists the alias in 'aliases'
+    (package-qualified when declared in another package).
+    """
+    for alias in package.aliases.values():
+        metadata = alias.metadata
+        target_package, target = package, package.resolve_alias(alias.name)
+        if target not in package.types:
+            # The chain leaves the package at its last alias: type A = pkg.T
+            last = package.aliases.get(target)
+            target_package, target = None, None
+            qualifier, _, name = ((last.metadata.get('aliased') if last else '') or '').partition('.')
+            if name.isidentifier():
+                candidates = [p for p in packages_by_name.get(qualifier, []) if p.resolve_alias(name) in p.types]
+                if len(candidates) == 1:
+                    target_package = candidates[0]
+                    target = target_package.resolve_alias(name)
+        if target is None:
+            metadata['alias_of'] = {'name': metadata.get('aliased'), 'resolved': False}
+            continue
+        
+        target_chunk = target_package.types[target]
+        ref = symbol_ref(target_chunk, target_package)
+        if target_package is not package:
+            ref['name'] = f"{target_package.name}.{ref['name']}"
+        metadata['alias_of'] = ref
+        target_metadata = target_chunk.metadata if target_chunk.metadata is not None else {}
+        underlying = target_metadata.get('underlying') if target_chunk.type == 'type' else target_chunk.type
+        if underlying:
+            metadata['underlying'] = underlying
+        label = alias.name if target_package is package else f"{package.name}.{alias.name}"
+        target_metadata.setdefault('aliases', []).append(label)
+        target_chunk.metadata = target_metadata
+
+
+def link_receivers(package: 'GoPackage'):
+    """
+    Link methods and the types they are declared on, both ways
+    
+    Each method gets an 'owner' reference to its receiver type (only the 'name',
+    with 'resolved' False, when the type is not indexed); each type gets its
+    declared 'methods', in source order, each marked with 'pointer_receiver'
+    (a method of *T rather than T).
+    """
+    methods: Dict[str, List[CodeChunk]] = defaultdict(list)
+    for (type_name, _), method in package.method_chunks.items():
+        methods[type_name].append(method)
+    
+    for type_name, declared in methods.items():
+        owner = package.types.get(type_name)
+        if owner is None:
+            for method in declared:
+                method.metadata = dict(method.metadata or {}, owner={'name': type_name, 'resolved': False})
+            continue
+        
+        refs = []
+        for method in sorted(declared, key=lambda m: (m.filepath, m.line_start)):
+            metadata = method.metadata or {}
+            method.metadata = dict(metadata, owner=symbol_ref(owner, package))
+            refs.append(dict(symbol_ref(method, package), pointer_receiver=bool(metadata.get('pointer_receiver'))))
+        owner.metadata = dict(owner.metadata or {}, methods=refs)
+
+
+def _satisfies(method_set: Dict[str, str], required: Dict[str, str]) -> bool:
+    """True if every required method is present with an identical signature"""
+    return all(method_set.get(name) == key for name, key in required.items())
+
+
+class GoPackage:
+    """The types and methods of one Go package, gathered across its files"""
+    
+    def __init__(self, name: str, chunks: List[CodeChunk]):
+        self.name = name
+        self.chunks = chunks
+        self.types: Dict[str, CodeChunk] = {}
+        self.interfaces: Dict[str, CodeChunk] = {}
+        self.functions: Dict[str, CodeChunk] = {}
+        self.aliases: Dict[str, CodeChunk] = {}
+        # receiver base type -> list of (method name, signature key, pointer receiver)
+        self.methods: Dict[str, List[Tuple[str, str, bool]]] = defaultdict(list)
+        self.method_chunks: Dict[Tuple[str, str], CodeChunk] = {}
+        self.constants: Dict[str, CodeChunk] = {}
+        
+        for chunk in chunks:
+            if chunk.type == 'alias':
+                chunk.metadata = chunk.metadata or {}
+                self.aliases[chunk.name] = chunk
+        
+        for chunk in chunks:
+            metadata = chunk.metadata or {}
+            if chunk.type in GO_TYPE_KINDS:
+                self.types[chunk.name] = chunk
+                if chunk.type == 'interface':
+                    chunk.metadata = metadata
+                    self.interfaces[chunk.name] = chunk
+            elif chunk.type == 'method' and chunk.parent_class:
+                receiver = self.resolve_alias(chunk.parent_class)
+                if receiver != chunk.parent_class:
+                    # A method declared on an alias is a method of the type it names
+                    chunk.metadata = dict(metadata, receiver_alias=chunk.parent_class)
+                    chunk.parent_class = chunk.parent = receiver
+                self.methods[chunk.parent_class].append(
+                    (chunk.name, metadata.get('signature_key', ''), bool(metadata.get('pointer_receiver')))
+                )
+                self.method_chunks[(chunk.parent_class, chunk.name)] = chunk
+            elif chunk.type == 'function' and chunk.name != 'init':
+                self.functions[chunk.name] = chunk
+            elif chunk.type == 'const':
+                chunk.metadata = metadata
+                self.constants[chunk.name] = chunk
+    
+    def resolve_alias(self, type_name: str) -> str:
+        """The type an alias of this package names, following alias chains (other names unchanged)"""
+        for _ in range(MAX_ALIAS_DEPTH):
+            alias = self.aliases.get(type_name)
+            target = (alias.metadata.get('aliased') or '') if alias else ''
+            if not target.isidentifier():
+                break
+            type_name = target
+        return type_name
+    
+    def method_set(self, type_name: str, pointer: bool) -> Dict[str, str]:
+        """
+        Method set of T (pointer=False) or *T (pointer=True), including methods
+        promoted through embedded fields
+        """
+        type_name = self.resolve_alias(type_name)
+        methods = {
+            name: key
+            for name, key, pointer_receiver in self.methods.get(type_name, [])
+            if pointer or not pointer_receiver
+        }
+        
+        promoted, _, _ = self.promotions(type_name)
+        for entry in promoted:
+            # A promoted *E method needs an addressable E: *T, or an embedded *E on the way
+            if pointer or not entry['pointer_receiver'] or entry['through_pointer']:
+                methods.setdefault(entry['name'], entry['signature_key'])
+        
+        return methods
+    
+    def method_chunk(self, type_name: str, method: str) -> Optional[CodeChunk]:
+        """Chunk of the method a selector x.method calls for x of type_name (declared or promoted)"""
+        type_name = self.resolve_alias(type_name)
+        if (type_name, method) in self.method_chunks:
+            return self.method_chunks[(type_name, method)]
+        promoted, _, _ = self.promotions(type_name)
+        for entry in promoted:
+            if entry['name'] == method:
+                return self.method_chunks.get((entry['from'], method))
+        return None
+    
+    def promotions(self, type_name: str) -> Tuple[List[Dict], List[Dict], List[str]]:
+        """
+        Resolve the methods and fields promoted into a struct by embedding
+        
+        Follows the selector rules of the Go spec: the shallowest depth wins,
+        declarations of the outer type shadow promoted ones, and a name found
+        twice at the same depth is ambiguous (and promoted from neither).
+        
+        Returns:
+            Tuple of (promoted methods, promoted fields, ambiguous names)
+        """
+        type_chunk = self.types.get(type_name)
+        if not type_chunk or type_chunk.type != 'struct':
+            return [], [], []
+        
+        taken = {field['name'] for field in self._fields(type_name)}
+        taken.update(name for name, _, _ in self.methods.get(type_name, []))
+        
+        methods, fields, ambiguous = [], [], set()
+        level = [(type_name, [], False)]  # (struct, embedding path, reached through a pointer)
+        visited = {type_name}
+        
+        for _ in range(MAX_EMBED_DEPTH):
+            candidates: Dict[str, List[Dict]] = defaultdict(list)
+            next_level = []
+            
+            for struct_name, path, through_pointer in level:
+                for field in self._fields(struct_name):
+                    embedded_name = self.resolve_alias(field['name'])
+                    if not field.get('embedded') or embedded_name not in self.types:
+                        continue  # embedded types from other packages are opaque
+                    
+                    via = path + [field['name']]
+                    pointer = through_pointer or field.get('pointer', False)
+                    origin = {'from': embedded_name, 'via': '.'.join(via)}
+                    
+                    for promoted_field in self._fields(embedded_name):
+                        candidates[promoted_field['name']].append(
+                            dict(origin, kind='field', name=promoted_field['name'], type=promoted_field['type'])
+                        )
+                    for name, key, pointer_receiver in self._declared_methods(embedded_name):
+                        candidates[name].append(dict(
+                            origin, kind='method', name=name, signature_key=key,
+                            pointer_receiver=pointer_receiver, through_pointer=pointer
+                        ))
+                    
+                    if embedded_name not in visited:
+                        visited.add(embedded_name)
+                        next_level.append((embedded_name, via, pointer))
+            
+            for name in sorted(candidates):
+                if name in taken:
+                    continue
+                taken.add(name)
+                entries = candidates[name]
+                if len(entries) > 1:
+                    ambiguous.add(name)
+                elif entries[0]['kind'] == 'field':
+                    fields.append(entries[0])
+                else:
+                    methods.append(entries[0])
+            
+            level = next_level
+            if not level:
+                break
+        
+        return methods, fields, sorted(ambiguous)
+    
+    def _fields(self, type_name: str) -> List[Dict]:
+        """Declared fields of a struct type (empty for other kinds)"""
+        type_chunk = self.types.get(type_name)
+        if not type_chunk or type_chunk.type != 'struct':
+            return []
+        return (type_chunk.metadata or {}).get('fields', [])
+    
+    def _declared_methods(self, type_name: str) -> List[Tuple[str, str, bool]]:
+        """Methods a type brings when embedded: its own, or an interface's required set"""
+        type_chunk = self.types.get(type_name)
+        if type_chunk and type_chunk.type == 'interface':
+            return [(name, key, False) for name, key in self.interface_methods(type_name).items()]
+        return self.methods.get(type_name, [])
+    
+    def interface_method_set(self, iface_name: str,
+                             packages_by_name: Dict[str, List['GoPackage']]) -> Tuple[List[Dict], List[str]]:
+        """
+        Every method an interface requires: its own, then those of embedded interfaces
+        
+        Embedded interfaces are followed into other indexed packages ('io.Reader'
+        when exactly one indexed package is named io). A method from an embedded
+        interface carries 'from' (the interface declaring it, package-qualified
+        when it is in another package) and 'via' (the embedding path, 'ReadCloser.Reader').
+        
+        Returns:
+            Tuple of (methods, embedded interfaces that are not indexed)
+        """
+        methods, unresolved = [], []
+        self._collect_interface(iface_name, packages_by_name, self, [], set(), methods, unresolved)
+        return methods, unresolved
+    
+    def _collect_interface(self, iface_name: str, packages_by_name: Dict[str, List['GoPackage']],
+                           root: 'GoPackage', path: List[str], visited: set,
+                           methods: List[Dict], unresolved: List[str]):
+        """Add an interface's methods, then recurse into what it embeds (depth-first, as written)"""
+        visited.add((id(self), iface_name))
+        path = path + [iface_name]
+        metadata = self.interfaces[iface_name].metadata or {}
+        taken = {m['name'] for m in methods}
+        
+        for method in metadata.get('methods', []):
+            if method['name'] in taken:
+                continue  # declared again by an embedding (identical, as Go requires)
+            entry = {k: method[k] for k in ('name', 'signature', 'signature_key')}
+            if len(path) > 1:
+                entry['from'] = iface_name if self is root else f"{self.name}.{iface_name}"
+                entry['via'] = '.'.join(path)
+            methods.append(entry)
+        
+        for embedded in metadata.get('embeds', []):
+            package, name = self, embedded.split('[', 1)[0]
+            if '.' in name:
+                qualifier, name = name.rsplit('.', 1)
+                candidates = [p for p in packages_by_name.get(qualifier, []) if name in p.interfaces]
+                package = candidates[0] if len(candidates) == 1 else None
+            if package is None or name not in package.interfaces:
+                unresolved.append(embedded)
+            elif (id(package), name) not in visited and len(path) <= MAX_EMBED_DEPTH:
+                package._collect_interface(name, packages_by_name, root, path, visited, methods, unresolved)
+    
+    def interface_methods(self, iface_name: str, depth: int = 0) -> Dict[str, str]:
+        """Required methods of an interface, flattening embedded interfaces of this package"""
+        iface = self.interfaces.get(iface_name)
+        if not iface or depth > MAX_EMBED_DEPTH:
+            return {}
+        
+        metadata = iface.metadata or {}
+        required = {m['name']: m.get('signature_key', '') for m in metadata.get('methods', [])}
+        for embedded in metadata.get('embeds', []):
+            embedded_name = embedded.lstrip('*')
+            if embedded_name in self.interfaces:
+                for name, key in self.interface_methods(embedded_name, depth + 1).items():
+                    required.setdefault(name, key)
+        return required
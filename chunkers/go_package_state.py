@@ -0,0 +1,176 @@
+#!/usr/bin/env python3
+"""
+Package-level state in Go chunks
+Links each package-level variable to the functions and methods of its package
+that set it: plain assignments (defaultManager = NewManager(store)) and writes
+through the variable (handlers[name] = h, config.Timeout = d), init functions
+included. A variable declared without a type gets the type of the call it is
+initialized with when that call is visible: an indexed function's result or a
+conversion to an indexed type. Best-effort: writes from other packages
+(session.Default = m) or through pointers are not seen, and a function that
+declares a local or parameter of the same name is taken to shadow the variable
+throughout.
+"""
+
+import re
+from collections import defaultdict
+from typing import TYPE_CHECKING, Dict, List, Optional, Set, Tuple
+
+from .base_chunker import CodeChunk
+from .go_call_graph import CALLABLE_KINDS, symbol_ref
+from .go_lexer import GoToken, match_bracket, tokenize_go
+
+if TYPE_CHECKING:
+    from .go_package_linker import GoPackage
+
+
+# Tokens after which a statement starts in a function body (besides semicolons)
+STATEMENT_STARTS = {'{', ':'}
+
+
+def link_package_state(packages: Dict[Tuple[str, str], 'GoPackage']) -> None:
+    """
+    Record 'assigned_in' on Go var chunks, 'assigns' on the functions and methods
+    setting them, and 'inferred_type' on vars initialized with a resolvable call
+    
+    'assigned_in' holds a reference to each function or method of the package that
+    sets the variable; 'assigns' lists the variable names a function sets.
+    
+    Args:
+        packages: GoPackage resolvers keyed by (directory, package name)
+    """
+    by_name: Dict[str, List['GoPackage']] = defaultdict(list)
+    for package in packages.values():
+        by_name[package.name].append(package)
+    
+    for package in packages.values():
+        variables = {chunk.name: chunk for chunk in package.chunks if chunk.type == 'var'}
+        if not variables:
+            continue
+        
+        for var in variables.values():
+            inferred = _call_type(var.metadata, package, by_name)
+            if inferred:
+                var.metadata['inferred_type'] = inferred
+        
+        for chunk in package.chunks:
+            if chunk.type not in CALLABLE_KINDS:
+                continue
+            assigned = assigned_variables(chunk, set(variables))
+            if not assigned:
+                continue
+            chunk.metadata = dict(chunk.metadata or {}, assigns=assigned)
+            for name in assigned:
+                variables[name].metadata.setdefault('assigned_in', []).append(symbol_ref(chunk, package))
+
+
+def _call_type(metadata: Dict, package: 'GoPackage',
+               by_name: Dict[str, List['GoPackage']]) -> Optional[str]:
+    """Type of a var's initializer call (NewManager(), sessions.New(), Level(x)), if indexed"""
+    callee = metadata.get('initializer')
+    if not callee:
+        return None
+    qualifier, _, name = callee.rpartition('.')
+    target = package
+    if qualifier:
+        candidates = by_name.get(qualifier, [])
+        if len(candidates) != 1:
+            return None
+        target = candidates[0]
+    
+    if target.resolve_alias(name) in target.types:
+        return callee  # a conversion
+    function = target.functions.get(name)
+    results = (function.metadata or {}).get('results', []) if function else []
+    index = metadata.get('result', 0)
+    if index >= len(results):
+        return None
+    result = results[index]['type']
+    if qualifier:
+        # *Manager of package sessions is *sessions.Manager here
+        match = re.fullmatch(r'([\[\]*]*)(\w+)(.*)', result)
+        if match and target.resolve_alias(match.group(2)) in target.types:
+            result = f"{match.group(1)}{qualifier}.{match.group(2)}{match.group(3)}"
+    return result
+
+
+def assigned_variables(chunk: CodeChunk, variables: Set[str]) -> List[str]:
+    """Names of the package variables a function or method body sets, in order of appearance"""
+    tokens = tokenize_go(chunk.content)[0]
+    body = _body_start(tokens)
+    metadata = chunk.metadata or {}
+    shadowed = {param['name'] for param in metadata.get('params', [])}
+    shadowed.add(metadata.get('receiver'))
+    shadowed.update(_local_names(tokens, body))
+    
+    assigned = []
+    for i in range(body, len(tokens)):
+        tok = tokens[i]
+        if tok.kind != 'ident' or not (tokens[i - 1].kind == ';' or tokens[i - 1].value in STATEMENT_STARTS):
+            continue
+        for name in _assignment_targets(tokens, i):
+            if name in variables and name not in shadowed and name not in assigned:
+                assigned.append(name)
+    return assigned
+
+
+def _assignment_targets(tokens: List[GoToken], i: int) -> List[str]:
+    """
+    Variables written by the statement at tokens[i], if it is an assignment
+    (a, b = ..., m[k] = ..., cfg.Timeout = ...): the name each target starts with
+    """
+    names = []
+    j = i
+    while j < len(tokens) and tokens[j].kind == 'ident':
+        names.append(tokens[j].value)
+        j += 1
+        # Selectors and index expressions: the write goes to the variable they start from
+        while j < len(tokens) and tokens[j].value in ('.', '['):
+            if tokens[j].value == '[':
+                j = match_bracket(tokens, j) + 1
+            elif j + 1 < len(tokens) and tokens[j + 1].kind == 'ident':
+                j += 2
+            else:
+                return []
+        if j < len(tokens) and tokens[j].value == ',':
+            j += 1
+            continue
+        break
+    return names if j < len(tokens) and tokens[j].value == '=' else []
+
+
+def _local_names(tokens: List[GoToken], body: int) -> Set[str]:
+    """Names the body declares: x := ..., a, b := ..., var x T, and func literal parameters"""
+    names = set()
+    for i in range(body, len(tokens)):
+        tok = tokens[i]
+        if tok.value == ':=':
+            j = i - 1
+            while j >= body and tokens[j].kind == 'ident':
+                names.add(tokens[j].value)
+                if j >= 1 and tokens[j - 1].value == ',':
+                    j -= 2
+                else:
+                    break
+        elif tok.value == 'var' and i + 1 < len(tokens) and tokens[i + 1].kind == 'ident':
+            names.add(tokens[i + 1].value)
+        elif tok.value == 'func' and i + 1 < len(tokens) and tokens[i + 1].value == '(':
+            close = match_bracket(tokens, i + 1)
+            names.update(t.value for t, after in zip(tokens[i + 2:close], tokens[i + 3:close + 1])
+                         if t.kind == 'ident' and after.value not in ('.', ')', ']'))
+    return names
+
+
+def _body_start(tokens: List[GoToken]) -> int:
+    """Index of the first token inside a function body"""
+    depth = 0
+    for i, tok in enumerate(tokens):
+        if tok.value in ('(', '['):
+            depth += 1
+        elif tok.value in (')', ']'):
+            depth -= 1
+        elif tok.value == '{' and depth == 0:
+            if i > 0 and tokens[i - 1].value in ('struct', 'interface'):
+                continue
+            return i + 1
+    return len(tokens)
@@ -0,0 +1,112 @@
+#!/usr/bin/env python3
+"""
+Package summary chunks for Go
+One chunk per package gathers its exported API (type declarations with their
+methods, functions, constants and variables, each with the first line of its
+doc comment) under the package doc comment, for coarse "what does package X
+do" retrieval next to the per-symbol chunks. It is built from the package
+clauses GoChunker extracts with package_clauses on: one clause becomes the
+summary, the others are dropped.
+"""
+
+import os
+from typing import TYPE_CHECKING, Dict, List
+
+from .base_chunker import CodeChunk
+from .go_call_graph import symbol_ref
+from .go_tests import TEST_KINDS
+
+if TYPE_CHECKING:
+    from .go_package_linker import GoPackage
+
+
+# Declaration kinds listed in a summary: types first, then the rest in this order
+SUMMARY_TYPE_KINDS = ('struct', 'interface', 'type', 'alias')
+SUMMARY_OTHER_KINDS = ('function', 'const', 'var')
+
+
+def summarize_packages(packages: Dict[tuple, 'GoPackage']) -> List[CodeChunk]:
+    """
+    Turn one package clause chunk per package into the package's summary
+    
+    The clause kept is the one with the package doc comment (as go/doc picks it:
+    "Package name ..." first, then doc.go), else the first file's. Its content
+    becomes the summary and it gets 'members', references to the chunks listed,
+    and 'files', the package's files. The summary is generated text, so it has
+    no byte range; its lines are those of the clause. Test files' declarations
+    are left out, and a package of test files only gets no summary.
+    
+    Args:
+        packages: GoPackage resolvers keyed by (directory, package name)
+    
+    Returns:
+        The package clause chunks not kept, to drop from the chunks to store
+    """
+    dropped = []
+    for package in packages.values():
+        clauses = [chunk for chunk in package.chunks if chunk.type == 'package']
+        if not clauses:
+            continue
+        sources = [chunk for chunk in clauses if chunk.kind not in TEST_KINDS]
+        if not sources:
+            dropped.extend(clauses)
+            continue
+        
+        summary = min(sources, key=lambda c: (
+            not (c.doc or '').startswith(f"Package {package.name}"),
+            not c.doc,
+            os.path.basename(c.filepath) != 'doc.go',
+            c.filepath,
+        ))
+        dropped.extend(chunk for chunk in clauses if chunk is not summary)
+        
+        members = _exported_members(package)
+        summary.content = _summary_text(package.name, summary.doc, members)
+        summary.byte_start = summary.byte_end = None
+        summary.metadata = dict(summary.metadata or {},
+                                members=[dict(symbol_ref(chunk, package), type=chunk.type) for chunk in members],
+                                files=sorted({chunk.filepath for chunk in package.chunks if chunk.kind not in TEST_KINDS}))
+    return dropped
+
+
+def _exported_members(package: 'GoPackage') -> List[CodeChunk]:
+    """
+    Exported declarations of a package in summary order: each type followed by its
+    exported methods (or the methods an interface requires), then functions,
+    constants and variables, each group in source order
+    """
+    def exported(chunk: CodeChunk) -> bool:
+        return chunk.name[:1].isupper() and chunk.kind not in TEST_KINDS
+    
+    def position(chunk: CodeChunk):
+        return chunk.filepath, chunk.line_start
+    
+    chunks = package.chunks
+    methods: Dict[str, List[CodeChunk]] = {}
+    for chunk in sorted(chunks, key=position):
+        if chunk.type in ('method', 'interface_method') and chunk.parent_class and exported(chunk):
+            methods.setdefault(chunk.parent_class, []).append(chunk)
+    
+    members = []
+    for chunk in sorted(chunks, key=position):
+        if chunk.type in SUMMARY_TYPE_KINDS and exported(chunk):
+            members.append(chunk)
+            members.extend(methods.get(chunk.name, []))
+    for kind in SUMMARY_OTHER_KINDS:
+        members.extend(chunk for chunk in sorted(chunks, key=position)
+                       if chunk.type == kind and exported(chunk) and chunk.name != 'init')
+    return members
+
+
+def _summary_text(name: str, doc: str, members: List[CodeChunk]) -> str:
+    """The package doc comment, clause and member signatures, as Go-like text"""
+    lines = [f"// {line}".rstrip() for line in (doc or '').splitlines()]
+    lines += [f"package {name}", ""]
+    for chunk in members:
+        signature = chunk.signature or f"{chunk.type} {chunk.name}"
+        indent = "\t" if chunk.type in ('method', 'interface_method') else ""
+        line = f"{indent}{signature}"
+        if chunk.doc:
+            line += f" // {chunk.doc.splitlines()[0]}"
+        lines.append(line)
+    return '\n'.join(lines).rstrip('\n')
@@ -0,0 +1,262 @@
+#!/usr/bin/env python3
+"""
+String forms of Go constants
+Links typed constants to the String method of their type and records the
+text it returns for each of them. Three kinds of String method are read:
+
+    switch sc { case Unauthorized: return "Unauthorized" }      // a switch
+    return statusText[sc]                                       // a table: map[StatusCode]string{...}
+    return _StatusCode_name[_StatusCode_index[i]:...]           // the output of stringer
+
+The text is written into the constant's context (const Unauthorized
+StatusCode = 1 // String(): "login required"), so a search for the text finds
+the constant even when the String method is in another file.
+"""
+
+import json
+from collections import defaultdict
+from typing import TYPE_CHECKING, Dict, List, Optional, Tuple
+
+from .base_chunker import CodeChunk
+from .go_call_graph import symbol_ref
+from .go_lexer import GoToken, match_bracket, split_top_level, tokenize_go
+from .go_constants import format_value
+
+if TYPE_CHECKING:
+    from .go_package_linker import GoPackage
+
+
+def link_string_methods(package: 'GoPackage'):
+    """
+    Link typed constants to the String method of their type
+    
+    A constant the method covers gets a 'string_method' reference and, when its
+    text is known, its 'string'; the method gets the constants it covers as
+    'cases' and how it maps them as 'stringer' ('switch', 'table' or
+    'generated'). A switch clause counts even when it does not return a literal.
+    """
+    by_type: Dict[str, Dict[str, CodeChunk]] = defaultdict(dict)
+    for chunk in package.constants.values():
+        type_name = (chunk.metadata or {}).get('type')
+        if type_name:
+            by_type[type_name][chunk.name] = chunk
+    variables = {chunk.name: chunk for chunk in package.chunks if chunk.type == 'var'}
+    
+    for type_name, constants in by_type.items():
+        method = package.method_chunks.get((type_name, 'String'))
+        if not method:
+            continue
+        
+        stringer, labels = 'generated', _generated_strings(type_name, constants, package.constants, variables)
+        if not labels:
+            stringer, labels = 'table', _table_strings(method, constants, variables)
+        if not labels:
+            stringer, labels = 'switch', {}
+            for names, label in _case_clauses(method.content):
+                for name in names:
+                    if name in constants and name not in labels:
+                        labels[name] = label
+        if not labels:
+            continue
+        
+        cases = []
+        for name, constant in constants.items():
+            if name not in labels:
+                continue
+            constant.metadata['string_method'] = symbol_ref(method, package)
+            if labels[name] is not None:
+                constant.metadata['string'] = labels[name]
+                constant.context = f"{constant.signature} // String(): {format_value(labels[name])}"
+            cases.append(symbol_ref(constant, package))
+        cases.sort(key=lambda ref: (ref['filepath'], ref['line']))
+        method.metadata = dict(method.metadata or {}, cases=cases, stringer=stringer)
+
+
+def _generated_strings(type_name: str, constants: Dict[str, CodeChunk], package_constants: Dict[str, CodeChunk],
+                       variables: Dict[str, CodeChunk]) -> Dict[str, str]:
+    """
+    Texts of the constants from the tables stringer generates: _T_name sliced by
+    _T_index for one run of consecutive values, _T_name_0, _T_index_0, ... for a
+    few runs, or _T_map for many. Empty if the tables do not match the constants
+    (a stale generated file)
+    """
+    def name_bytes(suffix: str = '') -> Optional[bytes]:
+        chunk = package_constants.get(f"_{type_name}_name{suffix}")
+        value = chunk.metadata.get('value') if chunk else None
+        return value.encode('utf-8') if isinstance(value, str) else None
+    
+    def numbers(suffix: str) -> Optional[List[int]]:
+        chunk = variables.get(f"_{type_name}_{suffix}")
+        elements = _literal_elements((chunk.metadata or {}).get('expression', '')) if chunk else None
+        if elements is None or any(key is not None or not _is_int(value) for key, value in elements):
+            return None
+        return [int(value[0].value, 0) for _, value in elements]
+    
+    values = {name: chunk.metadata.get('value') for name, chunk in constants.items()}
+    values = {name: value for name, value in values.items() if isinstance(value, int) and not isinstance(value, bool)}
+    by_value: Dict[int, str] = {}
+    
+    mapping = variables.get(f"_{type_name}_map")
+    text = name_bytes()
+    if mapping is not None and text is not None:
+        # 1: _T_name[0:7]
+        for key, value in _literal_elements((mapping.metadata or {}).get('expression', '')) or []:
+            if key is None or not _is_int(key) or len(value) != 6 or value[0].value != f"_{type_name}_name" \
+                    or value[1].value != '[' or value[3].value != ':' or not _is_int(value[2:3]) \
+                    or not _is_int(value[4:5]):
+                return {}
+            start, end = int(value[2].value, 0), int(value[4].value, 0)
+            by_value[int(key[0].value, 0)] = text[start:end].decode('utf-8', 'replace')
+    else:
+        runs: List[List[int]] = []
+        for value in sorted(set(values.values())):
+            if runs and value == runs[-1][-1] + 1:
+                runs[-1].append(value)
+            else:
+                runs.append([value])
+        suffixes = [''] if len(runs) == 1 else [f"_{n}" for n in range(len(runs))]
+        for run, suffix in zip(runs, suffixes):
+            text = name_bytes(suffix)
+            if text is None:
+                return {}
+            if len(run) == 1 and suffix:
+                by_value[run[0]] = text.decode('utf-8', 'replace')
+                continue
+            index = numbers(f"index{suffix}")
+            if index is None or len(index) != len(run) + 1:
+                return {}
+            for n, value in enumerate(run):
+                by_value[value] = text[index[n]:index[n + 1]].decode('utf-8', 'replace')
+    
+    return {name: by_value[value] for name, value in values.items() if value in by_value}
+
+
+def _table_strings(method: CodeChunk, constants: Dict[str, CodeChunk],
+                   variables: Dict[str, CodeChunk]) -> Dict[str, str]:
+    """
+    Texts of the constants from a map or array of strings the method indexes with
+    its receiver (return statusText[sc], names[int(d)-1]): keyed entries by the
+    constant named, positional ones by value
+    """
+    receiver = (method.metadata or {}).get('receiver')
+    tokens, _ = tokenize_go(method.content)
+    labels: Dict[str, str] = {}
+    for i, tok in enumerate(tokens):
+        table = variables.get(tok.value) if tok.kind == 'ident' else None
+        if table is None or i + 1 >= len(tokens) or tokens[i + 1].value != '[' or tokens[i - 1].value == '.':
+            continue
+        elements = _literal_elements((table.metadata or {}).get('expression', ''), 'string')
+        if not elements:
+            continue
+        offset = _index_offset(tokens[i + 2:match_bracket(tokens, i + 1)], receiver)
+        by_value: Dict[int, str] = {}
+        position = 0
+        for key, value in elements:
+            if len(value) != 1 or value[0].kind != 'string':
+                return {}
+            text = _string_value(value[0].value)
+            if key is None:
+                by_value[position] = text
+            elif len(key) == 1 and key[0].value in constants:
+                labels.setdefault(key[0].value, text)
+                position = constants[key[0].value].metadata.get('value', position)
+            elif _is_int(key):
+                position = int(key[0].value, 0)
+                by_value[position] = text
+            position = (position if isinstance(position, int) else 0) + 1
+        if offset is not None:
+            for name, constant in constants.items():
+                value = constant.metadata.get('value')
+                if isinstance(value, int) and not isinstance(value, bool) and value - offset in by_value:
+                    labels.setdefault(name, by_value[value - offset])
+    return labels
+
+
+def _index_offset(index: List[GoToken], receiver: Optional[str]) -> Optional[int]:
+    """N for an index of the receiver written as r, T(r), r - N or int(r) - N; None for anything else"""
+    if not receiver or not index:
+        return None
+    if len(index) >= 4 and index[0].kind == 'ident' and index[1].value == '(' and match_bracket(index, 1) == 3:
+        index = index[2:3] + index[4:]
+    if index[0].value != receiver:
+        return None
+    if len(index) == 1:
+        return 0
+    if len(index) == 3 and index[1].value in ('-', '+') and _is_int(index[2:]):
+        offset = int(index[2].value, 0)
+        return offset if index[1].value == '-' else -offset
+    return None
+
+
+def _literal_elements(expression: str, element_type: Optional[str] = None
+                      ) -> Optional[List[Tuple[Optional[List[GoToken]], List[GoToken]]]]:
+    """
+    (key tokens or None, value tokens) of each element of a composite literal of a
+    map, array or slice type (of element_type when given); None for other expressions
+    """
+    tokens, _ = tokenize_go(expression)
+    tokens = [tok for tok in tokens if tok.kind != ';']
+    if len(tokens) < 2 or tokens[0].value not in ('map', '['):
+        return None
+    type_end = match_bracket(tokens, 1 if tokens[0].value == 'map' else 0)
+    opening = next((n for n in range(type_end + 1, len(tokens)) if tokens[n].value in ('{', '[', '(')), None)
+    if opening is None or tokens[opening].value != '{' or match_bracket(tokens, opening) != len(tokens) - 1:
+        return None
+    if element_type is not None and [tok.value for tok in tokens[type_end + 1:opening]] != [element_type]:
+        return None
+    
+    elements = []
+    for element in split_top_level(tokens[opening + 1:-1], ','):
+        parts = split_top_level(element, ':')
+        if len(parts) == 1:
+            elements.append((None, element))
+        else:
+            elements.append((parts[0], element[len(parts[0]) + 1:]))
+    return elements
+
+
+def _case_clauses(source: str) -> List[Tuple[List[str], Optional[str]]]:
+    """
+    The case clauses of a function: (names listed, string literal the clause
+    returns first or None) for each 'case A, B:'
+    """
+    tokens, _ = tokenize_go(source)
+    clauses = []
+    for i, tok in enumerate(tokens):
+        if tok.value != 'case':
+            continue
+        names = []
+        j = i + 1
+        while j < len(tokens) and tokens[j].value != ':':
+            if tokens[j].value in ('(', '[', '{'):
+                j = match_bracket(tokens, j)
+            elif tokens[j].kind == 'ident' and tokens[j - 1].value != '.' \
+                    and (j + 1 >= len(tokens) or tokens[j + 1].value in (',', ':')):
+                names.append(tokens[j].value)
+            j += 1
+        label = None
+        if j + 2 < len(tokens) and tokens[j + 1].value == 'return' and tokens[j + 2].kind == 'string' \
+                and (j + 3 >= len(tokens) or tokens[j + 3].kind == ';' or tokens[j + 3].value == '}'):
+            label = _string_value(tokens[j + 2].value)
+        clauses.append((names, label))
+    return clauses
+
+
+def _string_value(literal: str) -> str:
+    """Value of a Go string literal"""
+    if literal.startswith('`'):
+        return literal[1:-1]
+    try:
+        return json.loads(literal)
+    except ValueError:
+        return literal[1:-1]
+
+
+def _is_int(tokens: List[GoToken]) -> bool:
+    if len(tokens) != 1 or tokens[0].kind != 'number':
+        return False
+    try:
+        int(tokens[0].value, 0)
+        return True
+    except ValueError:
+        return False
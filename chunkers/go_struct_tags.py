@@ -0,0 +1,66 @@
+#!/usr/bin/env python3
+"""
+Go struct tags as structured metadata
+A field's tag literal (`json:"username,omitempty" db:"user_name"`) is read the
+way reflect.StructTag reads it: space-separated key:"value" pairs, every
+namespace of the field kept. The name a tag maps the field to is the value up
+to its first comma ("-" skips the field), which is what the tag filter field
+matches: tag=username, or tag="json:user*" for one namespace.
+"""
+
+from fnmatch import fnmatchcase
+from typing import Dict, List
+
+from .go_constants import Unevaluable, _string
+
+
+def parse_struct_tag(literal: str) -> Dict[str, str]:
+    """Key/value pairs of a struct tag literal, in order; a malformed rest of the tag is ignored"""
+    try:
+        tag = _string(literal)
+    except Unevaluable:
+        return {}
+    pairs = {}
+    while tag:
+        tag = tag.lstrip(' ')
+        i = 0
+        while i < len(tag) and tag[i] > ' ' and tag[i] not in ':"\x7f':
+            i += 1
+        if i == 0 or i + 1 >= len(tag) or tag[i] != ':' or tag[i + 1] != '"':
+            break
+        key, tag = tag[:i], tag[i + 1:]
+        i = 1
+        while i < len(tag) and tag[i] != '"':
+            i += 2 if tag[i] == '\\' else 1
+        if i >= len(tag):
+            break
+        try:
+            pairs[key] = _string(tag[:i + 1])
+        except Unevaluable:
+            break
+        tag = tag[i + 1:]
+    return pairs
+
+
+def tag_name(value: str) -> str:
+    """The name a tag value maps its field to: json:"user_name,omitempty" -> user_name"""
+    return value.split(',', 1)[0]
+
+
+def tagged_names(fields: List[Dict]) -> List[Dict]:
+    """{'field', 'key', 'name'} for every tag namespace of the fields that names the field"""
+    names = []
+    for field in fields:
+        for key, value in (field.get('tags') or {}).items():
+            name = tag_name(value)
+            if name and name != '-':
+                names.append({'field': field['name'], 'key': key, 'name': name})
+    return names
+
+
+def matches_tag(fields: List[Dict], pattern: str) -> bool:
+    """True if a field's tag maps it to a name matching pattern, or key:name when the pattern has a colon"""
+    for entry in tagged_names(fields):
+        if fnmatchcase(f"{entry['key']}:{entry['name']}" if ':' in pattern else entry['name'], pattern):
+            return True
+    return False
@@ -0,0 +1,49 @@
+#!/usr/bin/env python3
+"""
+Templates rendered by Go functions
+Code that executes a template by name (tmpl.ExecuteTemplate(w,
+"user/profile", data)) records the name in 'templates', the name the
+template's {{define}} chunk carries (see html_chunker), so a handler and the
+template it renders are found by the same name. The files it parses
+(template.ParseFiles("web/profile.gohtml"), ParseGlob("web/*.tmpl") and
+ParseFS(fsys, "web/*.tmpl")) go into 'template_files'. Only string literal
+arguments are read.
+"""
+
+from typing import Dict, List
+
+from .go_constants import Unevaluable, _string
+from .go_errors import _arguments
+
+
+# Methods executing a template by name, and the argument holding the name
+EXECUTE_METHODS = {'ExecuteTemplate': 1}
+
+# Functions and methods parsing template files, and the first argument naming a file
+PARSE_METHODS = {'ParseFiles': 0, 'ParseGlob': 0, 'ParseFS': 1}
+
+
+def rendered_templates(tokens) -> Dict[str, List[str]]:
+    """'templates' and 'template_files' of a chunk's tokens; empty lists are left out"""
+    found: Dict[str, List[str]] = {}
+    for i in range(1, len(tokens) - 1):
+        if tokens[i - 1].value != '.' or tokens[i + 1].value != '(':
+            continue
+        method = tokens[i].value
+        if method in EXECUTE_METHODS:
+            key, args = 'templates', _arguments(tokens, i + 1)[EXECUTE_METHODS[method]:][:1]
+        elif method in PARSE_METHODS:
+            key, args = 'template_files', _arguments(tokens, i + 1)[PARSE_METHODS[method]:]
+        else:
+            continue
+        for arg in args:
+            if len(arg) != 1 or arg[0].kind != 'string':
+                continue
+            try:
+                value = _string(arg[0].value)
+            except Unevaluable:
+                continue
+            names = found.setdefault(key, [])
+            if value not in names:
+                names.append(value)
+    return found
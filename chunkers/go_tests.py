@@ -0,0 +1,95 @@
+#!/usr/bin/env python3
+"""
+Go test functions of _test.go files
+Tags the functions `go test` runs by their kind and the symbol they exercise,
+following the conventions of the testing package:
+
+    func TestXxx(t *testing.T)       kind 'test'
+    func BenchmarkXxx(b *testing.B)  kind 'benchmark'
+    func FuzzXxx(f *testing.F)       kind 'fuzz'
+    func ExampleXxx()                kind 'example'
+
+Xxx must not start with a lowercase letter. Every other chunk of a test file
+(helpers, fixtures, types) keeps the kind 'test'.
+"""
+
+import re
+from typing import List, Optional, Tuple
+
+from .base_chunker import CodeChunk
+
+
+# Chunk kinds of test file code; search treats all of them as tests (exclude_tests)
+TEST_KINDS = ('test', 'benchmark', 'fuzz', 'example')
+
+# Name prefix -> (kind, the testing type its single parameter points to; None: no parameters)
+TEST_PREFIXES = {
+    'Test': ('test', 'T'),
+    'Benchmark': ('benchmark', 'B'),
+    'Fuzz': ('fuzz', 'F'),
+    'Example': ('example', None),
+}
+
+# Functions a test binary calls that are not tests themselves: TestMain(m *testing.M)
+NOT_TESTS = {'TestMain'}
+
+
+def go_test_function(chunk: CodeChunk) -> Optional[Tuple[str, Optional[str]]]:
+    """
+    Kind and subject of a Go test function, or None if the chunk is not one
+    
+    The subject is the symbol named after the prefix, by the naming convention
+    of examples (ExampleT_M documents the method T.M; a lowercase _suffix only
+    tells examples apart): TestAuthenticate -> Authenticate,
+    TestAdminUser_Authenticate -> AdminUser.Authenticate,
+    ExampleParse_second -> Parse. A bare Test or Example has no subject.
+    """
+    if chunk.type != 'function' or chunk.parent or chunk.name in NOT_TESTS:
+        return None
+    prefix = next((p for p in TEST_PREFIXES if chunk.name.startswith(p)), None)
+    if prefix is None:
+        return None
+    rest = chunk.name[len(prefix):]
+    if rest[:1].islower():
+        return None  # Testify, Examples: not test functions
+    
+    kind, testing_type = TEST_PREFIXES[prefix]
+    metadata = chunk.metadata or {}
+    params, results = metadata.get('params', []), metadata.get('results', [])
+    if results or metadata.get('type_params'):
+        return None
+    if testing_type is None:
+        if params:
+            return None
+    elif len(params) != 1 or not re.fullmatch(r'\*\s*(?:\w+\.)?' + testing_type, params[0].get('type', '')):
+        return None
+    return kind, subject_from_name(rest)
+
+
+def subject_from_name(rest: str) -> Optional[str]:
+    """Symbol a test name refers to, from what follows its prefix (AdminUser_Authenticate)"""
+    parts = []
+    for part in rest.lstrip('_').split('_'):
+        if not part or part[:1].islower() or len(parts) == 2:
+            break
+        parts.append(part)
+    return '.'.join(parts) or None
+
+
+def tag_go_tests(chunks: List[CodeChunk]) -> List[CodeChunk]:
+    """
+    Mark the chunks of a _test.go file: kind 'test', or the kind of the test
+    function, with its 'subject' in the metadata
+    
+    Returns:
+        The same chunks
+    """
+    for chunk in chunks:
+        chunk.kind = 'test'
+        tagged = go_test_function(chunk)
+        if tagged is None:
+            continue
+        chunk.kind, subject = tagged
+        if subject:
+            chunk.metadata = dict(chunk.metadata or {}, subject=subject)
+    return chunks
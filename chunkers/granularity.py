@@ -0,0 +1,161 @@
+#!/usr/bin/env python3
+"""
+Chunk granularity modes
+Decides what one indexed chunk covers: a whole file, one symbol, or one
+symbol together with the imports it needs to be read on its own
+"""
+
+import os
+import re
+from enum import Enum
+from fnmatch import fnmatchcase
+from typing import List, Optional
+
+from .base_chunker import CodeChunk
+from .token_splitter import get_token_counter
+
+
+class Granularity(str, Enum):
+    """What a chunk covers"""
+    FILE = 'file'  # one chunk per file (oversized files are still split by token budget)
+    SYMBOL = 'symbol'  # one chunk per symbol, as extracted by the chunker
+    SYMBOL_WITH_IMPORTS = 'symbol_with_imports'  # functions/methods prefixed with the file's imports
+
+
+# Chunk types that get the import block in SYMBOL_WITH_IMPORTS mode
+PREFIXED_KINDS = ('function', 'method')
+
+# Chunk types a minimum size applies to (see is_small_symbol)
+SIZED_KINDS = ('function', 'method')
+
+# Chunk types that are not one symbol, never left out by name (see is_excluded_symbol)
+UNNAMED_KINDS = ('file', 'text')
+
+# Top-level statements that make up a file's import block, per language
+IMPORT_PATTERNS = {
+    'python': r'(?:import\s|from\s+\S+\s+import\b)',
+    'javascript': r'(?:import\b|export\s+(?:\*|\{[^}]*\})\s+from\b|(?:const|let|var)\s+[\w{},\s]+=\s*require\()',
+    'go': r'(?:package|import)\b',
+    'java': r'(?:package|import)\s',
+    'kotlin': r'(?:package|import)\s',
+    'scala': r'(?:package|import)\s',
+    'rust': r'(?:(?:pub(?:\([^)]*\))?\s+)?use\s|extern\s+crate\s)',
+    'cpp': r'(?:\s*#\s*(?:include|import)\b|using\s+namespace\s)',
+    'c': r'\s*#\s*include\b',
+    'mojom': r'(?:module|import)\s',
+    'gn': r'import\(',
+    'dart': r'(?:library|import|export|part)\s',
+}
+
+# Languages without an entry above: the usual spellings
+DEFAULT_IMPORT_PATTERN = r'(?:import\s|from\s+\S+\s+import\b|use\s|using\s|require\b|\s*#\s*include\b)'
+
+
+def parse_granularity(value: Optional[str]) -> Granularity:
+    """Granularity from a setting or flag value: 'symbol', 'File', 'symbol-with-imports', 'SymbolWithImports'"""
+    if isinstance(value, Granularity):
+        return value
+    if not value:
+        return Granularity.SYMBOL
+    normalized = re.sub(r'(?<=[a-z])(?=[A-Z])', '_', value.strip()).lower().replace('-', '_')
+    try:
+        return Granularity(normalized)
+    except ValueError:
+        choices = ', '.join(g.value for g in Granularity)
+        raise ValueError(f"Unknown granularity '{value}' (expected one of: {choices})")
+
+
+def extract_import_block(code: str, language: str) -> str:
+    """
+    The file's top-level import statements, in order
+    
+    Multi-line statements (Go import groups, parenthesized Python imports,
+    JavaScript named imports) are kept whole by bracket balance.
+    """
+    pattern = re.compile(IMPORT_PATTERNS.get(language, DEFAULT_IMPORT_PATTERN))
+    lines = code.split('\n')
+    block = []
+    i = 0
+    while i < len(lines):
+        if not pattern.match(lines[i]):
+            i += 1
+            continue
+        
+        # Take continuation lines until brackets balance and no line continuation is pending
+        depth = 0
+        while i < len(lines):
+            line = lines[i]
+            block.append(line.rstrip())
+            depth += sum(line.count(c) for c in '([{') - sum(line.count(c) for c in ')]}')
+            i += 1
+            if depth <= 0 and not line.rstrip().endswith('\\'):
+                break
+    return '\n'.join(block)
+
+
+def apply_granularity(chunks: List[CodeChunk], code: str, filepath: str, language: str,
+                      granularity: Granularity = Granularity.SYMBOL) -> List[CodeChunk]:
+    """
+    Reshape a file's chunks for the selected granularity
+    
+    Args:
+        chunks: Symbol chunks extracted from the file
+        code: The file's source
+        filepath: Path stored with the chunks
+        language: Language of the file
+        granularity: Target granularity
+    
+    Returns:
+        The chunks to index: the symbol chunks unchanged (SYMBOL), with their
+        import context set (SYMBOL_WITH_IMPORTS), or a single file chunk (FILE)
+    """
+    granularity = parse_granularity(granularity)
+    
+    if granularity == Granularity.FILE:
+        return [CodeChunk(
+            type='file',
+            name=os.path.basename(filepath),
+            content=code,
+            filepath=filepath,
+            language=language,
+            line_start=1,
+            line_end=code.count('\n') + (0 if code.endswith('\n') else 1),
+            signature=filepath,
+            metadata={'symbols': [c.name for c in chunks]},
+            byte_start=0,
+            byte_end=len(code.encode('utf-8'))
+        )]
+    
+    if granularity == Granularity.SYMBOL_WITH_IMPORTS:
+        imports = extract_import_block(code, language)
+        if imports:
+            for chunk in chunks:
+                if chunk.type in PREFIXED_KINDS:
+                    chunk.context = imports + '\n'
+    
+    return chunks
+
+
+def is_small_symbol(chunk: CodeChunk, min_lines: int = 0, min_tokens: int = 0,
+                    encoding: str = 'cl100k_base') -> bool:
+    """
+    True for a function or method shorter than min_lines lines or min_tokens
+    tokens of code (0 disables either); parts of a split symbol never are
+    """
+    if chunk.type not in SIZED_KINDS or chunk.part_count > 1:
+        return False
+    if min_lines and chunk.line_end - chunk.line_start + 1 < min_lines:
+        return True
+    return bool(min_tokens) and get_token_counter(encoding).count(chunk.content) < min_tokens
+
+
+def is_excluded_symbol(chunk: CodeChunk, patterns) -> bool:
+    """
+    True for a symbol whose qualified name (User.String, init) matches one of
+    the fnmatch patterns ('*.String', 'Test*'); whole-file chunks and text
+    blocks never are
+    """
+    if not patterns or chunk.type in UNNAMED_KINDS:
+        return False
+    name = chunk.qualified_name
+    return any(fnmatchcase(name, pattern) for pattern in patterns)
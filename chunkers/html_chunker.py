@@ -0,0 +1,399 @@
+#!/usr/bin/env python3
+"""
+HTML and Go template chunker
+Supports .html, .htm, .tmpl and .gohtml files
+
+Each {{define "name"}} ... {{end}} block is one chunk named after its template,
+with a {{/* comment */}} right above it as its doc. Outside the defines the
+page is split at its major sections (head, header, nav, section, article,
+aside, footer, form, dialog, table, script and style elements), the outermost
+one of a nest taking the rest with it; the markup between them is kept as
+document chunks. Chunks record the templates they include ({{template "x"}})
+and the blocks they declare ({{block "x" .}}), sections their element, id
+and first heading.
+
+Template actions are read with Go's quoting rules, so a '}}' in a string does
+not end one, and they are blanked out before the markup is scanned: a '<'
+inside an action, or an action inside an attribute, does not move a section.
+Unbalanced actions ({{end}} without an opening, an unclosed {{define}}) are
+reported in the diagnostics; an unclosed define runs to the end of the file.
+"""
+
+import re
+import textwrap
+from bisect import bisect_right
+from dataclasses import dataclass
+from fnmatch import fnmatchcase
+from pathlib import PurePosixPath
+from typing import Dict, List, Optional, Tuple
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error
+
+
+# Elements that start a chunk of their own, and the chunk type of each
+SECTION_TAGS = {
+    'head': 'section', 'header': 'section', 'nav': 'section', 'section': 'section',
+    'article': 'section', 'aside': 'section', 'footer': 'section', 'form': 'section',
+    'dialog': 'section', 'table': 'section', 'script': 'script', 'style': 'style',
+}
+
+# Elements whose content is raw text, where a '<' opens no tag
+RAW_TEXT_TAGS = ('script', 'style', 'textarea', 'title')
+
+# Actions that open a block closed by {{end}}
+BLOCK_ACTIONS = ('define', 'block', 'if', 'range', 'with')
+
+ACTION_KEYWORD = re.compile(r'(define|block|template|end|if|range|with|else|break|continue)\b\s*'
+                            r'("(?:[^"\\\n]|\\.)*"|`[^`]*`)?')
+TAG_PATTERN = re.compile(r'<(/?)([A-Za-z][\w:-]*)((?:"[^"]*"|\'[^\']*\'|[^\'">])*)>')
+# A comment action: {{/* ... */}} or {{- /* ... */ -}}
+COMMENT_OPEN = re.compile(r'(?:-\s+)?/\*')
+HTML_COMMENT = re.compile(r'<!--.*?(?:-->|\Z)', re.DOTALL)
+ATTRIBUTE_PATTERN = r'(?:^|\s){}\s*=\s*(?:"([^"]*)"|\'([^\']*)\'|([^\s>]+))'
+HEADING_PATTERN = re.compile(r'<h[1-6]\b[^>]*>(.*?)</h[1-6]\s*>', re.IGNORECASE | re.DOTALL)
+
+
+@dataclass
+class TemplateAction:
+    """A {{...}} action with its source span"""
+    start: int
+    end: int  # just past the closing '}}'
+    line: int
+    keyword: str  # define, template, end, ..., 'comment' for {{/* */}}, '' for other actions
+    name: Optional[str] = None  # template name of define, block and template
+    text: str = ''  # the action without its delimiters, or the comment's text
+
+
+def scan_actions(code: str) -> Tuple[List[TemplateAction], List[Dict]]:
+    """
+    Find the template actions of a file
+    
+    Returns:
+        The actions in order, and parse_error diagnostics (an unclosed action
+        ends the scan: the rest of the file is markup)
+    """
+    actions = []
+    diagnostics = []
+    position = 0
+    while True:
+        start = code.find('{{', position)
+        if start < 0:
+            break
+        end = _action_end(code, start + 2)
+        line = code.count('\n', 0, start) + 1
+        if end is None:
+            diagnostics.append(parse_error(line, "unclosed template action"))
+            break
+        actions.append(_parse_action(code[start + 2:end - 2], start, end, line))
+        position = end
+    return actions, diagnostics
+
+
+def _action_end(code: str, index: int) -> Optional[int]:
+    """Offset just past the '}}' closing the action whose body starts at index"""
+    comment = COMMENT_OPEN.match(code, index)
+    if comment:
+        close = code.find('*/', comment.end())
+        if close < 0:
+            return None
+        after = re.match(r'\s*-?\}\}', code[close + 2:])
+        return close + 2 + after.end() if after else None
+    
+    quote = None
+    i = index
+    while i < len(code):
+        char = code[i]
+        if quote:
+            if char == '\\' and quote != '`':
+                i += 2
+                continue
+            if char == quote:
+                quote = None
+        elif char in '"\'`':
+            quote = char
+        elif code.startswith('}}', i):
+            return i + 2
+        i += 1
+    return None
+
+
+def _parse_action(body: str, start: int, end: int, line: int) -> TemplateAction:
+    """Classify an action from its text between the delimiters"""
+    # '{{- ' and ' -}}' trim the markup around the action
+    text = re.sub(r'^-(?=\s)', '', body)
+    text = re.sub(r'(?<=\s)-$', '', text).strip()
+    if text.startswith('/*'):
+        return TemplateAction(start, end, line, 'comment', text=text[2:].rsplit('*/', 1)[0])
+    match = ACTION_KEYWORD.match(text)
+    if not match:
+        return TemplateAction(start, end, line, '', text=text)
+    name = match.group(2)[1:-1] if match.group(2) else None
+    return TemplateAction(start, end, line, match.group(1), name=name, text=text)
+
+
+@dataclass
+class TemplateDefine:
+    """A {{define}} block: its opening and closing actions"""
+    name: str
+    open: TemplateAction
+    close: Optional[TemplateAction]  # None when it is never closed
+
+
+class HtmlChunker(BaseChunker):
+    """Extracts template definitions and page sections from HTML and Go templates"""
+    
+    def __init__(self):
+        super().__init__('html')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract the defines and, outside them, the sections and markup of the file"""
+        self._code = code
+        self._line_starts = [0] + [m.end() for m in re.finditer(r'\n', code)]
+        self._actions, self.diagnostics = scan_actions(code)
+        self._masked = _mask(code, self._actions)
+        defines = self._find_defines()
+        
+        chunks = []
+        position = 0
+        for define in defines:
+            doc = self._doc_comment(define.open)
+            start = self._line_start(doc.start if doc else define.open.start)
+            end = self._line_end(define.close.end) if define.close else len(code)
+            chunks.extend(self._markup_chunks(position, start, filepath))
+            chunks.append(self._define_chunk(define, doc, start, end, filepath))
+            position = end
+        chunks.extend(self._markup_chunks(position, len(code), filepath))
+        
+        self.diagnostics.sort(key=lambda d: d['line'])
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Template definitions
+    # ------------------------------------------------------------------
+    
+    def _find_defines(self) -> List[TemplateDefine]:
+        """Match the block actions with their {{end}}; returns the top-level defines"""
+        defines = []
+        stack: List[TemplateAction] = []
+        for action in self._actions:
+            if action.keyword in BLOCK_ACTIONS:
+                if action.keyword == 'define' and stack:
+                    self.diagnostics.append(parse_error(action.line, "{{define}} inside another block"))
+                stack.append(action)
+            elif action.keyword == 'end':
+                if not stack:
+                    self.diagnostics.append(parse_error(action.line, "unexpected {{end}}"))
+                    continue
+                opening = stack.pop()
+                if opening.keyword == 'define' and not stack and opening.name:
+                    defines.append(TemplateDefine(opening.name, opening, action))
+        
+        for action in stack:
+            label = f'{{{{{action.keyword} "{action.name}"}}}}' if action.name else f'{{{{{action.keyword}}}}}'
+            self.diagnostics.append(parse_error(action.line, f"unclosed {label}"))
+        # An unclosed top-level define takes the rest of the file
+        if stack and stack[0].keyword == 'define' and stack[0].name:
+            defines.append(TemplateDefine(stack[0].name, stack[0], None))
+        return sorted(defines, key=lambda d: d.open.start)
+    
+    def _doc_comment(self, action: TemplateAction) -> Optional[TemplateAction]:
+        """The {{/* comment */}} directly above an action, if any"""
+        index = self._actions.index(action)
+        if index == 0:
+            return None
+        previous = self._actions[index - 1]
+        if previous.keyword != 'comment' or self._code[previous.end:action.start].strip():
+            return None
+        return previous
+    
+    def _define_chunk(self, define: TemplateDefine, doc: Optional[TemplateAction],
+                      start: int, end: int, filepath: str) -> CodeChunk:
+        metadata = dict({'template': define.name}, **self._references(start, end))
+        return CodeChunk(
+            type='template',
+            name=define.name,
+            content=self._code[start:end].rstrip(),
+            filepath=filepath,
+            language=self.language,
+            line_start=self._line(start),
+            line_end=self._line(end - 1 if end > start else end),
+            signature=f'{{{{define "{define.name}"}}}}',
+            doc=textwrap.dedent(doc.text).strip() or None if doc else None,
+            metadata=metadata
+        )
+    
+    # ------------------------------------------------------------------
+    # Page sections
+    # ------------------------------------------------------------------
+    
+    def _markup_chunks(self, start: int, end: int, filepath: str) -> List[CodeChunk]:
+        """Sections of the markup between start and end, and document chunks for what lies between them"""
+        chunks = []
+        position = start
+        for tag, open_start, open_end, close_end in self._sections(start, end):
+            section_start, section_end = self._line_start(open_start), self._line_end(close_end)
+            section_start, section_end = max(section_start, position), min(section_end, end)
+            chunks.extend(self._document_chunk(position, section_start, filepath))
+            chunks.append(self._section_chunk(tag, open_start, open_end, section_start, section_end, filepath))
+            position = section_end
+        chunks.extend(self._document_chunk(position, end, filepath))
+        return chunks
+    
+    def _sections(self, start: int, end: int) -> List[Tuple[str, int, int, int]]:
+        """Outermost section elements between start and end: (tag, open start, open end, close end)"""
+        sections = []
+        current: Optional[Tuple[str, int, int]] = None
+        depth = 0
+        position = start
+        while True:
+            match = TAG_PATTERN.search(self._masked, position, end)
+            if not match:
+                break
+            closing, tag = match.group(1), match.group(2).lower()
+            self_closing = match.group(3).rstrip().endswith('/')
+            position = match.end()
+            
+            if not closing and tag in RAW_TEXT_TAGS:
+                close = re.compile(r'</' + tag + r'\s*>', re.IGNORECASE).search(self._masked, position, end)
+                position = close.end() if close else end
+                if current is None and tag in SECTION_TAGS:
+                    sections.append((tag, match.start(), match.end(), position))
+                continue
+            
+            if current is None:
+                if not closing and not self_closing and tag in SECTION_TAGS:
+                    current = (tag, match.start(), match.end())
+                    depth = 1
+            elif tag == current[0] and not self_closing:
+                depth += -1 if closing else 1
+                if depth == 0:
+                    sections.append(current + (match.end(),))
+                    current = None
+        
+        # An unclosed section runs to the end of the markup
+        if current is not None:
+            sections.append(current + (end,))
+        return sections
+    
+    def _section_chunk(self, tag: str, open_start: int, open_end: int, start: int, end: int,
+                       filepath: str) -> CodeChunk:
+        opening = self._code[open_start:open_end]
+        element_id = _attribute(opening, 'id')
+        classes = (_attribute(opening, 'class') or '').split()
+        heading = self._heading(open_end, end)
+        
+        name = f"{tag}#{element_id}" if element_id else f"{tag}.{classes[0]}" if classes else tag
+        metadata: Dict = {'element': tag}
+        if element_id:
+            metadata['id'] = element_id
+        if heading:
+            metadata['heading'] = heading
+        metadata.update(self._references(start, end))
+        return CodeChunk(
+            type=SECTION_TAGS[tag],
+            name=name,
+            content=self._code[start:end].rstrip(),
+            filepath=filepath,
+            language=self.language,
+            line_start=self._line(start),
+            line_end=self._line(end - 1 if end > start else end),
+            signature=re.sub(r'\s+', ' ', opening),
+            metadata=metadata
+        )
+    
+    def _document_chunk(self, start: int, end: int, filepath: str) -> List[CodeChunk]:
+        """The markup between sections as a chunk, unless it is only tags and closing actions"""
+        text = self._code[start:end]
+        stripped = text.strip()
+        if not stripped:
+            return []
+        masked = self._masked[start:end]
+        has_text = bool(re.sub(r'<[^>]*>', '', masked).strip())
+        has_actions = any(start <= a.start < end and a.keyword not in ('end', 'else', 'comment')
+                          for a in self._actions)
+        if not has_text and not has_actions:
+            return []
+        
+        start += len(text) - len(text.lstrip())
+        start = self._line_start(start)
+        end = start + len(self._code[start:end].rstrip())
+        return [CodeChunk(
+            type='document',
+            name=PurePosixPath(filepath).name,
+            content=self._code[start:end],
+            filepath=filepath,
+            language=self.language,
+            line_start=self._line(start),
+            line_end=self._line(end - 1 if end > start else end),
+            metadata=self._references(start, end) or None
+        )]
+    
+    def _heading(self, start: int, end: int) -> Optional[str]:
+        """Text of the first h1-h6 between start and end, template actions kept"""
+        match = HEADING_PATTERN.search(self._masked, start, end)
+        if not match:
+            return None
+        text = re.sub(r'<[^>]*>', '', self._code[match.start(1):match.end(1)])
+        return re.sub(r'\s+', ' ', text).strip() or None
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _references(self, start: int, end: int) -> Dict[str, List[str]]:
+        """Templates included and blocks declared by the actions between start and end"""
+        references: Dict[str, List[str]] = {}
+        for action in self._actions:
+            if start <= action.start < end and action.name and action.keyword in ('template', 'block'):
+                names = references.setdefault('includes' if action.keyword == 'template' else 'blocks', [])
+                if action.name not in names:
+                    names.append(action.name)
+        return references
+    
+    def _line(self, offset: int) -> int:
+        """1-based line of an offset"""
+        return bisect_right(self._line_starts, offset)
+    
+    def _line_start(self, offset: int) -> int:
+        """Offset of the start of offset's line when only whitespace precedes it there"""
+        line_start = self._line_starts[self._line(offset) - 1]
+        return line_start if not self._code[line_start:offset].strip() else offset
+    
+    def _line_end(self, offset: int) -> int:
+        """Offset past the end of offset's line when only whitespace follows it there"""
+        newline = self._code.find('\n', offset)
+        line_end = len(self._code) if newline < 0 else newline + 1
+        return line_end if not self._code[offset:line_end].strip() else offset
+
+
+def _mask(code: str, actions: List[TemplateAction]) -> str:
+    """The code with template actions and HTML comments blanked out, newlines kept"""
+    blank = lambda text: re.sub(r'[^\n]', ' ', text)
+    parts = []
+    position = 0
+    for action in actions:
+        parts.append(code[position:action.start])
+        parts.append(blank(code[action.start:action.end]))
+        position = action.end
+    parts.append(code[position:])
+    masked = ''.join(parts)
+    return HTML_COMMENT.sub(lambda m: blank(m.group()), masked)
+
+
+def _attribute(tag: str, name: str) -> Optional[str]:
+    """Value of an attribute of an opening tag"""
+    match = re.search(ATTRIBUTE_PATTERN.format(name), tag[1:], re.IGNORECASE)
+    if not match:
+        return None
+    value = next(group for group in match.groups() if group is not None)
+    return value.strip() or None
+
+
+def matches_template(metadata: Dict, pattern: str) -> bool:
+    """
+    True if a chunk defines, includes or declares a template whose name matches
+    pattern, or is Go code executing one (see go_templates)
+    """
+    names = [metadata.get('template')] + [name for key in ('includes', 'blocks', 'templates')
+                                          for name in metadata.get(key) or []]
+    return any(isinstance(name, str) and fnmatchcase(name, pattern) for name in names)
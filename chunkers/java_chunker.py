@@ -0,0 +1,351 @@
+#!/usr/bin/env python3
+"""
+Java code chunker using tree-sitter for accurate parsing
+Supports .java files
+
+Type declarations are walked recursively through their bodies; method bodies,
+lambdas, anonymous classes and enum constant bodies are not entered. Nested
+and inner classes keep a reference to the classes that enclose them.
+"""
+
+import re
+from typing import Dict, List
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+# Declaration nodes and the chunk type they become; '@interface' declares an annotation type
+TYPE_DECLARATIONS = {
+    'class_declaration': 'class',
+    'interface_declaration': 'interface',
+    'enum_declaration': 'enum',
+    'record_declaration': 'record',
+    'annotation_type_declaration': 'annotation',
+}
+
+METHOD_DECLARATIONS = (
+    'method_declaration', 'constructor_declaration', 'compact_constructor_declaration',
+    'annotation_type_element_declaration',
+)
+
+FIELD_DECLARATIONS = ('field_declaration', 'constant_declaration')
+
+ANNOTATIONS = ('annotation', 'marker_annotation')
+
+COMMENTS = ('line_comment', 'block_comment')
+
+
+def is_javadoc(text: str) -> bool:
+    return text.startswith('/**') and text != '/**/'
+
+
+def javadoc_text(comment: str) -> str:
+    """Text of a Javadoc comment without the /** */ markers and leading asterisks"""
+    lines = []
+    for line in comment[3:-2].splitlines():
+        line = line.strip()
+        if line.startswith('*'):
+            line = line[1:]
+            line = line[1:] if line.startswith(' ') else line
+        lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)>\]])|([(<\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+class JavaChunker(BaseChunker):
+    """Extracts classes, interfaces, enums, records, methods and fields from Java code"""
+    
+    def __init__(self):
+        super().__init__('java')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('java')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Java code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._package = ''
+        chunks: List[CodeChunk] = []
+        
+        self._parse_declarations(tree.root_node, chunks)
+        
+        for chunk in chunks:
+            chunk.namespace = self._package
+            chunk.metadata['package'] = self._package
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    def _parse_declarations(self, parent: Node, chunks: List[CodeChunk]):
+        """Top-level declarations: the package and the types of the file"""
+        for node in parent.named_children:
+            if node.type == 'ERROR':
+                self._parse_declarations(node, chunks)
+            elif node.type == 'package_declaration':
+                name = next((c for c in node.named_children if c.type in ('scoped_identifier', 'identifier')), None)
+                if name is not None:
+                    self._package = re.sub(r'\s+', '', self._text(name))
+            elif node.type in TYPE_DECLARATIONS:
+                self._extract_type(node, [], chunks)
+    
+    # ------------------------------------------------------------------
+    # Members
+    # ------------------------------------------------------------------
+    
+    def _parse_body(self, body: Node, parents: List[str], owner: Dict, chunks: List[CodeChunk]):
+        """Extract the members of a class, interface, enum, record or annotation type body"""
+        for node in body.named_children:
+            if node.type in ('ERROR', 'enum_body_declarations'):
+                self._parse_body(node, parents, owner, chunks)
+            elif node.type == 'enum_constant':
+                name = node.child_by_field_name('name')
+                if name is not None:
+                    owner.setdefault('constants', []).append(self._text(name))
+            elif node.type in TYPE_DECLARATIONS:
+                self._extract_type(node, parents, chunks)
+            elif node.type in METHOD_DECLARATIONS:
+                self._extract_method(node, parents, owner, chunks)
+            elif node.type in FIELD_DECLARATIONS:
+                self._extract_field(node, parents, chunks)
+            # Initializer blocks (static { ... } or { ... }) are not chunked
+    
+    def _extract_type(self, node: Node, parents: List[str], chunks: List[CodeChunk]):
+        kind = TYPE_DECLARATIONS[node.type]
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        name = self._text(name_node)
+        annotations, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        
+        type_params = node.child_by_field_name('type_parameters')
+        if type_params is not None:
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        components = node.child_by_field_name('parameters')
+        if kind == 'record' and components is not None:
+            metadata['components'] = self._parse_params(components)
+        
+        # extends / implements / permits clauses
+        for child in node.named_children:
+            if child.type in ('superclass', 'extends_interfaces'):
+                metadata['extends'] = self._type_list(child)
+            elif child.type == 'super_interfaces':
+                metadata['implements'] = self._type_list(child)
+            elif child.type == 'permits':
+                metadata['permits'] = self._type_list(child)
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        chunk = CodeChunk(
+            type=kind,
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._signature(node)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node, annotations)
+        chunks.append(chunk)
+        
+        body = node.child_by_field_name('body')
+        if body is not None:
+            owner = {'kind': kind, 'name': name}
+            before = len(chunks)
+            self._parse_body(body, parents + [name], owner, chunks)
+            if owner.get('constants'):
+                metadata['constants'] = owner['constants']
+            metadata['methods'] = [c.name for c in chunks[before:]
+                                   if c.type == 'method' and c.parent == '.'.join(parents + [name])]
+    
+    def _extract_method(self, node: Node, parents: List[str], owner: Dict, chunks: List[CodeChunk]):
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        name = self._text(name_node)
+        annotations, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        
+        type_params = node.child_by_field_name('type_parameters')
+        if type_params is not None:
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        
+        if node.type in ('constructor_declaration', 'compact_constructor_declaration'):
+            metadata['constructor'] = True
+        else:
+            returns = node.child_by_field_name('type')
+            if returns is not None:
+                metadata['returns'] = normalize_signature(self._text(returns))
+        
+        params = node.child_by_field_name('parameters')
+        if params is not None:
+            metadata['params'] = self._parse_params(params)
+            throws = next((c for c in node.named_children if c.type == 'throws'), None)
+            if throws is not None:
+                metadata['throws'] = [normalize_signature(self._text(t)) for t in throws.named_children
+                                      if t.type not in COMMENTS]
+        else:
+            metadata['params'] = []
+            if node.type == 'compact_constructor_declaration':
+                metadata['compact'] = True
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        if node.child_by_field_name('body') is None and 'abstract' not in modifiers \
+                and owner['kind'] in ('interface', 'annotation'):
+            metadata['abstract'] = True
+        
+        chunk = CodeChunk(
+            type='method',
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._signature(node)),
+            parent_class=parents[-1],
+            parent='.'.join(parents),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node, annotations)
+        chunks.append(chunk)
+    
+    def _extract_field(self, node: Node, parents: List[str], chunks: List[CodeChunk]):
+        type_node = node.child_by_field_name('type')
+        declarators = node.children_by_field_name('declarator')
+        if type_node is None or not declarators:
+            return
+        annotations, modifiers = self._modifiers(node)
+        
+        # Type, then one or more declarators: int a = 1, b[] = {2};
+        names = [self._text(d.child_by_field_name('name')) for d in declarators
+                 if d.child_by_field_name('name') is not None]
+        if not names:
+            return
+        metadata: Dict = {'field_type': normalize_signature(self._text(type_node))}
+        if len(names) > 1:
+            metadata['names'] = names
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        # The signature stops before the first initializer
+        first = declarators[0]
+        value = first.child_by_field_name('value')
+        end = value.start_byte if value is not None else first.end_byte
+        signature = self._source[self._signature_start(node):end].decode('utf8', errors='replace')
+        chunk = CodeChunk(
+            type='field',
+            name=names[0],
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(signature.rstrip().rstrip('=')),
+            parent_class=parents[-1],
+            parent='.'.join(parents),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node, annotations)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _modifiers(self, node: Node):
+        """Annotations and modifier keywords of a declaration"""
+        annotations, modifiers = [], []
+        for child in node.children:
+            if child.type != 'modifiers':
+                continue
+            for modifier in child.children:
+                if modifier.type in ANNOTATIONS:
+                    annotations.append(normalize_signature(self._text(modifier)))
+                elif modifier.type not in COMMENTS:
+                    modifiers.append(self._text(modifier))
+        return annotations, modifiers
+    
+    def _parse_params(self, node: Node) -> List[Dict]:
+        """(name, type) pairs of a parameter or record component list"""
+        params = []
+        for param in node.named_children:
+            if param.type == 'formal_parameter':
+                name = param.child_by_field_name('name')
+                type_node = param.child_by_field_name('type')
+                if name is not None and type_node is not None:
+                    params.append({'name': self._text(name), 'type': normalize_signature(self._text(type_node))})
+            elif param.type == 'spread_parameter':
+                # int... ids: the type runs up to the '...'
+                declarator = next((c for c in param.named_children if c.type == 'variable_declarator'), None)
+                dots = next((c for c in param.children if c.type == '...'), None)
+                type_node = next((c for c in param.named_children if c.type not in ('modifiers',) + COMMENTS), None)
+                name = declarator.child_by_field_name('name') if declarator is not None else None
+                if name is not None and dots is not None and type_node is not None:
+                    type_text = self._source[type_node.start_byte:dots.end_byte].decode('utf8', errors='replace')
+                    params.append({'name': self._text(name), 'type': normalize_signature(type_text)})
+        return params
+    
+    def _type_list(self, clause: Node) -> List[str]:
+        """Types named in an extends, implements or permits clause"""
+        types = []
+        for child in clause.named_children:
+            if child.type == 'type_list':
+                types.extend(normalize_signature(self._text(t)) for t in child.named_children if t.type not in COMMENTS)
+            elif child.type not in COMMENTS:
+                types.append(normalize_signature(self._text(child)))
+        return types
+    
+    def _signature_start(self, node: Node) -> int:
+        """Offset of the first modifier or declaration token after a run of annotations"""
+        for child in node.children:
+            if child.type == 'modifiers':
+                for modifier in child.children:
+                    if modifier.type not in ANNOTATIONS + COMMENTS:
+                        return modifier.start_byte
+            elif child.type not in COMMENTS:
+                return child.start_byte
+        return node.start_byte
+    
+    def _signature(self, node: Node) -> str:
+        """A declaration up to its body (or its ';'), without leading annotations"""
+        body = node.child_by_field_name('body')
+        end = body.start_byte if body is not None else node.end_byte
+        text = self._source[self._signature_start(node):end].decode('utf8', errors='replace')
+        return text.rstrip().rstrip(';')
+    
+    def _attach_doc(self, chunk: CodeChunk, node: Node, annotations: List[str]):
+        """Javadoc directly before the declaration (or its annotations); flags deprecation"""
+        comment = node.prev_sibling
+        if comment is not None and comment.type == 'block_comment' and is_javadoc(self._text(comment)):
+            chunk.doc = javadoc_text(self._text(comment))
+        
+        if any(a == '@Deprecated' or a.startswith('@Deprecated(') for a in annotations) \
+                or (chunk.doc and re.search(r'^@deprecated\b', chunk.doc, re.MULTILINE)):
+            notice = re.search(r'^@deprecated\s+(.*(?:\n(?!@).*)*)', chunk.doc or '', re.MULTILINE)
+            chunk.metadata['deprecated'] = ' '.join(notice.group(1).split()) if notice else 'deprecated'
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
@@ -1,14 +1,22 @@
 #!/usr/bin/env python3
 """
 JavaScript/TypeScript code chunker using tree-sitter
-Supports .js, .ts, .jsx, .tsx files
+Supports .js, .jsx, .ts, .tsx, .mts and .cts files
 """
 
-from typing import List, Optional
+from pathlib import Path
+from typing import List, Optional, Tuple
 from tree_sitter import Language, Parser, Node
 import tree_sitter_javascript
 
-from .base_chunker import BaseChunker, CodeChunk
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+# JSX node types that mark a function as a React component
+JSX_NODE_TYPES = {'jsx_element', 'jsx_self_closing_element', 'jsx_fragment'}
+
+# Extensions parsed with the TypeScript grammars instead of the JavaScript one
+TYPESCRIPT_DIALECTS = {'.ts': 'typescript', '.mts': 'typescript', '.cts': 'typescript', '.tsx': 'tsx'}
 
 
 class JavaScriptChunker(BaseChunker):
@@ -18,26 +26,31 @@ class JavaScriptChunker(BaseChunker):
         super().__init__('javascript')
         JS_LANGUAGE = Language(tree_sitter_javascript.language())
         self.parser = Parser(JS_LANGUAGE)
+        self._ts_parsers = {}
     
     def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
         """Extract all JavaScript code elements"""
-        tree = self.parser.parse(bytes(code, "utf8"))
+        parser, language = self._parser_for(filepath)
+        tree = parser.parse(bytes(code, "utf8"))
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
         chunks = []
         
-        def traverse(node: Node, parent_class: str = ''):
-            # Extract function declarations
-            if node.type == "function_declaration":
-                chunk = self._extract_function(node, code, filepath, parent_class)
+        def traverse(node: Node):
+            # Extract function declarations (components when they render JSX)
+            if node.type in ("function_declaration", "generator_function_declaration"):
+                chunk = self._extract_function(node, code, filepath)
                 if chunk and self._should_include_chunk(chunk):
                     chunks.append(chunk)
+                return
             
             # Extract arrow functions assigned to variables
             elif node.type == "lexical_declaration" or node.type == "variable_declaration":
                 func_chunks = self._extract_arrow_functions(node, code, filepath)
                 chunks.extend([c for c in func_chunks if self._should_include_chunk(c)])
+                return
             
             # Extract classes
-            elif node.type == "class_declaration":
+            elif node.type in ("class_declaration", "abstract_class_declaration"):
                 chunk = self._extract_class(node, code, filepath)
                 if chunk and self._should_include_chunk(chunk):
                     chunks.append(chunk)
@@ -51,87 +64,171 @@ class JavaScriptChunker(BaseChunker):
                             method_chunk = self._extract_method(child, code, filepath, class_name)
                             if method_chunk and self._should_include_chunk(method_chunk):
                                 chunks.append(method_chunk)
+                return
+            
+            # TypeScript-only declarations
+            elif node.type == "interface_declaration":
+                chunk = self._extract_interface(node, code, filepath)
+                if chunk and self._should_include_chunk(chunk):
+                    chunks.append(chunk)
+                return
+            
+            elif node.type == "type_alias_declaration":
+                chunk = self._extract_type_alias(node, code, filepath)
+                if chunk and self._should_include_chunk(chunk):
+                    chunks.append(chunk)
+                return
+            
+            elif node.type == "enum_declaration":
+                chunk = self._extract_enum(node, code, filepath)
+                if chunk and self._should_include_chunk(chunk):
+                    chunks.append(chunk)
+                return
             
             # Continue traversing
             for child in node.children:
-                traverse(child, parent_class)
+                traverse(child)
         
         traverse(tree.root_node)
+        
+        for chunk in chunks:
+            chunk.language = language
         return chunks
     
+    def _parser_for(self, filepath: str) -> Tuple[Parser, str]:
+        """Pick the grammar for a file: TypeScript/TSX by extension, JavaScript (with JSX) otherwise"""
+        dialect = TYPESCRIPT_DIALECTS.get(Path(filepath).suffix.lower())
+        if not dialect:
+            return self.parser, 'javascript'
+        
+        if dialect not in self._ts_parsers:
+            from tree_sitter_language_pack import get_parser
+            self._ts_parsers[dialect] = get_parser(dialect)
+        return self._ts_parsers[dialect], 'typescript'
+    
     def _extract_function(self, node: Node, code: str, filepath: str, parent_class: str = '') -> Optional[CodeChunk]:
         """Extract function declaration"""
-        func_text = self._extract_text(code, node.start_byte, node.end_byte)
+        outer = self._export_node(node)
+        func_text = self._extract_text(code, outer.start_byte, outer.end_byte)
         
         # Get function name
         name_node = node.child_by_field_name("name")
         func_name = self._extract_text(code, name_node.start_byte, name_node.end_byte) if name_node else "anonymous"
         
-        # Get parameters
-        params_node = node.child_by_field_name("parameters")
-        params = self._extract_text(code, params_node.start_byte, params_node.end_byte) if params_node else "()"
+        type_params, params, return_type = self._function_parts(node, code)
+        is_async = any(child.type == "async" for child in node.children)
+        
+        signature = f"function {func_name}{type_params}{params}"
+        if return_type:
+            signature += f": {return_type}"
+        if is_async:
+            signature = "async " + signature
         
-        signature = f"function {func_name}{params}"
+        is_component = self._is_component(func_name, node)
         
         return CodeChunk(
-            type='function',
+            type='component' if is_component else 'function',
             name=func_name,
             content=func_text,
             filepath=filepath,
             language=self.language,
-            line_start=node.start_point[0] + 1,
-            line_end=node.end_point[0] + 1,
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
             signature=signature,
-            parent_class=parent_class
+            parent_class=parent_class,
+            metadata=self._export_metadata(node, {
+                'async': is_async,
+                'type_parameters': type_params,
+                'return_type': return_type,
+            })
         )
     
     def _extract_arrow_functions(self, node: Node, code: str, filepath: str) -> List[CodeChunk]:
         """Extract arrow functions from variable declarations"""
         chunks = []
+        outer = self._export_node(node)
+        keyword = node.child(0).type if node.child(0) else 'const'
         
         for child in node.children:
             if child.type == "variable_declarator":
                 name_node = child.child_by_field_name("name")
                 value_node = child.child_by_field_name("value")
                 
-                if value_node and value_node.type == "arrow_function":
+                if value_node and value_node.type in ("arrow_function", "function_expression", "function"):
                     func_name = self._extract_text(code, name_node.start_byte, name_node.end_byte) if name_node else "anonymous"
-                    func_text = self._extract_text(code, child.start_byte, child.end_byte)
+                    
+                    # Single declarators keep the keyword and export in their content
+                    if len([c for c in node.children if c.type == "variable_declarator"]) == 1:
+                        text_node = outer
+                    else:
+                        text_node = child
+                    func_text = self._extract_text(code, text_node.start_byte, text_node.end_byte)
                     
                     # Get parameters
-                    params_node = value_node.child_by_field_name("parameters")
-                    if not params_node:
-                        params_node = value_node.child_by_field_name("parameter")
-                    params = self._extract_text(code, params_node.start_byte, params_node.end_byte) if params_node else "()"
+                    type_params, params, return_type = self._function_parts(value_node, code)
+                    if not params or params == "()":
+                        param_node = value_node.child_by_field_name("parameter")
+                        if param_node:
+                            params = self._extract_text(code, param_node.start_byte, param_node.end_byte)
+                    
+                    # Declared variable type (e.g. React.FC<Props>)
+                    type_node = child.child_by_field_name("type")
+                    declared_type = self._annotation_text(type_node, code)
                     
-                    signature = f"const {func_name} = {params} => ..."
+                    signature = f"{keyword} {func_name}"
+                    if declared_type:
+                        signature += f": {declared_type}"
+                    signature += f" = {type_params}{params}"
+                    if return_type:
+                        signature += f": {return_type}"
+                    signature += " => ..."
+                    
+                    is_component = self._is_component(func_name, value_node)
                     
                     chunks.append(CodeChunk(
-                        type='function',
+                        type='component' if is_component else 'function',
                         name=func_name,
                         content=func_text,
                         filepath=filepath,
                         language=self.language,
-                        line_start=child.start_point[0] + 1,
-                        line_end=child.end_point[0] + 1,
-                        signature=signature
+                        line_start=text_node.start_point[0] + 1,
+                        line_end=text_node.end_point[0] + 1,
+                        signature=signature,
+                        metadata=self._export_metadata(node, {
+                            'arrow': value_node.type == "arrow_function",
+                            'async': any(c.type == "async" for c in value_node.children),
+                            'type_parameters': type_params,
+                            'return_type': return_type,
+                            'declared_type': declared_type,
+                        })
                     ))
         
         return chunks
     
     def _extract_class(self, node: Node, code: str, filepath: str) -> Optional[CodeChunk]:
         """Extract class declaration"""
-        class_text = self._extract_text(code, node.start_byte, node.end_byte)
+        outer = self._export_node(node)
+        class_text = self._extract_text(code, outer.start_byte, outer.end_byte)
         class_name = self._extract_class_name(node, code)
         
-        # Extract base class (extends)
-        heritage = node.child_by_field_name("heritage")
+        # Extract base class (extends) and implemented interfaces (TypeScript)
         base_class = None
-        if heritage:
+        implements = []
+        for heritage in node.children:
+            if heritage.type != "class_heritage":
+                continue
             for child in heritage.children:
-                if child.type == "identifier":
+                if child.type in ("identifier", "member_expression") and not base_class:
                     base_class = self._extract_text(code, child.start_byte, child.end_byte)
-                    break
+                elif child.type == "extends_clause":
+                    value = child.child_by_field_name("value") or (child.child(1) if child.child_count > 1 else None)
+                    if value:
+                        base_class = self._extract_text(code, value.start_byte, value.end_byte)
+                elif child.type == "implements_clause":
+                    implements.extend(
+                        self._extract_text(code, t.start_byte, t.end_byte)
+                        for t in child.children if t.is_named
+                    )
         
         # Count methods
         method_count = 0
@@ -141,13 +238,19 @@ class JavaScriptChunker(BaseChunker):
                 if child.type == "method_definition":
                     method_count += 1
         
-        signature = f"class {class_name}"
+        type_params = self._node_text(node.child_by_field_name("type_parameters"), code)
+        
+        signature = f"class {class_name}{type_params}"
         if base_class:
             signature += f" extends {base_class}"
+        if implements:
+            signature += f" implements {', '.join(implements)}"
         
         metadata = {
             'base_class': base_class,
-            'method_count': method_count
+            'implements': implements,
+            'method_count': method_count,
+            'abstract': node.type == "abstract_class_declaration",
         }
         
         return CodeChunk(
@@ -156,10 +259,10 @@ class JavaScriptChunker(BaseChunker):
             content=class_text,
             filepath=filepath,
             language=self.language,
-            line_start=node.start_point[0] + 1,
-            line_end=node.end_point[0] + 1,
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
             signature=signature,
-            metadata=metadata
+            metadata=self._export_metadata(node, metadata)
         )
     
     def _extract_method(self, node: Node, code: str, filepath: str, parent_class: str) -> Optional[CodeChunk]:
@@ -170,20 +273,26 @@ class JavaScriptChunker(BaseChunker):
         name_node = node.child_by_field_name("name")
         method_name = self._extract_text(code, name_node.start_byte, name_node.end_byte) if name_node else "anonymous"
         
-        # Get parameters
-        params_node = node.child_by_field_name("parameters")
-        params = self._extract_text(code, params_node.start_byte, params_node.end_byte) if params_node else "()"
+        type_params, params, return_type = self._function_parts(node, code)
         
         # Check if static, async, etc.
         is_static = any(child.type == "static" for child in node.children)
         is_async = any(child.type == "async" for child in node.children)
+        accessibility = None
+        for child in node.children:
+            if child.type == "accessibility_modifier":
+                accessibility = self._extract_text(code, child.start_byte, child.end_byte)
         
         signature = ""
+        if accessibility:
+            signature += f"{accessibility} "
         if is_static:
             signature += "static "
         if is_async:
             signature += "async "
-        signature += f"{method_name}{params}"
+        signature += f"{method_name}{type_params}{params}"
+        if return_type:
+            signature += f": {return_type}"
         
         return CodeChunk(
             type='method',
@@ -195,7 +304,92 @@ class JavaScriptChunker(BaseChunker):
             line_end=node.end_point[0] + 1,
             signature=signature,
             parent_class=parent_class,
-            metadata={'static': is_static, 'async': is_async}
+            parent=parent_class,
+            metadata={
+                'static': is_static,
+                'async': is_async,
+                'accessibility': accessibility,
+                'type_parameters': type_params,
+                'return_type': return_type,
+            }
+        )
+    
+    def _extract_interface(self, node: Node, code: str, filepath: str) -> Optional[CodeChunk]:
+        """Extract TypeScript interface declaration"""
+        outer = self._export_node(node)
+        name = self._extract_class_name(node, code)
+        type_params = self._node_text(node.child_by_field_name("type_parameters"), code)
+        
+        extends = []
+        members = []
+        for child in node.children:
+            if child.type == "extends_type_clause":
+                extends.extend(
+                    self._extract_text(code, t.start_byte, t.end_byte)
+                    for t in child.children if t.is_named
+                )
+        body = node.child_by_field_name("body")
+        if body:
+            for child in body.children:
+                if child.type in ("property_signature", "method_signature"):
+                    member_name = child.child_by_field_name("name")
+                    if member_name:
+                        members.append(self._extract_text(code, member_name.start_byte, member_name.end_byte))
+        
+        signature = f"interface {name}{type_params}"
+        if extends:
+            signature += f" extends {', '.join(extends)}"
+        
+        return CodeChunk(
+            type='interface',
+            name=name,
+            content=self._extract_text(code, outer.start_byte, outer.end_byte),
+            filepath=filepath,
+            language=self.language,
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
+            signature=signature,
+            metadata=self._export_metadata(node, {
+                'extends': extends,
+                'members': members,
+                'type_parameters': type_params,
+            })
+        )
+    
+    def _extract_type_alias(self, node: Node, code: str, filepath: str) -> Optional[CodeChunk]:
+        """Extract TypeScript type alias"""
+        outer = self._export_node(node)
+        name = self._extract_class_name(node, code)
+        type_params = self._node_text(node.child_by_field_name("type_parameters"), code)
+        value = self._node_text(node.child_by_field_name("value"), code)
+        
+        return CodeChunk(
+            type='type',
+            name=name,
+            content=self._extract_text(code, outer.start_byte, outer.end_byte),
+            filepath=filepath,
+            language=self.language,
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
+            signature=f"type {name}{type_params} = {value}" if len(value) < 200 else f"type {name}{type_params}",
+            metadata=self._export_metadata(node, {'type_parameters': type_params})
+        )
+    
+    def _extract_enum(self, node: Node, code: str, filepath: str) -> Optional[CodeChunk]:
+        """Extract TypeScript enum declaration"""
+        outer = self._export_node(node)
+        name = self._extract_class_name(node, code)
+        
+        return CodeChunk(
+            type='enum',
+            name=name,
+            content=self._extract_text(code, outer.start_byte, outer.end_byte),
+            filepath=filepath,
+            language=self.language,
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
+            signature=f"enum {name}",
+            metadata=self._export_metadata(node, {})
         )
     
     def _extract_class_name(self, node: Node, code: str) -> str:
@@ -204,3 +398,50 @@ class JavaScriptChunker(BaseChunker):
         if name_node:
             return self._extract_text(code, name_node.start_byte, name_node.end_byte)
         return "anonymous"
+    
+    def _function_parts(self, node: Node, code: str) -> Tuple[str, str, Optional[str]]:
+        """Return (type parameters, parameters, return type) text for a function-like node"""
+        type_params = self._node_text(node.child_by_field_name("type_parameters"), code)
+        params_node = node.child_by_field_name("parameters")
+        params = self._extract_text(code, params_node.start_byte, params_node.end_byte) if params_node else "()"
+        return_type = self._annotation_text(node.child_by_field_name("return_type"), code)
+        return type_params, params, return_type
+    
+    def _annotation_text(self, node: Optional[Node], code: str) -> Optional[str]:
+        """Text of a TypeScript type annotation without the leading colon"""
+        text = self._node_text(node, code).strip()
+        if text.startswith(':'):
+            text = text[1:].strip()
+        return text or None
+    
+    def _node_text(self, node: Optional[Node], code: str) -> str:
+        """Text of an optional node ('' when missing)"""
+        if not node:
+            return ''
+        return self._extract_text(code, node.start_byte, node.end_byte)
+    
+    def _export_node(self, node: Node) -> Node:
+        """Return the enclosing export statement if the declaration is exported"""
+        if node.parent and node.parent.type == "export_statement":
+            return node.parent
+        return node
+    
+    def _export_metadata(self, node: Node, metadata: dict) -> dict:
+        """Record exported / default-export status"""
+        export = self._export_node(node)
+        metadata['exported'] = export is not node
+        metadata['default_export'] = export is not node and any(c.type == "default" for c in export.children)
+        return metadata
+    
+    def _is_component(self, name: str, node: Node) -> bool:
+        """A React component is a capitalised function that renders JSX"""
+        if not name or not name[0].isupper():
+            return False
+        
+        stack = [node.child_by_field_name("body") or node]
+        while stack:
+            current = stack.pop()
+            if current.type in JSX_NODE_TYPES:
+                return True
+            stack.extend(current.children)
+        return False
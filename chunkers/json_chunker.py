@@ -0,0 +1,144 @@
+#!/usr/bin/env python3
+"""
+JSON chunker splitting configuration files by key
+Supports .json files, with the comments and trailing commas of JSONC
+(tsconfig.json, VS Code settings)
+
+The file is read by a small parser that keeps the span of every key and
+list item, so each chunk is the text of its entry as written; a '//'
+comment block above a key becomes its doc. See config_chunks for what
+becomes a chunk.
+"""
+
+import json
+import re
+from typing import List, Tuple
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error
+from .config_chunks import ConfigChunkBuilder, ConfigNode
+
+
+WHITESPACE = re.compile(r'\s+')
+COMMENT = re.compile(r'//[^\n]*|/\*.*?(?:\*/|$)', re.DOTALL)
+STRING = re.compile(r'"(?:[^"\\\n]|\\.)*"')
+LITERAL = re.compile(r'-?(?:0|[1-9]\d*)(?:\.\d+)?(?:[eE][+-]?\d+)?|true|false|null')
+
+
+class JsonSyntaxError(ValueError):
+    """Raised where the parser cannot go on; carries the offset"""
+    
+    def __init__(self, message: str, offset: int):
+        super().__init__(message)
+        self.offset = offset
+
+
+class JsonChunker(BaseChunker):
+    """Extracts keys, list items and Kubernetes manifests from JSON files"""
+    
+    def __init__(self):
+        super().__init__('json')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract the chunks of the file's top-level value"""
+        parser = JsonParser(code)
+        builder = ConfigChunkBuilder(code, filepath, self.language)
+        try:
+            node, value = parser.document()
+        except JsonSyntaxError as e:
+            self.diagnostics.append(parse_error(builder.line_of(e.offset), str(e)))
+            start = len(code) - len(code.lstrip())
+            node, value = ConfigNode('', start, len(code.rstrip())), None
+        builder.document(node, value)
+        return [c for c in builder.chunks if self._should_include_chunk(c)]
+
+
+class JsonParser:
+    """Parses JSON into its value and the entry tree of its spans"""
+    
+    def __init__(self, code: str):
+        self.code = code
+        self.pos = 0
+        self.comments: List[str] = []  # own-line comments since the last token
+    
+    def document(self) -> Tuple[ConfigNode, object]:
+        """The top-level value's entry tree and value"""
+        self._skip()
+        start = self.pos
+        node, value = self._value('')
+        node.start = start
+        self._skip()
+        if self.pos < len(self.code):
+            raise JsonSyntaxError("unexpected text after the top-level value", self.pos)
+        return node, value
+    
+    def _skip(self):
+        """Skip whitespace and comments, keeping the text of comments on their own line"""
+        while self.pos < len(self.code):
+            match = WHITESPACE.match(self.code, self.pos) or COMMENT.match(self.code, self.pos)
+            if not match:
+                return
+            if match.group(0).startswith('/'):
+                line_start = self.code.rfind('\n', 0, self.pos) + 1
+                if not self.code[line_start:self.pos].strip():
+                    text = match.group(0)
+                    text = text[2:] if text.startswith('//') else text[2:].rsplit('*/', 1)[0]
+                    self.comments.append('\n'.join(line.strip().lstrip('*').strip() for line in text.split('\n')))
+            self.pos = match.end()
+    
+    def _take_doc(self):
+        doc = '\n'.join(self.comments).strip()
+        self.comments = []
+        return doc or None
+    
+    def _value(self, key: str) -> Tuple[ConfigNode, object]:
+        """The value at pos, as a node spanning it"""
+        start = self.pos
+        ch = self.code[start:start + 1]
+        if ch == '{':
+            return self._container(key, '}')
+        if ch == '[':
+            return self._container(key, ']')
+        match = STRING.match(self.code, start) or LITERAL.match(self.code, start)
+        if not match:
+            raise JsonSyntaxError("expected a value", start)
+        self.pos = match.end()
+        return ConfigNode(key, start, self.pos), json.loads(match.group(0))
+    
+    def _container(self, key: str, close: str) -> Tuple[ConfigNode, object]:
+        """An object or array: its entries are the children of its node"""
+        sequence = close == ']'
+        node = ConfigNode(key, self.pos, self.pos, sequence=sequence)
+        value = [] if sequence else {}
+        self.pos += 1
+        while True:
+            self.comments = []
+            self._skip()
+            if self.code.startswith(close, self.pos):
+                break
+            doc = self._take_doc()
+            start = self.pos
+            if sequence:
+                child, item = self._value(f'[{len(value)}]')
+                value.append(item)
+            else:
+                match = STRING.match(self.code, start)
+                if not match:
+                    raise JsonSyntaxError("expected a key", start)
+                name = json.loads(match.group(0))
+                self.pos = match.end()
+                self._skip()
+                if not self.code.startswith(':', self.pos):
+                    raise JsonSyntaxError(f"expected ':' after the key {match.group(0)}", self.pos)
+                self.pos += 1
+                self._skip()
+                child, value[name] = self._value(name)
+            child.start, child.doc = start, doc
+            node.children.append(child)
+            self._skip()
+            if self.code.startswith(',', self.pos):
+                self.pos += 1
+            elif not self.code.startswith(close, self.pos):
+                raise JsonSyntaxError(f"expected ',' or '{close}'", self.pos)
+        self.pos += 1
+        node.end = self.pos
+        return node, value
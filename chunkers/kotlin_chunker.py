@@ -0,0 +1,522 @@
+#!/usr/bin/env python3
+"""
+Kotlin code chunker using tree-sitter for accurate parsing
+Supports .kt and .kts files
+
+Classes, interfaces and objects are walked recursively through their bodies;
+function and initializer bodies are not entered, so the statements of a .kts
+script are skipped too. Extension functions and properties record their
+receiver and are named after it (String.capitalize), and the members of a
+companion object belong to the class that encloses it.
+"""
+
+import re
+from bisect import bisect_right
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+TYPE_DECLARATIONS = ('class_declaration', 'object_declaration', 'companion_object')
+
+COMMENTS = ('line_comment', 'multiline_comment')
+
+# Children that start the next declaration in an ERROR node
+DECLARATION_STARTS = {
+    'modifiers', 'fun', 'class', 'interface', 'object', 'val', 'var', 'typealias',
+    'function_declaration', 'property_declaration', 'type_alias', 'secondary_constructor',
+} | set(TYPE_DECLARATIONS)
+
+
+def kdoc_text(comment: str) -> str:
+    """Text of a KDoc comment without the /** */ markers and leading asterisks"""
+    lines = []
+    for line in comment[3:-2].splitlines():
+        line = line.strip()
+        if line.startswith('*'):
+            line = line[1:]
+            line = line[1:] if line.startswith(' ') else line
+        lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)>\]])|([(<\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def receiver_name(receiver: str) -> str:
+    """Type an extension is declared on, without type arguments or '?' (List<T>? -> List)"""
+    if receiver.startswith('('):
+        return receiver  # a function type: (Int) -> Unit
+    return re.sub(r'<.*>', '', receiver).rstrip('?').strip()
+
+
+def strip_backticks(name: str) -> str:
+    """Name of a `quoted identifier` (test functions are often named `does the thing`)"""
+    return name[1:-1] if name.startswith('`') and name.endswith('`') else name
+
+
+class KotlinChunker(BaseChunker):
+    """Extracts classes, objects, interfaces, functions and properties from Kotlin code"""
+    
+    def __init__(self):
+        super().__init__('kotlin')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('kotlin')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Kotlin code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._package = ''
+        self._kdocs = self._collect_kdocs(tree.root_node)
+        self._kdoc_ends = sorted(self._kdocs)
+        chunks: List[CodeChunk] = []
+        
+        self._parse_members(tree.root_node, [], None, chunks)
+        
+        for chunk in chunks:
+            chunk.namespace = self._package
+            chunk.metadata['package'] = self._package
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Members
+    # ------------------------------------------------------------------
+    
+    def _parse_members(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        """Extract the declarations of a file or of a class, interface, enum or object body"""
+        children = node.children
+        i = 0
+        while i < len(children):
+            child = children[i]
+            if child.type == 'ERROR':
+                self._parse_members(child, parents, owner, chunks)
+            elif child.type == 'package_header':
+                name = next((c for c in child.named_children if c.type == 'identifier'), None)
+                if name is not None and owner is None:
+                    self._package = re.sub(r'\s+', '', self._text(name))
+            elif child.type in TYPE_DECLARATIONS:
+                self._extract_type(child, parents, owner, chunks)
+            elif child.type == 'function_declaration' or child.type == 'secondary_constructor' and owner is not None:
+                self._extract_function(child.children, parents, owner, chunks)
+            elif child.type == 'property_declaration':
+                self._extract_property(child, parents, owner, chunks)
+            elif child.type == 'type_alias':
+                self._extract_alias(child, parents, chunks)
+            elif child.type == 'enum_entry' and owner is not None:
+                name = next((c for c in child.named_children if c.type == 'simple_identifier'), None)
+                if name is not None:
+                    owner.setdefault('constants', []).append(strip_backticks(self._text(name)))
+            elif child.type == 'fun' and node.type == 'ERROR':
+                # A declaration the parser could not finish: fun pending(items: List<T>):
+                start = i - 1 if i > 0 and children[i - 1].type == 'modifiers' else i
+                end = next((j for j in range(i + 1, len(children)) if children[j].type in DECLARATION_STARTS),
+                           len(children))
+                self._extract_function(children[start:end], parents, owner, chunks)
+                i = end
+                continue
+            # Imports, initializer blocks and script statements are not chunked
+            i += 1
+    
+    def _extract_type(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        annotations, modifiers = self._modifiers(node)
+        keywords = [c.type for c in node.children if not c.is_named]
+        companion = node.type == 'companion_object'
+        if companion:
+            modifiers.append('companion')
+        if 'fun' in keywords:
+            modifiers.append('fun')  # fun interface: a single abstract method interface
+        if 'enum' in keywords:
+            modifiers.append('enum')
+        
+        name_node = next((c for c in node.named_children if c.type == 'type_identifier'), None)
+        if name_node is None and not companion:
+            return
+        name = strip_backticks(self._text(name_node)) if name_node is not None else 'Companion'
+        if node.type != 'class_declaration':
+            kind = 'object'
+        elif 'interface' in keywords:
+            kind = 'interface'
+        else:
+            kind = 'enum' if 'enum' in modifiers else 'annotation' if 'annotation' in modifiers else 'class'
+        metadata: Dict = {}
+        
+        type_params = next((c for c in node.named_children if c.type == 'type_parameters'), None)
+        if type_params is not None:
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        
+        # Primary constructor, possibly annotated or with a visibility: private constructor(...)
+        constructor = next((c for c in node.named_children if c.type == 'primary_constructor'), None)
+        if constructor is not None:
+            _, constructor_modifiers = self._modifiers(constructor)
+            if constructor_modifiers:
+                metadata['constructor_modifiers'] = constructor_modifiers
+            metadata['params'] = self._parse_params(constructor)
+        
+        # Supertypes: class A : Base(x), Api by delegate
+        specifiers = []
+        for child in node.named_children:
+            if child.type == 'delegation_specifiers':
+                specifiers.extend(c for c in child.named_children if c.type == 'delegation_specifier')
+            elif child.type == 'delegation_specifier':
+                specifiers.append(child)
+        if specifiers:
+            metadata['supertypes'] = [self._supertype(s) for s in specifiers]
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        if companion:
+            metadata['companion'] = True
+        
+        body = next((c for c in node.named_children if c.type in ('class_body', 'enum_class_body')), None)
+        header_end = body.start_byte if body is not None else node.end_byte
+        chunk = CodeChunk(
+            type=kind,
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(self._signature_start(node.children), header_end)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node.start_byte, annotations)
+        chunks.append(chunk)
+        
+        # Companion members are scoped to the class, not to Class.Companion
+        members_parents = parents if companion and parents else parents + [name]
+        properties = [p['name'] for p in metadata.get('params', []) if p.get('property')]
+        if body is not None:
+            body_owner = {'kind': kind, 'name': name, 'companion': companion}
+            before = len(chunks)
+            self._parse_members(body, members_parents, body_owner, chunks)
+            if body_owner.get('constants'):
+                metadata['constants'] = body_owner['constants']
+            members = [c for c in chunks[before:] if c.parent == '.'.join(members_parents)]
+            metadata['methods'] = [c.name for c in members if c.type == 'method']
+            properties += [c.name for c in members if c.type in ('property', 'constant')]
+        if properties:
+            metadata['properties'] = properties
+    
+    def _extract_function(self, parts: List[Node], parents: List[str], owner: Optional[Dict],
+                          chunks: List[CodeChunk]):
+        """A function or secondary constructor from its children (or the pieces of an unfinished one)"""
+        types = [c.type for c in parts]
+        params = next((c for c in parts if c.type == 'function_value_parameters'), None)
+        if params is None:
+            return
+        params_index = parts.index(params)
+        annotations, modifiers = self._modifiers_of(parts)
+        metadata: Dict = {}
+        receiver = None
+        
+        if 'constructor' in types and owner is not None:
+            name = owner['name']
+            metadata['constructor'] = True
+        else:
+            if 'fun' not in types:
+                return
+            keyword = types.index('fun')
+            name_index = params_index - 1
+            if name_index <= keyword or parts[name_index].type != 'simple_identifier':
+                return
+            name = strip_backticks(self._text(parts[name_index]))
+            between = [c for c in parts[keyword + 1:name_index] if c.type not in COMMENTS]
+            if between and between[0].type == 'type_parameters':
+                metadata['type_params'] = normalize_signature(self._text(between[0]))
+                between = between[1:]
+            # [Receiver.]name -- the receiver may itself be generic or a function type
+            if between and between[-1].type == '.':
+                between = between[:-1]
+            if between:
+                receiver = normalize_signature(self._span(between[0], between[-1]))
+                metadata['receiver'] = receiver
+            
+            # : ReturnType, then a where clause, then '{' or '= expression' (or nothing)
+            if params_index + 1 < len(parts) and parts[params_index + 1].type == ':':
+                # A half-typed 'fun f():' has no return type yet
+                metadata['returns'] = self._type_after(parts, params_index + 1, ('type_constraints', 'function_body'))
+        
+        metadata['params'] = self._parse_params(params)
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        body = next((c for c in parts if c.type in ('function_body', '{')), None)
+        if body is None and owner is not None and owner['kind'] == 'interface' and 'abstract' not in modifiers:
+            metadata['abstract'] = True
+        if owner is not None and owner.get('companion'):
+            metadata['companion'] = True
+        
+        if receiver and owner is None:
+            # Top-level extensions are found by their receiver: String.capitalize
+            parent = receiver_name(receiver)
+            parent_class = parent.rsplit('.', 1)[-1]
+        else:
+            parent = '.'.join(parents) or None
+            parent_class = parents[-1] if parents else None
+        
+        header_end = body.start_byte if body is not None else parts[-1].end_byte
+        chunk = CodeChunk(
+            type='method' if owner is not None else 'function',
+            name=name,
+            content=self._span(parts[0], parts[-1]),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(parts[0]),
+            line_end=self._line_end(parts[-1]),
+            signature=normalize_signature(self._decode(self._signature_start(parts), header_end)),
+            parent_class=parent_class,
+            parent=parent,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, parts[0].start_byte, annotations)
+        chunks.append(chunk)
+    
+    def _extract_property(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        annotations, modifiers = self._modifiers(node)
+        children = node.children
+        metadata: Dict = {}
+        receiver = None
+        
+        kind_index = next((k for k, c in enumerate(children) if c.type in ('binding_pattern_kind', 'val', 'var')), None)
+        declaration = next((c for c in children if c.type in ('variable_declaration', 'multi_variable_declaration')),
+                           None)
+        if kind_index is None or declaration is None:
+            return
+        if self._text(children[kind_index]) == 'var':
+            metadata['mutable'] = True
+        
+        between = [c for c in children[kind_index + 1:children.index(declaration)] if c.type not in COMMENTS]
+        if between and between[0].type == 'type_parameters':
+            metadata['type_params'] = normalize_signature(self._text(between[0]))
+            between = between[1:]
+        if between and between[-1].type == '.':
+            between = between[:-1]
+        if between:
+            receiver = normalize_signature(self._span(between[0], between[-1]))
+            metadata['receiver'] = receiver
+        
+        if declaration.type == 'multi_variable_declaration':
+            # Destructuring: val (key, value) = pair
+            names = [self._variable_name(v) for v in declaration.named_children if v.type == 'variable_declaration']
+            names = [n for n in names if n]
+            if not names:
+                return
+            metadata['names'] = names
+            name = names[0]
+        else:
+            name = self._variable_name(declaration)
+            if not name:
+                return
+            colon = next((k for k, c in enumerate(declaration.children) if c.type == ':'), None)
+            if colon is not None:
+                property_type = self._type_after(declaration.children, colon)
+                if property_type:
+                    metadata['property_type'] = property_type
+        
+        delegate = next((c for c in children if c.type == 'property_delegate'), None)
+        if delegate is not None:
+            # by lazy { ... }: the delegate without its arguments
+            match = re.match(r'by\s+([^({\n]*)', self._text(delegate))
+            if match and match.group(1).strip():
+                metadata['delegate'] = match.group(1).strip()
+        accessors = [c.type[:3] for c in children if c.type in ('getter', 'setter')]
+        if accessors:
+            metadata['accessors'] = accessors
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        if owner is not None and owner.get('companion'):
+            metadata['companion'] = True
+        
+        if receiver and owner is None:
+            parent = receiver_name(receiver)
+            parent_class = parent.rsplit('.', 1)[-1]
+        else:
+            parent = '.'.join(parents) or None
+            parent_class = parents[-1] if parents else None
+        
+        chunk = CodeChunk(
+            type='constant' if 'const' in modifiers else 'property',
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(self._signature_start(children), declaration.end_byte)),
+            parent_class=parent_class,
+            parent=parent,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node.start_byte, annotations)
+        chunks.append(chunk)
+    
+    def _extract_alias(self, node: Node, parents: List[str], chunks: List[CodeChunk]):
+        name = next((c for c in node.named_children if c.type == 'type_identifier'), None)
+        if name is None:
+            return
+        annotations, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        eq = next((k for k, c in enumerate(node.children) if c.type == '='), None)
+        if eq is not None:
+            aliased = self._type_after(node.children, eq)
+            if aliased:
+                metadata['aliased'] = aliased
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        chunk = CodeChunk(
+            type='alias',
+            name=strip_backticks(self._text(name)),
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(self._signature_start(node.children), node.end_byte)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node.start_byte, annotations)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _modifiers(self, node: Node) -> Tuple[List[str], List[str]]:
+        """Annotations and modifier keywords of a declaration"""
+        return self._modifiers_of(node.children)
+    
+    def _modifiers_of(self, children: List[Node]) -> Tuple[List[str], List[str]]:
+        annotations, modifiers = [], []
+        for child in children:
+            if child.type not in ('modifiers', 'parameter_modifiers'):
+                continue
+            for modifier in child.named_children:
+                if modifier.type == 'annotation':
+                    annotations.append(normalize_signature(self._text(modifier)))
+                elif modifier.type not in COMMENTS:
+                    modifiers.append(self._text(modifier))
+        return annotations, modifiers
+    
+    def _parse_params(self, node: Node) -> List[Dict]:
+        """(name, type) pairs of a parameter list; constructor val/var parameters are properties"""
+        params = []
+        modifiers: List[str] = []
+        for child in node.named_children:
+            if child.type == 'class_parameters':
+                params.extend(self._parse_params(child))
+            elif child.type == 'parameter_modifiers':
+                # Modifiers of a function parameter come before it: vararg others: User
+                modifiers = self._modifiers_of([child])[1]
+            elif child.type in ('parameter', 'class_parameter'):
+                name = next((c for c in child.named_children if c.type == 'simple_identifier'), None)
+                colon = next((k for k, c in enumerate(child.children) if c.type == ':'), None)
+                if name is None or colon is None:
+                    modifiers = []
+                    continue
+                param: Dict = {'name': strip_backticks(self._text(name)),
+                               'type': self._type_after(child.children, colon, ('=',))}
+                if child.type == 'class_parameter':
+                    modifiers = self._modifiers(child)[1]
+                    kind = next((c for c in child.children if c.type in ('binding_pattern_kind', 'val', 'var')), None)
+                    if kind is not None:
+                        param['property'] = self._text(kind)
+                if 'vararg' in modifiers:
+                    param['vararg'] = True
+                params.append(param)
+                modifiers = []
+        return params
+    
+    def _supertype(self, specifier: Node) -> str:
+        """A supertype without its constructor arguments or delegate: Entity(id) -> Entity"""
+        target = specifier.named_children[0] if specifier.named_children else specifier
+        if target.type in ('constructor_invocation', 'explicit_delegation') and target.named_children:
+            target = target.named_children[0]
+        return normalize_signature(self._text(target))
+    
+    def _variable_name(self, declaration: Node) -> str:
+        name = next((c for c in declaration.named_children if c.type == 'simple_identifier'), None)
+        return strip_backticks(self._text(name)) if name is not None else ''
+    
+    def _type_after(self, children: List[Node], index: int, stops: Tuple[str, ...] = ()) -> str:
+        """The type following the ':' (or '=') at children[index], up to the first child in stops"""
+        parts = []
+        for child in children[index + 1:]:
+            if child.type in stops:
+                break
+            if child.type not in COMMENTS:
+                parts.append(child)
+        parts = [c for c in parts if c.end_byte > c.start_byte]  # MISSING nodes of an unfinished type
+        return normalize_signature(self._span(parts[0], parts[-1])) if parts else ''
+    
+    def _signature_start(self, children: List[Node]) -> int:
+        """Offset of the first modifier or declaration token after a run of annotations"""
+        for child in children:
+            if child.type == 'modifiers':
+                for modifier in child.named_children:
+                    if modifier.type not in ('annotation',) + COMMENTS:
+                        return modifier.start_byte
+            elif child.type not in COMMENTS:
+                return child.start_byte
+        return children[0].start_byte
+    
+    def _collect_kdocs(self, root: Node) -> Dict[int, str]:
+        """Text of every comment by its end offset; only KDoc comments are kept"""
+        kdocs = {}
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type in COMMENTS:
+                text = self._text(node)
+                if text.startswith('/**') and text != '/**/':
+                    kdocs[node.end_byte] = text
+            else:
+                stack.extend(node.children)
+        return kdocs
+    
+    def _attach_doc(self, chunk: CodeChunk, start: int, annotations: List[str]):
+        """KDoc directly before the declaration (or its annotations); records @Deprecated"""
+        position = bisect_right(self._kdoc_ends, start) - 1
+        if position >= 0:
+            end = self._kdoc_ends[position]
+            if not self._source[end:start].strip():
+                chunk.doc = kdoc_text(self._kdocs[end])
+        
+        deprecated = next((a for a in annotations if re.match(r'@(?:kotlin\.)?Deprecated\b', a)), None)
+        if deprecated:
+            message = re.search(r'"((?:[^"\\]|\\.)*)"', deprecated)
+            chunk.metadata['deprecated'] = message.group(1) if message else 'deprecated'
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
@@ -0,0 +1,434 @@
+#!/usr/bin/env python3
+"""
+Lua code chunker using tree-sitter for accurate parsing
+Supports .lua files
+
+Functions are found in every form Lua defines them: 'function name()',
+'local function name()', 'function M.sub.name()' and 'function M:name()'
+(a method, self implied), 'name = function()' and 'M.name = function()', and
+'name = function()' fields of a table constructor. Functions on a table record
+it (the owning table M, or M.sub) as their parent. A top-level 'return M' or
+'return { ... }' is the module's return table: a module chunk named after the
+file lists its exports, and the functions it exports are marked. Functions
+nested in another function's body stay part of it. Leading -- comments and
+--[[ ]] blocks fill the doc field.
+"""
+
+import re
+from bisect import bisect_right
+from dataclasses import dataclass
+from pathlib import PurePosixPath
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error, tree_sitter_diagnostics
+
+
+# [[, [=[, [==[ ... opening a long string or, after --, a long comment
+LONG_BRACKET = re.compile(r'\[(=*)\[')
+
+# Statements whose blocks are not function bodies: definitions in them still count
+BLOCKS = (
+    'block', 'do_statement', 'if_statement', 'elseif_statement', 'else_statement', 'while_statement',
+    'repeat_statement', 'for_statement',
+)
+
+COMMENTS = ('comment',)
+
+
+@dataclass
+class LuaComment:
+    """A -- comment or a --[[ ]] block"""
+    text: str
+    start: int
+    end: int
+    line_start: int
+    line_end: int
+    own_line: bool  # nothing but whitespace before it on its line
+
+
+def comment_doc(comments: List[LuaComment]) -> str:
+    """Text of a run of -- (or LDoc ---) comments or of a --[[ ]] block, without the markers"""
+    lines = []
+    for comment in comments:
+        long = LONG_BRACKET.match(comment.text, 2)
+        if long:
+            body = comment.text[long.end():]
+            closing = ']' + long.group(1) + ']'
+            body = body[:-len(closing)] if body.endswith(closing) else body
+            lines.extend(line.rstrip() for line in body.strip('\n').splitlines())
+            continue
+        line = comment.text.lstrip('-')
+        line = line[1:] if line.startswith(' ') else line
+        lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)\]])|([(\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def string_value(literal: str) -> Optional[str]:
+    """Value of a short string literal without escapes ('name', "name"), or None"""
+    if len(literal) >= 2 and literal[0] in ('"', "'") and literal[-1] == literal[0] and '\\' not in literal:
+        return literal[1:-1]
+    return None
+
+
+def module_name(filepath: str) -> str:
+    """Name require finds a file by: its stem, or its directory for an init.lua"""
+    path = PurePosixPath(filepath.replace('\\', '/'))
+    if path.stem == 'init' and path.parent.name:
+        return path.parent.name
+    return path.stem
+
+
+class LuaChunker(BaseChunker):
+    """Extracts functions, methods and the module return table from Lua code"""
+    
+    def __init__(self):
+        super().__init__('lua')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('lua')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Lua code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._comments, unexpected = self._collect_extras(tree.root_node)
+        self._comment_ends = [c.end for c in self._comments]
+        self._module = module_name(filepath)
+        self._table_keys: Dict[str, List[str]] = {}  # top-level table -> keys assigned to it
+        self._table_values: Dict[str, Dict[str, str]] = {}  # top-level table -> key -> the name it holds
+        self._table_starts: Dict[str, Node] = {}  # top-level table -> the statement creating it
+        self._returned: Optional[Dict] = None
+        self._unclosed: Dict[int, Tuple[int, str]] = {}  # line of a missing 'end' -> the function it should close
+        chunks: List[CodeChunk] = []
+        
+        self._parse_block(tree.root_node, True, chunks)
+        if self._returned is not None:
+            self._emit_module(chunks)
+        
+        # "missing 'end' for function f" and "unexpected 'end'" say more than tree-sitter's messages
+        for diagnostic in self.diagnostics:
+            line = diagnostic['line']
+            if diagnostic['message'] == "missing 'end'" and line in self._unclosed:
+                diagnostic.update(parse_error(*self._unclosed[line]))
+            elif diagnostic['message'] == 'syntax error' and line in unexpected:
+                diagnostic['message'] = unexpected[line]
+        return [c for c in chunks if c.type == 'module' or self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Statements
+    # ------------------------------------------------------------------
+    
+    def _parse_block(self, node: Node, top: bool, chunks: List[CodeChunk]):
+        """Statements of the file (top) or of a do, if or loop block in it; function bodies are not entered"""
+        for child in node.named_children:
+            if child.type == 'ERROR':
+                self._parse_block(child, top, chunks)
+            elif child.type == 'function_declaration':
+                self._extract_declaration(child, chunks)
+            elif child.type == 'variable_declaration':
+                # local name = ...; a bare local name declares nothing to chunk
+                assignment = next((c for c in child.named_children if c.type == 'assignment_statement'), None)
+                if assignment is not None:
+                    self._assignment(child, assignment, True, top, chunks)
+            elif child.type == 'assignment_statement':
+                self._assignment(child, child, False, top, chunks)
+            elif child.type == 'return_statement':
+                if top:
+                    self._module_return(child, chunks)
+            elif child.type in BLOCKS:
+                self._parse_block(child, False, chunks)
+    
+    def _extract_declaration(self, node: Node, chunks: List[CodeChunk]):
+        """function name() ... end, function a.b.c(), function a:b() and local function a()"""
+        name = node.child_by_field_name('name')
+        method = name is not None and name.type == 'method_index_expression'
+        segments = self._segments(name)
+        if not segments:
+            return
+        table = '.'.join(segments[:-1]) or None
+        if table:
+            self._add_key(table, segments[-1])
+        local = node.children[0].type == 'local'
+        self._emit_function(node, node, node, segments[-1], table, method, local, 'declaration', False, chunks)
+    
+    def _assignment(self, statement: Node, node: Node, local: bool, top: bool, chunks: List[CodeChunk]):
+        """
+        name = ..., local name = ..., a.b.c = ... and a.b["c"] = ...; a function or a
+        table constructor assigned this way is named after its target
+        """
+        targets = self._list(node, 'variable_list', 'attribute')
+        values = self._list(node, 'expression_list')
+        if len(targets) != 1 or not values:
+            return  # a, b = ... defines nothing by name
+        segments = self._segments(targets[0])
+        if not segments:
+            return
+        value = values[0]
+        if top and len(segments) > 1:
+            table = '.'.join(segments[:-1])
+            self._add_key(table, segments[-1])
+            if value.type == 'identifier' and len(values) == 1:
+                self._table_values.setdefault(table, {})[segments[-1]] = self._text(value)  # M.helper = helper
+        if value.type == 'table_constructor':
+            if top and len(segments) == 1:
+                self._table_starts[segments[0]] = statement
+            self._parse_table(value, '.'.join(segments), False, chunks)
+        elif value.type == 'function_definition':
+            self._emit_function(statement, value, statement, segments[-1], '.'.join(segments[:-1]) or None, False,
+                                local, 'assignment', False, chunks)
+    
+    def _parse_table(self, node: Node, name: Optional[str], returned: bool,
+                     chunks: List[CodeChunk]) -> Tuple[List[str], Dict[str, str]]:
+        """
+        Fields of a table constructor (key = value, ["key"] = value) named after what
+        it is assigned to; returns its keys and the names its keys hold
+        """
+        keys: List[str] = []
+        values: Dict[str, str] = {}
+        for field in self._fields(node):
+            key = self._field_key(field)
+            value = field.child_by_field_name('value')
+            if key is None or value is None:
+                continue
+            if key not in keys:
+                keys.append(key)
+            if name is not None:
+                self._add_key(name, key)
+            if value.type == 'function_definition' and name is not None:
+                self._emit_function(field, value, field, key, name, False, False, 'field', returned, chunks)
+            elif value.type == 'table_constructor':
+                self._parse_table(value, f"{name}.{key}" if name is not None else None, returned, chunks)
+            elif value.type == 'identifier' and name is not None:
+                values[key] = self._text(value)
+                self._table_values.setdefault(name, {})[key] = self._text(value)
+        return keys, values
+    
+    def _module_return(self, node: Node, chunks: List[CodeChunk]):
+        """A top-level return: return M, return setmetatable(M, mt), return { ... }"""
+        values = self._list(node, 'expression_list')
+        if not values:
+            return
+        value = values[0]
+        returned = {'node': node, 'table': None, 'constructor': value.type == 'table_constructor',
+                    'keys': [], 'values': {}}
+        if value.type == 'table_constructor':
+            returned['table'] = self._module
+            returned['keys'], returned['values'] = self._parse_table(value, self._module, True, chunks)
+        elif value.type == 'function_call':
+            callee = value.child_by_field_name('name')
+            arguments = value.child_by_field_name('arguments')
+            first = next((c for c in arguments.named_children if c.type not in COMMENTS), None) \
+                if arguments is not None else None
+            if callee is not None and self._text(callee) == 'setmetatable' and first is not None \
+                    and first.type == 'identifier':
+                returned['table'] = self._text(first)
+        elif value.type == 'identifier':
+            returned['table'] = self._text(value)
+        if returned['table'] is not None:
+            self._returned = returned
+    
+    # ------------------------------------------------------------------
+    # Chunks
+    # ------------------------------------------------------------------
+    
+    def _emit_function(self, first: Node, function: Node, last: Node, name: str, table: Optional[str],
+                       method: bool, local: bool, form: str, exported: bool, chunks: List[CodeChunk]):
+        """Chunk of a function from first (its 'local', target or key) to last, named name"""
+        parameters = function.child_by_field_name('parameters')
+        metadata: Dict = {'params': self._parse_params(parameters), 'form': form}
+        if table:
+            metadata['table'] = table
+        else:
+            metadata['scope'] = 'local' if local else 'global'
+        if exported:
+            metadata['exported'] = True
+        end = function.children[-1] if function.children else function
+        if end.is_missing:
+            self._unclosed[self._line(end)] = (self._line(first), f"missing 'end' for function {name}")
+        
+        header_end = parameters.end_byte if parameters is not None else function.start_byte
+        path = table.split('.') if table else []
+        chunk = CodeChunk(
+            type='method' if method else 'function',
+            name=name,
+            content=self._span(first, last),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(last),
+            signature=normalize_signature(self._decode(first.start_byte, header_end)),
+            namespace='.'.join(path[:-1]) or None,
+            parent_class=path[-1] if path else None,
+            parent=table,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, first)
+        chunks.append(chunk)
+    
+    def _emit_module(self, chunks: List[CodeChunk]):
+        """The module chunk of the top-level return, marking the functions it exports"""
+        returned = self._returned
+        table = returned['table']
+        if returned['constructor']:
+            exports, values = returned['keys'], returned['values']
+        else:
+            exports, values = self._table_keys.get(table, []), self._table_values.get(table, {})
+        
+        # The functions of the returned table (those defined in a returned constructor are
+        # marked already) and what its keys hold: return { deepCopy = deepCopy, User = User },
+        # M.helper = helper
+        values = set(values.values())
+        for chunk in chunks:
+            owner = (chunk.parent or '').split('.')[0]
+            if owner == table and not returned['constructor'] or owner in values or not owner and chunk.name in values:
+                chunk.metadata['exported'] = True
+        
+        metadata: Dict = {'exports': list(exports), 'module': self._module}
+        if not returned['constructor']:
+            metadata['table'] = table
+        node = returned['node']
+        chunk = CodeChunk(
+            type='module',
+            name=self._module,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._text(node).split('\n')[0]),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node)
+        if chunk.doc is None and table in self._table_starts:
+            self._attach_doc(chunk, self._table_starts[table])  # --- The inventory API \n local M = {}
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _parse_params(self, node: Optional[Node]) -> List[Dict]:
+        """Parameters of a function: a, b, ..."""
+        params = []
+        for child in (node.children if node is not None else []):
+            if self._text(child) == '...':
+                params.append({'name': '...', 'kind': 'vararg'})
+            elif child.type == 'identifier':
+                params.append({'name': self._text(child)})
+        return params
+    
+    def _segments(self, node: Optional[Node]) -> Optional[List[str]]:
+        """Name segments of a target or a function name: name, a.b.c, a.b["c"], a:b; None for anything else"""
+        if node is None:
+            return None
+        if node.type == 'identifier':
+            return [self._text(node)]
+        if node.type in ('dot_index_expression', 'method_index_expression'):
+            table = self._segments(node.child_by_field_name('table'))
+            key = node.child_by_field_name('field') or node.child_by_field_name('method')
+            return table + [self._text(key)] if table and key is not None else None
+        if node.type == 'bracket_index_expression':
+            table = self._segments(node.child_by_field_name('table'))
+            key = node.child_by_field_name('field')
+            value = string_value(self._text(key)) if key is not None else None
+            return table + [value] if table and value is not None else None  # M["name"]
+        return None
+    
+    def _list(self, node: Node, kind: str, *skipped: str) -> List[Node]:
+        """Named children of the kind list (variable_list, expression_list) under node, or of node itself"""
+        lists = [c for c in node.named_children if c.type == kind]
+        if lists:
+            parts = lists[0].named_children
+        else:
+            # Unwrapped: the targets are before the '=', the values after it (or all of a return's)
+            eq = next((c.start_byte for c in node.children if c.type == '='), None)
+            parts = [c for c in node.named_children if eq is None or (c.end_byte <= eq) == (kind == 'variable_list')]
+        return [c for c in parts if c.type not in COMMENTS + skipped]
+    
+    def _fields(self, node: Node) -> List[Node]:
+        """The fields of a table constructor"""
+        fields = []
+        for child in node.named_children:
+            if child.type == 'field':
+                fields.append(child)
+            elif child.type == 'field_list':
+                fields.extend(self._fields(child))
+        return fields
+    
+    def _field_key(self, field: Node) -> Optional[str]:
+        """Key of a constructor field: name = ..., ["name"] = ...; None for a positional value"""
+        name = field.child_by_field_name('name')
+        if name is None:
+            return None
+        if any(c.type == '[' for c in field.children):
+            return string_value(self._text(name))
+        return self._text(name) if name.type == 'identifier' else None
+    
+    def _add_key(self, table: str, key: str):
+        keys = self._table_keys.setdefault(table, [])
+        if key not in keys:
+            keys.append(key)
+    
+    def _collect_extras(self, root: Node) -> Tuple[List[LuaComment], Dict[int, str]]:
+        """Every comment of the file in source order, and the lines of stray 'end's and 'until's"""
+        comments = []
+        unexpected: Dict[int, str] = {}
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type in COMMENTS:
+                start = node.start_byte
+                line_start = self._source.rfind(b'\n', 0, start) + 1
+                text = self._text(node).rstrip()
+                comments.append(LuaComment(text, start, start + len(text.encode('utf8')), self._line(node),
+                                           self._line(node) + text.count('\n'),
+                                           not self._source[line_start:start].strip()))
+                continue
+            if node.type == 'ERROR' and self._text(node).strip() in ('end', 'until'):
+                unexpected.setdefault(self._line(node), f"unexpected '{self._text(node).strip()}'")
+            stack.extend(node.children)
+        return sorted(comments, key=lambda c: c.start), unexpected
+    
+    def _attach_doc(self, chunk: CodeChunk, node: Node):
+        """-- comment lines or a --[[ ]] block directly above the declaration"""
+        next_line = self._line(node)
+        position = bisect_right(self._comment_ends, node.start_byte) - 1
+        doc_comments = []
+        while position >= 0:
+            comment = self._comments[position]
+            if not comment.own_line or comment.line_end != next_line - 1:
+                break
+            doc_comments.insert(0, comment)
+            if LONG_BRACKET.match(comment.text, 2):
+                break
+            next_line = comment.line_start
+            position -= 1
+        if doc_comments:
+            chunk.doc = comment_doc(doc_comments) or None
+        if chunk.doc and re.search(r'^@deprecated\b', chunk.doc, re.MULTILINE):
+            message = re.search(r'^@deprecated[ \t]*(.*)$', chunk.doc, re.MULTILINE).group(1).strip()
+            chunk.metadata['deprecated'] = message or 'deprecated'
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
@@ -0,0 +1,222 @@
+#!/usr/bin/env python3
+"""
+Markdown chunker splitting documents by heading hierarchy
+Supports .md, .markdown and .mdx files
+
+Each section runs from its heading to the next heading of any level and
+carries its heading path ("Architecture > Auth > Sessions"). Fenced code
+blocks are kept verbatim inside their section (a '#' line in a shell
+snippet is not a heading) and their info-string language is recorded.
+YAML frontmatter is parsed into the metadata of every section.
+"""
+
+import re
+from pathlib import PurePosixPath
+from typing import Dict, List, Optional, Tuple
+
+from .base_chunker import BaseChunker, CodeChunk
+
+
+ATX_HEADING = re.compile(r'^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$')
+SETEXT_UNDERLINE = re.compile(r'^ {0,3}(=+|-+)[ \t]*$')
+FENCE_OPEN = re.compile(r'^ {0,3}(`{3,}|~{3,})[ \t]*([^`\n]*)$')
+HEADING_ID = re.compile(r'[ \t]*\{#[^}]*\}$')
+
+HEADING_SEPARATOR = ' > '
+
+
+class MarkdownChunker(BaseChunker):
+    """Extracts one section per heading from Markdown documents"""
+    
+    def __init__(self):
+        super().__init__('markdown')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract the document's sections in order"""
+        lines = code.split('\n')
+        frontmatter, body_start = _split_frontmatter(lines)
+        headings, fences = _scan_blocks(lines, body_start)
+        
+        # Section boundaries: (first line, heading line count, level, title), 0-based lines
+        sections = []
+        if not headings or headings[0][0] > body_start:
+            title = frontmatter.get('title') if isinstance(frontmatter.get('title'), str) else None
+            sections.append((body_start, 0, 0, title or PurePosixPath(filepath).name))
+        sections.extend(headings)
+        
+        chunks = []
+        path: List[Tuple[int, str]] = []
+        for index, (first, heading_lines, level, title) in enumerate(sections):
+            end = sections[index + 1][0] if index + 1 < len(sections) else len(lines)
+            while end > first and not lines[end - 1].strip():
+                end -= 1
+            
+            if level:
+                while path and path[-1][0] >= level:
+                    path.pop()
+                ancestors = [t for _, t in path]
+                path.append((level, title))
+            else:
+                ancestors = []
+            
+            # Headings directly followed by a sub-heading only live on in their children's paths
+            if end - first <= heading_lines:
+                continue
+            
+            heading_path = HEADING_SEPARATOR.join(ancestors + [title]) if level else ''
+            metadata = {'heading_path': heading_path, 'heading_level': level}
+            blocks = [
+                {'language': language, 'line_start': start + 1, 'line_end': stop + 1}
+                for start, stop, language in fences if first <= start < end
+            ]
+            if blocks:
+                metadata['code_blocks'] = blocks
+                metadata['code_languages'] = sorted({b['language'] for b in blocks if b['language']})
+            if frontmatter:
+                metadata['frontmatter'] = frontmatter
+            
+            chunks.append(CodeChunk(
+                type='section',
+                name=title,
+                content='\n'.join(lines[first:end]),
+                filepath=filepath,
+                language=self.language,
+                line_start=first + 1,
+                line_end=end,
+                signature=heading_path or title,
+                metadata=metadata,
+                # The enclosing headings travel with the text into the embedding
+                context=HEADING_SEPARATOR.join(ancestors) if ancestors else None
+            ))
+        
+        return [c for c in chunks if self._should_include_chunk(c)]
+
+
+def _split_frontmatter(lines: List[str]) -> Tuple[Dict, int]:
+    """Parse a leading '---' YAML block; returns (fields, index of the first body line)"""
+    if not lines or lines[0].strip() != '---':
+        return {}, 0
+    for index in range(1, len(lines)):
+        if lines[index].strip() in ('---', '...'):
+            return parse_frontmatter('\n'.join(lines[1:index])), index + 1
+    return {}, 0
+
+
+def parse_frontmatter(text: str) -> Dict:
+    """
+    YAML frontmatter as a dict, with PyYAML when it is installed, otherwise
+    a reader for the common subset: scalars, inline [a, b] and block '- a' lists
+    """
+    try:
+        import yaml
+    except ImportError:
+        return _parse_simple_yaml(text)
+    try:
+        parsed = yaml.safe_load(text)
+    except yaml.YAMLError:
+        return {}
+    return parsed if isinstance(parsed, dict) else {}
+
+
+def _parse_simple_yaml(text: str) -> Dict:
+    """Top-level 'key: value' pairs and lists; nested mappings are skipped"""
+    fields: Dict = {}
+    key = None
+    for line in text.split('\n'):
+        if not line.strip() or line.lstrip().startswith('#'):
+            continue
+        item = re.match(r'^\s*-\s+(.*)$', line)
+        if item and key is not None and isinstance(fields.get(key), list):
+            fields[key].append(_yaml_scalar(item.group(1)))
+            continue
+        pair = re.match(r'^([\w.-]+)\s*:\s*(.*)$', line)
+        if not pair:
+            continue
+        key, value = pair.group(1), pair.group(2).strip()
+        if not value:
+            fields[key] = []  # a block list (or a nested mapping, left empty)
+        elif value.startswith('[') and value.endswith(']'):
+            fields[key] = [_yaml_scalar(v) for v in value[1:-1].split(',') if v.strip()]
+        else:
+            fields[key] = _yaml_scalar(value)
+    return fields
+
+
+def _yaml_scalar(value: str):
+    """A plain or quoted YAML scalar"""
+    value = value.strip()
+    if len(value) >= 2 and value[0] == value[-1] and value[0] in '"\'':
+        return value[1:-1]
+    value = re.sub(r'\s+#.*$', '', value)
+    lowered = value.lower()
+    if lowered in ('true', 'yes'):
+        return True
+    if lowered in ('false', 'no'):
+        return False
+    if lowered in ('null', '~', ''):
+        return None
+    if re.fullmatch(r'-?\d+', value):
+        return int(value)
+    if re.fullmatch(r'-?\d+\.\d*', value):
+        return float(value)
+    return value
+
+
+def _scan_blocks(lines: List[str], start: int) -> Tuple[List[Tuple[int, int, int, str]], List[Tuple[int, int, str]]]:
+    """
+    Find headings and fenced code blocks, skipping everything inside fences
+    
+    Returns:
+        Headings as (first line, line count, level, title) and fences as
+        (first line, last line, language), all 0-based
+    """
+    headings = []
+    fences = []
+    index = start
+    while index < len(lines):
+        line = lines[index]
+        fence = FENCE_OPEN.match(line)
+        if fence:
+            marker = fence.group(1)
+            language = _fence_language(fence.group(2))
+            close = re.compile(r'^ {0,3}' + re.escape(marker[0]) + '{' + str(len(marker)) + r',}[ \t]*$')
+            stop = index + 1
+            while stop < len(lines) and not close.match(lines[stop]):
+                stop += 1
+            stop = min(stop, len(lines) - 1)  # an unclosed fence runs to the end of the file
+            fences.append((index, stop, language))
+            index = stop + 1
+            continue
+        
+        atx = ATX_HEADING.match(line)
+        if atx:
+            headings.append((index, 1, len(atx.group(1)), _heading_title(atx.group(2) or '')))
+            index += 1
+            continue
+        
+        # Setext: a paragraph line underlined with === (level 1) or --- (level 2)
+        if (index + 1 < len(lines) and line.strip() and not line.startswith(('    ', '\t'))
+                and not re.match(r'^\s*(?:[-*+>]|\d+[.)])\s', line)
+                and (index == start or not lines[index - 1].strip())):
+            underline = SETEXT_UNDERLINE.match(lines[index + 1])
+            if underline:
+                level = 1 if underline.group(1)[0] == '=' else 2
+                headings.append((index, 2, level, _heading_title(line)))
+                index += 2
+                continue
+        index += 1
+    return headings, fences
+
+
+def _heading_title(text: str) -> str:
+    """Heading text without an explicit {#id} and code/emphasis markers"""
+    text = HEADING_ID.sub('', text.strip())
+    text = re.sub(r'`([^`]*)`', r'\1', text)
+    text = re.sub(r'(\*\*|__)(.+?)\1', r'\2', text)
+    return text.strip() or 'untitled'
+
+
+def _fence_language(info: str) -> Optional[str]:
+    """Language of a fence info string: 'go', 'python title="x"', '{.rust}'"""
+    match = re.match(r'\{?\.?([\w+#.-]+)', info.strip())
+    return match.group(1).lower() if match else None
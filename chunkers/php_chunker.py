@@ -0,0 +1,433 @@
+#!/usr/bin/env python3
+"""
+PHP code chunker using tree-sitter for accurate parsing
+Supports .php files
+
+tree-sitter-php parses templates too: the HTML around the <?php ... ?> (and
+<?= ... ?>) regions is text, so markup is never mistaken for code.
+Functions, classes, interfaces, traits and enums are extracted with their
+methods, properties and constants; function bodies are not entered. Members
+record their visibility (public when none is written) and modifiers, classes
+the traits they use, and every chunk the namespace it is declared in. PHPDoc
+comments (/** ... */) fill the doc field.
+"""
+
+import re
+from bisect import bisect_right
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error, tree_sitter_diagnostics
+
+
+VISIBILITIES = {'public', 'protected', 'private'}
+
+# Declaration nodes and the chunk type they become
+TYPE_DECLARATIONS = {
+    'class_declaration': 'class',
+    'interface_declaration': 'interface',
+    'trait_declaration': 'trait',
+    'enum_declaration': 'enum',
+}
+
+PARAMETERS = ('simple_parameter', 'variadic_parameter', 'property_promotion_parameter')
+
+
+def phpdoc_text(comment: str) -> str:
+    """Text of a PHPDoc comment without the /** */ markers and leading asterisks"""
+    lines = []
+    for line in comment[3:-2].splitlines():
+        line = line.strip()
+        if line.startswith('*'):
+            line = line[1:]
+            line = line[1:] if line.startswith(' ') else line
+        lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)\]])|([(\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def is_modifier(node: Node) -> bool:
+    """public, static, abstract, final, readonly, var (not the & of a by-reference function)"""
+    return node.type.endswith('_modifier') and node.type != 'reference_modifier'
+
+
+class PhpChunker(BaseChunker):
+    """Extracts functions, classes, interfaces, traits, enums, methods, properties and constants from PHP code"""
+    
+    def __init__(self):
+        super().__init__('php')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('php')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all PHP code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._phpdocs = self._collect_phpdocs(tree.root_node)
+        self._comment_ends = sorted(self._phpdocs)
+        self._unclosed: Dict[int, int] = {}  # line of a missing '}' -> line of its '{'
+        chunks: List[CodeChunk] = []
+        
+        self._parse_statements(tree.root_node.children, '', chunks)
+        
+        # Report the brace that was left open, as the line to fix
+        for diagnostic in self.diagnostics:
+            line = self._unclosed.get(diagnostic['line'])
+            if line and diagnostic['message'] == "missing '}'":
+                diagnostic.update(parse_error(line, "unclosed '{'"))
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Top-level statements
+    # ------------------------------------------------------------------
+    
+    def _parse_statements(self, children: List[Node], namespace: str, chunks: List[CodeChunk]):
+        """Extract the declarations among the statements of a file or namespace block"""
+        for child in children:
+            if child.type == 'ERROR':
+                self._parse_statements(child.children, namespace, chunks)
+            elif child.type == 'namespace_definition':
+                # namespace A\B; applies up to the next one, namespace A\B { ... } to its block
+                name_node = child.child_by_field_name('name')
+                name = self._text(name_node) if name_node is not None else ''
+                body = child.child_by_field_name('body')
+                if body is not None:
+                    self._note_unclosed(body)
+                    self._parse_statements(body.children, name, chunks)
+                else:
+                    namespace = name
+            elif child.type in TYPE_DECLARATIONS:
+                self._extract_type(child, namespace, chunks)
+            elif child.type == 'function_definition':
+                self._extract_function(child, namespace, None, chunks)
+            elif child.type == 'const_declaration':
+                self._extract_constants(child, namespace, None, chunks)
+            # Anything else (code, a closure, a conditional block) is not chunked
+    
+    # ------------------------------------------------------------------
+    # Declarations
+    # ------------------------------------------------------------------
+    
+    def _extract_type(self, node: Node, namespace: str, chunks: List[CodeChunk]):
+        kind = TYPE_DECLARATIONS[node.type]
+        name_node = node.child_by_field_name('name')
+        body = node.child_by_field_name('body')
+        if name_node is None:
+            return
+        name = self._text(name_node)
+        if body is None:
+            self.diagnostics.append(parse_error(self._line(node), f"{kind} {name} has no body"))
+            return
+        
+        attributes, modifiers = self._modifiers(node)
+        metadata: Dict = {'namespace': namespace} if namespace else {}
+        children = node.children
+        for i, child in enumerate(children):
+            if child.type == ':' and kind == 'enum' and i + 1 < len(children):
+                metadata['backed_by'] = self._text(children[i + 1])
+            elif child.type == 'base_clause':
+                # An interface may extend several; a class only one
+                bases = self._names(child)
+                if bases:
+                    metadata['extends'] = bases if kind == 'interface' else bases[0]
+            elif child.type == 'class_interface_clause':
+                metadata['implements'] = self._names(child)
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        header = [c for c in children[:children.index(body)] if c.type != 'attribute_list']
+        chunk = CodeChunk(
+            type=kind,
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._span(header[0], header[-1])),
+            namespace=namespace or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+        
+        owner = {'kind': kind, 'name': name}
+        before = len(chunks)
+        self._note_unclosed(body)
+        self._parse_body(body, namespace, owner, chunks)
+        members = [c for c in chunks[before:] if c.parent == name]
+        for key in ('traits', 'cases'):
+            if owner.get(key):
+                metadata[key] = owner[key]
+        metadata['methods'] = [c.name for c in members if c.type == 'method']
+        properties = [n for c in members if c.type == 'property'
+                      for n in c.metadata.get('names', [c.name])] + owner.get('promoted', [])
+        if properties:
+            metadata['properties'] = properties
+        constants = [n for c in members if c.type == 'const' for n in c.metadata.get('names', [c.name])]
+        if constants:
+            metadata['constants'] = constants
+    
+    def _parse_body(self, body: Node, namespace: str, owner: Dict, chunks: List[CodeChunk]):
+        """Extract the members of a class, interface, trait or enum body"""
+        for child in body.children:
+            if child.type == 'ERROR':
+                self._parse_body(child, namespace, owner, chunks)
+            elif child.type == 'use_declaration':
+                # use Loggable, Cacheable { log as protected; }
+                owner.setdefault('traits', []).extend(
+                    self._text(c).lstrip('\\') for c in child.named_children if c.type in ('name', 'qualified_name'))
+            elif child.type == 'enum_case':
+                name = child.child_by_field_name('name')
+                if name is not None:
+                    owner.setdefault('cases', []).append(self._text(name))
+            elif child.type == 'method_declaration':
+                self._extract_function(child, namespace, owner, chunks)
+            elif child.type == 'property_declaration':
+                self._extract_property(child, namespace, owner, chunks)
+            elif child.type == 'const_declaration':
+                self._extract_constants(child, namespace, owner, chunks)
+    
+    def _extract_property(self, node: Node, namespace: str, owner: Dict, chunks: List[CodeChunk]):
+        """[modifiers] [type] $name [= default] [, $other ...];"""
+        attributes, modifiers = self._modifiers(node)
+        elements = [c for c in node.named_children if c.type == 'property_element']
+        names = []
+        for element in elements:
+            variable = next((c for c in element.named_children if c.type == 'variable_name'), None)
+            if variable is not None:
+                names.append(self._text(variable).lstrip('$'))
+        if not names:
+            return
+        
+        metadata: Dict = self._member_metadata(namespace, attributes, modifiers)
+        type_nodes = [c for c in node.children[:node.children.index(elements[0])]
+                      if c.is_named and c.type != 'attribute_list' and not is_modifier(c)]
+        if type_nodes:
+            metadata['property_type'] = normalize_signature(self._span(type_nodes[0], type_nodes[-1]))
+        if len(names) > 1:
+            metadata['names'] = names
+        
+        first_variable = next(c for c in elements[0].named_children if c.type == 'variable_name')
+        chunk = CodeChunk(
+            type='property',
+            name=names[0],
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._span(self._signature_start(node), first_variable)),
+            namespace=namespace or None,
+            parent_class=owner['name'],
+            parent=owner['name'],
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+    
+    def _extract_function(self, node: Node, namespace: str, owner: Optional[Dict], chunks: List[CodeChunk]):
+        name_node = node.child_by_field_name('name')
+        params = node.child_by_field_name('parameters')
+        if name_node is None or params is None:
+            return
+        name = self._text(name_node)
+        body = node.child_by_field_name('body')
+        attributes, modifiers = self._modifiers(node)
+        
+        metadata: Dict = self._member_metadata(namespace, attributes, modifiers) if owner else (
+            {'namespace': namespace} if namespace else {})
+        metadata['params'] = self._parse_params(params, owner)
+        returns = node.child_by_field_name('return_type')
+        header_end = params
+        if returns is not None:
+            metadata['returns'] = normalize_signature(self._text(returns))
+            header_end = returns
+        if any(c.type == 'reference_modifier' for c in node.children):
+            metadata['by_reference'] = True
+        if owner is not None:
+            if name.lower() == '__construct':
+                metadata['constructor'] = True
+            if body is None:
+                metadata['abstract'] = True
+        if body is not None:
+            self._note_unclosed(body)
+        
+        chunk = CodeChunk(
+            type='method' if owner else 'function',
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._span(self._signature_start(node), header_end)),
+            namespace=namespace or None,
+            parent_class=owner['name'] if owner else None,
+            parent=owner['name'] if owner else None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+        if owner is not None and metadata.get('constructor'):
+            owner.setdefault('promoted', []).extend(p['name'] for p in metadata['params'] if p.get('promoted'))
+    
+    def _extract_constants(self, node: Node, namespace: str, owner: Optional[Dict], chunks: List[CodeChunk]):
+        """const A = 1, B = 2; at the top level or in a class (typed class constants: const int A = 1)"""
+        names = []
+        for element in (c for c in node.named_children if c.type == 'const_element'):
+            name = next((c for c in element.named_children if c.type == 'name'), None)
+            if name is not None:
+                names.append(self._text(name))
+        if not names:
+            return
+        
+        attributes, modifiers = self._modifiers(node)
+        metadata: Dict = self._member_metadata(namespace, attributes, modifiers) if owner else (
+            {'namespace': namespace} if namespace else {})
+        if len(names) > 1:
+            metadata['names'] = names
+        last = node.children[-1]
+        if last.type == ';' and len(node.children) > 1:
+            last = node.children[-2]
+        chunk = CodeChunk(
+            type='const',
+            name=names[0],
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._span(self._signature_start(node), last)),
+            namespace=namespace or None,
+            parent_class=owner['name'] if owner else None,
+            parent=owner['name'] if owner else None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _modifiers(self, node: Node) -> Tuple[List[str], List[str]]:
+        """Attributes (#[...]) and modifiers of a declaration"""
+        attributes, modifiers = [], []
+        for child in node.children:
+            if child.type == 'attribute_list':
+                groups = [c for c in child.named_children if c.type == 'attribute_group'] or [child]
+                attributes.extend(normalize_signature(self._text(g)) for g in groups)
+            elif is_modifier(child):
+                modifiers.append(self._text(child).lower())
+        return attributes, modifiers
+    
+    def _member_metadata(self, namespace: str, attributes: List[str], modifiers: List[str]) -> Dict:
+        """Namespace, visibility (public unless declared) and modifiers of a class member"""
+        metadata: Dict = {'namespace': namespace} if namespace else {}
+        metadata['visibility'] = next((m for m in modifiers if m in VISIBILITIES), 'public')
+        others = [m for m in modifiers if m not in VISIBILITIES and m != 'var']
+        if others:
+            metadata['modifiers'] = others
+        if 'static' in modifiers:
+            metadata['static'] = True
+        if attributes:
+            metadata['attributes'] = attributes
+        return metadata
+    
+    def _parse_params(self, node: Node, owner: Optional[Dict]) -> List[Dict]:
+        """Parameters of a function: name (without the $), type, default, and how they are passed"""
+        params = []
+        for child in node.named_children:
+            if child.type not in PARAMETERS:
+                continue
+            name = child.child_by_field_name('name')
+            if name is not None and name.type == 'by_ref':
+                name = next((c for c in name.named_children if c.type == 'variable_name'), None)
+            if name is None:
+                continue
+            param: Dict = {'name': self._text(name).lstrip('$')}
+            param_type = child.child_by_field_name('type')
+            if param_type is not None:
+                param['type'] = normalize_signature(self._text(param_type))
+            if child.type == 'variadic_parameter':
+                param['variadic'] = True
+            if any(c.type in ('reference_modifier', 'by_ref') for c in child.children):
+                param['by_reference'] = True
+            default = child.child_by_field_name('default_value')
+            if default is not None:
+                param['default'] = normalize_signature(self._text(default))
+            attributes, modifiers = self._modifiers(child)
+            visibility = next((m for m in modifiers if m in VISIBILITIES), None)
+            if owner is not None and (visibility or 'readonly' in modifiers):
+                # Constructor property promotion: __construct(private string $name)
+                param['promoted'] = visibility or 'public'
+                if 'readonly' in modifiers:
+                    param['readonly'] = True
+            if attributes:
+                param['attributes'] = attributes
+            params.append(param)
+        return params
+    
+    def _names(self, clause: Node) -> List[str]:
+        """Names listed by an extends or implements clause, without a leading backslash"""
+        return [self._text(c).lstrip('\\') for c in clause.named_children if c.type in ('name', 'qualified_name')]
+    
+    def _signature_start(self, node: Node) -> Node:
+        """The first child of a declaration after its attributes"""
+        return next((c for c in node.children if c.type != 'attribute_list'), node)
+    
+    def _note_unclosed(self, body: Node):
+        """Remember a body whose '}' tree-sitter had to make up, for its diagnostic"""
+        if body.children and body.children[-1].is_missing:
+            self._unclosed[self._line(body.children[-1])] = self._line(body)
+    
+    def _collect_phpdocs(self, root: Node) -> Dict[int, str]:
+        """Text of every comment by its end offset; only PHPDoc comments are kept"""
+        phpdocs = {}
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type == 'comment':
+                text = self._text(node)
+                phpdocs[node.end_byte] = text if text.startswith('/**') and text != '/**/' else ''
+            else:
+                stack.extend(node.children)
+        return phpdocs
+    
+    def _attach_doc(self, chunk: CodeChunk, node: Node):
+        """PHPDoc directly before the declaration (or its attributes); flags deprecation"""
+        position = bisect_right(self._comment_ends, node.start_byte) - 1
+        if position >= 0:
+            end = self._comment_ends[position]
+            if self._phpdocs[end] and not self._source[end:node.start_byte].strip():
+                chunk.doc = phpdoc_text(self._phpdocs[end])
+        
+        if chunk.doc and re.search(r'^@deprecated\b', chunk.doc, re.MULTILINE):
+            notice = re.search(r'^@deprecated[ \t]*(.*(?:\n(?!@).*)*)', chunk.doc, re.MULTILINE)
+            chunk.metadata['deprecated'] = ' '.join(notice.group(1).split()) or 'deprecated'
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
@@ -0,0 +1,648 @@
+#!/usr/bin/env python3
+"""
+Protocol Buffers chunker using tree-sitter for accurate parsing
+Supports .proto files (proto2, proto3 and editions)
+
+Messages, enums, services and extend blocks become chunks, nested messages
+and enums belonging to the message they are declared in (User.Address), and
+every rpc of a service is a chunk of its own (UserService.CreateUser) so a
+single method of an API contract can be retrieved. Messages record their
+fields with their types, numbers and labels, enums their values, rpcs their
+request and response types and any google.api.http binding. The comment
+directly above a declaration is its doc, as protoc attaches it; the file's
+package is the namespace of every chunk, and its syntax and go_package
+option are in every chunk's metadata.
+"""
+
+import re
+from bisect import bisect_right
+from dataclasses import dataclass
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error, tree_sitter_diagnostics
+
+
+# Declarations that open a body of their own
+DECLARATION_TYPES = ('message', 'enum', 'service', 'extend')
+
+# Fields of a message, oneof or extend block
+FIELD_TYPES = ('field', 'oneof_field', 'map_field', 'group')
+
+# Field labels
+LABELS = ('optional', 'repeated', 'required')
+
+# HTTP methods of a google.api.http rule (custom is {kind, path})
+HTTP_METHODS = ('get', 'put', 'post', 'delete', 'patch')
+
+# Option naming the HTTP binding of an rpc (grpc-gateway, Google API transcoding)
+HTTP_OPTION = '(google.api.http)'
+
+IDENTIFIER = re.compile(r'[A-Za-z_]\w*$')
+
+
+@dataclass
+class ProtoComment:
+    """A comment with its line span"""
+    text: str
+    start: int
+    end: int
+    line_start: int
+    line_end: int
+
+
+def match_brace(values: List[str], index: int) -> int:
+    """Return the index of the bracket closing values[index] (or the last index if unbalanced)"""
+    pairs = {'(': ')', '[': ']', '{': '}', '<': '>'}
+    opening = values[index]
+    closing = pairs[opening]
+    depth = 0
+    for i in range(index, len(values)):
+        if values[i] == opening:
+            depth += 1
+        elif values[i] == closing:
+            depth -= 1
+            if depth == 0:
+                return i
+    return len(values) - 1
+
+
+def comment_text(comments: List[ProtoComment]) -> str:
+    """Text of a run of // lines or a /* */ comment, without the comment markers"""
+    lines = []
+    for comment in comments:
+        if comment.text.startswith('//'):
+            line = comment.text[2:]
+            lines.append((line[1:] if line.startswith(' ') else line).rstrip())
+            continue
+        for line in comment.text[2:-2].splitlines():
+            line = line.strip()
+            if line.startswith('*'):
+                line = line[1:]
+                line = line[1:] if line.startswith(' ') else line
+            lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)>\]])|([(<\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def unquote(value: str) -> str:
+    """A string literal's text ("v1" -> v1); other constants as written"""
+    if len(value) >= 2 and value[0] in '"\'' and value[-1] == value[0]:
+        return re.sub(r'\\(.)', r'\1', value[1:-1])
+    return value
+
+
+def parse_number(text: str):
+    """An integer constant as a number (decimal, 0x hex or 0 octal); anything else (max) as written"""
+    try:
+        if text.lstrip('-').lower().startswith('0x'):
+            return int(text, 16)
+        if len(text.lstrip('-')) > 1 and text.lstrip('-').startswith('0'):
+            return int(text, 8)
+        return int(text)
+    except ValueError:
+        return text
+
+
+def http_rule(rule: Dict) -> Optional[Dict]:
+    """
+    The binding of a google.api.http option ({post: "/v1/sessions" body: "*"}):
+    {'method', 'path', 'body'}, with the rule's additional_bindings
+    """
+    if not isinstance(rule, dict):
+        return None
+    binding: Dict = {}
+    method = next((m for m in HTTP_METHODS if isinstance(rule.get(m), str)), None)
+    if method:
+        binding = {'method': method.upper(), 'path': rule[method]}
+    elif isinstance(rule.get('custom'), dict):
+        binding = {'method': rule['custom'].get('kind', ''), 'path': rule['custom'].get('path', '')}
+    if not binding:
+        return None
+    if isinstance(rule.get('body'), str):
+        binding['body'] = rule['body']
+    additional = rule.get('additional_bindings')
+    additional = additional if isinstance(additional, list) else [additional] if additional else []
+    bindings = [b for b in (http_rule(a) for a in additional) if b]
+    if bindings:
+        binding['additional_bindings'] = bindings
+    return binding
+
+
+class ProtobufChunker(BaseChunker):
+    """Extracts messages, enums, services and their rpcs from .proto files"""
+    
+    def __init__(self):
+        super().__init__('protobuf')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('proto')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all proto declarations"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._comments = self._collect_comments(tree.root_node)
+        self._comment_ends = [c.end for c in self._comments]
+        self._unclosed: Dict[int, Tuple[int, str]] = {}  # line of a missing '}' -> its '{' and declaration
+        self._file: Dict = {}
+        chunks: List[CodeChunk] = []
+        
+        self._parse_statements(tree.root_node, [], None, chunks)
+        
+        # Report the declaration that was left open, at its brace
+        for diagnostic in self.diagnostics:
+            unclosed = self._unclosed.get(diagnostic['line'])
+            if unclosed and diagnostic['message'] == "missing '}'":
+                diagnostic.update(parse_error(unclosed[0], f"missing '}}' of {unclosed[1]}"))
+        
+        package = self._file.get('package')
+        file_metadata = {key: self._file[key] for key in ('syntax', 'edition', 'package', 'go_package')
+                         if key in self._file}
+        for chunk in chunks:
+            chunk.namespace = package
+            chunk.metadata.update(file_metadata)
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Statements
+    # ------------------------------------------------------------------
+    
+    def _parse_statements(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        """Extract the statements of a file or of a declaration's body"""
+        for child in node.named_children:
+            if child.type == 'ERROR':
+                self._parse_statements(child, parents, owner, chunks)
+            elif child.type in DECLARATION_TYPES:
+                self._extract_declaration(child, parents, chunks)
+            elif child.type == 'option':
+                name, value = self._parse_option(child)
+                if name and owner is not None:
+                    owner.setdefault('options', {})[name] = value
+                elif name == 'go_package' and isinstance(value, str):
+                    self._file['go_package'] = value
+            elif owner is None:
+                if child.type in ('syntax', 'edition'):
+                    value = next((c for c in child.children if c.type not in (child.type, '=', ';')), None)
+                    if value is not None:
+                        self._file[child.type] = unquote(self._text(value))
+                elif child.type == 'package':
+                    name = next((c for c in child.named_children if c.type != 'comment'), None)
+                    if name is not None:
+                        self._file['package'] = re.sub(r'\s+', '', self._text(name))
+            elif child.type in ('reserved', 'extensions'):
+                owner.setdefault(child.type, []).extend(self._ranges(child))
+            elif child.type == 'rpc' and owner['kind'] == 'service':
+                self._extract_rpc(child, parents, chunks)
+            elif child.type == 'enum_field' and owner['kind'] == 'enum':
+                self._parse_enum_value(child, owner)
+            elif child.type == 'oneof' and owner['kind'] in ('message', 'extend'):
+                name = next((c for c in child.children if c.type == 'identifier'), None)
+                oneof = {'name': self._text(name) if name is not None else '', 'fields': []}
+                owner.setdefault('oneofs', []).append(oneof)
+                fields = owner.setdefault('fields', [])
+                before = len(fields)
+                self._parse_statements(child, parents, owner, chunks)
+                for field in fields[before:]:
+                    field['oneof'] = oneof['name']
+                    oneof['fields'].append(field['name'])
+            elif child.type in FIELD_TYPES and owner['kind'] in ('message', 'extend'):
+                # A field of a message or extend block; proto2 groups are fields with a body
+                self._parse_field(child, parents, owner, chunks)
+    
+    def _extract_declaration(self, node: Node, parents: List[str], chunks: List[CodeChunk]):
+        """A message, enum, service or extend block, and everything declared in it"""
+        kind = node.type
+        body = self._body(node)
+        brace = next((c for c in body.children if c.type == '{'), None)
+        name_node = next((c for c in node.named_children if c is not body and c.type != 'comment'), None)
+        if brace is None or name_node is None:
+            return
+        name = re.sub(r'\s+', '', self._text(name_node))
+        self._note_unclosed(body, brace, kind)
+        owner: Dict = {'kind': kind, 'name': name}
+        
+        chunk = CodeChunk(
+            type=kind,
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(node.start_byte, brace.start_byte)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata={}
+        )
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+        
+        # Extensions are fields of the extended message, not of a message named after it
+        members_parents = parents if kind == 'extend' else parents + [name]
+        before = len(chunks)
+        self._parse_statements(body, members_parents, owner, chunks)
+        
+        metadata = chunk.metadata
+        if kind == 'extend':
+            metadata['extendee'] = name
+        for key in ('fields', 'oneofs', 'values', 'reserved', 'extensions', 'options'):
+            if owner.get(key):
+                metadata[key] = owner[key]
+        if kind == 'message':
+            nested = [c.name for c in chunks[before:] if c.parent == '.'.join(members_parents)]
+            if nested:
+                metadata['nested'] = nested
+        if kind == 'service':
+            metadata['methods'] = [c.name for c in chunks[before:] if c.type == 'rpc']
+        if owner.get('options', {}).get('deprecated') == 'true':
+            metadata['deprecated'] = True
+    
+    def _parse_field(self, node: Node, parents: List[str], owner: Dict, chunks: List[CodeChunk]):
+        """A field: [label] Type name = number [options]; map<K, V> name = number; group Name = number {...}"""
+        children = [c for c in node.children if c.type != 'comment']
+        types = [c.type for c in children]
+        if '=' not in types:
+            return
+        equals = types.index('=')
+        name = next((c for c in reversed(children[:equals]) if c.type == 'identifier'), None)
+        number = children[equals + 1] if equals + 1 < len(children) else None
+        if name is None or number is None:
+            return
+        field: Dict = {'name': self._text(name)}
+        if not children[0].is_named and self._text(children[0]) in LABELS:
+            field['label'] = self._text(children[0])
+        
+        group = node.type == 'group'
+        if group:
+            # The field of a group is named after it in lower case
+            field['name'] = self._text(name).lower()
+            field['type'] = self._text(name)
+        elif node.type == 'map_field':
+            key = next((c for c in children if c.type == 'key_type'), None)
+            value = next((c for c in children if c.type == 'type'), None)
+            if key is not None and value is not None:
+                field['type'] = f"map<{self._compact(key)}, {self._compact(value)}>"
+                field['map'] = {'key': self._compact(key), 'value': self._compact(value)}
+            else:
+                field['type'] = self._compact_span(children[0], children[equals - 2])
+        else:
+            type_node = next((c for c in children[:equals] if c.type == 'type'), None)
+            field['type'] = self._compact(type_node) if type_node is not None else ''
+        field['number'] = parse_number(self._compact(number))
+        
+        options = {}
+        for option in self._descendants(node, ('field_option', 'enum_value_option')):
+            option_name, value = self._parse_option(option)
+            if option_name:
+                options[option_name] = value
+        if options:
+            field['options'] = options
+            if options.get('deprecated') == 'true':
+                field['deprecated'] = True
+        
+        if group:
+            # group Result = 1 { ... }: a nested message and a field of its type
+            self._extract_group(node, parents, chunks, field['type'])
+        doc = self._leading_doc(node) or self._trailing_doc(node)
+        if doc:
+            field['doc'] = doc
+        owner.setdefault('fields', []).append(field)
+    
+    def _extract_group(self, node: Node, parents: List[str], chunks: List[CodeChunk], name: str):
+        """The message of a proto2 group"""
+        body = self._body(node)
+        brace = next((c for c in body.children if c.type == '{'), None)
+        if brace is None:
+            return
+        self._note_unclosed(body, brace, 'message')
+        owner: Dict = {'kind': 'message', 'name': name}
+        self._parse_statements(body, parents + [name], owner, chunks)
+        chunk = CodeChunk(
+            type='message',
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(node.start_byte, brace.start_byte)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata={key: owner[key] for key in ('fields', 'oneofs', 'reserved', 'options') if owner.get(key)}
+        )
+        chunk.metadata['group'] = True
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+    
+    def _parse_enum_value(self, node: Node, owner: Dict):
+        """NAME = number [options];"""
+        children = [c for c in node.children if c.type != 'comment']
+        types = [c.type for c in children]
+        if not children or children[0].type != 'identifier' or '=' not in types:
+            return
+        equals = types.index('=')
+        number = next((c for c in children[equals + 1:] if c.type not in ('-', '+')), None)
+        if number is None or number.type in ('[', ';'):
+            return
+        sign = '-' if '-' in types[equals + 1:children.index(number)] else ''
+        value: Dict = {'name': self._text(children[0]), 'number': parse_number(sign + self._compact(number))}
+        options = {}
+        for option in self._descendants(node, ('enum_value_option', 'field_option')):
+            name, option_value = self._parse_option(option)
+            if name:
+                options[name] = option_value
+        if options:
+            value['options'] = options
+            if options.get('deprecated') == 'true':
+                value['deprecated'] = True
+        doc = self._leading_doc(node) or self._trailing_doc(node)
+        if doc:
+            value['doc'] = doc
+        owner.setdefault('values', []).append(value)
+    
+    def _extract_rpc(self, node: Node, parents: List[str], chunks: List[CodeChunk]):
+        """rpc Name(stream Request) returns (stream Response); with an optional body of options"""
+        name_node = next((c for c in node.named_children if c.type != 'comment'), None)
+        if name_node is None:
+            return
+        name = self._text(name_node)
+        metadata: Dict = {}
+        
+        # The request and the response are the two parenthesized types
+        children = node.children
+        roles = iter(('request', 'response'))
+        header_end = name_node
+        k = 0
+        while k < len(children):
+            if children[k].type != '(':
+                k += 1
+                continue
+            close = next((j for j in range(k + 1, len(children)) if children[j].type == ')'), len(children) - 1)
+            role = next(roles, None)
+            if role is None:
+                break
+            inner = [c for c in children[k + 1:close] if c.type != 'comment']
+            streaming = len(inner) > 1 and inner[0].type == 'stream'
+            message = inner[1:] if streaming else inner
+            metadata[role] = self._compact_span(message[0], message[-1]) if message else ''
+            metadata['client_streaming' if role == 'request' else 'server_streaming'] = streaming
+            header_end = children[close]
+            k = close + 1
+        
+        rpc_owner: Dict = {'kind': 'rpc', 'name': name}
+        self._parse_statements(self._body(node), parents, rpc_owner, chunks)
+        last = node
+        if node.next_sibling is not None and node.next_sibling.type in (';', 'empty_statement'):
+            last = node.next_sibling
+        
+        options = rpc_owner.get('options', {})
+        if options:
+            metadata['options'] = options
+            http = http_rule(options.get(HTTP_OPTION))
+            if http:
+                metadata['http'] = http
+            if options.get('deprecated') == 'true':
+                metadata['deprecated'] = True
+        
+        chunk = CodeChunk(
+            type='rpc',
+            name=name,
+            content=self._span(node, last),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(last),
+            signature=normalize_signature(self._span(node, header_end)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Options and ranges
+    # ------------------------------------------------------------------
+    
+    def _parse_option(self, node: Node) -> Tuple[Optional[str], object]:
+        """option name = constant; or a [name = constant] field option: the option's name and value"""
+        children = [c for c in node.children if c.type not in ('comment', 'option', ';')]
+        types = [c.type for c in children]
+        if '=' not in types or types.index('=') == 0 or types.index('=') + 1 >= len(children):
+            return None, None
+        equals = types.index('=')
+        name = self._compact_span(children[0], children[equals - 1])
+        leaves = [leaf for c in children[equals + 1:] for leaf in self._leaves(c)]
+        if not leaves:
+            return None, None
+        if self._text(leaves[0]) == '{':
+            return name, self._aggregate(leaves, 0)
+        return name, self._constant(leaves)
+    
+    def _constant(self, leaves: List[Node]) -> str:
+        """A scalar constant: a string (adjacent literals joined), number, identifier or -number"""
+        if leaves[0].type == 'string':
+            return ''.join(unquote(self._text(l)) for l in leaves if l.type == 'string')
+        return ''.join(self._text(l) for l in leaves)
+    
+    def _aggregate(self, leaves: List[Node], open_index: int) -> Dict:
+        """A text-format message value { key: value key { ... } }; repeated keys give lists"""
+        values_text = [self._text(l) for l in leaves]
+        close = match_brace(values_text, open_index)
+        values: Dict = {}
+        j = open_index + 1
+        while j < close:
+            text = values_text[j]
+            if text in (',', ';') or not IDENTIFIER.match(text) and text != '[':
+                j += 1
+                continue
+            # An extension or Any type URL key: [foo.bar]
+            key_end = match_brace(values_text, j) if text == '[' else j
+            key = ''.join(values_text[j:key_end + 1])
+            j = key_end + 1
+            if j < close and values_text[j] == ':':
+                j += 1
+            if j >= close:
+                break
+            if values_text[j] in ('{', '<'):
+                end = match_brace(values_text, j)
+                value = self._aggregate(leaves, j) if values_text[j] == '{' else self._span(leaves[j], leaves[end])
+                j = end + 1
+            elif values_text[j] == '[':
+                list_end = match_brace(values_text, j)
+                value = [self._constant([leaves[k] for k in part])
+                         for part in self._split_commas(values_text, j + 1, list_end)]
+                j = list_end + 1
+            else:
+                k = j + 1
+                if values_text[j] == '-':
+                    k += 1
+                while k < close and leaves[k].type == 'string' and leaves[j].type == 'string':
+                    k += 1
+                value = self._constant(leaves[j:k])
+                j = k
+            if key in values:
+                existing = values[key]
+                values[key] = (existing if isinstance(existing, list) else [existing]) + [value]
+            else:
+                values[key] = value
+        return values
+    
+    def _ranges(self, node: Node) -> List:
+        """reserved / extensions entries: numbers, 'N to M' ranges and reserved names"""
+        leaves = [leaf for c in node.children[1:] for leaf in self._leaves(c) if self._text(leaf) != ';']
+        values_text = [self._text(l) for l in leaves]
+        entries = []
+        for part in self._split_commas(values_text, 0, len(leaves)):
+            part = [k for k in part if k < next((p for p in part if values_text[p] == '['), len(leaves))]
+            if not part:
+                continue  # extension range options: extensions 1000 to max [declaration = {...}]
+            if leaves[part[0]].type == 'string':
+                entries.append(unquote(values_text[part[0]]))
+            else:
+                entries.append(re.sub(r'-\s+', '-', normalize_signature(self._span(leaves[part[0]],
+                                                                             leaves[part[-1]]))))
+        return entries
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _body(self, node: Node) -> Node:
+        """The body node of a declaration, or the declaration itself when its braces are its own children"""
+        return next((c for c in node.children if c.type.endswith('_body')), node)
+    
+    def _note_unclosed(self, body: Node, brace: Node, kind: str):
+        if body.children and body.children[-1].is_missing:
+            self._unclosed[self._line(body.children[-1])] = (self._line(brace), kind)
+    
+    def _leaves(self, node: Node) -> List[Node]:
+        """The tokens of a node in source order; string literals are one token"""
+        leaves = []
+        stack = [node]
+        while stack:
+            current = stack.pop()
+            if current.type == 'comment':
+                continue
+            if current.type == 'string' or not current.children:
+                leaves.append(current)
+            else:
+                stack.extend(reversed(current.children))
+        return leaves
+    
+    def _descendants(self, node: Node, types: Tuple[str, ...]) -> List[Node]:
+        """Nodes of the given types under node, in source order, without entering them"""
+        found = []
+        stack = list(reversed(node.children))
+        while stack:
+            current = stack.pop()
+            if current.type in types:
+                found.append(current)
+            elif not current.type.endswith('_body'):
+                stack.extend(reversed(current.children))
+        return found
+    
+    def _split_commas(self, values: List[str], start: int, end: int) -> List[List[int]]:
+        """Indexes between start and end split at top-level commas"""
+        groups, current = [], []
+        i = start
+        while i < end:
+            value = values[i]
+            if value in ('(', '[', '{', '<'):
+                close = min(match_brace(values, i), end - 1)
+                current.extend(range(i, close + 1))
+                i = close + 1
+                continue
+            if value == ',':
+                if current:
+                    groups.append(current)
+                current = []
+            else:
+                current.append(i)
+            i += 1
+        if current:
+            groups.append(current)
+        return groups
+    
+    def _collect_comments(self, root: Node) -> List[ProtoComment]:
+        comments = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type == 'comment':
+                comments.append(ProtoComment(self._text(node), node.start_byte, node.end_byte,
+                                             self._line(node), self._line_end(node)))
+            else:
+                stack.extend(node.children)
+        return sorted(comments, key=lambda c: c.start)
+    
+    def _leading_doc(self, node: Node) -> Optional[str]:
+        """
+        The comment directly above a declaration (a run of // lines or a /* */
+        comment), as protoc attaches it: not separated by a blank line, and not
+        the trailing comment of the line before
+        """
+        previous = node.prev_sibling
+        while previous is not None and previous.type == 'comment':
+            previous = previous.prev_sibling
+        previous_line = self._line_end(previous) if previous is not None else 0
+        position = bisect_right(self._comment_ends, node.start_byte) - 1
+        doc: List[ProtoComment] = []
+        following = node.start_byte
+        while position >= 0:
+            comment = self._comments[position]
+            gap = self._source[comment.end:following]
+            if gap.strip() or gap.count(b'\n') > 1 or comment.line_start <= previous_line:
+                break
+            doc.insert(0, comment)
+            if not comment.text.startswith('//'):
+                break  # a /* */ comment is the whole doc
+            following = comment.start
+            position -= 1
+        if not doc or len(doc) > 1 and not all(c.text.startswith('//') for c in doc):
+            return None
+        return comment_text(doc) or None
+    
+    def _trailing_doc(self, node: Node) -> Optional[str]:
+        """A comment on the same line after a field: int32 id = 1; // the user's id"""
+        position = bisect_right(self._comment_ends, node.end_byte)
+        if position < len(self._comments):
+            comment = self._comments[position]
+            if comment.line_start == self._line_end(node) and \
+                    not self._source[node.end_byte:comment.start].strip():
+                return comment_text([comment]) or None
+        return None
+    
+    def _attach_doc(self, chunk: CodeChunk, node: Node):
+        """The leading comment of the declaration becomes its doc"""
+        chunk.doc = self._leading_doc(node)
+    
+    def _compact(self, node: Node) -> str:
+        """A name or type without the whitespace between its parts (google.protobuf.Empty)"""
+        return re.sub(r'\s+', '', self._text(node))
+    
+    def _compact_span(self, first: Node, last: Node) -> str:
+        return re.sub(r'\s+', '', self._span(first, last))
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
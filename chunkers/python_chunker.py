@@ -1,14 +1,16 @@
 #!/usr/bin/env python3
 """
 Python code chunker using tree-sitter for accurate parsing
-Supports .py files
+Supports .py and .pyi files
 """
 
+import ast
+import inspect
 from typing import List, Optional
 from tree_sitter import Language, Parser, Node
 import tree_sitter_python
 
-from .base_chunker import BaseChunker, CodeChunk
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
 
 
 class PythonChunker(BaseChunker):
@@ -22,34 +24,41 @@ class PythonChunker(BaseChunker):
     def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
         """Extract all Python code elements"""
         tree = self.parser.parse(bytes(code, "utf8"))
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
         chunks = []
         
-        def traverse(node: Node, parent_class: str = ''):
-            # Extract functions
+        def traverse(node: Node, parent_class: str = '', parent: str = ''):
+            # Extract functions (methods when directly inside a class body)
             if node.type == "function_definition":
-                chunk = self._extract_function(node, code, filepath, parent_class)
+                chunk = self._extract_function(node, code, filepath, parent_class, parent)
                 if chunk and self._should_include_chunk(chunk):
                     chunks.append(chunk)
+                
+                # Nested definitions become child chunks of this function
+                qualified = self._qualify(parent, self._extract_class_name(node, code))
+                body = node.child_by_field_name("body")
+                if body:
+                    for child in body.children:
+                        traverse(child, '', qualified)
+                return
             
             # Extract classes
             elif node.type == "class_definition":
-                chunk = self._extract_class(node, code, filepath)
+                chunk = self._extract_class(node, code, filepath, parent)
                 if chunk and self._should_include_chunk(chunk):
                     chunks.append(chunk)
                 
-                # Extract methods within the class
+                # Methods and nested classes are children of the class
                 class_name = self._extract_class_name(node, code)
                 body = node.child_by_field_name("body")
                 if body:
                     for child in body.children:
-                        if child.type == "function_definition":
-                            method_chunk = self._extract_function(child, code, filepath, class_name)
-                            if method_chunk and self._should_include_chunk(method_chunk):
-                                chunks.append(method_chunk)
+                        traverse(child, class_name, self._qualify(parent, class_name))
+                return
             
-            # Continue traversing
+            # Continue traversing (decorated definitions, if/try blocks, ...)
             for child in node.children:
-                traverse(child, parent_class)
+                traverse(child, parent_class, parent)
         
         traverse(tree.root_node)
         
@@ -59,9 +68,12 @@ class PythonChunker(BaseChunker):
         
         return chunks
     
-    def _extract_function(self, node: Node, code: str, filepath: str, parent_class: str = '') -> Optional[CodeChunk]:
+    def _extract_function(self, node: Node, code: str, filepath: str,
+                          parent_class: str = '', parent: str = '') -> Optional[CodeChunk]:
         """Extract function or method definition"""
-        func_text = self._extract_text(code, node.start_byte, node.end_byte)
+        # Decorators live on the wrapping decorated_definition node
+        outer = self._outer_node(node)
+        func_text = self._extract_text(code, outer.start_byte, outer.end_byte)
         
         # Get function name
         name_node = node.child_by_field_name("name")
@@ -71,22 +83,27 @@ class PythonChunker(BaseChunker):
         params_node = node.child_by_field_name("parameters")
         params = self._extract_text(code, params_node.start_byte, params_node.end_byte) if params_node else "()"
         
-        # Check for decorators
-        decorators = []
-        for child in node.children:
-            if child.type == "decorator":
-                decorator_text = self._extract_text(code, child.start_byte, child.end_byte)
-                decorators.append(decorator_text)
+        # Get return annotation
+        return_node = node.child_by_field_name("return_type")
+        return_type = self._extract_text(code, return_node.start_byte, return_node.end_byte) if return_node else None
+        
+        decorators = self._extract_decorators(outer, code)
         
         # Build signature
         signature = f"def {func_name}{params}"
-        if decorators:
-            signature = '\n'.join(decorators) + '\n' + signature
+        if return_type:
+            signature += f" -> {return_type}"
         
         # Check if async
         is_async = any(child.type == "async" for child in node.children)
         if is_async:
             signature = "async " + signature
+        if decorators:
+            signature = '\n'.join(decorators) + '\n' + signature
+        
+        metadata = {'decorators': decorators, 'async': is_async}
+        if parent_class:
+            metadata.update(self._method_receiver(params_node, decorators, code))
         
         return CodeChunk(
             type='method' if parent_class else 'function',
@@ -94,16 +111,19 @@ class PythonChunker(BaseChunker):
             content=func_text,
             filepath=filepath,
             language=self.language,
-            line_start=node.start_point[0] + 1,
-            line_end=node.end_point[0] + 1,
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
             signature=signature,
             parent_class=parent_class,
-            metadata={'decorators': decorators, 'async': is_async}
+            parent=parent or None,
+            doc=self._extract_docstring(node, code),
+            metadata=metadata
         )
     
-    def _extract_class(self, node: Node, code: str, filepath: str) -> Optional[CodeChunk]:
+    def _extract_class(self, node: Node, code: str, filepath: str, parent: str = '') -> Optional[CodeChunk]:
         """Extract class definition"""
-        class_text = self._extract_text(code, node.start_byte, node.end_byte)
+        outer = self._outer_node(node)
+        class_text = self._extract_text(code, outer.start_byte, outer.end_byte)
         class_name = self._extract_class_name(node, code)
         
         # Extract base classes
@@ -111,22 +131,19 @@ class PythonChunker(BaseChunker):
         argument_list = node.child_by_field_name("superclasses")
         if argument_list:
             for child in argument_list.children:
-                if child.type == "identifier":
+                if child.type in ("identifier", "attribute"):
                     base_name = self._extract_text(code, child.start_byte, child.end_byte)
                     bases.append(base_name)
         
-        # Extract decorators
-        decorators = []
-        for child in node.children:
-            if child.type == "decorator":
-                decorator_text = self._extract_text(code, child.start_byte, child.end_byte)
-                decorators.append(decorator_text)
+        decorators = self._extract_decorators(outer, code)
         
         # Count methods
         method_count = 0
         body = node.child_by_field_name("body")
         if body:
             for child in body.children:
+                if child.type == "decorated_definition":
+                    child = child.child_by_field_name("definition") or child
                 if child.type == "function_definition":
                     method_count += 1
         
@@ -146,9 +163,11 @@ class PythonChunker(BaseChunker):
             content=class_text,
             filepath=filepath,
             language=self.language,
-            line_start=node.start_point[0] + 1,
-            line_end=node.end_point[0] + 1,
+            line_start=outer.start_point[0] + 1,
+            line_end=outer.end_point[0] + 1,
             signature=signature,
+            parent=parent or None,
+            doc=self._extract_docstring(node, code),
             metadata=metadata
         )
     
@@ -158,7 +177,7 @@ class PythonChunker(BaseChunker):
         
         for child in root_node.children:
             if child.type == "expression_statement":
-                # Check if it's an assignment
+                # Check if it's an assignment (stub files may omit the value)
                 assignment = child.child(0)
                 if assignment and assignment.type == "assignment":
                     left = assignment.child_by_field_name("left")
@@ -166,17 +185,22 @@ class PythonChunker(BaseChunker):
                         var_name = self._extract_text(code, left.start_byte, left.end_byte)
                         var_text = self._extract_text(code, assignment.start_byte, assignment.end_byte)
                         
-                        # Only include constants (uppercase variables)
-                        if var_name.isupper() and len(var_text) < 500:
-                            chunks.append(CodeChunk(
-                                type='constant',
-                                name=var_name,
-                                content=var_text,
-                                filepath=filepath,
-                                language=self.language,
-                                line_start=assignment.start_point[0] + 1,
-                                line_end=assignment.end_point[0] + 1
-                            ))
+                        if len(var_text) >= 500:
+                            continue
+                        
+                        type_node = assignment.child_by_field_name("type")
+                        chunks.append(CodeChunk(
+                            type='constant' if var_name.isupper() else 'variable',
+                            name=var_name,
+                            content=var_text,
+                            filepath=filepath,
+                            language=self.language,
+                            line_start=assignment.start_point[0] + 1,
+                            line_end=assignment.end_point[0] + 1,
+                            metadata={
+                                'annotation': self._extract_text(code, type_node.start_byte, type_node.end_byte)
+                            } if type_node else None
+                        ))
         
         return chunks
     
@@ -186,3 +210,61 @@ class PythonChunker(BaseChunker):
         if name_node:
             return self._extract_text(code, name_node.start_byte, name_node.end_byte)
         return "anonymous"
+    
+    def _outer_node(self, node: Node) -> Node:
+        """Return the decorated_definition wrapping node, if any"""
+        if node.parent and node.parent.type == "decorated_definition":
+            return node.parent
+        return node
+    
+    def _extract_decorators(self, outer: Node, code: str) -> List[str]:
+        """Collect decorator source text from a (possibly decorated) definition"""
+        return [
+            self._extract_text(code, child.start_byte, child.end_byte)
+            for child in outer.children
+            if child.type == "decorator"
+        ]
+    
+    def _method_receiver(self, params_node: Optional[Node], decorators: List[str], code: str) -> dict:
+        """Work out the receiver (self/cls) and method kind from decorators and parameters"""
+        if any(d.startswith('@staticmethod') for d in decorators):
+            return {'method_kind': 'staticmethod', 'receiver': None}
+        
+        # Only a plain positional first parameter is self/cls: not *args, **kw or one after a bare *
+        receiver = None
+        params = params_node.named_children if params_node else []
+        first = next((child for child in params if child.type != "comment"), None)
+        if first is not None and first.type == "typed_parameter":
+            first = first.named_children[0] if first.named_children else None
+        if first is not None and first.type == "identifier":
+            receiver = self._extract_text(code, first.start_byte, first.end_byte)
+        
+        kind = 'classmethod' if any(d.startswith('@classmethod') for d in decorators) else 'instance'
+        return {'method_kind': kind, 'receiver': receiver}
+    
+    def _extract_docstring(self, node: Node, code: str) -> Optional[str]:
+        """Return the docstring (first string literal in the body) of a def/class"""
+        body = node.child_by_field_name("body")
+        if not body:
+            return None
+        
+        for child in body.children:
+            if child.type == "comment":
+                continue
+            if child.type == "expression_statement" and child.child(0) and child.child(0).type == "string":
+                literal = child.child(0)
+                raw = self._extract_text(code, literal.start_byte, literal.end_byte)
+                try:
+                    value = ast.literal_eval(raw)
+                except (ValueError, SyntaxError):
+                    value = raw.strip('rRbBuUfF').strip('"\'')
+                if isinstance(value, bytes):
+                    value = value.decode('utf-8', errors='ignore')
+                return inspect.cleandoc(value) or None
+            break
+        
+        return None
+    
+    def _qualify(self, parent: str, name: str) -> str:
+        """Build a dotted qualified name"""
+        return f"{parent}.{name}" if parent else name
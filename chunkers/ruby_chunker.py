@@ -0,0 +1,541 @@
+#!/usr/bin/env python3
+"""
+Ruby code chunker using tree-sitter for accurate parsing
+Supports .rb files
+
+Modules and classes are walked recursively through their bodies; method
+bodies are not entered, but the blocks of class body calls (included do ...
+end) are, so the methods defined in them belong to the class. Classes
+record their superclass, mixed-in modules (include, extend, prepend) and
+attr_* accessors; 'def self.x' and the methods of 'class << self' are class
+methods. Leading # comments and =begin/=end blocks fill the doc field.
+"""
+
+import re
+from bisect import bisect_left, bisect_right
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error, tree_sitter_diagnostics
+
+
+# Class body macros
+ACCESSOR_MACROS = {'attr_accessor': 'accessor', 'attr_reader': 'reader', 'attr_writer': 'writer'}
+MIXIN_MACROS = {'include': 'includes', 'extend': 'extends', 'prepend': 'prepends'}
+VISIBILITY_MACROS = {'private', 'protected', 'public'}
+CLASS_VISIBILITY_MACROS = {'private_class_method': 'private', 'public_class_method': 'public'}
+ASSOCIATION_MACROS = {'belongs_to', 'has_one', 'has_many', 'has_and_belongs_to_many'}
+# Calls that may prefix a declaration on the same line: private def helper
+PREFIX_MACROS = VISIBILITY_MACROS | set(CLASS_VISIBILITY_MACROS) | {'module_function'}
+CLASS_MACROS = (set(ACCESSOR_MACROS) | set(MIXIN_MACROS) | PREFIX_MACROS | ASSOCIATION_MACROS
+                | {'private_constant'})
+
+# Constants assigned one of these define a class: Point = Struct.new(:x, :y)
+TYPE_CONSTRUCTORS = {('Struct', 'new'): 'class', ('Data', 'define'): 'class', ('Class', 'new'): 'class',
+                     ('Module', 'new'): 'module'}
+
+# Statements whose bodies may define methods: if defined?(Rails) ... def x ... end
+NESTED_STATEMENTS = {
+    'if', 'unless', 'elsif', 'else', 'then', 'case', 'when', 'while', 'until', 'for', 'do', 'begin',
+    'rescue', 'ensure', 'body_statement', 'parenthesized_statements', 'do_block', 'block', 'block_body',
+}
+
+# Parameter nodes and the kind of parameter they declare
+PARAM_KINDS = {
+    'splat_parameter': 'rest', 'hash_splat_parameter': 'keyword_rest', 'block_parameter': 'block',
+}
+
+# Magic comments are not documentation: # frozen_string_literal: true
+MAGIC_COMMENT = re.compile(r'^(?:-\*-.*-\*-|(?:frozen_string_literal|encoding|coding|warn_indent|'
+                           r'shareable_constant_value|typed):.*|rubocop:.*)$')
+
+
+def comment_doc(comments: List[str]) -> str:
+    """Text of a run of # comments or of an =begin/=end block, without the markers"""
+    lines = []
+    for comment in comments:
+        if comment.startswith('=begin'):
+            lines.extend(line.rstrip() for line in comment.rstrip().splitlines()[1:-1])
+            continue
+        line = comment[1:]
+        line = line[1:] if line.startswith(' ') else line
+        if not MAGIC_COMMENT.match(line.strip()) and not line.startswith('!'):
+            lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)\]])|([(\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+class RubyChunker(BaseChunker):
+    """Extracts modules, classes, methods and constants from Ruby code"""
+    
+    def __init__(self):
+        super().__init__('ruby')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('ruby')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Ruby code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._collect_extras(tree.root_node)
+        self._unclosed: Dict[int, Tuple[int, str]] = {}  # line of a missing 'end' -> what it should have closed
+        chunks: List[CodeChunk] = []
+        
+        top = self._frame('top', [])
+        self._parse_body(tree.root_node.children, top, top, chunks)
+        
+        # "missing 'end' for class Open" says more than tree-sitter's "missing 'end'"
+        for diagnostic in self.diagnostics:
+            message = self._unclosed.get(diagnostic['line'])
+            if message and diagnostic['message'] == "missing 'end'":
+                diagnostic.update(parse_error(message[0], message[1]))
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Bodies
+    # ------------------------------------------------------------------
+    
+    def _frame(self, kind: str, path: List[str], **fields) -> Dict:
+        """A scope; containers (top level, class, module) collect their members"""
+        frame = {'kind': kind, 'path': path, 'visibility': 'public', 'module_function': False,
+                 'members': [], 'accessors': [], 'constants': [], 'associations': [], 'metadata': {}}
+        frame.update(fields)
+        return frame
+    
+    def _parse_body(self, children: List[Node], container: Dict, scope: Dict, chunks: List[CodeChunk],
+                    nested: bool = False):
+        """
+        Extract the declarations among the statements of a body
+        container is the class, module or top level the members belong to; scope
+        is the container or a class << self inside it. Class macros and constants
+        only count directly in a body, not in the blocks and branches it nests.
+        """
+        for child in children:
+            if child.type == 'ERROR':
+                self._parse_body(child.children, container, scope, chunks, nested)
+            elif child.type in ('class', 'module'):
+                self._extract_type(child, container, scope, chunks)
+            elif child.type == 'singleton_class':
+                self._extract_singleton_class(child, container, chunks)
+            elif child.type in ('method', 'singleton_method'):
+                self._extract_method(child, child, [], container, scope, chunks)
+            elif child.type in ('assignment', 'operator_assignment') and not nested:
+                left = child.child_by_field_name('left')
+                if left is not None and left.type == 'constant':
+                    self._extract_constant(child, left, container, chunks)
+            elif child.type == 'identifier' and not nested:
+                # A bare visibility call applies to the methods that follow it
+                name = self._text(child)
+                if name in VISIBILITY_MACROS:
+                    scope['visibility'] = name
+                elif name == 'module_function':
+                    scope['module_function'] = True
+            elif child.type == 'call':
+                self._extract_call(child, container, scope, chunks, nested)
+            elif child.type in NESTED_STATEMENTS:
+                self._parse_body(child.children, container, scope, chunks, True)
+            # Other expressions are not chunked
+    
+    def _body(self, node: Node) -> List[Node]:
+        """Statements of a class, module, method or block body"""
+        body = node.child_by_field_name('body')
+        return body.children if body is not None else []
+    
+    def _extract_call(self, node: Node, container: Dict, scope: Dict, chunks: List[CodeChunk], nested: bool):
+        """Class body macros, and the methods defined in the block of a call"""
+        method = node.child_by_field_name('method')
+        macro = self._text(method) if method is not None else ''
+        if node.child_by_field_name('receiver') is None and macro in CLASS_MACROS and not nested:
+            self._class_macro(node, macro, container, scope, chunks)
+            return
+        
+        block = node.child_by_field_name('block')
+        if block is None:
+            return
+        if macro == 'class_methods' and node.child_by_field_name('receiver') is None:
+            # ActiveSupport concerns: the methods of class_methods do ... end are class methods
+            self._parse_body(self._body(block), container, self._frame('sclass', [], target='self'), chunks)
+        else:
+            self._parse_body(self._body(block), container, scope, chunks, True)
+    
+    # ------------------------------------------------------------------
+    # Declarations
+    # ------------------------------------------------------------------
+    
+    def _extract_type(self, node: Node, container: Dict, scope: Dict, chunks: List[CodeChunk]):
+        """class Name < Base, module A::B"""
+        name_node = node.child_by_field_name('name')
+        segments = self._segments(name_node) if name_node is not None else None
+        if not segments:
+            # Not a declaration we understand; what it defines belongs to the enclosing one
+            self._parse_body(self._body(node), container, scope, chunks, True)
+            return
+        
+        kind = node.type
+        metadata: Dict = {}
+        header_end = name_node
+        superclass = node.child_by_field_name('superclass')
+        if superclass is not None:
+            header_end = superclass
+            value = superclass.named_children
+            metadata['superclass'] = normalize_signature(self._text(value[0])) if value else ''
+        
+        absolute = self._text(name_node).startswith('::')
+        path = segments if absolute else container['path'] + segments
+        frame = self._frame(kind, path, name=segments[-1], metadata=metadata)
+        self._parse_body(self._body(node), frame, frame, chunks)
+        self._note_unclosed(node, f"{kind} {segments[-1]}")
+        self._emit_type(frame, node, header_end, chunks)
+    
+    def _extract_singleton_class(self, node: Node, container: Dict, chunks: List[CodeChunk]):
+        """class << self: its methods and accessors belong to the enclosing class"""
+        value = node.child_by_field_name('value')
+        target = normalize_signature(self._text(value)) if value is not None else ''
+        self._parse_body(self._body(node), container, self._frame('sclass', [], target=target), chunks)
+        self._note_unclosed(node, 'class << ' + target)
+    
+    def _extract_method(self, node: Node, start: Node, prefix: List[str], container: Dict, scope: Dict,
+                        chunks: List[CodeChunk]):
+        """def name(params), def self.name, def obj.name; start is the prefix call in private def x"""
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        name = self._text(name_node)
+        receiver_node = node.child_by_field_name('object')
+        receiver = self._text(receiver_node) if receiver_node is not None else None
+        
+        params = node.child_by_field_name('parameters')
+        metadata: Dict = {'params': self._parse_params(params) if params is not None else []}
+        
+        path = container['path']
+        class_method = receiver == 'self' or path and receiver == path[-1] \
+            or scope['kind'] == 'sclass' and scope['target'] == 'self' and not receiver
+        if class_method:
+            metadata['class_method'] = True
+        elif receiver:
+            metadata['receiver'] = receiver  # def obj.greet: a method of that one object
+        elif scope['kind'] == 'sclass':
+            metadata['receiver'] = scope['target']
+        
+        inline_visibility = next((v if v in VISIBILITY_MACROS else CLASS_VISIBILITY_MACROS[v]
+                                  for v in prefix if v in VISIBILITY_MACROS or v in CLASS_VISIBILITY_MACROS), None)
+        visibility = inline_visibility or (scope['visibility'] if not receiver else 'public')
+        if visibility != 'public':
+            metadata['visibility'] = visibility
+        if 'module_function' in prefix or scope['module_function'] and not receiver:
+            metadata['module_function'] = True
+        if any(c.type == '=' for c in node.children):
+            metadata['endless'] = True  # def area = width * height
+        else:
+            self._note_unclosed(node, 'def ' + name)
+        
+        header_end = params if params is not None else name_node
+        last = self._last_node(node)
+        chunk = CodeChunk(
+            type='method' if path else 'function',
+            name=name,
+            content=self._span(start, last),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(start),
+            line_end=self._line_end(last),
+            signature=normalize_signature(self._span(start, header_end)),
+            namespace='::'.join(path[:-1]) or None,
+            parent_class=path[-1] if path else None,
+            parent='::'.join(path) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, start)
+        chunks.append(chunk)
+        container['members'].append(chunk)
+    
+    def _parse_params(self, node: Node) -> List[Dict]:
+        """Parameters of a method: a, b = 1, *rest, key:, **opts, &block"""
+        params = []
+        for child in node.named_children:
+            name_node = child.child_by_field_name('name')
+            name = self._text(name_node) if name_node is not None else ''
+            value = child.child_by_field_name('value')
+            if child.type == 'identifier':
+                param = {'name': self._text(child)}
+            elif child.type == 'optional_parameter':
+                param = {'name': name}
+            elif child.type == 'keyword_parameter':
+                param = {'name': name, 'kind': 'keyword'}
+            elif child.type in PARAM_KINDS:
+                param = {'name': name, 'kind': PARAM_KINDS[child.type]} if name \
+                    else {'name': self._text(child), 'kind': 'anonymous'}
+            elif child.type == 'hash_splat_nil':
+                param = {'name': 'nil', 'kind': 'keyword_rest'}  # **nil: no keywords accepted
+            elif child.type == 'forward_parameter':
+                param = {'name': '...', 'kind': 'forward'}
+            elif child.type == 'destructured_parameter':
+                param = {'name': normalize_signature(self._text(child)), 'kind': 'destructure'}
+            else:
+                continue
+            if value is not None:
+                param['default'] = normalize_signature(self._text(value))
+            params.append(param)
+        return params
+    
+    def _extract_constant(self, node: Node, left: Node, container: Dict, chunks: List[CodeChunk]):
+        """NAME = value; Point = Struct.new(:x, :y) do ... end declares a class"""
+        name = self._text(left)
+        value = node.child_by_field_name('right')
+        if value is not None and value.type == 'call' and self._extract_constructed_type(node, name, value,
+                                                                                         container, chunks):
+            return
+        
+        last = self._last_node(node)
+        content = self._span(node, last)
+        path = container['path']
+        chunk = CodeChunk(
+            type='constant',
+            name=name,
+            content=content,
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(last),
+            signature=normalize_signature(content.split('\n')[0]),
+            namespace='::'.join(path[:-1]) or None,
+            parent_class=path[-1] if path else None,
+            parent='::'.join(path) or None,
+            metadata={}
+        )
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+        container['members'].append(chunk)
+        container['constants'].append(name)
+    
+    def _extract_constructed_type(self, node: Node, name: str, call: Node, container: Dict,
+                                  chunks: List[CodeChunk]) -> bool:
+        """Struct.new, Data.define, Class.new(Base), Module.new; False if the call is none of them"""
+        receiver = call.child_by_field_name('receiver')
+        method = call.child_by_field_name('method')
+        if receiver is None or method is None:
+            return False
+        constructor = TYPE_CONSTRUCTORS.get((self._text(receiver), self._text(method)))
+        if not constructor:
+            return False
+        
+        metadata: Dict = {}
+        header_end = method
+        arguments = call.child_by_field_name('arguments')
+        args = arguments.named_children if arguments is not None else []
+        if arguments is not None:
+            header_end = arguments
+        if self._text(receiver) in ('Struct', 'Data'):
+            metadata['superclass'] = self._text(receiver)
+            names = [self._literal_name(a) for a in args]
+            if args:
+                metadata['accessors'] = [{'name': n, 'kind': 'accessor' if self._text(receiver) == 'Struct'
+                                          else 'reader'} for n in names if n]
+        elif args and constructor == 'class':
+            metadata['superclass'] = normalize_signature(self._text(args[0]))
+        
+        frame = self._frame(constructor, container['path'] + [name], name=name, metadata=metadata)
+        block = call.child_by_field_name('block')
+        if block is not None:
+            self._parse_body(self._body(block), frame, frame, chunks)
+        self._emit_type(frame, node, header_end, chunks)
+        return True
+    
+    def _class_macro(self, node: Node, macro: str, container: Dict, scope: Dict, chunks: List[CodeChunk]):
+        """attr_accessor, include/extend/prepend, private/protected/public and friends in a class body"""
+        singleton = scope['kind'] == 'sclass' and scope['target'] == 'self'
+        arguments = node.child_by_field_name('arguments')
+        args = arguments.named_children if arguments is not None else []
+        
+        if macro in PREFIX_MACROS and len(args) == 1 and args[0].type in ('method', 'singleton_method'):
+            self._extract_method(args[0], node, [macro], container, scope, chunks)
+            return
+        if macro in PREFIX_MACROS and len(args) == 1 and args[0].type == 'call':
+            self._parse_body(args, container, scope, chunks)  # private attr_reader :x
+            return
+        names = [n for n in (self._literal_name(a) for a in args) if n]
+        
+        if macro in ACCESSOR_MACROS:
+            for name in names:
+                accessor = {'name': name, 'kind': ACCESSOR_MACROS[macro]}
+                if singleton:
+                    accessor['class_method'] = True
+                container['accessors'].append(accessor)
+        elif macro in MIXIN_MACROS:
+            key = 'extends' if singleton and macro == 'include' else MIXIN_MACROS[macro]
+            for arg in args:
+                if arg.type == 'constant' or arg.type == 'scope_resolution' \
+                        and re.fullmatch(r'(?:::)?[A-Z]\w*(?:::[A-Z]\w*)*', re.sub(r'\s+', '', self._text(arg))):
+                    container['metadata'].setdefault(key, []).append(re.sub(r'\s+', '', self._text(arg)))
+        elif macro in VISIBILITY_MACROS or macro == 'module_function':
+            if not names:
+                if macro == 'module_function':
+                    scope['module_function'] = True
+                else:
+                    scope['visibility'] = macro
+            for member in container['members']:
+                if member.name in names and bool(member.metadata.get('class_method')) == singleton:
+                    if macro == 'module_function':
+                        member.metadata['module_function'] = True
+                    else:
+                        self._set_visibility(member, macro)
+        elif macro in CLASS_VISIBILITY_MACROS:
+            for member in container['members']:
+                if member.name in names and member.metadata.get('class_method'):
+                    self._set_visibility(member, CLASS_VISIBILITY_MACROS[macro])
+        elif macro == 'private_constant':
+            for member in container['members']:
+                if member.name in names and member.type == 'constant':
+                    self._set_visibility(member, 'private')
+        elif macro in ASSOCIATION_MACROS and names:
+            container['associations'].append({'macro': macro, 'name': names[0]})
+    
+    def _set_visibility(self, chunk: CodeChunk, visibility: str):
+        if visibility == 'public':
+            chunk.metadata.pop('visibility', None)
+        else:
+            chunk.metadata['visibility'] = visibility
+    
+    def _segments(self, node: Node) -> Optional[List[str]]:
+        """Constants of a class or module name: A::B::C -> ['A', 'B', 'C']; None for other names"""
+        if node.type == 'constant':
+            return [self._text(node)]
+        if node.type != 'scope_resolution':
+            return None
+        scope = node.child_by_field_name('scope')
+        name = node.child_by_field_name('name')
+        outer = self._segments(scope) if scope is not None else []
+        if outer is None or name is None or name.type != 'constant':
+            return None
+        return outer + [self._text(name)]
+    
+    def _literal_name(self, node: Node) -> Optional[str]:
+        """Name given as a symbol or string argument (:name, 'name', "name"), or None"""
+        if node.type in ('simple_symbol', 'delimited_symbol'):
+            return self._text(node)[1:].strip('"\'')
+        if node.type == 'string' and self._text(node)[:1] in ('"', "'"):
+            return self._text(node)[1:-1]
+        return None
+    
+    def _note_unclosed(self, node: Node, what: str):
+        """Remember a declaration whose 'end' tree-sitter had to make up, for its diagnostic"""
+        last = node.children[-1] if node.children else None
+        if last is not None and last.is_missing:
+            self._unclosed[self._line(last)] = (self._line(node), f"missing 'end' for {what}")
+    
+    # ------------------------------------------------------------------
+    # Chunks
+    # ------------------------------------------------------------------
+    
+    def _emit_type(self, frame: Dict, node: Node, header_end: Node, chunks: List[CodeChunk]):
+        path = frame['path']
+        metadata = frame['metadata']
+        members = frame['members']
+        metadata['methods'] = [('self.' if c.metadata.get('class_method') else '') + c.name
+                               for c in members if c.type == 'method']
+        if frame['constants']:
+            metadata['constants'] = frame['constants']
+        if frame['accessors']:
+            metadata['accessors'] = metadata.get('accessors', []) + frame['accessors']
+        if frame['associations']:
+            metadata['associations'] = frame['associations']
+        
+        last = self._last_node(node)
+        chunk = CodeChunk(
+            type=frame['kind'],
+            name=frame['name'],
+            content=self._span(node, last),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(last),
+            signature=normalize_signature(self._span(node, header_end)),
+            namespace='::'.join(path[:-1]) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node)
+        chunks.append(chunk)
+    
+    def _collect_extras(self, root: Node):
+        """Comments, and the heredoc bodies that follow the line their heredoc starts on"""
+        self._comments: List[Node] = []
+        beginnings: List[Node] = []
+        bodies: List[Node] = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type == 'comment':
+                self._comments.append(node)
+            elif node.type == 'heredoc_beginning':
+                beginnings.append(node)
+            elif node.type == 'heredoc_body':
+                bodies.append(node)
+            else:
+                stack.extend(node.children)
+        self._comments.sort(key=lambda c: c.start_byte)
+        self._comment_ends = [c.end_byte for c in self._comments]
+        beginnings.sort(key=lambda h: h.start_byte)
+        bodies.sort(key=lambda h: h.start_byte)
+        self._heredoc_starts = [h.start_byte for h in beginnings]
+        self._heredoc_bodies = bodies
+    
+    def _last_node(self, node: Node) -> Node:
+        """The node a statement ends with: the body of the last heredoc it starts, if any"""
+        first = bisect_left(self._heredoc_starts, node.start_byte)
+        last = bisect_left(self._heredoc_starts, node.end_byte) - 1
+        if first <= last < len(self._heredoc_bodies):
+            body = self._heredoc_bodies[last]
+            if body.end_byte > node.end_byte:
+                return body
+        return node
+    
+    def _comment_line_end(self, comment: Node) -> int:
+        """Last line of a comment; an =begin/=end block may take the newline after =end"""
+        text = self._text(comment)
+        return self._line_end(comment) - (1 if text.endswith('\n') else 0)
+    
+    def _attach_doc(self, chunk: CodeChunk, start: Node):
+        """# comment lines or an =begin/=end block directly above the declaration"""
+        next_line = self._line(start)
+        position = bisect_right(self._comment_ends, start.start_byte) - 1
+        doc_comments = []
+        while position >= 0:
+            comment = self._comments[position]
+            line_start = self._source.rfind(b'\n', 0, comment.start_byte) + 1
+            own_line = not self._source[line_start:comment.start_byte].strip()
+            if not own_line or self._comment_line_end(comment) != next_line - 1:
+                break
+            text = self._text(comment)
+            doc_comments.insert(0, text)
+            if text.startswith('=begin'):
+                break
+            next_line = self._line(comment)
+            position -= 1
+        if doc_comments:
+            chunk.doc = comment_doc(doc_comments) or None
+        if chunk.doc and re.search(r'^@deprecated\b', chunk.doc, re.MULTILINE):
+            message = re.search(r'^@deprecated[ \t]*(.*)$', chunk.doc, re.MULTILINE).group(1).strip()
+            chunk.metadata['deprecated'] = message or 'deprecated'
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
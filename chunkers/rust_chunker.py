@@ -0,0 +1,495 @@
+#!/usr/bin/env python3
+"""
+Rust code chunker using tree-sitter for accurate parsing
+Supports .rs files
+
+Macro invocations and macro_rules! bodies are token trees to the grammar, so
+unusual macro syntax can't derail the items around it. Attributes and doc
+comments are siblings of the item they belong to and are collected from
+the nodes just above it.
+"""
+
+import re
+from pathlib import PurePosixPath
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+# Words that may precede an item keyword
+ITEM_QUALIFIERS = {'pub', 'const', 'async', 'unsafe', 'extern', 'default'}
+
+COMMENT_NODES = ('line_comment', 'block_comment')
+
+# Type items and the chunk type they become
+TYPE_ITEMS = {'struct_item': 'struct', 'enum_item': 'enum', 'union_item': 'union', 'trait_item': 'trait'}
+
+# Bodies that end an item header: everything before them is the signature
+BLOCK_BODIES = ('block', 'field_declaration_list', 'enum_variant_list', 'declaration_list')
+
+
+def comment_kind(text: str) -> str:
+    """'outer' for ///, /** doc comments, 'inner' for //!, /*!, 'plain' otherwise"""
+    if (text.startswith('///') and not text.startswith('////')) or (text.startswith('/**') and text not in ('/**/', '/***/')):
+        return 'outer'
+    if text.startswith('//!') or text.startswith('/*!'):
+        return 'inner'
+    return 'plain'
+
+
+def doc_comment_text(comments: List[str]) -> str:
+    """Text of a doc comment group without comment markers"""
+    lines = []
+    for comment in comments:
+        comment = comment.rstrip('\r\n')
+        if comment.startswith('//'):
+            body = comment[3:]
+            lines.append(body[1:] if body.startswith(' ') else body)
+        else:
+            for line in comment[3:-2].splitlines():
+                line = line.strip()
+                lines.append(line[1:].lstrip() if line.startswith('*') else line)
+    return '\n'.join(lines).strip()
+
+
+def base_type_name(type_text: str) -> str:
+    """Strip references, paths and generic arguments: '&mut crate::net::Conn<T>' -> 'Conn'"""
+    name = re.sub(r"^(?:&\s*(?:'\w+\s+)?(?:mut\s+)?|\*\s*(?:const|mut)\s+)+", '', type_text.strip())
+    name = re.sub(r'^(?:dyn|impl)\s+', '', name)
+    name = name.split('<', 1)[0]
+    return name.rsplit('::', 1)[-1].strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature (and the trailing comma of a where clause)"""
+    signature = re.sub(r'\s+', ' ', signature).strip().rstrip(',')
+    return re.sub(r'\s+([,;)>\]])|([(<\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+class RustChunker(BaseChunker):
+    """Extracts functions, types, traits, impl blocks and modules from Rust code"""
+    
+    def __init__(self):
+        super().__init__('rust')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('rust')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Rust code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        chunks: List[CodeChunk] = []
+        
+        # Inner docs (//!) at the top of the file document the module the file defines
+        file_docs = []
+        for child in tree.root_node.children:
+            if child.type not in COMMENT_NODES:
+                break
+            if comment_kind(self._text(child)) == 'inner':
+                file_docs.append(child)
+        file_doc = doc_comment_text([self._text(c) for c in file_docs])
+        if file_doc:
+            name = self._file_module_name(filepath)
+            first, last = file_docs[0], file_docs[-1]
+            chunks.append(CodeChunk(
+                type='module',
+                name=name,
+                content=self._span_text(first, last).rstrip(),
+                filepath=filepath,
+                language=self.language,
+                line_start=self._line(first),
+                line_end=self._line_end(last),
+                signature=f"mod {name}",
+                doc=file_doc,
+                metadata={'file_module': True}
+            ))
+        
+        self._parse_items(tree.root_node, [], None, chunks)
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Items
+    # ------------------------------------------------------------------
+    
+    def _parse_items(self, parent: Node, module_path: List[str], container: Optional[Dict],
+                     chunks: List[CodeChunk]):
+        """
+        Extract the items declared directly in a node
+        
+        Args:
+            module_path: Enclosing inline modules, outermost first
+            container: The enclosing impl or trait ({'kind', 'name', 'trait', ...}), if any
+        """
+        for node in parent.named_children:
+            if node.type == 'ERROR':
+                # Items tree-sitter could not fit in the tree are still items
+                self._parse_items(node, module_path, container, chunks)
+            elif node.type in ('function_item', 'function_signature_item'):
+                self._extract_fn(node, module_path, container, chunks)
+            elif node.type in TYPE_ITEMS:
+                self._extract_type(node, module_path, chunks)
+            elif node.type == 'impl_item':
+                self._extract_impl(node, module_path, chunks)
+            elif node.type == 'mod_item':
+                self._extract_mod(node, module_path, chunks)
+            elif node.type == 'macro_definition':
+                self._extract_macro(node, module_path, chunks)
+            # use, const, static, type aliases, extern blocks and macro invocations are not chunked
+    
+    def _extract_fn(self, node: Node, module_path: List[str], container: Optional[Dict],
+                    chunks: List[CodeChunk]):
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        attributes, docs = self._preamble(node)
+        
+        params_node = node.child_by_field_name('parameters')
+        params, self_param = self._parse_params(params_node) if params_node is not None else ([], None)
+        
+        metadata: Dict = {'params': params}
+        for key, value in (('generics', self._field_text(node, 'type_parameters')),
+                           ('returns', self._field_text(node, 'return_type')),
+                           ('where', self._child_text(node, 'where_clause'))):
+            if value:
+                metadata[key] = normalize_signature(value)
+        if attributes:
+            metadata['attributes'] = [self._text(a) for a in attributes]
+        qualifiers = [word for word in re.findall(r'\w+', self._child_text(node, 'function_modifiers'))
+                      if word in ITEM_QUALIFIERS - {'pub'}]
+        if qualifiers:
+            metadata['qualifiers'] = qualifiers
+        visibility = self._child_text(node, 'visibility_modifier')
+        if visibility:
+            metadata['visibility'] = normalize_signature(visibility)
+        
+        parent = None
+        if container:
+            parent = container['name']
+            if self_param:
+                metadata['self_param'] = self_param
+            if container.get('trait'):
+                metadata['impl_trait'] = container['trait']
+            if container['kind'] == 'trait':
+                metadata['trait_method'] = True
+                metadata['has_default'] = node.type == 'function_item'
+        
+        first = attributes[0] if attributes else node
+        chunk = CodeChunk(
+            type='method' if container else 'function',
+            name=self._text(name_node),
+            content=self._span_text(first, node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._header(node)),
+            namespace='::'.join(module_path),
+            parent_class=parent,
+            parent=parent,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, docs)
+        chunks.append(chunk)
+    
+    def _parse_params(self, node: Node) -> Tuple[List[Dict], Optional[str]]:
+        """(name, type) pairs of a parameter list, and the self parameter if any"""
+        params = []
+        self_param = None
+        for param in node.named_children:
+            if param.type in COMMENT_NODES or param.type == 'attribute_item':
+                continue
+            text = normalize_signature(self._text(param))
+            # self, mut self, &self, &'a mut self, self: Box<Self>
+            if param.type == 'self_parameter':
+                self_param = text
+                continue
+            pattern = param.child_by_field_name('pattern')
+            type_node = param.child_by_field_name('type')
+            if pattern is not None and self._text(pattern) in ('self', 'mut self'):
+                self_param = text
+                continue
+            if param.type != 'parameter' or pattern is None or type_node is None:
+                params.append({'name': '', 'type': text})
+                continue
+            params.append({
+                'name': normalize_signature(self._text(pattern)),
+                'type': normalize_signature(self._text(type_node))
+            })
+        return params, self_param
+    
+    def _extract_type(self, node: Node, module_path: List[str], chunks: List[CodeChunk]):
+        keyword = TYPE_ITEMS[node.type]
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        name = self._text(name_node)
+        attributes, docs = self._preamble(node)
+        
+        metadata: Dict = {}
+        generics = self._field_text(node, 'type_parameters')
+        if generics:
+            metadata['generics'] = normalize_signature(generics)
+        where = self._child_text(node, 'where_clause')
+        if where:
+            metadata['where'] = normalize_signature(where)
+        if attributes:
+            metadata['attributes'] = [self._text(a) for a in attributes]
+            derives = [d.strip() for a in metadata['attributes'] for d in re.findall(r'derive\s*\(([^)]*)\)', a)
+                       for d in d.split(',') if d.strip()]
+            if derives:
+                metadata['derives'] = derives
+        visibility = self._child_text(node, 'visibility_modifier')
+        if visibility:
+            metadata['visibility'] = normalize_signature(visibility)
+        
+        body = node.child_by_field_name('body')
+        if body is not None and body.type == 'ordered_field_declaration_list':
+            # Tuple structs: struct Meters(pub f64);
+            metadata['fields'] = self._parse_tuple_fields(body)
+        elif keyword in ('struct', 'union') and body is not None:
+            metadata['fields'] = self._parse_fields(body)
+        elif keyword == 'enum' and body is not None:
+            metadata['variants'] = [self._text(v.child_by_field_name('name')) for v in body.named_children
+                                    if v.type == 'enum_variant' and v.child_by_field_name('name') is not None]
+        elif keyword == 'trait':
+            bounds = node.child_by_field_name('bounds')
+            if bounds is not None:
+                metadata['supertraits'] = [normalize_signature(self._text(b)) for b in bounds.named_children
+                                           if b.type not in COMMENT_NODES]
+        
+        first = attributes[0] if attributes else node
+        chunk = CodeChunk(
+            type=keyword,
+            name=name,
+            content=self._span_text(first, node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._header(node)),
+            namespace='::'.join(module_path),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, docs)
+        chunks.append(chunk)
+        
+        # Trait bodies declare methods (with or without default bodies)
+        if keyword == 'trait' and body is not None:
+            before = len(chunks)
+            self._parse_items(body, module_path, {'kind': 'trait', 'name': name}, chunks)
+            chunk.metadata['methods'] = [c.name for c in chunks[before:] if c.type == 'method']
+    
+    def _parse_fields(self, body: Node) -> List[Dict]:
+        """Named fields of a struct or union body"""
+        fields = []
+        for field in body.named_children:
+            if field.type != 'field_declaration':
+                continue
+            name_node = field.child_by_field_name('name')
+            type_node = field.child_by_field_name('type')
+            if name_node is None or type_node is None:
+                continue
+            entry = {'name': self._text(name_node), 'type': normalize_signature(self._text(type_node))}
+            if any(c.type == 'visibility_modifier' for c in field.named_children):
+                entry['pub'] = True
+            fields.append(entry)
+        return fields
+    
+    def _parse_tuple_fields(self, body: Node) -> List[Dict]:
+        """Positional fields of a tuple struct, named by their index"""
+        fields = []
+        public = False
+        for child in body.named_children:
+            if child.type == 'visibility_modifier':
+                public = True
+            elif child.type not in COMMENT_NODES and child.type != 'attribute_item':
+                field = {'name': str(len(fields)), 'type': normalize_signature(self._text(child))}
+                if public:
+                    field['pub'] = True
+                fields.append(field)
+                public = False
+        return fields
+    
+    def _extract_impl(self, node: Node, module_path: List[str], chunks: List[CodeChunk]):
+        type_node = node.child_by_field_name('type')
+        if type_node is None:
+            return
+        attributes, docs = self._preamble(node)
+        trait_node = node.child_by_field_name('trait')
+        trait = normalize_signature(self._text(trait_node)) if trait_node is not None else None
+        target = normalize_signature(self._text(type_node))
+        target_name = base_type_name(target)
+        
+        metadata: Dict = {'target': target}
+        if trait:
+            metadata['trait'] = trait
+        generics = self._field_text(node, 'type_parameters')
+        if generics:
+            metadata['generics'] = normalize_signature(generics)
+        where = self._child_text(node, 'where_clause')
+        if where:
+            metadata['where'] = normalize_signature(where)
+        if attributes:
+            metadata['attributes'] = [self._text(a) for a in attributes]
+        
+        first = attributes[0] if attributes else node
+        chunk = CodeChunk(
+            type='impl',
+            name=target_name,
+            content=self._span_text(first, node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._header(node)),
+            namespace='::'.join(module_path),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, docs)
+        chunks.append(chunk)
+        
+        body = node.child_by_field_name('body')
+        if body is not None:
+            before = len(chunks)
+            container = {'kind': 'impl', 'name': target_name, 'trait': base_type_name(trait) if trait else None}
+            self._parse_items(body, module_path, container, chunks)
+            metadata['methods'] = [c.name for c in chunks[before:] if c.type == 'method']
+    
+    def _extract_mod(self, node: Node, module_path: List[str], chunks: List[CodeChunk]):
+        name_node = node.child_by_field_name('name')
+        body = node.child_by_field_name('body')
+        if name_node is None or body is None:
+            return  # mod name; (the module lives in its own file)
+        name = self._text(name_node)
+        attributes, docs = self._preamble(node)
+        
+        path = module_path + [name]
+        metadata: Dict = {'module_path': '::'.join(path)}
+        if attributes:
+            metadata['attributes'] = [self._text(a) for a in attributes]
+        
+        first = attributes[0] if attributes else node
+        chunk = CodeChunk(
+            type='module',
+            name=name,
+            content=self._span_text(first, node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._span_text(node, name_node)),
+            namespace='::'.join(module_path),
+            metadata=metadata
+        )
+        # Outer docs above the mod, then inner (//!) docs at the top of its body
+        self._attach_doc(chunk, docs)
+        inner = []
+        for child in body.children:
+            if child.type == '{':
+                continue
+            if child.type not in COMMENT_NODES:
+                break
+            if comment_kind(self._text(child)) == 'inner':
+                inner.append(self._text(child))
+        if inner:
+            chunk.doc = '\n\n'.join(d for d in (chunk.doc, doc_comment_text(inner)) if d)
+        chunks.append(chunk)
+        
+        self._parse_items(body, path, None, chunks)
+    
+    def _extract_macro(self, node: Node, module_path: List[str], chunks: List[CodeChunk]):
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        name = self._text(name_node)
+        attributes, docs = self._preamble(node)
+        first = attributes[0] if attributes else node
+        chunk = CodeChunk(
+            type='macro',
+            name=name,
+            content=self._span_text(first, node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(node),
+            signature=f"macro_rules! {name}",
+            namespace='::'.join(module_path),
+            metadata={}
+        )
+        self._attach_doc(chunk, docs)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Attributes and doc comments
+    # ------------------------------------------------------------------
+    
+    def _preamble(self, node: Node) -> Tuple[List[Node], List[Node]]:
+        """The #[...] attributes and outer doc comments (/// or /** */) directly above an item"""
+        attributes: List[Node] = []
+        docs: List[Node] = []
+        sibling = node.prev_sibling
+        while sibling is not None:
+            if sibling.type == 'attribute_item':
+                attributes.insert(0, sibling)
+            elif sibling.type in COMMENT_NODES and comment_kind(self._text(sibling)) == 'outer':
+                docs.insert(0, sibling)
+            else:
+                break
+            sibling = sibling.prev_sibling
+        return attributes, docs
+    
+    def _attach_doc(self, chunk: CodeChunk, docs: List[Node]):
+        """Store an item's outer doc comments; #[deprecated] is flagged in the metadata"""
+        doc = doc_comment_text([self._text(c) for c in docs])
+        if doc:
+            chunk.doc = doc
+        
+        for attribute in (chunk.metadata or {}).get('attributes', []):
+            if re.match(r'#\[\s*deprecated\b', attribute):
+                note = re.search(r'note\s*=\s*"((?:[^"\\]|\\.)*)"', attribute)
+                chunk.metadata = dict(chunk.metadata, deprecated=note.group(1) if note else 'deprecated')
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _header(self, node: Node) -> str:
+        """An item up to its body: the text its signature is made of"""
+        body = node.child_by_field_name('body')
+        if body is not None and body.type in BLOCK_BODIES:
+            return self._source[node.start_byte:body.start_byte].decode('utf8', errors='replace')
+        return self._text(node).rstrip().rstrip(';')
+    
+    def _field_text(self, node: Node, field: str) -> str:
+        child = node.child_by_field_name(field)
+        return self._text(child) if child is not None else ''
+    
+    def _child_text(self, node: Node, child_type: str) -> str:
+        """Text of the first child of a type (where_clause, visibility_modifier...), or ''"""
+        child = next((c for c in node.children if c.type == child_type), None)
+        return self._text(child) if child is not None else ''
+    
+    def _file_module_name(self, filepath: str) -> str:
+        path = PurePosixPath(filepath.replace('\\', '/'))
+        if path.stem in ('lib', 'main'):
+            return 'crate'
+        if path.stem == 'mod':
+            return path.parent.name or 'crate'
+        return path.stem
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _span_text(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._source[first.start_byte:last.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        # Line comments may end with the newline they run to
+        return node.start_point[0] + self._text(node).rstrip('\n').count('\n') + 1
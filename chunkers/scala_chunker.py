@@ -0,0 +1,663 @@
+#!/usr/bin/env python3
+"""
+Scala code chunker using tree-sitter for accurate parsing
+Supports .scala files, in the brace syntax of Scala 2 and the indentation syntax of Scala 3
+
+Class, trait, object, enum and given bodies are walked for their members;
+function and value bodies are not entered, and an `end Name` marker is part
+of the definition it closes. Implicit and given definitions record their
+keyword under 'implicit' (--filter implicit=true), anonymous givens are named
+the way the compiler names them (given_Ord_Int), and the methods of a Scala 3
+extension record their receiver and are named after it (String.shout), like
+Kotlin extensions.
+"""
+
+import re
+from bisect import bisect_right
+from dataclasses import dataclass
+from fnmatch import fnmatchcase
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+# Type definitions and the chunk type they become
+TYPE_DEFINITIONS = {
+    'class_definition': 'class',
+    'trait_definition': 'trait',
+    'object_definition': 'object',
+    'enum_definition': 'enum',
+    'package_object': 'object',
+}
+
+FUNCTIONS = ('function_definition', 'function_declaration')
+
+VALUES = ('val_definition', 'val_declaration', 'var_definition', 'var_declaration')
+
+# The keyword after a definition's annotations and modifiers
+KEYWORDS = ('class', 'trait', 'object', 'enum', 'package', 'def', 'val', 'var', 'type', 'given', 'extension')
+
+BODIES = ('template_body', 'with_template_body', 'enum_body')
+
+# Bounds of a type parameter or an abstract type: <: Upper, >: Lower, <% View, : Context
+BOUNDS = ('upper_bound', 'lower_bound', 'view_bound', 'context_bound')
+
+# Names a value definition binds: val a, b = 0 and val (key, value) = pair
+NAMES = ('identifier', 'operator_identifier')
+
+ANNOTATIONS = ('annotation',)
+
+COMMENTS = ('comment', 'block_comment')
+
+
+@dataclass
+class ScalaComment:
+    """A comment with its line span"""
+    text: str
+    start: int
+    end: int
+    line_start: int
+    line_end: int
+    
+    @property
+    def is_scaladoc(self) -> bool:
+        return self.text.startswith('/**') and self.text != '/**/'
+
+
+def scaladoc_text(comment: ScalaComment) -> str:
+    """Text of a Scaladoc comment without the /** */ markers and leading asterisks"""
+    lines = []
+    for line in comment.text[3:-2].splitlines():
+        line = line.strip()
+        if line.startswith('*'):
+            line = line[1:]
+            line = line[1:] if line.startswith(' ') else line
+        lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)\]])|([(\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def receiver_name(receiver: str) -> str:
+    """Type an extension is declared on, without type arguments (List[T] -> List)"""
+    return re.sub(r'\[.*\]', '', receiver).strip()
+
+
+def strip_backticks(name: str) -> str:
+    """Name of a `quoted identifier` (test names and Java keywords are often quoted)"""
+    return name[1:-1] if name.startswith('`') and name.endswith('`') else name
+
+
+def matches_implicit(metadata: Dict, pattern: str) -> bool:
+    """
+    True if a chunk is an implicit or given definition and pattern is 'true' (the
+    rest match 'false'), or if pattern matches its keyword: implicit or given
+    """
+    keyword = metadata.get('implicit')
+    if pattern.lower() in ('true', 'false'):
+        return str(bool(keyword)).lower() == pattern.lower()
+    return isinstance(keyword, str) and fnmatchcase(keyword, pattern)
+
+
+class ScalaChunker(BaseChunker):
+    """Extracts classes, traits, objects, enums, givens, methods and values from Scala code"""
+    
+    def __init__(self):
+        super().__init__('scala')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('scala')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Scala code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        comments = self._collect_comments(tree.root_node)
+        self._doc_by_end = {c.end: c for c in comments if c.is_scaladoc}
+        self._comment_ends = sorted(c.end for c in comments)
+        self._package = ''
+        chunks: List[CodeChunk] = []
+        
+        self._parse_statements(tree.root_node, [], None, chunks)
+        
+        # An object next to the class or trait of the same name is its companion
+        types = {(c.parent, c.name) for c in chunks if c.type in ('class', 'trait', 'enum')}
+        for chunk in chunks:
+            if chunk.type == 'object' and (chunk.parent, chunk.name) in types:
+                chunk.metadata['companion'] = True
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Members
+    # ------------------------------------------------------------------
+    
+    def _parse_statements(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk],
+                          extension: Optional[Dict] = None):
+        """Package clauses and definitions of a file, a package block or a template body"""
+        for child in node.named_children:
+            if child.type == 'ERROR':
+                self._parse_statements(child, parents, owner, chunks, extension)
+            elif child.type == 'package_clause':
+                self._parse_package(child, parents, owner, chunks)
+            elif child.type in TYPE_DEFINITIONS:
+                self._extract_type(child, parents, chunks)
+            elif child.type in FUNCTIONS:
+                self._extract_function(child, parents, owner, chunks, extension)
+            elif child.type in VALUES:
+                self._extract_value(child, parents, owner, chunks)
+            elif child.type == 'type_definition':
+                self._extract_alias(child, parents, owner, chunks)
+            elif child.type == 'given_definition':
+                self._extract_given(child, parents, owner, chunks)
+            elif child.type == 'extension_definition':
+                self._parse_extension(child, parents, owner, chunks)
+            elif child.type == 'enum_case_definitions' and owner is not None:
+                # case Red, Green and case Ok(value: A) extends Result[A]
+                for case in child.named_children:
+                    name = case.child_by_field_name('name') or next(
+                        (c for c in case.named_children if c.type in NAMES), None)
+                    if name is not None:
+                        owner.setdefault('constants', []).append(strip_backticks(self._text(name)))
+            elif child.type == 'self_type' and owner is not None:
+                # A self type opens the body: { self: Logging => ... }
+                parts = [c for c in child.children if c.type not in ('=>',) + COMMENTS]
+                if parts:
+                    owner['self_type'] = normalize_signature(self._span(parts[0], parts[-1]))
+            # Anything else (imports, exports, statements of an object body) is skipped
+    
+    def _parse_package(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        """package a.b, possibly chained with the next package clause, or package a.b { ... }"""
+        name_node = node.child_by_field_name('name') or next(
+            (c for c in node.named_children if c.type in ('package_identifier',) + NAMES), None)
+        if name_node is None:
+            return
+        name = re.sub(r'\s+', '', self._text(name_node))
+        package = f"{self._package}.{name}" if self._package else name
+        body = node.child_by_field_name('body') or next((c for c in node.children if c.type in BODIES), None)
+        if body is not None:
+            # The package of its block only
+            outer, self._package = self._package, package
+            self._parse_statements(body, parents, owner, chunks)
+            self._package = outer
+        else:
+            self._package = package
+    
+    def _extract_type(self, node: Node, parents: List[str], chunks: List[CodeChunk]):
+        """Classes, case classes, traits, objects, enums and package objects"""
+        kind = TYPE_DEFINITIONS[node.type]
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        name = strip_backticks(self._text(name_node))
+        annotations, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        
+        type_params = node.child_by_field_name('type_parameters')
+        if type_params is not None:
+            self._type_params(type_params, metadata)
+        
+        # Constructor, possibly with an access modifier: class A private (x: Int)(implicit y: Y)
+        children = [c for c in node.children if c.type not in COMMENTS]
+        constructor_modifiers = [
+            re.sub(r'\s+', '', self._text(c)) for c in children
+            if c.start_byte > name_node.start_byte and c.type in ('access_modifier', 'modifiers')
+        ]
+        parameter_lists = [c for c in children if c.type == 'class_parameters']
+        if parameter_lists:
+            if constructor_modifiers:
+                metadata['constructor_modifiers'] = constructor_modifiers
+            params = []
+            for parameter_list in parameter_lists:
+                # The parameters of a case class's first list are its fields
+                params += self._parse_params(parameter_list, properties='case' in modifiers and not params)
+            metadata['params'] = params
+        
+        # Parents: class A extends Base(x) with Api derives Eq, Show
+        extends = node.child_by_field_name('extend') or next(
+            (c for c in children if c.type == 'extends_clause'), None)
+        if extends is not None:
+            supertypes = []
+            for group in self._split(extends.children, (',', 'with', 'extends')):
+                if group[0].type in BODIES + ('block',):
+                    continue  # early definitions: extends { val x = 1 } with Base
+                parts = [p for p in group if p.type != 'arguments']
+                if parts:
+                    supertypes.append(normalize_signature(self._span(parts[0], parts[-1])))
+            metadata['supertypes'] = supertypes
+        derives = node.child_by_field_name('derive') or next(
+            (c for c in children if c.type == 'derives_clause'), None)
+        if derives is not None:
+            metadata['derives'] = [normalize_signature(self._span(g[0], g[-1]))
+                                   for g in self._split(derives.children, (',', 'derives'))]
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        if 'implicit' in modifiers:
+            metadata['implicit'] = 'implicit'
+            params = metadata.get('params', [])
+            if kind == 'class' and len(params) == 1:
+                metadata['receiver'] = params[0]['type']  # implicit class StringOps(s: String)
+        if node.type == 'package_object':
+            metadata['package_object'] = True
+        
+        body = node.child_by_field_name('body') or next((c for c in children if c.type in BODIES), None)
+        header_end = self._header_end(children, body.start_byte if body is not None else node.end_byte)
+        last = self._last(node)
+        chunk = self._chunk(kind, name, node, last, self._signature(node, header_end), parents, metadata)
+        self._attach_doc(chunk, node.start_byte, annotations)
+        chunks.append(chunk)
+        
+        members_parents = parents + [name]
+        properties = [p['name'] for p in metadata.get('params', []) if p.get('property')]
+        if body is not None:
+            owner = {'kind': chunk.type, 'name': name}
+            before = len(chunks)
+            self._parse_statements(body, members_parents, owner, chunks)
+            if owner.get('self_type'):
+                metadata['self_type'] = owner['self_type']
+            if owner.get('constants'):
+                metadata['constants'] = owner['constants']
+            members = [c for c in chunks[before:] if c.parent == '.'.join(members_parents)]
+            metadata['methods'] = [c.name for c in members if c.type == 'method']
+            properties += [c.name for c in members if c.type == 'property']
+        if properties:
+            metadata['properties'] = properties
+    
+    def _extract_function(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk],
+                          extension: Optional[Dict] = None):
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        annotations, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        
+        if self._text(name_node) == 'this' and owner is not None:
+            name = owner['name']  # def this(x: Int) = this(x, 0)
+            metadata['constructor'] = True
+        else:
+            name = strip_backticks(self._text(name_node))
+        type_params = node.child_by_field_name('type_parameters')
+        if type_params is not None:
+            self._type_params(type_params, metadata)
+        children = [c for c in node.children if c.type not in COMMENTS]
+        params = []
+        for parameter_list in (c for c in children if c.type == 'parameters'):
+            params += self._parse_params(parameter_list)
+        metadata['params'] = params
+        
+        # : ReturnType, then '= body', a procedure body '{' (Scala 2) or nothing (abstract)
+        returns = node.child_by_field_name('return_type')
+        if returns is not None:
+            metadata['returns'] = normalize_signature(self._text(returns))
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        if 'implicit' in modifiers:
+            metadata['implicit'] = 'implicit'
+        if node.type == 'function_declaration' and owner is not None:
+            metadata['abstract'] = True
+        
+        body = node.child_by_field_name('body')
+        equals = next((c for c in children if c.type == '='), None)
+        stop = equals if equals is not None else body
+        signature = self._signature(node, self._header_end(children, stop.start_byte if stop else node.end_byte))
+        parent = None
+        if extension is not None:
+            metadata['receiver'] = extension['receiver']
+            signature = f"{extension['header']} {signature}"
+            if owner is None:
+                # Top-level extension methods are found by their receiver: String.shout
+                parent = receiver_name(extension['receiver'])
+        chunk = self._chunk('method' if owner is not None else 'function', name, node, self._last(node), signature,
+                            parents, metadata, parent=parent)
+        self._attach_doc(chunk, node.start_byte, annotations)
+        if not chunk.doc and extension is not None and extension['line'] == self._line(node):
+            self._attach_doc(chunk, extension['start'], [])  # extension (s: String) def shout = ...
+        chunks.append(chunk)
+    
+    def _extract_value(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        annotations, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        if node.type.startswith('var_'):
+            metadata['mutable'] = True
+        
+        # val a, b: Int = 0; val (key, value) = pair binds both; other patterns are skipped
+        targets = node.children_by_field_name('pattern') or node.children_by_field_name('name')
+        if len(targets) == 1 and targets[0].type in ('identifiers', 'tuple_pattern'):
+            targets = [c for c in targets[0].named_children if c.type not in COMMENTS]
+        if not targets or any(t.type not in NAMES for t in targets):
+            return
+        names = [strip_backticks(self._text(t)) for t in targets]
+        if names[0] == '_':
+            return
+        if len(names) > 1:
+            metadata['names'] = names
+        
+        signature_end = targets[-1].end_byte
+        if targets[-1].next_sibling is not None and targets[-1].next_sibling.type == ')':
+            signature_end = targets[-1].next_sibling.end_byte  # val (lo, hi)
+        type_node = node.child_by_field_name('type')
+        if type_node is not None:
+            metadata['property_type'] = normalize_signature(self._text(type_node))
+            signature_end = type_node.end_byte
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        if 'implicit' in modifiers:
+            metadata['implicit'] = 'implicit'
+        if node.type.endswith('_declaration') and owner is not None:
+            metadata['abstract'] = True
+        
+        chunk = self._chunk('property', names[0], node, self._last(node), self._signature(node, signature_end),
+                            parents, metadata)
+        self._attach_doc(chunk, node.start_byte, annotations)
+        chunks.append(chunk)
+    
+    def _extract_alias(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        """type Handler = Event => Unit, and abstract types with their bounds: type Output <: Product"""
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        annotations, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        type_params = node.child_by_field_name('type_parameters')
+        if type_params is not None:
+            self._type_params(type_params, metadata)
+        bounds = [c for c in node.children if c.type in BOUNDS]
+        if bounds:
+            metadata['bounds'] = normalize_signature(self._span(bounds[0], bounds[-1]))  # <: Upper
+        aliased = node.child_by_field_name('type')
+        if aliased is not None:
+            metadata['aliased'] = normalize_signature(self._text(aliased))
+        elif owner is not None:
+            metadata['abstract'] = True
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        chunk = self._chunk('alias', strip_backticks(self._text(name_node)), node, node,
+                            self._signature(node, node.end_byte), parents, metadata)
+        self._attach_doc(chunk, node.start_byte, annotations)
+        chunks.append(chunk)
+    
+    def _extract_given(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        """
+        given intOrd: Ord[Int] with { ... }, given [T](using Ord[T]): Ord[List[T]] with ...,
+        given ec: ExecutionContext = ...; an anonymous given is named after its type
+        """
+        annotations, modifiers = self._modifiers(node)
+        metadata: Dict = {'implicit': 'given'}
+        name_node = node.child_by_field_name('name')
+        children = [c for c in node.children if c.type not in COMMENTS]
+        start = next((k + 1 for k, c in enumerate(children) if c.type == 'given'), 0)
+        
+        # The given type runs to 'with' (or the body) before a body, or to '= value'
+        params, type_nodes = [], []
+        stop = None
+        colon = False
+        for child in children[start:]:
+            if child.type in ('with', '=') or child.type in BODIES:
+                stop = child
+                break
+            if type_nodes:
+                type_nodes.append(child)
+            elif name_node is not None and child.start_byte == name_node.start_byte:
+                continue
+            elif child.type == 'type_parameters':
+                self._type_params(child, metadata)
+            elif child.type == 'parameters':
+                params += self._parse_params(child)
+            elif child.type == ':' and not colon:
+                colon = True
+            else:
+                type_nodes.append(child)
+        if params:
+            metadata['params'] = params
+        if type_nodes:
+            metadata['given_type'] = normalize_signature(self._span(type_nodes[0], type_nodes[-1]))
+        if name_node is not None:
+            name = strip_backticks(self._text(name_node))
+        else:
+            # The compiler's name for it: given Ord[List[T]] is given_Ord_List
+            own = {p.split(':')[0].strip().lstrip('+-') for p in
+                   re.split(r',(?![^\[]*\])', metadata.get('type_params', '[]')[1:-1])}
+            leaves = [leaf for n in type_nodes for leaf in self._leaves(n)]
+            words = [self._text(t) for k, t in enumerate(leaves)
+                     if t.type in ('type_identifier', 'identifier') and self._text(t) not in own
+                     and not (k + 1 < len(leaves) and leaves[k + 1].type == '.')]
+            name = '_'.join(['given'] + words)
+        
+        body = node.child_by_field_name('body')
+        if body is None or body.type not in BODIES:
+            body = next((c for c in children if c.type in BODIES), None)
+        if annotations:
+            metadata['annotations'] = annotations
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        if body is not None:
+            kind = 'object'
+        elif params or 'type_params' in metadata:
+            kind = 'method' if owner is not None else 'function'
+        else:
+            kind = 'property'
+        header_end = self._header_end(children, stop.start_byte if stop is not None else node.end_byte)
+        chunk = self._chunk(kind, name, node, self._last(node), self._signature(node, header_end), parents, metadata)
+        self._attach_doc(chunk, node.start_byte, annotations)
+        chunks.append(chunk)
+        
+        if body is not None:
+            before = len(chunks)
+            self._parse_statements(body, parents + [name], {'kind': 'object', 'name': name}, chunks)
+            members = [c for c in chunks[before:] if c.parent == '.'.join(parents + [name])]
+            metadata['methods'] = [c.name for c in members if c.type == 'method']
+    
+    def _parse_extension(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        """extension [T](xs: List[T]) followed by one method, an indented block of them or a braced one"""
+        children = [c for c in node.children if c.type not in COMMENTS]
+        parameter_lists = [c for c in children if c.type == 'parameters']
+        if not parameter_lists:
+            return
+        params = self._parse_params(parameter_lists[0])
+        extension = {
+            'receiver': params[0]['type'] if params else '', 'start': node.start_byte, 'line': self._line(node),
+            'header': normalize_signature(self._span(children[0], parameter_lists[-1])),
+        }
+        for child in children:
+            if child.end_byte <= parameter_lists[-1].end_byte or not child.is_named:
+                continue
+            if child.type in FUNCTIONS:
+                self._extract_function(child, parents, owner, chunks, extension)
+            else:
+                self._parse_statements(child, parents, owner, chunks, extension)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _chunk(self, type: str, name: str, first: Node, last: Node, signature: str, parents: List[str],
+               metadata: Dict, parent: Optional[str] = None) -> CodeChunk:
+        """Chunk of the definition from the start of first to the end of last, in the current package"""
+        metadata['package'] = self._package
+        parent = parent or '.'.join(parents) or None
+        return CodeChunk(
+            type=type,
+            name=name,
+            content=self._span(first, last),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(last),
+            signature=signature,
+            namespace=self._package,
+            parent_class=parent.rsplit('.', 1)[-1] if parent else None,
+            parent=parent,
+            metadata=metadata
+        )
+    
+    def _parse_params(self, node: Node, properties: bool = False) -> List[Dict]:
+        """
+        (name, type) pairs of a parameter list; val/var parameters (and, with
+        properties, all of them) are properties, implicit and using ones are marked
+        """
+        children = [c for c in node.children if c.type not in COMMENTS]
+        implicit = any(c.type in ('implicit', 'using') for c in children)
+        params = []
+        for child in children:
+            param: Dict = {}
+            if child.type in ('parameter', 'class_parameter'):
+                kind = next((c for c in child.children if c.type in ('val', 'var')), None)
+                if kind is not None:
+                    param['property'] = kind.type
+                elif properties:
+                    param['property'] = 'val'
+                name = child.child_by_field_name('name')
+                type_node = child.child_by_field_name('type')
+                if name is None or type_node is None:
+                    continue
+                param = dict(name=strip_backticks(self._text(name)), type=normalize_signature(self._text(type_node)),
+                             **param)
+            elif implicit and child.is_named and child.type not in ANNOTATIONS:
+                param = {'type': normalize_signature(self._text(child))}  # using Ord[T]
+            else:
+                continue
+            if param['type'].endswith('*'):
+                param['type'] = param['type'][:-1].rstrip()
+                param['vararg'] = True
+            if implicit:
+                param['implicit'] = True
+            params.append(param)
+        return params
+    
+    def _type_params(self, node: Node, metadata: Dict):
+        """Record a type parameter clause [K, V: Ordering] and its context bounds (V: Ordering)"""
+        metadata['type_params'] = normalize_signature(self._text(node))
+        bounds = []
+        for group in self._split(node.children, (',', '[', ']')):
+            # A variance annotation may wrap the parameter: +A, -B
+            while len(group) == 1 and group[0].type not in NAMES + ('type_identifier',) and group[0].children:
+                group = [c for c in group[0].children if c.type not in ('+', '-') + COMMENTS]
+            names = []
+            for k, child in enumerate(group):
+                if child.type in BOUNDS or child.type == ':':
+                    break
+                if child.type not in ANNOTATIONS + ('+', '-'):
+                    names.append(child)
+            if not names:
+                continue
+            name = normalize_signature(self._span(names[0], names[-1]))
+            for k, child in enumerate(group):
+                if child.type == 'context_bound':
+                    parts = [c for c in child.children if c.type not in (':',) + COMMENTS]
+                elif child.type == ':' and k + 1 < len(group):
+                    parts = [group[k + 1]]
+                else:
+                    continue
+                if parts:
+                    bounds.append(f"{name}: {normalize_signature(self._span(parts[0], parts[-1]))}")
+        if bounds:
+            metadata['context_bounds'] = bounds
+    
+    def _modifiers(self, node: Node) -> Tuple[List[str], List[str]]:
+        """Annotations and modifiers before a definition's keyword"""
+        annotations, modifiers = [], []
+        for child in node.children:
+            if child.type in KEYWORDS:
+                break
+            if child.type in ANNOTATIONS:
+                annotations.append(normalize_signature(self._text(child)))
+            elif child.type == 'modifiers':
+                # private[app], protected[this]
+                modifiers += [re.sub(r'\s+', '', self._text(c)) for c in child.children if c.type not in COMMENTS]
+            elif child.type not in COMMENTS:
+                modifiers.append(re.sub(r'\s+', '', self._text(child)))
+        return annotations, modifiers
+    
+    def _split(self, nodes: List[Node], separators: Tuple[str, ...]) -> List[List[Node]]:
+        """Nodes split at the separator children; empty groups dropped"""
+        groups, current = [], []
+        for node in nodes:
+            if node.type in separators:
+                groups.append(current)
+                current = []
+            elif node.type not in COMMENTS:
+                current.append(node)
+        groups.append(current)
+        return [g for g in groups if g]
+    
+    def _leaves(self, node: Node) -> List[Node]:
+        """The tokens of a node in source order; comments and empty MISSING nodes left out"""
+        if node.type in COMMENTS:
+            return []
+        if not node.children:
+            return [node] if node.end_byte > node.start_byte else []
+        return [leaf for child in node.children for leaf in self._leaves(child)]
+    
+    def _header_end(self, children: List[Node], stop: int) -> int:
+        """End offset of the last child before stop: a header without its '=', 'with' or body"""
+        before = [c for c in children if c.end_byte <= stop]
+        if before and before[-1].type == ':':
+            before.pop()  # class A: with an indented body
+        return before[-1].end_byte if before else stop
+    
+    def _last(self, node: Node) -> Node:
+        """The node, or the `end Name` marker closing it"""
+        sibling = node.next_sibling
+        return sibling if sibling is not None and sibling.type == 'end_marker' else node
+    
+    def _signature(self, node: Node, header_end: int) -> str:
+        """Normalized text from the first modifier after the annotations to header_end"""
+        start = next((c.start_byte for c in node.children if c.type not in ANNOTATIONS + COMMENTS),
+                     node.start_byte)
+        return normalize_signature(self._decode(start, max(header_end, start)))
+    
+    def _collect_comments(self, root: Node) -> List[ScalaComment]:
+        """Every comment of the file in source order"""
+        comments = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type in COMMENTS:
+                comments.append(ScalaComment(self._text(node), node.start_byte, node.end_byte,
+                                             self._line(node), self._line_end(node)))
+            else:
+                stack.extend(node.children)
+        return sorted(comments, key=lambda c: c.start)
+    
+    def _attach_doc(self, chunk: CodeChunk, start: int, annotations: List[str]):
+        """Scaladoc directly before the definition (or its annotations); records @deprecated"""
+        position = bisect_right(self._comment_ends, start) - 1
+        comment = self._doc_by_end.get(self._comment_ends[position]) if position >= 0 else None
+        if comment and not self._decode(comment.end, start).strip():
+            chunk.doc = scaladoc_text(comment)
+        
+        deprecated = next((a for a in annotations if re.match(r'@(?:scala\.|java\.lang\.)?[dD]eprecated\b', a)), None)
+        if deprecated:
+            message = re.search(r'"((?:[^"\\]|\\.)*)"', deprecated)
+            chunk.metadata['deprecated'] = message.group(1) if message else 'deprecated'
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
@@ -0,0 +1,789 @@
+#!/usr/bin/env python3
+"""
+SQL chunker splitting schema and query files into statements
+Supports .sql files
+
+The source is lexed by tree-sitter-sql, so string literals, quoted identifiers,
+comments and dollar-quoted bodies are single tokens, and statements are split
+on the ';' tokens outside BEGIN ... END blocks of procedure bodies. Splitting
+on tokens rather than the grammar's statements keeps the dialects it doesn't
+parse (MySQL DELIMITER scripts, T-SQL bodies) chunked statement by statement. CREATE TABLE/VIEW/FUNCTION/PROCEDURE/INDEX/
+TRIGGER/TYPE statements become named chunks with their columns, parameters
+and referenced tables in the metadata; other statements are chunked under
+their verb, or under the name an sqlc-style '-- name: GetUser :one' comment
+gives them. The '--' comment block above a statement becomes its doc.
+"""
+
+import re
+from bisect import bisect_right
+from dataclasses import dataclass, field
+from typing import Callable, Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error
+
+
+# Operators, longest first so the scanner picks the longest match
+SQL_OPERATORS = [
+    '::', '<>', '<=', '>=', '!=', '||', '->>', '->', '#>>', '#>', '@>', '<@', ':=', '=>',
+] + list('+-*/%^!&|=<>@.,;:#?~()[]{}')
+SQL_OPERATORS.sort(key=len, reverse=True)
+
+# Statements that declare a schema object: CREATE <object>
+OBJECT_CHUNK_TYPES = {
+    'TABLE': 'table', 'VIEW': 'view', 'FUNCTION': 'function', 'PROCEDURE': 'procedure',
+    'INDEX': 'index', 'TRIGGER': 'trigger', 'TYPE': 'type', 'DOMAIN': 'type',
+}
+OBJECT_KEYWORDS = set(OBJECT_CHUNK_TYPES) | {'SCHEMA', 'SEQUENCE', 'EXTENSION', 'DATABASE', 'ROLE', 'USER', 'POLICY'}
+
+# Bodies of these may hold BEGIN ... END blocks with ';' inside (MySQL, T-SQL, SQL standard)
+ROUTINE_OBJECTS = {'FUNCTION', 'PROCEDURE', 'TRIGGER', 'EVENT'}
+
+# END followed by one of these closes a statement it opened itself, not a BEGIN
+END_QUALIFIERS = {'IF', 'LOOP', 'WHILE', 'REPEAT', 'FOR'}
+
+# Transaction control is not worth a chunk
+SKIPPED_STATEMENTS = {'BEGIN', 'COMMIT', 'ROLLBACK', 'START', 'END', 'SAVEPOINT', 'RELEASE'}
+
+# Words that end a column's type in CREATE TABLE
+COLUMN_CONSTRAINTS = {
+    'NOT', 'NULL', 'DEFAULT', 'PRIMARY', 'REFERENCES', 'UNIQUE', 'CHECK', 'CONSTRAINT', 'GENERATED',
+    'COLLATE', 'AUTO_INCREMENT', 'AUTOINCREMENT', 'IDENTITY', 'COMMENT', 'ON',
+}
+TABLE_CONSTRAINTS = {'CONSTRAINT', 'PRIMARY', 'FOREIGN', 'UNIQUE', 'CHECK', 'EXCLUDE', 'KEY', 'INDEX',
+                     'FULLTEXT', 'SPATIAL', 'LIKE', 'PERIOD'}
+
+# Words that end a function's RETURNS clause
+ROUTINE_OPTIONS = {
+    'AS', 'LANGUAGE', 'IMMUTABLE', 'STABLE', 'VOLATILE', 'STRICT', 'SECURITY', 'CALLED', 'PARALLEL',
+    'COST', 'ROWS', 'SET', 'BEGIN', 'DETERMINISTIC', 'NOT', 'READS', 'MODIFIES', 'NO', 'CONTAINS',
+    'COMMENT', 'RETURN', 'WINDOW', 'LEAKPROOF', 'SUPPORT', 'EXTERNAL', 'TRANSFORM',
+}
+
+# Words that can't be a table alias (FROM users u, posts p)
+CLAUSE_KEYWORDS = {
+    'WHERE', 'JOIN', 'LEFT', 'RIGHT', 'INNER', 'OUTER', 'FULL', 'CROSS', 'NATURAL', 'ON', 'USING',
+    'GROUP', 'ORDER', 'HAVING', 'LIMIT', 'OFFSET', 'SET', 'VALUES', 'RETURNING', 'UNION', 'INTERSECT',
+    'EXCEPT', 'WINDOW', 'FOR', 'AS', 'SELECT', 'DEFAULT', 'LATERAL', 'ONLY', 'FETCH', 'WHEN', 'THEN',
+    'AND', 'OR', 'TABLESAMPLE', 'OVERRIDING', 'DO', 'CONFLICT',
+}
+
+# sqlc (-- name: GetUser :one) and HugSQL (-- :name get-user :? :1) named queries
+SQLC_NAME = re.compile(r'^\s*name:\s*(\S+)(?:\s+:(\w+))?')
+HUGSQL_NAME = re.compile(r'^\s*:name\s+(\S+)(?:\s+(:\S+))?')
+
+# goose and dbmate migration directives
+GOOSE_DIRECTIVE = re.compile(r'^\s*\+goose\s+(\w+)', re.IGNORECASE)
+DBMATE_DIRECTIVE = re.compile(r'^\s*migrate:(up|down)\b', re.IGNORECASE)
+
+DOLLAR_QUOTE = re.compile(r'\$([A-Za-z_][A-Za-z0-9_]*)?\$')
+DOLLAR_BODY = re.compile(rb'\$([A-Za-z_][A-Za-z0-9_]*|)\$(.*)\$\1\$', re.DOTALL)
+# String literals (E'...' with backslash escapes) and quoted identifiers
+LITERAL = re.compile(rb"""[eE]'(?:[^'\\]|\\.|'')*'|(?:[nNbBxX]|[uU]&)?'(?:[^']|'')*'|"(?:[^"]|"")*"|`(?:[^`]|``)*`""", re.DOTALL)
+WORD = re.compile(r'[A-Za-z_][A-Za-z0-9_$]*')
+NUMBER = re.compile(r'\d+(?:\.\d*)?(?:[eE][+-]?\d+)?|\.\d+')
+
+# Statements longer than this are cut short in their signature
+MAX_SIGNATURE_LENGTH = 100
+
+
+@dataclass
+class SqlToken:
+    """A single SQL token with its source span"""
+    kind: str  # word, ident (quoted identifier), string, dollar (dollar-quoted body), dollar_tag, number, op, delimiter
+    value: str
+    start: int
+    end: int
+    line: int
+    inner: List['SqlToken'] = field(default_factory=list)  # the tokens of a dollar-quoted body
+    
+    @property
+    def keyword(self) -> Optional[str]:
+        """The token as an upper-case keyword, if it is an unquoted word"""
+        return self.value.upper() if self.kind == 'word' else None
+
+
+@dataclass
+class SqlComment:
+    """A comment; own_line when nothing but whitespace precedes it"""
+    text: str
+    start: int
+    line: int
+    own_line: bool
+
+
+class SqlTokenReader:
+    """
+    Reads the tokens of a tree-sitter-sql tree in source order
+    
+    The leaves of the tree are the tokens; literals and quoted names are taken
+    whole. Text the grammar could not lex (an unterminated literal) is kept as
+    tokens and reported as a parse error. The tree's statements are not used:
+    the grammar knows one dialect, and the statements of others come out as
+    ERROR nodes whose tokens are still right.
+    """
+    
+    def __init__(self, source: bytes, parse: Callable[[bytes], Node]):
+        self.source = source
+        self.parse = parse
+        self.line_starts = [0] + [m.end() for m in re.finditer(b'\n', source)]
+        self.tokens: List[SqlToken] = []
+        self.comments: List[SqlComment] = []
+        self.diagnostics: List[Dict] = []
+    
+    def line_of(self, offset: int) -> int:
+        return bisect_right(self.line_starts, offset)
+    
+    def read(self) -> 'SqlTokenReader':
+        source = self.source
+        delimiter = b';'
+        statement_start = True
+        position = 0
+        for start, end in self._spans(self.parse(source)):
+            if start < position:
+                continue  # inside a DELIMITER line or a delimiter spanning several leaves
+            gap = source[position:start]
+            if gap.strip():
+                offset = position + len(gap) - len(gap.lstrip())
+                self.diagnostics.append(parse_error(self.line_of(offset), "unrecognized text"))
+                for piece in re.finditer(rb'\S+', gap):
+                    self._add(position + piece.start(), position + piece.end())
+            position = end
+            text = source[start:end]
+            
+            # MySQL client scripts change the delimiter to write procedure bodies: DELIMITER //
+            if statement_start and text.upper() == b'DELIMITER' and source[end:end + 1] in (b' ', b'\t'):
+                line_end = source.find(b'\n', end)
+                line_end = len(source) if line_end < 0 else line_end
+                delimiter = source[end:line_end].strip() or b';'
+                position = line_end
+                continue
+            if source.startswith(delimiter, start):
+                self.tokens.append(SqlToken('delimiter', delimiter.decode('utf8', errors='replace'), start,
+                                            start + len(delimiter), self.line_of(start)))
+                position = max(end, start + len(delimiter))
+                statement_start = True
+                continue
+            if text.startswith((b'--', b'/*')):
+                if text.startswith(b'--'):
+                    line = self.line_of(start)
+                    own_line = not source[self.line_starts[line - 1]:start].strip()
+                    comment = text[2:].rstrip(b'\r\n').decode('utf8', errors='replace')
+                    self.comments.append(SqlComment(comment, start, line, own_line))
+                continue
+            statement_start = False
+            
+            if re.search(rb'\s', text) and not LITERAL.fullmatch(text) and not DOLLAR_BODY.fullmatch(text):
+                # A leaf of several words (a multi-word keyword): one token per word
+                for piece in re.finditer(rb'\S+', text):
+                    self._add(start + piece.start(), start + piece.end())
+            else:
+                self._add(start, end)
+        
+        if source[position:].strip():
+            self.diagnostics.append(parse_error(self.line_of(len(source) - 1), "unrecognized text"))
+            for piece in re.finditer(rb'\S+', source[position:]):
+                self._add(position + piece.start(), position + piece.end())
+        return self
+    
+    def _spans(self, root: Node) -> List[Tuple[int, int]]:
+        """(start, end) of the leaves under root, literals and quoted names whole"""
+        spans = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.end_byte <= node.start_byte:
+                continue  # MISSING nodes
+            text = self.source[node.start_byte:node.end_byte]
+            if not node.children or LITERAL.fullmatch(text) or DOLLAR_BODY.fullmatch(text):
+                spans.append((node.start_byte, node.end_byte))
+            else:
+                stack.extend(reversed(node.children))
+        return spans
+    
+    def _add(self, start: int, end: int):
+        raw = self.source[start:end]
+        text = raw.decode('utf8', errors='replace')
+        kind, value, inner = 'op', text, []
+        body = DOLLAR_BODY.fullmatch(raw)
+        if body:
+            # A $tag$ ... $tag$ body the grammar took whole: its tokens are read on their own
+            kind, value = 'dollar', body.group(2).decode('utf8', errors='replace')
+            inner = SqlTokenReader(body.group(2), self.parse).read().tokens
+        elif DOLLAR_QUOTE.fullmatch(text):
+            kind = 'dollar_tag'
+        elif text[:1] in ('"', '`'):
+            quote = text[0]
+            kind, value = 'ident', text[1:-1].replace(quote * 2, quote) if len(text) > 1 and text.endswith(quote) \
+                else text[1:]
+        elif text[:1] == "'" or LITERAL.fullmatch(raw):
+            kind = 'string'
+        elif WORD.fullmatch(text):
+            kind = 'word'
+        elif NUMBER.fullmatch(text):
+            kind = 'number'
+        self.tokens.append(SqlToken(kind, value, start, end, self.line_of(start), inner))
+
+
+def read_tokens(source: bytes, parse: Callable[[bytes], Node]) -> Tuple[List[SqlToken], List[SqlComment], List[Dict]]:
+    """
+    The tokens of SQL source, as tree-sitter-sql lexes it
+    
+    Returns:
+        Tuple of (tokens, comments, parse_error diagnostics); comments are kept
+        out of the token stream and statement delimiters are 'delimiter' tokens
+    """
+    reader = SqlTokenReader(source, parse).read()
+    return reader.tokens, reader.comments, reader.diagnostics
+
+
+@dataclass
+class SqlStatement:
+    """The tokens of one statement, without its delimiter"""
+    tokens: List[SqlToken]
+    end: int  # offset after the delimiter, or after the last token
+    migration: Optional[str] = None  # 'up' or 'down' inside goose/dbmate migration sections
+
+
+def split_statements(tokens: List[SqlToken], comments: List[SqlComment]) -> List[SqlStatement]:
+    """
+    Group tokens into statements at delimiters outside BEGIN ... END blocks,
+    dollar-quoted bodies and goose StatementBegin/StatementEnd sections
+    """
+    statements: List[SqlStatement] = []
+    current: List[SqlToken] = []
+    migration = None
+    held = False
+    depth = 0
+    routine = False
+    quoted = None  # the $tag$ of the body the tokens are in
+    pending = iter(sorted(comments, key=lambda c: c.start))
+    comment = next(pending, None)
+    
+    def finish(end: int):
+        nonlocal current, depth, routine, quoted
+        if current:
+            statements.append(SqlStatement(current, end, migration))
+        current, depth, routine, quoted = [], 0, False, None
+    
+    for index, tok in enumerate(tokens):
+        while comment is not None and comment.start < tok.start:
+            goose = GOOSE_DIRECTIVE.match(comment.text)
+            dbmate = DBMATE_DIRECTIVE.match(comment.text)
+            directive = (goose.group(1).lower() if goose else '') or (dbmate.group(1).lower() if dbmate else '')
+            if directive in ('up', 'down'):
+                finish(current[-1].end if current else comment.start)
+                migration = directive
+            elif directive == 'statementbegin':
+                held = True
+            elif directive == 'statementend':
+                held = False
+                finish(current[-1].end if current else comment.start)
+            comment = next(pending, None)
+        
+        if tok.kind == 'delimiter':
+            if depth == 0 and not held and quoted is None:
+                finish(tok.end)
+            elif current:
+                current.append(tok)
+            continue
+        current.append(tok)
+        
+        # Bodies the grammar splits into tokens: $body$ ... $body$
+        if tok.kind == 'dollar_tag':
+            quoted = tok.value if quoted is None else (None if tok.value == quoted else quoted)
+            continue
+        if quoted is not None:
+            continue
+        keyword = tok.keyword
+        if len(current) <= 8 and keyword in ROUTINE_OBJECTS and current[0].keyword == 'CREATE':
+            routine = True
+        elif routine and keyword in ('BEGIN', 'CASE') and not (index and tokens[index - 1].keyword == 'END'):
+            depth += 1
+        elif routine and keyword == 'END' and depth:
+            following = tokens[index + 1].keyword if index + 1 < len(tokens) else None
+            if following not in END_QUALIFIERS:
+                depth -= 1
+    
+    finish(current[-1].end if current else 0)
+    return statements
+
+
+def qualified_name_at(tokens: List[SqlToken], i: int) -> Tuple[List[str], int]:
+    """A dotted name (schema.table) starting at tokens[i], as its parts, and the index after it"""
+    parts = []
+    while i < len(tokens) and tokens[i].kind in ('word', 'ident'):
+        parts.append(tokens[i].value)
+        if i + 1 < len(tokens) and tokens[i + 1].value == '.':
+            i += 2
+        else:
+            i += 1
+            break
+    return parts, i
+
+
+def matching_paren(tokens: List[SqlToken], i: int) -> int:
+    """Index of the ')' closing the '(' at tokens[i] (the last token if it is never closed)"""
+    depth = 0
+    for j in range(i, len(tokens)):
+        if tokens[j].kind == 'op' and tokens[j].value == '(':
+            depth += 1
+        elif tokens[j].kind == 'op' and tokens[j].value == ')':
+            depth -= 1
+            if depth == 0:
+                return j
+    return len(tokens) - 1
+
+
+def split_list(tokens: List[SqlToken], open_index: int) -> Tuple[List[List[SqlToken]], int]:
+    """The comma-separated elements of the (...) at tokens[open_index], and the index of its ')'"""
+    close = matching_paren(tokens, open_index)
+    elements: List[List[SqlToken]] = [[]]
+    depth = 0
+    for tok in tokens[open_index + 1:close]:
+        if tok.kind == 'op' and tok.value in '([':
+            depth += 1
+        elif tok.kind == 'op' and tok.value in ')]':
+            depth -= 1
+        elif tok.kind == 'op' and tok.value == ',' and depth == 0:
+            elements.append([])
+            continue
+        elements[-1].append(tok)
+    return [e for e in elements if e], close
+
+
+def referenced_tables(tokens: List[SqlToken]) -> List[str]:
+    """
+    Tables a statement reads or writes (FROM, JOIN, INSERT INTO, UPDATE, DELETE FROM, MERGE),
+    in order of first appearance; CTE names and set-returning functions are left out
+    """
+    tables: List[str] = []
+    ctes = set()
+    queries: List[bool] = []  # per open '(': whether it holds a query
+    
+    for i, tok in enumerate(tokens):
+        following = tokens[i + 1] if i + 1 < len(tokens) else None
+        if tok.kind == 'op' and tok.value == '(':
+            queries.append(following is not None and following.keyword in ('SELECT', 'WITH', 'VALUES'))
+            continue
+        if tok.kind == 'op' and tok.value == ')':
+            if queries:
+                queries.pop()
+            continue
+        if tok.kind == 'dollar':
+            tables.extend(referenced_tables(tok.inner))
+            continue
+        
+        keyword = tok.keyword
+        previous = tokens[i - 1].keyword if i else None
+        if keyword == 'AS' and following is not None and following.value == '(' and i \
+                and tokens[i - 1].kind in ('word', 'ident'):
+            ctes.add(tokens[i - 1].value.lower())  # WITH recent AS (...)
+            continue
+        if queries and not queries[-1]:
+            continue  # inside a call or a column list: EXTRACT(YEAR FROM created_at)
+        
+        if keyword in ('FROM', 'JOIN'):
+            _collect_names(tokens, i + 1, tables, repeat=keyword == 'FROM', calls=True)
+        elif keyword == 'INTO' and previous in ('INSERT', 'MERGE', 'REPLACE', 'IGNORE'):
+            _collect_names(tokens, i + 1, tables)  # a column list may follow: INSERT INTO users (name)
+        elif keyword == 'UPDATE' and previous not in ('ON', 'FOR', 'DO', 'OR', 'BEFORE', 'AFTER', 'INSTEAD', ',') \
+                and not (following is not None and (following.value == ',' or following.keyword in ('OF', 'SET', 'ON'))):
+            _collect_names(tokens, i + 1, tables)
+        elif keyword == 'USING' and any(t.keyword == 'MERGE' for t in tokens[:i]):
+            _collect_names(tokens, i + 1, tables)
+    
+    seen = set()
+    ordered = []
+    for table in tables:
+        if table.lower() not in ctes and table.lower() not in seen:
+            seen.add(table.lower())
+            ordered.append(table)
+    return ordered
+
+
+def _collect_names(tokens: List[SqlToken], i: int, tables: List[str], repeat: bool = False, calls: bool = False):
+    """
+    Table names from tokens[i]: one, or a comma list (FROM a x, b y) when repeat;
+    with calls, a name followed by '(' is a function (FROM generate_series(1, 10))
+    """
+    while i < len(tokens):
+        if tokens[i].keyword in ('ONLY', 'LATERAL', 'TABLE'):
+            i += 1
+            continue
+        if tokens[i].kind not in ('word', 'ident') or tokens[i].keyword in CLAUSE_KEYWORDS:
+            return
+        parts, i = qualified_name_at(tokens, i)
+        if calls and i < len(tokens) and tokens[i].value == '(':
+            i = matching_paren(tokens, i) + 1
+        else:
+            tables.append('.'.join(parts))
+        if not repeat:
+            return
+        if i < len(tokens) and tokens[i].keyword == 'AS':
+            i += 1
+        if i < len(tokens) and tokens[i].kind in ('word', 'ident') and tokens[i].keyword not in CLAUSE_KEYWORDS:
+            i += 1  # alias
+        if not (i < len(tokens) and tokens[i].value == ','):
+            return
+        i += 1
+
+
+class SqlChunker(BaseChunker):
+    """Extracts one chunk per statement from SQL schema, migration and query files"""
+    
+    def __init__(self):
+        super().__init__('sql')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('sql')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract the file's statements in order"""
+        self._source = bytes(code, "utf8")
+        tokens, comments, diagnostics = read_tokens(self._source, lambda source: self.parser.parse(source).root_node)
+        self.diagnostics = diagnostics
+        self._filepath = filepath
+        self._comment_lines = {c.line: c.text for c in comments if c.own_line}
+        
+        chunks = []
+        for statement in split_statements(tokens, comments):
+            chunk = self._statement_chunk(statement)
+            if chunk:
+                chunks.append(chunk)
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    def _statement_chunk(self, statement: SqlStatement) -> Optional[CodeChunk]:
+        tokens = statement.tokens
+        verb = tokens[0].keyword
+        if verb in SKIPPED_STATEMENTS and len(tokens) <= 3:
+            return None  # BEGIN; / COMMIT; / START TRANSACTION;
+        
+        doc, query_name, command = self._doc_above(tokens[0].line)
+        if verb == 'CREATE':
+            chunk = self._create_chunk(tokens)
+        else:
+            chunk = None
+        if chunk is None:
+            chunk = self._query_chunk(tokens, query_name, command)
+        
+        chunk.content = self._source[tokens[0].start:statement.end].decode('utf8', errors='replace')
+        chunk.filepath = self._filepath
+        chunk.language = self.language
+        chunk.line_start = tokens[0].line
+        chunk.line_end = self._source.count(b'\n', 0, statement.end - 1) + 1
+        chunk.doc = doc
+        if statement.migration:
+            chunk.metadata['migration'] = statement.migration
+        return chunk
+    
+    def _create_chunk(self, tokens: List[SqlToken]) -> Optional[CodeChunk]:
+        """CREATE TABLE/VIEW/FUNCTION/... as a named chunk; None for other CREATE statements"""
+        modifiers = []
+        i = 1
+        while i < len(tokens) and tokens[i].keyword not in OBJECT_KEYWORDS and tokens[i].value != '(':
+            if tokens[i].kind == 'word':
+                modifiers.append(tokens[i].keyword)
+            i += 1
+        if i >= len(tokens) or tokens[i].keyword not in OBJECT_CHUNK_TYPES:
+            return None
+        
+        kind = tokens[i].keyword
+        i += 1
+        while i < len(tokens) and tokens[i].keyword in ('IF', 'NOT', 'EXISTS', 'CONCURRENTLY'):
+            i += 1
+        
+        if kind == 'INDEX' and i < len(tokens) and tokens[i].keyword == 'ON':
+            parts = []  # CREATE INDEX ON users (email): the name is generated
+        else:
+            parts, i = qualified_name_at(tokens, i)
+        if not parts and kind != 'INDEX':
+            return None
+        
+        metadata: Dict = {'statement': f"CREATE {kind}"}
+        if 'MATERIALIZED' in modifiers:
+            metadata['materialized'] = True
+        if 'TEMP' in modifiers or 'TEMPORARY' in modifiers:
+            metadata['temporary'] = True
+        
+        detail = {
+            'TABLE': self._table_details, 'VIEW': self._view_details, 'FUNCTION': self._routine_details,
+            'PROCEDURE': self._routine_details, 'INDEX': self._index_details, 'TRIGGER': self._trigger_details,
+            'TYPE': self._type_details, 'DOMAIN': self._type_details,
+        }[kind]
+        suffix = detail(tokens, i, metadata)
+        
+        full = '.'.join(parts)
+        if not parts:
+            table = metadata.get('table', '')
+            full = f"{table}_{'_'.join(metadata.get('columns', []))}_idx"
+        prefix = 'CREATE ' + ('MATERIALIZED ' if metadata.get('materialized') else '') \
+            + ('UNIQUE ' if kind == 'INDEX' and metadata.get('unique') else '')
+        return CodeChunk(
+            type=OBJECT_CHUNK_TYPES[kind],
+            name=parts[-1] if parts else full,
+            content='',
+            filepath='',
+            language=self.language,
+            line_start=0,
+            line_end=0,
+            signature=f"{prefix}{kind} {full}{suffix}",
+            namespace='.'.join(parts[:-1]) or None,
+            metadata=metadata
+        )
+    
+    def _table_details(self, tokens: List[SqlToken], i: int, metadata: Dict) -> str:
+        """Columns, keys and referenced tables of CREATE TABLE; returns the signature's column list"""
+        columns = []
+        primary_key: List[str] = []
+        foreign_keys = []
+        
+        if i < len(tokens) and tokens[i].keyword == 'PARTITION':  # CREATE TABLE t PARTITION OF parent
+            parts, _ = qualified_name_at(tokens, i + 2)
+            metadata['partition_of'] = '.'.join(parts)
+        elif i < len(tokens) and tokens[i].value == '(':
+            elements, _ = split_list(tokens, i)
+            for element in elements:
+                if element[0].keyword in TABLE_CONSTRAINTS:
+                    self._table_constraint(element, primary_key, foreign_keys, metadata)
+                elif element[0].kind in ('word', 'ident'):
+                    column = self._column(element, foreign_keys)
+                    columns.append(column)
+                    if column.get('primary_key'):
+                        primary_key.append(column['name'])
+        elif any(tok.keyword == 'AS' for tok in tokens[i:]):
+            metadata['tables'] = referenced_tables(tokens[i:])  # CREATE TABLE t AS SELECT ...
+        
+        metadata['columns'] = columns
+        if primary_key:
+            metadata['primary_key'] = primary_key
+        if foreign_keys:
+            metadata['foreign_keys'] = foreign_keys
+            metadata['references'] = list(dict.fromkeys(fk['table'] for fk in foreign_keys))
+        return f" ({', '.join(c['name'] for c in columns)})" if columns else ''
+    
+    def _column(self, element: List[SqlToken], foreign_keys: List[Dict]) -> Dict:
+        column: Dict = {'name': element[0].value}
+        j = 1
+        while j < len(element) and element[j].keyword not in COLUMN_CONSTRAINTS:
+            j += 1
+        if j > 1:
+            column['type'] = self._text(element[1], element[j - 1])
+        
+        keywords = [tok.keyword for tok in element[j:]]
+        if 'PRIMARY' in keywords:
+            column['primary_key'] = True
+        if 'NOT' in keywords and 'NULL' in keywords[keywords.index('NOT'):keywords.index('NOT') + 2]:
+            column['not_null'] = True
+        if 'UNIQUE' in keywords:
+            column['unique'] = True
+        for k in range(j, len(element)):
+            if element[k].keyword == 'DEFAULT' and k + 1 < len(element):
+                stop = k + 1
+                while stop + 1 < len(element) and element[stop + 1].keyword not in COLUMN_CONSTRAINTS:
+                    stop += 1
+                column['default'] = self._text(element[k + 1], element[stop])
+            elif element[k].keyword == 'REFERENCES':
+                reference = self._reference(element, k + 1)
+                foreign_keys.append({'columns': [column['name']], **reference})
+                column['references'] = reference['table'] + (
+                    f"({', '.join(reference['referenced_columns'])})" if reference.get('referenced_columns') else '')
+        return column
+    
+    def _table_constraint(self, element: List[SqlToken], primary_key: List[str], foreign_keys: List[Dict],
+                          metadata: Dict):
+        j = 2 if element[0].keyword == 'CONSTRAINT' else 0
+        keyword = element[j].keyword if j < len(element) else None
+        names = self._paren_names(element, j)
+        if keyword == 'PRIMARY':
+            primary_key.extend(name for name in names if name not in primary_key)
+        elif keyword == 'UNIQUE':
+            metadata.setdefault('unique', []).append(names)
+        elif keyword == 'FOREIGN':
+            k = next((k for k in range(j, len(element)) if element[k].keyword == 'REFERENCES'), None)
+            if k is not None:
+                foreign_keys.append({'columns': names, **self._reference(element, k + 1)})
+    
+    def _paren_names(self, element: List[SqlToken], j: int) -> List[str]:
+        """Names in the first (...) at or after element[j]"""
+        k = next((k for k in range(j, len(element)) if element[k].value == '('), None)
+        if k is None:
+            return []
+        items, _ = split_list(element, k)
+        return [item[0].value for item in items if item[0].kind in ('word', 'ident')]
+    
+    def _reference(self, element: List[SqlToken], k: int) -> Dict:
+        """REFERENCES table [(columns)] starting at element[k]"""
+        parts, k = qualified_name_at(element, k)
+        reference: Dict = {'table': '.'.join(parts)}
+        if k < len(element) and element[k].value == '(':
+            reference['referenced_columns'] = self._paren_names(element, k)
+        return reference
+    
+    def _view_details(self, tokens: List[SqlToken], i: int, metadata: Dict) -> str:
+        if i < len(tokens) and tokens[i].value == '(':
+            metadata['columns'] = self._paren_names(tokens, i)
+        body = next((k for k in range(i, len(tokens)) if tokens[k].keyword == 'AS'), len(tokens))
+        metadata['tables'] = referenced_tables(tokens[body + 1:])
+        return ''
+    
+    def _routine_details(self, tokens: List[SqlToken], i: int, metadata: Dict) -> str:
+        """Parameters, return type, language and referenced tables of a function or procedure"""
+        parameters = []
+        if i < len(tokens) and tokens[i].value == '(':
+            elements, close = split_list(tokens, i)
+            parameters = [self._text(element[0], element[-1]) for element in elements]
+            i = close + 1
+        metadata['parameters'] = parameters
+        
+        returns = None
+        for k in range(i, len(tokens)):
+            if tokens[k].keyword == 'RETURNS' and k + 1 < len(tokens):
+                stop = k + 1
+                nested = 0
+                while stop < len(tokens):
+                    value = tokens[stop].value
+                    nested += value == '('
+                    nested -= value == ')'
+                    if nested == 0 and stop > k + 1 and (tokens[stop].keyword in ROUTINE_OPTIONS
+                                                          or tokens[stop].kind in ('dollar', 'dollar_tag', 'string')):
+                        break
+                    stop += 1
+                returns = self._text(tokens[k + 1], tokens[stop - 1])
+                metadata['returns'] = returns
+            elif tokens[k].keyword == 'LANGUAGE' and k + 1 < len(tokens):
+                metadata['language'] = tokens[k + 1].value.strip("'").lower()
+        
+        tables = referenced_tables(tokens[i:])
+        if tables:
+            metadata['tables'] = tables
+        return f"({', '.join(parameters)})" + (f" RETURNS {returns}" if returns else '')
+    
+    def _index_details(self, tokens: List[SqlToken], i: int, metadata: Dict) -> str:
+        metadata['unique'] = any(tok.keyword == 'UNIQUE' for tok in tokens[:i])
+        on = next((k for k in range(i, len(tokens)) if tokens[k].keyword == 'ON'), None)
+        if on is None:
+            return ''
+        k = on + 1 + (tokens[on + 1].keyword == 'ONLY' if on + 1 < len(tokens) else 0)
+        parts, k = qualified_name_at(tokens, k)
+        metadata['table'] = '.'.join(parts)
+        if k + 1 < len(tokens) and tokens[k].keyword == 'USING':
+            metadata['method'] = tokens[k + 1].value.lower()
+            k += 2
+        if k < len(tokens) and tokens[k].value == '(':
+            elements, _ = split_list(tokens, k)
+            metadata['columns'] = [self._text(element[0], element[-1]) for element in elements]
+        return f" ON {metadata['table']} ({', '.join(metadata.get('columns', []))})"
+    
+    def _trigger_details(self, tokens: List[SqlToken], i: int, metadata: Dict) -> str:
+        keywords = [tok.keyword for tok in tokens]
+        timing = next((k for k in ('BEFORE', 'AFTER', 'INSTEAD') if k in keywords), None)
+        if timing:
+            metadata['timing'] = 'INSTEAD OF' if timing == 'INSTEAD' else timing
+        metadata['events'] = [k for k in ('INSERT', 'UPDATE', 'DELETE', 'TRUNCATE') if k in keywords[i:]]
+        on = next((k for k in range(i, len(tokens)) if tokens[k].keyword == 'ON'), None)
+        if on is not None:
+            parts, _ = qualified_name_at(tokens, on + 1)
+            metadata['table'] = '.'.join(parts)
+        execute = next((k for k in range(i, len(tokens)) if tokens[k].keyword == 'EXECUTE'), None)
+        if execute is not None and execute + 2 < len(tokens):
+            parts, _ = qualified_name_at(tokens, execute + 2)
+            metadata['executes'] = '.'.join(parts)
+        return f" {metadata.get('timing', '')} {' OR '.join(metadata['events'])} ON {metadata.get('table', '')}".rstrip()
+    
+    def _type_details(self, tokens: List[SqlToken], i: int, metadata: Dict) -> str:
+        if i + 1 < len(tokens) and tokens[i].keyword == 'AS' and tokens[i + 1].keyword == 'ENUM':
+            if i + 2 < len(tokens) and tokens[i + 2].value == '(':
+                elements, _ = split_list(tokens, i + 2)
+                metadata['values'] = [element[0].value.strip("'") for element in elements]
+            return " AS ENUM"
+        if i < len(tokens) and tokens[i].keyword == 'AS' and i + 1 < len(tokens) and tokens[i + 1].value == '(':
+            elements, _ = split_list(tokens, i + 1)
+            metadata['fields'] = [{'name': e[0].value, 'type': self._text(e[1], e[-1])} for e in elements if len(e) > 1]
+        elif i < len(tokens) and tokens[i].keyword == 'AS':  # CREATE DOMAIN email AS text CHECK (...)
+            stop = i + 1
+            while stop + 1 < len(tokens) and tokens[stop + 1].keyword not in COLUMN_CONSTRAINTS:
+                stop += 1
+            if stop < len(tokens):
+                metadata['underlying'] = self._text(tokens[i + 1], tokens[stop])
+        return ''
+    
+    def _query_chunk(self, tokens: List[SqlToken], query_name: Optional[str], command: Optional[str]) -> CodeChunk:
+        """A statement named by its sqlc/HugSQL comment, or by its verb and first table"""
+        # An empty quoted identifier ("") or a stray quote has no value to name it by
+        verb = tokens[0].keyword or tokens[0].value or self._text(tokens[0], tokens[0])
+        if verb == 'WITH':
+            # The statement a CTE prefix leads to
+            depth = 0
+            for tok in tokens[1:]:
+                depth += tok.value == '('
+                depth -= tok.value == ')'
+                if depth == 0 and tok.keyword in ('SELECT', 'INSERT', 'UPDATE', 'DELETE', 'MERGE'):
+                    verb = tok.keyword
+                    break
+        
+        tables = referenced_tables(tokens)
+        metadata: Dict = {'statement': verb, 'tables': tables}
+        target = None
+        if len(tokens) > 1 and tokens[1].keyword in OBJECT_KEYWORDS:
+            # ALTER TABLE users ..., DROP INDEX idx ..., CREATE SCHEMA auth
+            k = 2
+            while k < len(tokens) and tokens[k].keyword in ('IF', 'NOT', 'EXISTS', 'ONLY', 'CONCURRENTLY'):
+                k += 1
+            parts, _ = qualified_name_at(tokens, k)
+            verb = f"{verb} {tokens[1].keyword}"
+            metadata['statement'] = verb
+            target = '.'.join(parts) or None
+            if tokens[1].keyword == 'TABLE' and target and target not in tables:
+                metadata['tables'] = [target] + tables
+        elif tables:
+            target = tables[0]
+        
+        if query_name:
+            metadata['query_name'] = query_name
+            if command:
+                metadata['command'] = command
+            name, chunk_type = query_name, 'query'
+        else:
+            name, chunk_type = f"{verb} {target}" if target else verb, 'statement'
+        
+        text = self._text(tokens[0], tokens[-1])
+        return CodeChunk(
+            type=chunk_type,
+            name=name,
+            content='',
+            filepath='',
+            language=self.language,
+            line_start=0,
+            line_end=0,
+            signature=text if len(text) <= MAX_SIGNATURE_LENGTH else text[:MAX_SIGNATURE_LENGTH].rstrip() + ' ...',
+            metadata=metadata
+        )
+    
+    def _text(self, first: SqlToken, last: SqlToken) -> str:
+        """Source text from first to last token, whitespace collapsed"""
+        return re.sub(r'\s+', ' ', self._source[first.start:last.end].decode('utf8', errors='replace')).strip()
+    
+    def _doc_above(self, line: int) -> Tuple[Optional[str], Optional[str], Optional[str]]:
+        """
+        The '--' comment block directly above a line
+        
+        Returns:
+            Tuple of (doc text, query name, sqlc command); migration directives are left out
+        """
+        lines = []
+        line -= 1
+        while line in self._comment_lines:
+            lines.append(self._comment_lines[line])
+            line -= 1
+        
+        doc_lines = []
+        query_name = command = None
+        for text in reversed(lines):
+            sqlc = SQLC_NAME.match(text)
+            hugsql = HUGSQL_NAME.match(text)
+            if sqlc or hugsql:
+                query_name = (sqlc or hugsql).group(1)
+                command = sqlc.group(2) if sqlc else ((hugsql.group(2) or '').lstrip(':') or None)
+            elif not (GOOSE_DIRECTIVE.match(text) or DBMATE_DIRECTIVE.match(text)):
+                doc_lines.append(text[1:] if text.startswith(' ') else text)
+        return '\n'.join(doc_lines).strip('\n') or None, query_name, command
@@ -0,0 +1,552 @@
+#!/usr/bin/env python3
+"""
+Swift code chunker using tree-sitter for accurate parsing
+Supports .swift files
+
+Types, protocols and extensions are walked recursively through their bodies;
+function, initializer and accessor bodies are not entered. Extensions are
+chunks of their own named after the type they extend, and their members
+belong to that type (User.hasRole). Conformances a type or an extension
+declares are recorded as 'implements'; swift_linker then moves those of
+extensions onto the extended type, across files.
+"""
+
+import re
+from bisect import bisect_right
+from dataclasses import dataclass
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
+
+
+# Effects written after the parameter list: func load() async throws -> Data
+EFFECTS = ('async', 'throws', 'rethrows', 'reasync')
+
+# Accessor and observer nodes of a property or subscript block, and the accessor they declare
+ACCESSORS = {
+    'computed_getter': 'get', 'computed_setter': 'set', 'computed_modify': '_modify',
+    'willset_clause': 'willSet', 'didset_clause': 'didSet',
+    'getter_specifier': 'get', 'setter_specifier': 'set',
+}
+
+# Raw value types an enum's inheritance list can start with (enum Color: String)
+RAW_VALUE_TYPES = {
+    'String', 'Character', 'Substring', 'Int', 'Int8', 'Int16', 'Int32', 'Int64', 'UInt', 'UInt8',
+    'UInt16', 'UInt32', 'UInt64', 'Float', 'Double', 'CGFloat',
+}
+
+# Well-known protocols a class may adopt without a superclass (class Settings: Codable);
+# any other first entry of a class's inheritance list is taken as its superclass
+STANDARD_PROTOCOLS = {
+    'Codable', 'Decodable', 'Encodable', 'Equatable', 'Hashable', 'Comparable', 'Identifiable',
+    'CustomStringConvertible', 'CustomDebugStringConvertible', 'Error', 'LocalizedError', 'Sendable',
+    'ObservableObject', 'CaseIterable', 'RawRepresentable', 'Sequence', 'Collection', 'IteratorProtocol',
+    'AnyObject', 'CodingKey', 'View', 'App', 'Scene',
+}
+
+FUNCTION_DECLARATIONS = (
+    'function_declaration', 'protocol_function_declaration', 'init_declaration', 'deinit_declaration',
+    'subscript_declaration',
+)
+
+PROPERTY_DECLARATIONS = ('property_declaration', 'protocol_property_declaration')
+
+COMMENTS = ('comment', 'multiline_comment')
+
+# The nodes a declaration's body may be
+BODIES = ('class_body', 'enum_class_body', 'protocol_body', 'function_body', 'computed_property')
+
+
+@dataclass
+class SwiftComment:
+    """A comment with its line span"""
+    text: str
+    start: int
+    end: int
+    line_start: int
+    line_end: int
+    
+    @property
+    def is_doc(self) -> bool:
+        return self.text.startswith('///') or self.text.startswith('/**') and self.text != '/**/'
+
+
+def doc_comment_text(comments: List[SwiftComment]) -> str:
+    """Text of a /** */ comment or a run of /// lines, without the comment markers"""
+    lines = []
+    for comment in comments:
+        if comment.text.startswith('///'):
+            line = comment.text[3:]
+            lines.append((line[1:] if line.startswith(' ') else line).rstrip())
+            continue
+        for line in comment.text[3:-2].splitlines():
+            line = line.strip()
+            if line.startswith('*'):
+                line = line[1:]
+                line = line[1:] if line.startswith(' ') else line
+            lines.append(line.rstrip())
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip()
+    return re.sub(r'\s+([,;)>\]])|([(<\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def base_type_name(type_text: str) -> str:
+    """A type without its generic arguments or optionality (Array<Element>? -> Array)"""
+    return re.sub(r'<.*>', '', type_text).rstrip('?!').strip()
+
+
+def strip_backticks(name: str) -> str:
+    """Name of a `quoted identifier` (func `default`())"""
+    return name[1:-1] if name.startswith('`') and name.endswith('`') else name
+
+
+class SwiftChunker(BaseChunker):
+    """Extracts types, extensions, functions and properties from Swift code"""
+    
+    def __init__(self):
+        super().__init__('swift')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('swift')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Swift code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        self._comments = self._collect_comments(tree.root_node)
+        self._comment_ends = [c.end for c in self._comments]
+        chunks: List[CodeChunk] = []
+        
+        self._parse_members(tree.root_node, [], None, chunks)
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Members
+    # ------------------------------------------------------------------
+    
+    def _parse_members(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        """Extract the declarations of a file or of a type, protocol or extension body"""
+        for child in node.children:
+            if child.type == 'ERROR':
+                self._parse_members(child, parents, owner, chunks)
+            elif child.type in ('class_declaration', 'protocol_declaration'):
+                self._extract_type(child, parents, chunks)
+            elif child.type in FUNCTION_DECLARATIONS:
+                self._extract_function(child, parents, owner, chunks)
+            elif child.type in PROPERTY_DECLARATIONS:
+                self._extract_property(child, parents, owner, chunks)
+            elif child.type == 'typealias_declaration':
+                self._extract_alias(child, parents, chunks)
+            elif child.type == 'enum_entry' and owner is not None and owner['kind'] == 'enum':
+                self._parse_enum_case(child, owner)
+            elif child.type == 'associatedtype_declaration' and owner is not None:
+                name = child.child_by_field_name('name')
+                if name is not None:
+                    owner.setdefault('associated_types', []).append(strip_backticks(self._text(name)))
+            # Imports and the top-level statements of main.swift or a script are not chunked
+    
+    def _extract_type(self, node: Node, parents: List[str], chunks: List[CodeChunk]):
+        attributes, modifiers = self._modifiers(node)
+        if node.type == 'protocol_declaration':
+            kind = 'protocol'
+        else:
+            kind_node = node.child_by_field_name('declaration_kind')
+            kind = self._text(kind_node) if kind_node is not None else 'class'
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        metadata: Dict = {}
+        
+        if kind == 'extension':
+            # extension Outer.Inner, extension Array where Element: Equatable
+            extended = normalize_signature(self._text(name_node))
+            metadata['extended_type'] = extended
+            path = [strip_backticks(part) for part in base_type_name(extended).split('.')]
+            name = path[-1]
+        else:
+            name = strip_backticks(self._text(name_node))
+            path = [name]
+        type_params = next((c for c in node.children if c.type == 'type_parameters'), None)
+        if type_params is not None:
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        
+        # Inheritance: class Admin: User, Authenticating where T: Hashable
+        inherits = [normalize_signature(self._text(c.child_by_field_name('inherits_from') or c))
+                    for c in node.children if c.type == 'inheritance_specifier']
+        if inherits:
+            metadata['inherits'] = inherits
+            conformances = list(inherits)
+            if kind == 'enum' and conformances[0] in RAW_VALUE_TYPES:
+                metadata['raw_type'] = conformances.pop(0)
+            elif kind == 'class' and conformances[0] not in STANDARD_PROTOCOLS:
+                metadata['superclass'] = conformances.pop(0)
+            if kind != 'protocol':
+                metadata['implements'] = conformances
+        where = next((c for c in node.children if c.type == 'type_constraints'), None)
+        if where is not None:
+            metadata['where'] = normalize_signature(self._text(where))
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        # Members of an extension belong to the type it extends
+        chunk_parents = parents + path[:-1]
+        members_parents = parents + path
+        body = node.child_by_field_name('body')
+        chunk = CodeChunk(
+            type=kind,
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=self._signature(node, body),
+            parent_class=chunk_parents[-1] if chunk_parents else None,
+            parent='.'.join(chunk_parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node, attributes)
+        chunks.append(chunk)
+        
+        if body is not None:
+            body_owner = {'kind': kind, 'name': name}
+            before = len(chunks)
+            self._parse_members(body, members_parents, body_owner, chunks)
+            if body_owner.get('cases'):
+                metadata['cases'] = body_owner['cases']
+            if body_owner.get('associated_types'):
+                metadata['associated_types'] = body_owner['associated_types']
+            members = [c for c in chunks[before:] if c.parent == '.'.join(members_parents)]
+            metadata['methods'] = [c.name for c in members if c.type == 'method']
+            properties = [c.name for c in members if c.type == 'property']
+            if properties:
+                metadata['properties'] = properties
+    
+    def _parse_enum_case(self, node: Node, owner: Dict):
+        """Record the cases of an enum case declaration: case success(T), failure(Error), case red = "r" """
+        cases = owner.setdefault('cases', [])
+        indirect = any(c.type == 'indirect' for c in node.children) or 'indirect' in self._modifiers(node)[1]
+        case: Optional[Dict] = None
+        after_equals = False
+        for child in node.children:
+            if child.type == '=':
+                after_equals = True
+            elif after_equals:
+                if case is not None:
+                    case['raw_value'] = self._text(child)
+                after_equals = False
+            elif child.type == 'simple_identifier':
+                case = {'name': strip_backticks(self._text(child))}
+                if indirect:
+                    case['indirect'] = True
+                cases.append(case)
+            elif child.type == 'enum_type_parameters' and case is not None:
+                case['associated_values'] = [normalize_signature(self._span(g[0], g[-1]))
+                                             for g in self._split_commas(child.children[1:-1])]
+    
+    def _extract_function(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        attributes, modifiers = self._modifiers(node)
+        children = node.children
+        types = [c.type for c in children]
+        metadata: Dict = {}
+        
+        if node.type == 'init_declaration':
+            name = 'init'
+            metadata['initializer'] = True
+            keyword = types.index('init') if 'init' in types else -1
+            if 0 <= keyword < len(children) - 1 and children[keyword + 1].type in ('?', '!'):
+                metadata['failable'] = True
+        elif node.type in ('deinit_declaration', 'subscript_declaration'):
+            name = node.type.split('_')[0]
+        else:
+            name_node = node.child_by_field_name('name')
+            if name_node is None:
+                return
+            name = strip_backticks(self._text(name_node))
+            if name_node.type != 'simple_identifier':
+                # Operator implementations: static func == (lhs: Self, rhs: Self) -> Bool
+                metadata['operator'] = True
+        
+        type_params = next((c for c in children if c.type == 'type_parameters'), None)
+        if type_params is not None:
+            metadata['type_params'] = normalize_signature(self._text(type_params))
+        if node.type != 'deinit_declaration' and '(' in types:
+            metadata['params'] = self._parse_params(children)
+        
+        # async throws(ParseError) -> Result where T: Decodable
+        after_params = types.index(')') + 1 if ')' in types else 0
+        effects = []
+        for child in children[after_params:]:
+            text = normalize_signature(self._text(child))
+            if child.type in ('->', 'type_constraints') or child.type in BODIES:
+                break
+            if text.split('(')[0] in EFFECTS:
+                effects.append(text)
+        if effects:
+            metadata['effects'] = effects
+        returns = node.child_by_field_name('return_type')
+        if returns is not None:
+            metadata['returns'] = normalize_signature(self._text(returns))
+        where = next((c for c in children if c.type == 'type_constraints'), None)
+        if where is not None:
+            metadata['where'] = normalize_signature(self._text(where))
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        if owner is not None and owner['kind'] == 'protocol':
+            metadata['abstract'] = True  # a requirement; default implementations live in extensions
+        
+        body = next((c for c in children if c.type in BODIES), None)
+        chunk = CodeChunk(
+            type='method' if owner is not None else 'function',
+            name=name,
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=self._signature(node, body),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node, attributes)
+        chunks.append(chunk)
+    
+    def _extract_property(self, node: Node, parents: List[str], owner: Optional[Dict], chunks: List[CodeChunk]):
+        attributes, modifiers = self._modifiers(node)
+        metadata: Dict = {}
+        binding = next((c for c in node.children if c.type in ('value_binding_pattern', 'var', 'let')), None)
+        if binding is not None and self._text(binding) == 'var':
+            metadata['mutable'] = True
+        
+        # var a = 1, b = 2; let (key, value) = pair
+        patterns = node.children_by_field_name('name')
+        names = [strip_backticks(n) for p in patterns for n in self._bound_names(p)]
+        if not names:
+            return
+        if len(names) > 1:
+            metadata['names'] = names
+        
+        signature_end = patterns[0]
+        following = patterns[0].next_sibling
+        if following is not None and following.type == 'type_annotation':
+            signature_end = following
+            annotation = [c for c in following.children if c.type != ':']
+            if annotation:
+                metadata['property_type'] = normalize_signature(self._span(annotation[0], annotation[-1]))
+        
+        # Accessors or observers: { get set }, { didSet { ... } }, or a getter body
+        block = next((c for c in node.children if c.type in ('computed_property', 'willset_didset_block',
+                                                             'protocol_property_requirements')), None)
+        if len(patterns) == 1 and block is not None:
+            accessors = [ACCESSORS[c.type] for c in block.named_children if c.type in ACCESSORS]
+            if not accessors and any(c.type not in COMMENTS for c in block.named_children):
+                accessors = ['get']
+            metadata['accessors'] = accessors
+            if set(accessors) & {'get', 'set', '_read', '_modify'}:
+                metadata['computed'] = True
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        if owner is not None:
+            chunk_type = 'property'
+        else:
+            chunk_type = 'variable' if metadata.get('mutable') else 'constant'
+        chunk = CodeChunk(
+            type=chunk_type,
+            name=names[0],
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(self._signature_start(node), signature_end.end_byte)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node, attributes)
+        chunks.append(chunk)
+    
+    def _extract_alias(self, node: Node, parents: List[str], chunks: List[CodeChunk]):
+        attributes, modifiers = self._modifiers(node)
+        name_node = node.child_by_field_name('name')
+        if name_node is None:
+            return
+        metadata: Dict = {}
+        value = node.child_by_field_name('value')
+        if value is not None:
+            metadata['aliased'] = normalize_signature(self._text(value))
+        if attributes:
+            metadata['attributes'] = attributes
+        if modifiers:
+            metadata['modifiers'] = modifiers
+        
+        chunk = CodeChunk(
+            type='alias',
+            name=strip_backticks(self._text(name_node)),
+            content=self._text(node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(node),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._decode(self._signature_start(node), node.end_byte)),
+            parent_class=parents[-1] if parents else None,
+            parent='.'.join(parents) or None,
+            metadata=metadata
+        )
+        self._attach_doc(chunk, node, attributes)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _modifiers(self, node: Node) -> Tuple[List[str], List[str]]:
+        """Attributes and modifiers of a declaration (public, private(set), static, class)"""
+        attributes, modifiers = [], []
+        for child in node.children:
+            if child.type == 'modifiers':
+                for modifier in child.children:
+                    if modifier.type == 'attribute':
+                        attributes.append(normalize_signature(self._text(modifier)))
+                    elif modifier.type not in COMMENTS:
+                        modifiers.append(re.sub(r'\s+', '', self._text(modifier)))
+            elif child.type == 'attribute':
+                attributes.append(normalize_signature(self._text(child)))
+            elif child.type == 'class' and node.type in FUNCTION_DECLARATIONS:
+                modifiers.append('class')  # class func make()
+        return attributes, modifiers
+    
+    def _parse_params(self, children: List[Node]) -> List[Dict]:
+        """Parameters as {'name', 'type'}, with the argument 'label' when it differs from the name"""
+        params = []
+        for child in children:
+            if child.type != 'parameter':
+                continue
+            name = child.child_by_field_name('name')
+            if name is None:
+                continue
+            parts = child.children
+            index = parts.index(name)
+            # Attributes may precede the name: @ViewBuilder content: () -> Content
+            labels = [p for p in parts[:index] if p.type not in ('attribute', 'modifiers')]
+            colon = next((k for k in range(index, len(parts)) if parts[k].type == ':'), None)
+            type_parts = [p for p in parts[colon + 1:] if p.type != '...'] if colon is not None else []
+            if not type_parts:
+                continue
+            param: Dict = {'name': strip_backticks(self._text(name)),
+                           'type': normalize_signature(self._span(type_parts[0], type_parts[-1]))}
+            if labels:
+                param['label'] = strip_backticks(self._text(labels[-1]))
+            if any(p.type == '...' for p in parts) or param['type'].endswith('...'):
+                param['type'] = param['type'][:-3] if param['type'].endswith('...') else param['type']
+                param['variadic'] = True
+            params.append(param)
+        return params
+    
+    def _bound_names(self, pattern: Node) -> List[str]:
+        """Identifiers a property pattern binds: x, or key and value in (key, value)"""
+        if pattern.type == 'simple_identifier':
+            return [self._text(pattern)]
+        names = []
+        stack = [pattern]
+        while stack:
+            node = stack.pop()
+            if node.type == 'simple_identifier':
+                names.append(self._text(node))
+            elif node.type not in ('type_annotation', 'user_type'):
+                stack.extend(reversed(node.children))
+        return names
+    
+    def _split_commas(self, children: List[Node]) -> List[List[Node]]:
+        """Children grouped by the commas between them"""
+        groups: List[List[Node]] = [[]]
+        for child in children:
+            if child.type == ',':
+                groups.append([])
+            elif child.type not in COMMENTS:
+                groups[-1].append(child)
+        return [g for g in groups if g]
+    
+    def _signature_start(self, node: Node) -> int:
+        """Offset of the first modifier or keyword of a declaration, after its attributes"""
+        for child in node.children:
+            if child.type == 'modifiers':
+                first = next((m for m in child.children if m.type != 'attribute' and m.type not in COMMENTS), None)
+                if first is not None:
+                    return first.start_byte
+            elif child.type != 'attribute' and child.type not in COMMENTS:
+                return child.start_byte
+        return node.start_byte
+    
+    def _signature(self, node: Node, body: Optional[Node]) -> str:
+        """A declaration from its first modifier or keyword up to its body"""
+        end = node.end_byte
+        if body is not None:
+            before = body.prev_sibling
+            end = before.end_byte if before is not None else body.start_byte
+        return normalize_signature(self._decode(self._signature_start(node), end))
+    
+    def _collect_comments(self, root: Node) -> List[SwiftComment]:
+        comments = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type in COMMENTS:
+                comments.append(SwiftComment(self._text(node), node.start_byte, node.end_byte,
+                                             self._line(node), self._line_end(node)))
+            else:
+                stack.extend(node.children)
+        return sorted(comments, key=lambda c: c.start)
+    
+    def _attach_doc(self, chunk: CodeChunk, node: Node, attributes: List[str]):
+        """/// lines or a /** */ comment directly before the declaration; records @available deprecations"""
+        position = bisect_right(self._comment_ends, node.start_byte) - 1
+        doc: List[SwiftComment] = []
+        following = node.start_byte
+        while position >= 0:
+            comment = self._comments[position]
+            gap = self._source[comment.end:following]
+            if not comment.is_doc or gap.strip() or gap.count(b'\n') > 1:
+                break
+            doc.insert(0, comment)
+            if not comment.text.startswith('///'):
+                break  # a /** */ comment is the whole doc
+            following = comment.start
+            position -= 1
+        if doc and (len(doc) == 1 or all(c.text.startswith('///') for c in doc)):
+            chunk.doc = doc_comment_text(doc) or None
+        
+        available = next((a for a in attributes if a.startswith('@available') and 'deprecated' in a), None)
+        if available:
+            message = re.search(r'(?:message|renamed)\s*:\s*"((?:[^"\\]|\\.)*)"', available)
+            chunk.metadata['deprecated'] = message.group(1) if message else 'deprecated'
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
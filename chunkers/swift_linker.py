@@ -0,0 +1,76 @@
+#!/usr/bin/env python3
+"""
+Cross-file analysis for Swift chunks
+Joins extensions to the types they extend (extension User: Identifiable in
+User+Identity.swift) so conformances and members added anywhere in the index
+belong to the type itself
+"""
+
+from collections import defaultdict
+from typing import Dict, List, Tuple
+
+from .base_chunker import CodeChunk
+from .cpp_linker import symbol_ref
+from .csharp_linker import _union
+
+
+EXTENSIBLE_KINDS = ('struct', 'class', 'enum', 'protocol', 'actor')
+
+# Member lists an extension adds to its type
+MEMBER_LISTS = ('methods', 'properties')
+
+
+def link_swift_extensions(chunks: List[CodeChunk]) -> List[CodeChunk]:
+    """
+    Link Swift extensions to the types they extend across files
+    
+    An extension matches the type of its repo with the same qualified name
+    (extension Outer.Inner). The type gets 'extensions', references
+    {'filepath', 'line', 'symbol_id'} to them, their members in its member
+    lists and their conformances in 'implements'; the extension gets
+    'extends', a reference to the type, and keeps its own conformances in
+    'implements' only when the type is not indexed (extension String: Identifiable).
+    A class's supposed superclass that turns out to be an indexed protocol
+    moves to its 'implements' as well.
+    
+    Args:
+        chunks: Swift chunks from any number of files
+    
+    Returns:
+        The same chunks, with link metadata filled in
+    """
+    types: Dict[Tuple, CodeChunk] = {}
+    protocols = set()
+    extensions: Dict[Tuple, List[CodeChunk]] = defaultdict(list)
+    for chunk in chunks:
+        key = (chunk.repo or '', chunk.qualified_name)
+        if chunk.type in EXTENSIBLE_KINDS:
+            types.setdefault(key, chunk)
+            if chunk.type == 'protocol':
+                protocols.add((chunk.repo or '', chunk.name))
+        elif chunk.type == 'extension':
+            extensions[key].append(chunk)
+    
+    for chunk in types.values():
+        superclass = chunk.metadata.get('superclass')
+        if superclass and (chunk.repo or '', superclass) in protocols:
+            del chunk.metadata['superclass']
+            chunk.metadata['implements'] = [superclass] + chunk.metadata.get('implements', [])
+    
+    for key, group in extensions.items():
+        target = types.get(key)
+        if target is None:
+            continue
+        group.sort(key=lambda c: (c.filepath, c.line_start))
+        target.metadata['extensions'] = [symbol_ref(extension) for extension in group]
+        implements = _union([target.metadata.get('implements', [])]
+                            + [extension.metadata.pop('implements', []) for extension in group])
+        if implements and target.type != 'protocol':
+            target.metadata['implements'] = implements
+        for name in MEMBER_LISTS:
+            members = _union([target.metadata.get(name, [])] + [e.metadata.get(name, []) for e in group])
+            if members:
+                target.metadata[name] = members
+        for extension in group:
+            extension.metadata['extends'] = symbol_ref(target)
+    return chunks
@@ -0,0 +1,141 @@
+#!/usr/bin/env python3
+"""
+Plain-text chunker for files no language parser handles
+Config files, logs and odd formats have no symbols to extract, but their text
+is still worth finding. The file is cut into blocks of whole lines that fit the
+token budget, each repeating the end of the one before as the overlap strategy
+has it (see token_splitter.OVERLAP_STRATEGIES), and stored with kind 'text' so
+searches can leave them out. The blocks are parts of one chunk
+per file, so they share its symbol_id and stitch back into the file. Files
+too large to load whole are read from disk a window at a time (stream_chunks)
+and cut into the same blocks.
+"""
+
+import os
+from typing import List
+
+from .base_chunker import BaseChunker, CodeChunk
+from .token_splitter import get_token_counter, line_segments, pack_segments
+
+
+# Chunk type and kind of plain-text blocks
+TEXT_KIND = 'text'
+
+# Characters stream_chunks reads at a time
+STREAM_WINDOW_CHARS = 1024 * 1024
+
+
+class TextChunker(BaseChunker):
+    """Splits any file into overlapping, token-bounded blocks of lines"""
+    
+    def __init__(self, language: str = 'text', max_tokens: int = 512, overlap_tokens: int = 64,
+                 encoding: str = 'cl100k_base', overlap_strategy: str = 'fixed_token'):
+        """
+        Args:
+            language: Language stored with the blocks ('text' for files no language maps)
+            max_tokens: Token budget per block (0 or less: the whole file in one block)
+            overlap_tokens: Tokens of trailing lines repeated at the start of the next block
+            encoding: Tokenizer encoding of the target embedding model
+            overlap_strategy: How blocks overlap and break (one of OVERLAP_STRATEGIES)
+        """
+        super().__init__(language)
+        self.max_tokens = max_tokens
+        self.overlap_tokens = overlap_tokens
+        self.overlap_strategy = overlap_strategy
+        self.counter = get_token_counter(encoding)
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Blocks of the file, in order; none for a file with no text"""
+        self.diagnostics = []
+        if not code.strip():
+            return []
+        
+        text = code.rstrip('\n')
+        if self.max_tokens > 0:
+            pieces = pack_segments(line_segments(text, 1, self.max_tokens, self.counter),
+                                   self.max_tokens, min(self.overlap_tokens, self.max_tokens // 2),
+                                   self.overlap_strategy, text)
+            spans = [(piece[0][0], piece[-1][0], piece[0][1], piece[-1][2]) for piece in pieces]
+        else:
+            spans = [(1, text.count('\n') + 1, 0, len(text))]
+        
+        chunks = [self._block(filepath, text[start:end], line_start, line_end, start)
+                  for line_start, line_end, start, end in spans]
+        for index, chunk in enumerate(chunks):
+            chunk.part_index, chunk.part_count = index, len(chunks)
+        return chunks
+    
+    def stream_chunks(self, path: str, filepath: str, window_chars: int = STREAM_WINDOW_CHARS) -> List[CodeChunk]:
+        """
+        The blocks extract_chunks makes of a file, read from disk window_chars at a
+        time instead of whole: each window ends at a line end, and its last block is
+        packed again with the next one, so blocks come out as they would from the whole
+        text (a line longer than a window may be cut elsewhere). Byte ranges are counted
+        as the windows are read.
+        
+        Args:
+            path: File to read
+            filepath: Path stored with the blocks
+            window_chars: Characters read at a time
+        """
+        self.diagnostics = []
+        chunks = []
+        pending, line, offset, byte_offset = '', 1, 0, 0  # text not in a block yet, and where it starts
+        with open(path, 'r', encoding='utf-8', errors='ignore') as f:
+            while True:
+                data = f.read(window_chars)
+                text = pending + data
+                if not data:
+                    text = text.rstrip('\n')
+                    if not text.strip():
+                        break
+                elif '\n' in data:
+                    # Whole lines only: the rest of the last one comes with the next window
+                    cut = len(pending) + data.rindex('\n') + 1
+                    text, pending = text[:cut], text[cut:]
+                else:
+                    pending = ''
+                if self.max_tokens > 0:
+                    pieces = pack_segments(line_segments(text, line, self.max_tokens, self.counter),
+                                           self.max_tokens, min(self.overlap_tokens, self.max_tokens // 2),
+                                           self.overlap_strategy, text)
+                    spans = [(piece[0][0], piece[-1][0], piece[0][1], piece[-1][2]) for piece in pieces]
+                else:
+                    spans = [(line, line + text.count('\n'), 0, len(text))]
+                if data:
+                    # The last block may grow with the next window
+                    spans, (line, _, rest, _) = spans[:-1], spans[-1]
+                else:
+                    rest = len(text)
+                
+                counted, size = 0, 0
+                for line_start, line_end, start, end in spans:
+                    size += len(text[counted:start].encode('utf-8'))
+                    counted = start
+                    chunk = self._block(filepath, text[start:end], line_start, line_end, offset + start)
+                    chunk.byte_start = byte_offset + size
+                    chunk.byte_end = chunk.byte_start + len(chunk.content.encode('utf-8'))
+                    chunks.append(chunk)
+                if not data:
+                    break
+                pending = text[rest:] + pending
+                byte_offset += size + len(text[counted:rest].encode('utf-8'))
+                offset += rest
+        
+        for index, chunk in enumerate(chunks):
+            chunk.part_index, chunk.part_count = index, len(chunks)
+        return chunks
+    
+    def _block(self, filepath: str, content: str, line_start: int, line_end: int, offset: int) -> CodeChunk:
+        """A block of the file's text; offset is where it starts in the file, in characters"""
+        return CodeChunk(
+            type=TEXT_KIND,
+            name=os.path.basename(filepath),
+            content=content,
+            filepath=filepath,
+            language=self.language,
+            line_start=line_start,
+            line_end=line_end,
+            metadata={'header_chars': 0, 'content_offset': offset},
+            kind=TEXT_KIND,
+        )
@@ -0,0 +1,378 @@
+#!/usr/bin/env python3
+"""
+Token-budget-aware splitting of oversized chunks
+Breaks a symbol that exceeds the embedding model's token limit into
+overlapping parts, each starting with the symbol's signature header
+
+Parts break at line ends; what the next part repeats of the one before is
+set by the overlap strategy (see OVERLAP_STRATEGIES): the trailing segments
+that fit the overlap budget ('fixed_token', the default), the whole lines
+closest to it ('line_aligned'), or, for code, lines from the start of a
+statement ('semantic', which also ends parts where a statement ends).
+"""
+
+import re
+from dataclasses import replace
+from typing import Dict, List, Optional, Set, Tuple
+
+from .base_chunker import CodeChunk, parse_metadata
+
+
+# Rough shape of a BPE vocabulary: words, runs of digits, single symbols, indentation
+ESTIMATE_PATTERN = re.compile(r'[A-Za-z]+|\d{1,3}|[^\w\s]|\n|[ \t]{2,}')
+
+# How the next part overlaps the previous one: 'fixed_token' carries the trailing line
+# segments that fit the overlap budget (the end of a line too long for a part can start
+# it); 'line_aligned' carries the whole lines whose tokens come closest to the budget, up
+# to half a part; 'semantic' starts the overlap at the nearest statement or block start
+# (up to twice the budget, or later) and ends each part after a complete statement when
+# one ends in the second half of the part
+OVERLAP_STRATEGIES = ('fixed_token', 'line_aligned', 'semantic')
+
+# Line endings after which a statement goes on, and line starts that continue the one above
+CONTINUED_LINE_END = re.compile(r'(?:[,(\[=+\-*/%&|^<>!?.\\]|&&|\|\||=>|->)[ \t]*$')
+CONTINUING_LINE_START = re.compile(r'[ \t]*(?:[)\]}.,]|&&|\|\||\?|=>|->|\+(?!\+)|\*(?!/))')
+
+# Line endings that open a block or bracket: a part does not end right after one
+OPENERS = ('{', '(', '[', ':')
+
+_counters: Dict[str, 'TokenCounter'] = {}
+
+
+class TokenCounter:
+    """
+    Counts tokens with tiktoken when it is installed, otherwise with an
+    estimate calibrated against cl100k_base on source code
+    """
+    
+    def __init__(self, encoding: str = 'cl100k_base'):
+        self.encoding_name = encoding
+        try:
+            import tiktoken
+            self._encoding = tiktoken.get_encoding(encoding)
+        except Exception:
+            self._encoding = None
+    
+    @property
+    def exact(self) -> bool:
+        """True when counts come from the real tokenizer"""
+        return self._encoding is not None
+    
+    def count(self, text: str) -> int:
+        """Number of tokens in text"""
+        if self._encoding is not None:
+            return len(self._encoding.encode(text, disallowed_special=()))
+        return estimate_tokens(text)
+
+
+def get_token_counter(encoding: str = 'cl100k_base') -> TokenCounter:
+    """Shared counter per encoding (loading a tokenizer is not free)"""
+    if encoding not in _counters:
+        _counters[encoding] = TokenCounter(encoding)
+    return _counters[encoding]
+
+
+def estimate_tokens(text: str) -> int:
+    """
+    Estimate BPE tokens without a tokenizer
+    Long identifiers cost about one token per 4 characters; symbols cost one each
+    """
+    total = 0
+    for piece in ESTIMATE_PATTERN.findall(text):
+        total += (len(piece) + 3) // 4 if piece[0].isalpha() else 1
+    return total
+
+
+def split_oversized_chunks(chunks: List[CodeChunk], max_tokens: int, overlap_tokens: int = 0,
+                           encoding: str = 'cl100k_base', overlap_strategy: str = 'fixed_token') -> List[CodeChunk]:
+    """
+    Split every chunk whose content exceeds max_tokens
+    
+    Args:
+        chunks: Chunks as produced by a chunker
+        max_tokens: Token budget per chunk (0 or less disables splitting)
+        overlap_tokens: Tokens of body repeated at the start of the next part
+        encoding: Tokenizer encoding of the target embedding model
+        overlap_strategy: How parts overlap and break (one of OVERLAP_STRATEGIES)
+    
+    Returns:
+        Chunks in the original order, oversized ones replaced by their parts.
+        A chunk's context is prepended to each of its parts (and counted
+        against the budget); context that alone takes more than half the
+        budget is dropped.
+    """
+    counter = get_token_counter(encoding)
+    result = []
+    for chunk in chunks:
+        context_tokens = counter.count(chunk.context) + 1 if chunk.context else 0
+        if max_tokens > 0 and context_tokens > max_tokens // 2:
+            chunk = replace(chunk, context=None)
+            context_tokens = 0
+        
+        parts = split_chunk(chunk, max_tokens - context_tokens, overlap_tokens, counter,
+                            overlap_strategy) if max_tokens > 0 else [chunk]
+        result.extend(with_context(part) for part in parts)
+    return result
+
+
+def with_context(chunk: CodeChunk) -> CodeChunk:
+    """The chunk with its context written in front of the content (header_chars covers it)"""
+    if not chunk.context:
+        return chunk
+    metadata = dict(chunk.metadata or {})
+    metadata['header_chars'] = metadata.get('header_chars', 0) + len(chunk.context) + 1
+    return replace(chunk, content=f"{chunk.context}\n{chunk.content}", metadata=metadata, context=None)
+
+
+def split_chunk(chunk: CodeChunk, max_tokens: int, overlap_tokens: int,
+                counter: TokenCounter, overlap_strategy: str = 'fixed_token') -> List[CodeChunk]:
+    """
+    Split one chunk into parts of at most max_tokens
+    
+    Parts break at line boundaries where possible. Parts after the first
+    repeat the signature header. All parts share the chunk's symbol_id and
+    carry part_index / part_count so they can be stitched back together.
+    """
+    if counter.count(chunk.content) <= max_tokens:
+        return [chunk]
+    
+    header = _header(chunk)
+    budget = max(max_tokens - counter.count(header) - 1, max_tokens // 2)
+    overlap_tokens = min(overlap_tokens, budget // 2)
+    
+    pieces = pack_segments(line_segments(chunk.content, chunk.line_start, budget, counter), budget, overlap_tokens,
+                           overlap_strategy, chunk.content)
+    
+    symbol_id = chunk.symbol_id or chunk.default_symbol_id()
+    parts = []
+    for index, piece in enumerate(pieces):
+        body = chunk.content[piece[0][1]:piece[-1][2]]
+        byte_start = byte_end = None
+        if chunk.byte_start is not None and chunk.byte_end is not None:
+            # The body's own span in the file (the repeated header is not part of it)
+            byte_start = min(chunk.byte_start + len(chunk.content[:piece[0][1]].encode('utf-8')), chunk.byte_end)
+            byte_end = min(byte_start + len(body.encode('utf-8')), chunk.byte_end)
+        parts.append(replace(
+            chunk,
+            content=body if index == 0 else f"{header}\n{body}",
+            line_start=piece[0][0],
+            line_end=piece[-1][0],
+            byte_start=byte_start,
+            byte_end=byte_end,
+            symbol_id=symbol_id,
+            part_index=index,
+            part_count=len(pieces),
+            metadata=dict(
+                chunk.metadata or {},
+                header_chars=0 if index == 0 else len(header) + 1,
+                content_offset=piece[0][1]
+            )
+        ))
+    return parts
+
+
+def line_segments(text: str, line_start: int, budget: int, counter: TokenCounter) -> List[Tuple[int, int, int, int]]:
+    """(line number, start offset, end offset, tokens) per line of text, lines cut to fit the budget"""
+    segments = []
+    offset = 0
+    for line_offset, line in enumerate(text.split('\n')):
+        for piece in _split_long_line(line, budget, counter):
+            segments.append((line_start + line_offset, offset, offset + len(piece), counter.count(piece) + 1))
+            offset += len(piece)
+        offset += 1  # the newline
+    return segments
+
+
+def pack_segments(segments: List[Tuple[int, int, int, int]], budget: int, overlap_tokens: int,
+                  strategy: str = 'fixed_token', text: str = '') -> List[List[Tuple[int, int, int, int]]]:
+    """
+    Greedy packing of line segments into parts, each repeating about overlap_tokens
+    of the one before as the strategy has it (see OVERLAP_STRATEGIES)
+    
+    Args:
+        segments: Line segments of text (see line_segments)
+        budget: Token budget per part
+        overlap_tokens: Overlap budget
+        strategy: One of OVERLAP_STRATEGIES; 'semantic' reads the text the
+            segments are offsets into
+    """
+    strategy = parse_overlap_strategy(strategy)
+    starts, ends = statement_boundaries(segments, text) if strategy == 'semantic' else (set(), set())
+    line_starts = {i for i in range(len(segments)) if i == 0 or segments[i - 1][0] != segments[i][0]}
+    pieces = []
+    start = 0
+    while start < len(segments):
+        end = start
+        used = 0
+        while end < len(segments) and (used + segments[end][3] <= budget or end == start):
+            used += segments[end][3]
+            end += 1
+        if strategy == 'semantic' and end < len(segments):
+            end = _statement_end(segments, ends, start, end, used)
+        pieces.append(segments[start:end])
+        if end >= len(segments):
+            break
+        
+        next_start = end
+        carried = 0
+        while next_start - 1 > start and carried + segments[next_start - 1][3] <= overlap_tokens:
+            next_start -= 1
+            carried += segments[next_start][3]
+        if strategy == 'line_aligned':
+            next_start = _line_overlap(segments, line_starts, start, end, next_start, carried,
+                                       overlap_tokens, budget // 2)
+        elif strategy == 'semantic' and overlap_tokens > 0:
+            next_start = _statement_overlap(segments, starts, start, end, next_start, carried,
+                                            min(2 * overlap_tokens, budget // 2))
+        start = next_start
+    return pieces
+
+
+def parse_overlap_strategy(value: Optional[str]) -> str:
+    """Overlap strategy from a setting value: 'fixed_token', 'line-aligned', 'Semantic'"""
+    if not value:
+        return OVERLAP_STRATEGIES[0]
+    normalized = value.strip().lower().replace('-', '_')
+    if normalized not in OVERLAP_STRATEGIES:
+        raise ValueError(f"Unknown overlap strategy '{value}' (expected one of: {', '.join(OVERLAP_STRATEGIES)})")
+    return normalized
+
+
+def statement_boundaries(segments: List[Tuple[int, int, int, int]], text: str) -> Tuple[Set[int], Set[int]]:
+    """
+    Indices of the segments that begin a statement or block, and of those a part
+    may end before
+    
+    Statements begin at the first segments of non-blank lines that follow a blank
+    line, a line ending in ; { } or :, or a complete line indented at least as
+    deep, and that do not continue the line above (a closing bracket, a leading
+    operator or .method). A part may end before one unless the line above opens
+    a block or a bracket, leaving it empty.
+    """
+    starts, ends = set(), set()
+    previous = ''
+    for index, (line, start, end, _) in enumerate(segments):
+        if index and segments[index - 1][0] == line:
+            continue
+        stop = text.find('\n', start)
+        current = text[start:stop if stop >= 0 else len(text)]
+        if index == 0 or (current.strip() and _starts_statement(previous, current)):
+            starts.add(index)
+            if not previous.rstrip().endswith(OPENERS):
+                ends.add(index)
+        previous = current if current.strip() else ''
+    return starts, ends
+
+
+def _starts_statement(previous: str, line: str) -> bool:
+    """True if line begins a statement after the line previous ('' after a blank line)"""
+    if not previous:
+        return True
+    if CONTINUING_LINE_START.match(line):
+        return False
+    if previous.rstrip().endswith((';', '{', '}', ':')):
+        return True
+    if CONTINUED_LINE_END.search(previous):
+        return False
+    return _indent(line) <= _indent(previous)
+
+
+def _indent(line: str) -> int:
+    """Width of a line's leading whitespace, tabs counting 4"""
+    whitespace = line[:len(line) - len(line.lstrip(' \t'))]
+    return len(whitespace) + 3 * whitespace.count('\t')
+
+
+def _statement_end(segments: List[Tuple[int, int, int, int]], ends: Set[int],
+                   start: int, end: int, used: int) -> int:
+    """The packed part's end moved back to the last statement end in its second half, if any"""
+    tokens = used
+    for candidate in range(end, start + 1, -1):
+        if tokens * 2 < used:
+            break
+        if candidate in ends:
+            return candidate
+        tokens -= segments[candidate - 1][3]
+    return end
+
+
+def _line_overlap(segments: List[Tuple[int, int, int, int]], line_starts: Set[int], start: int,
+                  end: int, next_start: int, carried: int, overlap_tokens: int, limit: int) -> int:
+    """
+    Start of a line-aligned overlap: the line start whose carried tokens come
+    closest to overlap_tokens, at most limit (a fixed_token overlap starting
+    inside a cut line moves back to the line's start, or on to the next line)
+    """
+    if overlap_tokens <= 0:
+        return end
+    index, tokens = next_start, carried
+    while index not in line_starts and index > start + 1 and tokens + segments[index - 1][3] <= limit:
+        index -= 1
+        tokens += segments[index][3]
+    if index not in line_starts:
+        index = next((i for i in range(next_start, end) if i in line_starts), end)
+        tokens = sum(segment[3] for segment in segments[index:end])
+    # One more line if its tokens bring the overlap nearer the budget
+    previous = max((i for i in line_starts if start < i < index), default=None)
+    if previous is not None:
+        more = tokens + sum(segment[3] for segment in segments[previous:index])
+        if more <= limit and abs(more - overlap_tokens) < abs(overlap_tokens - tokens):
+            return previous
+    return index
+
+
+def _statement_overlap(segments: List[Tuple[int, int, int, int]], starts: Set[int], start: int,
+                       end: int, next_start: int, carried: int, limit: int) -> int:
+    """
+    Start of a semantic overlap: the line-aligned one extended back to the
+    nearest statement start within limit tokens, else moved forward to one
+    (less overlap), else left as it is
+    """
+    index, tokens = next_start, carried
+    while index > start + 1 and index not in starts and tokens + segments[index - 1][3] <= limit:
+        index -= 1
+        tokens += segments[index][3]
+    if index in starts and index > start:
+        return index
+    forward = next((i for i in range(next_start, end + 1) if i in starts or i == end), end)
+    return forward if forward > start else next_start
+
+
+def stitch_parts(parts: List[Dict]) -> str:
+    """
+    Rebuild a symbol's source from its stored parts (database result dicts)
+    Each part records where its body starts in the original, so overlaps
+    and repeated headers drop out exactly
+    """
+    ordered = sorted(parts, key=lambda p: int(p['metadata'].get('part_index', 0)))
+    source = ''
+    for part in ordered:
+        extra = parse_metadata(part['metadata'].get('metadata'))
+        body = part['content'][extra.get('header_chars', 0):]
+        offset = extra.get('content_offset', len(source))
+        # Parts end before the newline that separates them from the next one
+        source = source[:offset].ljust(offset, '\n') + body
+    return source
+
+
+def _header(chunk: CodeChunk) -> str:
+    """Signature header repeated on continuation parts"""
+    if chunk.signature:
+        return chunk.signature
+    return chunk.content.split('\n', 1)[0]
+
+
+def _split_long_line(line: str, budget: int, counter: TokenCounter) -> List[str]:
+    """Cut a single line that alone exceeds the budget (minified code, data blobs)"""
+    if counter.count(line) + 1 <= budget:
+        return [line]
+    
+    pieces = []
+    while line:
+        # Shrink a character window until it fits the budget
+        size = min(len(line), budget * 4)
+        while size > 1 and counter.count(line[:size]) + 1 > budget:
+            size = size * 3 // 4
+        pieces.append(line[:size])
+        line = line[size:]
+    return pieces
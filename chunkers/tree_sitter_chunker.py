@@ -7,7 +7,7 @@ Allows adding support for new languages by simply providing a query
 from typing import List, Optional, Dict, Any
 from tree_sitter import Language, Parser, Node, QueryCursor
 
-from .base_chunker import BaseChunker, CodeChunk
+from .base_chunker import BaseChunker, CodeChunk, tree_sitter_diagnostics
 from utils.logger import get_logger
 
 class GenericTreeSitterChunker(BaseChunker):
@@ -41,24 +41,25 @@ class GenericTreeSitterChunker(BaseChunker):
             except AttributeError:
                 # Fallback for older versions
                 self.query = self.ts_language.query(query_scm)
-            
+        
         except ImportError as e:
             self.logger.error(f"Could not import tree-sitter-language-pack. Install it with: pip install tree-sitter-language-pack")
             raise
         except Exception as e:
             self.logger.error(f"Failed to initialize {language_name} parser: {e}")
             raise
-
+    
     def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
         """
         Extract chunks using tree-sitter query
         """
         if not code.strip():
             return []
-            
+        
         try:
             import tree_sitter
             tree = self.parser.parse(bytes(code, "utf8"))
+            self.diagnostics = tree_sitter_diagnostics(tree.root_node)
             
             # Handle API changes in tree-sitter QueryCursor
             try:
@@ -88,13 +89,13 @@ class GenericTreeSitterChunker(BaseChunker):
                         node = capture.node
                         tag = capture.name
                     self._process_capture(node, tag, code, filepath, chunks)
-                
-            return chunks
             
+            return chunks
+        
         except Exception as e:
             self.logger.error(f"Error chunking {self.language}: {e}")
             return []
-
+    
     def _process_capture(self, node, tag: str, code: str, filepath: str, chunks: List[CodeChunk]):
         """Process a single capture and add to chunks list"""
         # Extract text
@@ -121,9 +122,9 @@ class GenericTreeSitterChunker(BaseChunker):
             line_start=node.start_point[0] + 1,
             line_end=node.end_point[0] + 1
         ))
-            
-
-
+    
+    
+    
     def _map_tag_to_type(self, tag: str) -> str:
         """Map tree-sitter capture tag to chunk type"""
         # Remove @ prefix if present
@@ -0,0 +1,320 @@
+#!/usr/bin/env python3
+"""
+YAML chunker splitting configuration files by document and key
+Supports .yaml and .yml files
+
+A stream is split into its '---' documents, and each document into its
+top-level keys or list items by their indentation (block scalars, flow
+collections and comments are skipped over, so a '|' script holding "key:"
+lines starts no entry). Line ranges and text come from the file as written;
+values are read with PyYAML when it is installed, or by a reader of the
+common block subset otherwise (Helm templates, which PyYAML rejects, are read
+the same way). See config_chunks for what becomes a chunk.
+"""
+
+import re
+from typing import Dict, List, Optional, Tuple
+
+from .base_chunker import BaseChunker, CodeChunk
+from .config_chunks import ConfigChunkBuilder, ConfigNode
+from .markdown_chunker import _yaml_scalar
+
+
+DOCUMENT_START = re.compile(r'^---(?=\s|$)')
+DOCUMENT_END = re.compile(r'^\.\.\.\s*$')
+
+# key: at the start of a line (quoted, or plain up to the first ': ')
+MAPPING_KEY = re.compile(r'''^(?:"((?:[^"\\]|\\.)*)"|'((?:[^']|'')*)'|((?:[^\s#'"?:,\[\]{}&*!|>%@`-]|[?:-](?=\S))[^#]*?))[ \t]*:(?=\s|$)''')
+
+# | and > block scalars, with their chomping and indentation indicators
+BLOCK_SCALAR = re.compile(r'^[|>][-+0-9]*\s*(?:#.*)?$')
+
+# Anchors and tags before a value (&defaults, !!map)
+PROPERTIES = re.compile(r'^(?:[&!][^\s]*\s*)+')
+
+
+def load_yaml(text: str):
+    """
+    A YAML document's value, with PyYAML when it is installed and can parse it,
+    otherwise with parse_simple_yaml
+    """
+    try:
+        import yaml
+    except ImportError:
+        return parse_simple_yaml(text)
+    try:
+        return yaml.safe_load(text)
+    except yaml.YAMLError:
+        return parse_simple_yaml(text)
+
+
+def parse_simple_yaml(text: str):
+    """
+    A YAML document read without PyYAML: block mappings, lists and scalars
+    (flow collections are kept as their text, block scalars as their lines)
+    """
+    lines = [line.rstrip() for line in text.split('\n') if line.strip() and not line.lstrip().startswith('#')]
+    return _read_block(lines, 0, 0)[0]
+
+
+def _indent(line: str) -> int:
+    return len(line) - len(line.lstrip(' '))
+
+
+def _is_item(text: str) -> bool:
+    return text == '-' or text.startswith(('- ', '-\t'))
+
+
+def _key_text(match: re.Match) -> str:
+    quoted = match.group(1) if match.group(1) is not None else match.group(2)
+    if quoted is not None:
+        return quoted.replace("''", "'") if match.group(2) is not None else quoted
+    return match.group(3)
+
+
+def _value_text(rest: str) -> str:
+    """The value after a key or dash: without anchors, tags and a trailing comment"""
+    rest = PROPERTIES.sub('', rest.strip())
+    if rest[:1] in ('"', "'"):
+        close = rest.find(rest[0], 1)
+        return rest[:close + 1] if close != -1 else rest
+    return re.sub(r'(?:^|\s+)#.*$', '', rest)
+
+
+def _read_block(lines: List[str], index: int, indent: int) -> Tuple[object, int]:
+    """The mapping, list or scalar starting at lines[index], if it is indented by at least indent"""
+    if index >= len(lines) or _indent(lines[index]) < indent:
+        return None, index
+    indent = _indent(lines[index])
+    text = lines[index].strip()
+    
+    if _is_item(text):
+        items = []
+        while index < len(lines) and _indent(lines[index]) == indent and _is_item(lines[index].strip()):
+            rest = lines[index].strip()[1:].strip()
+            if not rest:
+                item, index = _read_block(lines, index + 1, indent + 1)
+            elif MAPPING_KEY.match(rest):
+                # The item's first key is on the dash's line: read it from where it stands
+                lines[index] = ' ' * (len(lines[index]) - len(rest)) + rest
+                item, index = _read_block(lines, index, indent + 1)
+            else:
+                item, index = _scalar_value(lines, index, indent, rest)
+            items.append(item)
+        return items, index
+    
+    if not MAPPING_KEY.match(text):
+        return _yaml_scalar(_value_text(text)), index + 1
+    mapping: Dict = {}
+    while index < len(lines) and _indent(lines[index]) == indent:
+        match = MAPPING_KEY.match(lines[index].strip())
+        if not match:
+            break
+        rest = lines[index].strip()[match.end():]
+        if not _value_text(rest):
+            index += 1
+            # A list may stand at its key's indentation
+            nested = indent if index < len(lines) and _indent(lines[index]) == indent \
+                and _is_item(lines[index].strip()) else indent + 1
+            value, index = _read_block(lines, index, nested)
+        else:
+            value, index = _scalar_value(lines, index, indent, rest)
+        mapping[_key_text(match)] = value
+    return mapping, index
+
+
+def _scalar_value(lines: List[str], index: int, indent: int, rest: str) -> Tuple[object, int]:
+    """A scalar on the line of its key or dash, with the lines of a block scalar below it"""
+    value = _value_text(rest)
+    end = index + 1
+    while end < len(lines) and _indent(lines[end]) > indent:
+        end += 1
+    if BLOCK_SCALAR.match(value):
+        separator = ' ' if value.startswith('>') else '\n'
+        text = separator.join(line.strip() for line in lines[index + 1:end])
+        return text if '-' in value else text + '\n', end
+    return _yaml_scalar(value), end
+
+
+class YamlChunker(BaseChunker):
+    """Extracts documents, keys and Kubernetes manifests from YAML files"""
+    
+    def __init__(self):
+        super().__init__('yaml')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract the chunks of every document in the stream"""
+        scanner = YamlScanner(code)
+        documents = [node for node in map(scanner.document, scanner.documents()) if node]
+        builder = ConfigChunkBuilder(code, filepath, self.language, len(documents))
+        for index, node in enumerate(documents):
+            builder.document(node, load_yaml(code[node.start:node.end]), index)
+        return [c for c in builder.chunks if self._should_include_chunk(c)]
+
+
+class YamlScanner:
+    """Finds the documents of a YAML stream and the entries of their blocks"""
+    
+    def __init__(self, code: str):
+        self.code = code
+        self.lines = code.split('\n')
+        self.line_starts = [0]
+        for line in self.lines:
+            self.line_starts.append(self.line_starts[-1] + len(line) + 1)
+        # Columns at which lines are read, for lines whose first part is a '---' or a dash
+        self.columns: Dict[int, int] = {}
+    
+    def documents(self) -> List[Tuple[int, int]]:
+        """(first line, end line) of each document, 0-based and end-exclusive"""
+        documents = []
+        first = 0
+        for index, line in enumerate(self.lines):
+            if DOCUMENT_START.match(line):
+                documents.append((first, index))
+                first = index + 1
+                rest = line[3:].lstrip()
+                if rest and not rest.startswith('#'):
+                    # A document that starts on its marker line ('--- |', '--- !tag')
+                    self.columns[index] = len(line) - len(rest)
+                    first = index
+            elif DOCUMENT_END.match(line):
+                documents.append((first, index))
+                first = index + 1
+            elif index == first and line.startswith('%'):
+                first = index + 1  # a directive (%YAML 1.2) before the document
+        documents.append((first, len(self.lines)))
+        return documents
+    
+    def document(self, span: Tuple[int, int]) -> Optional[ConfigNode]:
+        """A document's entry tree; None for a document holding only comments"""
+        first, last = span
+        content = [i for i in range(first, last) if self._is_content(i)]
+        if not content:
+            return None
+        node = ConfigNode('', self._start(content[0]), self._end(content[-1]), doc=self._doc(content[0], first))
+        node.children, node.sequence = self._block(content[0], content[-1] + 1, first)
+        return node
+    
+    def _text(self, index: int) -> Tuple[int, str]:
+        """Indentation and text of a line, read from its column"""
+        line = self.lines[index]
+        column = self.columns.get(index, 0)
+        text = line[column:].lstrip(' ')
+        return len(line) - len(text), text.rstrip()
+    
+    def _is_content(self, index: int) -> bool:
+        text = self._text(index)[1]
+        return bool(text) and not text.startswith('#')
+    
+    def _start(self, index: int) -> int:
+        return self.line_starts[index] + self._text(index)[0]
+    
+    def _end(self, index: int) -> int:
+        return self.line_starts[index] + len(self.lines[index].rstrip())
+    
+    def _doc(self, index: int, first: int) -> Optional[str]:
+        """The comment lines right above a line, from the first line on"""
+        lines = []
+        index -= 1
+        while index >= first:
+            text = self._text(index)[1]
+            if not text.startswith('#'):
+                break
+            lines.insert(0, text[1:].strip())
+            index -= 1
+        text = '\n'.join(lines).strip()
+        return text or None
+    
+    def _block(self, first: int, last: int, doc_from: Optional[int] = None) -> Tuple[List[ConfigNode], bool]:
+        """
+        Entries of the block in lines first..last (its first line is content):
+        keys of a mapping, or items of a list; a scalar or flow block has none.
+        Comments from doc_from on are docs of the entries below them
+        """
+        indent, text = self._text(first)
+        sequence = _is_item(text)
+        if not sequence and not MAPPING_KEY.match(text):
+            return [], False
+        
+        starts, ends = [], []
+        scalar_indent = None  # indentation of the key whose block scalar is being skipped
+        flow_depth = 0
+        for index in range(first, last):
+            line_indent, text = self._text(index)
+            if scalar_indent is not None:
+                if not text or line_indent > scalar_indent:
+                    if text:
+                        ends[-1] = index
+                    continue
+                scalar_indent = None
+            if not text or text.startswith('#'):
+                continue
+            if flow_depth:
+                flow_depth = _flow_depth(text, flow_depth)
+                ends[-1] = index
+                continue
+            
+            if line_indent == indent and ((sequence and _is_item(text))
+                                          or (not sequence and not _is_item(text) and MAPPING_KEY.match(text))):
+                starts.append(index)
+                ends.append(index)
+            elif starts:
+                ends[-1] = index
+            value = self._line_value(text)
+            if value is not None:
+                if BLOCK_SCALAR.match(value):
+                    scalar_indent = line_indent
+                elif value[:1] in ('[', '{'):
+                    flow_depth = _flow_depth(value, 0)
+        
+        entries = []
+        for number, (start, end) in enumerate(zip(starts, ends)):
+            line_indent, text = self._text(start)
+            key = f'[{number}]' if sequence else _key_text(MAPPING_KEY.match(text))
+            doc = self._doc(start, first if doc_from is None else doc_from)
+            node = ConfigNode(key, self._start(start), self._end(end), doc=doc)
+            node.children, node.sequence = self._value_block(start, end, line_indent, text, sequence)
+            entries.append(node)
+        return entries, sequence
+    
+    def _value_block(self, start: int, end: int, indent: int, text: str, item: bool) -> Tuple[List[ConfigNode], bool]:
+        """Entries of an entry's mapping or list value"""
+        if item:
+            rest = text[1:].lstrip()
+            if rest and MAPPING_KEY.match(rest):
+                # The item's mapping starts on the dash's line
+                self.columns[start] = indent + len(text) - len(rest)
+                return self._block(start, end + 1)
+            start += 1
+        elif _value_text(text[MAPPING_KEY.match(text).end():]):
+            return [], False
+        else:
+            start += 1
+        content = next((i for i in range(start, end + 1) if self._is_content(i)), None)
+        return self._block(content, end + 1, start) if content is not None else ([], False)
+    
+    def _line_value(self, text: str) -> Optional[str]:
+        """The value on a key's or dash's line, if it has one"""
+        while _is_item(text):
+            text = text[1:].lstrip()
+        match = MAPPING_KEY.match(text)
+        value = _value_text(text[match.end():] if match else text)
+        return value or None
+
+
+def _flow_depth(text: str, depth: int) -> int:
+    """Bracket depth of a flow collection after a line of it ('#' comments and quotes skipped)"""
+    quote = None
+    for i, ch in enumerate(text):
+        if quote:
+            if ch == quote:
+                quote = None
+        elif ch in ('"', "'"):
+            quote = ch
+        elif ch == '#' and (i == 0 or text[i - 1] in ' \t'):
+            break
+        elif ch in '[{':
+            depth += 1
+        elif ch in ']}':
+            depth -= 1
+    return max(depth, 0)
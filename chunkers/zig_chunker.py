@@ -0,0 +1,501 @@
+#!/usr/bin/env python3
+"""
+Zig code chunker using tree-sitter for accurate parsing
+Supports .zig files
+
+A Zig file is a struct: its top level is a container body like the body of
+'struct { ... }', holding fn declarations, const and var declarations, test
+blocks and (rarely) fields. Types are values, so 'const Point = struct { ... };'
+is how a type gets its name: a const or var bound to a struct, enum, union,
+opaque or error set is a type chunk named after it, listing its fields or
+variants and the methods in its body. A function returning 'type' whose body
+returns such a container ('fn List(comptime T: type) type { return struct {} }')
+is a generic type: its members belong to it. Function bodies are not entered,
+so nothing inside a function is mistaken for a declaration. '///' comments
+fill the doc field, '//!' comments at the top of the file document the file's
+own module chunk; 'pub' is recorded in the metadata, and comptime parameters
+keep their keyword in the signature.
+"""
+
+import re
+from dataclasses import dataclass
+from pathlib import PurePosixPath
+from typing import Dict, List, Optional, Tuple
+from tree_sitter import Node
+
+from .base_chunker import BaseChunker, CodeChunk, parse_error, tree_sitter_diagnostics
+
+
+# Words that may precede fn, const or var in a declaration
+DECL_QUALIFIERS = {'pub', 'export', 'extern', 'inline', 'noinline', 'threadlocal'}
+
+# Nodes a const can name as a type: struct, enum, union and opaque, and error sets
+CONTAINER_NODES = ('ContainerDecl', 'ErrorSetDecl')
+
+COMMENTS = ('line_comment', 'doc_comment', 'container_doc_comment')
+
+# align(4), callconv(.C), linksection(".text") and addrspace(.gs) between a fn's parameters and its return type
+FN_ATTRIBUTE = re.compile(r'(align|callconv|linksection|addrspace)\s*\((.*)\)\Z', re.DOTALL)
+
+# What comes before the '{' of a container: packed struct(u32), union(enum), error
+CONTAINER_HEADER = re.compile(r'(?:(packed|extern)\s+)?(struct|enum|union|opaque|error)\s*(?:\((.*)\))?\s*\Z',
+                              re.DOTALL)
+
+
+@dataclass
+class ZigComment:
+    """A line comment; kind is 'doc' (///), 'container' (//!) or 'plain'"""
+    text: str
+    kind: str
+    start: int
+    end: int
+    line: int
+
+
+def _comment_kind(text: str) -> str:
+    if text.startswith('///') and not text.startswith('////'):
+        return 'doc'
+    if text.startswith('//!'):
+        return 'container'
+    return 'plain'
+
+
+def doc_comment_text(comments: List[ZigComment]) -> str:
+    """Text of a doc comment group without the /// or //! markers"""
+    lines = []
+    for comment in comments:
+        body = comment.text[3:]
+        lines.append(body[1:] if body.startswith(' ') else body)
+    return '\n'.join(lines).strip()
+
+
+def normalize_signature(signature: str) -> str:
+    """Collapse whitespace in a rendered signature"""
+    signature = re.sub(r'\s+', ' ', signature).strip().rstrip(',')
+    return re.sub(r'\s+([,;)\]])|([(\[])\s+', lambda m: m.group(1) or m.group(2), signature)
+
+
+def identifier(text: str) -> str:
+    """Name an identifier stands for (@"two words" -> two words)"""
+    if text.startswith('@"'):
+        return text[2:-1]
+    return text
+
+
+class ZigChunker(BaseChunker):
+    """Extracts functions, container types, constants, variables and tests from Zig code"""
+    
+    def __init__(self):
+        super().__init__('zig')
+        from tree_sitter_language_pack import get_parser
+        self.parser = get_parser('zig')
+    
+    def extract_chunks(self, code: str, filepath: str) -> List[CodeChunk]:
+        """Extract all Zig code elements"""
+        self._source = bytes(code, "utf8")
+        tree = self.parser.parse(self._source)
+        self.diagnostics = tree_sitter_diagnostics(tree.root_node)
+        self._filepath = filepath
+        comments = self._collect_comments(tree.root_node)
+        self._docs_by_line = {c.line: c for c in comments if c.kind == 'doc'}
+        self._unclosed: Dict[int, Tuple[int, str]] = {}  # line of a missing '}' -> the declaration it should close
+        chunks: List[CodeChunk] = []
+        
+        # //! comments at the top of the file document the struct the file is
+        first_decl = next((c for c in tree.root_node.named_children if c.type not in COMMENTS), None)
+        file_docs = [c for c in comments
+                     if c.kind == 'container' and (first_decl is None or c.end <= first_decl.start_byte)]
+        file_doc = doc_comment_text(file_docs)
+        if file_doc:
+            first, last = file_docs[0], file_docs[-1]
+            chunks.append(CodeChunk(
+                type='module',
+                name=PurePosixPath(filepath.replace('\\', '/')).stem,
+                content=self._decode(first.start, last.end),
+                filepath=filepath,
+                language=self.language,
+                line_start=first.line,
+                line_end=last.line,
+                doc=file_doc,
+                metadata={'file_module': True}
+            ))
+        
+        self._parse_container(tree.root_node, [], chunks)
+        
+        # "missing '}' for fn f" says more than tree-sitter's message
+        for diagnostic in self.diagnostics:
+            if diagnostic['message'] == "missing '}'" and diagnostic['line'] in self._unclosed:
+                diagnostic.update(parse_error(*self._unclosed[diagnostic['line']]))
+        return [c for c in chunks if self._should_include_chunk(c)]
+    
+    # ------------------------------------------------------------------
+    # Container members
+    # ------------------------------------------------------------------
+    
+    def _parse_container(self, node: Node, path: List[str], chunks: List[CodeChunk]) -> Dict:
+        """
+        Extract the declarations of the file, a container body or an error set
+        
+        Args:
+            path: Names of the enclosing types, outermost first ([] for the file)
+        
+        Returns:
+            {'fields': [...], 'variants': [...], 'methods': [...]} of the body
+        """
+        members: Dict = {'fields': [], 'variants': [], 'methods': []}
+        brace = self._child(node, '{') if node.type in CONTAINER_NODES else None
+        pub = None
+        for child in node.children:
+            if brace is not None and child.end_byte <= brace.start_byte:
+                continue  # the keyword and tag of the container
+            if child.type == 'pub':
+                pub = child
+                continue
+            
+            if child.type == 'ERROR':
+                recovered = self._parse_container(child, path, chunks)
+                for key, values in recovered.items():
+                    members[key].extend(values)
+            elif child.type == 'TopLevelDecl':
+                self._extract_decl(child, pub, path, members, chunks)
+            elif child.type == 'TestDecl':
+                self._extract_test(child, path, chunks)
+            elif child.type == 'ContainerField':
+                self._extract_member(child, members)
+            elif child.type == 'IDENTIFIER':
+                members['variants'].append(identifier(self._text(child)))  # a name of an error set
+            # comptime blocks and usingnamespace declare nothing to chunk
+            
+            if child.is_named and child.type not in COMMENTS:
+                pub = None
+        return members
+    
+    def _extract_member(self, node: Node, members: Dict):
+        """Record a field (name: Type = default) or a bare enum/error variant"""
+        children = [c for c in node.children if c.type not in COMMENTS and c.type != ',']
+        types = [c.type for c in children]
+        name_node = node.child_by_field_name('field_member') or self._child(node, 'IDENTIFIER')
+        name = identifier(self._text(name_node))
+        doc = self._doc_above(self._line(children[0]))
+        if ':' not in types:
+            members['variants'].append(name)
+            return
+        
+        colon = types.index(':')
+        equals = types.index('=') if '=' in types else len(children)
+        field: Dict = {'name': name, 'type': normalize_signature(self._span(children[colon + 1], children[equals - 1]))}
+        if equals < len(children) - 1:
+            field['default'] = normalize_signature(self._span(children[equals + 1], children[-1]))
+        if types[0] == 'comptime':
+            field['comptime'] = True
+        if doc:
+            field['doc'] = doc
+        members['fields'].append(field)
+    
+    # ------------------------------------------------------------------
+    # Declarations
+    # ------------------------------------------------------------------
+    
+    def _extract_decl(self, node: Node, pub: Optional[Node], path: List[str], members: Dict,
+                      chunks: List[CodeChunk]):
+        """A TopLevelDecl: a fn (prototype or definition) or a const or var"""
+        first = pub if pub is not None else self._start(node)
+        metadata: Dict = {'pub': pub is not None or self._child(node, 'pub') is not None}
+        qualifiers = [c.type for c in node.children if c.type in DECL_QUALIFIERS - {'pub'}]
+        
+        proto = self._child(node, 'FnProto')
+        if proto is not None:
+            name_node = proto.child_by_field_name('function') or self._child(proto, 'IDENTIFIER')
+            if name_node is None:
+                return
+            if qualifiers:
+                metadata['qualifiers'] = qualifiers
+            library = next((self._text(c).strip('"') for c in node.children
+                            if c.type == 'STRINGLITERALSINGLE' and c.prev_sibling is not None
+                            and c.prev_sibling.type == 'extern'), None)
+            if library:
+                metadata['library'] = library
+            name = identifier(self._text(name_node))
+            self._extract_fn(node, proto, first, name, path, metadata, chunks)
+            members['methods'].append(name)
+            return
+        
+        var = self._child(node, 'VarDecl')
+        if var is not None:
+            if qualifiers:
+                metadata['qualifiers'] = qualifiers
+            self._extract_var(node, var, first, path, metadata, chunks)
+    
+    def _extract_fn(self, node: Node, proto: Node, first: Node, name: str, path: List[str], metadata: Dict,
+                    chunks: List[CodeChunk]):
+        body = self._child(node, 'Block')
+        if body is not None and body.children and body.children[-1].is_missing:
+            self._unclosed[self._line(body.children[-1])] = (self._line(first), f"missing '}}' for fn {name}")
+        
+        params = self._child(proto, 'ParamDeclList')
+        metadata = {'params': self._parse_params(params), **metadata}
+        returns = self._return_type(proto, params, metadata) if params is not None else ''
+        if returns:
+            metadata['returns'] = returns
+        
+        chunk = CodeChunk(
+            type='method' if path else 'function',
+            name=name,
+            content=self._span(first, node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._span(first, proto)),
+            **self._owner(path),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, first)
+        chunks.append(chunk)
+        
+        # A function returning a type built in its body is a generic type: fn List(comptime T: type) type
+        if body is not None and returns == 'type':
+            container = self._returned_container(body)
+            if container is not None:
+                kind = self._container_header(container)[0]
+                members = self._parse_container(container, path + [name], chunks)
+                metadata['generic_type'] = kind
+                self._describe_members(metadata, kind, members)
+    
+    def _parse_params(self, node: Optional[Node]) -> List[Dict]:
+        """Parameters of a fn: name, type, and comptime/noalias when marked so"""
+        params = []
+        for child in node.children if node is not None else []:
+            if child.type in ('(', ')', ',') + COMMENTS:
+                continue
+            if self._text(child) == '...':
+                params.append({'name': '...', 'type': '...'})
+                continue
+            children = [c for c in child.children if c.type not in COMMENTS] or [child]
+            param: Dict = {}
+            while len(children) > 1 and children[0].type in ('comptime', 'noalias'):
+                param[children[0].type] = True
+                children = children[1:]
+            types = [c.type for c in children]
+            if ':' in types:
+                colon = types.index(':')
+                param = dict(name=identifier(self._text(children[0])),
+                             type=normalize_signature(self._span(children[colon + 1], children[-1])), **param)
+            else:
+                param = dict(name='', type=normalize_signature(self._span(children[0], children[-1])), **param)
+            params.append(param)
+        return params
+    
+    def _return_type(self, proto: Node, params: Node, metadata: Dict) -> str:
+        """Return type of a fn prototype after its parameters, recording align/callconv/linksection"""
+        rest = [c for c in proto.children if c.start_byte >= params.end_byte and c.type not in COMMENTS]
+        while rest:
+            attribute = FN_ATTRIBUTE.match(self._text(rest[0]))
+            if not attribute:
+                break
+            metadata[attribute.group(1)] = normalize_signature(attribute.group(2))
+            rest = rest[1:]
+        return normalize_signature(self._span(rest[0], rest[-1])) if rest else ''
+    
+    def _returned_container(self, body: Node) -> Optional[Node]:
+        """The container a fn body returns at its top level ('return struct { ... };'), if any"""
+        for statement in body.named_children:
+            raw = self._source[statement.start_byte:statement.end_byte]
+            returned = re.match(rb'return\s+', raw)
+            if returned:
+                end = statement.start_byte + len(raw.rstrip().rstrip(b';').rstrip())
+                container = self._container_at(statement, statement.start_byte + returned.end(), end)
+                if container is not None:
+                    return container
+        return None
+    
+    def _container_at(self, node: Node, start: int, end: int) -> Optional[Node]:
+        """The struct, enum, union, opaque or error set spanning exactly start..end under node, if any"""
+        while node is not None:
+            if node.type in CONTAINER_NODES and node.start_byte == start and node.end_byte == end:
+                return node
+            node = next((c for c in node.named_children if c.start_byte <= start and c.end_byte >= end), None)
+        return None
+    
+    def _container_header(self, node: Node) -> Tuple[str, Optional[str], Optional[str]]:
+        """(keyword, layout, tag) of a container: ('struct', 'packed', 'u8') for packed struct(u8)"""
+        brace = self._child(node, '{')
+        header = self._decode(node.start_byte, brace.start_byte if brace is not None else node.end_byte)
+        match = CONTAINER_HEADER.match(header.strip())
+        if not match:
+            return ('error' if node.type == 'ErrorSetDecl' else 'struct'), None, None
+        return match.group(2), match.group(1), match.group(3)
+    
+    def _extract_var(self, node: Node, var: Node, first: Node, path: List[str], metadata: Dict,
+                     chunks: List[CodeChunk]):
+        """A const or var: a type chunk when it names a container, else (at the top level) a constant or variable"""
+        children = [c for c in var.children if c.type not in COMMENTS]
+        types = [c.type for c in children]
+        name_node = var.child_by_field_name('variable_type_function') or self._child(var, 'IDENTIFIER')
+        name = identifier(self._text(name_node))
+        binding = 'var' if 'var' in types else 'const'
+        end = types.index(';') if ';' in types else len(children)
+        equals = types.index('=') if '=' in types else None
+        container = None
+        if equals is not None and equals + 1 < end:
+            container = self._container_at(var, children[equals + 1].start_byte, children[end - 1].end_byte)
+        if container is None and path:
+            return  # constants inside a type are part of its chunk
+        
+        metadata['binding'] = binding
+        if ':' in types and (equals is None or types.index(':') < equals):
+            colon = types.index(':')
+            metadata['declared_type'] = normalize_signature(
+                self._span(children[colon + 1], children[(equals or end) - 1]))
+        
+        if container is not None:
+            kind, layout, tag = self._container_header(container)
+            closing = container.children[-1] if container.children else None
+            if closing is not None and closing.is_missing:
+                self._unclosed[self._line(closing)] = (self._line(first), f"missing '}}' for {kind} {name}")
+            if layout:
+                metadata['layout'] = layout
+            if tag:
+                metadata['tag'] = normalize_signature(tag)
+            chunk_type = kind if kind in ('struct', 'enum', 'union') else 'type'
+            if kind != chunk_type:
+                metadata['container'] = 'error_set' if kind == 'error' else kind
+            brace = self._child(container, '{')
+            signature = self._decode(first.start_byte, brace.start_byte if brace is not None else container.end_byte)
+        else:
+            chunk_type = 'constant' if binding == 'const' else 'variable'
+            signature = self._span(first, children[(equals or end) - 1])
+            if equals is not None and equals + 1 < end:
+                value = self._span(children[equals + 1], children[end - 1])
+                imported = re.fullmatch(r'@import\(\s*"([^"]*)"\s*\)((?:\s*\.\s*\w+)*)', value)
+                if imported:
+                    metadata['import'] = imported.group(1)
+                    if imported.group(2):
+                        metadata['member'] = re.sub(r'\s+', '', imported.group(2))[1:]
+                elif '\n' not in value and len(value) <= 80:
+                    metadata['value'] = value
+        
+        chunk = CodeChunk(
+            type=chunk_type,
+            name=name,
+            content=self._span(first, node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(node),
+            signature=normalize_signature(signature),
+            **self._owner(path),
+            metadata=metadata
+        )
+        self._attach_doc(chunk, first)
+        chunks.append(chunk)
+        
+        if container is not None:
+            members = self._parse_container(container, path + [name], chunks)
+            self._describe_members(metadata, kind, members)
+    
+    def _describe_members(self, metadata: Dict, kind: str, members: Dict):
+        """Add a container's fields, variants (enum values, error names) and methods to its metadata"""
+        if kind == 'enum' or kind == 'error':
+            names = members['variants'] + [field['name'] for field in members['fields']]
+            if names:
+                metadata['errors' if kind == 'error' else 'variants'] = names
+        else:
+            fields = members['fields'] + [{'name': variant, 'type': 'void'} for variant in members['variants']]
+            if fields:
+                metadata['fields'] = fields
+        if members['methods']:
+            metadata['methods'] = members['methods']
+    
+    def _extract_test(self, node: Node, path: List[str], chunks: List[CodeChunk]):
+        """test "name" { ... } (or a decltest, test name { ... }); anonymous tests are skipped"""
+        name_node = next((c for c in node.named_children if c.type not in COMMENTS + ('Block',)), None)
+        if name_node is None:
+            return
+        
+        first = self._start(node)
+        decltest = name_node.type == 'IDENTIFIER'
+        chunk = CodeChunk(
+            type='function',
+            name=identifier(self._text(name_node)) if decltest else self._text(name_node)[1:-1],
+            content=self._span(first, node),
+            filepath=self._filepath,
+            language=self.language,
+            line_start=self._line(first),
+            line_end=self._line_end(node),
+            signature=normalize_signature(self._span(first, name_node)),
+            **self._owner(path),
+            metadata={'test': True, **({'decltest': True} if decltest else {})},
+            kind='test'
+        )
+        self._attach_doc(chunk, first)
+        chunks.append(chunk)
+    
+    # ------------------------------------------------------------------
+    # Helpers
+    # ------------------------------------------------------------------
+    
+    def _collect_comments(self, root: Node) -> List[ZigComment]:
+        """Every comment line of the file in source order; a run of /// or //! lines is one node"""
+        comments = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type not in COMMENTS:
+                stack.extend(node.children)
+                continue
+            offset = node.start_byte
+            for raw in self._source[node.start_byte:node.end_byte].split(b'\n'):
+                start = offset + len(raw) - len(raw.lstrip())
+                text = raw.strip().decode('utf8', errors='replace')
+                if text.startswith('//'):
+                    line = self._source.count(b'\n', 0, start) + 1
+                    comments.append(ZigComment(text, _comment_kind(text), start, start + len(raw.strip()), line))
+                offset += len(raw) + 1
+        return sorted(comments, key=lambda c: c.start)
+    
+    def _attach_doc(self, chunk: CodeChunk, first: Node):
+        """Store the /// comments directly above a declaration"""
+        doc = self._doc_above(self._line(first))
+        if doc:
+            chunk.doc = doc
+    
+    def _doc_above(self, line: int) -> str:
+        """Text of the /// group ending on the line above a declaration's first line"""
+        group = []
+        comment = self._docs_by_line.get(line - 1)
+        while comment:
+            group.insert(0, comment)
+            comment = self._docs_by_line.get(comment.line - 1)
+        return doc_comment_text(group)
+    
+    @staticmethod
+    def _owner(path: List[str]) -> Dict:
+        """parent, parent_class and namespace of a declaration inside the types of path"""
+        if not path:
+            return {}
+        return {'parent': '.'.join(path), 'parent_class': path[-1], 'namespace': '.'.join(path[:-1]) or None}
+    
+    @staticmethod
+    def _child(node: Node, kind: str) -> Optional[Node]:
+        return next((c for c in node.children if c.type == kind), None)
+    
+    def _start(self, node: Node) -> Node:
+        """First child of a declaration that is not its doc comment"""
+        return next((c for c in node.children if c.type not in COMMENTS), node)
+    
+    def _span(self, first: Node, last: Node) -> str:
+        """Source text from the start of one node to the end of another"""
+        return self._decode(first.start_byte, last.end_byte)
+    
+    def _decode(self, start: int, end: int) -> str:
+        return self._source[start:end].decode('utf8', errors='replace')
+    
+    def _text(self, node: Node) -> str:
+        return self._source[node.start_byte:node.end_byte].decode('utf8', errors='replace')
+    
+    def _line(self, node: Node) -> int:
+        return node.start_point[0] + 1
+    
+    def _line_end(self, node: Node) -> int:
+        return node.end_point[0] + 1
@@ -5,37 +5,306 @@ Command-line interface for indexing, searching, and managing the vector database
 """
 
 import argparse
+import json
+import logging
 import sys
 from pathlib import Path
+from typing import Dict, Optional
 
-from rag import ChromeRAGSystem, VulnerabilityAnalyzer
-from indexer import ChromeIndexer
+from chunkers.base_chunker import parse_metadata, qualified_name
+from chunkers.granularity import Granularity
+from chunkers.token_splitter import OVERLAP_STRATEGIES
+from rag import EMBEDDING_MODES, PRIMARY_MODEL, VECTOR_INDEXES, ChromeRAGSystem, VulnerabilityAnalyzer
+from utils.cancellation import CancelToken, cancel_on_interrupt
+from utils.index_diff import STATUS_MARKERS, DiffIndex, describe_change
+from utils.index_snapshot import SnapshotError
+from utils.code_tokenizer import rule_problems, tokenize_code, tokenizer_rules
+from utils.chunk_processors import load_processor
+from utils.chunk_dedup import DEDUP_MODES
+from utils.code_normalization import NORMALIZATIONS
+from utils.embedding_templates import DOCUMENT_FIELDS, QUERY_FIELDS, template_problems
+from utils.config_file import (CONFIG_ENV, CONFIG_FILE_NAMES, ConfigFileError, apply_settings, env_settings,
+                               file_settings, find_config_file, read_config_file)
+from utils.context_packer import pack_context
+from utils.file_languages import LanguageMap
+from utils.filter_expression import FilterError, parse_filter
+from utils.generated_code import GENERATED_MODES
+from utils.git_blame import describe_change as describe_last_change
+from utils.go_build import GoBuildContext
+from utils.ignore_rules import LARGE_FILE_MODES, split_patterns
+from utils.path_scope import normalize_scopes
+from utils.path_priors import RECENCY_SOURCES
+from utils.related_symbols import RELATION_KINDS
+from utils.result_format import matching_lines, result_record, snippet
+from utils.result_groups import GROUP_MODES, describe_group, group_results
+from utils.retrieval_eval import DEFAULT_K, EvalError, evaluate, first_rank, load_queries
+from utils.saved_searches import describe_saved_search, expand_saved_search, saved_search_problems
+from utils.score_fusion import FUSION_METHODS, SCORE_NORMALIZATIONS, create_fuser
+from utils.secret_scan import SECRET_MODES
+from utils.symbol_neighbors import NEIGHBOR_MODES
+from utils.symbol_references import REFERENCE_KINDS
+from utils.tracing import SEARCH_STAGES, format_timings
+from indexer import ChromeIndexer, parse_root
 from config import CONFIG
+from embedders import MODEL_SPEC_KEYS, EmbeddingError, create_embedder, create_model_embedders
+from rerankers import create_reranker
+from stores import METRICS, QUANTIZATIONS, StoreError, create_store, similarity
 from utils.logger import (
-    setup_logger, print_success, print_error, print_warning,
-    print_header, print_stats, console
+    setup_logger, get_logger, print_success, print_error, print_warning, print_info,
+    print_header, print_stats, console, log_console, create_progress_bar, LOG_FORMATS
 )
 from rich.table import Table
 from rich.syntax import Syntax
 
+# Lexer of each indexed language for highlighting code (similar syntax where there is none)
+SYNTAX_LANGUAGES = {
+    'cpp': 'cpp',
+    'c': 'c',
+    'python': 'python',
+    'javascript': 'javascript',
+    'go': 'go',
+    'rust': 'rust',
+    'java': 'java',
+    'bash': 'bash',
+    'sql': 'sql',
+    'markdown': 'markdown',
+    'mojom': 'rust',
+    'gn': 'python'
+}
+
+# Lines of a result shown in text search output, unless --full
+SNIPPET_LINES = 15
+
+
+# Command-line options and the CONFIG setting each one defaults to; a CODE_RAG_<SETTING>
+# environment variable overrides the option as well as the config file (and
+# CODE_RAG_INDEX_ROOTS the --path of index and update)
+FLAG_SETTINGS = {
+    'db_path': 'db_path',
+    'store': 'vector_store',
+    'metric': 'distance_metric',
+    'quantization': 'vector_quantization',
+    'qdrant_url': 'qdrant_url',
+    'sqlite_path': 'sqlite_path',
+    'pg_dsn': 'pgvector_dsn',
+    'log_level': 'log_level',
+    'log_format': 'log_format',
+    'embedder': 'embedding_backend',
+    'embedding_model': 'ollama_model',
+    'ollama_url': 'ollama_base_url',
+    'embedding_rpm': 'embedding_requests_per_minute',
+    'embedding_concurrency': 'embedding_max_concurrency',
+    'embedding_workers': 'embedding_workers',
+    'reranker': 'reranker_backend',
+    'reranker_url': 'reranker_url',
+    'reranker_model': 'reranker_model',
+    'ignore': 'ignore_patterns',
+    'batch_size': 'batch_size',
+    'max_tokens': 'max_tokens',
+    'granularity': 'granularity',
+    'min_lines': 'min_chunk_lines',
+    'min_tokens': 'min_chunk_tokens',
+    'exclude_symbol': 'exclude_symbols',
+    'chunk_processor': 'chunk_processors',
+    'goos': 'go_goos',
+    'goarch': 'go_goarch',
+    'go_tags': 'go_build_tags',
+    'secrets': 'secret_scan',
+    'generated_code': 'generated_code',
+    'git_blame': 'git_blame',
+    'debounce': 'watch_debounce',
+    'lexical_weight': 'hybrid_lexical_weight',
+    'fusion': 'fusion_method',
+    'rrf_k': 'rrf_k',
+    'fusion_normalization': 'fusion_normalization',
+    'min_score': 'min_score',
+    'candidate_k': 'candidate_k',
+}
+
+# Settings limited to a set of values, checked by config validate
+SETTING_CHOICES = {
+    'vector_store': ('chroma', 'qdrant', 'sqlite', 'pgvector'),
+    'distance_metric': METRICS,
+    'vector_quantization': QUANTIZATIONS + (None,),
+    'embedding_backend': ('default', 'ollama', 'none'),
+    'embedding_mode': EMBEDDING_MODES,
+    'reranker_backend': ('none', 'http'),
+    'fusion_method': FUSION_METHODS,
+    'fusion_normalization': SCORE_NORMALIZATIONS,
+    'granularity': tuple(g.value for g in Granularity),
+    'overlap_strategy': OVERLAP_STRATEGIES,
+    'code_normalization': NORMALIZATIONS,
+    'dedup': DEDUP_MODES,
+    'secret_scan': tuple(SECRET_MODES),
+    'generated_code': GENERATED_MODES,
+    'large_files': LARGE_FILE_MODES,
+    'recency_source': RECENCY_SOURCES,
+    'log_level': ('DEBUG', 'INFO', 'WARNING', 'ERROR'),
+    'log_format': tuple(LOG_FORMATS),
+}
+
+
+def load_config(config_path=None):
+    """
+    Apply the config file and then the CODE_RAG_* environment variables to CONFIG
+    
+    Returns:
+        Tuple of (config file or None, {setting: 'file' or 'env'} of what was set,
+        problems with the file and the variables; settings with a problem are left as they were)
+    
+    Raises:
+        ConfigFileError: If the config file is missing or malformed
+    """
+    path = find_config_file(config_path)
+    from_file, problems = file_settings(read_config_file(path), CONFIG) if path else ({}, [])
+    apply_settings(CONFIG, from_file)
+    from_env, env_problems = env_settings(CONFIG)
+    apply_settings(CONFIG, from_env)
+    sources = {**{name: 'file' for name in from_file}, **{name: 'env' for name in from_env}}
+    return path, sources, problems + env_problems
+
+
+def create_rag(args, cache_embeddings: bool = False) -> ChromeRAGSystem:
+    """RAG system for the vector store and embedding backend selected on the command line"""
+    use_cache = cache_embeddings and not getattr(args, 'no_embedding_cache', False)
+    embedder = create_embedder(
+        backend=args.embedder,
+        model_name=args.embedding_model,
+        base_url=args.ollama_url,
+        cache_path=CONFIG.embedding_cache_path if use_cache else None,
+        requests_per_minute=args.embedding_rpm,
+        max_concurrent=args.embedding_concurrency
+    )
+    store = create_store(backend=args.store, db_path=args.db_path, url=args.qdrant_url,
+                         sqlite_path=args.sqlite_path, dsn=args.pg_dsn, metric=args.metric,
+                         quantization=args.quantization)
+    reranker = create_reranker(backend=args.reranker, url=args.reranker_url, model_name=args.reranker_model)
+    # Only index and update choose an embedding mode; other commands use the index's own
+    embedding_mode = getattr(args, 'embedding_mode', None) or ('doc' if getattr(args, 'embed_doc', False) else None)
+    # Surrounding context is read from --source-root (search) or the served --source
+    source_root = getattr(args, 'source_root', None) or getattr(args, 'source', None)
+    # Only index chooses normalization; other commands use the index's own
+    normalize = True if getattr(args, 'normalize_embeddings', False) else None
+    reduce_dimensions = getattr(args, 'reduce_dimensions', None)
+    code_normalization = getattr(args, 'normalize_code', None)
+    document_template = getattr(args, 'document_template', None)
+    query_template = getattr(args, 'query_template', None)
+    # Only index and update turn dedup on; an index keeps the mode it was built with
+    dedup = 'normalized' if getattr(args, 'dedup_ignore_formatting', False) else \
+        'exact' if getattr(args, 'dedup', False) else None
+    embedding_models = create_model_embedders(cache_path=CONFIG.embedding_cache_path if use_cache else None)
+    query_embedders = create_model_embedders(CONFIG.query_embedders)
+    return ChromeRAGSystem(db_path=args.db_path, embedder=embedder, store=store, reranker=reranker,
+                           embedding_mode=embedding_mode, source_root=source_root,
+                           normalize_embeddings=normalize, dedup=dedup, reduce_dimensions=reduce_dimensions,
+                           code_normalization=code_normalization, embedding_models=embedding_models,
+                           query_embedders=query_embedders, document_template=document_template,
+                           query_template=query_template)
+
+
+def create_indexer(args, rag: ChromeRAGSystem) -> Optional[ChromeIndexer]:
+    """
+    Indexer with the ignore rules and size cap selected on the command line
+    (None, reported, if a chunk processor does not load)
+    """
+    try:
+        processors = [load_processor(spec) for spec in split_patterns(args.chunk_processor)]
+    except ValueError as e:
+        print_error(str(e))
+        return None
+    return ChromeIndexer(
+        rag,
+        ignore_patterns=split_patterns(args.ignore),
+        use_default_ignores=not args.no_default_ignores,
+        use_gitignore=not args.no_gitignore,
+        max_file_bytes=None if args.max_file_size is None else args.max_file_size * 1024,
+        large_files=args.large_files,
+        languages=LanguageMap(detect=args.detect_languages),
+        go_build=GoBuildContext(
+            goos=args.goos,
+            goarch=args.goarch,
+            tags=split_patterns(args.go_tags),
+            include_tests=not args.no_go_tests
+        ),
+        secrets=args.secrets,
+        generated=args.generated_code,
+        blame=args.git_blame,
+        min_lines=args.min_lines,
+        min_tokens=args.min_tokens,
+        exclude_symbols=split_patterns(args.exclude_symbol),
+        processors=processors,
+        embed_workers=args.embedding_workers,
+        verbose=args.verbose
+    )
+
+
+def add_discovery_arguments(parser):
+    """File discovery and parsing options shared by index and update"""
+    parser.add_argument('--ignore', action='append', metavar='GLOB', default=list(CONFIG.ignore_patterns) or None, help='Skip paths matching a gitignore-style pattern (repeatable, comma-separated; added to the config file\'s ignore patterns)')
+    parser.add_argument('--no-default-ignores', action='store_true', help='Also index built-in excluded directories (vendor, node_modules, build, ...)')
+    parser.add_argument('--no-gitignore', action='store_true', help='Do not read .gitignore files')
+    parser.add_argument('--no-embedding-cache', action='store_true', help='Embed every chunk, ignoring the on-disk embedding cache')
+    parser.add_argument('--workers', type=int, help='Parser processes for parallel indexing (default: one per CPU)')
+    parser.add_argument('--embedding-workers', type=int, metavar='N', default=CONFIG.embedding_workers, help='Embed batches on N threads while files are still parsed, at most --embedding-concurrency with the ollama backend (default: embed each batch in line)')
+    parser.add_argument('--timeout', type=float, metavar='SECONDS', help='Stop indexing cleanly after this long; files not completed are left for the next run')
+    parser.add_argument('--granularity', choices=[g.value for g in Granularity], default=CONFIG.granularity,
+                        help=f'What one chunk covers (default: {CONFIG.granularity})')
+    parser.add_argument('--min-lines', type=int, metavar='N', default=CONFIG.min_chunk_lines, help='Leave out functions and methods shorter than N lines, counted in the summary (default: keep all)')
+    parser.add_argument('--min-tokens', type=int, metavar='N', default=CONFIG.min_chunk_tokens, help='Leave out functions and methods with fewer than N tokens of code (default: keep all)')
+    parser.add_argument('--exclude-symbol', action='append', metavar='GLOB', default=list(CONFIG.exclude_symbols) or None, help='Leave out symbols whose qualified name matches a glob, e.g. \'*.String\' or \'Test*\' (repeatable, comma-separated; added to the config file\'s exclude_symbols)')
+    parser.add_argument('--chunk-processor', action='append', metavar='SPEC', default=list(CONFIG.chunk_processors) or None, help='Run a chunk processor on every chunk before it is embedded: \'codeowners\', or \'package.module:Class\' (repeatable, comma-separated, run in order; added to the config file\'s chunk_processors)')
+    parser.add_argument('--max-file-size', type=int, metavar='KB', help=f'Skip files larger than this, 0 disables (default: {CONFIG.max_file_bytes // 1024})')
+    parser.add_argument('--large-files', choices=list(LARGE_FILE_MODES), default=CONFIG.large_files, help=f'Files over --max-file-size: skip them with a warning, or stream them from disk into plain-text blocks instead of parsing them whole (up to max_stream_file_bytes) (default: {CONFIG.large_files})')
+    parser.add_argument('--detect-languages', action='store_true', default=CONFIG.detect_languages, help=f'Index files no extension or name maps as the language their content points to (#! line, modeline, language constructs), or as plain text below language_confidence ({CONFIG.language_confidence})')
+    parser.add_argument('--goos', default=CONFIG.go_goos, help='Only index Go files whose build constraints match this GOOS (linux, darwin, windows, ...)')
+    parser.add_argument('--goarch', default=CONFIG.go_goarch, help='Only index Go files whose build constraints match this GOARCH (amd64, arm64, ...)')
+    parser.add_argument('--go-tags', action='append', metavar='TAGS', default=list(CONFIG.go_build_tags) or None, help='Extra satisfied Go build tags, comma-separated (e.g. cgo,integration)')
+    parser.add_argument('--secrets', choices=list(SECRET_MODES), default=CONFIG.secret_scan, help=f'Chunks holding hardcoded keys, tokens or passwords: index as is (off), redact the secrets, or skip the chunks; files are not changed (default: {CONFIG.secret_scan})')
+    parser.add_argument('--generated-code', choices=list(GENERATED_MODES), default=CONFIG.generated_code, help=f'Generated files (a "Code generated ... DO NOT EDIT." header, *.pb.go, *_pb2.py, ...): tag their chunks generated=true, skip them, or index them as is (off) (default: {CONFIG.generated_code})')
+    parser.add_argument('--git-blame', action='store_true', default=CONFIG.git_blame, help='Record who last changed each chunk and in which commit, from git blame (slower: one git process per file)')
+    parser.add_argument('--no-go-tests', action='store_true', default=not CONFIG.go_include_tests, help='Skip Go _test.go files (included by default, with kind "test")')
+
+
+def resolve_roots(specs):
+    """
+    The --path arguments (or the config file's roots) as (label, path) pairs, or None
+    after printing what is wrong; a single path without a label is an unlabeled root (label None)
+    """
+    if not specs:
+        print_error("No directory given: pass --path or set roots in a config file")
+        return None
+    roots = []
+    for spec in specs:
+        try:
+            label, path = parse_root(spec)
+        except ValueError as e:
+            print_error(str(e))
+            return None
+        source_path = Path(path)
+        if not source_path.exists():
+            print_error(f"Path does not exist: {source_path}")
+            return None
+        if not source_path.is_dir():
+            print_error(f"Path is not a directory: {source_path}")
+            return None
+        roots.append((label, str(source_path)))
+    return roots
+
 
 def cmd_index(args):
-    """Index a Chrome source directory"""
+    """Index a Chrome source directory (or several repositories)"""
     print_header("Chrome Source Code Indexer")
     
-    # Validate path
-    source_path = Path(args.path)
-    if not source_path.exists():
-        print_error(f"Path does not exist: {source_path}")
-        return 1
-    
-    if not source_path.is_dir():
-        print_error(f"Path is not a directory: {source_path}")
+    # Validate paths
+    roots = resolve_roots(args.path or CONFIG.index_roots)
+    if roots is None:
         return 1
     
     # Initialize systems
-    rag = ChromeRAGSystem(db_path=args.db_path)
-    indexer = ChromeIndexer(rag)
+    rag = create_rag(args, cache_embeddings=True)
+    indexer = create_indexer(args, rag)
+    if indexer is None:
+        return 1
     
     # Optionally clear existing data
     if args.clear:
@@ -49,87 +318,501 @@ def cmd_index(args):
     # Parse file types filter
     file_types = args.file_types.split(',') if args.file_types else None
     
-    # Index directory
-    stats = indexer.index_directory(
-        source_path=str(source_path),
+    options = dict(
         file_types=file_types,
         batch_size=args.batch_size,
-        parallel=not args.no_parallel
+        parallel=not args.no_parallel,
+        max_tokens=args.max_tokens,
+        workers=args.workers,
+        granularity=args.granularity
     )
     
+    # Index directory, or each repository under its label; the first Ctrl+C stops at the next file
+    cancel = CancelToken(args.timeout)
+    with cancel_on_interrupt(cancel):
+        if len(roots) == 1 and roots[0][0] is None:
+            indexer.index_directory(source_path=roots[0][1], cancel=cancel, **options)
+        else:
+            try:
+                indexer.index_roots(roots, cancel=cancel, **options)
+            except ValueError as e:
+                print_error(str(e))
+                return 1
+    
+    return 1 if cancel.cancelled else 0
+
+
+def cmd_update(args):
+    """Re-index only what changed since the last run (by file content hash)"""
+    print_header("Incremental Index Update")
+    
+    roots = resolve_roots(args.path or CONFIG.index_roots)
+    if roots is None:
+        return 1
+    if args.watch and len(roots) > 1:
+        print_error("--watch follows a single --path; start one watcher per repository")
+        return 1
+    
+    rag = create_rag(args, cache_embeddings=True)
+    indexer = create_indexer(args, rag)
+    if indexer is None:
+        return 1
+    
+    file_types = args.file_types.split(',') if args.file_types else None
+    options = dict(
+        file_types=file_types,
+        batch_size=args.batch_size,
+        parallel=not args.no_parallel,
+        max_tokens=args.max_tokens,
+        workers=args.workers,
+        granularity=args.granularity
+    )
+    cancel = CancelToken(args.timeout)
+    with cancel_on_interrupt(cancel):
+        if len(roots) == 1 and roots[0][0] is None:
+            results = {None: indexer.update_index(source_path=roots[0][1], cancel=cancel, **options)}
+        else:
+            try:
+                results = indexer.index_roots(roots, update=True, cancel=cancel, **options)
+            except ValueError as e:
+                print_error(str(e))
+                return 1
+    if cancel.cancelled:
+        return 1
+    
+    for stats in results.values():
+        print_stats({
+            "Chunks Added": stats.get('chunks_added', 0),
+            "Chunks Updated": stats.get('chunks_updated', 0),
+            "Chunks Removed": stats.get('chunks_removed', 0),
+            "Files Moved": stats.get('files_moved', 0)
+        })
+        if stats['errors'] and not stats['files_processed']:
+            return 1
+    
+    if args.watch:
+        from watcher import IndexWatcher, WatchError
+        label = next(iter(results))
+        watcher = IndexWatcher(
+            indexer, roots[0][1], debounce=args.debounce, repo=label, **options
+        )
+        try:
+            watcher.run()
+        except WatchError as e:
+            print_error(str(e))
+            return 1
     return 0
 
 
+def _sub_score(result, retriever):
+    """Format one retriever's score and rank for a fused search result"""
+    score = result.get(f'{retriever}_score')
+    if score is None:
+        return "-"
+    return f"{score:.3f} (#{result[f'{retriever}_rank']})"
+
+
+def _print_explanation(explanation):
+    """Print the 'explain' record of a search result: scores, fusion, BM25 terms and filters"""
+    vector, lexical, fused = explanation['vector'], explanation['lexical'], explanation['fused']
+    parts = [f"vector {vector['score']:.3f} (#{vector['rank']}, distance {vector['distance']:.3f})" if vector
+             else "vector -",
+             f"bm25 {lexical['score']:.3f} (#{lexical['rank']}, {lexical['share']:.0%} of a full match)" if lexical
+             else "bm25 -"]
+    console.print(f"[yellow]Explain:[/yellow] {' | '.join(parts)} | relevance {explanation['relevance']:.3f}")
+    boost = f" x boost {fused['boost']:g}" if 'boost' in fused else ''
+    settings = ''.join(f", {key} {value}" for key, value in fused.items()
+                       if key not in ('score', 'vector', 'lexical', 'lexical_weight', 'method', 'boost'))
+    console.print(f"  fused {fused['score']:.4f} = (vector {fused['vector']:.4f} + lexical {fused['lexical']:.4f}){boost} "
+                  f"[dim]({fused['method']}, lexical weight {fused['lexical_weight']}{settings})[/dim]")
+    if lexical and lexical['terms']:
+        terms = []
+        for entry in lexical['terms']:
+            fields = ', '.join(f"{field} x{count}" for field, count in entry['fields'].items())
+            details = f"expansion; {fields}" if entry['expansion'] else fields
+            terms.append(f"{entry['term']} {entry['score']:.3f} ({details})")
+        console.print(f"  terms: {', '.join(terms)}")
+    for key in ('rerank_score', 'mmr_score'):
+        if key in explanation:
+            console.print(f"  {key.replace('_', ' ')}: {explanation[key]:.4f}")
+    if explanation['filters']:
+        filters = ', '.join(f"{name}={', '.join(value) if isinstance(value, list) else value}"
+                            for name, value in explanation['filters'].items())
+        console.print(f"  filters passed: {filters}")
+
+
+def parse_boosts(specs) -> Dict[str, float]:
+    """--boost KIND=FACTOR values as boost_kinds"""
+    boosts = {}
+    for spec in specs or []:
+        kind, sep, factor = spec.partition('=')
+        try:
+            boosts[kind.strip()] = float(factor)
+        except ValueError:
+            sep = ''
+        if not sep or not kind.strip() or boosts[kind.strip()] <= 0:
+            raise ValueError(f"--boost takes KIND=FACTOR with a positive factor, got '{spec}'")
+    return boosts
+
+
+def parse_params(specs) -> Dict[str, str]:
+    """--param NAME=VALUE values of a saved search"""
+    params = {}
+    for spec in specs or []:
+        name, sep, value = spec.partition('=')
+        if not sep or not name.strip():
+            raise ValueError(f"--param takes NAME=VALUE, got '{spec}'")
+        params[name.strip()] = value
+    return params
+
+
+# Fields of a saved search and the search options they stand in for, unless given
+SAVED_SEARCH_OPTIONS = {
+    'query': 'query',
+    'top_k': 'n_results',
+    'languages': 'language',
+    'kinds': 'type',
+    'path_globs': 'path',
+    'repos': 'repo',
+    'uses': 'uses',
+    'filter': 'filter',
+    'scope': 'scope',
+    'exclude_tests': 'exclude_tests',
+    'exclude_text': 'exclude_text',
+    'exclude_generated': 'exclude_generated',
+    'query_embedder': 'query_embedder',
+}
+
+
+def apply_saved_search(args):
+    """Fill the search options not given on the command line from --saved and its --param values"""
+    search = expand_saved_search(CONFIG.saved_searches, args.saved, parse_params(args.param))
+    for field, dest in SAVED_SEARCH_OPTIONS.items():
+        if field not in search or getattr(args, dest):
+            continue
+        value = search[field]
+        # Comma-separated on the command line
+        if dest in ('language', 'type', 'repo', 'uses'):
+            value = ','.join(value)
+        elif dest == 'scope' and isinstance(value, str):
+            value = [value]
+        setattr(args, dest, value)
+
+
 def cmd_search(args):
     """Semantic search for code chunks"""
-    print_header("Semantic Code Search")
+    # json and jsonl keep stdout for the results; logs and status go to stderr
+    to_stderr = args.format != 'text'
+    if args.quiet:
+        get_logger().setLevel(logging.ERROR)
+    if args.pack and args.format != 'text':
+        print_error("--pack prints a text block; it cannot be combined with --format json or jsonl")
+        return 1
+    if args.group_by and (args.pack or args.format == 'jsonl'):
+        print_error("--group-by groups text or --format json output; it cannot be combined with --pack or --format jsonl")
+        return 1
+    try:
+        if args.saved:
+            apply_saved_search(args)
+        elif args.param:
+            raise ValueError("--param sets the parameters of a saved search; give --saved NAME too")
+        if not args.query:
+            raise ValueError("Give --query, or --saved NAME to run a saved search")
+        if args.n_results is None:
+            args.n_results = 5
+        filter_expr = parse_filter(args.filter) if args.filter else None
+        boost_kinds = parse_boosts(args.boost)
+        for flag, weight in (('--depth-penalty', args.depth_penalty), ('--recency-weight', args.recency_weight)):
+            if weight is not None and weight < 0:
+                raise ValueError(f"{flag} must not be negative, got {weight:g}")
+        if args.generated_weight is not None and args.generated_weight <= 0:
+            raise ValueError(f"--generated-weight must be positive, got {args.generated_weight:g}")
+        if args.offset is not None and args.offset < 0:
+            raise ValueError(f"--offset must not be negative, got {args.offset}")
+        scope = normalize_scopes(split_patterns(args.scope)) or None
+        fuser = create_fuser(args.fusion, args.rrf_k, args.fusion_normalization)
+    except (FilterError, ValueError) as e:
+        print_error(str(e))
+        return 1
+    if not args.quiet:
+        print_header("Semantic Code Search", stderr=to_stderr)
     
     # Initialize RAG system
-    rag = ChromeRAGSystem(db_path=args.db_path)
-    
+    rag = create_rag(args)
+    try:
+        models = rag.search_models(args.models.split(',') if args.models else None)
+        rag.query_embedder(args.query_embedder, models)
+    except (EmbeddingError, ValueError) as e:
+        print_error(str(e))
+        return 1
+    status = show_search(args, rag, filter_expr, boost_kinds, scope, fuser)
+    if args.verbose:
+        print_stats(format_timings(rag.tracer.summary(SEARCH_STAGES)), title="Search Timings", stderr=to_stderr)
+    return status
+
+
+def show_search(args, rag: ChromeRAGSystem, filter_expr, boost_kinds, scope, fuser) -> int:
+    """Run the search of cmd_search and print its results, returning the exit status"""
     # Perform search
-    results = rag.retrieve_context(
+    search = dict(
         query=args.query,
-        n_results=args.n_results,
-        language=args.language,
-        file_type=args.type
+        lexical_weight=args.lexical_weight,
+        languages=args.language.split(',') if args.language else None,
+        kinds=args.type.split(',') if args.type else None,
+        path_globs=args.path,
+        repos=args.repo.split(',') if args.repo else None,
+        uses=args.uses.split(',') if args.uses else None,
+        min_score=args.min_score,
+        mmr_lambda=args.mmr,
+        exclude_tests=args.exclude_tests,
+        exclude_text=args.exclude_text,
+        rerank=False if args.no_rerank else None,
+        rerank_candidates=args.rerank_candidates,
+        candidate_k=args.candidate_k,
+        vector_index=args.vector_index,
+        with_surrounding=args.with_surrounding,
+        with_neighbors=args.with_neighbors,
+        expand_query=args.expand or None,
+        explain=args.explain,
+        filter_expr=filter_expr,
+        boost_kinds=boost_kinds or None,
+        depth_penalty=args.depth_penalty,
+        recency_weight=args.recency_weight,
+        embedding_models=args.models.split(',') if args.models else None,
+        exclude_generated=args.exclude_generated,
+        generated_weight=args.generated_weight,
+        query_embedder=args.query_embedder,
+        scope=scope,
+        fusion=fuser
     )
+    page = None
+    if args.offset is not None:
+        page = rag.retrieve_page(page_size=args.n_results, offset=args.offset, **search)
+        results = page['results']
+    else:
+        results = rag.retrieve_context(n_results=args.n_results, **search)
+    terms = rag.expand_query(args.query) if args.expand else []
+    if args.expand and not args.quiet:
+        out = console if args.format == 'text' else log_console
+        out.print(f"[dim]Expanded with: {', '.join(terms) if terms else 'nothing'}[/dim]")
+    
+    # Machine-readable output: the records of the HTTP server's /search, empty when nothing matched
+    if args.format == 'json':
+        body = {'query': args.query, 'results': [result_record(r) for r in results]}
+        if page is not None:
+            body.update(offset=page['offset'], total=page['total'])
+        if args.group_by:
+            body['groups'] = group_results(results, args.group_by)
+        print(json.dumps(body, ensure_ascii=False, indent=2))
+        return 0
+    if args.format == 'jsonl':
+        for result in results:
+            print(json.dumps(result_record(result), ensure_ascii=False))
+        return 0
     
     if not results:
-        print_warning("No results found")
+        if args.quiet:
+            pass
+        elif args.min_score is not None:
+            print_warning(f"No results reach --min-score {args.min_score}")
+        else:
+            print_warning("No results found")
         return 0
     
-    print_success(f"Found {len(results)} results\n")
+    if args.pack:
+        with rag.tracer.span('pack', max_tokens=args.pack) as span:
+            packed, included = pack_context(results, args.pack, merge_files=args.merge_files)
+            span.items = len(included)
+        print(packed)
+        if not args.quiet:
+            console.print(f"\n[dim]Packed {len(included)} of {len(results)} results into {args.pack} tokens:[/dim]")
+            for result in included:
+                metadata = result['metadata']
+                console.print(f"[dim]  {metadata.get('filepath', 'unknown')}:{metadata.get('line_start', '?')} {metadata.get('name', 'unknown')}[/dim]")
+        return 0
     
-    # Display results
-    for i, result in enumerate(results, 1):
+    if not args.quiet and page is not None:
+        print_success(f"Results {page['offset'] + 1}-{page['offset'] + len(results)} of {page['total']}\n")
+    elif not args.quiet:
+        print_success(f"Found {len(results)} results\n")
+    
+    # Lines of a result with a query word (or an expansion of one) are highlighted
+    query_terms = set(tokenize_code(args.query, tokenizer_rules())) | set(terms)
+    
+    # Display results, each group's under a header when grouped (numbered by their rank all the same)
+    first_rank = 1 + (page['offset'] if page else 0)
+    listing, headers = list(enumerate(results, first_rank)), {}
+    if args.group_by:
+        listing = []
+        for number, group in enumerate(group_results(results, args.group_by), 1):
+            headers[len(listing)] = describe_group(group, number)
+            listing.extend((first_rank + position, results[position]) for position in group['positions'])
+    for shown_at, (i, result) in enumerate(listing):
+        if shown_at in headers:
+            console.print(f"\n[bold green]{headers[shown_at]}[/bold green]")
         metadata = result['metadata']
+        record = result_record(result)
+        line_start = metadata.get('line_start') or 1
         
-        console.print(f"\n[bold cyan]Result {i}[/bold cyan]")
-        console.print(f"[yellow]File:[/yellow] {metadata.get('filepath', 'unknown')}")
-        console.print(f"[yellow]Type:[/yellow] {metadata.get('type', 'unknown')}")
-        console.print(f"[yellow]Name:[/yellow] {metadata.get('name', 'unknown')}")
-        console.print(f"[yellow]Language:[/yellow] {metadata.get('language', 'unknown')}")
-        console.print(f"[yellow]Lines:[/yellow] {metadata.get('line_start', '?')}-{metadata.get('line_end', '?')}")
+        # Header: location, symbol, kind and score
+        location = f"{metadata['repo']}:" if metadata.get('repo') else ""
+        location += f"{metadata.get('filepath', 'unknown')}:{line_start}-{metadata.get('line_end', '?')}"
+        score = f"score {record['score']:.4f}" if record['score'] is not None else ""
+        if result.get('relevance') is not None:
+            score += f"  relevance {result['relevance']:.3f}"
+        console.print(f"\n[bold cyan]{i}. {location}[/bold cyan]  [bold]{qualified_name(metadata) or 'unknown'}[/bold] "
+                      f"[magenta]{metadata.get('type', 'unknown')}[/magenta] [dim]{metadata.get('language', '')}  {score}[/dim]")
+        
+        extra = parse_metadata(metadata.get('metadata'))
+        if extra.get('owner'):
+            console.print(f"[yellow]Method of:[/yellow] {extra['owner']['name']}")
+        if extra.get('instantiations'):
+            console.print(f"[yellow]Instantiates:[/yellow] {', '.join(i['instance'] for i in extra['instantiations'])}")
+        if extra.get('instantiated_by'):
+            instances = dict.fromkeys(i['instance'] for i in extra['instantiated_by'])
+            console.print(f"[yellow]Instantiated as:[/yellow] {', '.join(instances)}")
+        if extra.get('assigned_in'):
+            console.print(f"[yellow]Set in:[/yellow] {', '.join(dict.fromkeys(e['name'] for e in extra['assigned_in']))}")
+        see_also = [ref['name'] for ref in extra.get('doc_refs', []) if ref['resolved']]
+        if see_also:
+            console.print(f"[yellow]See also:[/yellow] {', '.join(see_also)}")
+        if metadata.get('doc'):
+            console.print(f"[yellow]Doc:[/yellow] {metadata['doc'].splitlines()[0]}")
+        if extra.get('last_change'):
+            console.print(f"[yellow]Last changed by:[/yellow] {describe_last_change(extra['last_change'])}")
+        if result.get('duplicates'):
+            places = ', '.join(f"{d['location']}:{d.get('line_start', '?')}" for d in result['duplicates'][:5])
+            more = f", ... {result['duplicate_count'] - 5} more" if result['duplicate_count'] > 5 else ''
+            console.print(f"[yellow]Also appears in {result['duplicate_count']} places:[/yellow] {places}{more}")
+        neighbors = result.get('neighbors') or {}
+        for label, key in (('Before', 'previous'), ('After', 'next')):
+            if neighbors.get(key):
+                neighbor = neighbors[key]
+                console.print(f"[yellow]{label}:[/yellow] {neighbor['qualified_name'] or neighbor['name']} "
+                              f"[magenta]{neighbor['type']}[/magenta] [dim]lines {neighbor['line_start']}-{neighbor['line_end']}[/dim]")
         
         if result.get('distance') is not None:
-            console.print(f"[yellow]Similarity:[/yellow] {1 - result['distance']:.3f}")
+            console.print(f"[yellow]Similarity:[/yellow] {similarity(result['distance'], rag.metric):.3f}")
+        if args.show_scores and 'rrf_score' in result:
+            console.print(f"[yellow]Scores:[/yellow] fused {result['rrf_score']:.4f} | "
+                          f"vector {_sub_score(result, 'vector')} | bm25 {_sub_score(result, 'bm25')} | "
+                          f"relevance {result['relevance']:.3f}")
+            if result.get('rerank_score') is not None:
+                console.print(f"[yellow]Rerank score:[/yellow] {result['rerank_score']:.4f}")
+        if args.explain:
+            _print_explanation(result['explain'])
         
-        # Show code snippet
-        content = result['content']
-        if len(content) > 500 and not args.full:
-            content = content[:500] + "\n... (truncated, use --full to see complete code)"
+        syntax_lang = SYNTAX_LANGUAGES.get(metadata.get('language'), 'text')
         
-        # Syntax highlight the code
-        language_map = {
-            'cpp': 'cpp',
-            'python': 'python',
-            'javascript': 'javascript',
-            'mojom': 'rust',  # Similar syntax
-            'gn': 'python'    # Similar syntax
-        }
-        syntax_lang = language_map.get(metadata.get('language'), 'text')
+        surrounding = result.get('surrounding') or {}
+        if surrounding.get('imports'):
+            console.print("[yellow]Imports:[/yellow]")
+            console.print(Syntax(surrounding['imports'], syntax_lang, theme="monokai"))
+        if surrounding.get('enclosing'):
+            console.print(f"[yellow]Enclosing type:[/yellow] {surrounding['enclosing_name']} "
+                          f"({surrounding['enclosing_filepath']}:{surrounding['enclosing_lines'][0]})")
+            console.print(Syntax(surrounding['enclosing'], syntax_lang, theme="monokai",
+                                 line_numbers=True, start_line=surrounding['enclosing_lines'][0]))
         
-        syntax = Syntax(content, syntax_lang, theme="monokai", line_numbers=True)
-        console.print(syntax)
+        # Code snippet around the first matching line, unless --full
+        content = result['content']
+        matches = matching_lines(content, query_terms)
+        first, code = (0, content) if args.full else snippet(content, matches, args.snippet_lines)
+        console.print(Syntax(code, syntax_lang, theme="monokai", line_numbers=True,
+                             start_line=line_start + first,
+                             highlight_lines={line_start + m for m in matches}))
+        shown = code.count('\n') + 1
+        hidden = content.count('\n') + 1 - shown
+        if hidden > 0:
+            console.print(f"[dim]... {hidden} more line{'s' if hidden > 1 else ''} (use --full to see the complete code)[/dim]")
+        for key in ('previous', 'next'):
+            neighbor = neighbors.get(key)
+            if neighbor and neighbor.get('content') is not None:
+                console.print(f"[dim]{'Before' if key == 'previous' else 'After'}: {neighbor['qualified_name'] or neighbor['name']}[/dim]")
+                console.print(Syntax(neighbor['content'], syntax_lang, theme="monokai", line_numbers=True,
+                                     start_line=neighbor['line_start']))
         console.print("[dim]" + "─" * 80 + "[/dim]")
     
     return 0
 
 
+def cmd_eval(args):
+    """Score retrieval quality on a query set: recall@k, MRR and nDCG@k"""
+    if args.format == 'json':
+        console.file = sys.stderr
+    print_header("Retrieval Evaluation")
+    
+    try:
+        k_values = sorted({int(k) for k in args.k.split(',')})
+    except ValueError:
+        print_error(f"--k takes comma-separated integers, got '{args.k}'")
+        return 1
+    if k_values[0] < 1:
+        print_error("--k values must be positive")
+        return 1
+    try:
+        queries = load_queries(args.queries)
+        fuser = create_fuser(args.fusion, args.rrf_k, args.fusion_normalization)
+    except (EvalError, ValueError) as e:
+        print_error(str(e))
+        return 1
+    
+    rag = create_rag(args)
+    report = evaluate(
+        rag, queries, k_values,
+        lexical_weight=args.lexical_weight,
+        fusion=fuser,
+        mmr_lambda=args.mmr,
+        exclude_tests=args.exclude_tests,
+        exclude_text=args.exclude_text,
+        rerank=False if args.no_rerank else None,
+        vector_index=args.vector_index,
+        expand_query=args.expand or None
+    )
+    
+    if args.format == 'json':
+        print(json.dumps(report, ensure_ascii=False, indent=2))
+        return 0
+    
+    print_success(f"Evaluated {report['queries']} queries from {args.queries}\n")
+    table = Table(show_header=True, header_style="bold magenta")
+    table.add_column("Metric", style="cyan", no_wrap=True)
+    table.add_column("Value", style="green", justify="right")
+    for k in k_values:
+        table.add_row(f"recall@{k}", f"{report[f'recall@{k}']:.3f}")
+    table.add_row("MRR", f"{report['mrr']:.3f}")
+    for k in k_values:
+        table.add_row(f"nDCG@{k}", f"{report[f'ndcg@{k}']:.3f}")
+    console.print(table)
+    
+    if args.per_query:
+        console.print()
+        queries_table = Table(title="Per Query", show_header=True, header_style="bold magenta")
+        queries_table.add_column("Query", style="cyan")
+        queries_table.add_column("First Hit", justify="right")
+        queries_table.add_column("Missed", style="yellow")
+        for scores in report['per_query']:
+            rank = first_rank(scores)
+            missed = [symbol for symbol, found in scores['ranks'].items() if found is None]
+            queries_table.add_row(scores['query'], str(rank) if rank else "[red]none[/red]", ', '.join(missed))
+        console.print(queries_table)
+    return 0
+
+
 def cmd_symbol(args):
     """Retrieve specific symbol by name"""
     print_header("Symbol Lookup")
     
     # Initialize RAG system
-    rag = ChromeRAGSystem(db_path=args.db_path)
+    rag = create_rag(args)
     
     # Retrieve symbol
     results = rag.retrieve_symbol(
         symbol_name=args.name,
         symbol_type=args.type,
         language=args.language,
-        n_results=args.n_results
+        n_results=args.n_results,
+        with_methods=args.with_methods
     )
     
     if not results:
@@ -154,16 +837,41 @@ def cmd_symbol(args):
         if metadata.get('namespace'):
             console.print(f"[yellow]Namespace:[/yellow] {metadata['namespace']}")
         
+        if result.get('owner'):
+            receiver = '*' if parse_metadata(metadata.get('metadata')).get('pointer_receiver') else ''
+            console.print(f"[yellow]Method of:[/yellow] {receiver}{result['owner']['name']}"
+                          + (f" ({result['owner']['filepath']}:{result['owner']['line']})"
+                             if result['owner'].get('resolved') else ""))
+        instantiated_by = parse_metadata(metadata.get('metadata')).get('instantiated_by', [])
+        if instantiated_by:
+            console.print(f"[yellow]Instantiations ({len(instantiated_by)}):[/yellow]")
+            for site in instantiated_by:
+                console.print(f"  {site['instance']} [dim]in {site['name']} {site['filepath']}:{site['line']}[/dim]")
+        assigned_in = parse_metadata(metadata.get('metadata')).get('assigned_in', [])
+        if assigned_in:
+            console.print(f"[yellow]Set in ({len(assigned_in)}):[/yellow]")
+            for site in assigned_in:
+                console.print(f"  {site['name']} [dim]{site['filepath']}:{site['line']}[/dim]")
+        doc_refs = [ref for ref in parse_metadata(metadata.get('metadata')).get('doc_refs', []) if ref['resolved']]
+        if doc_refs:
+            console.print(f"[yellow]Doc references ({len(doc_refs)}):[/yellow]")
+            for ref in doc_refs:
+                console.print(f"  {ref['name']} [dim]{ref['filepath']}:{ref['line']}[/dim]")
+        referenced_by = parse_metadata(metadata.get('metadata')).get('doc_referenced_by', [])
+        if referenced_by:
+            console.print(f"[yellow]Referenced in docs of ({len(referenced_by)}):[/yellow]")
+            for ref in referenced_by:
+                console.print(f"  {ref['name']} [dim]{ref['filepath']}:{ref['line']}[/dim]")
+        if result.get('methods'):
+            console.print(f"[yellow]Methods ({len(result['methods'])}):[/yellow]")
+            for method in result['methods']:
+                receiver = "*" if method['pointer_receiver'] else " "
+                console.print(f"  {receiver} {method['signature'] or method['name']} "
+                              f"[dim]{method['filepath']}:{method['line_start']}[/dim]")
+        
         # Show code
         content = result['content']
-        language_map = {
-            'cpp': 'cpp',
-            'python': 'python',
-            'javascript': 'javascript',
-            'mojom': 'rust',
-            'gn': 'python'
-        }
-        syntax_lang = language_map.get(metadata.get('language'), 'text')
+        syntax_lang = SYNTAX_LANGUAGES.get(metadata.get('language'), 'text')
         
         syntax = Syntax(content, syntax_lang, theme="monokai", line_numbers=True)
         console.print(syntax)
@@ -172,23 +880,328 @@ def cmd_symbol(args):
     return 0
 
 
+def cmd_lookup(args):
+    """Go to symbol: exact, prefix and fuzzy name matches, no semantic search"""
+    print_header("Symbol Name Lookup")
+    
+    rag = create_rag(args)
+    symbols = rag.lookup(
+        args.name,
+        limit=args.n_results,
+        kinds=args.kind.split(',') if args.kind else None,
+        languages=args.language.split(',') if args.language else None,
+        repos=args.repo.split(',') if args.repo else None
+    )
+    
+    if not symbols:
+        print_warning(f"No symbol name matches '{args.name}'")
+        return 0
+    
+    print_success(f"Found {len(symbols)} symbol(s) matching '{args.name}'\n")
+    table = Table(show_header=True, header_style="bold magenta")
+    table.add_column("Symbol", style="cyan", no_wrap=True)
+    table.add_column("Kind", style="green")
+    table.add_column("Match", style="yellow")
+    table.add_column("Location")
+    for symbol in symbols:
+        location = f"{symbol['filepath']}:{symbol['line_start']}"
+        table.add_row(
+            symbol['qualified_name'],
+            symbol['kind'],
+            f"fuzzy ({symbol['distance']})" if symbol['match'] == 'fuzzy' else symbol['match'],
+            f"{symbol['repo']}:{location}" if symbol['repo'] else location
+        )
+    console.print(table)
+    return 0
+
+
+def cmd_similar(args):
+    """Find code similar to an indexed chunk, by its stored vector"""
+    try:
+        filter_expr = parse_filter(args.filter) if args.filter else None
+    except FilterError as e:
+        print_error(str(e))
+        return 1
+    print_header("Similar Code")
+    
+    rag = create_rag(args)
+    try:
+        results = rag.similar_to(args.id, k=args.n_results, exclude_near_duplicates=args.exclude_near_duplicates,
+                                 near_duplicate_similarity=args.near_duplicate_similarity, filter_expr=filter_expr)
+    except (EmbeddingError, ValueError) as e:
+        print_error(str(e))
+        return 1
+    if results is None:
+        print_error(f"No indexed chunk or symbol has the id '{args.id}'")
+        return 1
+    if not results:
+        print_warning(f"No code similar to '{args.id}'")
+        return 0
+    
+    print_success(f"Found {len(results)} chunk(s) similar to '{args.id}'\n")
+    table = Table(show_header=True, header_style="bold magenta")
+    table.add_column("Symbol", style="cyan", no_wrap=True)
+    table.add_column("Similarity", style="green")
+    table.add_column("Location")
+    table.add_column("Copies", style="yellow")
+    for result in results:
+        metadata = result['metadata']
+        location = f"{metadata.get('filepath')}:{metadata.get('line_start')}"
+        copies = [entry['location'] for entry in result.get('duplicates', [])]
+        table.add_row(
+            qualified_name(metadata) or metadata.get('name', 'unknown'),
+            f"{result['vector_score']:.3f}" + (" near-duplicate" if result['near_duplicate'] else ""),
+            f"{metadata['repo']}:{location}" if metadata.get('repo') else location,
+            ', '.join(copies)
+        )
+    console.print(table)
+    return 0
+
+
+def cmd_implements(args):
+    """Find types that implement an interface"""
+    print_header("Interface Implementations")
+    
+    # Initialize RAG system
+    rag = create_rag(args)
+    
+    results = rag.find_implementations(
+        interface_name=args.interface,
+        language=args.language,
+        n_results=args.n_results
+    )
+    
+    if not results:
+        print_warning(f"No implementations of '{args.interface}' found")
+        return 0
+    
+    print_success(f"Found {len(results)} implementation(s) of '{args.interface}'\n")
+    
+    table = Table(show_header=True, header_style="bold magenta")
+    table.add_column("Type", style="cyan", no_wrap=True)
+    table.add_column("Package", style="green")
+    table.add_column("Receiver", style="yellow")
+    table.add_column("Location")
+    
+    for result in results:
+        metadata = result['metadata']
+        name = metadata.get('name', 'unknown')
+        table.add_row(
+            name,
+            metadata.get('namespace', ''),
+            f"*{name}" if result['pointer_only'] else name,
+            f"{metadata.get('filepath', 'unknown')}:{metadata.get('line_start', '?')}"
+        )
+    
+    console.print(table)
+    return 0
+
+
+def cmd_methods(args):
+    """Show the method set an interface requires"""
+    print_header("Interface Methods")
+    
+    # Initialize RAG system
+    rag = create_rag(args)
+    
+    matches = rag.interface_method_set(args.interface, language=args.language)
+    if not matches:
+        print_warning(f"No interface '{args.interface}' found")
+        return 0
+    
+    for match in matches:
+        metadata = match['interface']['metadata']
+        print_success(
+            f"{metadata.get('namespace', '')}.{metadata.get('name', args.interface)} "
+            f"({metadata.get('filepath', 'unknown')}:{metadata.get('line_start', '?')}) "
+            f"requires {len(match['methods'])} method(s)\n"
+        )
+        table = Table(show_header=True, header_style="bold magenta")
+        table.add_column("Method", style="cyan", no_wrap=True)
+        table.add_column("Signature", style="green")
+        table.add_column("From", style="yellow")
+        for method in match['methods']:
+            table.add_row(method['name'], method.get('signature', ''), method.get('via', ''))
+        console.print(table)
+        if match['unresolved_embeds']:
+            print_warning(f"Embedded interfaces not in the index: {', '.join(match['unresolved_embeds'])}")
+    return 0
+
+
+def cmd_calls(args):
+    """Show what a function calls, or who calls it"""
+    print_header("Callers" if args.callers else "Callees")
+    
+    # Initialize RAG system
+    rag = create_rag(args)
+    
+    if args.callers:
+        results = rag.find_callers(args.symbol, language=args.language, n_results=args.n_results)
+        if not results:
+            print_warning(f"No callers of '{args.symbol}' found")
+            return 0
+        
+        print_success(f"Found {len(results)} caller(s) of '{args.symbol}'\n")
+        table = Table(show_header=True, header_style="bold magenta")
+        table.add_column("Caller", style="cyan", no_wrap=True)
+        table.add_column("Package", style="green")
+        table.add_column("Location")
+        for result in results:
+            metadata = result['metadata']
+            name = qualified_name(metadata)
+            table.add_row(
+                name if result['resolved'] else f"{name} [dim](by name)[/dim]",
+                metadata.get('namespace', ''),
+                f"{metadata.get('filepath', 'unknown')}:{metadata.get('line_start', '?')}"
+            )
+        console.print(table)
+        return 0
+    
+    results = rag.find_callees(args.symbol, language=args.language)
+    if not results:
+        print_warning(f"No function or method '{args.symbol}' found")
+        return 0
+    
+    for result in results:
+        metadata = result['metadata']
+        console.print(f"[bold cyan]{qualified_name(metadata)}[/bold cyan] "
+                      f"[dim]{metadata.get('filepath', 'unknown')}:{metadata.get('line_start', '?')}[/dim]")
+        table = Table(show_header=True, header_style="bold magenta")
+        table.add_column("Calls", style="cyan", no_wrap=True)
+        table.add_column("Package", style="green")
+        table.add_column("Location")
+        for ref in result['calls']:
+            location = f"{ref['filepath']}:{ref['line']}" if ref.get('resolved') else "[dim]unresolved[/dim]"
+            table.add_row(ref['name'], ref.get('package', ''), location)
+        console.print(table)
+    return 0
+
+
+def cmd_references(args):
+    """Show the chunks that refer to a symbol, before renaming it"""
+    print_header("References")
+    
+    kinds = args.kind.split(',') if args.kind else []
+    unknown = [kind for kind in kinds if kind not in REFERENCE_KINDS]
+    if unknown:
+        print_error(f"Unknown reference kind: {', '.join(unknown)} (expected one of: {', '.join(REFERENCE_KINDS)})")
+        return 1
+    
+    # Initialize RAG system
+    rag = create_rag(args)
+    
+    results = rag.find_references(args.symbol, language=args.language, n_results=args.n_results)
+    if kinds:
+        results = [r for r in results if set(r['reference_kinds']) & set(kinds)]
+    if not results:
+        print_warning(f"No references to '{args.symbol}' found")
+        return 0
+    
+    print_success(f"Found {len(results)} symbol(s) referring to '{args.symbol}'\n")
+    table = Table(show_header=True, header_style="bold magenta")
+    table.add_column("Symbol", style="cyan", no_wrap=True)
+    table.add_column("Reference", style="yellow")
+    table.add_column("Location")
+    table.add_column("Lines", style="green")
+    for result in results:
+        metadata = result['metadata']
+        table.add_row(
+            qualified_name(metadata),
+            ', '.join(result['reference_kinds']),
+            f"{metadata.get('filepath', 'unknown')}:{metadata.get('line_start', '?')}",
+            ', '.join(str(line) for line in result['lines'])
+        )
+    console.print(table)
+    return 0
+
+
+def cmd_related(args):
+    """Show the functions related to one through shared callees and callers"""
+    print_header("Related Symbols")
+    
+    kinds = args.by.split(',') if args.by else list(RELATION_KINDS)
+    unknown = [kind for kind in kinds if kind not in RELATION_KINDS]
+    if unknown:
+        print_error(f"Unknown relation: {', '.join(unknown)} (expected one of: {', '.join(RELATION_KINDS)})")
+        return 1
+    
+    # Initialize RAG system
+    rag = create_rag(args)
+    
+    results = rag.find_related(args.symbol, language=args.language, n_results=args.n_results, kinds=kinds)
+    if not results:
+        print_warning(f"No symbols sharing callees or callers with '{args.symbol}' found")
+        return 0
+    
+    print_success(f"Found {len(results)} symbol(s) related to '{args.symbol}'\n")
+    table = Table(show_header=True, header_style="bold magenta")
+    table.add_column("Symbol", style="cyan", no_wrap=True)
+    table.add_column("Shared", justify="right")
+    table.add_column("Same callees", style="yellow")
+    table.add_column("Same callers", style="green")
+    table.add_column("Location")
+    for result in results:
+        metadata = result['metadata']
+        table.add_row(
+            qualified_name(metadata),
+            str(result['overlap']),
+            ', '.join(result['shared_callees']),
+            ', '.join(result['shared_callers']),
+            f"{metadata.get('filepath', 'unknown')}:{metadata.get('line_start', '?')}"
+        )
+    console.print(table)
+    return 0
+
+
+def format_bytes(size) -> str:
+    """A byte count in MB, '-' when unknown"""
+    return '-' if size is None else f"{size / (1024 * 1024):.1f} MB"
+
+
 def cmd_stats(args):
     """Display database statistics"""
+    if args.format == 'json':
+        console.file = sys.stderr
     print_header("Database Statistics")
     
     # Initialize RAG system
-    rag = ChromeRAGSystem(db_path=args.db_path)
+    rag = create_rag(args)
     
     # Get statistics
     stats = rag.get_statistics()
+    if args.format == 'json':
+        print(json.dumps(stats, ensure_ascii=False, indent=2))
+        return 0
     
     # Create main stats table
     main_stats = {
         "Total Chunks": stats['total_chunks'],
-        "Unique Files": stats['unique_files']
+        "Unique Files": stats['unique_files'],
+        "Search Mode": "keyword-only" if rag.keyword_only else "hybrid",
+        "Embedding Model": stats['embedding_model'],
+        "Dimensions": stats['dimensions'] or '-',
+        "Store": f"{stats['store']} ({stats['collection']}, {stats['metric']})",
+        "Vector Size": format_bytes(stats['vector_bytes']),
+        "Size on Disk": format_bytes(stats['disk_bytes'])
     }
+    if stats['duplicate_chunks']:
+        main_stats["Duplicate Chunks (Stored Once)"] = stats['duplicate_chunks']
     print_stats(main_stats)
     
+    # Extra embedding models each keep a full copy of the index's chunks
+    if stats.get('embedding_models'):
+        console.print()
+        model_table = Table(title="Extra Embedding Models", show_header=True, header_style="bold magenta")
+        model_table.add_column("Name", style="cyan", no_wrap=True)
+        model_table.add_column("Model", style="green")
+        model_table.add_column("Dimensions", justify="right")
+        model_table.add_column("Vectors", justify="right")
+        model_table.add_column("Vector Size", style="yellow", justify="right")
+        for name, info in stats['embedding_models'].items():
+            model_table.add_row(name, info['model'], str(info['dimensions'] or '-'), str(info['vectors']),
+                                format_bytes(info['vector_bytes']))
+        console.print(model_table)
+    
     # Create chunks by type table
     if stats['chunks_by_type']:
         console.print()
@@ -204,6 +1217,16 @@ def cmd_stats(args):
         
         console.print(type_table)
     
+    # Source, test and text chunks
+    if len(stats['chunks_by_kind']) > 1:
+        console.print()
+        kind_table = Table(title="Chunks by Kind", show_header=True, header_style="bold magenta")
+        kind_table.add_column("Kind", style="cyan", no_wrap=True)
+        kind_table.add_column("Count", style="green", justify="right")
+        for kind, count in sorted(stats['chunks_by_kind'].items(), key=lambda x: x[1], reverse=True):
+            kind_table.add_row(kind, str(count))
+        console.print(kind_table)
+    
     # Create chunks by language table
     if stats['chunks_by_language']:
         console.print()
@@ -219,6 +1242,292 @@ def cmd_stats(args):
         
         console.print(lang_table)
     
+    # Repositories of a multi-repo index, with the root each was indexed from
+    if stats.get('chunks_by_repo'):
+        console.print()
+        roots = rag.repo_roots()
+        repo_table = Table(title="Chunks by Repository", show_header=True, header_style="bold magenta")
+        repo_table.add_column("Repository", style="cyan", no_wrap=True)
+        repo_table.add_column("Count", style="green", justify="right")
+        repo_table.add_column("Root", style="dim")
+        
+        for repo, count in sorted(stats['chunks_by_repo'].items(), key=lambda x: x[1], reverse=True):
+            repo_table.add_row(repo, str(count), roots.get(repo, ''))
+        
+        console.print(repo_table)
+    
+    return 0
+
+
+def cmd_files(args):
+    """List the indexed files with their chunk counts"""
+    if args.format == 'json':
+        console.file = sys.stderr
+    print_header("Indexed Files")
+    
+    rag = create_rag(args)
+    files = rag.list_files(repo=args.repo, path_glob=args.path)
+    if args.format == 'json':
+        print(json.dumps({'files': files, 'total': len(files)}, ensure_ascii=False, indent=2))
+        return 0
+    if not files:
+        print_warning("No indexed files" + (" match" if args.repo or args.path else ""))
+        return 0
+    
+    table = Table(show_header=True, header_style="bold magenta")
+    table.add_column("File", style="cyan")
+    table.add_column("Language", style="green")
+    table.add_column("Chunks", justify="right")
+    table.add_column("Duplicates", style="yellow", justify="right")
+    for entry in files:
+        table.add_row(entry['location'], entry['language'], str(entry['chunks']), str(entry['duplicates'] or ''))
+    console.print(table)
+    console.print(f"[dim]{len(files)} files, {sum(entry['chunks'] for entry in files)} chunks[/dim]")
+    return 0
+
+
+def cmd_save(args):
+    """Save the index to a snapshot directory"""
+    print_header("Save Index")
+    
+    rag = create_rag(args)
+    manifest = rag.save_index(args.path)
+    
+    print_success(f"Saved {manifest['count']} chunks to {args.path}")
+    print_stats({
+        "Embedding Model": manifest['embedding_model'],
+        "Dimensions": manifest['dimensions'],
+        "Chunks": manifest['count']
+    })
+    return 0
+
+
+def cmd_export(args):
+    """Export the index as JSON lines for external tools"""
+    to_stdout = args.output == '-'
+    if not to_stdout:
+        print_header("Export Index")
+    
+    rag = create_rag(args)
+    where = {"language": args.language} if args.language else None
+    if to_stdout:
+        # stdout carries the records; the status goes to stderr
+        count = rag.export_jsonl(sys.stdout, with_embeddings=args.with_embeddings, where=where)
+        log_console.print(f"[dim]Exported {count} chunks[/dim]")
+    else:
+        with open(args.output, 'w', encoding='utf-8') as f:
+            count = rag.export_jsonl(f, with_embeddings=args.with_embeddings, where=where)
+        print_success(f"Exported {count} chunks to {args.output}")
+    return 0
+
+
+def cmd_ingest(args):
+    """Embed and store chunks parsed elsewhere, read as JSON lines"""
+    print_header("Ingest Chunks")
+    
+    rag = create_rag(args, cache_embeddings=True)
+    if args.input == '-':
+        summary = rag.ingest_chunks(sys.stdin, batch_size=args.batch_size)
+    else:
+        try:
+            with open(args.input, encoding='utf-8') as f:
+                summary = rag.ingest_chunks(f, batch_size=args.batch_size)
+        except OSError as e:
+            print_error(f"Cannot read {args.input}: {e}")
+            return 1
+    
+    for entry in summary['malformed'][:20]:
+        print_warning(f"Line {entry['line']}: {entry['error']}")
+    if len(summary['malformed']) > 20:
+        print_warning(f"... {len(summary['malformed']) - 20} more malformed records")
+    print_stats({
+        "Records": summary['records'],
+        "Chunks Ingested": summary['ingested'],
+        "Not Embedded": summary['not_embedded'],
+        "Malformed Records": len(summary['malformed'])
+    })
+    if summary['malformed'] or summary['not_embedded']:
+        print_error("Some records were not ingested")
+        return 1
+    print_success(f"Ingested {summary['ingested']} chunks")
+    return 0
+
+
+def cmd_diff(args):
+    """Show the symbols added, removed and modified between two index snapshots"""
+    if args.format == 'json':
+        console.file = sys.stderr
+    print_header("Index Diff")
+    
+    try:
+        diff = DiffIndex(args.old, args.new, ignore_formatting=args.ignore_formatting,
+                         paths=split_patterns(args.path))
+    except SnapshotError as e:
+        print_error(str(e))
+        return 1
+    
+    if args.format == 'json':
+        print(json.dumps(diff.to_dict(), ensure_ascii=False, indent=2))
+        return 0
+    
+    summary = diff.summary()
+    if not diff.changes:
+        print_success(f"No symbol changes between {args.old} and {args.new}")
+        return 0
+    styles = {'added': 'green', 'removed': 'red', 'modified': 'yellow'}
+    for location, changes in diff.by_file().items():
+        console.print(location, style="bold cyan", markup=False, highlight=False)
+        for change in changes:
+            # Names may hold brackets (generics), so no markup
+            line = f"  {describe_change(change)}"
+            if change['status'] != 'removed':
+                line += f"  (line {change['line_start']})"
+            console.print(line, style=styles[change['status']], markup=False, highlight=False)
+        console.print()
+    print_stats({
+        "Files Changed": summary['files'],
+        f"Symbols Modified ({STATUS_MARKERS['modified']})": summary['modified'],
+        f"Symbols Added ({STATUS_MARKERS['added']})": summary['added'],
+        f"Symbols Removed ({STATUS_MARKERS['removed']})": summary['removed']
+    })
+    return 0
+
+
+def cmd_load(args):
+    """Replace the database with a saved snapshot"""
+    print_header("Load Index")
+    
+    rag = create_rag(args)
+    try:
+        manifest = rag.load_index(args.path)
+    except SnapshotError as e:
+        print_error(str(e))
+        return 1
+    
+    print_success(f"Loaded {manifest['count']} chunks from {args.path}")
+    return 0
+
+
+def cmd_migrate(args):
+    """Re-embed the index with another embedding model, keeping it searchable until the switch"""
+    print_header("Migrate Embeddings")
+    
+    rag = create_rag(args)
+    # Vectors the new model already produced for a text come from the embedding cache
+    embedder = create_embedder(
+        backend=args.to_embedder or args.embedder,
+        model_name=args.to_model,
+        base_url=args.ollama_url,
+        cache_path=None if args.no_embedding_cache else CONFIG.embedding_cache_path,
+        requests_per_minute=args.embedding_rpm,
+        max_concurrent=args.embedding_concurrency
+    )
+    
+    # The first Ctrl+C stops at the next batch and leaves the index on the old model
+    cancel = CancelToken(args.timeout)
+    with create_progress_bar() as bar, cancel_on_interrupt(cancel):
+        task = bar.add_task("[cyan]Re-embedding chunks...", total=None)
+        
+        def report(state):
+            bar.update(task, completed=state.chunks_embedded, total=state.chunks_total)
+        
+        try:
+            summary = rag.migrate_embedder(embedder, cancel=cancel, progress=report)
+        except EmbeddingError as e:
+            print_error(f"Migration failed, the index is unchanged: {e}")
+            return 1
+    
+    if summary['cancelled']:
+        print_warning(f"Migration {cancel.reason}; the index still uses '{summary['previous_model']}'")
+        return 1
+    print_success(f"Migrated {summary['chunks']} chunks to '{summary['embedding_model']}'")
+    print_stats({
+        "Previous Model": summary['previous_model'],
+        "Embedding Model": summary['embedding_model'],
+        "Dimensions": summary['embedding_dimensions'],
+        "Chunks": summary['chunks']
+    })
+    return 0
+
+
+def cmd_serve(args):
+    """Serve the index over HTTP"""
+    from server import RAGServer
+    
+    if args.allow_reindex and not args.source:
+        print_error("--allow-reindex needs --source (the directory to re-index)")
+        return 1
+    
+    rag = create_rag(args)
+    if args.snapshot:
+        try:
+            rag.load_index(args.snapshot)
+        except SnapshotError as e:
+            print_error(str(e))
+            return 1
+    
+    server = RAGServer((args.host, args.port), rag, source_path=args.source, allow_reindex=args.allow_reindex,
+                       saved_searches=CONFIG.saved_searches, snippet_lines=CONFIG.snippet_lines,
+                       warmup=not args.no_warmup, warmup_query=args.warmup_query)
+    print_success(f"Serving {rag.collection.count()} chunks on http://{args.host}:{server.server_address[1]}")
+    if server.warming:
+        console.print("[dim]Warming up in the background, GET /health answers 503 until it is done[/dim]")
+    console.print("[dim]POST /search, GET /health" + (", POST /reindex" if args.allow_reindex else "") + " - Ctrl+C to stop[/dim]")
+    try:
+        server.serve_forever()
+    except KeyboardInterrupt:
+        print_warning("Shutting down")
+    finally:
+        server.server_close()
+    return 0
+
+
+def cmd_serve_grpc(args):
+    """Serve the index over gRPC"""
+    from grpc_server import GrpcUnavailable, create_server
+    
+    rag = create_rag(args)
+    if args.snapshot:
+        try:
+            rag.load_index(args.snapshot)
+        except SnapshotError as e:
+            print_error(str(e))
+            return 1
+    
+    try:
+        server, port = create_server(rag, host=args.host, port=args.port, max_workers=args.workers)
+    except GrpcUnavailable as e:
+        print_error(str(e))
+        return 1
+    if not args.no_warmup:
+        # Before the port accepts calls, so none of them waits on a cold index
+        from utils.warmup import warm_up
+        report = warm_up(rag, args.warmup_query)
+        if report['status'] == 'ok':
+            print_info(f"Warmed up in {report['seconds']}s")
+        else:
+            print_warning(f"Warm-up failed after {report['seconds']}s: {report['error']}")
+    server.start()
+    print_success(f"Serving {rag.collection.count()} chunks over gRPC on {args.host}:{port}")
+    console.print("[dim]coderag.v1.CodeSearch: Search, Lookup, GetSymbol - Ctrl+C to stop[/dim]")
+    try:
+        server.wait_for_termination()
+    except KeyboardInterrupt:
+        print_warning("Shutting down")
+    finally:
+        server.stop(grace=None)
+    return 0
+
+
+def cmd_mcp(args):
+    """Serve the index to coding agents over MCP (stdio)"""
+    from mcp_server import MCPServer
+    
+    # stdout carries the protocol; logs and status go to stderr
+    console.file = sys.stderr
+    rag = create_rag(args)
+    console.print(f"[dim]MCP server ready ({rag.collection.count()} chunks), reading stdin[/dim]")
+    MCPServer(rag, source_path=args.source).serve()
     return 0
 
 
@@ -233,7 +1542,7 @@ def cmd_clear(args):
             return 0
     
     # Initialize RAG system and clear
-    rag = ChromeRAGSystem(db_path=args.db_path)
+    rag = create_rag(args)
     rag.clear_collection()
     
     # Clear incremental state
@@ -244,8 +1553,201 @@ def cmd_clear(args):
     return 0
 
 
+def cmd_config(args):
+    """Config file commands"""
+    if args.config_command == 'searches':
+        return show_saved_searches(args)
+    if args.config_command != 'validate':
+        print_error("Usage: cli.py config validate [--offline] | config searches")
+        return 1
+    print_header("Config Check")
+    
+    if args.config_file:
+        print_info(f"Config file: {args.config_file}")
+    else:
+        print_info(f"No config file (--config, ${CONFIG_ENV} or {', '.join(CONFIG_FILE_NAMES)})")
+    if args.config_sources:
+        table = Table(show_header=True, header_style="bold magenta")
+        table.add_column("Setting", style="cyan", no_wrap=True)
+        table.add_column("Value", style="green")
+        table.add_column("From", style="yellow")
+        for setting, source in sorted(args.config_sources.items()):
+            table.add_row(setting, json.dumps(getattr(CONFIG, setting), default=str), source)
+        console.print(table)
+    
+    problems = list(args.config_problems) + setting_problems()
+    if not args.offline and not problems:
+        problems += backend_problems(args)
+    
+    if problems:
+        for problem in problems:
+            print_error(problem)
+        print_error(f"{len(problems)} problem(s) found")
+        return 1
+    print_success("Config is valid" + (" (backends not checked)" if args.offline else ""))
+    return 0
+
+
+def show_saved_searches(args) -> int:
+    """List the saved searches with their parameters and fields, and what is wrong with them"""
+    searches = CONFIG.saved_searches
+    problems = [problem for problem in args.config_problems if problem.startswith('saved_searches')]
+    problems += saved_search_problems(searches)
+    if args.format == 'json':
+        described = {name: describe_saved_search(spec) for name, spec in searches.items()
+                     if not saved_search_problems({name: spec})}
+        print(json.dumps({'saved_searches': described, 'problems': problems}, ensure_ascii=False, indent=2))
+        return 1 if problems else 0
+    
+    print_header("Saved Searches")
+    if not searches:
+        print_info("No saved searches (add [saved_searches.<name>] sections to the config file)")
+    table = Table(show_header=True, header_style="bold magenta")
+    table.add_column("Name", style="cyan", no_wrap=True)
+    table.add_column("Query", style="green")
+    table.add_column("Parameters", style="yellow")
+    table.add_column("Sets")
+    for name, spec in sorted(searches.items()):
+        if saved_search_problems({name: spec}):
+            continue
+        described = describe_saved_search(spec)
+        params = ', '.join(param if default is None else f"{param}={default}"
+                           for param, default in described['params'].items())
+        fields = ', '.join(f"{key}={json.dumps(value)}" for key, value in described['fields'].items())
+        query = described['query'] + (f"\n[dim]{described['description']}[/dim]" if described['description'] else '')
+        table.add_row(name, query, params or '-', fields or '-')
+    if searches:
+        console.print(table)
+    for problem in problems:
+        print_error(problem)
+    return 1 if problems else 0
+
+
+def setting_problems():
+    """What is wrong with the values of the current settings: unknown choices, bad numbers, missing roots"""
+    problems = []
+    for setting, choices in SETTING_CHOICES.items():
+        if getattr(CONFIG, setting) not in choices:
+            problems.append(f"{setting}: {getattr(CONFIG, setting)!r} is not one of: "
+                            f"{', '.join(str(c) for c in choices if c is not None)}")
+    for language, options in CONFIG.language_chunking.items():
+        for key in ('granularity', 'overlap_strategy'):
+            value = options.get(key)
+            if value is not None and value not in SETTING_CHOICES[key]:
+                problems.append(f"languages.{language}.{key}: {value!r} is not one of: "
+                                f"{', '.join(SETTING_CHOICES[key])}")
+        for key in ('min_chunk_lines', 'min_chunk_tokens'):
+            if options.get(key, 0) < 0:
+                problems.append(f"languages.{language}.{key}: must not be negative, got {options[key]}")
+    for language, normalization in CONFIG.language_code_normalization.items():
+        if normalization not in NORMALIZATIONS:
+            problems.append(f"languages.{language}.code_normalization: {normalization!r} is not one of: "
+                            f"{', '.join(NORMALIZATIONS)}")
+    problems += [f"tokenizer_rules: {problem}" for problem in rule_problems(CONFIG.tokenizer_rules)]
+    for language, rules in CONFIG.language_tokenizer_rules.items():
+        problems += [f"languages.{language}.tokenizer_rules: {problem}" for problem in rule_problems(rules)]
+    
+    for spec in CONFIG.chunk_processors:
+        try:
+            load_processor(spec)
+        except ValueError as e:
+            problems.append(f"chunk_processors: {e}")
+    
+    for setting in ('batch_size', 'embedding_batch_size', 'embedding_max_concurrency'):
+        if getattr(CONFIG, setting) < 1:
+            problems.append(f"{setting}: must be at least 1, got {getattr(CONFIG, setting)}")
+    for setting in ('depth_penalty', 'recency_weight', 'min_chunk_lines', 'min_chunk_tokens', 'embedding_workers',
+                    'max_file_bytes', 'max_stream_file_bytes'):
+        if getattr(CONFIG, setting) < 0:
+            problems.append(f"{setting}: must not be negative, got {getattr(CONFIG, setting)}")
+    for setting in ('max_top_k', 'max_candidate_k', 'max_response_bytes'):
+        if (getattr(CONFIG, setting) or 0) < 0:
+            problems.append(f"{setting}: must not be negative (0 disables the limit), got {getattr(CONFIG, setting)}")
+    if CONFIG.candidate_k and CONFIG.max_candidate_k and CONFIG.candidate_k > CONFIG.max_candidate_k:
+        problems.append(f"candidate_k: {CONFIG.candidate_k} is above max_candidate_k ({CONFIG.max_candidate_k}), "
+                        f"so every search would be rejected")
+    if CONFIG.rrf_k <= 0:
+        problems.append(f"rrf_k: must be positive, got {CONFIG.rrf_k}")
+    if CONFIG.generated_weight <= 0:
+        problems.append(f"generated_weight: must be positive, got {CONFIG.generated_weight}")
+    for setting, fields in (('embedding_document_template', DOCUMENT_FIELDS),
+                            ('embedding_query_template', QUERY_FIELDS)):
+        problems += [f"{setting}: {problem}" for problem in template_problems(getattr(CONFIG, setting), fields)]
+    if not 0 < CONFIG.near_duplicate_similarity <= 1:
+        problems.append(f"near_duplicate_similarity: must be above 0 and at most 1, got {CONFIG.near_duplicate_similarity}")
+    if not 0 <= CONFIG.language_confidence <= 1:
+        problems.append(f"language_confidence: must be between 0 and 1, got {CONFIG.language_confidence}")
+    if CONFIG.snippet_lines is not None and CONFIG.snippet_lines < 1:
+        problems.append(f"snippet_lines: must be at least 1, got {CONFIG.snippet_lines}")
+    for setting in ('embedding_models', 'query_embedders'):
+        for name, spec in getattr(CONFIG, setting).items():
+            if not isinstance(spec, dict):
+                problems.append(f"{setting}.{name}: expected a table of {', '.join(MODEL_SPEC_KEYS)}")
+                continue
+            unknown = sorted(set(spec) - set(MODEL_SPEC_KEYS))
+            if unknown:
+                problems.append(f"{setting}.{name}: unknown key {', '.join(unknown)} "
+                                f"(expected: {', '.join(MODEL_SPEC_KEYS)})")
+            if spec.get('backend', 'default') not in ('default', 'ollama'):
+                problems.append(f"{setting}.{name}.backend: {spec['backend']!r} is not one of: default, ollama")
+    for name in CONFIG.search_embedding_models:
+        if name != PRIMARY_MODEL and name not in CONFIG.embedding_models:
+            problems.append(f"search_embedding_models: {name!r} is not '{PRIMARY_MODEL}' or a name "
+                            f"from embedding_models")
+    problems += saved_search_problems(CONFIG.saved_searches)
+    if CONFIG.recency_half_life_days <= 0:
+        problems.append(f"recency_half_life_days: must be positive, got {CONFIG.recency_half_life_days}")
+    chunking = [('', CONFIG.max_tokens, CONFIG.token_overlap)] + [
+        (f"languages.{language}.", options.get('max_tokens', CONFIG.max_tokens),
+         options.get('token_overlap', CONFIG.token_overlap))
+        for language, options in CONFIG.language_chunking.items()
+    ]
+    for prefix, max_tokens, overlap in chunking:
+        if max_tokens < 0 or overlap < 0:
+            problems.append(f"{prefix}max_tokens and {prefix}token_overlap must not be negative")
+        elif max_tokens and overlap >= max_tokens:
+            problems.append(f"{prefix}token_overlap ({overlap}) must be smaller than max_tokens ({max_tokens})")
+    
+    for spec in CONFIG.index_roots:
+        try:
+            _, path = parse_root(spec)
+        except ValueError as e:
+            problems.append(f"roots: {e}")
+            continue
+        if not Path(path).is_dir():
+            problems.append(f"roots: {path} is not a directory")
+    return problems
+
+
+def backend_problems(args):
+    """What keeps the selected store and embedder from serving the index (unreachable, mismatched)"""
+    try:
+        rag = create_rag(args)
+    except (StoreError, EmbeddingError, ValueError) as e:
+        return [f"store: {e}"]
+    except Exception as e:
+        return [f"store: cannot open the {args.store} store: {e}"]
+    try:
+        dimension = rag.validate_embedder()
+    except EmbeddingError as e:
+        return [f"embedder: {e}"]
+    except Exception as e:
+        return [f"embedder: {rag.embedder} is unreachable: {e}"]
+    print_info(f"Store {rag.collection!r} and embedder {rag.embedder} ({dimension} dimensions) are ready")
+    return []
+
+
 def main():
     """Main CLI entry point"""
+    # The config file and the environment set the defaults of the options below
+    preparser = argparse.ArgumentParser(add_help=False)
+    preparser.add_argument('--config')
+    try:
+        config_file, config_sources, config_problems = load_config(preparser.parse_known_args()[0].config)
+    except ConfigFileError as e:
+        print_error(str(e))
+        return 1
+    
     parser = argparse.ArgumentParser(
         description="Chrome Source Code RAG System - Professional code indexing and search",
         formatter_class=argparse.RawDescriptionHelpFormatter,
@@ -257,48 +1759,322 @@ Examples:
   # Index only C++ files
   %(prog)s index --path /path/to/chromium/src --file-types cpp
   
+  # Re-index only what changed (content hashes; moves and deletions handled)
+  %(prog)s update --path /path/to/chromium/src
+  
+  # Keep the index current while editing
+  %(prog)s update --path /path/to/src --watch
+  
+  # Serve search over HTTP for the team
+  %(prog)s serve --port 8080 --source /path/to/chromium/src --allow-reindex
+  
+  # Serve search to internal services over gRPC
+  %(prog)s serve-grpc --port 50051
+  
   # Search for code
   %(prog)s search --query "buffer overflow vulnerability"
   
+  # Boolean filters: any mix of fields, with AND, OR, NOT and parentheses
+  %(prog)s search --query "token refresh" --filter "(language=go OR language=rust) AND NOT kind=test"
+  
+  # Usage examples ahead of other code
+  %(prog)s search --query "authenticate a user" --language go --boost example=2
+  
+  # Search results as JSON lines for scripts
+  %(prog)s search --query "session timeout" --format jsonl --quiet
+  
+  # A saved search of the config file, with its placeholders filled in
+  %(prog)s search --saved error-handling --param package=auth
+  
+  # The second page of 20 results
+  %(prog)s search --query "parse a url" --n-results 20 --offset 20
+  
+  # Measure retrieval quality on a query set (compare embedders, chunk sizes, weights)
+  %(prog)s eval test_samples/eval/comprehensive.jsonl --per-query
+  
   # Find a specific symbol
   %(prog)s symbol --name RenderFrameHost --type class
   
+  # Jump to a symbol by (part of) its name, typos allowed
+  %(prog)s lookup --name sessionmanager
+  
+  # Code similar to a function: copy-paste candidates and related implementations
+  %(prog)s similar --id "auth/login.go:Login:12"
+  
+  # Find Go types implementing an interface
+  %(prog)s implements --interface Authenticator
+  %(prog)s implements --interface Repository --language swift
+  
+  # Methods a Go interface requires (embedded interfaces included)
+  %(prog)s methods --interface Authenticator
+  
+  # Who calls a Go function, and what it calls
+  %(prog)s calls --symbol CreateSession --callers
+  %(prog)s calls --symbol AdminUser.Authenticate
+  
+  # Functions calling the same helpers as a Go function, or called from the same places
+  %(prog)s related --symbol CreateSession
+  
+  # Index fully offline with a local Ollama server
+  %(prog)s --embedder ollama --embedding-model nomic-embed-text index --path /path/to/src
+  
+  # Keep vectors in an existing Qdrant server instead of a local ChromaDB
+  %(prog)s --store qdrant --qdrant-url http://qdrant:6333 index --path /path/to/src
+  
+  # Single-file index, no services (copy the .db file to share it)
+  %(prog)s --store sqlite --sqlite-path ./chrome.db index --path /path/to/src
+  
   # View statistics
   %(prog)s stats
+  
+  # Snapshot the index and restore it elsewhere (no re-embedding)
+  %(prog)s save --path ./snapshots/chrome
+  %(prog)s load --path ./snapshots/chrome
+  
+  # What changed between two snapshots, symbol by symbol
+  %(prog)s diff ./snapshots/v1 ./snapshots/v2
+  
+  # Export chunks as JSON lines for notebooks or other vector databases
+  %(prog)s export --output chunks.jsonl --with-embeddings
+  
+  # Embed chunks parsed by another tool (JSON lines in the export schema)
+  my-parser src/ | %(prog)s ingest
         """
     )
     
+    parser.add_argument(
+        '--config',
+        metavar='FILE',
+        help=f'TOML or YAML file of settings; options given here override it, CODE_RAG_* environment '
+             f'variables override both (default: ${CONFIG_ENV}, else {" or ".join(CONFIG_FILE_NAMES)} if present)'
+    )
+    
     parser.add_argument(
         '--db-path',
         default=CONFIG.db_path,
         help=f'Path to ChromaDB database (default: {CONFIG.db_path})'
     )
     
+    parser.add_argument(
+        '--store',
+        default=CONFIG.vector_store,
+        choices=['chroma', 'qdrant', 'sqlite', 'pgvector'],
+        help=f'Vector store backend (default: {CONFIG.vector_store})'
+    )
+    
+    parser.add_argument(
+        '--metric',
+        default=CONFIG.distance_metric,
+        choices=list(METRICS),
+        help=f'Distance metric of the collection, fixed once it is indexed (default: {CONFIG.distance_metric})'
+    )
+    
+    parser.add_argument(
+        '--quantization',
+        default=CONFIG.vector_quantization,
+        choices=list(QUANTIZATIONS),
+        help='How the sqlite or qdrant store keeps vectors: none (float32) or int8 (a quarter of the size, '
+             'slightly lower recall); fixed once indexed (default: the collection\'s, else none)'
+    )
+    
+    parser.add_argument(
+        '--qdrant-url',
+        default=CONFIG.qdrant_url,
+        help=f'Qdrant server URL for the qdrant store (default: {CONFIG.qdrant_url})'
+    )
+    
+    parser.add_argument(
+        '--sqlite-path',
+        default=CONFIG.sqlite_path,
+        help=f'Database file for the sqlite store (default: {CONFIG.sqlite_path})'
+    )
+    
+    parser.add_argument(
+        '--pg-dsn',
+        default=CONFIG.pgvector_dsn,
+        help=f'PostgreSQL connection string for the pgvector store (default: {CONFIG.pgvector_dsn})'
+    )
+    
     parser.add_argument(
         '--log-level',
-        default='INFO',
+        default=CONFIG.log_level,
         choices=['DEBUG', 'INFO', 'WARNING', 'ERROR'],
-        help='Logging level (default: INFO)'
+        help=f'Logging level (default: {CONFIG.log_level})'
+    )
+    
+    parser.add_argument(
+        '--log-format',
+        default=CONFIG.log_format,
+        choices=list(LOG_FORMATS),
+        help=f'Log lines on stderr: colored text, or one JSON object per line with structured fields '
+             f'(default: {CONFIG.log_format})'
+    )
+    
+    parser.add_argument(
+        '--log-file',
+        help='Also write every log record, DEBUG included, to this file'
+    )
+    
+    parser.add_argument(
+        '--verbose',
+        action='store_true',
+        help='Print the calls, items and latency of each pipeline stage (crawl, parse, embed, upsert; '
+             'vector search, rerank, pack) after index, update and search'
+    )
+    
+    parser.add_argument(
+        '--embedder',
+        default=CONFIG.embedding_backend,
+        choices=['default', 'ollama', 'none'],
+        help=f'Embedding backend; none builds a keyword-only index searched by BM25 alone '
+             f'(default: {CONFIG.embedding_backend})'
+    )
+    
+    parser.add_argument(
+        '--embedding-model',
+        help=f'Embedding model for the ollama backend (default: {CONFIG.ollama_model})'
+    )
+    
+    parser.add_argument(
+        '--ollama-url',
+        default=CONFIG.ollama_base_url,
+        help=f'Ollama server URL (default: {CONFIG.ollama_base_url})'
+    )
+    
+    parser.add_argument(
+        '--embedding-rpm',
+        type=int,
+        metavar='N',
+        help=f'Embedding requests per minute to the ollama backend, 0 for no limit '
+             f'(default: {CONFIG.embedding_requests_per_minute})'
+    )
+    
+    parser.add_argument(
+        '--embedding-concurrency',
+        type=int,
+        metavar='N',
+        help=f'Embedding requests in flight at once to the ollama backend '
+             f'(default: {CONFIG.embedding_max_concurrency})'
+    )
+    
+    parser.add_argument(
+        '--reranker',
+        default=CONFIG.reranker_backend,
+        choices=['none', 'http'],
+        help=f'Cross-encoder reranking of the top search candidates (default: {CONFIG.reranker_backend})'
+    )
+    
+    parser.add_argument(
+        '--reranker-url',
+        default=CONFIG.reranker_url,
+        help=f'Reranking server URL for the http reranker (default: {CONFIG.reranker_url})'
+    )
+    
+    parser.add_argument(
+        '--reranker-model',
+        help=f'Reranking model for the http reranker (default: {CONFIG.reranker_model})'
     )
     
     subparsers = parser.add_subparsers(dest='command', help='Available commands')
     
     # Index command
     index_parser = subparsers.add_parser('index', help='Index Chrome source directory')
-    index_parser.add_argument('--path', required=True, help='Path to Chrome src/ directory')
+    index_parser.add_argument('--path', action='append', metavar='[LABEL=]PATH', help='Directory to index; repeat to index several repositories into one collection, each as LABEL=PATH (default label: the directory name; default: the config file\'s roots)')
     index_parser.add_argument('--file-types', help='Comma-separated list of file types (cpp,python,javascript,mojom,gn)')
     index_parser.add_argument('--batch-size', type=int, default=CONFIG.batch_size, help=f'Batch size for database operations (default: {CONFIG.batch_size})')
     index_parser.add_argument('--clear', action='store_true', help='Clear existing database before indexing')
     index_parser.add_argument('--force', action='store_true', help='Force re-indexing of all files (ignore incremental state)')
     index_parser.add_argument('--no-parallel', action='store_true', help='Disable parallel processing (use single thread)')
+    index_parser.add_argument('--max-tokens', type=int, default=CONFIG.max_tokens, help=f'Token budget per chunk; larger symbols are split, 0 disables (default: {CONFIG.max_tokens})')
+    index_parser.add_argument('--embed-doc', action='store_true', help='Shorthand for --embedding-mode doc')
+    index_parser.add_argument('--embedding-mode', choices=list(EMBEDDING_MODES), help=f'What chunk vectors are computed from: code, doc (doc comment + signature of documented symbols), signature (of every symbol) or dual (code and signature vectors) (default: the index\'s mode, else {CONFIG.embedding_mode})')
+    index_parser.add_argument('--normalize-embeddings', action='store_true', help='Scale vectors to unit length, so the dot metric ranks like cosine (recorded with the index)')
+    index_parser.add_argument('--dedup', action='store_true', help='Store chunks with identical bodies once, listing every place they appear (recorded with the index)')
+    index_parser.add_argument('--normalize-code', choices=list(NORMALIZATIONS), help=f'Normalize code before embedding it: whitespace (layout, gofmt-like for Go) or comments (layout and comments); the stored code is unchanged (recorded with the index, default: {CONFIG.code_normalization})')
+    index_parser.add_argument('--document-template', metavar='TEMPLATE', help="Template of the text chunks are embedded from, with {language}, {kind}, {symbol}, {doc}, {signature} and {body}, e.g. 'search_document: {body}' (recorded with the index, default: " + CONFIG.embedding_document_template + ")")
+    index_parser.add_argument('--query-template', metavar='TEMPLATE', help="Template of the text searches are embedded from, with {query}, e.g. 'search_query: {query}' (recorded with the index, default: " + CONFIG.embedding_query_template + ")")
+    index_parser.add_argument('--reduce-dimensions', type=int, metavar='N', help=f'Keep the first N components of each vector, for Matryoshka models such as nomic-embed-text; 0 keeps all (recorded with the index, default: {CONFIG.reduce_dimensions})')
+    index_parser.add_argument('--dedup-ignore-formatting', action='store_true', help='Like --dedup, but bodies that differ only in whitespace or comments count as identical')
+    add_discovery_arguments(index_parser)
+    
+    # Update command
+    update_parser = subparsers.add_parser('update', help='Re-index only files whose content changed')
+    update_parser.add_argument('--path', action='append', metavar='[LABEL=]PATH', help='Root directory that was indexed (repeatable, with the labels used when indexing; default: the config file\'s roots)')
+    update_parser.add_argument('--file-types', help='Comma-separated list of file types')
+    update_parser.add_argument('--batch-size', type=int, default=CONFIG.batch_size, help=f'Batch size for database operations (default: {CONFIG.batch_size})')
+    update_parser.add_argument('--no-parallel', action='store_true', help='Disable parallel processing (use single thread)')
+    update_parser.add_argument('--max-tokens', type=int, default=CONFIG.max_tokens, help=f'Token budget per chunk (default: {CONFIG.max_tokens})')
+    update_parser.add_argument('--embed-doc', action='store_true', help='Shorthand for --embedding-mode doc')
+    update_parser.add_argument('--embedding-mode', choices=list(EMBEDDING_MODES), help='What chunk vectors are computed from (default: the mode the index was built with)')
+    update_parser.add_argument('--dedup', action='store_true', help='Store chunks with identical bodies once (default: the mode the index was built with)')
+    update_parser.add_argument('--dedup-ignore-formatting', action='store_true', help='Like --dedup, ignoring whitespace and comment differences')
+    update_parser.add_argument('--watch', action='store_true', help='After updating, keep watching the tree and re-index files as they are saved')
+    update_parser.add_argument('--debounce', type=float, default=CONFIG.watch_debounce, metavar='SECONDS', help=f'With --watch, wait this long after the last change before indexing (default: {CONFIG.watch_debounce})')
+    add_discovery_arguments(update_parser)
     
     # Search command
     search_parser = subparsers.add_parser('search', help='Semantic search for code')
-    search_parser.add_argument('--query', required=True, help='Search query')
-    search_parser.add_argument('--n-results', type=int, default=5, help='Number of results (default: 5)')
-    search_parser.add_argument('--language', help='Filter by language (cpp, python, javascript, mojom, gn)')
-    search_parser.add_argument('--type', help='Filter by type (function, class, method, etc.)')
+    search_parser.add_argument('--query', help='Search query (required unless --saved is given)')
+    search_parser.add_argument('--saved', metavar='NAME', help='Run a saved search of the config file (see config searches); options given with it replace its own')
+    search_parser.add_argument('--param', action='append', metavar='NAME=VALUE', help='Value of a placeholder of the --saved search, e.g. package=auth (repeatable)')
+    search_parser.add_argument('--n-results', type=int, help='Number of results (default: 5, or the saved search\'s top_k)')
+    search_parser.add_argument('--language', help='Filter by language, comma-separated (cpp, python, javascript, go, ...)')
+    search_parser.add_argument('--type', help='Filter by symbol kind, comma-separated (function, method, type, interface, const, var, package, table, query, manifest, key, test, benchmark, fuzz, example, text, section)')
+    search_parser.add_argument('--exclude-tests', action='store_true', help='Leave out chunks of test files (kinds "test", "benchmark", "fuzz" and "example")')
+    search_parser.add_argument('--boost', action='append', metavar='KIND=FACTOR', help='Multiply the fused score of chunks of a kind, e.g. "example=2" to rank Go examples first (repeatable)')
+    search_parser.add_argument('--depth-penalty', type=float, metavar='WEIGHT', help=f'Rank results from deeply nested paths lower: the score is divided by 1 + WEIGHT * directory depth, e.g. 0.1 to put internal/ above third_party/github.com/... (default: {CONFIG.depth_penalty}, off)')
+    search_parser.add_argument('--recency-weight', type=float, metavar='WEIGHT', help=f'Rank recently modified files higher: the score is multiplied by 1 + WEIGHT * freshness, which halves every {CONFIG.recency_half_life_days:g} days (default: {CONFIG.recency_weight}, off)')
+    search_parser.add_argument('--exclude-text', action='store_true', help='Leave out plain-text blocks of files without a parser (kind "text")')
+    search_parser.add_argument('--exclude-generated', action='store_true', help='Leave out chunks of generated files (indexed with generated=true)')
+    search_parser.add_argument('--generated-weight', type=float, metavar='WEIGHT', help=f'Multiply the score of chunks of generated files, e.g. 0.5 to rank hand-written code first (default: {CONFIG.generated_weight:g})')
+    search_parser.add_argument('--path', action='append', help='Filter by file path glob, e.g. "net/*" (repeatable)')
+    search_parser.add_argument('--scope', action='append', metavar='DIR', help='Only search files under this directory of the indexed root, e.g. internal/auth (repeatable, comma-separated)')
+    search_parser.add_argument('--filter', metavar='EXPR', help='Boolean filter over language, kind, type, path, repo, name, uses, returns_error, errors, generated, author, commit, resource and tag, e.g. "(language=go OR language=rust) AND NOT kind=test AND path:*/auth/*"')
+    search_parser.add_argument('--repo', help='Filter by repository label, comma-separated (multi-repo indexes)')
+    search_parser.add_argument('--uses', help='Only code using these imported packages or members, comma-separated (sync.RWMutex, github.com/pkg/errors)')
     search_parser.add_argument('--full', action='store_true', help='Show full code without truncation')
+    search_parser.add_argument('--snippet-lines', type=int, default=SNIPPET_LINES, metavar='N', help=f'Lines of code shown per result in text output, around the first matching line (default: {SNIPPET_LINES})')
+    search_parser.add_argument('--format', choices=['text', 'json', 'jsonl'], default='text', help='Output format: colorized text, one JSON document, or one JSON record per line (default: text)')
+    search_parser.add_argument('--group-by', choices=GROUP_MODES, help="Group the results by file or by the type they belong to (a type's methods under it), groups in the order of their best result; the results stay the same")
+    search_parser.add_argument('--quiet', action='store_true', help='Print only the results: no header, status lines or log messages')
+    search_parser.add_argument('--min-score', type=float, default=CONFIG.min_score, metavar='SCORE', help='Drop results whose relevance is below SCORE; nothing above it gives no results (suggested: 0.5 vector only, 0.35 hybrid)')
+    search_parser.add_argument('--lexical-weight', type=float, default=CONFIG.hybrid_lexical_weight, help=f'BM25 share of the fused score, 0 = vector only, 1 = keyword only (default: {CONFIG.hybrid_lexical_weight})')
+    search_parser.add_argument('--fusion', choices=list(FUSION_METHODS), default=CONFIG.fusion_method, help=f'How the vector and BM25 rankings are fused: rrf (reciprocal rank) or weighted (sum of normalized scores) (default: {CONFIG.fusion_method})')
+    search_parser.add_argument('--rrf-k', type=int, default=CONFIG.rrf_k, metavar='K', help=f'Rank constant of --fusion rrf; larger flattens the lead of top ranks (default: {CONFIG.rrf_k})')
+    search_parser.add_argument('--fusion-normalization', choices=list(SCORE_NORMALIZATIONS), default=CONFIG.fusion_normalization, help=f'How --fusion weighted brings scores to 0-1: minmax over the candidates, max, or absolute (the relevance scale --min-score uses) (default: {CONFIG.fusion_normalization})')
+    search_parser.add_argument('--expand', action='store_true', help='Also search for identifier variants and synonyms of the query words (sign in -> signin, login, auth)')
+    search_parser.add_argument('--mmr', type=float, nargs='?', const=CONFIG.mmr_lambda, metavar='LAMBDA', help=f'Diversify results with MMR; LAMBDA trades relevance (1) against diversity (0) (default: {CONFIG.mmr_lambda})')
+    search_parser.add_argument('--with-surrounding', action='store_true', help="Show each result's file imports and enclosing type header, read from the source")
+    search_parser.add_argument('--with-neighbors', nargs='?', const='ids', choices=NEIGHBOR_MODES, help="Show the symbols defined right before and after each result in its file; 'bodies' shows their code too (default: ids)")
+    search_parser.add_argument('--source-root', help='Directory the indexed paths are under, for --with-surrounding (default: the directory last indexed)')
+    search_parser.add_argument('--vector-index', choices=list(VECTOR_INDEXES), help='Vectors to search in a dual index: code, signature, or both (default)')
+    search_parser.add_argument('--models', metavar='NAMES', help=f'Embedding models to rank by, comma-separated: {PRIMARY_MODEL} and names from embedding_models; several are fused by reciprocal rank (default: {",".join(CONFIG.search_embedding_models)})')
+    search_parser.add_argument('--query-embedder', metavar='NAME', help='Embed the query with this model from query_embedders instead of the index\'s embedder; it must produce vectors of the same dimension, in the same space (default: the index\'s embedder)')
+    search_parser.add_argument('--offset', type=int, metavar='N', help='Show the page of --n-results results starting after the first N of one ranking of up to page_depth results (' + str(CONFIG.page_depth) + '), the same ranking for every page while the index is unchanged')
+    search_parser.add_argument('--no-rerank', action='store_true', help='Skip the reranking stage even when a reranker is configured')
+    search_parser.add_argument('--rerank-candidates', type=int, metavar='N', help=f'Fused candidates the reranker scores (default: {CONFIG.reranker_candidates})')
+    search_parser.add_argument('--candidate-k', type=int, metavar='N', help='Candidates the vector store and the keyword index each return for reranking, MMR and --min-score to narrow down to -n (default: ' + (str(CONFIG.candidate_k) if CONFIG.candidate_k else 'sized from -n') + ')')
+    search_parser.add_argument('--pack', type=int, metavar='TOKENS', help='Print the results packed into one prompt-ready block of at most TOKENS tokens')
+    search_parser.add_argument('--merge-files', action='store_true', help='With --pack, combine chunks from the same file into one block')
+    search_parser.add_argument('--explain', action='store_true', help='Show how each result was scored: raw vector and BM25 scores, their fused shares, the query terms matched and the filters passed (in json, an "explain" record)')
+    search_parser.add_argument('--show-scores', action='store_true', help='Show the fused score with its vector and BM25 sub-scores (and the rerank score)')
+    
+    # Eval command
+    eval_parser = subparsers.add_parser('eval', help='Score retrieval on a query set: recall@k, MRR, nDCG@k')
+    eval_parser.add_argument('queries', help='JSON lines file of {"query": ..., "expected": [symbol, ...]} (see test_samples/eval)')
+    eval_parser.add_argument('--k', default=','.join(map(str, DEFAULT_K)), help=f'Comma-separated cutoffs for recall and nDCG; the largest is the number of results fetched (default: {",".join(map(str, DEFAULT_K))})')
+    eval_parser.add_argument('--lexical-weight', type=float, default=CONFIG.hybrid_lexical_weight, help=f'BM25 share of the fused score (default: {CONFIG.hybrid_lexical_weight})')
+    eval_parser.add_argument('--fusion', choices=list(FUSION_METHODS), default=CONFIG.fusion_method, help=f'How the vector and BM25 rankings are fused: rrf (reciprocal rank) or weighted (sum of normalized scores) (default: {CONFIG.fusion_method})')
+    eval_parser.add_argument('--rrf-k', type=int, default=CONFIG.rrf_k, metavar='K', help=f'Rank constant of --fusion rrf; larger flattens the lead of top ranks (default: {CONFIG.rrf_k})')
+    eval_parser.add_argument('--fusion-normalization', choices=list(SCORE_NORMALIZATIONS), default=CONFIG.fusion_normalization, help=f'How --fusion weighted brings scores to 0-1: minmax over the candidates, max, or absolute (the relevance scale --min-score uses) (default: {CONFIG.fusion_normalization})')
+    eval_parser.add_argument('--expand', action='store_true', help='Expand queries with identifier variants and synonyms')
+    eval_parser.add_argument('--mmr', type=float, nargs='?', const=CONFIG.mmr_lambda, metavar='LAMBDA', help='Diversify results with MMR')
+    eval_parser.add_argument('--exclude-tests', action='store_true', help='Leave out chunks of test files')
+    eval_parser.add_argument('--exclude-text', action='store_true', help='Leave out plain-text blocks')
+    eval_parser.add_argument('--vector-index', choices=list(VECTOR_INDEXES), help='Vectors to search in a dual index: code, signature, or both (default)')
+    eval_parser.add_argument('--no-rerank', action='store_true', help='Skip the reranking stage even when a reranker is configured')
+    eval_parser.add_argument('--per-query', action='store_true', help="Also list each query's first hit rank and the expected symbols it missed")
+    eval_parser.add_argument('--format', choices=['text', 'json'], default='text', help='Output format: tables, or the full report as JSON (default: text)')
     
     # Symbol command
     symbol_parser = subparsers.add_parser('symbol', help='Lookup specific symbol by name')
@@ -306,19 +2082,150 @@ Examples:
     symbol_parser.add_argument('--type', help='Symbol type (function, class, method, etc.)')
     symbol_parser.add_argument('--language', help='Language filter (cpp, python, javascript, mojom, gn)')
     symbol_parser.add_argument('--n-results', type=int, default=5, help='Maximum results (default: 5)')
+    symbol_parser.add_argument('--with-methods', action='store_true', help='List the methods declared on each type found (Go)')
+    
+    # Lookup command
+    lookup_parser = subparsers.add_parser('lookup', help='Go to symbol: exact, prefix and fuzzy name matches (no embedding)')
+    lookup_parser.add_argument('--name', required=True, help='Symbol name or part of one (sessionmanager finds NewSessionManager)')
+    lookup_parser.add_argument('--kind', help='Comma-separated symbol kinds (function, method, type, interface, const, var)')
+    lookup_parser.add_argument('--language', help='Comma-separated languages')
+    lookup_parser.add_argument('--repo', help='Comma-separated repository labels')
+    lookup_parser.add_argument('--n-results', type=int, default=20, help='Maximum results (default: 20)')
+    
+    # Similar command
+    similar_parser = subparsers.add_parser('similar', help='Find code similar to an indexed chunk (copy-paste candidates, related implementations)')
+    similar_parser.add_argument('--id', required=True, help='Chunk id (as search results show it) or symbol_id')
+    similar_parser.add_argument('--n-results', type=int, default=10, help='Maximum results (default: 10)')
+    similar_parser.add_argument('--exclude-near-duplicates', action='store_true', help='Leave out near-duplicates, to see related code rather than copies')
+    similar_parser.add_argument('--near-duplicate-similarity', type=float, metavar='SIMILARITY', help=f'Similarity from which a result is a near-duplicate (default: {CONFIG.near_duplicate_similarity})')
+    similar_parser.add_argument('--filter', metavar='EXPR', help='Boolean filter expression, as for search')
+    
+    # Implements command
+    implements_parser = subparsers.add_parser('implements', help='Find types implementing an interface (Go) or protocol (Swift, Elixir)')
+    implements_parser.add_argument('--interface', required=True, help='Interface name (optionally package-qualified, e.g. io.Reader)')
+    implements_parser.add_argument('--language', default='go', help='Language filter (default: go)')
+    implements_parser.add_argument('--n-results', type=int, default=50, help='Maximum results (default: 50)')
+    
+    # Methods command
+    methods_parser = subparsers.add_parser('methods', help='Show the methods an interface requires (Go)')
+    methods_parser.add_argument('--interface', required=True, help='Interface name (optionally package-qualified, e.g. io.ReadCloser)')
+    methods_parser.add_argument('--language', default='go', help='Language filter (default: go)')
+    
+    # Calls command
+    calls_parser = subparsers.add_parser('calls', help='Show call-graph edges of a function or method (Go)')
+    calls_parser.add_argument('--symbol', required=True, help='Function or method name (optionally qualified, e.g. Store.Open)')
+    calls_parser.add_argument('--callers', action='store_true', help='List callers instead of callees')
+    calls_parser.add_argument('--language', default='go', help='Language filter (default: go)')
+    calls_parser.add_argument('--n-results', type=int, default=50, help='Maximum callers (default: 50)')
+    
+    # References command
+    references_parser = subparsers.add_parser('references', help='Find everything that refers to a symbol, before renaming it (Go)')
+    references_parser.add_argument('--symbol', required=True, help='Symbol name (optionally qualified, e.g. pkg.User or Store.Open)')
+    references_parser.add_argument('--kind', help=f"Only these reference kinds, comma-separated ({', '.join(REFERENCE_KINDS)})")
+    references_parser.add_argument('--language', default='go', help='Language filter (default: go)')
+    references_parser.add_argument('--n-results', type=int, default=100, help='Maximum results (default: 100)')
+    
+    # Related command
+    related_parser = subparsers.add_parser('related', help='Find functions sharing callees or callers with a symbol (Go)')
+    related_parser.add_argument('--symbol', required=True, help='Function or method name (optionally qualified, e.g. Store.Open)')
+    related_parser.add_argument('--by', help=f"Only these relations, comma-separated ({', '.join(RELATION_KINDS)}; default: both)")
+    related_parser.add_argument('--language', default='go', help='Language filter (default: go)')
+    related_parser.add_argument('--n-results', type=int, default=20, help='Maximum results (default: 20)')
     
     # Stats command
     stats_parser = subparsers.add_parser('stats', help='Display database statistics')
+    stats_parser.add_argument('--format', choices=['text', 'json'], default='text', help='Output format: tables, or the statistics as JSON (default: text)')
+    
+    # Files command
+    files_parser = subparsers.add_parser('files', help='List the indexed files with their chunk counts')
+    files_parser.add_argument('--repo', help='Only files of this repository label')
+    files_parser.add_argument('--path', metavar='GLOB', help="Only files whose path matches this glob ('internal/auth/*')")
+    files_parser.add_argument('--format', choices=['text', 'json'], default='text', help='Output format (default: text)')
+    
+    # Save / load commands
+    save_parser = subparsers.add_parser('save', help='Save the index to a snapshot directory')
+    save_parser.add_argument('--path', required=True, help='Snapshot directory')
+    
+    load_parser = subparsers.add_parser('load', help='Replace the database with a saved snapshot')
+    load_parser.add_argument('--path', required=True, help='Snapshot directory')
+    
+    # Migrate command
+    migrate_parser = subparsers.add_parser('migrate', help='Re-embed the index with another embedding model')
+    migrate_parser.add_argument('--to-embedder', choices=['default', 'ollama'], help='Embedding backend to move to (default: --embedder)')
+    migrate_parser.add_argument('--to-model', help='Embedding model to move to, for the ollama backend')
+    migrate_parser.add_argument('--no-embedding-cache', action='store_true', help='Embed every chunk, ignoring the on-disk embedding cache')
+    migrate_parser.add_argument('--timeout', type=float, metavar='SECONDS', help='Give up after this long, leaving the index on the old model')
+    
+    # Diff command
+    diff_parser = subparsers.add_parser('diff', help='Symbols added, removed and modified between two snapshots')
+    diff_parser.add_argument('old', help='Snapshot directory of the earlier index')
+    diff_parser.add_argument('new', help='Snapshot directory of the later index')
+    diff_parser.add_argument('--ignore-formatting', action='store_true', help='Do not count changes to comments and whitespace alone')
+    diff_parser.add_argument('--path', action='append', metavar='GLOB', help='Only files matching this pattern (repeatable, comma-separated)')
+    diff_parser.add_argument('--format', choices=['text', 'json'], default='text', help='Output format: changes grouped by file, or the whole diff as JSON (default: text)')
+    
+    # Export command
+    export_parser = subparsers.add_parser('export', help='Export every chunk as JSON lines for external tools')
+    export_parser.add_argument('--output', default='-', help="Output file ('-' for stdout, the default)")
+    export_parser.add_argument('--with-embeddings', action='store_true', help='Include each chunk\'s embedding vector')
+    export_parser.add_argument('--language', help='Only chunks of this language')
+    
+    # Ingest command
+    ingest_parser = subparsers.add_parser('ingest', help='Embed and store chunks parsed elsewhere (JSON lines in the export schema)')
+    ingest_parser.add_argument('--input', default='-', help="JSON lines file ('-' for stdin, the default)")
+    ingest_parser.add_argument('--batch-size', type=int, default=CONFIG.batch_size, help=f'Chunks embedded per batch (default: {CONFIG.batch_size})')
+    
+    # Serve command
+    serve_parser = subparsers.add_parser('serve', help='Serve search over HTTP (POST /search, GET /health)')
+    serve_parser.add_argument('--host', default='127.0.0.1', help='Interface to listen on (default: 127.0.0.1)')
+    serve_parser.add_argument('--port', type=int, default=8080, help='Port to listen on (default: 8080)')
+    serve_parser.add_argument('--snapshot', help='Load this index snapshot into the database at startup')
+    serve_parser.add_argument('--source', help='Source root that POST /reindex updates the index from')
+    serve_parser.add_argument('--allow-reindex', action='store_true', help='Enable POST /reindex (incremental, in the background)')
+    serve_parser.add_argument('--no-warmup', action='store_true', default=not CONFIG.warmup, help='Skip the start-up warm-up (index load and probe embedding; GET /health answers 503 while it runs)')
+    serve_parser.add_argument('--warmup-query', default=CONFIG.warmup_query, help='Sample search the warm-up runs after loading the index')
+    
+    # gRPC serve command
+    grpc_parser = subparsers.add_parser('serve-grpc', help='Serve Search/Lookup/GetSymbol over gRPC (proto/code_rag.proto)')
+    grpc_parser.add_argument('--host', default='127.0.0.1', help='Interface to listen on (default: 127.0.0.1)')
+    grpc_parser.add_argument('--port', type=int, default=50051, help='Port to listen on (default: 50051)')
+    grpc_parser.add_argument('--workers', type=int, default=8, help='Requests handled at the same time (default: 8)')
+    grpc_parser.add_argument('--snapshot', help='Load this index snapshot into the database at startup')
+    grpc_parser.add_argument('--no-warmup', action='store_true', default=not CONFIG.warmup, help='Skip the warm-up (index load and probe embedding) run before the port accepts calls')
+    grpc_parser.add_argument('--warmup-query', default=CONFIG.warmup_query, help='Sample search the warm-up runs after loading the index')
+    
+    # MCP command
+    mcp_parser = subparsers.add_parser('mcp', help='Serve search_code/get_symbol tools to coding agents over MCP (stdio)')
+    mcp_parser.add_argument('--source', help='Source root the index was built from (for surrounding lines in get_symbol)')
     
     # Clear command
     clear_parser = subparsers.add_parser('clear', help='Clear the database')
     clear_parser.add_argument('--yes', action='store_true', help='Skip confirmation prompt')
     
+    # Config command
+    config_parser = subparsers.add_parser('config', help='Check the settings of the config file and the environment')
+    config_commands = config_parser.add_subparsers(dest='config_command', help='Config commands')
+    validate_parser = config_commands.add_parser('validate', help='Check the settings and the backends they name before a long run')
+    validate_parser.add_argument('--offline', action='store_true', help='Only check the settings, not the store and embedder they name')
+    searches_parser = config_commands.add_parser('searches', help='List the saved searches, their parameters and the options they set')
+    searches_parser.add_argument('--format', choices=['text', 'json'], default='text', help='Output format (default: text)')
+    
     # Parse arguments
     args = parser.parse_args()
     
+    # Environment variables override the options too
+    for dest, setting in FLAG_SETTINGS.items():
+        if config_sources.get(setting) == 'env' and hasattr(args, dest):
+            setattr(args, dest, getattr(CONFIG, setting))
+    if config_sources.get('index_roots') == 'env' and args.command in ('index', 'update'):
+        args.path = list(CONFIG.index_roots)
+    args.config_file, args.config_sources, args.config_problems = config_file, config_sources, config_problems
+    
     # Setup logging
-    setup_logger(level=args.log_level)
+    setup_logger(log_file=args.log_file, level=args.log_level, log_format=args.log_format)
+    if args.command != 'config':
+        for problem in config_problems:
+            print_warning(f"Ignoring setting {problem} (see config validate)")
     
     # Execute command
     if not args.command:
@@ -327,10 +2234,30 @@ Examples:
     
     commands = {
         'index': cmd_index,
+        'update': cmd_update,
         'search': cmd_search,
+        'eval': cmd_eval,
         'symbol': cmd_symbol,
+        'lookup': cmd_lookup,
+        'similar': cmd_similar,
+        'implements': cmd_implements,
+        'methods': cmd_methods,
+        'calls': cmd_calls,
+        'references': cmd_references,
+        'related': cmd_related,
         'stats': cmd_stats,
-        'clear': cmd_clear
+        'files': cmd_files,
+        'save': cmd_save,
+        'load': cmd_load,
+        'migrate': cmd_migrate,
+        'diff': cmd_diff,
+        'export': cmd_export,
+        'ingest': cmd_ingest,
+        'serve': cmd_serve,
+        'serve-grpc': cmd_serve_grpc,
+        'mcp': cmd_mcp,
+        'clear': cmd_clear,
+        'config': cmd_config
     }
     
     try:
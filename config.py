@@ -29,9 +29,96 @@ class Config:
         self.collection_name = "chrome_code"
         self.batch_size = 100
         
+        # Vector store ('chroma' = local ChromaDB at db_path, 'qdrant' = Qdrant server,
+        # 'sqlite' = single file at sqlite_path, brute-force search up to ~50k chunks,
+        # 'pgvector' = PostgreSQL tables at pgvector_dsn)
+        self.vector_store = "chroma"
+        self.sqlite_path = "./chrome_rag.db"
+        self.qdrant_url = "http://localhost:6333"
+        self.qdrant_api_key = None
+        self.qdrant_distance = None  # None: from distance_metric; Cosine, Dot, Euclid or Manhattan
+        self.qdrant_dimensions = None  # None: sized to the first vectors stored
+        self.qdrant_batch_size = 256
+        self.qdrant_timeout = 30.0
+        self.qdrant_max_retries = 3
+        self.pgvector_dsn = "postgresql://localhost:5432/rag"
+        self.pgvector_table_prefix = "rag_"
+        self.pgvector_dimensions = None  # None: sized to the first vectors stored
+        self.pgvector_index = "hnsw"  # hnsw, ivfflat or none (exact scan)
+        self.pgvector_lists = 100  # ivfflat lists; build after the initial load for best recall
+        self.pgvector_probes = 10  # ivfflat lists searched per query
+        self.pgvector_ef_search = 100  # hnsw candidate list size per query
+        self.pgvector_pool_size = 4
+        
+        # Distance the collection is searched by ('cosine', 'dot' or 'l2'), recorded with the
+        # index; normalize_embeddings scales vectors to unit length, so 'dot' ranks like 'cosine'
+        self.distance_metric = "cosine"
+        self.normalize_embeddings = False
+        
+        # Smaller vectors for bigger indexes, both recorded with the index: vector_quantization
+        # 'int8' stores a byte per component (sqlite and qdrant stores; None keeps the
+        # collection's format), reduce_dimensions keeps the first N components of every vector
+        # (0 = all; for Matryoshka-trained models such as nomic-embed-text)
+        self.vector_quantization = None
+        self.reduce_dimensions = 0
+        
+        # Embedding settings ('default' = bundled ONNX model, 'ollama' = local server,
+        # 'none' = keyword-only index, searched by BM25 alone)
+        self.embedding_backend = "default"
+        self.embedding_batch_size = 32
+        self.ollama_base_url = "http://localhost:11434"
+        self.ollama_model = "nomic-embed-text"
+        self.ollama_timeout = 60.0
+        self.ollama_max_retries = 3
+        
+        # Pacing of requests to HTTP embedding backends: requests started per minute (0 = no
+        # limit) and in flight at once. Throttled (429) requests back off with jitter, doubling
+        # from embedding_backoff up to embedding_max_backoff seconds, or wait as long as the
+        # backend's Retry-After asks
+        self.embedding_requests_per_minute = 0
+        self.embedding_max_concurrency = 1
+        self.embedding_max_retries = 5
+        self.embedding_backoff = 1.0
+        self.embedding_max_backoff = 60.0
+        # Batches embedded on background threads while files are still parsed (0 = embed each
+        # batch in line); at most embedding_max_concurrency with a rate limited backend
+        self.embedding_workers = 0
+        
+        # Extra embedding models (none unless listed), by name: each keeps a vector of every
+        # chunk in a collection of its own next to the index, e.g. {'general': {'backend':
+        # 'ollama', 'model': 'nomic-embed-text'}, 'code': {'backend': 'ollama', 'model':
+        # 'jina-embeddings-v2-base-code', 'url': 'http://gpu:11434'}}. Every model stores a
+        # full copy of the chunks (vectors, code and metadata) and embeds every indexed chunk
+        # again. search_embedding_models are the models a search ranks by, 'primary' being
+        # the embedder above; several are fused by reciprocal rank.
+        self.embedding_models = {}
+        self.search_embedding_models = ['primary']
+        
+        # Embedders a search may embed its query with instead of the primary one, by name,
+        # specified like embedding_models (search --query-embedder, "query_embedder" on
+        # /search): a tenant's fine-tuned query model, or one to experiment with. Stored
+        # vectors still come from the primary embedder, so a query embedder must produce
+        # vectors of the same dimension, in the same space, compared by the index's metric.
+        self.query_embedders = {}
+        
+        # On-disk cache of vectors keyed by model + normalized text (index/update runs)
+        self.embedding_cache_path = "./embedding_cache.db"
+        
+        # Cross-encoder reranking of the top search candidates ('none' = off, 'http' = reranking
+        # server speaking the 'tei' (/rerank) or 'cohere' (/v1/rerank) API)
+        self.reranker_backend = "none"
+        self.reranker_url = "http://localhost:8081"
+        self.reranker_model = "BAAI/bge-reranker-base"
+        self.reranker_api = "tei"
+        self.reranker_candidates = 20
+        self.reranker_batch_size = 32
+        self.reranker_timeout = 30.0
+        self.reranker_max_retries = 3
+        
         # Logging settings
         self.log_dir = "./logs"
         self.log_level = "INFO"
+        self.log_format = "text"  # 'text' (colored lines) or 'json' (one object per line), on stderr
         
         # Tree-sitter Queries
         self.QUERIES = {
@@ -193,19 +280,25 @@ class Config:
         # File type configurations
         self.file_types = {
             'cpp': FileTypeConfig(
-                extensions=['.cc', '.cpp', '.h', '.hpp', '.c', '.cxx', '.m', '.mm'],
+                extensions=['.cc', '.cpp', '.h', '.hpp', '.hh', '.cxx', '.m', '.mm'],
                 language='cpp',
                 parser_type='treesitter',
-                description='C/C++/Obj-C source and header files'
+                description='C++/Obj-C source and header files'
+            ),
+            'c': FileTypeConfig(
+                extensions=['.c'],
+                language='c',
+                parser_type='treesitter',
+                description='C source files (headers are indexed as C++)'
             ),
             'python': FileTypeConfig(
-                extensions=['.py'],
+                extensions=['.py', '.pyi'],
                 language='python',
                 parser_type='treesitter',
                 description='Python source files'
             ),
             'javascript': FileTypeConfig(
-                extensions=['.js', '.ts', '.jsx', '.tsx'],
+                extensions=['.js', '.ts', '.jsx', '.tsx', '.mts', '.cts'],
                 language='javascript',
                 parser_type='treesitter',
                 description='JavaScript/TypeScript files'
@@ -224,16 +317,16 @@ class Config:
             ),
             
             # JVM Languages
-            'java': FileTypeConfig(['.java'], 'java', 'treesitter', 'Java source', query_scm=self.QUERIES['java']),
-            'kotlin': FileTypeConfig(['.kt', '.kts'], 'kotlin', 'treesitter', 'Kotlin source', query_scm=self.QUERIES.get('kotlin')),
-            'scala': FileTypeConfig(['.scala'], 'scala', 'treesitter', 'Scala source', query_scm=self.QUERIES.get('scala')),
+            'java': FileTypeConfig(['.java'], 'java', 'treesitter', 'Java source'),
+            'kotlin': FileTypeConfig(['.kt', '.kts'], 'kotlin', 'treesitter', 'Kotlin source'),
+            'scala': FileTypeConfig(['.scala'], 'scala', 'treesitter', 'Scala source'),
             'groovy': FileTypeConfig(['.groovy', '.gradle'], 'groovy', 'treesitter', 'Groovy source', query_scm=self.QUERIES.get('groovy')),
             'clojure': FileTypeConfig(['.clj', '.cljs', '.cljc'], 'clojure', 'treesitter', 'Clojure source', query_scm=self.QUERIES.get('clojure')),
             
             # Systems Programming
-            'go': FileTypeConfig(['.go'], 'go', 'treesitter', 'Go source', query_scm=self.QUERIES['go']),
-            'rust': FileTypeConfig(['.rs'], 'rust', 'treesitter', 'Rust source', query_scm=self.QUERIES['rust']),
-            'zig': FileTypeConfig(['.zig'], 'zig', 'treesitter', 'Zig source', query_scm=self.QUERIES.get('zig')),
+            'go': FileTypeConfig(['.go'], 'go', 'treesitter', 'Go source'),
+            'rust': FileTypeConfig(['.rs'], 'rust', 'treesitter', 'Rust source'),
+            'zig': FileTypeConfig(['.zig'], 'zig', 'treesitter', 'Zig source'),
             'nim': FileTypeConfig(['.nim'], 'nim', 'treesitter', 'Nim source', query_scm=self.QUERIES.get('nim')),
             'd': FileTypeConfig(['.d'], 'd', 'treesitter', 'D source', query_scm=self.QUERIES.get('d')),
             'v': FileTypeConfig(['.v'], 'v', 'treesitter', 'V source', query_scm=self.QUERIES.get('v')),
@@ -244,20 +337,20 @@ class Config:
             'ada': FileTypeConfig(['.adb', '.ads'], 'ada', 'treesitter', 'Ada source', query_scm=self.QUERIES.get('ada')),
             
             # Dynamic Languages
-            'ruby': FileTypeConfig(['.rb'], 'ruby', 'treesitter', 'Ruby source', query_scm=self.QUERIES['ruby']),
-            'php': FileTypeConfig(['.php'], 'php', 'treesitter', 'PHP source', query_scm=self.QUERIES['php']),
-            'lua': FileTypeConfig(['.lua'], 'lua', 'treesitter', 'Lua source', query_scm=self.QUERIES.get('lua')),
+            'ruby': FileTypeConfig(['.rb', '.rake', '.gemspec'], 'ruby', 'treesitter', 'Ruby source'),
+            'php': FileTypeConfig(['.php'], 'php', 'treesitter', 'PHP source'),
+            'lua': FileTypeConfig(['.lua'], 'lua', 'treesitter', 'Lua source'),
             'perl': FileTypeConfig(['.pl', '.pm'], 'perl', 'treesitter', 'Perl source', query_scm=self.QUERIES.get('perl')),
-            'elixir': FileTypeConfig(['.ex', '.exs'], 'elixir', 'treesitter', 'Elixir source', query_scm=self.QUERIES.get('elixir')),
+            'elixir': FileTypeConfig(['.ex', '.exs'], 'elixir', 'treesitter', 'Elixir source'),
             'erlang': FileTypeConfig(['.erl', '.hrl'], 'erlang', 'treesitter', 'Erlang source', query_scm=self.QUERIES.get('erlang')),
-            'dart': FileTypeConfig(['.dart'], 'dart', 'treesitter', 'Dart source', query_scm=self.QUERIES.get('dart')),
+            'dart': FileTypeConfig(['.dart'], 'dart', 'treesitter', 'Dart source'),
             
             # .NET
-            'csharp': FileTypeConfig(['.cs'], 'csharp', 'treesitter', 'C# source', query_scm=self.QUERIES['csharp']),
+            'csharp': FileTypeConfig(['.cs'], 'csharp', 'treesitter', 'C# source'),
             'fsharp': FileTypeConfig(['.fs', '.fsx', '.fsi'], 'fsharp', 'treesitter', 'F# source', query_scm=self.QUERIES.get('fsharp')),
             
             # Web/JS Languages
-            'html': FileTypeConfig(['.html', '.htm'], 'html', 'treesitter', 'HTML files', query_scm=self.QUERIES.get('html')),
+            'html': FileTypeConfig(['.html', '.htm', '.tmpl', '.gohtml'], 'html', 'regex', 'HTML files and Go templates'),
             'css': FileTypeConfig(['.css', '.scss', '.sass', '.less'], 'css', 'treesitter', 'CSS files', query_scm=self.QUERIES.get('css')),
             'typescript': FileTypeConfig(['.ts'], 'typescript', 'treesitter', 'TypeScript files', query_scm=self.QUERIES.get('typescript')),
             'tsx': FileTypeConfig(['.tsx'], 'tsx', 'treesitter', 'TSX files', query_scm=self.QUERIES.get('tsx')),
@@ -266,21 +359,21 @@ class Config:
             'astro': FileTypeConfig(['.astro'], 'astro', 'treesitter', 'Astro files', query_scm=self.QUERIES.get('astro')),
             
             # Data/Config
-            'json': FileTypeConfig(['.json'], 'json', 'treesitter', 'JSON files', query_scm=self.QUERIES.get('json')),
-            'yaml': FileTypeConfig(['.yaml', '.yml'], 'yaml', 'treesitter', 'YAML files', query_scm=self.QUERIES.get('yaml')),
+            'json': FileTypeConfig(['.json'], 'json', 'regex', 'JSON files'),
+            'yaml': FileTypeConfig(['.yaml', '.yml'], 'yaml', 'regex', 'YAML files'),
             'toml': FileTypeConfig(['.toml'], 'toml', 'treesitter', 'TOML files', query_scm=self.QUERIES.get('toml')),
             'xml': FileTypeConfig(['.xml'], 'xml', 'treesitter', 'XML files', query_scm=self.QUERIES.get('xml')),
             'graphql': FileTypeConfig(['.graphql', '.gql'], 'graphql', 'treesitter', 'GraphQL files', query_scm=self.QUERIES.get('graphql')),
             'csv': FileTypeConfig(['.csv'], 'csv', 'treesitter', 'CSV files', query_scm=self.QUERIES.get('csv')),
             
             # Query/Protocols
-            'sql': FileTypeConfig(['.sql'], 'sql', 'treesitter', 'SQL files', query_scm=self.QUERIES.get('sql')),
-            'protobuf': FileTypeConfig(['.proto'], 'protobuf', 'treesitter', 'Protocol Buffer files', query_scm=self.QUERIES.get('protobuf')),
+            'sql': FileTypeConfig(['.sql'], 'sql', 'treesitter', 'SQL files'),
+            'protobuf': FileTypeConfig(['.proto'], 'protobuf', 'treesitter', 'Protocol Buffer files'),
             'thrift': FileTypeConfig(['.thrift'], 'thrift', 'treesitter', 'Thrift files', query_scm=self.QUERIES.get('thrift')),
             'capnp': FileTypeConfig(['.capnp'], 'capnp', 'treesitter', 'Cap n Proto files', query_scm=self.QUERIES.get('capnp')),
             
             # Scripts/Build/Config
-            'bash': FileTypeConfig(['.sh', '.bash'], 'bash', 'treesitter', 'Shell scripts', query_scm=self.QUERIES.get('bash')),
+            'bash': FileTypeConfig(['.sh', '.bash'], 'bash', 'treesitter', 'Shell scripts'),
             'dockerfile': FileTypeConfig(['Dockerfile', '.dockerfile'], 'dockerfile', 'treesitter', 'Docker files', query_scm=self.QUERIES.get('dockerfile')),
             'terraform': FileTypeConfig(['.tf', '.tfvars'], 'terraform', 'treesitter', 'Terraform files', query_scm=self.QUERIES.get('terraform')),
             'hcl': FileTypeConfig(['.hcl'], 'hcl', 'treesitter', 'HCL files', query_scm=self.QUERIES.get('hcl')),
@@ -294,7 +387,7 @@ class Config:
             
             'haskell': FileTypeConfig(['.hs'], 'haskell', 'treesitter', 'Haskell source', query_scm=self.QUERIES.get('haskell')),
             'ocaml': FileTypeConfig(['.ml', '.mli'], 'ocaml', 'treesitter', 'OCaml source', query_scm=self.QUERIES.get('ocaml')),
-            'swift': FileTypeConfig(['.swift'], 'swift', 'treesitter', 'Swift source', query_scm=self.QUERIES.get('swift')),
+            'swift': FileTypeConfig(['.swift'], 'swift', 'treesitter', 'Swift source'),
             'elm': FileTypeConfig(['.elm'], 'elm', 'treesitter', 'Elm source', query_scm=self.QUERIES.get('elm')),
             'purescript': FileTypeConfig(['.purs'], 'purescript', 'treesitter', 'PureScript source', query_scm=self.QUERIES.get('purescript')),
             'racket': FileTypeConfig(['.rkt'], 'racket', 'treesitter', 'Racket source', query_scm=self.QUERIES.get('racket')),
@@ -302,7 +395,7 @@ class Config:
             'commonlisp': FileTypeConfig(['.lisp', '.cl'], 'commonlisp', 'treesitter', 'Common Lisp source', query_scm=self.QUERIES.get('commonlisp')),
             'reasonml': FileTypeConfig(['.re', '.rei'], 'reasonml', 'treesitter', 'ReasonML source', query_scm=self.QUERIES.get('reasonml')),
             
-            'markdown': FileTypeConfig(['.md', '.markdown'], 'markdown', 'treesitter', 'Markdown files', query_scm=self.QUERIES.get('markdown')),
+            'markdown': FileTypeConfig(['.md', '.markdown', '.mdx'], 'markdown', 'regex', 'Markdown and MDX documents'),
             'rst': FileTypeConfig(['.rst'], 'rst', 'treesitter', 'reStructuredText files', query_scm=self.QUERIES.get('rst')),
             'latex': FileTypeConfig(['.tex'], 'latex', 'treesitter', 'LaTeX files', query_scm=self.QUERIES.get('latex')),
             'org': FileTypeConfig(['.org'], 'org', 'treesitter', 'Org-mode files', query_scm=self.QUERIES.get('org')),
@@ -319,17 +412,291 @@ class Config:
             'solidity': FileTypeConfig(['.sol'], 'solidity', 'treesitter', 'Solidity files', query_scm=self.QUERIES.get('solidity')),
         }
         
+        # Languages of files the table above gets wrong or leaves out (see utils/file_languages.py).
+        # extension_languages maps an extension or a file name to a language, in place of the
+        # table ({'.gohtml': 'html', '.inc': 'cpp', 'Jenkinsfile': 'groovy'}); path_languages
+        # maps glob patterns on the path under the indexed root to a language, winning over
+        # extensions ({'third_party/legacy/*.inc': 'c'}). An extensionless file whose #! line
+        # runs one of shebang_languages is indexed as that language. Files nothing maps are
+        # skipped unless text_fallback (below) is on.
+        self.extension_languages = {}
+        self.path_languages = {}
+        self.shebang_languages = {
+            'sh': 'bash', 'bash': 'bash', 'dash': 'bash', 'ksh': 'bash', 'zsh': 'bash',
+            'python': 'python', 'pypy': 'python',
+            'node': 'javascript', 'nodejs': 'javascript', 'deno': 'javascript',
+            'ruby': 'ruby', 'perl': 'perl', 'php': 'php', 'lua': 'lua', 'fish': 'fish',
+            'pwsh': 'powershell', 'tclsh': 'tcl', 'Rscript': 'r', 'julia': 'julia',
+        }
+        
+        # Content detection (off unless enabled): files none of the above maps, whatever their
+        # extension, get the language their text points to (a #! line, an Emacs or vim modeline,
+        # else constructs of a language such as 'package main' or 'def f():'), with a confidence
+        # from 0 to 1 recorded in their chunks' metadata (detected_language, language_confidence).
+        # Below language_confidence they are indexed as plain text; binary files are still skipped
+        self.detect_languages = False
+        self.language_confidence = 0.5
+        
+        # Plain-text fallback (off unless enabled): files nothing maps (as language 'text') and
+        # files their parser finds nothing in are indexed as overlapping blocks of lines within
+        # max_tokens (chunks/kind 'text', see chunkers/text_chunker.py), which searches can
+        # select with the 'text' kind or leave out with exclude_text
+        self.text_fallback = False
+        
         # Indexing settings
         self.max_chunk_size = 8000
         self.min_chunk_size = 50
         
-        # Directories to exclude
+        # Token budget per chunk; larger symbols are split into overlapping parts
+        # (counted with the tokenizer of the embedding model, via tiktoken if installed)
+        self.max_tokens = 512
+        self.token_overlap = 64
+        self.tokenizer_encoding = 'cl100k_base'
+        
+        # Where split parts and plain-text blocks start their overlap with the one before:
+        # 'fixed_token' (the trailing lines that fit token_overlap), 'line_aligned' (the
+        # whole lines closest to it) or 'semantic' (from the nearest statement or block
+        # start, with parts ending after a complete statement; see chunkers/token_splitter.py)
+        self.overlap_strategy = 'fixed_token'
+        
+        # What one chunk covers: 'file', 'symbol' or 'symbol_with_imports'
+        # (see chunkers/granularity.py)
+        self.granularity = 'symbol'
+        
+        # Functions and methods shorter than this many lines, or this many tokens of code,
+        # are left out of the index (one-line getters, empty stubs); 0 keeps them all
+        self.min_chunk_lines = 0
+        self.min_chunk_tokens = 0
+        
+        # Symbols left out of the index by qualified name (User.String, init), as fnmatch
+        # globs: ['*.String', 'Test*', 'init'] drops stringers, tests and init functions. A
+        # split symbol goes with all its parts; whole-file chunks and text blocks are kept
+        self.exclude_symbols = []
+        
+        # Chunk processors run on every chunk, in order, before it is embedded (see
+        # utils/chunk_processors.py): 'codeowners' adds metadata['owners'] from the root's
+        # CODEOWNERS file, 'package.module:Class' loads a ChunkProcessor of your own
+        self.chunk_processors = []
+        
+        # Per-language overrides of max_tokens, token_overlap, overlap_strategy, granularity,
+        # min_chunk_lines and min_chunk_tokens; languages not listed (and keys left out) use the
+        # values above or the command line's, e.g.
+        # {'markdown': {'max_tokens': 1024, 'token_overlap': 128}, 'go': {'granularity': 'symbol'}}
+        self.language_chunking = {}
+        
+        # What chunk vectors are computed from (the code is always stored and displayed):
+        # 'code'; 'doc' (doc comment + signature of documented symbols); 'signature'
+        # (doc comment + signature of every symbol, never the body); or 'dual' (code and
+        # signature vectors in two collections, searched together unless one is chosen).
+        # An existing index keeps the mode it was built with.
+        self.embedding_mode = 'code'
+        
+        # Chunks with identical bodies (vendored or generated copies): 'off' stores each one;
+        # 'exact' or 'normalized' (ignoring whitespace and comments) embed and store one copy
+        # that lists where else its body appears. An existing index keeps the mode it was built with.
+        self.dedup = 'off'
+        
+        # Similar code (similar_to, `similar`, POST /similar): results at least this similar to the
+        # chunk they were found for are near-duplicates, copy-paste candidates to refactor rather
+        # than related implementations
+        self.near_duplicate_similarity = 0.95
+        
+        # Normalization of code before it is embedded and hashed for dedup (the stored and
+        # displayed code is unchanged): 'off'; 'whitespace' (trailing whitespace, blank-line
+        # runs and indentation, and a gofmt-like layout for Go); or 'comments' (also drops
+        # comments, so docs no longer steer the vector). Per-language overrides, e.g.
+        # {'go': 'comments', 'markdown': 'off'}. An existing index keeps what it was built with.
+        self.code_normalization = 'off'
+        self.language_code_normalization = {}
+        
+        # Text sent to the embedder, recorded with the index: the document template formats what
+        # a chunk is embedded from, with {language}, {kind} (chunk type), {symbol} (qualified
+        # name), {doc}, {signature} and {body} (the code, or the doc and signature the embedding
+        # mode picks), and the query template a search's text, with {query}. Models trained with
+        # task prefixes want them: 'search_document: {body}' and 'search_query: {query}' for
+        # nomic-embed-text. The defaults send the text unchanged.
+        self.embedding_document_template = '{body}'
+        self.embedding_query_template = '{query}'
+        
+        # Hybrid search: share of the fused score given to BM25 (0 = vector only, 1 = keyword only)
+        # and how the vector and keyword rankings are fused (utils/score_fusion.py): 'rrf',
+        # reciprocal rank fusion with the constant rrf_k, or 'weighted', a weighted sum of their
+        # scores brought to 0-1 by fusion_normalization ('minmax' over each search's candidates,
+        # 'max', or 'absolute', the scale relevance and min_score use)
+        self.hybrid_lexical_weight = 0.5
+        self.fusion_method = 'rrf'
+        self.rrf_k = 60
+        self.fusion_normalization = 'minmax'
+        
+        # Keyword search: how many times the tokens of each field of a chunk count in its BM25
+        # document (whole numbers, 0 leaves the field out). 'name' is the symbol name and the
+        # names aliased to it, 'doc' the doc comment, 'body' the code; weighing names higher
+        # ranks SessionManager above a function that only mentions a session in a comment.
+        self.keyword_field_weights = {'name': 3, 'doc': 1, 'body': 1}
+        
+        # Keyword search: how identifiers are split into words (utils/code_tokenizer.py).
+        # split_case (CreateSession), split_underscores (create_session, MAX_RETRIES) and
+        # split_digits (parseURL2) choose the boundaries; kebab_case makes admin-user one
+        # identifier (on for YAML, JSON, TOML, CSS, HTML, Markdown, text and shell); keep_whole
+        # also indexes the joined identifier; words shorter than min_length, or among stopwords
+        # ('english' for a built-in list), are left out. language_tokenizer_rules overrides them
+        # per language, e.g. {'go': {'min_length': 2}}; queries take tokenizer_rules alone.
+        # The keyword index is rebuilt from the stored chunks, so changes apply on the next start.
+        self.tokenizer_rules = {}
+        self.language_tokenizer_rules = {}
+        
+        # Query expansion (off unless requested): adds the joined forms of adjacent query words
+        # (sign in -> signin, sign_in) and their synonyms (auth, authenticate, authentication)
+        # to the embedded query and, at query_expansion_weight of the query's own terms, to the
+        # keyword query. query_synonyms are extra groups of interchangeable words, merged with
+        # the built-in ones (utils/query_expansion.py) they overlap; query_synonyms_path is a
+        # JSON file of more groups (a list of word lists).
+        self.query_expansion = False
+        self.query_expansion_weight = 0.5
+        self.query_synonyms = []
+        self.query_synonyms_path = None
+        
+        # Relevance threshold: results whose 'relevance' (vector similarity and the matched share
+        # of the query's BM25 weight, blended by the lexical weight) is below it are dropped, and
+        # a search where nothing reaches it returns no results. None keeps the top n regardless.
+        # Starting points for the vector-only score under each distance metric (hybrid search
+        # blends in the BM25 share, so use about 0.7 of these at the default lexical weight):
+        self.min_score = None
+        self.suggested_min_scores = {'cosine': 0.5, 'dot': 0.5, 'l2': 0.5}
+        
+        # MMR diversity reranking (off unless requested): lambda used by --mmr, and how many
+        # candidates per requested result each retriever contributes to the pool
+        self.mmr_lambda = 0.5
+        self.mmr_candidate_factor = 4
+        
+        # Candidates the vector store and the keyword index each return per search, for
+        # reranking, MMR and min_score to narrow down to the requested top k (e.g. fetch 50,
+        # rerank, return 10); at least the top k. None sizes the pool from the top k
+        self.candidate_k = None
+        
+        # Ranking priors (off at 0, so ranking is unchanged unless asked for): the fused score is
+        # divided by 1 + depth_penalty * (directories above the file), ranking internal/ code above
+        # the same match deep in third_party/, and multiplied by 1 + recency_weight * freshness,
+        # where freshness is 1.0 for a file modified just now and halves every
+        # recency_half_life_days. recency_source is where indexing reads each file's last
+        # modification: 'mtime' or 'git' (its last commit; files without one use their mtime).
+        self.depth_penalty = 0.0
+        self.recency_weight = 0.0
+        self.recency_half_life_days = 90.0
+        self.recency_source = 'mtime'
+        
+        # Record who last changed each chunk, from git blame of its file when indexing a git
+        # work tree: the most recent commit among its lines (SHA, author, email, date, summary)
+        # as its 'last_change' metadata, for the author and commit filter fields. Off by
+        # default, since blame runs a git process per file and walks its history.
+        self.git_blame = False
+        
+        # Fused score factor of chunks tagged as generated code (1.0 ranks them like the rest;
+        # 0.5 halves their score without leaving them out)
+        self.generated_weight = 1.0
+        
+        # Saved searches, by name: a query template with {placeholders}, the defaults of its
+        # params and the /search fields it sets, e.g. {'error-handling': {'query': 'error handling
+        # in {package}', 'params': {'package': 'auth'}, 'top_k': 10, 'languages': ['go']}} (see
+        # utils/saved_searches.py)
+        self.saved_searches = {}
+        
+        # Cache of ranked search results (served until the index changes or ttl seconds pass;
+        # size 0 disables it, ttl 0 keeps entries until evicted)
+        self.query_cache_size = 256
+        self.query_cache_ttl = 300.0
+        
+        # Paged searches (retrieve_page, /search with an offset or a cursor): the most results
+        # one ranking holds for its pages, and how many rankings are kept for their cursors
+        # (they expire with query_cache_ttl; an evicted one is ranked again when paged)
+        self.page_depth = 200
+        self.page_cache_size = 32
+        
+        # Result limits (see utils/result_limits.py): searches asking for more results than
+        # max_top_k, or more candidates per retriever (candidate_k, rerank_candidates) than
+        # max_candidate_k, are rejected (a 400 from the servers, a ResultLimitError from the
+        # library), and so is an HTTP response body above max_response_bytes. 0 disables a
+        # limit. A paged search holds its page size to max_top_k, not the ranking of up to
+        # page_depth results its pages come from
+        self.max_top_k = 100
+        self.max_candidate_k = 1000
+        self.max_response_bytes = 16 * 1024 * 1024
+        
+        # Lines of code per /search result when a request does not set snippet_lines: a snippet
+        # around the result's matches, with a reference to GET /chunk for the whole chunk
+        # (None: whole chunks)
+        self.snippet_lines = None
+        
+        # Warm-up when a server starts (see utils/warmup.py): one probe embedding and one
+        # query per vector collection, so the first search does not pay for loading the
+        # index and reaching the embedder, then warmup_query as a sample search (None: none).
+        # The HTTP server's /health answers 503 until it is done
+        self.warmup = True
+        self.warmup_query = None
+        
+        # Surrounding context of search results (on request), re-read from the indexed files:
+        # the directory they are under (None: the directory last indexed into the collection)
+        # and the most lines of an enclosing type declaration shown
+        self.source_root = None
+        self.surrounding_max_lines = 30
+        
+        # Directories to exclude (the built-in ignore list; .gitignore rules apply on top)
         self.exclude_dirs = {
-            'third_party', 'out', 'build', '.git', '.svn', '.hg',
+            'third_party', 'out', 'build', 'dist', 'vendor', '.git', '.svn', '.hg',
             '__pycache__', 'node_modules', 'venv', 'env',
             'test', 'tests', 'testing'
         }
         
+        # Directories index and update read when no --path is given ([LABEL=]PATH, as --path),
+        # and gitignore-style patterns skipped on top of --ignore; usually set by a config file
+        self.index_roots = []
+        self.ignore_patterns = []
+        
+        # Secrets in indexed code (hardcoded keys, tokens, passwords, PEM private keys and
+        # high-entropy string literals): 'off' indexes chunks as they are; 'redact' replaces
+        # each secret with a [REDACTED:kind] marker before embedding and storage; 'skip'
+        # leaves chunks holding one out of the index. The files themselves are not touched.
+        # String literals mixing letters and digits, at least secret_min_length long, whose
+        # entropy (bits per character) reaches secret_min_entropy for their alphabet count as secrets.
+        self.secret_scan = 'off'
+        self.secret_min_length = 20
+        self.secret_min_entropy = {'base64': 4.0, 'hex': 3.0}
+        
+        # Generated code (a "// Code generated ... DO NOT EDIT." Go header, an @generated or
+        # <auto-generated> header comment, or a generator's file name such as *.pb.go or
+        # *_pb2.py; see utils.generated_code): 'tag' indexes it with generated=true, 'skip'
+        # leaves the files out during discovery, 'off' indexes them like hand-written code.
+        # generated_patterns are extra globs of generated files (with a '/', of the path).
+        self.generated_code = 'tag'
+        self.generated_patterns = []
+        
+        # Parser processes for parallel indexing (0 = one per CPU)
+        self.parse_workers = 0
+        
+        # Files larger than this are skipped during discovery, with a warning (0 = no limit).
+        # large_files 'stream' indexes them instead as plain-text blocks read from disk a
+        # window at a time, since language parsers need the whole file (and several times
+        # its size) in memory; files over max_stream_file_bytes are still skipped (0 = no limit)
+        self.max_file_bytes = 1024 * 1024
+        self.large_files = 'skip'
+        self.max_stream_file_bytes = 256 * 1024 * 1024
+        
+        # Watch mode (update --watch): seconds without file events before the changed files
+        # are re-indexed, so a burst of saves (or an editor's atomic save) is one update
+        self.watch_debounce = 0.5
+        
+        # Go build constraints: files whose file name or //go:build lines exclude the target
+        # GOOS/GOARCH are skipped during discovery (None = that dimension is not filtered);
+        # go_build_tags are extra satisfied tags. _test.go chunks are stored with kind 'test'.
+        self.go_goos = None
+        self.go_goarch = None
+        self.go_build_tags = []
+        self.go_include_tests = True
+        
+        # One summary chunk per Go package: its doc comment and exported API (types with
+        # their methods, functions, constants, variables), for "what does package X do"
+        self.go_package_summaries = True
+        
         # Progress tracking
         self.progress_update_interval = 10
     
@@ -341,7 +708,9 @@ class Config:
         return extensions
     
     def get_language_for_extension(self, extension: str) -> str:
-        """Get language name for a file extension"""
+        """Get language name for a file extension (or file name), extension_languages first"""
+        if extension in self.extension_languages:
+            return self.extension_languages[extension]
         for lang, ft_config in self.file_types.items():
             if extension in ft_config.extensions:
                 return lang
@@ -0,0 +1,118 @@
+"""
+Embedders package: pluggable backends that turn chunk text into vectors
+"""
+
+from typing import Dict, Optional
+
+from .base_embedder import Embedder, EmbeddingError, PartialEmbeddingError, RateLimitError
+from .cached_embedder import CachedEmbedder
+from .default_embedder import DefaultEmbedder
+from .keyword_only_embedder import KEYWORD_ONLY_MODEL, KeywordOnlyEmbedder
+from .ollama_embedder import OllamaEmbedder
+from .rate_limited_embedder import RateLimitedEmbedder, RateLimiter
+
+# Keys of an extra embedding model's spec in CONFIG.embedding_models
+MODEL_SPEC_KEYS = ('backend', 'model', 'url')
+
+
+def create_embedder(backend: Optional[str] = None, model_name: Optional[str] = None,
+                    base_url: Optional[str] = None, cache_path: Optional[str] = None,
+                    requests_per_minute: Optional[int] = None,
+                    max_concurrent: Optional[int] = None) -> Embedder:
+    """
+    Build an embedder from its backend name (defaults come from CONFIG)
+    
+    Args:
+        backend: 'default' (bundled ONNX model), 'ollama' or 'none' (keyword-only index)
+        model_name: Optional model override
+        base_url: Optional server URL (ollama)
+        cache_path: Optional SQLite file caching vectors by model and text
+        requests_per_minute: Requests per minute to an HTTP backend (0: no limit)
+        max_concurrent: Requests in flight at once to an HTTP backend
+    """
+    embedder = _create_backend(backend, model_name, base_url, requests_per_minute, max_concurrent)
+    if isinstance(embedder, KeywordOnlyEmbedder):
+        return embedder  # nothing worth caching
+    # Cache hits never reach the rate limiter
+    return CachedEmbedder(embedder, cache_path) if cache_path else embedder
+
+
+def _create_backend(backend: Optional[str], model_name: Optional[str], base_url: Optional[str],
+                    requests_per_minute: Optional[int], max_concurrent: Optional[int]) -> Embedder:
+    from config import CONFIG
+    
+    backend = backend or CONFIG.embedding_backend
+    if backend == 'default':
+        return DefaultEmbedder(batch_size=CONFIG.embedding_batch_size)
+    if backend == 'none':
+        return KeywordOnlyEmbedder(batch_size=CONFIG.embedding_batch_size)
+    if backend == 'ollama':
+        embedder = OllamaEmbedder(
+            model_name=model_name or CONFIG.ollama_model,
+            base_url=base_url or CONFIG.ollama_base_url,
+            batch_size=CONFIG.embedding_batch_size,
+            timeout=CONFIG.ollama_timeout,
+            max_retries=CONFIG.ollama_max_retries
+        )
+        return RateLimitedEmbedder(
+            embedder,
+            requests_per_minute=CONFIG.embedding_requests_per_minute if requests_per_minute is None
+            else requests_per_minute,
+            max_concurrent=max_concurrent or CONFIG.embedding_max_concurrency,
+            max_retries=CONFIG.embedding_max_retries,
+            backoff=CONFIG.embedding_backoff,
+            max_backoff=CONFIG.embedding_max_backoff
+        )
+    raise ValueError(f"Unknown embedding backend: {backend}")
+
+
+def create_model_embedders(models: Optional[Dict[str, Dict]] = None,
+                            cache_path: Optional[str] = None) -> Dict[str, Embedder]:
+    """
+    Extra embedders by name, from their specs (defaults to CONFIG.embedding_models)
+    
+    Args:
+        models: {name: {'backend', 'model', 'url'}}; keys left out take the
+            defaults of create_embedder
+        cache_path: Optional SQLite file caching vectors by model and text
+    """
+    from config import CONFIG
+    
+    models = CONFIG.embedding_models if models is None else models
+    embedders = {}
+    for name, spec in models.items():
+        unknown = sorted(set(spec) - set(MODEL_SPEC_KEYS))
+        if unknown:
+            raise ValueError(f"Embedding model '{name}': unknown key {', '.join(unknown)} "
+                             f"(expected: {', '.join(MODEL_SPEC_KEYS)})")
+        embedders[name] = create_embedder(backend=spec.get('backend'), model_name=spec.get('model'),
+                                          base_url=spec.get('url'), cache_path=cache_path)
+    return embedders
+
+
+def find_embedder(embedder: Embedder, kind: type) -> Optional[Embedder]:
+    """The embedder of a class among an embedder and the ones it wraps (.embedder), or None"""
+    while embedder is not None:
+        if isinstance(embedder, kind):
+            return embedder
+        embedder = getattr(embedder, 'embedder', None)
+    return None
+
+
+__all__ = [
+    'Embedder',
+    'EmbeddingError',
+    'PartialEmbeddingError',
+    'RateLimitError',
+    'CachedEmbedder',
+    'DefaultEmbedder',
+    'KEYWORD_ONLY_MODEL',
+    'MODEL_SPEC_KEYS',
+    'KeywordOnlyEmbedder',
+    'OllamaEmbedder',
+    'RateLimitedEmbedder',
+    'RateLimiter',
+    'create_embedder',
+    'create_model_embedders',
+    'find_embedder',
+]
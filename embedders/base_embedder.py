@@ -0,0 +1,77 @@
+#!/usr/bin/env python3
+"""
+Base embedder class defining the interface for all embedding backends
+"""
+
+from abc import ABC, abstractmethod
+from typing import Iterator, List, Optional, Tuple
+
+
+class EmbeddingError(Exception):
+    """Raised when an embedding backend cannot produce vectors"""
+
+
+class RateLimitError(EmbeddingError):
+    """The backend refused a request for now (HTTP 429): retry after a while"""
+    
+    def __init__(self, message: str, retry_after: Optional[float] = None):
+        """
+        Args:
+            message: What the backend said
+            retry_after: Seconds the backend asked to wait (its Retry-After), if it said
+        """
+        super().__init__(message)
+        self.retry_after = retry_after
+
+
+class PartialEmbeddingError(EmbeddingError):
+    """Some texts of a call could not be embedded; the others were"""
+    
+    def __init__(self, vectors: List[Optional[List[float]]], failures: List[Tuple[int, str]]):
+        """
+        Args:
+            vectors: One entry per input text: its vector, or None if it failed
+            failures: (index of the text, error message) of each failed text
+        """
+        super().__init__(f"{len(failures)} of {len(vectors)} texts could not be embedded: {failures[0][1]}")
+        self.vectors = vectors
+        self.failures = failures
+
+
+class Embedder(ABC):
+    """Abstract base class for all embedding backends"""
+    
+    def __init__(self, model_name: str, batch_size: int = 32):
+        """
+        Args:
+            model_name: Identifier of the embedding model (stored with the index)
+            batch_size: Maximum number of texts sent to the backend per call
+        """
+        self.model_name = model_name
+        self.batch_size = max(1, batch_size)
+    
+    @abstractmethod
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        """
+        Embed a batch of texts
+        
+        Args:
+            texts: Texts to embed
+        
+        Returns:
+            One vector per text, in input order
+        """
+        pass
+    
+    @abstractmethod
+    def dimensions(self) -> int:
+        """Length of the vectors produced by this embedder"""
+        pass
+    
+    def _batches(self, texts: List[str]) -> Iterator[List[str]]:
+        """Split texts into backend-sized batches"""
+        for start in range(0, len(texts), self.batch_size):
+            yield texts[start:start + self.batch_size]
+    
+    def __repr__(self) -> str:
+        return f"{self.__class__.__name__}(model={self.model_name!r})"
@@ -0,0 +1,125 @@
+#!/usr/bin/env python3
+"""
+Content-addressed embedding cache
+Vectors are stored on disk (SQLite) keyed by model and normalized text, so
+re-indexing unchanged code costs no embedding calls
+"""
+
+import hashlib
+import sqlite3
+import threading
+from array import array
+from typing import Dict, List
+
+from .base_embedder import Embedder, PartialEmbeddingError
+
+
+def normalize_text(text: str) -> str:
+    """Text as cached: unified line endings, no trailing whitespace"""
+    lines = text.replace('\r\n', '\n').replace('\r', '\n').split('\n')
+    return '\n'.join(line.rstrip() for line in lines).strip()
+
+
+def cache_key(model_name: str, text: str) -> str:
+    """Cache key of a text for one model"""
+    return hashlib.sha256(f"{model_name}\0{normalize_text(text)}".encode('utf-8')).hexdigest()
+
+
+class CachedEmbedder(Embedder):
+    """Wraps an embedder and only forwards texts it has not embedded before"""
+    
+    def __init__(self, embedder: Embedder, cache_path: str = "embedding_cache.db"):
+        """
+        Args:
+            embedder: Backend that embeds cache misses
+            cache_path: SQLite file holding the cached vectors
+        """
+        super().__init__(embedder.model_name, embedder.batch_size)
+        self.embedder = embedder
+        self.cache_path = cache_path
+        self.hits = 0
+        self.misses = 0
+        self._lock = threading.Lock()
+        
+        with sqlite3.connect(self.cache_path) as conn:
+            conn.execute("""
+                CREATE TABLE IF NOT EXISTS embeddings (
+                    key TEXT PRIMARY KEY,
+                    model TEXT,
+                    vector BLOB
+                )
+            """)
+    
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        """
+        Embed texts, answering repeats from the cache and batching the rest
+        
+        Raises:
+            PartialEmbeddingError: If the backend failed some texts; the vectors it
+                did produce are cached all the same
+        """
+        keys = [cache_key(self.model_name, text) for text in texts]
+        cached = self._load(set(keys))
+        
+        # Each missing text is embedded once, however often it repeats
+        missing: Dict[str, str] = {}
+        for key, text in zip(keys, texts):
+            if key not in cached and key not in missing:
+                missing[key] = text
+        
+        errors: Dict[str, str] = {}
+        if missing:
+            try:
+                vectors = self.embedder.embed(list(missing.values()))
+            except PartialEmbeddingError as e:
+                vectors = e.vectors
+                failed = list(missing.keys())
+                errors = {failed[index]: message for index, message in e.failures}
+            fresh = {key: vector for key, vector in zip(missing.keys(), vectors) if vector is not None}
+            self._store(fresh)
+            cached.update(fresh)
+        
+        with self._lock:
+            self.misses += len(missing)
+            self.hits += len(texts) - len(missing)
+        if errors:
+            # Failures by position in texts, repeats included
+            raise PartialEmbeddingError([cached.get(key) for key in keys],
+                                        [(i, errors[key]) for i, key in enumerate(keys) if key in errors])
+        return [cached[key] for key in keys]
+    
+    def dimensions(self) -> int:
+        return self.embedder.dimensions()
+    
+    def reset_stats(self):
+        """Zero the hit and miss counters (e.g. at the start of an indexing run)"""
+        with self._lock:
+            self.hits = 0
+            self.misses = 0
+    
+    def _load(self, keys) -> Dict[str, List[float]]:
+        if not keys:
+            return {}
+        found = {}
+        keys = list(keys)
+        with sqlite3.connect(self.cache_path) as conn:
+            # Stay under SQLite's bound-parameter limit
+            for start in range(0, len(keys), 500):
+                chunk = keys[start:start + 500]
+                rows = conn.execute(
+                    f"SELECT key, vector FROM embeddings WHERE key IN ({','.join('?' * len(chunk))})",
+                    chunk
+                )
+                for key, blob in rows:
+                    found[key] = array('d', blob).tolist()
+        return found
+    
+    def _store(self, vectors: Dict[str, List[float]]):
+        with sqlite3.connect(self.cache_path) as conn:
+            conn.executemany(
+                "INSERT OR REPLACE INTO embeddings (key, model, vector) VALUES (?, ?, ?)",
+                [(key, self.model_name, array('d', vector).tobytes()) for key, vector in vectors.items()]
+            )
+    
+    def __repr__(self) -> str:
+        return f"{self.embedder!r} (cached)"
@@ -0,0 +1,35 @@
+#!/usr/bin/env python3
+"""
+Default embedder: ChromaDB's bundled all-MiniLM-L6-v2 model (runs locally via ONNX)
+"""
+
+from typing import List, Optional
+
+from .base_embedder import Embedder
+
+
+class DefaultEmbedder(Embedder):
+    """Wraps chromadb's DefaultEmbeddingFunction"""
+    
+    def __init__(self, batch_size: int = 32):
+        super().__init__('all-MiniLM-L6-v2', batch_size)
+        self._function = None
+        self._dimensions: Optional[int] = None
+    
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        """Embed texts with the bundled ONNX model"""
+        if self._function is None:
+            # Loaded lazily: the model is downloaded on first use
+            from chromadb.utils import embedding_functions
+            self._function = embedding_functions.DefaultEmbeddingFunction()
+        
+        vectors = []
+        for batch in self._batches(texts):
+            vectors.extend([float(x) for x in vector] for vector in self._function(batch))
+        return vectors
+    
+    def dimensions(self) -> int:
+        """Vector length, probed once"""
+        if self._dimensions is None:
+            self._dimensions = len(self.embed(['dimension probe'])[0])
+        return self._dimensions
@@ -0,0 +1,32 @@
+#!/usr/bin/env python3
+"""
+Keyword-only "embedder": builds an index searched by BM25 alone, without any embedding model
+"""
+
+from typing import List
+
+from .base_embedder import Embedder
+
+
+# Model name a keyword-only index is recorded with
+KEYWORD_ONLY_MODEL = 'none'
+
+
+class KeywordOnlyEmbedder(Embedder):
+    """
+    Gives every text the same one-dimensional placeholder vector
+    
+    Vector stores keep a vector per chunk, so a keyword-only index stores this
+    placeholder; searches of an index recorded with KEYWORD_ONLY_MODEL skip
+    the vector component and rank by keyword matches only.
+    """
+    
+    def __init__(self, batch_size: int = 32):
+        super().__init__(KEYWORD_ONLY_MODEL, batch_size)
+    
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        """The placeholder vector, once per text"""
+        return [[1.0] for _ in texts]
+    
+    def dimensions(self) -> int:
+        return 1
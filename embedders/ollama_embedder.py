@@ -0,0 +1,162 @@
+#!/usr/bin/env python3
+"""
+Ollama embedder: embeddings from a local Ollama server (fully offline indexing)
+Uses the batched /api/embed endpoint, falling back to /api/embeddings
+(one text per request) on servers that predate it
+"""
+
+import datetime
+import email.utils
+import json
+import time
+import urllib.request
+import urllib.error
+from typing import List, Optional
+
+from .base_embedder import Embedder, EmbeddingError, RateLimitError
+
+
+# HTTP statuses worth retrying: the server is up but busy or restarting
+RETRYABLE_STATUSES = {500, 502, 503, 504}
+
+
+class OllamaEmbedder(Embedder):
+    """Embeds texts with a model served by Ollama (e.g. nomic-embed-text)"""
+    
+    def __init__(self, model_name: str = 'nomic-embed-text', base_url: str = 'http://localhost:11434',
+                 batch_size: int = 32, timeout: float = 60.0, max_retries: int = 3,
+                 backoff: float = 0.5):
+        """
+        Args:
+            model_name: Ollama model tag
+            base_url: Ollama server URL
+            batch_size: Texts per request
+            timeout: Per-request timeout in seconds
+            max_retries: Retries for connection errors and 5xx responses
+            backoff: Initial retry delay in seconds (doubles on each retry)
+        """
+        super().__init__(model_name, batch_size)
+        self.base_url = base_url.rstrip('/')
+        self.timeout = timeout
+        self.max_retries = max_retries
+        self.backoff = backoff
+        self._dimensions: Optional[int] = None
+        self._batch_endpoint = True  # cleared if the server lacks /api/embed
+    
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        """Embed texts in batches of batch_size"""
+        vectors = []
+        for batch in self._batches(texts):
+            vectors.extend(self._embed_batch(batch))
+        
+        if vectors and self._dimensions is None:
+            self._dimensions = len(vectors[0])
+        return vectors
+    
+    def dimensions(self) -> int:
+        """Vector length, found with a probe embedding on first use"""
+        if self._dimensions is None:
+            self._dimensions = len(self._embed_batch(['dimension probe'])[0])
+        return self._dimensions
+    
+    def _embed_batch(self, batch: List[str]) -> List[List[float]]:
+        """Embed one batch, with the batched endpoint when available"""
+        if self._batch_endpoint:
+            try:
+                response = self._post('/api/embed', {'model': self.model_name, 'input': batch})
+                vectors = response.get('embeddings') or []
+                if len(vectors) != len(batch):
+                    raise EmbeddingError(
+                        f"Ollama returned {len(vectors)} embeddings for {len(batch)} texts"
+                    )
+                return vectors
+            except _EndpointMissing:
+                self._batch_endpoint = False
+        
+        vectors = []
+        for text in batch:
+            try:
+                response = self._post('/api/embeddings', {'model': self.model_name, 'prompt': text})
+            except _EndpointMissing as e:
+                raise EmbeddingError(f"{self.base_url} does not serve Ollama embedding endpoints") from e
+            vector = response.get('embedding')
+            if not vector:
+                raise EmbeddingError(f"Ollama returned no embedding for model '{self.model_name}'")
+            vectors.append(vector)
+        return vectors
+    
+    def _post(self, path: str, payload: dict) -> dict:
+        """POST a JSON payload, retrying transient failures with exponential backoff"""
+        request_body = json.dumps(payload).encode('utf-8')
+        delay = self.backoff
+        
+        for attempt in range(self.max_retries + 1):
+            request = urllib.request.Request(
+                self.base_url + path,
+                data=request_body,
+                headers={'Content-Type': 'application/json'},
+                method='POST'
+            )
+            try:
+                with urllib.request.urlopen(request, timeout=self.timeout) as response:
+                    return json.loads(response.read().decode('utf-8'))
+            
+            except urllib.error.HTTPError as e:
+                message = self._error_message(e)
+                if e.code == 404 and 'model' in message.lower():
+                    raise EmbeddingError(
+                        f"Ollama model '{self.model_name}' is not available. "
+                        f"Run: ollama pull {self.model_name}"
+                    ) from e
+                if e.code == 404:
+                    raise _EndpointMissing(path) from e
+                if e.code == 429:
+                    # Throttled: the rate limiter in front of the embedder decides when to retry
+                    raise RateLimitError(
+                        f"Ollama throttled the request (429): {message}", retry_after(e.headers)
+                    ) from e
+                if e.code not in RETRYABLE_STATUSES or attempt == self.max_retries:
+                    raise EmbeddingError(f"Ollama request failed ({e.code}): {message}") from e
+            
+            except (urllib.error.URLError, ConnectionError, TimeoutError) as e:
+                if attempt == self.max_retries:
+                    reason = getattr(e, 'reason', e)
+                    raise EmbeddingError(
+                        f"Cannot reach Ollama at {self.base_url}: {reason}. Is 'ollama serve' running?"
+                    ) from e
+            
+            time.sleep(delay)
+            delay *= 2
+        
+        raise EmbeddingError(f"Ollama request to {path} failed")  # not reached
+    
+    def _error_message(self, error: urllib.error.HTTPError) -> str:
+        """Ollama reports errors as {"error": "..."}"""
+        body = error.read().decode('utf-8', 'ignore')
+        try:
+            return json.loads(body).get('error', body)
+        except (ValueError, AttributeError):
+            return body
+
+
+def retry_after(headers) -> Optional[float]:
+    """Seconds a Retry-After header asks to wait (delay-seconds or an HTTP date), or None"""
+    value = (headers.get('Retry-After') if headers else None) or ''
+    value = value.strip()
+    if not value:
+        return None
+    try:
+        return max(0.0, float(value))
+    except ValueError:
+        pass
+    try:
+        when = email.utils.parsedate_to_datetime(value)
+    except (TypeError, ValueError):
+        return None
+    if when.tzinfo is None:
+        when = when.replace(tzinfo=datetime.timezone.utc)
+    return max(0.0, (when - datetime.datetime.now(datetime.timezone.utc)).total_seconds())
+
+
+class _EndpointMissing(Exception):
+    """The server does not know an endpoint (older Ollama releases)"""
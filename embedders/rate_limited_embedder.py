@@ -0,0 +1,223 @@
+#!/usr/bin/env python3
+"""
+Rate limiting and retries in front of an embedding backend
+Hosted embedding APIs throttle large indexing runs (HTTP 429); this wrapper
+paces requests (per minute and in flight), backs off with jitter when the
+backend throttles anyway, honouring its Retry-After, and retries the texts of
+a failed batch one by one so a single bad text does not sink the others
+"""
+
+import random
+import threading
+import time
+from collections import deque
+from concurrent.futures import ThreadPoolExecutor
+from typing import Callable, Dict, List, Optional, Tuple
+
+from .base_embedder import Embedder, EmbeddingError, PartialEmbeddingError, RateLimitError
+
+
+# Length of the requests-per-minute window, in seconds
+WINDOW = 60.0
+
+
+class RateLimiter:
+    """Admits requests at most requests_per_minute per minute and max_concurrent at a time"""
+    
+    def __init__(self, requests_per_minute: int = 0, max_concurrent: int = 1,
+                 clock: Callable[[], float] = time.monotonic, sleep: Callable[[float], None] = time.sleep):
+        """
+        Args:
+            requests_per_minute: Requests started in any 60 seconds (0: no limit)
+            max_concurrent: Requests in flight at once
+            clock: Monotonic time source (tests pass a fake one)
+            sleep: How to wait (tests pass a fake one)
+        """
+        if requests_per_minute < 0:
+            raise ValueError(f"requests_per_minute must be 0 (no limit) or more, got {requests_per_minute}")
+        if max_concurrent < 1:
+            raise ValueError(f"max_concurrent must be at least 1, got {max_concurrent}")
+        self.requests_per_minute = requests_per_minute
+        self.max_concurrent = max_concurrent
+        self._clock = clock
+        self._sleep = sleep
+        self._slots = threading.BoundedSemaphore(max_concurrent)
+        self._lock = threading.Lock()
+        self._started = deque()  # start times of the requests of the last minute
+        self._paused_until = 0.0
+    
+    def acquire(self) -> float:
+        """
+        Wait until a request may start, and count it as started
+        
+        Returns:
+            Seconds spent waiting for the rate limit or a pause (not for a free slot)
+        """
+        self._slots.acquire()
+        waited = 0.0
+        while True:
+            with self._lock:
+                now = self._clock()
+                while self._started and self._started[0] <= now - WINDOW:
+                    self._started.popleft()
+                wait = self._paused_until - now
+                if self.requests_per_minute and len(self._started) >= self.requests_per_minute:
+                    wait = max(wait, self._started[0] + WINDOW - now)
+                if wait <= 0:
+                    self._started.append(now)
+                    return waited
+            self._sleep(wait)
+            waited += wait
+    
+    def release(self):
+        """Mark a request started with acquire as finished"""
+        self._slots.release()
+    
+    def pause(self, seconds: float):
+        """Hold back every request for seconds (the backend asked all clients to wait)"""
+        with self._lock:
+            self._paused_until = max(self._paused_until, self._clock() + seconds)
+
+
+class RateLimitedEmbedder(Embedder):
+    """Wraps an embedder: paced requests, backoff on throttling, per-text retries of failed batches"""
+    
+    def __init__(self, embedder: Embedder, requests_per_minute: int = 0, max_concurrent: int = 1,
+                 max_retries: int = 5, backoff: float = 1.0, max_backoff: float = 60.0,
+                 clock: Callable[[], float] = time.monotonic, sleep: Callable[[float], None] = time.sleep,
+                 jitter: Callable[[], float] = random.random):
+        """
+        Args:
+            embedder: Backend the requests go to; each call with up to its batch_size texts
+                is one request
+            requests_per_minute: Requests started in any 60 seconds (0: no limit)
+            max_concurrent: Requests in flight at once; with more than 1, the batches
+                of a call are embedded in parallel
+            max_retries: Retries of a throttled request (RateLimitError) before giving up
+            backoff: Upper bound of the first retry delay in seconds (doubles on each retry)
+            max_backoff: Cap of the retry delay, unless the backend's Retry-After asks for more
+            clock: Monotonic time source (tests pass a fake one)
+            sleep: How to wait (tests pass a fake one)
+            jitter: Random fraction of the delay waited ("full jitter"; tests pass a fixed one)
+        """
+        super().__init__(embedder.model_name, embedder.batch_size)
+        self.embedder = embedder
+        self.limiter = RateLimiter(requests_per_minute, max_concurrent, clock=clock, sleep=sleep)
+        self.max_retries = max(0, max_retries)
+        self.backoff = backoff
+        self.max_backoff = max_backoff
+        self._sleep = sleep
+        self._jitter = jitter
+        self._lock = threading.Lock()
+        self.reset_stats()
+    
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        """
+        Embed texts in batches of batch_size
+        
+        Raises:
+            RateLimitError: If the backend still throttled after max_retries retries
+            PartialEmbeddingError: If some texts failed even on their own; it carries
+                the vectors of the others
+            EmbeddingError: If every text failed
+        """
+        batches = list(self._batches(texts))
+        if self.limiter.max_concurrent > 1 and len(batches) > 1:
+            with ThreadPoolExecutor(max_workers=self.limiter.max_concurrent) as pool:
+                outcomes = list(pool.map(self._embed_batch, batches))
+        else:
+            outcomes = [self._embed_batch(batch) for batch in batches]
+        
+        vectors: List[Optional[List[float]]] = []
+        failures: List[Tuple[int, str]] = []
+        for batch_vectors, batch_failures in outcomes:
+            failures.extend((len(vectors) + index, message) for index, message in batch_failures)
+            vectors.extend(batch_vectors)
+        if failures:
+            raise PartialEmbeddingError(vectors, failures)
+        return vectors
+    
+    def dimensions(self) -> int:
+        return self.embedder.dimensions()
+    
+    def reset_stats(self):
+        """Zero the counters (e.g. at the start of an indexing run)"""
+        with self._lock:
+            self.requests = 0
+            self.retries = 0
+            self.throttled = 0
+            self.throttle_wait = 0.0
+            self.batches_split = 0
+            self.texts_failed = 0
+    
+    def summary(self) -> Dict:
+        """The counters, by name: requests, retries, throttled responses, seconds waited, ..."""
+        with self._lock:
+            return {
+                'requests': self.requests,
+                'retries': self.retries,
+                'throttled': self.throttled,
+                'throttle_wait': round(self.throttle_wait, 3),
+                'batches_split': self.batches_split,
+                'texts_failed': self.texts_failed,
+            }
+    
+    def _embed_batch(self, batch: List[str]) -> Tuple[List[Optional[List[float]]], List[Tuple[int, str]]]:
+        """Vectors of a batch (None where a text failed) and the (index, message) of each failure"""
+        try:
+            return self._request(batch), []
+        except RateLimitError:
+            raise  # still throttled: more, smaller requests would not help
+        except EmbeddingError as e:
+            if len(batch) == 1:
+                raise
+            error = e
+        
+        # Retry each text on its own, so only the texts that fail again are lost
+        with self._lock:
+            self.batches_split += 1
+        vectors, failures = [], []
+        for index, text in enumerate(batch):
+            try:
+                vectors.append(self._request([text])[0])
+            except RateLimitError:
+                raise
+            except EmbeddingError as e:
+                vectors.append(None)
+                failures.append((index, str(e)))
+        if len(failures) == len(batch):
+            raise error
+        with self._lock:
+            self.texts_failed += len(failures)
+        return vectors, failures
+    
+    def _request(self, batch: List[str]) -> List[List[float]]:
+        """One backend call, retried with backoff while the backend throttles it"""
+        for attempt in range(self.max_retries + 1):
+            waited = self.limiter.acquire()
+            try:
+                with self._lock:
+                    self.requests += 1
+                    self.throttle_wait += waited
+                vectors = self.embedder.embed(batch)
+                if len(vectors) != len(batch):
+                    raise EmbeddingError(f"{self.embedder} returned {len(vectors)} vectors for {len(batch)} texts")
+                return vectors
+            except RateLimitError as e:
+                with self._lock:
+                    self.throttled += 1
+                if attempt == self.max_retries:
+                    raise RateLimitError(f"Still throttled after {self.max_retries} retries: {e}",
+                                         e.retry_after) from e
+                # Exponential backoff with full jitter; the backend's Retry-After is a floor
+                delay = self._jitter() * min(self.max_backoff, self.backoff * 2 ** attempt)
+                delay = max(delay, e.retry_after or 0.0)
+                self.limiter.pause(delay)
+                with self._lock:
+                    self.retries += 1
+            finally:
+                self.limiter.release()
+        raise EmbeddingError("Embedding request failed")  # not reached
+    
+    def __repr__(self) -> str:
+        return f"{self.embedder!r} (rate limited)"
@@ -0,0 +1,270 @@
+#!/usr/bin/env python3
+"""
+gRPC query service for the RAG system
+The CodeSearch service of proto/code_rag.proto, started with `cli.py serve-grpc`
+
+    Search      hybrid search, server-streaming: results are sent best first,
+                each as soon as its content is known
+    Lookup      go to symbol: exact, prefix and fuzzy name matches, no embedding
+    GetSymbol   a symbol by symbol_id, split parts stitched together
+
+Requests take the filters of the HTTP server's POST /search and /lookup.
+Needs grpcio (pip install grpcio grpcio-tools) and the Python stubs generated
+by proto/generate.sh into gen/python.
+"""
+
+import sys
+from concurrent.futures import ThreadPoolExecutor
+from pathlib import Path
+from typing import Dict, Iterator, Optional
+
+from chunkers.base_chunker import parse_metadata, qualified_name
+from rag import VECTOR_INDEXES, ChromeRAGSystem
+from utils.logger import get_logger
+from utils.path_scope import normalize_scopes
+from utils.result_limits import check_result_limits
+from utils.symbol_neighbors import NEIGHBOR_MODES
+
+
+# Where proto/generate.sh writes the Python stubs
+GENERATED_DIR = Path(__file__).parent / 'gen' / 'python'
+
+DEFAULT_PORT = 50051
+
+
+class GrpcUnavailable(Exception):
+    """grpcio or the generated stubs are not installed"""
+
+
+def load_stubs():
+    """The grpc module and the generated code_rag_pb2 and code_rag_pb2_grpc modules"""
+    if str(GENERATED_DIR) not in sys.path:
+        sys.path.insert(0, str(GENERATED_DIR))
+    try:
+        import grpc
+        import code_rag_pb2
+        import code_rag_pb2_grpc
+    except ImportError as e:
+        raise GrpcUnavailable(
+            f"gRPC serving needs grpcio and the generated stubs "
+            f"(pip install grpcio grpcio-tools, then sh proto/generate.sh): {e}"
+        ) from e
+    return grpc, code_rag_pb2, code_rag_pb2_grpc
+
+
+def create_server(rag: ChromeRAGSystem, host: str = '127.0.0.1', port: int = DEFAULT_PORT,
+                  max_workers: int = 8):
+    """
+    gRPC server serving CodeSearch over rag, not started yet
+    
+    Args:
+        rag: RAG system over the persisted index to serve
+        host: Interface to listen on
+        port: Port to listen on (0 picks a free one)
+        max_workers: Requests handled at the same time
+    
+    Returns:
+        (server, port it is bound to)
+    """
+    grpc, messages, services = load_stubs()
+    server = grpc.server(ThreadPoolExecutor(max_workers=max_workers))
+    services.add_CodeSearchServicer_to_server(CodeSearchServicer(rag, messages, grpc.StatusCode), server)
+    bound = server.add_insecure_port(f"{host}:{port}")
+    return server, bound
+
+
+class CodeSearchServicer:
+    """
+    The CodeSearch RPCs over one RAG system, shared between the server's threads
+    messages is the generated code_rag_pb2 module and status_codes grpc.StatusCode
+    """
+    
+    def __init__(self, rag: ChromeRAGSystem, messages, status_codes):
+        self.rag = rag
+        self.messages = messages
+        self.status_codes = status_codes
+        self.logger = get_logger()
+    
+    def Search(self, request, context) -> Iterator:
+        try:
+            search = search_arguments(request)
+        except ValueError as e:
+            context.abort(self.status_codes.INVALID_ARGUMENT, str(e))
+        
+        # Failures after the first result end the stream with the same status
+        try:
+            for result in self.rag.iter_context(**search):
+                yield self._result(result)
+        except Exception as e:
+            self.logger.error(f"Search failed: {e}")
+            context.abort(self.status_codes.INTERNAL, f"Search failed: {e}")
+    
+    def Lookup(self, request, context):
+        if not request.name.strip():
+            context.abort(self.status_codes.INVALID_ARGUMENT, "'name' must be a non-empty string")
+        if request.limit < 0:
+            context.abort(self.status_codes.INVALID_ARGUMENT, "'limit' must be at least 1")
+        symbols = self.rag.lookup(request.name, limit=request.limit or 20, kinds=list(request.kinds) or None,
+                                  languages=list(request.languages) or None, repos=list(request.repos) or None)
+        return self.messages.LookupResponse(symbols=[self._symbol_match(symbol) for symbol in symbols])
+    
+    def GetSymbol(self, request, context):
+        if not request.symbol_id:
+            context.abort(self.status_codes.INVALID_ARGUMENT, "'symbol_id' must be a non-empty string")
+        symbol = self.rag.retrieve_full_symbol(request.symbol_id)
+        if symbol is None:
+            context.abort(self.status_codes.NOT_FOUND, f"No symbol '{request.symbol_id}' indexed")
+        metadata = symbol['metadata']
+        return _message(
+            self.messages.Symbol,
+            symbol_id=metadata.get('symbol_id'),
+            name=metadata.get('name'),
+            qualified_name=qualified_name(metadata),
+            type=metadata.get('type'),
+            language=metadata.get('language'),
+            location=self._location(metadata),
+            content=symbol['content'],
+            parts=symbol.get('parts', 1),
+            owner=self._owner(metadata),
+            methods=[
+                _message(self.messages.SymbolRef, name=method['qualified_name'], symbol_id=method['symbol_id'],
+                         location=self._location(method), pointer_receiver=method['pointer_receiver'],
+                         signature=method['signature'])
+                for method in self.rag.symbol_methods(metadata)
+            ] if request.include_methods else []
+        )
+    
+    def _result(self, result: Dict):
+        """Search result as sent by Search (the fields of the HTTP server's results)"""
+        metadata = result['metadata']
+        surrounding = result.get('surrounding')
+        return _message(
+            self.messages.SearchResult,
+            id=result['id'],
+            symbol_id=metadata.get('symbol_id'),
+            score=result.get('mmr_score', result.get('rerank_score', result.get('rrf_score'))),
+            vector_score=result.get('vector_score'),
+            bm25_score=result.get('bm25_score'),
+            rerank_score=result.get('rerank_score'),
+            relevance=result.get('relevance'),
+            name=metadata.get('name'),
+            type=metadata.get('type'),
+            language=metadata.get('language'),
+            kind=metadata.get('kind', 'source'),
+            location=self._location(metadata),
+            byte_start=metadata.get('byte_start'),
+            byte_end=metadata.get('byte_end'),
+            content=result['content'],
+            surrounding=_message(
+                self.messages.Surrounding,
+                imports=surrounding.get('imports'),
+                enclosing=surrounding.get('enclosing'),
+                enclosing_name=surrounding.get('enclosing_name'),
+                enclosing_filepath=surrounding.get('enclosing_filepath')
+            ) if surrounding else None,
+            duplicates=[self._location(location) for location in result.get('duplicates', [])],
+            owner=self._owner(metadata),
+            neighbors=self._neighbors(result.get('neighbors')),
+            highlights=[_message(self.messages.Highlight, **span) for span in result.get('highlights', [])]
+        )
+    
+    def _owner(self, metadata: Dict):
+        """Reference to the type a Go method is declared on, or None"""
+        extra = parse_metadata(metadata.get('metadata'))
+        owner = extra.get('owner')
+        if not owner:
+            return None
+        return _message(
+            self.messages.SymbolRef,
+            name=owner['name'],
+            symbol_id=owner.get('symbol_id'),
+            location=self._location({'filepath': owner['filepath'], 'line_start': owner['line'],
+                                     'line_end': owner['line']}) if owner.get('resolved') else None,
+            pointer_receiver=bool(extra.get('pointer_receiver'))
+        )
+    
+    def _neighbors(self, neighbors: Optional[Dict]):
+        """The symbols before and after a result, or None when they were not asked for"""
+        if neighbors is None:
+            return None
+        refs = {}
+        for key, neighbor in neighbors.items():
+            if neighbor:
+                refs[key] = _message(self.messages.SymbolRef, name=neighbor['qualified_name'] or neighbor['name'],
+                                     symbol_id=neighbor['symbol_id'], location=self._location(neighbor),
+                                     content=neighbor.get('content'))
+        return _message(self.messages.Neighbors, previous=refs.get('previous'), next=refs.get('next'))
+    
+    def _symbol_match(self, symbol: Dict):
+        return _message(
+            self.messages.SymbolMatch,
+            name=symbol.get('name'),
+            qualified_name=symbol.get('qualified_name'),
+            kind=symbol.get('kind'),
+            type=symbol.get('type'),
+            language=symbol.get('language'),
+            symbol_id=symbol.get('symbol_id'),
+            location=self._location(symbol),
+            match=symbol.get('match'),
+            distance=symbol.get('distance'),
+            alias_of=symbol.get('alias_of')
+        )
+    
+    def _location(self, metadata: Dict):
+        return _message(
+            self.messages.Location,
+            filepath=metadata.get('filepath'),
+            repo=metadata.get('repo') or None,
+            line_start=metadata.get('line_start'),
+            line_end=metadata.get('line_end'),
+            citation=f"{metadata.get('filepath')}#L{metadata.get('line_start')}-L{metadata.get('line_end')}"
+        )
+
+
+def search_arguments(request) -> Dict:
+    """retrieve_context arguments of a SearchRequest; raises ValueError for invalid requests"""
+    if not request.query.strip():
+        raise ValueError("'query' must be a non-empty string")
+    if request.top_k < 0:
+        raise ValueError("'top_k' must be at least 1")
+    if request.rerank_candidates < 0:
+        raise ValueError("'rerank_candidates' must be at least 1")
+    if request.candidate_k and request.candidate_k < (request.top_k or 5):
+        raise ValueError("'candidate_k' must be at least 'top_k'")
+    check_result_limits(request.top_k or 5, request.candidate_k or None, request.rerank_candidates or None,
+                        name="'top_k'")
+    if request.vector_index and request.vector_index not in VECTOR_INDEXES:
+        raise ValueError(f"'vector_index' must be one of: {', '.join(VECTOR_INDEXES)}")
+    if request.with_neighbors and request.with_neighbors not in NEIGHBOR_MODES:
+        raise ValueError(f"'with_neighbors' must be one of: {', '.join(NEIGHBOR_MODES)}")
+    scope = normalize_scopes(list(request.scope)) or None
+    
+    def optional(field: str):
+        return getattr(request, field) if request.HasField(field) else None
+    
+    return dict(
+        query=request.query,
+        n_results=request.top_k or 5,
+        lexical_weight=optional('lexical_weight'),
+        languages=list(request.languages) or None,
+        kinds=list(request.kinds) or None,
+        path_globs=list(request.path_globs) or None,
+        repos=list(request.repos) or None,
+        uses=list(request.uses) or None,
+        min_score=optional('min_score'),
+        mmr_lambda=optional('mmr_lambda'),
+        exclude_tests=request.exclude_tests,
+        rerank=optional('rerank'),
+        rerank_candidates=request.rerank_candidates or None,
+        candidate_k=request.candidate_k or None,
+        vector_index=request.vector_index or None,
+        with_surrounding=request.with_surrounding,
+        with_neighbors=request.with_neighbors or None,
+        expand_query=optional('expand_query'),
+        scope=scope
+    )
+
+
+def _message(message_type, **fields):
+    """A message with the fields that have a value (protobuf rejects None)"""
+    return message_type(**{name: value for name, value in fields.items() if value is not None})
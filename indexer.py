@@ -6,59 +6,117 @@ Supports parallel processing and incremental updates
 """
 
 import os
+import re
 import multiprocessing
+import time
 from pathlib import Path
-from typing import List, Dict, Set, Optional, Tuple
+from typing import Iterator, List, Dict, Set, Optional, Tuple
 from collections import defaultdict
 from functools import partial
 
 from config import CONFIG
+from embedders import CachedEmbedder, Embedder, RateLimitedEmbedder, find_embedder
 from chunkers import (
-    CppChunker, PythonChunker, JavaScriptChunker,
-    MojomChunker, GnChunker
+    CppChunker, PythonChunker, JavaScriptChunker, MojomChunker, GnChunker, GoChunker,
+    RustChunker, ZigChunker, JavaChunker, KotlinChunker, ScalaChunker, CSharpChunker, RubyChunker, LuaChunker, PhpChunker, ElixirChunker, DartChunker, SwiftChunker,
+    ProtobufChunker, MarkdownChunker, HtmlChunker, BashChunker, SqlChunker, YamlChunker, JsonChunker, TextChunker, link_go_packages,
+    link_cpp_declarations, link_csharp_partials, link_swift_extensions,
+    split_oversized_chunks, parse_overlap_strategy,
+    apply_granularity, is_excluded_symbol, is_small_symbol, parse_granularity, assign_byte_ranges, assign_symbol_ids, find_module_path, tag_go_tests,
+    repo_filepath
 )
 from utils.logger import (
     get_logger, create_progress_bar,
     print_success, print_error, print_warning, print_header, print_stats
 )
+from utils.cancellation import CancelToken, IndexProgress, ProgressCallback, ignore_interrupts
+from utils.chunk_processors import ChunkProcessor, load_processor, run_processors
+from utils.embedding_pipeline import EmbeddingPipeline
+from utils.file_languages import TEXT_LANGUAGE, LanguageMap, tag_detection
+from utils.generated_code import GENERATED_MODES, generated_reason, read_head, tag_generated
+from utils.git_blame import blame_lines, tag_last_changes
+from utils.go_build import GoBuildContext, is_go_test_file
+from utils.ignore_rules import LARGE_FILE_MODES, IgnoreRules, skip_reason
+from utils.path_priors import file_modified_time, git_modified_times
+from utils.secret_scan import SECRET_MODES, scan_chunk
 from utils.state_manager import StateManager
+from utils.tracing import INDEX_STAGES, Tracer, format_timings
 
 
-def process_file_worker(args) -> Tuple[str, str, List, Optional[str]]:
+# Languages whose chunks need a cross-file pass before insertion.
+# Their chunks are held back until every file of the run has been chunked;
+# languages sharing a linker are linked together (C sources with C++ headers).
+PACKAGE_LINKERS = {
+    'go': link_go_packages,
+    'cpp': link_cpp_declarations,
+    'c': link_cpp_declarations,
+    'csharp': link_csharp_partials,
+    'swift': link_swift_extensions,
+}
+
+# Settings CONFIG.language_chunking may override per language
+CHUNKING_KEYS = ('max_tokens', 'token_overlap', 'overlap_strategy', 'granularity', 'min_chunk_lines',
+                 'min_chunk_tokens')
+
+# Labels of the repositories of a multi-repo index (stored on chunks, part of their ids)
+REPO_LABEL = re.compile(r'^[A-Za-z0-9][A-Za-z0-9._-]*$')
+
+
+def process_file_worker(args) -> Tuple[str, str, List, Optional[str], List[Dict]]:
     """
     Worker function for parallel processing
     Must be top-level to be pickleable
     
     Args:
-        args: Tuple of (file_path, language, root_path)
-        
+        args: Tuple of (file_path, language, root_path, max_tokens, granularity[, repo[, overlap
+            [, generated[, blame[, stream_above[, detection[, overlap_strategy]]]]]]]); generated is the extra
+            generated_patterns to tag the chunks of generated files by, or None to leave them
+            untagged; blame records each chunk's last change from git blame; a file larger than
+            stream_above bytes (0: none) is streamed into plain-text blocks instead of parsed;
+            detection is the Detection its language came from, recorded in the chunks' metadata;
+            overlap_strategy is how split parts and text blocks overlap (see OVERLAP_STRATEGIES)
+    
     Returns:
-        Tuple of (file_path, language, chunks, error_message)
+        Tuple of (file_path, language, chunks, error_message, diagnostics); a file
+        with syntax errors still yields the chunks that parsed, with its
+        parse_error diagnostics
     """
-    file_path, language, root_path = args
+    file_path, language, root_path, max_tokens, granularity = args[:5]
+    repo = args[5] if len(args) > 5 else None
+    overlap = args[6] if len(args) > 6 else None
+    generated = args[7] if len(args) > 7 else None
+    blame = args[8] if len(args) > 8 else False
+    stream_above = args[9] if len(args) > 9 else 0
+    detection = args[10] if len(args) > 10 else None
+    strategy = args[11] if len(args) > 11 else None
     
     try:
+        # Get relative path
+        try:
+            rel_path = str(Path(file_path).relative_to(root_path))
+        except ValueError:
+            rel_path = str(file_path)
+        
+        if stream_above and os.path.getsize(file_path) > stream_above:
+            chunks = stream_file(file_path, rel_path, language, max_tokens, overlap, repo, generated, strategy)
+            return str(file_path), language, tag_detection(chunks, detection), None, []
+        
         # Read file content
         with open(file_path, 'r', encoding='utf-8', errors='ignore') as f:
             code = f.read()
         
         # Skip empty files
         if not code.strip():
-            return str(file_path), language, [], None
-        
-        # Get relative path
-        try:
-            rel_path = str(Path(file_path).relative_to(root_path))
-        except ValueError:
-            rel_path = str(file_path)
+            return str(file_path), language, [], None, []
         
         # Instantiate appropriate chunker
         # We instantiate here to avoid pickling issues with C extensions (tree-sitter)
         chunker = None
         
-        # Check if it's a generic tree-sitter language
+        # Check if it's a generic tree-sitter language (only usable with a query;
+        # languages without one are handled by their dedicated chunker below)
         file_config = CONFIG.file_types.get(language)
-        if file_config and file_config.parser_type == 'treesitter':
+        if file_config and file_config.parser_type == 'treesitter' and file_config.query_scm:
             from chunkers import AdaptiveChunker
             # Use AdaptiveChunker with architecture-aware fallback
             chunker = AdaptiveChunker(
@@ -66,8 +124,8 @@ def process_file_worker(args) -> Tuple[str, str, List, Optional[str]]:
                 query_scm=file_config.query_scm
             )
         # Fallback to specific chunkers (legacy)
-        elif language == 'cpp':
-            chunker = CppChunker()
+        elif language in ('cpp', 'c'):
+            chunker = CppChunker(language)
         elif language == 'python':
             chunker = PythonChunker()
         elif language == 'javascript':
@@ -76,16 +134,239 @@ def process_file_worker(args) -> Tuple[str, str, List, Optional[str]]:
             chunker = MojomChunker()
         elif language == 'gn':
             chunker = GnChunker()
-            
+        elif language == 'go':
+            chunker = GoChunker(find_module_path(str(Path(file_path).parent)),
+                                package_clauses=CONFIG.go_package_summaries)
+        elif language == 'rust':
+            chunker = RustChunker()
+        elif language == 'zig':
+            chunker = ZigChunker()
+        elif language == 'java':
+            chunker = JavaChunker()
+        elif language == 'kotlin':
+            chunker = KotlinChunker()
+        elif language == 'scala':
+            chunker = ScalaChunker()
+        elif language == 'csharp':
+            chunker = CSharpChunker()
+        elif language == 'ruby':
+            chunker = RubyChunker()
+        elif language == 'lua':
+            chunker = LuaChunker()
+        elif language == 'php':
+            chunker = PhpChunker()
+        elif language == 'elixir':
+            chunker = ElixirChunker()
+        elif language == 'dart':
+            chunker = DartChunker()
+        elif language == 'swift':
+            chunker = SwiftChunker()
+        elif language == 'protobuf':
+            chunker = ProtobufChunker()
+        elif language == 'markdown':
+            chunker = MarkdownChunker()
+        elif language == 'html':
+            chunker = HtmlChunker()
+        elif language == 'bash':
+            chunker = BashChunker()
+        elif language == 'sql':
+            chunker = SqlChunker()
+        elif language == 'yaml':
+            chunker = YamlChunker()
+        elif language == 'json':
+            chunker = JsonChunker()
+        elif language == TEXT_LANGUAGE:
+            chunker = text_chunker(language, max_tokens, overlap, strategy)
+        
         if not chunker:
-            return str(file_path), language, [], f"No chunker for language: {language}"
+            if not CONFIG.text_fallback:
+                return str(file_path), language, [], f"No chunker for language: {language}", []
+            chunker = text_chunker(language, max_tokens, overlap, strategy)
         
-        # Extract chunks
+        # Extract chunks; a file its parser finds nothing in is indexed as text when the fallback is on
         chunks = chunker.extract_chunks(code, rel_path)
-        return str(file_path), language, chunks, None
+        if not chunks and CONFIG.text_fallback and not isinstance(chunker, TextChunker):
+            chunker = text_chunker(language, max_tokens, overlap, strategy)
+            chunks = chunker.extract_chunks(code, rel_path)
+        chunks = assign_byte_ranges(chunks, code)
+        if not isinstance(chunker, TextChunker):
+            chunks = apply_granularity(chunks, code, rel_path, language, granularity)
+        for chunk in chunks:
+            chunk.repo = repo
+        chunks = assign_symbol_ids(chunks)
+        if language == 'go' and is_go_test_file(rel_path):
+            chunks = tag_go_tests(chunks)
+        if generated is not None and generated_reason(rel_path, code, language, generated):
+            chunks = tag_generated(chunks)
+        if blame:
+            lines = blame_lines(root_path, rel_path)
+            if lines:
+                chunks = tag_last_changes(chunks, lines)
+        chunks = tag_detection(chunks, detection)
         
+        # Linked languages are split after their cross-file pass
+        if language not in PACKAGE_LINKERS:
+            chunks = split_chunks(chunks, max_tokens, overlap, strategy)
+        return str(file_path), language, chunks, None, chunker.diagnostics
+    
     except Exception as e:
-        return str(file_path), language, [], str(e)
+        return str(file_path), language, [], str(e), []
+
+
+def stream_file(file_path: str, rel_path: str, language: str, max_tokens: Optional[int] = None,
+                overlap: Optional[int] = None, repo: Optional[str] = None,
+                generated: Optional[Tuple[str, ...]] = None, strategy: Optional[str] = None) -> List:
+    """
+    Chunks of a file too large to parse: plain-text blocks read from disk a window at a
+    time, tagged like the chunks of a parsed file except for git blame (which would hold
+    every line of the file at once)
+    """
+    chunks = text_chunker(language, max_tokens, overlap, strategy).stream_chunks(file_path, rel_path)
+    for chunk in chunks:
+        chunk.repo = repo
+    chunks = assign_symbol_ids(chunks)
+    if generated is not None and generated_reason(rel_path, read_head(file_path), language, generated):
+        chunks = tag_generated(chunks)
+    return chunks
+
+
+def timed_file_worker(args) -> Tuple[Tuple, float, float]:
+    """process_file_worker with its start (epoch seconds) and duration, for the parse span"""
+    start = time.time()
+    started = time.perf_counter()
+    result = process_file_worker(args)
+    return result, start, time.perf_counter() - started
+
+
+def text_chunker(language: str, max_tokens: Optional[int] = None, overlap: Optional[int] = None,
+                 strategy: Optional[str] = None) -> TextChunker:
+    """Plain-text chunker for a file of the language, with the run's token budget"""
+    return TextChunker(language, CONFIG.max_tokens if max_tokens is None else max_tokens,
+                       CONFIG.token_overlap if overlap is None else overlap, CONFIG.tokenizer_encoding,
+                       strategy or CONFIG.overlap_strategy)
+
+
+def parse_worker_count(workers: Optional[int] = None) -> int:
+    """Parser process count: the requested number, CONFIG.parse_workers, or one per CPU"""
+    workers = CONFIG.parse_workers if workers is None else workers
+    return workers if workers and workers > 0 else (os.cpu_count() or 1)
+
+
+def embed_worker_count(embedder: Optional[Embedder], workers: Optional[int] = None) -> int:
+    """
+    Threads embedding batches while parsing goes on: the requested number or
+    CONFIG.embedding_workers (0 embeds each batch in line), at most the requests
+    a rate limited backend takes at once
+    """
+    workers = CONFIG.embedding_workers if workers is None else workers
+    limited = find_embedder(embedder, RateLimitedEmbedder)
+    if limited is not None:
+        workers = min(workers, limited.limiter.max_concurrent)
+    return max(0, workers)
+
+
+def split_chunks(chunks: List, max_tokens: Optional[int] = None, overlap: Optional[int] = None,
+                 strategy: Optional[str] = None) -> List:
+    """Split chunks that exceed the token budget of the embedding model"""
+    return split_oversized_chunks(
+        chunks,
+        max_tokens=CONFIG.max_tokens if max_tokens is None else max_tokens,
+        overlap_tokens=CONFIG.token_overlap if overlap is None else overlap,
+        encoding=CONFIG.tokenizer_encoding,
+        overlap_strategy=strategy or CONFIG.overlap_strategy
+    )
+
+
+def chunking_params(language: str, max_tokens: Optional[int] = None,
+                    granularity: Optional[str] = None, min_lines: Optional[int] = None,
+                    min_tokens: Optional[int] = None) -> Dict:
+    """
+    Effective chunking parameters for files of a language
+    CONFIG.language_chunking[language] wins over the run's max_tokens, granularity and
+    minimum sizes, which win over the global CONFIG values
+    
+    Returns:
+        Dict with max_tokens, token_overlap, overlap_strategy, granularity, min_chunk_lines
+        and min_chunk_tokens
+    
+    Raises:
+        ValueError: If the override names an unknown setting, granularity or overlap strategy
+    """
+    override = CONFIG.language_chunking.get(language) or {}
+    unknown = set(override) - set(CHUNKING_KEYS)
+    if unknown:
+        raise ValueError(f"Unknown chunking setting for {language}: {', '.join(sorted(unknown))} "
+                         f"(expected: {', '.join(CHUNKING_KEYS)})")
+    return {
+        'max_tokens': override.get('max_tokens', CONFIG.max_tokens if max_tokens is None else max_tokens),
+        'token_overlap': override.get('token_overlap', CONFIG.token_overlap),
+        'overlap_strategy': parse_overlap_strategy(override.get('overlap_strategy') or CONFIG.overlap_strategy),
+        'granularity': parse_granularity(override.get('granularity') or granularity or CONFIG.granularity),
+        'min_chunk_lines': override.get('min_chunk_lines', CONFIG.min_chunk_lines if min_lines is None else min_lines),
+        'min_chunk_tokens': override.get('min_chunk_tokens',
+                                         CONFIG.min_chunk_tokens if min_tokens is None else min_tokens),
+    }
+
+
+def drop_small_symbols(chunks: List, min_lines: int = 0, min_tokens: int = 0) -> List:
+    """The chunks without the functions and methods below the minimum size (see is_small_symbol)"""
+    if not min_lines and not min_tokens:
+        return chunks
+    return [chunk for chunk in chunks
+            if not is_small_symbol(chunk, min_lines, min_tokens, CONFIG.tokenizer_encoding)]
+
+
+def drop_excluded_symbols(chunks: List, patterns) -> List:
+    """The chunks without the symbols whose qualified name matches a pattern (see is_excluded_symbol)"""
+    if not patterns:
+        return chunks
+    return [chunk for chunk in chunks if not is_excluded_symbol(chunk, patterns)]
+
+
+def file_batches(chunks: List, batch_size: int) -> Iterator[List]:
+    """
+    Chunks in batches of whole files, each at least batch_size chunks but the last
+    (a file larger than that is a batch of its own), files in the order they first appear
+    """
+    by_file = defaultdict(list)
+    for chunk in chunks:
+        by_file[chunk.filepath].append(chunk)
+    batch = []
+    for file_chunks in by_file.values():
+        batch.extend(file_chunks)
+        if len(batch) >= batch_size:
+            yield batch
+            batch = []
+    if batch:
+        yield batch
+
+
+def parse_root(spec: str) -> Tuple[Optional[str], str]:
+    """
+    Split a root given as LABEL=PATH into its repository label and path
+    (None for a plain path)
+    
+    Raises:
+        ValueError: If the label is not a valid repository label
+    """
+    label, separator, path = spec.partition('=')
+    if not separator or os.sep in label or not label:
+        return None, spec
+    if not REPO_LABEL.match(label):
+        raise ValueError(f"Invalid repository label: {label!r} (letters, digits, '.', '_' and '-')")
+    return label, path
+
+
+def _in_scope(path: Path, scope: List[Tuple[Path, bool]]) -> bool:
+    """True if a file is in one of the (directory, recursive) entries of an update scope"""
+    return any(path.parent == directory or (recursive and path.is_relative_to(directory))
+               for directory, recursive in scope)
+
+
+def _leads_into_scope(directory: Path, scope: List[Tuple[Path, bool]]) -> bool:
+    """True if a directory is on the way to an update scope entry, or inside a recursive one"""
+    return any(target.is_relative_to(directory) or (recursive and directory.is_relative_to(target))
+               for target, recursive in scope)
 
 
 class ChromeIndexer:
@@ -94,47 +375,129 @@ class ChromeIndexer:
     Discovers files, routes to appropriate chunkers, and manages database insertions
     """
     
-    def __init__(self, rag_system):
+    def __init__(self, rag_system, embedder: Optional[Embedder] = None,
+                 state_manager: Optional[StateManager] = None,
+                 ignore_patterns: Optional[List[str]] = None, use_default_ignores: bool = True,
+                 use_gitignore: bool = True, max_file_bytes: Optional[int] = None,
+                 large_files: Optional[str] = None, max_stream_file_bytes: Optional[int] = None,
+                 go_build: Optional[GoBuildContext] = None, secrets: Optional[str] = None,
+                 languages: Optional[LanguageMap] = None, generated: Optional[str] = None,
+                 generated_patterns: Optional[List[str]] = None, blame: Optional[bool] = None,
+                 min_lines: Optional[int] = None, min_tokens: Optional[int] = None,
+                 exclude_symbols: Optional[List[str]] = None, processors: Optional[List[ChunkProcessor]] = None,
+                 embed_workers: Optional[int] = None, verbose: bool = False):
+        """
+        Args:
+            rag_system: Vector database the chunks are written to
+            embedder: Optional embedding backend; replaces the RAG system's own
+            state_manager: Optional incremental-indexing state (default: index_state.db)
+            ignore_patterns: Extra gitignore-style patterns to skip
+            use_default_ignores: Skip the built-in directories (CONFIG.exclude_dirs)
+            use_gitignore: Honour .gitignore files in the indexed tree
+            max_file_bytes: Skip larger files (default: CONFIG.max_file_bytes, 0 disables)
+            large_files: What to do with files over max_file_bytes, one of LARGE_FILE_MODES:
+                skip them, or stream them into plain-text blocks (default: CONFIG.large_files)
+            max_stream_file_bytes: Skip files larger than this even when streaming
+                (default: CONFIG.max_stream_file_bytes, 0 disables)
+            go_build: Target GOOS/GOARCH, tags and test-file handling for Go files
+                (default: from CONFIG.go_goos, go_goarch, go_build_tags, go_include_tests)
+            secrets: What to do with chunks holding secrets, one of SECRET_MODES
+                (default: CONFIG.secret_scan)
+            languages: Language of each discovered file (default: CONFIG.file_types with
+                CONFIG.extension_languages, path_languages, shebang_languages and
+                text_fallback)
+            generated: What to do with generated files, one of GENERATED_MODES: tag
+                their chunks generated=true, skip them, or index them as they are
+                (default: CONFIG.generated_code)
+            generated_patterns: Extra globs of generated files (default: CONFIG.generated_patterns)
+            blame: Record who last changed each chunk, and in which commit, from git blame
+                (default: CONFIG.git_blame)
+            min_lines: Leave out functions and methods of fewer lines (default:
+                CONFIG.min_chunk_lines; CONFIG.language_chunking overrides it per language)
+            min_tokens: Leave out functions and methods of fewer tokens of code (default:
+                CONFIG.min_chunk_tokens, likewise)
+            exclude_symbols: Leave out the symbols whose qualified name matches one of
+                these globs, e.g. '*.String' or 'Test*' (default: CONFIG.exclude_symbols)
+            processors: ChunkProcessors run on every chunk, in order, before it is
+                embedded (default: those CONFIG.chunk_processors names)
+            embed_workers: Batches embedded on background threads while files are still
+                parsed, stored in the order they were produced; at most the rate limiter's
+                requests in flight, 0 embeds in line (default: CONFIG.embedding_workers)
+            verbose: Add the calls, items and latencies of each stage to the printed statistics
+        """
         self.logger = get_logger()
         self.rag = rag_system
-        self.state_manager = StateManager()
+        if embedder is not None:
+            self.rag.set_embedder(embedder)
+        self.state_manager = state_manager or StateManager()
+        self.ignore_patterns = list(ignore_patterns or [])
+        self.use_default_ignores = use_default_ignores
+        self.use_gitignore = use_gitignore
+        self.max_file_bytes = CONFIG.max_file_bytes if max_file_bytes is None else max_file_bytes
+        self.large_files = large_files or CONFIG.large_files
+        if self.large_files not in LARGE_FILE_MODES:
+            raise ValueError(f"Unknown large file mode: {self.large_files} "
+                             f"(expected one of: {', '.join(LARGE_FILE_MODES)})")
+        self.max_stream_file_bytes = (CONFIG.max_stream_file_bytes if max_stream_file_bytes is None
+                                      else max_stream_file_bytes)
+        self.go_build = go_build or GoBuildContext(
+            CONFIG.go_goos, CONFIG.go_goarch, CONFIG.go_build_tags, CONFIG.go_include_tests
+        )
+        self.secrets = secrets or CONFIG.secret_scan
+        if self.secrets not in SECRET_MODES:
+            raise ValueError(f"Unknown secret scan mode: {self.secrets} (expected one of: {', '.join(SECRET_MODES)})")
+        self.languages = languages or LanguageMap()
+        self.generated = generated or CONFIG.generated_code
+        if self.generated not in GENERATED_MODES:
+            raise ValueError(f"Unknown generated code mode: {self.generated} "
+                             f"(expected one of: {', '.join(GENERATED_MODES)})")
+        self.generated_patterns = tuple(CONFIG.generated_patterns if generated_patterns is None
+                                        else generated_patterns)
+        self.blame = CONFIG.git_blame if blame is None else blame
+        self.min_lines = min_lines
+        self.min_tokens = min_tokens
+        self.exclude_symbols = tuple(CONFIG.exclude_symbols if exclude_symbols is None else exclude_symbols)
+        self.processors = (list(processors) if processors is not None
+                           else [load_processor(spec) for spec in CONFIG.chunk_processors])
+        self.embed_workers = embed_workers
+        # Stage timings go to the RAG system's tracer, next to its embed and upsert spans
+        self.tracer = getattr(self.rag, 'tracer', None) or Tracer()
+        self.verbose = verbose
         
         # Statistics tracking
-        self.stats = {
-            'files_processed': 0,
-            'files_skipped': 0,
-            'files_failed': 0,
-            'chunks_created': 0,
-            'files_by_type': defaultdict(int),
-            'chunks_by_type': defaultdict(int),
-            'errors': []
-        }
+        self._reset_stats()
     
     def index_directory(self, source_path: str, file_types: Optional[List[str]] = None,
-                       batch_size: Optional[int] = None, parallel: bool = True) -> Dict:
+                       batch_size: Optional[int] = None, parallel: bool = True,
+                       max_tokens: Optional[int] = None, workers: Optional[int] = None,
+                       granularity: Optional[str] = None, repo: Optional[str] = None,
+                       cancel: Optional[CancelToken] = None,
+                       progress: Optional[ProgressCallback] = None) -> Dict:
         """
         Index an entire directory tree
         
+        A cancelled run stops at the next file or insert batch: the files it
+        completed are indexed and recorded, the others are left out entirely
+        (and picked up by the next run).
+        
         Args:
             source_path: Path to Chrome src/ directory
             file_types: Optional filter for specific file types
             batch_size: Optional batch size override
             parallel: Whether to use parallel processing
-            
+            max_tokens: Optional token budget per chunk (0 disables splitting)
+            workers: Parser processes when parallel (default: CONFIG.parse_workers)
+            granularity: 'file', 'symbol' or 'symbol_with_imports' (default: CONFIG.granularity)
+            repo: Label recorded on every chunk, for an index of several repositories
+            cancel: Token that stops the run when cancelled
+            progress: Called with an IndexProgress after every file parsed and batch stored
+        
         Returns:
-            Dictionary with indexing statistics
+            Dictionary with indexing statistics ('cancelled' holds the reason of a stopped run)
         """
         # Reset stats for this run
-        self.stats = {
-            'files_processed': 0,
-            'files_skipped': 0,
-            'files_failed': 0,
-            'chunks_created': 0,
-            'files_by_type': defaultdict(int),
-            'chunks_by_type': defaultdict(int),
-            'errors': []
-        }
-
+        self._reset_stats(cancel, progress)
+        
         print_header(f"Indexing Chrome Source Code: {source_path}")
         
         source_path = Path(source_path)
@@ -143,15 +506,26 @@ class ChromeIndexer:
             return self.stats
         
         batch_size = batch_size or CONFIG.batch_size
+        max_tokens = CONFIG.max_tokens if max_tokens is None else max_tokens
+        granularity = parse_granularity(granularity or CONFIG.granularity)
+        
+        if not self._check_embedder():
+            return self.stats
+        self.rag.record_source_root(str(source_path.resolve()), repo)
         
         # Discover all files
+        self._report(stage='discovering')
         self.logger.info("Discovering files...")
-        all_files = self._discover_files(source_path, file_types)
+        with self.tracer.span('crawl') as span:
+            all_files = self._discover_files(source_path, file_types)
+            span.items = len(all_files)
+        if self._cancel_requested():
+            return self._stop()
         
         if not all_files:
             print_warning("No files found to index")
             return self.stats
-            
+        
         # Filter files that need processing
         files_to_process = []
         files_by_lang = defaultdict(int)
@@ -162,56 +536,449 @@ class ChromeIndexer:
             else:
                 self.stats['files_skipped'] += 1
         
-        self.logger.info(f"Found {len(all_files)} files ({len(files_to_process)} new/modified, {self.stats['files_skipped']} skipped)")
-        
-        # Display file breakdown by type
-        if files_by_lang:
-            print("\n📊 Files to Index by Type:")
-            print("─" * 40)
-            for lang in sorted(files_by_lang.keys()):
-                count = files_by_lang[lang]
-                print(f"  • {lang.ljust(15)} : {count:>4} files")
-            print("─" * 40)
-            print(f"  Total: {len(all_files)} files\n")
+        self.logger.info(
+            f"Found {len(all_files)} files ({len(files_to_process)} new/modified, {self.stats['files_skipped']} skipped)",
+            extra={'phase': 'discovering', 'files': len(all_files), 'files_to_process': len(files_to_process),
+                   'files_skipped': self.stats['files_skipped'],
+                   'files_by_language': dict(sorted(files_by_lang.items()))}
+        )
         
         if not files_to_process:
             print_success("All files are up to date!")
+            self.stats['timings'] = self.tracer.summary(INDEX_STAGES)
+            return self.stats
+        
+        # Modified files: their previous chunks go once the new ones are ready to store
+        indexed = self.state_manager.get_all_indexed_files()
+        for fp, _ in files_to_process:
+            if str(fp) in indexed:
+                self._replacing[self._relative_path(fp, source_path)] = repo
+        current = {str(fp): (lang, None) for fp, lang in all_files}
+        files_to_process = self._expand_linked_packages(files_to_process, current, source_path, repo)
+        
+        if not self._process_files(files_to_process, source_path, batch_size, parallel, max_tokens,
+                                   workers, granularity, repo):
+            return self.stats
+        
+        # Print final statistics
+        self.stats['timings'] = self.tracer.summary(INDEX_STAGES)
+        print_success(f"Indexing complete!")
+        self._print_statistics()
+        
+        return self.stats
+    
+    def update_index(self, source_path: str, file_types: Optional[List[str]] = None,
+                     batch_size: Optional[int] = None, parallel: bool = True,
+                     max_tokens: Optional[int] = None, workers: Optional[int] = None,
+                     granularity: Optional[str] = None, paths: Optional[List[str]] = None,
+                     report: bool = True, repo: Optional[str] = None,
+                     cancel: Optional[CancelToken] = None,
+                     progress: Optional[ProgressCallback] = None) -> Dict:
+        """
+        Bring the index in line with a directory tree using file content hashes
+        
+        Only files whose content changed are re-parsed and re-embedded. Files
+        that disappeared have their chunks purged; a file that moved without
+        changing keeps its chunks (and embeddings) under the new path.
+        
+        With paths, only those files and directories are compared with the
+        recorded state (the rest of the tree is neither read nor hashed); a file
+        brings its directory along, so moves within it and linked packages resolve.
+        
+        Args:
+            source_path: Root directory that was indexed before
+            file_types: Optional filter for specific file types
+            batch_size: Optional batch size override
+            parallel: Whether to use parallel processing
+            max_tokens: Optional token budget per chunk (0 disables splitting)
+            workers: Parser processes when parallel (default: CONFIG.parse_workers)
+            granularity: 'file', 'symbol' or 'symbol_with_imports' (default: CONFIG.granularity)
+            paths: Limit the update to these files and directories under source_path
+            report: Print the header and the statistics table
+            repo: Label of the repository, if source_path was indexed as one
+            cancel: Token that stops the run when cancelled (as for index_directory)
+            progress: Called with an IndexProgress after every file parsed and batch stored
+        
+        Returns:
+            Dictionary with indexing statistics, including chunks_added,
+            chunks_updated, chunks_removed and files_moved, and the relative
+            paths in paths_added, paths_updated, paths_removed and paths_moved
+        """
+        self._reset_stats(cancel, progress)
+        self.stats.update({
+            'chunks_added': 0, 'chunks_updated': 0, 'chunks_removed': 0,
+            'files_moved': 0,
+            'paths_added': [], 'paths_updated': [], 'paths_removed': [], 'paths_moved': []
+        })
+        
+        if report:
+            print_header(f"Updating Index: {source_path}")
+        
+        source_path = Path(source_path)
+        if not source_path.exists():
+            print_error(f"Path does not exist: {source_path}")
+            return self.stats
+        
+        batch_size = batch_size or CONFIG.batch_size
+        max_tokens = CONFIG.max_tokens if max_tokens is None else max_tokens
+        granularity = parse_granularity(granularity or CONFIG.granularity)
+        
+        if not self._check_embedder():
             return self.stats
+        self.rag.record_source_root(str(source_path.resolve()), repo)
+        
+        recorded = self.state_manager.get_file_hashes(str(source_path))
+        scope = self._update_scope(paths, recorded) if paths is not None else None
+        
+        # Current tree: path -> (language, content hash)
+        self._report(stage='discovering')
+        self.logger.info("Discovering files...")
+        current = {}
+        with self.tracer.span('crawl', hashed=True) as span:
+            for fp, lang in self._discover_files(source_path, file_types, scope):
+                if self._cancel_requested():
+                    break
+                current[str(fp)] = (lang, self.state_manager.current_hash(str(fp)))
+            span.items = len(current)
+        if self._cancel_requested():
+            return self._stop()
+        
+        # Previous state of this tree (limited to the requested file types)
+        previous = {
+            path: file_hash
+            for path, file_hash in recorded.items()
+            if (not file_types or self.languages.language(Path(path), self._relative_path(path, source_path)) in file_types)
+            and (scope is None or _in_scope(Path(path), scope))
+        }
         
-        # Prepare arguments for worker
-        worker_args = [(str(fp), lang, str(source_path)) for fp, lang in files_to_process]
+        vanished = {path: h for path, h in previous.items() if path not in current}
+        vanished_by_hash = defaultdict(list)
+        for path, file_hash in vanished.items():
+            vanished_by_hash[file_hash].append(path)
+        
+        files_to_process = []
+        added_paths, updated_paths = set(), set()
+        for path, (lang, file_hash) in sorted(current.items()):
+            if path in previous:
+                if previous[path] == file_hash:
+                    self.stats['files_skipped'] += 1
+                    continue
+                # Changed content: replace the old chunks, once the new ones are ready to store
+                self._replacing[self._relative_path(path, source_path)] = repo
+                updated_paths.add(path)
+                self.stats['paths_updated'].append(self._relative_path(path, source_path))
+                files_to_process.append((Path(path), lang))
+            elif vanished_by_hash.get(file_hash):
+                # Byte-identical file under a new name: keep its chunks and embeddings
+                old_path = vanished_by_hash[file_hash].pop()
+                del vanished[old_path]
+                self.rag.move_file_chunks(
+                    self._relative_path(old_path, source_path), self._relative_path(path, source_path), repo
+                )
+                self.state_manager.move_file(old_path, path)
+                self.stats['files_moved'] += 1
+                self.stats['paths_moved'].append(
+                    (self._relative_path(old_path, source_path), self._relative_path(path, source_path))
+                )
+            else:
+                added_paths.add(path)
+                self.stats['paths_added'].append(self._relative_path(path, source_path))
+                files_to_process.append((Path(path), lang))
+        
+        # Files gone from disk: purge everything they contributed
+        for path in vanished:
+            self.stats['chunks_removed'] += self.rag.delete_file_chunks(self._relative_path(path, source_path), repo)
+            self.state_manager.remove_file(path)
+            self.stats['paths_removed'].append(self._relative_path(path, source_path))
+        
+        self.logger.info(
+            f"{len(files_to_process)} files to (re)index, {len(vanished)} removed, "
+            f"{self.stats['files_moved']} moved, {self.stats['files_skipped']} unchanged",
+            extra={'phase': 'discovering', 'files_to_process': len(files_to_process), 'files_removed': len(vanished),
+                   'files_moved': self.stats['files_moved'], 'files_skipped': self.stats['files_skipped']}
+        )
+        
+        # Cross-file passes need whole packages: reprocess siblings of changed or removed files
+        files_to_process = self._expand_linked_packages(files_to_process, current, source_path, repo,
+                                                        removed=[Path(path) for path in vanished])
+        if files_to_process:
+            if not self._process_files(files_to_process, source_path, batch_size, parallel, max_tokens,
+                                       workers, granularity, repo):
+                return self.stats
+        
+        def inserted(paths):
+            return sum(self._file_chunk_counts.get(self._relative_path(p, source_path), 0) for p in paths)
+        
+        self.stats['chunks_added'] = inserted(added_paths)
+        self.stats['chunks_updated'] = inserted(updated_paths)
+        self.stats['chunks_by_file'] = dict(self._file_chunk_counts)
+        
+        if self.stats['chunks_removed'] or self.stats['files_moved']:
+            self.rag._build_keyword_index()
+        
+        self.stats['timings'] = self.tracer.summary(INDEX_STAGES)
+        if report:
+            print_success("Index up to date!")
+            self._print_statistics()
+        return self.stats
+    
+    def index_roots(self, roots: List[Tuple[Optional[str], str]], update: bool = False,
+                    **options) -> Dict[str, Dict]:
+        """
+        Index several repositories into the one collection
+        
+        Each root is indexed (or updated) on its own, with its label recorded on
+        every chunk: paths stay relative to their root and chunk ids include the
+        label, so the same relative path can occur in several repositories.
+        Cross-file linking (Go packages, C++ declarations) stays within a root.
+        
+        Args:
+            roots: (label, path) pairs; a root without a label is named after its directory
+            update: Run update_index instead of index_directory
+            **options: Passed on to index_directory / update_index
+        
+        Returns:
+            Statistics of each root, by label (only the roots reached, if cancelled)
+        
+        Raises:
+            ValueError: If a label is invalid or given to two roots
+        """
+        labeled = []
+        for label, path in roots:
+            label = label or Path(path).resolve().name
+            if not REPO_LABEL.match(label):
+                raise ValueError(f"Invalid repository label: {label!r} (letters, digits, '.', '_' and '-')")
+            if any(label == other for other, _ in labeled):
+                raise ValueError(f"Repository label used twice: {label}")
+            labeled.append((label, path))
+        
+        run = self.update_index if update else self.index_directory
+        cancel = options.get('cancel')
+        results = {}
+        for label, path in labeled:
+            if cancel is not None and cancel.cancelled:
+                break  # the roots not reached are left as they were
+            results[label] = run(path, repo=label, **options)
+        return results
+    
+    def _update_scope(self, paths: List[str], recorded: Dict[str, str]) -> List[Tuple[Path, bool]]:
+        """
+        Directories an update limited to paths looks at, as (directory, recursive)
+        
+        A directory (on disk, or with recorded files under it) is taken with its
+        subtree; a file stands for its own directory, without subdirectories.
+        """
+        scope = []
+        for path in map(Path, paths):
+            prefix = str(path).rstrip(os.sep) + os.sep
+            if path.is_dir() or any(p.startswith(prefix) for p in recorded):
+                scope.append((path, True))
+            else:
+                scope.append((path.parent, False))
+        return scope
+    
+    def _reset_stats(self, cancel: Optional[CancelToken] = None,
+                     progress: Optional[ProgressCallback] = None):
+        """Reset statistics for a new run, which reports to progress and stops when cancel is"""
+        self.stats = {
+            'files_processed': 0,
+            'files_skipped': 0,
+            'files_failed': 0,
+            'files_ignored': 0,
+            'files_too_large': [],
+            'files_streamed': 0,
+            'files_constrained': 0,
+            'files_generated': 0,
+            'files_relinked': 0,
+            'chunks_created': 0,
+            'chunks_too_small': 0,
+            'chunks_excluded': 0,
+            'chunks_dropped_processors': 0,
+            'files_by_type': defaultdict(int),
+            'chunks_by_type': defaultdict(int),
+            'files_partial': [],
+            'secrets_found': 0,
+            'chunks_redacted': 0,
+            'chunks_skipped_secrets': 0,
+            'secret_findings': [],
+            'embedding_failures': [],
+            # Files whose chunks could not all be embedded: left as they were, for the next update
+            'files_to_retry': [],
+            'errors': [],
+            'cancelled': None,
+            # Per-stage calls, items and seconds of the run (see Tracer.summary)
+            'timings': {}
+        }
+        self._file_chunk_counts = {}
+        self._cancel = cancel
+        self._on_progress = progress
+        self.progress = IndexProgress()
+        # Files of the run not recorded as indexed yet: relative path -> (path, diagnostics,
+        # chunks left to insert or None while unknown, whether any were inserted)
+        self._awaiting: Dict[str, Dict] = {}
+        # Files of the run with chunks of a previous version stored: relative path -> repo
+        self._replacing: Dict[str, Optional[str]] = {}
+        # Chunks the RAG system stored as duplicates of others before this run
+        self._deduplicated_before = getattr(self.rag, 'chunks_deduplicated', 0)
+        self.tracer.reset(INDEX_STAGES)
+        
+        embedder = getattr(self.rag, 'embedder', None)
+        for kind in (CachedEmbedder, RateLimitedEmbedder):
+            wrapper = find_embedder(embedder, kind)
+            if wrapper is not None:
+                wrapper.reset_stats()
+    
+    def _check_embedder(self) -> bool:
+        """Check the embedding backend before spending time on parsing"""
+        try:
+            dimension = self.rag.validate_embedder()
+            self.logger.info(f"Embedding with {self.rag.embedder} ({dimension} dimensions)")
+            return True
+        except Exception as e:
+            print_error(f"Embedding backend unavailable: {e}")
+            self.stats['errors'].append(str(e))
+            return False
+    
+    def _relative_path(self, file_path, source_path: Path) -> str:
+        """Path as stored in chunk metadata (relative to the indexed root)"""
+        try:
+            return str(Path(file_path).relative_to(source_path))
+        except ValueError:
+            return str(file_path)
+    
+    def _expand_linked_packages(self, files: List[tuple], current: Dict, source_path: Path,
+                                repo: Optional[str] = None, removed: List[Path] = ()) -> List[tuple]:
+        """
+        Add unchanged files that share a directory with a changed (or removed) file
+        of a linked language
+        """
+        dirs = {(fp.parent, PACKAGE_LINKERS[lang]) for fp, lang in files if lang in PACKAGE_LINKERS}
+        for fp in removed:
+            lang = self.languages.language(fp, self._relative_path(fp, source_path))
+            if lang in PACKAGE_LINKERS:
+                dirs.add((fp.parent, PACKAGE_LINKERS[lang]))
+        if not dirs:
+            return files
+        
+        selected = {str(fp) for fp, _ in files}
+        expanded = list(files)
+        for path, (lang, _) in current.items():
+            if path not in selected and (Path(path).parent, PACKAGE_LINKERS.get(lang)) in dirs:
+                # Its chunks are rebuilt with fresh cross-file metadata; it is forgotten
+                # first, so a run that dies before storing them again re-parses it
+                self.state_manager.remove_file(path)
+                self._replacing[self._relative_path(path, source_path)] = repo
+                self.stats['files_skipped'] -= 1
+                self.stats['files_relinked'] += 1
+                expanded.append((Path(path), lang))
+        return expanded
+    
+    def _process_files(self, files_to_process: List[tuple], source_path: Path,
+                       batch_size: int, parallel: bool, max_tokens: int,
+                       workers: Optional[int] = None, granularity: Optional[str] = None,
+                       repo: Optional[str] = None) -> bool:
+        """
+        Chunk, link and insert files
+        
+        A file is recorded in the state manager once all its chunks are stored.
+        When the run is cancelled (or interrupted), the chunks already stored for
+        files that are not complete are removed again.
+        
+        Returns:
+            False if cancelled or interrupted by the user
+        """
+        # Prepare arguments for worker, with each language's chunking parameters
+        params = {}
+        for lang in sorted({lang for _, lang in files_to_process}):
+            params[lang] = chunking_params(lang, max_tokens, granularity, self.min_lines, self.min_tokens)
+            self.logger.debug(f"Chunking {lang}: " + self._describe_params(params[lang]))
+        worker_args = [(str(fp), lang, str(source_path), params[lang]['max_tokens'],
+                        params[lang]['granularity'], repo, params[lang]['token_overlap'],
+                        self.generated_patterns if self.generated == 'tag' else None, self.blame,
+                        self.max_file_bytes if self.large_files == 'stream' else 0,
+                        self.languages.detections.get(str(fp)), params[lang]['overlap_strategy'])
+                       for fp, lang in files_to_process]
+        # Last commit of each file, for the recency boost (else its mtime)
+        git_times = git_modified_times(source_path) if CONFIG.recency_source == 'git' else {}
+        for processor in self.processors:
+            processor.start(source_path)
+        self._report(stage='parsing', files_total=len(files_to_process))
         
         # Process files
         with create_progress_bar() as progress:
             task = progress.add_task("[cyan]Processing files...", total=len(files_to_process))
+            embed_task = progress.add_task("[cyan]Embedding chunks...", total=None)
+            
+            def insert(chunks, stored=None, prefetched=None):
+                self._insert(chunks, stored, prefetched)
+                progress.update(embed_task, completed=self.progress.chunks_embedded,
+                                total=self.progress.chunks_produced)
+            
+            # With embedding workers, the next batches are embedded while files are still
+            # parsed; batches are stored in the order they were produced, all from this thread
+            pipeline = self._embedding_pipeline(insert)
+            
+            def store(chunks):
+                if pipeline is None:
+                    insert(chunks)
+                else:
+                    # Secrets are redacted before the text is embedded
+                    pipeline.submit((chunks, self._scan_secrets(chunks)))
             
             batch = []
+            deferred = defaultdict(list)  # linker -> chunks awaiting it
             
             # Use multiprocessing if parallel is True and we have enough files
-            use_parallel = parallel and len(files_to_process) > 10
-            cpu_count = max(1, multiprocessing.cpu_count() - 1)
+            workers = parse_worker_count(workers)
+            use_parallel = parallel and workers > 1 and len(files_to_process) > 10
             
             if use_parallel:
-                self.logger.info(f"Starting parallel processing with {cpu_count} workers")
-                pool = multiprocessing.Pool(processes=cpu_count)
-                iterator = pool.imap_unordered(process_file_worker, worker_args, chunksize=10)
+                # Workers only parse; results come back in input order and every
+                # shared structure (stats, state, batches) is touched by this process alone,
+                # so the index is identical to a sequential run
+                self.logger.info(f"Starting parallel processing with {workers} workers")
+                pool = multiprocessing.Pool(processes=workers, initializer=ignore_interrupts)
+                chunksize = max(1, min(10, len(worker_args) // (workers * 4)))
+                iterator = pool.imap(timed_file_worker, worker_args, chunksize=chunksize)
             else:
                 self.logger.info("Using sequential processing")
-                iterator = map(process_file_worker, worker_args)
+                iterator = map(timed_file_worker, worker_args)
             
+            stopped = False
             try:
-                for file_path, language, chunks, error in iterator:
+                for (file_path, language, chunks, error, diagnostics), start, duration in iterator:
+                    if self._cancel_requested():
+                        stopped = True
+                        break
+                    
                     # Print current file being processed
                     rel_path = Path(file_path).relative_to(source_path) if Path(file_path).is_relative_to(source_path) else Path(file_path).name
-                    self.logger.info(f"Processing: {rel_path} ({language})")
+                    # Timed in the worker, recorded here so hooks never run in other processes
+                    self.tracer.record('parse', duration, items=len(chunks), start=start,
+                                       filepath=str(rel_path), language=language,
+                                       **({'error': 'ParseError'} if error else {}))
+                    fields = {'phase': 'parsing', 'file': str(rel_path), 'language': language}
+                    self.logger.info(f"Processing: {rel_path} ({language})",
+                                     extra=dict(fields, chunks=len(chunks), duration=duration))
+                    self.logger.debug(f"Chunked {rel_path} with " + self._describe_params(params[language]), extra=fields)
                     
                     if error:
-                        self.logger.error(f"Failed to process {file_path}: {error}")
+                        self.logger.error(f"Failed to process {file_path}: {error}", extra=fields)
                         self.stats['files_failed'] += 1
                         self.stats['errors'].append(f"{file_path}: {error}")
                     else:
-                        # Add to batch
-                        batch.extend(chunks)
+                        modified = file_modified_time(file_path, str(rel_path), git_times)
+                        for chunk in chunks:
+                            chunk.modified = modified
+                        
+                        # Add to batch (or hold back for cross-file linking, which still sees small
+                        # and excluded symbols)
+                        if language not in PACKAGE_LINKERS:
+                            chunks = self._postprocess(self._drop_excluded(self._drop_small(chunks, params[language])))
+                        if language in PACKAGE_LINKERS:
+                            deferred[PACKAGE_LINKERS[language]].extend(chunks)
+                        else:
+                            batch.extend(chunks)
                         
                         # Update stats
                         self.stats['files_processed'] += 1
@@ -221,63 +988,421 @@ class ChromeIndexer:
                         for chunk in chunks:
                             self.stats['chunks_by_type'][chunk.type] += 1
                         
-                        # Mark as processed in state manager
-                        self.state_manager.mark_processed(file_path)
+                        # The file is kept with what parsed; its problems go into the summary
+                        if diagnostics:
+                            self.stats['files_partial'].append(
+                                {'filepath': str(rel_path), 'diagnostics': diagnostics}
+                            )
+                        
+                        # Recorded in the state manager once its chunks are stored
+                        # (linked chunks are counted again after the linker has run)
+                        self._await_file(file_path, self._relative_path(file_path, source_path), diagnostics,
+                                         None if language in PACKAGE_LINKERS else len(chunks))
                         
                         # Insert batch if it's large enough
                         if len(batch) >= batch_size:
-                            self.rag.add_chunks_batch(batch)
+                            store(batch)
                             batch = []
                     
+                    self._report(files_parsed=self.progress.files_parsed + 1,
+                                 chunks_produced=self.progress.chunks_produced + len(chunks),
+                                 current_file=str(rel_path))
                     progress.update(task, advance=1)
-                    
+            
             except KeyboardInterrupt:
-                print_warning("\nIndexing interrupted by user")
-                if use_parallel:
-                    pool.terminate()
-                    pool.join()
-                return self.stats
+                stopped = True
+                if self._cancel is None:
+                    self._cancel = CancelToken()
+                self._cancel.cancel('interrupted')
+            except BaseException:
+                if pipeline is not None:
+                    pipeline.cancel()
+                raise
             finally:
                 if use_parallel:
-                    pool.close()
+                    if stopped:
+                        pool.terminate()
+                    else:
+                        pool.close()
                     pool.join()
             
+            if stopped or self._cancel_requested():
+                if pipeline is not None:
+                    pipeline.cancel()
+                self._stop(repo)
+                return False
+            
+            # Link held-back chunks now that all their packages are complete
+            if deferred:
+                self._report(stage='linking', current_file=None)
+            linked = []
+            for linker, chunks in deferred.items():
+                with self.tracer.span('link', items=len(chunks), linker=linker.__name__) as span:
+                    try:
+                        chunks = self._uncount(chunks, linker(chunks))
+                    except Exception as e:
+                        span.attributes['error'] = type(e).__name__
+                        self.logger.error(f"Failed to link chunks ({linker.__name__}): {e}",
+                                          extra={'phase': 'linking', 'linker': linker.__name__})
+                        self.stats['errors'].append(f"{linker.__name__}: {e}")
+                # Split per language (C and C++ share a linker but not necessarily a budget)
+                for lang in dict.fromkeys(chunk.language for chunk in chunks):
+                    own = [chunk for chunk in chunks if chunk.language == lang]
+                    lang_params = params.get(lang) or chunking_params(lang, max_tokens, granularity,
+                                                                      self.min_lines, self.min_tokens)
+                    own = self._uncount(own, self._drop_excluded(self._drop_small(own, lang_params)))
+                    parts = split_chunks(own, lang_params['max_tokens'], lang_params['token_overlap'],
+                                         lang_params['overlap_strategy'])
+                    self.stats['chunks_created'] += len(parts) - len(own)
+                    linked.extend(self._uncount(parts, self._postprocess(parts)))
+            self._count_linked(linked)
+            batch.extend(linked)
+            
             # Insert remaining chunks
-            if batch:
-                self.rag.add_chunks_batch(batch)
+            self._report(stage='storing', current_file=None)
+            try:
+                for chunks in file_batches(batch, batch_size):
+                    if self._cancel_requested():
+                        if pipeline is not None:
+                            pipeline.cancel()
+                        self._stop(repo)
+                        return False
+                    store(chunks)
+                if pipeline is not None:
+                    pipeline.flush()
+                    pipeline.close()
+            except BaseException:
+                if pipeline is not None:
+                    pipeline.cancel()
+                raise
         
-        # Print final statistics
-        print_success(f"Indexing complete!")
-        self._print_statistics()
+        self._report(stage='done')
+        return True
+    
+    def _cancel_requested(self) -> bool:
+        """True once the run's cancel token is cancelled"""
+        return self._cancel is not None and self._cancel.cancelled
+    
+    def _report(self, **changes):
+        """Update the run's progress and pass it to the progress callback"""
+        for key, value in changes.items():
+            setattr(self.progress, key, value)
+        if self._on_progress is not None:
+            self._on_progress(self.progress)
+    
+    def _stop(self, repo: Optional[str] = None) -> Dict:
+        """
+        End a cancelled run: remove what was stored of files that are not complete,
+        so the index only holds whole files, all recorded in the state manager
+        (modified files not stored yet keep their previous chunks)
+        """
+        for rel_path, entry in self._awaiting.items():
+            if entry['inserted']:
+                self.rag.delete_file_chunks(rel_path, repo)
+        self._awaiting = {}
+        self._replacing = {}
         
+        reason = (self._cancel.reason if self._cancel is not None else None) or 'cancelled'
+        self.stats['cancelled'] = reason
+        self.stats['timings'] = self.tracer.summary(INDEX_STAGES)
+        self._report(stage='cancelled')
+        print_warning(f"\nIndexing {reason}: files not completed were left out of the index")
         return self.stats
     
-    def _discover_files(self, root_path: Path, file_types: Optional[List[str]] = None) -> List[tuple]:
+    def _await_file(self, file_path: str, rel_path: str, diagnostics: List[Dict], chunk_count: Optional[int]):
+        """Hold back recording a parsed file until its chunk_count chunks are stored (None: not known yet)"""
+        self._awaiting[rel_path] = {
+            'path': file_path, 'diagnostics': diagnostics, 'remaining': chunk_count, 'inserted': False
+        }
+        if chunk_count == 0:
+            self._mark_indexed(rel_path)
+    
+    def _uncount(self, chunks: List, linked: List) -> List:
+        """
+        Take the chunks a linker left out (Go package clauses merged into a summary),
+        or the minimum size or exclude_symbols did, off the counts
+        """
+        kept = {id(chunk) for chunk in linked}
+        dropped = [chunk for chunk in chunks if id(chunk) not in kept]
+        for chunk in dropped:
+            self.stats['chunks_by_type'][chunk.type] -= 1
+        self.stats['chunks_created'] -= len(dropped)
+        self._report(chunks_produced=self.progress.chunks_produced - len(dropped))
+        return linked
+    
+    def _drop_small(self, chunks: List, params: Dict) -> List:
+        """Leave out the functions and methods below a language's minimum size, counting them"""
+        kept = drop_small_symbols(chunks, params['min_chunk_lines'], params['min_chunk_tokens'])
+        if len(kept) < len(chunks):
+            self.stats['chunks_too_small'] += len(chunks) - len(kept)
+            names = {id(chunk) for chunk in kept}
+            self.logger.debug("Left out small symbols: " + ', '.join(
+                f"{chunk.filepath}:{chunk.qualified_name}" for chunk in chunks if id(chunk) not in names))
+        return kept
+    
+    def _drop_excluded(self, chunks: List) -> List:
+        """Leave out the symbols matching an exclude_symbols pattern, counting them"""
+        kept = drop_excluded_symbols(chunks, self.exclude_symbols)
+        if len(kept) < len(chunks):
+            self.stats['chunks_excluded'] += len(chunks) - len(kept)
+            names = {id(chunk) for chunk in kept}
+            self.logger.debug("Left out excluded symbols: " + ', '.join(
+                f"{chunk.filepath}:{chunk.qualified_name}" for chunk in chunks if id(chunk) not in names))
+        return kept
+    
+    def _postprocess(self, chunks: List) -> List:
+        """Run the chunk processors on chunks, counting those they drop and recording their errors"""
+        kept, errors = run_processors(self.processors, chunks)
+        if len(kept) < len(chunks):
+            self.stats['chunks_dropped_processors'] += len(chunks) - len(kept)
+        for error in errors:
+            self.logger.error(f"Chunk processor failed: {error}", extra={'phase': 'processing'})
+            self.stats['errors'].append(error)
+        return kept
+    
+    def _count_linked(self, chunks: List):
+        """Set the chunk counts of files whose chunks went through a linker"""
+        counts = defaultdict(int)
+        for chunk in chunks:
+            counts[chunk.filepath] += 1
+        for rel_path, entry in list(self._awaiting.items()):
+            if entry['remaining'] is None:
+                entry['remaining'] = counts.get(rel_path, 0)
+                if not entry['remaining']:
+                    self._mark_indexed(rel_path)
+    
+    def _mark_indexed(self, rel_path: str):
+        """Record a file whose chunks are all stored in the state manager"""
+        self._replace_previous(rel_path)
+        entry = self._awaiting.pop(rel_path)
+        self.state_manager.mark_processed(entry['path'], diagnostics=entry['diagnostics'])
+    
+    @staticmethod
+    def _describe_params(params: Dict) -> str:
+        """Chunking parameters as written to the log"""
+        described = (f"max_tokens={params['max_tokens']}, token_overlap={params['token_overlap']}, "
+                     f"overlap_strategy={params['overlap_strategy']}, granularity={params['granularity'].value}")
+        for key in ('min_chunk_lines', 'min_chunk_tokens'):
+            if params[key]:
+                described += f", {key}={params[key]}"
+        return described
+    
+    def _embedding_pipeline(self, insert) -> Optional[EmbeddingPipeline]:
+        """
+        Pipeline embedding batches ahead of insert, or None to embed each batch in line
+        (the requested workers are 0, or the RAG system deduplicates, which only knows
+        what to embed once the batch is stored)
+        """
+        workers = embed_worker_count(getattr(self.rag, 'embedder', None), self.embed_workers)
+        if not workers or getattr(self.rag, 'dedup', 'off') != 'off':
+            return None
+        self.logger.info(f"Embedding with {workers} background workers")
+        return EmbeddingPipeline(lambda batch: self.rag.prefetch_embeddings(batch[1]),
+                                 lambda batch, vectors: insert(batch[0], batch[1], vectors), workers)
+    
+    def _insert(self, chunks: List, stored: Optional[List] = None, prefetched: Optional[Dict] = None):
+        """
+        Write chunks to the database, counting them per file and recording the files completed
+        (stored: the chunks left to store after the secret scan, if it already ran;
+        prefetched: their vectors, embedded ahead)
+        
+        Batches hold whole files, and each is stored whole or not at all: every text is
+        embedded before anything is written, a file with a chunk that could not be
+        embedded keeps its previous chunks and is left for the next update to retry,
+        and the previous chunks of the others are only removed once their new ones are
+        stored (right before, in a deduplicated index), so searches running meanwhile
+        always find each file's old or new version.
+        """
+        files = list(dict.fromkeys(chunk.filepath for chunk in chunks))
+        if stored is None:
+            stored = self._scan_secrets(chunks)
+        failures = getattr(self.rag, 'embedding_failures', [])
+        failed_before = len(failures)
+        stage = getattr(self.rag, 'stage_embeddings', None)
+        if stored and stage is not None:
+            replacing = [repo_filepath(self._replacing[rel_path], rel_path)
+                         for rel_path in files if rel_path in self._replacing]
+            prefetched = stage(stored, prefetched, replacing)
+        failed = {failure['filepath'] for failure in failures[failed_before:]}
+        self._record_failures(failures[failed_before:])
+        stored = [chunk for chunk in stored if chunk.filepath not in failed]
+        
+        # Deduplicated chunks may be listed under the previous ones, so those go first
+        replace_first = getattr(self.rag, 'dedup', 'off') != 'off'
+        for rel_path in files:
+            if rel_path in failed:
+                continue
+            if replace_first:
+                self._replace_previous(rel_path)
+            if rel_path in self._awaiting:
+                self._awaiting[rel_path]['inserted'] = True
+        staged = len(failures)
+        if stored:
+            if prefetched is None:
+                self.rag.add_chunks_batch(stored)
+            else:
+                self.rag.add_chunks_batch(stored, prefetched=prefetched)
+        if not replace_first:
+            new_ids = defaultdict(list)
+            for chunk in stored:
+                new_ids[chunk.filepath].append(chunk.chunk_id())
+            for rel_path in files:
+                if rel_path not in failed:
+                    self._replace_previous(rel_path, keep=new_ids[rel_path])
+        for chunk in stored:
+            self._file_chunk_counts[chunk.filepath] = self._file_chunk_counts.get(chunk.filepath, 0) + 1
+        # Failures while storing (a body whose only stored copy was just replaced):
+        # those files are retried by the next update as well
+        for failure in failures[staged:]:
+            self._file_chunk_counts[failure['filepath']] -= 1
+        self._record_failures(failures[staged:])
+        # Skipped chunks count as done for their files
+        for chunk in chunks:
+            entry = self._awaiting.get(chunk.filepath)
+            if entry and entry['remaining']:
+                entry['remaining'] -= 1
+        
+        for rel_path in files:
+            entry = self._awaiting.get(rel_path)
+            if entry and entry['remaining'] == 0:
+                self._mark_indexed(rel_path)
+        self._report(chunks_embedded=self.progress.chunks_embedded + len(chunks))
+    
+    def _record_failures(self, failures: List[Dict]):
+        """
+        Count chunks that could not be embedded, and keep their files out of the state
+        manager (and their previous chunks in the index), so the next update tries them again
+        """
+        for failure in failures:
+            self.stats['embedding_failures'].append(failure)
+            self.stats['errors'].append(f"{failure['filepath']}:{failure['line']}: "
+                                        f"could not embed {failure['name']}: {failure['error']}")
+            self._awaiting.pop(failure['filepath'], None)
+            self._replacing.pop(failure['filepath'], None)
+            if failure['filepath'] not in self.stats['files_to_retry']:
+                self.stats['files_to_retry'].append(failure['filepath'])
+    
+    def _replace_previous(self, rel_path: str, keep: Optional[List[str]] = None):
+        """
+        Remove the chunks a previous version of a file left in the index (once per run),
+        except those whose ids are in keep (the new version's, already stored)
+        """
+        if rel_path in self._replacing:
+            self.rag.delete_file_chunks(rel_path, self._replacing.pop(rel_path), keep=keep)
+    
+    def _scan_secrets(self, chunks: List) -> List:
+        """
+        Redact the secrets in chunks, or leave out the chunks holding any, as self.secrets says
+        Returns the chunks to store; every secret found is recorded in the stats (never its text)
+        """
+        if self.secrets == 'off':
+            return chunks
+        stored = []
+        for chunk in chunks:
+            findings = scan_chunk(chunk, redact=self.secrets == 'redact', min_length=CONFIG.secret_min_length,
+                                  min_entropy=CONFIG.secret_min_entropy)
+            if findings:
+                self.stats['secrets_found'] += len(findings)
+                self.stats['secret_findings'].extend(findings)
+                if self.secrets == 'skip':
+                    self.stats['chunks_skipped_secrets'] += 1
+                    self.logger.debug(f"Skipping {chunk.location}:{chunk.line_start} {chunk.name} "
+                                      f"({len(findings)} secrets)")
+                    continue
+                self.stats['chunks_redacted'] += 1
+            stored.append(chunk)
+        return stored
+    
+    def _discover_files(self, root_path: Path, file_types: Optional[List[str]] = None,
+                        scope: Optional[List[Tuple[Path, bool]]] = None) -> List[tuple]:
         """
         Recursively discover all supported files
+        
+        Skips ignored paths (built-in directories, .gitignore rules and extra
+        patterns), binary files and files over the size cap. With a scope (see
+        _update_scope), only the directories leading to it are walked, so the
+        .gitignore files above it still apply.
         """
         files = []
-        extensions = CONFIG.get_all_extensions()
+        rules = IgnoreRules(root_path, self.ignore_patterns, use_gitignore=self.use_gitignore)
         
         for dirpath, dirnames, filenames in os.walk(root_path):
-            # Filter out excluded directories
-            dirnames[:] = [d for d in dirnames if not CONFIG.should_exclude_dir(d)]
+            if self._cancel_requested():
+                break
+            directory = Path(dirpath)
+            rules.enter(directory)
             
-            for filename in filenames:
-                file_path = Path(dirpath) / filename
-                ext = file_path.suffix
+            # Prune excluded directories so the walk never enters them; sorted so
+            # files are always processed (and chunk IDs assigned) in the same order
+            dirnames[:] = [
+                d for d in sorted(dirnames)
+                if not (self.use_default_ignores and CONFIG.should_exclude_dir(d))
+                and not rules.is_ignored(directory / d, is_dir=True)
+                and (scope is None or _leads_into_scope(directory / d, scope))
+            ]
+            
+            for filename in sorted(filenames):
+                file_path = directory / filename
+                if scope is not None and not _in_scope(file_path, scope):
+                    continue
+                language = self.languages.language(file_path, file_path.relative_to(root_path))
                 
-                if ext in extensions:
-                    language = CONFIG.get_language_for_extension(ext)
-                    
+                if language:
                     # Filter by file type if specified
                     if file_types and language not in file_types:
                         continue
                     
+                    if rules.is_ignored(file_path):
+                        continue
+                    reason = skip_reason(file_path, self.max_file_bytes)
+                    streamed = reason == 'too large' and self.large_files == 'stream'
+                    if streamed:
+                        # Streaming holds a window of the file at a time, up to its own cap
+                        reason = skip_reason(file_path, self.max_stream_file_bytes)
+                    if reason == 'too large':
+                        self._skip_large_file(file_path, root_path)
+                        continue
+                    if reason:
+                        self.logger.debug(f"Skipping {file_path} ({reason})")
+                        self.stats['files_ignored'] += 1
+                        continue
+                    # Go files outside the target build are never parsed
+                    if language == 'go' and not self.go_build.includes(file_path):
+                        self.logger.debug(f"Skipping {file_path} (excluded by {self.go_build})")
+                        self.stats['files_constrained'] += 1
+                        continue
+                    if self.generated == 'skip':
+                        reason = generated_reason(str(file_path.relative_to(root_path)), read_head(file_path),
+                                                  language, self.generated_patterns)
+                        if reason:
+                            self.logger.debug(f"Skipping {file_path} (generated: {reason})")
+                            self.stats['files_generated'] += 1
+                            continue
+                    
+                    if streamed:
+                        self.logger.info(f"Streaming {file_path} as plain text ({file_path.stat().st_size} bytes, "
+                                         f"over the {self.max_file_bytes}-byte cap for parsing)")
+                        self.stats['files_streamed'] += 1
                     files.append((file_path, language))
         
+        if self.stats['files_ignored']:
+            self.logger.info(f"Skipped {self.stats['files_ignored']} binary or oversized files")
+        if self.stats['files_constrained']:
+            self.logger.info(f"Skipped {self.stats['files_constrained']} Go files excluded by build constraints")
+        if self.stats['files_generated']:
+            self.logger.info(f"Skipped {self.stats['files_generated']} generated files")
         return files
     
+    def _skip_large_file(self, file_path: Path, root_path: Path):
+        """Leave out a file over the size cap, with a warning naming it and its size"""
+        cap = self.max_stream_file_bytes if self.large_files == 'stream' else self.max_file_bytes
+        size = file_path.stat().st_size
+        self.logger.warning(f"Skipping {file_path}: {size} bytes, over the {cap}-byte cap"
+                            + (" (large_files = 'stream' indexes it as text)" if self.large_files == 'skip' else ""),
+                            extra={'phase': 'discovery', 'file': str(file_path), 'bytes': size})
+        self.stats['files_ignored'] += 1
+        self.stats['files_too_large'].append({'filepath': str(file_path.relative_to(root_path)), 'bytes': size})
+    
     def _print_statistics(self):
         """Print detailed indexing statistics"""
         stats_dict = {
@@ -285,9 +1410,62 @@ class ChromeIndexer:
             "Files Processed": self.stats['files_processed'],
             "Files Skipped (Up-to-date)": self.stats['files_skipped'],
             "Files Failed": self.stats['files_failed'],
+            "Files Parsed Partially": len(self.stats['files_partial']),
+            "Files Skipped (Binary/Too Large)": self.stats['files_ignored'],
+            "Files Skipped (Go Build Constraints)": self.stats['files_constrained'],
+            "Files Streamed as Text (Too Large to Parse)": self.stats['files_streamed'],
             "Total Chunks Created": self.stats['chunks_created'],
         }
         
+        if self.generated == 'skip':
+            stats_dict["Files Skipped (Generated)"] = self.stats['files_generated']
+        
+        if self.stats['chunks_too_small']:
+            stats_dict["Chunks Skipped (Too Small)"] = self.stats['chunks_too_small']
+        
+        if self.stats['chunks_excluded']:
+            stats_dict["Chunks Skipped (Excluded Symbols)"] = self.stats['chunks_excluded']
+        
+        if self.stats['chunks_dropped_processors']:
+            stats_dict["Chunks Skipped (Processors)"] = self.stats['chunks_dropped_processors']
+        
+        if self.stats['files_to_retry']:
+            stats_dict["Files Left for Retry (Not Embedded)"] = len(self.stats['files_to_retry'])
+        
+        if self.stats['secrets_found']:
+            stats_dict["Secrets Found"] = self.stats['secrets_found']
+            if self.secrets == 'skip':
+                stats_dict["Chunks Skipped (Secrets)"] = self.stats['chunks_skipped_secrets']
+            else:
+                stats_dict["Chunks Redacted"] = self.stats['chunks_redacted']
+        
+        deduplicated = getattr(self.rag, 'chunks_deduplicated', 0) - self._deduplicated_before
+        if deduplicated:
+            self.stats['chunks_deduplicated'] = deduplicated
+            stats_dict["Chunks Stored as Duplicates"] = deduplicated
+        
+        embedder = getattr(self.rag, 'embedder', None)
+        cached = find_embedder(embedder, CachedEmbedder)
+        if cached is not None:
+            self.stats['embedding_cache_hits'] = cached.hits
+            self.stats['embedding_cache_misses'] = cached.misses
+            stats_dict["Embedding Cache Hits"] = cached.hits
+            stats_dict["Embedding Cache Misses"] = cached.misses
+        
+        # How hard the embedding backend pushed back
+        limited = find_embedder(embedder, RateLimitedEmbedder)
+        if limited is not None:
+            summary = limited.summary()
+            self.stats['embedding_requests'] = summary
+            stats_dict["Embedding Requests"] = summary['requests']
+            for key, label in (('retries', "Embedding Retries"), ('throttled', "Embedding Requests Throttled"),
+                               ('batches_split', "Embedding Batches Retried per Text"),
+                               ('texts_failed', "Texts Not Embedded")):
+                if summary[key]:
+                    stats_dict[label] = summary[key]
+            if summary['throttle_wait']:
+                stats_dict["Embedding Throttle Wait"] = f"{summary['throttle_wait']:.1f}s"
+        
         if self.stats['files_processed'] > 0:
             stats_dict["Avg Chunks/File"] = f"{self.stats['chunks_created'] / self.stats['files_processed']:.2f}"
         
@@ -295,7 +1473,62 @@ class ChromeIndexer:
         for lang, count in self.stats['files_by_type'].items():
             stats_dict[f"Files ({lang})"] = count
         
+        if self.verbose:
+            stats_dict.update(format_timings(self.stats['timings']))
+        
         print_stats(stats_dict)
+        # The same counts as one record, for whatever reads the logs
+        self.logger.info("Indexing statistics", extra={
+            'phase': 'done',
+            'files_processed': self.stats['files_processed'],
+            'files_skipped': self.stats['files_skipped'],
+            'files_failed': self.stats['files_failed'],
+            'files_partial': len(self.stats['files_partial']),
+            'files_too_large': len(self.stats['files_too_large']),
+            'files_streamed': self.stats['files_streamed'],
+            'chunks_created': self.stats['chunks_created'],
+            'chunks_too_small': self.stats['chunks_too_small'],
+            'chunks_excluded': self.stats['chunks_excluded'],
+            'chunks_dropped_processors': self.stats['chunks_dropped_processors'],
+            'chunks_not_embedded': len(self.stats['embedding_failures']),
+            'files_to_retry': len(self.stats['files_to_retry']),
+            'errors': len(self.stats['errors']),
+            'durations': {stage: round(totals['total'], 3) for stage, totals in self.stats['timings'].items()}
+        })
+        
+        # Files indexed without the parts that did not parse
+        if self.stats['files_partial']:
+            print_warning(f"{len(self.stats['files_partial'])} files had syntax errors; indexed what parsed")
+            for partial in self.stats['files_partial'][:10]:
+                first = partial['diagnostics'][0]
+                more = len(partial['diagnostics']) - 1
+                self.logger.warning(f"{partial['filepath']}:{first['line']}: {first['message']}"
+                                    + (f" (+{more} more)" if more else ""),
+                                    extra={'phase': 'parsing', 'file': partial['filepath'], 'line': first['line'],
+                                           'diagnostics': len(partial['diagnostics'])})
+        
+        # Where secrets were found, for an audit (the indexed text no longer holds them)
+        if self.stats['secret_findings']:
+            action = 'skipped' if self.secrets == 'skip' else 'redacted'
+            print_warning(f"{self.stats['secrets_found']} secrets found; their chunks were {action}")
+            for finding in self.stats['secret_findings'][:10]:
+                self.logger.warning(f"{finding['filepath']}:{finding['line']}: {finding['kind']}",
+                                    extra={'phase': 'parsing', 'file': finding['filepath'], 'line': finding['line'],
+                                           'secret': finding['kind']})
+            if len(self.stats['secret_findings']) > 10:
+                print_warning(f"  ... {len(self.stats['secret_findings']) - 10} more")
+        
+        # Chunks left out: their files are indexed again by the next update
+        if self.stats['embedding_failures']:
+            failures = self.stats['embedding_failures']
+            print_warning(f"{len(failures)} chunks could not be embedded; their "
+                          f"{len(self.stats['files_to_retry'])} files kept their previous chunks "
+                          f"and will be retried")
+            for failure in failures[:10]:
+                self.logger.warning(f"{failure['filepath']}:{failure['line']}: {failure['error']}",
+                                    extra={'phase': 'storing', 'file': failure['filepath'], 'line': failure['line']})
+            if len(failures) > 10:
+                print_warning(f"  ... {len(failures) - 10} more")
         
         # Log errors if any
         if self.stats['errors']:
@@ -0,0 +1,299 @@
+#!/usr/bin/env python3
+"""
+Model Context Protocol (MCP) server over stdio, started with `cli.py mcp`
+Lets coding agents query the index through two tools:
+
+    search_code   hybrid search with optional language / kind / path / repository / dependency filters
+    get_symbol    one symbol by file and name, with surrounding source lines
+
+Messages are newline-delimited JSON-RPC 2.0 on stdin/stdout; logs go to stderr.
+"""
+
+import json
+import sys
+from pathlib import Path
+from typing import Dict, List, Optional, TextIO
+
+from chunkers.base_chunker import qualified_name
+from rag import ChromeRAGSystem
+from utils.logger import console, get_logger
+from utils.path_scope import normalize_scopes
+from utils.result_limits import check_result_limits
+from utils.symbol_neighbors import NEIGHBOR_MODES
+
+
+# Newest first; a client asking for another version is answered with the newest
+PROTOCOL_VERSIONS = ['2025-06-18', '2025-03-26', '2024-11-05']
+SERVER_INFO = {'name': 'chrome-rag', 'version': '1.0.0'}
+
+TOOLS = [
+    {
+        'name': 'search_code',
+        'description': 'Search the indexed codebase with hybrid semantic + keyword retrieval. '
+                       'Returns ranked code chunks with file paths and line ranges.',
+        'inputSchema': {
+            'type': 'object',
+            'properties': {
+                'query': {'type': 'string', 'description': 'What to look for (natural language or identifiers)'},
+                'top_k': {'type': 'integer', 'minimum': 1, 'default': 5, 'description': 'Number of results'},
+                'languages': {'type': 'array', 'items': {'type': 'string'}, 'description': 'Only these languages (e.g. go, cpp)'},
+                'kinds': {'type': 'array', 'items': {'type': 'string'},
+                          'description': 'Only these symbol kinds: function, method, type, interface, const, var, table, query'},
+                'path_globs': {'type': 'array', 'items': {'type': 'string'}, 'description': 'Only files matching these globs'},
+                'scope': {'type': 'string',
+                          'description': 'Only files under this directory of the repository (e.g. internal/auth)'},
+                'repos': {'type': 'array', 'items': {'type': 'string'},
+                          'description': 'Only these repositories (labels shown in results of a multi-repo index)'},
+                'uses': {'type': 'array', 'items': {'type': 'string'},
+                         'description': 'Only code using these imported packages or members (sync.RWMutex, github.com/pkg/errors)'},
+                'min_score': {'type': 'number',
+                              'description': 'Drop results less relevant than this (0 to 1, e.g. 0.35); '
+                                             'no result above it means the codebase has no good match'},
+                'with_neighbors': {'type': 'string', 'enum': list(NEIGHBOR_MODES),
+                                   'description': 'Also list the symbols defined right before and after each '
+                                                  "result in its file; 'bodies' includes their code"},
+            },
+            'required': ['query'],
+        },
+    },
+    {
+        'name': 'get_symbol',
+        'description': 'Fetch the full definition of a symbol by file path and name '
+                       '(e.g. AdminUser.Authenticate), with surrounding source lines.',
+        'inputSchema': {
+            'type': 'object',
+            'properties': {
+                'filepath': {'type': 'string', 'description': 'File path as shown in search results'},
+                'name': {'type': 'string', 'description': 'Symbol name or qualified name (Type.Method)'},
+                'repo': {'type': 'string', 'description': 'Repository label as shown in search results, if any'},
+                'context_lines': {'type': 'integer', 'minimum': 0, 'default': 5,
+                                  'description': 'Source lines to include before and after the symbol'},
+                'include_methods': {'type': 'boolean', 'default': False,
+                                    'description': 'For a type, also list the signatures of the methods declared on it (Go)'},
+            },
+            'required': ['filepath', 'name'],
+        },
+    },
+]
+
+# JSON-RPC error codes
+PARSE_ERROR = -32700
+INVALID_REQUEST = -32600
+METHOD_NOT_FOUND = -32601
+INVALID_PARAMS = -32602
+
+
+class ToolError(Exception):
+    """A tool call that failed in a way the agent should see (returned with isError)"""
+
+
+class MCPServer:
+    """Dispatches MCP JSON-RPC messages to the RAG system"""
+    
+    def __init__(self, rag: ChromeRAGSystem, source_path: Optional[str] = None):
+        """
+        Args:
+            rag: RAG system over the index to query
+            source_path: Root the index was built from; needed for get_symbol's surrounding lines
+                (labeled repositories of a multi-repo index use the roots they were indexed from)
+        """
+        self.rag = rag
+        self.source_path = Path(source_path) if source_path else None
+        self.logger = get_logger()
+    
+    def serve(self, stdin: TextIO = sys.stdin, stdout: TextIO = sys.stdout):
+        """Answer messages from stdin until it closes"""
+        # Anything else printing to stdout would corrupt the protocol stream
+        saved_stdout, saved_console = sys.stdout, console.file
+        sys.stdout = console.file = sys.stderr
+        try:
+            for line in stdin:
+                if not line.strip():
+                    continue
+                response = self.handle_line(line)
+                if response is not None:
+                    stdout.write(json.dumps(response) + '\n')
+                    stdout.flush()
+        finally:
+            sys.stdout, console.file = saved_stdout, saved_console
+    
+    def handle_line(self, line: str) -> Optional[Dict]:
+        """Response to one raw message, or None for notifications"""
+        try:
+            message = json.loads(line)
+        except json.JSONDecodeError as e:
+            return _error(None, PARSE_ERROR, f"Parse error: {e}")
+        return self.handle(message)
+    
+    def handle(self, message: Dict) -> Optional[Dict]:
+        """Response to one JSON-RPC message, or None for notifications"""
+        if not isinstance(message, dict) or message.get('jsonrpc') != '2.0' or 'method' not in message:
+            return _error(message.get('id') if isinstance(message, dict) else None,
+                          INVALID_REQUEST, "Invalid JSON-RPC request")
+        
+        method = message['method']
+        params = message.get('params') or {}
+        if 'id' not in message:
+            return None  # notification (e.g. notifications/initialized): no reply
+        request_id = message['id']
+        
+        if method == 'initialize':
+            requested = params.get('protocolVersion')
+            return _result(request_id, {
+                'protocolVersion': requested if requested in PROTOCOL_VERSIONS else PROTOCOL_VERSIONS[0],
+                'capabilities': {'tools': {}},
+                'serverInfo': SERVER_INFO,
+            })
+        if method == 'ping':
+            return _result(request_id, {})
+        if method == 'tools/list':
+            return _result(request_id, {'tools': TOOLS})
+        if method == 'tools/call':
+            return self._call_tool(request_id, params)
+        return _error(request_id, METHOD_NOT_FOUND, f"Method not found: {method}")
+    
+    def _call_tool(self, request_id, params: Dict) -> Dict:
+        name = params.get('name')
+        arguments = params.get('arguments') or {}
+        tools = {'search_code': self._search_code, 'get_symbol': self._get_symbol}
+        if name not in tools:
+            return _error(request_id, INVALID_PARAMS, f"Unknown tool: {name}")
+        
+        try:
+            content = tools[name](arguments)
+            return _result(request_id, {'content': content, 'isError': False})
+        except ToolError as e:
+            return _result(request_id, {'content': [_text(str(e))], 'isError': True})
+        except Exception as e:
+            self.logger.error(f"Tool {name} failed: {e}")
+            return _result(request_id, {'content': [_text(f"{name} failed: {e}")], 'isError': True})
+    
+    def _search_code(self, arguments: Dict) -> List[Dict]:
+        query = arguments.get('query')
+        if not isinstance(query, str) or not query.strip():
+            raise ToolError("'query' must be a non-empty string")
+        
+        min_score = arguments.get('min_score')
+        if min_score is not None and (isinstance(min_score, bool) or not isinstance(min_score, (int, float))):
+            raise ToolError("'min_score' must be a number")
+        try:
+            scope = normalize_scopes(arguments.get('scope')) or None
+        except ValueError as e:
+            raise ToolError(str(e))
+        with_neighbors = arguments.get('with_neighbors')
+        if with_neighbors is not None and with_neighbors not in NEIGHBOR_MODES:
+            raise ToolError(f"'with_neighbors' must be one of: {', '.join(NEIGHBOR_MODES)}")
+        top_k = int(arguments.get('top_k', 5))
+        try:
+            check_result_limits(top_k, name="'top_k'")
+        except ValueError as e:
+            raise ToolError(str(e))
+        
+        results = self.rag.retrieve_context(
+            query=query,
+            n_results=top_k,
+            languages=arguments.get('languages'),
+            kinds=arguments.get('kinds'),
+            path_globs=arguments.get('path_globs'),
+            repos=arguments.get('repos'),
+            uses=arguments.get('uses'),
+            min_score=min_score,
+            scope=scope,
+            with_neighbors=with_neighbors
+        )
+        if not results:
+            if min_score is not None:
+                return [_text(f"No results for: {query} (none reach min_score {min_score})")]
+            return [_text(f"No results for: {query}")]
+        return [_text(_format_chunk(r['metadata'], r['content'], rank=i) + _format_neighbors(r['metadata'], r.get('neighbors')))
+                for i, r in enumerate(results, 1)]
+    
+    def _get_symbol(self, arguments: Dict) -> List[Dict]:
+        filepath, name = arguments.get('filepath'), arguments.get('name')
+        if not filepath or not name:
+            raise ToolError("'filepath' and 'name' are required")
+        context_lines = max(int(arguments.get('context_lines', 5)), 0)
+        
+        # Match the bare name in the index, then the qualified name here (Type.Method)
+        bare_name = name.rsplit('.', 1)[-1]
+        conditions = [{"filepath": filepath}, {"name": bare_name}]
+        if arguments.get('repo'):
+            conditions.append({"repo": arguments['repo']})
+        found = self.rag.collection.get(where={"$and": conditions})
+        chunks = [c for c in self.rag._format_get_results(found)
+                  if name in (c['metadata'].get('name'), qualified_name(c['metadata']))]
+        if not chunks:
+            raise ToolError(f"No symbol '{name}' indexed in {filepath}")
+        
+        content = []
+        for symbol_id in dict.fromkeys(c['metadata'].get('symbol_id') for c in chunks):
+            symbol = self.rag.retrieve_full_symbol(symbol_id) if symbol_id else None
+            symbol = symbol or next(c for c in chunks if c['metadata'].get('symbol_id') == symbol_id)
+            content.append(_text(self._with_context(symbol, context_lines)))
+            if arguments.get('include_methods'):
+                methods = self.rag.symbol_methods(symbol['metadata'])
+                if methods:
+                    content.append(_text(_format_methods(symbol['metadata'], methods)))
+        return content
+    
+    def _with_context(self, symbol: Dict, context_lines: int) -> str:
+        """Symbol text, widened with surrounding lines from the source file when available"""
+        metadata = symbol['metadata']
+        root = self.rag.repo_roots().get(metadata['repo']) if metadata.get('repo') else self.source_path
+        source_file = Path(root) / metadata['filepath'] if root else None
+        if not context_lines or not source_file or not source_file.is_file():
+            return _format_chunk(metadata, symbol['content'])
+        
+        lines = source_file.read_text(encoding='utf-8', errors='ignore').splitlines()
+        start = max(int(metadata['line_start']) - context_lines, 1)
+        end = min(int(metadata['line_end']) + context_lines, len(lines))
+        return _format_chunk(metadata, "\n".join(lines[start - 1:end]), lines=(start, end))
+
+
+def _format_chunk(metadata: Dict, code: str, rank: Optional[int] = None, lines=None) -> str:
+    """Code with a source attribution header"""
+    start, end = lines or (metadata.get('line_start', '?'), metadata.get('line_end', '?'))
+    header = f"{metadata.get('filepath', 'unknown')}:{start}-{end} {qualified_name(metadata)} ({metadata.get('type', 'unknown')})"
+    if metadata.get('repo'):
+        header += f" in {metadata['repo']}"
+    if metadata.get('duplicate_count'):
+        header += f" (also appears in {metadata['duplicate_count']} places)"
+    if rank is not None:
+        header = f"[{rank}] {header}"
+    return f"{header}\n```{metadata.get('language', '')}\n{code.rstrip()}\n```"
+
+
+def _format_neighbors(metadata: Dict, neighbors: Optional[Dict]) -> str:
+    """The symbols before and after a result, one line each, followed by their code when it was fetched"""
+    if not neighbors:
+        return ''
+    lines, bodies = [], []
+    for label, key in (('Before', 'previous'), ('After', 'next')):
+        neighbor = neighbors.get(key)
+        if not neighbor:
+            continue
+        lines.append(f"{label}: {neighbor['qualified_name'] or neighbor['name']} ({neighbor['type']}, "
+                     f"{neighbor['filepath']}:{neighbor['line_start']}-{neighbor['line_end']})")
+        if neighbor.get('content') is not None:
+            bodies.append(f"```{metadata.get('language', '')}\n{neighbor['content'].rstrip()}\n```")
+    return ''.join(f"\n{part}" for part in lines + bodies)
+
+
+def _format_methods(metadata: Dict, methods: List[Dict]) -> str:
+    """The methods declared on a type, one signature and location per line"""
+    lines = [f"Methods of {metadata.get('name')}:"]
+    for method in methods:
+        lines.append(f"- {method['signature'] or method['qualified_name']}  ({method['filepath']}:{method['line_start']})")
+    return "\n".join(lines)
+
+
+def _text(text: str) -> Dict:
+    return {'type': 'text', 'text': text}
+
+
+def _result(request_id, result: Dict) -> Dict:
+    return {'jsonrpc': '2.0', 'id': request_id, 'result': result}
+
+
+def _error(request_id, code: int, message: str) -> Dict:
+    return {'jsonrpc': '2.0', 'id': request_id, 'error': {'code': code, 'message': message}}
@@ -0,0 +1,19 @@
+#!/bin/sh
+# Generate the stubs of proto/code_rag.proto
+#   gen/python  messages and service for grpc_server.py (pip install grpcio-tools)
+#   gen/go      messages and client for Go services (protoc with protoc-gen-go and
+#               protoc-gen-go-grpc on PATH; skipped when they are missing)
+set -e
+cd "$(dirname "$0")/.."
+
+mkdir -p gen/python gen/go/coderagv1
+python -m grpc_tools.protoc -Iproto --python_out=gen/python --grpc_python_out=gen/python proto/code_rag.proto
+echo "Generated gen/python/code_rag_pb2.py and gen/python/code_rag_pb2_grpc.py"
+
+if command -v protoc >/dev/null && command -v protoc-gen-go >/dev/null && command -v protoc-gen-go-grpc >/dev/null; then
+    protoc -Iproto --go_out=gen/go/coderagv1 --go_opt=paths=source_relative \
+        --go-grpc_out=gen/go/coderagv1 --go-grpc_opt=paths=source_relative proto/code_rag.proto
+    echo "Generated gen/go/coderagv1/code_rag.pb.go and gen/go/coderagv1/code_rag_grpc.pb.go"
+else
+    echo "protoc, protoc-gen-go or protoc-gen-go-grpc not found: Go stubs not generated" >&2
+fi
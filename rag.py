@@ -4,55 +4,447 @@ Chrome Source Code RAG System - Refactored
 Professional vector database management for Chrome source code
 """
 
-from typing import List, Dict, Optional
+from typing import Dict, Iterable, Iterator, List, Optional, Set, TextIO, Tuple, Union
 from collections import defaultdict
-import chromadb
-from chromadb.utils import embedding_functions
+from fnmatch import fnmatch
+import json
+import os
+import re
+import secrets
+import threading
+import time
 
-from chunkers.base_chunker import CodeChunk
+from chunkers.base_chunker import CodeChunk, parse_metadata, qualified_name, repo_filepath, stored_chunk_id
+from chunkers.go_imports import uses_dependency
+from chunkers.go_tests import TEST_KINDS
+from chunkers.token_splitter import stitch_parts
 from config import CONFIG
+from embedders import (KEYWORD_ONLY_MODEL, Embedder, EmbeddingError, PartialEmbeddingError, create_embedder,
+                       create_model_embedders)
+from rerankers import Reranker, RerankError, create_reranker
+from stores import VectorStore, create_store, similarity
+from utils.cancellation import CancelToken
+from utils.chunk_dedup import (DEDUP_MODES, content_hash, duplicate_entry, in_file, locations,
+                               parse_duplicates, set_duplicates)
+from utils.chunk_processors import EMBEDDING_TEXT_KEY
+from utils.code_normalization import NORMALIZATIONS, normalization_for, normalize_code
+from utils.code_tokenizer import tokenize_code, tokenizer_rules
+from utils.embedding_migration import MIGRATION_SUFFIX, MigrationCallback, MigrationProgress, stored_chunk
+from utils.embedding_templates import (DOCUMENT_FIELDS, QUERY_FIELDS, check_template, render_document,
+                                       render_query)
+from utils.filter_expression import FilterExpression, Term, parse_filter
+from utils.generated_code import check_generated_weight
 from utils.logger import get_logger
+from utils.match_highlights import highlight_spans
+from utils.path_priors import check_weight, depth_factor, recency_factor
+from utils.path_scope import normalize_scopes, resolve_scopes
+from utils.query_cache import QueryCache, freeze, normalize_query
+from utils.query_expansion import SYNONYM_GROUPS, build_synonyms, expand_query as expansion_terms, load_synonym_groups
+from utils.related_symbols import RELATION_KINDS, call_graph, related_symbols, symbol_key
+from utils.result_pages import decode_cursor, encode_cursor, search_fingerprint
+from utils.result_format import with_snippet
+from utils.result_limits import check_result_limits
+from utils.result_types import SearchResult
+from utils.score_fusion import SIGNALS, Fuser, create_fuser
+from utils.search_explain import matched_filters, term_contributions
+from utils.symbol_lookup import lookup_symbols
+from utils.surrounding_context import declaration_header, enclosing_type_name, file_imports
+from utils.symbol_neighbors import NEIGHBOR_MODES, adjacent_symbols, group_symbols, neighbor_entry
+from utils.symbol_references import REFERENCE_KINDS, mention_lines, names_symbol, reference_kinds
+from utils.chunk_ingest import read_records
+from utils.jsonl_export import export_record, write_jsonl
+from utils.index_stats import count_chunks, indexed_files
+from utils.index_snapshot import (LOAD_SUFFIX, MODELS_DIR, SIGNATURES_DIR, SnapshotError, read_manifest, read_snapshot,
+                                  staged_snapshot, write_snapshot)
+from utils.tracing import Tracer
 
 
 from rank_bm25 import BM25Okapi
 import numpy as np
 
+
+# Symbol kinds accepted by search filters, mapped to the chunk types chunkers emit
+# (any other kind is matched against the chunk type verbatim)
+SYMBOL_KINDS = {
+    'function': ['function', 'func', 'procedure'],
+    'method': ['method', 'rpc'],
+    'type': ['type', 'struct', 'class', 'enum', 'union', 'record', 'typedef', 'alias', 'trait', 'protocol', 'object',
+             'actor', 'delegate', 'module', 'message'],
+    'interface': ['interface', 'service'],
+    'const': ['const', 'constant', 'macro'],
+    'var': ['var', 'variable', 'field', 'property'],
+    'table': ['table', 'view'],
+    'query': ['query'],
+}
+
+# Chunk kinds, apart from symbol kinds: code, code of test files (Go test functions by
+# their kind: test, benchmark, fuzz, example), plain-text blocks; all but 'source' can
+# be selected as kinds too
+CHUNK_KINDS = ('source',) + TEST_KINDS + ('text',)
+
+
+# What a chunk's vector is computed from (see CONFIG.embedding_mode)
+EMBEDDING_MODES = ('code', 'doc', 'signature', 'dual')
+
+# Fields of a chunk's BM25 document, weighted by CONFIG.keyword_field_weights
+KEYWORD_FIELDS = ('name', 'doc', 'body')
+
+# Vector indexes a search can use: a dual index has both
+VECTOR_INDEXES = ('code', 'signature', 'both')
+
+# Times a search is ranked when a re-index replaces chunks it ranked before their content is read
+SEARCH_ATTEMPTS = 3
+
+# Collection holding the signature vectors of a dual index, next to the main one
+SIGNATURE_COLLECTION_SUFFIX = '_signatures'
+
+# Name of the embedder an index is built with, among the models a search ranks by;
+# extra embedding models keep their vectors in collections named <collection>_model_<name>
+PRIMARY_MODEL = 'primary'
+MODEL_COLLECTION_INFIX = '_model_'
+MODEL_NAME_PATTERN = re.compile(r'^[A-Za-z0-9_-]+$')
+
+
+def chunk_types_for_kinds(kinds: List[str]) -> Set[str]:
+    """Chunk types matched by a list of symbol kinds"""
+    types = set()
+    for kind in kinds:
+        types.update(SYMBOL_KINDS.get(kind, [kind]))
+    return types
+
+
+def symbol_kind(chunk_type: str) -> str:
+    """Symbol kind a chunk type belongs to (struct -> type); other types are their own kind"""
+    return next((kind for kind, types in SYMBOL_KINDS.items() if chunk_type in types), chunk_type)
+
+
+# Chunk types that are symbols with a signature (what signature embedding applies to)
+SYMBOL_CHUNK_TYPES = chunk_types_for_kinds(list(SYMBOL_KINDS))
+
+# Chunk types a method can belong to (whose header surrounding context shows)
+TYPE_CHUNK_TYPES = chunk_types_for_kinds(['type', 'interface'])
+
+
+def _merge_vector_rankings(rankings: List[List[Dict]]) -> List[Dict]:
+    """One vector ranking from several: each chunk ranked by its closest vector"""
+    if len(rankings) == 1:
+        return rankings[0]
+    best: Dict[str, Dict] = {}
+    for ranking in rankings:
+        for result in ranking:
+            current = best.get(result['id'])
+            if current is None or result['distance'] < current['distance']:
+                # Keep the code of a main-collection hit even when its signature is closer
+                if current is not None and current['content'] != "Content not stored in RAM":
+                    result = dict(current, distance=result['distance'])
+                best[result['id']] = result
+    return sorted(best.values(), key=lambda r: r['distance'])
+
+
+def _fuse_model_rankings(rankings: Dict[str, List[Dict]], k: int) -> List[Dict]:
+    """
+    One vector ranking from the rankings of several embedding models
+    
+    Distances from different models do not compare, so chunks are ranked by
+    reciprocal rank fusion, the sum of 1 / (k + rank) over the models that found
+    them. A chunk keeps the distance of the first model listed that found it and
+    records its rank by each model in 'model_ranks'.
+    """
+    if len(rankings) == 1:
+        return next(iter(rankings.values()))
+    scores: Dict[str, float] = defaultdict(float)
+    merged: Dict[str, Dict] = {}
+    for name, ranking in rankings.items():
+        for rank, result in enumerate(ranking, 1):
+            scores[result['id']] += 1.0 / (k + rank)
+            entry = merged.setdefault(result['id'], dict(result, model_ranks={}))
+            entry['model_ranks'][name] = rank
+            if entry['content'] == "Content not stored in RAM":
+                entry['content'] = result['content']  # a signature hit: the code comes from another ranking
+    return sorted(merged.values(), key=lambda r: scores[r['id']], reverse=True)
+
+
+def _describe_models(models: Dict[str, str]) -> str:
+    """Extra embedding models as 'name=model, ...' ('none' when there are none)"""
+    return ', '.join(f"{name}={model}" for name, model in sorted(models.items())) or 'none'
+
+
+def _kind_boosts(boost_kinds: Dict[str, float]) -> List[Tuple[Term, float]]:
+    """Validated boost_kinds, as the kind term each factor applies to"""
+    boosts = []
+    for kind, factor in boost_kinds.items():
+        if isinstance(factor, bool) or not isinstance(factor, (int, float)) or factor <= 0:
+            raise ValueError(f"Boost for kind '{kind}' must be a positive number, got {factor!r}")
+        boosts.append((Term('kind', (kind,)), float(factor)))
+    return boosts
+
+
+def _apply_boosts(results: List[Dict], boosts: List[Tuple[Term, float]],
+                  depth_penalty: float = 0.0, recency_weight: float = 0.0,
+                  generated_weight: float = 1.0) -> List[Dict]:
+    """
+    Scale the fused score of each result by the boosts of its kinds, by the
+    path depth and recency priors (see utils.path_priors) and, for generated
+    code, by generated_weight, and re-sort
+    """
+    now = time.time()
+    for result in results:
+        metadata = result['metadata']
+        boost = 1.0
+        for term, factor in boosts:
+            if term.matches(metadata, SYMBOL_KINDS):
+                boost *= factor
+        if depth_penalty:
+            boost *= depth_factor(metadata.get('filepath', ''), depth_penalty)
+        if recency_weight:
+            boost *= recency_factor(metadata.get('modified'), recency_weight, CONFIG.recency_half_life_days, now)
+        if metadata.get('generated'):
+            boost *= generated_weight
+        if boost != 1.0:
+            result['rrf_score'] *= boost
+            result['boost'] = boost
+    return sorted(results, key=lambda r: r['rrf_score'], reverse=True)
+
+
+def _annotate_duplicates(results: List[Dict]):
+    """Attach to each result of a deduplicated index the other places its body appears"""
+    for result in results:
+        found = locations(result.get('metadata'))
+        if found:
+            result['duplicates'] = found
+            result['duplicate_count'] = len(found)
+
+
+def _check_neighbor_mode(mode: Optional[str]):
+    if mode is not None and mode not in NEIGHBOR_MODES:
+        raise ValueError(f"with_neighbors must be one of: {', '.join(NEIGHBOR_MODES)}")
+
+
+def _check_candidate_k(candidate_k: Optional[int], n_results: int):
+    if candidate_k is not None and candidate_k < n_results:
+        raise ValueError(f"candidate_k ({candidate_k}) must be at least n_results ({n_results})")
+
+
+def _keyword_weight(bm25, tokens: List[str]) -> float:
+    """
+    BM25 score of a document of average length containing each query token once:
+    the sum of their idf (tokens the corpus lacks count as its rarest term)
+    """
+    idf = getattr(bm25, 'idf', None) or {}
+    rarest = max(idf.values(), default=0.0)
+    return sum(max(idf.get(token, rarest), 0.0) for token in tokens)
+
+
+def _field_weights(weights: Dict) -> Dict[str, int]:
+    """Validated keyword field weights: a whole number of at least 0 for each of KEYWORD_FIELDS"""
+    unknown = set(weights) - set(KEYWORD_FIELDS)
+    if unknown:
+        raise ValueError(f"Unknown keyword field: {', '.join(sorted(unknown))} (expected one of: {', '.join(KEYWORD_FIELDS)})")
+    for field, weight in weights.items():
+        if isinstance(weight, bool) or not isinstance(weight, int) or weight < 0:
+            raise ValueError(f"Keyword field weight for '{field}' must be a whole number of at least 0, got {weight!r}")
+    return {field: weights.get(field, 1) for field in KEYWORD_FIELDS}
+
+
+def _ref_matches(ref: Dict, symbol_name: str) -> bool:
+    """True if a call reference names the symbol: 'Open' matches 'Store.Open' and 'db.Open'"""
+    name = ref.get('name', '')
+    if '.' in symbol_name:
+        return name == symbol_name
+    return name.split('.')[-1] == symbol_name
+
+
+def _unit(vector: List[float]) -> List[float]:
+    """Scale a vector to unit length (zero vectors are left as they are)"""
+    norm = sum(x * x for x in vector) ** 0.5
+    return [x / norm for x in vector] if norm else vector
+
+
+def _dot(a: List[float], b: List[float]) -> float:
+    return sum(x * y for x, y in zip(a, b))
+
+
 class ChromeRAGSystem:
     """
     Professional RAG system for Chrome source code
     Manages vector database operations with efficient batch processing
     """
     
-    def __init__(self, db_path: Optional[str] = None, collection_name: Optional[str] = None):
+    def __init__(self, db_path: Optional[str] = None, collection_name: Optional[str] = None,
+                 embedder: Optional[Embedder] = None, embed_doc_signature: Optional[bool] = None,
+                 store: Optional[VectorStore] = None, reranker: Optional[Reranker] = None,
+                 fuser: Optional[Fuser] = None, embedding_mode: Optional[str] = None, source_root: Optional[str] = None,
+                 normalize_embeddings: Optional[bool] = None,
+                 keyword_field_weights: Optional[Dict[str, int]] = None,
+                 dedup: Optional[str] = None,
+                 query_synonyms: Optional[List[List[str]]] = None,
+                 tracer: Optional[Tracer] = None, reduce_dimensions: Optional[int] = None,
+                 code_normalization: Optional[str] = None,
+                 embedding_models: Optional[Dict[str, Embedder]] = None,
+                 query_embedders: Optional[Dict[str, Embedder]] = None,
+                 document_template: Optional[str] = None, query_template: Optional[str] = None):
         """
         Initialize the RAG system
         
         Args:
-            db_path: Path to ChromaDB storage
+            db_path: Path to ChromaDB storage (chroma store)
             collection_name: Name of the collection to use
+            embedder: Embedding backend (defaults to CONFIG.embedding_backend)
+            embed_doc_signature: Shorthand for embedding_mode='doc'
+            store: Vector store backend (defaults to CONFIG.vector_store)
+            reranker: Cross-encoder applied to the top search candidates
+                (defaults to CONFIG.reranker_backend, which is 'none')
+            fuser: How searches combine the vector and keyword rankings (defaults to
+                CONFIG.fusion_method, reciprocal rank fusion; see utils/score_fusion.py)
+            embedding_mode: What chunk vectors are computed from, one of EMBEDDING_MODES
+                (defaults to the mode the collection was indexed with, else CONFIG.embedding_mode)
+            source_root: Directory the indexed paths are relative to, read for surrounding
+                context (defaults to CONFIG.source_root, else the directory last indexed)
+            normalize_embeddings: Scale vectors to unit length before storing and searching
+                (defaults to what the collection was indexed with, else CONFIG.normalize_embeddings)
+            keyword_field_weights: Times each field's tokens count in keyword search, by
+                KEYWORD_FIELDS name; fields left out keep CONFIG.keyword_field_weights
+            dedup: Store chunks with identical bodies once, one of DEDUP_MODES (defaults to
+                the mode the collection was indexed with, else CONFIG.dedup)
+            query_synonyms: Groups of interchangeable words for query expansion, on top of
+                the built-in ones, CONFIG.query_synonyms and CONFIG.query_synonyms_path
+            tracer: Records the timing of each indexing and search stage (see utils.tracing);
+                an indexer of this system records its stages in it too
+            reduce_dimensions: Keep only the first N components of every vector, 0 for all
+                (defaults to what the collection was indexed with, else CONFIG.reduce_dimensions);
+                meant for Matryoshka-trained models, whose leading components carry the most
+            code_normalization: How code is normalized before it is embedded and hashed for
+                dedup, one of NORMALIZATIONS (defaults to what the collection was indexed with,
+                else CONFIG.code_normalization; CONFIG.language_code_normalization overrides it
+                per language for a new index)
+            embedding_models: Extra embedders by name (defaults to CONFIG.embedding_models,
+                none unless configured), each keeping a vector of every chunk in its own
+                collection; searches pick among them and the PRIMARY_MODEL embedder.
+                An index keeps the models it was built with. Not available with dedup.
+            query_embedders: Embedders by name a search may embed its query with in place
+                of the primary one (defaults to CONFIG.query_embedders, none unless
+                configured); stored vectors still come from the primary embedder
+            document_template: Template of the text chunks are embedded from, and
+            query_template: of the text queries are embedded from, for every embedding model
+                (see utils/embedding_templates.py; default to what the collection was indexed
+                with, else CONFIG.embedding_document_template and CONFIG.embedding_query_template)
         """
         self.logger = get_logger()
         self.db_path = db_path or CONFIG.db_path
         self.collection_name = collection_name or CONFIG.collection_name
+        self.embedder = embedder or create_embedder()
+        self.reranker = reranker if reranker is not None else create_reranker()
+        self.fuser = fuser if fuser is not None else create_fuser()
+        self.source_root = source_root or CONFIG.source_root
+        self.tracer = tracer or Tracer()
         
-        # Initialize ChromaDB
-        self.logger.info(f"Initializing ChromaDB at {self.db_path}")
-        self.client = chromadb.PersistentClient(path=self.db_path)
+        # Open the vector store (vectors come from self.embedder, not from the store)
+        self.collection = store or create_store(collection_name=self.collection_name, db_path=self.db_path)
+        self.collection_name = self.collection.name
+        self.logger.info(f"Using {self.collection!r}")
         
-        # Use default embedding function
-        self.embedding_fn = embedding_functions.DefaultEmbeddingFunction()
+        # An index keeps the embedding mode it was built with unless another one is asked for
+        requested = embedding_mode or ('doc' if embed_doc_signature else None)
+        if requested not in EMBEDDING_MODES + (None,):
+            raise ValueError(f"Unknown embedding mode: {requested} (expected one of: {', '.join(EMBEDDING_MODES)})")
+        self.embedding_mode = requested or self._recorded_mode() or CONFIG.embedding_mode
+        self._signature_store: Optional[VectorStore] = None
         
-        # Get or create collection
-        self.collection = self.client.get_or_create_collection(
-            name=self.collection_name,
-            embedding_function=self.embedding_fn,
-            metadata={"description": "Chrome source code for vulnerability analysis"}
-        )
+        if dedup not in DEDUP_MODES + (None,):
+            raise ValueError(f"Unknown dedup mode: {dedup} (expected one of: {', '.join(DEDUP_MODES)})")
+        self.dedup = dedup or (self.collection.metadata or {}).get('dedup') or CONFIG.dedup
+        
+        self.embedding_models = create_model_embedders() if embedding_models is None else dict(embedding_models)
+        for name, model in self.embedding_models.items():
+            if name == PRIMARY_MODEL or not MODEL_NAME_PATTERN.match(name):
+                raise ValueError(f"Invalid embedding model name: {name!r} (letters, digits, '_' and '-', "
+                                 f"not '{PRIMARY_MODEL}')")
+            if model.model_name == KEYWORD_ONLY_MODEL:
+                raise ValueError(f"Embedding model '{name}' has no vectors (backend 'none')")
+        if self.embedding_models and self.dedup != 'off':
+            raise ValueError("Extra embedding models cannot be combined with dedup")
+        self.query_embedders = create_model_embedders(CONFIG.query_embedders) if query_embedders is None \
+            else dict(query_embedders)
+        for name, model in self.query_embedders.items():
+            if name == PRIMARY_MODEL or not MODEL_NAME_PATTERN.match(name):
+                raise ValueError(f"Invalid query embedder name: {name!r} (letters, digits, '_' and '-', "
+                                 f"not '{PRIMARY_MODEL}')")
+            if model.model_name == KEYWORD_ONLY_MODEL:
+                raise ValueError(f"Query embedder '{name}' has no vectors (backend 'none')")
+        self._model_stores: Dict[str, VectorStore] = {}
+        self._stores_lock = threading.Lock()  # collections are opened on first use, by any thread
+        
+        # An index keeps the code normalization it was built with, per-language overrides included
+        if code_normalization not in NORMALIZATIONS + (None,):
+            raise ValueError(f"Unknown code normalization: {code_normalization} "
+                             f"(expected one of: {', '.join(NORMALIZATIONS)})")
+        recorded = self.collection.metadata or {}
+        self.code_normalization = code_normalization or recorded.get('code_normalization') or CONFIG.code_normalization
+        overrides = recorded.get('language_code_normalization')
+        self.language_code_normalization = json.loads(overrides) if overrides is not None \
+            else dict(CONFIG.language_code_normalization)
+        unknown = set(self.language_code_normalization.values()) - set(NORMALIZATIONS)
+        if unknown:
+            raise ValueError(f"Unknown code normalization: {', '.join(sorted(unknown))} "
+                             f"(expected one of: {', '.join(NORMALIZATIONS)})")
+        # ...and the templates of the texts it embeds
+        self.document_template = check_template(
+            document_template or recorded.get('document_template') or CONFIG.embedding_document_template,
+            DOCUMENT_FIELDS, 'document_template')
+        self.query_template = check_template(
+            query_template or recorded.get('query_template') or CONFIG.embedding_query_template,
+            QUERY_FIELDS, 'query_template')
+        self.chunks_deduplicated = 0  # chunks stored as duplicates of another, since startup
+        # Chunks left out because the embedder failed their text, since startup:
+        # {'filepath', 'repo', 'line', 'name', 'error'}
+        self.embedding_failures: List[Dict] = []
+        
+        # The store searches by its metric; whether vectors are unit length is the index's choice
+        self.metric = getattr(self.collection, 'metric', 'cosine')
+        recorded = (self.collection.metadata or {}).get('normalized_embeddings')
+        if normalize_embeddings is not None:
+            self.normalize_embeddings = normalize_embeddings
+        elif recorded is not None:
+            self.normalize_embeddings = bool(recorded)
+        else:
+            self.normalize_embeddings = CONFIG.normalize_embeddings
+        recorded = (self.collection.metadata or {}).get('reduced_dimensions')
+        if reduce_dimensions is None:
+            reduce_dimensions = CONFIG.reduce_dimensions if recorded is None else int(recorded)
+        if not isinstance(reduce_dimensions, int) or reduce_dimensions < 0:
+            raise ValueError(f"reduce_dimensions must be a non-negative integer, got {reduce_dimensions!r}")
+        self.reduce_dimensions = reduce_dimensions
+        if self.reduce_dimensions:
+            self.logger.info(f"Vectors reduced to their first {self.reduce_dimensions} dimensions")
         
         self.logger.info(f"Collection '{self.collection_name}' ready")
-        self._chunk_counter = self.collection.count()
         
-        # Initialize BM25 index
+        self.keyword_field_weights = _field_weights({**CONFIG.keyword_field_weights, **(keyword_field_weights or {})})
+        
+        groups = SYNONYM_GROUPS + list(CONFIG.query_synonyms)
+        if CONFIG.query_synonyms_path:
+            groups += load_synonym_groups(CONFIG.query_synonyms_path)
+        self.synonyms = build_synonyms(groups + list(query_synonyms or []))
+        
+        # Initialize BM25 index (swapped as a whole under the lock, so searches
+        # running while it is rebuilt see either the old or the new index)
+        self._keyword_lock = threading.Lock()
+        # Rebuilds run one at a time, so a slow one never swaps in an index older than
+        # the one a later rebuild built; searches never wait on it
+        self._rebuild_lock = threading.Lock()
+        self._migration_lock = threading.Lock()  # held by a running migrate_embedder
+        
+        # Ranked results of recent searches; every change to the index bumps index_version,
+        # which is part of the cache key, so results cached before it are never served
+        self.query_cache = QueryCache(CONFIG.query_cache_size, CONFIG.query_cache_ttl)
+        self.index_version = 0
+        # Rankings of paged searches, by search and index version; the epoch tells the
+        # cursors of this instance from those of an earlier one at the same version
+        self.page_cache = QueryCache(CONFIG.page_cache_size, CONFIG.query_cache_ttl)
+        self._index_epoch = secrets.token_hex(4)
+        self._metadata_cache = None  # (index_version, metadatas) for symbol lookups
+        self._path_cache = None  # (index_version, sorted file paths) for path filters and scopes
+        
         self.bm25 = None
         self.bm25_corpus = []
         self.bm25_ids = []
@@ -60,37 +452,578 @@ class ChromeRAGSystem:
         self._build_keyword_index()
     
     def _build_keyword_index(self):
-        """Build BM25 index from existing documents in ChromaDB"""
-        self.logger.info("Building BM25 keyword index...")
-        try:
-            # Fetch all documents (this might be heavy for very large datasets)
-            # For production, we might want to cache this or load incrementally
-            all_docs = self.collection.get()
+        """Build BM25 index from existing documents in the vector store"""
+        with self._rebuild_lock:
+            self.logger.info("Building BM25 keyword index...")
+            try:
+                # Fetch all documents (this might be heavy for very large datasets)
+                # For production, we might want to cache this or load incrementally
+                all_docs = self.collection.get()
+                
+                if not all_docs['ids']:
+                    self.logger.info("No documents to index for BM25")
+                    with self._keyword_lock:
+                        self.bm25, self.bm25_ids, self.bm25_metadatas = None, [], []
+                    self._index_changed()
+                    return
+                
+                # Tokenize documents for BM25
+                tokenized_corpus = [
+                    self._keyword_tokens(doc, meta)
+                    for doc, meta in zip(all_docs['documents'], all_docs['metadatas'])
+                ]
+                bm25 = BM25Okapi(tokenized_corpus)
+                with self._keyword_lock:
+                    self.bm25, self.bm25_ids, self.bm25_metadatas = bm25, all_docs['ids'], all_docs['metadatas']
+                self._index_changed()
+                self.logger.info(f"BM25 index built with {len(all_docs['ids'])} documents")
             
-            if not all_docs['ids']:
-                self.logger.info("No documents to index for BM25")
+            except Exception as e:
+                self.logger.error(f"Failed to build BM25 index: {e}")
+    
+    def _index_changed(self):
+        """Invalidate cached search results (chunks or the keyword index changed)"""
+        with self._keyword_lock:
+            self.index_version += 1
+    
+    def _keyword_tokens(self, document: str, metadata: Optional[Dict]) -> List[str]:
+        """
+        Tokens for the BM25 index: those of each of its fields (see _keyword_fields),
+        repeated as many times as its keyword_field_weights
+        """
+        fields = self._keyword_fields(document, metadata)
+        tokens = []
+        for field in KEYWORD_FIELDS:
+            tokens.extend(fields[field] * self.keyword_field_weights[field])
+        return tokens
+    
+    def _keyword_fields(self, document: str, metadata: Optional[Dict]) -> Dict[str, List[str]]:
+        """
+        Tokens of each field of a chunk's BM25 document: the code, doc comment, symbol name,
+        names promoted or aliased to the chunk or that instantiate it, and the symbols its doc refers to,
+        split into words by the tokenizer rules of the chunk's language
+        """
+        metadata = metadata or {}
+        extra = parse_metadata(metadata.get('metadata'))
+        rules = tokenizer_rules(metadata.get('language'))
+        
+        def tokenize(text: str) -> List[str]:
+            return tokenize_code(text, rules)
+        
+        fields = {
+            'body': tokenize(document),
+            'doc': tokenize(metadata.get('doc') or ''),
+            # An exact identifier match ranks the symbol's definition first
+            'name': tokenize(qualified_name(metadata)),
+        }
+        
+        # Go types answer for the names their aliases give them
+        for alias in extra.get('aliases', []):
+            fields['name'].extend(tokenize(alias))
+        
+        # Go test functions answer for the symbol they test (TestAuthenticate -> Authenticate)
+        if extra.get('subject'):
+            fields['name'].extend(tokenize(extra['subject']))
+        
+        # Go docs answer for the symbols they link to or mention ("see [Authenticator]")
+        for entry in extra.get('doc_refs', []):
+            if entry['resolved']:
+                fields['doc'].extend(tokenize(entry['name']))
+        
+        # Go structs answer for fields/methods declared on the types they embed
+        for entry in extra.get('promoted_fields', []) + extra.get('promoted_methods', []):
+            fields['body'].extend(tokenize(entry['name']))
+        
+        # Go generics answer for the type arguments they are instantiated with (SessionManager[User])
+        for entry in extra.get('instantiated_by', []):
+            fields['body'].extend(tokenize(entry['instance']))
+        
+        # Go constants answer for their named type and the text their String method gives them,
+        # variables for the type they are initialized to and the functions that set them
+        if metadata.get('type') in ('const', 'var'):
+            fields['body'].extend(tokenize(extra.get('type') or extra.get('inferred_type') or ''))
+            fields['body'].extend(tokenize(extra.get('string') or ''))
+            for entry in extra.get('assigned_in', []):
+                fields['body'].extend(tokenize(entry['name']))
+        
+        # Kubernetes manifests answer for their kind (the Deployment named auth-service)
+        if metadata.get('type') == 'manifest':
+            fields['name'].extend(tokenize(extra.get('kind') or ''))
+        
+        return fields
+    
+    def set_embedder(self, embedder: Embedder):
+        """Switch embedding backend (validated against the collection on the next insert)"""
+        self.embedder = embedder
+    
+    def validate_embedder(self):
+        """
+        Fail fast if the embedder cannot serve this collection
+        Raises EmbeddingError on an unreachable backend, a dimension mismatch, or
+        an embedding mode, metric, normalization or extra embedding models other
+        than the collection's
+        """
+        self._check_mode()
+        self._check_metric()
+        dimension = self._reduced_dimension(self.embedder.dimensions())
+        self._check_dimension(dimension)
+        self._check_models()
+        for name, model in self.embedding_models.items():
+            self._check_model_dimension(name, model.dimensions())
+        return dimension
+    
+    @property
+    def keyword_only(self) -> bool:
+        """
+        True if searches rank by keyword matches alone: the index was built
+        without embeddings, or there is no embedder to embed the query with
+        """
+        recorded = (self.collection.metadata or {}).get('embedding_model')
+        return KEYWORD_ONLY_MODEL in (recorded, self.embedder.model_name)
+    
+    @property
+    def embedding_dimensions(self) -> int:
+        """Dimension of the collection's vectors (of the embedder's, reduced, before the first insert)"""
+        stored = (self.collection.metadata or {}).get('embedding_dimensions')
+        return int(stored) if stored is not None else self._reduced_dimension(self.embedder.dimensions())
+    
+    def embed_query(self, text: str) -> List[float]:
+        """
+        Vector of a query string, embedded as searches embed it: reduced and
+        normalized like the collection's vectors, so it compares with them
+        (exported with --with-embeddings) by the collection's metric
+        Raises EmbeddingError for a keyword-only index or an embedder the collection does not match
+        """
+        if self.keyword_only:
+            raise EmbeddingError(f"Collection '{self.collection_name}' is a keyword-only index; "
+                                 f"it has no vectors to compare a query vector with")
+        self._check_mode()
+        return self._embed([render_query(self.query_template, text)])[0]
+    
+    def _recorded_mode(self) -> Optional[str]:
+        """Embedding mode the collection was indexed with (None for older or empty collections)"""
+        return (self.collection.metadata or {}).get('embedding_mode')
+    
+    def _check_mode(self):
+        """Raise if the collection was indexed with another embedding mode"""
+        recorded = self._recorded_mode()
+        if recorded is not None and recorded != self.embedding_mode:
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' was indexed with embedding mode '{recorded}', "
+                f"not '{self.embedding_mode}'. Clear the collection or index with --embedding-mode {recorded}."
+            )
+    
+    def _check_metric(self):
+        """Raise if the collection was indexed for another distance metric or normalization"""
+        metadata = self.collection.metadata or {}
+        recorded = metadata.get('distance_metric')
+        if recorded is not None and recorded != self.metric:
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' was indexed for the '{recorded}' metric, "
+                f"but the store searches by '{self.metric}'. Clear the collection or use --metric {recorded}."
+            )
+        normalized = metadata.get('normalized_embeddings')
+        if normalized is not None and bool(normalized) != self.normalize_embeddings:
+            state = 'unit-length' if normalized else 'unnormalized'
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' holds {state} vectors; "
+                f"clear it before switching normalization"
+            )
+        normalization = metadata.get('code_normalization')
+        if normalization is not None and normalization != self.code_normalization:
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' embeds code normalized as '{normalization}', "
+                f"not '{self.code_normalization}'; clear it before changing the normalization"
+            )
+        template = metadata.get('document_template')
+        if template is not None and template != self.document_template:
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' embeds chunks as {template!r}, "
+                f"not {self.document_template!r}; clear it before changing the document template"
+            )
+        reduced = metadata.get('reduced_dimensions')
+        if reduced is not None and int(reduced) != self.reduce_dimensions:
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' keeps {reduced or 'all'} dimensions of each vector, "
+                f"not {self.reduce_dimensions or 'all'}; clear it before changing the reduction"
+            )
+        quantization = metadata.get('quantization')
+        if quantization is not None and quantization != self.collection.quantization:
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' holds {quantization} vectors, but the store "
+                f"keeps {self.collection.quantization}. Clear the collection or use --quantization {quantization}."
+            )
+    
+    def _reduced_dimension(self, dimension: int, keep: Optional[int] = None,
+                           embedder: Optional[Embedder] = None) -> int:
+        """Dimension of the vectors stored for the embedder's dimension (keep: the reduction, default own)"""
+        keep = self.reduce_dimensions if keep is None else keep
+        if not keep:
+            return dimension
+        if keep > dimension:
+            raise EmbeddingError(f"Cannot reduce the {dimension}-dimensional vectors of {embedder or self.embedder} "
+                                 f"to {keep} dimensions")
+        return keep
+    
+    def record_source_root(self, path: str, repo: Optional[str] = None):
+        """Remember the directory the collection (or one of its labeled repositories) is indexed from"""
+        metadata = dict(self.collection.metadata or {})
+        if repo:
+            roots = self.repo_roots()
+            if roots.get(repo) == path:
                 return
-            
-            self.bm25_ids = all_docs['ids']
-            self.bm25_metadatas = all_docs['metadatas']
-            
-            # Tokenize documents for BM25
-            tokenized_corpus = [doc.lower().split() for doc in all_docs['documents']]
-            self.bm25 = BM25Okapi(tokenized_corpus)
-            self.logger.info(f"BM25 index built with {len(self.bm25_ids)} documents")
-            
-        except Exception as e:
-            self.logger.error(f"Failed to build BM25 index: {e}")
-
-    def add_chunks_batch(self, chunks: List[CodeChunk]) -> int:
+            roots[repo] = path
+            metadata['repo_roots'] = json.dumps(roots, sort_keys=True)
+        elif metadata.get('source_root') != path:
+            metadata['source_root'] = path
+        else:
+            return
+        self.collection.modify(metadata=metadata)
+    
+    def repo_roots(self) -> Dict[str, str]:
+        """Directory of each labeled repository in the collection, by label"""
+        recorded = (self.collection.metadata or {}).get('repo_roots')
+        try:
+            return dict(json.loads(recorded)) if recorded else {}
+        except (TypeError, ValueError):
+            return {}
+    
+    @property
+    def signature_store(self) -> VectorStore:
+        """Collection of the signature vectors of a dual index"""
+        with self._stores_lock:
+            if self._signature_store is None:
+                self._signature_store = self.collection.open_collection(
+                    self.collection_name + SIGNATURE_COLLECTION_SUFFIX)
+            return self._signature_store
+    
+    def _primary_stores(self) -> List[VectorStore]:
+        """Collections holding the vectors of the primary embedder"""
+        return [self.collection, self.signature_store] if self.embedding_mode == 'dual' else [self.collection]
+    
+    def _stores(self) -> List[VectorStore]:
+        """Every collection that holds vectors for this index, extra embedding models' included"""
+        models = sorted(set(self.embedding_models) | set(self.recorded_models()))
+        return self._primary_stores() + [self.model_store(name) for name in models]
+    
+    def model_store(self, name: str) -> VectorStore:
+        """Collection of the vectors of an extra embedding model"""
+        with self._stores_lock:
+            if name not in self._model_stores:
+                self._model_stores[name] = self.collection.open_collection(
+                    self.collection_name + MODEL_COLLECTION_INFIX + name)
+            return self._model_stores[name]
+    
+    def recorded_models(self) -> Dict[str, str]:
+        """Extra embedding models the collection was indexed with: model name by name"""
+        recorded = (self.collection.metadata or {}).get('embedding_models')
+        try:
+            return dict(json.loads(recorded)) if recorded else {}
+        except (TypeError, ValueError):
+            return {}
+    
+    def embedding_model_info(self) -> Dict[str, Dict]:
+        """
+        Each extra embedding model of the index with its 'model', 'dimensions' (None
+        before its first vector), number of 'vectors' and 'vector_bytes', the raw size
+        of those vectors (4 bytes a component, 1 with int8 quantization); its
+        collection also keeps a copy of every chunk's code and metadata
+        """
+        component = 1 if self.collection.quantization == 'int8' else 4
+        info = {}
+        for name, model in sorted(self.recorded_models().items()):
+            store = self.model_store(name)
+            dimensions = (store.metadata or {}).get('embedding_dimensions')
+            count = store.count()
+            info[name] = {'model': model, 'dimensions': None if dimensions is None else int(dimensions),
+                          'vectors': count, 'vector_bytes': count * int(dimensions or 0) * component}
+        return info
+    
+    def _check_models(self):
+        """Raise if the collection was indexed with other extra embedding models"""
+        if (self.collection.metadata or {}).get('embedding_dimensions') is None:
+            return  # nothing stored yet: the first vectors record the models
+        recorded = self.recorded_models()
+        configured = {name: model.model_name for name, model in self.embedding_models.items()}
+        if recorded != configured:
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' was indexed with embedding models "
+                f"{_describe_models(recorded)}, not {_describe_models(configured)}. "
+                f"Clear the collection or configure the same embedding_models."
+            )
+    
+    def _check_model_dimension(self, name: str, dimension: int):
+        """Raise if an extra embedding model's collection holds vectors of another dimension"""
+        stored = (self.model_store(name).metadata or {}).get('embedding_dimensions')
+        if stored is not None and int(stored) != dimension:
+            raise EmbeddingError(
+                f"Embedding model '{name}' holds {stored}-dimensional vectors, "
+                f"but {self.embedding_models[name]} produces {dimension}. Clear the collection or switch back."
+            )
+    
+    def search_models(self, names: Optional[List[str]]) -> List[str]:
+        """
+        The embedding models a search ranks by (defaults to CONFIG.search_embedding_models)
+        Raises ValueError for a model that is not configured or that the index was built without
+        """
+        names = list(dict.fromkeys(CONFIG.search_embedding_models if names is None else names)) or [PRIMARY_MODEL]
+        recorded = self.recorded_models()
+        indexed = (self.collection.metadata or {}).get('embedding_dimensions') is not None
+        for name in names:
+            if name == PRIMARY_MODEL:
+                continue
+            if name not in self.embedding_models:
+                raise ValueError(f"Unknown embedding model '{name}' (expected one of: "
+                                 f"{', '.join([PRIMARY_MODEL] + sorted(self.embedding_models))})")
+            if indexed and recorded.get(name) != self.embedding_models[name].model_name:
+                raise ValueError(f"Collection '{self.collection_name}' was not indexed with embedding model "
+                                 f"'{name}' ({self.embedding_models[name].model_name}); indexed with "
+                                 f"{_describe_models(recorded)}")
+        return names
+    
+    def search_fuser(self, fusion: Union[str, Fuser, None] = None) -> Fuser:
+        """
+        The fuser a search combines its rankings with: fusion itself, the method of
+        utils.score_fusion it names, or self.fuser; ValueError for an unknown method
+        """
+        if fusion is None:
+            return self.fuser
+        if isinstance(fusion, Fuser):
+            return fusion
+        return create_fuser(fusion)
+    
+    def query_embedder(self, name: Optional[str], embedding_models: Optional[List[str]] = None) -> Optional[Embedder]:
+        """
+        The query embedder a search embeds its query with in place of the primary
+        embedder (None for the primary embedder itself)
+        
+        Args:
+            name: Name from query_embedders, or None
+            embedding_models: Models the search ranks by (see search_models); the
+                primary one must be among them
+        
+        Raises:
+            ValueError: For an unknown name, a search that does not rank by the primary
+                model, a keyword-only index, or vectors of another dimension than the index's
+        """
+        if name is None:
+            return None
+        if name not in self.query_embedders:
+            raise ValueError(f"Unknown query embedder '{name}' (expected one of: "
+                             f"{', '.join(sorted(self.query_embedders)) or 'none configured'})")
+        if PRIMARY_MODEL not in self.search_models(embedding_models):
+            raise ValueError(f"Query embedder '{name}' embeds queries for the '{PRIMARY_MODEL}' vectors, "
+                             f"which this search does not rank by")
+        if self.keyword_only:
+            raise ValueError(f"Collection '{self.collection_name}' is a keyword-only index; "
+                             f"it has no vectors to compare a query vector with")
+        embedder = self.query_embedders[name]
+        dimension = self._reduced_dimension(embedder.dimensions(), embedder=embedder)
+        if dimension != self.embedding_dimensions:
+            model = (self.collection.metadata or {}).get('embedding_model', self.embedder.model_name)
+            raise ValueError(f"Query embedder '{name}' ({embedder}) produces {dimension}-dimensional vectors, "
+                             f"but collection '{self.collection_name}' holds {self.embedding_dimensions}-dimensional "
+                             f"vectors from '{model}'")
+        return embedder
+    
+    def _check_dimension(self, dimension: int):
+        """Raise if the collection already holds vectors of another dimension"""
+        stored = (self.collection.metadata or {}).get('embedding_dimensions')
+        if stored is not None and int(stored) != dimension:
+            model = (self.collection.metadata or {}).get('embedding_model', 'unknown')
+            if model == KEYWORD_ONLY_MODEL:
+                raise EmbeddingError(
+                    f"Collection '{self.collection_name}' is a keyword-only index. Reindex it with --clear, "
+                    f"or run migrate, to add vectors from {self.embedder}."
+                )
+            raise EmbeddingError(
+                f"Collection '{self.collection_name}' holds {stored}-dimensional vectors from '{model}', "
+                f"but {self.embedder} produces {dimension}. Clear the collection or switch back."
+            )
+    
+    def _embed(self, texts: List[str], record: bool = False, failures: Optional[Dict[int, str]] = None,
+               prefetched: Optional[Dict] = None) -> List[List[float]]:
+        """
+        Embed texts and check the vectors match what the collection already holds
+        With record=True, an empty collection remembers the model and dimension
+        Given a failures dict, texts the embedder failed on their own get None and
+        their error message in failures (by position) instead of failing the call;
+        texts in prefetched (see prefetch_embeddings) are not embedded again
+        """
+        vectors, dimension = self._vectors(self.embedder, texts, failures, prefetched=prefetched)
+        
+        stored = (self.collection.metadata or {}).get('embedding_dimensions')
+        if stored is None:
+            if not record:
+                return vectors
+            # First vectors in this collection: record which model produced them
+            metadata = dict(self.collection.metadata or {})
+            metadata.update({
+                'embedding_model': self.embedder.model_name,
+                'embedding_dimensions': dimension,
+                'embedding_mode': self.embedding_mode,
+                'distance_metric': self.metric,
+                'normalized_embeddings': self.normalize_embeddings,
+                'dedup': self.dedup,
+                'code_normalization': self.code_normalization,
+                'language_code_normalization': json.dumps(self.language_code_normalization, sort_keys=True),
+                'document_template': self.document_template,
+                'query_template': self.query_template,
+                'reduced_dimensions': self.reduce_dimensions,
+                'quantization': self.collection.quantization
+            })
+            if self.embedding_models:
+                metadata['embedding_models'] = json.dumps(
+                    {name: model.model_name for name, model in self.embedding_models.items()}, sort_keys=True)
+            self.collection.modify(metadata=metadata)
+        else:
+            self._check_dimension(dimension)
+            self._check_metric()
+        
+        return vectors
+    
+    def _vectors(self, embedder: Embedder, texts: List[str], failures: Optional[Dict[int, str]] = None,
+                 reduce: bool = True, prefetched: Optional[Dict] = None) -> Tuple[List[List[float]], int]:
+        """
+        Vectors of texts from an embedder, reduced and normalized as the collection keeps them, and their dimension
+        (reduce=False keeps every component: extra embedding models are not reduced). The raw
+        vectors of texts in prefetched, and their failures, are taken from there
+        """
+        prefetched = prefetched or {}
+        vectors = [prefetched[text][0] if text in prefetched else None for text in texts]
+        missing = [i for i, text in enumerate(texts) if text not in prefetched]
+        if failures is not None:
+            failures.update((i, prefetched[text][1]) for i, text in enumerate(texts)
+                            if text in prefetched and prefetched[text][1] is not None)
+        if missing:
+            try:
+                fresh = embedder.embed([texts[i] for i in missing])
+            except PartialEmbeddingError as e:
+                if failures is None:
+                    raise
+                fresh = e.vectors
+                failures.update((missing[n], error) for n, error in e.failures)
+            if len(fresh) != len(missing):
+                raise EmbeddingError(f"{embedder} returned {len(fresh)} vectors for {len(missing)} texts")
+            for i, vector in zip(missing, fresh):
+                vectors[i] = vector
+        
+        embedded = [v for v in vectors if v is not None]
+        dimension = len(embedded[0]) if embedded else embedder.dimensions()
+        if any(len(v) != dimension for v in embedded):
+            raise EmbeddingError(f"{embedder} returned vectors of mixed dimensions")
+        if self.reduce_dimensions and reduce:
+            # Truncated before normalizing, so reduced vectors are unit length too
+            dimension = self._reduced_dimension(dimension, embedder=embedder)
+            vectors = [None if v is None else list(v[:dimension]) for v in vectors]
+        if self.normalize_embeddings:
+            vectors = [None if v is None else _unit(v) for v in vectors]
+        return vectors, dimension
+    
+    def prefetch_embeddings(self, chunks: List[CodeChunk]) -> Dict[str, Tuple[Optional[List[float]], Optional[str]]]:
+        """
+        Embed the texts add_chunks_batch would embed for chunks, without touching the index
+        
+        Safe to call from another thread while the index is written to, so the next
+        batch can be embedded while this one is stored (see utils/embedding_pipeline.py).
+        With dedup on, nothing is embedded ahead: only chunks not already stored are
+        known to need a vector once the batch is stored.
+        
+        Returns:
+            (raw vector, None) or (None, error message) of each text, by text; passed
+            to add_chunks_batch as prefetched
+        
+        Raises:
+            EmbeddingError: If the whole call failed (partial failures are returned)
+        """
+        if self.dedup != 'off' or not chunks:
+            return {}
+        return self._prefetch(chunks)
+    
+    def stage_embeddings(self, chunks: List[CodeChunk], prefetched: Optional[Dict] = None,
+                         replacing: Iterable[str] = ()) -> Dict[str, Tuple[Optional[List[float]], Optional[str]]]:
+        """
+        Embed ahead every text add_chunks_batch needs for chunks, and record the chunks that fail
+        
+        Lets the indexer store a file only once all of its chunks have a vector: the
+        chunks whose text could not be embedded are listed in embedding_failures here,
+        before anything is written, and the ones left out of them are then passed to
+        add_chunks_batch with the result. With dedup on, only bodies not already
+        stored are embedded; those stored only by chunks about to be replaced count
+        as not stored, and a body that fails takes every copy of it in the batch along.
+        
+        Args:
+            chunks: Chunks about to be added
+            prefetched: Vectors prefetch_embeddings already returned for them
+            replacing: Locations (see repo_filepath) of files whose stored chunks are
+                removed before these are added
+        
+        Returns:
+            prefetched, with the vectors of the texts it was missing
+        
+        Raises:
+            EmbeddingError: If the whole call failed (partial failures are recorded)
+        """
+        # Chunks that share a body share its vector, and its failure
+        groups = [[chunk] for chunk in chunks]
+        if self.dedup != 'off' and chunks:
+            hashes = [content_hash(self._normalized_code(chunk), chunk.language, self.dedup) for chunk in chunks]
+            ids, replacing = {chunk.chunk_id() for chunk in chunks}, set(replacing)
+            existing = self.collection.get(where={'content_hash': {'$in': sorted(set(hashes))}}, include=['metadatas'])
+            stored = {metadata['content_hash'] for chunk_id, metadata in zip(existing['ids'], existing['metadatas'])
+                      if chunk_id not in ids and
+                      repo_filepath(metadata.get('repo') or None, metadata['filepath']) not in replacing}
+            bodies: Dict[str, List[CodeChunk]] = {}
+            for chunk, digest in zip(chunks, hashes):
+                if digest not in stored:
+                    bodies.setdefault(digest, []).append(chunk)
+            groups = list(bodies.values())
+        vectors = dict(prefetched or {})
+        missing = [group[0] for group in groups if self._embedding_text(group[0]) not in vectors]
+        if missing:
+            vectors.update(self._prefetch(missing))
+        for group in groups:
+            error = vectors[self._embedding_text(group[0])][1]
+            if error is not None:
+                for chunk in group:
+                    self._embedding_failed(chunk.to_dict(), error)
+        return vectors
+    
+    def _prefetch(self, chunks: List[CodeChunk]) -> Dict[str, Tuple[Optional[List[float]], Optional[str]]]:
+        """Embed the texts of chunks, and their signatures in a dual index (see prefetch_embeddings)"""
+        texts = [self._embedding_text(chunk) for chunk in chunks]
+        if self.embedding_mode == 'dual':
+            signatures = zip(chunks, map(self._signature_text, chunks))
+            texts += [self._document_text(chunk, text) for chunk, text in signatures if text]
+        texts = list(dict.fromkeys(texts))
+        failures: Dict[int, str] = {}
+        with self.tracer.span('embed', items=len(texts), prefetched=True) as span:
+            try:
+                vectors = self.embedder.embed(texts)
+            except PartialEmbeddingError as e:
+                vectors = e.vectors
+                failures.update(e.failures)
+            span.attributes['failed'] = len(failures)
+        if len(vectors) != len(texts):
+            raise EmbeddingError(f"{self.embedder} returned {len(vectors)} vectors for {len(texts)} texts")
+        return {text: (None, failures[i]) if i in failures else (vector, None)
+                for i, (text, vector) in enumerate(zip(texts, vectors))}
+    
+    def add_chunks_batch(self, chunks: List[CodeChunk], prefetched: Optional[Dict] = None) -> int:
         """
         Add multiple chunks in a single batch operation
         
+        Chunk ids come from the chunks' symbol_ids (see assign_symbol_ids), so
+        identical source always gets identical ids
+        
         Args:
             chunks: List of CodeChunk objects
-            
+            prefetched: Vectors prefetch_embeddings or stage_embeddings returned for
+                these chunks; only texts missing from it are embedded here
+        
         Returns:
-            Number of chunks added
+            Number of chunks added; chunks whose text could not be embedded are
+            left out and listed in embedding_failures
         """
         if not chunks:
             return 0
@@ -100,241 +1033,2361 @@ class ChromeRAGSystem:
         metadatas = []
         
         for chunk in chunks:
-            chunk_id = f"chunk_{self._chunk_counter}"
-            self._chunk_counter += 1
-            
-            ids.append(chunk_id)
+            ids.append(chunk.chunk_id())
             documents.append(chunk.content)
             metadatas.append(chunk.to_dict())
         
-        # Batch insert
-        self.collection.add(
+        self._check_mode()
+        self._check_models()
+        if self.dedup != 'off':
+            failed_before = len(self.embedding_failures)
+            self._add_deduplicated(chunks, ids, documents, metadatas, prefetched)
+            self._index_changed()
+            return len(chunks) - (len(self.embedding_failures) - failed_before)
+        
+        embedded, embeddings, signed = self._embed_chunks(chunks, metadatas, prefetched)
+        
+        # Ids are stable across runs: replace chunks a previous run left under the same id,
+        # in place in the main collection (see _store_chunks) so searches never miss them
+        kept = set(ids[i] for i in embedded)
+        for store in self._stores():
+            store.delete(ids=[chunk_id for chunk_id in ids if store is not self.collection or chunk_id not in kept])
+        
+        if embedded:
+            self._store_chunks([chunks[i] for i in embedded], [ids[i] for i in embedded],
+                               [documents[i] for i in embedded], [metadatas[i] for i in embedded],
+                               embeddings, signed)
+            self._store_model_vectors([chunks[i] for i in embedded], [ids[i] for i in embedded],
+                                      [documents[i] for i in embedded], [metadatas[i] for i in embedded])
+        
+        # Update BM25 index (incremental update is tricky with BM25Okapi, 
+        # so we'll just rebuild it for now or append if possible, but rebuilding is safer for consistency)
+        # For performance, we might want to defer this until after a large batch import
+        # But for now, let's keep it simple and rebuild
+        # self._build_keyword_index() # Commented out for performance during bulk index
+        
+        self._index_changed()
+        return len(embedded)
+    
+    def _embed_chunks(self, chunks: List[CodeChunk], metadatas: List[Dict],
+                      prefetched: Optional[Dict] = None) -> Tuple[List[int], List, List]:
+        """
+        Embed chunks, and each symbol's signature in a dual index
+        
+        A chunk whose text the embedder failed is left out and recorded in
+        embedding_failures; a failed signature only costs its chunk the signature vector
+        
+        Returns:
+            Positions of the chunks embedded, their vectors followed by the signature
+            vectors, and the (position among the chunks embedded, text) of each signature
+        """
+        # A dual index also embeds each symbol's signature, in its own collection
+        signed = []
+        if self.embedding_mode == 'dual':
+            signed = [(i, text) for i, text in enumerate(map(self._signature_text, chunks)) if text]
+        texts = [self._embedding_text(chunk) for chunk in chunks] + \
+            [self._document_text(chunks[i], text) for i, text in signed]
+        failures: Dict[int, str] = {}
+        # Texts embedded ahead were traced when they were
+        fresh = len([text for text in texts if text not in (prefetched or {})])
+        with self.tracer.span('embed', items=fresh) as span:
+            vectors = self._embed(texts, record=True, failures=failures, prefetched=prefetched)
+            span.attributes['failed'] = len(failures)
+        
+        embedded = [i for i in range(len(chunks)) if i not in failures]
+        for i in range(len(chunks)):
+            if i in failures:
+                self._embedding_failed(metadatas[i], failures[i])
+        position = {i: n for n, i in enumerate(embedded)}
+        kept_signed, signature_vectors = [], []
+        for n, (i, text) in enumerate(signed):
+            if len(chunks) + n in failures:
+                self.logger.warning(f"No signature vector for {metadatas[i]['filepath']}:"
+                                    f"{metadatas[i]['line_start']}: {failures[len(chunks) + n]}")
+            elif i in position:
+                kept_signed.append((position[i], text))
+                signature_vectors.append(vectors[len(chunks) + n])
+        return embedded, [vectors[i] for i in embedded] + signature_vectors, kept_signed
+    
+    def _embedding_failed(self, metadata: Dict, error: str):
+        """Record a chunk left out of the index because its text could not be embedded"""
+        failure = {'filepath': metadata['filepath'], 'repo': metadata.get('repo', ''),
+                   'line': metadata.get('line_start'), 'name': metadata.get('name', ''), 'error': error}
+        self.embedding_failures.append(failure)
+        self.logger.warning(f"Could not embed {failure['filepath']}:{failure['line']} ({failure['name']}): {error}")
+    
+    def _store_chunks(self, chunks: List[CodeChunk], ids: List[str], documents: List[str],
+                      metadatas: List[Dict], embeddings: List[List[float]], signed: List):
+        """Insert embedded chunks, and the signature vectors of a dual index"""
+        with self.tracer.span('upsert', items=len(ids) + len(signed), store=type(self.collection).__name__):
+            # The full code is stored for display whatever text was embedded
+            self.collection.replace(
+                ids=ids,
+                documents=documents,
+                metadatas=metadatas,
+                embeddings=embeddings[:len(chunks)]
+            )
+            if signed:
+                self.signature_store.add(
+                    ids=[ids[i] for i, _ in signed],
+                    documents=[text for _, text in signed],
+                    metadatas=[metadatas[i] for i, _ in signed],
+                    embeddings=embeddings[len(chunks):]
+                )
+    
+    def _store_model_vectors(self, chunks: List[CodeChunk], ids: List[str], documents: List[str],
+                             metadatas: List[Dict]):
+        """
+        Embed stored chunks with each extra embedding model, into the model's collection
+        A chunk the model fails on is still indexed, without that model's vector
+        """
+        texts = [self._embedding_text(chunk) for chunk in chunks]
+        for name, model in self.embedding_models.items():
+            failures: Dict[int, str] = {}
+            with self.tracer.span('embed', items=len(texts), model=name) as span:
+                vectors, dimension = self._vectors(model, texts, failures, reduce=False)
+                span.attributes['failed'] = len(failures)
+            store = self.model_store(name)
+            if (store.metadata or {}).get('embedding_dimensions') is None:
+                store.modify(metadata={'embedding_model': model.model_name, 'embedding_dimensions': dimension})
+            else:
+                self._check_model_dimension(name, dimension)
+            for i, error in failures.items():
+                self.logger.warning(f"No '{name}' vector for {metadatas[i]['filepath']}:"
+                                    f"{metadatas[i]['line_start']}: {error}")
+            kept = [i for i in range(len(chunks)) if i not in failures]
+            if kept:
+                with self.tracer.span('upsert', items=len(kept), store=type(store).__name__, model=name):
+                    store.add(
+                        ids=[ids[i] for i in kept],
+                        documents=[documents[i] for i in kept],
+                        metadatas=[metadatas[i] for i in kept],
+                        embeddings=[vectors[i] for i in kept]
+                    )
+    
+    def _add_deduplicated(self, chunks: List[CodeChunk], ids: List[str], documents: List[str],
+                          metadatas: List[Dict], prefetched: Optional[Dict] = None):
+        """
+        add_chunks_batch with dedup on: only the first chunk of each body is embedded and
+        stored, and every later one is listed in its 'duplicates' metadata instead
+        """
+        recorded = self.collection.metadata or {}
+        if 'embedding_dimensions' in recorded and recorded.get('dedup') != self.dedup:
+            # An index built without dedup keeps it from now on (the first vectors record it otherwise)
+            self.collection.modify(metadata=dict(recorded, dedup=self.dedup))
+        # Copies that embed alike are duplicates: the hash is of the normalized code
+        hashes = [content_hash(self._normalized_code(chunk), chunk.language, self.dedup) for chunk in chunks]
+        for metadata, digest in zip(metadatas, hashes):
+            metadata['content_hash'] = digest
+        
+        # Ids are stable across runs: replace chunks a previous run left under the same id
+        self._remove_chunks(ids)
+        
+        # Bodies already stored keep their representative; the batch adds to its duplicates
+        existing = self.collection.get(where={'content_hash': {'$in': sorted(set(hashes))}},
+                                       include=['documents', 'metadatas'])
+        representatives = {metadata['content_hash']: (chunk_id, document, metadata)
+                           for chunk_id, document, metadata in
+                           zip(existing['ids'], existing['documents'], existing['metadatas'])}
+        added, grown = [], {}
+        for i, digest in enumerate(hashes):
+            if digest not in representatives:
+                representatives[digest] = (ids[i], documents[i], metadatas[i])
+                added.append(i)
+                continue
+            chunk_id, document, metadata = representatives[digest]
+            entries = grown.setdefault(chunk_id, parse_duplicates(metadata))
+            entries.append(duplicate_entry(ids[i], metadatas[i], documents[i], document))
+            self.chunks_deduplicated += 1
+        
+        # Chunks first stored in this batch carry their duplicates from the start
+        for i in added:
+            if ids[i] in grown:
+                metadatas[i] = set_duplicates(metadatas[i], grown.pop(ids[i]))
+        if added:
+            embedded, embeddings, signed = self._embed_chunks([chunks[i] for i in added],
+                                                              [metadatas[i] for i in added], prefetched)
+            # The duplicates of a chunk that failed go with it (they are all of this batch)
+            for n in set(range(len(added))) - set(embedded):
+                failed = metadatas[added[n]]
+                entries = parse_duplicates(failed)
+                for entry in entries:
+                    self._embedding_failed(entry, f"duplicate of {failed['filepath']}:{failed['line_start']}, "
+                                                  f"which could not be embedded")
+                self.chunks_deduplicated -= len(entries)
+            stored = [added[n] for n in embedded]
+            if stored:
+                self._store_chunks([chunks[i] for i in stored], [ids[i] for i in stored],
+                                   [documents[i] for i in stored], [metadatas[i] for i in stored],
+                                   embeddings, signed)
+        
+        # Representatives stored before: only their duplicate lists change
+        if grown:
+            current = {chunk_id: metadata for chunk_id, _, metadata in representatives.values()}
+            self.collection.update(ids=list(grown),
+                                   metadatas=[set_duplicates(current[chunk_id], entries)
+                                              for chunk_id, entries in grown.items()])
+    
+    def _remove_chunks(self, ids: List[str], filepath: Optional[str] = None, repo: Optional[str] = None) -> int:
+        """
+        Remove stored chunks from a deduplicated index: the first duplicate of a removed
+        representative takes its place (keeping its vectors), and chunks that were only
+        listed as duplicates, by id or as part of the file, are dropped from the lists
+        
+        Returns:
+            Number of stored chunks removed
+        """
+        gone = set(ids)
+        existing = self.collection.get(ids=ids, include=['documents', 'metadatas', 'embeddings'])
+        signatures = {}
+        if existing['ids']:
+            if self.embedding_mode == 'dual':
+                stored = self.signature_store.get(ids=existing['ids'], include=['documents', 'embeddings'])
+                signatures = dict(zip(stored['ids'], zip(stored['documents'], stored['embeddings'])))
+            for store in self._stores():
+                store.delete(ids=existing['ids'])
+        
+        def remaining(entries: List[Dict]) -> List[Dict]:
+            return [entry for entry in entries
+                    if entry.get('id') not in gone and not (filepath and in_file(entry, filepath, repo))]
+        
+        # The first duplicate left takes over each removed representative, with its vectors
+        for i, chunk_id in enumerate(existing['ids']):
+            entries = remaining(parse_duplicates(existing['metadatas'][i]))
+            if not entries:
+                continue
+            document = existing['documents'][i]
+            promoted = dict(entries[0])
+            promoted_id = promoted.pop('id')
+            promoted['content'] = promoted.get('content', document)
+            rebased = []
+            for entry in entries[1:]:
+                entry = dict(entry)
+                text = entry.pop('content', document)
+                if text != promoted['content']:
+                    entry['content'] = text
+                rebased.append(entry)
+            promoted = set_duplicates(promoted, rebased)
+            
+            self.collection.add(ids=[promoted_id], documents=[promoted['content']], metadatas=[promoted],
+                                embeddings=[[float(x) for x in existing['embeddings'][i]]])
+            if chunk_id in signatures:
+                text, vector = signatures[chunk_id]
+                self.signature_store.add(ids=[promoted_id], documents=[text], metadatas=[promoted],
+                                         embeddings=[[float(x) for x in vector]])
+        
+        # Other representatives may list removed chunks among their duplicates
+        listing = self.collection.get(where={'duplicate_count': {'$gt': 0}}, include=['metadatas'])
+        changed_ids, changed = [], []
+        for chunk_id, metadata in zip(listing['ids'], listing['metadatas']):
+            entries = parse_duplicates(metadata)
+            kept = remaining(entries)
+            if len(kept) < len(entries):
+                changed_ids.append(chunk_id)
+                changed.append(set_duplicates(metadata, kept))
+        if changed_ids:
+            self.collection.update(ids=changed_ids, metadatas=changed)
+        return len(existing['ids'])
+    
+    def _embedding_text(self, chunk: CodeChunk) -> str:
+        """
+        Text whose embedding represents a chunk in the main collection: its code, or the
+        doc comment and signature, which match natural-language queries more closely
+        ('doc' mode: documented symbols only; 'signature' mode: every symbol), formatted
+        by the document template; a chunk processor's metadata['embedding_text'] replaces both
+        """
+        rewritten = (chunk.metadata or {}).get(EMBEDDING_TEXT_KEY)
+        if isinstance(rewritten, str) and rewritten:
+            return self._document_text(chunk, rewritten)
+        if self.embedding_mode == 'signature' or (self.embedding_mode == 'doc' and chunk.doc):
+            return self._document_text(chunk, self._signature_text(chunk) or self._normalized_code(chunk))
+        return self._document_text(chunk, self._normalized_code(chunk))
+    
+    def _document_text(self, chunk: CodeChunk, body: str) -> str:
+        """Text embedded for a chunk whose embedding mode picked body, by the document template"""
+        return render_document(self.document_template, chunk, body)
+    
+    def _normalized_code(self, chunk: CodeChunk) -> str:
+        """A chunk's code as it is embedded: normalized by the level set for its language"""
+        level = normalization_for(chunk.language, self.code_normalization, self.language_code_normalization)
+        return normalize_code(chunk.content, chunk.language, level)
+    
+    def _signature_text(self, chunk: CodeChunk) -> Optional[str]:
+        """
+        Doc comment and signature of a symbol, without its body; None for chunks that
+        are not symbols (files, document sections) and for parts of a split symbol,
+        which keep their code so each part stays distinguishable
+        """
+        if chunk.part_count != 1 or not chunk.signature or chunk.type not in SYMBOL_CHUNK_TYPES:
+            return None
+        return f"{chunk.doc}\n{chunk.signature}" if chunk.doc else chunk.signature
+    
+    def delete_file_chunks(self, filepath: str, repo: Optional[str] = None,
+                           keep: Optional[List[str]] = None) -> int:
+        """
+        Remove every chunk of a file
+        
+        Args:
+            filepath: File path as stored in chunk metadata (relative to the indexed root)
+            repo: Label of the repository the file belongs to, in a multi-repo index
+            keep: Ids of chunks of the file to leave in place (its new version, stored
+                first so searches never see the file missing); not in a deduplicated index
+        
+        Returns:
+            Number of chunks removed
+        """
+        if self._deduplicated():
+            # Chunks of the file may be listed as duplicates, or be the copy others rely on
+            existing = self.collection.get(where=self._file_filter(filepath, repo), include=[])
+            removed = self._remove_chunks(existing['ids'], filepath, repo)
+            self._index_changed()
+            return removed
+        
+        removed = 0
+        keep = set(keep or [])
+        for store in self._stores():
+            existing = store.get(where=self._file_filter(filepath, repo), include=[])
+            ids = [chunk_id for chunk_id in existing['ids'] if chunk_id not in keep]
+            if ids:
+                store.delete(ids=ids)
+            if store is self.collection:
+                removed = len(ids)
+        if removed:
+            self._index_changed()
+        return removed
+    
+    def move_file_chunks(self, old_filepath: str, new_filepath: str, repo: Optional[str] = None) -> int:
+        """
+        Re-point the chunks of a renamed file at its new path, keeping their embeddings
+        The chunks are re-inserted under the ids a fresh index of the new path would give them
+        
+        Args:
+            old_filepath: Previous path as stored in chunk metadata
+            new_filepath: New path, relative to the same root
+            repo: Label of the repository the file belongs to, in a multi-repo index
+        
+        Returns:
+            Number of chunks updated
+        """
+        moved = [self._move_chunks(store, old_filepath, new_filepath, repo) for store in self._stores()]
+        if self._deduplicated():
+            self._move_duplicates(old_filepath, new_filepath, repo)
+        if moved[0]:
+            self._index_changed()
+        return moved[0]
+    
+    def _move_chunks(self, store: VectorStore, old_filepath: str, new_filepath: str,
+                     repo: Optional[str] = None) -> int:
+        """move_file_chunks within one collection"""
+        existing = store.get(where=self._file_filter(old_filepath, repo),
+                             include=['documents', 'metadatas', 'embeddings'])
+        if not existing['ids']:
+            return 0
+        
+        old_location, new_location = repo_filepath(repo, old_filepath), repo_filepath(repo, new_filepath)
+        ids, metadatas = [], []
+        for metadata in existing['metadatas']:
+            metadata = dict(metadata, filepath=new_filepath)
+            symbol_id = metadata.get('symbol_id', '')
+            if symbol_id.startswith(old_location + ':'):
+                metadata['symbol_id'] = new_location + symbol_id[len(old_location):]
+            metadatas.append(metadata)
+            ids.append(stored_chunk_id(metadata['symbol_id'], int(metadata.get('part_index', 0)),
+                                       int(metadata.get('part_count', 1))))
+        
+        store.delete(ids=existing['ids'])
+        store.add(
             ids=ids,
-            documents=documents,
-            metadatas=metadatas
+            documents=existing['documents'],
+            metadatas=metadatas,
+            embeddings=[[float(x) for x in vector] for vector in existing['embeddings']]
         )
+        return len(ids)
+    
+    def _move_duplicates(self, old_filepath: str, new_filepath: str, repo: Optional[str] = None):
+        """move_file_chunks for the chunks listed as duplicates of others"""
+        old_location, new_location = repo_filepath(repo, old_filepath), repo_filepath(repo, new_filepath)
+        listing = self.collection.get(where={'duplicate_count': {'$gt': 0}}, include=['metadatas'])
+        changed_ids, changed = [], []
+        for chunk_id, metadata in zip(listing['ids'], listing['metadatas']):
+            entries = parse_duplicates(metadata)
+            if not any(in_file(entry, old_filepath, repo) for entry in entries):
+                continue
+            for entry in entries:
+                if not in_file(entry, old_filepath, repo):
+                    continue
+                entry['filepath'] = new_filepath
+                symbol_id = entry.get('symbol_id', '')
+                if symbol_id.startswith(old_location + ':'):
+                    entry['symbol_id'] = new_location + symbol_id[len(old_location):]
+                entry['id'] = stored_chunk_id(entry['symbol_id'], int(entry.get('part_index', 0)),
+                                              int(entry.get('part_count', 1)))
+            changed_ids.append(chunk_id)
+            changed.append(set_duplicates(metadata, entries))
+        if changed_ids:
+            self.collection.update(ids=changed_ids, metadatas=changed)
+    
+    def _deduplicated(self) -> bool:
+        """True if chunks of this index may be stored as duplicates of others"""
+        recorded = (self.collection.metadata or {}).get('dedup')
+        return self.dedup != 'off' or recorded not in (None, 'off')
+    
+    @staticmethod
+    def _file_filter(filepath: str, repo: Optional[str] = None) -> Dict:
+        """Store filter selecting the chunks of one file (of one repository)"""
+        if repo:
+            return {"$and": [{"filepath": filepath}, {"repo": repo}]}
+        return {"filepath": filepath}
+    
+    def retrieve_symbol(self, symbol_name: str, symbol_type: Optional[str] = None,
+                       language: Optional[str] = None, n_results: int = 5,
+                       with_methods: bool = False) -> List[Dict]:
+        """
+        Retrieve specific symbol by name
+        
+        Args:
+            symbol_name: Name of the symbol to find
+            symbol_type: Optional type filter (function, class, etc.)
+            language: Optional language filter (cpp, python, etc.)
+            n_results: Maximum number of results
+            with_methods: Attach the 'methods' of each type found (see symbol_methods)
+        
+        Returns:
+            List of matching chunks with metadata; a Go method also has the 'owner'
+            reference of the type it is declared on
+        """
+        conditions = [{"name": symbol_name}]
+        
+        if symbol_type:
+            conditions.append({"type": symbol_type})
+        
+        if language:
+            conditions.append({"language": language})
+        
+        where_clause = {"$and": conditions} if len(conditions) > 1 else conditions[0]
+        
+        try:
+            results = self.collection.get(
+                where=where_clause,
+                limit=n_results
+            )
+            found = self._format_get_results(results)
+        except Exception as e:
+            self.logger.error(f"Error retrieving symbol: {e}")
+            return []
+        
+        for result in found:
+            extra = parse_metadata(result['metadata'].get('metadata'))
+            if extra.get('owner'):
+                result['owner'] = extra['owner']
+            if with_methods and extra.get('methods'):
+                result['methods'] = self.symbol_methods(result['metadata'])
+        return found
+    
+    def lookup(self, name: str, limit: int = 20, kinds: Optional[List[str]] = None,
+               languages: Optional[List[str]] = None, repos: Optional[List[str]] = None) -> List[Dict]:
+        """
+        Go to symbol: exact, prefix and fuzzy matches of a name, without an embedding call
+        Matches are ranked exact over prefix over fuzzy (see utils/symbol_lookup.py);
+        "sessionmanager" finds NewSessionManager
+        
+        Args:
+            name: Symbol name, or part of one
+            limit: Maximum number of symbols
+            kinds: Only these symbol kinds (function, method, type, ...)
+            languages: Only these languages
+            repos: Only these repository labels
+        
+        Returns:
+            One entry per symbol with its name, kind, location and 'match' (exact, prefix or fuzzy)
+        """
+        return lookup_symbols(name, self._all_metadatas(), limit=limit, kinds=kinds, languages=languages, repos=repos,
+                              kind_of=symbol_kind)
+    
+    def _all_metadatas(self) -> List[Dict]:
+        """Metadata of every chunk, re-read from the store once per index version"""
+        with self._keyword_lock:
+            version = self.index_version
+            cached = self._metadata_cache
+        if cached is not None and cached[0] == version:
+            return cached[1]
+        metadatas = self.collection.get(include=['metadatas'])['metadatas'] or []
+        with self._keyword_lock:
+            self._metadata_cache = (version, metadatas)
+        return metadatas
+    
+    def _indexed_paths(self) -> List[str]:
+        """Sorted file paths of the indexed chunks, re-read once per index version"""
+        with self._keyword_lock:
+            version = self.index_version
+            cached = self._path_cache
+        if cached is not None and cached[0] == version:
+            return cached[1]
+        paths = sorted({metadata.get('filepath', '') for metadata in self._all_metadatas()})
+        with self._keyword_lock:
+            self._path_cache = (version, paths)
+        return paths
+    
+    def retrieve_full_symbol(self, symbol_id: str) -> Optional[Dict]:
+        """
+        Reassemble a symbol that was split into parts at index time
+        
+        Args:
+            symbol_id: The 'symbol_id' metadata of any of its chunks
+        
+        Returns:
+            Chunk dict with the stitched content, or None if unknown
+        """
+        try:
+            results = self.collection.get(where={"symbol_id": symbol_id})
+        except Exception as e:
+            self.logger.error(f"Error retrieving symbol parts: {e}")
+            return None
+        
+        parts = self._format_get_results(results)
+        if not parts:
+            return None
+        
+        first = min(parts, key=lambda p: int(p['metadata'].get('part_index', 0)))
+        last = max(parts, key=lambda p: int(p['metadata'].get('part_index', 0)))
+        metadata = dict(first['metadata'], line_end=last['metadata'].get('line_end'))
+        return {
+            'content': stitch_parts(parts),
+            'metadata': metadata,
+            'id': first['id'],
+            'parts': len(parts)
+        }
+    
+    def symbol_methods(self, metadata: Dict) -> List[Dict]:
+        """
+        Methods declared on a type, from the 'methods' the Go linker recorded
+        
+        Args:
+            metadata: Stored metadata of the type's chunk
+        
+        Returns:
+            One entry per method in source order: its name, 'pointer_receiver', and
+            the signature, doc and location of its chunk when it is indexed
+        """
+        refs = [ref for ref in parse_metadata(metadata.get('metadata')).get('methods', []) if isinstance(ref, dict)]
+        symbol_ids = [ref['symbol_id'] for ref in refs if ref.get('symbol_id')]
+        chunks = {}
+        if symbol_ids:
+            stored = self.collection.get(where={'symbol_id': {'$in': symbol_ids}}, include=['metadatas'])
+            for method in stored['metadatas']:
+                if int(method.get('part_index', 0)) == 0:
+                    chunks[method['symbol_id']] = method
+        
+        methods = []
+        for ref in refs:
+            method = chunks.get(ref.get('symbol_id'), {})
+            methods.append({
+                'name': ref['name'].rsplit('.', 1)[-1],
+                'qualified_name': ref['name'],
+                'pointer_receiver': bool(ref.get('pointer_receiver')),
+                'symbol_id': ref.get('symbol_id'),
+                'signature': method.get('signature', ''),
+                'doc': method.get('doc', ''),
+                'filepath': method.get('filepath', ref.get('filepath')),
+                'line_start': method.get('line_start', ref.get('line')),
+                'line_end': method.get('line_end', ref.get('line')),
+            })
+        return methods
+    
+    def find_implementations(self, interface_name: str, language: str = 'go',
+                             n_results: int = 50) -> List[Dict]:
+        """
+        Find concrete types that implement an interface
+        
+        Args:
+            interface_name: Interface name, optionally package-qualified (io.Reader)
+            language: Language whose type chunks carry 'implements' metadata
+            n_results: Maximum number of results
+        
+        Returns:
+            List of matching type chunks (Swift extensions of unindexed types and
+            Elixir protocol implementations too);
+            'pointer_only' is set when only *T satisfies it
+        """
+        try:
+            results = self.collection.get(
+                where={"$and": [
+                    {"language": language},
+                    {"type": {"$in": ["struct", "type", "class", "enum", "actor", "extension", "module"]}}
+                ]}
+            )
+        except Exception as e:
+            self.logger.error(f"Error finding implementations: {e}")
+            return []
+        
+        short_name = interface_name.split('.')[-1]
+        matches = []
+        for result in self._format_get_results(results):
+            metadata = parse_metadata(result['metadata'].get('metadata'))
+            implements = metadata.get('implements', [])
+            # 'Reader' matches 'io.Reader' and vice versa; two qualified names must agree
+            names = [
+                n for n in implements
+                if n == interface_name or (n.split('.')[-1] == short_name
+                                           and ('.' not in n or '.' not in interface_name))
+            ]
+            if not names:
+                continue
+            result['pointer_only'] = names[0] in metadata.get('implements_pointer_only', [])
+            matches.append(result)
+            if len(matches) >= n_results:
+                break
+        
+        return matches
+    
+    def interface_method_set(self, interface_name: str, language: str = 'go') -> List[Dict]:
+        """
+        Find the methods an interface requires, embedded interfaces flattened in
+        
+        Args:
+            interface_name: Interface name, optionally package-qualified (io.Reader)
+            language: Language whose interface chunks carry 'method_set' metadata
+        
+        Returns:
+            One entry per matching interface: the interface chunk and its 'methods',
+            each with 'name', 'signature' and, when inherited, 'from' and 'via'
+        """
+        qualifier, _, short_name = interface_name.rpartition('.')
+        try:
+            results = self.collection.get(
+                where={"$and": [
+                    {"language": language},
+                    {"type": "interface"},
+                    {"name": short_name}
+                ]}
+            )
+        except Exception as e:
+            self.logger.error(f"Error finding interface methods: {e}")
+            return []
+        
+        matches = []
+        for result in self._format_get_results(results):
+            if qualifier and result['metadata'].get('namespace') != qualifier:
+                continue
+            metadata = parse_metadata(result['metadata'].get('metadata'))
+            # Indexes built before method sets were linked only know the declared methods
+            methods = metadata.get('method_set', metadata.get('methods', []))
+            matches.append({
+                'interface': result,
+                'methods': methods,
+                'unresolved_embeds': metadata.get('unresolved_embeds', []),
+            })
+        return matches
+    
+    def find_callers(self, symbol_name: str, language: str = 'go',
+                     n_results: int = 50) -> List[Dict]:
+        """
+        Find the functions and methods that call a symbol
+        
+        Args:
+            symbol_name: Function or method name, optionally qualified (Type.Method, pkg.Func)
+            language: Language whose chunks carry 'calls' metadata
+            n_results: Maximum number of results
+        
+        Returns:
+            List of caller chunks (one per symbol); 'resolved' is False when the only
+            matching call could not be resolved and was recorded by name
+        """
+        matches = []
+        seen = set()
+        for result in self._callable_chunks(language):
+            metadata = parse_metadata(result['metadata'].get('metadata'))
+            refs = [ref for ref in metadata.get('calls', []) if _ref_matches(ref, symbol_name)]
+            symbol_id = result['metadata'].get('symbol_id') or result['id']
+            if not refs or symbol_id in seen:
+                continue
+            seen.add(symbol_id)
+            result['resolved'] = any(ref.get('resolved') for ref in refs)
+            matches.append(result)
+            if len(matches) >= n_results:
+                break
+        
+        return matches
+    
+    def find_callees(self, symbol_name: str, language: str = 'go') -> List[Dict]:
+        """
+        Find what a function or method calls
+        
+        Args:
+            symbol_name: Function or method name, optionally qualified (Type.Method)
+            language: Language whose chunks carry 'calls' metadata
+        
+        Returns:
+            List of matching definitions (one per symbol), each with its 'calls' references
+        """
+        matches = []
+        seen = set()
+        for result in self._callable_chunks(language):
+            metadata = result['metadata']
+            if symbol_name not in (metadata.get('name'), qualified_name(metadata)):
+                continue
+            symbol_id = metadata.get('symbol_id') or result['id']
+            if symbol_id in seen:
+                continue
+            seen.add(symbol_id)
+            result['calls'] = parse_metadata(metadata.get('metadata')).get('calls', [])
+            matches.append(result)
+        
+        return matches
+    
+    def find_references(self, symbol_name: str, language: str = 'go',
+                        n_results: int = 100) -> List[Dict]:
+        """
+        Find the chunks that refer to a symbol, for impact analysis before a rename
+        
+        Combines the call graph with the type and doc links of the index (see
+        utils.symbol_references): callers, types embedding it or with fields of
+        it, functions taking or returning it, methods on it, implementations,
+        instantiations, doc comments, and code that names it otherwise. Chunks
+        defining a symbol of that name, and Go package summaries, are left out.
+        
+        Args:
+            symbol_name: Symbol name, optionally qualified (User, pkg.User, Store.Open)
+            language: Language whose chunks are searched
+            n_results: Maximum number of results
+        
+        Returns:
+            One entry per referring symbol (split parts merged), in file order: the
+            chunk, its 'reference_kinds' (in REFERENCE_KINDS order) and the file
+            'lines' writing the name
+        """
+        try:
+            results = self.collection.get(where={"language": language})
+        except Exception as e:
+            self.logger.error(f"Error finding references: {e}")
+            return []
         
-        # Update BM25 index (incremental update is tricky with BM25Okapi, 
-        # so we'll just rebuild it for now or append if possible, but rebuilding is safer for consistency)
-        # For performance, we might want to defer this until after a large batch import
-        # But for now, let's keep it simple and rebuild
-        # self._build_keyword_index() # Commented out for performance during bulk index
+        matches: Dict[str, Dict] = {}
+        for result in self._format_get_results(results):
+            metadata = result['metadata']
+            if metadata.get('type') == 'package' or names_symbol(qualified_name(metadata), symbol_name):
+                continue  # package summaries restate the declarations; the definition itself
+            kinds = reference_kinds(parse_metadata(metadata.get('metadata')), result['content'], symbol_name)
+            if not kinds:
+                continue
+            # Lines counted back from the end: context prepended to the code is not in the range
+            lines = result['content'].splitlines()
+            first = int(metadata.get('line_start', 1))
+            last = int(metadata.get('line_end', first + len(lines) - 1))
+            found = [line for line in (last - (len(lines) - 1 - i) for i in mention_lines(result['content'], symbol_name))
+                     if line >= first]
+            symbol_id = metadata.get('symbol_id') or result['id']
+            match = matches.get(symbol_id)
+            if match is None:
+                if len(matches) >= n_results:
+                    continue
+                result.update(reference_kinds=kinds, lines=found)
+                matches[symbol_id] = result
+            else:
+                merged = set(match['reference_kinds']) | set(kinds)
+                match['reference_kinds'] = [kind for kind in REFERENCE_KINDS if kind in merged]
+                if 'code' in merged and len(merged) > 1:
+                    match['reference_kinds'].remove('code')
+                match['lines'] = sorted(set(match['lines']) | set(found))
         
-        return len(chunks)
+        return sorted(matches.values(), key=lambda r: (r['metadata'].get('repo') or '', r['metadata'].get('filepath', ''),
+                                                        int(r['metadata'].get('line_start', 0))))
     
-    def retrieve_symbol(self, symbol_name: str, symbol_type: Optional[str] = None,
-                       language: Optional[str] = None, n_results: int = 5) -> List[Dict]:
+    def find_related(self, symbol_name: str, language: str = 'go', n_results: int = 20,
+                     kinds: Optional[List[str]] = None) -> List[Dict]:
         """
-        Retrieve specific symbol by name
+        Find the functions and methods related to one through the call graph
+        
+        Graph-based counterpart of similar_to (see utils.related_symbols): symbols
+        calling the same indexed functions, or called by the same ones, ranked by
+        how many they share. The symbol itself, its direct callers and callees
+        only count when they share some too.
         
         Args:
-            symbol_name: Name of the symbol to find
-            symbol_type: Optional type filter (function, class, etc.)
-            language: Optional language filter (cpp, python, etc.)
+            symbol_name: Function or method name, optionally qualified (Type.Method);
+                every definition of the name is looked up
+            language: Language whose chunks carry 'calls' metadata
             n_results: Maximum number of results
-            
+            kinds: RELATION_KINDS to count (default: both)
+        
         Returns:
-            List of matching chunks with metadata
+            One entry per related symbol (its first part), most shared first, with
+            'shared_callees' and 'shared_callers' (qualified names, sorted) and their
+            total as 'overlap'
+        
+        Raises:
+            ValueError: If a kind is not one of RELATION_KINDS
         """
-        conditions = [{"name": symbol_name}]
+        symbols: Dict[str, Dict] = {}
+        calls: Dict[str, List[Dict]] = defaultdict(list)
+        for result in self._callable_chunks(language):
+            metadata = result['metadata']
+            symbol_id = metadata.get('symbol_id') or result['id']
+            calls[symbol_id].extend(parse_metadata(metadata.get('metadata')).get('calls', []))
+            first = symbols.get(symbol_id)
+            if first is None or int(metadata.get('part_index', 0)) < int(first['metadata'].get('part_index', 0)):
+                symbols[symbol_id] = result
         
-        if symbol_type:
-            conditions.append({"type": symbol_type})
+        by_key = {symbol_key(result['metadata']): result for result in symbols.values()}
+        callees, callers = call_graph({symbol_key(symbols[symbol_id]['metadata']): refs
+                                       for symbol_id, refs in calls.items()})
+        targets = {key for key, result in by_key.items()
+                   if symbol_name in (result['metadata'].get('name'), qualified_name(result['metadata']))}
         
-        if language:
-            conditions.append({"language": language})
-            
-        where_clause = {"$and": conditions} if len(conditions) > 1 else conditions[0]
+        names = lambda keys: sorted({qualified_name(by_key[key]['metadata']) if key in by_key else key for key in keys})
+        matches = []
+        for key, same_callees, same_callers in related_symbols(callees, callers, targets, kinds or RELATION_KINDS):
+            if key in by_key:  # else called, but not indexed as a function of this language
+                matches.append(dict(by_key[key], shared_callees=names(same_callees),
+                                    shared_callers=names(same_callers), overlap=len(same_callees) + len(same_callers)))
         
+        # Ties in file order
+        matches.sort(key=lambda r: (-r['overlap'], r['metadata'].get('repo') or '', r['metadata'].get('filepath', ''),
+                                    int(r['metadata'].get('line_start', 0))))
+        return matches[:n_results]
+    
+    def _callable_chunks(self, language: str) -> List[Dict]:
+        """Function and method chunks of one language"""
         try:
             results = self.collection.get(
-                where=where_clause,
-                limit=n_results
+                where={"$and": [
+                    {"language": language},
+                    {"type": {"$in": ["function", "method"]}}
+                ]}
             )
-            return self._format_get_results(results)
         except Exception as e:
-            self.logger.error(f"Error retrieving symbol: {e}")
+            self.logger.error(f"Error reading call graph: {e}")
             return []
+        return self._format_get_results(results)
     
     def retrieve_context(self, query: str, n_results: int = 5,
                         language: Optional[str] = None,
-                        file_type: Optional[str] = None) -> List[Dict]:
+                        file_type: Optional[str] = None,
+                        lexical_weight: Optional[float] = None,
+                        languages: Optional[List[str]] = None,
+                        kinds: Optional[List[str]] = None,
+                        path_globs: Optional[List[str]] = None,
+                        mmr_lambda: Optional[float] = None,
+                        exclude_tests: bool = False,
+                        rerank: Optional[bool] = None,
+                        rerank_candidates: Optional[int] = None,
+                        candidate_k: Optional[int] = None,
+                        vector_index: Optional[str] = None,
+                        repos: Optional[List[str]] = None,
+                        uses: Optional[List[str]] = None,
+                        min_score: Optional[float] = None,
+                        with_surrounding: bool = False,
+                        expand_query: Optional[bool] = None,
+                        explain: bool = False,
+                        exclude_text: bool = False,
+                        filter_expr: Union[str, Dict, FilterExpression, None] = None,
+                        boost_kinds: Optional[Dict[str, float]] = None,
+                        scope: Union[str, List[str], None] = None,
+                        with_neighbors: Optional[str] = None,
+                        depth_penalty: Optional[float] = None,
+                        recency_weight: Optional[float] = None,
+                        embedding_models: Optional[List[str]] = None,
+                        exclude_generated: bool = False,
+                        generated_weight: Optional[float] = None,
+                        query_embedder: Optional[str] = None,
+                        fusion: Union[str, Fuser, None] = None,
+                        check_limits: bool = True) -> List[Dict]:
         """
         Hybrid Semantic Search (Vector + BM25)
         
+        Filters are applied before scoring, so the top results are the best matches
+        within the filtered set. When the filters match no chunk at all, the result
+        is an empty list rather than an error.
+        
         Args:
             query: Search query
             n_results: Number of results to return
-            language: Optional language filter
-            file_type: Optional file type filter
+            language: Optional language filter (shorthand for languages=[language])
+            file_type: Optional chunk type filter (shorthand for kinds=[file_type])
+            lexical_weight: Share of the fused score given to BM25, 0.0 (vector only)
+                to 1.0 (keyword only); defaults to CONFIG.hybrid_lexical_weight
+            languages: Only chunks in one of these languages
+            kinds: Only symbols of these kinds (see SYMBOL_KINDS, e.g. 'method', 'type');
+                'test' selects chunks of test files, 'benchmark', 'fuzz' and 'example'
+                Go test functions of those kinds, 'text' the plain-text blocks of files
+                without a parser
+            path_globs: Only chunks whose file path matches one of these fnmatch patterns
+                (e.g. 'net/*' - '*' also matches across directories)
+            mmr_lambda: Rerank with Maximal Marginal Relevance, trading relevance (1.0)
+                against diversity (0.0); None (default) keeps the fused ranking
+            exclude_tests: Leave out chunks of test files (kind 'test', 'benchmark', 'fuzz' or 'example')
+            rerank: Re-score the top candidates with the reranker; None (default) reranks
+                whenever a reranker is configured, False skips it
+            rerank_candidates: How many fused candidates the reranker scores
+                (defaults to candidate_k if set, else CONFIG.reranker_candidates; never
+                fewer than n_results)
+            candidate_k: How many candidates the vector store and the keyword index each
+                return for reranking, MMR and min_score to narrow down to n_results
+                (defaults to CONFIG.candidate_k; None derives the pool from n_results)
+            vector_index: Vectors to search in a dual index: 'code', 'signature', or
+                'both' (default), which ranks each chunk by its closer vector
+            repos: Only chunks of these repositories (labels given when indexing several roots)
+            uses: Only chunks that refer to one of these imported packages or package
+                members, by path, name or member ('sync', 'github.com/pkg/errors',
+                'sync.RWMutex'), as recorded in their 'imports' metadata
+            min_score: Drop results whose 'relevance' is below this (defaults to
+                CONFIG.min_score; None keeps the top n_results however weak)
+            with_surrounding: Attach each result's 'surrounding' context, re-read from
+                the source: the file's 'imports' and, for a member of a type, the
+                'enclosing' type declaration header
+            expand_query: Add identifier variants and synonyms of the query's words to
+                the search (see expand_query); defaults to CONFIG.query_expansion
+            explain: Attach to each result an 'explain' record of how it was scored:
+                its raw vector and BM25 scores, their shares of the fused score, the
+                filters it passed and the query terms its BM25 score came from (see
+                utils.search_explain)
+            exclude_text: Leave out plain-text blocks (kind 'text', see CONFIG.text_fallback)
+            filter_expr: Only chunks matching a boolean filter expression over language,
+                kind, type, path, repo, name, uses, returns_error, errors, generated,
+                author and commit, as a string
+                ('(language=go OR language=rust) AND NOT kind=test') or a JSON tree
+                (see utils.filter_expression); it ANDs with the other filters
+            boost_kinds: Multiply the fused score of chunks of these kinds by their factor,
+                by symbol or chunk kind as for kinds ({'example': 2.0} ranks Go examples
+                first without leaving out the rest); applied before reranking
+            scope: Only chunks of files in this directory subtree of the indexed root
+                ('internal/auth' covers internal/auth/... but not internal/authz), or in
+                any of several; resolved against the sorted indexed paths, so the
+                vector search only looks at the chunks of files in scope
+            with_neighbors: Attach to each symbol result the 'neighbors' defined right
+                before and after it in its file ('previous' and 'next', None at either
+                end): 'ids' gives their id, name and lines, 'bodies' their code as well
+            depth_penalty: Divide the fused score by 1 + depth_penalty * the number of
+                directories above the chunk's file, ranking internal/auth above the
+                same match under third_party/github.com/...; defaults to
+                CONFIG.depth_penalty (0.0, off). Applied with boost_kinds
+            recency_weight: Multiply the fused score by 1 + recency_weight * freshness
+                of the chunk's file, 1.0 when just modified and halving every
+                CONFIG.recency_half_life_days; chunks indexed without a modification
+                time are not boosted. Defaults to CONFIG.recency_weight (0.0, off)
+            embedding_models: Embedding models the vector search ranks by: PRIMARY_MODEL
+                and names of extra embedding models; the rankings of several are fused
+                by reciprocal rank, each result listing its rank by each in 'model_ranks'.
+                Defaults to CONFIG.search_embedding_models (the primary model alone)
+            exclude_generated: Leave out chunks of generated files (tagged generated=true
+                when indexed, see CONFIG.generated_code)
+            generated_weight: Multiply the fused score of chunks of generated files by
+                this, ranking them below hand-written code without leaving them out;
+                defaults to CONFIG.generated_weight (1.0, off). Applied with boost_kinds
+            query_embedder: Embed the query with this one of query_embedders instead of
+                the primary embedder (the vectors searched are the primary model's)
+            fusion: How the vector and keyword rankings are combined: a Fuser, or the name
+                of a method of utils.score_fusion ('rrf' or 'weighted', with CONFIG's rrf_k
+                and fusion_normalization); defaults to self.fuser. min_score compares
+                'relevance' whichever fusion ranks the results
+            check_limits: Hold n_results, candidate_k and rerank_candidates to
+                CONFIG.max_top_k and CONFIG.max_candidate_k (see utils.result_limits);
+                False for rankings that are not returned whole, as retrieve_page's
+        
+        Raises:
+            FilterError: If filter_expr does not parse
+            ResultLimitError: If n_results, candidate_k or rerank_candidates is above
+                its limit
+            ValueError: If a boost_kinds factor or generated_weight is not a positive
+                number, depth_penalty or recency_weight is negative, scope is an absolute path or leaves the
+                indexed root, with_neighbors is not one of NEIGHBOR_MODES, candidate_k
+                is below n_results, an embedding model is unknown or not in the index, or
+                the query embedder is unknown or does not fit the index (see query_embedder),
+                or fusion names an unknown method
+        
+        Repeated searches are answered from self.query_cache until the index changes
+        or the entry expires (CONFIG.query_cache_size and CONFIG.query_cache_ttl).
+        Searches may run from several threads while the index is updated; one whose
+        ranked chunks are replaced before their content is read is ranked again.
+        
+        Returns:
+            List of relevant chunks with metadata, the fused 'rrf_score' (so named
+            whatever the fusion), and the sub-scores it came from ('vector_score'/'vector_rank',
+            'bm25_score'/'bm25_rank'; None when the chunk was not returned by that retriever).
+            'relevance' blends the vector similarity with the share of the query's BM25 weight
+            the chunk matched (0 to 1) by the lexical weight; unlike rrf_score, which only
+            orders the results of one search, it says how good a match is. An empty list means nothing matched the
+            filters or reached min_score. Reranked results
+            are ordered by their 'rerank_score'; if the reranker fails, the fused
+            ranking is returned. In a deduplicated index, a result whose body is stored
+            once for several places lists the others in 'duplicates' (their locations)
+            and 'duplicate_count'. 'highlights' lists where the query's terms occur in
+            the content of a result the keyword index matched (see
+            utils.match_highlights); it is empty for results only the vector search found.
+        """
+        _check_neighbor_mode(with_neighbors)
+        options = self._search_options(n_results, dict(
+            language=language, file_type=file_type, lexical_weight=lexical_weight, languages=languages,
+            kinds=kinds, path_globs=path_globs, mmr_lambda=mmr_lambda, exclude_tests=exclude_tests,
+            rerank=rerank, rerank_candidates=rerank_candidates, candidate_k=candidate_k, vector_index=vector_index,
+            repos=repos, uses=uses, min_score=min_score, expand_query=expand_query, explain=explain,
+            exclude_text=exclude_text, filter_expr=filter_expr, boost_kinds=boost_kinds, scope=scope,
+            depth_penalty=depth_penalty, recency_weight=recency_weight, embedding_models=embedding_models,
+            exclude_generated=exclude_generated, generated_weight=generated_weight, query_embedder=query_embedder,
+            fusion=fusion
+        ), check_limits=check_limits)
+        key = self._cache_key(query, n_results, dict(options, with_surrounding=with_surrounding,
+                                                     with_neighbors=with_neighbors))
+        with self.tracer.span('search', cached=False) as span:
+            if key is not None:
+                cached = self.query_cache.get(key)
+                if cached is not None:
+                    span.items, span.attributes['cached'] = len(cached), True
+                    return [dict(result) for result in cached]
             
+            for _ in range(SEARCH_ATTEMPTS):
+                final_results = self._rank(query, n_results, **options)
+                ranked = len(final_results)
+                self._fill_content(final_results)
+                if len(final_results) == ranked:
+                    break
+            _annotate_duplicates(final_results)
+            self._add_highlights(final_results, query, options['expand_query'])
+            if with_surrounding:
+                self._add_surrounding(final_results)
+            if with_neighbors:
+                self._add_neighbors(final_results, bodies=with_neighbors == 'bodies')
+            if key is not None:
+                self.query_cache.put(key, [dict(result) for result in final_results])
+            span.items = len(final_results)
+            return final_results
+    
+    def search(self, query: str, n_results: int = 5, snippet_lines: Optional[int] = None,
+               **filters) -> List[SearchResult]:
+        """
+        retrieve_context with typed results, for library callers
+        
+        The results are the public result types of utils.result_types, whose
+        to_dict() is the record POST /search returns; unlike the dicts of
+        retrieve_context, their fields are versioned (RESULT_SCHEMA_VERSION).
+        
+        Args:
+            query: Search query
+            n_results: Number of results to return
+            snippet_lines: Give each result a snippet of this many lines instead of
+                its content (see utils.result_format.with_snippet; get_chunk has the rest)
+            **filters: Any other retrieve_context argument
+        """
+        results = self.retrieve_context(query, n_results, **filters)
+        if snippet_lines is not None:
+            results = [with_snippet(result, snippet_lines) for result in results]
+        return [SearchResult.from_result(result) for result in results]
+    
+    def get_chunk(self, chunk_id: str) -> Optional[Dict]:
+        """A stored chunk by its id, shaped as a result without scores; None if there is none"""
+        fetched = self.collection.get(ids=[chunk_id])
+        if not fetched['ids']:
+            return None
+        return {'id': fetched['ids'][0], 'content': fetched['documents'][0], 'metadata': fetched['metadatas'][0]}
+    
+    def similar_to(self, chunk_id: str, k: int = 5, exclude_near_duplicates: bool = False,
+                   near_duplicate_similarity: Optional[float] = None,
+                   filter_expr: Union[str, Dict, FilterExpression, None] = None) -> Optional[List[Dict]]:
+        """
+        Find similar code: the k chunks nearest to an indexed chunk by its stored vector
+        Nothing is embedded. The chunk and the other parts of its symbol are left out;
+        results as similar as near_duplicate_similarity are near-duplicates, copy-paste
+        candidates rather than related implementations.
+        
+        Args:
+            chunk_id: Id of the chunk, or the 'symbol_id' of a symbol (its first part is compared)
+            k: Maximum number of results
+            exclude_near_duplicates: Leave the near-duplicates out
+            near_duplicate_similarity: Vector similarity from which a result is a near-duplicate
+                (defaults to CONFIG.near_duplicate_similarity)
+            filter_expr: Only chunks matching a boolean filter expression (see retrieve_context)
+        
+        Returns:
+            Results closest first, each with its 'vector_score' and 'near_duplicate'. In a
+            deduplicated index the exact copies of the chunk come first (vector_score 1.0) and
+            a result lists the other places its body appears in 'duplicates'. None if no chunk
+            has the id.
+        
+        Raises:
+            EmbeddingError: For a keyword-only index
+            FilterError: If filter_expr does not parse
+            ResultLimitError: If k is above CONFIG.max_top_k
+        """
+        if k < 1:
+            raise ValueError(f"k must be at least 1, got {k}")
+        check_result_limits(k, name='k')
+        if self.keyword_only:
+            raise EmbeddingError(f"Collection '{self.collection_name}' is a keyword-only index; "
+                                 f"it has no vectors to compare chunks by")
+        threshold = CONFIG.near_duplicate_similarity if near_duplicate_similarity is None else near_duplicate_similarity
+        source = self._similarity_source(chunk_id)
+        if source is None:
+            return None
+        vector, own_ids, own_symbol, copies = source
+        if exclude_near_duplicates:
+            copies = []
+        
+        allowed = {}
+        if filter_expr is not None:
+            filter_expr = parse_filter(filter_expr)
+            allowed = self._resolve_filters([], [], [], filter_expr=filter_expr)
+            if allowed is None:
+                return []
+            copies = [copy for copy in copies if filter_expr.matches(copy['metadata'], SYMBOL_KINDS)]
+        conditions = [{field: {"$in": sorted(values)}} for field, values in allowed.items()]
+        where_clause = {"$and": conditions} if len(conditions) > 1 else (conditions[0] if conditions else None)
+        
+        # The chunk's own parts (and near-duplicates, when left out) take places of the top k: fetch
+        # more until k remain or the index runs out
+        total = self.collection.count()
+        fetch = min(k + len(own_ids), total)
+        while True:
+            found = self._format_query_results(self.collection.query(
+                query_embeddings=[vector], n_results=fetch, where=where_clause,
+                include=['documents', 'metadatas', 'distances']))
+            similar = []
+            for result in found:
+                symbol = result['metadata'].get('symbol_id')
+                if result['id'] in own_ids or (own_symbol and symbol == own_symbol):
+                    continue
+                result['vector_score'] = similarity(result['distance'], self.metric)
+                result['near_duplicate'] = result['vector_score'] >= threshold
+                if not (exclude_near_duplicates and result['near_duplicate']):
+                    similar.append(result)
+            if len(copies) + len(similar) >= k or fetch >= total:
+                break
+            fetch = min(fetch * 2, total)
+        
+        results = (copies + similar)[:k]
+        _annotate_duplicates(results)
+        return results
+    
+    def _similarity_source(self, chunk_id: str) -> Optional[Tuple[List[float], Set[str], Optional[str], List[Dict]]]:
+        """
+        What similar_to compares with: the stored vector of the chunk (by id, by symbol_id, or in a
+        deduplicated index the copy it is a duplicate of), the ids of that stored chunk and of the one
+        asked for, its symbol_id, and its exact copies as results
+        """
+        include = ['documents', 'metadatas', 'embeddings']
+        fetched = self.collection.get(ids=[chunk_id], include=include)
+        if not fetched['ids']:
+            parts = self.collection.get(where={'symbol_id': chunk_id}, include=include)
+            if parts['ids']:
+                first = min(range(len(parts['ids'])), key=lambda i: int(parts['metadatas'][i].get('part_index', 0)))
+                fetched = {key: [values[first]] for key, values in parts.items() if values is not None}
+        own_symbol = fetched['metadatas'][0].get('symbol_id') if fetched['ids'] else None
+        
+        representative = None
+        if not fetched['ids'] and self._deduplicated():
+            # A duplicate has no vector of its own: it compares by the copy that is stored
+            listing = self.collection.get(where={'duplicate_count': {'$gt': 0}}, include=['metadatas'])
+            for stored_id, metadata in zip(listing['ids'], listing['metadatas']):
+                entry = next((e for e in parse_duplicates(metadata) if e.get('id') == chunk_id), None)
+                if entry is not None:
+                    fetched = self.collection.get(ids=[stored_id], include=include)
+                    own_symbol, representative = entry.get('symbol_id'), stored_id
+                    break
+        if not fetched['ids']:
+            return None
+        
+        stored_id, document, metadata = fetched['ids'][0], fetched['documents'][0], fetched['metadatas'][0]
+        copies = []
+        if representative is not None:
+            copies.append({'id': stored_id, 'content': document,
+                           'metadata': {key: value for key, value in metadata.items()
+                                        if key not in ('duplicates', 'duplicate_count')}})
+        for entry in parse_duplicates(metadata):
+            if entry.get('id') != chunk_id:
+                copies.append({'id': entry['id'], 'content': entry.get('content', document),
+                               'metadata': {key: value for key, value in entry.items() if key not in ('id', 'content')}})
+        for copy in copies:
+            copy.update(vector_score=1.0, near_duplicate=True)
+        return [float(x) for x in fetched['embeddings'][0]], {stored_id, chunk_id}, own_symbol, copies
+    
+    def retrieve_page(self, query: str, page_size: int = 10, offset: int = 0, cursor: Optional[str] = None,
+                      **filters) -> Dict:
+        """
+        One page of a search, for browsing results beyond the first few
+        
+        The search is ranked once, up to CONFIG.page_depth results (or candidate_k,
+        if smaller), and each page is a slice of that ranking, so successive pages
+        neither drop nor repeat a result while the index is unchanged. The ranking
+        is kept in self.page_cache; one evicted or expired is ranked again, which
+        gives the same order unless the reranker does not.
+        
+        Args:
+            query: Search query
+            page_size: Results per page
+            offset: Position of the page's first result in the ranking
+            cursor: The 'next_cursor' of the previous page, given with the same query
+                and filters (instead of offset)
+            **filters: Any other retrieve_context argument but n_results
+        
         Returns:
-            List of relevant chunks with metadata and similarity scores
+            Dict of 'results' (as from retrieve_context), their 'offset', the 'total'
+            results of the ranking and the 'next_cursor' of the following page (None
+            after the last one)
+        
+        Raises:
+            CursorError: If the cursor is malformed or belongs to another search
+            StaleCursorError: If the index changed since the cursor was issued
+            ResultLimitError: If page_size is above CONFIG.max_top_k, or a candidate
+                pool above CONFIG.max_candidate_k
+            ValueError: If page_size is below 1, offset is negative or given with a
+                cursor, or for the filters retrieve_context rejects
+        """
+        if page_size < 1:
+            raise ValueError(f"page_size must be at least 1, got {page_size}")
+        check_result_limits(page_size, filters.get('candidate_k'), filters.get('rerank_candidates'), name='page_size')
+        if offset < 0:
+            raise ValueError(f"offset must not be negative, got {offset}")
+        search = search_fingerprint(query, filters)
+        with self._keyword_lock:
+            version = f"{self._index_epoch}.{self.index_version}"
+        if cursor is not None:
+            if offset:
+                raise ValueError("Give either an offset or a cursor, not both")
+            offset = decode_cursor(cursor, search, version)
+        
+        ranked = self.page_cache.get((search, version))
+        if ranked is None:
+            candidate_k = filters.get('candidate_k') or CONFIG.candidate_k
+            depth = min(CONFIG.page_depth, candidate_k) if candidate_k else CONFIG.page_depth
+            ranked = self.retrieve_context(query, n_results=max(depth, 1), check_limits=False, **filters)
+            self.page_cache.put((search, version), ranked)
+        
+        end = offset + page_size
+        return {
+            'results': ranked[offset:end],
+            'offset': offset,
+            'total': len(ranked),
+            'next_cursor': encode_cursor(search, version, end) if end < len(ranked) else None,
+        }
+    
+    def expand_query(self, query: str) -> List[str]:
+        """Terms query expansion adds to a query: identifier variants and synonyms of its words"""
+        return expansion_terms(query, self.synonyms)
+    
+    def _search_options(self, n_results: int, options: Dict, check_limits: bool = True) -> Dict:
+        """
+        The _rank options of a search (any retrieve_context argument but the query,
+        n_results, check_limits and the with_* additions), checked and with their
+        configured defaults filled in; retrieve_context and iter_context both start here
+        
+        Raises:
+            ValueError, FilterError or ResultLimitError: as retrieve_context
+        """
+        options = dict(options)
+        if options.get('min_score') is None:
+            options['min_score'] = CONFIG.min_score
+        if options.get('expand_query') is None:
+            options['expand_query'] = CONFIG.query_expansion
+        if options.get('filter_expr') is not None:
+            options['filter_expr'] = parse_filter(options['filter_expr'])
+        if options.get('candidate_k') is None:
+            options['candidate_k'] = CONFIG.candidate_k
+        _check_candidate_k(options['candidate_k'], n_results)
+        if check_limits:
+            check_result_limits(n_results, options['candidate_k'], options.get('rerank_candidates'))
+        for name in ('depth_penalty', 'recency_weight'):
+            weight = options.get(name)
+            options[name] = check_weight(name, getattr(CONFIG, name) if weight is None else weight)
+        options['embedding_models'] = self.search_models(options.get('embedding_models'))
+        self.query_embedder(options.get('query_embedder'), options['embedding_models'])
+        weight = options.get('generated_weight')
+        options['generated_weight'] = check_generated_weight(CONFIG.generated_weight if weight is None else weight)
+        options['fusion'] = self.search_fuser(options.get('fusion'))
+        return options
+    
+    def _cache_key(self, query: str, n_results: int, options: Dict):
+        """Query cache key of a search (its checked options), or None when caching is off"""
+        if not self.query_cache.enabled:
+            return None
+        options = dict(options, scope=normalize_scopes(options.get('scope')) or None,
+                       fusion=repr(options.get('fusion')))
+        # Options left at their defaults are dropped, so both search entry points share
+        # entries (rerank=False is not a default: it turns the configured reranker off)
+        options = {name: value for name, value in options.items()
+                   if value is not None and (value is not False or name == 'rerank')}
+        return (normalize_query(query), n_results, freeze(options), self.index_version)
+    
+    def iter_context(self, query: str, n_results: int = 5, **filters) -> Iterator[Dict]:
+        """
+        retrieve_context as a generator, for streaming responses
+        
+        Ranking runs when iteration starts; results are then yielded best first,
+        each as soon as its content is known (keyword-only hits are fetched from
+        the store one by one instead of in a single batch at the end).
+        
+        Args:
+            query: Search query
+            n_results: Number of results to yield
+            **filters: Any other retrieve_context argument
         """
+        with_surrounding = filters.pop('with_surrounding', False)
+        with_neighbors = filters.pop('with_neighbors', None)
+        _check_neighbor_mode(with_neighbors)
+        filters = self._search_options(n_results, filters)
+        key = self._cache_key(query, n_results, dict(filters, with_surrounding=with_surrounding,
+                                                     with_neighbors=with_neighbors))
+        if key is not None:
+            cached = self.query_cache.get(key)
+            if cached is not None:
+                yield from (dict(result) for result in cached)
+                return
+        
+        ranked = []
+        for result in self._rank(query, n_results, **filters):
+            filled = [result]
+            self._fill_content(filled)
+            if not filled:
+                continue
+            _annotate_duplicates([result])
+            self._add_highlights([result], query, filters['expand_query'])
+            if with_surrounding:
+                self._add_surrounding([result])
+            if with_neighbors:
+                self._add_neighbors([result], bodies=with_neighbors == 'bodies')
+            ranked.append(result)
+            yield result
+        if key is not None:
+            self.query_cache.put(key, [dict(result) for result in ranked])
+    
+    def _rank(self, query: str, n_results: int = 5, *,
+              language: Optional[str] = None,
+              file_type: Optional[str] = None,
+              lexical_weight: Optional[float] = None,
+              languages: Optional[List[str]] = None,
+              kinds: Optional[List[str]] = None,
+              path_globs: Optional[List[str]] = None,
+              mmr_lambda: Optional[float] = None,
+              exclude_tests: bool = False,
+              rerank: Optional[bool] = None,
+              rerank_candidates: Optional[int] = None,
+              candidate_k: Optional[int] = None,
+              vector_index: Optional[str] = None,
+              repos: Optional[List[str]] = None,
+              uses: Optional[List[str]] = None,
+              min_score: Optional[float] = None,
+              expand_query: Optional[bool] = None,
+              explain: bool = False,
+              exclude_text: bool = False,
+              filter_expr: Union[str, Dict, FilterExpression, None] = None,
+              boost_kinds: Optional[Dict[str, float]] = None,
+              scope: Union[str, List[str], None] = None,
+              depth_penalty: float = 0.0,
+              recency_weight: float = 0.0,
+              embedding_models: Optional[List[str]] = None,
+              exclude_generated: bool = False,
+              generated_weight: float = 1.0,
+              query_embedder: Optional[str] = None,
+              fusion: Union[str, Fuser, None] = None) -> List[Dict]:
+        """The final ranking of retrieve_context; keyword-only hits still lack their content"""
+        if expand_query is None:
+            expand_query = CONFIG.query_expansion
+        expansion = self.expand_query(query) if expand_query else []
+        if lexical_weight is None:
+            lexical_weight = CONFIG.hybrid_lexical_weight
+        lexical_weight = 1.0 if self.keyword_only else min(max(lexical_weight, 0.0), 1.0)
+        reranker = self.reranker if rerank is not False else None
+        if rerank and reranker is None:
+            self.logger.warning("Reranking requested, but no reranker is configured")
+        if candidate_k is None:
+            candidate_k = CONFIG.candidate_k
+        _check_candidate_k(candidate_k, n_results)
+        rerank_candidates = max(rerank_candidates or candidate_k or CONFIG.reranker_candidates,
+                                n_results) if reranker else 0
+        # Fetch more for re-ranking (and a wider pool to diversify from with MMR)
+        candidates = candidate_k or max(n_results * (CONFIG.mmr_candidate_factor if mmr_lambda is not None else 2),
+                                        rerank_candidates)
+        
+        languages = (languages or []) + ([language] if language else [])
+        kinds = (kinds or []) + ([file_type] if file_type else [])
+        if filter_expr is not None:
+            filter_expr = parse_filter(filter_expr)
+        boosts = _kind_boosts(boost_kinds or {})
+        scopes = normalize_scopes(scope)
+        allowed = self._resolve_filters(
+            languages,
+            kinds,
+            path_globs or [],
+            exclude_tests,
+            repos or [],
+            uses or [],
+            exclude_text,
+            filter_expr,
+            scopes,
+            exclude_generated
+        )
+        if allowed is None:
+            self.logger.debug("Search filters match no chunks")
+            return []
+        
         # 1. Vector Search
         vector_results = []
         
-        conditions = []
-        if language:
-            conditions.append({"language": language})
-        if file_type:
-            conditions.append({"type": file_type})
-            
+        conditions = [{field: {"$in": sorted(values)}} for field, values in allowed.items()]
         where_clause = {}
         if len(conditions) > 1:
             where_clause = {"$and": conditions}
         elif len(conditions) == 1:
             where_clause = conditions[0]
-            
-        try:
-            v_res = self.collection.query(
-                query_texts=[query],
-                n_results=n_results * 2, # Fetch more for re-ranking
-                where=where_clause if where_clause else None
-            )
-            vector_results = self._format_query_results(v_res)
-        except Exception as e:
-            self.logger.error(f"Vector search failed: {e}")
-
+        
+        if lexical_weight < 1.0:
+            with self.tracer.span('vector_search', candidates=candidates) as span:
+                try:
+                    query_text = ' '.join([query] + expansion)
+                    by_model = {}
+                    for name in embedding_models or [PRIMARY_MODEL]:
+                        if name == PRIMARY_MODEL:
+                            by_model[name] = self._primary_ranking(query_text, vector_index, candidates,
+                                                                   where_clause, mmr_lambda is not None,
+                                                                   self.query_embedder(query_embedder))
+                        else:
+                            by_model[name] = self._model_ranking(name, query_text, candidates, where_clause)
+                    vector_results = _fuse_model_rankings(by_model, CONFIG.rrf_k)
+                except Exception as e:
+                    span.attributes['error'] = type(e).__name__
+                    self.logger.error(f"Vector search failed: {e}")
+                span.items = len(vector_results)
+        
         # 2. Keyword Search (BM25)
         keyword_results = []
-        if self.bm25:
-            try:
-                tokenized_query = query.lower().split()
-                # Get top N documents
-                doc_scores = self.bm25.get_scores(tokenized_query)
-                top_n_indices = np.argsort(doc_scores)[::-1][:n_results * 2]
-                
-                for idx in top_n_indices:
-                    if doc_scores[idx] > 0:
-                        # Apply filters manually since BM25 doesn't support them natively
-                        metadata = self.bm25_metadatas[idx]
-                        if language and metadata.get('language') != language:
-                            continue
-                        if file_type and metadata.get('type') != file_type:
+        keyword_weight = 0.0
+        with self._keyword_lock:
+            bm25, bm25_ids, bm25_metadatas = self.bm25, self.bm25_ids, self.bm25_metadatas
+        if bm25 and lexical_weight > 0.0:
+            with self.tracer.span('keyword_search', candidates=candidates) as span:
+                try:
+                    tokenized_query = tokenize_code(query, tokenizer_rules())
+                    doc_scores = bm25.get_scores(tokenized_query)
+                    # Expansion terms widen the match without counting in the weight a full match needs
+                    if expansion:
+                        doc_scores = [score + CONFIG.query_expansion_weight * extra
+                                      for score, extra in zip(doc_scores, bm25.get_scores(expansion))]
+                    keyword_weight = _keyword_weight(bm25, tokenized_query)
+                    
+                    # Filter before cutting to the top N, since BM25 doesn't support filters natively
+                    for idx in np.argsort(doc_scores)[::-1]:
+                        if doc_scores[idx] <= 0 or len(keyword_results) >= candidates:
+                            break
+                        metadata = bm25_metadatas[idx]
+                        if any(metadata.get(field) not in values for field, values in allowed.items()):
                             continue
-                            
+                        
                         keyword_results.append({
                             'content': "Content not stored in RAM", # We don't store content in RAM to save space
                             'metadata': metadata,
-                            'id': self.bm25_ids[idx],
-                            'score': doc_scores[idx]
+                            'id': bm25_ids[idx],
+                            'score': float(doc_scores[idx])
                         })
-            except Exception as e:
-                self.logger.error(f"Keyword search failed: {e}")
+                except Exception as e:
+                    span.attributes['error'] = type(e).__name__
+                    self.logger.error(f"Keyword search failed: {e}")
+                span.items = len(keyword_results)
         
-        # 3. Reciprocal Rank Fusion (RRF)
-        combined_results = self._rrf_fusion(vector_results, keyword_results, k=60)
+        # 3. Score fusion (reciprocal rank fusion unless configured otherwise)
+        fuser = self.search_fuser(fusion)
+        combined_results, contributions = self._fuse(vector_results, keyword_results, fuser,
+                                                     lexical_weight=lexical_weight, keyword_weight=keyword_weight)
+        if boosts or depth_penalty or recency_weight or generated_weight != 1.0:
+            combined_results = _apply_boosts(combined_results, boosts, depth_penalty, recency_weight,
+                                             generated_weight)
+        if min_score is not None:
+            relevant = [r for r in combined_results if r['relevance'] >= min_score]
+            if len(relevant) < len(combined_results):
+                self.logger.debug(f"{len(combined_results) - len(relevant)} of {len(combined_results)} "
+                                  f"candidates below min_score {min_score}")
+            combined_results = relevant
         
         # Fill in content for keyword results if missing (from vector results or DB)
         # Since we need to return content, we might need to fetch it if it came purely from BM25
         # But for simplicity, let's assume we can get it. 
         # Actually, let's just return the top N combined
         
-        final_results = combined_results[:n_results]
+        # 4. Cross-encoder reranking, over the candidate set only
+        score_key = 'rrf_score'
+        if reranker:
+            reranked = self._cross_rerank(reranker, query, combined_results[:rerank_candidates])
+            if reranked is not None:
+                combined_results, score_key = reranked, 'rerank_score'
         
-        # Fetch content for any result that doesn't have it (BM25 hits not in Vector hits)
-        ids_to_fetch = [r['id'] for r in final_results if 'content' not in r or r['content'] == "Content not stored in RAM"]
+        if mmr_lambda is not None:
+            with self.tracer.span('rerank', items=len(combined_results), method='mmr'):
+                results = self._mmr_rerank(combined_results, n_results, mmr_lambda, score_key)
+        else:
+            results = combined_results[:n_results]
+        
+        if explain:
+            filters = dict(languages=languages, kinds=kinds, path_globs=path_globs, repos=repos, uses=uses,
+                           exclude_tests=exclude_tests, exclude_text=exclude_text,
+                           exclude_generated=exclude_generated, min_score=min_score,
+                           filter_expr=filter_expr, scope=scopes)
+            self._explain(results, tokenize_code(query, tokenizer_rules()), expansion,
+                          bm25 if lexical_weight > 0.0 else None, bm25_ids, lexical_weight, keyword_weight, filters,
+                          fuser, contributions)
+        return results
+    
+    def _explain(self, results: List[Dict], query_tokens: List[str], expansion: List[str], bm25,
+                 bm25_ids: List[str], lexical_weight: float, keyword_weight: float, filters: Dict,
+                 fuser: Fuser, contributions: Dict[str, Dict[str, float]]):
+        """Attach the 'explain' record of each result (see utils.search_explain)"""
+        self._fill_content(results)
+        terms = list(dict.fromkeys(query_tokens)) + expansion
+        positions = {doc_id: index for index, doc_id in enumerate(bm25_ids)}
+        # One BM25 pass per term gives every result's share of it
+        term_scores = {term: bm25.get_scores([term]) for term in terms} \
+            if bm25 and any(r.get('bm25_rank') is not None for r in results) else {}
+        
+        for result in results:
+            vector_rank, bm25_rank = result.get('vector_rank'), result.get('bm25_rank')
+            explanation = {
+                'vector': None if vector_rank is None else dict({
+                    'score': result['vector_score'], 'distance': result.get('distance'), 'rank': vector_rank},
+                    **({'models': result['model_ranks']} if result.get('model_ranks') else {})),
+                'lexical': None,
+                'fused': dict({
+                    'score': result['rrf_score'],
+                    'vector': contributions.get(result['id'], {}).get('vector', 0.0),
+                    'lexical': contributions.get(result['id'], {}).get('lexical', 0.0),
+                    'lexical_weight': lexical_weight,
+                    'method': fuser.name,
+                }, **fuser.parameters()),
+                'relevance': result['relevance'],
+                'filters': matched_filters(result['metadata'], filters, result['relevance']),
+            }
+            position = positions.get(result['id'])
+            if bm25_rank is not None and term_scores and position is not None:
+                scores = {term: float(term_scores[term][position])
+                          * (CONFIG.query_expansion_weight if term in expansion else 1.0) for term in terms}
+                explanation['lexical'] = {
+                    'score': result['bm25_score'],
+                    'rank': bm25_rank,
+                    'share': min(result['bm25_score'] / keyword_weight, 1.0) if keyword_weight else 0.0,
+                    'terms': term_contributions(scores, self._keyword_fields(result['content'], result['metadata']),
+                                                expansion),
+                }
+            if result.get('boost') is not None:
+                explanation['fused']['boost'] = result['boost']
+            for key in ('rerank_score', 'mmr_score'):
+                if result.get(key) is not None:
+                    explanation[key] = result[key]
+            result['explain'] = explanation
+    
+    def _primary_ranking(self, query_text: str, vector_index: Optional[str], candidates: int,
+                         where: Dict, with_embeddings: bool, query_embedder: Optional[Embedder] = None) -> List[Dict]:
+        """
+        Vector ranking by the primary embedder, over the collections vector_index selects
+        (the query embedded by query_embedder instead, when given)
+        """
+        query_text = render_query(self.query_template, query_text)
+        if query_embedder is None:
+            query_embeddings = self._embed([query_text])
+        else:
+            query_embeddings, dimension = self._vectors(query_embedder, [query_text])
+            if dimension != self.embedding_dimensions:
+                raise EmbeddingError(f"{query_embedder} returned {dimension}-dimensional vectors, "
+                                     f"not {self.embedding_dimensions}")
+        rankings = []
+        for store in self._vector_stores(vector_index):
+            v_res = store.query(
+                query_embeddings=query_embeddings,
+                n_results=candidates,
+                where=where if where else None,
+                include=['documents', 'metadatas', 'distances'] + (['embeddings'] if with_embeddings else [])
+            )
+            ranking = self._format_query_results(v_res)
+            if store is not self.collection:
+                # Signature hits carry the signature text and vector: the code comes from the main collection
+                for result in ranking:
+                    result['content'] = "Content not stored in RAM"
+                    result.pop('embedding', None)
+            rankings.append(ranking)
+        return _merge_vector_rankings(rankings)
+    
+    def _model_ranking(self, name: str, query_text: str, candidates: int, where: Dict) -> List[Dict]:
+        """
+        Vector ranking by an extra embedding model, in its own collection (vectors
+        of another model are left out, so MMR compares the primary ones)
+        """
+        query_embeddings, _ = self._vectors(self.embedding_models[name], [render_query(self.query_template, query_text)],
+                                            reduce=False)
+        v_res = self.model_store(name).query(
+            query_embeddings=query_embeddings,
+            n_results=candidates,
+            where=where if where else None,
+            include=['documents', 'metadatas', 'distances']
+        )
+        return self._format_query_results(v_res)
+    
+    def _vector_stores(self, vector_index: Optional[str]) -> List[VectorStore]:
+        """Collections a vector search runs against"""
+        if vector_index not in VECTOR_INDEXES + (None,):
+            raise ValueError(f"Unknown vector index: {vector_index} (expected one of: {', '.join(VECTOR_INDEXES)})")
+        if self.embedding_mode != 'dual':
+            if vector_index not in (None, 'both') and vector_index != ('signature' if self.embedding_mode == 'signature' else 'code'):
+                self.logger.warning(f"Collection '{self.collection_name}' has only {self.embedding_mode} vectors; "
+                                    f"ignoring vector_index='{vector_index}'")
+            return [self.collection]
+        if vector_index == 'code':
+            return [self.collection]
+        if vector_index == 'signature':
+            return [self.signature_store]
+        return [self.collection, self.signature_store]
+    
+    def _cross_rerank(self, reranker: Reranker, query: str, candidates: List[Dict]) -> Optional[List[Dict]]:
+        """Candidates reordered by the reranker, or None if it failed (the fused ranking stands)"""
+        with self.tracer.span('rerank', items=len(candidates), method='cross_encoder') as span:
+            self._fill_content(candidates)
+            try:
+                return reranker.rerank(query, candidates)
+            except RerankError as e:
+                span.attributes['error'] = type(e).__name__
+                self.logger.warning(f"Reranking failed, keeping the fused ranking: {e}")
+                return None
+    
+    def _fill_content(self, results: List[Dict]):
+        """
+        Fetch content for any result that doesn't have it (BM25 hits not in Vector hits);
+        hits whose chunk is no longer stored (a re-index replaced its file since the
+        keyword index was built) are removed from results
+        """
+        ids_to_fetch = [r['id'] for r in results if 'content' not in r or r['content'] == "Content not stored in RAM"]
         if ids_to_fetch:
             fetched = self.collection.get(ids=ids_to_fetch)
             id_map = {id: (doc, meta) for id, doc, meta in zip(fetched['ids'], fetched['documents'], fetched['metadatas'])}
             
-            for r in final_results:
+            for r in results:
                 if r['id'] in id_map:
                     r['content'] = id_map[r['id']][0]
                     r['metadata'] = id_map[r['id']][1]
-                    
-        return final_results
-
-    def _rrf_fusion(self, vector_results: List[Dict], keyword_results: List[Dict], k: int = 60) -> List[Dict]:
+            results[:] = [r for r in results if r.get('content') != "Content not stored in RAM"]
+    
+    def _add_highlights(self, results: List[Dict], query: str, expand_query: bool):
+        """
+        Attach the 'highlights' of each result: the spans of its content where the
+        query's terms (and their expansion) matched, for results the keyword index
+        returned; results found only by their vector get none
+        """
+        terms = tokenize_code(query, tokenizer_rules()) + (self.expand_query(query) if expand_query else [])
+        for result in results:
+            lexical = result.get('bm25_rank') is not None and result['content'] != "Content not stored in RAM"
+            result['highlights'] = highlight_spans(result['content'], terms) if lexical else []
+    
+    def _add_surrounding(self, results: List[Dict]):
         """
-        Combine results using Reciprocal Rank Fusion
-        score = 1 / (k + rank)
+        Attach the surrounding context of each result, read from its source file
+        (results whose file cannot be read are left without one)
         """
-        scores = defaultdict(float)
+        default_root = self.source_root or (self.collection.metadata or {}).get('source_root')
+        repo_roots = self.repo_roots()
+        if not (default_root or repo_roots):
+            self.logger.warning("No source root known for this collection; set one to get surrounding context")
+            return
         
-        # Process Vector Results
-        for rank, result in enumerate(vector_results):
-            scores[result['id']] += 1 / (k + rank + 1)
+        sources: Dict[str, Optional[str]] = {}
+        for result in results:
+            metadata = result['metadata']
+            root = repo_roots.get(metadata['repo']) if metadata.get('repo') else default_root
+            if not root:
+                continue
+            source = self._read_source(root, metadata.get('filepath'), sources)
+            if source is None:
+                continue
+            surrounding = {'imports': file_imports(source, metadata.get('language', ''))}
             
-        # Process Keyword Results
-        for rank, result in enumerate(keyword_results):
-            scores[result['id']] += 1 / (k + rank + 1)
+            enclosing = self._enclosing_type(metadata)
+            if enclosing is not None:
+                type_source = self._read_source(root, enclosing['filepath'], sources)
+                if type_source is not None:
+                    start, end = enclosing['line_start'], enclosing['line_end']
+                    # Methods declared inside the type get its opening lines, others the whole type
+                    nested = (enclosing['filepath'] == metadata.get('filepath')
+                              and start <= int(metadata.get('line_start', 0)) <= end)
+                    surrounding.update({
+                        'enclosing': declaration_header(type_source.splitlines(), start, end, nested,
+                                                        CONFIG.surrounding_max_lines),
+                        'enclosing_name': enclosing['name'],
+                        'enclosing_filepath': enclosing['filepath'],
+                        'enclosing_lines': [start, end],
+                    })
+            result['surrounding'] = surrounding
+    
+    def _add_neighbors(self, results: List[Dict], bodies: bool = False):
+        """
+        Attach the symbols defined right before and after each symbol result in its
+        file, from the stored chunks of the file (read once per file and search)
+        """
+        files: Dict[Tuple[str, str], List[List[Dict]]] = {}
+        for result in results:
+            metadata = result['metadata']
+            if metadata.get('type') not in SYMBOL_CHUNK_TYPES or not metadata.get('filepath'):
+                continue
+            key = (metadata['filepath'], metadata.get('repo', ''))
+            if key not in files:
+                try:
+                    stored = self.collection.get(where=self._file_filter(*key),
+                                                 include=['documents', 'metadatas'] if bodies else ['metadatas'])
+                except Exception as e:
+                    self.logger.error(f"Error reading the symbols of {key[0]}: {e}")
+                    stored = {'ids': [], 'metadatas': []}
+                chunks = [{'id': id, 'metadata': meta, 'content': doc}
+                          for id, meta, doc in zip(stored['ids'], stored['metadatas'],
+                                                   stored.get('documents') or [None] * len(stored['ids']))]
+                files[key] = group_symbols([c for c in chunks if c['metadata'].get('type') in SYMBOL_CHUNK_TYPES])
+            previous, following = adjacent_symbols(files[key], metadata)
+            result['neighbors'] = {
+                'previous': neighbor_entry(previous, bodies) if previous else None,
+                'next': neighbor_entry(following, bodies) if following else None,
+            }
+    
+    def _read_source(self, root: str, filepath: Optional[str], cache: Dict[str, Optional[str]]) -> Optional[str]:
+        """Text of an indexed file, read once per search"""
+        if not filepath:
+            return None
+        path = os.path.join(root, filepath)
+        if path not in cache:
+            try:
+                with open(path, encoding='utf-8', errors='replace') as f:
+                    cache[path] = f.read()
+            except OSError as e:
+                self.logger.debug(f"Cannot read {filepath} for surrounding context: {e}")
+                cache[path] = None
+        return cache[path]
+    
+    def _enclosing_type(self, metadata: Dict) -> Optional[Dict]:
+        """
+        Location of the type declaration a chunk is a member of, preferring one in the
+        same file, then in the same namespace (a Go method may live apart from its type)
+        """
+        name = enclosing_type_name(metadata)
+        if not name:
+            return None
+        conditions = [{"name": name}]
+        if metadata.get('language'):
+            conditions.append({"language": metadata['language']})
+        if metadata.get('repo'):
+            conditions.append({"repo": metadata['repo']})
+        try:
+            found = self._format_get_results(self.collection.get(
+                where={"$and": conditions} if len(conditions) > 1 else conditions[0], limit=50))
+        except Exception as e:
+            self.logger.error(f"Error looking up enclosing type {name}: {e}")
+            return None
+        
+        parts = [r['metadata'] for r in found if r['metadata'].get('type') in TYPE_CHUNK_TYPES]
+        if not parts:
+            return None
+        best = min(parts, key=lambda m: (m.get('filepath') != metadata.get('filepath'),
+                                         m.get('namespace') != metadata.get('namespace'),
+                                         int(m.get('part_index', 0))))
+        # A split declaration spans all its parts
+        same = [m for m in parts if m.get('symbol_id') == best.get('symbol_id')]
+        return {
+            'name': qualified_name(best) or name,
+            'filepath': best.get('filepath'),
+            'line_start': min(int(m.get('line_start', 0)) for m in same),
+            'line_end': max(int(m.get('line_end', 0)) for m in same),
+        }
+    
+    def _mmr_rerank(self, candidates: List[Dict], n_results: int, mmr_lambda: float,
+                    score_key: str = 'rrf_score') -> List[Dict]:
+        """
+        Pick n_results candidates by Maximal Marginal Relevance:
+        lambda * relevance - (1 - lambda) * max similarity to the already picked results
+        
+        Relevance is the score_key score (fused, or from the reranker) scaled to [0, 1];
+        similarity is the cosine of the stored chunk embeddings, so no new embeddings are computed.
+        """
+        if len(candidates) <= 1:
+            return candidates[:n_results]
+        
+        # Vector hits carry their embeddings; keyword-only hits are looked up in the store
+        missing = [r['id'] for r in candidates if r.get('embedding') is None]
+        if missing:
+            fetched = self.collection.get(ids=missing, include=['embeddings'])
+            stored = dict(zip(fetched['ids'], fetched['embeddings']))
+            for r in candidates:
+                if r['id'] in stored:
+                    r['embedding'] = stored[r['id']]
+        
+        vectors = {r['id']: _unit([float(x) for x in r['embedding']]) for r in candidates
+                   if r.get('embedding') is not None}
+        # Reranker scores may be negative logits: scale from the lowest one in that case
+        low = min(min(r[score_key] for r in candidates), 0.0)
+        span = (max(r[score_key] for r in candidates) - low) or 1.0
+        
+        selected = []
+        remaining = list(candidates)
+        while remaining and len(selected) < n_results:
+            def mmr_score(result):
+                relevance = (result[score_key] - low) / span
+                vector = vectors.get(result['id'])
+                redundancy = max((_dot(vector, vectors[s['id']]) for s in selected
+                                  if vector and s['id'] in vectors), default=0.0)
+                return mmr_lambda * relevance - (1 - mmr_lambda) * redundancy
             
-        # Sort by combined score
-        sorted_ids = sorted(scores.keys(), key=lambda x: scores[x], reverse=True)
+            best = max(remaining, key=mmr_score)
+            best['mmr_score'] = mmr_score(best)
+            selected.append(best)
+            remaining.remove(best)
+        
+        for r in candidates:
+            r.pop('embedding', None)
+        return selected
+    
+    def _resolve_filters(self, languages: List[str], kinds: List[str], path_globs: List[str],
+                         exclude_tests: bool = False,
+                         repos: Optional[List[str]] = None,
+                         uses: Optional[List[str]] = None,
+                         exclude_text: bool = False,
+                         filter_expr: Optional[FilterExpression] = None,
+                         scopes: Optional[List[str]] = None,
+                         exclude_generated: bool = False) -> Optional[Dict[str, Set[str]]]:
+        """
+        Resolve search filters to the metadata values each filtered field may take
         
-        # Create final list merging data
-        merged = []
-        seen_ids = set()
+        Returns:
+            {field: allowed values} ({} when unfiltered), or None if no chunk can match
+        """
+        allowed = {}
+        if languages:
+            allowed['language'] = set(languages)
+        if repos:
+            allowed['repo'] = set(repos)
+        # 'test' (and the kinds of Go test functions) and 'text' are chunk kinds, not symbol types
+        chunk_kinds = [kind for kind in kinds if kind in CHUNK_KINDS[1:]]
+        if chunk_kinds:
+            allowed['kind'] = set(chunk_kinds)
+            kinds = [kind for kind in kinds if kind not in chunk_kinds]
+        if exclude_tests:
+            allowed['kind'] = allowed.get('kind', set(CHUNK_KINDS)) - set(TEST_KINDS)
+        if exclude_text:
+            allowed['kind'] = allowed.get('kind', set(CHUNK_KINDS)) - {'text'}
+        if kinds:
+            allowed['type'] = chunk_types_for_kinds(kinds)
+        if path_globs:
+            # Vector stores can't glob, so expand the patterns against the indexed paths
+            allowed['filepath'] = {
+                path for path in self._indexed_paths()
+                if any(fnmatch(path, pattern) for pattern in path_globs)
+            }
+        if scopes:
+            # A subtree is a range of the sorted paths: found by binary search, not a scan
+            in_scope = resolve_scopes(self._indexed_paths(), scopes)
+            allowed['filepath'] = allowed['filepath'] & in_scope if 'filepath' in allowed else in_scope
+        if uses:
+            # Dependencies live in the JSON metadata, so they resolve to the symbols that have them
+            allowed['symbol_id'] = {
+                metadata.get('symbol_id', '') for metadata in self._all_metadatas()
+                if any(uses_dependency(parse_metadata(metadata.get('metadata')).get('imports', []), dependency)
+                       for dependency in uses)
+            }
+        if filter_expr is not None:
+            # An expression can mix any fields, so it resolves to the symbols it matches
+            matching = {metadata.get('symbol_id', '') for metadata in self._all_metadatas()
+                        if filter_expr.matches(metadata, SYMBOL_KINDS)}
+            allowed['symbol_id'] = allowed['symbol_id'] & matching if 'symbol_id' in allowed else matching
+        if exclude_generated:
+            # Hand-written chunks lack the flag, so the ones to keep are resolved by symbol
+            written = {metadata.get('symbol_id', '') for metadata in self._all_metadatas()
+                       if not metadata.get('generated')}
+            allowed['symbol_id'] = allowed['symbol_id'] & written if 'symbol_id' in allowed else written
         
-        # Create a map for quick lookup
-        all_results_map = {r['id']: r for r in vector_results + keyword_results}
+        if any(not values for values in allowed.values()):
+            return None
+        return allowed
+    
+    def _fuse(self, vector_results: List[Dict], keyword_results: List[Dict], fuser: Fuser,
+              lexical_weight: float = 0.5, keyword_weight: float = 0.0) -> Tuple[List[Dict], Dict[str, Dict[str, float]]]:
+        """
+        Combine the vector and keyword rankings with a fuser, the vector ranking weighted
+        1 - w and the keyword ranking w; with reciprocal rank fusion
+        score = (1 - w) / (k + vector_rank) + w / (k + bm25_rank)
         
-        for doc_id in sorted_ids:
-            if doc_id in all_results_map:
-                result = all_results_map[doc_id]
-                result['rrf_score'] = scores[doc_id]
-                merged.append(result)
+        Each result also gets its relevance, (1 - w) * vector_score + w * the BM25 score
+        as a share of keyword_weight (capped at 1); a retriever that missed it adds 0
         
-        return merged
-
+        Returns:
+            The results, best first, and what each signal added to their fused score
+            (see Fuser.contributions)
+        """
+        merged = {}
+        
+        # Process Vector Results
+        for rank, result in enumerate(vector_results, 1):
+            entry = merged.setdefault(result['id'], result)
+            entry['vector_rank'] = rank
+            entry['vector_score'] = similarity(result['distance'], self.metric) if result.get('distance') is not None else None
+        
+        # Process Keyword Results (vector hits keep their content and distance)
+        for rank, result in enumerate(keyword_results, 1):
+            entry = merged.setdefault(result['id'], result)
+            entry['bm25_rank'] = rank
+            entry['bm25_score'] = result['score']
+        
+        shares = {}
+        for doc_id, entry in merged.items():
+            for key in ('vector_rank', 'vector_score', 'bm25_rank', 'bm25_score'):
+                entry.setdefault(key, None)
+            shares[doc_id] = min(entry['bm25_score'] / keyword_weight, 1.0) if entry['bm25_score'] and keyword_weight else 0.0
+            entry['relevance'] = (1 - lexical_weight) * (entry['vector_score'] or 0.0) + lexical_weight * shares[doc_id]
+        
+        rankings = {
+            'vector': [{'id': r['id'], 'score': merged[r['id']]['vector_score'],
+                        'relevance': merged[r['id']]['vector_score']} for r in vector_results],
+            'lexical': [{'id': r['id'], 'score': r['score'], 'relevance': shares[r['id']]} for r in keyword_results],
+        }
+        contributions = fuser.contributions(rankings, {'vector': 1 - lexical_weight, 'lexical': lexical_weight})
+        for doc_id, entry in merged.items():
+            entry['rrf_score'] = sum(contributions.get(doc_id, {}).get(signal, 0.0) for signal in SIGNALS)
+        
+        return sorted(merged.values(), key=lambda r: r['rrf_score'], reverse=True), contributions
+    
     def get_statistics(self) -> Dict:
         """
-        Get comprehensive database statistics
+        What the index holds, from the stored metadata (re-read once per index version)
         
         Returns:
-            Dictionary with statistics about indexed code
-        """
-        total_chunks = self.collection.count()
-        
-        # Get sample to analyze distribution
-        sample_size = min(1000, total_chunks)
-        if sample_size == 0:
-            return {
-                'total_chunks': 0,
-                'chunks_by_type': {},
-                'chunks_by_language': {},
-                'unique_files': 0
-            }
+            The chunk totals of utils.index_stats.count_chunks, with the 'embedding_model'
+            and 'dimensions' the index was built with (None before its first vector),
+            'keyword_only', the 'store' backend, 'collection', 'metric' and
+            'quantization', its approximate size ('vector_bytes', the raw size of the
+            stored vectors, 4 bytes a component or 1 with int8 quantization, and
+            'disk_bytes', the store's files on local disk, None on a server), and the
+            'embedding_models' of an index that has extra ones (see embedding_model_info)
+        """
+        stats = count_chunks(self._all_metadatas())
+        metadata = self.collection.metadata or {}
+        dimensions = metadata.get('embedding_dimensions')
+        component = 1 if self.collection.quantization == 'int8' else 4
+        stats.update(
+            embedding_model=metadata.get('embedding_model', self.embedder.model_name),
+            dimensions=None if dimensions is None else int(dimensions),
+            keyword_only=self.keyword_only,
+            store=self.collection.backend or type(self.collection).__name__,
+            collection=self.collection_name,
+            metric=self.metric,
+            quantization=self.collection.quantization,
+            vector_bytes=stats['total_chunks'] * int(dimensions or 0) * component,
+            disk_bytes=self.collection.disk_bytes(),
+        )
+        models = self.embedding_model_info()
+        if models:
+            stats['embedding_models'] = models
+        
+        return stats
+    
+    def list_files(self, repo: Optional[str] = None, path_glob: Optional[str] = None) -> List[Dict]:
+        """
+        The indexed files with their chunk counts, to see whether (and how) a file was indexed
+        
+        Args:
+            repo: Only files of this repository label
+            path_glob: Only files whose path matches this glob ('internal/auth/*')
+        
+        Returns:
+            One entry per file, sorted by location (see utils.index_stats.indexed_files)
+        """
+        return indexed_files(self._all_metadatas(), repo=repo, path_glob=path_glob)
+    
+    def save_index(self, path: str) -> Dict:
+        """
+        Write the whole collection (vectors, chunks, manifest) to a snapshot directory
+        The snapshot is written beside path and renamed into place when complete, so a
+        save that fails or is killed leaves the snapshot already at path untouched
+        
+        Args:
+            path: Target directory (a new or empty one, or an earlier snapshot to replace)
+        
+        Returns:
+            The snapshot manifest
+        
+        Raises:
+            SnapshotError: If path holds files that are not part of a snapshot
+        """
+        metadata = self.collection.metadata or {}
+        dimensions = metadata.get('embedding_dimensions')
+        model_name = metadata.get('embedding_model', self.embedder.model_name)
+        total = self.collection.count()
+        if dimensions is None and total:
+            # Collections indexed before dimensions were recorded: take it from the data
+            first = self.collection.get(limit=1, include=['embeddings'])
+            dimensions = len(first['embeddings'][0])
+        elif dimensions is None:
+            dimensions = self.embedder.dimensions()
+        
+        def rows(store: VectorStore):
+            page_size = max(CONFIG.batch_size, 1000)
+            for offset in range(0, store.count(), page_size):
+                page = store.get(
+                    limit=page_size, offset=offset,
+                    include=['documents', 'metadatas', 'embeddings']
+                )
+                for row in zip(page['ids'], page['documents'], page['metadatas'], page['embeddings']):
+                    yield row[0], row[1], row[2], [float(x) for x in row[3]]
+        
+        models = {name: {'embedding_model': info['model'], 'dimensions': info['dimensions']}
+                  for name, info in self.embedding_model_info().items() if info['dimensions'] is not None}
+        with staged_snapshot(path) as staging:
+            manifest = write_snapshot(staging, rows(self.collection), model_name, int(dimensions), extra=dict({
+                'collection': self.collection_name,
+                'embedding_mode': self.embedding_mode,
+                'distance_metric': metadata.get('distance_metric', self.metric),
+                'normalized_embeddings': bool(metadata.get('normalized_embeddings', self.normalize_embeddings)),
+                'code_normalization': self.code_normalization,
+                'language_code_normalization': self.language_code_normalization,
+                'reduced_dimensions': int(metadata.get('reduced_dimensions', self.reduce_dimensions) or 0),
+                'quantization': metadata.get('quantization', self.collection.quantization)
+            }, **({'embedding_models': models} if models else {})))
+            if self.embedding_mode == 'dual':
+                write_snapshot(os.path.join(staging, SIGNATURES_DIR), rows(self.signature_store), model_name,
+                               int(dimensions))
+            for name, model in models.items():
+                write_snapshot(os.path.join(staging, MODELS_DIR, name), rows(self.model_store(name)),
+                               model['embedding_model'], model['dimensions'])
+        self.logger.info(f"Saved {manifest['count']} chunks to {path}")
+        return manifest
+    
+    def export_jsonl(self, writer: TextIO, with_embeddings: bool = False,
+                     where: Optional[Dict] = None) -> int:
+        """
+        Write every chunk as one JSON object per line (schema in utils/jsonl_export.py)
+        
+        Args:
+            writer: Text stream to write to
+            with_embeddings: Include each chunk's vector
+            where: Only chunks matching this store filter (e.g. {"language": "go"})
+        
+        Returns:
+            Number of chunks written
+        """
+        include = ['documents', 'metadatas'] + (['embeddings'] if with_embeddings else [])
+        model_name = (self.collection.metadata or {}).get('embedding_model', self.embedder.model_name)
+        
+        def records():
+            page_size = max(CONFIG.batch_size, 1000)
+            offset = 0
+            while True:
+                page = self.collection.get(where=where, limit=page_size, offset=offset, include=include)
+                if not page['ids']:
+                    return
+                embeddings = page['embeddings'] if with_embeddings else [None] * len(page['ids'])
+                for chunk_id, document, metadata, embedding in zip(page['ids'], page['documents'],
+                                                                   page['metadatas'], embeddings):
+                    yield export_record(chunk_id, document, metadata, symbol_kind(metadata.get('type', '')),
+                                        embedding, model_name if with_embeddings else None)
+                offset += len(page['ids'])
+        
+        count = write_jsonl(writer, records())
+        self.logger.info(f"Exported {count} chunks")
+        return count
+    
+    def ingest_chunks(self, reader: TextIO, batch_size: Optional[int] = None) -> Dict:
+        """
+        Embed and store chunks parsed elsewhere, read as JSON lines in the
+        export schema (required fields in utils/chunk_ingest.py)
+        
+        Records are read and embedded a batch at a time, so the input can be
+        a stream. A chunk with the id of a stored one replaces it. Malformed
+        records are skipped and reported; the rest are still ingested.
+        
+        Args:
+            reader: Text stream of JSON lines (a file, sys.stdin)
+            batch_size: Chunks embedded per batch (default CONFIG.batch_size)
+        
+        Returns:
+            {'records': valid records read, 'ingested': chunks stored,
+             'malformed': [{'line', 'error'}], 'not_embedded': chunks the
+             embedder failed (listed in embedding_failures)}
+        """
+        batch_size = batch_size or CONFIG.batch_size
+        summary = {'records': 0, 'ingested': 0, 'malformed': [], 'not_embedded': 0}
+        batch = []
+        
+        def flush():
+            added = self.add_chunks_batch(batch)
+            summary['ingested'] += added
+            summary['not_embedded'] += len(batch) - added
+            batch.clear()
+        
+        for number, chunk, problem in read_records(reader):
+            if chunk is None:
+                summary['malformed'].append({'line': number, 'error': problem})
+                self.logger.warning(f"Line {number}: {problem}")
+                continue
+            summary['records'] += 1
+            batch.append(chunk)
+            if len(batch) >= batch_size:
+                flush()
+        if batch:
+            flush()
+        
+        if summary['ingested']:
+            self._build_keyword_index()
+        self.logger.info(f"Ingested {summary['ingested']} chunks, {len(summary['malformed'])} malformed records")
+        return summary
+    
+    def load_index(self, path: str) -> Dict:
+        """
+        Replace the collection with a snapshot written by save_index
+        Refuses snapshots built with another embedding model, dimension, distance metric
+        or quantization, and corrupt ones; the dimension reduction is the snapshot's.
+        The chunks are loaded into a staging collection that replaces the live one only
+        once all of them are in, so a load that fails leaves the index as it was.
+        
+        Args:
+            path: Snapshot directory
+        
+        Returns:
+            The snapshot manifest
+        """
+        manifest = read_manifest(path)
+        if manifest['embedding_model'] != self.embedder.model_name:
+            raise SnapshotError(
+                f"Snapshot was embedded with '{manifest['embedding_model']}', "
+                f"but the current embedder is '{self.embedder.model_name}'"
+            )
+        reduced = int(manifest.get('reduced_dimensions') or 0)
+        try:
+            dimension = self._reduced_dimension(self.embedder.dimensions(), reduced)
+        except EmbeddingError as e:
+            raise SnapshotError(str(e)) from e
+        if int(manifest['dimensions']) != dimension:
+            raise SnapshotError(
+                f"Snapshot vectors have {manifest['dimensions']} dimensions, "
+                f"but {self.embedder} produces {dimension}" + (f" (reduced to {reduced})" if reduced else "")
+            )
+        quantization = manifest.get('quantization', 'none')  # not recorded before quantization existed
+        if quantization != self.collection.quantization:
+            raise SnapshotError(
+                f"Snapshot holds {quantization} vectors, but the store keeps {self.collection.quantization} "
+                f"(use --quantization {quantization})"
+            )
+        metric = manifest.get('distance_metric')  # None for snapshots from before metrics were recorded
+        if metric is not None and metric != self.metric:
+            raise SnapshotError(
+                f"Snapshot was indexed for the '{metric}' metric, but the store searches by '{self.metric}'"
+            )
         
-        # Get all metadata (this could be slow for large collections)
-        all_data = self.collection.get(limit=sample_size)
+        models = dict(manifest.get('embedding_models') or {})
+        snapshot_models = {name: model.get('embedding_model') for name, model in models.items()}
+        configured = {name: model.model_name for name, model in self.embedding_models.items()}
+        if snapshot_models != configured:
+            raise SnapshotError(
+                f"Snapshot was embedded with the extra embedding models {_describe_models(snapshot_models)}, "
+                f"but {_describe_models(configured)} are configured"
+            )
+        for name, model in models.items():
+            dimension = self.embedding_models[name].dimensions()
+            if int(model.get('dimensions') or 0) != dimension:
+                raise SnapshotError(f"Snapshot vectors of embedding model '{name}' have {model.get('dimensions')} "
+                                    f"dimensions, but {self.embedding_models[name]} produces {dimension}")
+        
+        rows = read_snapshot(path)  # validates the vector blob and chunks before anything is staged
+        mode = manifest.get('embedding_mode')
+        signature_rows = read_snapshot(os.path.join(path, SIGNATURES_DIR)) if mode == 'dual' else None
+        model_rows = {name: read_snapshot(os.path.join(path, MODELS_DIR, name)) for name in models}
         
-        stats = {
-            'total_chunks': total_chunks,
-            'chunks_by_type': defaultdict(int),
-            'chunks_by_language': defaultdict(int),
-            'files': set()
+        # Snapshots from before normalization existed embedded code as written
+        code_normalization = manifest.get('code_normalization', 'off')
+        language_code_normalization = dict(manifest.get('language_code_normalization') or {})
+        normalized = bool(manifest.get('normalized_embeddings', False))
+        metadata = {
+            "description": "Chrome source code for vulnerability analysis",
+            'embedding_model': manifest['embedding_model'],
+            'embedding_dimensions': int(manifest['dimensions']),
+            'distance_metric': metric or self.metric,
+            'normalized_embeddings': normalized,
+            'reduced_dimensions': reduced,
+            'quantization': quantization,
+            'code_normalization': code_normalization,
+            'language_code_normalization': json.dumps(language_code_normalization, sort_keys=True)
         }
+        if mode:
+            metadata['embedding_mode'] = mode
+        if models:
+            metadata['embedding_models'] = json.dumps(snapshot_models, sort_keys=True)
+        
+        staging = self.collection.open_collection(self.collection_name + LOAD_SUFFIX)
+        signature_staging = self.collection.open_collection(
+            self.collection_name + SIGNATURE_COLLECTION_SUFFIX + LOAD_SUFFIX) if signature_rows is not None else None
+        model_staging = {name: self.collection.open_collection(
+            self.collection_name + MODEL_COLLECTION_INFIX + name + LOAD_SUFFIX) for name in models}
+        staged = [store for store in (staging, signature_staging) if store is not None] + list(model_staging.values())
+        try:
+            for store in staged:
+                store.reset()  # left over by a load that did not finish
+            staging.modify(metadata=metadata)
+            self._add_rows(staging, rows)
+            if signature_staging is not None:
+                self._add_rows(signature_staging, signature_rows)
+            for name, store in model_staging.items():
+                store.modify(metadata={'embedding_model': snapshot_models[name],
+                                       'embedding_dimensions': int(models[name]['dimensions'])})
+                self._add_rows(store, model_rows[name])
+        except BaseException:
+            for store in staged:
+                store.reset()
+            raise
+        
+        previous_stores = self._stores()
+        previous_models = set(self.recorded_models())
+        with self._keyword_lock:
+            self.collection.replace_with(staging)
+            if signature_staging is not None:
+                self.signature_store.replace_with(signature_staging)
+            elif self.signature_store in previous_stores:
+                self.signature_store.reset()  # the snapshot is not a dual index
+            for name, store in model_staging.items():
+                self.model_store(name).replace_with(store)
+            for name in previous_models - set(models):
+                self.model_store(name).reset()
+            self.bm25, self.bm25_ids, self.bm25_metadatas = None, [], []
+        self.embedding_mode = mode or self.embedding_mode
+        self.normalize_embeddings = normalized
+        self.reduce_dimensions = reduced
+        self.code_normalization = code_normalization
+        self.language_code_normalization = language_code_normalization
+        self._index_changed()
+        
+        self._build_keyword_index()
+        self.logger.info(f"Loaded {manifest['count']} chunks from {path}")
+        return manifest
+    
+    def _add_rows(self, store: VectorStore, rows: Iterable):
+        """Insert pre-embedded (id, document, metadata, vector) rows in batches"""
+        batch = []
+        for row in rows:
+            batch.append(row)
+            if len(batch) >= CONFIG.batch_size:
+                self._add_batch(store, batch)
+                batch = []
+        if batch:
+            self._add_batch(store, batch)
+    
+    def _add_batch(self, store: VectorStore, rows: List):
+        ids, documents, metadatas, embeddings = zip(*rows)
+        store.add(
+            ids=list(ids),
+            documents=list(documents),
+            metadatas=list(metadatas),
+            embeddings=list(embeddings)
+        )
+    
+    def migrate_embedder(self, embedder: Embedder, cancel: Optional[CancelToken] = None,
+                         progress: Optional[MigrationCallback] = None) -> Dict:
+        """
+        Re-embed the whole index with another embedding model, then switch to it
         
-        if all_data and 'metadatas' in all_data:
-            for metadata in all_data['metadatas']:
-                stats['chunks_by_type'][metadata.get('type', 'unknown')] += 1
-                stats['chunks_by_language'][metadata.get('language', 'unknown')] += 1
-                stats['files'].add(metadata.get('filepath', ''))
+        Chunks are embedded again from their stored code and metadata, nothing is
+        re-parsed. The new vectors are staged next to the index (see
+        utils/embedding_migration.py), which keeps answering searches with the old
+        model until every chunk is embedded and is then replaced at once, with the
+        new model and dimension recorded. Texts the embedder has cached (a
+        CachedEmbedder) are not sent to its backend again. Chunks indexed while a
+        migration runs are not carried over, so do not update the index meanwhile.
         
-        stats['unique_files'] = len(stats['files'])
-        del stats['files']  # Don't include the set in return
+        Args:
+            embedder: The embedder to move to
+            cancel: Stops the migration before its next batch, leaving the index as it was
+            progress: Called with a MigrationProgress after every batch
         
-        # Convert defaultdicts to regular dicts
-        stats['chunks_by_type'] = dict(stats['chunks_by_type'])
-        stats['chunks_by_language'] = dict(stats['chunks_by_language'])
+        Returns:
+            Summary: models and dimensions before and after, chunks (and signatures of
+            a dual index) re-embedded, and whether the migration was cancelled
         
-        return stats
+        Raises:
+            EmbeddingError: If another migration is running or the embedder fails on a
+                chunk; the index is left as it was
+        """
+        if not self._migration_lock.acquire(blocking=False):
+            raise EmbeddingError(f"Collection '{self.collection_name}' is already being migrated")
+        try:
+            return self._migrate(embedder, cancel, progress)
+        finally:
+            self._migration_lock.release()
+    
+    def _migrate(self, embedder: Embedder, cancel: Optional[CancelToken],
+                 progress: Optional[MigrationCallback]) -> Dict:
+        recorded = dict(self.collection.metadata or {})
+        dimension = self._reduced_dimension(embedder.dimensions(), embedder=embedder)
+        stores = self._primary_stores()  # extra embedding models keep their vectors
+        state = MigrationProgress(chunks_total=sum(store.count() for store in stores))
+        summary = {
+            'previous_model': recorded.get('embedding_model', self.embedder.model_name),
+            'previous_dimensions': recorded.get('embedding_dimensions'),
+            'embedding_model': embedder.model_name,
+            'embedding_dimensions': dimension,
+            'chunks': self.collection.count(),
+            'cancelled': False
+        }
+        if self.embedding_mode == 'dual':
+            summary['signatures'] = self.signature_store.count()
+        
+        def report(**changes):
+            for key, value in changes.items():
+                setattr(state, key, value)
+            if progress is not None:
+                progress(state)
+        
+        if not state.chunks_total:
+            # Nothing to re-embed: the first vectors stored will record the new model
+            self.embedder = embedder
+            report(stage='done')
+            return summary
+        
+        report()
+        staging = [store.open_collection(store.name + MIGRATION_SUFFIX) for store in stores]
+        try:
+            for store, target in zip(stores, staging):
+                target.reset()  # left over by a migration that did not finish
+                main = store is self.collection
+                page_size = max(CONFIG.batch_size, 1)
+                for offset in range(0, store.count(), page_size):
+                    if cancel is not None and cancel.cancelled:
+                        for staged in staging:
+                            staged.reset()
+                        summary['cancelled'] = True
+                        report(stage='cancelled')
+                        return summary
+                    page = store.get(limit=page_size, offset=offset, include=['documents', 'metadatas'])
+                    # Signature collections hold the very text their vectors embed (before the template)
+                    texts = [self._embedding_text(stored_chunk(document, metadata)) if main
+                             else self._document_text(stored_chunk(document, metadata), document)
+                             for document, metadata in zip(page['documents'], page['metadatas'])]
+                    unique = list(dict.fromkeys(texts))
+                    with self.tracer.span('embed', items=len(unique)):
+                        vectors, produced = self._vectors(embedder, unique)
+                    if produced != dimension:
+                        raise EmbeddingError(f"{embedder} produced {produced}-dimensional vectors, "
+                                             f"not the {dimension} it reports")
+                    by_text = dict(zip(unique, vectors))
+                    target.add(ids=page['ids'], documents=page['documents'], metadatas=page['metadatas'],
+                               embeddings=[by_text[text] for text in texts])
+                    report(chunks_embedded=state.chunks_embedded + len(texts))
+                target.modify(metadata=dict(recorded, embedding_model=embedder.model_name,
+                                            embedding_dimensions=dimension) if main else dict(store.metadata or {}))
+        except BaseException:
+            for staged in staging:
+                staged.reset()
+            raise
+        
+        report(stage='swapping')
+        with self._keyword_lock:
+            for store, target in zip(stores, staging):
+                store.replace_with(target)
+            self.embedder = embedder
+            self.index_version += 1
+        self.logger.info(f"Migrated {summary['chunks']} chunks from '{summary['previous_model']}' "
+                         f"to '{embedder.model_name}'")
+        report(stage='done')
+        return summary
     
     def clear_collection(self):
         """Delete and recreate the collection"""
         self.logger.warning(f"Deleting collection '{self.collection_name}'")
-        self.client.delete_collection(self.collection_name)
+        for store in self._stores():
+            store.reset()
         
-        self.collection = self.client.get_or_create_collection(
-            name=self.collection_name,
-            embedding_function=self.embedding_fn
-        )
-        
-        self._chunk_counter = 0
-        self.bm25 = None # Reset BM25
+        with self._keyword_lock:
+            self.bm25, self.bm25_ids, self.bm25_metadatas = None, [], [] # Reset BM25
+        self._index_changed()
         self.logger.info("Collection cleared and recreated")
     
     def _format_get_results(self, results: Dict) -> List[Dict]:
@@ -356,13 +3409,17 @@ class ChromeRAGSystem:
         formatted = []
         
         if results and 'documents' in results and results['documents']:
+            embeddings = results.get('embeddings')
             for i, doc in enumerate(results['documents'][0]):
-                formatted.append({
+                result = {
                     'content': doc,
                     'metadata': results['metadatas'][0][i] if results['metadatas'] else {},
                     'distance': results['distances'][0][i] if results['distances'] else None,
                     'id': results['ids'][0][i] if results['ids'] else None
-                })
+                }
+                if embeddings is not None:
+                    result['embedding'] = embeddings[0][i]
+                formatted.append(result)
         
         return formatted
 
@@ -386,7 +3443,7 @@ class VulnerabilityAnalyzer:
         
         Args:
             filepath: Path to file to analyze
-            
+        
         Returns:
             Dictionary with analysis results
         """
@@ -417,7 +3474,7 @@ RELATED CODE CONTEXT:
 {context_text}
 
 Please identify any potential security issues."""
-        
+
         # TODO: Send to your AI API
         # response = await your_ai_api.complete(prompt)
         
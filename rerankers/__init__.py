@@ -0,0 +1,46 @@
+"""
+Rerankers package: pluggable backends that re-score search candidates
+"""
+
+from typing import Optional
+
+from .base_reranker import Reranker, RerankError
+from .http_reranker import HttpReranker
+
+
+def create_reranker(backend: Optional[str] = None, url: Optional[str] = None,
+                    model_name: Optional[str] = None) -> Optional[Reranker]:
+    """
+    Build a reranker from its backend name (defaults come from CONFIG)
+    
+    Args:
+        backend: 'none' (no reranking stage) or 'http' (reranking server)
+        url: Optional server URL (http)
+        model_name: Optional model override
+    
+    Returns:
+        The reranker, or None for 'none'
+    """
+    from config import CONFIG
+    
+    backend = backend or CONFIG.reranker_backend
+    if backend == 'none':
+        return None
+    if backend == 'http':
+        return HttpReranker(
+            base_url=url or CONFIG.reranker_url,
+            model_name=model_name or CONFIG.reranker_model,
+            api=CONFIG.reranker_api,
+            batch_size=CONFIG.reranker_batch_size,
+            timeout=CONFIG.reranker_timeout,
+            max_retries=CONFIG.reranker_max_retries
+        )
+    raise ValueError(f"Unknown reranker backend: {backend}")
+
+
+__all__ = [
+    'Reranker',
+    'RerankError',
+    'HttpReranker',
+    'create_reranker',
+]
@@ -0,0 +1,69 @@
+#!/usr/bin/env python3
+"""
+Base reranker class defining the interface for all reranking backends
+"""
+
+from abc import ABC, abstractmethod
+from typing import Dict, Iterator, List
+
+
+class RerankError(Exception):
+    """Raised when a reranking backend cannot score documents"""
+
+
+class Reranker(ABC):
+    """
+    Abstract base class for rerankers: models that score (query, document)
+    pairs jointly, such as cross-encoders
+    """
+    
+    def __init__(self, model_name: str, batch_size: int = 32):
+        """
+        Args:
+            model_name: Identifier of the reranking model
+            batch_size: Maximum number of documents sent to the backend per call
+        """
+        self.model_name = model_name
+        self.batch_size = max(1, batch_size)
+    
+    @abstractmethod
+    def score(self, query: str, documents: List[str]) -> List[float]:
+        """
+        Score documents against a query
+        
+        Args:
+            query: Search query
+            documents: Texts to score
+        
+        Returns:
+            One relevance score per document, in input order (higher is better)
+        """
+        pass
+    
+    def rerank(self, query: str, results: List[Dict]) -> List[Dict]:
+        """
+        Reorder search results by relevance to the query
+        
+        Args:
+            query: Search query
+            results: Results with their 'content'
+        
+        Returns:
+            The same results, best first, each with its 'rerank_score'
+        """
+        if not results:
+            return []
+        scores = self.score(query, [r['content'] for r in results])
+        if len(scores) != len(results):
+            raise RerankError(f"{self} returned {len(scores)} scores for {len(results)} documents")
+        for result, score in zip(results, scores):
+            result['rerank_score'] = float(score)
+        return sorted(results, key=lambda r: r['rerank_score'], reverse=True)
+    
+    def _batches(self, documents: List[str]) -> Iterator[List[str]]:
+        """Split documents into backend-sized batches"""
+        for start in range(0, len(documents), self.batch_size):
+            yield documents[start:start + self.batch_size]
+    
+    def __repr__(self) -> str:
+        return f"{self.__class__.__name__}(model={self.model_name!r})"
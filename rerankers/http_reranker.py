@@ -0,0 +1,117 @@
+#!/usr/bin/env python3
+"""
+HTTP reranker: cross-encoder scores from a local reranking server
+Speaks the two common APIs: Hugging Face text-embeddings-inference
+(POST /rerank with "texts") and the Cohere-style /v1/rerank with
+"documents", served by Jina, Infinity, vLLM and llama.cpp
+"""
+
+import json
+import time
+import urllib.request
+import urllib.error
+from typing import Dict, List
+
+from .base_reranker import Reranker, RerankError
+
+
+# HTTP statuses worth retrying: the server is up but busy or restarting
+RETRYABLE_STATUSES = {500, 502, 503, 504}
+
+# Request path per API flavour
+API_PATHS = {'tei': '/rerank', 'cohere': '/v1/rerank'}
+
+
+class HttpReranker(Reranker):
+    """Scores (query, document) pairs with a model behind a reranking server"""
+    
+    def __init__(self, base_url: str = 'http://localhost:8081', model_name: str = 'BAAI/bge-reranker-base',
+                 api: str = 'tei', batch_size: int = 32, timeout: float = 30.0, max_retries: int = 3,
+                 backoff: float = 0.5):
+        """
+        Args:
+            base_url: Reranking server URL
+            model_name: Model to ask for (ignored by servers that serve a single model)
+            api: 'tei' (text-embeddings-inference) or 'cohere' (/v1/rerank)
+            batch_size: Documents per request
+            timeout: Per-request timeout in seconds
+            max_retries: Retries for connection errors and 5xx responses
+            backoff: Initial retry delay in seconds (doubles on each retry)
+        """
+        if api not in API_PATHS:
+            raise ValueError(f"Unknown reranker API: {api} (expected one of: {', '.join(API_PATHS)})")
+        super().__init__(model_name, batch_size)
+        self.base_url = base_url.rstrip('/')
+        self.api = api
+        self.timeout = timeout
+        self.max_retries = max_retries
+        self.backoff = backoff
+    
+    def score(self, query: str, documents: List[str]) -> List[float]:
+        """Score documents in batches of batch_size"""
+        scores = []
+        for batch in self._batches(documents):
+            scores.extend(self._score_batch(query, batch))
+        return scores
+    
+    def _score_batch(self, query: str, batch: List[str]) -> List[float]:
+        """Score one batch; servers may answer in any order, so scores are placed by index"""
+        if self.api == 'tei':
+            response = self._post({'query': query, 'texts': batch, 'truncate': True})
+            entries = [(e.get('index'), e.get('score')) for e in _as_list(response)]
+        else:
+            response = self._post({'model': self.model_name, 'query': query, 'documents': batch,
+                                   'top_n': len(batch)})
+            results = response.get('results') if isinstance(response, dict) else None
+            entries = [(e.get('index'), e.get('relevance_score')) for e in _as_list(results)]
+        
+        scores = [None] * len(batch)
+        for index, score in entries:
+            if isinstance(index, int) and 0 <= index < len(batch) and isinstance(score, (int, float)):
+                scores[index] = float(score)
+        if None in scores:
+            raise RerankError(f"Reranker at {self.base_url} returned no score for some of {len(batch)} documents")
+        return scores
+    
+    def _post(self, payload: Dict):
+        """POST a JSON payload, retrying transient failures with exponential backoff"""
+        path = API_PATHS[self.api]
+        request_body = json.dumps(payload).encode('utf-8')
+        delay = self.backoff
+        
+        for attempt in range(self.max_retries + 1):
+            request = urllib.request.Request(
+                self.base_url + path,
+                data=request_body,
+                headers={'Content-Type': 'application/json'},
+                method='POST'
+            )
+            try:
+                with urllib.request.urlopen(request, timeout=self.timeout) as response:
+                    return json.loads(response.read().decode('utf-8'))
+            
+            except urllib.error.HTTPError as e:
+                message = e.read().decode('utf-8', 'ignore')
+                if e.code not in RETRYABLE_STATUSES or attempt == self.max_retries:
+                    raise RerankError(f"Reranker request failed ({e.code}): {message}") from e
+            
+            except (urllib.error.URLError, ConnectionError, TimeoutError) as e:
+                if attempt == self.max_retries:
+                    reason = getattr(e, 'reason', e)
+                    raise RerankError(f"Cannot reach the reranker at {self.base_url}: {reason}") from e
+            
+            except ValueError as e:
+                raise RerankError(f"Reranker at {self.base_url} sent invalid JSON: {e}") from e
+            
+            time.sleep(delay)
+            delay *= 2
+        
+        raise RerankError(f"Reranker request to {path} failed")  # not reached
+    
+    def __repr__(self) -> str:
+        return f"HttpReranker(url={self.base_url!r}, model={self.model_name!r}, api={self.api!r})"
+
+
+def _as_list(value) -> List[Dict]:
+    """The entries of a response list, skipping anything that is not an object"""
+    return [entry for entry in value if isinstance(entry, dict)] if isinstance(value, list) else []
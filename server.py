@@ -0,0 +1,640 @@
+#!/usr/bin/env python3
+"""
+HTTP query server for the RAG system
+Standard library only (http.server), started with `cli.py serve`
+
+    GET  /health    index status, query cache hit/miss counters, background
+                    re-index state (with its progress while it runs) and how the
+                    start-up warm-up went (null without one); 503 "warming" until
+                    the warm-up is done (see utils/warmup.py)
+    GET  /schema    JSON Schema of the /search response (see utils/result_types.py)
+    GET  /searches  the saved searches: query template, parameters (with their
+                    defaults, null when required) and the fields each one sets
+    GET  /chunk?id=<chunk id>
+                    the whole stored chunk a result's "snippet" was cut from
+    GET  /stats     what the index holds: chunk counts by type, kind, language and
+                    repository, the number of files, the embedding model and dimension,
+                    the store backend and approximate size (see utils/index_stats.py)
+    GET  /files?repo=<label>&path=<glob>
+                    the indexed files (optionally of one repository, matching a glob)
+                    with their language and chunk counts, and their "total"
+    POST /search    {"query": ..., "top_k": 5, "languages": [...], "kinds": [...],
+                     "path_globs": [...], "repos": [...], "uses": [...], "lexical_weight": 0.5, "mmr_lambda": null,
+                     "min_score": null,
+                     "exclude_tests": false, "exclude_text": false, "rerank": null, "rerank_candidates": null,
+                     "candidate_k": null, "vector_index": null, "with_surrounding": false, "expand_query": null,
+                     "explain": false, "filter": null, "boost_kinds": null, "scope": null,
+                     "with_neighbors": null, "depth_penalty": null, "recency_weight": null,
+                     "embedding_models": null, "exclude_generated": false, "generated_weight": null,
+                     "query_embedder": null, "fusion": null, "rrf_k": null, "fusion_normalization": null,
+                     "offset": null, "cursor": null, "snippet_lines": null, "group_by": null}
+                    with "Accept: application/x-ndjson", one result per line as
+                    each is ready instead of a single JSON document; /search?explain=true
+                    (or "explain": true) adds how each result was scored; "filter" is a
+                    boolean expression, '(language=go OR language=rust) AND NOT kind=test',
+                    or the same as a JSON tree (see utils/filter_expression.py); "boost_kinds"
+                    scales the scores of kinds ({"example": 2.0} ranks Go examples first);
+                    "scope" restricts the search to a directory subtree ("internal/auth")
+                    or a list of them; "with_neighbors" ("ids" or "bodies") adds the
+                    symbols defined right before and after each result in its file;
+                    "candidate_k" is how many candidates each retriever fetches for
+                    reranking and MMR to narrow down to top_k (at least top_k);
+                    "depth_penalty" ranks deeply nested paths lower and "recency_weight"
+                    recently modified files higher (0 or null: off); "embedding_models"
+                    names the models to rank by ("primary" and extra embedding models,
+                    fused by reciprocal rank when several are given); "exclude_generated"
+                    leaves out chunks of generated files and "generated_weight" scales
+                    their scores instead (0.5 ranks hand-written code first);
+                    "query_embedder" embeds the query with one of the configured
+                    query_embedders instead of the index's embedder (same dimension,
+                    same vector space; unknown or mismatched ones are a 400);
+                    "fusion" picks how the vector and BM25 rankings are combined, "rrf"
+                    (with its constant "rrf_k") or "weighted" (a sum of scores brought to
+                    0-1 by "fusion_normalization": "minmax", "max" or "absolute"; see
+                    utils/score_fusion.py), the configured one when left out;
+                    {"saved": "error-handling", "params": {"package": "auth"}} runs a
+                    saved search (see utils/saved_searches.py), the request's own
+                    fields replacing the ones it sets; "offset" or "cursor" (the
+                    "next_cursor" of the previous reply, sent with the same query and
+                    filters) returns a page of top_k results of one stable ranking, with
+                    its "offset", the "total" and the "next_cursor" (see
+                    utils/result_pages.py); a cursor from before the index changed is a 409;
+                    "snippet_lines" returns each result's "snippet" instead of its "content":
+                    that many lines around its matches (the top, signature first, for a
+                    result found by its vector alone) and the "full" /chunk path of the rest;
+                    "group_by" ("file" or "type") adds "groups" next to the results, in
+                    the order of their best result, each with the "positions" of its
+                    results and their summed "score" (see utils/result_groups.py); the
+                    results themselves are the same, and grouped searches are not streamed
+    POST /lookup    {"name": ..., "limit": 20, "kinds": [...], "languages": [...], "repos": [...]}
+                    go to symbol: exact, prefix and fuzzy name matches, no embedding
+    POST /similar   {"id": ..., "top_k": 10, "exclude_near_duplicates": false, "filter": ...}
+                    code similar to an indexed chunk (or symbol_id), by its stored vector:
+                    result records with their "vector_score" and "near_duplicate" (at least
+                    "near_duplicate_similarity" similar, a copy rather than related code)
+    POST /embed     {"text": ...}: the query vector searches would use for the text,
+                    comparable with the index's vectors, and its dimension
+    POST /reindex   incremental re-index of the source root in the background
+                    (only when the server was started with reindexing allowed);
+                    closing the server cancels a re-index still running
+
+A "top_k" above max_top_k, a "candidate_k" or "rerank_candidates" above
+max_candidate_k, and a response body above max_response_bytes get a 400 rather
+than a search or a reply that size (see utils/result_limits.py); a streamed
+search ends with an {"error": ...} line where it would pass the size limit
+"""
+
+import itertools
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from typing import Dict, Optional
+from urllib.parse import parse_qs, urlsplit
+
+from embedders import EmbeddingError
+from rag import VECTOR_INDEXES, ChromeRAGSystem
+from utils.cancellation import CancelToken
+from utils.filter_expression import parse_filter
+from utils.logger import get_logger
+from utils.path_scope import normalize_scopes
+from utils.result_pages import CursorError, StaleCursorError
+from utils.result_format import chunk_record, result_record
+from utils.result_groups import GROUP_MODES, group_results
+from utils.result_limits import ResultLimitError, check_filter_limits, check_response_size, check_result_limits
+from utils.result_types import citation, json_schema
+from utils.saved_searches import describe_saved_search, expand_saved_search, saved_search_problems
+from utils.score_fusion import create_fuser
+from utils.symbol_neighbors import NEIGHBOR_MODES
+from utils.warmup import warm_up
+
+
+# Largest request body accepted (search requests are small)
+MAX_BODY_BYTES = 1 << 20
+
+# Accept type that makes /search stream one JSON result per line
+NDJSON_TYPE = 'application/x-ndjson'
+
+
+class RAGServer(ThreadingHTTPServer):
+    """Threaded HTTP server sharing one RAG system between request threads"""
+    
+    daemon_threads = True
+    
+    def __init__(self, address, rag: ChromeRAGSystem, source_path: Optional[str] = None,
+                 allow_reindex: bool = False, indexer=None, saved_searches: Optional[Dict] = None,
+                 snippet_lines: Optional[int] = None, warmup: bool = False, warmup_query: Optional[str] = None):
+        """
+        Args:
+            address: (host, port) to listen on
+            rag: RAG system over the persisted index to serve
+            source_path: Source root that /reindex updates the index from
+            allow_reindex: Enable POST /reindex
+            indexer: ChromeIndexer used by /reindex (default: one over rag)
+            saved_searches: Searches /search runs by name (CONFIG.saved_searches in cli.py)
+            snippet_lines: Snippet length of /search requests that do not set one (CONFIG.snippet_lines)
+            warmup: Warm the index and the embedder up in the background (see utils/warmup.py);
+                /health answers 503 until it is done (CONFIG.warmup)
+            warmup_query: Sample search the warm-up runs (CONFIG.warmup_query)
+        """
+        super().__init__(address, RAGRequestHandler)
+        self.rag = rag
+        self.source_path = source_path
+        self.allow_reindex = allow_reindex
+        self.indexer = indexer
+        self.saved_searches = dict(saved_searches or {})
+        self.snippet_lines = snippet_lines
+        self.logger = get_logger()
+        
+        self._reindex_lock = threading.Lock()
+        self._reindex_thread: Optional[threading.Thread] = None
+        self._reindex_cancel: Optional[CancelToken] = None
+        self.last_reindex: Optional[Dict] = None
+        
+        self.warmup_query = warmup_query
+        self.last_warmup: Optional[Dict] = None
+        self._warmup_thread: Optional[threading.Thread] = None
+        if warmup:
+            self._warmup_thread = threading.Thread(target=self._warm_up, daemon=True)
+            self._warmup_thread.start()
+    
+    @property
+    def warming(self) -> bool:
+        return self._warmup_thread is not None and self._warmup_thread.is_alive()
+    
+    def wait_warm(self, timeout: Optional[float] = None) -> bool:
+        """Wait for the warm-up to finish; False if it is still running after timeout seconds"""
+        if self._warmup_thread is not None:
+            self._warmup_thread.join(timeout)
+        return not self.warming
+    
+    @property
+    def reindexing(self) -> bool:
+        return self._reindex_thread is not None and self._reindex_thread.is_alive()
+    
+    def start_reindex(self) -> bool:
+        """Start a background incremental re-index; False if one is already running"""
+        with self._reindex_lock:
+            if self.reindexing:
+                return False
+            self._reindex_cancel = CancelToken()
+            self._reindex_thread = threading.Thread(target=self._reindex, daemon=True)
+            self._reindex_thread.start()
+            return True
+    
+    def reindex_progress(self) -> Optional[Dict]:
+        """Progress of the running re-index, or None"""
+        if not self.reindexing or self.indexer is None:
+            return None
+        return self.indexer.progress.to_dict()
+    
+    def server_close(self):
+        """Stop a running re-index at its next file, then close the socket"""
+        if self.reindexing:
+            self._reindex_cancel.cancel()
+            self._reindex_thread.join()
+        super().server_close()
+    
+    def _warm_up(self):
+        self.logger.info("Warm-up started")
+        self.last_warmup = warm_up(self.rag, self.warmup_query)
+        if self.last_warmup['status'] == 'ok':
+            self.logger.info(f"Warm-up done in {self.last_warmup['seconds']}s")
+        else:
+            self.logger.warning(f"Warm-up failed after {self.last_warmup['seconds']}s: {self.last_warmup['error']}")
+    
+    def _reindex(self):
+        if self.indexer is None:
+            from indexer import ChromeIndexer
+            self.indexer = ChromeIndexer(self.rag)
+        
+        self.logger.info(f"Background re-index of {self.source_path} started")
+        try:
+            # Single process: forking a worker pool from a server thread is unsafe
+            stats = self.indexer.update_index(self.source_path, parallel=False, cancel=self._reindex_cancel)
+            self.last_reindex = {'status': 'cancelled' if stats.get('cancelled') else 'ok', 'stats': stats}
+        except Exception as e:
+            self.logger.error(f"Background re-index failed: {e}")
+            self.last_reindex = {'status': 'error', 'error': str(e)}
+
+
+class RAGRequestHandler(BaseHTTPRequestHandler):
+    """JSON request handler for RAGServer"""
+    
+    server: RAGServer
+    
+    def do_GET(self):
+        if self.path == '/schema':
+            return self._reply(200, json_schema())
+        if self.path == '/searches':
+            return self._saved_searches()
+        url = urlsplit(self.path)
+        if url.path == '/chunk':
+            return self._chunk(parse_qs(url.query))
+        if url.path == '/stats':
+            return self._reply(200, self.server.rag.get_statistics())
+        if url.path == '/files':
+            return self._files(parse_qs(url.query))
+        if self.path != '/health':
+            return self._reply(404, {'error': f'Unknown endpoint: {self.path}'})
+        if self.server.warming:
+            return self._reply(503, {'status': 'warming'})
+        self._reply(200, {
+            'status': 'ok',
+            'chunks': self.server.rag.collection.count(),
+            'embedding_model': self.server.rag.embedder.model_name,
+            'keyword_only': self.server.rag.keyword_only,
+            'index_version': self.server.rag.index_version,
+            'query_cache': self.server.rag.query_cache.stats(),
+            'reindex': {
+                'enabled': self.server.allow_reindex,
+                'running': self.server.reindexing,
+                'progress': self.server.reindex_progress(),
+                'last': self.server.last_reindex,
+            },
+            'warmup': self.server.last_warmup,
+        })
+    
+    def do_POST(self):
+        url = urlsplit(self.path)
+        if url.path == '/search':
+            return self._search(parse_qs(url.query))
+        if url.path == '/lookup':
+            return self._lookup()
+        if url.path == '/similar':
+            return self._similar()
+        if url.path == '/embed':
+            return self._embed()
+        if url.path == '/reindex':
+            return self._reindex()
+        self._reply(404, {'error': f'Unknown endpoint: {self.path}'})
+    
+    def _search(self, params: Dict):
+        try:
+            request = self._read_json()
+            if request.get('saved') is not None:
+                request = self._saved_request(request)
+            query = request.get('query')
+            if not isinstance(query, str) or not query.strip():
+                raise ValueError("'query' must be a non-empty string")
+            top_k = int(request.get('top_k', 5))
+            if top_k < 1:
+                raise ValueError("'top_k' must be at least 1")
+            for key in ('languages', 'kinds', 'path_globs', 'repos', 'uses'):
+                values = request.get(key)
+                if values is not None and not (isinstance(values, list) and all(isinstance(v, str) for v in values)):
+                    raise ValueError(f"'{key}' must be a list of strings")
+            weights = {key: float(request[key]) for key in ('lexical_weight', 'mmr_lambda', 'min_score',
+                                                            'depth_penalty', 'recency_weight', 'generated_weight')
+                       if request.get(key) is not None}
+            for key in ('depth_penalty', 'recency_weight'):
+                if weights.get(key, 0.0) < 0:
+                    raise ValueError(f"'{key}' must not be negative")
+            if weights.get('generated_weight', 1.0) <= 0:
+                raise ValueError("'generated_weight' must be positive")
+            exclude_tests = request.get('exclude_tests', False)
+            if not isinstance(exclude_tests, bool):
+                raise ValueError("'exclude_tests' must be a boolean")
+            exclude_text = request.get('exclude_text', False)
+            if not isinstance(exclude_text, bool):
+                raise ValueError("'exclude_text' must be a boolean")
+            exclude_generated = request.get('exclude_generated', False)
+            if not isinstance(exclude_generated, bool):
+                raise ValueError("'exclude_generated' must be a boolean")
+            rerank = request.get('rerank')
+            if rerank is not None and not isinstance(rerank, bool):
+                raise ValueError("'rerank' must be a boolean")
+            expand_query = request.get('expand_query')
+            if expand_query is not None and not isinstance(expand_query, bool):
+                raise ValueError("'expand_query' must be a boolean")
+            rerank_candidates = request.get('rerank_candidates')
+            if rerank_candidates is not None:
+                rerank_candidates = int(rerank_candidates)
+                if rerank_candidates < 1:
+                    raise ValueError("'rerank_candidates' must be at least 1")
+            candidate_k = request.get('candidate_k')
+            if candidate_k is not None:
+                candidate_k = int(candidate_k)
+                if candidate_k < top_k:
+                    raise ValueError("'candidate_k' must be at least 'top_k'")
+            check_result_limits(top_k, candidate_k, rerank_candidates, name="'top_k'")
+            with_surrounding = request.get('with_surrounding', False)
+            if not isinstance(with_surrounding, bool):
+                raise ValueError("'with_surrounding' must be a boolean")
+            with_neighbors = request.get('with_neighbors')
+            if with_neighbors is not None and with_neighbors not in NEIGHBOR_MODES:
+                raise ValueError(f"'with_neighbors' must be one of: {', '.join(NEIGHBOR_MODES)}")
+            vector_index = request.get('vector_index')
+            if vector_index is not None and vector_index not in VECTOR_INDEXES:
+                raise ValueError(f"'vector_index' must be one of: {', '.join(VECTOR_INDEXES)}")
+            explain = request.get('explain', False)
+            if 'explain' in params:
+                value = params['explain'][-1].lower()
+                if value not in ('true', 'false', '1', '0'):
+                    raise ValueError("'explain' must be true or false")
+                explain = value in ('true', '1')
+            if not isinstance(explain, bool):
+                raise ValueError("'explain' must be a boolean")
+            filter_expr = self._request_filter(request)
+            boost_kinds = request.get('boost_kinds')
+            if boost_kinds is not None and not (isinstance(boost_kinds, dict) and all(
+                    isinstance(f, (int, float)) and not isinstance(f, bool) and f > 0 for f in boost_kinds.values())):
+                raise ValueError("'boost_kinds' must map kinds to positive numbers")
+            scope = request.get('scope')
+            if scope is not None and not (isinstance(scope, str) or (
+                    isinstance(scope, list) and all(isinstance(s, str) for s in scope))):
+                raise ValueError("'scope' must be a path or a list of paths")
+            scope = normalize_scopes(scope) or None
+            embedding_models = request.get('embedding_models')
+            if embedding_models is not None:
+                if not (isinstance(embedding_models, list) and all(isinstance(m, str) for m in embedding_models)):
+                    raise ValueError("'embedding_models' must be a list of strings")
+                self.server.rag.search_models(embedding_models)  # unknown models are a bad request
+            query_embedder = request.get('query_embedder')
+            if query_embedder is not None:
+                if not isinstance(query_embedder, str):
+                    raise ValueError("'query_embedder' must be the name of a query embedder")
+                self.server.rag.query_embedder(query_embedder, embedding_models)
+            fuser = None
+            if any(request.get(key) is not None for key in ('fusion', 'rrf_k', 'fusion_normalization')):
+                fuser = create_fuser(request.get('fusion'), request.get('rrf_k'), request.get('fusion_normalization'))
+            offset, cursor = request.get('offset'), request.get('cursor')
+            if offset is not None and (not isinstance(offset, int) or isinstance(offset, bool) or offset < 0):
+                raise ValueError("'offset' must be a non-negative integer")
+            if cursor is not None and not isinstance(cursor, str):
+                raise ValueError("'cursor' must be the 'next_cursor' of a page")
+            snippet_lines = request.get('snippet_lines', self.server.snippet_lines)
+            if snippet_lines is not None and (not isinstance(snippet_lines, int) or isinstance(snippet_lines, bool)
+                                              or snippet_lines < 1):
+                raise ValueError("'snippet_lines' must be a positive integer")
+            group_by = request.get('group_by')
+            if group_by is not None and group_by not in GROUP_MODES:
+                raise ValueError(f"'group_by' must be one of: {', '.join(GROUP_MODES)}")
+            paged = offset is not None or cursor is not None
+            if paged and NDJSON_TYPE in self.headers.get('Accept', ''):
+                raise ValueError("Paged searches ('offset', 'cursor') are not streamed")
+            if group_by and NDJSON_TYPE in self.headers.get('Accept', ''):
+                raise ValueError("Grouped searches ('group_by') are not streamed")
+        except (TypeError, ValueError) as e:
+            return self._reply(400, {'error': str(e)})
+        except EmbeddingError as e:
+            self.server.logger.error(f"Embedding failed: {e}")
+            return self._reply(500, {'error': f'Embedding failed: {e}'})
+        
+        search = dict(
+            query=query,
+            n_results=top_k,
+            lexical_weight=weights.get('lexical_weight'),
+            languages=request.get('languages'),
+            kinds=request.get('kinds'),
+            path_globs=request.get('path_globs'),
+            repos=request.get('repos'),
+            uses=request.get('uses'),
+            min_score=weights.get('min_score'),
+            mmr_lambda=weights.get('mmr_lambda'),
+            exclude_tests=exclude_tests,
+            exclude_text=exclude_text,
+            rerank=rerank,
+            rerank_candidates=rerank_candidates,
+            candidate_k=candidate_k,
+            vector_index=vector_index,
+            with_surrounding=with_surrounding,
+            with_neighbors=with_neighbors,
+            expand_query=expand_query,
+            explain=explain,
+            filter_expr=filter_expr,
+            boost_kinds=boost_kinds,
+            depth_penalty=weights.get('depth_penalty'),
+            recency_weight=weights.get('recency_weight'),
+            embedding_models=embedding_models,
+            exclude_generated=exclude_generated,
+            generated_weight=weights.get('generated_weight'),
+            query_embedder=query_embedder,
+            scope=scope,
+            fusion=fuser
+        )
+        if paged:
+            return self._search_page(search, offset or 0, cursor, snippet_lines, group_by)
+        if NDJSON_TYPE in self.headers.get('Accept', ''):
+            return self._stream_search(search, snippet_lines)
+        
+        try:
+            results = self.server.rag.retrieve_context(**search)
+        except Exception as e:
+            self.server.logger.error(f"Search failed: {e}")
+            return self._reply(500, {'error': f'Search failed: {e}'})
+        
+        body = {'query': query, 'results': [result_record(r, snippet_lines) for r in results]}
+        if group_by:
+            body['groups'] = group_results(results, group_by)
+        self._reply(200, body)
+    
+    def _search_page(self, search: Dict, offset: int, cursor: Optional[str], snippet_lines: Optional[int],
+                     group_by: Optional[str] = None):
+        page_size = search.pop('n_results')
+        try:
+            page = self.server.rag.retrieve_page(page_size=page_size, offset=offset, cursor=cursor, **search)
+        except StaleCursorError as e:
+            return self._reply(409, {'error': str(e)})
+        except CursorError as e:
+            return self._reply(400, {'error': str(e)})
+        except Exception as e:
+            self.server.logger.error(f"Search failed: {e}")
+            return self._reply(500, {'error': f'Search failed: {e}'})
+        
+        body = {'query': search['query'], 'results': [result_record(r, snippet_lines) for r in page['results']],
+                'offset': page['offset'], 'total': page['total'], 'next_cursor': page['next_cursor']}
+        if group_by:
+            # Groups of the page's results: positions index its results, not the whole ranking
+            body['groups'] = group_results(page['results'], group_by)
+        self._reply(200, body)
+    
+    def _chunk(self, params: Dict):
+        chunk_id = params.get('id', [None])[-1]
+        if not chunk_id:
+            return self._reply(400, {'error': "'id' must be a chunk id"})
+        chunk = self.server.rag.get_chunk(chunk_id)
+        if chunk is None:
+            return self._reply(404, {'error': f'Unknown chunk: {chunk_id}'})
+        self._reply(200, {'chunk': chunk_record(chunk)})
+    
+    def _files(self, params: Dict):
+        files = self.server.rag.list_files(repo=params.get('repo', [None])[-1] or None,
+                                           path_glob=params.get('path', [None])[-1] or None)
+        self._reply(200, {'files': files, 'total': len(files)})
+    
+    def _saved_request(self, request: Dict) -> Dict:
+        """A /search request naming a saved search, with the fields the search sets filled in"""
+        saved, params = request['saved'], request.get('params')
+        if not isinstance(saved, str):
+            raise ValueError("'saved' must be the name of a saved search")
+        if params is not None and not isinstance(params, dict):
+            raise ValueError("'params' must map parameter names to values")
+        search = expand_saved_search(self.server.saved_searches, saved, params)
+        search.update((key, value) for key, value in request.items() if key not in ('saved', 'params'))
+        return search
+    
+    def _saved_searches(self):
+        # Malformed searches are left out (config validate reports them)
+        self._reply(200, {'saved_searches': {name: describe_saved_search(spec)
+                                             for name, spec in self.server.saved_searches.items()
+                                             if not saved_search_problems({name: spec})}})
+    
+    def _lookup(self):
+        try:
+            request = self._read_json()
+            name = request.get('name')
+            if not isinstance(name, str) or not name.strip():
+                raise ValueError("'name' must be a non-empty string")
+            limit = int(request.get('limit', 20))
+            if limit < 1:
+                raise ValueError("'limit' must be at least 1")
+            for key in ('kinds', 'languages', 'repos'):
+                values = request.get(key)
+                if values is not None and not (isinstance(values, list) and all(isinstance(v, str) for v in values)):
+                    raise ValueError(f"'{key}' must be a list of strings")
+        except (TypeError, ValueError) as e:
+            return self._reply(400, {'error': str(e)})
+        
+        symbols = self.server.rag.lookup(name, limit=limit, kinds=request.get('kinds'),
+                                         languages=request.get('languages'), repos=request.get('repos'))
+        for symbol in symbols:
+            symbol['citation'] = citation(symbol)
+        self._reply(200, {'name': name, 'symbols': symbols})
+    
+    def _similar(self):
+        try:
+            request = self._read_json()
+            chunk_id = request.get('id')
+            if not isinstance(chunk_id, str) or not chunk_id:
+                raise ValueError("'id' must be a chunk id")
+            top_k = int(request.get('top_k', 10))
+            if top_k < 1:
+                raise ValueError("'top_k' must be at least 1")
+            check_result_limits(top_k, name="'top_k'")
+            exclude_near_duplicates = request.get('exclude_near_duplicates', False)
+            if not isinstance(exclude_near_duplicates, bool):
+                raise ValueError("'exclude_near_duplicates' must be a boolean")
+            threshold = request.get('near_duplicate_similarity')
+            if threshold is not None:
+                threshold = float(threshold)
+            filter_expr = self._request_filter(request)
+        except (TypeError, ValueError) as e:
+            return self._reply(400, {'error': str(e)})
+        
+        rag = self.server.rag
+        if rag.keyword_only:
+            return self._reply(409, {'error': f"Collection '{rag.collection_name}' is a keyword-only index"})
+        results = rag.similar_to(chunk_id, k=top_k, exclude_near_duplicates=exclude_near_duplicates,
+                                 near_duplicate_similarity=threshold, filter_expr=filter_expr)
+        if results is None:
+            return self._reply(404, {'error': f'Unknown chunk: {chunk_id}'})
+        self._reply(200, {'id': chunk_id, 'results': [dict(result_record(result), near_duplicate=result['near_duplicate'])
+                                                      for result in results]})
+    
+    def _embed(self):
+        try:
+            text = self._read_json().get('text')
+            if not isinstance(text, str) or not text.strip():
+                raise ValueError("'text' must be a non-empty string")
+        except (TypeError, ValueError) as e:
+            return self._reply(400, {'error': str(e)})
+        
+        rag = self.server.rag
+        if rag.keyword_only:
+            return self._reply(409, {'error': f"Collection '{rag.collection_name}' is a keyword-only index"})
+        try:
+            embedding = rag.embed_query(text)
+        except EmbeddingError as e:
+            self.server.logger.error(f"Embedding failed: {e}")
+            return self._reply(500, {'error': f'Embedding failed: {e}'})
+        self._reply(200, {'embedding': embedding, 'dimensions': len(embedding),
+                          'model': rag.embedder.model_name, 'metric': rag.metric,
+                          'normalized': rag.normalize_embeddings})
+    
+    def _stream_search(self, search: Dict, snippet_lines: Optional[int]):
+        """
+        Reply with one JSON result per line, flushed as each is ready
+        Failures before the first result get a normal error reply; later ones
+        end the stream with an {"error": ...} line
+        """
+        results = self.server.rag.iter_context(**search)
+        try:
+            first = next(results, None)
+        except Exception as e:
+            self.server.logger.error(f"Search failed: {e}")
+            return self._reply(500, {'error': f'Search failed: {e}'})
+        
+        # No Content-Length: the response ends when the connection closes
+        self.close_connection = True
+        self.send_response(200)
+        self.send_header('Content-Type', NDJSON_TYPE)
+        self.send_header('Cache-Control', 'no-cache')
+        self.end_headers()
+        if first is None:
+            return
+        written = 0
+        try:
+            for result in itertools.chain([first], results):
+                line = json.dumps(result_record(result, snippet_lines)).encode('utf-8') + b'\n'
+                written += len(line)
+                check_response_size(written)
+                self.wfile.write(line)
+                self.wfile.flush()
+        except ResultLimitError as e:
+            self._write_line({'error': str(e)})
+        except (BrokenPipeError, ConnectionResetError):
+            self.server.logger.debug("Client closed a streaming search early")
+        except Exception as e:
+            self.server.logger.error(f"Search failed while streaming: {e}")
+            self._write_line({'error': f'Search failed: {e}'})
+    
+    def _write_line(self, body: Dict):
+        self.wfile.write(json.dumps(body).encode('utf-8') + b'\n')
+        self.wfile.flush()
+    
+    def _reindex(self):
+        if not self.server.allow_reindex:
+            return self._reply(403, {'error': 'Re-indexing is disabled (start the server with --allow-reindex)'})
+        if not self.server.start_reindex():
+            return self._reply(409, {'error': 'A re-index is already running'})
+        self._reply(202, {'status': 'started', 'source': self.server.source_path})
+    
+    def _request_filter(self, request: Dict):
+        """The parsed 'filter' of a request, or None; one too long or too deeply nested is a ValueError"""
+        expression = request.get('filter')
+        if expression is None:
+            return None
+        check_filter_limits(expression)
+        try:
+            return parse_filter(expression)
+        except RecursionError:
+            raise ValueError("'filter' is nested too deeply")
+    
+    def _read_json(self) -> Dict:
+        length = int(self.headers.get('Content-Length') or 0)
+        if length > MAX_BODY_BYTES:
+            raise ValueError("Request body too large")
+        try:
+            body = json.loads(self.rfile.read(length) or b'{}')
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Invalid JSON: {e}")
+        except RecursionError:
+            raise ValueError("Request body is nested too deeply")
+        if not isinstance(body, dict):
+            raise ValueError("Request body must be a JSON object")
+        return body
+    
+    def _reply(self, status: int, body: Dict):
+        data = json.dumps(body).encode('utf-8')
+        try:
+            check_response_size(len(data))
+        except ResultLimitError as e:
+            status, data = 400, json.dumps({'error': str(e)}).encode('utf-8')
+        self.send_response(status)
+        self.send_header('Content-Type', 'application/json')
+        self.send_header('Content-Length', str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+    
+    def log_message(self, format, *args):
+        self.server.logger.debug(f"{self.address_string()} {format % args}")
@@ -0,0 +1,88 @@
+"""
+Stores package: pluggable backends that keep chunk vectors and metadata
+"""
+
+from typing import Optional
+
+from .base_store import METRICS, StoreError, VectorStore, check_quantization, similarity
+from .chroma_store import ChromaStore
+from .pgvector_store import PgvectorStore
+from .qdrant_store import QdrantStore
+from .quantization import QUANTIZATIONS
+from .sqlite_store import SqliteStore
+
+
+def create_store(backend: Optional[str] = None, collection_name: Optional[str] = None,
+                 db_path: Optional[str] = None, url: Optional[str] = None,
+                 sqlite_path: Optional[str] = None, dsn: Optional[str] = None,
+                 metric: Optional[str] = None, quantization: Optional[str] = None) -> VectorStore:
+    """
+    Build a vector store from its backend name (defaults come from CONFIG)
+    
+    Args:
+        backend: 'chroma' (local ChromaDB directory), 'qdrant' (Qdrant server),
+            'sqlite' (single local file) or 'pgvector' (PostgreSQL)
+        collection_name: Optional collection override
+        db_path: Optional ChromaDB directory (chroma)
+        url: Optional server URL (qdrant)
+        sqlite_path: Optional database file (sqlite)
+        dsn: Optional connection string (pgvector)
+        metric: Optional distance metric override (one of METRICS)
+        quantization: Stored vector format, one of QUANTIZATIONS (default:
+            CONFIG.vector_quantization; None keeps the collection's); only the sqlite
+            and qdrant stores quantize
+    """
+    from config import CONFIG
+    
+    backend = backend or CONFIG.vector_store
+    collection_name = collection_name or CONFIG.collection_name
+    metric = metric or CONFIG.distance_metric
+    quantization = quantization or CONFIG.vector_quantization
+    if backend == 'chroma':
+        check_quantization(ChromaStore, quantization)
+        return ChromaStore(db_path or CONFIG.db_path, collection_name, metric=metric)
+    if backend == 'qdrant':
+        return QdrantStore(
+            url=url or CONFIG.qdrant_url,
+            name=collection_name,
+            api_key=CONFIG.qdrant_api_key,
+            metric=metric,
+            distance=CONFIG.qdrant_distance,
+            dimensions=CONFIG.qdrant_dimensions,
+            batch_size=CONFIG.qdrant_batch_size,
+            timeout=CONFIG.qdrant_timeout,
+            max_retries=CONFIG.qdrant_max_retries,
+            quantization=quantization
+        )
+    if backend == 'sqlite':
+        return SqliteStore(sqlite_path or CONFIG.sqlite_path, collection_name, metric=metric,
+                           quantization=quantization)
+    if backend == 'pgvector':
+        check_quantization(PgvectorStore, quantization)
+        return PgvectorStore(
+            dsn=dsn or CONFIG.pgvector_dsn,
+            name=collection_name,
+            table_prefix=CONFIG.pgvector_table_prefix,
+            dimensions=CONFIG.pgvector_dimensions,
+            index=CONFIG.pgvector_index,
+            metric=metric,
+            lists=CONFIG.pgvector_lists,
+            probes=CONFIG.pgvector_probes,
+            ef_search=CONFIG.pgvector_ef_search,
+            pool_size=CONFIG.pgvector_pool_size
+        )
+    raise ValueError(f"Unknown vector store: {backend}")
+
+
+__all__ = [
+    'VectorStore',
+    'StoreError',
+    'METRICS',
+    'QUANTIZATIONS',
+    'similarity',
+    'ChromaStore',
+    'QdrantStore',
+    'PgvectorStore',
+    'SqliteStore',
+    'create_store',
+]
@@ -0,0 +1,187 @@
+#!/usr/bin/env python3
+"""
+Base vector store class defining the interface for all storage backends
+
+The interface follows the Chroma collection API (ids, documents, metadatas,
+embeddings, and 'where' filters with $and/$or/$in/$nin/$ne/$gt/$gte/$lt/$lte),
+so the RAG system talks to every backend the same way.
+
+Distances are smaller-is-closer for every metric: 1 - cosine similarity for
+'cosine', 1 - dot product for 'dot' and the Euclidean distance for 'l2'.
+
+Backends that can quantize vectors (see stores/quantization.py) keep them in a
+smaller format; they still take and return float vectors.
+"""
+
+from abc import ABC, abstractmethod
+from typing import Dict, List, Optional
+
+from .quantization import QUANTIZATIONS
+
+
+# Distance metrics a collection can be searched by
+METRICS = ('cosine', 'dot', 'l2')
+
+
+class StoreError(Exception):
+    """Raised when a vector store backend cannot serve a request"""
+
+
+def similarity(distance: float, metric: str = 'cosine') -> float:
+    """Higher-is-closer score of a store distance: 1 - distance for cosine and dot, 1 / (1 + distance) otherwise"""
+    if metric in ('cosine', 'dot'):
+        return 1.0 - distance
+    return 1.0 / (1.0 + distance)
+
+
+def check_quantization(store_class, quantization: Optional[str]):
+    """Raise unless a store class can keep vectors in this format (None: the collection's own)"""
+    if quantization is None:
+        return
+    if quantization not in QUANTIZATIONS:
+        raise StoreError(f"Unknown quantization '{quantization}' (expected one of: {', '.join(QUANTIZATIONS)})")
+    if quantization not in store_class.supported_quantizations:
+        raise StoreError(
+            f"{store_class.__name__} cannot store {quantization} vectors "
+            f"(supported: {', '.join(store_class.supported_quantizations)})"
+        )
+
+
+def select_fields(result: Dict, include: List[str]) -> Dict:
+    """Blank the result fields that were not asked for (Chroma returns None for them)"""
+    return {key: (value if key == 'ids' or key in include else None) for key, value in result.items()}
+
+
+class VectorStore(ABC):
+    """Abstract base class for all vector store backends"""
+    
+    # Metrics the backend can search by, and vector formats it can keep
+    supported_metrics = METRICS
+    supported_quantizations = ('none',)
+    # Name of the backend in create_store and CONFIG.vector_store
+    backend = ''
+    
+    def __init__(self, name: str, metric: str = 'cosine', quantization: str = 'none'):
+        """
+        Args:
+            name: Collection name
+            metric: Distance metric of the collection (one of supported_metrics)
+            quantization: Format of the stored vectors (one of supported_quantizations)
+        """
+        if metric not in self.supported_metrics:
+            raise StoreError(
+                f"{self.__class__.__name__} cannot search by '{metric}' "
+                f"(expected one of: {', '.join(self.supported_metrics)})"
+            )
+        check_quantization(self.__class__, quantization)
+        self.name = name
+        self.metric = metric
+        self.quantization = quantization
+    
+    @property
+    @abstractmethod
+    def metadata(self) -> Dict:
+        """Collection-level metadata (embedding_model, embedding_dimensions, ...)"""
+        pass
+    
+    @abstractmethod
+    def modify(self, metadata: Dict):
+        """Replace the collection-level metadata"""
+        pass
+    
+    @abstractmethod
+    def count(self) -> int:
+        """Number of stored chunks"""
+        pass
+    
+    @abstractmethod
+    def add(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+            embeddings: List[List[float]]):
+        """Insert chunks with their vectors"""
+        pass
+    
+    def replace(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+                embeddings: List[List[float]]):
+        """
+        Insert chunks in place of any stored under the same ids, so a search running
+        meanwhile finds the old or the new version of each; deleted then added here,
+        backends that can do both in one transaction (or upsert) override this
+        """
+        self.delete(ids=ids)
+        self.add(ids=ids, documents=documents, metadatas=metadatas, embeddings=embeddings)
+    
+    @abstractmethod
+    def get(self, ids: Optional[List[str]] = None, where: Optional[Dict] = None,
+            limit: Optional[int] = None, offset: Optional[int] = None,
+            include: Optional[List[str]] = None) -> Dict:
+        """
+        Fetch chunks by id and/or filter
+        
+        Args:
+            ids: Only these ids
+            where: Metadata filter
+            limit: Maximum number of chunks
+            offset: Number of matching chunks to skip
+            include: Any of 'documents', 'metadatas', 'embeddings'
+                (default: documents and metadatas)
+        
+        Returns:
+            {'ids': [...], 'documents': [...], 'metadatas': [...], 'embeddings': [...]}
+        """
+        pass
+    
+    @abstractmethod
+    def query(self, query_embeddings: List[List[float]], n_results: int = 10,
+              where: Optional[Dict] = None, include: Optional[List[str]] = None) -> Dict:
+        """
+        Nearest-neighbour search
+        
+        Returns:
+            One list per query vector under 'ids', 'documents', 'metadatas',
+            'distances' (and 'embeddings' when included), closest first
+        """
+        pass
+    
+    @abstractmethod
+    def update(self, ids: List[str], metadatas: List[Dict]):
+        """Replace the metadata of stored chunks, keeping their vectors"""
+        pass
+    
+    @abstractmethod
+    def delete(self, ids: List[str]):
+        """Remove chunks by id"""
+        pass
+    
+    @abstractmethod
+    def reset(self):
+        """Drop every chunk and the collection metadata"""
+        pass
+    
+    @abstractmethod
+    def open_collection(self, name: str) -> 'VectorStore':
+        """Another collection of the same backend and location (created if missing)"""
+        pass
+    
+    def replace_with(self, source: 'VectorStore'):
+        """
+        Take over the chunks and metadata of another collection of this backend, leaving it empty
+        Copied page by page here; backends that can swap in one transaction override this
+        """
+        self.reset()
+        page_size = 1000
+        for offset in range(0, source.count(), page_size):
+            page = source.get(limit=page_size, offset=offset, include=['documents', 'metadatas', 'embeddings'])
+            self.add(ids=page['ids'], documents=page['documents'], metadatas=page['metadatas'],
+                     embeddings=[list(vector) for vector in page['embeddings']])
+        self.modify(metadata=dict(source.metadata or {}))
+        source.reset()
+    
+    def disk_bytes(self) -> Optional[int]:
+        """
+        Size of the store's files on local disk (shared by the collections they hold),
+        None for a backend on a server
+        """
+        return None
+    
+    def __repr__(self) -> str:
+        return f"{self.__class__.__name__}(collection={self.name!r}, metric={self.metric!r})"
@@ -0,0 +1,106 @@
+#!/usr/bin/env python3
+"""
+Chroma vector store: an in-process persistent ChromaDB collection
+"""
+
+import os
+from typing import Dict, List, Optional
+
+from .base_store import StoreError, VectorStore
+
+
+DESCRIPTION = "Chrome source code for vulnerability analysis"
+
+# Chroma's HNSW space for each metric (its 'l2' distances are squared)
+SPACES = {'cosine': 'cosine', 'dot': 'ip', 'l2': 'l2'}
+
+
+class ChromaStore(VectorStore):
+    """Stores chunks in a ChromaDB collection on local disk"""
+    
+    backend = 'chroma'
+    
+    def __init__(self, path: str, name: str, metric: str = 'cosine'):
+        """
+        Args:
+            path: ChromaDB storage directory
+            name: Collection name
+            metric: Distance metric; fixed by Chroma when the collection is created
+        """
+        import chromadb
+        
+        super().__init__(name, metric)
+        self.path = path
+        self.client = chromadb.PersistentClient(path=path)
+        self.collection = self._open()
+    
+    def _open(self):
+        # Vectors come from the RAG system's embedder, not from Chroma
+        collection = self.client.get_or_create_collection(
+            name=self.name,
+            embedding_function=None,
+            metadata={"description": DESCRIPTION, "hnsw:space": SPACES[self.metric]}
+        )
+        space = (collection.metadata or {}).get('hnsw:space')
+        if space is not None and space != SPACES[self.metric]:
+            metric = next((m for m, s in SPACES.items() if s == space), space)
+            raise StoreError(
+                f"Chroma collection '{self.name}' was created for the '{metric}' metric, not '{self.metric}'"
+            )
+        return collection
+    
+    @property
+    def metadata(self) -> Dict:
+        return self.collection.metadata or {}
+    
+    def modify(self, metadata: Dict):
+        # Chroma refuses to change the HNSW settings of an existing collection
+        self.collection.modify(metadata={k: v for k, v in metadata.items() if not k.startswith('hnsw:')})
+    
+    def count(self) -> int:
+        return self.collection.count()
+    
+    def disk_bytes(self) -> Optional[int]:
+        total = 0
+        for directory, _, filenames in os.walk(self.path):
+            total += sum(os.path.getsize(os.path.join(directory, name)) for name in filenames)
+        return total
+    
+    def add(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+            embeddings: List[List[float]]):
+        self.collection.add(ids=ids, documents=documents, metadatas=metadatas, embeddings=embeddings)
+    
+    def replace(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+                embeddings: List[List[float]]):
+        self.collection.upsert(ids=ids, documents=documents, metadatas=metadatas, embeddings=embeddings)
+    
+    def get(self, ids: Optional[List[str]] = None, where: Optional[Dict] = None,
+            limit: Optional[int] = None, offset: Optional[int] = None,
+            include: Optional[List[str]] = None) -> Dict:
+        kwargs = {'ids': ids, 'where': where, 'limit': limit, 'offset': offset}
+        if include is not None:
+            kwargs['include'] = include
+        return self.collection.get(**{k: v for k, v in kwargs.items() if v is not None})
+    
+    def query(self, query_embeddings: List[List[float]], n_results: int = 10,
+              where: Optional[Dict] = None, include: Optional[List[str]] = None) -> Dict:
+        kwargs = {'query_embeddings': query_embeddings, 'n_results': n_results, 'where': where}
+        if include is not None:
+            kwargs['include'] = include
+        results = self.collection.query(**kwargs)
+        if self.metric == 'l2' and results.get('distances'):
+            results['distances'] = [[max(d, 0.0) ** 0.5 for d in row] for row in results['distances']]
+        return results
+    
+    def update(self, ids: List[str], metadatas: List[Dict]):
+        self.collection.update(ids=ids, metadatas=metadatas)
+    
+    def delete(self, ids: List[str]):
+        self.collection.delete(ids=ids)
+    
+    def reset(self):
+        self.client.delete_collection(self.name)
+        self.collection = self._open()
+    
+    def open_collection(self, name: str) -> 'ChromaStore':
+        return ChromaStore(self.path, name, self.metric)
@@ -0,0 +1,436 @@
+#!/usr/bin/env python3
+"""
+PostgreSQL vector store: chunks in a pgvector table
+Each collection is one table with an `embedding vector(N)` column, the chunk
+metadata as JSONB and the filtered fields as generated columns. Searches use an
+HNSW (or IVFFlat) index built for the collection's metric, with metadata filters in
+the same WHERE clause.
+Needs psycopg 3 with its pool (pip install "psycopg[binary,pool]") and a server
+with the vector extension.
+"""
+
+import json
+from typing import Dict, List, Optional, Tuple
+
+from .base_store import StoreError, VectorStore, select_fields
+
+
+# Metadata fields kept as indexed columns next to the JSONB metadata
+COLUMNS = ('filepath', 'language', 'type', 'name', 'symbol_id', 'kind')
+
+# Approximate nearest-neighbour indexes pgvector can build ('none' scans exactly)
+INDEX_METHODS = ('hnsw', 'ivfflat', 'none')
+
+# Distance operator and index operator class of each metric; '<#>' is the negated dot product
+OPERATORS = {'cosine': ('<=>', 'vector_cosine_ops'), 'dot': ('<#>', 'vector_ip_ops'), 'l2': ('<->', 'vector_l2_ops')}
+
+# IVFFlat trains its lists on the rows present when it is built: wait for this many per list
+IVFFLAT_ROWS_PER_LIST = 10
+
+# Chroma-style comparison operators and their SQL spelling
+COMPARISONS = {'$eq': '=', '$ne': 'IS DISTINCT FROM', '$gt': '>', '$gte': '>=', '$lt': '<', '$lte': '<='}
+
+
+def _operand(key: str, values: List) -> Tuple[str, List, str]:
+    """
+    SQL expression reading a metadata field, its parameters, and the cast for values
+    compared with it: the column for string values of a column field, else the JSONB value
+    """
+    if key in COLUMNS and all(isinstance(v, str) for v in values):
+        return key, [], ''
+    return "(metadata -> %s::text)", [key], '::jsonb'
+
+
+def _value(value, cast: str):
+    return json.dumps(value) if cast else value
+
+
+def to_pg_filter(where: Dict) -> Tuple[str, List]:
+    """
+    Translate a Chroma-style 'where' filter into an SQL condition and its parameters
+    
+    {'language': 'go'}             -> language = %s
+    {'line_start': {'$gte': 10}}   -> (metadata -> %s::text) >= %s::jsonb
+    {'type': {'$in': [...]}}       -> type = ANY(%s)
+    {'$and': [...]}, {'$or': [...]} -> (... AND ...), (... OR ...)
+    """
+    clauses, params = [], []
+    for key, value in where.items():
+        if key in ('$and', '$or'):
+            parts = [to_pg_filter(clause) for clause in value]
+            joiner = ' AND ' if key == '$and' else ' OR '
+            clauses.append('(' + joiner.join(sql for sql, _ in parts) + ')' if parts else 'TRUE')
+            for _, part_params in parts:
+                params.extend(part_params)
+        elif isinstance(value, dict):
+            for operator, argument in value.items():
+                if operator in ('$in', '$nin'):
+                    values = list(argument)
+                    if not values:
+                        clauses.append('FALSE' if operator == '$in' else 'TRUE')
+                        continue
+                    field, field_params, cast = _operand(key, values)
+                    array = [_value(v, cast) for v in values]
+                    if operator == '$in':
+                        clauses.append(f"{field} = ANY(%s{cast}[])" if cast else f"{field} = ANY(%s)")
+                        params.extend(field_params + [array])
+                    else:
+                        any_clause = f"{field} = ANY(%s{cast}[])" if cast else f"{field} = ANY(%s)"
+                        clauses.append(f"({field} IS NULL OR NOT {any_clause})")
+                        params.extend(field_params + field_params + [array])
+                elif operator in COMPARISONS:
+                    field, field_params, cast = _operand(key, [argument])
+                    clauses.append(f"{field} {COMPARISONS[operator]} %s{cast}")
+                    params.extend(field_params + [_value(argument, cast)])
+                else:
+                    raise StoreError(f"Unsupported filter operator '{operator}' on '{key}'")
+        else:
+            field, field_params, cast = _operand(key, [value])
+            clauses.append(f"{field} = %s{cast}")
+            params.extend(field_params + [_value(value, cast)])
+    return ' AND '.join(clauses) or 'TRUE', params
+
+
+def _vector_text(vector: List[float]) -> str:
+    """A vector in pgvector's text format: [1,2,3]"""
+    return '[' + ','.join(repr(float(x)) for x in vector) + ']'
+
+
+class PgvectorStore(VectorStore):
+    """Stores chunks in a PostgreSQL table searched with pgvector"""
+    
+    backend = 'pgvector'
+    
+    def __init__(self, dsn: str = 'postgresql://localhost:5432/rag', name: str = 'chrome_code',
+                 table_prefix: str = 'rag_', dimensions: Optional[int] = None, index: str = 'hnsw',
+                 metric: str = 'cosine', lists: int = 100, probes: int = 10, ef_search: int = 100, pool_size: int = 4,
+                 pool=None):
+        """
+        Args:
+            dsn: PostgreSQL connection string
+            name: Collection name (the table is table_prefix + name)
+            table_prefix: Prefix of the tables this store creates
+            dimensions: Vector size; None creates the table on the first insert,
+                sized to the vectors stored
+            index: ANN index (one of INDEX_METHODS); HNSW is built with the table,
+                IVFFlat once the table holds lists * IVFFLAT_ROWS_PER_LIST rows
+            metric: Distance metric (one of METRICS); the ANN index is built for it
+            lists: IVFFlat list count (about rows / 1000 suits most indexes)
+            probes: IVFFlat lists searched per query (recall vs. speed)
+            ef_search: HNSW candidate list size per query (recall vs. speed)
+            pool_size: Most connections kept open
+            pool: Connection pool to share (collections of one database)
+        """
+        try:
+            from psycopg import sql
+        except ImportError as e:
+            raise StoreError("The pgvector store needs psycopg 3: pip install \"psycopg[binary,pool]\"") from e
+        if index not in INDEX_METHODS:
+            raise StoreError(f"Unknown pgvector index '{index}' (expected one of: {', '.join(INDEX_METHODS)})")
+        if name == 'collections':
+            raise StoreError("'collections' is reserved for the collection metadata table")
+        
+        super().__init__(name, metric)
+        self._sql = sql
+        self.dsn = dsn
+        self.table_prefix = table_prefix
+        self.dimensions = dimensions
+        self.index = index
+        self.lists = max(1, lists)
+        self.probes = max(1, probes)
+        self.ef_search = max(1, ef_search)
+        self.pool_size = max(1, pool_size)
+        self._pool = pool
+        self.table = sql.Identifier(table_prefix + name)
+        self.meta_table = sql.Identifier(table_prefix + 'collections')
+        self._size: Optional[int] = None  # vector size of the existing table
+        self._metadata: Optional[Dict] = None
+        self._meta_ready = False
+        self._ivfflat_built = False
+        
+        if dimensions and self._table_size() is None:
+            self._create_table(dimensions)
+    
+    # Connections
+    
+    @property
+    def pool(self):
+        """Connection pool, opened on first use"""
+        if self._pool is None:
+            from psycopg_pool import ConnectionPool
+            self._pool = ConnectionPool(self.dsn, min_size=1, max_size=self.pool_size, open=True)
+        return self._pool
+    
+    def _execute(self, statement, params: Optional[List] = None, fetch: bool = False):
+        """Run one statement in its own transaction"""
+        import psycopg
+        try:
+            with self.pool.connection() as conn:
+                cursor = conn.execute(statement, params)
+                return cursor.fetchall() if fetch else None
+        except psycopg.Error as e:
+            raise StoreError(f"PostgreSQL error on '{self.name}': {e}") from e
+    
+    # Tables
+    
+    def _table_size(self) -> Optional[int]:
+        """Vector size of the collection table, or None if it does not exist yet"""
+        if self._size is None:
+            rows = self._execute(
+                "SELECT a.atttypmod FROM pg_attribute a "
+                "WHERE a.attrelid = to_regclass(%s) AND a.attname = 'embedding' AND NOT a.attisdropped",
+                [self._regclass()], fetch=True
+            )
+            if not rows:
+                return None
+            self._size = int(rows[0][0])
+            if self.dimensions and self._size != self.dimensions:
+                raise StoreError(
+                    f"Table for '{self.name}' holds {self._size}-dimensional vectors, "
+                    f"but {self.dimensions} are configured"
+                )
+            self._check_index_metric()
+        return self._size
+    
+    def _check_index_metric(self):
+        """Raise if the table's ANN index was built for another metric (it would not be used)"""
+        rows = self._execute("SELECT indexdef FROM pg_indexes WHERE indexname = %s",
+                             [f"{self.table_prefix}{self.name}_embedding"], fetch=True)
+        if not rows:
+            return
+        operator_class = OPERATORS[self.metric][1]
+        if operator_class not in rows[0][0]:
+            built = next((m for m, (_, ops) in OPERATORS.items() if ops in rows[0][0]), 'another')
+            raise StoreError(f"The vector index of '{self.name}' was built for the '{built}' metric, not '{self.metric}'")
+    
+    def _regclass(self) -> str:
+        """The table name as to_regclass() expects it (quoted)"""
+        return '"' + (self.table_prefix + self.name).replace('"', '""') + '"'
+    
+    def _create_table(self, size: int):
+        """Create the table with its metadata columns, filter indexes and HNSW index"""
+        sql = self._sql
+        columns = sql.SQL(', ').join(
+            sql.SQL("{} TEXT GENERATED ALWAYS AS (metadata ->> {}) STORED").format(
+                sql.Identifier(column), sql.Literal(column))
+            for column in COLUMNS
+        )
+        statements = [
+            sql.SQL("CREATE EXTENSION IF NOT EXISTS vector"),
+            sql.SQL(
+                "CREATE TABLE IF NOT EXISTS {} ("
+                "seq BIGSERIAL, id TEXT PRIMARY KEY, document TEXT, metadata JSONB NOT NULL, "
+                "embedding vector({}) NOT NULL, {})"
+            ).format(self.table, sql.Literal(size), columns),
+        ]
+        # Per-file lookups (updates, deletes, renames), split-symbol reassembly and filters
+        for column in COLUMNS:
+            statements.append(sql.SQL("CREATE INDEX IF NOT EXISTS {} ON {} ({})").format(
+                sql.Identifier(f"{self.table_prefix}{self.name}_{column}"), self.table, sql.Identifier(column)))
+        if self.index == 'hnsw':
+            statements.append(sql.SQL("CREATE INDEX IF NOT EXISTS {} ON {} USING hnsw (embedding {})").format(
+                sql.Identifier(f"{self.table_prefix}{self.name}_embedding"), self.table,
+                sql.SQL(OPERATORS[self.metric][1])))
+        
+        import psycopg
+        try:
+            with self.pool.connection() as conn:
+                for statement in statements:
+                    conn.execute(statement)
+        except psycopg.Error as e:
+            raise StoreError(f"Cannot create the table for '{self.name}' (is the vector extension installed?): {e}") from e
+        self._size = size
+    
+    def _ensure_table(self, size: int):
+        existing = self._table_size()
+        if existing is None:
+            if self.dimensions and size != self.dimensions:
+                raise StoreError(f"Got {size}-dimensional vectors, but {self.dimensions} are configured")
+            self._create_table(size)
+        elif existing != size:
+            raise StoreError(f"Table for '{self.name}' holds {existing}-dimensional vectors, got {size}")
+    
+    def _ensure_meta_table(self):
+        if not self._meta_ready:
+            self._execute(self._sql.SQL(
+                "CREATE TABLE IF NOT EXISTS {} (name TEXT PRIMARY KEY, metadata JSONB NOT NULL)"
+            ).format(self.meta_table))
+            self._meta_ready = True
+    
+    @property
+    def metadata(self) -> Dict:
+        """Kept as one JSONB row per collection in the shared '<prefix>collections' table"""
+        if self._metadata is None:
+            self._ensure_meta_table()
+            rows = self._execute(self._sql.SQL("SELECT metadata FROM {} WHERE name = %s").format(self.meta_table),
+                                 [self.name], fetch=True)
+            self._metadata = dict(rows[0][0]) if rows else {}
+        return self._metadata
+    
+    def modify(self, metadata: Dict):
+        self._ensure_meta_table()
+        self._execute(self._sql.SQL(
+            "INSERT INTO {} (name, metadata) VALUES (%s, %s::jsonb) "
+            "ON CONFLICT (name) DO UPDATE SET metadata = EXCLUDED.metadata"
+        ).format(self.meta_table), [self.name, json.dumps(metadata)])
+        self._metadata = dict(metadata)
+    
+    def reset(self):
+        self._ensure_meta_table()
+        self._execute(self._sql.SQL("DROP TABLE IF EXISTS {}").format(self.table))
+        self._execute(self._sql.SQL("DELETE FROM {} WHERE name = %s").format(self.meta_table), [self.name])
+        self._size = None
+        self._metadata = None
+        self._ivfflat_built = False
+        if self.dimensions:
+            self._create_table(self.dimensions)
+    
+    def open_collection(self, name: str) -> 'PgvectorStore':
+        return PgvectorStore(
+            dsn=self.dsn, name=name, table_prefix=self.table_prefix, dimensions=self.dimensions,
+            index=self.index, metric=self.metric, lists=self.lists, probes=self.probes, ef_search=self.ef_search,
+            pool_size=self.pool_size, pool=self._pool
+        )
+    
+    # Chunks
+    
+    def count(self) -> int:
+        if self._table_size() is None:
+            return 0
+        return int(self._execute(self._sql.SQL("SELECT COUNT(*) FROM {}").format(self.table), fetch=True)[0][0])
+    
+    def replace(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+                embeddings: List[List[float]]):
+        self.add(ids=ids, documents=documents, metadatas=metadatas, embeddings=embeddings)  # add upserts
+    
+    def add(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+            embeddings: List[List[float]]):
+        """Upsert chunks: COPY into a staging table, then one INSERT ... ON CONFLICT"""
+        if not ids:
+            return
+        self._ensure_table(len(embeddings[0]))
+        
+        import psycopg
+        sql = self._sql
+        try:
+            with self.pool.connection() as conn:
+                conn.execute("CREATE TEMP TABLE rag_staging (id TEXT, document TEXT, metadata JSONB, "
+                             "embedding vector) ON COMMIT DROP")
+                with conn.cursor() as cursor:
+                    with cursor.copy("COPY rag_staging (id, document, metadata, embedding) FROM STDIN") as copy:
+                        for chunk_id, document, metadata, vector in zip(ids, documents, metadatas, embeddings):
+                            copy.write_row((chunk_id, document, json.dumps(metadata or {}), _vector_text(vector)))
+                conn.execute(sql.SQL(
+                    "INSERT INTO {} (id, document, metadata, embedding) "
+                    "SELECT id, document, metadata, embedding FROM rag_staging "
+                    "ON CONFLICT (id) DO UPDATE SET document = EXCLUDED.document, "
+                    "metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding"
+                ).format(self.table))
+        except psycopg.Error as e:
+            raise StoreError(f"Cannot store {len(ids)} chunks in '{self.name}': {e}") from e
+        if self.index == 'ivfflat' and not self._ivfflat_built:
+            self._build_ivfflat()
+    
+    def _build_ivfflat(self):
+        """Build the IVFFlat index once there are enough rows to train its lists (exact scans until then)"""
+        name = f"{self.table_prefix}{self.name}_embedding"
+        if self._execute("SELECT to_regclass(%s)", ['"' + name.replace('"', '""') + '"'], fetch=True)[0][0]:
+            self._ivfflat_built = True
+        elif self.count() >= self.lists * IVFFLAT_ROWS_PER_LIST:
+            self._execute(self._sql.SQL(
+                "CREATE INDEX IF NOT EXISTS {} ON {} USING ivfflat (embedding {}) WITH (lists = {})"
+            ).format(self._sql.Identifier(name), self.table, self._sql.SQL(OPERATORS[self.metric][1]),
+                     self._sql.Literal(self.lists)))
+            self._ivfflat_built = True
+    
+    def get(self, ids: Optional[List[str]] = None, where: Optional[Dict] = None,
+            limit: Optional[int] = None, offset: Optional[int] = None,
+            include: Optional[List[str]] = None) -> Dict:
+        include = ['documents', 'metadatas'] if include is None else include
+        result = {'ids': [], 'documents': [], 'metadatas': [], 'embeddings': []}
+        if self._table_size() is None or ids == [] or limit == 0:
+            return select_fields(result, include)
+        
+        condition, params = to_pg_filter(where or {})
+        if ids is not None:
+            condition += " AND id = ANY(%s)"
+            params.append(list(ids))
+        rows = self._execute(self._sql.SQL(
+            "SELECT id, document, metadata, {} FROM {} WHERE " + condition + " ORDER BY seq LIMIT %s OFFSET %s"
+        ).format(self._embedding_column(include), self.table), params + [limit, offset or 0], fetch=True)
+        
+        for chunk_id, document, metadata, embedding in rows:
+            self._append(result, chunk_id, document, metadata, embedding)
+        return select_fields(result, include)
+    
+    def query(self, query_embeddings: List[List[float]], n_results: int = 10,
+              where: Optional[Dict] = None, include: Optional[List[str]] = None) -> Dict:
+        include = ['documents', 'metadatas', 'distances'] if include is None else include
+        results = {'ids': [], 'documents': [], 'metadatas': [], 'distances': [], 'embeddings': []}
+        if self._table_size() is None:
+            for key in results:
+                results[key] = [[] for _ in query_embeddings]
+            return select_fields(results, include)
+        
+        condition, params = to_pg_filter(where or {})
+        operator = OPERATORS[self.metric][0]
+        # The ORDER BY must use the index's operator; 1 + <#> turns the negated dot product into 1 - dot
+        distance = f"1 + (embedding {operator} %s::vector)" if self.metric == 'dot' else f"embedding {operator} %s::vector"
+        statement = self._sql.SQL(
+            "SELECT id, document, metadata, {}, " + distance + " AS distance FROM {} "
+            "WHERE " + condition + " ORDER BY embedding " + operator + " %s::vector LIMIT %s"
+        ).format(self._embedding_column(include), self.table)
+        
+        import psycopg
+        try:
+            with self.pool.connection() as conn:
+                # Search-time recall settings, for this transaction only
+                conn.execute("SELECT set_config('ivfflat.probes', %s, true), set_config('hnsw.ef_search', %s, true)",
+                             [str(self.probes), str(self.ef_search)])
+                for vector in query_embeddings:
+                    text = _vector_text(vector)
+                    rows = conn.execute(statement, [text] + params + [text, n_results]).fetchall()
+                    row = {'ids': [], 'documents': [], 'metadatas': [], 'embeddings': []}
+                    for chunk_id, document, metadata, embedding, _ in rows:
+                        self._append(row, chunk_id, document, metadata, embedding)
+                    for key in row:
+                        results[key].append(row[key])
+                    results['distances'].append([float(distance) for *_, distance in rows])
+        except psycopg.Error as e:
+            raise StoreError(f"Search in '{self.name}' failed: {e}") from e
+        
+        return select_fields(results, include)
+    
+    def update(self, ids: List[str], metadatas: List[Dict]):
+        if not ids or self._table_size() is None:
+            return
+        import psycopg
+        try:
+            with self.pool.connection() as conn:
+                with conn.cursor() as cursor:
+                    cursor.executemany(
+                        self._sql.SQL("UPDATE {} SET metadata = metadata || %s::jsonb WHERE id = %s").format(self.table),
+                        [(json.dumps(metadata), chunk_id) for chunk_id, metadata in zip(ids, metadatas)]
+                    )
+        except psycopg.Error as e:
+            raise StoreError(f"Cannot update {len(ids)} chunks in '{self.name}': {e}") from e
+    
+    def delete(self, ids: List[str]):
+        if not ids or self._table_size() is None:
+            return
+        self._execute(self._sql.SQL("DELETE FROM {} WHERE id = ANY(%s)").format(self.table), [list(ids)])
+    
+    def _embedding_column(self, include: List[str]):
+        """The vector as text when asked for (parsed as JSON), else NULL"""
+        return self._sql.SQL("embedding::text" if 'embeddings' in include else "NULL")
+    
+    def _append(self, result: Dict, chunk_id: str, document: Optional[str], metadata: Dict,
+                embedding: Optional[str]):
+        """Add one row to a Chroma-shaped result"""
+        result['ids'].append(chunk_id)
+        result['documents'].append(document)
+        result['metadatas'].append(dict(metadata or {}))
+        result['embeddings'].append([float(x) for x in json.loads(embedding)] if embedding is not None else None)
+    
+    def __repr__(self) -> str:
+        return f"PgvectorStore(collection={self.name!r}, table={self.table_prefix + self.name!r})"
@@ -0,0 +1,417 @@
+#!/usr/bin/env python3
+"""
+Qdrant vector store: chunks kept in a collection on a Qdrant server
+Talks to the REST API, so no client library is needed; metadata filters are
+translated to Qdrant payload filters and evaluated server-side. With int8
+quantization the collection is created with Qdrant's scalar quantization: the
+quantized vectors are kept in RAM and searched, the originals stay on disk for
+rescoring the top hits
+"""
+
+import json
+import time
+import urllib.error
+import urllib.parse
+import urllib.request
+import uuid
+from typing import Dict, List, Optional
+
+from .base_store import StoreError, VectorStore, check_quantization, select_fields
+
+
+# HTTP statuses worth retrying: the server is up but busy or restarting
+RETRYABLE_STATUSES = {500, 502, 503, 504}
+
+# Distance metrics Qdrant accepts for a collection, and the metric each one searches by
+DISTANCES = {'Cosine': 'cosine', 'Dot': 'dot', 'Euclid': 'l2', 'Manhattan': 'manhattan'}
+
+# Payload fields the RAG system filters on, indexed when the collection is created
+INDEXED_FIELDS = ('filepath', 'language', 'type', 'name', 'symbol_id')
+
+# Chroma-style comparison operators and their Qdrant range keys
+RANGE_OPERATORS = {'$gt': 'gt', '$gte': 'gte', '$lt': 'lt', '$lte': 'lte'}
+
+# Collection quantization settings of each vector format ('none' sets none)
+QUANTIZATION_CONFIGS = {'int8': {'scalar': {'type': 'int8', 'quantile': 0.99, 'always_ram': True}}}
+
+# Payload keys holding the chunk id and text next to the chunk metadata
+ID_KEY = '_id'
+DOCUMENT_KEY = '_document'
+
+
+def point_id(chunk_id: str) -> str:
+    """Qdrant point ids are integers or UUIDs: derive a stable UUID from the chunk id"""
+    return str(uuid.uuid5(uuid.NAMESPACE_URL, chunk_id))
+
+
+def to_qdrant_filter(where: Dict) -> Dict:
+    """
+    Translate a Chroma-style 'where' filter into a Qdrant filter
+    
+    {'language': 'go'}                   -> must match value
+    {'type': {'$in': [...]}}             -> must match any
+    {'type': {'$nin': [...]}}, '$ne'     -> must_not
+    {'line_start': {'$gte': 10}}         -> range
+    {'$and': [...]}, {'$or': [...]}      -> nested must / should
+    """
+    must, must_not = [], []
+    for key, value in where.items():
+        if key == '$and':
+            must.extend(to_qdrant_filter(clause) for clause in value)
+        elif key == '$or':
+            must.append({'should': [to_qdrant_filter(clause) for clause in value]})
+        elif isinstance(value, dict):
+            for operator, argument in value.items():
+                if operator == '$eq':
+                    must.append(_match(key, argument))
+                elif operator == '$ne':
+                    must_not.append(_match(key, argument))
+                elif operator == '$in':
+                    must.append({'key': key, 'match': {'any': list(argument)}})
+                elif operator == '$nin':
+                    must_not.append({'key': key, 'match': {'any': list(argument)}})
+                elif operator in RANGE_OPERATORS:
+                    must.append({'key': key, 'range': {RANGE_OPERATORS[operator]: argument}})
+                else:
+                    raise StoreError(f"Unsupported filter operator '{operator}' on '{key}'")
+        else:
+            must.append(_match(key, value))
+    
+    translated = {}
+    if must:
+        translated['must'] = must
+    if must_not:
+        translated['must_not'] = must_not
+    return translated
+
+
+def _match(key: str, value) -> Dict:
+    return {'key': key, 'match': {'value': value}}
+
+
+class QdrantStore(VectorStore):
+    """Stores chunks in a Qdrant collection; vectors are upserted in batches"""
+    
+    backend = 'qdrant'
+    supported_metrics = tuple(DISTANCES.values())
+    supported_quantizations = ('none', 'int8')
+    
+    def __init__(self, url: str = 'http://localhost:6333', name: str = 'chrome_code',
+                 api_key: Optional[str] = None, metric: str = 'cosine', distance: Optional[str] = None,
+                 dimensions: Optional[int] = None, batch_size: int = 256,
+                 timeout: float = 30.0, max_retries: int = 3, backoff: float = 0.5,
+                 quantization: Optional[str] = None):
+        """
+        Args:
+            url: Qdrant server URL
+            name: Collection name
+            api_key: Optional API key (sent as the 'api-key' header)
+            metric: Distance metric (one of supported_metrics)
+            distance: Qdrant distance name (one of DISTANCES), overriding metric
+            dimensions: Vector size; None creates the collection on the first insert,
+                sized to the vectors stored
+            batch_size: Points per upsert/delete request
+            timeout: Per-request timeout in seconds
+            max_retries: Retries (on a fresh connection) for connection errors and 5xx responses
+            backoff: Initial retry delay in seconds (doubles on each retry)
+            quantization: 'none' or 'int8' (scalar quantization) for a new collection; None
+                keeps an existing collection's ('none' for a new one)
+        """
+        if distance is not None and distance not in DISTANCES:
+            raise StoreError(f"Unknown Qdrant distance '{distance}' (expected one of: {', '.join(DISTANCES)})")
+        check_quantization(QdrantStore, quantization)
+        self._requested_quantization = quantization
+        self._size: Optional[int] = None  # vector size of the existing collection
+        super().__init__(name, DISTANCES[distance] if distance else metric, quantization or 'none')
+        self.url = url.rstrip('/')
+        self.api_key = api_key
+        self.distance = next(qdrant for qdrant, m in DISTANCES.items() if m == self.metric)
+        self.dimensions = dimensions
+        self.batch_size = max(1, batch_size)
+        self.timeout = timeout
+        self.max_retries = max_retries
+        self.backoff = backoff
+        self.meta_name = f"{name}_meta"
+        self._metadata: Optional[Dict] = None
+        
+        if dimensions and self._collection_size() is None:
+            self._create_collection(dimensions)
+    
+    @property
+    def quantization(self) -> str:
+        """Vector format: an existing collection's own, unless one was configured"""
+        if self._requested_quantization is None and self._size is None:
+            self._collection_size()
+        return self._quantization
+    
+    @quantization.setter
+    def quantization(self, value: str):
+        self._quantization = value
+    
+    # Collection management
+    
+    def _collection_size(self) -> Optional[int]:
+        """Vector size of the collection, or None if it does not exist yet"""
+        if self._size is None:
+            try:
+                info = self._request('GET', f'/collections/{self.name}')
+            except _NotFound:
+                return None
+            vectors = info['config']['params']['vectors']
+            if vectors.get('distance', self.distance) != self.distance:
+                raise StoreError(
+                    f"Qdrant collection '{self.name}' searches by {vectors['distance']}, "
+                    f"but {self.distance} is configured"
+                )
+            self._size = int(vectors['size'])
+            existing = 'int8' if (info['config'].get('quantization_config') or {}).get('scalar') else 'none'
+            if self._requested_quantization not in (None, existing):
+                raise StoreError(
+                    f"Qdrant collection '{self.name}' stores {existing} vectors, "
+                    f"but {self._requested_quantization} is configured"
+                )
+            self.quantization = existing
+            if self.dimensions and self._size != self.dimensions:
+                raise StoreError(
+                    f"Qdrant collection '{self.name}' holds {self._size}-dimensional vectors, "
+                    f"but {self.dimensions} are configured"
+                )
+        return self._size
+    
+    def _create_collection(self, size: int):
+        """Create the collection with the configured metric and index the filtered fields"""
+        config = {'vectors': {'size': size, 'distance': self.distance}}
+        if self._quantization in QUANTIZATION_CONFIGS:
+            config['quantization_config'] = QUANTIZATION_CONFIGS[self._quantization]
+        self._request('PUT', f'/collections/{self.name}', config)
+        for field in INDEXED_FIELDS:
+            self._request('PUT', f'/collections/{self.name}/index?wait=true', {
+                'field_name': field, 'field_schema': 'keyword'
+            })
+        self._size = size
+    
+    def _ensure_collection(self, size: int):
+        existing = self._collection_size()
+        if existing is None:
+            if self.dimensions and size != self.dimensions:
+                raise StoreError(f"Got {size}-dimensional vectors, but {self.dimensions} are configured")
+            self._create_collection(size)
+        elif existing != size:
+            raise StoreError(
+                f"Qdrant collection '{self.name}' holds {existing}-dimensional vectors, got {size}"
+            )
+    
+    @property
+    def metadata(self) -> Dict:
+        """Kept as the payload of a single point in a companion '<name>_meta' collection"""
+        if self._metadata is None:
+            try:
+                point = self._request('GET', f'/collections/{self.meta_name}/points/0')
+                self._metadata = dict(point.get('payload') or {})
+            except _NotFound:
+                self._metadata = {}
+        return self._metadata
+    
+    def modify(self, metadata: Dict):
+        try:
+            self._request('GET', f'/collections/{self.meta_name}')
+        except _NotFound:
+            self._request('PUT', f'/collections/{self.meta_name}', {
+                'vectors': {'size': 1, 'distance': 'Dot'}
+            })
+        self._request('PUT', f'/collections/{self.meta_name}/points?wait=true', {
+            'points': [{'id': 0, 'vector': [1.0], 'payload': metadata}]
+        })
+        self._metadata = dict(metadata)
+    
+    def reset(self):
+        for name in (self.name, self.meta_name):
+            try:
+                self._request('DELETE', f'/collections/{name}')
+            except _NotFound:
+                pass
+        self._size = None
+        self._metadata = None
+        if self.dimensions:
+            self._create_collection(self.dimensions)
+    
+    def open_collection(self, name: str) -> 'QdrantStore':
+        return QdrantStore(
+            url=self.url, name=name, api_key=self.api_key, metric=self.metric,
+            dimensions=self.dimensions, batch_size=self.batch_size, timeout=self.timeout,
+            max_retries=self.max_retries, backoff=self.backoff, quantization=self.quantization
+        )
+    
+    # Points
+    
+    def count(self) -> int:
+        if self._collection_size() is None:
+            return 0
+        return int(self._request('POST', f'/collections/{self.name}/points/count', {'exact': True})['count'])
+    
+    def replace(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+                embeddings: List[List[float]]):
+        self.add(ids=ids, documents=documents, metadatas=metadatas, embeddings=embeddings)  # add upserts
+    
+    def add(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+            embeddings: List[List[float]]):
+        if not ids:
+            return
+        self._ensure_collection(len(embeddings[0]))
+        
+        points = [
+            {
+                'id': point_id(chunk_id),
+                'vector': [float(x) for x in vector],
+                'payload': dict(metadata or {}, **{ID_KEY: chunk_id, DOCUMENT_KEY: document})
+            }
+            for chunk_id, document, metadata, vector in zip(ids, documents, metadatas, embeddings)
+        ]
+        for start in range(0, len(points), self.batch_size):
+            self._request('PUT', f'/collections/{self.name}/points?wait=true',
+                          {'points': points[start:start + self.batch_size]})
+    
+    def get(self, ids: Optional[List[str]] = None, where: Optional[Dict] = None,
+            limit: Optional[int] = None, offset: Optional[int] = None,
+            include: Optional[List[str]] = None) -> Dict:
+        include = ['documents', 'metadatas'] if include is None else include
+        result = {'ids': [], 'documents': [], 'metadatas': [], 'embeddings': []}
+        if self._collection_size() is None or ids == [] or limit == 0:
+            return select_fields(result, include)
+        
+        query_filter = to_qdrant_filter(where or {})
+        if ids is not None:
+            query_filter.setdefault('must', []).append({'has_id': [point_id(i) for i in ids]})
+        
+        # Scroll pages in point-id order; offset/limit count matching chunks, like Chroma
+        skip = offset or 0
+        page_offset = None
+        while True:
+            body = {
+                'limit': self.batch_size,
+                'with_payload': True if ('documents' in include or 'metadatas' in include) else [ID_KEY],
+                'with_vector': 'embeddings' in include
+            }
+            if query_filter:
+                body['filter'] = query_filter
+            if page_offset is not None:
+                body['offset'] = page_offset
+            page = self._request('POST', f'/collections/{self.name}/points/scroll', body)
+            
+            for point in page.get('points', []):
+                if skip:
+                    skip -= 1
+                    continue
+                self._append(result, point)
+                if limit is not None and len(result['ids']) >= limit:
+                    return select_fields(result, include)
+            
+            page_offset = page.get('next_page_offset')
+            if page_offset is None:
+                return select_fields(result, include)
+    
+    def query(self, query_embeddings: List[List[float]], n_results: int = 10,
+              where: Optional[Dict] = None, include: Optional[List[str]] = None) -> Dict:
+        include = ['documents', 'metadatas', 'distances'] if include is None else include
+        results = {'ids': [], 'documents': [], 'metadatas': [], 'distances': [], 'embeddings': []}
+        exists = self._collection_size() is not None
+        
+        for vector in query_embeddings:
+            row = {'ids': [], 'documents': [], 'metadatas': [], 'embeddings': []}
+            distances = []
+            if exists:
+                body = {
+                    'vector': [float(x) for x in vector],
+                    'limit': n_results,
+                    'with_payload': True,
+                    'with_vector': 'embeddings' in include
+                }
+                query_filter = to_qdrant_filter(where or {})
+                if query_filter:
+                    body['filter'] = query_filter
+                for point in self._request('POST', f'/collections/{self.name}/points/search', body):
+                    self._append(row, point)
+                    distances.append(self._distance(point['score']))
+            
+            for key in ('ids', 'documents', 'metadatas', 'embeddings'):
+                results[key].append(row[key])
+            results['distances'].append(distances)
+        
+        return select_fields(results, include)
+    
+    def update(self, ids: List[str], metadatas: List[Dict]):
+        operations = [
+            {'set_payload': {'payload': metadata, 'points': [point_id(chunk_id)]}}
+            for chunk_id, metadata in zip(ids, metadatas)
+        ]
+        for start in range(0, len(operations), self.batch_size):
+            self._request('POST', f'/collections/{self.name}/points/batch?wait=true',
+                          {'operations': operations[start:start + self.batch_size]})
+    
+    def delete(self, ids: List[str]):
+        if not ids or self._collection_size() is None:
+            return
+        points = [point_id(chunk_id) for chunk_id in ids]
+        for start in range(0, len(points), self.batch_size):
+            self._request('POST', f'/collections/{self.name}/points/delete?wait=true',
+                          {'points': points[start:start + self.batch_size]})
+    
+    def _append(self, result: Dict, point: Dict):
+        """Add one Qdrant point to a Chroma-shaped result"""
+        payload = dict(point.get('payload') or {})
+        result['ids'].append(payload.pop(ID_KEY, str(point['id'])))
+        result['documents'].append(payload.pop(DOCUMENT_KEY, None))
+        result['metadatas'].append(payload)
+        result['embeddings'].append(point.get('vector'))
+    
+    def _distance(self, score: float) -> float:
+        """Qdrant scores similarity for Cosine/Dot (higher is closer) and distance otherwise"""
+        if self.distance in ('Cosine', 'Dot'):
+            return 1.0 - score
+        return score
+    
+    # HTTP
+    
+    def _request(self, method: str, path: str, payload: Optional[Dict] = None):
+        """Send a JSON request and return its 'result', retrying transient failures with backoff"""
+        body = json.dumps(payload).encode('utf-8') if payload is not None else None
+        headers = {'Content-Type': 'application/json'}
+        if self.api_key:
+            headers['api-key'] = self.api_key
+        delay = self.backoff
+        
+        for attempt in range(self.max_retries + 1):
+            request = urllib.request.Request(self.url + path, data=body, headers=headers, method=method)
+            try:
+                with urllib.request.urlopen(request, timeout=self.timeout) as response:
+                    return json.loads(response.read().decode('utf-8')).get('result')
+            
+            except urllib.error.HTTPError as e:
+                message = self._error_message(e)
+                if e.code == 404:
+                    raise _NotFound(path) from e
+                if e.code not in RETRYABLE_STATUSES or attempt == self.max_retries:
+                    raise StoreError(f"Qdrant {method} {urllib.parse.urlsplit(path).path} failed ({e.code}): {message}") from e
+            
+            except (urllib.error.URLError, ConnectionError, TimeoutError) as e:
+                if attempt == self.max_retries:
+                    reason = getattr(e, 'reason', e)
+                    raise StoreError(f"Cannot reach Qdrant at {self.url}: {reason}") from e
+            
+            time.sleep(delay)
+            delay *= 2
+        
+        raise StoreError(f"Qdrant request to {path} failed")  # not reached
+    
+    def _error_message(self, error: urllib.error.HTTPError) -> str:
+        """Qdrant reports errors as {"status": {"error": "..."}}"""
+        body = error.read().decode('utf-8', 'ignore')
+        try:
+            status = json.loads(body).get('status')
+            return status.get('error', body) if isinstance(status, dict) else body
+        except (ValueError, AttributeError):
+            return body
+
+
+class _NotFound(Exception):
+    """The collection or point does not exist"""
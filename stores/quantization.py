@@ -0,0 +1,55 @@
+#!/usr/bin/env python3
+"""
+Scalar quantization of stored vectors
+'int8' keeps each component in one signed byte, with a per-vector scale and
+offset: x ~= offset + scale * code, code in [-128, 127]. That is a quarter of
+the float32 size; the rounding error of a component is at most scale / 2,
+1/510 of the vector's value range. Dot products are taken in quantized space,
+q . x ~= offset * sum(q) + scale * (q . code), so nothing is dequantized to scan.
+"""
+
+import struct
+from array import array
+from operator import mul
+from typing import List, Tuple
+
+
+# Vector formats a store can keep ('none' = float32)
+QUANTIZATIONS = ('none', 'int8')
+
+# Scale and offset, little-endian float32, in front of the codes of an int8 blob
+INT8_HEADER = struct.Struct('<ff')
+
+
+def quantize_int8(vector: List[float]) -> Tuple[array, float, float]:
+    """Codes, scale and offset of a vector (a constant vector gets scale 0)"""
+    low, high = min(vector), max(vector)
+    scale = (high - low) / 255.0
+    offset = low + 128.0 * scale
+    if not scale:
+        return array('b', bytes(len(vector))), 0.0, float(low)
+    codes = array('b', (max(-128, min(127, round((x - offset) / scale))) for x in vector))
+    return codes, scale, offset
+
+
+def dequantize_int8(codes: array, scale: float, offset: float) -> List[float]:
+    return [offset + scale * code for code in codes]
+
+
+def pack_int8(vector: List[float]) -> Tuple[bytes, List[float]]:
+    """The stored blob of a vector, and the vector it decodes to"""
+    codes, scale, offset = quantize_int8(vector)
+    # Stored as float32: decode with the values actually kept
+    scale, offset = INT8_HEADER.unpack(INT8_HEADER.pack(scale, offset))
+    return INT8_HEADER.pack(scale, offset) + codes.tobytes(), dequantize_int8(codes, scale, offset)
+
+
+def unpack_int8(blob: bytes) -> Tuple[array, float, float]:
+    scale, offset = INT8_HEADER.unpack_from(blob)
+    return array('b', blob[INT8_HEADER.size:]), scale, offset
+
+
+def quantized_dot(query: List[float], query_sum: float, codes: array, scale: float, offset: float) -> float:
+    """Dot product of a float query with a quantized vector, given sum(query)"""
+    return offset * query_sum + scale * sum(map(mul, query, codes))
+
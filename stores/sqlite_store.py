@@ -0,0 +1,363 @@
+#!/usr/bin/env python3
+"""
+SQLite vector store: vectors and chunk metadata in a single .db file
+Filters run in SQL over the JSON metadata; nearest neighbours are found by a
+brute-force scan (cosine, dot or l2) of the filtered rows, which stays interactive up to
+a few tens of thousands of chunks (roughly 50k chunks of 384 dimensions take
+about a second per query). Larger indexes should use the Qdrant store.
+With int8 quantization, vectors take a quarter of the space and are scanned
+in quantized space; the format is recorded per collection.
+Searches can run from several threads while a re-index writes: writes are
+short transactions other connections wait for (BUSY_TIMEOUT), and each read
+runs in one transaction, so a query ranks and returns the rows of one snapshot
+however the chunks change meanwhile.
+"""
+
+import json
+import heapq
+import os
+import sqlite3
+import sys
+from array import array
+from operator import mul
+from typing import Dict, Iterator, List, Optional, Tuple
+
+from .base_store import StoreError, VectorStore, check_quantization, select_fields
+from .quantization import dequantize_int8, pack_int8, quantized_dot, unpack_int8
+
+
+# SQLite's bound-parameter limit is 999 on older builds
+PARAMETER_BATCH = 500
+
+# Seconds a connection waits for another one's write to finish
+BUSY_TIMEOUT = 30.0
+
+# Chroma-style comparison operators and their SQL spelling
+COMPARISONS = {'$eq': '=', '$ne': 'IS NOT', '$gt': '>', '$gte': '>=', '$lt': '<', '$lte': '<='}
+
+
+def _field(key: str) -> str:
+    """SQL expression reading one metadata field"""
+    return "json_extract(metadata, '$.\"" + key.replace('"', '""').replace("'", "''") + "\"')"
+
+
+def to_sql_filter(where: Dict) -> Tuple[str, List]:
+    """
+    Translate a Chroma-style 'where' filter into an SQL condition and its parameters
+    
+    {'language': 'go'}             -> json_extract(metadata, '$."language"') = ?
+    {'type': {'$in': [...]}}       -> ... IN (?, ...)
+    {'$and': [...]}, {'$or': [...]} -> (... AND ...), (... OR ...)
+    """
+    clauses, params = [], []
+    for key, value in where.items():
+        if key in ('$and', '$or'):
+            parts = [to_sql_filter(clause) for clause in value]
+            joiner = ' AND ' if key == '$and' else ' OR '
+            clauses.append('(' + joiner.join(sql for sql, _ in parts) + ')' if parts else '1')
+            for _, part_params in parts:
+                params.extend(part_params)
+        elif isinstance(value, dict):
+            for operator, argument in value.items():
+                if operator in ('$in', '$nin'):
+                    values = list(argument)
+                    if not values:
+                        clauses.append('0' if operator == '$in' else '1')
+                        continue
+                    placeholders = ','.join('?' * len(values))
+                    if operator == '$in':
+                        clauses.append(f"{_field(key)} IN ({placeholders})")
+                    else:
+                        clauses.append(f"({_field(key)} IS NULL OR {_field(key)} NOT IN ({placeholders}))")
+                    params.extend(values)
+                elif operator in COMPARISONS:
+                    clauses.append(f"{_field(key)} {COMPARISONS[operator]} ?")
+                    params.append(argument)
+                else:
+                    raise StoreError(f"Unsupported filter operator '{operator}' on '{key}'")
+        else:
+            clauses.append(f"{_field(key)} = ?")
+            params.append(value)
+    return ' AND '.join(clauses) or '1', params
+
+
+def _pack(vector: List[float]) -> bytes:
+    """Little-endian float32, the snapshot vector format"""
+    values = array('f', vector)
+    if sys.byteorder == 'big':
+        values.byteswap()
+    return values.tobytes()
+
+
+def _unpack(blob: bytes) -> array:
+    values = array('f', blob)
+    if sys.byteorder == 'big':
+        values.byteswap()
+    return values
+
+
+class SqliteStore(VectorStore):
+    """Stores chunks, their vectors and collection metadata in one SQLite file"""
+    
+    backend = 'sqlite'
+    supported_quantizations = ('none', 'int8')
+    
+    def __init__(self, path: str = "chrome_rag.db", name: str = 'chrome_code', metric: str = 'cosine',
+                 quantization: Optional[str] = None):
+        """
+        Args:
+            path: SQLite file (created if missing; several collections may share it)
+            name: Collection name
+            metric: Distance metric of the scan (one of METRICS)
+            quantization: 'none' (float32) or 'int8' vectors; None keeps the format the
+                collection was created with ('none' for a new one)
+        
+        Raises:
+            StoreError: If the collection holds vectors in another format than quantization
+        """
+        check_quantization(SqliteStore, quantization)
+        super().__init__(name, metric, quantization or 'none')
+        self.path = path
+        
+        with self._connect() as conn:
+            conn.execute("""
+                CREATE TABLE IF NOT EXISTS collections (
+                    name TEXT PRIMARY KEY,
+                    metadata TEXT NOT NULL
+                )
+            """)
+            conn.execute("""
+                CREATE TABLE IF NOT EXISTS chunks (
+                    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+                    collection TEXT NOT NULL,
+                    id TEXT NOT NULL,
+                    document TEXT,
+                    metadata TEXT NOT NULL,
+                    vector BLOB NOT NULL,
+                    norm REAL NOT NULL,
+                    UNIQUE (collection, id)
+                )
+            """)
+            # Per-file lookups (updates, deletes, renames) and split-symbol reassembly
+            conn.execute(f"CREATE INDEX IF NOT EXISTS chunks_filepath ON chunks (collection, {_field('filepath')})")
+            conn.execute(f"CREATE INDEX IF NOT EXISTS chunks_symbol_id ON chunks (collection, {_field('symbol_id')})")
+            # Format of each collection's vector blobs
+            conn.execute("""
+                CREATE TABLE IF NOT EXISTS vector_formats (
+                    collection TEXT PRIMARY KEY,
+                    quantization TEXT NOT NULL
+                )
+            """)
+            row = conn.execute("SELECT quantization FROM vector_formats WHERE collection = ?", (name,)).fetchone()
+        
+        # Collections filled before formats were recorded hold float32 vectors
+        recorded = row[0] if row else ('none' if self.count() else None)
+        if quantization is None:
+            self.quantization = recorded or 'none'
+        elif recorded not in (None, quantization) and self.count():
+            raise StoreError(f"Collection '{name}' stores {recorded} vectors, not {quantization}; "
+                             f"reset it to change the format")
+    
+    @property
+    def metadata(self) -> Dict:
+        with self._connect() as conn:
+            row = conn.execute("SELECT metadata FROM collections WHERE name = ?", (self.name,)).fetchone()
+        return json.loads(row[0]) if row else {}
+    
+    def modify(self, metadata: Dict):
+        with self._connect() as conn:
+            conn.execute(
+                "INSERT OR REPLACE INTO collections (name, metadata) VALUES (?, ?)",
+                (self.name, json.dumps(metadata))
+            )
+    
+    def count(self) -> int:
+        with self._connect() as conn:
+            return conn.execute("SELECT COUNT(*) FROM chunks WHERE collection = ?", (self.name,)).fetchone()[0]
+    
+    def disk_bytes(self) -> Optional[int]:
+        # The database file with its write-ahead log, if one is open
+        sizes = [os.path.getsize(path) for path in (self.path, self.path + '-wal') if os.path.exists(path)]
+        return sum(sizes) if sizes else None
+    
+    def add(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+            embeddings: List[List[float]]):
+        self._insert(ids, documents, metadatas, embeddings)
+    
+    def replace(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+                embeddings: List[List[float]]):
+        self._insert(ids, documents, metadatas, embeddings, replace=True)
+    
+    def _insert(self, ids: List[str], documents: List[str], metadatas: List[Dict],
+                embeddings: List[List[float]], replace: bool = False):
+        """Insert chunk rows in one transaction, first deleting any under the same ids if replace is set"""
+        rows = []
+        for chunk_id, document, metadata, vector in zip(ids, documents, metadatas, embeddings):
+            # int8: the norm of the vector the codes decode to, so distances stay consistent
+            blob, decoded = pack_int8(vector) if self.quantization == 'int8' else (_pack(vector), vector)
+            rows.append((self.name, chunk_id, document, json.dumps(metadata or {}), blob,
+                         sum(x * x for x in decoded) ** 0.5))
+        try:
+            with self._connect() as conn:
+                conn.execute("INSERT OR REPLACE INTO vector_formats (collection, quantization) VALUES (?, ?)",
+                             (self.name, self.quantization))
+                if replace:
+                    self._delete_rows(conn, ids)
+                conn.executemany(
+                    "INSERT INTO chunks (collection, id, document, metadata, vector, norm) VALUES (?, ?, ?, ?, ?, ?)",
+                    rows
+                )
+        except sqlite3.IntegrityError as e:
+            raise StoreError(f"Duplicate chunk id in collection '{self.name}': {e}") from e
+    
+    def get(self, ids: Optional[List[str]] = None, where: Optional[Dict] = None,
+            limit: Optional[int] = None, offset: Optional[int] = None,
+            include: Optional[List[str]] = None) -> Dict:
+        include = ['documents', 'metadatas'] if include is None else include
+        result = {'ids': [], 'documents': [], 'metadatas': [], 'embeddings': []}
+        
+        condition, params = to_sql_filter(where or {})
+        if ids is not None:
+            batches = [ids[start:start + PARAMETER_BATCH] for start in range(0, len(ids), PARAMETER_BATCH)]
+        else:
+            batches = [None]
+        
+        with self._connect() as conn:
+            conn.execute("BEGIN")  # the batches read one snapshot
+            rows = []
+            for batch in batches:
+                sql = f"SELECT seq, id, document, metadata, vector FROM chunks WHERE collection = ? AND {condition}"
+                batch_params = [self.name] + params
+                if batch is not None:
+                    sql += f" AND id IN ({','.join('?' * len(batch))})"
+                    batch_params += batch
+                if len(batches) == 1:
+                    sql += " ORDER BY seq LIMIT ? OFFSET ?"
+                    batch_params += [-1 if limit is None else limit, offset or 0]
+                rows.extend(conn.execute(sql, batch_params))
+        
+        if len(batches) > 1:
+            rows.sort()
+            rows = rows[offset or 0:]
+            rows = rows if limit is None else rows[:limit]
+        
+        for _, chunk_id, document, metadata, blob in rows:
+            result['ids'].append(chunk_id)
+            result['documents'].append(document)
+            result['metadatas'].append(json.loads(metadata))
+            result['embeddings'].append(self._decode(blob) if 'embeddings' in include else None)
+        return select_fields(result, include)
+    
+    def query(self, query_embeddings: List[List[float]], n_results: int = 10,
+              where: Optional[Dict] = None, include: Optional[List[str]] = None) -> Dict:
+        include = ['documents', 'metadatas', 'distances'] if include is None else include
+        condition, params = to_sql_filter(where or {})
+        results = {'ids': [], 'documents': [], 'metadatas': [], 'distances': [], 'embeddings': []}
+        with self._connect() as conn:
+            # The rows scanned and the rows returned come from one snapshot, even while
+            # a re-index deletes and re-adds chunks
+            conn.execute("BEGIN")
+            rows = conn.execute(
+                f"SELECT seq, vector, norm FROM chunks WHERE collection = ? AND {condition}",
+                [self.name] + params
+            ).fetchall()
+            if self.quantization == 'int8':
+                vectors = [(seq, unpack_int8(blob), norm) for seq, blob, norm in rows]
+            else:
+                vectors = [(seq, _unpack(blob), norm) for seq, blob, norm in rows]
+            
+            for query in query_embeddings:
+                scored = heapq.nsmallest(n_results, self._distances(query, vectors))
+                found = self._rows_by_seq(conn, [seq for _, seq in scored])
+                
+                results['distances'].append([distance for distance, _ in scored])
+                for key, index in (('ids', 0), ('documents', 1), ('metadatas', 2)):
+                    results[key].append([found[seq][index] for _, seq in scored])
+                results['embeddings'].append([found[seq][3] for _, seq in scored])
+        
+        return select_fields(results, include)
+    
+    def _distances(self, query: List[float], vectors: List[Tuple[int, array, float]]) -> Iterator[Tuple[float, int]]:
+        """(distance, seq) of every scanned row; the map/sum pairs keep the scan loop in C"""
+        query_norm = sum(x * x for x in query) ** 0.5
+        if self.quantization == 'int8':
+            return self._quantized_distances(query, query_norm, vectors)
+        if self.metric == 'cosine':
+            query_norm = query_norm or 1.0
+            return ((1.0 - sum(map(mul, query, vector)) / (query_norm * (norm or 1.0)), seq)
+                    for seq, vector, norm in vectors)
+        if self.metric == 'dot':
+            return ((1.0 - sum(map(mul, query, vector)), seq) for seq, vector, _ in vectors)
+        # |q - v|^2 = |q|^2 + |v|^2 - 2 q.v, from the stored norms
+        return ((max(query_norm * query_norm + norm * norm - 2.0 * sum(map(mul, query, vector)), 0.0) ** 0.5, seq)
+                for seq, vector, norm in vectors)
+    
+    def _quantized_distances(self, query: List[float], query_norm: float, vectors: List) -> Iterator[Tuple[float, int]]:
+        """_distances over int8 rows, from dot products taken in quantized space"""
+        query_sum = sum(query)
+        dots = ((quantized_dot(query, query_sum, *quantized), seq, norm) for seq, quantized, norm in vectors)
+        if self.metric == 'cosine':
+            query_norm = query_norm or 1.0
+            return ((1.0 - dot / (query_norm * (norm or 1.0)), seq) for dot, seq, norm in dots)
+        if self.metric == 'dot':
+            return ((1.0 - dot, seq) for dot, seq, _ in dots)
+        return ((max(query_norm * query_norm + norm * norm - 2.0 * dot, 0.0) ** 0.5, seq) for dot, seq, norm in dots)
+    
+    def _decode(self, blob: bytes) -> List[float]:
+        """A stored vector blob as floats"""
+        return dequantize_int8(*unpack_int8(blob)) if self.quantization == 'int8' else _unpack(blob).tolist()
+    
+    def _rows_by_seq(self, conn: sqlite3.Connection, seqs: List[int]) -> Dict[int, Tuple]:
+        if not seqs:
+            return {}
+        rows = conn.execute(
+            f"SELECT seq, id, document, metadata, vector FROM chunks WHERE seq IN ({','.join('?' * len(seqs))})",
+            seqs
+        )
+        return {
+            seq: (chunk_id, document, json.loads(metadata), self._decode(blob))
+            for seq, chunk_id, document, metadata, blob in rows
+        }
+    
+    def _connect(self) -> sqlite3.Connection:
+        return sqlite3.connect(self.path, timeout=BUSY_TIMEOUT)
+    
+    def update(self, ids: List[str], metadatas: List[Dict]):
+        with self._connect() as conn:
+            conn.executemany(
+                "UPDATE chunks SET metadata = json_patch(metadata, ?) WHERE collection = ? AND id = ?",
+                [(json.dumps(metadata), self.name, chunk_id) for chunk_id, metadata in zip(ids, metadatas)]
+            )
+    
+    def delete(self, ids: List[str]):
+        with self._connect() as conn:
+            self._delete_rows(conn, ids)
+    
+    def _delete_rows(self, conn: sqlite3.Connection, ids: List[str]):
+        for start in range(0, len(ids), PARAMETER_BATCH):
+            batch = ids[start:start + PARAMETER_BATCH]
+            conn.execute(
+                f"DELETE FROM chunks WHERE collection = ? AND id IN ({','.join('?' * len(batch))})",
+                [self.name] + batch
+            )
+    
+    def reset(self):
+        with self._connect() as conn:
+            conn.execute("DELETE FROM chunks WHERE collection = ?", (self.name,))
+            conn.execute("DELETE FROM collections WHERE name = ?", (self.name,))
+            conn.execute("DELETE FROM vector_formats WHERE collection = ?", (self.name,))
+    
+    def replace_with(self, source: VectorStore):
+        if not isinstance(source, SqliteStore) or source.path != self.path:
+            super().replace_with(source)
+            return
+        # One transaction: a search reads either the old chunks or the new ones
+        with self._connect() as conn:
+            for table, column in (('chunks', 'collection'), ('collections', 'name'),
+                                  ('vector_formats', 'collection')):
+                conn.execute(f"DELETE FROM {table} WHERE {column} = ?", (self.name,))
+                conn.execute(f"UPDATE {table} SET {column} = ? WHERE {column} = ?", (self.name, source.name))
+        self.quantization = source.quantization
+    
+    def open_collection(self, name: str) -> 'SqliteStore':
+        return SqliteStore(self.path, name, self.metric, self.quantization)
@@ -0,0 +1,90 @@
+#!/usr/bin/env python3
+"""
+Shared helpers for the test scripts
+A deterministic embedder, so tests need no model, and a RAG system factory
+over a throwaway store:
+
+    workdir = Path(tempfile.mkdtemp(prefix="rag_example_"))
+    rag = make_rag(workdir, "example")
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db"))).index_directory(str(source))
+"""
+
+import hashlib
+import re
+import sys
+from pathlib import Path
+from typing import List, Optional
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from embedders import Embedder
+from rag import ChromeRAGSystem
+from stores import SqliteStore
+
+
+class HashEmbedder(Embedder):
+    """
+    Bag-of-words vectors from hashed tokens: similar wording, similar vector
+    Records what it was asked: calls counts embed calls, texts keeps every text
+    """
+    
+    def __init__(self, model_name: str = 'test-hash', size: int = 256, normalize: bool = True,
+                 batch_size: int = 32):
+        """
+        Args:
+            model_name: Model name recorded with the index
+            size: Vector length (tokens are hashed into this many buckets)
+            normalize: Scale vectors to unit length
+            batch_size: Maximum texts per backend call
+        """
+        super().__init__(model_name, batch_size=batch_size)
+        self.size = size
+        self.normalize = normalize
+        self.calls = 0
+        self.texts: List[str] = []
+    
+    def embed(self, texts):
+        self.calls += 1
+        self.texts.extend(texts)
+        return [self.vector(text) for text in texts]
+    
+    def vector(self, text: str) -> List[float]:
+        """Vector of one text"""
+        vector = [0.0] * self.size
+        for word in self.words(text):
+            vector[hashlib.md5(word.encode()).digest()[0] % self.size] += 1.0
+        if not self.normalize:
+            return vector
+        norm = sum(x * x for x in vector) ** 0.5 or 1.0
+        return [x / norm for x in vector]
+    
+    def words(self, text: str) -> List[str]:
+        """Tokens that are hashed into the vector"""
+        return re.findall(r'[a-z]+', text.lower())
+    
+    def dimensions(self):
+        return self.size
+
+
+def make_rag(workdir, name: str = 'code', embedder: Optional[Embedder] = None, db: Optional[str] = None,
+             metric: Optional[str] = None, **options) -> ChromeRAGSystem:
+    """
+    RAG system over a SQLite store in workdir, embedding with a HashEmbedder
+    
+    Args:
+        workdir: Directory the store file is created in
+        name: Collection name
+        embedder: Embedding backend (default: a new HashEmbedder)
+        db: Store file name in workdir (default: <name>.db)
+        metric: Distance metric of the store (default: the store's)
+        options: Further ChromeRAGSystem arguments
+    """
+    store_options = {'metric': metric} if metric else {}
+    store = SqliteStore(str(Path(workdir) / (db or f"{name}.db")), name, **store_options)
+    return ChromeRAGSystem(collection_name=name, embedder=embedder or HashEmbedder(), store=store, **options)
+
+
+def make_chroma_rag(db_path, name: str = 'code', embedder: Optional[Embedder] = None,
+                    **options) -> ChromeRAGSystem:
+    """RAG system over a ChromaDB store at db_path, embedding with a HashEmbedder by default"""
+    return ChromeRAGSystem(db_path=str(db_path), collection_name=name, embedder=embedder or HashEmbedder(),
+                           **options)
@@ -0,0 +1,160 @@
+#!/usr/bin/env python3
+"""
+Test script for the Bash chunker
+Scripts are parsed by tree-sitter-bash
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import BashChunker
+
+
+SCRIPT = '''#!/bin/bash
+# shellcheck disable=SC2034
+source ./lib/common.sh
+. "$ROOT/lib/retry.sh"
+
+# Where builds are written
+export BUILD_DIR="/srv/build"
+readonly -a HOSTS=(web1 web2 "db 1")
+RETRIES=3 TIMEOUT=$(printf ')')
+DEBUG=1 make all
+declare -f helper
+
+# Renders the config template
+# for one host
+render() {
+    local host="$1"
+    echo "} is not the end $(printf ')')"
+    cat <<-EOF
+	}
+	)
+	EOF
+    case "$host" in
+        web*) echo web ;;
+        db|cache) echo "data}" ;;
+    esac
+}
+
+function deploy {
+    source ./lib/ssh.sh
+    connect() ( ssh "$1" )
+    for host in "${HOSTS[@]}"; do
+        connect "$host"
+    done
+}
+
+function cleanup() {
+  if [[ -d $BUILD_DIR ]]; then rm -rf "$BUILD_DIR/tmp"; fi
+}
+'''
+
+
+def by_name(chunks, name):
+    return next(c for c in chunks if c.name == name)
+
+
+def test_functions_and_docs():
+    chunks = BashChunker().extract_chunks(SCRIPT, 'scripts/deploy.sh')
+    functions = [(c.qualified_name, c.line_start, c.line_end) for c in chunks if c.type == 'function']
+    assert functions == [('render', 15, 26), ('deploy', 28, 34), ('deploy.connect', 30, 30), ('cleanup', 36, 38)], functions
+    render = by_name(chunks, 'render')
+    assert render.doc == 'Renders the config template\nfor one host', render.doc
+    assert render.signature == 'render()' and render.content.endswith('esac\n}')
+    assert by_name(chunks, 'deploy').signature == 'function deploy'
+    assert by_name(chunks, 'cleanup').signature == 'function cleanup()'
+    assert by_name(chunks, 'connect').metadata['subshell'] is True
+    print("✅ Functions found with exact line ranges and docs")
+
+
+def test_quotes_and_heredocs():
+    code = '''tricky() {
+    echo '}' "}" $'\\'}' ${VAR:-"}"} `echo }` $(echo "$(echo })")
+    cat <<'END'
+}
+function fake() { :; }
+END
+    echo ok # } in a comment
+}
+
+after() { :; }
+'''
+    chunker = BashChunker()
+    chunks = chunker.extract_chunks(code, 'tricky.sh')
+    assert [(c.name, c.line_start, c.line_end) for c in chunks] == [('tricky', 1, 8), ('after', 10, 10)], \
+        [(c.name, c.line_start, c.line_end) for c in chunks]
+    assert chunker.diagnostics == [], chunker.diagnostics
+    print("✅ Quoting and heredocs do not confuse function boundaries")
+
+
+def test_variables():
+    chunks = BashChunker().extract_chunks(SCRIPT, 'scripts/deploy.sh')
+    variables = {c.name: c for c in chunks if c.type == 'variable'}
+    assert sorted(variables) == ['BUILD_DIR', 'HOSTS', 'RETRIES', 'TIMEOUT'], sorted(variables)
+    build = variables['BUILD_DIR']
+    assert build.metadata['exported'] and build.metadata['value'] == '"/srv/build"', build.metadata
+    assert build.doc == 'Where builds are written' and build.line_start == 7
+    hosts = variables['HOSTS']
+    assert hosts.metadata['readonly'] and hosts.metadata['array'] == 'indexed', hosts.metadata
+    assert variables['TIMEOUT'].metadata['value'] == "$(printf ')')"
+    assert 'host' not in variables, "locals are not the script's variables"
+    print("✅ Top-level variables extracted, command prefixes skipped")
+
+
+def test_sources():
+    chunks = BashChunker().extract_chunks(SCRIPT, 'scripts/deploy.sh')
+    assert by_name(chunks, 'render').metadata['sources'] == ['./lib/common.sh', '$ROOT/lib/retry.sh']
+    assert by_name(chunks, 'deploy').metadata['sources'] == ['./lib/common.sh', '$ROOT/lib/retry.sh', './lib/ssh.sh']
+    print("✅ Sourced files recorded as dependencies")
+
+
+def test_unterminated():
+    chunker = BashChunker()
+    chunks = chunker.extract_chunks('ok() { :; }\n\nbroken() {\n    echo "never closed\n}\n', 'broken.sh')
+    ok = by_name(chunks, 'ok')
+    assert (ok.line_start, ok.line_end) == (1, 1) and 'parse_error' not in ok.metadata
+    assert chunker.diagnostics, "the unterminated string is reported"
+    assert all(d['kind'] == 'parse_error' and d['line'] >= 3 for d in chunker.diagnostics), chunker.diagnostics
+    print("✅ Unterminated strings are reported, earlier functions kept")
+
+
+def test_sample_file():
+    """The comprehensive sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'all_languages' / 'deploy.sh'
+    chunks = BashChunker().extract_chunks(sample.read_text(), 'all_languages/deploy.sh')
+    functions = [c.name for c in chunks if c.type == 'function']
+    assert functions == ['log_message', 'check_dependencies', 'parse_config', 'deploy_application', 'cleanup', 'main'], functions
+    assert by_name(chunks, 'main').doc == 'Main execution'
+    assert by_name(chunks, 'CONFIG').metadata['array'] == 'associative'
+    print("✅ Sample file parsed")
+
+
+def main():
+    print("=" * 70)
+    print("BASH CHUNKER TEST")
+    print("=" * 70)
+
+    tests = [
+        test_functions_and_docs, test_quotes_and_heredocs, test_variables,
+        test_sources, test_unterminated, test_sample_file
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
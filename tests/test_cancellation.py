@@ -0,0 +1,176 @@
+#!/usr/bin/env python3
+"""
+Test script for cancelling indexing runs and reporting their progress
+A cancelled run stops at the next file or insert batch and leaves only whole
+files in the index, each recorded in the incremental state; the next run
+indexes the rest. Progress is reported per file parsed and batch stored.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from collections import Counter
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.cancellation import CancelToken
+from utils.state_manager import StateManager
+
+
+def make_tree(root: Path, files: int = 12):
+    """Go packages (linked, so stored last) and Markdown notes (stored as they are parsed)"""
+    for i in range(files):
+        package = f"pkg{i % 3}"
+        path = root / package / f"file{i:02d}.go"
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(
+            f"package {package}\n\n"
+            f"type T{i} struct{{ N int }}\n\n"
+            f"func (t *T{i}) Get() int {{ return t.N }}\n\n"
+            f"func New{i}() *T{i} {{ return &T{i}{{N: {i}}} }}\n"
+        )
+        (root / "docs").mkdir(exist_ok=True)
+        (root / "docs" / f"note{i:02d}.md").write_text(f"# Note {i}\n\nThe session token of package {package}.\n")
+
+
+class Run:
+    """An indexer over its own store and state, for one tree"""
+    
+    def __init__(self, workdir: Path, name: str):
+        self.rag = make_rag(workdir, name)
+        self.state = StateManager(str(workdir / f"{name}-state.db"))
+        self.indexer = ChromeIndexer(self.rag, state_manager=self.state)
+    
+    def index(self, source: Path, **options):
+        return self.indexer.index_directory(str(source), parallel=False, batch_size=4, **options)
+    
+    def chunks_per_file(self) -> Counter:
+        return Counter(metadata['filepath'] for metadata in self.rag.collection.get()['metadatas'])
+    
+    def recorded(self, source: Path):
+        return {str(Path(path).relative_to(source)) for path in self.state.get_all_indexed_files()}
+
+
+def check_whole_files(run: Run, full: Counter, source: Path):
+    """Every file in the index has all its chunks and is recorded; recorded files are in the index"""
+    stored = run.chunks_per_file()
+    for filepath, count in stored.items():
+        assert count == full[filepath], f"{filepath}: {count} of {full[filepath]} chunks"
+    assert run.recorded(source) == set(stored), (sorted(run.recorded(source)), sorted(stored))
+    return stored
+
+
+def test_cancel_while_parsing(workdir, source, full):
+    run = Run(workdir, "parsing")
+    token = CancelToken()
+    
+    def progress(state):
+        if state.files_parsed == 7:
+            token.cancel()
+    
+    stats = run.index(source, cancel=token, progress=progress)
+    assert stats['cancelled'] == 'cancelled', stats['cancelled']
+    assert run.indexer.progress.stage == 'cancelled' and run.indexer.progress.files_parsed == 7
+    stored = check_whole_files(run, full, source)
+    assert stored and len(stored) < len(full), stored
+    assert not any(path.endswith('.go') for path in stored), "linked files were never stored"
+    
+    stats = run.index(source)
+    assert stats['cancelled'] is None and run.chunks_per_file() == full, "the next run indexes the rest"
+    assert stats['files_skipped'] == len(stored)
+    print("✅ Cancelling while parsing keeps whole files, and the next run finishes")
+
+
+def test_cancel_while_storing(workdir, source, full):
+    run = Run(workdir, "storing")
+    token = CancelToken()
+    
+    def progress(state):
+        if state.stage == 'storing' and state.chunks_embedded >= 30:
+            token.cancel()
+    
+    stats = run.index(source, cancel=token, progress=progress)
+    assert stats['cancelled'] == 'cancelled'
+    stored = check_whole_files(run, full, source)
+    go_files = [path for path in stored if path.endswith('.go')]
+    assert 0 < len(go_files) < sum(path.endswith('.go') for path in full), go_files
+    
+    run.index(source)
+    assert run.chunks_per_file() == full
+    print("✅ Cancelling between insert batches removes partly stored files")
+
+
+def test_progress_and_timeout(workdir, source, full):
+    run = Run(workdir, "progress")
+    seen = []
+    stats = run.index(source, progress=lambda state: seen.append((state.stage, state.files_parsed,
+                                                                      state.chunks_embedded)))
+    stages = list(dict.fromkeys(stage for stage, _, _ in seen))
+    assert stages == ['discovering', 'parsing', 'linking', 'storing', 'done'], stages
+    final = run.indexer.progress
+    assert final.files_total == final.files_parsed == len(full), final
+    assert final.chunks_embedded == sum(full.values()) == stats['chunks_created'], (final, stats['chunks_created'])
+    parsed = list(dict.fromkeys(parsed for stage, parsed, _ in seen if stage == 'parsing'))
+    assert parsed == list(range(len(full) + 1)), parsed
+    
+    timed_out = Run(workdir, "timeout")
+    stats = timed_out.index(source, cancel=CancelToken(timeout=1e-9))
+    assert stats['cancelled'] == 'timed out' and not timed_out.chunks_per_file(), stats['cancelled']
+    print("✅ Progress is reported per file and batch; a timeout cancels the run")
+
+
+def test_parallel_cancel(workdir, source, full):
+    run = Run(workdir, "parallel")
+    token = CancelToken()
+    
+    def progress(state):
+        if state.files_parsed == 3:
+            token.cancel()
+    
+    stats = run.indexer.index_directory(str(source), parallel=True, workers=2, batch_size=4,
+                                        cancel=token, progress=progress)
+    assert stats['cancelled'] == 'cancelled' and run.indexer.progress.files_parsed == 3
+    check_whole_files(run, full, source)
+    print("✅ A cancelled parallel run stops its workers")
+
+
+def main():
+    print("=" * 70)
+    print("CANCELLATION AND PROGRESS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="cancellation_"))
+    source = workdir / "src"
+    make_tree(source)
+    reference = Run(workdir, "reference")
+    reference.index(source)
+    full = reference.chunks_per_file()
+    
+    tests = [
+        lambda: test_cancel_while_parsing(workdir, source, full),
+        lambda: test_cancel_while_storing(workdir, source, full),
+        lambda: test_progress_and_timeout(workdir, source, full),
+        lambda: test_parallel_cancel(workdir, source, full),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,147 @@
+#!/usr/bin/env python3
+"""
+Test script for deterministic chunk ids
+Ids come from the file path and qualified name, never from insertion order
+or line numbers, so identical source always gets identical ids
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import JavaChunker, assign_symbol_ids
+from chunkers.base_chunker import CodeChunk
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+
+class RecordingRAG:
+    """Stands in for the vector database and records the ids chunks are stored under"""
+    
+    embedder = 'recording'
+    
+    def __init__(self):
+        self.ids = {}
+    
+    def validate_embedder(self):
+        return 1
+    
+    def add_chunks_batch(self, chunks):
+        for chunk in chunks:
+            self.ids[chunk.chunk_id()] = (chunk.name, chunk.line_start)
+        return len(chunks)
+    
+    def delete_file_chunks(self, filepath, repo=None):
+        return 0
+    
+    def record_source_root(self, path, repo=None):
+        pass
+
+
+SERVER = '''package server
+
+type Server struct{ Addr string }
+
+func (s *Server) Start() error { return nil }
+
+func init() { register("a") }
+
+func init() { register("b") }
+
+func Listen(addr string) *Server { return &Server{Addr: addr} }
+'''
+
+
+def index(root: Path, run: str):
+    rag = RecordingRAG()
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(root.parent / f"state_{run}.db")))
+    indexer.index_directory(str(root), parallel=False)
+    return rag.ids
+
+
+def test_assign_symbol_ids():
+    source = '''package app;
+class Repo {
+    void find(String name) {}
+    void find(int id) {}
+    void save() {}
+}
+'''
+    chunks = assign_symbol_ids(JavaChunker().extract_chunks(source, 'app/Repo.java'))
+    ids = {c.signature: c.symbol_id for c in chunks if c.name in ('find', 'save')}
+    assert ids['void save()'] == 'app/Repo.java:Repo.save', ids
+    overloads = [ids[s] for s in ids if 'find' in s]
+    assert len(set(overloads)) == 2 and all(i.startswith('app/Repo.java:Repo.find~') for i in overloads), ids
+    
+    # Same ids whatever the order, and pre-assigned ids are kept
+    reordered = assign_symbol_ids(list(reversed(JavaChunker().extract_chunks(source, 'app/Repo.java'))))
+    assert {c.signature: c.symbol_id for c in reordered if c.name in ('find', 'save')} == ids
+    chunk = CodeChunk(type='function', name='f', content='def f(): pass', filepath='a.py',
+                      language='python', line_start=1, line_end=1, symbol_id='custom')
+    assert assign_symbol_ids([chunk])[0].symbol_id == 'custom'
+    print("✅ Overloads get distinct ids independent of their order")
+
+
+def test_split_parts():
+    chunk = CodeChunk(type='function', name='Run', content='x', filepath='cmd/run.go', language='go',
+                      line_start=5, line_end=90, symbol_id='cmd/run.go:Run', part_index=2, part_count=3)
+    assert chunk.chunk_id() == 'cmd/run.go:Run#2'
+    print("✅ Parts of a split symbol are numbered under its id")
+
+
+def test_stable_across_runs():
+    workdir = Path(tempfile.mkdtemp(prefix="chunk_ids_"))
+    try:
+        root = workdir / "src"
+        (root / "server").mkdir(parents=True)
+        path = root / "server" / "server.go"
+        path.write_text(SERVER)
+        
+        first = index(root, 'first')
+        assert index(root, 'second') == first, "identical source produced different ids"
+        assert 'server/server.go:Server.Start' in first and 'server/server.go:Listen' in first, sorted(first)
+        inits = [i for i in first if i.startswith('server/server.go:init~')]
+        assert len(inits) == 2, sorted(first)
+        
+        # Unrelated code above shifts every line, not the ids
+        path.write_text(SERVER.replace("type Server", "const Version = 2\n\nfunc helper() {}\n\ntype Server"))
+        edited = index(root, 'edited')
+        assert set(first) <= set(edited), set(first) - set(edited)
+        assert edited['server/server.go:Listen'][1] > first['server/server.go:Listen'][1]
+        
+        # A renamed symbol gets a new id
+        path.write_text(SERVER.replace("func Listen(", "func ListenAndServe("))
+        renamed = index(root, 'renamed')
+        assert 'server/server.go:Listen' not in renamed and 'server/server.go:ListenAndServe' in renamed
+        assert renamed['server/server.go:Server.Start'] == first['server/server.go:Server.Start']
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ Ids are identical across runs and survive unrelated edits")
+
+
+def main():
+    print("=" * 70)
+    print("CHUNK ID TEST")
+    print("=" * 70)
+    
+    tests = [test_assign_symbol_ids, test_split_parts, test_stable_across_runs]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,147 @@
+#!/usr/bin/env python3
+"""
+Test script for chunk ingestion
+Chunks parsed elsewhere are read as JSON lines in the export schema, embedded
+and stored; an export ingested into another index exports the same records,
+and malformed records are reported by line. Uses a small deterministic embedder
+"""
+
+import io
+import json
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, link_go_packages
+from chunkers.base_chunker import assign_byte_ranges
+from helpers import make_rag
+from utils.chunk_ingest import record_chunk
+
+GO_SOURCE = '''package auth
+
+// Authenticator checks credentials.
+type Authenticator interface {
+    Authenticate(token string) bool
+}
+
+type AdminUser struct {
+    Token string
+}
+
+// Authenticate compares the token.
+func (a *AdminUser) Authenticate(token string) bool {
+    return a.Token == token
+}
+'''
+
+COBOL = {'filepath': 'pay/calc.cbl', 'language': 'cobol', 'kind': 'function', 'name': 'CALC-TAX',
+         'body': 'CALC-TAX.\n    COMPUTE TAX = GROSS * RATE.', 'line_start': 40, 'line_end': 41}
+
+
+def export(rag):
+    buffer = io.StringIO()
+    rag.export_jsonl(buffer)
+    return sorted((json.loads(line) for line in buffer.getvalue().splitlines()), key=lambda r: r['id'])
+
+
+def ingest(rag, lines, **options):
+    return rag.ingest_chunks(io.StringIO(''.join(line + '\n' for line in lines)), **options)
+
+
+def test_round_trip(workdir):
+    source = make_rag(workdir, "source")
+    chunks = assign_byte_ranges(GoChunker().extract_chunks(GO_SOURCE, "auth/user.go"), GO_SOURCE)
+    source.add_chunks_batch(link_go_packages(chunks))
+    records = export(source)
+    
+    target = make_rag(workdir, "target")
+    summary = ingest(target, [json.dumps(r) for r in records], batch_size=2)
+    assert summary == {'records': len(records), 'ingested': len(records), 'malformed': [], 'not_embedded': 0}, summary
+    assert export(target) == records, "an ingested export exports the same records"
+    
+    results = target.retrieve_context("authenticate admin token", n_results=3)
+    assert results and results[0]['metadata']['filepath'] == 'auth/user.go'
+    print("✅ An export ingested into another index keeps every record")
+
+
+def test_minimal_records(workdir):
+    rag = make_rag(workdir, "minimal")
+    summary = ingest(rag, [json.dumps(COBOL), json.dumps(dict(COBOL, name='CALC-NET', line_start=50, line_end=52))])
+    assert summary['ingested'] == 2, summary
+    stored = {m['name']: m for m in rag.collection.get(include=['metadatas'])['metadatas']}
+    assert stored['CALC-TAX']['type'] == 'function' and stored['CALC-TAX']['kind'] == 'source'
+    assert stored['CALC-TAX']['symbol_id'] == 'pay/calc.cbl:CALC-TAX:40', "ids come from path, name and line"
+    assert 'byte_start' not in stored['CALC-TAX']
+    
+    results = rag.retrieve_context("compute tax", n_results=1, languages=['cobol'])
+    assert results[0]['metadata']['name'] == 'CALC-TAX', results[0]['metadata']
+    
+    # Ingesting a chunk again replaces it
+    ingest(rag, [json.dumps(dict(COBOL, body='CALC-TAX.\n    COMPUTE TAX = GROSS * RATE * 2.'))])
+    assert rag.collection.count() == 2
+    
+    chunk = record_chunk(dict(COBOL, qualified_name='Payroll.CALC-TAX', chunk_type='paragraph', test=True))
+    assert (chunk.parent, chunk.type, chunk.kind) == ('Payroll', 'paragraph', 'test')
+    print("✅ Records with only the required fields are embedded, stored and searchable")
+
+
+def test_malformed(workdir):
+    rag = make_rag(workdir, "malformed")
+    lines = [
+        json.dumps(COBOL),
+        '{"filepath": "pay/calc.cbl",',
+        json.dumps({k: v for k, v in COBOL.items() if k != 'body'}),
+        '',
+        json.dumps(dict(COBOL, line_start='40')),
+        json.dumps(dict(COBOL, line_start=41, line_end=40)),
+        json.dumps(dict(COBOL, line_end=True)),
+        json.dumps(dict(COBOL, schema_version=2)),
+        json.dumps([COBOL]),
+        json.dumps(dict(COBOL, name='CALC-NET', line_start=50, line_end=52)),
+    ]
+    summary = ingest(rag, lines)
+    assert summary['records'] == 2 and summary['ingested'] == 2 and rag.collection.count() == 2
+    problems = {entry['line']: entry['error'] for entry in summary['malformed']}
+    assert sorted(problems) == [2, 3, 5, 6, 7, 8, 9], problems
+    assert problems[2].startswith('invalid JSON'), problems[2]
+    assert problems[3] == "missing 'body'"
+    assert problems[5] == "'line_start' must be int, got str"
+    assert problems[6] == "bad line range 41-40"
+    assert problems[7] == "'line_end' must be int, got bool"
+    assert 'schema_version 2' in problems[8]
+    assert problems[9] == "expected a JSON object, got list"
+    print("✅ Malformed records are reported by line and the valid ones are still ingested")
+
+
+def main():
+    print("=" * 70)
+    print("CHUNK INGEST TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="ingest_"))
+    tests = [
+        lambda: test_round_trip(workdir),
+        lambda: test_minimal_records(workdir),
+        lambda: test_malformed(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
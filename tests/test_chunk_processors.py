@@ -0,0 +1,216 @@
+#!/usr/bin/env python3
+"""
+Test script for chunk processors
+Processors run on every chunk before it is embedded, in order, and can add
+metadata, rewrite the embedding text or drop the chunk; CodeOwnersProcessor
+records owners from a CODEOWNERS file
+"""
+
+import re
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk, parse_metadata
+from helpers import HashEmbedder, make_chroma_rag
+from indexer import ChromeIndexer
+from utils.chunk_processors import (EMBEDDING_TEXT_KEY, ChunkProcessor, CodeOwnersProcessor, file_owners,
+                                    load_processor, parse_codeowners, run_processors)
+from utils.state_manager import StateManager
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+CODEOWNERS = '''# Default owners
+*                   @acme/core
+*.lua               @acme/lua  # inline comment
+/docs/*             @acme/docs
+services/billing/   @acme/billing alice@example.com
+services/billing/legacy.lua
+'''
+
+
+class TicketProcessor(ChunkProcessor):
+    """Ticket references of a chunk as metadata, and as the text it is embedded from"""
+    
+    def process(self, chunk):
+        tickets = sorted(set(re.findall(r'\b[A-Z]+-\d+\b', (chunk.doc or '') + chunk.content)))
+        if tickets:
+            chunk.metadata = dict(chunk.metadata or {}, tickets=tickets)
+            chunk.metadata[EMBEDDING_TEXT_KEY] = f"{chunk.name} tickets {' '.join(tickets)}"
+        return chunk
+
+
+class DropHelpers(ChunkProcessor):
+    """Drops every chunk whose name starts with _"""
+    
+    def process(self, chunk):
+        return None if chunk.name.startswith('_') else chunk
+
+
+class Failing(ChunkProcessor):
+    """Raises on every chunk"""
+    
+    def process(self, chunk):
+        raise RuntimeError("lookup service down")
+
+
+def make_chunk(name, filepath='a.py'):
+    return CodeChunk(type='function', name=name, content=f"def {name}(): pass", filepath=filepath,
+                     language='python', line_start=1, line_end=1)
+
+
+def stored(rag):
+    listing = rag.collection.get(include=['metadatas'])
+    return {metadata['name']: metadata for metadata in listing['metadatas']}
+
+
+def test_codeowners_rules():
+    rules = parse_codeowners(CODEOWNERS)
+    assert [rule.source for rule in rules] == ['*', '*.lua', '/docs/*', 'services/billing/', 'services/billing/legacy.lua']
+    assert file_owners(rules, 'main.go') == ['@acme/core']
+    assert file_owners(rules, 'tools/gen.lua') == ['@acme/lua'], "the last matching rule wins"
+    assert file_owners(rules, 'docs/guide.md') == ['@acme/docs']
+    assert file_owners(rules, 'docs/api/README.md') == ['@acme/core'], "docs/* leaves out subdirectories"
+    assert file_owners(rules, 'services/billing/invoice/pdf.go') == ['@acme/billing', 'alice@example.com']
+    assert file_owners(rules, 'services/billing/legacy.lua') == [], "a rule without owners clears them"
+    assert file_owners([], 'main.go') == []
+    print("✅ CODEOWNERS rules match gitignore-style, the last match deciding the owners")
+
+
+def test_run_processors():
+    chunks = [make_chunk('load'), make_chunk('_cache'), make_chunk('save')]
+    seen = []
+    
+    class Recorder(ChunkProcessor):
+        def process(self, chunk):
+            seen.append(chunk.name)
+            return chunk
+    
+    kept, errors = run_processors([DropHelpers(), Recorder()], chunks)
+    assert [chunk.name for chunk in kept] == ['load', 'save'] and not errors
+    assert seen == ['load', 'save'], "a dropped chunk goes to no later processor"
+    
+    class Replace(ChunkProcessor):
+        def process(self, chunk):
+            return make_chunk(chunk.name.upper())
+    
+    kept, errors = run_processors([Failing(), Replace(), Recorder()], chunks[:1])
+    assert [chunk.name for chunk in kept] == ['LOAD'] and seen[-1] == 'LOAD', "each sees what the last returned"
+    assert errors == ["a.py:1: Failing: lookup service down"], errors
+    assert run_processors([], chunks) == (chunks, [])
+    print("✅ Processors run in order; None drops the chunk and an error leaves it to the next one")
+
+
+def test_load_processor():
+    assert isinstance(load_processor('codeowners'), CodeOwnersProcessor)
+    processor = load_processor('test_chunk_processors:TicketProcessor')
+    assert type(processor).__name__ == 'TicketProcessor' and processor.name == 'TicketProcessor'
+    for spec, message in (('owners', 'Unknown chunk processor'), ('no_such_module:X', 'Cannot load'),
+                          ('test_chunk_processors:Missing', 'Cannot load'),
+                          ('test_chunk_processors:SAMPLES', 'has no process method')):
+        try:
+            load_processor(spec)
+        except ValueError as e:
+            assert message in str(e), (spec, e)
+        else:
+            raise AssertionError(f"{spec} loaded")
+    print("✅ Processors load by built-in name or 'module:Class', and bad names are reported")
+
+
+def test_indexing(workdir):
+    embedder = HashEmbedder()
+    rag = make_chroma_rag(workdir / "db", "processed", embedder=embedder)
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db")),
+                            processors=[CodeOwnersProcessor(), DropHelpers(), TicketProcessor()])
+    indexer.index_directory(str(workdir / "src"), parallel=False)
+    symbols = stored(rag)
+    
+    assert '_round_cents' not in symbols, "the dropped chunk is not stored"
+    assert indexer.stats['chunks_dropped_processors'] == 1, indexer.stats['chunks_dropped_processors']
+    charge = parse_metadata(symbols['charge']['metadata'])
+    assert charge['owners'] == ['@acme/billing', 'alice@example.com'], charge
+    assert charge['tickets'] == ['BILL-42', 'PAY-7'], charge
+    assert any(text.startswith('charge tickets BILL-42 PAY-7') for text in embedder.texts), \
+        "the rewritten text is what is embedded"
+    assert not any('account.balance - total' in text for text in embedder.texts)
+    refund = parse_metadata(symbols['refund']['metadata'])
+    assert 'tickets' not in refund and any('account.balance + amount' in text for text in embedder.texts)
+    
+    # Go chunks have their processors run after the package linker
+    go = [metadata for metadata in symbols.values() if metadata['language'] == 'go']
+    assert go and all(parse_metadata(metadata['metadata'])['owners'] == ['@acme/core'] for metadata in go), go
+    assert indexer.stats['chunks_created'] == rag.collection.count(), indexer.stats['chunks_created']
+    print("✅ Indexing stores the owners and tickets processors add, embeds the rewritten text "
+          "and leaves out dropped chunks")
+
+
+def test_indexing_errors(workdir):
+    rag = make_chroma_rag(workdir / "db", "failing")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "failing.db")),
+                            processors=[Failing(), CodeOwnersProcessor(str(workdir / "none"))])
+    indexer.index_directory(str(workdir / "src"), parallel=False)
+    symbols = stored(rag)
+    assert 'charge' in symbols and 'lookup service down' in ' '.join(indexer.stats['errors'])
+    assert all('owners' not in parse_metadata(metadata['metadata']) for metadata in symbols.values()), \
+        "a missing CODEOWNERS file leaves owners out"
+    print("✅ A failing processor keeps the chunks indexed and reports its errors")
+
+
+def main():
+    print("=" * 70)
+    print("CHUNK PROCESSORS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_processors_"))
+    src = workdir / "src"
+    (src / ".github").mkdir(parents=True)
+    (src / ".github" / "CODEOWNERS").write_text(CODEOWNERS)
+    (src / "services" / "billing").mkdir(parents=True)
+    (src / "services" / "billing" / "payments.lua").write_text('''local Payments = {}
+
+--- Charge an account (BILL-42, see PAY-7 for retries)
+function Payments.charge(account, amount)
+  local total = Payments._round_cents(amount)
+  account.balance = account.balance - total
+  return total
+end
+
+--- Give an amount back
+function Payments.refund(account, amount)
+  account.balance = account.balance + amount
+  return amount
+end
+
+function Payments._round_cents(amount)
+  return math.floor(amount * 100 + 0.5) / 100
+end
+
+return Payments
+''')
+    shutil.copy(SAMPLES / "complex.go", src / "complex.go")
+    
+    tests = [
+        test_codeowners_rules, test_run_processors, test_load_processor, lambda: test_indexing(workdir),
+        lambda: test_indexing_errors(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
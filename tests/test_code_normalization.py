@@ -0,0 +1,162 @@
+#!/usr/bin/env python3
+"""
+Test script for normalizing code before it is embedded
+Checks that copies differing only in layout (and, at the 'comments' level, in
+comments) normalize to the same text, that the embedder gets the normalized text
+while the stored code stays as written, that dedup then stores such copies once,
+and that the level is kept with the index and its snapshots. Uses a small
+deterministic embedder that records what it embeds
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from embedders import EmbeddingError
+from helpers import make_rag
+from utils.code_normalization import normalize_code
+
+
+GO_SOURCE = '''package retry
+
+// Backoff returns the delay before attempt n
+func Backoff(n int) time.Duration {
+    if n<=0 { return 0 }
+    
+    return time.Duration(n*n) * 100 * time.Millisecond
+}
+'''
+
+GO_REFORMATTED = '''package retry
+
+// Backoff returns the delay before attempt n
+func Backoff(n int) time.Duration {
+	if n <= 0 {return 0}
+
+
+	return time.Duration(n * n)*100*time.Millisecond
+}
+'''
+
+PY_TWO_SPACES = '''def retry(call, attempts=3):
+  for attempt in range(attempts):
+    try:
+      return call()
+    except IOError:
+      pass
+  raise RuntimeError("gave up # after retries")'''
+
+PY_FOUR_SPACES = '''def retry(call, attempts=3):
+    # Try a few times before giving up
+    for attempt in range(attempts):
+        try:
+            return call()  # done
+        except IOError:
+            pass
+    raise RuntimeError("gave up # after retries")'''
+
+
+def test_layouts():
+    assert normalize_code(PY_TWO_SPACES, 'python', 'off') == PY_TWO_SPACES
+    two, four = normalize_code(PY_TWO_SPACES, 'python'), normalize_code(PY_FOUR_SPACES, 'python')
+    assert two.split('\n')[3] == '            return call()' and not any(line.endswith(' ') for line in two.split('\n'))
+    assert two != four, "comments are kept at the whitespace level"
+    
+    two, four = normalize_code(PY_TWO_SPACES, 'python', 'comments'), normalize_code(PY_FOUR_SPACES, 'python', 'comments')
+    assert two == four, (two, four)
+    assert '"gave up # after retries"' in two, "a '#' inside a string is not a comment"
+    
+    go, reformatted = (normalize_code(source, 'go') for source in (GO_SOURCE, GO_REFORMATTED))
+    assert go == reformatted, (go, reformatted)
+    assert '\treturn time.Duration(n * n) * 100 * time.Millisecond' in go and '// Backoff returns' in go
+    assert 'Backoff' not in normalize_code('// Backoff doc\nfunc f() {}', 'go', 'comments')
+    assert normalize_code('x := []*T(nil)\ny := -a[i]', 'go') == 'x := []*T(nil)\ny := -a[i]'
+    print("✅ Copies differing in layout normalize to the same text, Go by its tokens")
+
+
+def test_embedded_text(workdir):
+    rag = make_rag(workdir, 'embedded', code_normalization='whitespace')
+    rag.add_chunks_batch(GoChunker().extract_chunks(GO_REFORMATTED, 'retry/retry.go'))
+    stored = rag.collection.get(where={'name': 'Backoff'}, include=['documents'])['documents'][0]
+    assert '{return 0}\n\n\n\treturn' in stored, "the stored code is kept as written"
+    backoff = next(text for text in rag.embedder.texts if 'Backoff' in text)
+    assert backoff == normalize_code(stored, 'go') and '\n\n\n' not in backoff, backoff
+    assert rag.collection.metadata['code_normalization'] == 'whitespace'
+    
+    reopened = make_rag(workdir, 'embedded')
+    assert reopened.code_normalization == 'whitespace'
+    try:
+        make_rag(workdir, 'embedded', code_normalization='comments').validate_embedder()
+        assert False, "an index normalized as 'whitespace' should refuse 'comments'"
+    except EmbeddingError:
+        pass
+    print("✅ The normalized code is embedded, the original stored, and the level kept")
+
+
+def test_dedup_of_reformatted(workdir):
+    for level, expected in (('off', 2), ('whitespace', 1)):
+        rag = make_rag(workdir, f'dedup_{level}', dedup='exact', code_normalization=level)
+        for path, source in (('a/retry.go', GO_SOURCE), ('b/retry.go', GO_REFORMATTED)):
+            rag.add_chunks_batch(GoChunker().extract_chunks(source, path))
+        stored = rag.collection.get(where={'name': 'Backoff'}, include=['metadatas'])
+        assert len(stored['ids']) == expected, (level, stored['ids'])
+    assert stored['metadatas'][0]['duplicate_count'] == 1
+    print("✅ Exact dedup stores reformatted copies once when code is normalized")
+
+
+def test_language_overrides(workdir):
+    saved = CONFIG.language_code_normalization
+    CONFIG.language_code_normalization = {'python': 'off'}
+    try:
+        rag = make_rag(workdir, 'overrides', code_normalization='comments')
+    finally:
+        CONFIG.language_code_normalization = saved
+    chunks = [CodeChunk(type='function', name='retry', content=PY_FOUR_SPACES, filepath='retry.py',
+                        language='python', line_start=1, line_end=8)]
+    chunks += GoChunker().extract_chunks(GO_SOURCE, 'retry/retry.go')
+    rag.add_chunks_batch(chunks)
+    assert PY_FOUR_SPACES in rag.embedder.texts, "python is embedded as written"
+    assert not any('// Backoff' in text for text in rag.embedder.texts), "go comments are dropped"
+    assert make_rag(workdir, 'overrides').language_code_normalization == {'python': 'off'}
+    
+    manifest = rag.save_index(str(workdir / 'snapshot'))
+    assert manifest['code_normalization'] == 'comments', manifest
+    restored = make_rag(workdir, 'restored')
+    restored.load_index(str(workdir / 'snapshot'))
+    assert restored.code_normalization == 'comments' and restored.language_code_normalization == {'python': 'off'}
+    assert make_rag(workdir, 'restored').code_normalization == 'comments'
+    print("✅ Per-language levels are kept with the index and its snapshots")
+
+
+def main():
+    print("=" * 70)
+    print("CODE NORMALIZATION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="normalization_"))
+    tests = [test_layouts, lambda: test_embedded_text(workdir), lambda: test_dedup_of_reformatted(workdir),
+             lambda: test_language_overrides(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
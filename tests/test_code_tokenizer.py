@@ -0,0 +1,127 @@
+#!/usr/bin/env python3
+"""
+Test script for identifier-aware keyword tokenization
+Covers the default split, the configurable tokenizer rules and their
+per-language defaults, and a keyword search matching every identifier style
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from embedders import KeywordOnlyEmbedder
+from helpers import make_rag
+from utils.code_tokenizer import TokenizerRules, make_rules, split_identifier, tokenize_code, tokenizer_rules
+
+
+def test_split_identifier():
+    assert split_identifier('CreateSession') == ['create', 'session']
+    assert split_identifier('create_session') == ['create', 'session']
+    assert split_identifier('HTTPServer') == ['http', 'server']
+    assert split_identifier('kMaxRetries') == ['k', 'max', 'retries']
+    assert split_identifier('parseURL2') == ['parse', 'url', '2']
+    print("✅ Identifiers split on camelCase/snake_case boundaries")
+
+
+def test_tokenize_code():
+    tokens = tokenize_code('func (s *Server) CreateSession(ctx context.Context) error {')
+    for expected in ['createsession', 'create', 'session', 'server', 'ctx', 'context']:
+        assert expected in tokens, (expected, tokens)
+    assert '(s' not in tokens and '{' not in tokens
+    print("✅ Code tokenized into whole identifiers and their parts")
+
+
+def test_query_matches_identifier():
+    document = set(tokenize_code('def create_session(user): pass'))
+    assert set(tokenize_code('create session')) <= document
+    assert set(tokenize_code('CreateSession')) & document == {'create', 'session'}
+    print("✅ Natural-language queries match split identifiers")
+
+
+def test_rules():
+    assert split_identifier('parseURL2', TokenizerRules(split_digits=False)) == ['parse', 'url2']
+    assert split_identifier('ADMIN_USER', TokenizerRules(split_underscores=False)) == ['admin_user']
+    assert split_identifier('AdminUser', TokenizerRules(split_case=False)) == ['adminuser']
+    assert tokenize_code('max-age: 3') == ['max', 'age', '3'], "hyphens separate words by default"
+    assert tokenize_code('max-age: 3', TokenizerRules(kebab_case=True)) == ['max-age', 'max', 'age', '3']
+    assert tokenize_code('x - y', TokenizerRules(kebab_case=True)) == ['x', 'y'], "a minus is not kebab-case"
+    assert tokenize_code('CreateSession', TokenizerRules(keep_whole=False)) == ['create', 'session']
+    assert tokenize_code('kMaxRetries', TokenizerRules(min_length=2)) == ['kmaxretries', 'max', 'retries']
+    rules = make_rules({'stopwords': ['english', 'TODO']})
+    assert tokenize_code('TODO: close the session', rules) == ['close', 'session'], rules
+    print("✅ Tokenizer rules choose the boundaries, whole forms, minimum length and stopwords")
+
+
+def test_language_rules():
+    assert tokenizer_rules('yaml').kebab_case and not tokenizer_rules('go').kebab_case
+    assert tokenizer_rules().kebab_case, "queries keep kebab-case words whole for the languages that do"
+    saved = CONFIG.tokenizer_rules, CONFIG.language_tokenizer_rules
+    CONFIG.tokenizer_rules = {'min_length': 2}
+    CONFIG.language_tokenizer_rules = {'go': {'split_digits': False}, 'yaml': {'kebab_case': False}}
+    try:
+        assert tokenizer_rules('go') == TokenizerRules(min_length=2, split_digits=False)
+        assert not tokenizer_rules('yaml').kebab_case and tokenizer_rules('python').min_length == 2
+        assert not any('tokenizer_rules' in p for p in cli.setting_problems())
+        CONFIG.tokenizer_rules = {'min_length': 0, 'split_camel': True}
+        CONFIG.language_tokenizer_rules = {'go': {'stopwords': 'the'}}
+        problems = [p for p in cli.setting_problems() if 'tokenizer_rules' in p]
+    finally:
+        CONFIG.tokenizer_rules, CONFIG.language_tokenizer_rules = saved
+    assert any(p.startswith('tokenizer_rules: min_length') for p in problems), problems
+    assert any('unknown rule split_camel' in p for p in problems), problems
+    assert any(p.startswith('languages.go.tokenizer_rules: stopwords') for p in problems), problems
+    print("✅ Rules have per-language defaults and overrides, and bad ones are reported")
+
+
+def test_identifier_styles():
+    workdir = Path(tempfile.mkdtemp(prefix="rag_code_tokenizer_"))
+    try:
+        rag = make_rag(workdir, "styles", embedder=KeywordOnlyEmbedder())
+        sources = [('AdminUser', 'go', 'type AdminUser struct{}'),
+                   ('admin_user', 'python', 'def admin_user(): pass'),
+                   ('ADMIN_USER', 'c', '#define ADMIN_USER 1'),
+                   ('admin-user', 'yaml', 'admin-user: true'),
+                   ('render', 'go', 'func render() {}')]
+        rag.add_chunks_batch([CodeChunk(type='function', name=name, content=content, filepath=f'src/{n}.txt',
+                                        language=language, line_start=1, line_end=1)
+                              for n, (name, language, content) in enumerate(sources)])
+        rag._build_keyword_index()
+        names = {r['metadata']['name'] for r in rag.retrieve_context('admin user', n_results=5)}
+        assert names == {'AdminUser', 'admin_user', 'ADMIN_USER', 'admin-user'}, names
+        top = rag.retrieve_context('admin-user', n_results=1)[0]['metadata']['name']
+        assert top == 'admin-user', top
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ 'admin user' matches AdminUser, admin_user, ADMIN_USER and admin-user")
+
+
+def main():
+    print("=" * 70)
+    print("CODE TOKENIZER TEST")
+    print("=" * 70)
+    
+    tests = [test_split_identifier, test_tokenize_code, test_query_matches_identifier, test_rules,
+             test_language_rules, test_identifier_styles]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,198 @@
+#!/usr/bin/env python3
+"""
+Concurrency test and benchmark for searches served from several threads
+Reader threads search while a background re-index rewrites every file and
+swaps in a new keyword index, as the HTTP server does under load. No search
+may fail or log an error, and every result must be a stored chunk with its
+real content, of either the old or the new version of its file. Reports the
+searches per second with and without the re-index running. Uses a small
+deterministic embedder
+"""
+
+import logging
+import re
+import shutil
+import sys
+import tempfile
+import threading
+import time
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.query_cache import QueryCache
+from utils.state_manager import StateManager
+
+
+# Reader threads, and the seconds each benchmark runs
+READERS = 4
+DURATION = 3.0
+
+QUERIES = ['open session', 'close session', 'parse config', 'retry request', 'render page', 'store token']
+
+
+class ErrorLog(logging.Handler):
+    """Collects the error records logged while it is attached"""
+    
+    def __init__(self):
+        super().__init__(logging.ERROR)
+        self.messages = []
+    
+    def emit(self, record):
+        self.messages.append(record.getMessage())
+
+
+def source(package: str, version: int) -> str:
+    functions = '\n'.join(
+        f'// {verb.capitalize()} {noun} number {n} (version {version})\n'
+        f'func {verb.capitalize()}{noun.capitalize()}{n}() string {{\n    return "{verb} {noun} v{version}"\n}}\n'
+        for n, (verb, noun) in enumerate(query.split() for query in QUERIES)
+    )
+    return f'package {package}\n\n{functions}'
+
+
+def write_tree(root: Path, version: int, files: int = 12):
+    for n in range(files):
+        path = root / f'pkg{n}' / f'file{n}.go'
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(source(f'pkg{n}', version))
+
+
+def make_index(workdir: Path, name: str):
+    root = workdir / name
+    write_tree(root, 0)
+    rag = make_rag(workdir, name)
+    rag.query_cache = QueryCache(0)  # every search runs in full
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / f"{name}_state.db")))
+    indexer.index_directory(str(root), parallel=False)
+    return root, rag, indexer
+
+
+def run_readers(rag, stop: threading.Event, problems: list) -> int:
+    """Search from READERS threads until stop is set; returns the number of searches"""
+    counts = [0] * READERS
+    
+    def read(slot):
+        n = 0
+        while not stop.is_set():
+            query = QUERIES[(slot + n) % len(QUERIES)]
+            try:
+                results = rag.retrieve_context(query, n_results=5, lexical_weight=0.5)
+            except Exception as e:
+                problems.append(f"{query}: {type(e).__name__}: {e}")
+                return
+            for result in results:
+                if not re.search(r'^package pkg\d+|^func \w+\(', result.get('content') or '', re.MULTILINE):
+                    problems.append(f"{query}: result {result.get('id')} without its content")
+            if not results:
+                problems.append(f"{query}: no results")
+            n += 1
+            counts[slot] = n
+    
+    threads = [threading.Thread(target=read, args=(slot,)) for slot in range(READERS)]
+    for thread in threads:
+        thread.start()
+    for thread in threads:
+        thread.join()
+    return sum(counts)
+
+
+def test_search_during_reindex(workdir):
+    """Searches stay correct while a background re-index rewrites every file"""
+    root, rag, indexer = make_index(workdir, "race")
+    errors = ErrorLog()
+    rag.logger.addHandler(errors)
+    stop = threading.Event()
+    problems = []
+    updates = []
+    
+    def reindex():
+        version = 0
+        try:
+            while not stop.is_set():
+                version += 1
+                write_tree(root, version)
+                stats = indexer.update_index(str(root), parallel=False, report=False)
+                rag._build_keyword_index()
+                updates.append(len(stats['paths_updated']))
+        except Exception as e:
+            problems.append(f"re-index: {type(e).__name__}: {e}")
+    
+    writer = threading.Thread(target=reindex)
+    writer.start()
+    timer = threading.Timer(DURATION, stop.set)
+    timer.start()
+    searches = run_readers(rag, stop, problems)
+    stop.set()
+    writer.join()
+    rag.logger.removeHandler(errors)
+    
+    assert not errors.messages, errors.messages[:5]
+    assert not problems, problems[:5]
+    assert updates and all(count == 12 for count in updates), updates
+    assert len(rag.collection.get(include=[])['ids']) == 12 * (len(QUERIES) + 1), "no chunk lost or duplicated"
+    print(f"✅ {searches} searches during {len(updates)} re-indexes, all correct "
+          f"({searches / DURATION:.0f} searches/s)")
+
+
+def test_concurrent_keyword_rebuilds(workdir):
+    """Keyword index rebuilds racing each other leave the newest one in place"""
+    root, rag, indexer = make_index(workdir, "rebuilds")
+    write_tree(root, 1)
+    indexer.update_index(str(root), parallel=False, report=False)
+    threads = [threading.Thread(target=rag._build_keyword_index) for _ in range(8)]
+    for thread in threads:
+        thread.start()
+    for thread in threads:
+        thread.join()
+    stored = set(rag.collection.get(include=[])['ids'])
+    assert set(rag.bm25_ids) == stored, "the keyword index matches the store"
+    results = rag.retrieve_context("parse config", n_results=3, lexical_weight=1.0)
+    assert results and all(' v1"' in r['content'] for r in results), [r['content'][:40] for r in results]
+    print("✅ Racing keyword index rebuilds leave the newest in place")
+
+
+def test_benchmark(workdir):
+    """Search throughput from several threads over a settled index"""
+    _, rag, _ = make_index(workdir, "bench")
+    stop = threading.Event()
+    problems = []
+    timer = threading.Timer(DURATION, stop.set)
+    timer.start()
+    searches = run_readers(rag, stop, problems)
+    assert not problems, problems[:5]
+    print(f"✅ {searches} searches in {DURATION:.0f}s from {READERS} threads "
+          f"({searches / DURATION:.0f} searches/s)")
+
+
+def main():
+    print("=" * 70)
+    print("CONCURRENT SEARCH TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_concurrent_search_"))
+    tests = [
+        lambda: test_search_during_reindex(workdir),
+        lambda: test_concurrent_keyword_rebuilds(workdir),
+        lambda: test_benchmark(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,247 @@
+#!/usr/bin/env python3
+"""
+Test script for the YAML and JSON chunkers
+Runs without tree-sitter: documents and keys are found by hand-written scanners.
+The search test uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import JsonChunker, YamlChunker
+from chunkers.yaml_chunker import load_yaml, parse_simple_yaml
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+
+MANIFESTS = '''# The auth service
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: auth-service
+  namespace: auth
+  labels:
+    app: auth
+    tier: backend
+spec:
+  replicas: 2
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: registry.local/auth-migrate:1.4
+      containers:
+      - name: auth
+        image: registry.local/auth:1.4
+        args: ["--port", "8080",
+               "--log", "json"]
+        command:
+        - /bin/sh
+        - -c
+        - |
+          echo "key: value"
+          exec auth
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: auth-service
+  namespace: auth
+spec:
+  selector:
+    app: auth
+  ports:
+    - port: 80
+      targetPort: 8080
+...
+---
+# Settings for the billing worker
+log_level: debug
+queue_name: billing-jobs # the queue it drains
+'''
+
+COMPOSE_HEAD = '''version: "3.9"
+# One service per tenant
+services:
+'''
+
+SETTINGS = '''{
+  // Compiler settings
+  "compilerOptions": {
+    "target": "es2020",
+    "strict": true, // always
+  },
+  "include": ["src/**/*.ts"],
+}
+'''
+
+LIST = '''{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "auth-config"}, "data": {"level": "info"}},
+    {
+      "apiVersion": "batch/v1",
+      "kind": "CronJob",
+      "metadata": {"name": "token-cleanup", "namespace": "auth"},
+      "spec": {"jobTemplate": {"spec": {"template": {"spec": {
+        "containers": [{"name": "cleanup", "image": "registry.local/cleanup:2"}]
+      }}}}}
+    }
+  ]
+}
+'''
+
+
+def compose(services):
+    """A compose file whose services mapping is longer than MAX_KEY_LINES"""
+    lines = [COMPOSE_HEAD]
+    for n in range(services):
+        lines.append(f"  tenant{n}:\n    image: registry.local/tenant:{n}\n    environment:\n"
+                     f"      TENANT_ID: \"{n}\"\n      DATABASE_URL: postgres://db/tenant{n}\n")
+    return ''.join(lines)
+
+
+def by_name(chunks, name):
+    return next(c for c in chunks if c.name == name)
+
+
+def test_manifests():
+    chunks = YamlChunker().extract_chunks(MANIFESTS, 'deploy/auth.yaml')
+    assert [(c.type, c.name) for c in chunks] == [('manifest', 'auth-service'), ('manifest', 'auth-service'),
+                                                 ('key', 'log_level'), ('key', 'queue_name')], \
+        [(c.type, c.name) for c in chunks]
+    deployment, service, level, queue = chunks
+    assert (deployment.line_start, deployment.line_end) == (2, 27), "the '|' script's 'key:' line starts nothing"
+    assert deployment.content == '\n'.join(MANIFESTS.split('\n')[1:27])
+    assert deployment.namespace == 'auth' and deployment.doc == 'The auth service'
+    assert deployment.signature == 'Deployment auth-service'
+    assert deployment.metadata == {
+        'api_version': 'apps/v1', 'kind': 'Deployment', 'name': 'auth-service', 'namespace': 'auth',
+        'labels': {'app': 'auth', 'tier': 'backend'},
+        'images': ['registry.local/auth-migrate:1.4', 'registry.local/auth:1.4'],
+        'resource': 'Deployment/auth-service', 'document': 1,
+    }, deployment.metadata
+    assert (service.line_start, service.line_end) == (29, 39) and service.metadata['document'] == 2
+    assert service.metadata['resource'] == 'Service/auth-service' and 'images' not in service.metadata
+    
+    assert level.doc == 'Settings for the billing worker' and level.metadata == {'document': 3, 'key_path': 'log_level'}
+    assert (queue.line_start, queue.line_end) == (44, 44) and queue.doc is None
+    print("✅ Kubernetes manifests are chunked per document with their kind, name, labels and images")
+
+
+def test_keys():
+    code = compose(10)
+    chunks = YamlChunker().extract_chunks(code, 'docker-compose.yml')
+    assert [c.qualified_name for c in chunks] == ['version'] + [f'services.tenant{n}' for n in range(10)], \
+        [c.qualified_name for c in chunks]
+    tenant = by_name(chunks, 'tenant3')
+    assert tenant.type == 'key' and tenant.parent == 'services' and tenant.metadata == {'key_path': 'services.tenant3'}
+    assert (tenant.line_start, tenant.line_end) == (19, 23) and tenant.content.startswith('tenant3:\n    image:')
+    assert by_name(chunks, 'tenant0').doc is None
+    short = YamlChunker().extract_chunks(compose(2), 'docker-compose.yml')
+    assert [c.name for c in short] == ['version', 'services'] and short[1].doc == 'One service per tenant'
+    
+    playbook = ''.join(f"- name: Configure host {n}\n  hosts: web{n}\n  tasks:\n    - apt: name=nginx\n"
+                       for n in range(3))
+    plays = YamlChunker().extract_chunks(playbook, 'site.yml')
+    assert [(c.type, c.name, c.metadata['key_path'], c.line_start) for c in plays] == [
+        ('item', f'Configure host {n}', f'[{n}]', 1 + 4 * n) for n in range(3)], [c.name for c in plays]
+    
+    template = "replicas: {{ .Values.replicas }}\nimage:\n{{- if .Values.image }}\n  repository: {{ .Values.image }}\n{{- end }}\n"
+    chunks = YamlChunker().extract_chunks(template, 'templates/deployment.yaml')
+    assert [(c.name, c.line_start, c.line_end) for c in chunks] == [('replicas', 1, 1), ('image', 2, 5)]
+    print("✅ YAML keys and list items are chunked with their key paths, long mappings by their keys")
+
+
+def test_simple_reader():
+    deployment, service = MANIFESTS.split('---')[0], MANIFESTS.split('---')[1].split('...')[0]
+    for text in (service, compose(2)):
+        assert parse_simple_yaml(text) == load_yaml(text), (parse_simple_yaml(text), load_yaml(text))
+    simple, loaded = parse_simple_yaml(deployment), load_yaml(deployment)
+    container = simple['spec']['template']['spec']['containers'][0]
+    assert container['command'] == ['/bin/sh', '-c', 'echo "key: value"\nexec auth\n']
+    assert container.pop('args') == '["--port", "8080",', "flow lists are kept as text"
+    del loaded['spec']['template']['spec']['containers'][0]['args']
+    assert simple == loaded, (simple, loaded)
+    print("✅ The reader without PyYAML agrees with it on block mappings, lists and scalars")
+
+
+def test_json():
+    chunker = JsonChunker()
+    chunks = chunker.extract_chunks(SETTINGS, 'tsconfig.json')
+    assert not chunker.diagnostics, chunker.diagnostics
+    assert [(c.name, c.line_start, c.line_end) for c in chunks] == [('compilerOptions', 3, 6), ('include', 7, 7)]
+    options = chunks[0]
+    assert options.doc == 'Compiler settings', "a trailing '// always' is not a doc"
+    assert options.content == '"compilerOptions": {\n    "target": "es2020",\n    "strict": true, // always\n  }'
+    
+    chunks = JsonChunker().extract_chunks(LIST, 'cluster.json')
+    assert [(c.type, c.name, c.line_start, c.line_end) for c in chunks] == [
+        ('manifest', 'auth-config', 5, 5), ('manifest', 'token-cleanup', 6, 13)], [(c.name, c.line_start) for c in chunks]
+    cleanup = chunks[1]
+    assert cleanup.namespace == 'auth' and cleanup.metadata['images'] == ['registry.local/cleanup:2']
+    assert cleanup.metadata['key_path'] == 'items[1]' and cleanup.metadata['resource'] == 'CronJob/token-cleanup'
+    
+    chunker = JsonChunker()
+    chunks = chunker.extract_chunks('{\n  "name": "broken",\n  "tags": [1, 2\n  "next": true\n}\n', 'broken.json')
+    assert [c.type for c in chunks] == ['document'] and chunks[0].line_end == 5
+    assert chunker.diagnostics == [{'kind': 'parse_error', 'line': 4, 'message': "expected ',' or ']'"}], chunker.diagnostics
+    print("✅ JSON keys and Kubernetes List items are chunked with their spans, comments and all")
+
+
+def test_search():
+    workdir = Path(tempfile.mkdtemp(prefix="config_chunks_"))
+    try:
+        source = workdir / "repo"
+        (source / "deploy").mkdir(parents=True)
+        (source / "deploy" / "auth.yaml").write_text(MANIFESTS)
+        (source / "deploy" / "cluster.json").write_text(LIST)
+        (source / "docker-compose.yml").write_text(compose(10))
+        rag = make_rag(workdir, "config")
+        ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db"))).index_directory(str(source), parallel=False)
+        rag._build_keyword_index()
+        
+        top = rag.retrieve_context("the deployment for the auth service", n_results=3)[0]
+        assert (top['metadata']['type'], top['metadata']['name']) == ('manifest', 'auth-service'), top['metadata']
+        assert top['metadata']['line_start'] == 2 and '"resource": "Deployment/auth-service"' in top['metadata']['metadata']
+        found = [r['metadata']['name'] for r in rag.retrieve_context("auth", n_results=10, filter_expr="resource=Service")]
+        assert found == ['auth-service'], found
+        found = sorted(r['metadata']['name'] for r in rag.retrieve_context("auth", n_results=10, kinds=['manifest']))
+        assert found == ['auth-config', 'auth-service', 'auth-service', 'token-cleanup'], found
+        found = [r['metadata']['name'] for r in rag.retrieve_context("cleanup", n_results=10,
+                                                                    filter_expr="resource=CronJob/token-*")]
+        assert found == ['token-cleanup'], found
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ Searching for a deployment finds its manifest, and manifests filter by resource")
+
+
+def main():
+    print("=" * 70)
+    print("YAML AND JSON CHUNKER TEST")
+    print("=" * 70)
+    
+    tests = [test_manifests, test_keys, test_simple_reader, test_json, test_search]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
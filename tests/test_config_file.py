@@ -0,0 +1,251 @@
+#!/usr/bin/env python3
+"""
+Test script for config files and environment settings
+Reads TOML and YAML files into CONFIG, checks what is reported about bad
+settings, and that options override the file and the environment overrides both
+"""
+
+import copy
+import os
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from config import CONFIG
+from utils.config_file import (ConfigFileError, apply_settings, env_settings, file_settings, find_config_file,
+                               read_config_file)
+
+
+TOML = '''roots = ["src", "proto=../proto"]
+ignore = "*.pb.go"
+
+[store]
+backend = "sqlite"
+sqlite_path = "./team.db"
+
+[embedder]
+backend = "ollama"
+model = "mxbai-embed-large"
+requests_per_minute = 300
+
+[chunking]
+max_tokens = 800
+
+[languages.markdown]
+max_tokens = 1024
+token_overlap = 128
+
+[languages.go]
+code_normalization = "identifiers"
+
+[settings]
+hybrid_lexical_weight = 0.4
+'''
+
+YAML = '''store:
+  backend: qdrant
+  qdrant_url: http://qdrant:6333
+chunking:
+  granularity: file
+languages:
+  python:
+    granularity: symbol
+'''
+
+
+class SavedConfig:
+    """Restores every CONFIG setting on exit"""
+    
+    def __enter__(self):
+        self.saved = copy.deepcopy(CONFIG.__dict__)
+        return CONFIG
+    
+    def __exit__(self, *exc):
+        CONFIG.__dict__.clear()
+        CONFIG.__dict__.update(self.saved)
+
+
+def run_cli(argv, environ=None):
+    """cli.main with argv and extra environment variables; returns (exit code, parsed args of the command)"""
+    seen = {}
+    commands = {name: getattr(cli, name) for name in ('cmd_config', 'cmd_search')}
+    
+    def capture(args):
+        seen['args'] = args
+        return 0
+    
+    saved_argv, saved_env = sys.argv, dict(os.environ)
+    sys.argv = ['cli.py'] + argv
+    os.environ.update(environ or {})
+    cli.cmd_search = capture
+    try:
+        return cli.main(), seen.get('args')
+    finally:
+        sys.argv = saved_argv
+        os.environ.clear()
+        os.environ.update(saved_env)
+        for name, command in commands.items():
+            setattr(cli, name, command)
+
+
+def test_toml(workdir):
+    """A TOML file maps its sections onto CONFIG settings"""
+    path = workdir / 'code-rag.toml'
+    path.write_text(TOML)
+    with SavedConfig() as config:
+        config.language_chunking = {'markdown': {'granularity': 'section'}}
+        settings, problems = file_settings(read_config_file(path), config)
+        assert problems == [], problems
+        apply_settings(config, settings)
+        assert config.index_roots == ['src', 'proto=../proto'] and config.ignore_patterns == ['*.pb.go']
+        assert (config.vector_store, config.sqlite_path) == ('sqlite', './team.db')
+        assert (config.embedding_backend, config.ollama_model) == ('ollama', 'mxbai-embed-large')
+        assert config.embedding_requests_per_minute == 300 and config.max_tokens == 800
+        assert config.language_chunking == {
+            'markdown': {'granularity': 'section', 'max_tokens': 1024, 'token_overlap': 128}}
+        assert config.language_code_normalization['go'] == 'identifiers'
+        assert config.hybrid_lexical_weight == 0.4
+    print("✅ TOML config applied")
+
+
+def test_yaml_and_discovery(workdir):
+    """YAML files work too; the file is found by name, $CODE_RAG_CONFIG or in the working directory"""
+    path = workdir / 'team.yaml'
+    path.write_text(YAML)
+    with SavedConfig() as config:
+        settings, problems = file_settings(read_config_file(path), config)
+        assert problems == [], problems
+        assert settings['vector_store'] == 'qdrant' and settings['granularity'] == 'file'
+        assert settings['language_chunking'] == {'python': {'granularity': 'symbol'}}
+    
+    assert find_config_file(str(path), environ={}) == path
+    assert find_config_file(None, environ={'CODE_RAG_CONFIG': str(path)}) == path
+    cwd = os.getcwd()
+    os.chdir(workdir)
+    try:
+        assert find_config_file(None, environ={}) == Path('code-rag.toml')
+    finally:
+        os.chdir(cwd)
+    try:
+        find_config_file(str(workdir / 'missing.toml'), environ={})
+        raise AssertionError("a missing config file was accepted")
+    except ConfigFileError as e:
+        assert 'missing.toml' in str(e)
+    print("✅ YAML config and file discovery")
+
+
+def test_problems(workdir):
+    """Unknown keys, wrong types and malformed files are reported, not applied"""
+    data = {'store': {'backend': 'sqlite', 'batch_size': 'big'}, 'chunking': {'max_token': 10},
+            'languages': {'go': {'granularity': 'file', 'colour': 'red'}}, 'embeder': {}, 'settings': {'nope': 1}}
+    with SavedConfig() as config:
+        settings, problems = file_settings(data, config)
+        assert settings == {'vector_store': 'sqlite', 'language_chunking': {'go': {'granularity': 'file'}}}, settings
+        assert problems == [
+            "store.batch_size: expected an integer, got 'big'",
+            "chunking.max_token: unknown key (expected one of: max_tokens, token_overlap, overlap_strategy, granularity, "
+            "min_chunk_lines, min_chunk_tokens, exclude_symbols, code_normalization, dedup, max_file_bytes)",
+            "languages.go.colour: unknown key (expected one of: max_tokens, token_overlap, overlap_strategy, granularity, "
+            "min_chunk_lines, min_chunk_tokens, code_normalization, tokenizer_rules)",
+            "embeder: unknown key or section",
+            "settings.nope: unknown setting",
+        ], problems
+    
+    broken = workdir / 'broken.toml'
+    broken.write_text('[store\nbackend = 1')
+    try:
+        read_config_file(broken)
+        raise AssertionError("a malformed file was read")
+    except ConfigFileError as e:
+        assert 'broken.toml' in str(e)
+    print("✅ Config problems reported")
+
+
+def test_environment():
+    """CODE_RAG_* variables are read by the type of the setting they name"""
+    environ = {'CODE_RAG_MAX_TOKENS': '256', 'CODE_RAG_NORMALIZE_EMBEDDINGS': 'yes',
+               'CODE_RAG_HYBRID_LEXICAL_WEIGHT': '0.7', 'CODE_RAG_GO_BUILD_TAGS': 'cgo, integration',
+               'CODE_RAG_LANGUAGE_CHUNKING': '{"go": {"max_tokens": 64}}', 'CODE_RAG_CANDIDATE_K': '40',
+               'CODE_RAG_QDRANT_API_KEY': 'secret', 'CODE_RAG_BATCH_SIZE': 'many', 'CODE_RAG_HOME': '/opt/rag',
+               'CODE_RAG_CONFIG': 'team.toml'}
+    with SavedConfig() as config:
+        settings, problems = env_settings(config, environ)
+        assert settings == {'max_tokens': 256, 'normalize_embeddings': True, 'hybrid_lexical_weight': 0.7,
+                            'go_build_tags': ['cgo', 'integration'], 'language_chunking': {'go': {'max_tokens': 64}},
+                            'candidate_k': 40, 'qdrant_api_key': 'secret'}, settings
+        assert problems == ["CODE_RAG_BATCH_SIZE: expected an integer, got 'many'"], problems
+    print("✅ Environment settings read")
+
+
+def test_layering(workdir):
+    """Options override the config file; environment variables override both"""
+    path = workdir / 'layers.toml'
+    path.write_text('roots = ["src"]\n[store]\nbackend = "sqlite"\nsqlite_path = "./file.db"\n'
+                    '[settings]\nhybrid_lexical_weight = 0.2\n')
+    with SavedConfig():
+        code, args = run_cli(['--config', str(path), 'search', '--query', 'x'])
+        assert code == 0 and (args.store, args.sqlite_path, args.lexical_weight) == ('sqlite', './file.db', 0.2)
+    with SavedConfig():
+        _, args = run_cli(['--config', str(path), '--sqlite-path', './flag.db', 'search', '--query', 'x',
+                           '--lexical-weight', '0.9'])
+        assert (args.sqlite_path, args.lexical_weight) == ('./flag.db', 0.9), (args.sqlite_path, args.lexical_weight)
+    with SavedConfig():
+        _, args = run_cli(['--config', str(path), '--sqlite-path', './flag.db', 'search', '--query', 'x'],
+                          {'CODE_RAG_SQLITE_PATH': './env.db', 'CODE_RAG_HYBRID_LEXICAL_WEIGHT': '1.0'})
+        assert (args.sqlite_path, args.lexical_weight) == ('./env.db', 1.0), (args.sqlite_path, args.lexical_weight)
+    print("✅ Options and environment layered over the file")
+
+
+def test_validate(workdir):
+    """config validate reports bad settings and missing roots"""
+    (workdir / 'src').mkdir(exist_ok=True)
+    good = workdir / 'good.toml'
+    good.write_text('roots = ["%s"]\n[embedder]\nbackend = "none"\n' % (workdir / 'src'))
+    bad = workdir / 'bad.toml'
+    bad.write_text('roots = ["%s"]\n[store]\nbackend = "mongo"\n[chunking]\nmax_tokens = 100\n'
+                   'token_overlap = 100\n[languages.go]\ngranularity = "chapter"\n' % (workdir / 'nowhere'))
+    with SavedConfig():
+        code, _ = run_cli(['--config', str(good), 'config', 'validate', '--offline'])
+        assert code == 0, code
+    with SavedConfig():
+        assert cli.setting_problems() == []
+        code, _ = run_cli(['--config', str(bad), 'config', 'validate', '--offline'])
+        assert code == 1, code
+        problems = cli.setting_problems()
+        assert problems[0].startswith("vector_store: 'mongo' is not one of: chroma")
+        assert any(p.startswith("languages.go.granularity: 'chapter'") for p in problems), problems
+        assert "token_overlap (100) must be smaller than max_tokens (100)" in problems, problems
+        assert problems[-1] == f"roots: {workdir / 'nowhere'} is not a directory", problems
+    print("✅ config validate")
+
+
+def main():
+    print("=" * 70)
+    print("CONFIG FILE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="config_file_"))
+    tests = [lambda: test_toml(workdir), lambda: test_yaml_and_discovery(workdir), lambda: test_problems(workdir),
+             test_environment, lambda: test_layering(workdir), lambda: test_validate(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,84 @@
+#!/usr/bin/env python3
+"""
+Test script for packing retrieved chunks into a token budget
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.token_splitter import get_token_counter
+from config import CONFIG
+from utils.context_packer import pack_context
+
+
+def result(name, filepath, line_start, body_lines, score):
+    content = f"func {name}() {{\n" + "\n".join(f"    step{i}()" for i in range(body_lines)) + "\n}"
+    return {
+        'id': f"{filepath}:{name}",
+        'content': content,
+        'rrf_score': score,
+        'metadata': {'name': name, 'type': 'function', 'language': 'go', 'filepath': filepath,
+                     'line_start': line_start, 'line_end': line_start + body_lines + 1},
+    }
+
+
+RESULTS = [
+    result('small', 'a.go', 1, 2, 0.02),
+    result('huge', 'b.go', 1, 400, 0.03),
+    result('medium', 'a.go', 20, 10, 0.01),
+]
+
+
+def test_budget_and_order():
+    packed, included = pack_context(RESULTS, 250)
+    counter = get_token_counter(CONFIG.tokenizer_encoding)
+    assert counter.count(packed) <= 250
+    assert [r['metadata']['name'] for r in included] == ['small', 'medium']
+    assert '### a.go' in packed and '// small (function, lines 1-4)' in packed
+    print("✅ Best-scoring chunks that fit are packed, with path and symbol headers")
+
+
+def test_skip_not_truncate():
+    packed, included = pack_context(RESULTS, 250)
+    assert 'huge' not in packed
+    assert all(r['content'].rstrip() in packed for r in included)
+    print("✅ Overflowing chunk skipped whole, smaller ones still packed")
+
+
+def test_merge_files():
+    packed, included = pack_context(RESULTS, 250, merge_files=True)
+    assert packed.count('### a.go') == 1
+    assert [r['metadata']['name'] for r in included] == ['small', 'medium']
+    print("✅ Chunks from the same file combined into one block")
+
+
+def test_empty_budget():
+    assert pack_context(RESULTS, 5) == ("", [])
+    print("✅ Nothing packed when nothing fits")
+
+
+def main():
+    print("=" * 70)
+    print("CONTEXT PACKER TEST")
+    print("=" * 70)
+    
+    tests = [test_budget_and_order, test_skip_not_truncate, test_merge_files, test_empty_budget]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
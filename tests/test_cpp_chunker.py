@@ -0,0 +1,265 @@
+#!/usr/bin/env python3
+"""
+Test script for the C/C++ chunker
+Members attached to their class, templates, macros, the live branch of #if
+chains, and header declarations linked to their definitions
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import CppChunker, link_cpp_declarations
+
+
+HEADER = '''// Copyright 2024 The Authors
+
+#ifndef UI_WIDGET_H_
+#define UI_WIDGET_H_
+
+#define WIDGET_MAX_CHILDREN 64
+#define WIDGET_CHECK(cond) \\
+    do { if (!(cond)) abort(); } while (0)
+
+namespace ui {
+
+class Widget : public View, private Observer<Widget> {
+ public:
+  // Creates a widget.
+  explicit Widget(Widget* parent = nullptr);
+  ~Widget() override;
+  
+  bool operator==(const Widget& other) const;
+  
+  template <typename T>
+  T* FindChild(const std::string& name) const {
+    return static_cast<T*>(Lookup(name));
+  }
+  
+  DISALLOW_COPY_AND_ASSIGN(Widget);
+ 
+ private:
+  struct Geometry {
+    int x = 0;
+    int y = 0;
+  };
+  
+  bool valid_ = true;
+  void (*callback_)(int);
+#if defined(OS_WIN)
+  HWND hwnd_;
+#else
+  int fd_;
+#endif
+};
+
+}  // namespace ui
+
+#endif  // UI_WIDGET_H_
+'''
+
+SOURCE = '''#include "ui/widget.h"
+
+namespace ui {
+
+Widget::Widget(Widget* parent)
+    : View(parent), callback_(nullptr) {
+  Init();
+}
+
+Widget::~Widget() = default;
+
+bool Widget::operator==(const Widget& other) const {
+  return this == &other;
+}
+
+}  // namespace ui
+'''
+
+C_SOURCE = '''#include <stdlib.h>
+
+typedef struct {
+    int *items;
+    size_t len;
+} vec_t;
+
+/* Allocates an empty vector. */
+vec_t *vec_new(void)
+{
+    return calloc(1, sizeof(vec_t));
+}
+
+#ifdef _WIN32
+void vec_free(vec_t *v) {
+    HeapFree(GetProcessHeap(), 0, v);
+}
+#else
+void vec_free(vec_t *v) {
+    free(v);
+}
+#endif
+
+#if 0
+int dead_code(void) { return 0; }
+#elif defined(VEC_DEBUG)
+int vec_check(vec_t *v) { return v != NULL; }
+#endif
+
+int vec_push(vec_t *v, int value);
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_classes_and_members():
+    """Members are attached to their class; templates and operators are kept"""
+    chunks = CppChunker().extract_chunks(HEADER, 'ui/widget.h')
+    
+    widget = by_name(chunks, 'Widget', 'class')
+    assert widget.namespace == 'ui'
+    assert widget.metadata['base_classes'] == ['public View', 'private Observer<Widget>']
+    assert widget.metadata['methods'] == ['Widget', '~Widget', 'operator==', 'FindChild'], widget.metadata['methods']
+    assert widget.metadata['fields'] == ['valid_', 'callback_', 'hwnd_'], widget.metadata['fields']
+    
+    constructor = by_name(chunks, 'Widget', 'method')
+    assert constructor.parent_class == 'Widget' and constructor.metadata['constructor']
+    assert constructor.doc == 'Creates a widget.'
+    assert constructor.metadata['params'] == [{'name': 'parent', 'type': 'Widget*', 'default': 'nullptr'}]
+    assert constructor.metadata['definition'] is False and constructor.metadata['access'] == 'public'
+    
+    find = by_name(chunks, 'FindChild')
+    assert find.signature == 'template <typename T> T* FindChild(const std::string& name) const', find.signature
+    assert find.metadata['template'] == 'template <typename T>'
+    assert find.metadata['definition'] is True
+    
+    geometry = by_name(chunks, 'Geometry')
+    assert geometry.parent == 'Widget' and geometry.metadata['access'] == 'private'
+    assert geometry.metadata['fields'] == ['x', 'y']
+    print("✅ Classes, members, templates and operators extracted")
+
+
+def test_macros_and_preprocessor():
+    """#define macros are chunks; include guards and macro calls in class bodies are not"""
+    chunks = CppChunker().extract_chunks(HEADER, 'ui/widget.h')
+    names = [c.name for c in chunks]
+    assert 'UI_WIDGET_H_' not in names and 'DISALLOW_COPY_AND_ASSIGN' not in names
+    
+    check = by_name(chunks, 'WIDGET_CHECK')
+    assert check.type == 'macro' and check.metadata['params'] == ['cond']
+    assert (check.line_start, check.line_end) == (7, 8)
+    
+    # Line numbers come from the original source despite directives and macros
+    widget = by_name(chunks, 'Widget', 'class')
+    assert (widget.line_start, widget.line_end) == (12, 40), (widget.line_start, widget.line_end)
+    assert by_name(chunks, 'operator==').line_start == 18
+    assert 'conditions' not in widget.metadata, "the include guard is not a condition"
+    print("✅ Macros extracted, include guards and macro calls skipped")
+
+
+def test_out_of_line_definitions():
+    """Widget::Method definitions become methods of Widget"""
+    chunks = CppChunker().extract_chunks(SOURCE, 'ui/widget.cc')
+    
+    constructor = by_name(chunks, 'Widget')
+    assert constructor.type == 'method' and constructor.parent_class == 'Widget'
+    assert constructor.namespace == 'ui' and constructor.metadata['definition']
+    assert constructor.signature == 'Widget::Widget(Widget* parent)', constructor.signature
+    assert 'Init();' in constructor.content and constructor.line_end == 8
+    
+    assert by_name(chunks, '~Widget').metadata['defaulted']
+    assert by_name(chunks, 'operator==').metadata['returns'] == 'bool'
+    print("✅ Out-of-line member definitions attached to their class")
+
+
+def test_declarations_linked():
+    """Header declarations link to their definitions and back"""
+    chunks = (CppChunker().extract_chunks(HEADER, 'ui/widget.h')
+              + CppChunker().extract_chunks(SOURCE, 'ui/widget.cc'))
+    link_cpp_declarations(chunks)
+    
+    declared = [c for c in chunks if c.name == 'operator==' and c.filepath == 'ui/widget.h'][0]
+    defined = [c for c in chunks if c.name == 'operator==' and c.filepath == 'ui/widget.cc'][0]
+    assert declared.metadata['defined_at'] == {
+        'filepath': 'ui/widget.cc', 'line': defined.line_start, 'symbol_id': defined.default_symbol_id()
+    }
+    assert defined.metadata['declared_at']['filepath'] == 'ui/widget.h'
+    
+    destructor = [c for c in chunks if c.name == '~Widget' and c.filepath == 'ui/widget.h'][0]
+    assert destructor.metadata['defined_at']['line'] == 10
+    constructor = [c for c in chunks if c.name == 'Widget' and c.type == 'method' and c.filepath == 'ui/widget.h'][0]
+    assert constructor.metadata['defined_at']['line'] == 5, "a default argument does not change the parameter types"
+    print("✅ Declarations and definitions linked")
+
+
+def test_c_live_branches():
+    """C files: typedef'd structs, prototypes and only the live branch of each #if chain"""
+    chunks = CppChunker('c').extract_chunks(C_SOURCE, 'src/vec.c')
+    assert all(c.language == 'c' for c in chunks)
+    
+    vec = by_name(chunks, 'vec_t')
+    assert vec.type == 'struct' and vec.metadata['fields'] == ['items', 'len']
+    assert vec.line_start == 3 and vec.line_end == 6
+    
+    new = by_name(chunks, 'vec_new')
+    assert new.metadata['returns'] == 'vec_t *' and new.metadata['params'] == []
+    assert new.doc == 'Allocates an empty vector.'
+    
+    frees = [c for c in chunks if c.name == 'vec_free']
+    assert len(frees) == 1 and frees[0].metadata['conditions'] == ['defined(_WIN32)'], [c.metadata for c in frees]
+    assert frees[0].line_start == 15, "the first branch is the one kept"
+    assert 'dead_code' not in [c.name for c in chunks]
+    assert by_name(chunks, 'vec_check').metadata['conditions'] == ['defined(VEC_DEBUG)'], "#elif after #if 0 is live"
+    
+    push = by_name(chunks, 'vec_push')
+    assert push.metadata['definition'] is False and push.line_start == 30
+    assert push.metadata['params'] == [{'name': 'v', 'type': 'vec_t *'}, {'name': 'value', 'type': 'int'}]
+    print("✅ C parsed along the live branch of each preprocessor chain")
+
+
+def test_sample_file():
+    """The comprehensive sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'complex.cc'
+    chunks = CppChunker().extract_chunks(sample.read_text(), 'comprehensive/complex.cc')
+    
+    admin = by_name(chunks, 'AdminUser', 'class')
+    assert admin.namespace == 'authentication::complex'
+    assert admin.metadata['base_classes'] == ['public User', 'public Authenticator']
+    
+    manager = by_name(chunks, 'SessionManager', 'class')
+    assert manager.metadata['template'] == 'template<typename T>'
+    assert 'GetSession' in manager.metadata['methods']
+    assert by_name(chunks, 'StatusCode').metadata['enumerators'][0] == 'kSuccess'
+    assert by_name(chunks, 'StatusCode').metadata['scoped']
+    assert by_name(chunks, 'Authenticate', 'method').metadata['pure_virtual']
+    print("✅ Sample file parsed")
+
+
+def main():
+    print("=" * 70)
+    print("C/C++ CHUNKER TEST")
+    print("=" * 70)
+    
+    tests = [
+        test_classes_and_members, test_macros_and_preprocessor, test_out_of_line_definitions,
+        test_declarations_linked, test_c_live_branches, test_sample_file
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
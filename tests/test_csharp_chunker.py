@@ -0,0 +1,261 @@
+#!/usr/bin/env python3
+"""
+Test script for the C# chunker
+Sources are parsed by tree-sitter-c-sharp
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import CSharpChunker, link_csharp_partials
+from helpers import make_chroma_rag
+
+
+CONTROLLER = '''using System;
+using System.Collections.Generic;
+[assembly: InternalsVisibleTo("Tests")]
+
+namespace Shop.Api;
+
+/// <summary>
+/// Handles <see cref="T:Shop.Api.Order"/> requests.
+/// </summary>
+/// <typeparam name="T">The order type</typeparam>
+[ApiController, Route("api/[controller]")]
+[Obsolete("Use OrdersV2Controller")]
+public partial class OrdersController<T> : ControllerBase, IDisposable where T : class, new()
+{
+    private const int MaxItems = 50;
+    private readonly string _name = $"orders {{{MaxItems}}} {"}"}", _other;
+    public string Name { get; private set; } = "x";
+    public int Count => _items.Count;
+    public T this[int index] { get => _items[index]; set { _items[index] = value; } }
+    public event EventHandler Changed;
+    
+    /// <summary>Gets an order.</summary>
+    /// <param name="id">The <paramref name="id"/> to look up</param>
+    /// <returns>The order</returns>
+    /// <exception cref="KeyNotFoundException">When missing</exception>
+    [HttpGet("{id}")]
+    public async Task<ActionResult<T>> Get([FromRoute] int id, CancellationToken ct = default)
+    {
+        var s = @"verbatim "" } string";
+        return Ok(_items[id]);
+    }
+    
+    public static OrdersController<T> operator +(OrdersController<T> a, OrdersController<T> b) => a;
+    public static implicit operator string(OrdersController<T> c) => c.Name;
+    void IDisposable.Dispose() { }
+    ~OrdersController() { }
+    partial void OnCreated();
+    
+    public delegate void OrderHandler<TArg>(TArg arg) where TArg : struct;
+}
+
+public record Order(int Id, string Name) : Entity(Id);
+public readonly record struct Point(int X, int Y);
+internal struct Money { public decimal Amount; }
+public enum Level : byte { Low = 1, High }
+'''
+
+CONTROLLER_PART = '''namespace Shop.Api
+{
+    public partial class OrdersController<T>
+    {
+        /// <summary>Clears the cached orders.</summary>
+        public void Reset() { }
+        partial void OnCreated() { Reset(); }
+    }
+}
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_types():
+    """Classes, records, structs, enums and delegates with generics, constraints and attributes"""
+    chunks = CSharpChunker().extract_chunks(CONTROLLER, 'Api/OrdersController.cs')
+    assert all(c.namespace == 'Shop.Api' for c in chunks if c.type != 'namespace')
+    
+    controller = by_name(chunks, 'OrdersController', 'class')
+    assert controller.signature == ('public partial class OrdersController<T> : ControllerBase, IDisposable'
+                                    ' where T : class, new()'), controller.signature
+    assert controller.metadata['type_params'] == '<T>'
+    assert controller.metadata['constraints'] == {'T': ['class', 'new()']}
+    assert controller.metadata['bases'] == ['ControllerBase', 'IDisposable']
+    assert controller.metadata['attributes'] == ['ApiController', 'Route("api/[controller]")',
+                                                 'Obsolete("Use OrdersV2Controller")']
+    assert controller.metadata['deprecated'] == 'Use OrdersV2Controller'
+    assert controller.doc == 'Handles Order requests.\n\n@typeparam T The order type', controller.doc
+    assert (controller.line_start, controller.line_end) == (11, 40)
+    
+    order = by_name(chunks, 'Order')
+    assert order.type == 'record' and order.metadata['bases'] == ['Entity']
+    assert [p['name'] for p in order.metadata['params']] == ['Id', 'Name']
+    assert by_name(chunks, 'Point').metadata['record_struct']
+    assert by_name(chunks, 'Money').type == 'struct' and by_name(chunks, 'Amount').parent == 'Money'
+    level = by_name(chunks, 'Level')
+    assert level.metadata['constants'] == ['Low', 'High'] and level.metadata['underlying_type'] == 'byte'
+    
+    handler = by_name(chunks, 'OrderHandler')
+    assert handler.type == 'delegate' and handler.parent == 'OrdersController'
+    assert handler.signature == 'public delegate void OrderHandler<TArg>(TArg arg) where TArg : struct'
+    print("✅ Types extracted with generics, constraints and attributes")
+
+
+def test_members():
+    """Methods, constructors, operators, properties, indexers and fields"""
+    chunks = CSharpChunker().extract_chunks(CONTROLLER, 'Api/OrdersController.cs')
+    controller = by_name(chunks, 'OrdersController', 'class')
+    assert controller.metadata['methods'] == ['Get', 'operator +', 'operator string', 'Dispose',
+                                              '~OrdersController', 'OnCreated'], controller.metadata['methods']
+    assert controller.metadata['properties'] == ['Name', 'Count', 'this']
+    
+    get = by_name(chunks, 'Get')
+    assert get.qualified_name == 'OrdersController.Get' and get.metadata['returns'] == 'Task<ActionResult<T>>'
+    assert get.metadata['params'] == [{'name': 'id', 'type': 'int'}, {'name': 'ct', 'type': 'CancellationToken'}]
+    assert get.metadata['attributes'] == ['HttpGet("{id}")'] and get.metadata['modifiers'] == ['public', 'async']
+    assert get.doc == ('Gets an order.\n\n@param id The id to look up\n@returns The order\n'
+                       '@exception KeyNotFoundException When missing'), get.doc
+    assert (get.line_start, get.line_end) == (26, 31), "strings with braces do not end the body"
+    
+    assert by_name(chunks, 'Dispose').metadata['explicit_interface'] == 'IDisposable'
+    assert by_name(chunks, '~OrdersController').metadata['destructor']
+    assert by_name(chunks, 'operator +').metadata['returns'] == 'OrdersController<T>'
+    
+    name = by_name(chunks, 'Name')
+    assert name.signature == 'public string Name { get; private set; }'
+    assert name.metadata['accessors'] == ['get', 'private set']
+    assert by_name(chunks, 'Count').metadata['expression_bodied']
+    indexer = by_name(chunks, 'this')
+    assert indexer.metadata['params'] == [{'name': 'index', 'type': 'int'}] and indexer.metadata['property_type'] == 'T'
+    
+    assert by_name(chunks, 'MaxItems').type == 'constant'
+    assert by_name(chunks, '_name').metadata['names'] == ['_name', '_other']
+    assert by_name(chunks, 'Changed').metadata['modifiers'] == ['public', 'event']
+    print("✅ Methods, operators, properties, indexers and fields extracted")
+
+
+def test_namespaces():
+    """File-scoped and block namespaces list their members"""
+    chunks = CSharpChunker().extract_chunks(CONTROLLER, 'Api/OrdersController.cs')
+    namespace = by_name(chunks, 'Shop.Api', 'namespace')
+    assert namespace.metadata['file_scoped'] and namespace.line_start == 5
+    assert namespace.metadata['members'] == ['OrdersController', 'Order', 'Point', 'Money', 'Level']
+    
+    part = CSharpChunker().extract_chunks(CONTROLLER_PART, 'Api/OrdersController.Reset.cs')
+    block = by_name(part, 'Shop.Api', 'namespace')
+    assert 'file_scoped' not in block.metadata and (block.line_start, block.line_end) == (1, 9)
+    assert block.content == 'namespace Shop.Api\n{'
+    print("✅ Namespaces extracted")
+
+
+def test_partial_types():
+    """The parts of a partial class resolve to one logical type"""
+    chunks = (CSharpChunker().extract_chunks(CONTROLLER, 'Api/OrdersController.cs')
+              + CSharpChunker().extract_chunks(CONTROLLER_PART, 'Api/OrdersController.Reset.cs'))
+    link_csharp_partials(chunks)
+    
+    parts = [c for c in chunks if c.name == 'OrdersController' and c.type == 'class']
+    main_part = next(c for c in parts if c.filepath == 'Api/OrdersController.cs')
+    reset_part = next(c for c in parts if c.filepath == 'Api/OrdersController.Reset.cs')
+    assert main_part.metadata['partial_parts'] == [{
+        'filepath': 'Api/OrdersController.Reset.cs', 'line': 3, 'symbol_id': reset_part.default_symbol_id()
+    }], main_part.metadata['partial_parts']
+    assert reset_part.metadata['partial_parts'][0]['filepath'] == 'Api/OrdersController.cs'
+    assert reset_part.metadata['methods'] == main_part.metadata['methods']
+    assert main_part.metadata['methods'][:2] == ['Reset', 'OnCreated'] and main_part.metadata['methods'].count('OnCreated') == 1
+    assert reset_part.metadata['properties'] == ['Name', 'Count', 'this']
+    
+    # The owner is the first part by path, whichever file holds the member
+    reset = by_name(chunks, 'Reset')
+    get = by_name(chunks, 'Get')
+    assert reset.metadata['owner'] == get.metadata['owner'] == {
+        'filepath': 'Api/OrdersController.Reset.cs', 'line': 3, 'symbol_id': reset_part.default_symbol_id()
+    }
+    assert 'owner' not in by_name(chunks, 'Amount').metadata, "types that are not partial are left alone"
+    print("✅ Partial class parts linked across files")
+
+
+def test_truncated_attributes():
+    """An attribute bracket left unclosed in a parameter list keeps the file's other chunks"""
+    chunks = CSharpChunker().extract_chunks("class A {\n    void M([FromBody\n", 'A.cs')
+    assert by_name(chunks, 'A', 'class'), [c.name for c in chunks]
+    
+    source = "class B {\n    public void Ok(int y) { }\n    public void Run(int x, [Attr\n"
+    chunks = CSharpChunker().extract_chunks(source, 'B.cs')
+    assert by_name(chunks, 'Ok').metadata['params'] == [{'name': 'y', 'type': 'int'}]
+    assert by_name(chunks, 'Run').metadata['params'] == [{'name': 'x', 'type': 'int'}], \
+        "the unclosed attribute ends the parameter it starts"
+    print("✅ Truncated parameter attributes do not lose the file")
+
+
+def test_sample_file():
+    """The comprehensive sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'Complex.cs'
+    chunks = CSharpChunker().extract_chunks(sample.read_text(), 'comprehensive/Complex.cs')
+    
+    assert by_name(chunks, 'IAuthenticator').doc == 'Authenticator interface'
+    assert by_name(chunks, 'Authenticate', 'method').metadata['abstract']
+    admin = by_name(chunks, 'AdminUser', 'method')
+    assert admin.metadata['constructor'] and admin.signature == 'public AdminUser(string username, int id) : base(username, id)'
+    assert by_name(chunks, 'SessionManager', 'class').metadata['constraints'] == {'T': ['User']}
+    assert by_name(chunks, 'GetMessage').metadata['receiver'] == 'StatusCode'
+    
+    session = by_name(chunks, 'AuthenticateAndCreateSession')
+    assert session.metadata['returns'] == '(bool success, string sessionId)', session.metadata
+    assert session.metadata['constraints'] == {'T': ['User', 'IAuthenticator']}
+    assert session.signature.endswith('where T : User, IAuthenticator')
+    print("✅ Sample file parsed")
+
+
+def test_search(workdir):
+    """Members of either part are found under the type"""
+    rag = make_chroma_rag(workdir / "db", "test_csharp")
+    chunks = (CSharpChunker().extract_chunks(CONTROLLER, 'Api/OrdersController.cs')
+              + CSharpChunker().extract_chunks(CONTROLLER_PART, 'Api/OrdersController.Reset.cs'))
+    rag.add_chunks_batch(link_csharp_partials(chunks))
+    rag._build_keyword_index()
+    
+    results = rag.retrieve_context("orders", n_results=30, filter_expr="name=OrdersController.* AND kind=method")
+    members = sorted(r['metadata']['name'] for r in results)
+    assert 'Reset' in members and 'Get' in members, members
+    assert {r['metadata']['filepath'] for r in results} == {'Api/OrdersController.cs', 'Api/OrdersController.Reset.cs'}
+    print("✅ Members of partial classes found by their type")
+
+
+def main():
+    print("=" * 70)
+    print("C# CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_csharp_"))
+    
+    tests = [
+        test_types, test_members, test_namespaces, test_partial_types, test_truncated_attributes, test_sample_file,
+        lambda: test_search(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
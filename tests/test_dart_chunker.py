@@ -0,0 +1,394 @@
+#!/usr/bin/env python3
+"""
+Test script for the Dart chunker
+Sources are parsed by tree-sitter-dart
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import DartChunker, split_oversized_chunks
+from chunkers.token_splitter import stitch_parts
+from helpers import make_chroma_rag
+
+
+WIDGETS = '''library counter;
+
+import 'package:flutter/material.dart';
+import 'src/theme.dart' as theme show accent;
+part 'counter.g.dart';
+
+/// Starts the app.
+void main() => runApp(const CounterApp());
+
+/// A counter that counts taps.
+///
+/// Shows the [title] above the count.
+class Counter extends StatefulWidget {
+  const Counter({super.key, required this.title, this.start = 0});
+  
+  final String title;
+  final int start;
+  static const routeName = '/counter';
+  
+  @override
+  State<Counter> createState() => _CounterState();
+}
+
+class _CounterState extends State<Counter> with TickerProviderStateMixin {
+  late int _count = widget.start;
+  
+  void _increment() {
+    setState(() { _count++; });
+  }
+  
+  @override
+  Widget build(BuildContext context) {
+    final label = '${widget.title}: ${_count > 0 ? '}$_count' : "none"}';
+    return Scaffold(
+      appBar: AppBar(title: Text(label)),
+      floatingActionButton: FloatingActionButton(onPressed: _increment),
+    );
+  }
+}
+
+class CounterApp extends StatelessWidget {
+  const CounterApp({Key? key}) : super(key: key);
+  
+  @override
+  Widget build(BuildContext context) => const MaterialApp(home: Counter(title: 'Taps'));
+}
+'''
+
+MODEL = '''/* Geometry helpers /* nested */ still a comment */
+
+typedef Json = Map<String, dynamic>;
+typedef int Compare(Object a, Object b);
+
+const double epsilon = 1e-9;
+final registry = <String, Shape>{};
+
+/** A point in the plane. */
+@immutable
+class Point implements Comparable<Point> {
+  final double x, y;
+  
+  const Point(this.x, this.y);
+  
+  /// The origin.
+  const Point.origin() : x = 0, y = 0;
+  
+  Point.fromJson(Json json)
+      : x = json['x'] as double,
+        y = json['y'] as double {
+    assert(x.isFinite);
+  }
+  
+  factory Point.parse(String text) {
+    final parts = text.split(',');
+    return Point(double.parse(parts[0]), double.parse(parts[1]));
+  }
+  
+  double get length => sqrt(x * x + y * y);
+  
+  set scale(double factor) {}
+  
+  Point operator +(Point other) => Point(x + other.x, y + other.y);
+  
+  bool operator ==(Object other) => other is Point && other.x == x && other.y == y;
+  
+  @Deprecated('Use distanceTo instead')
+  double distance(Point other, [double? cap]) => (this - other).length;
+  
+  T fold<T>(T initial, T Function(T, double) combine) => combine(combine(initial, x), y);
+  
+  @override
+  int compareTo(Point other) => length.compareTo(other.length);
+}
+
+abstract interface class Shape {
+  double area();
+  String get label;
+}
+
+sealed class Result<T> {}
+
+final class Circle extends Shape with Named, Described implements Drawable {
+  Circle(this.radius);
+  final double radius;
+  @override
+  double area() => 3.14 * radius * radius;
+}
+
+class NamedCircle = Circle with Named;
+
+mixin Named on Shape {
+  String get name => runtimeType.toString();
+}
+
+base mixin Described {}
+
+extension StringX on String {
+  /// Title case.
+  String capitalize() => isEmpty ? this : this[0].toUpperCase() + substring(1);
+}
+
+extension<T> on List<T> {
+  T? get second => length > 1 ? this[1] : null;
+}
+
+extension type Meters(double value) implements double {
+  Meters operator +(Meters other) => Meters(value + other.value);
+}
+
+enum Color { red, green, blue }
+
+/// Planets and their masses.
+enum Planet implements Comparable<Planet> {
+  mercury(mass: 3.3e23),
+  earth(mass: 5.97e24);
+  
+  const Planet({required this.mass});
+  
+  final double mass;
+  
+  bool get isHeavy => mass > 1e24;
+  
+  @override
+  int compareTo(Planet other) => mass.compareTo(other.mass);
+}
+
+Future<List<Point>> loadPoints(String path, {int limit = 10}) async {
+  return [];
+}
+
+Stream<int> ticks() async* {
+  yield 1;
+}
+
+Iterable<int> naturals() sync* {
+  yield 0;
+}
+'''
+
+
+def by_name(chunks, name, type=None, parent=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type)
+                and (parent is None or c.parent == parent))
+
+
+def test_widgets():
+    """Widgets, their state classes and build methods; directives skipped"""
+    chunks = DartChunker().extract_chunks(WIDGETS, 'lib/counter.dart')
+    assert [(c.type, c.name) for c in chunks if c.parent is None] == [
+        ('function', 'main'), ('class', 'Counter'), ('class', '_CounterState'), ('class', 'CounterApp')
+    ], [(c.type, c.name) for c in chunks if c.parent is None]
+    assert all(c.namespace == 'counter' and c.metadata['library'] == 'counter' for c in chunks)
+    assert by_name(chunks, 'main').doc == 'Starts the app.'
+    
+    counter = by_name(chunks, 'Counter', 'class')
+    assert counter.metadata['widget'] and counter.metadata['extends'] == 'StatefulWidget'
+    assert counter.doc == 'A counter that counts taps.\n\nShows the [title] above the count.', counter.doc
+    assert counter.metadata['constructors'] == ['Counter'] and counter.metadata['methods'] == ['createState']
+    assert counter.metadata['fields'] == ['title', 'start', 'routeName']
+    assert counter.signature == 'class Counter extends StatefulWidget'
+    constructor = by_name(chunks, 'Counter', 'method')
+    assert constructor.metadata['params'] == [
+        {'name': 'key', 'named': True, 'super': True},
+        {'name': 'title', 'named': True, 'required': True, 'field': True},
+        {'name': 'start', 'named': True, 'default': '0', 'field': True},
+    ], constructor.metadata['params']
+    assert by_name(chunks, 'routeName').type == 'constant'
+    assert by_name(chunks, 'title').metadata['field_type'] == 'String'
+    
+    state = by_name(chunks, '_CounterState')
+    assert state.metadata['state_of'] == 'Counter' and 'widget' not in state.metadata
+    assert state.metadata['with'] == ['TickerProviderStateMixin']
+    build = by_name(chunks, 'build', parent='_CounterState')
+    assert build.type == 'method' and build.parent_class == '_CounterState'
+    assert build.metadata['annotations'] == ['@override'] and build.metadata['returns'] == 'Widget'
+    assert build.signature == 'Widget build(BuildContext context)', build.signature
+    assert (build.line_start, build.line_end) == (31, 38), (build.line_start, build.line_end)
+    assert build.qualified_name == '_CounterState.build'
+    
+    app = by_name(chunks, 'CounterApp', 'method')
+    assert app.signature == 'const CounterApp({Key? key}) : super(key: key)', app.signature
+    print("✅ Widgets, state classes and build methods extracted")
+
+
+def test_constructors():
+    """Unnamed, named, factory and const constructors, with initializer lists"""
+    chunks = DartChunker().extract_chunks(MODEL, 'lib/geometry.dart')
+    point = by_name(chunks, 'Point', 'class')
+    assert point.metadata['constructors'] == ['Point', 'Point.origin', 'Point.fromJson', 'Point.parse'], \
+        point.metadata.get('constructors')
+    assert point.metadata['implements'] == ['Comparable<Point>'] and point.metadata['annotations'] == ['@immutable']
+    assert point.doc == 'A point in the plane.'
+    assert point.metadata['fields'] == ['x'] and by_name(chunks, 'x').metadata['names'] == ['x', 'y']
+    
+    origin = by_name(chunks, 'origin')
+    assert origin.metadata['constructor_name'] == 'Point.origin' and origin.qualified_name == 'Point.origin'
+    assert origin.doc == 'The origin.' and origin.metadata['modifiers'] == ['const']
+    assert origin.signature == 'const Point.origin() : x = 0, y = 0', origin.signature
+    from_json = by_name(chunks, 'fromJson')
+    assert from_json.metadata['params'] == [{'name': 'json', 'type': 'Json'}]
+    assert (from_json.line_start, from_json.line_end) == (19, 23), (from_json.line_start, from_json.line_end)
+    parse = by_name(chunks, 'parse')
+    assert parse.metadata['factory'] and parse.content.rstrip().endswith('}')
+    print("✅ Unnamed, named and factory constructors extracted")
+
+
+def test_members():
+    """Getters, setters, operators, generic and deprecated methods"""
+    chunks = DartChunker().extract_chunks(MODEL, 'lib/geometry.dart')
+    point = by_name(chunks, 'Point', 'class')
+    assert point.metadata['methods'] == ['length', 'scale', 'operator +', 'operator ==', 'distance', 'fold',
+                                         'compareTo'], point.metadata['methods']
+    length = by_name(chunks, 'length')
+    assert length.metadata['accessor'] == 'get' and length.metadata['returns'] == 'double'
+    assert by_name(chunks, 'scale').metadata['accessor'] == 'set'
+    plus = by_name(chunks, 'operator +', parent='Point')
+    assert plus.metadata['params'] == [{'name': 'other', 'type': 'Point'}] and plus.metadata['returns'] == 'Point'
+    
+    distance = by_name(chunks, 'distance')
+    assert distance.metadata['deprecated'] == 'Use distanceTo instead'
+    assert distance.metadata['params'][1] == {'name': 'cap', 'type': 'double?', 'optional': True}
+    fold = by_name(chunks, 'fold')
+    assert fold.metadata['type_params'] == '<T>' and fold.metadata['returns'] == 'T'
+    assert fold.metadata['params'][1] == {'name': 'combine', 'type': 'T Function(T, double)'}
+    
+    shape = by_name(chunks, 'Shape')
+    assert shape.metadata['modifiers'] == ['abstract', 'interface']
+    assert by_name(chunks, 'area', parent='Shape').metadata['abstract']
+    assert by_name(chunks, 'label').metadata == {'accessor': 'get', 'returns': 'String', 'abstract': True}
+    print("✅ Getters, setters, operators and generic methods extracted")
+
+
+def test_types():
+    """Class modifiers, mixins, extensions, extension types, enums and typedefs"""
+    chunks = DartChunker().extract_chunks(MODEL, 'lib/geometry.dart')
+    assert by_name(chunks, 'Result').metadata == {'type_params': '<T>', 'modifiers': ['sealed'],
+                                                  'methods': []}
+    circle = by_name(chunks, 'Circle', 'class')
+    assert circle.metadata['modifiers'] == ['final'] and circle.metadata['with'] == ['Named', 'Described']
+    assert circle.metadata['implements'] == ['Drawable'] and circle.metadata['extends'] == 'Shape'
+    named_circle = by_name(chunks, 'NamedCircle')
+    assert named_circle.metadata['mixin_application'] and named_circle.metadata['extends'] == 'Circle'
+    assert named_circle.metadata['with'] == ['Named']
+    
+    named = by_name(chunks, 'Named', 'mixin')
+    assert named.metadata['on'] == ['Shape'] and by_name(chunks, 'name').parent == 'Named'
+    assert by_name(chunks, 'Described').metadata['modifiers'] == ['base']
+    
+    string_x = by_name(chunks, 'StringX')
+    assert string_x.type == 'extension' and string_x.metadata['on'] == 'String'
+    capitalize = by_name(chunks, 'capitalize')
+    assert capitalize.qualified_name == 'StringX.capitalize' and capitalize.doc == 'Title case.'
+    unnamed = next(c for c in chunks if c.type == 'extension' and c.metadata.get('unnamed'))
+    assert unnamed.name == 'List' and unnamed.metadata['on'] == 'List<T>' and unnamed.metadata['type_params'] == '<T>'
+    assert by_name(chunks, 'second').parent == 'List'
+    
+    meters = by_name(chunks, 'Meters', 'type')
+    assert meters.metadata['extension_type'] and meters.metadata['representation'] == {'name': 'value', 'type': 'double'}
+    assert meters.metadata['methods'] == ['operator +']
+    
+    assert by_name(chunks, 'Color').metadata['constants'] == ['red', 'green', 'blue']
+    planet = by_name(chunks, 'Planet', 'enum')
+    assert planet.metadata['constants'] == ['mercury', 'earth'] and planet.doc == 'Planets and their masses.'
+    assert planet.metadata['methods'] == ['isHeavy', 'compareTo'] and planet.metadata['constructors'] == ['Planet']
+    
+    assert by_name(chunks, 'Json').metadata['aliased'] == 'Map<String, dynamic>'
+    assert by_name(chunks, 'Compare').type == 'alias'
+    assert by_name(chunks, 'epsilon').type == 'constant' and by_name(chunks, 'registry').type == 'variable'
+    print("✅ Mixins, extensions, extension types, enums and typedefs extracted")
+
+
+def test_functions():
+    """Top-level functions with named parameters and async markers"""
+    chunks = DartChunker().extract_chunks(MODEL, 'lib/geometry.dart')
+    load = by_name(chunks, 'loadPoints')
+    assert load.type == 'function' and load.parent is None and load.metadata['async'] == 'async'
+    assert load.metadata['returns'] == 'Future<List<Point>>'
+    assert load.metadata['params'] == [{'name': 'path', 'type': 'String'},
+                                       {'name': 'limit', 'type': 'int', 'named': True, 'default': '10'}]
+    assert by_name(chunks, 'ticks').metadata['async'] == 'async*'
+    assert by_name(chunks, 'naturals').metadata['async'] == 'sync*'
+    print("✅ Top-level functions and async markers extracted")
+
+
+def test_large_build_method():
+    """A large build method splits into parts that stitch back into the method"""
+    children = '\n'.join(f"        Text('Row number {n} of the long list'),"
+                         for n in range(200))
+    code = f'''class LongList extends StatelessWidget {{
+  @override
+  Widget build(BuildContext context) {{
+    return Column(
+      children: [
+{children}
+      ],
+    );
+  }}
+}}
+'''
+    chunks = DartChunker().extract_chunks(code, 'lib/long_list.dart')
+    build = by_name(chunks, 'build')
+    assert build.line_start == 2 and build.content.rstrip().endswith('}')
+    parts = [p for p in split_oversized_chunks(chunks, 400) if p.name == 'build']
+    assert len(parts) > 2, len(parts)
+    assert all(p.parent_class == 'LongList' and p.part_count == len(parts) for p in parts)
+    assert parts[1].content.startswith(build.signature), parts[1].content[:60]
+    stitched = stitch_parts([{'content': p.content, 'metadata': dict(p.to_dict(), part_index=p.part_index)}
+                             for p in parts])
+    assert stitched == build.content
+    print(f"✅ A large build method splits into {len(parts)} parts and stitches back")
+
+
+def test_owner_search(workdir):
+    """Methods are found through the class they belong to"""
+    rag = make_chroma_rag(workdir / "db", "test_dart")
+    rag.add_chunks_batch(DartChunker().extract_chunks(WIDGETS, 'lib/counter.dart')
+                         + DartChunker().extract_chunks(MODEL, 'lib/geometry.dart'))
+    rag._build_keyword_index()
+    
+    names = [r['metadata']['name'] for r in rag.retrieve_context("capitalize", n_results=3)]
+    assert names[0] == 'capitalize', names
+    builds = sorted(r['metadata']['parent'] for r in rag.retrieve_context(
+        "build widget", n_results=10, filter_expr="name=build"))
+    assert builds == ['CounterApp', '_CounterState'], builds
+    print("✅ Methods found by name and owner")
+
+
+def main():
+    print("=" * 70)
+    print("DART CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_dart_"))
+    
+    tests = [
+        test_widgets, test_constructors, test_members, test_types, test_functions,
+        test_large_build_method, lambda: test_owner_search(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
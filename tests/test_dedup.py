@@ -0,0 +1,174 @@
+#!/usr/bin/env python3
+"""
+Test script for chunk deduplication
+With dedup on, chunks with identical bodies (vendored copies) are embedded and
+stored once; the stored chunk lists every other place the body appears, and
+one of those takes over when the stored copy's file is removed.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from helpers import make_rag
+from utils.chunk_dedup import content_hash
+
+SOURCE = '''package retry
+
+// Backoff returns the delay before attempt n
+func Backoff(n int) time.Duration {
+    return time.Duration(n*n) * 100 * time.Millisecond
+}
+
+// Jitter spreads a delay to avoid retry storms
+func Jitter(d time.Duration) time.Duration {
+    return d + time.Duration(rand.Int63n(int64(d)))
+}
+'''
+
+REFORMATTED = '''package retry
+
+// Backoff returns the delay before attempt n
+func Backoff(n int) time.Duration {
+	// quadratic, in steps of 100ms
+	return time.Duration(n*n) * 100 *  time.Millisecond
+}
+'''
+
+VENDORED = ["vendor/a/retry/retry.go", "vendor/b/retry/retry.go", "third_party/retry/retry.go"]
+
+
+def index(rag, files):
+    for path, source in files:
+        rag.add_chunks_batch(GoChunker().extract_chunks(source, path))
+    rag._build_keyword_index()
+
+
+def stored_names(rag):
+    return sorted(m['name'] for m in rag.collection.get(include=['metadatas'])['metadatas'])
+
+
+def test_stored_once(workdir):
+    rag = make_rag(workdir, "exact", dedup='exact')
+    index(rag, [(path, SOURCE) for path in VENDORED])
+    assert stored_names(rag) == ['Backoff', 'Jitter'], stored_names(rag)
+    assert len(rag.embedder.texts) == 2, "duplicates are not embedded"
+    assert rag.chunks_deduplicated == 4
+    
+    plain = make_rag(workdir, "plain", dedup='off')
+    index(plain, [(path, SOURCE) for path in VENDORED])
+    assert len(stored_names(plain)) == 6 and len(plain.embedder.texts) == 6
+    print("✅ Identical chunks are embedded and stored once")
+
+
+def test_search_annotation(workdir):
+    rag = make_rag(workdir, "annotated", dedup='exact')
+    index(rag, [(path, SOURCE) for path in VENDORED])
+    results = rag.retrieve_context("Backoff delay before attempt", n_results=3)
+    backoff = [r for r in results if r['metadata']['name'] == 'Backoff']
+    assert len(backoff) == 1, "one result per body"
+    result = backoff[0]
+    assert result['metadata']['filepath'] == VENDORED[0]
+    assert result['duplicate_count'] == 2
+    assert [d['filepath'] for d in result['duplicates']] == VENDORED[1:]
+    assert result['duplicates'][0]['line_start'] == 4
+    print("✅ Search returns one result that lists where else the body appears")
+
+
+def test_removal_promotes(workdir):
+    rag = make_rag(workdir, "removal", dedup='exact')
+    index(rag, [(path, SOURCE) for path in VENDORED])
+    
+    assert rag.delete_file_chunks(VENDORED[0]) == 2
+    stored = rag.collection.get(where={'name': 'Backoff'}, include=['metadatas', 'embeddings'])
+    assert len(stored['ids']) == 1, "a duplicate takes over the removed copy"
+    metadata = stored['metadatas'][0]
+    assert metadata['filepath'] == VENDORED[1] and metadata['duplicate_count'] == 1
+    assert stored['ids'][0].startswith(VENDORED[1]), stored['ids'][0]
+    assert len(stored['embeddings'][0]) == rag.embedder.dimensions(), "the vector is kept"
+    
+    rag.delete_file_chunks(VENDORED[2])
+    metadata = rag.collection.get(where={'name': 'Backoff'}, include=['metadatas'])['metadatas'][0]
+    assert not metadata.get('duplicate_count'), "removed duplicates are dropped from the list"
+    
+    rag.delete_file_chunks(VENDORED[1])
+    assert stored_names(rag) == []
+    
+    # Re-adding a file replaces its own entries rather than piling them up
+    index(rag, [(path, SOURCE) for path in VENDORED[:2]])
+    index(rag, [(VENDORED[1], SOURCE)])
+    metadata = rag.collection.get(where={'name': 'Backoff'}, include=['metadatas'])['metadatas'][0]
+    assert metadata['duplicate_count'] == 1, metadata
+    print("✅ Removing the stored copy promotes one of its duplicates")
+
+
+def test_move(workdir):
+    rag = make_rag(workdir, "moved", dedup='exact')
+    index(rag, [(path, SOURCE) for path in VENDORED[:2]])
+    rag.move_file_chunks(VENDORED[1], "vendor/c/retry/retry.go")
+    result = next(r for r in rag.retrieve_context("Backoff", n_results=2) if r['metadata']['name'] == 'Backoff')
+    assert [d['filepath'] for d in result['duplicates']] == ["vendor/c/retry/retry.go"]
+    print("✅ Renaming a file re-points its duplicate entries")
+
+
+def test_normalized(workdir):
+    assert content_hash("a  b", "go", "exact") != content_hash("a b", "go", "exact")
+    assert content_hash("x := 1 // one", "go", "normalized") == content_hash("x := 1", "go", "normalized")
+    assert content_hash('s := "//"', "go", "normalized") != content_hash('s := "', "go", "normalized"), \
+        "comment markers inside strings are kept"
+    
+    exact = make_rag(workdir, "exact_formatting", dedup='exact')
+    index(exact, [(VENDORED[0], SOURCE), (VENDORED[1], REFORMATTED)])
+    assert stored_names(exact) == ['Backoff', 'Backoff', 'Jitter']
+    
+    normalized = make_rag(workdir, "normalized", dedup='normalized')
+    index(normalized, [(VENDORED[0], SOURCE), (VENDORED[1], REFORMATTED)])
+    assert stored_names(normalized) == ['Backoff', 'Jitter']
+    
+    # The stored copy's own text is listed for duplicates that differ from it
+    normalized.delete_file_chunks(VENDORED[0])
+    stored = normalized.collection.get(where={'name': 'Backoff'})
+    assert "quadratic" in stored['documents'][0], "the promoted duplicate keeps its own code"
+    
+    reopened = make_rag(workdir, "normalized")
+    assert reopened.dedup == 'normalized', "an index keeps the mode it was built with"
+    print("✅ Normalized dedup ignores whitespace and comment differences")
+
+
+def main():
+    print("=" * 70)
+    print("CHUNK DEDUPLICATION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="dedup_"))
+    
+    tests = [
+        lambda: test_stored_once(workdir),
+        lambda: test_search_annotation(workdir),
+        lambda: test_removal_promotes(workdir),
+        lambda: test_move(workdir),
+        lambda: test_normalized(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
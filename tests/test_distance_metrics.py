@@ -0,0 +1,156 @@
+#!/usr/bin/env python3
+"""
+Test script for distance metrics and vector normalization
+Checks the distances each metric gives, that normalized dot-product search ranks
+like cosine, and that the metric recorded with an index (and its snapshots) is
+enforced. Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from embedders import EmbeddingError
+from helpers import HashEmbedder
+from rag import ChromeRAGSystem
+from stores import QdrantStore, SqliteStore, StoreError, similarity
+from utils.index_snapshot import SnapshotError
+
+
+def sample_chunks():
+    return [
+        CodeChunk(type='function', name='ParseURL', content='func ParseURL(raw string) { parse url parse url scheme host }',
+                  filepath='net/url.go', language='go', line_start=1, line_end=10),
+        CodeChunk(type='function', name='Dial', content='func Dial(addr string) { dial tcp connection }',
+                  filepath='net/dial.go', language='go', line_start=1, line_end=10),
+        CodeChunk(type='function', name='Escape', content='func Escape(s string) { escape url query }',
+                  filepath='net/escape.go', language='go', line_start=1, line_end=10),
+    ]
+
+
+def new_rag(path, metric='cosine', **options):
+    return ChromeRAGSystem(collection_name='code', embedder=HashEmbedder(size=64, normalize=False),
+                           store=SqliteStore(path, 'code', metric=metric), **options)
+
+
+def ranking(rag, query):
+    return [r['metadata']['name'] for r in rag.retrieve_context(query, n_results=3, lexical_weight=0.0)]
+
+
+def test_store_distances(workdir):
+    vectors = {'a': [3.0, 4.0], 'b': [1.0, 0.0]}
+    expected = {
+        'cosine': {'a': 1.0 - 3.0 / 5.0, 'b': 0.0},
+        'dot': {'a': 1.0 - 3.0, 'b': 0.0},
+        'l2': {'a': (4.0 + 16.0) ** 0.5, 'b': 0.0},
+    }
+    for metric, distances in expected.items():
+        store = SqliteStore(str(workdir / f'{metric}.db'), 'vectors', metric=metric)
+        store.add(list(vectors), ['', ''], [{}, {}], list(vectors.values()))
+        found = store.query([[1.0, 0.0]], n_results=2)
+        got = dict(zip(found['ids'][0], found['distances'][0]))
+        assert all(abs(got[k] - v) < 1e-5 for k, v in distances.items()), (metric, got)
+        assert found['distances'][0] == sorted(found['distances'][0])
+    assert similarity(0.25, 'cosine') == 0.75 and similarity(1.0, 'l2') == 0.5
+    print("✅ Each metric gives smaller distances for closer vectors")
+
+
+def test_normalized_dot_ranks_like_cosine(workdir):
+    cosine = new_rag(str(workdir / 'cosine.db'))
+    dot = new_rag(str(workdir / 'dot.db'), metric='dot', normalize_embeddings=True)
+    for rag in (cosine, dot):
+        rag.add_chunks_batch(sample_chunks())
+    
+    stored = dot.collection.get(include=['embeddings'])['embeddings']
+    assert all(abs(sum(x * x for x in vector) - 1.0) < 1e-5 for vector in stored)
+    for query in ("parse url", "dial tcp", "escape query"):
+        assert ranking(dot, query) == ranking(cosine, query), query
+    
+    metadata = dot.collection.metadata
+    assert metadata['distance_metric'] == 'dot' and metadata['normalized_embeddings'] is True, metadata
+    print("✅ Normalized vectors searched by dot product rank like cosine")
+
+
+def test_metric_enforced(workdir):
+    path = str(workdir / 'enforced.db')
+    new_rag(path, metric='l2').add_chunks_batch(sample_chunks())
+    
+    reopened = new_rag(path, metric='l2')
+    assert reopened.validate_embedder() == 64
+    assert ranking(reopened, "dial tcp connection")[0] == 'Dial'
+    
+    for options in ({'metric': 'cosine'}, {'metric': 'l2', 'normalize_embeddings': True}):
+        try:
+            new_rag(path, **options).validate_embedder()
+            assert False, f"{options} should not open an l2, unnormalized index"
+        except EmbeddingError:
+            pass
+    print("✅ An index refuses another metric or normalization than it was built with")
+
+
+def test_snapshot_records_metric(workdir):
+    rag = new_rag(str(workdir / 'saved.db'), metric='dot', normalize_embeddings=True)
+    rag.add_chunks_batch(sample_chunks())
+    manifest = rag.save_index(str(workdir / 'snapshot'))
+    assert manifest['distance_metric'] == 'dot' and manifest['normalized_embeddings'] is True, manifest
+    
+    try:
+        new_rag(str(workdir / 'other.db'), metric='cosine').load_index(str(workdir / 'snapshot'))
+        assert False, "a dot-product snapshot should not load into a cosine store"
+    except SnapshotError:
+        pass
+    
+    restored = new_rag(str(workdir / 'restored.db'), metric='dot')
+    restored.load_index(str(workdir / 'snapshot'))
+    assert restored.normalize_embeddings, "the snapshot's normalization should be adopted"
+    assert ranking(restored, "parse url") == ranking(rag, "parse url")
+    print("✅ Snapshots carry the metric and are refused by stores searching by another")
+
+
+def test_backend_metrics(workdir):
+    try:
+        SqliteStore(str(workdir / 'bad.db'), 'code', metric='manhattan')
+        assert False, "sqlite has no manhattan scan"
+    except StoreError:
+        pass
+    assert QdrantStore(metric='l2').distance == 'Euclid'
+    assert QdrantStore(distance='Manhattan').metric == 'manhattan'
+    print("✅ Metrics are validated against what each backend can search by")
+
+
+def main():
+    print("=" * 70)
+    print("DISTANCE METRIC TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="metrics_"))
+    
+    tests = [
+        lambda: test_store_distances(workdir),
+        lambda: test_normalized_dot_ranks_like_cosine(workdir),
+        lambda: test_metric_enforced(workdir),
+        lambda: test_snapshot_records_metric(workdir),
+        lambda: test_backend_metrics(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
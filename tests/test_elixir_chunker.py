@@ -0,0 +1,268 @@
+#!/usr/bin/env python3
+"""
+Test script for the Elixir chunker
+Sources are parsed by tree-sitter-elixir
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import ElixirChunker
+from helpers import make_chroma_rag
+
+
+ACCOUNTS = '''defmodule MyApp.Accounts do
+  @moduledoc """
+  The Accounts context.
+  
+  Users, sessions and their tokens.
+  """
+  
+  use Boundary, deps: [MyApp.Repo]
+  import Ecto.Query, only: [from: 2]
+  alias MyApp.{Repo, Accounts.User}
+  alias MyApp.Accounts.Token, as: SessionToken
+  require Logger
+  
+  @max_attempts 5
+  
+  @doc """
+  Fetches a user by id or email.
+  
+  Returns `nil` when there is none.
+  """
+  @spec get_user(integer() | String.t()) :: User.t() | nil
+  def get_user(id) when is_integer(id), do: Repo.get(User, id)
+  
+  def get_user("" <> email) do
+    query = from u in User, where: u.email == ^email
+    Repo.one(query)
+  end
+  
+  @doc "Registers a user"
+  @spec register(map(), keyword()) :: {:ok, User.t()} | {:error, Ecto.Changeset.t()}
+  def register(attrs, opts \\\\ []) do
+    %User{}
+    |> User.changeset(attrs)
+    |> Repo.insert(opts)
+  end
+  
+  @doc false
+  def __schema__, do: "end"
+  
+  # Sends the welcome mail; "end" and ~r/end/ close nothing
+  defp notify(%User{email: email} = user) do
+    message = ~s(Welcome, #{user.name} - end)
+    pattern = ~r/end$/i
+    char = ?e
+    Logger.info("#{message} #{inspect(pattern)} #{char}")
+    Enum.each([email], fn address ->
+      send_mail(address, :end, end: true)
+    end)
+  end
+  
+  defp send_mail(_address, _atom, _opts), do: :ok
+  
+  defmacro with_user(id, do: block) do
+    quote do
+      user = get_user(unquote(id))
+      unquote(block)
+    end
+  end
+  
+  defguard is_admin(user) when is_map(user) and :erlang.map_get(:role, user) == :admin
+  
+  defdelegate token(user), to: SessionToken, as: :build
+  
+  defmodule User do
+    @moduledoc "A registered user"
+    @derive {Jason.Encoder, only: [:name, :email]}
+    defstruct [:name, :email, role: :member, tags: []]
+    
+    @type t :: %__MODULE__{name: String.t(), email: String.t()}
+  end
+end
+'''
+
+PROTOCOL = '''defprotocol Size do
+  @moduledoc false
+  @doc "Calculates the size of a data structure"
+  def size(data)
+end
+
+defimpl Size, for: BitString do
+  def size(string), do: byte_size(string)
+end
+
+defmodule MyApp.Worker do
+  @behaviour GenServer
+  use GenServer
+  
+  @callback handle(term()) :: :ok
+  
+  @impl true
+  def init(state), do: {:ok, state}
+  
+  @impl GenServer
+  def handle_call(:ping, _from, state), do: {:reply, :pong, state}
+  def handle_call({:get, key}, _from, state) do
+    {:reply, Map.get(state, key), state}
+  end
+  
+  def handle_call(:stop, from, state) do
+    GenServer.reply(from, :ok)
+    {:stop, :normal, state}
+  end
+  
+  @deprecated "Use init/1 instead"
+  def start(state), do: init(state)
+end
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_modules():
+    """Modules with their docs, directives, functions and structs"""
+    chunker = ElixirChunker()
+    chunks = chunker.extract_chunks(ACCOUNTS, 'lib/my_app/accounts.ex')
+    assert not chunker.diagnostics, chunker.diagnostics
+    
+    accounts = by_name(chunks, 'Accounts', 'module')
+    assert accounts.namespace == 'MyApp' and accounts.signature == 'defmodule MyApp.Accounts'
+    assert accounts.doc == 'The Accounts context.\n\nUsers, sessions and their tokens.', accounts.doc
+    assert (accounts.line_start, accounts.line_end) == (1, 71), "'end' in strings, sigils and atoms closes nothing"
+    metadata = accounts.metadata
+    assert metadata['uses'] == ['Boundary'] and metadata['imports'] == ['Ecto.Query']
+    assert metadata['aliases'] == ['MyApp.Repo', 'MyApp.Accounts.User', 'MyApp.Accounts.Token as SessionToken']
+    assert metadata['requires'] == ['Logger'] and metadata['attributes'] == ['max_attempts']
+    assert metadata['functions'] == ['get_user/1', 'register/2', '__schema__/0', 'notify/1', 'send_mail/3',
+                                     'token/1'], metadata['functions']
+    assert metadata['macros'] == ['with_user/2', 'is_admin/1'], metadata['macros']
+    
+    user = by_name(chunks, 'User', 'module')
+    assert user.namespace == 'MyApp.Accounts' and user.doc == 'A registered user'
+    assert user.metadata['struct'] == ['name', 'email', 'role', 'tags'], user.metadata
+    assert user.metadata['attributes'] == ['derive']
+    assert user.metadata['types'] == ['t/0'] and (user.line_start, user.line_end) == (64, 70)
+    print("✅ Modules extracted with their docs, directives and structs")
+
+
+def test_functions():
+    """Clauses grouped by name and arity, with specs, docs, guards and visibility"""
+    chunks = ElixirChunker().extract_chunks(ACCOUNTS, 'lib/my_app/accounts.ex')
+    
+    get_user = by_name(chunks, 'get_user')
+    assert get_user.type == 'function' and get_user.qualified_name == 'MyApp.Accounts.get_user'
+    assert get_user.metadata == {'arity': 1, 'clauses': 2, 'guards': ['is_integer(id)']}, get_user.metadata
+    assert get_user.signature == ('@spec get_user(integer() | String.t()) :: User.t() | nil\n'
+                                  'def get_user(id) when is_integer(id)'), get_user.signature
+    assert get_user.doc == 'Fetches a user by id or email.\n\nReturns `nil` when there is none.', get_user.doc
+    assert (get_user.line_start, get_user.line_end) == (22, 27)
+    
+    register = by_name(chunks, 'register')
+    assert register.metadata == {'arity': 2, 'min_arity': 1}, register.metadata
+    assert register.doc == 'Registers a user' and (register.line_start, register.line_end) == (31, 35)
+    assert register.signature.startswith('@spec register(map(), keyword()) ::')
+    
+    assert by_name(chunks, '__schema__').metadata == {'arity': 0, 'hidden': True}
+    notify = by_name(chunks, 'notify')
+    assert notify.metadata == {'arity': 1, 'visibility': 'private'} and (notify.line_start, notify.line_end) == (41, 49)
+    assert notify.doc == 'Sends the welcome mail; "end" and ~r/end/ close nothing'
+    
+    with_user = by_name(chunks, 'with_user')
+    assert with_user.type == 'macro' and (with_user.line_start, with_user.line_end) == (53, 58)
+    is_admin = by_name(chunks, 'is_admin')
+    assert is_admin.type == 'macro' and is_admin.metadata['defguard'] and is_admin.line_end == 60
+    assert by_name(chunks, 'send_mail').metadata == {'arity': 3, 'visibility': 'private'}
+    token = by_name(chunks, 'token')
+    assert token.metadata == {'arity': 1, 'delegate_to': 'SessionToken', 'delegate_as': ':build'}, token.metadata
+    print("✅ Function clauses grouped with their arity, specs, docs and visibility")
+
+
+def test_protocols_and_behaviours():
+    """Protocols, their implementations and behaviour callbacks"""
+    chunks = ElixirChunker().extract_chunks(PROTOCOL, 'lib/size.ex')
+    
+    size = by_name(chunks, 'Size', 'protocol')
+    assert size.metadata == {'functions': ['size/1'], 'hidden': True}, size.metadata
+    head = next(c for c in chunks if c.name == 'size' and c.parent == 'Size')
+    assert head.doc == 'Calculates the size of a data structure' and head.content == 'def size(data)'
+    
+    impl = by_name(chunks, 'BitString', 'module')
+    assert impl.namespace == 'Size' and impl.signature == 'defimpl Size, for: BitString'
+    assert impl.metadata['implements'] == ['Size'] and impl.metadata['for'] == ['BitString']
+    assert next(c for c in chunks if c.parent == 'Size.BitString').name == 'size'
+    
+    worker = by_name(chunks, 'Worker', 'module')
+    assert worker.metadata['behaviours'] == ['GenServer'] and worker.metadata['uses'] == ['GenServer']
+    assert worker.metadata['callbacks'] == ['handle/1']
+    assert by_name(chunks, 'init').metadata == {'arity': 1, 'impl': True}
+    handle_call = by_name(chunks, 'handle_call')
+    assert handle_call.metadata == {'arity': 3, 'clauses': 3, 'impl': 'GenServer'}, handle_call.metadata
+    assert (handle_call.line_start, handle_call.line_end) == (21, 29)
+    assert by_name(chunks, 'start').metadata['deprecated'] == 'Use init/1 instead'
+    
+    chunker = ElixirChunker()
+    chunks = chunker.extract_chunks("defmodule Open do\n  def run do\n    :ok\n", 'open.ex')
+    assert [c.name for c in chunks] == ['run', 'Open'], [c.name for c in chunks]
+    assert chunker.diagnostics == [{'kind': 'parse_error', 'line': 2, 'message': "missing 'end' for def run"},
+                                   {'kind': 'parse_error', 'line': 1, 'message': "missing 'end' for defmodule Open"}]
+    print("✅ Protocols, implementations and behaviour callbacks extracted")
+
+
+def test_search(workdir):
+    """Elixir modules are types to the kind filter, and implementations are found by protocol"""
+    rag = make_chroma_rag(workdir / "db", "test_elixir")
+    chunker = ElixirChunker()
+    rag.add_chunks_batch(chunker.extract_chunks(ACCOUNTS, 'lib/my_app/accounts.ex')
+                         + chunker.extract_chunks(PROTOCOL, 'lib/size.ex'))
+    rag._build_keyword_index()
+    
+    results = rag.retrieve_context("accounts user", n_results=30, filter_expr="kind=type")
+    names = sorted(r['metadata']['name'] for r in results)
+    assert names == ['Accounts', 'BitString', 'Size', 'User', 'Worker'], names
+    results = rag.retrieve_context("user", n_results=30, filter_expr='name="MyApp.Accounts.*"')
+    assert 'get_user' in {r['metadata']['name'] for r in results}
+    implementations = rag.find_implementations('Size', language='elixir')
+    assert [r['metadata']['name'] for r in implementations] == ['BitString'], implementations
+    print("✅ Elixir symbols found by kind, name and protocol")
+
+
+def main():
+    print("=" * 70)
+    print("ELIXIR CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_elixir_"))
+    
+    tests = [
+        test_modules,
+        test_functions,
+        test_protocols_and_behaviours,
+        lambda: test_search(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,154 @@
+#!/usr/bin/env python3
+"""
+Test script for the pluggable embedding backends
+Texts are embedded in backend-sized batches, backends are built from their
+name, and a collection keeps vectors of the model and dimension it started
+with: another dimension is refused before anything is parsed or stored
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from embedders import (DefaultEmbedder, Embedder, EmbeddingError, OllamaEmbedder, RateLimitedEmbedder,
+                       create_embedder, find_embedder)
+from indexer import ChromeIndexer
+from rag import ChromeRAGSystem
+from utils.state_manager import StateManager
+
+SOURCE = '''package store
+
+// Open opens the store
+func Open(path string) *Store {
+    return &Store{path: path}
+}
+'''
+
+
+class CountingEmbedder(Embedder):
+    """Vectors of letter counts; records the batches it was sent"""
+    
+    def __init__(self, model_name='test-count', size=8, batch_size=2):
+        super().__init__(model_name, batch_size=batch_size)
+        self.size = size
+        self.calls = []
+    
+    def embed(self, texts):
+        vectors = []
+        for batch in self._batches(texts):
+            self.calls.append(list(batch))
+            vectors.extend([float(text.count(chr(ord('a') + i))) + 1.0 for i in range(self.size)] for text in batch)
+        return vectors
+    
+    def dimensions(self):
+        return self.size
+
+
+class FakeFunction:
+    """Stands in for chromadb's DefaultEmbeddingFunction"""
+    
+    def __init__(self):
+        self.calls = []
+    
+    def __call__(self, texts):
+        self.calls.append(list(texts))
+        return [[float(len(text)), 0.5, 0.25] for text in texts]
+
+
+def test_batching():
+    embedder = CountingEmbedder(batch_size=2)
+    vectors = embedder.embed(['a', 'bb', 'ccc', 'dddd', 'eeeee'])
+    assert embedder.calls == [['a', 'bb'], ['ccc', 'dddd'], ['eeeee']], embedder.calls
+    assert len(vectors) == 5 and vectors[2][2] == 4.0, "one vector per text, in input order"
+    assert CountingEmbedder(batch_size=0).batch_size == 1, "a batch holds at least one text"
+    
+    default = DefaultEmbedder(batch_size=3)
+    default._function = FakeFunction()
+    vectors = default.embed(['one', 'two', 'three', 'four'])
+    assert default._function.calls == [['one', 'two', 'three'], ['four']], default._function.calls
+    assert vectors[2] == [5.0, 0.5, 0.25] and all(isinstance(x, float) for x in vectors[0])
+    assert default.dimensions() == 3 and default.dimensions() == 3
+    assert default._function.calls[2:] == [['dimension probe']], "the dimension is probed once"
+    print("✅ Texts are embedded in batches of batch_size, vectors in input order")
+
+
+def test_create_embedder():
+    default = create_embedder('default')
+    assert isinstance(default, DefaultEmbedder) and default.model_name == 'all-MiniLM-L6-v2'
+    embedder = create_embedder('ollama', model_name='mxbai-embed-large', base_url='http://gpu-box:11434/')
+    assert isinstance(embedder, RateLimitedEmbedder), embedder
+    ollama = find_embedder(embedder, OllamaEmbedder)
+    assert ollama.model_name == 'mxbai-embed-large' and ollama.base_url == 'http://gpu-box:11434', ollama.base_url
+    try:
+        create_embedder('openai')
+    except ValueError as e:
+        assert 'Unknown embedding backend: openai' in str(e), e
+    else:
+        raise AssertionError("an unknown backend was accepted")
+    print("✅ Backends are built from their name, with the model and server given")
+
+
+def test_collection_model(workdir):
+    rag = ChromeRAGSystem(db_path=str(workdir / "db"), collection_name="models", embedder=CountingEmbedder())
+    assert rag.validate_embedder() == 8, "an empty collection takes any dimension"
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db"))).index_directory(str(workdir / "src"),
+                                                                                            parallel=False)
+    metadata = rag.collection.metadata
+    assert (metadata['embedding_model'], metadata['embedding_dimensions']) == ('test-count', 8), metadata
+    
+    rag.set_embedder(CountingEmbedder('test-count-wide', size=12))
+    try:
+        rag.validate_embedder()
+    except EmbeddingError as e:
+        assert "holds 8-dimensional vectors from 'test-count'" in str(e) and 'produces 12' in str(e), e
+    else:
+        raise AssertionError("a 12-dimensional embedder was accepted for 8-dimensional vectors")
+    print("✅ The collection records its model and dimension and refuses another dimension")
+
+
+def test_indexer_fails_fast(workdir):
+    rag = ChromeRAGSystem(db_path=str(workdir / "db"), collection_name="models", embedder=CountingEmbedder())
+    wide = CountingEmbedder('test-count-wide', size=12)
+    (workdir / "src" / "close.go").write_text(SOURCE.replace('Open', 'Close'))
+    indexer = ChromeIndexer(rag, embedder=wide, state_manager=StateManager(str(workdir / "state.db")))
+    assert rag.embedder is wide, "the indexer's embedder replaces the RAG system's"
+    count = rag.collection.count()
+    stats = indexer.index_directory(str(workdir / "src"), parallel=False)
+    assert stats['files_processed'] == 0 and wide.calls == [], "nothing is parsed or embedded"
+    assert any('8-dimensional' in error for error in stats['errors']), stats['errors']
+    assert rag.collection.count() == count
+    print("✅ Indexing with a mismatched embedder stops before parsing, with the reason in the errors")
+
+
+def main():
+    print("=" * 70)
+    print("EMBEDDERS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_embedders_"))
+    (workdir / "src").mkdir()
+    (workdir / "src" / "open.go").write_text(SOURCE)
+    
+    tests = [test_batching, test_create_embedder, lambda: test_collection_model(workdir),
+             lambda: test_indexer_fails_fast(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,109 @@
+#!/usr/bin/env python3
+"""
+Test script for the on-disk embedding cache
+Unchanged text must never reach the backend twice, across runs
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from embedders import CachedEmbedder, Embedder, create_embedder
+from embedders.cached_embedder import normalize_text
+
+
+class CountingEmbedder(Embedder):
+    """Records every text it is asked to embed"""
+    
+    def __init__(self, model_name='counting', batch_size=4):
+        super().__init__(model_name, batch_size)
+        self.calls = []
+    
+    def embed(self, texts):
+        self.calls.append(list(texts))
+        return [[float(len(text)), float(sum(map(ord, text)) % 97)] for text in texts]
+    
+    def dimensions(self):
+        return 2
+
+
+def test_reuse_across_runs(workdir: Path):
+    cache_path = str(workdir / "cache.db")
+    texts = [f"func F{i}() {{}}" for i in range(10)]
+    
+    first = CachedEmbedder(CountingEmbedder(), cache_path)
+    vectors = first.embed(texts)
+    assert (first.hits, first.misses) == (0, 10)
+    
+    # A new run: 9 of 10 texts unchanged, one edited
+    backend = CountingEmbedder()
+    second = CachedEmbedder(backend, cache_path)
+    edited = texts[:9] + ["func F9() { return }"]
+    again = second.embed(edited)
+    assert backend.calls == [["func F9() { return }"]], backend.calls
+    assert (second.hits, second.misses) == (9, 1)
+    assert again[:9] == vectors[:9]
+    print("✅ Unchanged chunks answered from the cache on a re-run")
+
+
+def test_keyed_by_model_and_normalized_text(workdir: Path):
+    cache_path = str(workdir / "models.db")
+    CachedEmbedder(CountingEmbedder('model-a'), cache_path).embed(["x := 1"])
+    
+    other_model = CountingEmbedder('model-b')
+    CachedEmbedder(other_model, cache_path).embed(["x := 1"])
+    assert other_model.calls == [["x := 1"]], "vectors of another model were reused"
+    
+    same_model = CountingEmbedder('model-a')
+    cached = CachedEmbedder(same_model, cache_path)
+    cached.embed(["x := 1   \r\n", "x := 1"])
+    assert same_model.calls == [] and cached.hits == 2
+    assert normalize_text("a  \r\nb\t\n") == "a\nb"
+    print("✅ Cache keyed by model and normalized text")
+
+
+def test_duplicates_embedded_once(workdir: Path):
+    backend = CountingEmbedder()
+    cached = CachedEmbedder(backend, str(workdir / "dups.db"))
+    vectors = cached.embed(["a", "b", "a", "a"])
+    assert backend.calls == [["a", "b"]], backend.calls
+    assert vectors[0] == vectors[2] == vectors[3]
+    print("✅ Repeated texts in one batch embedded once")
+
+
+def test_factory(workdir: Path):
+    embedder = create_embedder('ollama', cache_path=str(workdir / "factory.db"))
+    assert isinstance(embedder, CachedEmbedder) and embedder.model_name == embedder.embedder.model_name
+    assert not isinstance(create_embedder('ollama'), CachedEmbedder)
+    print("✅ create_embedder wraps the backend when a cache path is given")
+
+
+def main():
+    print("=" * 70)
+    print("EMBEDDING CACHE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="embedding_cache_"))
+    tests = [test_reuse_across_runs, test_keyed_by_model_and_normalized_text,
+             test_duplicates_embedded_once, test_factory]
+    failed = 0
+    for test in tests:
+        try:
+            test(workdir)
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,200 @@
+#!/usr/bin/env python3
+"""
+Test script for moving an index to another embedding model
+Re-embeds a small SQLite index from its stored chunks, checks the old index
+answers searches until the swap, and that failures and cancellation leave it as it was
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from embedders import CachedEmbedder, EmbeddingError
+from helpers import HashEmbedder, make_rag
+from stores.base_store import VectorStore
+from utils.cancellation import CancelToken
+from utils.embedding_migration import MIGRATION_SUFFIX
+
+
+class FailingEmbedder(HashEmbedder):
+    """Hashed-token vectors until fail_after texts are embedded, then a backend error"""
+    
+    def __init__(self, model_name, size, fail_after):
+        super().__init__(model_name, size)
+        self.fail_after = fail_after
+    
+    def embed(self, texts):
+        if len(self.texts) + len(texts) > self.fail_after:
+            raise EmbeddingError("backend went away")
+        return super().embed(texts)
+
+
+def sample_chunks():
+    """Twelve functions, two of them with the same body"""
+    chunks = []
+    for i, topic in enumerate(['parse header', 'render page', 'open socket', 'close socket', 'hash password',
+                               'verify token', 'read config', 'write config', 'load cache', 'evict cache']):
+        name = topic.replace(' ', '_')
+        chunks.append(CodeChunk(type='function', name=name, content=f"def {name}(value):\n    return {name}_impl(value)",
+                                filepath='app.py', language='python', line_start=i * 3 + 1, line_end=i * 3 + 2,
+                                signature=f"def {name}(value)", doc=f"{topic.capitalize()} for the request"))
+    for name in ('copy_a', 'copy_b'):
+        chunks.append(CodeChunk(type='function', name=name, content="def copy():\n    return shared_body()",
+                                filepath=f'{name}.py', language='python', line_start=1, line_end=2))
+    return chunks
+
+
+def build_rag(workdir, name, embedding_mode=None):
+    rag = make_rag(workdir, name, embedder=HashEmbedder('hash-64', size=64), db='migration.db',
+                   embedding_mode=embedding_mode)
+    rag.add_chunks_batch(sample_chunks())
+    rag._build_keyword_index()
+    return rag
+
+
+def vector_sizes(store):
+    return {len(v) for v in store.get(include=['embeddings'])['embeddings']}
+
+
+def test_migrate(workdir):
+    """Every chunk is re-embedded from its stored code and the new model is recorded"""
+    rag = build_rag(workdir, 'migrate')
+    before = rag.collection.get()
+    target = HashEmbedder('hash-32', size=32)
+    
+    summary = rag.migrate_embedder(target)
+    assert summary == {'previous_model': 'hash-64', 'previous_dimensions': 64, 'embedding_model': 'hash-32',
+                       'embedding_dimensions': 32, 'chunks': 12, 'cancelled': False}, summary
+    metadata = rag.collection.metadata
+    assert metadata['embedding_model'] == 'hash-32' and metadata['embedding_dimensions'] == 32
+    assert metadata['distance_metric'] == 'cosine', "the rest of the manifest is kept"
+    assert vector_sizes(rag.collection) == {32} and rag.embedder is target
+    after = rag.collection.get()
+    assert sorted(zip(after['ids'], after['documents'])) == sorted(zip(before['ids'], before['documents']))
+    assert len(target.texts) == 11, "identical bodies are embedded once"
+    assert rag.collection.open_collection('migrate' + MIGRATION_SUFFIX).count() == 0
+    
+    results = rag.retrieve_context("hash password", n_results=3)
+    assert results[0]['metadata']['name'] == 'hash_password', [r['metadata']['name'] for r in results]
+    rag.validate_embedder()
+    print("✅ Chunks re-embedded with the new model, manifest updated")
+
+
+def test_searchable_until_swap(workdir):
+    """The old vectors answer searches while the new ones are staged"""
+    rag = build_rag(workdir, 'live')
+    seen = []
+    
+    def probe(state):
+        if state.stage == 'embedding' and state.chunks_embedded:
+            names = [r['metadata']['name'] for r in rag.retrieve_context("verify token", n_results=2)]
+            seen.append((state.chunks_embedded, rag.collection.metadata['embedding_model'], names[0]))
+            try:
+                rag.migrate_embedder(HashEmbedder('hash-16', size=16))
+                assert False, "a second migration should be refused"
+            except EmbeddingError:
+                pass
+    
+    batch_size = CONFIG.batch_size
+    CONFIG.batch_size = 5
+    try:
+        rag.migrate_embedder(HashEmbedder('hash-32', size=32), progress=probe)
+    finally:
+        CONFIG.batch_size = batch_size
+    assert seen == [(5, 'hash-64', 'verify_token'), (10, 'hash-64', 'verify_token'),
+                    (12, 'hash-64', 'verify_token')], seen
+    assert rag.collection.metadata['embedding_model'] == 'hash-32'
+    print("✅ The old index stays searchable until the swap, one migration at a time")
+
+
+def test_failure_and_cancel(workdir):
+    """A failing embedder or a cancelled migration leaves the index on the old model"""
+    rag = build_rag(workdir, 'failing')
+    old = rag.embedder
+    batch_size = CONFIG.batch_size
+    CONFIG.batch_size = 5
+    try:
+        try:
+            rag.migrate_embedder(FailingEmbedder('hash-32', 32, fail_after=6))
+            assert False, "the failure should be raised"
+        except EmbeddingError:
+            pass
+        assert rag.collection.metadata['embedding_model'] == 'hash-64' and rag.embedder is old
+        assert vector_sizes(rag.collection) == {64} and rag.collection.count() == 12
+        assert rag.collection.open_collection('failing' + MIGRATION_SUFFIX).count() == 0
+        
+        cancel = CancelToken()
+        summary = rag.migrate_embedder(HashEmbedder('hash-32', size=32), cancel=cancel,
+                                       progress=lambda state: cancel.cancel())
+    finally:
+        CONFIG.batch_size = batch_size
+    assert summary['cancelled'] and rag.collection.metadata['embedding_model'] == 'hash-64'
+    assert rag.collection.open_collection('failing' + MIGRATION_SUFFIX).count() == 0
+    
+    try:
+        make_rag(workdir, 'failing', embedder=old, db='migration.db', reduce_dimensions=48
+                 ).migrate_embedder(HashEmbedder('hash-32', size=32))
+        assert False, "vectors too short for the reduction should be refused"
+    except EmbeddingError:
+        pass
+    assert rag.collection.metadata['embedding_model'] == 'hash-64'
+    print("✅ Failed and cancelled migrations leave the index as it was")
+
+
+def test_cache_and_dual(workdir):
+    """Cached vectors are reused, and a dual index migrates its signature vectors too"""
+    rag = build_rag(workdir, 'dual', embedding_mode='dual')
+    backend = HashEmbedder('hash-32', size=32)
+    cached = CachedEmbedder(backend, str(workdir / 'cache.db'))
+    summary = rag.migrate_embedder(cached)
+    assert summary['chunks'] == 12 and summary['signatures'] == 10, summary
+    assert vector_sizes(rag.collection) == {32} and vector_sizes(rag.signature_store) == {32}
+    assert rag.retrieve_context("Verify token for the request", n_results=1)[0]['metadata']['name'] == 'verify_token'
+    embedded = len(backend.texts)
+    
+    rag.migrate_embedder(HashEmbedder('hash-64', size=64))
+    rag.migrate_embedder(cached)
+    assert len(backend.texts) == embedded, "texts embedded before come from the cache"
+    
+    # Backends without a one-step swap copy the staged chunks over
+    store = rag.collection
+    staging = store.open_collection('copied')
+    staging.add(ids=['a'], documents=['def a(): pass'], metadatas=[{'name': 'a'}], embeddings=[[1.0] * 32])
+    staging.modify(metadata={'embedding_model': 'copied'})
+    VectorStore.replace_with(store, staging)
+    assert store.get()['ids'] == ['a'] and store.metadata == {'embedding_model': 'copied'}
+    assert staging.count() == 0
+    print("✅ Cached vectors reused, signature vectors migrated")
+
+
+def main():
+    print("=" * 70)
+    print("EMBEDDING MIGRATION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="migration_"))
+    tests = [lambda: test_migrate(workdir), lambda: test_searchable_until_swap(workdir),
+             lambda: test_failure_and_cancel(workdir), lambda: test_cache_and_dual(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
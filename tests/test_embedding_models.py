@@ -0,0 +1,205 @@
+#!/usr/bin/env python3
+"""
+Test script for extra embedding models
+Each configured model keeps a vector of every chunk in a collection of its
+own; searches rank by one model or fuse several, the index and its snapshots
+record each model and its dimension, and an index refuses other models.
+Uses two small deterministic embedders
+"""
+
+import hashlib
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from embedders import EmbeddingError
+from helpers import HashEmbedder, make_rag
+from utils.index_snapshot import MODELS_DIR, SnapshotError
+
+
+class TrigramEmbedder(HashEmbedder):
+    """Vectors from hashed character trigrams: a second, differently shaped model"""
+    
+    def __init__(self):
+        super().__init__('test-trigrams', size=64)
+    
+    def embed(self, texts):
+        vectors = []
+        for text in texts:
+            vector = [0.0] * self.size
+            text = text.lower()
+            for i in range(len(text) - 2):
+                vector[hashlib.md5(text[i:i + 3].encode()).digest()[0] % self.size] += 1.0
+            norm = sum(x * x for x in vector) ** 0.5 or 1.0
+            vectors.append([x / norm for x in vector])
+        return vectors
+
+
+CHUNKS = [
+    ('auth/token.go', 'ValidateToken', 'func ValidateToken(token string) error { check token signature }'),
+    ('auth/session.go', 'RefreshSession', 'func RefreshSession(s *Session) error { refresh session expiry }'),
+    ('store/cache.go', 'EvictCache', 'func EvictCache(c *Cache) { evict stale cache entries }'),
+]
+
+
+def sample_chunks():
+    return [CodeChunk(type='function', name=name, content=content, filepath=path, language='go',
+                      line_start=1, line_end=3) for path, name, content in CHUNKS]
+
+
+def new_rag(workdir, name, models=True, **options):
+    return make_rag(workdir, name, embedding_models={'trigrams': TrigramEmbedder()} if models else {}, **options)
+
+
+def names(results):
+    return [r['metadata']['name'] for r in results]
+
+
+def test_storage(workdir):
+    rag = new_rag(workdir, 'stored')
+    rag.add_chunks_batch(sample_chunks())
+    store = rag.model_store('trigrams')
+    assert store.name == 'stored_model_trigrams' and store.count() == rag.collection.count() == 3
+    assert rag.recorded_models() == {'trigrams': 'test-trigrams'}
+    assert rag.embedding_model_info() == {'trigrams': {'model': 'test-trigrams', 'dimensions': 64, 'vectors': 3,
+                                                       'vector_bytes': 3 * 64 * 4}}, rag.embedding_model_info()
+    assert rag.get_statistics()['embedding_models']['trigrams']['vectors'] == 3
+    assert 'embedding_models' not in new_rag(workdir, 'plain', models=False).get_statistics()
+    
+    rag.move_file_chunks('auth/token.go', 'auth/tokens.go')
+    assert {m['filepath'] for m in store.get()['metadatas']} == {'auth/tokens.go', 'auth/session.go', 'store/cache.go'}
+    rag.delete_file_chunks('store/cache.go')
+    assert store.count() == rag.collection.count() == 2
+    rag.clear_collection()
+    assert store.count() == 0
+    print("✅ Each model keeps a vector of every chunk, moved, deleted and cleared with the index")
+
+
+def test_search(workdir):
+    rag = new_rag(workdir, 'searched')
+    rag.add_chunks_batch(sample_chunks())
+    rag._build_keyword_index()
+    query = "validate token signature"
+    
+    primary = rag.retrieve_context(query, n_results=3, lexical_weight=0.0)
+    assert names(primary)[0] == 'ValidateToken' and 'model_ranks' not in primary[0]
+    
+    # One model: its own ranking, from its own collection
+    vector = rag.embedding_models['trigrams'].embed([query])
+    direct = rag.model_store('trigrams').query(query_embeddings=vector, n_results=3)
+    trigrams = rag.retrieve_context(query, n_results=3, lexical_weight=0.0, embedding_models=['trigrams'])
+    assert [r['id'] for r in trigrams] == direct['ids'][0]
+    
+    fused = rag.retrieve_context(query, n_results=3, lexical_weight=0.0, embedding_models=['primary', 'trigrams'],
+                                 explain=True)
+    assert names(fused)[0] == 'ValidateToken'
+    assert fused[0]['model_ranks'] == {'primary': 1, 'trigrams': 1}, fused[0]['model_ranks']
+    assert fused[0]['explain']['vector']['models'] == {'primary': 1, 'trigrams': 1}
+    
+    try:
+        rag.retrieve_context(query, embedding_models=['missing'])
+        assert False, "an unknown model should be refused"
+    except ValueError as e:
+        assert "'missing'" in str(e) and 'primary, trigrams' in str(e), e
+    print("✅ Searches rank by one model or fuse several by reciprocal rank")
+
+
+def test_mismatches(workdir):
+    rag = new_rag(workdir, 'kept')
+    rag.add_chunks_batch(sample_chunks())
+    
+    without = new_rag(workdir, 'kept', models=False)
+    try:
+        without.add_chunks_batch(sample_chunks())
+        assert False, "indexing without the index's models should be refused"
+    except EmbeddingError as e:
+        assert 'trigrams=test-trigrams' in str(e), e
+    assert without.retrieve_context("refresh session", n_results=1), "searching by the primary model still works"
+    
+    plain = new_rag(workdir, 'older', models=False)
+    plain.add_chunks_batch(sample_chunks())
+    added = new_rag(workdir, 'older')
+    try:
+        added.retrieve_context("refresh session", embedding_models=['trigrams'])
+        assert False, "a model the index was built without should be refused"
+    except ValueError as e:
+        assert 'not indexed with embedding model' in str(e), e
+    
+    try:
+        new_rag(workdir, 'deduplicated', dedup='exact')
+        assert False, "models and dedup should be refused together"
+    except ValueError as e:
+        assert 'dedup' in str(e), e
+    try:
+        make_rag(workdir, 'bad', embedding_models={'primary': HashEmbedder()})
+        assert False, "the primary model's name should be refused"
+    except ValueError as e:
+        assert 'primary' in str(e), e
+    print("✅ An index refuses models other than the ones it was built with")
+
+
+def test_snapshots(workdir):
+    rag = new_rag(workdir, 'saved')
+    rag.add_chunks_batch(sample_chunks())
+    path = workdir / 'snapshot'
+    manifest = rag.save_index(str(path))
+    assert manifest['embedding_models'] == {'trigrams': {'embedding_model': 'test-trigrams', 'dimensions': 64}}
+    assert (path / MODELS_DIR / 'trigrams').is_dir()
+    
+    restored = new_rag(workdir, 'restored')
+    assert restored.load_index(str(path))['count'] == 3
+    assert restored.embedding_model_info()['trigrams']['vectors'] == 3
+    results = restored.retrieve_context("evict cache", n_results=1, embedding_models=['trigrams'])
+    assert names(results) == ['EvictCache'], names(results)
+    
+    plain = new_rag(workdir, 'unmodeled', models=False)
+    try:
+        plain.load_index(str(path))
+        assert False, "a snapshot with other models should be refused"
+    except SnapshotError as e:
+        assert 'trigrams=test-trigrams' in str(e), e
+    
+    # A snapshot without models replaces the vectors the index kept for them
+    plain.add_chunks_batch(sample_chunks()[:1])
+    bare = workdir / 'bare'
+    plain.save_index(str(bare))
+    reloaded = new_rag(workdir, 'restored', models=False)
+    reloaded.load_index(str(bare))
+    assert reloaded.model_store('trigrams').count() == 0 and reloaded.recorded_models() == {}
+    print("✅ Snapshots keep each model's vectors, and record its model and dimension")
+
+
+def main():
+    print("=" * 70)
+    print("EMBEDDING MODELS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_models_"))
+    tests = [
+        lambda: test_storage(workdir),
+        lambda: test_search(workdir),
+        lambda: test_mismatches(workdir),
+        lambda: test_snapshots(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,185 @@
+#!/usr/bin/env python3
+"""
+Test script for pipelined embedding
+With embedding workers, batches are embedded on background threads while
+files are still parsed and stored in the order they were produced: the index,
+its failures and the state recorded match a run that embeds in line, and the
+rate limiter still bounds the requests in flight. Uses a small deterministic
+embedder that answers out of order and rejects some texts
+"""
+
+import random
+import shutil
+import sys
+import tempfile
+import threading
+import time
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from config import CONFIG
+from embedders import EmbeddingError, RateLimitedEmbedder
+from helpers import HashEmbedder, make_rag
+from indexer import ChromeIndexer, embed_worker_count
+from utils.embedding_pipeline import EmbeddingPipeline
+from utils.state_manager import StateManager
+
+
+class SlowEmbedder(HashEmbedder):
+    """
+    Hashed bag-of-words vectors, each call taking a random while;
+    a batch holding POISON is rejected whole, and counts of calls in flight are kept
+    """
+    
+    def __init__(self, seed=7):
+        super().__init__('test-slow', size=64, normalize=False, batch_size=4)
+        self.random = random.Random(seed)
+        self.lock = threading.Lock()
+        self.in_flight = 0
+        self.most_in_flight = 0
+    
+    def embed(self, texts):
+        with self.lock:
+            self.in_flight += 1
+            self.most_in_flight = max(self.most_in_flight, self.in_flight)
+            delay = self.random.uniform(0, 0.02)
+        try:
+            time.sleep(delay)
+            if any('POISON' in text for text in texts):
+                raise EmbeddingError("input rejected")
+            return [self.vector(text) for text in texts]
+        finally:
+            with self.lock:
+                self.in_flight -= 1
+
+
+def make_tree(source):
+    """Twelve Go files of three functions in three packages, one function holding a text the backend rejects"""
+    for n in range(12):
+        package = source / f"pkg{n % 3}"
+        package.mkdir(parents=True, exist_ok=True)
+        bodies = []
+        for m in range(3):
+            word = 'POISON' if (n, m) == (5, 1) else f'value{n}x{m}'
+            bodies.append(f"// Handle{n}x{m} handles {word}\nfunc Handle{n}x{m}() string {{\n"
+                          f"    return \"{word} handled by worker {n}\"\n}}\n")
+        (package / f"file{n}.go").write_text(f"package pkg{n % 3}\n\n" + "\n".join(bodies))
+
+
+def index(workdir, source, name, backend, embed_workers):
+    embedder = RateLimitedEmbedder(backend, max_concurrent=2)
+    rag = make_rag(workdir, name, embedder=embedder)
+    state = StateManager(str(workdir / f"{name}-state.db"))
+    indexer = ChromeIndexer(rag, state_manager=state, embed_workers=embed_workers)
+    stats = indexer.index_directory(str(source), parallel=False, batch_size=3)
+    stored = rag.collection.get(include=['embeddings', 'metadatas'])
+    vectors = {id: list(vector) for id, vector in zip(stored['ids'], stored['embeddings'])}
+    recorded = {str(Path(path).relative_to(source)) for path in state.get_all_indexed_files()}
+    return vectors, stats, recorded, indexer
+
+
+def test_ordering():
+    stored = []
+    
+    def embed(batch):
+        # Later batches finish first
+        time.sleep(0.01 * (5 - batch))
+        return f"vectors of {batch}"
+    
+    pipeline = EmbeddingPipeline(embed, lambda batch, vectors: stored.append((batch, vectors)), workers=4)
+    for batch in range(6):
+        pipeline.submit(batch)
+        assert len(pipeline) <= pipeline.max_pending
+    pipeline.flush()
+    pipeline.close()
+    assert stored == [(batch, f"vectors of {batch}") for batch in range(6)], stored
+    
+    def failing(batch):
+        if batch == 1:
+            raise EmbeddingError("backend down")
+        return batch
+    
+    pipeline = EmbeddingPipeline(failing, lambda batch, vectors: stored.append(batch), workers=2)
+    stored.clear()
+    try:
+        for batch in range(3):
+            pipeline.submit(batch)
+        pipeline.flush()
+        assert False, "a failed batch, but no error"
+    except EmbeddingError as e:
+        assert 'backend down' in str(e)
+    pipeline.cancel()
+    assert stored == [0], "batches after the failed one are not stored"
+    print("✅ Batches are stored in the order submitted, whatever order they finish in")
+
+
+def test_matches_serial(workdir):
+    source = workdir / "tree"
+    make_tree(source)
+    serial, serial_stats, serial_recorded, _ = index(workdir, source, "serial", SlowEmbedder(), 0)
+    backend = SlowEmbedder()
+    piped, piped_stats, piped_recorded, indexer = index(workdir, source, "piped", backend, 4)
+    
+    assert piped == serial, "every chunk has the vector of its own text"
+    # Files with a rejected chunk are left out whole
+    assert len([id for id in piped if ':Handle' in id]) == 30, sorted(piped)
+    assert not [id for id in piped if 'file5.go' in id or 'file11.go' in id], sorted(piped)
+    # The rejected text is in Handle5x1 and in the summary of its package
+    failures = [(f['filepath'], f['name']) for f in piped_stats['embedding_failures']]
+    assert failures == [(f['filepath'], f['name']) for f in serial_stats['embedding_failures']], failures
+    assert sorted(name for _, name in failures) == ['Handle5x1', 'pkg2'], failures
+    assert piped_recorded == serial_recorded and len(piped_recorded) == 10, piped_recorded
+    assert piped_stats['chunks_created'] == serial_stats['chunks_created']
+    assert backend.most_in_flight <= 2, f"{backend.most_in_flight} requests in flight past the limiter's 2"
+    
+    # The files with rejected chunks are the only ones left for the next run
+    stats = indexer.update_index(str(source), report=False)
+    assert sorted(stats['paths_added']) == ['pkg2/file11.go', 'pkg2/file5.go'], stats['paths_added']
+    print("✅ A pipelined run stores what a run embedding in line does, within the rate limit")
+
+
+def test_worker_count(workdir):
+    class Plain(SlowEmbedder):
+        pass
+    
+    assert embed_worker_count(Plain(), 8) == 8
+    assert embed_worker_count(RateLimitedEmbedder(Plain(), max_concurrent=3), 8) == 3
+    assert embed_worker_count(RateLimitedEmbedder(Plain(), max_concurrent=3), 2) == 2
+    assert embed_worker_count(Plain()) == CONFIG.embedding_workers == 0, "embedding in line by default"
+    
+    rag = make_rag(workdir, "dedup", embedder=Plain(), dedup='exact')
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "dedup-state.db")), embed_workers=4)
+    assert indexer._embedding_pipeline(lambda *args: None) is None, "deduplicating indexes embed in line"
+    print("✅ Workers are capped by the rate limiter, and off by default")
+
+
+def main():
+    print("=" * 70)
+    print("EMBEDDING PIPELINE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_embedding_pipeline_"))
+    tests = [
+        test_ordering,
+        lambda: test_matches_serial(workdir),
+        lambda: test_worker_count(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,141 @@
+#!/usr/bin/env python3
+"""
+Test script for embedding input templates
+The document and query templates format the text sent to the embedder: the
+defaults send it unchanged, placeholders take the chunk's fields, signature
+vectors of a dual index and every query path use them, and the templates
+are recorded with the index. Uses a small deterministic embedder that
+remembers what it was sent
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from embedders import EmbeddingError
+from helpers import HashEmbedder, make_rag
+from utils.embedding_templates import DOCUMENT_FIELDS, QUERY_FIELDS, render_document, render_query, template_problems
+
+CHUNKS = [
+    CodeChunk(type='method', name='Authenticate', content='func (s *Server) Authenticate(user string) error { }',
+              filepath='auth/login.go', language='go', line_start=3, line_end=3, parent='Server',
+              signature='func (s *Server) Authenticate(user string) error', doc='Authenticate checks a login'),
+    CodeChunk(type='function', name='Render', content='func Render(page Page) string { }',
+              filepath='web/render.go', language='go', line_start=1, line_end=1,
+              signature='func Render(page Page) string'),
+]
+
+DOCUMENT = 'search_document: {language} {kind} {symbol}\n{doc}\n{body}'
+
+
+def test_render():
+    chunk = CHUNKS[0]
+    assert render_document('{body}', chunk, 'code') == 'code' and render_query('{query}', 'q') == 'q'
+    assert render_document(DOCUMENT, chunk, 'code') == \
+        'search_document: go method Server.Authenticate\nAuthenticate checks a login\ncode'
+    assert render_document('{signature} {{literal}} {doc}|', CHUNKS[1], 'x') == \
+        'func Render(page Page) string {literal} |', "missing fields are empty"
+    assert render_query('search_query: {query}', 'login') == 'search_query: login'
+    
+    assert not template_problems(DOCUMENT, DOCUMENT_FIELDS) and not template_problems('{query}', QUERY_FIELDS)
+    assert template_problems('{body} {path}', DOCUMENT_FIELDS)[0].startswith('unknown placeholder {path}')
+    assert template_problems('{query', QUERY_FIELDS) and template_problems('{body!r}', DOCUMENT_FIELDS)
+    assert template_problems('{body}', QUERY_FIELDS), "queries have no body"
+    print("✅ Templates format chunk fields and queries, and bad placeholders are reported")
+
+
+def test_defaults(workdir):
+    default = make_rag(workdir, "plain")
+    default.add_chunks_batch(CHUNKS)
+    assert default.embedder.texts == [c.content for c in CHUNKS], "the defaults send the text unchanged"
+    default.retrieve_context("check a login", n_results=1)
+    assert default.embedder.texts[-1] == 'check a login'
+    assert default.collection.metadata['document_template'] == '{body}'
+    assert default.collection.metadata['query_template'] == '{query}'
+    print("✅ The default templates reproduce the plain texts")
+
+
+def test_templates(workdir):
+    rag = make_rag(workdir, "nomic", embedding_mode='dual', document_template=DOCUMENT,
+                   query_template='search_query: {query}', embedding_models={'other': HashEmbedder('other')})
+    rag.add_chunks_batch(CHUNKS)
+    sent = rag.embedder.texts
+    assert sent[0] == ('search_document: go method Server.Authenticate\nAuthenticate checks a login\n'
+                       + CHUNKS[0].content), sent[0]
+    assert sent[2] == ('search_document: go method Server.Authenticate\nAuthenticate checks a login\n'
+                       'Authenticate checks a login\nfunc (s *Server) Authenticate(user string) error'), \
+        "signature vectors are templated too"
+    assert rag.embedding_models['other'].texts == sent[:2], "extra models embed the same texts"
+    stored = rag.signature_store.get(include=['documents'])['documents']
+    assert 'func Render(page Page) string' in stored, "the signature collection keeps the plain text"
+    
+    rag.retrieve_context("check a login", n_results=1, embedding_models=['primary', 'other'])
+    assert rag.embedder.texts[-1] == 'search_query: check a login'
+    assert rag.embedding_models['other'].texts[-1] == 'search_query: check a login'
+    assert rag.embed_query("check a login") == HashEmbedder().embed(['search_query: check a login'])[0]
+    
+    reopened = make_rag(workdir, "nomic", embedding_models={'other': HashEmbedder('other')})
+    assert (reopened.document_template, reopened.query_template) == (DOCUMENT, 'search_query: {query}'), \
+        "an index keeps its templates"
+    changed = make_rag(workdir, "nomic", document_template='{body}', embedding_models={'other': HashEmbedder('other')})
+    try:
+        changed.add_chunks_batch([CodeChunk(type='function', name='Other', content='func Other() {}',
+                                            filepath='x.go', language='go', line_start=1, line_end=1)])
+        assert False, "another document template, but no error"
+    except EmbeddingError as e:
+        assert 'clear it before changing the document template' in str(e), e
+    try:
+        make_rag(workdir, "broken", document_template='{body} {path}')
+        assert False, "an unknown placeholder, but no error"
+    except ValueError as e:
+        assert str(e).startswith('document_template: unknown placeholder {path}'), e
+    print("✅ Templates apply to chunks, signatures and queries of every model, and are recorded with the index")
+
+
+def test_settings():
+    saved = CONFIG.embedding_query_template
+    CONFIG.embedding_query_template = 'search_query: {text}'
+    try:
+        problems = cli.setting_problems()
+    finally:
+        CONFIG.embedding_query_template = saved
+    assert any(p.startswith('embedding_query_template: unknown placeholder {text}') for p in problems), problems
+    print("✅ config validate reports templates with unknown placeholders")
+
+
+def main():
+    print("=" * 70)
+    print("EMBEDDING TEMPLATES TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_embedding_templates_"))
+    tests = [
+        test_render,
+        lambda: test_defaults(workdir),
+        lambda: test_templates(workdir),
+        test_settings,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
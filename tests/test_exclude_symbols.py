@@ -0,0 +1,159 @@
+#!/usr/bin/env python3
+"""
+Test script for excluding symbols by name
+Chunks whose qualified name matches an exclude_symbols glob are left out at
+parse time and counted in the summary; split symbols go with all their parts,
+and Go's package linker still sees the symbols left out.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import is_excluded_symbol
+from chunkers.base_chunker import CodeChunk, parse_metadata, qualified_name
+from config import CONFIG
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.config_file import apply_settings, file_settings, read_config_file
+from utils.state_manager import StateManager
+
+GO_SOURCE = '''package auth
+
+func init() {
+    register("auth", New)
+}
+
+// Stringer has a display form
+type Stringer interface {
+    String() string
+}
+
+type User struct {
+    name string
+}
+
+// String returns the user's name
+func (u *User) String() string {
+    return u.name
+}
+
+// Authenticate checks the password against the stored hash
+func Authenticate(user *User, password string) bool {
+    hash := hashPassword(password)
+    return compareHashes(hash, lookupHash(user.name))
+}
+'''
+
+TEST_SOURCE = '''package auth
+
+func TestAuthenticate(t *testing.T) {
+    if !Authenticate(&User{name: "ada"}, "secret") {
+        t.Fatal("rejected")
+    }
+}
+'''
+
+
+def index(workdir, name, **options):
+    """Qualified names indexed per file of the sample tree, with the indexer that indexed them"""
+    source = workdir / name
+    (source / "auth").mkdir(parents=True)
+    (source / "auth" / "user.go").write_text(GO_SOURCE)
+    (source / "auth" / "user_test.go").write_text(TEST_SOURCE)
+    (source / "notes.txt").write_text("String formatting notes\n")
+    
+    rag = make_rag(workdir, name)
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / f"{name}-state.db")), **options)
+    indexer.index_directory(str(source), parallel=False)
+    
+    names = {}
+    for metadata in rag.collection.get(include=['metadatas'])['metadatas']:
+        names.setdefault(metadata['filepath'], {})[qualified_name(metadata)] = metadata
+    return names, indexer
+
+
+def test_exclude_symbols(workdir):
+    names, indexer = index(workdir, "excluded", exclude_symbols=['*.String', 'Test*', 'init'])
+    indexed = set(names['auth/user.go'])
+    assert {'Stringer', 'User', 'Authenticate'} <= indexed, indexed
+    assert not {'init', 'User.String', 'Stringer.String'} & indexed, indexed
+    assert 'auth/user_test.go' not in names, names.get('auth/user_test.go')
+    assert indexer.stats['chunks_excluded'] == 4, indexer.stats['chunks_excluded']
+    assert indexer.stats['chunks_created'] == sum(len(found) for found in names.values()), indexer.stats
+    
+    user = parse_metadata(names['auth/user.go']['User']['metadata'])
+    assert user['implements'] == ['Stringer'], "the linker still saw the excluded method"
+    
+    names, indexer = index(workdir, "kept")
+    assert {'init', 'User.String', 'TestAuthenticate'} <= {n for found in names.values() for n in found}
+    assert indexer.stats['chunks_excluded'] == 0
+    print("✅ Symbols matching exclude_symbols are left out at parse time and counted")
+
+
+def test_configured(workdir):
+    saved = CONFIG.exclude_symbols
+    config = workdir / "rag.toml"
+    config.write_text('[chunking]\nexclude_symbols = ["Authenticate"]\n')
+    try:
+        settings, problems = file_settings(read_config_file(config), CONFIG)
+        assert not problems, problems
+        apply_settings(CONFIG, settings)
+        assert CONFIG.exclude_symbols == ['Authenticate'], CONFIG.exclude_symbols
+        names, indexer = index(workdir, "configured")
+        assert 'Authenticate' not in names['auth/user.go'] and 'User.String' in names['auth/user.go']
+        assert indexer.stats['chunks_excluded'] == 1, indexer.stats['chunks_excluded']
+    finally:
+        CONFIG.exclude_symbols = saved
+    print("✅ exclude_symbols is read from the [chunking] section of a config file")
+
+
+def test_excluded_symbols():
+    def chunk(**fields):
+        return CodeChunk(**dict(dict(type='method', name='String', content='func (u *User) String() string',
+                                     filepath='a.go', language='go', line_start=1, line_end=1,
+                                     parent='User'), **fields))
+    
+    assert is_excluded_symbol(chunk(), ['*.String'])
+    assert is_excluded_symbol(chunk(), ['User.*']) and not is_excluded_symbol(chunk(), ['String'])
+    assert not is_excluded_symbol(chunk(), []) and not is_excluded_symbol(chunk(), ['*.string']), "case matters"
+    assert is_excluded_symbol(chunk(part_index=1, part_count=3), ['*.String']), "every part of a split symbol"
+    assert is_excluded_symbol(chunk(type='function', name='TestLogin', parent=None), ['Test*'])
+    assert not is_excluded_symbol(chunk(type='file', name='user.go', parent=None), ['*']), "whole files are kept"
+    assert not is_excluded_symbol(chunk(type='text', name='notes.txt', parent=None), ['*'])
+    print("✅ Qualified names are matched case-sensitively; files and text blocks are never excluded")
+
+
+def main():
+    print("=" * 70)
+    print("EXCLUDE SYMBOLS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="exclude_symbols_"))
+    tests = [
+        lambda: test_exclude_symbols(workdir),
+        lambda: test_configured(workdir),
+        test_excluded_symbols,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,113 @@
+#!/usr/bin/env python3
+"""
+Test script for keyword field weights
+A chunk's symbol name, doc comment and body count CONFIG.keyword_field_weights
+times in its BM25 document, so a query naming a symbol ranks it above code that
+only mentions the word.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from helpers import make_rag
+
+SOURCE = '''package auth
+
+// SessionManager tracks logged in users
+type SessionManager struct {
+    active map[string]*User
+}
+
+// CreateSession starts a login
+func CreateSession(user *User) string {
+    return newToken(user)
+}
+
+// Cleanup releases what a request held
+func Cleanup(cache *Cache, pool *Pool) {
+    // the session cache is flushed by the pool, so only release the pool here
+    pool.Release()
+    cache.Reset()
+}
+
+// PurgeExpired drops stale entries from the session store
+func PurgeExpired(store *Store) {
+    store.Purge()
+}
+'''
+
+
+def build_rag(workdir, collection, **weights):
+    rag = make_rag(workdir, collection, keyword_field_weights=weights or None)
+    rag.add_chunks_batch(GoChunker().extract_chunks(SOURCE, "auth/session.go"))
+    rag._build_keyword_index()
+    return rag
+
+
+def keyword_ranking(rag, query):
+    return [r['metadata']['name'] for r in rag.retrieve_context(query, n_results=4, lexical_weight=1.0)]
+
+
+def test_name_matches_first(workdir):
+    ranking = keyword_ranking(build_rag(workdir, "weighted"), "session")
+    assert sorted(ranking[:2]) == ['CreateSession', 'SessionManager'], ranking
+    assert ranking.index('Cleanup') > 1, "a mention in a comment ranks below the names"
+    print("✅ Symbol name matches rank above mentions in the body")
+
+
+def test_configurable(workdir):
+    weighted = build_rag(workdir, "default")
+    assert keyword_ranking(weighted, "stale") == ['PurgeExpired']
+    
+    undocumented = build_rag(workdir, "undocumented", doc=0)
+    assert undocumented.keyword_field_weights == {'name': 3, 'doc': 0, 'body': 1}, "unset fields keep the configured weight"
+    assert keyword_ranking(undocumented, "stale") == [], "a weight of 0 leaves the field out"
+    
+    names_only = build_rag(workdir, "names_only", doc=0, body=0)
+    assert keyword_ranking(names_only, "pool") == []
+    assert sorted(keyword_ranking(names_only, "session")) == ['CreateSession', 'SessionManager']
+    
+    for bad in ({'title': 2}, {'name': -1}, {'name': 1.5}):
+        try:
+            build_rag(workdir, "bad", **bad)
+        except ValueError:
+            continue
+        raise AssertionError(f"{bad} was accepted")
+    print("✅ Field weights are configurable and validated")
+
+
+def main():
+    print("=" * 70)
+    print("KEYWORD FIELD WEIGHT TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="field_weights_"))
+    
+    tests = [
+        lambda: test_name_matches_first(workdir),
+        lambda: test_configurable(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
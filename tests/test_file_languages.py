@@ -0,0 +1,151 @@
+#!/usr/bin/env python3
+"""
+Test script for the file-to-language mapping: extension and file name
+overrides, path patterns, #! lines of extensionless scripts and plain text
+indexing of files nothing maps
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from indexer import ChromeIndexer
+from utils.file_languages import LanguageMap, shebang_interpreter
+from utils.state_manager import StateManager
+
+
+class RecordingRAG:
+    """Stands in for the vector database and records each chunk's file and language"""
+    
+    embedder = 'recording'
+    
+    def __init__(self):
+        self.inserted = []
+    
+    def validate_embedder(self):
+        return 1
+    
+    def add_chunks_batch(self, chunks):
+        self.inserted.extend((c.filepath, c.language) for c in chunks)
+        return len(chunks)
+    
+    def delete_file_chunks(self, filepath, repo=None):
+        return 0
+    
+    def record_source_root(self, path, repo=None):
+        pass
+
+
+def make_tree(root: Path):
+    files = {
+        'web/page.gohtml': "<html>{{ .Title }}</html>\n",
+        'src/tables.inc': "int lookup(int key) {\n    return key * 2 + 1;\n}\n",
+        'third_party/legacy/codes.inc': "static int codes(void) {\n    return 42 + 7;\n}\n",
+        'tools/deploy': "#!/usr/bin/env -S bash -e\ndeploy() {\n    echo \"deploying the release\"\n}\n",
+        'tools/release': "#!/bin/sh\nrelease() {\n    echo \"tagging the release\"\n}\n",
+        'tools/LICENSE': "Permission is hereby granted, free of charge, to any person.\n",
+        'docs/notes.adoc': "= Release notes\n\nThe deploy script ships the release.\n",
+        'main.go': "package main\n\nfunc main() {\n    println(\"hello, world\")\n}\n",
+    }
+    for name, text in files.items():
+        path = root / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(text)
+
+
+def test_shebang(workdir):
+    cases = {
+        "#!/bin/bash -e\n": 'bash', "#!/usr/bin/env python3.11\n": 'python',
+        "#!/usr/bin/env -S FOO=1 node --harmony\n": 'node', "#!\n": None, "echo hi\n": None,
+    }
+    for i, (line, expected) in enumerate(cases.items()):
+        path = workdir / f"script{i}"
+        path.write_text(line)
+        assert shebang_interpreter(path) == expected, (line, shebang_interpreter(path))
+    assert shebang_interpreter(workdir / "missing") is None
+    print("✅ #! lines name their interpreter, with env options and versions dropped")
+
+
+def test_language_map(workdir):
+    root = workdir / "map"
+    make_tree(root)
+    languages = LanguageMap(extensions={'.gohtml': 'html', '.inc': 'cpp'},
+                            paths={'third_party/legacy/*.inc': 'c'})
+    
+    def language(name):
+        return languages.language(root / name, name)
+    
+    assert language('web/page.gohtml') == 'html' and language('src/tables.inc') == 'cpp'
+    assert language('third_party/legacy/codes.inc') == 'c', "path patterns win over extensions"
+    assert language('tools/deploy') == 'bash' and language('tools/release') == 'bash'
+    assert language('main.go') == 'go' and language('Makefile') == 'make' and language('BUILD') == 'bazel'
+    assert language('tools/LICENSE') is None and language('docs/notes.adoc') is None
+    
+    text = LanguageMap(plain_text=True)
+    assert text.language(root / 'docs/notes.adoc') == 'text' and text.language(root / 'main.go') == 'go'
+    
+    try:
+        LanguageMap(extensions={'.tmpl': 'gotemplate'})
+        assert False, "an unknown language should be rejected"
+    except ValueError as e:
+        assert 'gotemplate' in str(e) and 'extension_languages' in str(e), e
+    print("✅ Overrides, path patterns and #! lines decide a file's language")
+
+
+def test_index(workdir):
+    root = workdir / "src"
+    make_tree(root)
+    rag = RecordingRAG()
+    languages = LanguageMap(extensions={'.inc': 'cpp'}, paths={'third_party/*': 'c'}, plain_text=True)
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db")),
+                            use_default_ignores=False, languages=languages)
+    stats = indexer.index_directory(str(root), parallel=False)
+    
+    found = dict(rag.inserted)
+    assert found.get('src/tables.inc') == 'cpp' and found.get('third_party/legacy/codes.inc') == 'c', found
+    assert found.get('tools/deploy') == 'bash' and found.get('tools/release') == 'bash', found
+    assert found.get('docs/notes.adoc') == 'text' and found.get('tools/LICENSE') == 'text', found
+    assert found.get('web/page.gohtml') == 'html', found
+    assert stats['files_by_type']['text'] == 2 and stats['files_failed'] == 0, stats
+    
+    rag = RecordingRAG()
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "plain.db")),
+                            languages=LanguageMap(plain_text=False))
+    indexer.index_directory(str(root), parallel=False)
+    assert {filepath for filepath, _ in rag.inserted} == {'main.go', 'tools/deploy', 'tools/release', 'web/page.gohtml'}, rag.inserted
+    print("✅ Remapped, extensionless and unmapped files are indexed as their language")
+
+
+def main():
+    print("=" * 70)
+    print("FILE LANGUAGE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_languages_"))
+    
+    tests = [
+        lambda: test_shebang(workdir),
+        lambda: test_language_map(workdir),
+        lambda: test_index(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
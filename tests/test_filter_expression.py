@@ -0,0 +1,180 @@
+#!/usr/bin/env python3
+"""
+Test script for boolean search filters: parsing strings and JSON trees, clear
+errors for bad expressions, and filtering retrieval before it ranks
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from chunkers.base_chunker import CodeChunk
+from helpers import make_chroma_rag
+from rag import SYMBOL_KINDS
+from utils.filter_expression import And, FilterError, Not, Or, Term, parse_filter
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+
+def test_parse():
+    expression = parse_filter("(language=go OR language=rust) AND NOT kind=test AND path:*/auth/*")
+    assert expression == And((Or((Term('language', ('go',)), Term('language', ('rust',)))),
+                              Not(Term('kind', ('test',))), Term('path', ('*/auth/*',)))), expression
+    assert str(expression) == "(language=go OR language=rust) AND NOT kind=test AND path=*/auth/*"
+    
+    tree = {'and': [{'language': ['go', 'rust']}, {'not': {'kind': 'test'}}, {'path': '*/auth/*'}]}
+    assert parse_filter(tree).matches({'language': 'rust', 'filepath': 'src/auth/token.rs'}, SYMBOL_KINDS)
+    
+    for text in ("language=go AND kind=method", "name=Open or type=struct",
+                 'NOT (language=go AND kind=method) OR name="and (or)"', "kind != test AND name:New*"):
+        parsed = parse_filter(text)
+        assert parse_filter(str(parsed)) == parsed, (text, str(parsed))
+    assert parse_filter("language=go or NOT kind=test and repo=auth") == \
+        Or((Term('language', ('go',)), And((Not(Term('kind', ('test',))), Term('repo', ('auth',)))))), \
+        "NOT binds tighter than AND, AND tighter than OR"
+    assert parse_filter(expression) is expression
+    print("✅ Strings and JSON trees parse to the same expression, with a canonical string form")
+
+
+def test_errors():
+    cases = {
+        "language=go AND (kind=method": ("Expected ')'", 28),
+        "language=go AND": ("Expected a field=value term", 15),
+        "lang=go": ("Unknown filter field 'lang'", 0),
+        "language": ("Expected '=', ':' or '!='", 8),
+        "language=": ("Expected a value after 'language='", 9),
+        'path:"src/auth': ("Unterminated quoted value", 5),
+        "language=go)": ("Expected AND, OR or the end", 11),
+        "language=go ! kind=method": ("Unexpected character '!'", 12),
+        "  ": ("Empty filter", 0),
+    }
+    for text, (message, position) in cases.items():
+        try:
+            parse_filter(text)
+            assert False, f"{text!r} should not parse"
+        except FilterError as e:
+            assert message in str(e) and f"at position {position} " in str(e), (text, str(e))
+    
+    for tree, message in (({'and': []}, 'filter.and: expected a non-empty list'),
+                          ({'or': [{'language': 'go'}, {'kind': 'x', 'name': 'y'}]}, 'filter.or[1]: expected an object'),
+                          ({'not': {'lang': 'go'}}, "filter.not: Unknown filter field 'lang'"),
+                          ({'name': ['']}, "'name' needs a non-empty value"), (42, 'string or a JSON object')):
+        try:
+            parse_filter(tree)
+            assert False, f"{tree} should not parse"
+        except FilterError as e:
+            assert message in str(e), (tree, str(e))
+    
+    deep_tree = {'language': 'go'}
+    for _ in range(2000):
+        deep_tree = {'not': deep_tree}
+    for hostile in ('(' * 2000 + 'language=go' + ')' * 2000, 'NOT ' * 2000 + 'language=go',
+                    'language=go OR ' * 400 + 'kind=test', deep_tree):
+        try:
+            parse_filter(hostile)
+            assert False, "a hostile filter should not parse"
+        except FilterError as e:
+            assert str(e) == "filter nested too deeply", str(e)
+    assert parse_filter('(' * 10 + 'language=go' + ')' * 10) == Term('language', ('go',))
+    print("✅ Bad expressions are rejected with what is wrong and where")
+
+
+def test_matches():
+    method = {'language': 'go', 'type': 'method', 'kind': 'source', 'name': 'Authenticate', 'parent': 'AdminUser',
+              'filepath': 'auth/admin.go', 'metadata': '{"imports": [{"path": "sync", "name": "sync", "symbols": ["RWMutex"]}]}'}
+    test = dict(method, kind='test', name='TestAuthenticate', parent=None, filepath='auth/admin_test.go')
+    
+    def selects(text):
+        expression = parse_filter(text)
+        return [m['name'] for m in (method, test) if expression.matches(m, SYMBOL_KINDS)]
+    
+    assert selects("kind=method") == ['Authenticate', 'TestAuthenticate']
+    assert selects("kind=method AND NOT kind=test") == ['Authenticate']
+    assert selects("kind=test") == ['TestAuthenticate'] and selects("kind=source") == ['Authenticate']
+    assert selects("type=method AND name=AdminUser.Authenticate") == ['Authenticate']
+    assert selects("name:*Authenticate AND path:auth/*_test.go") == ['TestAuthenticate']
+    assert selects("uses=sync.RWMutex AND repo!=auth") == ['Authenticate', 'TestAuthenticate']
+    assert selects("language=rust OR uses=net/http") == []
+    print("✅ Terms match symbol and chunk kinds, names, paths, repos and dependencies")
+
+
+def test_retrieval(workdir):
+    rag = make_chroma_rag(workdir / "db", "test_filter_expr")
+    chunks = GoChunker().extract_chunks((SAMPLES / "complex.go").read_text(), "auth/complex.go")
+    chunks.append(CodeChunk(type="function", name="authenticate",
+                            content="def authenticate(username, password):\n    return check(username, password)",
+                            filepath="auth/login.py", language="python", line_start=1, line_end=2))
+    chunks.append(CodeChunk(type="function", name="test_authenticate",
+                            content="def test_authenticate():\n    assert authenticate('admin', 'password1')",
+                            filepath="auth/test_login.py", language="python", line_start=1, line_end=2, kind="test"))
+    chunks.append(CodeChunk(type="method", name="authenticate", parent="AdminUser",
+                            content="fn authenticate(&self, username: &str, password: &str) -> bool { true }",
+                            filepath="core/admin.rs", language="rust", line_start=1, line_end=1))
+    rag.add_chunks_batch(chunks)
+    rag._build_keyword_index()
+    
+    def found(**options):
+        return sorted(f"{r['metadata']['filepath']}:{r['metadata']['name']}"
+                      for r in rag.retrieve_context("authenticate username password", n_results=20, **options))
+    
+    selected = found(filter_expr="(language=go OR language=python) AND NOT kind=test AND path:auth/*"
+                                 " AND (kind=function OR kind=method)")
+    assert selected == ['auth/complex.go:AddPermission', 'auth/complex.go:Authenticate', 'auth/complex.go:CreateSession',
+                        'auth/complex.go:GetSession', 'auth/complex.go:Logout', 'auth/complex.go:NewAdminUser',
+                        'auth/complex.go:NewSessionManager', 'auth/complex.go:String', 'auth/login.py:authenticate'], selected
+    assert found(filter_expr="name=authenticate") == ['auth/login.py:authenticate', 'core/admin.rs:authenticate']
+    assert found(filter_expr={'not': {'language': ['go', 'python']}}) == ['core/admin.rs:authenticate']
+    assert found(filter_expr="name=authenticate", languages=['rust']) == ['core/admin.rs:authenticate'], \
+        "an expression ANDs with the flat filters"
+    assert found(filter_expr="language=java") == []
+    assert found(filter_expr="kind=test", exclude_tests=True) == []
+    
+    result = rag.retrieve_context("authenticate", n_results=1, filter_expr="language=rust OR kind=test", explain=True)[0]
+    assert result['explain']['filters'] == {'filter_expr': 'language=rust OR kind=test'}, result['explain']['filters']
+    streamed = [r['metadata']['filepath'] for r in rag.iter_context("authenticate", n_results=5,
+                                                                      filter_expr={'language': 'rust'})]
+    assert streamed == ['core/admin.rs'], streamed
+    
+    try:
+        rag.retrieve_context("authenticate", filter_expr="language=go AND")
+        assert False, "a bad expression should raise"
+    except FilterError:
+        pass
+    print("✅ Expressions pre-filter retrieval and compose with the flat filters")
+
+
+def main():
+    print("=" * 70)
+    print("FILTER EXPRESSION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_filter_expr_"))
+    
+    tests = [
+        test_parse,
+        test_errors,
+        test_matches,
+        lambda: test_retrieval(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
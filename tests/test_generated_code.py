@@ -0,0 +1,200 @@
+#!/usr/bin/env python3
+"""
+Test script for generated code
+Files marked generated by their header comment (the Go rule, @generated,
+<auto-generated>, protoc's header) or by their name (*.pb.go, *_pb2.py) are
+tagged generated=true or skipped when indexed; searches can leave their chunks
+out, filter on them or rank them lower. Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.generated_code import generated_reason
+from utils.state_manager import StateManager
+
+GENERATED_GO = '''// Copyright 2024 The Authors. All rights reserved.
+
+// Code generated by stringer -type=Color; DO NOT EDIT.
+
+//go:build linux
+
+package color
+
+func (c Color) String() string { return colorNames[c] }
+'''
+
+BUILD_GO = '''package color
+
+// Code generated by hand, but this is after the package clause. DO NOT EDIT.
+
+func ParseColor(name string) Color { return colorValues[name] }
+'''
+
+PROTOC_PY = '''# -*- coding: utf-8 -*-
+# Generated by the protocol buffer compiler.  DO NOT EDIT!
+# source: color.proto
+"""Generated protocol buffer code."""
+from google.protobuf import descriptor as _descriptor
+'''
+
+DESIGNER_CS = '''//------------------------------------------------------------------------------
+// <auto-generated>
+//     This code was generated by a tool.
+// </auto-generated>
+//------------------------------------------------------------------------------
+namespace App { partial class MainForm { } }
+'''
+
+HANDWRITTEN_PY = '''"""Colors of the palette; see generate_palette() for how they are made"""
+
+def generate_palette(count):
+    return ["#%06x" % (i * 0x111111) for i in range(count)]
+'''
+
+
+def write_tree(root):
+    files = {
+        'color/color_string.go': GENERATED_GO,
+        'color/color.go': 'package color\n\n// Color names a palette color\ntype Color int\n\n'
+                          'func (c Color) Hex() string { return colorHex[c] }\n',
+        'api/color.pb.go': 'package api\n\nfunc (m *ColorRequest) GetName() string { return m.Name }\n',
+        'palette/palette.go': 'package palette\n\n// GeneratePalette makes count colors; the names are not generated\n'
+                              'func GeneratePalette(count int) []string { return nil }\n',
+    }
+    for path, code in files.items():
+        (root / path).parent.mkdir(parents=True, exist_ok=True)
+        (root / path).write_text(code)
+
+
+def indexed_paths(rag):
+    return sorted({m['filepath'] for m in rag.collection.get(include=['metadatas'])['metadatas']})
+
+
+def test_detection():
+    assert generated_reason('color/color_string.go', GENERATED_GO, 'go') == 'header'
+    assert generated_reason('color/parse.go', BUILD_GO, 'go') is None, "the Go header must come before the package"
+    assert generated_reason('color/loose.go', '// code generated by something, do not edit\npackage color\n',
+                            'go') is None, "Go headers follow the exact form"
+    assert generated_reason('api/color_pb2.py', PROTOC_PY, 'python') == 'header'
+    assert generated_reason('App/MainForm.cs', DESIGNER_CS, 'csharp') == 'header'
+    assert generated_reason('lib/schema.rs', '// @generated by sqlx\nfn f() {}\n', 'rust') == 'header'
+    
+    assert generated_reason('api/color.pb.go', 'package api\n', 'go') == '*.pb.go'
+    assert generated_reason('App/MainForm.Designer.cs', 'namespace App { }\n', 'csharp') == '*.Designer.cs'
+    assert generated_reason('api/color.pb.go', 'package api\n', 'python') is None, "name patterns are per language"
+    assert generated_reason('mocks/mock_store.go', 'package mocks\n', 'go', ('mock_*.go',)) == 'mock_*.go'
+    assert generated_reason('pkg/client/typed.go', 'package client\n', 'go', ('pkg/client/*',)) == 'pkg/client/*'
+    assert generated_reason('cmd/client/typed.go', 'package client\n', 'go', ('pkg/client/*',)) is None
+    
+    assert generated_reason('palette/palette.py', HANDWRITTEN_PY, 'python') is None, \
+        "a docstring about generating is not a header"
+    assert generated_reason('color/color.go', 'package color\n', 'go') is None
+    print("✅ Generated files are recognized by their language's header convention and their names")
+
+
+def test_tagging(workdir):
+    root = workdir / 'tagged'
+    write_tree(root)
+    rag = make_rag(workdir, "tagged")
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / 'tagged_state.db'))).index_directory(
+        str(root), parallel=False)
+    tagged = {m['filepath'] for m in rag.collection.get(include=['metadatas'])['metadatas'] if m.get('generated')}
+    assert tagged == {'color/color_string.go', 'api/color.pb.go'}, tagged
+    assert len(indexed_paths(rag)) == 4
+    
+    rag._build_keyword_index()
+    query = "color name string"
+    
+    def files(**options):
+        return {r['metadata']['filepath'] for r in rag.retrieve_context(query, n_results=10, **options)}
+    
+    assert 'color/color_string.go' in files()
+    assert files(exclude_generated=True) == {'color/color.go', 'palette/palette.go'}, files(exclude_generated=True)
+    assert files(filter_expr="generated=true") == tagged
+    assert files(filter_expr="path=color/* AND generated!=true") == {'color/color.go'}
+    
+    plain = rag.retrieve_context(query, n_results=10)
+    weighted = rag.retrieve_context(query, n_results=10, generated_weight=0.25)
+    scores = {r['id']: r['rrf_score'] for r in plain}
+    for result in weighted:
+        expected = scores[result['id']] * (0.25 if result['metadata'].get('generated') else 1.0)
+        assert abs(result['rrf_score'] - expected) < 1e-12, result['metadata']['filepath']
+    try:
+        rag.retrieve_context(query, generated_weight=0)
+        assert False, "a zero generated_weight should be refused"
+    except ValueError as e:
+        assert 'generated_weight' in str(e), e
+    print("✅ Chunks of generated files are tagged, and searches exclude, filter or down-weight them")
+
+
+def test_skipping(workdir):
+    root = workdir / 'skipped'
+    write_tree(root)
+    rag = make_rag(workdir, "skipped")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / 'skipped_state.db')), generated='skip',
+                            generated_patterns=['palette/*'])
+    indexer.index_directory(str(root), parallel=False)
+    assert indexed_paths(rag) == ['color/color.go'], indexed_paths(rag)
+    assert indexer.stats['files_generated'] == 3
+    
+    plain = make_rag(workdir, "untagged")
+    ChromeIndexer(plain, state_manager=StateManager(str(workdir / 'untagged_state.db')),
+                  generated='off').index_directory(str(root), parallel=False)
+    metadatas = plain.collection.get(include=['metadatas'])['metadatas']
+    assert len({m['filepath'] for m in metadatas}) == 4 and not any(m.get('generated') for m in metadatas)
+    
+    try:
+        ChromeIndexer(rag, state_manager=StateManager(str(workdir / 'bad_state.db')), generated='drop')
+        assert False, "an unknown mode should be refused"
+    except ValueError as e:
+        assert 'tag, skip, off' in str(e), e
+    print("✅ Generated files can be skipped during discovery, or indexed like the rest")
+
+
+def test_chunk_field():
+    chunk = CodeChunk(type='function', name='String', content='func String()', filepath='a.go', language='go',
+                      line_start=1, line_end=1)
+    assert 'generated' not in chunk.to_dict(), "hand-written chunks do not store the flag"
+    chunk.generated = True
+    assert chunk.to_dict()['generated'] is True
+    print("✅ Only chunks of generated files store the generated flag")
+
+
+def main():
+    print("=" * 70)
+    print("GENERATED CODE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_generated_"))
+    tests = [
+        test_detection,
+        lambda: test_tagging(workdir),
+        lambda: test_skipping(workdir),
+        test_chunk_field,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
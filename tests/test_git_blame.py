@@ -0,0 +1,185 @@
+#!/usr/bin/env python3
+"""
+Test script for git blame metadata
+With git_blame on, every chunk of a file in a git work tree records the most
+recent commit among its lines as 'last_change', which results carry and the
+author and commit filter fields match. Uses a small deterministic embedder
+"""
+
+import os
+import shutil
+import subprocess
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.git_blame import describe_change, last_change, matches_author, matches_commit, parse_porcelain
+from utils.result_types import SearchResult
+from utils.state_manager import StateManager
+
+PORCELAIN = '''1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d 1 1 2
+author Alice
+author-mail <alice@example.com>
+author-time 1700000000
+committer-time 1700000000
+summary Add the retry loop
+filename net/retry.go
+\tpackage net
+1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d 2 2
+\t
+0000000000000000000000000000000000000000 3 3 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1800000000
+committer-time 1800000000
+summary Version of net/retry.go from net/retry.go
+filename net/retry.go
+\tfunc Retry() {}
+'''
+
+ALICE_GO = '''package net
+
+// Retry runs f until it succeeds or the attempts run out
+func Retry(attempts int, f func() error) error {
+\tvar err error
+\tfor i := 0; i < attempts; i++ {
+\t\tif err = f(); err == nil {
+\t\t\treturn nil
+\t\t}
+\t}
+\treturn err
+}
+
+// Backoff is the delay before the next retry attempt
+func Backoff(attempt int) int {
+\treturn attempt * 100
+}
+'''
+
+
+def commit(root, author, message, date):
+    """Commit everything in root as author, at a fixed date so the order of commits is known"""
+    env = dict(os.environ, GIT_AUTHOR_DATE=date, GIT_COMMITTER_DATE=date)
+    identity = ['-c', f'user.name={author}', '-c', f'user.email={author.lower()}@example.com']
+    subprocess.run(['git', 'add', '-A'], cwd=root, check=True, capture_output=True)
+    subprocess.run(['git', *identity, 'commit', '-q', '-m', message], cwd=root, env=env, check=True,
+                   capture_output=True)
+    return subprocess.run(['git', 'rev-parse', 'HEAD'], cwd=root, check=True, capture_output=True,
+                          text=True).stdout.strip()
+
+
+def make_repo(root):
+    """A repository where Alice wrote net/retry.go and Bob later changed Backoff"""
+    (root / 'net').mkdir(parents=True)
+    subprocess.run(['git', 'init', '-q', str(root)], check=True, capture_output=True)
+    (root / 'net' / 'retry.go').write_text(ALICE_GO)
+    alice = commit(root, 'Alice', 'Add the retry loop', '2024-01-10T12:00:00Z')
+    (root / 'net' / 'retry.go').write_text(ALICE_GO.replace('attempt * 100', 'attempt * attempt * 50'))
+    bob = commit(root, 'Bob', 'Back off quadratically', '2024-03-05T12:00:00Z')
+    # A new file, not committed yet
+    (root / 'net' / 'pool.go').write_text('package net\n\n// Pool keeps idle connections\ntype Pool struct{}\n')
+    return alice, bob
+
+
+def index(root, workdir, name, **options):
+    rag = make_rag(workdir, name)
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / f'{name}_state.db')), **options).index_directory(
+        str(root), parallel=False)
+    rag._build_keyword_index()
+    return rag
+
+
+def changes(rag):
+    """The last_change of each symbol in the index, by name"""
+    stored = rag.collection.get(include=['metadatas'])
+    chunks = [SearchResult.from_result({'id': id, 'content': '', 'metadata': metadata}).chunk
+              for id, metadata in zip(stored['ids'], stored['metadatas'])]
+    return {chunk.name: chunk.last_change for chunk in chunks}
+
+
+def test_parsing():
+    lines = parse_porcelain(PORCELAIN)
+    assert len(lines) == 3 and lines[2] is None, "uncommitted lines have no commit"
+    assert lines[0] is lines[1] and lines[0]['author'] == 'Alice' and lines[0]['email'] == 'alice@example.com'
+    
+    change = last_change(lines, 1, 3)
+    assert change == {'commit': '1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d', 'author': 'Alice',
+                      'email': 'alice@example.com', 'date': '2023-11-14T22:13:20Z',
+                      'summary': 'Add the retry loop'}, change
+    assert last_change(lines, 3, 3) is None
+    assert describe_change(change) == 'Alice <alice@example.com> in 1a2b3c4 (2023-11-14): Add the retry loop'
+    
+    assert matches_author(change, 'alice') and matches_author(change, '*@EXAMPLE.com')
+    assert not matches_author(change, 'bob*') and not matches_author(None, '*')
+    assert matches_commit(change, '1A2B3C') and matches_commit(change, '*3c4d') and not matches_commit(change, '2b3')
+    print("✅ Blame output gives each line's commit, and a range its most recent one")
+
+
+def test_indexing(workdir):
+    root = workdir / 'repo'
+    alice, bob = make_repo(root)
+    rag = index(root, workdir, 'blamed', blame=True)
+    found = changes(rag)
+    assert found['Retry'].commit == alice and found['Retry'].author == 'Alice', found['Retry']
+    assert found['Backoff'].commit == bob and found['Backoff'].date == '2024-03-05T12:00:00Z', found['Backoff']
+    assert found['Backoff'].summary == 'Back off quadratically' and found['Backoff'].email == 'bob@example.com'
+    assert found['Pool'] is None, "a file not committed yet has no last change"
+    
+    def names(filter_expr):
+        return {r['metadata']['name'] for r in rag.retrieve_context("retry backoff pool", n_results=10,
+                                                                     filter_expr=filter_expr)}
+    
+    assert names("author=bob*") == {'Backoff'}
+    assert names(f"commit={alice[:8]}") == {'Retry'}
+    assert names("author=*@example.com AND NOT author=alice") == {'Backoff'}
+    record = SearchResult.from_result(rag.retrieve_context("backoff", n_results=1,
+                                                           filter_expr="author=bob")[0]).to_dict()
+    assert record['last_change']['commit'] == bob and record['last_change']['author'] == 'Bob'
+    print("✅ Chunks record the last commit of their lines, and the author and commit fields filter on it")
+
+
+def test_off_by_default(workdir):
+    root = workdir / 'repo'
+    found = changes(index(root, workdir, 'unblamed'))
+    assert found and not any(found.values()), found
+    
+    plain = workdir / 'plain'
+    shutil.copytree(root / 'net', plain / 'net')
+    found = changes(index(plain, workdir, 'untracked', blame=True))
+    assert found and not any(found.values()), "files outside a work tree have no last change"
+    print("✅ Blame is off by default, and files outside a git work tree are indexed without it")
+
+
+def main():
+    print("=" * 70)
+    print("GIT BLAME TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_git_blame_"))
+    tests = [
+        test_parsing,
+        lambda: test_indexing(workdir),
+        lambda: test_off_by_default(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
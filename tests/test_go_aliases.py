@@ -0,0 +1,160 @@
+#!/usr/bin/env python3
+"""
+Test script for Go type aliases
+type A = B is another name for B, not a new type: alias chunks point at the
+type they name, methods declared through an alias belong to that type, and a
+search for the alias name finds the target.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, link_go_packages
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+STATUS_SOURCE = '''package http
+
+// StatusCode is an HTTP status
+type StatusCode int
+
+// Code is the older name of StatusCode
+type Code = StatusCode
+
+// LegacyCode keeps a second generation of callers compiling
+type LegacyCode = Code
+
+// Header holds request headers
+type Header = map[string][]string
+
+// Stringer describes itself
+type Stringer interface {
+    String() string
+}
+
+const NotFound Code = 404
+
+// String gives the reason phrase, declared through the alias
+func (c Code) String() string {
+    return "status"
+}
+
+// Class is the hundreds digit
+func (c StatusCode) Class() int {
+    return int(c) / 100
+}
+'''
+
+CLIENT_SOURCE = '''package client
+
+import "example.com/web/http"
+
+// Status is what the client calls a response code
+type Status = http.StatusCode
+
+// Context is an alias of a package that is not indexed
+type Context = context.Context
+
+// Describe reports the class of a status
+func Describe(s Status) int {
+    return s.Class()
+}
+'''
+
+
+def by_name(chunks, name, chunk_type=None):
+    return next(chunk for chunk in chunks if chunk.name == name and chunk_type in (None, chunk.type))
+
+
+def test_alias_chunks():
+    chunks = GoChunker().extract_chunks(STATUS_SOURCE, 'http/status.go')
+    status, code, header = by_name(chunks, 'StatusCode'), by_name(chunks, 'Code'), by_name(chunks, 'Header')
+    assert status.type == 'type' and status.metadata['underlying'] == 'int', status.metadata
+    assert code.type == 'alias' and code.metadata['aliased'] == 'StatusCode', (code.type, code.metadata)
+    assert code.signature == 'type Code = StatusCode', code.signature
+    assert header.type == 'alias' and header.metadata['aliased'] == 'map[string][]string', header.metadata
+    assert by_name(chunks, 'NotFound').metadata['value'] == 404, "constants typed by an alias still evaluate"
+    print("✅ Aliases are told apart from defined types")
+
+
+def test_alias_linking():
+    chunks = GoChunker().extract_chunks(STATUS_SOURCE, 'http/status.go') \
+        + GoChunker().extract_chunks(CLIENT_SOURCE, 'client/client.go')
+    link_go_packages(chunks)
+    status, code, legacy = by_name(chunks, 'StatusCode'), by_name(chunks, 'Code'), by_name(chunks, 'LegacyCode')
+    
+    string = by_name(chunks, 'String', 'method')
+    assert string.parent_class == 'StatusCode' and string.metadata['receiver_alias'] == 'Code', string.metadata
+    assert status.metadata['implements'] == ['Stringer'], "methods declared through the alias count"
+    assert 'implements' not in code.metadata, "an alias is not a second implementer"
+    
+    assert code.metadata['alias_of']['name'] == 'StatusCode' and code.metadata['alias_of']['resolved']
+    assert legacy.metadata['alias_of']['name'] == 'StatusCode', "alias chains end at the defined type"
+    assert code.metadata['underlying'] == 'int'
+    assert sorted(status.metadata['aliases']) == ['Code', 'LegacyCode', 'client.Status'], status.metadata['aliases']
+    assert by_name(chunks, 'Status').metadata['alias_of']['name'] == 'http.StatusCode'
+    assert by_name(chunks, 'Context').metadata['alias_of'] == {'name': 'context.Context', 'resolved': False}
+    assert by_name(chunks, 'Header').metadata['alias_of']['resolved'] is False
+    
+    describe = by_name(chunks, 'Describe')
+    assert [call['name'] for call in describe.metadata['calls']] == ['StatusCode.Class'], describe.metadata['calls']
+    print("✅ Aliases point at their target, which owns the methods")
+
+
+def test_alias_search(workdir):
+    source = workdir / "web"
+    (source / "http").mkdir(parents=True)
+    (source / "client").mkdir()
+    (source / "http" / "status.go").write_text(STATUS_SOURCE)
+    (source / "client" / "client.go").write_text(CLIENT_SOURCE)
+    
+    rag = make_rag(workdir, "aliases")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db")))
+    indexer.index_directory(str(source), parallel=False)
+    rag._build_keyword_index()
+    
+    found = [r['metadata']['name'] for r in rag.retrieve_context("LegacyCode", n_results=3, kinds=["type"],
+                                                                  lexical_weight=1.0)]
+    assert found[:2] == ['LegacyCode', 'StatusCode'], found
+    lookup = rag.lookup("Code", kinds=["type"])
+    code = next(entry for entry in lookup if entry['name'] == 'Code')
+    assert code['type'] == 'alias' and code['alias_of']['name'] == 'StatusCode', code
+    print("✅ Searching for an alias finds the type it names")
+
+
+def main():
+    print("=" * 70)
+    print("GO TYPE ALIAS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="go_aliases_"))
+    
+    tests = [
+        test_alias_chunks,
+        test_alias_linking,
+        lambda: test_alias_search(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
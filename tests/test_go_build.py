@@ -0,0 +1,185 @@
+#!/usr/bin/env python3
+"""
+Test script for Go build constraints: constraint evaluation, file name
+suffixes, discovery filtering and test-file tagging
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.go_build import GoBuildContext, build_constraint_lines, is_go_test_file
+from utils.state_manager import StateManager
+
+
+class RecordingRAG:
+    """Stands in for the vector database and records the chunks' files and kinds"""
+    
+    embedder = 'recording'
+    
+    def __init__(self):
+        self.inserted = []
+    
+    def validate_embedder(self):
+        return 1
+    
+    def add_chunks_batch(self, chunks):
+        # Declarations only: package summaries list what is left of the package
+        self.inserted.extend((c.filepath, c.name, c.kind) for c in chunks if c.type != 'package')
+        return len(chunks)
+    
+    def delete_file_chunks(self, filepath, repo=None):
+        return 0
+    
+    def record_source_root(self, path, repo=None):
+        pass
+
+
+def test_evaluate():
+    linux = GoBuildContext('linux', 'amd64', tags=['integration'])
+    assert linux.evaluate('linux') is True
+    assert linux.evaluate('windows') is False
+    assert linux.evaluate('linux && !arm64') is True
+    assert linux.evaluate('(darwin || windows) && amd64') is False
+    assert linux.evaluate('unix && integration') is True
+    assert linux.evaluate('android') is False
+    assert linux.evaluate('cgo') is False
+    assert linux.evaluate('go1.21 && gc') is True
+    assert linux.evaluate('linux &&') is None
+    
+    assert GoBuildContext('android', 'arm64').evaluate('linux') is True
+    
+    # Unset dimensions never exclude, negated or not
+    any_os = GoBuildContext(goarch='arm64')
+    assert any_os.evaluate('!windows') is None
+    assert any_os.evaluate('windows && amd64') is False
+    assert any_os.evaluate('windows || arm64') is True
+    print("✅ //go:build expressions evaluate against the target")
+
+
+def test_constraint_lines():
+    code = "// Copyright\n\n//go:build linux && !cgo\n// +build linux,!cgo\n\npackage x\n//go:build windows\n"
+    assert build_constraint_lines(code) == ['linux && !cgo']
+    
+    legacy = "/* header */\n// +build linux,amd64 darwin\n// +build !cgo\n\npackage x\n"
+    lines = build_constraint_lines(legacy)
+    assert lines == ['(linux && amd64) || (darwin)', '(!cgo)'], lines
+    context = GoBuildContext('linux', 'amd64')
+    assert context.matches_code(legacy)
+    assert not GoBuildContext('linux', 'arm64').matches_code(legacy)
+    assert context.matches_code("package x\n\n// +build windows\n")  # after the package clause
+    print("✅ //go:build and legacy // +build lines are read from the file header")
+
+
+def test_file_names():
+    context = GoBuildContext('linux', 'amd64')
+    assert context.matches_file_name('conn_linux.go')
+    assert not context.matches_file_name('conn_windows.go')
+    assert not context.matches_file_name('conn_windows_test.go')
+    assert context.matches_file_name('asm_linux_amd64.go')
+    assert not context.matches_file_name('asm_linux_arm64.go')
+    assert not context.matches_file_name('asm_arm64.go')
+    assert context.matches_file_name('windows.go')  # the first element is never a constraint
+    assert context.matches_file_name('conn_helper.go')
+    assert GoBuildContext().matches_file_name('conn_windows.go')
+    assert is_go_test_file('pkg/conn_test.go') and not is_go_test_file('pkg/contest.go')
+    print("✅ _GOOS / _GOARCH file name suffixes are matched")
+
+
+def make_tree(root: Path):
+    files = {
+        'net/conn.go': "package net\n\nfunc Dial() {}\n",
+        'net/conn_windows.go': "package net\n\nfunc dialWindows() {}\n",
+        'net/poll.go': "//go:build darwin || freebsd\n\npackage net\n\nfunc pollKqueue() {}\n",
+        'net/epoll.go': "//go:build linux\n\npackage net\n\nfunc pollEpoll() {}\n",
+        'net/conn_test.go': "package net\n\nfunc TestDial(t *testing.T) {}\n",
+    }
+    for name, code in files.items():
+        path = root / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(code)
+
+
+def index(root: Path, go_build: GoBuildContext, run: str):
+    rag = RecordingRAG()
+    state = StateManager(str(root.parent / f"state_{run}.db"))
+    indexer = ChromeIndexer(rag, state_manager=state, go_build=go_build)
+    stats = indexer.index_directory(str(root), parallel=False)
+    return rag.inserted, stats
+
+
+def test_discovery():
+    workdir = Path(tempfile.mkdtemp(prefix="go_build_"))
+    try:
+        root = workdir / "src"
+        make_tree(root)
+        
+        inserted, stats = index(root, GoBuildContext('linux', 'amd64'), 'linux')
+        names = {name for _, name, _ in inserted}
+        assert names == {'Dial', 'pollEpoll', 'TestDial'}, names
+        assert stats['files_constrained'] == 2, stats
+        kinds = {name: kind for _, name, kind in inserted}
+        assert kinds['TestDial'] == 'test' and kinds['Dial'] == 'source', kinds
+        
+        # No target: every file is indexed
+        inserted, stats = index(root, GoBuildContext(), 'any')
+        assert len({name for _, name, _ in inserted}) == 5, inserted
+        assert stats['files_constrained'] == 0
+        
+        inserted, stats = index(root, GoBuildContext(include_tests=False), 'no_tests')
+        assert {name for _, name, _ in inserted} == {'Dial', 'dialWindows', 'pollKqueue', 'pollEpoll'}, inserted
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ Constraint-excluded files are skipped and _test.go chunks tagged")
+
+
+def test_search_filters():
+    workdir = Path(tempfile.mkdtemp(prefix="go_build_search_"))
+    try:
+        rag = make_rag(workdir, 'code', db='index.db')
+        rag.add_chunks_batch([
+            CodeChunk(type='function', name='Dial', content='func Dial() { dial conn }',
+                      filepath='net/conn.go', language='go', line_start=3, line_end=3),
+            CodeChunk(type='function', name='TestDial', content='func TestDial(t *testing.T) { dial conn }',
+                      filepath='net/conn_test.go', language='go', line_start=3, line_end=3, kind='test'),
+        ])
+        
+        names = lambda results: {r['metadata']['name'] for r in results}
+        assert names(rag.retrieve_context('dial conn', n_results=5)) == {'Dial', 'TestDial'}
+        assert names(rag.retrieve_context('dial conn', n_results=5, exclude_tests=True)) == {'Dial'}
+        assert names(rag.retrieve_context('dial conn', n_results=5, kinds=['test'])) == {'TestDial'}
+        assert rag.retrieve_context('dial conn', n_results=5, kinds=['test'], exclude_tests=True) == []
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ Searches filter test chunks in and out")
+
+
+def main():
+    print("=" * 70)
+    print("GO BUILD CONSTRAINT TEST")
+    print("=" * 70)
+    
+    tests = [test_evaluate, test_constraint_lines, test_file_names, test_discovery, test_search_filters]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,834 @@
+#!/usr/bin/env python3
+"""
+Test script for the Go chunker and cross-file package linking
+Declarations are parsed by tree-sitter-go
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, link_go_packages
+
+
+FILE_A = """package shapes
+
+type Shape interface {
+    Area() float64
+    Name() string
+}
+
+type Namer interface {
+    Name() string
+}
+
+type Circle struct {
+    Radius float64
+}
+
+func (c Circle) Area() float64 { return 3.14 * c.Radius * c.Radius }
+"""
+
+FILE_B = """package shapes
+
+func (c Circle) Name() string { return "circle" }
+
+type Square struct {
+    Side float64
+}
+
+func (s *Square) Area() float64 { return s.Side * s.Side }
+func (s *Square) Name() string  { return "square" }
+
+// Labeled embeds Circle and inherits its method set
+type Labeled struct {
+    Circle
+    Label string
+}
+"""
+
+
+def by_name(chunks, name, chunk_type=None):
+    return next(c for c in chunks if c.name == name and chunk_type in (None, c.type))
+
+
+def test_declarations():
+    """Functions, methods and type declarations with their metadata"""
+    chunks = GoChunker().extract_chunks(FILE_A, 'shapes/a.go')
+    
+    shape = by_name(chunks, 'Shape')
+    assert shape.type == 'interface', shape.type
+    assert [m['name'] for m in shape.metadata['methods']] == ['Area', 'Name']
+    
+    area = by_name(chunks, 'Area', 'method')
+    assert area.parent_class == 'Circle'
+    assert area.metadata['signature_key'] == '() float64'
+    assert area.namespace == 'shapes'
+    print("✅ Declarations extracted")
+
+
+def test_implements_across_files():
+    """Method sets are assembled from every file of the package"""
+    chunker = GoChunker()
+    chunks = chunker.extract_chunks(FILE_A, 'shapes/a.go') + chunker.extract_chunks(FILE_B, 'shapes/b.go')
+    link_go_packages(chunks)
+    
+    circle = by_name(chunks, 'Circle')
+    assert circle.metadata['implements'] == ['Namer', 'Shape'], circle.metadata['implements']
+    assert circle.metadata['implements_pointer_only'] == []
+    
+    square = by_name(chunks, 'Square')
+    assert square.metadata['implements'] == ['Namer', 'Shape']
+    assert square.metadata['implements_pointer_only'] == ['Namer', 'Shape']
+    
+    labeled = by_name(chunks, 'Labeled')
+    assert 'Shape' in labeled.metadata['implements'], labeled.metadata
+    
+    shape = by_name(chunks, 'Shape')
+    assert shape.metadata['implemented_by'] == ['Circle', 'Labeled', 'Square']
+    print("✅ Interface implementations linked")
+
+
+EMBEDDING = """package accounts
+
+type Base struct {
+    ID int
+}
+
+func (b *Base) Touch() {}
+
+type User struct {
+    Base
+    Username string
+}
+
+func (u User) Display() string { return u.Username }
+
+type Audit struct {
+    ID string
+}
+
+type AdminUser struct {
+    *User
+    Audit
+    Level int
+}
+"""
+
+
+IO = """package io
+
+type Reader interface {
+    Read(p []byte) (n int, err error)
+}
+
+type Closer interface {
+    Close() error
+}
+"""
+
+STORAGE = """package storage
+
+import "io"
+
+// Blob is a stored object that can be streamed.
+type Blob interface {
+    io.Reader
+    io.Closer
+    Sizer
+    fs.File
+    // Name is the key the blob is stored under.
+    Name() string
+}
+
+type Sizer interface {
+    Size() int64
+    Close() error
+}
+"""
+
+
+def test_interface_method_sets():
+    """Interface methods are symbols of their own; embedded method sets are flattened"""
+    chunker = GoChunker()
+    chunks = chunker.extract_chunks(IO, 'io/io.go') + chunker.extract_chunks(STORAGE, 'storage/blob.go')
+    link_go_packages(chunks)
+    
+    name = by_name(chunks, 'Name', 'interface_method')
+    assert name.parent == 'Blob' and name.qualified_name == 'Blob.Name', name
+    assert name.signature == 'Name() string' and name.content == 'Name() string'
+    assert name.doc == 'Name is the key the blob is stored under.' and name.line_start == 12
+    assert name.metadata == {'signature_key': '() string', 'interface': 'Blob'}, name.metadata
+    assert name.context == 'type Blob interface'
+    close = by_name(chunks, 'Close', 'interface_method')
+    assert close.parent == 'Closer' and close.content == 'Close() error'  # kept despite its size
+    
+    blob = by_name(chunks, 'Blob')
+    assert blob.metadata['methods'] == [{'name': 'Name', 'signature': 'Name() string',
+                                         'signature_key': '() string', 'line': 12}], blob.metadata['methods']
+    method_set = blob.metadata['method_set']
+    assert [(m['name'], m.get('from'), m.get('via')) for m in method_set] == [
+        ('Name', None, None),
+        ('Read', 'io.Reader', 'Blob.Reader'),
+        ('Close', 'io.Closer', 'Blob.Closer'),
+        ('Size', 'Sizer', 'Blob.Sizer'),
+    ], method_set
+    assert method_set[1]['signature'] == 'Read(p []byte) (n int, err error)'
+    assert blob.metadata['unresolved_embeds'] == ['fs.File']
+    assert 'unresolved_embeds' not in by_name(chunks, 'Sizer').metadata
+    print("✅ Interface method sets flattened across embedded interfaces")
+
+
+def test_promotions():
+    """Fields and methods promoted through (pointer) embedding, with ambiguity"""
+    chunks = GoChunker().extract_chunks(EMBEDDING, 'accounts/accounts.go')
+    link_go_packages(chunks)
+    
+    admin = by_name(chunks, 'AdminUser').metadata
+    fields = {f['name']: f for f in admin['promoted_fields']}
+    assert fields['Username']['from'] == 'User', fields
+    assert fields['Base']['via'] == 'User'
+    # Base.ID is at depth 2, Audit.ID at depth 1: Audit wins, no ambiguity
+    assert fields['ID']['from'] == 'Audit', fields['ID']
+    assert admin['ambiguous_promotions'] == []
+    
+    methods = {m['name']: m for m in admin['promoted_methods']}
+    assert methods['Display']['from'] == 'User'
+    assert methods['Touch']['via'] == 'User.Base'
+    
+    # User only sees Base's fields
+    user = by_name(chunks, 'User').metadata
+    assert [f['name'] for f in user['promoted_fields']] == ['ID']
+    print("✅ Embedded promotions resolved")
+
+
+def test_ambiguous_promotion():
+    """The same selector from two embeds at one depth is flagged, not promoted"""
+    code = """package p
+
+type A struct { Name string }
+type B struct { Name string }
+type C struct {
+    A
+    B
+}
+"""
+    chunks = GoChunker().extract_chunks(code, 'p/p.go')
+    link_go_packages(chunks)
+    
+    c = by_name(chunks, 'C').metadata
+    assert c['ambiguous_promotions'] == ['Name'], c
+    assert c['promoted_fields'] == []
+    print("✅ Ambiguous promotion flagged")
+
+
+def test_type_params():
+    """Generic types and functions keep their type parameters and constraints"""
+    code = """package generic
+
+type Number interface {
+    ~int | ~int64 | ~float64
+}
+
+type Pair[K comparable, V any] struct {
+    Key K
+    Val V
+}
+
+type Buffer [N * 2]byte
+
+func Sum[T Number](xs []T) T { return xs[0] }
+
+func (p *Pair[K, V]) Swap() {}
+"""
+    chunks = GoChunker().extract_chunks(code, 'generic/generic.go')
+    
+    pair = by_name(chunks, 'Pair')
+    assert pair.signature == 'type Pair[K comparable, V any] struct', pair.signature
+    assert pair.metadata['type_params'] == [
+        {'name': 'K', 'constraint': 'comparable'}, {'name': 'V', 'constraint': 'any'}
+    ]
+    
+    assert by_name(chunks, 'Sum').metadata['type_params'] == [{'name': 'T', 'constraint': 'Number'}]
+    assert by_name(chunks, 'Number').metadata['type_terms'] == ['~int | ~int64 | ~float64']
+    assert 'type_params' not in by_name(chunks, 'Buffer').metadata
+    assert by_name(chunks, 'Swap').metadata['receiver_type_params'] == ['K', 'V']
+    print("✅ Type parameters captured")
+
+
+def test_doc_comments():
+    code = '''package acl
+
+// AddPermission adds a permission to the user.
+// Duplicates are ignored.
+//
+// Deprecated: use Grant, which also records an audit entry.
+//
+//go:noinline
+func AddPermission(u *User, p string) {
+    u.perms = append(u.perms, p)
+}
+
+var x = 1 // trailing comment, not a doc
+func Undocumented() {
+    return
+}
+
+type (
+    // Role groups permissions.
+    Role struct {
+        Perms []string
+    }
+)
+'''
+    chunks = GoChunker().extract_chunks(code, 'acl/acl.go')
+    add = by_name(chunks, 'AddPermission')
+    assert add.doc == ('AddPermission adds a permission to the user.\nDuplicates are ignored.\n\n'
+                       'Deprecated: use Grant, which also records an audit entry.'), repr(add.doc)
+    assert add.metadata['deprecated'] == 'use Grant, which also records an audit entry.'
+    assert add.content.startswith('func AddPermission')
+    assert by_name(chunks, 'Undocumented').doc is None
+    assert by_name(chunks, 'Role').doc == 'Role groups permissions.'
+    print("✅ Doc comments attached to declarations")
+
+
+CALLS_STORE = """package store
+
+type DB struct{}
+
+func (d *DB) Query(q string) error { return nil }
+
+type Store struct {
+    *DB
+    name string
+}
+
+func Open(name string) (*Store, error) {
+    return &Store{name: name}, nil
+}
+
+func (s *Store) Get(key string) error {
+    return s.Query(key)
+}
+"""
+
+CALLS_MAIN = """package main
+
+import "example.com/store"
+
+type Logger interface {
+    Log(msg string)
+}
+
+func run(log Logger, handler func()) error {
+    s, err := store.Open("users")
+    if err != nil {
+        return err
+    }
+    log.Log("opened")
+    handler()
+    size := len("x")
+    _ = int64(size)
+    return s.Get("alice")
+}
+
+func main() {
+    run(nil, func() {})
+    var d store.DB
+    d.Query("ping")
+}
+"""
+
+
+def test_call_graph():
+    """Calls resolve to same-package, qualified and method callees; the rest by name"""
+    chunker = GoChunker()
+    chunks = (chunker.extract_chunks(CALLS_STORE, 'store/store.go')
+              + chunker.extract_chunks(CALLS_MAIN, 'cmd/main.go'))
+    link_go_packages(chunks)
+    
+    def calls(chunk):
+        return [(ref['name'], ref['resolved']) for ref in chunk.metadata['calls']]
+    
+    # s.Query resolves through the embedded *DB to DB.Query
+    get = by_name(chunks, 'Get')
+    assert calls(get) == [('DB.Query', True)], calls(get)
+    assert get.metadata['calls'][0]['filepath'] == 'store/store.go'
+    
+    # Builtins and conversions are skipped; the interface call and func value stay unresolved
+    run = by_name(chunks, 'run')
+    assert calls(run) == [('Open', True), ('Logger.Log', False), ('handler', False), ('Store.Get', True)], calls(run)
+    
+    # 'var d store.DB' names a type of another indexed package
+    main = by_name(chunks, 'main')
+    assert calls(main) == [('run', True), ('DB.Query', True)], calls(main)
+    
+    assert [ref['name'] for ref in by_name(chunks, 'Open').metadata['called_by']] == ['run']
+    assert [ref['name'] for ref in by_name(chunks, 'Query').metadata['called_by']] == ['Store.Get', 'main']
+    assert by_name(chunks, 'run').metadata['called_by'][0]['symbol_id'] == main.default_symbol_id()
+    assert 'call_sites' not in run.metadata, "the call sites read off the tree are resolved into 'calls'"
+    print("✅ Call graph linked")
+
+
+CONSTANTS = """package units
+
+// Size units
+const (
+    _  = iota
+    KB ByteSize = 1 << (10 * iota) // kilobyte
+    MB
+    GB
+)
+
+type ByteSize uint64
+
+type Mode uint8
+
+const (
+    Read Mode = 1 << iota
+    Write
+    All = Read | Write
+    None = ^All &^ 0xF0
+)
+
+const MaxPort, Name = ^uint16(0), "svc" + "-" + "units"
+
+const Unknown = os.PathSeparator + 1
+
+var (
+    // ErrClosed is returned after Close.
+    ErrClosed = errors.New("closed")
+    mu        sync.Mutex
+)
+"""
+
+
+def test_constants():
+    """Const and var groups give one chunk per name, with iota values and named types"""
+    chunks = GoChunker().extract_chunks(CONSTANTS, 'units/units.go')
+    kb, mb, gb = by_name(chunks, 'KB'), by_name(chunks, 'MB'), by_name(chunks, 'GB')
+    assert [c.metadata['value'] for c in (kb, mb, gb)] == [1024, 1 << 20, 1 << 30]
+    assert gb.metadata['type'] == 'ByteSize' and gb.metadata['iota'] == 3 and gb.metadata['implicit']
+    assert gb.signature == 'const GB ByteSize = 1073741824', gb.signature
+    assert gb.content == 'GB' and gb.context == gb.signature and gb.line_start == 8
+    assert kb.content == 'KB ByteSize = 1 << (10 * iota) // kilobyte' and kb.doc == 'Size units'
+    assert not any(c.name == '_' for c in chunks)
+    
+    values = {c.name: c.metadata.get('value') for c in chunks if c.type == 'const'}
+    assert (values['Read'], values['Write'], values['All'], values['None']) == (1, 2, 3, 12), values
+    assert values['MaxPort'] == 65535 and by_name(chunks, 'MaxPort').metadata['type'] == 'uint16'
+    assert values['Name'] == 'svc-units' and by_name(chunks, 'Name').signature == 'const Name = "svc-units"'
+    unknown = by_name(chunks, 'Unknown')
+    assert values['Unknown'] is None and unknown.signature == 'const Unknown = os.PathSeparator + 1'
+    
+    closed, mu = by_name(chunks, 'ErrClosed'), by_name(chunks, 'mu')
+    assert closed.type == 'var' and closed.doc == 'ErrClosed is returned after Close.'
+    assert closed.signature == 'var ErrClosed = errors.New("closed")'
+    assert mu.signature == 'var mu sync.Mutex' and mu.metadata == {'type': 'sync.Mutex'}
+    print("✅ Constant groups expanded with their iota values")
+
+
+STATE_SESSION = """package session
+
+// DefaultManager serves requests that bring no manager of their own
+var DefaultManager = NewManager(memoryStore{})
+
+var (
+    handlers  = map[string]Handler{}
+    fallback  = &Manager{ttl: 30}
+    conn, err = dial("localhost")
+    level     = Level(2)
+    started   bool
+)
+
+type Level int
+
+type Handler func()
+
+type Manager struct{ ttl int }
+
+func NewManager(s Store) *Manager { return &Manager{} }
+
+func dial(addr string) (*Conn, error) { return nil, nil }
+
+func init() {
+    DefaultManager = NewManager(diskStore{})
+    started = true
+}
+"""
+
+STATE_REGISTRY = """package session
+
+// Register makes a handler available by name
+func Register(name string, h Handler) {
+    handlers[name] = h
+}
+
+func (m *Manager) Reset(fallback *Manager) {
+    fallback.ttl = 0
+    conn, err := dial("backup")
+    conn = nil
+}
+"""
+
+STATE_MAIN = """package main
+
+var manager = session.NewManager(nil)
+"""
+
+
+def test_package_state():
+    """Package-level vars with their initializers, inferred types and the functions setting them"""
+    chunks = link_go_packages(GoChunker().extract_chunks(STATE_SESSION, 'session/session.go')
+                              + GoChunker().extract_chunks(STATE_REGISTRY, 'session/registry.go')
+                              + GoChunker().extract_chunks(STATE_MAIN, 'cmd/main.go'))
+    default = by_name(chunks, 'DefaultManager')
+    assert default.type == 'var' and default.metadata['expression'] == 'NewManager(memoryStore{})'
+    assert default.metadata['initializer'] == 'NewManager' and default.metadata['inferred_type'] == '*Manager'
+    assert [e['name'] for e in default.metadata['assigned_in']] == ['init'], default.metadata
+    assert by_name(chunks, 'init').metadata['assigns'] == ['DefaultManager', 'started']
+    
+    types = {name: by_name(chunks, name).metadata.get('inferred_type') for name in ('handlers', 'fallback', 'conn', 'err', 'level')}
+    assert types == {'handlers': 'map[string]Handler', 'fallback': '*Manager', 'conn': '*Conn', 'err': 'error',
+                     'level': 'Level'}, types
+    assert by_name(chunks, 'err').metadata['result'] == 1 and by_name(chunks, 'err').metadata['expression'] == 'dial("localhost")'
+    assert by_name(chunks, 'started').metadata['type'] == 'bool'
+    assert by_name(chunks, 'manager').metadata['inferred_type'] == '*session.Manager'
+    
+    # Writes through a var count; parameters and locals of the same name shadow it
+    handlers = by_name(chunks, 'handlers')
+    assert [(e['name'], e['filepath']) for e in handlers.metadata['assigned_in']] == [('Register', 'session/registry.go')]
+    assert 'assigns' not in by_name(chunks, 'Reset').metadata
+    assert 'assigned_in' not in by_name(chunks, 'fallback').metadata and 'assigned_in' not in by_name(chunks, 'conn').metadata
+    print("✅ Package-level state linked to the functions that initialize it")
+
+
+ANONYMOUS = """package server
+
+// config holds the settings read at start-up
+var config struct {
+    Host string `json:"host"`
+    Port int
+}
+
+var (
+    defaults = &struct {
+        Timeout int
+    }{Timeout: 5}
+    onStart = func() {
+        println("start")
+    }
+)
+
+// Handle serves the status page
+func Handle(w http.ResponseWriter, r *http.Request) {
+    type reply struct {
+        Status string
+    }
+    write := func(v interface{}) {
+        resp := struct {
+            Data interface{}
+        }{v}
+        encode(w, resp)
+    }
+    go func() {
+        write(reply{Status: "ok"})
+    }()
+}
+
+// After comes after the closures
+func After() int {
+    return 1
+}
+"""
+
+
+def test_anonymous_structs():
+    """Package vars of anonymous struct types describe the struct; closures stay in their function"""
+    chunks = GoChunker().extract_chunks(ANONYMOUS, 'server/server.go')
+    config, defaults = by_name(chunks, 'config'), by_name(chunks, 'defaults')
+    assert config.metadata['struct_name'] == 'config.struct'
+    assert [(f['name'], f['type'], f['tag']) for f in config.metadata['fields']] == [
+        ('Host', 'string', '`json:"host"`'), ('Port', 'int', None)], config.metadata['fields']
+    assert config.signature == 'var config struct{Host string `json:"host"` Port int}', config.signature
+    assert (config.line_start, config.line_end) == (4, 7)
+    assert defaults.metadata['type'] == '*struct{Timeout int}' and defaults.metadata['struct_name'] == 'defaults.struct'
+    assert [f['name'] for f in defaults.metadata['fields']] == ['Timeout']
+    assert 'struct_name' not in by_name(chunks, 'onStart').metadata
+    
+    # Function literals and local types are part of the function, not symbols of their own
+    assert sorted(c.name for c in chunks) == ['After', 'Handle', 'config', 'defaults', 'onStart'], \
+        [c.name for c in chunks]
+    handle, after = by_name(chunks, 'Handle'), by_name(chunks, 'After')
+    assert (handle.line_start, handle.line_end) == (19, 32) and handle.content.endswith('}()\n}')
+    assert (after.line_start, after.line_end) == (35, 37) and after.doc == 'After comes after the closures'
+    print("✅ Anonymous struct vars named after their variable; closures kept inside their function")
+
+
+def test_string_cases():
+    """Constants are linked to the cases of their type's String method"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'complex.go'
+    chunks = link_go_packages(GoChunker().extract_chunks(sample.read_text(), 'comprehensive/complex.go'))
+    
+    forbidden = by_name(chunks, 'Forbidden')
+    assert forbidden.metadata['value'] == 2 and forbidden.metadata['type'] == 'StatusCode'
+    assert forbidden.metadata['string'] == 'Forbidden'
+    assert forbidden.metadata['string_method']['name'] == 'StatusCode.String'
+    
+    string = next(c for c in chunks if c.name == 'String' and c.parent_class == 'StatusCode')
+    assert [ref['name'] for ref in string.metadata['cases']] == ['Success', 'Unauthorized', 'Forbidden', 'NotFound']
+    print("✅ String method cases linked to their constants")
+
+
+CGO = """package syscalls
+
+/*
+#cgo LDFLAGS: -lm
+#include <stdlib.h>
+
+static int add(int a, int b) { return a + b; }
+static const char *open_brace = "{";
+*/
+import "C"
+
+import "unsafe"
+
+// Add adds two ints in C
+func Add(a, b int) int {
+    return int(C.add(C.int(a), C.int(b)))
+}
+
+func Free(p unsafe.Pointer) {
+    C.free(p)
+}
+"""
+
+
+def test_cgo():
+    """The C preamble is not parsed, import "C" is no dependency, C calls stay name-only"""
+    chunker = GoChunker()
+    chunks = chunker.extract_chunks(CGO, 'syscalls/cgo.go')
+    assert [c.name for c in chunks] == ['Add', 'Free'], [c.name for c in chunks]
+    assert chunker.diagnostics == [], chunker.diagnostics
+    assert chunker.cgo and [imp.path for imp in chunker.imports] == ['unsafe']
+    
+    add, free = chunks
+    assert add.doc == 'Add adds two ints in C' and add.line_start == 15, (add.doc, add.line_start)
+    assert free.doc in (None, ''), "the preamble is no doc comment"
+    assert add.metadata['cgo_symbols'] == ['add', 'int'], add.metadata
+    assert 'imports' not in add.metadata
+    assert [entry['path'] for entry in free.metadata['imports']] == ['unsafe']
+    
+    link_go_packages(chunks)
+    assert add.metadata['calls'] == [{'name': 'C.add', 'resolved': False}, {'name': 'C.int', 'resolved': False}]
+    assert free.metadata['calls'] == [{'name': 'C.free', 'resolved': False}]
+    print("✅ cgo files indexed")
+
+
+def test_receivers():
+    """Methods point at their receiver type, which lists them, across files"""
+    chunks = GoChunker().extract_chunks(FILE_A, 'shapes/a.go') + GoChunker().extract_chunks(FILE_B, 'shapes/b.go')
+    chunks += GoChunker().extract_chunks("package shapes\n\nfunc (t *Triangle) Area() float64 { return 0 }\n",
+                                         'shapes/c.go')
+    link_go_packages(chunks)
+    
+    circle = by_name(chunks, 'Circle')
+    assert [(m['name'], m['filepath'], m['pointer_receiver']) for m in circle.metadata['methods']] == [
+        ('Circle.Area', 'shapes/a.go', False), ('Circle.Name', 'shapes/b.go', False)], circle.metadata['methods']
+    name = next(c for c in chunks if c.name == 'Name' and c.parent_class == 'Circle')
+    assert name.metadata['owner']['name'] == 'Circle' and name.metadata['owner']['filepath'] == 'shapes/a.go'
+    assert name.metadata['owner']['symbol_id'] == circle.default_symbol_id()
+    
+    square = by_name(chunks, 'Square')
+    assert all(m['pointer_receiver'] for m in square.metadata['methods'])
+    assert 'methods' not in (by_name(chunks, 'Labeled').metadata or {}), "promoted methods are not declared ones"
+    
+    orphan = next(c for c in chunks if c.parent_class == 'Triangle')
+    assert orphan.metadata['owner'] == {'name': 'Triangle', 'resolved': False}
+    print("✅ Methods and receiver types linked both ways")
+
+
+def test_instantiations():
+    """Explicit instantiations of generics are recorded on the user and the generic"""
+    generic = """package session
+
+type SessionManager[T any] struct {
+    sessions map[string]T
+}
+
+func NewSessionManager[T any]() *SessionManager[T] {
+    return &SessionManager[T]{sessions: map[string]T{}}
+}
+
+func (sm *SessionManager[T]) Get(id string) T {
+    return sm.sessions[id]
+}
+
+func Map[T, U any](xs []T, f func(T) U) []U {
+    return nil
+}
+
+type Registry struct {
+    users *SessionManager[User]
+}
+"""
+    app = """package app
+
+import "example.com/session"
+
+var admins = session.NewSessionManager[Admin]()
+
+func Run(list []User, name func(User) string, arr []int, i int) {
+    users := session.NewSessionManager[User]()
+    counts := session.SessionManager[map[string]int]{}
+    names := session.Map[User, string](list, name)
+    inferred := session.Map(list, name)
+    again := session.NewSessionManager[User]()
+    first := arr[i]
+}
+"""
+    chunks = GoChunker().extract_chunks(generic, 'session/session.go') + GoChunker().extract_chunks(app, 'app/app.go')
+    link_go_packages(chunks)
+    
+    run = by_name(chunks, 'Run')
+    assert [(i['instance'], i['type_args']) for i in run.metadata['instantiations']] == [
+        ('session.NewSessionManager[User]', ['User']),
+        ('session.SessionManager[map[string]int]', ['map[string]int']),
+        ('session.Map[User, string]', ['User', 'string'])], run.metadata['instantiations']
+    new = by_name(chunks, 'NewSessionManager')
+    assert run.metadata['instantiations'][0]['generic']['symbol_id'] == new.default_symbol_id()
+    assert run.metadata['instantiations'][0]['line'] == run.line_start + 1
+    assert by_name(chunks, 'Registry').metadata['instantiations'][0]['instance'] == 'SessionManager[User]'
+    
+    assert [(site['name'], site['instance']) for site in new.metadata['instantiated_by']] == [
+        ('admins', 'session.NewSessionManager[Admin]'), ('Run', 'session.NewSessionManager[User]')]
+    manager = by_name(chunks, 'SessionManager')
+    assert [site['name'] for site in manager.metadata['instantiated_by']] == ['Registry', 'Run']
+    assert 'instantiations' not in new.metadata, "the generic's own type parameters are not an instantiation"
+    assert 'instantiations' not in by_name(chunks, 'Get').metadata
+    print("✅ Generic instantiations linked with their type arguments")
+
+
+def test_doc_links():
+    """Doc links and mentions of known symbols are resolved, and recorded both ways"""
+    auth = """package auth
+
+// Authenticator checks credentials. See [AdminUser.Authenticate] and [*bytes.Buffer].
+type Authenticator interface {
+    Authenticate() bool
+}
+
+// AdminUser is an [Authenticator] backed by `users`; the Session it opens
+// is described in [session.Manager].
+//
+// [Go docs]: https://go.dev/doc/comment
+type AdminUser struct{}
+
+// Authenticate implements Authenticator for admins; AdminUser keeps no state.
+func (a *AdminUser) Authenticate() bool { return true }
+
+var users = map[string]bool{}
+
+// Reset clears the users and returns the Default value of session.NewManager
+func Reset() {}
+"""
+    session = """package session
+
+// Manager keeps sessions; see [auth.AdminUser] for the logins it stores.
+type Manager struct{}
+
+func NewManager() *Manager { return &Manager{} }
+"""
+    chunks = GoChunker().extract_chunks(auth, 'auth/auth.go') + GoChunker().extract_chunks(session, 'session/session.go')
+    link_go_packages(chunks)
+    documented = {chunk.name: chunk for chunk in chunks if chunk.doc}
+    refs = lambda name: [(r['name'], r['resolved'], r['form']) for r in documented[name].metadata.get('doc_refs', [])]
+    
+    assert refs('Authenticator') == [('AdminUser.Authenticate', True, 'link'), ('bytes.Buffer', False, 'link')], \
+        refs('Authenticator')
+    assert refs('AdminUser') == [('Authenticator', True, 'link'), ('Manager', True, 'link'), ('users', True, 'mention')], \
+        refs('AdminUser')
+    assert refs('Authenticate') == [('Authenticator', True, 'mention'), ('AdminUser', True, 'mention')], \
+        "bare names count when exported, and a method's doc does not refer to itself"
+    assert refs('Reset') == [('NewManager', True, 'mention')], "lowercase words are not mentions"
+    assert refs('Manager') == [('AdminUser', True, 'link')] and 'doc_refs' not in by_name(chunks, 'NewManager').metadata
+    
+    manager = by_name(chunks, 'Manager')
+    assert by_name(chunks, 'AdminUser').metadata['doc_refs'][1]['symbol_id'] == manager.default_symbol_id()
+    assert by_name(chunks, 'AdminUser').metadata['doc_refs'][1]['package'] == 'session'
+    admin = by_name(chunks, 'AdminUser').metadata['doc_referenced_by']
+    assert [ref['name'] for ref in admin] == ['AdminUser.Authenticate', 'Manager'], admin
+    assert [ref['name'] for ref in by_name(chunks, 'users').metadata['doc_referenced_by']] == ['AdminUser']
+    print("✅ Doc links and symbol mentions resolved")
+
+
+def test_sample_file():
+    """The comprehensive sample: AdminUser satisfies Authenticator through *AdminUser"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'complex.go'
+    chunks = GoChunker().extract_chunks(sample.read_text(), 'comprehensive/complex.go')
+    link_go_packages(chunks)
+    
+    admin = by_name(chunks, 'AdminUser')
+    assert admin.metadata['implements'] == ['Authenticator']
+    assert admin.metadata['implements_pointer_only'] == ['Authenticator']
+    assert by_name(chunks, 'User').metadata['implements'] == []
+    
+    manager = by_name(chunks, 'NewSessionManager')
+    assert manager.signature == 'func NewSessionManager[T any]() *SessionManager[T]', manager.signature
+    print("✅ Sample file linked")
+
+
+def test_package_summary():
+    """One summary chunk per package lists its exported API and references the members"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'complex.go'
+    chunker = GoChunker(package_clauses=True)
+    chunks = link_go_packages(chunker.extract_chunks(sample.read_text(), 'comprehensive/complex.go'))
+    
+    summary = by_name(chunks, 'complex', 'package')
+    members = [member['name'] for member in summary.metadata['members']]
+    for name in ('Authenticator', 'User', 'AdminUser', 'SessionManager', 'StatusCode', 'NewSessionManager'):
+        assert name in members, members
+        assert name in summary.content, summary.content
+    assert members.index('Authenticator') < members.index('Authenticator.Authenticate') < members.index('User'), members
+    assert summary.content.startswith('// Comprehensive Go test file') and 'package complex' in summary.content
+    assert summary.metadata['files'] == ['comprehensive/complex.go']
+    ref = next(member for member in summary.metadata['members'] if member['name'] == 'AdminUser')
+    assert ref['resolved'] and ref['type'] == 'struct' and ref['line'] == by_name(chunks, 'AdminUser').line_start
+    
+    # Several files: the package keeps a single summary
+    chunks = link_go_packages(chunker.extract_chunks(FILE_A, 'shapes/a.go') + chunker.extract_chunks(FILE_B, 'shapes/b.go'))
+    summaries = [chunk for chunk in chunks if chunk.type == 'package']
+    assert len(summaries) == 1 and summaries[0].metadata['files'] == ['shapes/a.go', 'shapes/b.go'], summaries
+    assert not any(chunk.type == 'package' for chunk in GoChunker().extract_chunks(FILE_A, 'shapes/a.go'))
+    print("✅ Package summaries list the exported API")
+
+
+def main():
+    print("=" * 70)
+    print("GO CHUNKER TEST")
+    print("=" * 70)
+    
+    tests = [
+        test_declarations, test_implements_across_files, test_interface_method_sets, test_promotions,
+        test_ambiguous_promotion, test_type_params, test_doc_comments, test_call_graph,
+        test_constants, test_anonymous_structs, test_string_cases, test_cgo, test_receivers, test_instantiations, test_sample_file,
+        test_package_summary, test_package_state, test_doc_links
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
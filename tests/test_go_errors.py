@@ -0,0 +1,171 @@
+#!/usr/bin/env python3
+"""
+Test script for Go error-handling metadata
+Functions and methods record whether they return an error and how they make,
+wrap, return and check errors; the returns_error and errors filter fields
+select them. Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from helpers import make_rag
+from utils.filter_expression import parse_filter
+
+STORE = '''package store
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    
+    pkgerrors "github.com/pkg/errors"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// NotFoundError names the missing key
+type NotFoundError struct {
+    Key string
+}
+
+func (e *NotFoundError) Error() string {
+    return "not found: " + e.Key
+}
+
+// Open opens the store file
+func Open(path string) (*Store, error) {
+    f, err := os.Open(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, ErrNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("open %s: %w", path, err)
+    }
+    return &Store{f: f}, nil
+}
+
+// Get reads the value of a key
+func (s *Store) Get(key string) ([]byte, error) {
+    if key == "" {
+        return nil, fmt.Errorf("empty key")
+    }
+    if s.closed {
+        return nil, &NotFoundError{Key: key}
+    }
+    return nil, pkgerrors.Wrap(io.EOF, "read value")
+}
+
+// Close closes both files
+func (s *Store) Close() error {
+    return errors.Join(s.f.Close(), s.log.Close())
+}
+
+// Len counts the keys
+func (s *Store) Len() int {
+    return len(s.keys)
+}
+'''
+
+SHADOWED = '''package count
+
+import "errors"
+
+func Count(errors []string) int {
+    var first error = errors.New("x")
+    _ = first
+    return len(errors)
+}
+'''
+
+
+def by_name(chunks):
+    return {chunk.qualified_name: chunk.metadata or {} for chunk in chunks}
+
+
+def test_patterns():
+    chunks = by_name(GoChunker().extract_chunks(STORE, "store/store.go"))
+    assert chunks['Open']['returns_error'] and chunks['Store.Get']['returns_error']
+    assert chunks['Store.Len']['returns_error'] is False, "no error return is recorded as such"
+    assert chunks['NotFoundError.Error']['returns_error'] is False
+    assert 'returns_error' not in chunks['NotFoundError'] and 'returns_error' not in chunks['ErrNotFound']
+    
+    assert chunks['Open']['error_handling'] == [
+        {'kind': 'check', 'call': 'errors.Is', 'target': 'fs.ErrNotExist', 'line': 27},
+        {'kind': 'sentinel', 'name': 'ErrNotFound', 'line': 28},
+        {'kind': 'wrap', 'call': 'fmt.Errorf', 'format': 'open %s: %w', 'line': 31},
+    ], chunks['Open']['error_handling']
+    assert chunks['Store.Get']['error_handling'] == [
+        {'kind': 'new', 'call': 'fmt.Errorf', 'format': 'empty key', 'line': 39},
+        {'kind': 'type', 'type': 'NotFoundError', 'line': 42},
+        {'kind': 'wrap', 'call': 'pkgerrors.Wrap', 'message': 'read value', 'line': 44},
+        {'kind': 'sentinel', 'name': 'io.EOF', 'line': 44},
+    ], chunks['Store.Get']['error_handling']
+    assert [r['kind'] for r in chunks['Store.Close']['error_handling']] == ['join']
+    assert 'error_handling' not in chunks['Store.Len']
+    print("✅ Error returns, wrapping calls, error types, sentinels and checks are recorded")
+
+
+def test_shadowing():
+    chunks = by_name(GoChunker().extract_chunks(SHADOWED, "count/count.go"))
+    assert chunks['Count']['returns_error'] is False
+    assert 'error_handling' not in chunks['Count'], "a parameter named errors is not the package"
+    print("✅ A local name that shadows the errors package is not an error call")
+
+
+def test_filters(workdir):
+    rag = make_rag(workdir, "errors")
+    rag.add_chunks_batch(GoChunker().extract_chunks(STORE, "store/store.go"))
+    
+    def names(expression):
+        results = rag.retrieve_context("store key value", n_results=20, filter_expr=expression)
+        return sorted(r['metadata']['name'] for r in results)
+    
+    assert names("returns_error=true") == ['Close', 'Get', 'Open'], names("returns_error=true")
+    assert names("returns_error=false") == ['Error', 'Len'], names("returns_error=false")
+    assert names("errors=wrap") == ['Get', 'Open']
+    assert names("errors=sentinel AND errors=check") == ['Open']
+    assert names("errors=NotFoundError") == ['Get'] and names("errors=ErrNotFound") == ['Open']
+    assert names("errors=*.Errorf") == ['Get', 'Open']
+    assert names({"errors": ["join", "type"]}) == ['Close', 'Get']
+    assert str(parse_filter("returns_error:TRUE AND NOT errors=new")) == "returns_error=TRUE AND NOT errors=new"
+    assert names("returns_error:TRUE AND NOT errors=new") == ['Close', 'Open']
+    print("✅ returns_error and errors filter to error-returning functions and their patterns")
+
+
+def main():
+    print("=" * 70)
+    print("GO ERROR HANDLING TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_go_errors_"))
+    tests = [
+        test_patterns,
+        test_shadowing,
+        lambda: test_filters(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,167 @@
+#!/usr/bin/env python3
+"""
+Test script for Go import resolution
+Each declaration must list the imported packages it refers to, classified as
+standard library, internal (under the go.mod module path) or third-party, and
+searches must be filterable by package or member.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, find_module_path, uses_dependency
+from chunkers.go_imports import classify_import, default_package_name
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+USER_SOURCE = '''package auth
+
+import (
+    "fmt"
+    "sync"
+    _ "embed"
+    log "github.com/sirupsen/logrus"
+    "github.com/pkg/errors"
+    "example.com/shop/internal/store"
+)
+
+// User is a logged-in account
+type User struct {
+    mu   sync.RWMutex
+    Name string
+}
+
+// Load reads a user from the store
+func Load(id string) (*User, error) {
+    row, err := store.Get(id)
+    if err != nil {
+        return nil, errors.Wrap(err, "load user")
+    }
+    log.Debug("loaded")
+    return &User{Name: row.Name}, nil
+}
+
+// Describe formats a user, with a local that shadows the fmt package
+func Describe(u *User) string {
+    fmt := "%s"
+    return strings(fmt, u.Name)
+}
+
+// Greet formats a greeting
+func Greet(name string) string {
+    return fmt.Sprintf("hello %s", name)
+}
+'''
+
+
+def imports_of(chunks, name):
+    chunk = next(chunk for chunk in chunks if chunk.name == name)
+    return (chunk.metadata or {}).get('imports', [])
+
+
+def test_referenced_imports():
+    chunker = GoChunker(module_path="example.com/shop")
+    chunks = chunker.extract_chunks(USER_SOURCE, "auth/user.go")
+    assert [imp.path for imp in chunker.imports] == [
+        'fmt', 'sync', 'embed', 'github.com/sirupsen/logrus', 'github.com/pkg/errors', 'example.com/shop/internal/store'
+    ], chunker.imports
+    
+    assert imports_of(chunks, 'User') == [
+        {'path': 'sync', 'name': 'sync', 'class': 'stdlib', 'symbols': ['RWMutex']}
+    ], imports_of(chunks, 'User')
+    load = imports_of(chunks, 'Load')
+    assert [(entry['path'], entry['class'], entry['symbols']) for entry in load] == [
+        ('github.com/sirupsen/logrus', 'third_party', ['Debug']),
+        ('github.com/pkg/errors', 'third_party', ['Wrap']),
+        ('example.com/shop/internal/store', 'internal', ['Get']),
+    ], load
+    assert load[0]['name'] == 'log', "an alias is the name the package is used by"
+    assert imports_of(chunks, 'Describe') == [], "a local named fmt shadows the package"
+    assert [entry['path'] for entry in imports_of(chunks, 'Greet')] == ['fmt']
+    print("✅ Declarations list the imports they refer to, classified")
+
+
+def test_names_and_classes(workdir):
+    assert default_package_name("gopkg.in/yaml.v3") == "yaml"
+    assert default_package_name("github.com/mattn/go-sqlite3") == "sqlite3"
+    assert default_package_name("github.com/jackc/pgx/v5") == "pgx"
+    assert classify_import("net/http") == "stdlib"
+    assert classify_import("golang.org/x/sync/errgroup") == "third_party"
+    assert classify_import("shop/internal/store", "shop") == "internal"
+    assert classify_import("shopping/cart", "shop") == "stdlib", "a module path is a prefix of whole elements"
+    
+    module = workdir / "module"
+    (module / "cmd" / "server").mkdir(parents=True)
+    (module / "go.mod").write_text('// The shop\nmodule example.com/shop\n\ngo 1.22\n')
+    assert find_module_path(str(module / "cmd" / "server")) == "example.com/shop"
+    print("✅ Package names follow the import path conventions, modules come from go.mod")
+
+
+def test_uses_dependency():
+    imports = [{'path': 'github.com/pkg/errors', 'name': 'errors', 'class': 'third_party', 'symbols': ['Wrap']}]
+    for dependency in ('github.com/pkg/errors', 'errors', 'errors.Wrap', 'github.com/pkg/errors.Wrap'):
+        assert uses_dependency(imports, dependency), dependency
+    for dependency in ('errors.New', 'github.com/pkg', 'sync.RWMutex'):
+        assert not uses_dependency(imports, dependency), dependency
+    print("✅ Dependencies match by path, name or member")
+
+
+def test_search_filter(workdir):
+    source = workdir / "shop"
+    (source / "auth").mkdir(parents=True)
+    (source / "go.mod").write_text("module example.com/shop\n")
+    (source / "auth" / "user.go").write_text(USER_SOURCE)
+    
+    rag = make_rag(workdir, "imports")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db")))
+    indexer.index_directory(str(source), parallel=False)
+    
+    def found(**filters):
+        return sorted(r['metadata']['name'] for r in rag.retrieve_context("user", n_results=10, **filters))
+    
+    assert found(uses=["sync.RWMutex"]) == ['User']
+    assert found(uses=["errors.Wrap", "fmt"]) == ['Greet', 'Load']
+    assert found(uses=["example.com/shop/internal/store"]) == ['Load']
+    assert found(uses=["sync.Mutex"]) == []
+    stored = rag.collection.get(where={"name": "Load"})['metadatas'][0]
+    assert '"class": "internal"' in stored['metadata'], "the module path comes from the indexed go.mod"
+    print("✅ Searches can be limited to code using a package or member")
+
+
+def main():
+    print("=" * 70)
+    print("GO IMPORT RESOLUTION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="go_imports_"))
+    
+    tests = [
+        test_referenced_imports,
+        lambda: test_names_and_classes(workdir),
+        test_uses_dependency,
+        lambda: test_search_filter(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
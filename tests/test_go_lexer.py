@@ -0,0 +1,130 @@
+#!/usr/bin/env python3
+"""
+Fuzz test for the Go lexer the chunk analyses run on
+Truncated and randomly mutated Go sources must tokenize quickly into tokens
+that match the source, and the bracket helpers and constant evaluator on top
+of them must terminate without crashing. Runs without tree-sitter
+"""
+
+import random
+import sys
+import time
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.go_constants import Unevaluable, evaluate_constant
+from chunkers.go_lexer import match_bracket, split_top_level, tokenize_go
+
+
+SAMPLE = (Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'complex.go').read_text()
+
+# Pieces that open or close literals, comments and brackets: where lexers go wrong
+FRAGMENTS = ['"', '`', "'", '/*', '*/', '//', '\\', '\n', '(', ')', '[', ']', '{', '}', '0x', '1e', '.5',
+             'func', 'iota', '<<', '&^=', '...', ':=', 'é', '\x00']
+
+# No input may take longer than this to go through the lexer and the helpers
+MAX_SECONDS = 1.0
+
+
+def mutations(source, generator, count):
+    """Copies of source with random slices deleted, duplicated or replaced by fragments"""
+    for _ in range(count):
+        text = source
+        for _ in range(generator.randint(1, 8)):
+            start = generator.randrange(len(text) + 1)
+            end = min(len(text), start + generator.randint(0, 40))
+            action = generator.choice(('delete', 'duplicate', 'insert'))
+            if action == 'delete':
+                text = text[:start] + text[end:]
+            elif action == 'duplicate':
+                text = text[:end] + text[start:end] + text[end:]
+            else:
+                text = text[:start] + ''.join(generator.choice(FRAGMENTS) for _ in range(3)) + text[start:]
+        yield text
+
+
+def check(text):
+    """Tokenize text and run the helpers on the tokens, asserting the tokens are sound"""
+    started = time.monotonic()
+    tokens, comments = tokenize_go(text)
+    
+    position = 0
+    for token in tokens:
+        assert position <= token.start <= token.end <= len(text), (token, position)
+        if token.kind == ';' and token.value == '\n':
+            assert token.start == token.end, "an inserted semicolon has no text"
+        else:
+            assert text[token.start:token.end] == token.value, token
+        assert token.line == text.count('\n', 0, token.start) + 1, token
+        position = token.end
+    for comment in comments:
+        assert text[comment.start:comment.end] == comment.text and comment.line_start <= comment.line_end
+    
+    for index, token in enumerate(tokens):
+        if token.value in ('(', '[', '{'):
+            assert index <= match_bracket(tokens, index) < len(tokens)
+    assert sum(len(part) for part in split_top_level(tokens)) <= len(tokens)
+    for part in split_top_level(tokens)[:50]:
+        try:
+            evaluate_constant(part, 0, lambda name: None, lambda name: None)
+        except Unevaluable:
+            pass
+    
+    elapsed = time.monotonic() - started
+    assert elapsed < MAX_SECONDS, f"{elapsed:.2f}s for {len(text)} characters"
+
+
+def test_sample_tokens():
+    tokens, comments = tokenize_go(SAMPLE)
+    assert tokens[0].value == 'package' and tokens[0].kind == 'keyword'
+    assert any(token.kind == ';' for token in tokens), "newlines insert semicolons"
+    assert comments, "comments are kept apart"
+    check(SAMPLE)
+    print("✅ The sample tokenizes into tokens matching its source")
+
+
+def test_truncated_sources():
+    for end in range(0, len(SAMPLE), 7):
+        check(SAMPLE[:end])
+    print("✅ Every prefix of the sample tokenizes")
+
+
+def test_mutated_sources():
+    generator = random.Random(43)
+    for text in mutations(SAMPLE, generator, 300):
+        check(text)
+    print("✅ Randomly mutated sources tokenize, and the helpers terminate on them")
+
+
+def test_random_text():
+    generator = random.Random(7)
+    alphabet = FRAGMENTS + list('abcxyz019 \t+-*/%&|^<>=!.,;:~_')
+    for _ in range(300):
+        check(''.join(generator.choice(alphabet) for _ in range(generator.randint(0, 200))))
+    print("✅ Random character soup tokenizes")
+
+
+def main():
+    print("=" * 70)
+    print("GO LEXER FUZZ TEST")
+    print("=" * 70)
+    
+    tests = [test_sample_tokens, test_truncated_sources, test_mutated_sources, test_random_text]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,263 @@
+#!/usr/bin/env python3
+"""
+Test script for the string forms of Go constants
+Constants are linked to their type's String method, and get the text it
+returns for them, whether the method is a switch, indexes a table of strings
+or was generated by stringer (in another file of the package); the text is
+embedded with the constant, so searching for it finds the constant.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import parse_metadata
+from chunkers.go_chunker import GoChunker
+from chunkers.go_package_linker import link_go_packages
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+STATUS_GO = '''package status
+
+// StatusCode is the outcome of a request
+type StatusCode int
+
+const (
+    Success      StatusCode = iota // request succeeded
+    Unauthorized                   // login required
+    Forbidden                      // access denied
+)
+
+// Retry tells the client to try again
+func Retry(code StatusCode) bool {
+    return code == Forbidden
+}
+'''
+
+STATUS_STRING_GO = '''// Code generated by "stringer -type=StatusCode -linecomment"; DO NOT EDIT.
+
+package status
+
+import "strconv"
+
+func _() {
+    // An "invalid array index" compiler error signifies that the constant values have changed.
+    // Re-run the stringer command to generate them again.
+    var x [1]struct{}
+    _ = x[Success-0]
+    _ = x[Unauthorized-1]
+    _ = x[Forbidden-2]
+}
+
+const _StatusCode_name = "request succeededlogin requiredaccess denied"
+
+var _StatusCode_index = [...]uint8{0, 17, 31, 44}
+
+func (i StatusCode) String() string {
+    if i < 0 || i >= StatusCode(len(_StatusCode_index)-1) {
+        return "StatusCode(" + strconv.FormatInt(int64(i), 10) + ")"
+    }
+    return _StatusCode_name[_StatusCode_index[i]:_StatusCode_index[i+1]]
+}
+'''
+
+LEVEL_GO = '''package log
+
+type Level int
+
+const (
+    Debug Level = iota + 1
+    Info
+    Warn
+    Error Level = 10
+    Fatal Level = 11
+    Panic Level = 20
+)
+
+const (
+    _Level_name_0 = "DebugInfoWarn"
+    _Level_name_1 = "ErrorFatal"
+    _Level_name_2 = "Panic"
+)
+
+var (
+    _Level_index_0 = [...]uint8{0, 5, 9, 13}
+    _Level_index_1 = [...]uint8{0, 5, 10}
+)
+
+func (i Level) String() string {
+    switch {
+    case 1 <= i && i <= 3:
+        i -= 1
+        return _Level_name_0[_Level_index_0[i]:_Level_index_0[i+1]]
+    case 10 <= i && i <= 11:
+        i -= 10
+        return _Level_name_1[_Level_index_1[i]:_Level_index_1[i+1]]
+    case i == 20:
+        return _Level_name_2
+    default:
+        return "Level(" + strconv.FormatInt(int64(i), 10) + ")"
+    }
+}
+
+type Signal int
+
+const (
+    Hup  Signal = 1
+    Int  Signal = 2
+    Kill Signal = 9
+)
+
+const _Signal_name = "hangupinterruptkilled"
+
+var _Signal_map = map[Signal]string{
+    1: _Signal_name[0:6],
+    2: _Signal_name[6:15],
+    9: _Signal_name[15:21],
+}
+
+func (i Signal) String() string {
+    if str, ok := _Signal_map[i]; ok {
+        return str
+    }
+    return "Signal(" + strconv.FormatInt(int64(i), 10) + ")"
+}
+'''
+
+TABLES_GO = '''package shapes
+
+type Color int
+
+const (
+    Red Color = iota
+    Green
+    Blue
+)
+
+var colorNames = map[Color]string{
+    Red:   "crimson",
+    Green: "emerald",
+}
+
+func (c Color) String() string { return colorNames[c] }
+
+type Weekday int
+
+const (
+    Monday Weekday = iota + 1
+    Tuesday
+    Wednesday
+)
+
+var dayNames = [...]string{"Mon", "Tue", "Wed"}
+
+func (d Weekday) String() string { return dayNames[int(d)-1] }
+
+type Shape int
+
+const (
+    Circle Shape = iota
+    Square
+)
+
+// The tables were generated before Square was added
+const _Shape_name = "Circle"
+
+var _Shape_index = [...]uint8{0, 6}
+
+func (s Shape) String() string { return "shape" }
+'''
+
+
+def linked(*files):
+    """Chunks of (path, source) pairs after the package linker, by name"""
+    chunks = []
+    for path, source in files:
+        chunks.extend(GoChunker().extract_chunks(source, path))
+    return {(c.parent_class + '.' if c.parent_class else '') + c.name: c for c in link_go_packages(chunks)}
+
+
+def test_generated():
+    chunks = linked(('status/status.go', STATUS_GO), ('status/statuscode_string.go', STATUS_STRING_GO))
+    strings = {name: chunks[name].metadata.get('string') for name in ('Success', 'Unauthorized', 'Forbidden')}
+    assert strings == {'Success': 'request succeeded', 'Unauthorized': 'login required',
+                       'Forbidden': 'access denied'}, strings
+    unauthorized = chunks['Unauthorized']
+    assert unauthorized.metadata['string_method']['filepath'] == 'status/statuscode_string.go'
+    assert unauthorized.context == 'const Unauthorized StatusCode = 1 // String(): "login required"', unauthorized.context
+    
+    method = chunks['StatusCode.String']
+    assert method.metadata['stringer'] == 'generated'
+    assert [ref['name'] for ref in method.metadata['cases']] == ['Success', 'Unauthorized', 'Forbidden']
+    
+    chunks = linked(('log/level.go', LEVEL_GO))
+    assert {name: chunks[name].metadata.get('string') for name in ('Debug', 'Warn', 'Error', 'Fatal', 'Panic')} == \
+        {'Debug': 'Debug', 'Warn': 'Warn', 'Error': 'Error', 'Fatal': 'Fatal', 'Panic': 'Panic'}
+    assert {name: chunks[name].metadata.get('string') for name in ('Hup', 'Int', 'Kill')} == \
+        {'Hup': 'hangup', 'Int': 'interrupt', 'Kill': 'killed'}, "stringer's map for many runs"
+    print("✅ The tables stringer generates give each constant its text, in any file of the package")
+
+
+def test_tables():
+    chunks = linked(('shapes/shapes.go', TABLES_GO))
+    assert chunks['Red'].metadata['string'] == 'crimson' and chunks['Green'].metadata['string'] == 'emerald'
+    assert 'string_method' not in chunks['Blue'].metadata, "not in the map, so not covered"
+    assert chunks['Color.String'].metadata['stringer'] == 'table'
+    assert [chunks[name].metadata.get('string') for name in ('Monday', 'Tuesday', 'Wednesday')] == \
+        ['Mon', 'Tue', 'Wed'], "positional entries, indexed by the value less one"
+    
+    assert 'string' not in chunks['Circle'].metadata, "stale generated tables are not read"
+    assert 'stringer' not in (chunks['Shape.String'].metadata or {})
+    print("✅ Maps and arrays of strings the String method indexes give the constants their text")
+
+
+def test_search(workdir):
+    source = workdir / "tree"
+    (source / "status").mkdir(parents=True)
+    (source / "status" / "status.go").write_text(STATUS_GO)
+    (source / "status" / "statuscode_string.go").write_text(STATUS_STRING_GO)
+    
+    rag = make_rag(workdir, "stringer")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "stringer_state.db")))
+    indexer.index_directory(str(source), parallel=False)
+    
+    results = rag.retrieve_context("login required status text", n_results=1, kinds=['const'])
+    assert results and results[0]['metadata']['name'] == 'Unauthorized', [r['metadata']['name'] for r in results]
+    assert parse_metadata(results[0]['metadata']['metadata'])['string'] == 'login required'
+    print("✅ Searching for a constant's text finds the constant")
+
+
+def main():
+    print("=" * 70)
+    print("GO STRINGER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_go_stringer_"))
+    tests = [
+        test_generated,
+        test_tables,
+        lambda: test_search(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,145 @@
+#!/usr/bin/env python3
+"""
+Test script for Go struct tags
+Struct fields keep their tags as key/value pairs per namespace, read the way
+reflect.StructTag reads them; the tag filter field selects structs by the
+names their tags map fields to, and a search for a tagged name finds the
+struct. Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import parse_metadata
+from chunkers.go_chunker import GoChunker
+from chunkers.go_struct_tags import matches_tag, parse_struct_tag, tag_name, tagged_names
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+USER_GO = '''package account
+
+import "time"
+
+// User is an account as the API and the database see it
+type User struct {
+    ID       int64     `json:"id" db:"user_id"`
+    Username string    `json:"username,omitempty" db:"login" validate:"required,min=3"`
+    Password string    `json:"-"`
+    Created  time.Time "json:\\"created_at\\""
+    Audit
+    Email, Backup string `json:"email"`
+}
+
+// Audit records who changed a row
+type Audit struct {
+    ChangedBy string `db:"changed_by"`
+}
+
+// Session tracks a login
+type Session struct {
+    Token   string
+    Expires time.Time
+}
+'''
+
+
+def test_parse():
+    assert parse_struct_tag('`json:"username,omitempty" db:"login"`') == {'json': 'username,omitempty', 'db': 'login'}
+    assert list(parse_struct_tag('`b:"2" a:"1"`')) == ['b', 'a'], "namespaces keep their order"
+    assert parse_struct_tag('"json:\\"created_at\\""') == {'json': 'created_at'}, "interpreted string literals"
+    assert parse_struct_tag('`re:"a\\"b" x:"\\u00e9"`') == {'re': 'a"b', 'x': 'é'}, "values are Go strings"
+    assert parse_struct_tag('`json:"id"  db:"user_id"`') == {'json': 'id', 'db': 'user_id'}
+    assert parse_struct_tag('`json:"id" broken db:"x"`') == {'json': 'id'}, "the rest of a malformed tag is dropped"
+    assert parse_struct_tag('`json:"id`') == {} and parse_struct_tag('`just text`') == {}
+    assert tag_name('username,omitempty') == 'username' and tag_name(',omitempty') == ''
+    print("✅ Tags are read as reflect.StructTag reads them, every namespace kept")
+
+
+def test_fields():
+    chunks = {c.name: c for c in GoChunker().extract_chunks(USER_GO, 'account/user.go')}
+    fields = {f['name']: f for f in chunks['User'].metadata['fields']}
+    assert list(fields) == ['ID', 'Username', 'Password', 'Created', 'Audit', 'Email', 'Backup'], list(fields)
+    assert fields['Username']['tags'] == {'json': 'username,omitempty', 'db': 'login',
+                                          'validate': 'required,min=3'}
+    assert fields['Username']['tag'] == '`json:"username,omitempty" db:"login" validate:"required,min=3"`'
+    assert fields['Created']['tags'] == {'json': 'created_at'} and fields['Audit']['tags'] == {}
+    assert fields['Email']['tags'] == fields['Backup']['tags'] == {'json': 'email'}, "one tag for every name"
+    assert chunks['Session'].metadata['fields'][0]['tags'] == {}
+    
+    names = tagged_names(chunks['User'].metadata['fields'])
+    assert ('Password', 'json') not in [(n['field'], n['key']) for n in names], '"-" maps no name'
+    assert {'field': 'Username', 'key': 'db', 'name': 'login'} in names
+    assert not any(n['key'] == 'validate' and n['field'] == 'ID' for n in names)
+    print("✅ Struct fields carry their tags as key/value pairs per namespace")
+
+
+def test_filter():
+    fields = {c.name: c.metadata['fields'] for c in GoChunker().extract_chunks(USER_GO, 'account/user.go')
+              if c.type == 'struct'}
+    assert matches_tag(fields['User'], 'username') and matches_tag(fields['User'], 'login')
+    assert matches_tag(fields['User'], 'json:user*') and not matches_tag(fields['User'], 'db:username')
+    assert matches_tag(fields['Audit'], 'db:changed_*') and not matches_tag(fields['Session'], '*')
+    assert not matches_tag(fields['User'], 'Username'), "field names are not tag names"
+    print("✅ The tag filter matches names tags map fields to, in any or one namespace")
+
+
+def test_search(workdir):
+    source = workdir / "tree"
+    (source / "account").mkdir(parents=True)
+    (source / "account" / "user.go").write_text(USER_GO)
+    
+    rag = make_rag(workdir, "struct_tags")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "struct_tags_state.db")))
+    indexer.index_directory(str(source), parallel=False)
+    rag._build_keyword_index()
+    
+    top = rag.retrieve_context("field mapped to json username", n_results=1)[0]
+    assert top['metadata']['name'] == 'User', top['metadata']['name']
+    lines = top['content'].split('\n')
+    assert any('Username' in lines[span['line'] - 1] for span in top['highlights'] if span['term'] == 'username')
+    fields = parse_metadata(top['metadata']['metadata'])['fields']
+    assert fields[1]['tags']['json'] == 'username,omitempty'
+    
+    found = [r['metadata']['name'] for r in rag.retrieve_context("account", n_results=5, filter_expr='tag="db:*_by"')]
+    assert found == ['Audit'], found
+    found = sorted(r['metadata']['name'] for r in rag.retrieve_context("account", n_results=5,
+                                                                       filter_expr='type=struct AND NOT tag=*'))
+    assert found == ['Session'], found
+    print("✅ Searching for a tagged name finds the struct, and structs filter by their tags")
+
+
+def main():
+    print("=" * 70)
+    print("GO STRUCT TAGS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_go_struct_tags_"))
+    tests = [
+        test_parse,
+        test_fields,
+        test_filter,
+        lambda: test_search(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
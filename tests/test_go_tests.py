@@ -0,0 +1,179 @@
+#!/usr/bin/env python3
+"""
+Test script for Go test function detection: kinds of Test/Benchmark/Fuzz/Example
+functions, their subjects, and searching for them
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from chunkers import GoChunker, go_test_function, tag_go_tests
+from helpers import make_chroma_rag
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+AUTH_TEST = '''package auth
+
+import (
+    "fmt"
+    "testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+    user := NewAdminUser("admin", 1)
+    if !user.Authenticate("admin", "password1") {
+        t.Fatal("authenticate failed")
+    }
+}
+
+func TestAdminUser_Logout(t *testing.T) {}
+
+func TestAuthenticate_wrongPassword(t *testing.T) {}
+
+func BenchmarkAuthenticate(b *testing.B) {
+    for i := 0; i < b.N; i++ {
+        NewAdminUser("admin", 1).Authenticate("admin", "password1")
+    }
+}
+
+func FuzzParseToken(f *testing.F) {
+    f.Fuzz(func(t *testing.T, token string) {})
+}
+
+func ExampleAdminUser_Authenticate() {
+    user := NewAdminUser("admin", 1)
+    fmt.Println(user.Authenticate("admin", "password1"))
+    // Output: true
+}
+
+func Example_sessions() {}
+
+func TestMain(m *testing.M) {}
+
+func Testify(t *testing.T) {}
+
+func TestHelper(name string) {}
+
+func newFixture() *AdminUser { return NewAdminUser("fixture", 2) }
+'''
+
+
+def auth_test_chunks():
+    return tag_go_tests(GoChunker().extract_chunks(AUTH_TEST, 'auth/complex_test.go'))
+
+
+def test_kinds():
+    """Test, benchmark, fuzz and example functions get their kind; the rest of the file is 'test'"""
+    kinds = {c.name: c.kind for c in auth_test_chunks()}
+    assert kinds == {
+        'TestAuthenticate': 'test', 'TestAdminUser_Logout': 'test', 'TestAuthenticate_wrongPassword': 'test',
+        'BenchmarkAuthenticate': 'benchmark', 'FuzzParseToken': 'fuzz', 'ExampleAdminUser_Authenticate': 'example',
+        'Example_sessions': 'example', 'TestMain': 'test', 'Testify': 'test', 'TestHelper': 'test',
+        'newFixture': 'test',
+    }, kinds
+    
+    by_name = {c.name: c for c in auth_test_chunks()}
+    for name in ('TestMain', 'Testify', 'TestHelper', 'newFixture'):
+        assert go_test_function(by_name[name]) is None, f"{name} is not a test function"
+    source = GoChunker().extract_chunks((SAMPLES / "complex.go").read_text(), 'auth/complex.go')
+    assert all(go_test_function(c) is None for c in source)
+    print("✅ Go test functions tagged by kind")
+
+
+def test_subjects():
+    """The symbol under test comes from the name, by the example naming convention"""
+    subjects = {c.name: (c.metadata or {}).get('subject') for c in auth_test_chunks()}
+    assert subjects['TestAuthenticate'] == 'Authenticate'
+    assert subjects['TestAdminUser_Logout'] == 'AdminUser.Logout'
+    assert subjects['TestAuthenticate_wrongPassword'] == 'Authenticate', "a lowercase suffix names a case"
+    assert subjects['BenchmarkAuthenticate'] == 'Authenticate' and subjects['FuzzParseToken'] == 'ParseToken'
+    assert subjects['ExampleAdminUser_Authenticate'] == 'AdminUser.Authenticate'
+    assert subjects['Example_sessions'] is None and subjects['TestMain'] is None
+    print("✅ Subjects inferred from test names")
+
+
+def test_search(workdir):
+    """Examples and tests are selected, excluded and boosted by kind, and found by their subject"""
+    rag = make_chroma_rag(workdir / "db", "test_go_tests")
+    source = GoChunker().extract_chunks((SAMPLES / "complex.go").read_text(), 'auth/complex.go')
+    rag.add_chunks_batch(source + auth_test_chunks())
+    rag._build_keyword_index()
+    
+    def names(query, n_results=20, **options):
+        return [r['metadata']['name'] for r in rag.retrieve_context(query, n_results=n_results, **options)]
+    
+    examples = names("authenticate", kinds=['example'])
+    assert examples == ['ExampleAdminUser_Authenticate', 'Example_sessions'], examples
+    assert sorted(names("authenticate", kinds=['benchmark', 'fuzz'])) == ['BenchmarkAuthenticate', 'FuzzParseToken']
+    assert not set(names("authenticate admin password", exclude_tests=True)) & {c.name for c in auth_test_chunks()}
+    assert names("authenticate", filter_expr="kind=example AND name=Example*Authenticate") == \
+        ['ExampleAdminUser_Authenticate']
+    
+    tests = names("Logout", kinds=['test'])
+    assert tests[0] == 'TestAdminUser_Logout', tests
+    
+    plain = names("authenticate admin password", n_results=100)
+    boosted = names("authenticate admin password", n_results=100, boost_kinds={'fuzz': 50.0})
+    assert boosted[0] == 'FuzzParseToken' and plain.index('FuzzParseToken') > 5, (plain, boosted)
+    assert sorted(plain) == sorted(boosted), "boosting reorders without filtering"
+    
+    result = rag.retrieve_context("authenticate admin password", n_results=1, boost_kinds={'example': 5.0},
+                                  explain=True)[0]
+    assert result['boost'] == 5.0 and result['explain']['fused']['boost'] == 5.0
+    streamed = [r['metadata']['name'] for r in rag.iter_context("authenticate admin password", n_results=1,
+                                                                 boost_kinds={'example': 5.0})]
+    assert streamed == ['ExampleAdminUser_Authenticate']
+    
+    for bad in ({'example': 0}, {'example': 'high'}, {'example': True}):
+        try:
+            rag.retrieve_context("authenticate", boost_kinds=bad)
+            assert False, f"{bad} should be rejected"
+        except ValueError as e:
+            assert "must be a positive number" in str(e)
+    print("✅ Test kinds filter and boost retrieval")
+
+
+def test_cli_boosts():
+    """--boost KIND=FACTOR values"""
+    assert cli.parse_boosts(['example=2', 'method=1.5']) == {'example': 2.0, 'method': 1.5}
+    assert cli.parse_boosts(None) == {}
+    for spec in ('example', 'example=fast', '=2', 'example=-1'):
+        try:
+            cli.parse_boosts([spec])
+            assert False, f"{spec} should be rejected"
+        except ValueError as e:
+            assert "KIND=FACTOR" in str(e), str(e)
+    print("✅ --boost parsed and validated")
+
+
+def main():
+    print("=" * 70)
+    print("GO TEST FUNCTION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_go_tests_"))
+    
+    tests = [test_kinds, test_subjects, lambda: test_search(workdir), test_cli_boosts]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
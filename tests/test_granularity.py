@@ -0,0 +1,171 @@
+#!/usr/bin/env python3
+"""
+Test script for chunk granularity modes: whole files, symbols, and
+symbols prefixed with their file's imports
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import (
+    GoChunker, Granularity, apply_granularity, parse_granularity,
+    split_oversized_chunks, stitch_parts
+)
+from chunkers.granularity import extract_import_block
+from indexer import process_file_worker
+
+
+GO_FILE = '''package store
+
+import (
+    "database/sql"
+    "fmt"
+)
+
+// Store wraps a database handle.
+type Store struct{ db *sql.DB }
+
+// Get loads one value.
+func (s *Store) Get(key string) (string, error) {
+    var value string
+    err := s.db.QueryRow("SELECT v FROM kv WHERE k = ?", key).Scan(&value)
+    return value, fmt.Errorf("get %s: %w", key, err)
+}
+'''
+
+PYTHON_FILE = '''"""Helpers."""
+import os
+from typing import (
+    Dict,
+    List,
+)
+
+def walk(root):
+    import json  # local imports stay out of the block
+    return os.listdir(root)
+'''
+
+
+def chunks_of(code: str, granularity):
+    chunks = GoChunker().extract_chunks(code, 'store/store.go')
+    return apply_granularity(chunks, code, 'store/store.go', 'go', granularity)
+
+
+def test_parse_granularity():
+    assert parse_granularity(None) == Granularity.SYMBOL
+    assert parse_granularity('File') == Granularity.FILE
+    assert parse_granularity('SymbolWithImports') == Granularity.SYMBOL_WITH_IMPORTS
+    assert parse_granularity('symbol-with-imports') == Granularity.SYMBOL_WITH_IMPORTS
+    try:
+        parse_granularity('line')
+        assert False, "unknown granularity accepted"
+    except ValueError as e:
+        assert 'symbol_with_imports' in str(e)
+    print("✅ Granularity names parsed")
+
+
+def test_import_blocks():
+    """Multi-line imports are kept whole; nested imports are not part of the block"""
+    assert extract_import_block(GO_FILE, 'go') == 'package store\n\nimport (\n    "database/sql"\n    "fmt"\n)'.replace('\n\n', '\n')
+    assert extract_import_block(PYTHON_FILE, 'python') == 'import os\nfrom typing import (\n    Dict,\n    List,\n)'
+    js = "import React from 'react';\nimport {\n  a,\n  b,\n} from './x';\nconst fs = require('fs');\n\nfunction f() {}\n"
+    assert extract_import_block(js, 'javascript') == "import React from 'react';\nimport {\n  a,\n  b,\n} from './x';\nconst fs = require('fs');"
+    print("✅ Import blocks extracted")
+
+
+def test_symbol_is_default():
+    """Symbol mode leaves the chunker's output untouched"""
+    chunks = chunks_of(GO_FILE, None)
+    assert [c.name for c in chunks] == ['Store', 'Get']
+    assert all(c.context is None for c in chunks)
+    print("✅ Symbol mode keeps per-symbol chunks")
+
+
+def test_symbol_with_imports():
+    """Functions and methods carry the import block; line numbers still match the body"""
+    chunks = split_oversized_chunks(chunks_of(GO_FILE, 'symbol_with_imports'), max_tokens=512)
+    store, get = chunks
+    assert not store.content.startswith('package'), "types are not prefixed"
+    assert get.content.startswith('package store\nimport (\n    "database/sql"'), get.content
+    assert get.content.endswith('return value, fmt.Errorf("get %s: %w", key, err)\n}')
+    assert get.line_start == 12
+    header = get.metadata['header_chars']
+    assert get.content[header:].startswith('func (s *Store) Get')
+    print("✅ Functions prefixed with their file's imports")
+
+
+def test_prefix_survives_splitting():
+    """Every part of a split function starts with the imports; stitching drops them"""
+    body = '\n'.join(f'    total += lookup("key{i}")' for i in range(120))
+    code = GO_FILE + f'\nfunc Sum() int {{\n    total := 0\n{body}\n    return total\n}}\n'
+    chunks = chunks_of(code, Granularity.SYMBOL_WITH_IMPORTS)
+    original = [c for c in chunks if c.name == 'Sum'][0].content
+    
+    parts = [c for c in split_oversized_chunks(chunks, max_tokens=200) if c.name == 'Sum']
+    assert len(parts) > 1
+    assert all(p.content.startswith('package store\nimport (') for p in parts)
+    stored = [{'content': p.content, 'metadata': dict(p.to_dict(), part_index=p.part_index)} for p in parts]
+    assert stitch_parts(stored) == original
+    print("✅ Import prefix repeated on every part and removed when stitching")
+
+
+def test_file_mode():
+    """File mode yields one chunk per file, still split by the token budget"""
+    chunks = chunks_of(GO_FILE, 'file')
+    assert len(chunks) == 1
+    file_chunk = chunks[0]
+    assert file_chunk.type == 'file' and file_chunk.content == GO_FILE
+    assert (file_chunk.line_start, file_chunk.line_end) == (1, 16)
+    assert file_chunk.metadata['symbols'] == ['Store', 'Get']
+    
+    large = GO_FILE * 20
+    parts = split_oversized_chunks(chunks_of(large, 'file'), max_tokens=200)
+    assert len(parts) > 1 and all(p.type == 'file' for p in parts)
+    print("✅ File mode produces whole-file chunks, split when oversized")
+
+
+def test_worker_applies_granularity():
+    """The indexer's parse worker honours the selected mode"""
+    workdir = Path(tempfile.mkdtemp(prefix="granularity_"))
+    try:
+        path = workdir / 'store.go'
+        path.write_text(GO_FILE)
+        _, _, chunks, error, _ = process_file_worker((str(path), 'go', str(workdir), 512, Granularity.FILE))
+        assert error is None, error
+        assert [c.type for c in chunks] == ['file'] and chunks[0].name == 'store.go'
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ Parse worker applies the granularity")
+
+
+def main():
+    print("=" * 70)
+    print("CHUNK GRANULARITY TEST")
+    print("=" * 70)
+    
+    tests = [
+        test_parse_granularity, test_import_blocks, test_symbol_is_default,
+        test_symbol_with_imports, test_prefix_survives_splitting, test_file_mode,
+        test_worker_applies_granularity
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,194 @@
+#!/usr/bin/env python3
+"""
+Test script for the gRPC query service
+The servicer runs everywhere, with stand-ins for the generated messages; the
+round trip over a real channel runs when grpcio and the stubs generated by
+proto/generate.sh are installed, and is skipped otherwise.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from enum import Enum
+from pathlib import Path
+from types import SimpleNamespace
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from grpc_server import CodeSearchServicer, GrpcUnavailable, create_server, load_stubs
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+
+class Message:
+    """Stand-in for a generated message: its fields as attributes"""
+    
+    def __init__(self, **fields):
+        self.__dict__.update(fields)
+
+
+MESSAGES = SimpleNamespace(**{name: type(name, (Message,), {}) for name in (
+    'SearchResult', 'Location', 'Surrounding', 'SymbolMatch', 'LookupResponse', 'Symbol', 'SymbolRef', 'Neighbors', 'Highlight')})
+
+StatusCode = Enum('StatusCode', 'INVALID_ARGUMENT NOT_FOUND INTERNAL')
+
+SEARCH_DEFAULTS = dict(query='', top_k=0, languages=[], kinds=[], path_globs=[], repos=[], uses=[],
+                       exclude_tests=False, rerank_candidates=0, candidate_k=0, vector_index='', with_surrounding=False, scope=[],
+                       with_neighbors='')
+
+
+class Request(Message):
+    """Stand-in for a request message: unset proto3 fields read as their defaults"""
+    
+    def __init__(self, defaults, **fields):
+        super().__init__(**{**defaults, **fields})
+        self._set = set(fields)
+    
+    def HasField(self, name):
+        return name in self._set
+
+
+class Aborted(Exception):
+    pass
+
+
+class Context:
+    """Stand-in for the servicer context: abort raises, like grpc's"""
+    
+    def abort(self, code, details):
+        raise Aborted(code, details)
+
+
+def aborted(call):
+    try:
+        call()
+    except Aborted as e:
+        return e.args[0]
+    raise AssertionError("the call was not aborted")
+
+
+def test_search(servicer):
+    request = Request(SEARCH_DEFAULTS, query='authenticate', top_k=3, languages=['go'], kinds=['method'],
+                      lexical_weight=0.5)
+    results = list(servicer.Search(request, Context()))
+    assert 0 < len(results) <= 3
+    top = results[0]
+    assert top.name == 'Authenticate' and top.type == 'method' and top.kind == 'source', vars(top)
+    assert top.location.filepath == 'complex.go' and top.location.line_start < top.location.line_end
+    assert top.location.citation == f"complex.go#L{top.location.line_start}-L{top.location.line_end}"
+    assert top.score > 0 and top.symbol_id and top.content != "Content not stored in RAM"
+    assert not hasattr(top, 'surrounding'), "unset messages are left out"
+    assert top.highlights == [], "no keyword index here: nothing matched lexically to highlight"
+    
+    assert list(servicer.Search(Request(SEARCH_DEFAULTS, query='user', path_globs=['nowhere/*']), Context())) == []
+    
+    request = Request(SEARCH_DEFAULTS, query='authenticate', top_k=1, kinds=['method'], with_neighbors='bodies')
+    neighbors = next(servicer.Search(request, Context())).neighbors
+    neighbor = getattr(neighbors, 'previous', None) or neighbors.next
+    assert neighbor.name and neighbor.content and neighbor.location.filepath == 'complex.go', vars(neighbor)
+    print("✅ Search streams ranked results with locations, kinds, scores and neighbors")
+
+
+def test_invalid(servicer):
+    assert aborted(lambda: list(servicer.Search(Request(SEARCH_DEFAULTS, query='  '), Context()))) == \
+        StatusCode.INVALID_ARGUMENT
+    assert aborted(lambda: list(servicer.Search(Request(SEARCH_DEFAULTS, query='x', vector_index='fast'),
+                                                Context()))) == StatusCode.INVALID_ARGUMENT
+    assert aborted(lambda: list(servicer.Search(Request(SEARCH_DEFAULTS, query='x', with_neighbors='all'),
+                                                Context()))) == StatusCode.INVALID_ARGUMENT
+    assert aborted(lambda: servicer.Lookup(Request(dict(name='', limit=0, kinds=[], languages=[], repos=[])),
+                                           Context())) == StatusCode.INVALID_ARGUMENT
+    assert aborted(lambda: servicer.GetSymbol(Request(dict(symbol_id='nowhere.go:Missing', include_methods=False)), Context())) == \
+        StatusCode.NOT_FOUND
+    print("✅ Invalid requests and unknown symbols abort with their status codes")
+
+
+def test_lookup_and_get_symbol(servicer):
+    response = servicer.Lookup(Request(dict(name='sessionmanager', limit=0, kinds=[], languages=[], repos=[])),
+                               Context())
+    assert response.symbols, "lookup found nothing"
+    match = response.symbols[0]
+    assert 'SessionManager' in match.name and match.match in ('exact', 'prefix', 'fuzzy'), vars(match)
+    
+    symbol = servicer.GetSymbol(Request(dict(symbol_id=match.symbol_id, include_methods=False)), Context())
+    assert symbol.symbol_id == match.symbol_id and symbol.location.filepath == 'complex.go'
+    assert match.name in symbol.content and symbol.parts >= 1 and symbol.methods == []
+    
+    admin = servicer.Lookup(Request(dict(name='AdminUser', limit=1, kinds=['type'], languages=[], repos=[])),
+                            Context()).symbols[0]
+    admin = servicer.GetSymbol(Request(dict(symbol_id=admin.symbol_id, include_methods=True)), Context())
+    assert [(m.name, m.pointer_receiver) for m in admin.methods] == [
+        ('AdminUser.Authenticate', True), ('AdminUser.Logout', True), ('AdminUser.AddPermission', True)]
+    logout = servicer.GetSymbol(Request(dict(symbol_id=admin.methods[1].symbol_id, include_methods=False)), Context())
+    assert logout.owner.name == 'AdminUser' and logout.owner.symbol_id == admin.symbol_id
+    assert logout.owner.pointer_receiver and logout.methods == []
+    print("✅ Lookup matches names and GetSymbol fetches them by symbol_id, with owners and methods")
+
+
+def test_round_trip(rag):
+    grpc, messages, services = load_stubs()
+    server, port = create_server(rag, port=0)
+    server.start()
+    try:
+        with grpc.insecure_channel(f"127.0.0.1:{port}") as channel:
+            stub = services.CodeSearchStub(channel)
+            results = list(stub.Search(messages.SearchRequest(query='authenticate', top_k=3, kinds=['method'])))
+            assert results and results[0].name == 'Authenticate', results
+            assert not results[0].HasField('rerank_score'), "scores a retriever did not give stay unset"
+            symbol = stub.GetSymbol(messages.GetSymbolRequest(symbol_id=results[0].symbol_id))
+            assert symbol.name == 'Authenticate'
+            try:
+                stub.GetSymbol(messages.GetSymbolRequest(symbol_id='nowhere.go:Missing'))
+            except grpc.RpcError as e:
+                assert e.code() == grpc.StatusCode.NOT_FOUND
+            else:
+                raise AssertionError("unknown symbol was found")
+    finally:
+        server.stop(grace=None)
+    print("✅ The service answers over a gRPC channel")
+
+
+def main():
+    print("=" * 70)
+    print("GRPC SERVER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_grpc_"))
+    source = workdir / "src"
+    source.mkdir()
+    shutil.copy(SAMPLES / "complex.go", source / "complex.go")
+    
+    rag = make_rag(workdir, "test_grpc", db="grpc.db")
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db"))).update_index(str(source), parallel=False)
+    servicer = CodeSearchServicer(rag, MESSAGES, StatusCode)
+    
+    tests = [lambda: test_search(servicer), lambda: test_invalid(servicer),
+             lambda: test_lookup_and_get_symbol(servicer)]
+    try:
+        load_stubs()
+        tests.append(lambda: test_round_trip(rag))
+    except GrpcUnavailable:
+        print("⚠️  grpcio or the generated stubs not installed: skipping the round trip")
+    
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,238 @@
+#!/usr/bin/env python3
+"""
+Test script for the HTML and Go template chunker
+Runs without tree-sitter: templates are read by a hand-written scanner. Also
+checks the Go side (handlers recording the templates they execute) and the
+template filter field linking the two
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, HtmlChunker
+from chunkers.html_chunker import scan_actions
+from config import CONFIG
+from helpers import make_chroma_rag
+
+
+PROFILE = '''{{/* user/profile renders the profile page of a user */}}
+{{define "user/profile"}}
+<!DOCTYPE html>
+<html>
+  {{template "head" .}}
+  <body>
+    {{template "nav" .}}
+    <section id="profile" class="card {{if .Admin}}admin{{end}}">
+      <h2>{{.User.Name}}'s profile</h2>
+      {{ if gt (len .User.Emails) 0 }}<p>{{ "}}<section>" }}</p>{{ end }}
+    </section>
+    {{block "footer" .}}<footer>Default footer</footer>{{end}}
+  </body>
+</html>
+{{end}}
+
+{{define "head"}}<head><title>{{.Title}}</title></head>{{end}}
+'''
+
+PAGE = '''<!DOCTYPE html>
+<html>
+<head>
+  <title>Account settings</title>
+  <style>
+    .card > h2 { margin: 0 }
+  </style>
+</head>
+<body>
+  <nav class="top">
+    <a href="/">Home</a>
+  </nav>
+  <main>
+    <p>Welcome back, {{ .User.Name }}</p>
+    <!-- <section id="old"> kept out -->
+    <form id="settings" action="/save">
+      <section><h3>Email settings</h3></section>
+      <input name="email" value="{{ .User.Email }}">
+    </form>
+  </main>
+  <script>
+    if (a < b) { document.write("<section>") }
+  </script>
+</body>
+</html>
+'''
+
+HANDLERS = '''package web
+
+var pages = template.Must(template.ParseFiles("web/profile.gohtml", "web/head.gohtml"))
+
+// Profile renders the profile page of the signed-in user
+func Profile(w http.ResponseWriter, r *http.Request) {
+    pages.ExecuteTemplate(w, "user/profile", load(r))
+}
+
+// Settings renders the account settings
+func Settings(w http.ResponseWriter, r *http.Request, name string) {
+    pages.ExecuteTemplate(w, name, load(r))
+}
+'''
+
+
+def by_name(chunks, name):
+    matches = [c for c in chunks if c.name == name]
+    assert len(matches) == 1, f"{name}: {[(c.type, c.name) for c in chunks]}"
+    return matches[0]
+
+
+def test_defines():
+    chunker = HtmlChunker()
+    chunks = chunker.extract_chunks(PROFILE, 'web/profile.gohtml')
+    assert [(c.type, c.name) for c in chunks] == [('template', 'user/profile'), ('template', 'head')], chunks
+    
+    profile = by_name(chunks, 'user/profile')
+    assert (profile.line_start, profile.line_end) == (1, 15), (profile.line_start, profile.line_end)
+    assert profile.content.startswith('{{/*') and profile.content.endswith('{{end}}')
+    assert profile.doc == 'user/profile renders the profile page of a user', profile.doc
+    assert profile.signature == '{{define "user/profile"}}'
+    assert profile.metadata == {'template': 'user/profile', 'includes': ['head', 'nav'], 'blocks': ['footer']}
+    
+    head = by_name(chunks, 'head')
+    assert head.line_start == head.line_end == 17 and head.metadata == {'template': 'head'}
+    assert not chunker.diagnostics, chunker.diagnostics
+    print("✅ Each {{define}} is a template chunk with its doc, includes and blocks")
+
+
+def test_sections():
+    chunker = HtmlChunker()
+    chunks = chunker.extract_chunks(PAGE, 'web/settings.html')
+    assert [(c.type, c.name) for c in chunks] == [
+        ('section', 'head'), ('section', 'nav.top'), ('document', 'settings.html'),
+        ('section', 'form#settings'), ('script', 'script'),
+    ], [(c.type, c.name) for c in chunks]
+    
+    head = by_name(chunks, 'head')
+    assert (head.line_start, head.line_end) == (3, 8) and '<style>' in head.content, head.content
+    form = by_name(chunks, 'form#settings')
+    assert form.metadata == {'element': 'form', 'id': 'settings', 'heading': 'Email settings'}, form.metadata
+    assert form.signature == '<form id="settings" action="/save">' and form.content.startswith('    <form')
+    assert form.content.endswith('</form>') and '<section>' in form.content, "nested sections stay in their form"
+    
+    document = by_name(chunks, 'settings.html')
+    assert 'Welcome back' in document.content and 'section id="old"' in document.content, document.content
+    assert (document.line_start, document.line_end) == (13, 15), (document.line_start, document.line_end)
+    
+    script = by_name(chunks, 'script')
+    assert '"<section>"' in script.content and script.line_end == 23
+    assert not chunker.diagnostics, chunker.diagnostics
+    print("✅ Pages split at head, nav, form and script; the markup between them is a document chunk")
+
+
+def test_embedded_actions():
+    """Actions with tags, braces or quotes inside do not move boundaries"""
+    actions, diagnostics = scan_actions('{{ "}}<section>" }}{{- /* a }} comment */ -}}{{`{{raw}}`}}{{.A}}')
+    assert [a.keyword for a in actions] == ['', 'comment', '', ''] and not diagnostics, actions
+    assert actions[0].text == '"}}<section>"' and actions[1].text.strip() == 'a }} comment'
+    assert actions[2].text == '`{{raw}}`'
+    
+    code = '''<section id="list">
+  {{range .Items}}<article class="{{if .Hot}}hot{{else}}cold{{end}}">{{.Name}}</article>{{end}}
+  {{ printf "</section>" }}
+</section>
+<footer>{{template "copyright" .}}</footer>
+'''
+    chunks = HtmlChunker().extract_chunks(code, 'web/list.tmpl')
+    assert [c.name for c in chunks] == ['section#list', 'footer'], [c.name for c in chunks]
+    assert chunks[0].line_end == 4 and chunks[1].metadata == {'element': 'footer', 'includes': ['copyright']}
+    
+    # A template with no markup at all is one document chunk
+    text = 'Hello {{.Name}},\n{{range .Orders}}- {{.ID}}\n{{end}}'
+    chunks = HtmlChunker().extract_chunks(text, 'mail/orders.tmpl')
+    assert [(c.type, c.name, c.content) for c in chunks] == [('document', 'orders.tmpl', text)], chunks
+    print("✅ Template actions are read as Go reads them and never move a section boundary")
+
+
+def test_unbalanced():
+    chunker = HtmlChunker()
+    code = '''{{define "row"}}<tr><td>{{.Name}}</td></tr>{{end}}{{end}}
+{{define "table"}}
+<table>{{range .Rows}}{{template "row" .}}{{end}}</table>
+'''
+    chunks = chunker.extract_chunks(code, 'web/table.gohtml')
+    assert [(c.name, c.line_start, c.line_end) for c in chunks] == [('row', 1, 1), ('table', 2, 3)], chunks
+    assert chunks[1].metadata['includes'] == ['row']
+    messages = [(d['line'], d['message']) for d in chunker.diagnostics]
+    assert messages == [(1, 'unexpected {{end}}'), (2, 'unclosed {{define "table"}}')], messages
+    
+    chunker.extract_chunks('<section>\n  <p>{{ .Title </p>\n</section>\n', 'web/broken.html')
+    assert [d['message'] for d in chunker.diagnostics] == ['unclosed template action'], chunker.diagnostics
+    print("✅ Unbalanced actions are reported; an unclosed define runs to the end of the file")
+
+
+def test_go_handlers():
+    chunks = GoChunker().extract_chunks(HANDLERS, 'web/handlers.go')
+    profile = next(c for c in chunks if c.name == 'Profile')
+    assert profile.metadata['templates'] == ['user/profile'], profile.metadata
+    settings = next(c for c in chunks if c.name == 'Settings')
+    assert 'templates' not in settings.metadata, "a name only known at run time is not recorded"
+    pages = next(c for c in chunks if c.name == 'pages')
+    assert pages.metadata['template_files'] == ['web/profile.gohtml', 'web/head.gohtml'], pages.metadata
+    print("✅ Go code records the templates it executes and the files it parses")
+
+
+def test_template_search(workdir):
+    """A template, the pages including it and its handler are found by the template's name"""
+    rag = make_chroma_rag(workdir / "db", "test_html")
+    rag.add_chunks_batch(HtmlChunker().extract_chunks(PROFILE, 'web/profile.gohtml')
+                         + HtmlChunker().extract_chunks(PAGE, 'web/settings.html')
+                         + GoChunker().extract_chunks(HANDLERS, 'web/handlers.go'))
+    rag._build_keyword_index()
+    
+    results = rag.retrieve_context("the template that renders the user profile", n_results=3)
+    assert results[0]['metadata']['name'] == 'user/profile', [r['metadata']['name'] for r in results]
+    
+    linked = rag.retrieve_context("profile", n_results=10, filter_expr="template=user/profile")
+    assert sorted(r['metadata']['name'] for r in linked) == ['Profile', 'user/profile'], linked
+    heads = rag.retrieve_context("head", n_results=10, filter_expr="template=head AND language=html")
+    assert sorted(r['metadata']['name'] for r in heads) == ['head', 'user/profile'], "defined or included"
+    print("✅ --filter template=... links a template to the pages and handlers using it")
+
+
+def test_file_types():
+    extensions = CONFIG.file_types['html'].extensions
+    assert {'.html', '.htm', '.tmpl', '.gohtml'} <= set(extensions), extensions
+    assert CONFIG.file_types['html'].parser_type == 'regex'
+    print("✅ .html, .htm, .tmpl and .gohtml files are indexed with the template chunker")
+
+
+def main():
+    print("=" * 70)
+    print("HTML TEMPLATE CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_html_"))
+    
+    tests = [
+        test_defines, test_sections, test_embedded_actions, test_unbalanced, test_go_handlers,
+        lambda: test_template_search(workdir), test_file_types
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,111 @@
+#!/usr/bin/env python3
+"""
+Test script for file discovery ignore rules: .gitignore semantics,
+built-in excluded directories, extra patterns, binary and oversized files
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from indexer import ChromeIndexer
+from utils.ignore_rules import IgnoreRules, parse_ignore_lines
+from utils.state_manager import StateManager
+
+
+def write(root: Path, relative: str, content='x = 1\n'):
+    path = root / relative
+    path.parent.mkdir(parents=True, exist_ok=True)
+    if isinstance(content, bytes):
+        path.write_bytes(content)
+    else:
+        path.write_text(content)
+
+
+def test_patterns():
+    def ignored(pattern, path, is_dir=False):
+        return parse_ignore_lines([pattern])[0].matches(path, is_dir)
+    
+    assert ignored('*.pb.go', 'api/v1/user.pb.go')          # unanchored: any depth
+    assert not ignored('/gen', 'api/gen', is_dir=True)       # leading slash: root only
+    assert ignored('/gen', 'gen', is_dir=True)
+    assert ignored('docs/*.md', 'docs/a.md') and not ignored('docs/*.md', 'x/docs/a.md')
+    assert ignored('**/fixtures', 'a/b/fixtures', is_dir=True)
+    assert ignored('logs/**', 'logs/2024/app.py')
+    assert ignored('cache/', 'src/cache', is_dir=True) and not ignored('cache/', 'src/cache')
+    assert parse_ignore_lines(['# comment', '', '   ']) == []
+    print("✅ Gitignore patterns anchored and globbed like git")
+
+
+def test_nested_and_negation():
+    root = Path(tempfile.mkdtemp(prefix="ignore_rules_"))
+    try:
+        write(root, '.gitignore', '*.py\n!keep.py\n')
+        write(root, 'pkg/.gitignore', '!*.py\nlocal.py\n')
+        rules = IgnoreRules(root)
+        rules.enter(root)
+        rules.enter(root / 'pkg')
+        assert rules.is_ignored(root / 'main.py')
+        assert not rules.is_ignored(root / 'keep.py')
+        assert not rules.is_ignored(root / 'pkg' / 'lib.py')      # nested negation re-includes
+        assert rules.is_ignored(root / 'pkg' / 'local.py')
+    finally:
+        shutil.rmtree(root, ignore_errors=True)
+    print("✅ Nested .gitignore files and negation applied in order")
+
+
+def test_discovery():
+    root = Path(tempfile.mkdtemp(prefix="ignore_discovery_"))
+    try:
+        write(root, '.gitignore', 'generated/\n')
+        write(root, 'src/app.py')
+        write(root, 'src/generated/api.py')
+        write(root, 'node_modules/lib/index.js', 'module.exports = 1\n')
+        write(root, 'vendor/dep/dep.go', 'package dep\n')
+        write(root, 'src/blob.py', b'\x00\x01binary')
+        write(root, 'src/huge.py', 'x = 1\n' * 2000)
+        write(root, 'src/scratch.py')
+        
+        def discover(**options):
+            indexer = ChromeIndexer(None, state_manager=StateManager(str(root / 'state.db')), **options)
+            return sorted(str(p.relative_to(root)) for p, _ in indexer._discover_files(root)), indexer.stats
+        
+        files, stats = discover(max_file_bytes=1024, ignore_patterns=['scratch.py'])
+        assert files == ['src/app.py'], files
+        assert stats['files_ignored'] == 2, stats
+        
+        files, _ = discover(max_file_bytes=0, use_default_ignores=False, use_gitignore=False)
+        for expected in ['node_modules/lib/index.js', 'vendor/dep/dep.go', 'src/generated/api.py', 'src/huge.py']:
+            assert expected in files, (expected, files)
+        assert 'src/blob.py' not in files
+    finally:
+        shutil.rmtree(root, ignore_errors=True)
+    print("✅ Discovery skips ignored, binary and oversized files")
+
+
+def main():
+    print("=" * 70)
+    print("IGNORE RULES TEST")
+    print("=" * 70)
+    
+    tests = [test_patterns, test_nested_and_negation, test_discovery]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
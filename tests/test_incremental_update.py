@@ -0,0 +1,137 @@
+#!/usr/bin/env python3
+"""
+Test script for incremental index updates from file content hashes
+Only changed files are re-embedded, a byte-identical file that moved keeps
+its embeddings under the new path, deleted files are purged, and the
+update reports what it added, updated and removed
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import HashEmbedder, make_chroma_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+
+FILES = {
+    'auth/login.go': 'package auth\n\n// Login checks a password\nfunc Login(user, password string) bool {\n'
+                     '    return check(user, password)\n}\n',
+    'cache/lru.go': 'package cache\n\n// Evict drops the oldest entry\nfunc Evict(c *Cache) {\n'
+                    '    c.drop(c.oldest())\n}\n',
+    'store/open.go': 'package store\n\n// Open opens the store\nfunc Open(path string) *Store {\n'
+                     '    return &Store{path: path}\n}\n\n// Close closes the store\nfunc Close(s *Store) {\n'
+                     '    s.file.Close()\n}\n',
+}
+
+
+def setup(workdir):
+    root = workdir / 'repo'
+    for path, content in FILES.items():
+        (root / path).parent.mkdir(parents=True, exist_ok=True)
+        (root / path).write_text(content)
+    embedder = HashEmbedder()
+    rag = make_chroma_rag(workdir / 'db', 'updates', embedder=embedder)
+    state = StateManager(str(workdir / 'state.db'))
+    indexer = ChromeIndexer(rag, state_manager=state)
+    indexer.index_directory(str(root), parallel=False)
+    embedder.texts.clear()
+    return root, rag, state, indexer
+
+
+def chunks_of(rag, filepath):
+    rows = rag.collection.get(where={'filepath': filepath}, include=['metadatas', 'embeddings'])
+    return {metadata['name']: [round(x, 6) for x in vector]
+            for metadata, vector in zip(rows['metadatas'], rows['embeddings'])}
+
+
+def test_moved_file(workdir):
+    root, rag, state, indexer = setup(workdir)
+    before = chunks_of(rag, 'store/open.go')
+    assert sorted(before) == ['Close', 'Open', 'store'], before
+    
+    (root / 'storage').mkdir()
+    (root / 'store' / 'open.go').rename(root / 'storage' / 'open.go')
+    stats = indexer.update_index(str(root), parallel=False, report=False)
+    assert stats['files_moved'] == 1 and stats['paths_moved'] == [('store/open.go', 'storage/open.go')], stats
+    assert not (stats['paths_added'] or stats['paths_updated'] or stats['paths_removed']), stats
+    assert rag.embedder.texts == [], "a moved byte-identical file is not embedded again"
+    assert chunks_of(rag, 'storage/open.go') == before, "its chunks keep their embeddings under the new path"
+    assert chunks_of(rag, 'store/open.go') == {}
+    recorded = state.get_file_hashes(str(root))
+    assert str(root / 'storage' / 'open.go') in recorded and str(root / 'store' / 'open.go') not in recorded
+    top = rag.retrieve_context("open the store path", n_results=1)[0]
+    assert top['metadata']['filepath'] == 'storage/open.go', top['metadata']
+    print("✅ A moved byte-identical file keeps its embeddings under the new path")
+
+
+def test_deleted_file(workdir):
+    root, rag, state, indexer = setup(workdir)
+    count = rag.collection.count()
+    (root / 'cache' / 'lru.go').unlink()
+    stats = indexer.update_index(str(root), parallel=False, report=False)
+    assert stats['paths_removed'] == ['cache/lru.go'] and stats['chunks_removed'] == 2, stats
+    assert chunks_of(rag, 'cache/lru.go') == {} and rag.collection.count() == count - 2
+    assert str(root / 'cache' / 'lru.go') not in state.get_file_hashes(str(root))
+    assert rag.embedder.texts == [], "removing a file embeds nothing"
+    assert all(r['metadata']['filepath'] != 'cache/lru.go'
+               for r in rag.retrieve_context("evict the oldest cache entry", n_results=5)), "nor is it found"
+    print("✅ A deleted file's chunks and state are purged")
+
+
+def test_counts(workdir):
+    root, rag, state, indexer = setup(workdir)
+    (root / 'auth' / 'login.go').write_text(FILES['auth/login.go'].replace('checks a password',
+                                                                           'verifies a password'))
+    (root / 'cache' / 'lru.go').unlink()
+    (root / 'queue').mkdir()
+    (root / 'queue' / 'push.go').write_text('package queue\n\n// Push appends a job\nfunc Push(q *Queue, job Job) {\n'
+                                            '    q.jobs = append(q.jobs, job)\n}\n\n// Pop takes the first job\n'
+                                            'func Pop(q *Queue) Job {\n    return q.jobs[0]\n}\n')
+    stats = indexer.update_index(str(root), parallel=False, report=False)
+    assert (stats['paths_added'], stats['paths_updated'], stats['paths_removed']) == (
+        ['queue/push.go'], ['auth/login.go'], ['cache/lru.go']), stats
+    assert (stats['chunks_added'], stats['chunks_updated'], stats['chunks_removed']) == (3, 2, 2), stats
+    assert stats['files_skipped'] == 1 and stats['files_moved'] == 0, "store/open.go is unchanged"
+    assert not any('Open opens the store' in text for text in rag.embedder.texts), "unchanged files are not embedded"
+    assert any('verifies a password' in text for text in rag.embedder.texts)
+    
+    stats = indexer.update_index(str(root), parallel=False, report=False)
+    assert (stats['chunks_added'], stats['chunks_updated'], stats['chunks_removed']) == (0, 0, 0), stats
+    assert stats['files_skipped'] == 3, "a second update finds nothing to do"
+    print("✅ An update reports the files and chunks it added, updated and removed")
+
+
+def main():
+    print("=" * 70)
+    print("INCREMENTAL UPDATE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_incremental_"))
+    tests = [
+        lambda: test_moved_file(workdir / 'moved'),
+        lambda: test_deleted_file(workdir / 'deleted'),
+        lambda: test_counts(workdir / 'counts'),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
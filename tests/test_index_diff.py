@@ -0,0 +1,178 @@
+#!/usr/bin/env python3
+"""
+Test script for symbol-level diffs of index snapshots
+Two versions of a Go tree are indexed and saved; the diff must report the
+symbols added, removed and modified (with the members an interface gained),
+and nothing for symbols that only moved. Uses a small deterministic embedder
+"""
+
+import json
+import shutil
+import subprocess
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.index_diff import DiffIndex, describe_change
+from utils.index_snapshot import SnapshotError
+from utils.state_manager import StateManager
+
+
+VERSION_1 = {
+    'store/store.go': """package store
+
+// Store keeps sessions
+type Store interface {
+	Get(id string) (*Session, error)
+}
+
+type Session struct {
+	ID string
+}
+
+// Refresh extends the session
+func (s *Session) Refresh() {}
+
+func Format(s *Session) string { return s.ID }
+
+func Unchanged() int { return 1 }
+""",
+    'store/old.go': """package store
+
+func Legacy() {}
+""",
+}
+
+VERSION_2 = {
+    'store/store.go': """package store
+
+// Store keeps sessions
+type Store interface {
+	Get(id string) (*Session, error)
+	Close() error
+}
+
+type Session struct {
+	ID string
+}
+
+// ParseConfig reads the store configuration
+func ParseConfig(path string) error { return nil }
+
+func Format(s *Session) string {
+	// the id is the whole format
+	return s.ID
+}
+
+func Unchanged() int { return 1 }
+""",
+}
+
+
+def snapshot(workdir: Path, name: str, files) -> str:
+    """Index a tree and save it as a snapshot"""
+    source = workdir / name
+    for rel_path, text in files.items():
+        (source / rel_path).parent.mkdir(parents=True, exist_ok=True)
+        (source / rel_path).write_text(text)
+    rag = make_rag(workdir, name)
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / f"{name}-state.db"))).index_directory(
+        str(source), parallel=False)
+    path = str(workdir / f"{name}-snapshot")
+    rag.save_index(path)
+    return path
+
+
+def statuses(diff: DiffIndex):
+    return {(change['status'], change['name']) for change in diff.changes}
+
+
+def test_symbol_changes(old: str, new: str):
+    diff = DiffIndex(old, new)
+    found = statuses(diff)
+    for expected in [('added', 'ParseConfig'), ('removed', 'Session.Refresh'), ('removed', 'Legacy'),
+                     ('modified', 'Store'), ('modified', 'Format')]:
+        assert expected in found, f"{expected} not in {sorted(found)}"
+    # Unchanged moved down a few lines and Session did not change at all
+    assert not {name for _, name in found} & {'Unchanged', 'Session'}, sorted(found)
+    
+    store = next(change for change in diff.modified if change['name'] == 'Store')
+    assert store['members_added'] == ['Close'] and 'members_removed' not in store, store
+    assert describe_change(store).endswith('+Close'), describe_change(store)
+    
+    files = diff.by_file()
+    assert list(files) == ['store/old.go', 'store/store.go'], list(files)
+    # Modified first, then added, then removed
+    order = [change['status'] for change in files['store/store.go']]
+    assert order == sorted(order, key=['modified', 'added', 'removed'].index), order
+    summary = diff.summary()
+    assert summary['files'] == 2 and summary['added'] == len(diff.added), summary
+    print("✅ Added, removed and modified symbols are reported; moves are not")
+
+
+def test_ignore_formatting_and_paths(old: str, new: str):
+    found = statuses(DiffIndex(old, new, ignore_formatting=True))
+    assert ('modified', 'Format') not in found, "a comment and line breaks counted as a change"
+    assert ('modified', 'Store') in found
+    
+    only = DiffIndex(old, new, paths=['*/old.go'])
+    assert ('removed', 'Legacy') in statuses(only), statuses(only)
+    assert {change['filepath'] for change in only.changes} == {'store/old.go'}, only.changes
+    assert not DiffIndex(new, new).changes
+    print("✅ Formatting changes can be ignored and diffs narrowed to paths")
+
+
+def test_cli_json(old: str, new: str, workdir: Path):
+    result = subprocess.run([sys.executable, str(Path(__file__).parent.parent / 'cli.py'), 'diff', old, new,
+                             '--format', 'json'], capture_output=True, text=True, cwd=str(workdir))
+    assert result.returncode == 0, result.stderr[-500:]
+    report = json.loads(result.stdout)
+    assert report['summary']['files'] == 2, report['summary']
+    changes = {change['name']: change for entry in report['files'] for change in entry['changes']}
+    assert changes['ParseConfig']['status'] == 'added' and 'hash' not in changes['ParseConfig']
+    
+    missing = subprocess.run([sys.executable, str(Path(__file__).parent.parent / 'cli.py'), 'diff', old,
+                              str(workdir / 'nowhere')], capture_output=True, text=True, cwd=str(workdir))
+    assert missing.returncode == 1, missing.returncode
+    try:
+        DiffIndex(old, str(workdir / 'nowhere'))
+        assert False, "a missing snapshot was accepted"
+    except SnapshotError:
+        pass
+    print("✅ diff prints the changes as JSON and fails on a missing snapshot")
+
+
+def main():
+    print("=" * 70)
+    print("INDEX DIFF TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="index_diff_"))
+    failed = 0
+    try:
+        old = snapshot(workdir, 'v1', VERSION_1)
+        new = snapshot(workdir, 'v2', VERSION_2)
+        tests = [lambda: test_symbol_changes(old, new), lambda: test_ignore_formatting_and_paths(old, new),
+                 lambda: test_cli_json(old, new, workdir)]
+        for test in tests:
+            try:
+                test()
+            except Exception as e:
+                failed += 1
+                print(f"❌ Test failed: {e}")
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,269 @@
+#!/usr/bin/env python3
+"""
+Test script for index snapshots
+A saved index loads back whole, and only with the embedder it was built with;
+a save that dies part way leaves the previous snapshot in place, a corrupt
+snapshot is refused before the live index is touched, a load that fails
+leaves the index as it was, and an update that dies is completed by the next.
+Uses a small deterministic embedder
+"""
+
+import json
+import os
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import HashEmbedder, make_rag
+from indexer import ChromeIndexer
+from utils.index_snapshot import (CHUNKS_FILE, LOAD_SUFFIX, MANIFEST_FILE, VECTORS_FILE, SnapshotError,
+                                  read_manifest, staged_snapshot, write_snapshot)
+from utils.state_manager import StateManager
+
+
+def sample_chunks(count):
+    return [CodeChunk(type='function', name=f'Handler{i}', content=f'func Handler{i}() {{ serve request {i} }}',
+                      filepath=f'server/handler{i}.go', language='go', line_start=1, line_end=3)
+            for i in range(count)]
+
+
+def expect_error(action, *fragments):
+    try:
+        action()
+    except SnapshotError as e:
+        assert all(fragment in str(e) for fragment in fragments), e
+        return
+    assert False, f"expected a SnapshotError mentioning {fragments}"
+
+
+def stored(rag):
+    rows = rag.collection.get(include=['documents', 'metadatas', 'embeddings'])
+    return {id_: (document, metadata, [round(x, 6) for x in vector])
+            for id_, document, metadata, vector in zip(rows['ids'], rows['documents'], rows['metadatas'],
+                                                       rows['embeddings'])}
+
+
+def test_round_trip(workdir):
+    source = make_rag(workdir, 'original')
+    source.add_chunks_batch(sample_chunks(3))
+    path = workdir / 'snapshot'
+    manifest = source.save_index(str(path))
+    assert (manifest['embedding_model'], manifest['dimensions'], manifest['count']) == ('test-hash', 256, 3), manifest
+    assert read_manifest(str(path)) == manifest
+    
+    copy = make_rag(workdir, 'copy')
+    assert copy.load_index(str(path))['count'] == 3
+    assert stored(copy) == stored(source), "ids, chunks, metadata and vectors come back as saved"
+    assert copy.collection.metadata['embedding_model'] == 'test-hash'
+    assert copy.collection.metadata['embedding_dimensions'] == 256
+    found = [{r['id'] for r in rag.retrieve_context("serve request", n_results=3)} for rag in (source, copy)]
+    assert found[0] == found[1] and len(found[1]) == 3, found
+    print("✅ A saved index loads back with the same chunks and vectors, and searches the same")
+
+
+def test_embedder_mismatch(workdir):
+    source = make_rag(workdir, 'hashed')
+    source.add_chunks_batch(sample_chunks(3))
+    path = workdir / 'snapshot'
+    source.save_index(str(path))
+    
+    for embedder, fragments in ((HashEmbedder('other-hash'), ("embedded with 'test-hash'", "'other-hash'")),
+                                (HashEmbedder(size=128), ('256 dimensions', 'produces 128'))):
+        live = make_rag(workdir, 'live', embedder=embedder, db=f"live_{embedder.dimensions()}.db")
+        live.add_chunks_batch(sample_chunks(1))
+        before = stored(live)
+        expect_error(lambda: live.load_index(str(path)), *fragments)
+        assert stored(live) == before, "a refused snapshot leaves the index as it was"
+    print("✅ A snapshot from another embedding model or dimension is refused with both named")
+
+
+def test_interrupted_save(workdir):
+    rag = make_rag(workdir, 'saved')
+    rag.add_chunks_batch(sample_chunks(3))
+    path = workdir / 'snapshot'
+    assert rag.save_index(str(path))['count'] == 3
+    assert sorted(os.listdir(path)) == sorted([CHUNKS_FILE, MANIFEST_FILE, VECTORS_FILE])
+    
+    # The process dies while the second save writes its rows
+    rows = [(f'id{i}', 'doc', {}, [0.5] * 256) for i in range(5)]
+    
+    def dying_rows():
+        yield from rows[:2]
+        raise KeyboardInterrupt
+    try:
+        with staged_snapshot(str(path)) as staging:
+            write_snapshot(staging, dying_rows(), 'test-hash', 256)
+        assert False, "the save should have died"
+    except KeyboardInterrupt:
+        pass
+    assert read_manifest(str(path))['count'] == 3, "the previous snapshot is untouched"
+    assert sorted(os.listdir(workdir)) == sorted(['saved.db', 'snapshot']), os.listdir(workdir)
+    
+    # A save replaces the previous snapshot whole, and nothing is left beside it
+    rag.add_chunks_batch(sample_chunks(5)[3:])
+    assert rag.save_index(str(path))['count'] == 5
+    assert sorted(os.listdir(workdir)) == sorted(['saved.db', 'snapshot']), os.listdir(workdir)
+    
+    # Dying between moving the old snapshot aside and moving the new one in
+    os.replace(path, workdir / '.snapshot.previous')
+    restored = make_rag(workdir, 'restored')
+    assert restored.load_index(str(path))['count'] == 5 and restored.collection.count() == 5
+    assert not (workdir / '.snapshot.previous').exists()
+    
+    other = workdir / 'other'
+    other.mkdir()
+    (other / 'notes.txt').write_text('keep me')
+    expect_error(lambda: rag.save_index(str(other)), 'not part of an index snapshot')
+    assert (other / 'notes.txt').read_text() == 'keep me'
+    print("✅ An interrupted save leaves the previous snapshot; a finished one replaces it whole")
+
+
+def test_corrupt_snapshots(workdir):
+    source = make_rag(workdir, 'source')
+    source.add_chunks_batch(sample_chunks(4))
+    good = workdir / 'good'
+    source.save_index(str(good))
+    live = make_rag(workdir, 'live')
+    live.add_chunks_batch(sample_chunks(2))
+    
+    def corrupted(name, damage):
+        path = workdir / name
+        shutil.copytree(good, path)
+        damage(path)
+        return str(path)
+    
+    def truncate(path):
+        data = (path / VECTORS_FILE).read_bytes()
+        (path / VECTORS_FILE).write_bytes(data[:-4])
+    
+    def flip_byte(path):
+        data = bytearray((path / VECTORS_FILE).read_bytes())
+        data[10] ^= 0xFF
+        (path / VECTORS_FILE).write_bytes(bytes(data))
+    
+    def extra_chunk(path):
+        with open(path / CHUNKS_FILE, 'a', encoding='utf-8') as f:
+            f.write(json.dumps({'id': 'x', 'document': 'x', 'metadata': {}}) + '\n')
+    
+    def garble_manifest(path):
+        (path / MANIFEST_FILE).write_text('{"format_version": 1, "dimens')
+    
+    def bad_count(path):
+        manifest = json.loads((path / MANIFEST_FILE).read_text())
+        manifest['count'] = -1
+        (path / MANIFEST_FILE).write_text(json.dumps(manifest))
+    
+    expect_error(lambda: live.load_index(corrupted('truncated', truncate)), 'truncated', '4 vectors of 256 dimensions')
+    expect_error(lambda: live.load_index(corrupted('flipped', flip_byte)), VECTORS_FILE, 'checksum')
+    expect_error(lambda: live.load_index(corrupted('extra', extra_chunk)), 'holds 5 chunks', 'records 4')
+    expect_error(lambda: live.load_index(corrupted('garbled', garble_manifest)), MANIFEST_FILE, 'corrupt')
+    expect_error(lambda: live.load_index(corrupted('count', bad_count)), "'count' is -1")
+    expect_error(lambda: live.load_index(str(workdir / 'missing')), 'No index snapshot')
+    assert live.collection.count() == 2, "corrupt snapshots never touch the live index"
+    assert live.retrieve_context("serve request", n_results=1)[0]['metadata']['name'].startswith('Handler')
+    print("✅ Corrupt snapshots are refused with a clear error before anything is replaced")
+
+
+def test_failed_load(workdir):
+    source = make_rag(workdir, 'full')
+    source.add_chunks_batch(sample_chunks(6))
+    path = workdir / 'full_snapshot'
+    source.save_index(str(path))
+    
+    live = make_rag(workdir, 'kept')
+    live.add_chunks_batch(sample_chunks(2))
+    add_rows = live._add_rows
+    
+    def failing(store, rows):
+        add_rows(store, [next(rows)])
+        raise OSError("disk full")
+    live._add_rows = failing
+    try:
+        live.load_index(str(path))
+        assert False, "the load should have failed"
+    except OSError:
+        pass
+    assert live.collection.count() == 2, "a failed load leaves the index as it was"
+    assert live.collection.open_collection('kept' + LOAD_SUFFIX).count() == 0, "nothing is left staged"
+    
+    live._add_rows = add_rows
+    assert live.load_index(str(path))['count'] == 6 and live.collection.count() == 6
+    assert live.retrieve_context("handler five", n_results=1)
+    print("✅ A load replaces the index only once every chunk is in")
+
+
+def test_interrupted_update(workdir):
+    root = workdir / 'repo' / 'store'
+    root.mkdir(parents=True)
+    (root / 'open.go').write_text('package store\n\n// Open opens the store\nfunc Open() *Store {\n    return &Store{}\n}\n')
+    (root / 'store.go').write_text('package store\n\n// Store holds the records\ntype Store struct {\n    rows int\n}\n')
+    rag = make_rag(workdir, 'update')
+    state = StateManager(str(workdir / 'update_state.db'))
+    ChromeIndexer(rag, state_manager=state).index_directory(str(workdir / 'repo'), parallel=False)
+    names = sorted(r['metadata']['name'] for r in rag._format_get_results(rag.collection.get()))
+    
+    # Changing open.go re-links store.go too; the run dies once open.go is stored,
+    # before store.go is stored again
+    (root / 'open.go').write_text('package store\n\n// Open opens the store\nfunc Open() *Store {\n'
+                                  '    return &Store{rows: 1}\n}\n')
+    add = rag.add_chunks_batch
+    calls = []
+    
+    def killed(chunks, *args, **kwargs):
+        calls.append([chunk.filepath for chunk in chunks])
+        if 'store/store.go' in calls[-1]:
+            raise KeyboardInterrupt
+        return add(chunks, *args, **kwargs)
+    rag.add_chunks_batch = killed
+    try:
+        ChromeIndexer(rag, state_manager=state).index_directory(str(workdir / 'repo'), batch_size=1, parallel=False)
+    except KeyboardInterrupt:
+        pass
+    rag.add_chunks_batch = add
+    assert set(calls[0]) == {'store/open.go'} and 'store/store.go' in calls[-1], calls
+    
+    ChromeIndexer(rag, state_manager=state).index_directory(str(workdir / 'repo'), parallel=False)
+    after = sorted(r['metadata']['name'] for r in rag._format_get_results(rag.collection.get()))
+    assert after == names, (names, after)
+    print("✅ An update that dies is completed by the next one, linked files included")
+
+
+def main():
+    print("=" * 70)
+    print("INDEX SNAPSHOT TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_snapshot_"))
+    
+    tests = [
+        lambda: test_round_trip(workdir / 'round_trip'),
+        lambda: test_embedder_mismatch(workdir / 'mismatch'),
+        lambda: test_interrupted_save(workdir / 'save'),
+        lambda: test_corrupt_snapshots(workdir / 'corrupt'),
+        lambda: test_failed_load(workdir / 'load'),
+        lambda: test_interrupted_update(workdir / 'update'),
+    ]
+    for name in ('round_trip', 'mismatch', 'save', 'corrupt', 'load', 'update'):
+        (workdir / name).mkdir()
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,160 @@
+#!/usr/bin/env python3
+"""
+Test script for index statistics and the indexed file listing
+get_statistics counts every stored chunk by type, kind, language and
+repository and reports the embedding model, store and approximate size;
+list_files lists the indexed files with their chunk counts. Covers both on
+plain and deduplicated indexes, and GET /stats and GET /files. Uses a small
+deterministic embedder
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+import threading
+import urllib.request
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import make_rag
+from server import RAGServer
+
+
+def chunk(name, filepath, language='go', line=1, **fields):
+    return CodeChunk(type=fields.pop('type', 'function'), name=name,
+                     content=f'func {name}() {{ run("{name}", {line}) }}', filepath=filepath, language=language, line_start=line, line_end=line, **fields)
+
+
+CHUNKS = [
+    chunk('Login', 'auth/login.go', line=3, repo='backend'),
+    chunk('Logout', 'auth/login.go', line=9, repo='backend'),
+    chunk('TestLogin', 'auth/login_test.go', kind='test', repo='backend'),
+    chunk('Session', 'auth/session.go', type='struct', repo='backend'),
+    chunk('render', 'web/render.js', language='javascript', repo='frontend'),
+    chunk('notes', 'docs/NOTES', language='text', type='text', kind='text', repo='frontend'),
+    # One symbol split in two parts
+    chunk('Sync', 'auth/sync.go', repo='backend', symbol_id='backend:auth/sync.go:Sync:1', part_index=0, part_count=2),
+    chunk('Sync', 'auth/sync.go', line=20, repo='backend', symbol_id='backend:auth/sync.go:Sync:1', part_index=1,
+          part_count=2),
+]
+
+
+def build_rag(workdir, name, chunks=CHUNKS, **options):
+    rag = make_rag(workdir, name, **options)
+    rag.add_chunks_batch(chunks)
+    return rag
+
+
+def get(url):
+    with urllib.request.urlopen(url, timeout=10) as response:
+        return response.status, json.loads(response.read().decode())
+
+
+def test_statistics(workdir):
+    rag = build_rag(workdir, "stats")
+    stats = rag.get_statistics()
+    assert stats['total_chunks'] == 8 and stats['unique_files'] == 6, stats
+    assert stats['chunks_by_kind'] == {'source': 6, 'test': 1, 'text': 1}, stats['chunks_by_kind']
+    assert stats['chunks_by_language'] == {'go': 6, 'javascript': 1, 'text': 1}
+    assert stats['chunks_by_type']['struct'] == 1 and stats['chunks_by_repo'] == {'backend': 6, 'frontend': 2}
+    assert (stats['embedding_model'], stats['dimensions'], stats['store']) == ('test-hash', 256, 'sqlite'), stats
+    assert stats['collection'] == 'stats' and stats['metric'] == 'cosine' and not stats['keyword_only']
+    assert stats['vector_bytes'] == 8 * 256 * 4 and stats['disk_bytes'] > 0, stats
+    assert stats['duplicate_chunks'] == 0 and 'embedding_models' not in stats
+    json.dumps(stats)
+    
+    # Every chunk counts, not a sample of them
+    many = [chunk(f'Handler{n}', f'api/handler{n % 300}.go', line=n) for n in range(1200)]
+    big = build_rag(workdir, "stats_big", many).get_statistics()
+    assert big['total_chunks'] == 1200 and big['unique_files'] == 300, (big['total_chunks'], big['unique_files'])
+    
+    empty = make_rag(workdir, "empty").get_statistics()
+    assert empty['total_chunks'] == 0 and empty['unique_files'] == 0 and empty['dimensions'] is None, empty
+    print("✅ Statistics count every chunk and report the model, store and size")
+
+
+def test_files(workdir):
+    rag = build_rag(workdir, "files")
+    files = rag.list_files()
+    assert [f['location'] for f in files] == ['backend:auth/login.go', 'backend:auth/login_test.go',
+                                              'backend:auth/session.go', 'backend:auth/sync.go',
+                                              'frontend:docs/NOTES', 'frontend:web/render.js'], files
+    login = files[0]
+    assert login == {'location': 'backend:auth/login.go', 'filepath': 'auth/login.go', 'repo': 'backend',
+                     'language': 'go', 'chunks': 2, 'duplicates': 0}, login
+    assert files[3]['chunks'] == 2, "each part of a split symbol counts"
+    assert [f['filepath'] for f in rag.list_files(repo='frontend')] == ['docs/NOTES', 'web/render.js']
+    assert [f['filepath'] for f in rag.list_files(path_glob='auth/login*')] == ['auth/login.go', 'auth/login_test.go']
+    assert rag.list_files(repo='backend', path_glob='web/*') == []
+    
+    rag.delete_file_chunks('auth/session.go', 'backend')
+    assert 'auth/session.go' not in [f['filepath'] for f in rag.list_files()], "the listing follows the index"
+    assert rag.get_statistics()['total_chunks'] == 7
+    print("✅ list_files lists each indexed file with its chunk count")
+
+
+def test_dedup(workdir):
+    copies = [chunk('Login', 'vendor/a/login.go', line=3, repo='backend'),
+              chunk('Login', 'vendor/b/login.go', line=3, repo='backend')]
+    rag = build_rag(workdir, "files_dedup", CHUNKS + copies, dedup='exact')
+    stats = rag.get_statistics()
+    assert stats['total_chunks'] == 8 and stats['duplicate_chunks'] == 2, stats
+    assert stats['unique_files'] == 8, "files whose chunks are all stored elsewhere still count"
+    vendored = {f['filepath']: f for f in rag.list_files(path_glob='vendor/*')}
+    assert vendored['vendor/a/login.go']['chunks'] == 1 and vendored['vendor/a/login.go']['duplicates'] == 1
+    assert rag.list_files(path_glob='auth/login.go')[0]['duplicates'] == 0
+    print("✅ Duplicates stored once still count as chunks of their own files")
+
+
+def test_server(workdir):
+    rag = build_rag(workdir, "stats_server")
+    server = RAGServer(('127.0.0.1', 0), rag)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        status, body = get(url + '/stats')
+        assert status == 200 and body['total_chunks'] == 8 and body['store'] == 'sqlite', body
+        assert body['chunks_by_kind']['test'] == 1 and body['dimensions'] == 256
+        status, body = get(url + '/files')
+        assert status == 200 and body['total'] == 6 and body['files'][0]['location'] == 'backend:auth/login.go', body
+        status, body = get(url + '/files?repo=backend&path=auth/s*')
+        assert [f['filepath'] for f in body['files']] == ['auth/session.go', 'auth/sync.go'], body
+    finally:
+        server.shutdown()
+        server.server_close()
+    print("✅ GET /stats and GET /files report the statistics and the file listing")
+
+
+def main():
+    print("=" * 70)
+    print("INDEX STATISTICS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_index_stats_"))
+    tests = [
+        lambda: test_statistics(workdir),
+        lambda: test_files(workdir),
+        lambda: test_dedup(workdir),
+        lambda: test_server(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
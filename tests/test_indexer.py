@@ -0,0 +1,105 @@
+#!/usr/bin/env python3
+"""
+Test script for the indexing pipeline: parallel parsing with a worker pool
+must produce the same chunks, in the same order, as a sequential run
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from indexer import ChromeIndexer, parse_worker_count
+from utils.state_manager import StateManager
+
+
+class RecordingRAG:
+    """Stands in for the vector database and records insertions in order"""
+    
+    embedder = 'recording'
+    
+    def __init__(self):
+        self.inserted = []
+    
+    def validate_embedder(self):
+        return 1
+    
+    def add_chunks_batch(self, chunks):
+        self.inserted.extend((c.filepath, c.name, c.line_start, c.part_index) for c in chunks)
+        return len(chunks)
+    
+    def delete_file_chunks(self, filepath, repo=None):
+        return 0
+    
+    def record_source_root(self, path, repo=None):
+        pass
+
+
+def make_tree(root: Path, files: int = 24):
+    for i in range(files):
+        package = f"pkg{i % 3}"
+        path = root / package / f"file{i:02d}.go"
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(
+            f"package {package}\n\n"
+            f"type T{i} struct{{ N int }}\n\n"
+            f"func (t *T{i}) Get() int {{ return t.N }}\n\n"
+            f"func New{i}() *T{i} {{ return &T{i}{{N: {i}}} }}\n"
+        )
+
+
+def index(root: Path, parallel: bool, workers: int):
+    rag = RecordingRAG()
+    state = StateManager(str(root.parent / f"state_{parallel}_{workers}.db"))
+    indexer = ChromeIndexer(rag, state_manager=state)
+    stats = indexer.index_directory(str(root), parallel=parallel, workers=workers)
+    return rag.inserted, stats
+
+
+def test_parallel_matches_sequential():
+    workdir = Path(tempfile.mkdtemp(prefix="indexer_pool_"))
+    try:
+        root = workdir / "src"
+        make_tree(root)
+        sequential, stats = index(root, parallel=False, workers=1)
+        parallel, parallel_stats = index(root, parallel=True, workers=3)
+        assert stats['files_processed'] == 24, stats
+        assert len(sequential) == 24 * 3 + 3, len(sequential)  # and a summary per package
+        assert parallel == sequential, "parallel run inserted chunks in a different order"
+        assert parallel_stats['chunks_created'] == stats['chunks_created']
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ Worker pool output identical to a sequential run")
+
+
+def test_worker_count():
+    assert parse_worker_count(4) == 4
+    assert parse_worker_count(0) >= 1  # one per CPU
+    print("✅ Worker count defaults to the CPU count")
+
+
+def main():
+    print("=" * 70)
+    print("INDEXER TEST")
+    print("=" * 70)
+    
+    tests = [test_parallel_matches_sequential, test_worker_count]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
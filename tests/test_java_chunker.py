@@ -0,0 +1,208 @@
+#!/usr/bin/env python3
+"""
+Test script for the Java chunker
+Declarations are parsed by tree-sitter-java
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import JavaChunker
+
+
+SERVICE = '''package org.example.users;
+
+import java.util.*;
+
+/**
+ * User lookups.
+ *
+ * @author team
+ */
+@Service
+@RequestMapping(value = "/users", produces = {"json"})
+public class UserService<K extends Comparable<K>> extends Base<K> implements Api, Closeable {
+    private static final int[] LIMITS = {1, 2, 3};
+    private final Runnable task = () -> { log("not a member; }"); };
+    int a = 1, b, c[] = {4};
+    static { init(); }
+    
+    /** Creates the service. */
+    public UserService(Repo repo) { this.repo = repo; }
+    
+    /**
+     * Finds users.
+     * @deprecated use {@link #search} instead
+     */
+    @Deprecated
+    public <T extends Number & Comparable<T>> Map<K, List<T>> find(final @NonNull String query, int... ids)
+            throws IOException, SQLException {
+        if (a < b && b > c) { return null; }
+        return null;
+    }
+    
+    public static class Cache {
+        /** Entries of the cache. */
+        private class Entry {
+            void touch() { }
+        }
+    }
+}
+'''
+
+TYPES = '''package org.example.model;
+
+public record Point(int x, @Min(0) int y) implements Comparable<Point> {
+    public Point {
+        if (x < 0) throw new IllegalArgumentException();
+    }
+    public static Point origin() { return new Point(0, 0); }
+}
+
+enum Op {
+    PLUS("+") { int apply(int a, int b) { return a + b; } },
+    MINUS("-") { int apply(int a, int b) { return a - b; } };
+    private final String symbol;
+    Op(String symbol) { this.symbol = symbol; }
+    abstract int apply(int a, int b);
+}
+
+interface Callback {
+    default void onDone(String s) { System.out.println(s); }
+    void onError(Throwable t);
+}
+
+@interface Marker {
+    String value() default "";
+    int[] codes() default {1, 2};
+}
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_package_and_annotations():
+    """The package becomes the namespace; annotations are kept in the metadata"""
+    chunks = JavaChunker().extract_chunks(SERVICE, 'src/UserService.java')
+    assert all(c.namespace == 'org.example.users' for c in chunks)
+    
+    service = by_name(chunks, 'UserService', 'class')
+    assert service.metadata['package'] == 'org.example.users'
+    assert service.metadata['annotations'] == ['@Service', '@RequestMapping(value = "/users", produces = {"json"})']
+    assert service.metadata['extends'] == ['Base<K>']
+    assert service.metadata['implements'] == ['Api', 'Closeable']
+    assert service.doc == 'User lookups.\n\n@author team', service.doc
+    
+    find = by_name(chunks, 'find')
+    assert find.metadata['annotations'] == ['@Deprecated']
+    assert find.metadata['deprecated'] == 'use {@link #search} instead'
+    print("✅ Package, annotations and Javadoc extracted")
+
+
+def test_signatures():
+    """Method signatures keep parameter types, generics and throws clauses"""
+    chunks = JavaChunker().extract_chunks(SERVICE, 'src/UserService.java')
+    find = by_name(chunks, 'find')
+    assert find.signature == ('public <T extends Number & Comparable<T>> Map<K, List<T>> '
+                              'find(final @NonNull String query, int... ids) throws IOException, SQLException'), find.signature
+    assert find.metadata['type_params'] == '<T extends Number & Comparable<T>>'
+    assert find.metadata['returns'] == 'Map<K, List<T>>'
+    assert find.metadata['params'] == [{'name': 'query', 'type': 'String'}, {'name': 'ids', 'type': 'int...'}]
+    assert find.metadata['throws'] == ['IOException', 'SQLException']
+    assert find.content.rstrip().endswith('}') and 'return null;' in find.content
+    
+    constructor = by_name(chunks, 'UserService', 'method')
+    assert constructor.metadata['constructor'] and constructor.doc == 'Creates the service.'
+    print("✅ Signatures with generics and throws clauses")
+
+
+def test_fields_and_nesting():
+    """Fields are extracted; nested classes point at their enclosing classes"""
+    chunks = JavaChunker().extract_chunks(SERVICE, 'src/UserService.java')
+    names = [c.name for c in chunks]
+    assert 'log' not in names and 'init' not in names
+    
+    limits = by_name(chunks, 'LIMITS')
+    assert limits.type == 'field' and limits.metadata['field_type'] == 'int[]'
+    assert limits.metadata['modifiers'] == ['private', 'static', 'final']
+    assert by_name(chunks, 'a').metadata['names'] == ['a', 'b', 'c']
+    
+    entry = by_name(chunks, 'Entry')
+    assert entry.parent == 'UserService.Cache' and entry.parent_class == 'Cache'
+    assert entry.doc == 'Entries of the cache.'
+    touch = by_name(chunks, 'touch')
+    assert touch.parent == 'UserService.Cache.Entry' and touch.parent_class == 'Entry'
+    print("✅ Fields and nested classes extracted")
+
+
+def test_records_enums_interfaces():
+    """Records, enums with constant bodies, default methods and annotation types"""
+    chunks = JavaChunker().extract_chunks(TYPES, 'src/Model.java')
+    
+    point = by_name(chunks, 'Point', 'record')
+    assert point.metadata['components'] == [{'name': 'x', 'type': 'int'}, {'name': 'y', 'type': 'int'}]
+    assert point.metadata['methods'] == ['Point', 'origin']
+    assert by_name(chunks, 'Point', 'method').metadata['compact']
+    
+    op = by_name(chunks, 'Op', 'enum')
+    assert op.metadata['constants'] == ['PLUS', 'MINUS']
+    assert [c.name for c in chunks if c.parent == 'Op'] == ['symbol', 'Op', 'apply']
+    
+    callback = by_name(chunks, 'Callback')
+    assert callback.type == 'interface' and callback.metadata['methods'] == ['onDone', 'onError']
+    assert by_name(chunks, 'onError').metadata['abstract']
+    assert by_name(chunks, 'onDone').metadata['modifiers'] == ['default']
+    
+    marker = by_name(chunks, 'Marker')
+    assert marker.type == 'annotation' and marker.metadata['methods'] == ['value', 'codes']
+    assert by_name(chunks, 'codes').signature == 'int[] codes() default {1, 2}'
+    print("✅ Records, enums, interfaces and annotation types extracted")
+
+
+def test_sample_file():
+    """The comprehensive sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'ComplexJava.java'
+    chunks = JavaChunker().extract_chunks(sample.read_text(), 'comprehensive/ComplexJava.java')
+    
+    admin = by_name(chunks, 'AdminUser', 'class')
+    assert admin.namespace == 'com.chromium.test.complex'
+    assert admin.metadata['extends'] == ['User'] and admin.metadata['implements'] == ['Authenticator']
+    assert admin.doc == 'Admin user with elevated privileges'
+    
+    get_instance = by_name(chunks, 'getInstance')
+    assert get_instance.signature == 'public static <T extends User> SessionManager<T> getInstance()'
+    assert by_name(chunks, 'getRole', 'method').metadata.get('modifiers') == ['public', 'abstract']
+    assert by_name(chunks, 'StatusCode', 'enum').metadata['constants'][0] == 'SUCCESS'
+    print("✅ Sample file parsed")
+
+
+def main():
+    print("=" * 70)
+    print("JAVA CHUNKER TEST")
+    print("=" * 70)
+    
+    tests = [
+        test_package_and_annotations, test_signatures, test_fields_and_nesting,
+        test_records_enums_interfaces, test_sample_file
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
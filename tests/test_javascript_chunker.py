@@ -0,0 +1,233 @@
+#!/usr/bin/env python3
+"""
+Test script for the JavaScript/TypeScript chunker
+TypeScript signatures with type parameters and return types, React components
+in TSX and JSX, export metadata, and the .mts/.cts TypeScript dialects
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import JavaScriptChunker
+from config import CONFIG
+
+
+USERS_TS = '''export interface User<Id = string> extends Entity, Named {
+  id: Id;
+  rename(name: string): void;
+}
+
+export type Handler<T> = (event: T) => Promise<void>;
+
+export enum Role {
+  Admin,
+  Member,
+}
+
+export async function loadUser<T extends User>(id: string, cache: Map<string, T>): Promise<T> {
+  return cache.get(id)!;
+}
+
+function internalHelper(value: number): number {
+  return value * 2;
+}
+
+export const formatName = <T>(user: T): string => {
+  return String(user);
+};
+
+export default class Repository<T> extends Base implements Store<T> {
+  private static cache = new Map();
+  
+  public async find(id: string): Promise<T | undefined> {
+    return undefined;
+  }
+  
+  protected static create<U>(value: U): Repository<U> {
+    return null as any;
+  }
+}
+
+export abstract class Shape {
+  describe(): string {
+    return "shape";
+  }
+}
+'''
+
+BUTTON_TSX = '''import React from 'react';
+
+interface ButtonProps {
+  label: string;
+  onClick?: () => void;
+}
+
+export const Button: React.FC<ButtonProps> = ({ label, onClick }) => {
+  return (
+    <button className="btn" onClick={onClick}>
+      {label.length > 10 ? <span>{label.slice(0, 10)}</span> : label}
+    </button>
+  );
+};
+
+export default function App(): JSX.Element {
+  const items = [1, 2, 3];
+  return <main>{items.map((i) => <Button key={i} label={`#${i}`} />)}</main>;
+}
+
+function formatLabel(label: string): string {
+  return label.trim();
+}
+'''
+
+TODOS_JSX = '''export function TodoList({ todos }) {
+  return <ul>{todos.map((todo) => <li key={todo.id}>{todo.title}</li>)}</ul>;
+}
+
+export const Empty = () => <p>Nothing to do</p>;
+
+const toTitle = (text) => text.toUpperCase();
+'''
+
+STORE_JS = '''class Store extends EventEmitter {
+  static create() {
+    return new Store();
+  }
+  
+  async load(key) {
+    return this.get(key);
+  }
+}
+'''
+
+ARGS_MTS = '''export function parseArgs(argv: string[]): Options {
+  return { verbose: argv.includes("-v") } as Options;
+}
+'''
+
+
+def by_name(chunks, name):
+    return next(c for c in chunks if c.name == name)
+
+
+def test_typescript_declarations():
+    chunks = JavaScriptChunker().extract_chunks(USERS_TS, 'services/users.ts')
+    user = by_name(chunks, 'User')
+    assert user.type == 'interface' and user.signature == 'interface User<Id = string> extends Entity, Named', \
+        user.signature
+    assert user.metadata['extends'] == ['Entity', 'Named'] and user.metadata['members'] == ['id', 'rename'], \
+        user.metadata
+    handler = by_name(chunks, 'Handler')
+    assert handler.type == 'type' and handler.signature == 'type Handler<T> = (event: T) => Promise<void>'
+    role = by_name(chunks, 'Role')
+    assert role.type == 'enum' and role.signature == 'enum Role'
+    assert all(c.language == 'typescript' for c in chunks), {c.language for c in chunks}
+    print("✅ Interfaces, type aliases and enums are chunked with their signatures")
+
+
+def test_typescript_signatures():
+    chunks = JavaScriptChunker().extract_chunks(USERS_TS, 'services/users.ts')
+    load = by_name(chunks, 'loadUser')
+    assert load.signature == ('async function loadUser<T extends User>(id: string, cache: Map<string, T>)'
+                              ': Promise<T>'), load.signature
+    assert load.metadata['type_parameters'] == '<T extends User>' and load.metadata['return_type'] == 'Promise<T>'
+    assert load.metadata['async']
+    
+    format_name = by_name(chunks, 'formatName')
+    assert format_name.signature == 'const formatName = <T>(user: T): string => ...', format_name.signature
+    assert format_name.metadata['arrow'] and format_name.metadata['return_type'] == 'string'
+    
+    repository = by_name(chunks, 'Repository')
+    assert repository.signature == 'class Repository<T> extends Base implements Store<T>', repository.signature
+    assert repository.metadata['base_class'] == 'Base' and repository.metadata['implements'] == ['Store<T>']
+    assert repository.metadata['method_count'] == 2
+    find = by_name(chunks, 'find')
+    assert find.type == 'method' and find.parent == 'Repository', (find.type, find.parent)
+    assert find.signature == 'public async find(id: string): Promise<T | undefined>', find.signature
+    create = by_name(chunks, 'create')
+    assert create.signature == 'protected static create<U>(value: U): Repository<U>', create.signature
+    assert create.metadata['static'] and create.metadata['accessibility'] == 'protected'
+    assert by_name(chunks, 'Shape').metadata['abstract']
+    print("✅ Type parameters and return types are part of the signatures")
+
+
+def test_export_metadata():
+    chunks = JavaScriptChunker().extract_chunks(USERS_TS, 'services/users.ts')
+    exported = {c.name: (c.metadata['exported'], c.metadata['default_export']) for c in chunks if c.type != 'method'}
+    assert exported == {
+        'User': (True, False), 'Handler': (True, False), 'Role': (True, False), 'loadUser': (True, False),
+        'internalHelper': (False, False), 'formatName': (True, False), 'Repository': (True, True),
+        'Shape': (True, False),
+    }, exported
+    assert by_name(chunks, 'loadUser').content.startswith('export async function'), "the export is in the content"
+    print("✅ Exported and default-exported declarations are recorded as such")
+
+
+def test_tsx_components():
+    chunker = JavaScriptChunker()
+    chunks = chunker.extract_chunks(BUTTON_TSX, 'components/Button.tsx')
+    assert chunker.diagnostics == [], chunker.diagnostics
+    assert [c.name for c in chunks] == ['ButtonProps', 'Button', 'App', 'formatLabel'], [c.name for c in chunks]
+    button = by_name(chunks, 'Button')
+    assert button.type == 'component' and button.metadata['declared_type'] == 'React.FC<ButtonProps>'
+    assert button.signature == 'const Button: React.FC<ButtonProps> = ({ label, onClick }) => ...', button.signature
+    assert '</button>' in button.content, "the whole component is one chunk"
+    app = by_name(chunks, 'App')
+    assert app.type == 'component' and app.metadata['default_export'] and app.metadata['return_type'] == 'JSX.Element'
+    assert by_name(chunks, 'formatLabel').type == 'function', "functions without JSX are not components"
+    assert by_name(chunks, 'ButtonProps').metadata['members'] == ['label', 'onClick']
+    assert all(c.language == 'typescript' for c in chunks)
+    print("✅ TSX components are single chunks, JSX in their bodies parsed cleanly")
+
+
+def test_jsx_and_javascript():
+    chunker = JavaScriptChunker()
+    chunks = chunker.extract_chunks(TODOS_JSX, 'components/Todos.jsx')
+    assert chunker.diagnostics == [], chunker.diagnostics
+    kinds = {c.name: (c.type, c.metadata['exported']) for c in chunks}
+    assert kinds == {'TodoList': ('component', True), 'Empty': ('component', True), 'toTitle': ('function', False)}, \
+        kinds
+    assert all(c.language == 'javascript' for c in chunks)
+    
+    chunks = JavaScriptChunker().extract_chunks(STORE_JS, 'lib/store.js')
+    assert by_name(chunks, 'Store').metadata['base_class'] == 'EventEmitter'
+    assert by_name(chunks, 'create').signature == 'static create()'
+    assert by_name(chunks, 'load').signature == 'async load(key)' and by_name(chunks, 'load').parent == 'Store'
+    print("✅ JSX components and plain JavaScript classes are chunked")
+
+
+def test_module_dialects():
+    for extension in ('.mts', '.cts'):
+        assert CONFIG.get_language_for_extension(extension) == 'javascript', extension
+        chunks = JavaScriptChunker().extract_chunks(ARGS_MTS, f'cli/args{extension}')
+        parse = by_name(chunks, 'parseArgs')
+        assert parse.language == 'typescript' and parse.metadata['return_type'] == 'Options', (extension, parse.metadata)
+    print("✅ .mts and .cts files are indexed and parsed as TypeScript")
+
+
+def main():
+    print("=" * 70)
+    print("JAVASCRIPT/TYPESCRIPT CHUNKER TEST")
+    print("=" * 70)
+    
+    tests = [test_typescript_declarations, test_typescript_signatures, test_export_metadata, test_tsx_components,
+             test_jsx_and_javascript, test_module_dialects]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,128 @@
+#!/usr/bin/env python3
+"""
+Test script for the JSONL export
+Indexes a small Go package and checks the exported records against the schema,
+with and without embeddings. Uses a small deterministic embedder
+"""
+
+import io
+import json
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, link_go_packages
+from chunkers.base_chunker import CodeChunk, assign_byte_ranges
+from helpers import make_rag
+from utils.jsonl_export import EXPORT_SCHEMA_VERSION
+
+GO_SOURCE = '''package auth
+
+// Authenticator checks credentials.
+type Authenticator interface {
+    Authenticate(token string) bool
+}
+
+type AdminUser struct {
+    Token string
+}
+
+// Authenticate compares the token.
+func (a *AdminUser) Authenticate(token string) bool {
+    return a.Token == token
+}
+'''
+
+FIELDS = {
+    'schema_version', 'id', 'symbol_id', 'part_index', 'part_count', 'filepath', 'repo', 'language',
+    'kind', 'chunk_type', 'test', 'name', 'qualified_name', 'namespace', 'signature', 'doc',
+    'body', 'line_start', 'line_end', 'byte_start', 'byte_end', 'metadata',
+}
+
+
+def build_rag(workdir):
+    rag = make_rag(workdir, "export")
+    chunks = assign_byte_ranges(GoChunker().extract_chunks(GO_SOURCE, "auth/user.go"), GO_SOURCE)
+    rag.add_chunks_batch(link_go_packages(chunks))
+    rag.add_chunks_batch([CodeChunk(type='function', name='helper', content='def helper():\n    return 1',
+                                    filepath='tools/helper.py', language='python', line_start=1, line_end=2)])
+    return rag
+
+
+def export(rag, **options):
+    buffer = io.StringIO()
+    count = rag.export_jsonl(buffer, **options)
+    records = [json.loads(line) for line in buffer.getvalue().splitlines()]
+    assert count == len(records), (count, len(records))
+    return records
+
+
+def test_records(rag):
+    records = export(rag)
+    assert len(records) == rag.collection.count()
+    assert all(set(r) == FIELDS for r in records), [set(r) ^ FIELDS for r in records]
+    assert all(r['schema_version'] == EXPORT_SCHEMA_VERSION for r in records)
+    
+    method = next(r for r in records if r['qualified_name'] == 'AdminUser.Authenticate')
+    assert method['kind'] == 'method' and method['chunk_type'] == 'method' and not method['test']
+    assert method['filepath'] == 'auth/user.go' and method['language'] == 'go'
+    assert method['doc'] == 'Authenticate compares the token.'
+    assert method['signature'] == 'func (a *AdminUser) Authenticate(token string) bool', method['signature']
+    assert method['body'].startswith('func (a *AdminUser) Authenticate') and method['body'].endswith('}')
+    assert (method['line_start'], method['line_end']) == (13, 15)
+    source = GO_SOURCE.encode('utf-8')
+    assert source[method['byte_start']:method['byte_end']].decode() == method['body']
+    
+    struct = next(r for r in records if r['name'] == 'AdminUser')
+    assert struct['kind'] == 'type' and struct['chunk_type'] == 'struct'
+    assert struct['metadata']['implements'] == ['Authenticator'], struct['metadata']
+    
+    spec = next(r for r in records if r['qualified_name'] == 'Authenticator.Authenticate')
+    assert spec['kind'] == 'interface_method' and spec['namespace'] == 'auth'
+    
+    helper = next(r for r in records if r['name'] == 'helper')
+    assert helper['byte_start'] is None and helper['metadata'] == {}
+    print("✅ One record per chunk with the documented fields")
+
+
+def test_embeddings_and_filter(rag):
+    records = export(rag, with_embeddings=True, where={"language": "go"})
+    assert records and all(r['language'] == 'go' for r in records)
+    assert all(len(r['embedding']) == 256 and r['embedding_model'] == 'test-hash' for r in records)
+    assert 'embedding' not in export(rag)[0]
+    print("✅ Embeddings included on request; store filters apply")
+
+
+def main():
+    print("=" * 70)
+    print("JSONL EXPORT TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="export_"))
+    rag = build_rag(workdir)
+    
+    tests = [
+        lambda: test_records(rag),
+        lambda: test_embeddings_and_filter(rag),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
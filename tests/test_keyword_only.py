@@ -0,0 +1,148 @@
+#!/usr/bin/env python3
+"""
+Test script for keyword-only indexes
+Builds a SQLite index without an embedding model, checks searches rank by BM25
+alone with the usual filters, and that the index can be made hybrid later
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from embedders import KEYWORD_ONLY_MODEL, Embedder, EmbeddingError, KeywordOnlyEmbedder, create_embedder
+from helpers import HashEmbedder, make_rag
+
+
+class UnreachableEmbedder(Embedder):
+    """A configured backend that is not there"""
+    
+    def __init__(self):
+        super().__init__('all-MiniLM-L6-v2', batch_size=32)
+    
+    def embed(self, texts):
+        raise EmbeddingError("no embedding backend available")
+    
+    def dimensions(self):
+        raise EmbeddingError("no embedding backend available")
+
+
+def sample_chunks():
+    chunks = []
+    for i, (name, language) in enumerate([('ParseURL', 'go'), ('RenderPage', 'go'), ('parse_header', 'python'),
+                                          ('open_socket', 'python'), ('VerifyToken', 'go')]):
+        chunks.append(CodeChunk(type='function', name=name, content=f"func {name}(value string) error {{}}",
+                                filepath=f'src/{name.lower()}.{language[:2]}', language=language,
+                                line_start=i + 1, line_end=i + 2, signature=f"{name}(value string) error"))
+    return chunks
+
+
+def build_rag(workdir, name, embedder):
+    return make_rag(workdir, name, embedder=embedder, db='keyword.db')
+
+
+def record_queries(rag):
+    """Spy on the store's vector searches"""
+    queries = []
+    query = rag.collection.query
+    
+    def recording_query(**kwargs):
+        queries.append(kwargs['n_results'])
+        return query(**kwargs)
+    
+    rag.collection.query = recording_query
+    return queries
+
+
+def test_backend():
+    """The 'none' backend builds the keyword-only embedder, never cached"""
+    saved = CONFIG.embedding_backend
+    CONFIG.embedding_backend = 'none'
+    try:
+        embedder = create_embedder(cache_path='unused.db')
+    finally:
+        CONFIG.embedding_backend = saved
+    assert isinstance(embedder, KeywordOnlyEmbedder) and embedder.model_name == KEYWORD_ONLY_MODEL
+    assert embedder.embed(['a', 'b']) == [[1.0], [1.0]] and embedder.dimensions() == 1
+    print("✅ Keyword-only backend created")
+
+
+def test_keyword_search(workdir):
+    """Searches rank by keyword matches alone, filters included"""
+    rag = build_rag(workdir, 'keyword', KeywordOnlyEmbedder())
+    assert rag.add_chunks_batch(sample_chunks()) == 5
+    rag._build_keyword_index()
+    assert rag.keyword_only and rag.collection.metadata['embedding_model'] == KEYWORD_ONLY_MODEL
+    
+    queries = record_queries(rag)
+    results = rag.retrieve_context('ParseURL', n_results=3, lexical_weight=0.2)
+    assert results[0]['metadata']['name'] == 'ParseURL', [r['metadata']['name'] for r in results]
+    assert results[0]['content'].startswith('func ParseURL')
+    assert queries == [], "no vector search on a keyword-only index"
+    
+    names = [r['metadata']['name'] for r in rag.retrieve_context('parse', n_results=5, language='python')]
+    assert names == ['parse_header'], names
+    assert rag.retrieve_context('nothing matches this', n_results=3) == []
+    try:
+        rag.embed_query('ParseURL')
+        assert False, "a keyword-only index has no query vectors"
+    except EmbeddingError:
+        pass
+    print("✅ Keyword-only search")
+
+
+def test_reopen_and_upgrade(workdir):
+    """A keyword-only index stays keyword-only until it is reindexed or migrated"""
+    build_rag(workdir, 'upgrade', KeywordOnlyEmbedder()).add_chunks_batch(sample_chunks())
+    
+    # Opened with the default backend configured but unreachable: still searchable
+    rag = build_rag(workdir, 'upgrade', UnreachableEmbedder())
+    assert rag.keyword_only
+    names = [r['metadata']['name'] for r in rag.retrieve_context('VerifyToken', n_results=2)]
+    assert names[0] == 'VerifyToken', names
+    
+    rag.set_embedder(HashEmbedder())
+    try:
+        rag.add_chunks_batch(sample_chunks()[:1])
+        raise AssertionError("vectors were added to a keyword-only index")
+    except EmbeddingError as e:
+        assert 'keyword-only' in str(e) and '--clear' in str(e), e
+    
+    summary = rag.migrate_embedder(HashEmbedder())
+    assert summary['previous_model'] == KEYWORD_ONLY_MODEL and summary['chunks'] == 5, summary
+    assert not rag.keyword_only
+    queries = record_queries(rag)
+    assert rag.retrieve_context('render page', n_results=2)[0]['metadata']['name'] == 'RenderPage'
+    assert len(queries) == 1, "a migrated index searches by vectors too"
+    print("✅ Reopened and made hybrid")
+
+
+def main():
+    print("=" * 70)
+    print("KEYWORD-ONLY INDEX TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="keyword_only_"))
+    tests = [test_backend, lambda: test_keyword_search(workdir), lambda: test_reopen_and_upgrade(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,294 @@
+#!/usr/bin/env python3
+"""
+Test script for the Kotlin chunker
+Sources are parsed by tree-sitter-kotlin
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import KotlinChunker
+from helpers import make_chroma_rag
+
+
+STRINGS = '''@file:JvmName("StringUtils")
+package com.example.text
+
+import kotlin.math.max
+import com.example.util.*
+
+/**
+ * Capitalizes the first letter.
+ *
+ * @receiver the string to change
+ */
+fun String.capitalize(): String = replaceFirstChar { it.uppercase() }
+
+fun <T> List<T>.second(): T = this[1]
+
+fun String?.orEmpty(): String = this ?: ""
+
+val String.lastChar: Char
+    get() = this[length - 1]
+
+const val MAX_LENGTH = 100
+
+typealias Handler = (String) -> Unit
+
+fun shout(text: String) =
+    text.uppercase()
+        .plus("!")
+'''
+
+MODEL = '''package com.example.users
+
+/** A user of the system. */
+@Serializable
+data class User(val id: Long, var name: String, private val tags: List<String> = emptyList()) : Entity(id), Comparable<User> {
+    val display: String
+        get() = "User(${name.map { "}$it" }})"
+    
+    var age: Int = 0
+        private set
+    
+    override fun compareTo(other: User): Int = id.compareTo(other.id)
+    
+    /** Greets the user. */
+    @Deprecated("use greet(other) instead")
+    suspend fun greet(
+        greeting: String = "Hi",
+        vararg others: User,
+    ): String {
+        val brace = "}" /* { nested /* comment */ } */
+        return """$greeting ${name}"""
+    }
+    
+    companion object Factory {
+        private const val PREFIX = "u"
+        
+        /** Creates a user. */
+        @JvmStatic
+        fun create(name: String): User = User(0, name)
+    }
+}
+
+sealed interface Result<out T> {
+    data class Ok<T>(val value: T) : Result<T>
+    object Missing : Result<Nothing>
+    fun isOk(): Boolean
+}
+
+enum class Color(val rgb: Int) {
+    RED(0xFF0000) {
+        override fun label() = "r"
+    },
+    GREEN(0x00FF00) { override fun label() = "g" };
+    
+    abstract fun label(): String
+}
+
+class Session private constructor(val token: String) {
+    constructor(user: User) : this(user.name) {
+        println(user)
+    }
+    init { check(token.isNotEmpty()) }
+    companion object {
+        fun guest() = Session("guest")
+    }
+    fun String.masked(): String = "***"
+}
+
+object Registry {
+    private val users by lazy { mutableMapOf<Long, User>() }
+}
+'''
+
+SCRIPT = '''plugins {
+    kotlin("jvm") version "2.0.0"
+}
+
+val versionName = "1.2"
+
+fun releaseTag(): String = "v$versionName"
+
+tasks.register("tag") {
+    doLast { println(releaseTag()) }
+}
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_extensions():
+    """Extension functions and properties record their receiver and are named after it"""
+    chunks = KotlinChunker().extract_chunks(STRINGS, 'src/Strings.kt')
+    assert all(c.namespace == 'com.example.text' for c in chunks)
+    
+    capitalize = by_name(chunks, 'capitalize')
+    assert capitalize.type == 'function' and capitalize.metadata['receiver'] == 'String'
+    assert capitalize.qualified_name == 'String.capitalize' and capitalize.parent_class == 'String'
+    assert capitalize.signature == 'fun String.capitalize(): String', capitalize.signature
+    assert capitalize.doc == 'Capitalizes the first letter.\n\n@receiver the string to change', capitalize.doc
+    
+    second = by_name(chunks, 'second')
+    assert second.metadata['receiver'] == 'List<T>' and second.parent == 'List'
+    assert second.metadata['type_params'] == '<T>' and second.metadata['returns'] == 'T'
+    or_empty = by_name(chunks, 'orEmpty')
+    assert or_empty.metadata['receiver'] == 'String?' and or_empty.qualified_name == 'String.orEmpty'
+    
+    last_char = by_name(chunks, 'lastChar')
+    assert last_char.type == 'property' and last_char.metadata['receiver'] == 'String'
+    assert last_char.metadata['accessors'] == ['get'] and last_char.line_end == 19
+    print("✅ Extension receivers recorded (String.capitalize, List<T>.second, String?.orEmpty)")
+
+
+def test_top_level():
+    """Top-level functions, constants and type aliases; statements end at an unfinished line"""
+    chunks = KotlinChunker().extract_chunks(STRINGS, 'src/Strings.kt')
+    assert [c.name for c in chunks] == ['capitalize', 'second', 'orEmpty', 'lastChar', 'MAX_LENGTH', 'Handler', 'shout']
+    
+    assert by_name(chunks, 'MAX_LENGTH').type == 'constant'
+    assert by_name(chunks, 'Handler').metadata['aliased'] == '(String) -> Unit'
+    shout = by_name(chunks, 'shout')
+    assert shout.parent is None and (shout.line_start, shout.line_end) == (25, 27), (shout.line_start, shout.line_end)
+    assert shout.signature == 'fun shout(text: String)'
+    
+    script = KotlinChunker().extract_chunks(SCRIPT, 'build.gradle.kts')
+    assert [(c.type, c.name) for c in script] == [('property', 'versionName'), ('function', 'releaseTag')], \
+        [(c.type, c.name) for c in script]
+    print("✅ Top-level declarations extracted, script statements skipped")
+
+
+def test_classes():
+    """Data classes, sealed interfaces, enums and objects with their members"""
+    chunks = KotlinChunker().extract_chunks(MODEL, 'src/User.kt')
+    
+    user = by_name(chunks, 'User', 'class')
+    assert user.metadata['modifiers'] == ['data'] and user.metadata['annotations'] == ['@Serializable']
+    assert user.metadata['supertypes'] == ['Entity', 'Comparable<User>']
+    assert user.metadata['params'][:2] == [{'name': 'id', 'type': 'Long', 'property': 'val'},
+                                           {'name': 'name', 'type': 'String', 'property': 'var'}]
+    assert user.metadata['properties'] == ['id', 'name', 'tags', 'display', 'age', 'PREFIX'], user.metadata
+    assert user.doc == 'A user of the system.' and user.line_end == 31
+    
+    greet = by_name(chunks, 'greet')
+    assert greet.type == 'method' and greet.parent == 'User' and greet.doc == 'Greets the user.'
+    assert greet.metadata['params'] == [{'name': 'greeting', 'type': 'String'},
+                                        {'name': 'others', 'type': 'User', 'vararg': True}]
+    assert greet.metadata['deprecated'] == 'use greet(other) instead'
+    assert greet.metadata['modifiers'] == ['suspend'] and greet.content.rstrip().endswith('}')
+    assert by_name(chunks, 'age').metadata == {'mutable': True, 'property_type': 'Int', 'accessors': ['set'],
+                                               'package': 'com.example.users'}
+    
+    result = by_name(chunks, 'Result')
+    assert result.type == 'interface' and result.metadata['modifiers'] == ['sealed']
+    assert by_name(chunks, 'Ok').parent == 'Result' and by_name(chunks, 'Missing').type == 'object'
+    assert by_name(chunks, 'isOk').metadata['abstract']
+    
+    color = by_name(chunks, 'Color')
+    assert color.type == 'enum' and color.metadata['constants'] == ['RED', 'GREEN']
+    assert [c.name for c in chunks if c.parent == 'Color'] == ['label']
+    assert by_name(chunks, 'users').metadata['delegate'] == 'lazy'
+    print("✅ Data classes, sealed interfaces, enums and objects extracted")
+
+
+def test_companion_and_constructors():
+    """Companion members are scoped to their class; secondary constructors are methods"""
+    chunks = KotlinChunker().extract_chunks(MODEL, 'src/User.kt')
+    
+    factory = by_name(chunks, 'Factory')
+    assert factory.type == 'object' and factory.parent == 'User' and factory.metadata['companion']
+    create = by_name(chunks, 'create')
+    assert create.qualified_name == 'User.create' and create.metadata['companion'] and create.doc == 'Creates a user.'
+    assert by_name(chunks, 'PREFIX').parent == 'User'
+    assert by_name(chunks, 'User', 'class').metadata['methods'] == ['compareTo', 'greet', 'create']
+    
+    session = by_name(chunks, 'Session', 'class')
+    assert session.metadata['constructor_modifiers'] == ['private']
+    assert session.metadata['methods'] == ['Session', 'guest', 'masked'], session.metadata['methods']
+    constructor = by_name(chunks, 'Session', 'method')
+    assert constructor.metadata['constructor'] and constructor.signature == 'constructor(user: User) : this(user.name)'
+    assert by_name(chunks, 'Companion').parent == 'Session'
+    assert by_name(chunks, 'guest').qualified_name == 'Session.guest'
+    
+    masked = by_name(chunks, 'masked')
+    assert masked.parent == 'Session' and masked.metadata['receiver'] == 'String', "member extensions stay in their class"
+    print("✅ Companion object members and secondary constructors belong to the class")
+
+
+def test_truncated_declarations():
+    """A declaration ending right after its ':' has an empty return type and keeps the rest of the file"""
+    source = "fun ready(): Int = 1\n\nfun <T> pending(items: List<T>):"
+    chunks = KotlinChunker().extract_chunks(source, 'src/Draft.kt')
+    assert by_name(chunks, 'ready').metadata['returns'] == 'Int'
+    assert by_name(chunks, 'pending').metadata['returns'] == '', by_name(chunks, 'pending').metadata
+    chunks = KotlinChunker().extract_chunks("fun pendingResult(value: Int):", 'src/Draft.kt')
+    assert by_name(chunks, 'pendingResult').metadata['returns'] == ''
+    print("✅ Truncated declarations are chunked with an empty return type")
+
+
+def test_sample_file():
+    """The multi-language sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'all_languages' / 'UserService.kt'
+    chunks = KotlinChunker().extract_chunks(sample.read_text(), 'all_languages/UserService.kt')
+    
+    assert by_name(chunks, 'Result').metadata['modifiers'] == ['sealed']
+    assert by_name(chunks, 'getOrNull').qualified_name == 'Result.getOrNull'
+    assert by_name(chunks, 'onSuccess').metadata['modifiers'] == ['inline']
+    assert by_name(chunks, 'create').qualified_name == 'UserService.create'
+    retry = by_name(chunks, 'retry')
+    assert retry.metadata['params'][1] == {'name': 'block', 'type': 'suspend () -> T'}
+    assert (retry.line_start, retry.line_end) == (91, 100)
+    print("✅ Sample file parsed")
+
+
+def test_receiver_search(workdir):
+    """Extension helpers are found by their receiver"""
+    rag = make_chroma_rag(workdir / "db", "test_kotlin")
+    rag.add_chunks_batch(KotlinChunker().extract_chunks(STRINGS, 'src/Strings.kt')
+                         + KotlinChunker().extract_chunks(MODEL, 'src/User.kt'))
+    rag._build_keyword_index()
+    
+    names = [r['metadata']['name'] for r in rag.retrieve_context("capitalize", n_results=3)]
+    assert names[0] == 'capitalize', names
+    extensions = sorted(r['metadata']['name'] for r in rag.retrieve_context(
+        "string helper", n_results=10, filter_expr="name=String.*"))
+    assert extensions == ['capitalize', 'lastChar', 'orEmpty'], extensions
+    print("✅ Extensions found by name and by receiver")
+
+
+def main():
+    print("=" * 70)
+    print("KOTLIN CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_kotlin_"))
+    
+    tests = [
+        test_extensions, test_top_level, test_classes, test_companion_and_constructors,
+        test_truncated_declarations, test_sample_file, lambda: test_receiver_search(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,152 @@
+#!/usr/bin/env python3
+"""
+Test script for per-language chunking parameters
+CONFIG.language_chunking overrides max_tokens, token_overlap and granularity for
+the files of one language; other languages keep the global values, and the
+effective parameters of every file are logged at debug level.
+Uses a small deterministic embedder
+"""
+
+import logging
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from config import CONFIG
+from helpers import make_rag
+from indexer import ChromeIndexer, chunking_params
+from utils.logger import get_logger
+from utils.state_manager import StateManager
+
+GO_SOURCE = "package auth\n\nfunc Check() int {\n" + "".join(
+    f"    total += lookupPermission(user, \"scope{i}\")\n" for i in range(30)
+) + "    return total\n}\n\nfunc Small() int {\n    return 1\n}\n"
+
+MARKDOWN_SOURCE = "# Auth\n\n" + "".join(
+    f"Sessions are checked against permission scope {i} before access.\n" for i in range(12)
+)
+
+
+class RecordingHandler(logging.Handler):
+    """Keeps the messages it is given"""
+    
+    def __init__(self):
+        super().__init__(logging.DEBUG)
+        self.messages = []
+    
+    def emit(self, record):
+        self.messages.append(record.getMessage())
+
+
+def index(workdir, name, overrides):
+    """Chunks per file of the sample tree indexed with the given overrides, and the log"""
+    source = workdir / name
+    (source / "auth").mkdir(parents=True)
+    (source / "auth" / "check.go").write_text(GO_SOURCE)
+    (source / "README.md").write_text(MARKDOWN_SOURCE)
+    
+    rag = make_rag(workdir, name)
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / f"{name}-state.db")))
+    
+    logger = get_logger()
+    handler = RecordingHandler()
+    level = logger.level
+    logger.addHandler(handler)
+    logger.setLevel(logging.DEBUG)
+    saved = CONFIG.language_chunking
+    CONFIG.language_chunking = overrides
+    try:
+        indexer.index_directory(str(source), parallel=False, max_tokens=512)
+    finally:
+        CONFIG.language_chunking = saved
+        logger.removeHandler(handler)
+        logger.setLevel(level)
+    
+    chunks = {}
+    found = rag.collection.get()
+    for metadata in found['metadatas']:
+        chunks.setdefault(metadata['filepath'], []).append(metadata)
+    return chunks, handler.messages
+
+
+def test_token_budget_override(workdir):
+    chunks, log = index(workdir, "budget", {'go': {'max_tokens': 80, 'token_overlap': 0}})
+    check = [m for m in chunks['auth/check.go'] if m['name'] == 'Check']
+    assert len(check) > 1, "Go functions should be split by the Go budget"
+    assert all(m['part_count'] == len(check) for m in check), check
+    assert all(m['part_count'] == 1 for m in chunks['README.md']), "Markdown keeps the global budget"
+    
+    assert "Chunking go: max_tokens=80, token_overlap=0, overlap_strategy=fixed_token, granularity=symbol" in log, log
+    assert (f"Chunking markdown: max_tokens=512, token_overlap={CONFIG.token_overlap}, "
+            f"overlap_strategy=fixed_token, granularity=symbol") in log, log
+    assert any(message.startswith("Chunked auth/check.go with max_tokens=80") for message in log), log
+    print("✅ A language's token budget applies to its files only, and is logged")
+
+
+def test_granularity_override(workdir):
+    chunks, _ = index(workdir, "granularity", {'go': {'granularity': 'file'}})
+    assert {m['type'] for m in chunks['auth/check.go']} == {'file'}, chunks['auth/check.go']
+    assert chunks['README.md'][0]['type'] != 'file', chunks['README.md']
+    print("✅ A language's granularity applies to its files only")
+
+
+def test_chunking_params():
+    saved = CONFIG.language_chunking
+    try:
+        CONFIG.language_chunking = {'markdown': {'max_tokens': 1024, 'token_overlap': 128,
+                                                 'overlap_strategy': 'line-aligned'}}
+        params = chunking_params('markdown', max_tokens=256, granularity='file')
+        assert params['max_tokens'] == 1024 and params['token_overlap'] == 128, params
+        assert params['overlap_strategy'] == 'line_aligned', params
+        assert params['granularity'].value == 'file', "keys left out fall back to the run's value"
+        params = chunking_params('go', max_tokens=256)
+        assert params['max_tokens'] == 256 and params['token_overlap'] == CONFIG.token_overlap, params
+        assert params['granularity'].value == CONFIG.granularity, params
+        assert params['overlap_strategy'] == CONFIG.overlap_strategy, params
+        
+        for bad in ({'go': {'max_token': 10}}, {'go': {'granularity': 'line'}},
+                    {'go': {'overlap_strategy': 'sentence'}}):
+            CONFIG.language_chunking = bad
+            try:
+                chunking_params('go')
+                assert False, f"{bad} should be rejected"
+            except ValueError:
+                pass
+    finally:
+        CONFIG.language_chunking = saved
+    print("✅ Overrides fall back to the run's and global values, and are validated")
+
+
+def main():
+    print("=" * 70)
+    print("PER-LANGUAGE CHUNKING TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="language_chunking_"))
+    
+    tests = [
+        lambda: test_token_budget_override(workdir),
+        lambda: test_granularity_override(workdir),
+        test_chunking_params,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
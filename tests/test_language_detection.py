@@ -0,0 +1,201 @@
+#!/usr/bin/env python3
+"""
+Test script for content-based language detection
+Files no extension or name maps get the language of their #! line, of an
+editor modeline or of the constructs in their text, with a confidence below
+which they are indexed as plain text; the detected language and confidence
+are recorded in their chunks' metadata. Covers detect_language, LanguageMap
+with detection on, an indexing run and the settings
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from config import CONFIG
+from indexer import ChromeIndexer
+from utils.file_languages import Detection, LanguageMap, detect_language
+from utils.state_manager import StateManager
+
+
+FILES = {
+    'tools/runner': "import os\nimport sys\n\n\nclass Runner(object):\n    def __init__(self, path):\n"
+                    "        self.path = path\n\n    def run(self):\n        return os.listdir(self.path)\n",
+    'snippets/hello.txt': "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tmsg := \"hi\"\n"
+                          "\tfmt.Println(msg)\n}\n",
+    'etc/env.conf': "# vim: set ft=sh:\nFOO=1\nBAR=2\n",
+    'etc/build.cfg': "# -*- mode: python -*-\nTARGETS = ['app']\n",
+    'tools/deploy.cgi': "#!/usr/bin/env bash\ndeploy() {\n    echo \"deploying the release\"\n}\n",
+    'src/table.in': "#include <stdio.h>\n#include <stdlib.h>\n\nint main(int argc, char **argv) {\n"
+                    "    printf(\"hello\\n\");\n    return 0;\n}\n",
+    'docs/NOTES': "Just some notes about the release.\nNothing in here is code.\n",
+    # One construct of Go and one of Python: too close to call
+    'misc/mixed': "package tables\nself.count = 1\n",
+    'main.go': "// vim: set ft=python:\npackage main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+}
+
+
+class RecordingRAG:
+    """Stands in for the vector database and keeps the chunks it is given"""
+    
+    embedder = 'recording'
+    
+    def __init__(self):
+        self.inserted = []
+    
+    def validate_embedder(self):
+        return 1
+    
+    def add_chunks_batch(self, chunks):
+        self.inserted.extend(chunks)
+        return len(chunks)
+    
+    def delete_file_chunks(self, filepath, repo=None):
+        return 0
+    
+    def record_source_root(self, path, repo=None):
+        pass
+
+
+def make_tree(root: Path):
+    for name, text in FILES.items():
+        path = root / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(text)
+    (root / 'assets').mkdir()
+    (root / 'assets' / 'logo').write_bytes(b'\x89PNG\r\n\x1a\n\0\0\0\rIHDR')
+
+
+def test_detect(workdir):
+    root = workdir / "detect"
+    make_tree(root)
+    
+    def detect(name):
+        return detect_language(root / name)
+    
+    assert detect('tools/runner') == Detection('python', 1.0), detect('tools/runner')
+    assert detect('snippets/hello.txt').language == 'go' and detect('snippets/hello.txt').confidence >= 0.5
+    assert detect('src/table.in').language == 'c'
+    assert detect('etc/env.conf') == Detection('bash', 1.0), "a vim modeline names the language"
+    assert detect('etc/build.cfg') == Detection('python', 1.0), "so does an Emacs one"
+    assert detect('tools/deploy.cgi') == Detection('bash', 1.0), "#! lines count whatever the extension"
+    assert detect_language(root / 'tools/deploy.cgi', shebangs={}) != Detection('bash', 1.0)
+    
+    assert detect('docs/NOTES') == Detection(None, 0.0), detect('docs/NOTES')
+    assert detect('misc/mixed').confidence == 0.0, detect('misc/mixed')
+    assert detect('assets/logo') is None and detect('missing') is None, "binary and unreadable files are not text"
+    print("✅ #! lines, modelines and language constructs point to a language, with a confidence")
+
+
+def test_language_map(workdir):
+    root = workdir / "map"
+    make_tree(root)
+    languages = LanguageMap(detect=True)
+    
+    def language(name):
+        return languages.language(root / name, name)
+    
+    assert language('main.go') == 'go', "an extension still wins over the content"
+    assert str(root / 'main.go') not in languages.detections
+    assert language('tools/runner') == 'python' and language('snippets/hello.txt') == 'go'
+    assert language('src/table.in') == 'c' and language('etc/env.conf') == 'bash'
+    assert languages.detections[str(root / 'snippets/hello.txt')].language == 'go'
+    assert language('docs/NOTES') == 'text' and language('misc/mixed') == 'text', "low confidence is plain text"
+    assert language('assets/logo') is None
+    assert LanguageMap(detect=True, plain_text=True).language(root / 'assets/logo') == 'text'
+    
+    strict = LanguageMap(detect=True, min_confidence=1.0)
+    assert strict.language(root / 'tools/runner') == 'python'
+    assert strict.language(root / 'snippets/hello.txt') == 'text'
+    
+    plain = LanguageMap()
+    assert plain.language(root / 'snippets/hello.txt') is None and plain.language(root / 'tools/runner') is None
+    assert plain.language(root / 'tools/deploy.cgi') is None, "without detection only extensionless files have #! lines"
+    try:
+        LanguageMap(detect=True, min_confidence=1.5)
+        assert False, "a confidence above 1, but no error"
+    except ValueError as e:
+        assert 'language_confidence' in str(e), e
+    print("✅ Files nothing maps get their detected language, or plain text when it is not confident")
+
+
+def test_index(workdir):
+    root = workdir / "tree"
+    make_tree(root)
+    # Python files are covered by the other tests, which need no parser
+    for name in ('tools/runner', 'etc/build.cfg'):
+        (root / name).unlink()
+    rag = RecordingRAG()
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "detect_state.db")),
+                            languages=LanguageMap(detect=True))
+    stats = indexer.index_directory(str(root), parallel=False)
+    assert stats['files_failed'] == 0, stats
+    
+    chunks = {}
+    for chunk in rag.inserted:
+        chunks.setdefault(chunk.filepath, []).append(chunk)
+    hello = chunks['snippets/hello.txt']
+    assert {c.language for c in hello} == {'go'} and 'main' in {c.name for c in hello}, "parsed as Go"
+    assert all(c.metadata['detected_language'] == 'go' and c.metadata['language_confidence'] >= 0.5 for c in hello)
+    deploy = chunks['tools/deploy.cgi']
+    assert {c.language for c in deploy} == {'bash'} and deploy[0].metadata['language_confidence'] == 1.0
+    
+    notes = chunks['docs/NOTES']
+    assert {c.language for c in notes} == {'text'} and {c.kind for c in notes} == {'text'}, "plain-text blocks"
+    assert notes[0].metadata['detected_language'] is None and notes[0].metadata['language_confidence'] == 0.0
+    assert chunks['misc/mixed'][0].metadata['detected_language'] in ('go', 'python')
+    assert not (chunks['main.go'][0].metadata or {}).get('detected_language'), "mapped files are not detected"
+    assert 'assets/logo' not in chunks
+    
+    rag = RecordingRAG()
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / "plain_state.db")),
+                  languages=LanguageMap()).index_directory(str(root), parallel=False)
+    assert {c.filepath for c in rag.inserted} == {'main.go'}, "detection is off by default"
+    print("✅ Indexing parses detected files as their language and records the detection")
+
+
+def test_settings():
+    saved = CONFIG.language_confidence
+    CONFIG.language_confidence = 2
+    try:
+        problems = cli.setting_problems()
+    finally:
+        CONFIG.language_confidence = saved
+    assert any(p.startswith('language_confidence:') for p in problems), problems
+    print("✅ config validate reports a language confidence out of range")
+
+
+def main():
+    print("=" * 70)
+    print("LANGUAGE DETECTION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_language_detection_"))
+    tests = [
+        lambda: test_detect(workdir),
+        lambda: test_language_map(workdir),
+        lambda: test_index(workdir),
+        test_settings,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
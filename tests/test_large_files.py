@@ -0,0 +1,173 @@
+#!/usr/bin/env python3
+"""
+Test script for large files
+Files over the size cap are skipped with a warning naming them, or with
+large_files 'stream' read from disk a window at a time into the plain-text
+blocks a text file gets, up to a cap of their own. Covers
+TextChunker.stream_chunks, discovery, an indexing run and the settings.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from chunkers import TextChunker
+from chunkers.token_splitter import stitch_parts
+from config import CONFIG
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+
+def generated_go(functions):
+    lines = ['// Code generated by tablegen. DO NOT EDIT.', '', 'package tables', '']
+    for n in range(functions):
+        lines += [f'// Lookup{n} returns entry {n} of the tablé', f'func Lookup{n}(key string) int {{',
+                  f'    return table{n}[key]', '}', '']
+    return '\n'.join(lines) + '\n'
+
+
+def blocks(chunks):
+    return [(c.content, c.line_start, c.line_end, c.part_index, c.part_count, c.metadata['content_offset'])
+            for c in chunks]
+
+
+def test_stream_chunks(workdir):
+    text = generated_go(60) + 'x' * 3000 + '\n' + '\n\n'
+    path = workdir / 'tables.go'
+    path.write_text(text, encoding='utf-8')
+    chunker = TextChunker('go', max_tokens=64, overlap_tokens=16)
+    whole = chunker.extract_chunks(text, 'tables.go')
+    assert len(whole) > 10
+    for window in (500, 4096, 1 << 20):
+        streamed = chunker.stream_chunks(str(path), 'tables.go', window_chars=window)
+        assert blocks(streamed) == blocks(whole), f"window {window}: blocks differ from the whole file's"
+    
+    # Windows shorter than a line still cover the file exactly, if not in the same blocks
+    raw = path.read_bytes()
+    streamed = chunker.stream_chunks(str(path), 'tables.go', window_chars=37)
+    for chunk in streamed:
+        assert raw[chunk.byte_start:chunk.byte_end].decode('utf-8') == chunk.content
+    parts = [{'content': c.content, 'metadata': {'part_index': c.part_index, 'metadata': c.metadata}}
+             for c in streamed]
+    assert stitch_parts(parts) == text.rstrip('\n'), "streamed blocks stitch back into the file"
+    short = generated_go(60)
+    path.write_text(short, encoding='utf-8')
+    assert blocks(chunker.stream_chunks(str(path), 'tables.go', window_chars=37)) == \
+        blocks(chunker.extract_chunks(short, 'tables.go'))
+    
+    (workdir / 'blank.txt').write_text('\n\n  \n')
+    assert chunker.stream_chunks(str(workdir / 'blank.txt'), 'blank.txt') == []
+    print("✅ Streamed blocks are the blocks of the whole text, with their byte ranges")
+
+
+def make_tree(workdir):
+    source = workdir / "tree"
+    (source / "tables").mkdir(parents=True)
+    (source / "tables" / "tables.go").write_text(generated_go(400) + '// Zanzibar holds the fallback entries\n'
+                                                 'var Zanzibar = map[string]int{}\n')
+    (source / "tables" / "small.go").write_text('package tables\n\n// Small is small\nfunc Small() int { return 1 }\n')
+    (source / "tables" / "blob.go").write_bytes(b'\0' * 40000)
+    return source
+
+
+def discover(workdir, source, **options):
+    indexer = ChromeIndexer(None, state_manager=StateManager(str(workdir / 'discover_state.db')), **options)
+    files = sorted(str(p.relative_to(source)) for p, _ in indexer._discover_files(source))
+    return files, indexer.stats
+
+
+def test_discovery(workdir):
+    source = make_tree(workdir)
+    size = (source / "tables" / "tables.go").stat().st_size
+    assert size > 20000
+    files, stats = discover(workdir, source, max_file_bytes=20000)
+    assert files == ['tables/small.go'], files
+    assert stats['files_too_large'] == [{'filepath': 'tables/blob.go', 'bytes': 40000},
+                                        {'filepath': 'tables/tables.go', 'bytes': size}], stats
+    assert stats['files_ignored'] == 2 and stats['files_streamed'] == 0
+    
+    files, stats = discover(workdir, source, max_file_bytes=20000, large_files='stream')
+    assert files == ['tables/small.go', 'tables/tables.go'], files
+    assert stats['files_streamed'] == 1 and stats['files_too_large'] == [], "a large binary file is still binary"
+    assert stats['files_ignored'] == 1
+    
+    files, stats = discover(workdir, source, max_file_bytes=20000, large_files='stream', max_stream_file_bytes=size - 1)
+    assert files == ['tables/small.go'] and stats['files_too_large'] == [{'filepath': 'tables/tables.go', 'bytes': size}]
+    try:
+        ChromeIndexer(None, state_manager=StateManager(str(workdir / 'discover_state.db')), large_files='chunk')
+        assert False, "an unknown large file mode, but no error"
+    except ValueError:
+        pass
+    print("✅ Discovery skips files over the cap with a warning, or streams them up to their own cap")
+
+
+def test_index(workdir):
+    source = make_tree(workdir)
+    rag = make_rag(workdir, "large_files")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "large_files_state.db")),
+                            max_file_bytes=20000, large_files='stream')
+    indexer.index_directory(str(source), parallel=False)
+    rag._build_keyword_index()
+    
+    stored = rag.collection.get(where={'filepath': 'tables/tables.go'})
+    metadatas = stored['metadatas']
+    assert len(metadatas) > 1 and all(m['kind'] == 'text' and m['language'] == 'go' for m in metadatas)
+    assert all(m.get('generated') for m in metadatas), "streamed files are still tagged generated"
+    assert len({m['symbol_id'] for m in metadatas}) == 1 and {m['part_count'] for m in metadatas} == {len(metadatas)}
+    assert indexer.stats['files_streamed'] == 1 and indexer.stats['files_failed'] == 0
+    
+    names = {m['name'] for m in rag.collection.get(where={'filepath': 'tables/small.go'})['metadatas']}
+    assert 'Small' in names, "files under the cap are parsed as before"
+    top = rag.retrieve_context("zanzibar fallback entries", n_results=1)[0]
+    assert top['metadata']['filepath'] == 'tables/tables.go' and 'var Zanzibar' in top['content'], top
+    print("✅ A streamed file is indexed as text blocks of its language, and found by search")
+
+
+def test_settings():
+    saved = CONFIG.large_files, CONFIG.max_stream_file_bytes
+    CONFIG.large_files, CONFIG.max_stream_file_bytes = 'parse', -1
+    try:
+        problems = cli.setting_problems()
+    finally:
+        CONFIG.large_files, CONFIG.max_stream_file_bytes = saved
+    assert any(p.startswith('large_files:') for p in problems), problems
+    assert any(p.startswith('max_stream_file_bytes:') for p in problems), problems
+    print("✅ config validate reports bad large file settings")
+
+
+def main():
+    print("=" * 70)
+    print("LARGE FILES TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_large_files_"))
+    tests = [
+        lambda: test_stream_chunks(workdir),
+        lambda: test_discovery(workdir / "discovery"),
+        lambda: test_index(workdir / "index"),
+        test_settings,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
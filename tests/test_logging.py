@@ -0,0 +1,154 @@
+#!/usr/bin/env python3
+"""
+Test script for structured logging
+Log records go to stderr as text lines or JSON objects carrying their fields
+(file, phase, counts, durations), and an indexing run reports through them;
+results printed on stdout stay free of log lines
+"""
+
+import io
+import json
+import logging
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.logger import (JsonFormatter, TextFormatter, console, create_progress_bar, get_logger, log_console,
+                          record_fields, setup_logger)
+from utils.state_manager import StateManager
+
+
+class RecordingHandler(logging.Handler):
+    """Keeps every record it is given"""
+    
+    def __init__(self):
+        super().__init__(logging.DEBUG)
+        self.records = []
+    
+    def emit(self, record):
+        self.records.append(record)
+
+
+def make_record(message, level=logging.INFO, **fields):
+    record = logging.LogRecord('chrome_rag', level, __file__, 1, message, None, None)
+    record.__dict__.update(fields)
+    return record
+
+
+def test_formatters():
+    """Fields come from extra; text appends them, JSON keeps them as keys"""
+    record = make_record("Processing: app.go (go)", file='app.go', phase='parsing', chunks=3, duration=0.25,
+                         durations={'parse': 0.25})
+    assert record_fields(record) == {'file': 'app.go', 'phase': 'parsing', 'chunks': 3, 'duration': 0.25,
+                                     'durations': {'parse': 0.25}}
+    assert record_fields(make_record("plain")) == {}
+    
+    text = TextFormatter().format(record)
+    assert text == 'Processing: app.go (go) file=app.go phase=parsing chunks=3 duration=0.250 durations={"parse": 0.25}', text
+    assert TextFormatter(markup=True).format(make_record("Linked", linker='[go]')) == r"Linked linker=\[go]"
+    
+    entry = json.loads(JsonFormatter().format(record))
+    assert entry['level'] == 'INFO' and entry['logger'] == 'chrome_rag' and entry['message'] == record.getMessage()
+    assert entry['file'] == 'app.go' and entry['chunks'] == 3 and entry['durations'] == {'parse': 0.25}
+    assert entry['time'].endswith('+00:00'), entry['time']
+    try:
+        raise KeyError('missing')
+    except KeyError:
+        failed = make_record("Failed")
+        failed.exc_info = sys.exc_info()
+    assert 'KeyError' in json.loads(JsonFormatter().format(failed))['exception']
+    print("✅ Text lines append fields, JSON objects carry them as keys")
+
+
+def test_handlers(workdir):
+    """Logs go to stderr in the chosen format and level, never to stdout"""
+    saved = sys.stderr
+    sys.stderr = stream = io.StringIO()
+    try:
+        logger = setup_logger('logging_test', log_file=str(workdir / 'logs' / 'run.log'), level='INFO',
+                              log_format='json')
+    finally:
+        sys.stderr = saved
+    logger.debug("hidden", extra={'phase': 'parsing'})
+    logger.info("Found 3 files", extra={'phase': 'discovering', 'files': 3})
+    lines = stream.getvalue().splitlines()
+    assert len(lines) == 1 and json.loads(lines[0])['files'] == 3, lines
+    logged = (workdir / 'logs' / 'run.log').read_text().splitlines()
+    assert [json.loads(line)['message'] for line in logged] == ['Found 3 files']
+    
+    setup_logger('logging_test', level='DEBUG')
+    handler = logging.getLogger('logging_test').handlers[0]
+    assert handler.console is log_console and log_console.stderr and not console.stderr
+    assert create_progress_bar().console is log_console, "progress bars stay off stdout too"
+    try:
+        setup_logger('logging_test', log_format='xml')
+        assert False, "an unknown format should be refused"
+    except ValueError:
+        pass
+    print("✅ Handlers write to stderr, as text or JSON, at the chosen level")
+
+
+def test_indexing_records(workdir):
+    """An indexing run logs each file and its totals as structured records"""
+    source = workdir / 'tree'
+    (source / 'pkg').mkdir(parents=True)
+    (source / 'pkg' / 'store.go').write_text("package pkg\n\n// Get returns a value\nfunc Get() int {\n    return 1\n}\n")
+    (source / 'pkg' / 'doc.md').write_text("# Store\n\nKeeps values.\n")
+    rag = make_rag(workdir, 'logging')
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / 'state.db')))
+    
+    handler = RecordingHandler()
+    logger = get_logger()
+    logger.addHandler(handler)
+    saved = console.file
+    console.file = output = io.StringIO()
+    try:
+        indexer.index_directory(str(source), parallel=False)
+    finally:
+        console.file = saved
+        logger.removeHandler(handler)
+    
+    fields = [record_fields(record) for record in handler.records]
+    found = next(f for f in fields if f.get('phase') == 'discovering')
+    assert found['files'] == 2 and found['files_by_language'] == {'go': 1, 'markdown': 1}, found
+    parsed = {f['file']: f for f in fields if f.get('phase') == 'parsing' and 'chunks' in f}
+    assert set(parsed) == {'pkg/store.go', 'pkg/doc.md'}, parsed
+    assert parsed['pkg/doc.md']['language'] == 'markdown' and parsed['pkg/doc.md']['duration'] >= 0
+    done = next(f for f in fields if f.get('phase') == 'done')
+    assert done['files_processed'] == 2 and done['chunks_created'] == indexer.stats['chunks_created']
+    assert set(done['durations']) >= {'crawl', 'parse', 'embed', 'upsert'}, done['durations']
+    assert 'Processing' not in output.getvalue() and 'Files to Index' not in output.getvalue(), \
+        "log lines stay out of the printed results"
+    print("✅ Indexing runs report files, phases, counts and durations as fields")
+
+
+def main():
+    print("=" * 70)
+    print("STRUCTURED LOGGING TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="logging_"))
+    tests = [test_formatters, lambda: test_handlers(workdir), lambda: test_indexing_records(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,292 @@
+#!/usr/bin/env python3
+"""
+Test script for the Lua chunker
+Sources are parsed by tree-sitter-lua
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import LuaChunker
+from config import CONFIG
+from helpers import make_chroma_rag
+
+
+INVENTORY = '''#!/usr/bin/env lua
+--- Inventory management for the modding API.
+-- Items live in slots.
+local Inventory = {}
+Inventory.__index = Inventory
+
+local MAX <const> = 64
+
+--[[
+Creates an inventory.
+@param size number of slots
+]]
+function Inventory.new(size)
+  local self = setmetatable({ slots = {}, size = size or 16 }, Inventory)
+  for i = 1, self.size do
+    self.slots[i] = false
+  end
+  return self
+end
+
+-- Adds an item; "end" in a string closes nothing
+function Inventory:add(item, count, ...)
+  local label = [[ if end ]] .. "end" .. 'function' --[==[ end ]==]
+  repeat
+    count = count - 1
+  until count <= 0
+  while false do end
+  if item then
+    return true
+  elseif count then
+    return false
+  else
+    return nil
+  end
+end
+
+Inventory.remove = function(self, item)
+  return self.slots[item]
+end
+
+Inventory["clear"] = function(self) self.slots = {} end
+
+---@deprecated use Inventory:add
+local legacy = function(x) return x end
+
+local function helper() return MAX end
+
+function Inventory.util.sort(list)
+  local function compare(a, b) return a < b end
+  table.sort(list, function(a, b) return compare(a, b) end)
+end
+
+Inventory.handlers = {
+  -- Fired on pickup
+  onPickup = function(item)
+    print(item)
+  end,
+  ["onDrop"] = function(item) end,
+  limit = 10,
+}
+
+globalCounter = function() return 1 end
+
+Inventory.helper = helper
+
+return Inventory
+'''
+
+PLUGIN = '''-- Plugin entry points
+local function load(config)
+  return config
+end
+
+local function unload() end
+
+function register(name, callback)
+  hooks[name] = callback
+end
+
+return {
+  version = "1.0",
+  load = load,
+  events = {
+    tick = function(dt) end,
+  },
+  reload = function()
+    unload()
+    load()
+  end,
+}
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def lines(chunk):
+    return chunk.line_start, chunk.line_end
+
+
+def test_function_forms():
+    """Global, local and assigned functions, with their scope, form and parameters"""
+    chunks = (LuaChunker().extract_chunks(PLUGIN, 'mods/plugin/init.lua')
+              + LuaChunker().extract_chunks(INVENTORY, 'mods/inventory.lua'))
+    
+    register = by_name(chunks, 'register')
+    assert register.type == 'function' and register.parent is None
+    assert register.metadata['scope'] == 'global' and register.metadata['form'] == 'declaration'
+    assert register.signature == 'function register(name, callback)', register.signature
+    assert register.metadata['params'] == [{'name': 'name'}, {'name': 'callback'}]
+    
+    load = by_name(chunks, 'load')
+    assert load.metadata['scope'] == 'local' and load.signature == 'local function load(config)'
+    assert load.content.startswith('local function load') and lines(load) == (2, 4), lines(load)
+    
+    legacy = by_name(chunks, 'legacy')
+    assert legacy.metadata['scope'] == 'local' and legacy.metadata['form'] == 'assignment'
+    assert legacy.signature == 'local legacy = function(x)', legacy.signature
+    counter = by_name(chunks, 'globalCounter')
+    assert counter.metadata['scope'] == 'global' and counter.metadata['form'] == 'assignment'
+    assert by_name(chunks, 'add').metadata['params'][-1] == {'name': '...', 'kind': 'vararg'}
+    print("✅ function, local function and name = function forms extracted with their scope")
+
+
+def test_tables_and_methods():
+    """Functions defined on a table record it as their parent; ':' definitions are methods"""
+    chunks = LuaChunker().extract_chunks(INVENTORY, 'mods/inventory.lua')
+    
+    new = by_name(chunks, 'new')
+    assert new.type == 'function' and new.qualified_name == 'Inventory.new' and new.metadata['table'] == 'Inventory'
+    add = by_name(chunks, 'add')
+    assert add.type == 'method' and add.parent_class == 'Inventory' and add.signature.startswith('function Inventory:add(')
+    assert [p['name'] for p in add.metadata['params']] == ['item', 'count', '...'], "self is implied"
+    
+    remove = by_name(chunks, 'remove')
+    assert remove.metadata['form'] == 'assignment' and remove.qualified_name == 'Inventory.remove'
+    assert remove.signature == 'Inventory.remove = function(self, item)', remove.signature
+    assert by_name(chunks, 'clear').qualified_name == 'Inventory.clear'
+    
+    sort = by_name(chunks, 'sort')
+    assert sort.parent == 'Inventory.util' and sort.namespace == 'Inventory' and sort.parent_class == 'util'
+    
+    pickup = by_name(chunks, 'onPickup')
+    assert pickup.qualified_name == 'Inventory.handlers.onPickup' and pickup.metadata['form'] == 'field'
+    assert pickup.signature == 'onPickup = function(item)' and pickup.doc == 'Fired on pickup'
+    assert by_name(chunks, 'onDrop').signature == '["onDrop"] = function(item)'
+    print("✅ M.name, M:name, M.name = function and constructor fields belong to their table")
+
+
+def test_boundaries():
+    """end in strings, long strings and comments, repeat/until and loop do's keep blocks balanced"""
+    chunker = LuaChunker()
+    chunks = chunker.extract_chunks(INVENTORY, 'mods/inventory.lua')
+    assert not chunker.diagnostics, chunker.diagnostics
+    
+    assert lines(by_name(chunks, 'new')) == (13, 19), lines(by_name(chunks, 'new'))
+    add = by_name(chunks, 'add')
+    assert lines(add) == (22, 35) and add.content.endswith('  end\nend'), lines(add)
+    assert lines(by_name(chunks, 'remove')) == (37, 39)
+    assert lines(by_name(chunks, 'clear')) == (41, 41)
+    assert lines(by_name(chunks, 'sort')) == (48, 51)
+    assert lines(by_name(chunks, 'onPickup')) == (55, 57)
+    names = [c.name for c in chunks]
+    assert 'compare' not in names, "functions nested in a function body stay part of it"
+    
+    chunker.extract_chunks('function broken()\n  if x then\n    return 1\nend\n', 'broken.lua')
+    assert [d['line'] for d in chunker.diagnostics] == [1], chunker.diagnostics
+    chunker.extract_chunks('local function f() end\nend\n', 'extra.lua')
+    assert chunker.diagnostics and "unexpected 'end'" in chunker.diagnostics[0]['message'], chunker.diagnostics
+    print("✅ Function boundaries and line ranges are exact around strings, comments and nested blocks")
+
+
+def test_module_return():
+    """return M and return { ... } make a module chunk listing the exports"""
+    chunks = LuaChunker().extract_chunks(INVENTORY, 'mods/inventory.lua')
+    module = by_name(chunks, 'inventory', 'module')
+    assert module.metadata['table'] == 'Inventory' and module.content == 'return Inventory'
+    assert module.metadata['exports'] == ['__index', 'new', 'add', 'remove', 'clear', 'handlers', 'helper'], \
+        module.metadata['exports']
+    assert module.doc == 'Inventory management for the modding API.\nItems live in slots.', module.doc
+    exported = sorted(c.qualified_name for c in chunks if c.metadata.get('exported'))
+    assert exported == ['Inventory.add', 'Inventory.clear', 'Inventory.handlers.onDrop', 'Inventory.handlers.onPickup',
+                        'Inventory.new', 'Inventory.remove', 'Inventory.util.sort', 'helper'], exported
+    
+    chunks = LuaChunker().extract_chunks(PLUGIN, 'mods/plugin/init.lua')
+    module = by_name(chunks, 'plugin', 'module')
+    assert module.metadata['exports'] == ['version', 'load', 'events', 'reload'], module.metadata['exports']
+    assert 'table' not in module.metadata and lines(module) == (12, 22), lines(module)
+    assert by_name(chunks, 'tick').qualified_name == 'plugin.events.tick'
+    exported = sorted(c.qualified_name for c in chunks if c.metadata.get('exported'))
+    assert exported == ['load', 'plugin.events.tick', 'plugin.reload'], exported
+    
+    chunks = LuaChunker().extract_chunks('local M = {}\nfunction M.run() end\nreturn setmetatable(M, {})\n', 'tool.lua')
+    assert by_name(chunks, 'tool', 'module').metadata['exports'] == ['run']
+    chunks = LuaChunker().extract_chunks('local function main() print(1) end\nif ready then\n  return main\nend\n',
+                                         'script.lua')
+    assert [c.type for c in chunks] == ['function'], "a return inside a block is not the module's"
+    print("✅ Module return tables list their exports and mark the exported functions")
+
+
+def test_docs():
+    """--, --- and --[[ ]] comments right above a definition become its doc"""
+    chunks = LuaChunker().extract_chunks(INVENTORY, 'mods/inventory.lua')
+    assert by_name(chunks, 'new').doc == 'Creates an inventory.\n@param size number of slots'
+    assert by_name(chunks, 'add').doc == 'Adds an item; "end" in a string closes nothing'
+    legacy = by_name(chunks, 'legacy')
+    assert legacy.doc == '@deprecated use Inventory:add' and legacy.metadata['deprecated'] == 'use Inventory:add'
+    assert by_name(chunks, 'remove').doc is None
+    print("✅ Leading comment blocks fill the doc field")
+
+
+def test_sample_file():
+    """The multi-language sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'all_languages' / 'UserService.lua'
+    chunker = LuaChunker()
+    chunks = chunker.extract_chunks(sample.read_text(), 'all_languages/UserService.lua')
+    assert not chunker.diagnostics, chunker.diagnostics
+    
+    find = by_name(chunks, 'findById')
+    assert find.type == 'method' and find.qualified_name == 'UserService.findById' and lines(find) == (39, 51)
+    assert by_name(chunks, 'validate').parent == 'User'
+    assert lines(by_name(chunks, 'processAsync')) == (71, 82)
+    module = by_name(chunks, 'UserService', 'module')
+    assert module.metadata['exports'] == ['UserService', 'User', 'deepCopy'] and module.doc == 'Module exports'
+    assert all(c.metadata.get('exported') for c in chunks if c.type != 'module')
+    assert CONFIG.file_types['lua'].parser_type == 'treesitter'
+    print("✅ Sample file parsed")
+
+
+def test_search(workdir):
+    """A table's API is found by its qualified names"""
+    rag = make_chroma_rag(workdir / "db", "test_lua")
+    rag.add_chunks_batch(LuaChunker().extract_chunks(INVENTORY, 'mods/inventory.lua')
+                         + LuaChunker().extract_chunks(PLUGIN, 'mods/plugin/init.lua'))
+    rag._build_keyword_index()
+    
+    names = [r['metadata']['name'] for r in rag.retrieve_context("inventory item", n_results=20,
+                                                                  filter_expr="name=Inventory.*")]
+    assert sorted(names) == ['add', 'clear', 'new', 'onDrop', 'onPickup', 'remove', 'sort'], names
+    results = rag.retrieve_context("fired on pickup", n_results=3, languages=['lua'])
+    assert results[0]['metadata']['name'] == 'onPickup', [r['metadata']['name'] for r in results]
+    print("✅ Lua functions are searchable by their owning table")
+
+
+def main():
+    print("=" * 70)
+    print("LUA CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_lua_"))
+    
+    tests = [
+        test_function_forms, test_tables_and_methods, test_boundaries, test_module_return, test_docs,
+        test_sample_file, lambda: test_search(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
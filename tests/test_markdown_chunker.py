@@ -0,0 +1,165 @@
+#!/usr/bin/env python3
+"""
+Test script for the Markdown chunker
+Sections by heading hierarchy, fenced code blocks and YAML frontmatter
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import MarkdownChunker, assign_byte_ranges
+from chunkers.markdown_chunker import _parse_simple_yaml, parse_frontmatter
+from config import CONFIG
+from indexer import process_file_worker
+
+
+DESIGN = '''---
+title: Auth design
+tags: [auth, sessions]
+owners:
+  - alice
+  - bob
+draft: false
+---
+How requests are authenticated.
+
+# Architecture
+
+The server is split into a gateway and workers.
+
+## Auth
+
+### Sessions
+
+Sessions are stored server side and looked up by `Authenticate`.
+
+```go title="auth.go"
+# not a heading
+func Authenticate(token string) (*Session, error) {}
+```
+
+~~~
+plain fence
+~~~
+
+### Tokens {#tokens}
+
+Bearer tokens expire after an hour.
+
+Rollout
+=======
+
+Enabled per tenant.
+'''
+
+
+def sections(code=DESIGN, filepath='docs/auth.md'):
+    return {c.name: c for c in assign_byte_ranges(MarkdownChunker().extract_chunks(code, filepath), code)}
+
+
+def test_heading_paths():
+    chunks = sections()
+    assert list(chunks) == ['Auth design', 'Architecture', 'Sessions', 'Tokens', 'Rollout'], list(chunks)
+    assert 'Auth' not in chunks  # only a heading: kept in its children's paths
+    
+    sessions = chunks['Sessions']
+    assert sessions.type == 'section' and sessions.language == 'markdown'
+    assert sessions.metadata['heading_path'] == 'Architecture > Auth > Sessions'
+    assert sessions.metadata['heading_level'] == 3
+    assert sessions.signature == 'Architecture > Auth > Sessions'
+    assert sessions.context == 'Architecture > Auth'
+    assert chunks['Tokens'].metadata['heading_path'] == 'Architecture > Auth > Tokens'
+    assert chunks['Rollout'].metadata['heading_path'] == 'Rollout'  # setext level 1
+    assert chunks['Rollout'].context is None
+    assert chunks['Architecture'].content == (
+        "# Architecture\n\nThe server is split into a gateway and workers."
+    )
+    print("✅ Sections carry their heading path")
+
+
+def test_code_blocks():
+    sessions = sections()['Sessions']
+    assert '# not a heading' in sessions.content and sessions.content.endswith('plain fence\n~~~')
+    blocks = sessions.metadata['code_blocks']
+    assert [b['language'] for b in blocks] == ['go', None], blocks
+    assert sessions.metadata['code_languages'] == ['go']
+    assert DESIGN.split('\n')[blocks[0]['line_start'] - 1].startswith('```go')
+    assert DESIGN.split('\n')[blocks[0]['line_end'] - 1] == '```'
+    
+    unclosed = "# Setup\n\n```bash\n# install\nmake\n"
+    setup = sections(unclosed)
+    assert list(setup) == ['Setup'] and setup['Setup'].metadata['code_languages'] == ['bash']
+    print("✅ Fenced code blocks are kept whole and language-tagged")
+
+
+def test_frontmatter():
+    chunks = sections()
+    expected = {'title': 'Auth design', 'tags': ['auth', 'sessions'], 'owners': ['alice', 'bob'], 'draft': False}
+    assert chunks['Auth design'].metadata['frontmatter'] == expected
+    assert chunks['Rollout'].metadata['frontmatter'] == expected
+    assert chunks['Auth design'].metadata['heading_level'] == 0
+    assert chunks['Auth design'].line_start == 9  # first line after the frontmatter
+    
+    # Without PyYAML the common subset is still understood
+    text = 'title: "A: B"\nweight: 3\ntags: [x, "y"]\nauthors:\n  - carol\nextra:\n  nested: 1\n'
+    assert _parse_simple_yaml(text) == {
+        'title': 'A: B', 'weight': 3, 'tags': ['x', 'y'], 'authors': ['carol'], 'extra': []
+    }
+    assert parse_frontmatter('- a list') == {}
+    
+    untitled = sections("Just prose, no headings at all.\n", 'docs/notes.md')
+    assert list(untitled) == ['notes.md']
+    print("✅ Frontmatter is parsed into metadata")
+
+
+def test_byte_ranges():
+    encoded = DESIGN.encode('utf-8')
+    for chunk in sections().values():
+        assert encoded[chunk.byte_start:chunk.byte_end].decode('utf-8') == chunk.content, chunk.name
+    print("✅ Sections map back to their bytes in the file")
+
+
+def test_indexing_mdx():
+    assert CONFIG.get_language_for_extension('.mdx') == 'markdown'
+    workdir = Path(tempfile.mkdtemp(prefix="markdown_"))
+    try:
+        path = workdir / 'guide.mdx'
+        path.write_text("import { Tabs } from 'docs'\n\n# Guide\n\n## Install\n\n<Tabs>npm install</Tabs>\n")
+        _, language, chunks, error, _ = process_file_worker((path, 'markdown', workdir, 512, 'symbol'))
+        assert error is None and language == 'markdown', error
+        install = [c for c in chunks if c.name == 'Install'][0]
+        # The enclosing headings are written in front of the section for embedding
+        assert install.content.startswith("Guide\n## Install"), install.content
+        assert install.metadata['heading_path'] == 'Guide > Install'
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ .mdx files are indexed as Markdown")
+
+
+def main():
+    print("=" * 70)
+    print("MARKDOWN CHUNKER TEST")
+    print("=" * 70)
+    
+    tests = [test_heading_paths, test_code_blocks, test_frontmatter, test_byte_ranges, test_indexing_mdx]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,113 @@
+#!/usr/bin/env python3
+"""
+Test script for match highlights
+Results the keyword index matched carry the spans of their content where the
+query's terms occur (whole identifiers or their camelCase/snake_case parts);
+results only the vector search found carry none.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from helpers import make_rag
+from utils.match_highlights import highlight_spans
+from utils.result_format import result_record
+
+SOURCE = '''package session
+
+// Expire drops the sessions idle for longer than the timeout
+func Expire(store *Store, timeout int) int {
+    dropped := 0
+    for id, s := range store.sessions {
+        if s.idleTimeout > timeout {
+            delete(store.sessions, id)
+            dropped++
+        }
+    }
+    return dropped
+}
+
+// SignIn opens a session for the user
+func SignIn(user string) *Session {
+    return open(user)
+}
+
+// Render draws the account page
+func Render(a *Account) string {
+    return page(a)
+}
+'''
+
+
+def test_spans():
+    content = "func Expire(timeout int) {\n    if s.idleTimeout > timeout {\n        max_timeout = 0\n    }\n}"
+    spans = highlight_spans(content, ['timeout'])
+    assert [content[s['start']:s['end']] for s in spans] == ['timeout', 'Timeout', 'timeout', 'timeout'], spans
+    assert [s['line'] for s in spans] == [1, 2, 2, 3] and all(s['term'] == 'timeout' for s in spans), spans
+    
+    whole = highlight_spans(content, ['idletimeout', 'idle'])
+    assert [(content[s['start']:s['end']], s['term']) for s in whole] == [('idleTimeout', 'idletimeout')], whole
+    assert highlight_spans(content, ['max']) == [{'start': 68, 'end': 71, 'line': 3, 'term': 'max'}]
+    assert highlight_spans(content, ['expire', 'timeout'], limit=2) == highlight_spans(content, ['expire', 'timeout'])[:2]
+    assert highlight_spans(content, []) == [] and highlight_spans(content, ['login']) == []
+    print("✅ Spans cover whole identifiers, or the parts of them that match, with their lines")
+
+
+def test_search_highlights(rag):
+    results = rag.retrieve_context("idle timeout", n_results=3, lexical_weight=0.5)
+    top = results[0]
+    assert top['metadata']['name'] == 'Expire', top['metadata']['name']
+    matched = [top['content'][s['start']:s['end']] for s in top['highlights']]
+    assert 'timeout' in matched and 'idle' in matched and 'Timeout' in matched, matched
+    assert result_record(top)['highlights'] == top['highlights']
+    
+    render = next(r for r in results if r['metadata']['name'] == 'Render')
+    assert render['bm25_rank'] is None and render['highlights'] == [], "vector-only matches have no highlights"
+    
+    semantic = rag.retrieve_context("idle timeout", n_results=3, lexical_weight=0.0)
+    assert all(r['highlights'] == [] for r in semantic), "a vector-only search highlights nothing"
+    
+    streamed = list(rag.iter_context("login", n_results=1, lexical_weight=1.0, expand_query=True))
+    assert streamed[0]['metadata']['name'] == 'SignIn'
+    assert {s['term'] for s in streamed[0]['highlights']} >= {'signin'}, streamed[0]['highlights']
+    print("✅ Lexical matches are highlighted, expansion terms included; vector-only ones are not")
+
+
+def main():
+    print("=" * 70)
+    print("MATCH HIGHLIGHTS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_highlights_"))
+    rag = make_rag(workdir, "highlights")
+    rag.add_chunks_batch(GoChunker().extract_chunks(SOURCE, "session.go"))
+    rag._build_keyword_index()
+    
+    tests = [
+        test_spans,
+        lambda: test_search_highlights(rag),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,125 @@
+#!/usr/bin/env python3
+"""
+Test script for the MCP stdio server
+Drives the JSON-RPC message flow an agent would send, over in-memory streams
+"""
+
+import io
+import json
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, link_go_packages
+from helpers import make_chroma_rag
+from mcp_server import MCPServer
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+
+def call(server, method, params=None, request_id=1):
+    return server.handle({'jsonrpc': '2.0', 'id': request_id, 'method': method, 'params': params or {}})
+
+
+def test_handshake(server):
+    response = call(server, 'initialize', {'protocolVersion': '2024-11-05', 'capabilities': {},
+                                           'clientInfo': {'name': 'test', 'version': '0'}})
+    assert response['result']['protocolVersion'] == '2024-11-05'
+    assert 'tools' in response['result']['capabilities']
+    assert server.handle({'jsonrpc': '2.0', 'method': 'notifications/initialized'}) is None
+    tools = {t['name'] for t in call(server, 'tools/list')['result']['tools']}
+    assert tools == {'search_code', 'get_symbol'}, tools
+    print("✅ initialize / tools/list handshake")
+
+
+def test_search_code(server):
+    result = call(server, 'tools/call', {'name': 'search_code', 'arguments': {
+        'query': 'authenticate', 'top_k': 2, 'languages': ['go'], 'kinds': ['method']}})['result']
+    assert not result['isError'] and len(result['content']) == 2
+    first = result['content'][0]
+    assert first['type'] == 'text'
+    assert first['text'].startswith('[1] complex.go:') and 'AdminUser.Authenticate (method)' in first['text'], first['text']
+    
+    result = call(server, 'tools/call', {'name': 'search_code', 'arguments': {
+        'query': 'authenticate', 'top_k': 1, 'kinds': ['method'], 'with_neighbors': 'bodies'}})['result']
+    text = result['content'][0]['text']
+    assert not result['isError'] and ('\nBefore: ' in text or '\nAfter: ' in text), text
+    assert text.count('```') >= 4, "neighbor bodies follow the result's code"
+    print("✅ search_code returns attributed chunks, with their neighbors when asked")
+
+
+def test_get_symbol(server):
+    result = call(server, 'tools/call', {'name': 'get_symbol', 'arguments': {
+        'filepath': 'complex.go', 'name': 'AdminUser.Authenticate', 'context_lines': 2}})['result']
+    assert not result['isError'], result
+    text = result['content'][0]['text']
+    assert text.startswith('complex.go:') and 'func (a *AdminUser) Authenticate' in text
+    assert '// Authenticate implements Authenticator' in text  # from the surrounding lines
+    
+    missing = call(server, 'tools/call', {'name': 'get_symbol', 'arguments': {
+        'filepath': 'complex.go', 'name': 'Nope'}})['result']
+    assert missing['isError']
+    
+    result = call(server, 'tools/call', {'name': 'get_symbol', 'arguments': {
+        'filepath': 'complex.go', 'name': 'AdminUser', 'context_lines': 0, 'include_methods': True}})['result']
+    assert len(result['content']) == 2, result
+    methods = result['content'][1]['text'].splitlines()
+    assert methods[0] == 'Methods of AdminUser:', methods
+    assert [line.split('(a *AdminUser) ')[1].split('(')[0] for line in methods[1:]] == \
+        ['Authenticate', 'Logout', 'AddPermission'], methods
+    print("✅ get_symbol returns the definition with surrounding lines")
+
+
+def test_errors(server):
+    assert call(server, 'no/such/method')['error']['code'] == -32601
+    assert call(server, 'tools/call', {'name': 'nope'})['error']['code'] == -32602
+    assert server.handle_line('{not json')['error']['code'] == -32700
+    print("✅ JSON-RPC errors for unknown methods, tools and bad JSON")
+
+
+def test_stdio(server):
+    stdin = io.StringIO(
+        json.dumps({'jsonrpc': '2.0', 'id': 7, 'method': 'ping'}) + '\n' +
+        json.dumps({'jsonrpc': '2.0', 'method': 'notifications/initialized'}) + '\n'
+    )
+    stdout = io.StringIO()
+    server.serve(stdin=stdin, stdout=stdout)
+    lines = stdout.getvalue().splitlines()
+    assert lines == [json.dumps({'jsonrpc': '2.0', 'id': 7, 'result': {}})], lines
+    print("✅ Newline-delimited messages over stdio")
+
+
+def main():
+    print("=" * 70)
+    print("MCP SERVER TEST")
+    print("=" * 70)
+    
+    workdir = tempfile.mkdtemp(prefix="rag_mcp_")
+    shutil.copy(SAMPLES / "complex.go", Path(workdir) / "complex.go")
+    rag = make_chroma_rag(Path(workdir) / "db", "test_mcp")
+    rag.add_chunks_batch(link_go_packages(GoChunker().extract_chunks((SAMPLES / "complex.go").read_text(), "complex.go")))
+    rag._build_keyword_index()
+    server = MCPServer(rag, source_path=workdir)
+    
+    tests = [test_handshake, test_search_code, test_get_symbol, test_errors, test_stdio]
+    failed = 0
+    for test in tests:
+        try:
+            test(server)
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
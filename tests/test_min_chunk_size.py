@@ -0,0 +1,156 @@
+#!/usr/bin/env python3
+"""
+Test script for the minimum chunk size
+Functions and methods below min_chunk_lines lines or min_chunk_tokens tokens
+are left out of the index and counted in the summary; languages can set their
+own minimum, and Go's package linker still sees the small methods.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import is_small_symbol
+from chunkers.base_chunker import CodeChunk, parse_metadata, qualified_name
+from config import CONFIG
+from helpers import make_rag
+from indexer import ChromeIndexer, chunking_params
+from utils.state_manager import StateManager
+
+GO_SOURCE = '''package auth
+
+// Namer has a display name
+type Namer interface {
+    Name() string
+}
+
+type User struct {
+    name string
+}
+
+func (u *User) Name() string { return u.name }
+
+func Logout() {}
+
+// Authenticate checks the password against the stored hash
+func Authenticate(user *User, password string) bool {
+    hash := hashPassword(password)
+    return compareHashes(hash, lookupHash(user.name))
+}
+'''
+
+BASH_SOURCE = '''#!/bin/bash
+
+noop() { :; }
+
+deploy() {
+    build_release "$1"
+    upload_artifacts "$1"
+}
+'''
+
+
+def index(workdir, name, overrides=None, **options):
+    """Qualified names indexed per file of the sample tree, with the indexer that indexed them"""
+    source = workdir / name
+    (source / "auth").mkdir(parents=True)
+    (source / "auth" / "user.go").write_text(GO_SOURCE)
+    (source / "deploy.sh").write_text(BASH_SOURCE)
+    
+    rag = make_rag(workdir, name)
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / f"{name}-state.db")), **options)
+    saved = CONFIG.language_chunking
+    CONFIG.language_chunking = overrides or {}
+    try:
+        indexer.index_directory(str(source), parallel=False)
+    finally:
+        CONFIG.language_chunking = saved
+    
+    names = {}
+    for metadata in rag.collection.get(include=['metadatas'])['metadatas']:
+        names.setdefault(metadata['filepath'], {})[qualified_name(metadata)] = metadata
+    return names, indexer
+
+
+def test_min_lines(workdir):
+    names, indexer = index(workdir, "lines", min_lines=2)
+    assert set(names['auth/user.go']) >= {'Namer', 'User', 'Authenticate'}, names['auth/user.go']
+    assert not {'User.Name', 'Logout'} & set(names['auth/user.go']), "one-line Go functions are left out"
+    assert 'Namer.Name' in names['auth/user.go'], "interface methods are not functions"
+    assert 'noop' not in names['deploy.sh'] and 'deploy' in names['deploy.sh'], names['deploy.sh']
+    assert indexer.stats['chunks_too_small'] == 3, indexer.stats['chunks_too_small']
+    assert indexer.stats['chunks_created'] == sum(len(found) for found in names.values()), indexer.stats
+    
+    user = parse_metadata(names['auth/user.go']['User']['metadata'])
+    assert user['implements'] == ['Namer'], "the linker still saw the small method"
+    assert [m['name'] for m in user['methods']] == ['User.Name']
+    
+    # The files count as indexed, so an update has nothing to do
+    indexer.update_index(str(workdir / "lines"), report=False)
+    assert indexer.stats['files_processed'] == 0 and indexer.stats['files_skipped'] == 2, indexer.stats
+    print("✅ Functions and methods shorter than min_lines are left out and counted")
+
+
+def test_per_language(workdir):
+    names, indexer = index(workdir, "override", {'bash': {'min_chunk_lines': 0}, 'go': {'min_chunk_tokens': 12}},
+                           min_lines=2)
+    assert 'noop' in names['deploy.sh'], "the Bash override keeps every function"
+    assert not {'User.Name', 'Logout'} & set(names['auth/user.go']) and 'Authenticate' in names['auth/user.go']
+    assert indexer.stats['chunks_too_small'] == 2
+    
+    params = chunking_params('go', min_lines=2)
+    assert (params['min_chunk_lines'], params['min_chunk_tokens']) == (2, CONFIG.min_chunk_tokens), params
+    
+    names, indexer = index(workdir, "kept")
+    assert {'User.Name', 'Logout'} <= set(names['auth/user.go']) and indexer.stats['chunks_too_small'] == 0
+    print("✅ Languages set their own minimum, and nothing is left out by default")
+
+
+def test_small_symbols():
+    def chunk(content, **fields):
+        lines = content.count('\n') + 1
+        return CodeChunk(**dict(dict(type='function', name='f', content=content, filepath='a.go', language='go',
+                                     line_start=1, line_end=lines), **fields))
+    
+    assert is_small_symbol(chunk('func Logout() {}'), min_lines=2)
+    assert not is_small_symbol(chunk('func Logout() {\n}'), min_lines=2)
+    assert is_small_symbol(chunk('func Logout() {\n}'), min_tokens=20)
+    assert not is_small_symbol(chunk('func Logout() {}'), min_lines=0, min_tokens=0)
+    assert not is_small_symbol(chunk('type ID string', type='type'), min_lines=2), "only functions and methods"
+    assert not is_small_symbol(chunk('}', part_index=1, part_count=2), min_lines=2), "parts of a split symbol"
+    print("✅ Only whole functions and methods below the minimum are small")
+
+
+def main():
+    print("=" * 70)
+    print("MINIMUM CHUNK SIZE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="min_chunk_size_"))
+    tests = [
+        lambda: test_min_lines(workdir),
+        lambda: test_per_language(workdir),
+        test_small_symbols,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,124 @@
+#!/usr/bin/env python3
+"""
+Test script for relevance thresholds
+Results below min_score are dropped, on the vector similarity alone or on the
+fused relevance of a hybrid search, and a query nothing matches well returns
+an empty result instead of its weak top n.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from config import CONFIG
+from helpers import make_rag
+from rag import _keyword_weight
+from utils.code_tokenizer import tokenize_code
+
+SOURCE = '''package auth
+
+// CheckPassword compares a password with the stored hash
+func CheckPassword(user *User, password string) bool {
+    return compareHash(user.hash, password)
+}
+
+// RotateToken replaces the session token of a user
+func RotateToken(session *Session) string {
+    session.token = newToken()
+    return session.token
+}
+
+// ParseConfig reads the service configuration file
+func ParseConfig(path string) (*Config, error) {
+    return loadYAML(path)
+}
+'''
+
+
+def names(results):
+    return [r['metadata']['name'] for r in results]
+
+
+def keyword_weight(rag, query):
+    return _keyword_weight(rag.bm25, tokenize_code(query))
+
+
+def test_vector_threshold(rag):
+    unfiltered = rag.retrieve_context("check password hash", n_results=3, lexical_weight=0.0)
+    assert len(unfiltered) == 3, "without a threshold the top n always come back"
+    assert names(unfiltered)[0] == 'CheckPassword'
+    assert all(r['relevance'] == r['vector_score'] for r in unfiltered), "vector only: relevance is the similarity"
+    
+    cutoff = (unfiltered[0]['relevance'] + unfiltered[1]['relevance']) / 2
+    filtered = rag.retrieve_context("check password hash", n_results=3, lexical_weight=0.0, min_score=cutoff)
+    assert names(filtered) == ['CheckPassword'], names(filtered)
+    print("✅ Results below min_score are dropped")
+
+
+def test_hybrid_threshold(rag):
+    results = rag.retrieve_context("rotate session token", n_results=3)
+    top = results[0]
+    assert top['metadata']['name'] == 'RotateToken', names(results)
+    expected = 0.5 * top['vector_score'] + 0.5 * min(top['bm25_score'] / keyword_weight(rag, "rotate session token"), 1.0)
+    assert abs(top['relevance'] - expected) < 1e-9, (top['relevance'], expected)
+    assert 0.0 <= top['relevance'] <= 1.0
+    assert all(r['relevance'] < top['relevance'] for r in results[1:]), [r['relevance'] for r in results]
+    
+    kept = rag.retrieve_context("rotate session token", n_results=3, min_score=top['relevance'])
+    assert names(kept) == ['RotateToken'], names(kept)
+    print("✅ Hybrid search thresholds the fused relevance")
+
+
+def test_nothing_relevant(rag):
+    query = "kubernetes operator reconcile loop"
+    assert len(rag.retrieve_context(query, n_results=3)) == 3, "the weak top n without a threshold"
+    assert rag.retrieve_context(query, n_results=3, min_score=0.35) == [], "an empty result, not an error"
+    
+    saved = CONFIG.min_score
+    CONFIG.min_score = 0.35
+    try:
+        assert rag.retrieve_context(query, n_results=3) == [], "CONFIG.min_score is the default"
+        assert names(rag.retrieve_context("check password hash", n_results=3))[0] == 'CheckPassword'
+    finally:
+        CONFIG.min_score = saved
+    print("✅ A query nothing matches well returns no results")
+
+
+def main():
+    print("=" * 70)
+    print("RELEVANCE THRESHOLD TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="min_score_"))
+    rag = make_rag(workdir, "min_score")
+    rag.add_chunks_batch(GoChunker().extract_chunks(SOURCE, "auth/auth.go"))
+    rag._build_keyword_index()
+    
+    tests = [
+        lambda: test_vector_threshold(rag),
+        lambda: test_hybrid_threshold(rag),
+        lambda: test_nothing_relevant(rag),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,170 @@
+#!/usr/bin/env python3
+"""
+Test script for multi-repo indexing
+Two repositories with the same relative paths are indexed into one collection;
+chunk ids, updates, renames, deletes and search filters must keep them apart.
+Uses a small deterministic embedder
+"""
+
+import os
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import HashEmbedder, make_rag
+from indexer import ChromeIndexer, parse_root
+from utils.state_manager import StateManager
+
+
+def go_file(package, name, body='return 1'):
+    return f"package {package}\n\nimport \"fmt\"\n\nfunc {name}() int {{\n    {body}\n}}\n"
+
+
+class Harness:
+    """Two repositories sharing a layout, indexed into one collection"""
+    
+    def __init__(self, workdir: Path):
+        self.backend = workdir / "backend"
+        self.frontend = workdir / "frontend-src"
+        for root, name in ((self.backend, "CheckToken"), (self.frontend, "ShowLogin")):
+            (root / "auth").mkdir(parents=True)
+            (root / "auth" / "user.go").write_text(go_file("auth", name))
+        
+        self.embedder = HashEmbedder()
+        self.rag = make_rag(workdir, "repos", embedder=self.embedder)
+        self.indexer = ChromeIndexer(self.rag, state_manager=StateManager(str(workdir / "state.db")))
+        self.roots = [("backend", str(self.backend)), (None, str(self.frontend))]
+    
+    def index(self, update=False):
+        return self.indexer.index_roots(self.roots, update=update, parallel=False)
+    
+    def chunks(self, repo, filepath):
+        found = self.rag.collection.get(where={"$and": [{"repo": repo}, {"filepath": filepath}, {"type": "function"}]})
+        return sorted(zip(found['ids'], found['metadatas']), key=lambda item: item[0])
+    
+    def names(self, repo, filepath):
+        return [metadata['name'] for _, metadata in self.chunks(repo, filepath)]
+
+
+def test_same_path_in_two_repos(h):
+    results = h.index()
+    assert set(results) == {"backend", "frontend-src"}, results
+    assert all(stats['files_processed'] == 1 for stats in results.values()), results
+    
+    backend = h.chunks("backend", "auth/user.go")
+    frontend = h.chunks("frontend-src", "auth/user.go")
+    assert [m['name'] for _, m in backend] == ['CheckToken'], backend
+    assert [m['name'] for _, m in frontend] == ['ShowLogin'], frontend
+    assert backend[0][0] == "backend:auth/user.go:CheckToken", backend[0][0]
+    assert backend[0][1]['symbol_id'] == backend[0][0]
+    assert backend[0][0] != frontend[0][0]
+    
+    assert h.rag.repo_roots() == {"backend": str(h.backend.resolve()), "frontend-src": str(h.frontend.resolve())}
+    stats = h.rag.get_statistics()
+    assert stats['chunks_by_repo'] == {"backend": 2, "frontend-src": 2}, stats  # a function and a package summary
+    assert stats['unique_files'] == 2, stats
+    print("✅ The same relative path in two repositories gives two sets of chunks")
+
+
+def test_repo_filter(h):
+    results = h.rag.retrieve_context("check token login", n_results=5, repos=["frontend-src"])
+    assert sorted(r['metadata']['name'] for r in results) == ['ShowLogin', 'auth'], results
+    results = h.rag.retrieve_context("check token login", n_results=5, repos=["backend", "frontend-src"])
+    assert {r['metadata']['repo'] for r in results} == {"backend", "frontend-src"}
+    assert h.rag.retrieve_context("check token", repos=["missing"]) == []
+    print("✅ Searches can be limited to one or more repositories")
+
+
+def test_surrounding_per_repo(h):
+    results = h.rag.retrieve_context("show login", n_results=2, with_surrounding=True)
+    assert len(results) == 2
+    assert all(r['surrounding']['imports'] == 'import "fmt"' for r in results), results
+    print("✅ Surrounding context is read from each repository's own root")
+
+
+def test_update_stays_in_its_repo(h):
+    (h.backend / "auth" / "user.go").write_text(go_file("auth", "CheckSession"))
+    h.embedder.texts = []
+    results = h.index(update=True)
+    assert results["backend"]['paths_updated'] == ['auth/user.go'], results["backend"]
+    assert results["frontend-src"]['files_skipped'] == 1, results["frontend-src"]
+    assert h.names("backend", "auth/user.go") == ['CheckSession']
+    assert h.names("frontend-src", "auth/user.go") == ['ShowLogin']
+    assert all('ShowLogin' not in text for text in h.embedder.texts)
+    print("✅ Updating one repository leaves the same path in another alone")
+
+
+def test_rename_and_delete(h):
+    os.rename(h.backend / "auth" / "user.go", h.backend / "auth" / "session.go")
+    h.embedder.texts = []
+    results = h.index(update=True)
+    assert results["backend"]['paths_moved'] == [('auth/user.go', 'auth/session.go')], results["backend"]
+    assert h.embedder.texts == [], "a rename should not re-embed"
+    moved = h.chunks("backend", "auth/session.go")
+    assert [m['name'] for _, m in moved] == ['CheckSession'], moved
+    assert moved[0][0].startswith("backend:auth/session.go:"), moved[0][0]
+    assert h.names("frontend-src", "auth/user.go") == ['ShowLogin']
+    
+    (h.frontend / "auth" / "user.go").unlink()
+    results = h.index(update=True)
+    assert results["frontend-src"]['paths_removed'] == ['auth/user.go'], results["frontend-src"]
+    assert h.names("frontend-src", "auth/user.go") == []
+    assert h.names("backend", "auth/session.go") == ['CheckSession']
+    print("✅ Renames and deletes only touch their own repository")
+
+
+def test_labels(h):
+    assert parse_root("backend=/src/backend") == ("backend", "/src/backend")
+    assert parse_root("/src/backend") == (None, "/src/backend")
+    assert parse_root("./dir=with=equals")[0] is None
+    for bad in ("-x=/src", "a b=/src"):
+        try:
+            parse_root(bad)
+            assert False, f"{bad} should be rejected"
+        except ValueError:
+            pass
+    try:
+        h.indexer.index_roots([("same", str(h.backend)), ("same", str(h.frontend))])
+        assert False, "a label used twice should be rejected"
+    except ValueError:
+        pass
+    print("✅ Repository labels are validated")
+
+
+def main():
+    print("=" * 70)
+    print("MULTI-REPO INDEXING TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="multi_repo_"))
+    h = Harness(workdir)
+    
+    tests = [
+        lambda: test_same_path_in_two_repos(h),
+        lambda: test_repo_filter(h),
+        lambda: test_surrounding_per_repo(h),
+        lambda: test_update_stays_in_its_repo(h),
+        lambda: test_rename_and_delete(h),
+        lambda: test_labels(h),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,151 @@
+#!/usr/bin/env python3
+"""
+Test script for the Ollama embedding backend
+Runs against a fake Ollama server on localhost, so no model is needed
+"""
+
+import json
+import sys
+import threading
+from http.server import BaseHTTPRequestHandler, HTTPServer
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from embedders import OllamaEmbedder, EmbeddingError
+
+
+class FakeOllama(BaseHTTPRequestHandler):
+    """Serves /api/embed and /api/embeddings; can fail requests with a 503 first"""
+    failures_left = 0
+    legacy = False  # pretend to be an old server without /api/embed
+    requests = []
+    
+    def do_POST(self):
+        payload = json.loads(self.rfile.read(int(self.headers['Content-Length'])))
+        FakeOllama.requests.append((self.path, payload))
+        
+        if FakeOllama.failures_left:
+            FakeOllama.failures_left -= 1
+            return self._reply(503, {'error': 'server busy'})
+        if payload['model'] != 'nomic-embed-text':
+            return self._reply(404, {'error': f"model \"{payload['model']}\" not found, try pulling it first"})
+        if self.path == '/api/embed' and not FakeOllama.legacy:
+            return self._reply(200, {'embeddings': [[float(len(t)), 1.0, 0.0] for t in payload['input']]})
+        if self.path == '/api/embeddings':
+            return self._reply(200, {'embedding': [float(len(payload['prompt'])), 1.0, 0.0]})
+        self._reply(404, {'error': '404 page not found'})
+    
+    def _reply(self, status, body):
+        data = json.dumps(body).encode()
+        self.send_response(status)
+        self.send_header('Content-Type', 'application/json')
+        self.send_header('Content-Length', str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+    
+    def log_message(self, *args):
+        pass
+
+
+def start_server():
+    server = HTTPServer(('127.0.0.1', 0), FakeOllama)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    return server, f"http://127.0.0.1:{server.server_address[1]}"
+
+
+def reset(failures=0, legacy=False):
+    FakeOllama.failures_left = failures
+    FakeOllama.legacy = legacy
+    FakeOllama.requests = []
+
+
+def test_batching(url):
+    reset()
+    embedder = OllamaEmbedder(base_url=url, batch_size=2)
+    vectors = embedder.embed(['a', 'bb', 'ccc'])
+    assert [v[0] for v in vectors] == [1.0, 2.0, 3.0]
+    assert [len(p['input']) for _, p in FakeOllama.requests] == [2, 1]
+    assert embedder.dimensions() == 3
+    print("✅ Texts embedded in batches")
+
+
+def test_dimension_probe(url):
+    reset()
+    embedder = OllamaEmbedder(base_url=url)
+    assert embedder.dimensions() == 3
+    assert len(FakeOllama.requests) == 1
+    print("✅ Dimension probed on first use")
+
+
+def test_retry_on_5xx(url):
+    reset(failures=2)
+    embedder = OllamaEmbedder(base_url=url, backoff=0.01)
+    assert len(embedder.embed(['retry me'])) == 1
+    assert len(FakeOllama.requests) == 3
+    print("✅ Transient 5xx retried with backoff")
+
+
+def test_missing_model(url):
+    reset()
+    embedder = OllamaEmbedder(model_name='not-pulled', base_url=url)
+    try:
+        embedder.embed(['x'])
+    except EmbeddingError as e:
+        assert 'ollama pull not-pulled' in str(e), str(e)
+        print("✅ Missing model reported with pull hint")
+        return
+    raise AssertionError("expected EmbeddingError")
+
+
+def test_legacy_endpoint(url):
+    reset(legacy=True)
+    embedder = OllamaEmbedder(base_url=url)
+    assert [v[0] for v in embedder.embed(['a', 'bb'])] == [1.0, 2.0]
+    assert [path for path, _ in FakeOllama.requests] == ['/api/embed', '/api/embeddings', '/api/embeddings']
+    print("✅ Falls back to /api/embeddings on older servers")
+
+
+def test_unreachable():
+    embedder = OllamaEmbedder(base_url='http://127.0.0.1:9', max_retries=1, backoff=0.01, timeout=1)
+    try:
+        embedder.embed(['x'])
+    except EmbeddingError as e:
+        assert 'Cannot reach Ollama' in str(e), str(e)
+        print("✅ Unreachable server reported")
+        return
+    raise AssertionError("expected EmbeddingError")
+
+
+def main():
+    print("=" * 70)
+    print("OLLAMA EMBEDDER TEST")
+    print("=" * 70)
+    
+    server, url = start_server()
+    tests = [
+        lambda: test_batching(url),
+        lambda: test_dimension_probe(url),
+        lambda: test_retry_on_5xx(url),
+        lambda: test_missing_model(url),
+        lambda: test_legacy_endpoint(url),
+        test_unreachable,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    server.shutdown()
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,136 @@
+#!/usr/bin/env python3
+"""
+Test script for partial extraction from files with syntax errors
+A Go file with one broken function must still yield the declarations before it,
+record parse diagnostics, and report the file in the run summary.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+GOOD_SOURCE = '''package auth
+
+func Good() int {
+    return 1
+}
+
+func Broken() int {
+    return 2
+}
+
+func AlsoGood() int {
+    return 3
+}
+
+type User struct {
+    Name string
+}
+'''
+
+BROKEN_SOURCE = GOOD_SOURCE.replace('    return 2\n}', '    if true {\n        return 2\n}')
+
+
+def names(chunks):
+    return [chunk.name for chunk in chunks]
+
+
+def test_go_recovery():
+    chunker = GoChunker()
+    chunks = chunker.extract_chunks(BROKEN_SOURCE, "auth/user.go")
+    good = next(chunk for chunk in chunks if chunk.name == 'Good')
+    assert good.content == 'func Good() int {\n    return 1\n}' and 'parse_error' not in good.metadata, good.metadata
+    
+    assert chunker.diagnostics, "a broken file should report diagnostics"
+    assert all(d['kind'] == 'parse_error' for d in chunker.diagnostics)
+    assert all(d['line'] >= 7 for d in chunker.diagnostics), chunker.diagnostics
+    print("✅ Declarations before a broken function are still extracted")
+
+
+def test_several_unclosed_brackets():
+    # Half-typed calls and index expressions leave several brackets open inside a body
+    for broken in ('    g(h(\n', '    x := m[f(\n', '    {((}\n'):
+        source = GOOD_SOURCE.replace('    return 2\n', broken + '    return 2\n')
+        chunker = GoChunker()
+        chunks = chunker.extract_chunks(source, "auth/user.go")
+        assert 'Good' in names(chunks), (broken, names(chunks))
+        assert chunker.diagnostics, (broken, chunker.diagnostics)
+    print("✅ Two or more unclosed brackets between valid functions are reported, not looped on")
+
+
+def test_valid_file_unchanged():
+    chunker = GoChunker()
+    chunks = chunker.extract_chunks(GOOD_SOURCE, "auth/user.go")
+    assert names(chunks) == ['Good', 'Broken', 'AlsoGood', 'User'], names(chunks)
+    assert chunker.diagnostics == []
+    assert all('parse_error' not in chunk.metadata for chunk in chunks)
+    print("✅ Valid files report no diagnostics")
+
+
+def test_indexer_reports_partial_files(workdir):
+    source = workdir / "src"
+    (source / "auth").mkdir(parents=True)
+    path = source / "auth" / "user.go"
+    path.write_text(BROKEN_SOURCE)
+    
+    rag = make_rag(workdir, "partial")
+    state = StateManager(str(workdir / "state.db"))
+    indexer = ChromeIndexer(rag, state_manager=state)
+    stats = indexer.index_directory(str(source), parallel=False)
+    
+    assert stats['files_processed'] == 1 and stats['files_failed'] == 0, stats
+    assert [p['filepath'] for p in stats['files_partial']] == ['auth/user.go'], stats['files_partial']
+    found = rag.collection.get(where={"filepath": "auth/user.go"})
+    assert {'Good', 'auth'} <= {m['name'] for m in found['metadatas']}, found['metadatas']
+    
+    recorded = state.get_diagnostics(str(source.resolve()))
+    assert list(recorded) == [str(path.resolve())], recorded
+    assert recorded[str(path.resolve())][0]['kind'] == 'parse_error'
+    
+    path.write_text(GOOD_SOURCE)
+    stats = indexer.update_index(str(source), parallel=False)
+    assert stats['files_partial'] == [], stats['files_partial']
+    assert state.get_diagnostics() == {}, "fixing the file should clear its diagnostics"
+    print("✅ Partly parsed files are indexed, reported and recorded until fixed")
+
+
+def main():
+    print("=" * 70)
+    print("PARSE ERROR RECOVERY TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="parse_recovery_"))
+    
+    tests = [
+        test_go_recovery,
+        test_several_unclosed_brackets,
+        test_valid_file_unchanged,
+        lambda: test_indexer_reports_partial_files(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
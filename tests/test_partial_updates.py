@@ -0,0 +1,191 @@
+#!/usr/bin/env python3
+"""
+Test script for retry-safe incremental updates
+A file is stored whole or not at all: its previous chunks are only removed
+once all its new ones are embedded, and a file with a chunk that could not be
+embedded keeps what it had and is retried by the next update. Covers updates
+embedding in line and pipelined, new files, deduplicated indexes and
+file_batches. Uses a small deterministic embedder that rejects marked texts
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from embedders import PartialEmbeddingError
+from helpers import HashEmbedder, make_rag
+from indexer import ChromeIndexer, file_batches
+from utils.state_manager import StateManager
+
+
+class RejectingEmbedder(HashEmbedder):
+    """Hashed-token vectors, rejecting texts that mention 'poison' while reject is on"""
+    
+    def __init__(self):
+        super().__init__(batch_size=64)
+        self.reject = True
+    
+    def embed(self, texts):
+        vectors, failures = [], []
+        for i, text in enumerate(texts):
+            if self.reject and 'poison' in text.lower():
+                vectors.append(None)
+                failures.append((i, "input rejected"))
+                continue
+            vectors.append(self.vector(text))
+        if failures:
+            raise PartialEmbeddingError(vectors, failures)
+        return vectors
+
+
+DEPLOY = '''#!/bin/bash
+
+build() {
+    echo "building the release"
+}
+
+ship() {
+    echo "shipping the release"
+}
+'''
+OTHER = '''#!/bin/bash
+
+clean() {
+    echo "cleaning the workspace"
+}
+'''
+
+
+def make_tree(root: Path):
+    root.mkdir(parents=True)
+    (root / 'deploy.sh').write_text(DEPLOY)
+    (root / 'other.sh').write_text(OTHER)
+
+
+def make_indexer(workdir, name, **options):
+    embedder = RejectingEmbedder()
+    rag = make_rag(workdir, name, embedder=embedder, dedup=options.pop('dedup', 'off'))
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / f"{name}_state.db")), **options)
+    return indexer, rag, embedder
+
+
+def contents(rag, filepath):
+    return sorted(rag.collection.get(where={'filepath': filepath})['documents'])
+
+
+def check_update(workdir, name, **options):
+    """A modified file with a rejected chunk keeps its previous chunks until an update stores it whole"""
+    root = workdir / name
+    make_tree(root)
+    indexer, rag, embedder = make_indexer(workdir, name, **options)
+    indexer.index_directory(str(root), parallel=False)
+    before = contents(rag, 'deploy.sh')
+    assert len(before) == 2 and 'shipping' in ' '.join(before), before
+    
+    (root / 'deploy.sh').write_text(DEPLOY.replace('building', 'compiling').replace('shipping', 'poison shipping'))
+    (root / 'other.sh').write_text(OTHER.replace('cleaning', 'wiping'))
+    stats = indexer.update_index(str(root), report=False)
+    assert stats['files_to_retry'] == ['deploy.sh'], stats['files_to_retry']
+    assert contents(rag, 'deploy.sh') == before, "the failed file is left as it was"
+    assert 'wiping' in ' '.join(contents(rag, 'other.sh')), "the other files are updated"
+    
+    # Nothing changed since, but the failed file is still due
+    stats = indexer.update_index(str(root), report=False)
+    assert stats['paths_updated'] == ['deploy.sh'] and stats['files_to_retry'] == ['deploy.sh'], stats
+    assert contents(rag, 'deploy.sh') == before
+    
+    embedder.reject = False
+    stats = indexer.update_index(str(root), report=False)
+    assert stats['paths_updated'] == ['deploy.sh'] and stats['files_to_retry'] == [], stats
+    after = ' '.join(contents(rag, 'deploy.sh'))
+    assert 'compiling' in after and 'poison shipping' in after and 'building' not in after, after
+    assert len(contents(rag, 'deploy.sh')) == 2
+    assert indexer.update_index(str(root), report=False)['paths_updated'] == [], "nothing left to retry"
+    return rag
+
+
+def test_update(workdir):
+    check_update(workdir, "inline")
+    print("✅ A file that does not embed whole keeps its previous chunks and is retried")
+
+
+def test_pipelined(workdir):
+    check_update(workdir, "pipelined", embed_workers=2)
+    print("✅ So does one embedded ahead by the pipeline")
+
+
+def test_new_file(workdir):
+    root = workdir / "new"
+    make_tree(root)
+    (root / 'release.sh').write_text(DEPLOY.replace('shipping', 'poison shipping'))
+    indexer, rag, embedder = make_indexer(workdir, "new")
+    stats = indexer.index_directory(str(root), parallel=False)
+    assert stats['files_to_retry'] == ['release.sh'], stats['files_to_retry']
+    assert contents(rag, 'release.sh') == [], "none of a new file's chunks are stored"
+    assert len(contents(rag, 'deploy.sh')) == 2
+    
+    embedder.reject = False
+    stats = indexer.update_index(str(root), report=False)
+    assert stats['paths_added'] == ['release.sh'] and len(contents(rag, 'release.sh')) == 2, stats
+    print("✅ A new file is stored whole or not at all")
+
+
+def test_dedup(workdir):
+    rag = check_update(workdir, "dedup", dedup='exact')
+    # build() is unchanged, and only stored by the version the update replaces
+    root = workdir / "dedup"
+    (root / 'deploy.sh').write_text((root / 'deploy.sh').read_text().replace('poison shipping', 'shipping'))
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "dedup_state.db")))
+    stats = indexer.update_index(str(root), report=False)
+    assert stats['paths_updated'] == ['deploy.sh'] and stats['files_to_retry'] == [], stats
+    stored = ' '.join(contents(rag, 'deploy.sh'))
+    assert 'compiling' in stored and 'poison' not in stored, stored
+    assert len(rag.collection.get()['ids']) == 3
+    print("✅ A deduplicated index embeds the bodies only the replaced version stored")
+
+
+def test_file_batches():
+    chunks = [CodeChunk(type='function', name=f'f{n}', content='', filepath=filepath, language='go',
+                        line_start=n, line_end=n)
+              for n, filepath in enumerate(['a.go', 'b.go', 'a.go', 'c.go', 'c.go', 'c.go', 'd.go'])]
+    batches = [[chunk.name for chunk in batch] for batch in file_batches(chunks, 2)]
+    assert batches == [['f0', 'f2'], ['f1', 'f3', 'f4', 'f5'], ['f6']], batches
+    assert list(file_batches([], 2)) == []
+    print("✅ Batches hold whole files")
+
+
+def main():
+    print("=" * 70)
+    print("PARTIAL UPDATES TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_partial_updates_"))
+    tests = [
+        lambda: test_update(workdir),
+        lambda: test_pipelined(workdir),
+        lambda: test_new_file(workdir),
+        lambda: test_dedup(workdir),
+        test_file_batches,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,155 @@
+#!/usr/bin/env python3
+"""
+Test script for the path depth and recency ranking priors
+Both are off by default and leave the ranking unchanged; with a weight the
+path depth penalty ranks internal/ code above the same match in third_party/,
+and the recency boost ranks recently modified files above stale ones.
+Uses a small deterministic embedder
+"""
+
+import os
+import shutil
+import subprocess
+import sys
+import tempfile
+import time
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.path_priors import depth_factor, git_modified_times, path_depth, recency_factor
+from utils.state_manager import StateManager
+
+DAY = 86400.0
+
+
+def chunk(filepath, content, modified=None):
+    return CodeChunk(type='function', name='ValidateToken', content=content, filepath=filepath,
+                     language='go', line_start=1, line_end=3, modified=modified)
+
+
+def paths(results):
+    return [r['metadata']['filepath'] for r in results]
+
+
+def test_factors():
+    assert path_depth('main.go') == 0 and path_depth('internal/auth/token.go') == 2
+    assert path_depth('./third_party/lib/x.go') == 2
+    assert depth_factor('internal/auth/token.go', 0.0) == 1.0
+    assert abs(depth_factor('internal/auth/token.go', 0.5) - 0.5) < 1e-9
+    
+    now = time.time()
+    assert recency_factor(now, 1.0, 90.0, now) == 2.0
+    assert abs(recency_factor(now - 90 * DAY, 1.0, 90.0, now) - 1.5) < 1e-9
+    assert recency_factor(None, 1.0, 90.0, now) == 1.0, "chunks without a time are not boosted"
+    assert recency_factor(now - 90 * DAY, 0.0, 90.0, now) == 1.0
+    print("✅ Depth and recency factors are 1.0 at weight 0 and scale with depth and age")
+
+
+def test_depth_penalty(rag):
+    query = "validate session token"
+    # The vendored copy matches the query a little better
+    rag.add_chunks_batch([
+        chunk('internal/auth/token.go', 'func ValidateToken(token string) error { check token }'),
+        chunk('third_party/github.com/lib/auth/session/token.go',
+              'func ValidateToken(token string) error { validate session token }'),
+    ])
+    rag._build_keyword_index()
+    plain = paths(rag.retrieve_context(query, n_results=2))
+    assert plain[0].startswith('third_party/'), plain
+    assert paths(rag.retrieve_context(query, n_results=2, depth_penalty=0.0)) == plain, "0 leaves the ranking alone"
+    
+    penalized = rag.retrieve_context(query, n_results=2, depth_penalty=0.2, explain=True)
+    assert paths(penalized)[0] == 'internal/auth/token.go', paths(penalized)
+    assert abs(penalized[0]['explain']['fused']['boost'] - 1 / 1.4) < 1e-9, penalized[0]['explain']['fused']
+    
+    try:
+        rag.retrieve_context(query, depth_penalty=-1.0)
+        assert False, "a negative penalty should be refused"
+    except ValueError as e:
+        assert 'depth_penalty' in str(e), e
+    print("✅ The depth penalty ranks shallow code above an equally good vendored match")
+
+
+def test_recency(rag):
+    now = time.time()
+    query = "rotate signing keys"
+    rag.add_chunks_batch([
+        chunk('keys/rotate.go', 'func RotateKeys() { rotate signing keys now }', modified=now - 400 * DAY),
+        chunk('keys/legacy.go', 'func RotateLegacyKeys() { rotate keys }', modified=now - DAY),
+    ])
+    rag._build_keyword_index()
+    plain = paths(rag.retrieve_context(query, n_results=2))
+    assert plain[0] == 'keys/rotate.go', plain
+    boosted = paths(rag.retrieve_context(query, n_results=2, recency_weight=1.0))
+    assert boosted[0] == 'keys/legacy.go', boosted
+    print("✅ The recency boost ranks recently modified files above stale ones")
+
+
+def test_indexed_times(workdir):
+    root = workdir / 'repo'
+    (root / 'auth').mkdir(parents=True)
+    (root / 'auth' / 'token.go').write_text('package auth\n\nfunc Validate() error {\n    return nil\n}\n')
+    mtime = time.time() - 30 * DAY
+    os.utime(root / 'auth' / 'token.go', (mtime, mtime))
+    
+    rag = make_rag(workdir, "times")
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / 'times_state.db'))).index_directory(
+        str(root), parallel=False)
+    modified = {r['metadata']['modified'] for r in rag._format_get_results(rag.collection.get())}
+    assert modified == {mtime}, modified
+    
+    # The last commit, by path relative to the directory asked about
+    git = ['git', '-c', 'user.name=test', '-c', 'user.email=test@example.com']
+    try:
+        subprocess.run(['git', 'init', '-q'], cwd=root, check=True)
+    except (OSError, subprocess.CalledProcessError):
+        print("✅ Indexed chunks record their file's mtime (git not available)")
+        return
+    subprocess.run(git + ['add', '.'], cwd=root, check=True)
+    env = dict(os.environ, GIT_COMMITTER_DATE='2024-01-02T03:04:05Z')
+    subprocess.run(git + ['commit', '-q', '-m', 'initial'], cwd=root, check=True, env=env)
+    committed = 1704164645.0
+    assert git_modified_times(root) == {'auth/token.go': committed}, git_modified_times(root)
+    assert git_modified_times(root / 'auth') == {'token.go': committed}, git_modified_times(root / 'auth')
+    assert git_modified_times(workdir) == {}, "not a work tree"
+    print("✅ Indexed chunks record their file's mtime, or its last commit from git")
+
+
+def main():
+    print("=" * 70)
+    print("PATH PRIORS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_priors_"))
+    
+    def new_rag(name):
+        return make_rag(workdir, name)
+    
+    tests = [
+        test_factors,
+        lambda: test_depth_penalty(new_rag('depth')),
+        lambda: test_recency(new_rag('recency')),
+        lambda: test_indexed_times(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
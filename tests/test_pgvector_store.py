@@ -0,0 +1,149 @@
+#!/usr/bin/env python3
+"""
+Test script for the PostgreSQL (pgvector) vector store
+Filter translation runs everywhere; the database tests run against the server
+in PGVECTOR_TEST_DSN (e.g. the pgvector/pgvector Docker image) and are skipped
+without one. Uses a small deterministic embedder so no embedding model is needed
+"""
+
+import os
+import sys
+import uuid
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import HashEmbedder
+from rag import ChromeRAGSystem
+from stores import PgvectorStore, StoreError
+from stores.pgvector_store import to_pg_filter
+
+DSN = os.environ.get('PGVECTOR_TEST_DSN')
+
+
+def sample_chunks():
+    return [
+        CodeChunk(type='function', name='ParseURL', content='func ParseURL(raw string) (*URL, error) { parse url }',
+                  filepath='net/url.go', language='go', line_start=10, line_end=40),
+        CodeChunk(type='method', name='String', content='func (u *URL) String() string { format url }',
+                  filepath='net/url.go', language='go', line_start=42, line_end=60, parent_class='URL'),
+        CodeChunk(type='function', name='parse_url', content='def parse_url(raw):\n    return urlsplit(raw)',
+                  filepath='tools/url.py', language='python', line_start=1, line_end=2),
+        CodeChunk(type='class', name='URLParser', content='class URLParser:\n    parse url',
+                  filepath='tools/url.py', language='python', line_start=4, line_end=5),
+    ]
+
+
+def new_store(index='hnsw'):
+    """A store on a collection no other run uses"""
+    return PgvectorStore(DSN, f"test_{uuid.uuid4().hex[:12]}", index=index)
+
+
+def build_rag(index='hnsw'):
+    store = new_store(index)
+    rag = ChromeRAGSystem(collection_name=store.name, embedder=HashEmbedder(), store=store)
+    rag.add_chunks_batch(sample_chunks())
+    rag._build_keyword_index()
+    return rag
+
+
+def test_filter_translation():
+    assert to_pg_filter({}) == ('TRUE', [])
+    assert to_pg_filter({'language': 'go'}) == ('language = %s', ['go'])
+    assert to_pg_filter({'line_start': {'$gte': 10}}) == \
+        ('(metadata -> %s::text) >= %s::jsonb', ['line_start', '10'])
+    assert to_pg_filter({'type': {'$in': ['function', 'method']}}) == \
+        ('type = ANY(%s)', [['function', 'method']])
+    assert to_pg_filter({'part_count': {'$in': [1, 2]}}) == \
+        ('(metadata -> %s::text) = ANY(%s::jsonb[])', ['part_count', ['1', '2']])
+    assert to_pg_filter({'kind': {'$nin': ['test']}}) == \
+        ('(kind IS NULL OR NOT kind = ANY(%s))', [['test']])
+    assert to_pg_filter({'$and': [{'language': 'go'}, {'kind': {'$ne': 'test'}}]}) == \
+        ('(language = %s AND kind IS DISTINCT FROM %s)', ['go', 'test'])
+    assert to_pg_filter({'$or': [{'name': 'Run'}, {'parent_class': 'URL'}]}) == \
+        ('(name = %s OR (metadata -> %s::text) = %s::jsonb)', ['Run', 'parent_class', '"URL"'])
+    assert to_pg_filter({'type': {'$in': []}}) == ('FALSE', [])
+    try:
+        to_pg_filter({'name': {'$regex': 'x'}})
+        assert False, "unsupported operator accepted"
+    except StoreError:
+        pass
+    print("✅ Filters translated to SQL: columns for indexed fields, JSONB for the rest")
+
+
+def test_filtered_search():
+    rag = build_rag()
+    try:
+        results = rag.retrieve_context("parse url", n_results=5, languages=['python'], lexical_weight=0.0)
+        assert results and all(r['metadata']['language'] == 'python' for r in results), results
+        methods = rag.retrieve_context("format url", n_results=5, kinds=['method'], lexical_weight=0.0)
+        assert [r['metadata']['name'] for r in methods] == ['String'], methods
+        assert rag.collection.metadata['embedding_dimensions'] == 64
+    finally:
+        rag.collection.reset()
+    print("✅ ANN search with metadata filters in the WHERE clause")
+
+
+def test_get_upsert_update_delete():
+    store = new_store(index='ivfflat')
+    try:
+        store.add(ids=['a', 'b'], documents=['one', 'two'], metadatas=[{'filepath': 'x.go'}, {'filepath': 'y.go'}],
+                  embeddings=[[1.0, 0.0], [0.0, 1.0]])
+        store.add(ids=['a'], documents=['one again'], metadatas=[{'filepath': 'x.go'}], embeddings=[[1.0, 0.0]])
+        assert store.count() == 2
+        assert store.get(ids=['a'])['documents'] == ['one again']
+        
+        store.update(ids=['b'], metadatas=[{'filepath': 'z.go'}])
+        fetched = store.get(where={'filepath': 'z.go'}, include=['metadatas', 'embeddings'])
+        assert fetched['ids'] == ['b'] and fetched['embeddings'] == [[0.0, 1.0]], fetched
+        
+        hits = store.query([[0.9, 0.1]], n_results=1)
+        assert hits['ids'] == [['a']] and hits['distances'][0][0] < 0.1, hits
+        
+        store.delete(['a'])
+        assert store.get()['ids'] == ['b']
+        try:
+            store.add(ids=['c'], documents=['three'], metadatas=[{}], embeddings=[[1.0, 0.0, 0.0]])
+            assert False, "dimension mismatch accepted"
+        except StoreError:
+            pass
+        
+        sibling = store.open_collection(store.name + '_signatures')
+        sibling.add(ids=['s'], documents=['sig'], metadatas=[{}], embeddings=[[1.0, 1.0]])
+        assert sibling.count() == 1 and store.count() == 1
+        sibling.reset()
+    finally:
+        store.reset()
+    assert store.count() == 0 and store.metadata == {}
+    print("✅ Upserts, metadata merges, deletes and sibling collections")
+
+
+def main():
+    print("=" * 70)
+    print("PGVECTOR STORE TEST")
+    print("=" * 70)
+    
+    tests = [test_filter_translation]
+    if DSN:
+        tests += [test_filtered_search, test_get_upsert_update_delete]
+    else:
+        print("⚠️  PGVECTOR_TEST_DSN not set: skipping the database tests")
+    
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,291 @@
+#!/usr/bin/env python3
+"""
+Test script for the PHP chunker
+Sources are parsed by tree-sitter-php
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import PhpChunker
+from helpers import make_chroma_rag
+
+
+CONTROLLER = '''<?php
+declare(strict_types=1);
+
+namespace App\\Http\\Controllers;
+
+use App\\Models\\Invoice;
+use function App\\Support\\money;
+
+/**
+ * Invoices of the current account
+ *
+ * @deprecated Use StatementController instead
+ */
+#[Route('/invoices')]
+final class InvoiceController extends Controller implements Listable, \\Countable
+{
+    use Paginates, Caches {
+        Caches::get insteadof Paginates;
+    }
+    
+    public const PAGE_SIZE = 25, MAX_PAGES = 40;
+    
+    protected static ?Invoice $latest = null;
+    private readonly array $rates, $totals;
+    var $legacy;
+    
+    public function __construct(private readonly InvoiceRepository $invoices, protected ?string $currency = 'EUR')
+    {
+        $this->rates = ['standard' => 0.2];
+    }
+    
+    /** Lists invoices; braces in strings: "{" '}' */
+    #[Get('/')]
+    public function index(Request $request, int ...$ids): array
+    {
+        $query = <<<SQL
+        SELECT * FROM invoices WHERE id IN ({$ids}) }
+        SQL;
+        return array_map(fn ($row) => money($row['total']), $this->invoices->run($query));
+    }
+    
+    public static function &latest(): ?Invoice
+    {
+        return self::$latest;
+    }
+    
+    abstract protected function render(array $rows);
+    
+    public function count(): int { return 0; } // {
+}
+
+interface Listable extends \\Traversable, Sized
+{
+    public function items(): iterable;
+}
+
+trait Paginates
+{
+    protected int $page = 1;
+    
+    public function page(): int
+    {
+        return $this->page;
+    }
+}
+
+enum Status: string
+{
+    case Paid = 'paid';
+    case Open = 'open';
+    
+    public function label(): string
+    {
+        return match ($this) {
+            Status::Paid => 'Paid',
+            Status::Open => 'Open',
+        };
+    }
+}
+
+function format_total(float $total, string $currency = 'EUR'): string
+{
+    return sprintf('%.2f %s', $total, $currency);
+}
+
+if (!function_exists('polyfill')) {
+    function polyfill() {}
+}
+'''
+
+TEMPLATE = '''<!DOCTYPE html>
+<html>
+<head><title><?= htmlspecialchars($title) ?></title></head>
+<body>
+<?php if ($user): ?>
+  <p>function notCode() { return 1; }</p>
+<?php endif; ?>
+<?php
+/** Greets the visitor */
+function greet(string $name): string
+{
+    return "Hello $name ?>";
+}
+?>
+<script>
+function alsoNotCode() {}
+</script>
+<?php
+class Widget
+{
+    public function render() { ?>
+        <div class="widget">}</div>
+    <?php }
+}
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_types():
+    """Classes, interfaces, traits and enums with their namespace, docs and relations"""
+    chunker = PhpChunker()
+    chunks = chunker.extract_chunks(CONTROLLER, 'app/Http/Controllers/InvoiceController.php')
+    assert not chunker.diagnostics, chunker.diagnostics
+    
+    controller = by_name(chunks, 'InvoiceController', 'class')
+    assert controller.namespace == 'App\\Http\\Controllers' and controller.metadata['namespace'] == controller.namespace
+    assert controller.signature == 'final class InvoiceController extends Controller implements Listable, \\Countable'
+    assert controller.metadata['extends'] == 'Controller'
+    assert controller.metadata['implements'] == ['Listable', 'Countable']
+    assert controller.metadata['modifiers'] == ['final'] and controller.metadata['attributes'] == ["#[Route('/invoices')]"]
+    assert controller.metadata['traits'] == ['Paginates', 'Caches']
+    assert controller.doc == 'Invoices of the current account\n\n@deprecated Use StatementController instead', controller.doc
+    assert controller.metadata['deprecated'] == 'Use StatementController instead'
+    assert controller.metadata['methods'] == ['__construct', 'index', 'latest', 'render', 'count']
+    assert controller.metadata['properties'] == ['latest', 'rates', 'totals', 'legacy', 'invoices', 'currency']
+    assert controller.metadata['constants'] == ['PAGE_SIZE', 'MAX_PAGES']
+    assert (controller.line_start, controller.line_end) == (14, 50), "braces in strings, heredocs and comments close nothing"
+    
+    listable = by_name(chunks, 'Listable', 'interface')
+    assert listable.metadata['extends'] == ['Traversable', 'Sized'] and listable.metadata['methods'] == ['items']
+    
+    trait = by_name(chunks, 'Paginates', 'trait')
+    assert trait.metadata['properties'] == ['page'] and trait.metadata['methods'] == ['page']
+    
+    status = by_name(chunks, 'Status', 'enum')
+    assert status.signature == 'enum Status: string' and status.metadata['backed_by'] == 'string'
+    assert status.metadata['cases'] == ['Paid', 'Open'] and status.metadata['methods'] == ['label']
+    print("✅ Classes, interfaces, traits and enums extracted with namespace and relations")
+
+
+def test_members():
+    """Methods and properties record their visibility, modifiers and types"""
+    chunks = PhpChunker().extract_chunks(CONTROLLER, 'app/Http/Controllers/InvoiceController.php')
+    
+    constructor = by_name(chunks, '__construct')
+    assert constructor.type == 'method' and constructor.parent == 'InvoiceController'
+    assert constructor.qualified_name == 'InvoiceController.__construct' and constructor.metadata['constructor']
+    assert constructor.metadata['params'] == [
+        {'name': 'invoices', 'type': 'InvoiceRepository', 'promoted': 'private', 'readonly': True},
+        {'name': 'currency', 'type': '?string', 'default': "'EUR'", 'promoted': 'protected'},
+    ], constructor.metadata['params']
+    
+    index = by_name(chunks, 'index')
+    assert index.signature == 'public function index(Request $request, int ...$ids): array'
+    assert index.metadata['visibility'] == 'public' and index.metadata['returns'] == 'array'
+    assert index.metadata['attributes'] == ["#[Get('/')]"]
+    assert index.metadata['params'][1] == {'name': 'ids', 'type': 'int', 'variadic': True}
+    assert index.doc == 'Lists invoices; braces in strings: "{" \'}\''
+    assert (index.line_start, index.line_end) == (33, 40)
+    
+    latest = by_name(chunks, 'latest', 'method')
+    assert latest.metadata['static'] and latest.metadata['by_reference'] and latest.metadata['returns'] == '?Invoice'
+    render = by_name(chunks, 'render')
+    assert render.metadata['visibility'] == 'protected' and render.metadata['abstract']
+    assert render.metadata['modifiers'] == ['abstract']
+    assert by_name(chunks, 'items').metadata['abstract'], "interface methods have no body"
+    
+    latest = by_name(chunks, 'latest', 'property')
+    assert latest.signature == 'protected static ?Invoice $latest' and latest.metadata['static']
+    assert latest.metadata['visibility'] == 'protected' and latest.metadata['property_type'] == '?Invoice'
+    rates = by_name(chunks, 'rates')
+    assert rates.metadata['names'] == ['rates', 'totals'] and rates.metadata['modifiers'] == ['readonly']
+    assert by_name(chunks, 'legacy').metadata['visibility'] == 'public', "var properties are public"
+    assert by_name(chunks, 'PAGE_SIZE').metadata['names'] == ['PAGE_SIZE', 'MAX_PAGES']
+    
+    function = by_name(chunks, 'format_total')
+    assert function.type == 'function' and function.parent is None and 'visibility' not in function.metadata
+    assert function.namespace == 'App\\Http\\Controllers'
+    assert not any(c.name == 'polyfill' for c in chunks), "conditional declarations are statements"
+    print("✅ Members extracted with visibility, modifiers and parameters")
+
+
+def test_templates():
+    """Only the PHP regions of a template are parsed"""
+    chunker = PhpChunker()
+    chunks = chunker.extract_chunks(TEMPLATE, 'views/page.php')
+    assert [c.name for c in chunks] == ['greet', 'Widget', 'render'], [c.name for c in chunks]
+    assert not chunker.diagnostics, chunker.diagnostics
+    
+    greet = by_name(chunks, 'greet')
+    assert greet.doc == 'Greets the visitor' and (greet.line_start, greet.line_end) == (10, 13)
+    assert 'namespace' not in greet.metadata and greet.namespace is None
+    widget = by_name(chunks, 'Widget')
+    assert (widget.line_start, widget.line_end) == (19, 24), "HTML inside a method body is skipped"
+    
+    chunks = chunker.extract_chunks("<?php\nclass Open {\n  function run() {\n    work();\n  }\n", 'open.php')
+    assert [c.name for c in chunks] == ['Open', 'run'], [c.name for c in chunks]
+    assert chunker.diagnostics == [{'kind': 'parse_error', 'line': 2, 'message': "unclosed '{'"}]
+    print("✅ HTML outside <?php ?> skipped and unbalanced files reported")
+
+
+def test_sample_file():
+    """The comprehensive sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'Complex.php'
+    chunker = PhpChunker()
+    chunks = chunker.extract_chunks(sample.read_text(), 'comprehensive/Complex.php')
+    assert not chunker.diagnostics, chunker.diagnostics
+    
+    admin = by_name(chunks, 'AdminUser', 'class')
+    assert admin.metadata['extends'] == 'User' and admin.metadata['implements'] == ['Authenticator']
+    assert admin.metadata['traits'] == ['Loggable'] and admin.namespace == 'Authentication'
+    assert by_name(chunks, 'validatePassword').metadata['visibility'] == 'private'
+    assert by_name(chunks, 'getInstance').metadata['static']
+    assert by_name(chunks, 'instance').metadata['property_type'] == '?SessionManager'
+    assert by_name(chunks, 'User', 'class').metadata['modifiers'] == ['abstract']
+    assert by_name(chunks, 'StatusCode', 'enum').metadata['cases'] == ['SUCCESS', 'UNAUTHORIZED', 'FORBIDDEN',
+                                                                        'NOT_FOUND']
+    assert by_name(chunks, 'authenticateAndCreateSession').type == 'function'
+    print("✅ Sample file parsed")
+
+
+def test_search(workdir):
+    """PHP traits and enums are types, properties are variables to the kind filter"""
+    rag = make_chroma_rag(workdir / "db", "test_php")
+    rag.add_chunks_batch(PhpChunker().extract_chunks(CONTROLLER, 'app/Http/Controllers/InvoiceController.php'))
+    rag._build_keyword_index()
+    
+    results = rag.retrieve_context("invoice page status", n_results=40, filter_expr="kind=type")
+    names = sorted(r['metadata']['name'] for r in results)
+    assert names == ['InvoiceController', 'Paginates', 'Status'], names
+    results = rag.retrieve_context("invoice rates", n_results=40, filter_expr="kind=var")
+    assert {'rates', 'legacy', 'page'} <= {r['metadata']['name'] for r in results}
+    print("✅ PHP symbols found by kind")
+
+
+def main():
+    print("=" * 70)
+    print("PHP CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_php_"))
+    
+    tests = [test_types, test_members, test_templates, test_sample_file, lambda: test_search(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,279 @@
+#!/usr/bin/env python3
+"""
+Test script for the protobuf chunker
+Sources are parsed by tree-sitter-proto
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import ProtobufChunker
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+
+SESSION = '''// Session service contract.
+syntax = "proto3";
+
+package auth.v1;
+
+import "google/api/annotations.proto";
+import public "auth/v1/user.proto";
+
+option go_package = "example.com/app/gen/auth/v1;authv1";
+
+// A signed-in session of a user.
+message Session {
+  // Opaque session id
+  string id = 1;
+  string user_id = 2 [json_name = "userId"];
+  repeated string scopes = 3; // granted OAuth scopes
+  map<string, string> labels = 4;
+  google.protobuf.Timestamp expires_at = 5 [deprecated = true];
+  
+  oneof credential {
+    string password_hash = 6;
+    bytes token = 7;
+  }
+  
+  // Where the session was opened
+  message Origin {
+    string ip = 1;
+    Kind kind = 2;
+    
+    enum Kind {
+      KIND_UNSPECIFIED = 0;
+      KIND_WEB = 1;
+      KIND_MOBILE = 2 [deprecated = true];
+    }
+  }
+  
+  reserved 8, 10 to 12, "legacy";
+}
+
+/* Lifecycle of a session. */
+enum SessionState {
+  option allow_alias = true;
+  SESSION_STATE_UNSPECIFIED = 0;
+  SESSION_STATE_ACTIVE = 1;
+  SESSION_STATE_LIVE = 1;
+  SESSION_STATE_EXPIRED = -1;
+}
+
+// Manages sign-in sessions.
+service SessionService {
+  // Creates a session for a user's credentials.
+  rpc CreateSession(CreateSessionRequest) returns (Session) {
+    option (google.api.http) = {
+      post: "/v1/sessions"
+      body: "*"
+    };
+  }
+  
+  // Streams session events as they happen.
+  rpc WatchSessions(stream WatchRequest) returns (stream SessionEvent);
+  
+  // Ends a session.
+  rpc DeleteSession(DeleteSessionRequest) returns (google.protobuf.Empty) {
+    option deprecated = true;
+    option (google.api.http) = {
+      delete: "/v1/{name=sessions/*}"
+      additional_bindings { post: "/v1/{name=sessions/*}:delete" }
+    };
+  }
+}
+
+message CreateSessionRequest {
+  string user_id = 1;
+  string password = 2;
+}
+
+extend google.protobuf.MessageOptions {
+  optional string table_name = 50001;
+}
+'''
+
+LEGACY = '''syntax = "proto2";
+package legacy;
+
+message SearchResponse {
+  repeated group Result = 1 {
+    required string url = 2;
+    optional string title = 3;
+  }
+  extensions 100 to max;
+  optional int32 count = 4 [default = 10];
+}
+'''
+
+
+def by_name(chunks, qualified_name):
+    return next(c for c in chunks if c.qualified_name == qualified_name)
+
+
+def test_messages():
+    """Fields with their types, numbers, labels, oneofs and nested declarations"""
+    chunks = ProtobufChunker().extract_chunks(SESSION, 'proto/auth/v1/session.proto')
+    
+    session = by_name(chunks, 'Session')
+    assert session.type == 'message' and session.signature == 'message Session'
+    assert session.doc == 'A signed-in session of a user.', session.doc
+    fields = session.metadata['fields']
+    assert [(f['name'], f['type'], f['number']) for f in fields[:3]] == [
+        ('id', 'string', 1), ('user_id', 'string', 2), ('scopes', 'string', 3)], fields
+    assert fields[0]['doc'] == 'Opaque session id' and fields[2]['doc'] == 'granted OAuth scopes'
+    assert fields[1]['options'] == {'json_name': 'userId'} and fields[2]['label'] == 'repeated'
+    assert fields[3]['type'] == 'map<string, string>' and fields[3]['map'] == {'key': 'string', 'value': 'string'}
+    assert fields[4]['type'] == 'google.protobuf.Timestamp' and fields[4]['deprecated']
+    assert session.metadata['oneofs'] == [{'name': 'credential', 'fields': ['password_hash', 'token']}]
+    assert fields[6]['oneof'] == 'credential'
+    assert session.metadata['reserved'] == ['8', '10 to 12', 'legacy']
+    assert session.metadata['nested'] == ['Origin']
+    
+    origin = by_name(chunks, 'Session.Origin')
+    assert origin.doc == 'Where the session was opened' and origin.parent_class == 'Session'
+    assert origin.metadata['nested'] == ['Kind']
+    assert by_name(chunks, 'Session.Origin.Kind').type == 'enum'
+    
+    extend = next(c for c in chunks if c.type == 'extend')
+    assert extend.metadata['extendee'] == 'google.protobuf.MessageOptions'
+    assert extend.metadata['fields'] == [{'name': 'table_name', 'label': 'optional', 'type': 'string', 'number': 50001}]
+    print("✅ Messages extracted with their fields")
+
+
+def test_enums():
+    """Enum values, negative numbers and aliases"""
+    chunks = ProtobufChunker().extract_chunks(SESSION, 'proto/auth/v1/session.proto')
+    
+    state = by_name(chunks, 'SessionState')
+    assert state.doc == 'Lifecycle of a session.'
+    assert [(v['name'], v['number']) for v in state.metadata['values']] == [
+        ('SESSION_STATE_UNSPECIFIED', 0), ('SESSION_STATE_ACTIVE', 1), ('SESSION_STATE_LIVE', 1),
+        ('SESSION_STATE_EXPIRED', -1)], state.metadata['values']
+    assert state.metadata['options'] == {'allow_alias': 'true'}
+    kind = by_name(chunks, 'Session.Origin.Kind')
+    assert kind.metadata['values'][2] == {'name': 'KIND_MOBILE', 'number': 2, 'options': {'deprecated': 'true'},
+                                          'deprecated': True}, kind.metadata['values']
+    print("✅ Enum values extracted")
+
+
+def test_services():
+    """Every rpc is a chunk of its own, with its request, response and HTTP binding"""
+    chunks = ProtobufChunker().extract_chunks(SESSION, 'proto/auth/v1/session.proto')
+    
+    service = by_name(chunks, 'SessionService')
+    assert service.type == 'service' and service.doc == 'Manages sign-in sessions.'
+    assert service.metadata['methods'] == ['CreateSession', 'WatchSessions', 'DeleteSession']
+    
+    create = by_name(chunks, 'SessionService.CreateSession')
+    assert create.type == 'rpc' and create.parent_class == 'SessionService'
+    assert create.signature == 'rpc CreateSession(CreateSessionRequest) returns (Session)', create.signature
+    assert create.doc == "Creates a session for a user's credentials."
+    assert create.metadata['request'] == 'CreateSessionRequest' and create.metadata['response'] == 'Session'
+    assert not create.metadata['client_streaming'] and not create.metadata['server_streaming']
+    assert create.metadata['http'] == {'method': 'POST', 'path': '/v1/sessions', 'body': '*'}, create.metadata
+    assert create.content.endswith('}') and create.line_end == create.line_start + 5
+    
+    watch = by_name(chunks, 'SessionService.WatchSessions')
+    assert watch.metadata['client_streaming'] and watch.metadata['server_streaming']
+    assert watch.signature == 'rpc WatchSessions(stream WatchRequest) returns (stream SessionEvent)'
+    
+    delete = by_name(chunks, 'SessionService.DeleteSession')
+    assert delete.metadata['deprecated'] and delete.metadata['response'] == 'google.protobuf.Empty'
+    assert delete.metadata['http'] == {
+        'method': 'DELETE', 'path': '/v1/{name=sessions/*}',
+        'additional_bindings': [{'method': 'POST', 'path': '/v1/{name=sessions/*}:delete'}],
+    }, delete.metadata['http']
+    print("✅ Services and rpcs extracted")
+
+
+def test_file_metadata():
+    """Package is the namespace, syntax and go_package are on every chunk; the file comment is no doc"""
+    chunker = ProtobufChunker()
+    chunks = chunker.extract_chunks(SESSION, 'proto/auth/v1/session.proto')
+    
+    assert all(c.namespace == 'auth.v1' for c in chunks)
+    assert all(c.metadata['go_package'] == 'example.com/app/gen/auth/v1;authv1' for c in chunks)
+    assert all(c.metadata['syntax'] == 'proto3' and c.metadata['package'] == 'auth.v1' for c in chunks)
+    assert chunker.diagnostics == []
+    
+    legacy = chunker.extract_chunks(LEGACY, 'legacy.proto')
+    response = by_name(legacy, 'SearchResponse')
+    assert response.metadata['syntax'] == 'proto2' and 'go_package' not in response.metadata
+    assert response.metadata['fields'][0] == {'name': 'result', 'label': 'repeated', 'type': 'Result', 'number': 1}
+    assert response.metadata['extensions'] == ['100 to max']
+    assert response.metadata['fields'][1]['options'] == {'default': '10'}
+    result = by_name(legacy, 'SearchResponse.Result')
+    assert result.metadata['group'] and [f['name'] for f in result.metadata['fields']] == ['url', 'title']
+    
+    chunker.extract_chunks('message Broken {\n  string name = 1;\n  string "oops = 2;\n}\n', 'broken.proto')
+    assert chunker.diagnostics and chunker.diagnostics[0]['line'] == 3, chunker.diagnostics
+    print("✅ File metadata recorded, problems reported")
+
+
+def test_sample_file():
+    """The schema sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'adaptive_test' / 'api.proto'
+    chunks = ProtobufChunker().extract_chunks(sample.read_text(), 'adaptive_test/api.proto')
+    
+    assert by_name(chunks, 'User.Address').metadata['fields'][1] == {'name': 'city', 'type': 'string', 'number': 2}
+    assert by_name(chunks, 'Post.Status').metadata['values'][-1] == {'name': 'ARCHIVED', 'number': 2}
+    assert by_name(chunks, 'UserService').metadata['methods'] == [
+        'GetUser', 'CreateUser', 'UpdateUser', 'DeleteUser', 'ListUsers']
+    assert by_name(chunks, 'UserService.ListUsers').metadata['response'] == 'UserList'
+    assert by_name(chunks, 'Post').doc == 'Post message definition'
+    print("✅ Sample file parsed")
+
+
+def test_retrieval(workdir):
+    """An rpc is found on its own by what it does"""
+    root = workdir / "proto"
+    (root / "auth").mkdir(parents=True)
+    (root / "auth" / "session.proto").write_text(SESSION)
+    rag = make_rag(workdir, "protobuf")
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db"))).index_directory(str(root), parallel=False)
+    rag._build_keyword_index()
+    
+    results = rag.retrieve_context("rpc to create a session", n_results=3, lexical_weight=1.0)
+    assert results[0]['metadata']['name'] == 'CreateSession', [r['metadata']['name'] for r in results]
+    assert results[0]['metadata']['language'] == 'protobuf' and results[0]['metadata']['type'] == 'rpc'
+    
+    methods = rag.retrieve_context("delete a session", n_results=5, kinds=['method'])
+    assert {r['metadata']['type'] for r in methods} == {'rpc'}, methods
+    print("✅ Rpcs retrieved individually")
+
+
+def main():
+    print("=" * 70)
+    print("PROTOBUF CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_protobuf_"))
+    
+    tests = [
+        test_messages, test_enums, test_services, test_file_metadata, test_sample_file,
+        lambda: test_retrieval(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,201 @@
+#!/usr/bin/env python3
+"""
+Test script for the Python chunker
+Docstrings, decorators, self/cls receivers, nested definitions with their
+parent, module-level assignments and .pyi stub files
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import PythonChunker
+from config import CONFIG
+
+
+ACCOUNTS = '''"""Account helpers"""
+
+import functools
+
+MAX_RETRIES = 3
+default_region: str = "eu"
+
+
+def retry(times):
+    """Retry a call a number of times"""
+    def decorate(func):
+        """Wrap func"""
+        @functools.wraps(func)
+        def wrapper(*args, **kwargs):
+            return func(*args, **kwargs)
+        return wrapper
+    return decorate
+
+
+def make_handler():
+    class Handler:
+        def handle(self):
+            return 1
+    return Handler
+
+
+@dataclass
+class Point:
+    x: int = 0
+    y: int = 0
+
+
+class Account(Base):
+    """A customer account
+    
+    Holds the balance.
+    """
+    
+    class Meta:
+        """Storage options"""
+        table = "accounts"
+    
+    def deposit(self, amount: int) -> int:
+        """Add to the balance"""
+        self.balance += amount
+        return self.balance
+    
+    @classmethod
+    def open(cls, owner):
+        return cls(owner)
+    
+    @staticmethod
+    def fee(amount):
+        return amount // 100
+    
+    def forward(*args, **kwargs):
+        return args
+    
+    def options(**settings):
+        return settings
+    
+    def keyword_only(*, force=False):
+        return force
+    
+    @retry(3)
+    async def sync(self: "Account", *, force=False):
+        return force
+'''
+
+STUB = '''def parse(text: str) -> Document: ...
+
+class Document:
+    def render(self) -> str: ...
+
+VERSION: str
+'''
+
+
+def by_name(chunks, name):
+    return next(c for c in chunks if c.name == name)
+
+
+def test_docstrings():
+    chunks = PythonChunker().extract_chunks(ACCOUNTS, 'billing/accounts.py')
+    assert by_name(chunks, 'retry').doc == 'Retry a call a number of times'
+    assert by_name(chunks, 'decorate').doc == 'Wrap func'
+    assert by_name(chunks, 'Account').doc == 'A customer account\n\nHolds the balance.', by_name(chunks, 'Account').doc
+    assert by_name(chunks, 'deposit').doc == 'Add to the balance'
+    assert by_name(chunks, 'open').doc is None, "a body without a leading string has no docstring"
+    print("✅ The first string literal of a def or class body is its doc, dedented")
+
+
+def test_nested_parents():
+    chunks = PythonChunker().extract_chunks(ACCOUNTS, 'billing/accounts.py')
+    assert by_name(chunks, 'retry').parent is None
+    assert by_name(chunks, 'decorate').parent == 'retry'
+    wrapper = by_name(chunks, 'wrapper')
+    assert wrapper.parent == 'retry.decorate' and wrapper.type == 'function', (wrapper.parent, wrapper.type)
+    handler = by_name(chunks, 'Handler')
+    assert handler.type == 'class' and handler.parent == 'make_handler', handler.parent
+    handle = by_name(chunks, 'handle')
+    assert handle.type == 'method' and handle.parent == 'make_handler.Handler', handle.parent
+    assert by_name(chunks, 'Meta').parent == 'Account'
+    assert by_name(chunks, 'deposit').parent == 'Account' and by_name(chunks, 'deposit').parent_class == 'Account'
+    print("✅ Nested functions and classes in functions are child chunks with their parent")
+
+
+def test_decorators():
+    chunks = PythonChunker().extract_chunks(ACCOUNTS, 'billing/accounts.py')
+    wrapper = by_name(chunks, 'wrapper')
+    assert wrapper.metadata['decorators'] == ['@functools.wraps(func)'], wrapper.metadata
+    assert wrapper.content.lstrip().startswith('@functools.wraps(func)'), "the decorator is part of the chunk"
+    point = by_name(chunks, 'Point')
+    assert point.metadata['decorators'] == ['@dataclass'] and point.content.startswith('@dataclass')
+    assert point.line_start == ACCOUNTS.splitlines().index('@dataclass') + 1, point.line_start
+    sync = by_name(chunks, 'sync')
+    assert sync.metadata['decorators'] == ['@retry(3)'] and sync.metadata['async'], sync.metadata
+    assert sync.signature == '@retry(3)\nasync def sync(self: "Account", *, force=False)', sync.signature
+    account = by_name(chunks, 'Account')
+    assert account.metadata['base_classes'] == ['Base'] and account.signature == 'class Account(Base)'
+    print("✅ Decorators are recorded, kept in the content and shown in the signature")
+
+
+def test_receivers():
+    chunks = PythonChunker().extract_chunks(ACCOUNTS, 'billing/accounts.py')
+    receivers = {name: (by_name(chunks, name).metadata['method_kind'], by_name(chunks, name).metadata['receiver'])
+                 for name in ('deposit', 'open', 'fee', 'forward', 'options', 'keyword_only', 'sync', 'handle')}
+    assert receivers == {
+        'deposit': ('instance', 'self'),
+        'open': ('classmethod', 'cls'),
+        'fee': ('staticmethod', None),
+        'forward': ('instance', None),
+        'options': ('instance', None),
+        'keyword_only': ('instance', None),
+        'sync': ('instance', 'self'),
+        'handle': ('instance', 'self'),
+    }, receivers
+    assert 'receiver' not in by_name(chunks, 'retry').metadata, "functions have no receiver"
+    assert by_name(chunks, 'deposit').signature == 'def deposit(self, amount: int) -> int'
+    print("✅ Only a plain first parameter is the receiver, never *args, **kw or a keyword-only one")
+
+
+def test_module_assignments():
+    chunks = PythonChunker().extract_chunks(ACCOUNTS, 'billing/accounts.py')
+    assert by_name(chunks, 'MAX_RETRIES').type == 'constant'
+    region = by_name(chunks, 'default_region')
+    assert region.type == 'variable' and region.metadata == {'annotation': 'str'}, region.metadata
+    assert not [c for c in chunks if c.name == 'table'], "class attributes are not module assignments"
+    print("✅ Module-level assignments are constants or variables, with their annotation")
+
+
+def test_stub_files():
+    assert CONFIG.get_language_for_extension('.pyi') == 'python'
+    chunks = PythonChunker().extract_chunks(STUB, 'pkg/document.pyi')
+    assert [c.name for c in chunks] == ['parse', 'Document', 'render', 'VERSION'], [c.name for c in chunks]
+    assert by_name(chunks, 'parse').signature == 'def parse(text: str) -> Document'
+    assert by_name(chunks, 'render').metadata['receiver'] == 'self'
+    assert by_name(chunks, 'VERSION').metadata == {'annotation': 'str'}, "a stub annotation without a value"
+    print("✅ .pyi stubs yield their declarations, annotations without values included")
+
+
+def main():
+    print("=" * 70)
+    print("PYTHON CHUNKER TEST")
+    print("=" * 70)
+    
+    tests = [test_docstrings, test_nested_parents, test_decorators, test_receivers, test_module_assignments,
+             test_stub_files]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,334 @@
+#!/usr/bin/env python3
+"""
+Test script for the Qdrant vector store backend
+Runs against a fake Qdrant server on localhost that keeps points in memory
+and evaluates payload filters, so no Qdrant instance is needed
+"""
+
+import json
+import sys
+import threading
+from http.server import BaseHTTPRequestHandler, HTTPServer
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import HashEmbedder
+from rag import ChromeRAGSystem
+from stores import QdrantStore, StoreError
+from stores.qdrant_store import point_id, to_qdrant_filter
+
+
+def matches(payload, point_id_, condition):
+    """Evaluate a Qdrant condition or nested filter against one point"""
+    if 'key' not in condition and 'has_id' not in condition:
+        return (all(matches(payload, point_id_, c) for c in condition.get('must', []))
+                and not any(matches(payload, point_id_, c) for c in condition.get('must_not', []))
+                and (not condition.get('should') or any(matches(payload, point_id_, c) for c in condition['should'])))
+    if 'has_id' in condition:
+        return point_id_ in condition['has_id']
+    value = payload.get(condition['key'])
+    if 'match' in condition:
+        match = condition['match']
+        return value in match['any'] if 'any' in match else value == match['value']
+    bounds = condition['range']
+    return value is not None and all({
+        'gt': value > arg, 'gte': value >= arg, 'lt': value < arg, 'lte': value <= arg
+    }[op] for op, arg in bounds.items())
+
+
+class FakeQdrant(BaseHTTPRequestHandler):
+    """Serves the collection and point endpoints the store uses; can fail requests with a 503 first"""
+    collections = {}
+    failures_left = 0
+    requests = []
+    
+    def do_GET(self):
+        self._handle('GET')
+    
+    def do_PUT(self):
+        self._handle('PUT')
+    
+    def do_POST(self):
+        self._handle('POST')
+    
+    def do_DELETE(self):
+        self._handle('DELETE')
+    
+    def _handle(self, method):
+        length = int(self.headers.get('Content-Length') or 0)
+        body = json.loads(self.rfile.read(length)) if length else None
+        path = self.path.split('?')[0]
+        FakeQdrant.requests.append((method, path, body))
+        
+        if FakeQdrant.failures_left:
+            FakeQdrant.failures_left -= 1
+            return self._reply(503, {'status': {'error': 'service unavailable'}})
+        
+        parts = path.strip('/').split('/')
+        name = parts[1]
+        collection = FakeQdrant.collections.get(name)
+        if method == 'PUT' and len(parts) == 2:
+            FakeQdrant.collections[name] = {'config': body['vectors'], 'points': {}, 'indexes': [],
+                                            'quantization': body.get('quantization_config')}
+            return self._result(True)
+        if collection is None:
+            return self._reply(404, {'status': {'error': f"Collection `{name}` doesn't exist!"}})
+        if method == 'DELETE':
+            del FakeQdrant.collections[name]
+            return self._result(True)
+        if method == 'GET' and len(parts) == 2:
+            return self._result({'config': {'params': {'vectors': collection['config']},
+                                            'quantization_config': collection['quantization']}})
+        
+        points = collection['points']
+        action = parts[-1]
+        if action == 'index':
+            collection['indexes'].append(body['field_name'])
+            return self._result(True)
+        if method == 'GET':
+            point = points.get(parts[3]) or points.get(int(parts[3]) if parts[3].isdigit() else None)
+            if point is None:
+                return self._reply(404, {'status': {'error': 'Not found'}})
+            return self._result(point)
+        if method == 'PUT' and action == 'points':
+            for point in body['points']:
+                points[point['id']] = point
+            return self._result({'status': 'completed'})
+        
+        selected = [p for p in points.values()
+                    if matches(p['payload'], p['id'], body.get('filter') or {})]
+        if action == 'count':
+            return self._result({'count': len(points)})
+        if action == 'scroll':
+            selected.sort(key=lambda p: str(p['id']))
+            start = [str(p['id']) for p in selected].index(body['offset']) if 'offset' in body else 0
+            page = selected[start:start + body['limit']]
+            following = selected[start + body['limit']:]
+            return self._result({
+                'points': [self._view(p, body) for p in page],
+                'next_page_offset': str(following[0]['id']) if following else None
+            })
+        if action == 'search':
+            scored = sorted(selected, key=lambda p: -sum(a * b for a, b in zip(p['vector'], body['vector'])))
+            return self._result([
+                dict(self._view(p, body), score=sum(a * b for a, b in zip(p['vector'], body['vector'])))
+                for p in scored[:body['limit']]
+            ])
+        if action == 'batch':
+            for operation in body['operations']:
+                for pid in operation['set_payload']['points']:
+                    points[pid]['payload'].update(operation['set_payload']['payload'])
+            return self._result(True)
+        if action == 'delete':
+            for pid in body['points']:
+                points.pop(pid, None)
+            return self._result(True)
+        self._reply(404, {'status': {'error': 'unknown endpoint'}})
+    
+    def _view(self, point, body):
+        with_payload = body.get('with_payload', True)
+        if isinstance(with_payload, list):
+            payload = {k: v for k, v in point['payload'].items() if k in with_payload}
+        else:
+            payload = point['payload'] if with_payload else None
+        return {'id': point['id'], 'payload': payload,
+                'vector': point['vector'] if body.get('with_vector') else None}
+    
+    def _result(self, result):
+        self._reply(200, {'result': result, 'status': 'ok'})
+    
+    def _reply(self, status, body):
+        data = json.dumps(body).encode()
+        self.send_response(status)
+        self.send_header('Content-Type', 'application/json')
+        self.send_header('Content-Length', str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+    
+    def log_message(self, *args):
+        pass
+
+
+def start_server():
+    server = HTTPServer(('127.0.0.1', 0), FakeQdrant)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    return server, f"http://127.0.0.1:{server.server_address[1]}"
+
+
+def reset(failures=0):
+    FakeQdrant.collections = {}
+    FakeQdrant.failures_left = failures
+    FakeQdrant.requests = []
+
+
+def sample_chunks():
+    return [
+        CodeChunk(type='function', name='ParseURL', content='func ParseURL(raw string) (*URL, error)',
+                  filepath='net/url.go', language='go', line_start=10, line_end=40),
+        CodeChunk(type='method', name='String', content='func (u *URL) String() string',
+                  filepath='net/url.go', language='go', line_start=42, line_end=60, parent_class='URL'),
+        CodeChunk(type='function', name='parse_url', content='def parse_url(raw):\n    return urlsplit(raw)',
+                  filepath='tools/url.py', language='python', line_start=1, line_end=2),
+        CodeChunk(type='class', name='URLParser', content='class URLParser:\n    pass',
+                  filepath='tools/url.py', language='python', line_start=4, line_end=5),
+        CodeChunk(type='struct', name='URL', content='type URL struct { Scheme string }',
+                  filepath='net/url.go', language='go', line_start=1, line_end=8),
+    ]
+
+
+def build_rag(url, batch_size=256):
+    store = QdrantStore(url=url, name='code', distance='Dot', batch_size=batch_size, backoff=0.01)
+    rag = ChromeRAGSystem(collection_name='code', embedder=HashEmbedder(size=16), store=store)
+    rag.add_chunks_batch(sample_chunks())
+    rag._build_keyword_index()
+    return rag
+
+
+def test_filter_translation():
+    translated = to_qdrant_filter({'$and': [
+        {'language': 'go'},
+        {'type': {'$in': ['function', 'method']}},
+        {'$or': [{'line_start': {'$gte': 10}}, {'name': {'$ne': 'URL'}}]}
+    ]})
+    assert translated == {'must': [
+        {'must': [{'key': 'language', 'match': {'value': 'go'}}]},
+        {'must': [{'key': 'type', 'match': {'any': ['function', 'method']}}]},
+        {'must': [{'should': [
+            {'must': [{'key': 'line_start', 'range': {'gte': 10}}]},
+            {'must_not': [{'key': 'name', 'match': {'value': 'URL'}}]}
+        ]}]}
+    ]}, translated
+    try:
+        to_qdrant_filter({'name': {'$like': 'URL%'}})
+        assert False, "unsupported operator accepted"
+    except StoreError:
+        pass
+    print("✅ Chroma-style filters translated to Qdrant payload filters")
+
+
+def test_collection_creation(url):
+    reset()
+    rag = build_rag(url, batch_size=2)
+    collection = FakeQdrant.collections['code']
+    assert collection['config'] == {'size': 16, 'distance': 'Dot'}
+    assert set(collection['indexes']) >= {'filepath', 'language', 'type', 'name'}
+    upserts = [body for method, path, body in FakeQdrant.requests
+               if method == 'PUT' and path == '/collections/code/points']
+    assert [len(body['points']) for body in upserts] == [2, 2, 1]
+    
+    payload = collection['points'][point_id('net/url.go:String:42')]['payload']
+    assert (payload['filepath'], payload['language'], payload['type']) == ('net/url.go', 'go', 'method')
+    assert (payload['name'], payload['line_start'], payload['line_end']) == ('String', 42, 60)
+    assert rag.collection.count() == 5
+    assert rag.collection.metadata['embedding_dimensions'] == 16
+    print("✅ Collection sized and indexed on first insert; upserts batched")
+
+
+def test_quantized_collection(url):
+    reset()
+    store = QdrantStore(url=url, name='code', distance='Dot', quantization='int8', backoff=0.01)
+    rag = ChromeRAGSystem(collection_name='code', embedder=HashEmbedder(size=16), store=store)
+    rag.add_chunks_batch(sample_chunks())
+    assert FakeQdrant.collections['code']['quantization'] == {'scalar': {'type': 'int8', 'quantile': 0.99, 'always_ram': True}}
+    assert rag.collection.metadata['quantization'] == 'int8'
+    
+    # Reopened without a setting, the collection's own format is used; another one is refused
+    assert QdrantStore(url=url, name='code', distance='Dot').quantization == 'int8'
+    try:
+        QdrantStore(url=url, name='code', distance='Dot', quantization='none').count()
+        assert False, "an int8 collection should not open as float32"
+    except StoreError:
+        pass
+    print("✅ Scalar quantization configured on creation and kept by reopened stores")
+
+
+def test_filtered_search(url):
+    reset()
+    rag = build_rag(url)
+    results = rag.retrieve_context("parse url", n_results=5, languages=['go'], kinds=['function', 'method'],
+                                   lexical_weight=0.0)
+    assert results and all(r['metadata']['language'] == 'go' for r in results)
+    assert {r['metadata']['name'] for r in results} == {'ParseURL', 'String'}
+    assert results[0]['content'].startswith('func ')
+    
+    searches = [body for _, path, body in FakeQdrant.requests if path.endswith('/points/search')]
+    conditions = json.dumps(searches[-1]['filter'])
+    assert '"language"' in conditions and '"method"' in conditions, "filter not sent to the server"
+    print("✅ Language and kind filters evaluated server-side")
+
+
+def test_get_update_delete(url):
+    reset()
+    rag = build_rag(url, batch_size=2)
+    symbol = rag.retrieve_symbol('parse_url', language='python')
+    assert [s['metadata']['filepath'] for s in symbol] == ['tools/url.py']
+    
+    assert rag.move_file_chunks('tools/url.py', 'tools/urls.py') == 2
+    assert len(rag.collection.get(where={'filepath': 'tools/urls.py'})['ids']) == 2
+    assert rag.delete_file_chunks('net/url.go') == 3
+    assert sorted(rag.collection.get(include=[])['ids']) == ['tools/urls.py:URLParser:4', 'tools/urls.py:parse_url:1']
+    page = rag.collection.get(limit=1, offset=1, include=['embeddings'])
+    assert len(page['ids']) == 1 and len(page['embeddings'][0]) == 16 and page['documents'] is None
+    
+    rag.clear_collection()
+    assert rag.collection.count() == 0 and rag.collection.metadata == {}
+    assert rag.retrieve_context("parse url") == []
+    print("✅ Points fetched, re-pathed, deleted and cleared")
+
+
+def test_retry_and_reconnect(url):
+    reset()
+    store = QdrantStore(url=url, name='code', dimensions=16, backoff=0.01)
+    FakeQdrant.failures_left = 2
+    store.add(['chunk_0'], ['x'], [{'language': 'go'}], [[1.0] * 16])
+    assert store.count() == 1
+    
+    try:
+        QdrantStore(url=url, name='code', dimensions=8)
+        assert False, "dimension mismatch accepted"
+    except StoreError as e:
+        assert '16-dimensional' in str(e), str(e)
+    
+    unreachable = QdrantStore(url='http://127.0.0.1:9', name='code', max_retries=1, backoff=0.01, timeout=1)
+    try:
+        unreachable.count()
+        assert False, "unreachable server not reported"
+    except StoreError as e:
+        assert 'Cannot reach Qdrant' in str(e), str(e)
+    print("✅ Transient failures retried; mismatched dimensions and dead servers reported")
+
+
+def main():
+    print("=" * 70)
+    print("QDRANT STORE TEST")
+    print("=" * 70)
+    
+    server, url = start_server()
+    tests = [
+        test_filter_translation,
+        lambda: test_collection_creation(url),
+        lambda: test_quantized_collection(url),
+        lambda: test_filtered_search(url),
+        lambda: test_get_update_delete(url),
+        lambda: test_retry_and_reconnect(url),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    server.shutdown()
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,210 @@
+#!/usr/bin/env python3
+"""
+Test script for int8 vector quantization and dimension reduction
+Checks the rounding error of int8 codes, that the sqlite store keeps them in a
+quarter of the space with little loss of recall, that a collection keeps the
+format and reduction it was built with, and that snapshots record both. Uses
+random vectors and a small deterministic embedder
+"""
+
+import random
+import shutil
+import sqlite3
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from embedders import EmbeddingError
+from helpers import HashEmbedder
+from rag import ChromeRAGSystem
+from stores import SqliteStore, StoreError, create_store
+from stores.quantization import dequantize_int8, pack_int8, quantize_int8, quantized_dot
+from utils.index_snapshot import SnapshotError
+
+
+def sample_chunks():
+    return [
+        CodeChunk(type='function', name='ParseURL', content='func ParseURL(raw string) { parse url parse url scheme host }',
+                  filepath='net/url.go', language='go', line_start=1, line_end=10),
+        CodeChunk(type='function', name='Dial', content='func Dial(addr string) { dial tcp connection }',
+                  filepath='net/dial.go', language='go', line_start=1, line_end=10),
+        CodeChunk(type='function', name='Escape', content='func Escape(s string) { escape url query }',
+                  filepath='net/escape.go', language='go', line_start=1, line_end=10),
+    ]
+
+
+def new_rag(path, quantization=None, **options):
+    return ChromeRAGSystem(collection_name='code', embedder=HashEmbedder(size=64, normalize=False),
+                           store=SqliteStore(path, 'code', quantization=quantization), **options)
+
+
+def ranking(rag, query):
+    return [r['metadata']['name'] for r in rag.retrieve_context(query, n_results=3, lexical_weight=0.0)]
+
+
+def random_vectors(count, dimensions, seed):
+    generator = random.Random(seed)
+    return [[generator.gauss(0.0, 1.0) for _ in range(dimensions)] for _ in range(count)]
+
+
+def test_int8_roundtrip():
+    for vector in random_vectors(20, 96, seed=1):
+        codes, scale, offset = quantize_int8(vector)
+        decoded = dequantize_int8(codes, scale, offset)
+        assert max(abs(a - b) for a, b in zip(vector, decoded)) <= scale / 2 + 1e-9
+        assert min(codes) == -128 and max(codes) == 127
+        query = vector[::-1]
+        exact = sum(a * b for a, b in zip(query, decoded))
+        assert abs(quantized_dot(query, sum(query), codes, scale, offset) - exact) < 1e-6
+    
+    blob, decoded = pack_int8([0.5] * 8)  # constant vectors have no range to scale
+    assert decoded == [0.5] * 8 and len(blob) == 8 + 8
+    print("✅ int8 codes round each component to within half a step")
+
+
+def test_sqlite_int8_recall(workdir):
+    documents = random_vectors(400, 64, seed=2)
+    queries = random_vectors(25, 64, seed=3)
+    ids = [f'v{i}' for i in range(len(documents))]
+    stores = {}
+    for format in ('none', 'int8'):
+        stores[format] = SqliteStore(str(workdir / f'{format}.db'), 'vectors', quantization=format)
+        stores[format].add(ids, [''] * len(ids), [{}] * len(ids), documents)
+    
+    with sqlite3.connect(str(workdir / 'int8.db')) as conn:
+        quantized = conn.execute("SELECT SUM(LENGTH(vector)) FROM chunks").fetchone()[0]
+    with sqlite3.connect(str(workdir / 'none.db')) as conn:
+        full = conn.execute("SELECT SUM(LENGTH(vector)) FROM chunks").fetchone()[0]
+    assert quantized * 3.5 < full, (quantized, full)
+    
+    found = 0
+    for query in queries:
+        exact = set(stores['none'].query([query], n_results=10)['ids'][0])
+        found += len(exact & set(stores['int8'].query([query], n_results=10)['ids'][0]))
+    recall = found / (10 * len(queries))
+    assert recall >= 0.9, recall
+    
+    vector = stores['int8'].get(ids=['v7'], include=['embeddings'])['embeddings'][0]
+    assert max(abs(a - b) for a, b in zip(vector, documents[7])) < 0.05
+    print(f"✅ int8 vectors take a quarter of the space; recall@10 {recall:.2f} against float32")
+
+
+def test_format_kept(workdir):
+    path = str(workdir / 'kept.db')
+    rag = new_rag(path, quantization='int8')
+    rag.add_chunks_batch(sample_chunks())
+    assert rag.collection.metadata['quantization'] == 'int8'
+    
+    reopened = new_rag(path)
+    assert reopened.collection.quantization == 'int8'
+    assert ranking(reopened, "dial tcp connection")[0] == 'Dial'
+    try:
+        SqliteStore(path, 'code', quantization='none')
+        assert False, "an int8 collection should not open as float32"
+    except StoreError:
+        pass
+    
+    # Reset, a collection can take the other format
+    reopened.clear_collection()
+    assert SqliteStore(path, 'code', quantization='none').quantization == 'none'
+    for backend in ('chroma', 'pgvector'):
+        try:
+            create_store(backend=backend, quantization='int8')
+            assert False, f"{backend} keeps float vectors only"
+        except StoreError:
+            pass
+    print("✅ A collection keeps its vector format; stores without int8 refuse it")
+
+
+def test_reduced_dimensions(workdir):
+    path = str(workdir / 'reduced.db')
+    rag = new_rag(path, reduce_dimensions=32, normalize_embeddings=True)
+    rag.add_chunks_batch(sample_chunks())
+    stored = rag.collection.get(include=['embeddings'])['embeddings']
+    assert all(len(vector) == 32 for vector in stored)
+    assert all(abs(sum(x * x for x in vector) - 1.0) < 1e-5 for vector in stored)
+    assert rag.collection.metadata['reduced_dimensions'] == 32
+    
+    reopened = new_rag(path)
+    assert reopened.reduce_dimensions == 32 and reopened.validate_embedder() == 32
+    assert ranking(reopened, "parse url scheme")[0] == 'ParseURL'
+    for options in ({'reduce_dimensions': 16}, {'reduce_dimensions': 0}):
+        try:
+            new_rag(path, **options).validate_embedder()
+            assert False, f"{options} should not open an index of 32-dimensional vectors"
+        except EmbeddingError:
+            pass
+    try:
+        new_rag(str(workdir / 'too-many.db'), reduce_dimensions=128).add_chunks_batch(sample_chunks())
+        assert False, "64-dimensional vectors cannot be reduced to 128"
+    except EmbeddingError:
+        pass
+    print("✅ Reduced vectors keep their leading components, and the index its reduction")
+
+
+def test_embed_query(workdir):
+    rag = new_rag(str(workdir / 'query.db'), reduce_dimensions=32, normalize_embeddings=True)
+    assert rag.embedding_dimensions == 32, "an empty index reports the reduced dimension"
+    rag.add_chunks_batch(sample_chunks())
+    vector = rag.embed_query("parse url scheme")
+    assert len(vector) == rag.embedding_dimensions == 32
+    assert abs(sum(x * x for x in vector) - 1.0) < 1e-5
+    
+    stored = rag.collection.get(include=['embeddings', 'metadatas'])
+    scores = [sum(a * b for a, b in zip(vector, embedding)) for embedding in stored['embeddings']]
+    best = stored['metadatas'][scores.index(max(scores))]['name']
+    assert best == 'ParseURL', f"the query vector should be closest to ParseURL, got {best}"
+    print("✅ Query vectors are reduced and normalized like the stored ones")
+
+
+def test_snapshot_records_scheme(workdir):
+    rag = new_rag(str(workdir / 'saved.db'), quantization='int8', reduce_dimensions=48)
+    rag.add_chunks_batch(sample_chunks())
+    manifest = rag.save_index(str(workdir / 'snapshot'))
+    assert manifest['quantization'] == 'int8' and manifest['reduced_dimensions'] == 48, manifest
+    assert manifest['dimensions'] == 48
+    
+    try:
+        new_rag(str(workdir / 'float.db')).load_index(str(workdir / 'snapshot'))
+        assert False, "an int8 snapshot should not load into a float32 store"
+    except SnapshotError:
+        pass
+    
+    restored = new_rag(str(workdir / 'restored.db'), quantization='int8')
+    restored.load_index(str(workdir / 'snapshot'))
+    assert restored.reduce_dimensions == 48, "the snapshot's reduction should be adopted"
+    assert restored.collection.metadata['quantization'] == 'int8'
+    assert ranking(restored, "escape url query") == ranking(rag, "escape url query")
+    print("✅ Snapshots carry the vector format and reduction, and loads keep to them")
+
+
+def main():
+    print("=" * 70)
+    print("VECTOR QUANTIZATION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="quantization_"))
+    tests = [test_int8_roundtrip, lambda: test_sqlite_int8_recall(workdir), lambda: test_format_kept(workdir),
+             lambda: test_reduced_dimensions(workdir), lambda: test_embed_query(workdir),
+             lambda: test_snapshot_records_scheme(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
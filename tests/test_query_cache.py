@@ -0,0 +1,134 @@
+#!/usr/bin/env python3
+"""
+Test script for the query result cache
+Checks the LRU on its own (hits, expiry, eviction), then repeated searches on a
+small index and their invalidation when the index changes
+"""
+
+import shutil
+import sys
+import tempfile
+import time
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import make_chroma_rag
+from utils.query_cache import QueryCache, freeze, normalize_query
+
+
+def sample_chunks():
+    return [
+        CodeChunk(type='function', name='ParseURL', content='func ParseURL(raw string) (*URL, error) { parse url }',
+                  filepath='net/url.go', language='go', line_start=10, line_end=40),
+        CodeChunk(type='function', name='parse_url', content='def parse_url(raw):\n    return urlsplit(raw)',
+                  filepath='tools/url.py', language='python', line_start=1, line_end=2),
+    ]
+
+
+def test_lru():
+    cache = QueryCache(max_entries=2, ttl=0)
+    cache.put('a', [{'id': 1}])
+    cache.put('b', [{'id': 2}])
+    assert cache.get('a') == [{'id': 1}]
+    cache.put('c', [{'id': 3}])
+    assert cache.get('b') is None, "least recently used entry kept"
+    assert cache.get('a') and cache.get('c')
+    
+    copy = cache.get('a')
+    copy[0]['id'] = 99
+    assert cache.get('a') == [{'id': 1}], "cached results shared with the caller"
+    
+    stats = cache.stats()
+    assert (stats['hits'], stats['misses'], stats['evictions'], stats['entries']) == (5, 1, 1, 2), stats
+    assert abs(stats['hit_rate'] - 5 / 6) < 1e-9
+    
+    assert not QueryCache(max_entries=0).enabled
+    assert normalize_query("  Parse   URL\n") == "parse url"
+    assert freeze({'kinds': ['method'], 'language': 'go'}) == (('kinds', ('method',)), ('language', 'go'))
+    print("✅ LRU eviction, copies on get, hit/miss counters")
+
+
+def test_ttl():
+    cache = QueryCache(max_entries=4, ttl=0.05)
+    cache.put('a', [])
+    assert cache.get('a') == []
+    time.sleep(0.1)
+    assert cache.get('a') is None
+    assert cache.stats()['expirations'] == 1
+    print("✅ Entries expire after the TTL")
+
+
+def test_repeated_search(rag):
+    embedder = rag.embedder
+    first = rag.retrieve_context("parse url", n_results=2, languages=['go'])
+    calls = embedder.calls
+    again = rag.retrieve_context("  Parse URL ", n_results=2, languages=['go'])
+    assert again == first and embedder.calls == calls, "repeated query not served from the cache"
+    
+    streamed = list(rag.iter_context("parse url", n_results=2, languages=['go']))
+    assert streamed == first and embedder.calls == calls
+    first[0]['content'] = again[0]['content'] = streamed[0]['content'] = 'edited by the caller'
+    assert rag.retrieve_context("parse url", n_results=2, languages=['go'])[0]['content'] != 'edited by the caller', \
+        "a cache hit hands out copies"
+    
+    rag.retrieve_context("parse url", n_results=1, languages=['go'])
+    rag.retrieve_context("parse url", n_results=2, languages=['python'])
+    assert embedder.calls == calls + 2, "different top-k or filters shared an entry"
+    assert rag.query_cache.stats()['hits'] == 3
+    print("✅ Repeated searches are answered from the cache; top-k and filters are part of the key")
+
+
+def test_invalidation(rag):
+    before = rag.retrieve_context("split raw", n_results=5)
+    version = rag.index_version
+    rag.add_chunks_batch([CodeChunk(type='function', name='split_raw', content='def split_raw(raw): split raw',
+                                    filepath='tools/split.py', language='python', line_start=1, line_end=1)])
+    rag._build_keyword_index()
+    assert rag.index_version > version
+    after = rag.retrieve_context("split raw", n_results=5)
+    assert 'split_raw' in [r['metadata']['name'] for r in after], after
+    assert len(after) == len(before) + 1
+    
+    # Vector-only, so the result does not depend on the keyword index, rebuilt only after updates
+    assert 'split_raw' in [r['metadata']['name'] for r in rag.retrieve_context("split raw", n_results=5, lexical_weight=0.0)]
+    rag.delete_file_chunks('tools/split.py')
+    names = [r['metadata']['name'] for r in rag.retrieve_context("split raw", n_results=5, lexical_weight=0.0)]
+    assert 'split_raw' not in names, names
+    print("✅ Adding or deleting chunks invalidates cached results")
+
+
+def main():
+    print("=" * 70)
+    print("QUERY CACHE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="query_cache_"))
+    rag = make_chroma_rag(workdir / "db", "query_cache")
+    rag.add_chunks_batch(sample_chunks())
+    rag._build_keyword_index()
+    tests = [
+        test_lru,
+        test_ttl,
+        lambda: test_repeated_search(rag),
+        lambda: test_invalidation(rag),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,170 @@
+#!/usr/bin/env python3
+"""
+Test script for query embedders
+A search can embed its query with one of the configured query embedders
+instead of the index's embedder: the stored vectors stay the primary model's,
+embedders of another dimension or unknown names are refused before the search
+runs, on the command line and the HTTP server alike. Uses small deterministic
+embedders
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+import threading
+import urllib.error
+import urllib.request
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from helpers import HashEmbedder, make_rag
+from server import RAGServer
+from utils.config_file import file_settings
+
+CHUNKS = [
+    CodeChunk(type='function', name='Authenticate', content='func Authenticate(user, password string) error',
+              filepath='auth/login.go', language='go', line_start=1, line_end=1),
+    CodeChunk(type='function', name='SignOut', content='func SignOut(session Session) { session.Close() }',
+              filepath='auth/logout.go', language='go', line_start=1, line_end=1),
+    CodeChunk(type='function', name='Render', content='func Render(page Page) string { return page.HTML() }',
+              filepath='web/render.go', language='go', line_start=1, line_end=1),
+]
+
+
+class AliasEmbedder(HashEmbedder):
+    """Hashed tokens where aliases map a word to another before hashing"""
+    
+    def __init__(self, model_name, aliases):
+        super().__init__(model_name)
+        self.aliases = aliases
+    
+    def words(self, text):
+        return [self.aliases.get(word, word) for word in super().words(text)]
+
+
+def build_rag(workdir, name, **options):
+    rag = make_rag(workdir, name, query_embedders={
+        'tenant': AliasEmbedder('tenant-model', aliases={'signin': 'authenticate'}),
+        'wide': HashEmbedder('wide-model', size=128),
+    }, **options)
+    rag.add_chunks_batch(CHUNKS)
+    rag._build_keyword_index()
+    return rag
+
+
+def request(url, path, body):
+    req = urllib.request.Request(url + path, data=json.dumps(body).encode(), method='POST',
+                                 headers={'Content-Type': 'application/json'})
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            return response.status, json.loads(response.read())
+    except urllib.error.HTTPError as e:
+        return e.code, json.loads(e.read())
+
+
+def test_query_embedder(workdir):
+    rag = build_rag(workdir, "tenants")
+    
+    def top(**options):
+        results = rag.retrieve_context("signin html", n_results=3, lexical_weight=0.0, **options)
+        return [r['metadata']['name'] for r in results]
+    
+    tenant = rag.query_embedders['tenant']
+    assert top(query_embedder='tenant')[0] == 'Authenticate' and tenant.calls == 1
+    assert top()[0] == 'Render', "the index's embedder does not know the alias"
+    assert top(query_embedder='tenant')[0] == 'Authenticate' and tenant.calls == 1, "answered from the cache"
+    assert rag.collection.metadata['embedding_model'] == 'test-hash', "stored vectors are the primary model's"
+    
+    streamed = [r['metadata']['name'] for r in rag.iter_context("signin html", n_results=3, lexical_weight=0.0,
+                                                                 query_embedder='tenant', candidate_k=5)]
+    assert streamed[0] == 'Authenticate', streamed
+    print("✅ A search embeds its query with the query embedder it names")
+
+
+def test_refused(workdir):
+    rag = build_rag(workdir, "refused", embedding_models={'other': HashEmbedder('other-model')})
+    for options, message in (({'query_embedder': 'nope'},
+                              "Unknown query embedder 'nope' (expected one of: tenant, wide)"),
+                             ({'query_embedder': 'wide'}, "produces 128-dimensional vectors, but collection 'refused' "
+                                                          "holds 256-dimensional vectors from 'test-hash'"),
+                             ({'query_embedder': 'tenant', 'embedding_models': ['other']},
+                              "which this search does not rank by")):
+        for search in (rag.retrieve_context, lambda *args, **kw: list(rag.iter_context(*args, **kw))):
+            try:
+                search("signin", n_results=2, **options)
+                assert False, f"no error for {options}"
+            except ValueError as e:
+                assert message in str(e), str(e)
+    assert rag.query_embedders['wide'].calls == 0, "refused before anything is embedded"
+    
+    try:
+        make_rag(workdir, "bad", query_embedders={'primary': HashEmbedder()})
+        assert False, "a query embedder named primary, but no error"
+    except ValueError as e:
+        assert 'Invalid query embedder name' in str(e), e
+    print("✅ Unknown query embedders and ones of another dimension are refused")
+
+
+def test_config_and_server(workdir):
+    tenants = {'tenant-a': {'backend': 'ollama', 'model': 'nomic'}}
+    settings, problems = file_settings({'query_embedders': tenants}, CONFIG)
+    assert not problems and settings['query_embedders'] == tenants, (settings, problems)
+    saved = CONFIG.query_embedders
+    CONFIG.query_embedders = {'tenant-a': {'backend': 'ollama', 'modle': 'nomic'}}
+    try:
+        problems = cli.setting_problems()
+    finally:
+        CONFIG.query_embedders = saved
+    assert any(p.startswith("query_embedders.tenant-a: unknown key modle") for p in problems), problems
+    
+    rag = build_rag(workdir, "served")
+    server = RAGServer(('127.0.0.1', 0), rag)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        status, body = request(url, '/search', {'query': 'signin html', 'top_k': 1, 'lexical_weight': 0.0,
+                                                'query_embedder': 'tenant'})
+        assert status == 200 and body['results'][0]['name'] == 'Authenticate', body
+        status, body = request(url, '/search', {'query': 'signin', 'query_embedder': 'wide'})
+        assert status == 400 and '128-dimensional' in body['error'], body
+        assert request(url, '/search', {'query': 'signin', 'query_embedder': ['tenant']})[0] == 400
+    finally:
+        server.shutdown()
+        server.server_close()
+    print("✅ Query embedders come from the config file and are picked per /search request")
+
+
+def main():
+    print("=" * 70)
+    print("QUERY EMBEDDERS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_query_embedders_"))
+    tests = [
+        lambda: test_query_embedder(workdir),
+        lambda: test_refused(workdir),
+        lambda: test_config_and_server(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
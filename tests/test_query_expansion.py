@@ -0,0 +1,170 @@
+#!/usr/bin/env python3
+"""
+Test script for query expansion
+Plain-English queries are widened with the joined forms of adjacent words and
+with synonyms, so they find code that spells them as one identifier or an
+abbreviation; user groups extend the built-in synonyms.
+Uses a small deterministic embedder
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from config import CONFIG
+from helpers import make_rag
+from utils.query_expansion import SYNONYM_GROUPS, build_synonyms, expand_query, load_synonym_groups
+
+SOURCE = '''package account
+
+// SignIn checks the credentials and opens a session
+func SignIn(name, secret string) (*Session, error) {
+    return open(name, secret)
+}
+
+// AuthToken returns the bearer token of a session
+func AuthToken(s *Session) string {
+    return s.token
+}
+
+// LoadCfg reads the settings file
+func LoadCfg(path string) (*Settings, error) {
+    return parse(path)
+}
+
+// UserName is the display name of an account
+func UserName(a *Account) string {
+    return a.display
+}
+
+// TenantOf is the organisation an account belongs to
+func TenantOf(a *Account) string {
+    return a.org
+}
+
+// Render draws the account page
+func Render(a *Account) string {
+    return page(a)
+}
+'''
+
+
+def build_rag(workdir, collection, query_synonyms=None):
+    rag = make_rag(workdir, collection, query_synonyms=query_synonyms)
+    rag.add_chunks_batch(GoChunker().extract_chunks(SOURCE, "account.go"))
+    rag._build_keyword_index()
+    return rag
+
+
+def names(results):
+    return [result['metadata']['name'] for result in results]
+
+
+def test_expand_query():
+    synonyms = build_synonyms(SYNONYM_GROUPS)
+    terms = expand_query("sign in", synonyms)
+    assert terms[:2] == ['signin', 'sign_in'], terms
+    assert {'login', 'logon', 'log_in'} <= set(terms), "synonyms of the joined form were not added"
+    
+    terms = expand_query("authentication config", synonyms)
+    assert {'auth', 'authenticate', 'cfg', 'configuration', 'settings'} <= set(terms), terms
+    assert 'authentication' not in terms and 'config' not in terms, "query words were added again"
+    assert expand_query("authentication config", synonyms) == terms, "expansion is not deterministic"
+    assert expand_query("x", synonyms) == []
+    print("✅ Queries expand to joined words and synonyms, without repeating their own words")
+
+
+def test_build_synonyms(workdir):
+    synonyms = build_synonyms(SYNONYM_GROUPS + [['login', 'Authenticate'], ['tenant', 'org', ' '], ['solo']])
+    assert {'auth', 'signin', 'authentication'} <= synonyms['login'], "overlapping groups were not merged"
+    assert synonyms['tenant'] == {'org'} and 'solo' not in synonyms and '' not in synonyms
+    
+    path = workdir / "synonyms.json"
+    path.write_text(json.dumps([['tenant', 'org']]))
+    assert load_synonym_groups(str(path)) == [['tenant', 'org']]
+    path.write_text(json.dumps({'tenant': ['org']}))
+    try:
+        load_synonym_groups(str(path))
+    except ValueError as e:
+        assert 'list of word lists' in str(e)
+    else:
+        raise AssertionError("a synonyms file that is not a list of word lists was accepted")
+    print("✅ Synonym groups sharing a word are merged, and files of groups are validated")
+
+
+def test_recall(workdir):
+    rag = build_rag(workdir, "recall")
+    questions = {
+        "login": 'SignIn',
+        "authentication": 'AuthToken',
+        "configuration": 'LoadCfg',
+        "user name": 'UserName',
+    }
+    for lexical_weight in (1.0, 0.5):
+        plain = sum(expected in names(rag.retrieve_context(query, n_results=2, lexical_weight=lexical_weight))
+                    for query, expected in questions.items())
+        expanded = sum(expected in names(rag.retrieve_context(query, n_results=2, lexical_weight=lexical_weight,
+                                                              expand_query=True))
+                       for query, expected in questions.items())
+        assert expanded == len(questions), f"expansion found {expanded} of {len(questions)} (weight {lexical_weight})"
+        assert plain < expanded, f"expansion did not improve recall: {plain} of {len(questions)} without it"
+    
+    top = rag.retrieve_context("login", n_results=1, lexical_weight=1.0, expand_query=True)[0]
+    assert top['metadata']['name'] == 'SignIn' and 0 < top['relevance'] <= 1.0, top
+    print(f"✅ Expansion finds {len(questions)} of {len(questions)} plain-English queries' symbols ({plain} without)")
+
+
+def test_user_synonyms(workdir):
+    plain = build_rag(workdir, "plain")
+    assert plain.retrieve_context("organization", n_results=1, lexical_weight=1.0, expand_query=True) == []
+    rag = build_rag(workdir, "custom", query_synonyms=[['organization', 'org', 'tenant']])
+    assert rag.expand_query("organization") == ['org', 'tenant']
+    results = rag.retrieve_context("organization", n_results=1, lexical_weight=1.0, expand_query=True)
+    assert names(results) == ['TenantOf'], names(results)
+    
+    saved = CONFIG.query_expansion
+    CONFIG.query_expansion = True
+    try:
+        assert names(rag.retrieve_context("organization", n_results=1, lexical_weight=1.0)) == ['TenantOf']
+        assert rag.retrieve_context("organization", n_results=1, lexical_weight=1.0, expand_query=False) == []
+    finally:
+        CONFIG.query_expansion = saved
+    print("✅ User synonym groups extend expansion, which CONFIG.query_expansion turns on by default")
+
+
+def main():
+    print("=" * 70)
+    print("QUERY EXPANSION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_expansion_"))
+    
+    tests = [
+        test_expand_query,
+        lambda: test_build_synonyms(workdir),
+        lambda: test_recall(workdir),
+        lambda: test_user_synonyms(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
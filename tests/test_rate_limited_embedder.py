@@ -0,0 +1,233 @@
+#!/usr/bin/env python3
+"""
+Test script for rate limiting and retries in front of embedding backends
+Requests are paced per minute and in flight, throttled requests back off with
+jitter and honour Retry-After, and a batch that fails is retried text by text
+so only the texts that fail on their own are left out of the index (and their
+files retried by the next run). Uses a fake clock and small fake backends
+"""
+
+import shutil
+import sys
+import tempfile
+from email.utils import format_datetime
+from datetime import datetime, timedelta, timezone
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from embedders import (CachedEmbedder, EmbeddingError, PartialEmbeddingError, RateLimitedEmbedder,
+                       RateLimitError, RateLimiter, create_embedder, find_embedder)
+from embedders.ollama_embedder import retry_after
+from helpers import HashEmbedder, make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+
+class FakeClock:
+    """Time that only moves when something sleeps"""
+    
+    def __init__(self):
+        self.now = 1000.0
+        self.sleeps = []
+    
+    def __call__(self):
+        return self.now
+    
+    def sleep(self, seconds):
+        self.sleeps.append(seconds)
+        self.now += seconds
+
+
+class FlakyEmbedder(HashEmbedder):
+    """Hashed bag-of-words vectors; throttles the first `throttle` requests and fails any batch holding POISON"""
+    
+    def __init__(self, throttle=0, retry_after=None, batch_size=4):
+        super().__init__('flaky', size=32, normalize=False, batch_size=batch_size)
+        self.throttle = throttle
+        self.retry_after = retry_after
+        self.calls = []
+    
+    def embed(self, texts):
+        self.calls.append(list(texts))
+        if self.throttle:
+            self.throttle -= 1
+            raise RateLimitError("slow down", self.retry_after)
+        if any('POISON' in text for text in texts):
+            raise EmbeddingError("input rejected")
+        return [self.vector(text) for text in texts]
+
+
+def limited(backend, clock, **options):
+    return RateLimitedEmbedder(backend, clock=clock, sleep=clock.sleep, jitter=lambda: 1.0, **options)
+
+
+def test_requests_per_minute(workdir: Path):
+    clock = FakeClock()
+    limiter = RateLimiter(requests_per_minute=2, clock=clock, sleep=clock.sleep)
+    waits = []
+    for _ in range(5):
+        waits.append(limiter.acquire())
+        limiter.release()
+    # Two requests per 60 seconds: the third and fifth wait for the window to move on
+    assert waits == [0.0, 0.0, 60.0, 0.0, 60.0], waits
+    
+    embedder = limited(FlakyEmbedder(batch_size=1), clock, requests_per_minute=3)
+    start = clock.now
+    embedder.embed([f"text {i}" for i in range(6)])
+    assert embedder.requests == 6 and clock.now - start == 60.0, (embedder.requests, clock.now - start)
+    assert embedder.summary()['throttle_wait'] == 60.0, embedder.summary()
+    
+    for bad in ({'requests_per_minute': -1}, {'max_concurrent': 0}):
+        try:
+            RateLimiter(**bad)
+            assert False, f"{bad} accepted"
+        except ValueError:
+            pass
+    print("✅ Requests are paced to the requests-per-minute budget")
+
+
+def test_backoff_and_retry_after(workdir: Path):
+    clock = FakeClock()
+    embedder = limited(FlakyEmbedder(throttle=3), clock, backoff=1.0, max_backoff=3.0)
+    vectors = embedder.embed(["alpha", "beta"])
+    assert len(vectors) == 2
+    # Full-jitter delays (jitter pinned to 1): 1, 2, then capped at 3
+    assert clock.sleeps == [1.0, 2.0, 3.0], clock.sleeps
+    summary = embedder.summary()
+    assert (summary['requests'], summary['retries'], summary['throttled']) == (4, 3, 3), summary
+    
+    # The backend's Retry-After is a floor of the delay
+    clock = FakeClock()
+    embedder = limited(FlakyEmbedder(throttle=1, retry_after=30), clock, backoff=1.0)
+    embedder.embed(["alpha"])
+    assert clock.sleeps == [30.0], clock.sleeps
+    
+    # Throttled on every retry: give up with the rate-limit error
+    clock = FakeClock()
+    embedder = limited(FlakyEmbedder(throttle=10), clock, max_retries=2)
+    try:
+        embedder.embed(["alpha"])
+        assert False, "still throttled, but no error"
+    except RateLimitError as e:
+        assert 'after 2 retries' in str(e), e
+    assert embedder.throttled == 3 and embedder.retries == 2, embedder.summary()
+    
+    embedder.reset_stats()
+    assert set(embedder.summary().values()) == {0}, embedder.summary()
+    print("✅ Throttled requests back off with jitter and honour Retry-After")
+
+
+def test_failed_batch_retried_per_text(workdir: Path):
+    clock = FakeClock()
+    backend = FlakyEmbedder(batch_size=3)
+    embedder = limited(backend, clock)
+    texts = ["alpha", "beta POISON", "gamma", "delta", "epsilon"]
+    try:
+        embedder.embed(texts)
+        assert False, "a poisoned text, but no error"
+    except PartialEmbeddingError as e:
+        assert [v is None for v in e.vectors] == [False, True, False, False, False], e.vectors
+        assert [index for index, _ in e.failures] == [1], e.failures
+        assert e.vectors[0] == backend.embed(["alpha"])[0]
+    # First batch failed whole, then its three texts went one by one; the second batch was fine
+    assert embedder.batches_split == 1 and embedder.texts_failed == 1, embedder.summary()
+    
+    # Nothing embeds: the backend's own error, not a partial one
+    try:
+        embedder.embed(["POISON one", "POISON two"])
+        assert False, "nothing embedded, but no error"
+    except PartialEmbeddingError:
+        assert False, "a call where every text failed reported a partial result"
+    except EmbeddingError as e:
+        assert 'input rejected' in str(e), e
+    
+    # Concurrent batches keep their order
+    parallel = limited(FlakyEmbedder(batch_size=1), FakeClock(), max_concurrent=4)
+    words = [f"word{i} {'abcdefgh'[i]}" for i in range(8)]
+    assert parallel.embed(words) == FlakyEmbedder().embed(words)
+    print("✅ A failed batch is retried text by text, losing only the bad text")
+
+
+def test_cache_keeps_partial_results(workdir: Path):
+    backend = FlakyEmbedder(batch_size=2)
+    cached = CachedEmbedder(limited(backend, FakeClock()), str(workdir / "partial.db"))
+    try:
+        cached.embed(["one", "two POISON", "one", "three"])
+        assert False, "a poisoned text, but no error"
+    except PartialEmbeddingError as e:
+        # Positions are those of the caller's texts, repeats included
+        assert [index for index, _ in e.failures] == [1], e.failures
+        assert e.vectors[0] == e.vectors[2] is not None
+    backend.calls = []
+    cached.embed(["one", "three"])
+    assert backend.calls == [], f"embedded texts were not cached: {backend.calls}"
+    print("✅ The embedding cache keeps the vectors of a partly failed call")
+
+
+def test_indexing_leaves_out_failed_chunks(workdir: Path):
+    source = workdir / "tree"
+    (source / "docs").mkdir(parents=True)
+    (source / "docs" / "good.md").write_text("# Sessions\n\nThe session token expires after an hour.\n")
+    (source / "docs" / "bad.md").write_text("# Broken\n\nThis note holds POISON the backend rejects.\n")
+    
+    clock = FakeClock()
+    embedder = limited(FlakyEmbedder(throttle=1, retry_after=2), clock)
+    rag = make_rag(workdir, "limited", embedder=embedder)
+    state = StateManager(str(workdir / "limited-state.db"))
+    stats = ChromeIndexer(rag, state_manager=state).index_directory(str(source), parallel=False, batch_size=8)
+    
+    stored = {metadata['filepath'] for metadata in rag.collection.get()['metadatas']}
+    assert stored == {'docs/good.md'}, stored
+    assert [failure['filepath'] for failure in stats['embedding_failures']] == ['docs/bad.md'], stats['embedding_failures']
+    assert any('could not embed' in error for error in stats['errors']), stats['errors']
+    recorded = {str(Path(path).relative_to(source)) for path in state.get_all_indexed_files()}
+    assert recorded == {'docs/good.md'}, f"a file with chunks left out was recorded as indexed: {recorded}"
+    summary = stats['embedding_requests']
+    assert summary['throttled'] == 1 and summary['texts_failed'] == 1, summary
+    assert rag.retrieve_context("session token expires", n_results=1)[0]['metadata']['filepath'] == 'docs/good.md'
+    print("✅ Indexing stores what embedded and leaves failed files for the next run")
+
+
+def test_factory_and_retry_after(workdir: Path):
+    embedder = create_embedder('ollama', requests_per_minute=120, max_concurrent=2)
+    assert isinstance(embedder, RateLimitedEmbedder), embedder
+    assert (embedder.limiter.requests_per_minute, embedder.limiter.max_concurrent) == (120, 2)
+    cached = create_embedder('ollama', cache_path=str(workdir / "factory.db"))
+    assert isinstance(find_embedder(cached, RateLimitedEmbedder), RateLimitedEmbedder)
+    assert find_embedder(create_embedder('default'), RateLimitedEmbedder) is None
+    
+    assert retry_after({'Retry-After': '7'}) == 7.0
+    later = format_datetime(datetime.now(timezone.utc) + timedelta(seconds=90), usegmt=True)
+    assert 80 < retry_after({'Retry-After': later}) <= 90
+    assert retry_after({'Retry-After': 'soon'}) is None and retry_after({}) is None
+    print("✅ HTTP backends are rate limited and Retry-After headers parsed")
+
+
+def main():
+    print("=" * 70)
+    print("RATE LIMITED EMBEDDER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rate_limited_"))
+    tests = [test_requests_per_minute, test_backoff_and_retry_after, test_failed_batch_retried_per_text,
+             test_cache_keeps_partial_results, test_indexing_leaves_out_failed_chunks,
+             test_factory_and_retry_after]
+    failed = 0
+    for test in tests:
+        try:
+            test(workdir)
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,169 @@
+#!/usr/bin/env python3
+"""
+Test script for related symbols
+Functions related through the call graph rather than their wording: those
+calling the same indexed helpers, or called by the same functions, ranked by
+how many they share.
+Uses a small deterministic embedder
+"""
+
+import argparse
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, link_go_packages, split_oversized_chunks
+from chunkers.base_chunker import qualified_name
+from helpers import make_chroma_rag
+from utils.related_symbols import call_graph, related_symbols
+
+ORDERS = '''package orders
+
+func validate(o *Order) error {
+    return check(o)
+}
+
+func save(o *Order) error {
+    return db.Insert(o)
+}
+
+func audit(o *Order) {
+    log.Println("order", o.ID)
+}
+
+// CreateOrder checks and stores a new order
+func CreateOrder(o *Order) error {
+    if err := validate(o); err != nil {
+        return fmt.Errorf("invalid order: %w", err)
+    }
+    audit(o)
+    return save(o)
+}
+
+// UpdateOrder checks and stores the changes to an order
+func UpdateOrder(o *Order) error {
+    if err := validate(o); err != nil {
+        return fmt.Errorf("invalid order: %w", err)
+    }
+    return save(o)
+}
+
+// DeleteOrder drops an order, leaving a trace
+func DeleteOrder(o *Order) error {
+    audit(o)
+    return nil
+}
+
+// Render formats an order for display
+func Render(o *Order) string {
+    return fmt.Sprintf("invalid order: %v", o)
+}
+
+// HandleOrder creates an order and shows it
+func HandleOrder(o *Order) {
+    CreateOrder(o)
+    Render(o)
+}
+'''
+
+
+def related(rag, name, **options):
+    return [(qualified_name(r['metadata']), r['overlap'], r['shared_callees'], r['shared_callers'])
+            for r in rag.find_related(name, **options)]
+
+
+def test_graph():
+    calls = {
+        'a': [{'name': 'h', 'resolved': True, 'symbol_id': 'h'}, {'name': 'fmt.Errorf', 'resolved': False}],
+        'b': [{'name': 'h', 'resolved': True, 'symbol_id': 'h'}, {'name': 'fmt.Errorf', 'resolved': False},
+              {'name': 'b', 'resolved': True, 'symbol_id': 'b'}],
+        'c': [{'name': 'fmt.Errorf', 'resolved': False}],
+    }
+    callees, callers = call_graph(calls)
+    assert callees == {'a': {'h'}, 'b': {'h'}} and callers == {'h': {'a', 'b'}}, (callees, callers)
+    assert related_symbols(callees, callers, {'a'}) == [('b', {'h'}, set())]
+    assert related_symbols(callees, callers, {'c'}) == [], "calls by name only relate nothing"
+    assert related_symbols(callees, callers, {'a'}, kinds=['callers']) == []
+    try:
+        related_symbols(callees, callers, {'a'}, kinds=['siblings'])
+        assert False, "unknown relation accepted"
+    except ValueError:
+        pass
+    print("✅ Shared callees and callers come from resolved calls only")
+
+
+def test_find_related(rag):
+    assert related(rag, 'CreateOrder') == [
+        ('UpdateOrder', 2, ['save', 'validate'], []),
+        ('DeleteOrder', 1, ['audit'], []),
+        ('Render', 1, [], ['HandleOrder']),
+    ], related(rag, 'CreateOrder')
+    assert related(rag, 'validate') == [('save', 2, [], ['CreateOrder', 'UpdateOrder']),
+                                        ('audit', 1, [], ['CreateOrder'])], related(rag, 'validate')
+    assert [name for name, *_ in related(rag, 'CreateOrder', kinds=['callers'])] == ['Render']
+    assert len(related(rag, 'CreateOrder', n_results=1)) == 1
+    assert related(rag, 'Nothing') == [] and related(rag, 'HandleOrder') == []
+    print("✅ find_related ranks symbols by shared callees and callers")
+
+
+def test_split_symbols(workdir):
+    """The parts of a split function add up to one symbol"""
+    body = '\n'.join(f'    step{i}(o)' for i in range(120))
+    code = ORDERS + f'\nfunc Migrate(o *Order) {{\n    validate(o)\n{body}\n    save(o)\n}}\n'
+    rag = make_chroma_rag(workdir / "split", "split")
+    chunks = link_go_packages(GoChunker().extract_chunks(code, "orders/orders.go"))
+    rag.add_chunks_batch(split_oversized_chunks(chunks, max_tokens=200))
+    migrate = [r for r in rag._callable_chunks('go') if r['metadata']['name'] == 'Migrate']
+    assert len(migrate) > 1, "Migrate is split"
+    found = related(rag, 'Migrate')
+    assert found[:2] == [('CreateOrder', 2, ['save', 'validate'], []), ('UpdateOrder', 2, ['save', 'validate'], [])], found
+    assert ('Migrate', 2, ['save', 'validate'], []) in related(rag, 'UpdateOrder')
+    print("✅ Split functions are one symbol, found through their first part")
+
+
+def test_cli(rag):
+    import cli
+    args = argparse.Namespace(symbol='CreateOrder', by='callees', language='go', n_results=20)
+    cli.create_rag = lambda _: rag
+    assert cli.cmd_related(args) == 0
+    args.by = 'callees,siblings'
+    assert cli.cmd_related(args) == 1
+    print("✅ related --by validates the relations it counts")
+
+
+def main():
+    print("=" * 70)
+    print("RELATED SYMBOLS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_related_"))
+    rag = make_chroma_rag(workdir / "db", "related")
+    rag.add_chunks_batch(link_go_packages(GoChunker().extract_chunks(ORDERS, "orders/orders.go")))
+    
+    tests = [
+        test_graph,
+        lambda: test_find_related(rag),
+        lambda: test_split_symbols(workdir),
+        lambda: test_cli(rag),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,267 @@
+#!/usr/bin/env python3
+"""
+Test script for the cross-encoder reranking stage
+Runs the HTTP reranker against a fake reranking server and the search
+pipeline against a scripted reranker, so no model is needed
+"""
+
+import json
+import re
+import shutil
+import sys
+import tempfile
+import threading
+from http.server import BaseHTTPRequestHandler, HTTPServer
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from helpers import HashEmbedder
+from rag import ChromeRAGSystem
+from rerankers import HttpReranker, Reranker, RerankError, create_reranker
+from stores import SqliteStore
+
+
+def overlap(query, text):
+    """Query words found in the text: the fake model's relevance score"""
+    words = set(re.findall(r'[a-z]+', text.lower()))
+    return float(sum(word in words for word in re.findall(r'[a-z]+', query.lower())))
+
+
+class FakeReranker(BaseHTTPRequestHandler):
+    """Serves /rerank (TEI) and /v1/rerank (Cohere), best first; can fail requests with a 503 first"""
+    failures_left = 0
+    requests = []
+    
+    def do_POST(self):
+        body = json.loads(self.rfile.read(int(self.headers['Content-Length'])))
+        FakeReranker.requests.append((self.path, body))
+        if FakeReranker.failures_left:
+            FakeReranker.failures_left -= 1
+            return self._reply(503, {'error': 'model loading'})
+        
+        if self.path == '/rerank':
+            scores = [{'index': i, 'score': overlap(body['query'], text)} for i, text in enumerate(body['texts'])]
+            self._reply(200, sorted(scores, key=lambda e: e['score'], reverse=True))
+        elif self.path == '/v1/rerank':
+            scores = [{'index': i, 'relevance_score': overlap(body['query'], text)}
+                      for i, text in enumerate(body['documents'])]
+            self._reply(200, {'results': sorted(scores, key=lambda e: e['relevance_score'], reverse=True)})
+        else:
+            self._reply(404, {'error': 'not found'})
+    
+    def _reply(self, status, body):
+        data = json.dumps(body).encode()
+        self.send_response(status)
+        self.send_header('Content-Type', 'application/json')
+        self.send_header('Content-Length', str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+    
+    def log_message(self, *args):
+        pass
+
+
+class ScriptedReranker(Reranker):
+    """Scores by query word overlap and records every document it is asked to score"""
+    
+    def __init__(self, fail=False):
+        super().__init__('scripted')
+        self.fail = fail
+        self.scored = []
+    
+    def score(self, query, documents):
+        self.scored.extend(documents)
+        if self.fail:
+            raise RerankError("server down")
+        return [overlap(query, document) for document in documents]
+
+
+def start_server():
+    server = HTTPServer(('127.0.0.1', 0), FakeReranker)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    return server, f"http://127.0.0.1:{server.server_address[1]}"
+
+
+def reset(failures=0):
+    FakeReranker.failures_left = failures
+    FakeReranker.requests = []
+
+
+def sample_chunks():
+    chunks = [
+        CodeChunk(type='function', name=f'Handler{i}', content=f'func Handler{i}() {{ serve request {i} }}',
+                  filepath=f'api/handler{i}.go', language='go', line_start=1, line_end=3)
+        for i in range(30)
+    ]
+    chunks.append(CodeChunk(type='function', name='ValidateToken',
+                            content='func ValidateToken(token string) error { check session token expiry }',
+                            filepath='auth/token.go', language='go', line_start=1, line_end=5))
+    return chunks
+
+
+def build_rag(path, reranker):
+    rag = ChromeRAGSystem(collection_name='code', embedder=HashEmbedder(),
+                          store=SqliteStore(path, 'code'), reranker=reranker)
+    rag.add_chunks_batch(sample_chunks())
+    rag._build_keyword_index()
+    return rag
+
+
+def test_http_tei(url):
+    reset(failures=1)
+    reranker = HttpReranker(base_url=url, batch_size=2, backoff=0.01)
+    documents = ['print hello', 'parse url string', 'url']
+    scores = reranker.score('parse url', documents)
+    assert scores == [0.0, 2.0, 1.0], scores
+    assert [len(body['texts']) for _, body in FakeReranker.requests] == [2, 2, 1], FakeReranker.requests
+    
+    results = reranker.rerank('parse url', [{'content': d} for d in documents])
+    assert [r['content'] for r in results] == ['parse url string', 'url', 'print hello']
+    assert results[0]['rerank_score'] == 2.0
+    print("✅ TEI scores placed by index across batches, after a retried 503")
+
+
+def test_http_cohere(url):
+    reset()
+    reranker = HttpReranker(base_url=url, model_name='jina-reranker', api='cohere')
+    assert reranker.score('session token', ['token', 'session token', 'other']) == [1.0, 2.0, 0.0]
+    path, body = FakeReranker.requests[0]
+    assert path == '/v1/rerank' and body['model'] == 'jina-reranker' and body['top_n'] == 3, body
+    
+    unreachable = HttpReranker(base_url='http://127.0.0.1:9', max_retries=1, backoff=0.01, timeout=1)
+    try:
+        unreachable.score('q', ['x'])
+        assert False, "unreachable server not reported"
+    except RerankError as e:
+        assert 'Cannot reach the reranker' in str(e), str(e)
+    try:
+        HttpReranker(api='openai')
+        assert False, "unknown API accepted"
+    except ValueError:
+        pass
+    print("✅ Cohere-style API supported; dead servers and unknown APIs reported")
+
+
+def test_factory():
+    assert create_reranker('none') is None
+    reranker = create_reranker('http', url='http://reranker:80/', model_name='bge')
+    assert isinstance(reranker, HttpReranker) and reranker.base_url == 'http://reranker:80'
+    assert reranker.model_name == 'bge'
+    print("✅ 'none' disables reranking, 'http' builds the server client")
+
+
+def test_candidates_only(path):
+    reranker = ScriptedReranker()
+    rag = build_rag(path / 'candidates.db', reranker)
+    results = rag.retrieve_context('validate session token', n_results=3, rerank_candidates=8)
+    assert len(reranker.scored) == 8, f"{len(reranker.scored)} documents scored"
+    assert results[0]['metadata']['name'] == 'ValidateToken', [r['metadata']['name'] for r in results]
+    assert results[0]['rerank_score'] == 2.0, results[0]['rerank_score']
+    scores = [r['rerank_score'] for r in results]
+    assert scores == sorted(scores, reverse=True), scores
+    
+    # Never fewer candidates than results
+    reranker.scored = []
+    rag.retrieve_context('validate session token', n_results=5, rerank_candidates=2)
+    assert len(reranker.scored) == 5, f"{len(reranker.scored)} documents scored"
+    print("✅ Only the top candidates are reranked, and they come back in rerank order")
+
+
+def test_candidate_k(path):
+    reranker = ScriptedReranker()
+    rag = build_rag(path / 'candidate_k.db', reranker)
+    fetched = []
+    query = rag.collection.query
+    
+    def recording_query(**kwargs):
+        fetched.append(kwargs['n_results'])
+        return query(**kwargs)
+    
+    rag.collection.query = recording_query
+    results = rag.retrieve_context('validate session token', n_results=3, candidate_k=12)
+    assert fetched == [12], fetched
+    assert len(reranker.scored) == 12, "the whole pool is reranked unless rerank_candidates says otherwise"
+    assert len(results) == 3 and results[0]['metadata']['name'] == 'ValidateToken'
+    
+    reranker.scored, fetched[:] = [], []
+    rag.retrieve_context('validate session token', n_results=3, candidate_k=12, rerank_candidates=6)
+    assert fetched == [12] and len(reranker.scored) == 6, (fetched, len(reranker.scored))
+    
+    saved = CONFIG.candidate_k
+    CONFIG.candidate_k = 9
+    try:
+        fetched[:] = []
+        list(rag.iter_context('serve request', n_results=4, rerank=False))
+        assert fetched == [9], fetched
+    finally:
+        CONFIG.candidate_k = saved
+    
+    try:
+        rag.retrieve_context('validate session token', n_results=5, candidate_k=4)
+        assert False, "a pool smaller than the results asked for should be refused"
+    except ValueError:
+        pass
+    print("✅ candidate_k sizes the pool each retriever fetches for reranking")
+
+
+def test_skip_and_fallback(path):
+    reranker = ScriptedReranker()
+    rag = build_rag(path / 'skip.db', reranker)
+    plain = rag.retrieve_context('validate session token', n_results=3, rerank=False)
+    assert not reranker.scored and all('rerank_score' not in r for r in plain)
+    
+    reranker.fail = True
+    fallback = rag.retrieve_context('validate session token', n_results=3)
+    assert reranker.scored, "reranker not called"
+    assert [r['id'] for r in fallback] == [r['id'] for r in plain]
+    
+    # MMR diversifies by the rerank score when there is one
+    reranker.fail = False
+    diverse = rag.retrieve_context('validate session token', n_results=3, mmr_lambda=0.5)
+    assert diverse[0]['metadata']['name'] == 'ValidateToken'
+    
+    unranked = build_rag(path / 'none.db', None)
+    assert [r['id'] for r in unranked.retrieve_context('validate session token', n_results=3)] == \
+        [r['id'] for r in plain]
+    print("✅ Reranking can be skipped, and a failing reranker keeps the fused order")
+
+
+def main():
+    print("=" * 70)
+    print("RERANKER TEST")
+    print("=" * 70)
+    
+    server, url = start_server()
+    workdir = Path(tempfile.mkdtemp(prefix="reranker_"))
+    tests = [
+        lambda: test_http_tei(url),
+        lambda: test_http_cohere(url),
+        test_factory,
+        lambda: test_candidates_only(workdir),
+        lambda: test_candidate_k(workdir),
+        lambda: test_skip_and_fallback(workdir),
+    ]
+    failed = 0
+    try:
+        for test in tests:
+            try:
+                test()
+            except AssertionError as e:
+                failed += 1
+                print(f"❌ Test failed: {e}")
+    finally:
+        server.shutdown()
+        shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,187 @@
+#!/usr/bin/env python3
+"""
+Test script for search output formats
+Text output shows each result under a file:line header with a snippet around
+the lines matching the query; json and jsonl print the records of the HTTP
+server's /search, with nothing else on stdout.
+Uses a small deterministic embedder
+"""
+
+import argparse
+import contextlib
+import io
+import json
+import re
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from chunkers import GoChunker
+from helpers import make_rag
+from utils.logger import console
+from utils.result_format import matching_lines, result_record, snippet
+
+SOURCE = '''package session
+
+// Expire drops the sessions idle for longer than the timeout
+func Expire(store *Store, timeout int) int {
+    dropped := 0
+    for id, s := range store.sessions {
+        if s.idle > timeout {
+            delete(store.sessions, id)
+            dropped++
+        }
+    }
+    return dropped
+}
+
+// Render draws the account page
+func Render(a *Account) string {
+    return page(a)
+}
+'''
+
+RECORD_KEYS = ['id', 'score', 'vector_score', 'bm25_score', 'rerank_score', 'relevance', 'filepath', 'repo',
+               'name', 'type', 'language', 'kind', 'generated', 'last_change', 'line_start', 'line_end', 'byte_start',
+               'byte_end', 'citation', 'content', 'surrounding', 'neighbors', 'duplicates', 'highlights']
+
+
+def search_args(**options):
+    defaults = dict(query="session timeout", saved=None, param=None, n_results=2, lexical_weight=0.5, fusion=None, rrf_k=None, fusion_normalization=None, language=None, type=None,
+                    path=None, repo=None, uses=None, min_score=None, mmr=None, exclude_tests=False,
+                    exclude_text=False, filter=None, boost=None, depth_penalty=None, recency_weight=None, scope=None, no_rerank=False, rerank_candidates=None, candidate_k=None, vector_index=None, models=None,
+                    exclude_generated=False, generated_weight=None, query_embedder=None, offset=None, group_by=None,
+                    with_surrounding=False, with_neighbors=None, expand=False, pack=None, merge_files=False, show_scores=False, full=False,
+                    snippet_lines=cli.SNIPPET_LINES, format='text', quiet=False, explain=False,
+                    verbose=False)
+    defaults.update(options)
+    return argparse.Namespace(**defaults)
+
+
+def run_search(rag, **options):
+    """stdout and stderr of `search` with the given options"""
+    saved_create, saved_file = cli.create_rag, console.file
+    cli.create_rag = lambda args: rag
+    stdout, stderr = io.StringIO(), io.StringIO()
+    console.file = stdout
+    try:
+        with contextlib.redirect_stdout(stdout), contextlib.redirect_stderr(stderr):
+            code = cli.cmd_search(search_args(**options))
+    finally:
+        cli.create_rag, console.file = saved_create, saved_file
+    assert code == 0, f"search exited with {code}"
+    return stdout.getvalue(), stderr.getvalue()
+
+
+def test_snippet():
+    content = '\n'.join(f"line {i}" for i in range(40))
+    assert matching_lines(content, {'30'}) == [30]
+    assert matching_lines("IdleTimeout := 5\nx := 1", {'timeout'}) == [0], "identifier parts were not matched"
+    first, text = snippet(content, [30], 12)
+    assert first == 27 and text.splitlines()[0] == 'line 27' and len(text.splitlines()) == 12
+    assert snippet(content, [39], 12)[0] == 28, "the snippet ran past the end"
+    assert snippet(content, [], 12)[0] == 0
+    assert snippet("a\nb", [1], 12) == (0, "a\nb")
+    print("✅ Snippets start a little above the first matching line and stay inside the code")
+
+
+def test_json(rag):
+    out, _ = run_search(rag, format='json')
+    document = json.loads(out)
+    assert document['query'] == "session timeout" and len(document['results']) == 2
+    top = document['results'][0]
+    assert list(top) == RECORD_KEYS, list(top)
+    assert top['name'] == 'Expire' and top['citation'] == f"session.go#L{top['line_start']}-L{top['line_end']}"
+    
+    out, _ = run_search(rag, format='jsonl', quiet=True)
+    records = [json.loads(line) for line in out.splitlines()]
+    assert [r['name'] for r in records] == [r['name'] for r in document['results']]
+    assert records[0] == result_record(rag.retrieve_context("session timeout", n_results=2, lexical_weight=0.5)[0])
+    
+    out, _ = run_search(rag, query="zzz", format='jsonl', min_score=0.99)
+    assert out == "", out
+    
+    out, _ = run_search(rag, format='json', n_results=1, offset=1)
+    page = json.loads(out)
+    assert page['offset'] == 1 and page['total'] >= 2, page
+    assert [r['name'] for r in page['results']] == [document['results'][1]['name']], "--offset skips the first page"
+    
+    out, _ = run_search(rag, format='json', group_by='file')
+    grouped = json.loads(out)
+    assert grouped['results'] == document['results'], "--group-by leaves the results as they were"
+    assert [(g['group'], g['positions']) for g in grouped['groups']] == [('session.go', [0, 1])], grouped['groups']
+    print("✅ json and jsonl print the /search records and nothing else on stdout")
+
+
+def test_text(rag):
+    out, err = run_search(rag, snippet_lines=4)
+    assert "Semantic Code Search" in out and "Found 2 results" in err, "status lines go to stderr"
+    plain = re.sub(r'\[/?[a-z ]+\]', '', out)
+    assert re.search(r"1\. session\.go:4-13 +Expire function go +score", plain), plain
+    assert "if s.idle > timeout {" in out and "return dropped" not in out, "the snippet is not around the match"
+    assert "more lines (use --full" in out
+    
+    out, err = run_search(rag, quiet=True, full=True)
+    assert "Semantic Code Search" not in out and "Found 2 results" not in out + err
+    assert "return dropped" in out and "more lines" not in out
+    
+    out, _ = run_search(rag, quiet=True, with_neighbors='bodies')
+    plain = re.sub(r'\[/?[a-z ]+\]', '', out)
+    assert "After: Render function lines 16-18" in plain and "return page(a)" in out, plain
+    
+    out, _ = run_search(rag, quiet=True, group_by='file')
+    plain = re.sub(r'\[/?[a-z ]+\]', '', out)
+    assert re.search(r"1\. session\.go \(file\)  2 results  score [0-9.]+ \(best [0-9.]+\)\n\n?1\. session\.go:4-13",
+                     plain), plain
+    print("✅ Text output shows a file:line header and a snippet; --quiet leaves only the results")
+
+
+def test_verbose(rag):
+    rag.query_cache.clear()
+    out, err = run_search(rag, format='json', verbose=True)
+    assert len(json.loads(out)['results']) == 2
+    assert "Time (vector_search)" in err and "Time (keyword_search)" in err, err
+    
+    out, _ = run_search(rag, pack=200, verbose=True, quiet=True)
+    assert re.search(r"Time \(pack\)\W*1 calls", out), out
+    print("✅ --verbose prints the stage timings, on stderr beside json output")
+
+
+def main():
+    print("=" * 70)
+    print("SEARCH OUTPUT FORMAT TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_format_"))
+    rag = make_rag(workdir, "formats")
+    rag.add_chunks_batch(GoChunker().extract_chunks(SOURCE, "session.go"))
+    rag._build_keyword_index()
+    
+    tests = [
+        test_snippet,
+        lambda: test_json(rag),
+        lambda: test_text(rag),
+        lambda: test_verbose(rag),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,161 @@
+#!/usr/bin/env python3
+"""
+Test script for result grouping
+Results grouped by file or by owning type, groups in the order of their best
+result, with the results themselves left as the search ranked them
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+import threading
+import urllib.error
+import urllib.request
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_chroma_rag
+from indexer import ChromeIndexer
+from server import RAGServer
+from utils.result_groups import describe_group, group_results
+from utils.state_manager import StateManager
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+
+def make_result(name, filepath, score, chunk_type='method', parent=None, repo=None, language='go'):
+    metadata = {'name': name, 'filepath': filepath, 'type': chunk_type, 'language': language}
+    if parent:
+        metadata['parent'] = parent
+    if repo:
+        metadata['repo'] = repo
+    return {'id': f"{filepath}:{name}", 'metadata': metadata, 'rrf_score': score, 'content': ''}
+
+
+RESULTS = [
+    make_result('Authenticate', 'auth/admin.go', 0.9, parent='AdminUser'),
+    make_result('Hash', 'auth/hash.go', 0.8, chunk_type='function'),
+    make_result('AdminUser', 'auth/user.go', 0.7, chunk_type='struct'),
+    make_result('Logout', 'auth/admin.go', 0.6, parent='AdminUser'),
+    make_result('Compare', 'auth/hash.go', 0.5, chunk_type='function'),
+    make_result('Authenticate', 'auth/admin.go', 0.4, parent='AdminUser', repo='mirror'),
+]
+
+
+def request(url, body, headers=None):
+    req = urllib.request.Request(url + '/search', data=json.dumps(body).encode(), method='POST',
+                                 headers=dict({'Content-Type': 'application/json'}, **(headers or {})))
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            return response.status, json.loads(response.read())
+    except urllib.error.HTTPError as e:
+        return e.code, json.loads(e.read())
+
+
+def test_group_by_type():
+    groups = group_results(RESULTS, 'type')
+    assert [(g['by'], g['group'], g['repo']) for g in groups] == [
+        ('type', 'AdminUser', None), ('file', 'auth/hash.go', None), ('type', 'AdminUser', 'mirror')], groups
+    admin = groups[0]
+    assert admin['positions'] == [0, 2, 3] and admin['count'] == 3, "the type and its methods, in rank order"
+    assert admin['files'] == ['auth/admin.go', 'auth/user.go'], admin['files']
+    assert abs(admin['score'] - 2.2) < 1e-9 and admin['best_score'] == 0.9, admin
+    assert groups[1]['positions'] == [1, 4], "functions outside a type are grouped by file"
+    assert groups[2]['files'] == ['mirror:auth/admin.go'], "another repository's type is its own group"
+    print("✅ By type, a type's methods go with it across files, and free functions by file")
+
+
+def test_group_by_file():
+    groups = group_results(RESULTS, 'file')
+    assert [g['group'] for g in groups] == ['auth/admin.go', 'auth/hash.go', 'auth/user.go', 'mirror:auth/admin.go']
+    assert [g['positions'] for g in groups] == [[0, 3], [1, 4], [2], [5]], groups
+    assert all(g['by'] == 'file' for g in groups)
+    positions = sorted(p for g in groups for p in g['positions'])
+    assert positions == list(range(len(RESULTS))), "every result is in exactly one group"
+    assert group_results([], 'file') == []
+    print("✅ By file, groups follow the rank of their best result and cover every result once")
+
+
+def test_scores_and_errors():
+    unscored = [dict(result, rrf_score=None) for result in RESULTS[:2]]
+    groups = group_results(unscored, 'file')
+    assert groups[0]['score'] is None and groups[0]['best_score'] is None, groups
+    assert describe_group(groups[0], 1) == "1. auth/admin.go (file)  1 result"
+    header = describe_group(group_results(RESULTS, 'type')[0])
+    assert header == "AdminUser (type)  3 results  score 2.2000 (best 0.9000)  in 2 files", header
+    mmr = [dict(RESULTS[0], mmr_score=0.3), dict(RESULTS[3], mmr_score=0.2)]
+    assert abs(group_results(mmr, 'type')[0]['score'] - 0.5) < 1e-9, "groups add up the scores results rank by"
+    try:
+        group_results(RESULTS, 'package')
+    except ValueError as e:
+        assert 'Unknown grouping' in str(e), e
+    else:
+        raise AssertionError("an unknown mode was accepted")
+    print("✅ Group scores add up the ranking scores, and an unknown mode is refused")
+
+
+def test_server(rag):
+    server = RAGServer(('127.0.0.1', 0), rag)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        search = {'query': 'authenticate admin user logout permission', 'top_k': 8}
+        plain = request(url, search)[1]
+        status, body = request(url, dict(search, group_by='type'))
+        assert status == 200 and 'groups' not in plain, plain
+        assert body['results'] == plain['results'], "grouping does not change the results"
+        assert body['groups'][0]['positions'][0] == 0, "the first group holds the top result"
+        firsts = [group['positions'][0] for group in body['groups']]
+        assert firsts == sorted(firsts), firsts
+        admin = next(group for group in body['groups'] if group['group'] == 'AdminUser')
+        names = {body['results'][i]['name'] for i in admin['positions']}
+        assert {'AdminUser', 'Logout'} <= names, "the struct and its method"
+        interface = next(group for group in body['groups'] if group['group'] == 'Authenticator')
+        assert {body['results'][i]['name'] for i in interface['positions']} >= {'Authenticator', 'Authenticate'}
+        
+        status, page = request(url, dict(search, top_k=3, offset=3, group_by='file'))
+        assert status == 200 and sorted(p for g in page['groups'] for p in g['positions']) == [0, 1, 2], page
+        for body, headers, message in ((dict(search, group_by='package'), None, "'group_by' must be one of"),
+                                       (dict(search, group_by='type'), {'Accept': 'application/x-ndjson'},
+                                        'not streamed')):
+            status, error = request(url, body, headers)
+            assert status == 400 and message in error['error'], (status, error)
+    finally:
+        server.shutdown()
+    print("✅ /search adds groups with group_by, the results unchanged, and refuses bad or streamed ones")
+
+
+def main():
+    print("=" * 70)
+    print("RESULT GROUPS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_groups_"))
+    source = workdir / "src"
+    source.mkdir()
+    shutil.copy(SAMPLES / "complex.go", source / "complex.go")
+    rag = make_chroma_rag(workdir / "db", "groups")
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db"))).index_directory(str(source),
+                                                                                            parallel=False)
+    
+    tests = [test_group_by_type, test_group_by_file, test_scores_and_errors, lambda: test_server(rag)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,224 @@
+#!/usr/bin/env python3
+"""
+Test script for the result limits
+Searches asking for more results or larger candidate pools than max_top_k and
+max_candidate_k are rejected by the library and answered with a 400 by the
+servers; the HTTP server also refuses bodies above max_response_bytes and
+filters too long or too deeply nested
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+import threading
+import urllib.error
+import urllib.request
+from pathlib import Path
+from types import SimpleNamespace
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from cli import setting_problems
+from config import CONFIG
+from grpc_server import search_arguments
+from helpers import make_chroma_rag
+from indexer import ChromeIndexer
+from server import RAGServer
+from utils.result_limits import ResultLimitError, check_filter_limits, check_response_size, check_result_limits
+from utils.state_manager import StateManager
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+
+class Limits:
+    """Sets result limits for the duration of a with block"""
+    
+    def __init__(self, **limits):
+        self.limits = limits
+    
+    def __enter__(self):
+        self.saved = {key: getattr(CONFIG, key) for key in self.limits}
+        for key, value in self.limits.items():
+            setattr(CONFIG, key, value)
+    
+    def __exit__(self, *exc):
+        for key, value in self.saved.items():
+            setattr(CONFIG, key, value)
+
+
+def request(url, path, body=None, accept='application/json'):
+    data = json.dumps(body).encode() if body is not None else None
+    req = urllib.request.Request(url + path, data=data, method='POST' if data is not None else 'GET',
+                                 headers={'Content-Type': 'application/json', 'Accept': accept})
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            if accept == 'application/x-ndjson':
+                return response.status, [json.loads(line) for line in response if line.strip()]
+            return response.status, json.loads(response.read())
+    except urllib.error.HTTPError as e:
+        return e.code, json.loads(e.read())
+
+
+def raises(call, text):
+    try:
+        call()
+    except ResultLimitError as e:
+        assert text in str(e), e
+        return
+    raise AssertionError(f"expected a ResultLimitError mentioning {text!r}")
+
+
+def test_checks():
+    with Limits(max_top_k=10, max_candidate_k=50, max_response_bytes=100):
+        check_result_limits(10, 50, 50)
+        raises(lambda: check_result_limits(11), 'n_results (11) is above the limit of 10 results (max_top_k)')
+        raises(lambda: check_result_limits(5, candidate_k=51), 'candidate_k (51)')
+        raises(lambda: check_result_limits(5, rerank_candidates=51), 'rerank_candidates (51)')
+        check_response_size(100)
+        raises(lambda: check_response_size(101), 'max_response_bytes')
+    with Limits(max_top_k=0, max_candidate_k=None, max_response_bytes=0):
+        check_result_limits(10 ** 6, 10 ** 6, 10 ** 6)
+        check_response_size(10 ** 9)
+    check_filter_limits('language=go AND NOT kind=test')
+    check_filter_limits({'and': [{'language': ['go', 'rust']}, {'not': {'kind': 'test'}}]})
+    raises(lambda: check_filter_limits('language=go OR ' * 400), "'filter' is longer than the limit")
+    deep = {'language': 'go'}
+    for _ in range(40):
+        deep = {'not': deep}
+    raises(lambda: check_filter_limits(deep), "'filter' nests deeper than the limit of 32 levels")
+    assert issubclass(ResultLimitError, ValueError), "callers catching ValueError keep working"
+    print("✅ Counts and sizes above a limit raise ResultLimitError; 0 or None disables a limit")
+
+
+def test_library(rag):
+    with Limits(max_top_k=3, max_candidate_k=20):
+        assert len(rag.retrieve_context("authenticate", n_results=3)) == 3
+        raises(lambda: rag.retrieve_context("authenticate", n_results=4), 'max_top_k')
+        raises(lambda: rag.retrieve_context("authenticate", n_results=3, candidate_k=21), 'candidate_k')
+        raises(lambda: rag.retrieve_context("authenticate", n_results=3, rerank_candidates=21), 'rerank_candidates')
+        raises(lambda: list(rag.iter_context("authenticate", n_results=4)), 'max_top_k')
+        raises(lambda: rag.retrieve_page("authenticate", page_size=4), 'page_size (4)')
+        
+        # Pages are cut from a deeper ranking than max_top_k; only the page size counts
+        first = rag.retrieve_page("authenticate", page_size=3)
+        second = rag.retrieve_page("authenticate", page_size=3, cursor=first['next_cursor'])
+        assert len(first['results']) == len(second['results']) == 3, (first, second)
+        
+        chunk_id = first['results'][0]['id']
+        assert rag.similar_to(chunk_id, k=3) is not None
+        raises(lambda: rag.similar_to(chunk_id, k=4), 'k (4)')
+    print("✅ retrieve_context, iter_context, retrieve_page and similar_to reject searches over the limits")
+
+
+def test_server(url):
+    with Limits(max_top_k=3, max_candidate_k=20):
+        status, body = request(url, '/search', {'query': 'authenticate', 'top_k': 3})
+        assert status == 200 and len(body['results']) == 3, body
+        status, body = request(url, '/search', {'query': 'authenticate', 'top_k': 4})
+        assert status == 400 and "'top_k' (4) is above the limit of 3 results" in body['error'], body
+        status, body = request(url, '/search', {'query': 'authenticate', 'top_k': 3, 'candidate_k': 50})
+        assert status == 400 and 'max_candidate_k' in body['error'], body
+        status, body = request(url, '/search', {'query': 'authenticate', 'top_k': 4},
+                               accept='application/x-ndjson')
+        assert status == 400 and 'max_top_k' in body['error'], "a stream is refused before it starts"
+    
+    deep = {'language': 'go'}
+    for _ in range(500):
+        deep = {'not': deep}
+    for hostile in ('(' * 2000 + 'language=go' + ')' * 2000, 'NOT ' * 1000 + 'language=go', deep):
+        for path, body in (('/search', {'query': 'authenticate', 'filter': hostile}),
+                           ('/similar', {'id': 'x', 'filter': hostile})):
+            status, reply = request(url, path, body)
+            assert status == 400 and ('too deeply' in reply['error'] or 'deeper' in reply['error']), (path, reply)
+    print("✅ POST /search over max_top_k or max_candidate_k, or with a filter too deeply nested, is a 400")
+
+
+def test_response_size(url):
+    query = {'query': 'authenticate', 'top_k': 5}
+    status, body = request(url, '/search', query)
+    assert status == 200, body
+    size = len(json.dumps(body).encode('utf-8'))
+    with Limits(max_response_bytes=size - 1):
+        status, body = request(url, '/search', query)
+        assert status == 400 and 'max_response_bytes' in body['error'], body
+    
+    status, lines = request(url, '/search', query, accept='application/x-ndjson')
+    assert status == 200 and len(lines) == 5, lines
+    first = len(json.dumps(lines[0]).encode('utf-8')) + 1
+    with Limits(max_response_bytes=first):
+        status, lines = request(url, '/search', query, accept='application/x-ndjson')
+    assert status == 200 and len(lines) == 2, lines
+    assert 'error' not in lines[0] and 'max_response_bytes' in lines[1]['error'], lines
+    print("✅ A reply above max_response_bytes is a 400; a stream past it ends with an error line")
+
+
+def test_grpc_arguments():
+    def search_request(**fields):
+        values = dict(query='authenticate', top_k=3, candidate_k=0, rerank_candidates=0,
+                      vector_index='', with_neighbors='', scope=[], languages=[], kinds=[])
+        values.update(fields)
+        return SimpleNamespace(HasField=lambda field: False, **values)
+    
+    with Limits(max_top_k=3, max_candidate_k=20):
+        for fields, text in (({'top_k': 4}, "'top_k' (4)"), ({'candidate_k': 21}, 'candidate_k (21)'),
+                             ({'rerank_candidates': 21}, 'rerank_candidates (21)')):
+            try:
+                search_arguments(search_request(**fields))
+            except ValueError as e:
+                assert text in str(e), e
+            else:
+                raise AssertionError(f"{fields} was accepted")
+    print("✅ gRPC searches over the limits are invalid arguments")
+
+
+def test_validate():
+    with Limits(max_top_k=-1, candidate_k=500, max_candidate_k=100):
+        problems = setting_problems()
+    assert any(p.startswith('max_top_k: must not be negative') for p in problems), problems
+    assert any(p.startswith('candidate_k: 500 is above max_candidate_k (100)') for p in problems), problems
+    with Limits(max_top_k=0, max_response_bytes=None):
+        assert not [p for p in setting_problems() if p.startswith('max_')], setting_problems()
+    print("✅ config validate reports negative limits and a candidate_k above max_candidate_k")
+
+
+def main():
+    print("=" * 70)
+    print("RESULT LIMITS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_limits_"))
+    source = workdir / "src"
+    source.mkdir()
+    shutil.copy(SAMPLES / "complex.go", source / "complex.go")
+    
+    rag = make_chroma_rag(workdir / "db", "test_limits")
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db"))).update_index(str(source), parallel=False)
+    
+    server = RAGServer(('127.0.0.1', 0), rag, source_path=str(source))
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    
+    tests = [
+        test_checks, lambda: test_library(rag), lambda: test_server(url), lambda: test_response_size(url),
+        test_grpc_arguments, test_validate
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    server.shutdown()
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,203 @@
+#!/usr/bin/env python3
+"""
+Test script for paged search results
+Pages are slices of one ranking per search and index version: walking them
+by offset or by cursor returns every result once, a cursor only fits the
+search it came from, and a change to the index makes it stale. Covers
+retrieve_page and the offset/cursor fields of POST /search. Uses a small
+deterministic embedder
+"""
+
+import json
+import re
+import shutil
+import sys
+import tempfile
+import threading
+import urllib.error
+import urllib.request
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import make_rag
+from server import NDJSON_TYPE, RAGServer
+from utils.result_pages import CursorError, StaleCursorError, decode_cursor, encode_cursor, search_fingerprint
+
+
+def handler(n):
+    language = 'go' if n % 3 else 'python'
+    return CodeChunk(type='function', name=f'HandleRequest{n}',
+                     content=f'func HandleRequest{n}(r *Request) error {{ return parse(r, "request {n}") }}',
+                     filepath=f'handlers/h{n}.go', language=language, line_start=1, line_end=1)
+
+
+def build_rag(workdir, name):
+    rag = make_rag(workdir, name)
+    rag.add_chunks_batch([handler(n) for n in range(23)])
+    rag._build_keyword_index()
+    return rag
+
+
+def request(url, body, headers=None):
+    req = urllib.request.Request(url + '/search', data=json.dumps(body).encode(), method='POST',
+                                 headers=dict({'Content-Type': 'application/json'}, **(headers or {})))
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            return response.status, json.loads(response.read())
+    except urllib.error.HTTPError as e:
+        return e.code, json.loads(e.read())
+
+
+def test_cursors():
+    search = search_fingerprint("parse a URL", {'languages': ['go'], 'exclude_tests': False, 'rerank': None})
+    assert search == search_fingerprint("  parse a url ", {'languages': ['go']}), "defaults do not count"
+    assert search != search_fingerprint("parse a url", {'languages': ['rust']})
+    
+    cursor = encode_cursor(search, 'a1.4', 20)
+    assert re.fullmatch(r'[A-Za-z0-9_-]+', cursor), cursor
+    assert decode_cursor(cursor, search, 'a1.4') == 20
+    for bad in ('', 'not a cursor!', encode_cursor(search, 'a1.4', -1)[:-2], 'W10'):
+        try:
+            decode_cursor(bad, search, 'a1.4')
+            assert False, f"no error for {bad!r}"
+        except CursorError as e:
+            assert not isinstance(e, StaleCursorError)
+    try:
+        decode_cursor(cursor, search_fingerprint("other", {}), 'a1.4')
+        assert False, "a cursor of another search, but no error"
+    except CursorError as e:
+        assert 'another search' in str(e)
+    try:
+        decode_cursor(cursor, search, 'a1.5')
+        assert False, "a stale cursor, but no error"
+    except StaleCursorError as e:
+        assert 'index changed' in str(e)
+    print("✅ Cursors name their search, index version and offset")
+
+
+def test_pages(workdir):
+    rag = build_rag(workdir, "pages")
+    rankings = []
+    retrieve_context = rag.retrieve_context
+    
+    def counting(*args, **kwargs):
+        rankings.append(kwargs.get('n_results'))
+        return retrieve_context(*args, **kwargs)
+    rag.retrieve_context = counting
+    
+    # Walk by cursor: every result once, all from one ranking
+    seen, cursor = [], None
+    while True:
+        page = rag.retrieve_page("handle request", page_size=5, cursor=cursor, languages=['go'])
+        assert page['offset'] == len(seen) and page['total'] == 15, page
+        seen.extend(r['id'] for r in page['results'])
+        cursor = page['next_cursor']
+        if cursor is None:
+            break
+    assert len(seen) == 15 and len(set(seen)) == 15, seen
+    assert rankings == [200], f"pages were ranked {len(rankings)} times"
+    
+    # Offsets slice the same ranking; with the page cache evicted, it is ranked again in the same order
+    rag.page_cache.clear()
+    by_offset = [r['id'] for offset in range(0, 15, 4)
+                 for r in rag.retrieve_page("handle request", page_size=4, offset=offset, languages=['go'])['results']]
+    assert by_offset == seen and len(rankings) == 2
+    assert rag.retrieve_page("handle request", page_size=4, offset=40, languages=['go']) == \
+        {'results': [], 'offset': 40, 'total': 15, 'next_cursor': None}
+    
+    first = rag.retrieve_page("handle request", page_size=5, languages=['go'])
+    for kwargs, error in (({'languages': ['python']}, CursorError), ({'languages': ['go'], 'offset': 5}, ValueError)):
+        try:
+            rag.retrieve_page("handle request", page_size=5, cursor=first['next_cursor'], **kwargs)
+            assert False, f"no error for {kwargs}"
+        except error:
+            pass
+    for kwargs in ({'page_size': 0}, {'offset': -1}):
+        try:
+            rag.retrieve_page("handle request", **kwargs)
+            assert False, f"no error for {kwargs}"
+        except ValueError:
+            pass
+    
+    # A change to the index: the old cursor is stale, and a new walk sees the new chunk
+    rag.add_chunks_batch([handler(23)])
+    rag._build_keyword_index()
+    try:
+        rag.retrieve_page("handle request", page_size=5, cursor=first['next_cursor'], languages=['go'])
+        assert False, "a cursor from before the index changed, but no error"
+    except StaleCursorError:
+        pass
+    assert rag.retrieve_page("handle request", page_size=5, languages=['go'])['total'] == 16
+    assert rag.retrieve_page("handle request", page_size=5, candidate_k=8)['total'] == 8, \
+        "pages reach as deep as the candidate pool"
+    print("✅ Pages by cursor or offset cover one ranking once, and cursors go stale when the index changes")
+
+
+def test_server(workdir):
+    rag = build_rag(workdir, "pages_server")
+    server = RAGServer(('127.0.0.1', 0), rag)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        status, body = request(url, {'query': 'handle request', 'top_k': 10, 'offset': 0})
+        assert status == 200 and body['offset'] == 0 and body['total'] == 23, body
+        names = [r['name'] for r in body['results']]
+        status, body = request(url, {'query': 'handle request', 'top_k': 10, 'cursor': body['next_cursor']})
+        assert status == 200 and body['offset'] == 10, body
+        names += [r['name'] for r in body['results']]
+        last = request(url, {'query': 'handle request', 'top_k': 10, 'cursor': body['next_cursor']})[1]
+        assert last['next_cursor'] is None and len(last['results']) == 3
+        names += [r['name'] for r in last['results']]
+        assert sorted(names) == sorted(f'HandleRequest{n}' for n in range(23)), names
+        
+        plain = request(url, {'query': 'handle request', 'top_k': 10})[1]
+        assert set(plain) == {'query', 'results'}, "searches without offset or cursor are not paged"
+        
+        cursor = request(url, {'query': 'handle request', 'top_k': 5, 'offset': 0})[1]['next_cursor']
+        status, body = request(url, {'query': 'handle request', 'top_k': 5, 'cursor': cursor, 'kinds': ['type']})
+        assert status == 400 and 'another search' in body['error'], body
+        for bad in ({'offset': -1}, {'offset': '5'}, {'cursor': 7}):
+            assert request(url, dict({'query': 'handle request'}, **bad))[0] == 400, bad
+        status, body = request(url, {'query': 'handle request', 'offset': 0}, {'Accept': NDJSON_TYPE})
+        assert status == 400 and 'not streamed' in body['error'], body
+        
+        rag.add_chunks_batch([handler(23)])
+        status, body = request(url, {'query': 'handle request', 'top_k': 5, 'cursor': cursor})
+        assert status == 409 and 'index changed' in body['error'], body
+    finally:
+        server.shutdown()
+        server.server_close()
+    print("✅ POST /search pages by offset and cursor, with a 409 for stale cursors")
+
+
+def main():
+    print("=" * 70)
+    print("RESULT PAGES TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_result_pages_"))
+    tests = [
+        test_cursors,
+        lambda: test_pages(workdir),
+        lambda: test_server(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
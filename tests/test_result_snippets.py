@@ -0,0 +1,203 @@
+#!/usr/bin/env python3
+"""
+Test script for result snippets
+With snippet_lines a result's record carries the window of lines holding
+most of its matches (or its top, signature first) instead of its content,
+with highlights relative to the window and the GET /chunk path of the whole
+chunk. Covers with_snippet, rag.search and get_chunk, and POST /search and
+GET /chunk. Uses a small deterministic embedder
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+import threading
+import urllib.error
+import urllib.request
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import make_rag
+from server import NDJSON_TYPE, RAGServer
+from utils.match_highlights import highlight_spans
+from utils.result_format import chunk_path, result_record, snippet_window, with_snippet
+from utils.result_types import Snippet, json_schema
+
+# 40 lines: a doc comment, the signature, then steps; the token refresh is on lines 28-30
+REFRESH = '\n'.join(
+    ['// Refresh renews a session.', '// It retries on transient errors.',
+     'func (s *Session) Refresh(ctx context.Context) error {']
+    + [f'    step{n}(ctx)' for n in range(3, 27)]
+    + ['    token, err := s.client.RefreshToken(ctx)', '    if err != nil { return err }',
+       '    s.token = token // keep the refreshed token']
+    + [f'    audit{n}(ctx)' for n in range(30, 38)]
+    + ['    return nil', '}'])
+
+
+def refresh_result(highlights):
+    return {'id': 'auth/session.go:Session.Refresh:10', 'content': REFRESH, 'highlights': highlights,
+            'metadata': {'filepath': 'auth/session.go', 'name': 'Refresh', 'type': 'method', 'language': 'go',
+                         'line_start': 10, 'line_end': 49,
+                         'signature': 'func (s *Session) Refresh(ctx context.Context) error'}}
+
+
+def request(url, path, body=None, headers=None):
+    req = urllib.request.Request(url + path, data=None if body is None else json.dumps(body).encode(),
+                                 method='GET' if body is None else 'POST',
+                                 headers=dict({'Content-Type': 'application/json'}, **(headers or {})))
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            return response.status, response.read().decode()
+    except urllib.error.HTTPError as e:
+        return e.code, e.read().decode()
+
+
+def test_window():
+    assert snippet_window(40, [28, 29, 30], 6) == (26, 32), "centered on the matches"
+    assert snippet_window(40, [2, 28, 29, 30], 6) == (26, 32), "the window with the most matches wins"
+    assert snippet_window(40, [2, 29], 6) == (0, 6), "ties go to the first"
+    assert snippet_window(40, [0, 2, 28, 28, 28], 6) == (25, 31), "lines count once per match"
+    assert snippet_window(40, [38, 39], 6) == (34, 40) and snippet_window(40, [0], 6) == (0, 6)
+    assert snippet_window(4, [3], 6) == (0, 4)
+    assert snippet_window(40, [], 6) == (0, 6) and snippet_window(40, [], 2, header_lines=3) == (0, 3)
+    print("✅ Snippet windows hold the most matched lines, or the top without matches")
+
+
+def test_records():
+    lexical = refresh_result(highlight_spans(REFRESH, ['refreshtoken', 'token']))
+    record = result_record(lexical, snippet_lines=5)
+    assert 'content' not in record and record['highlights'] == lexical['highlights'], \
+        "the record's highlights still point into the whole chunk"
+    snippet = record['snippet']
+    lines = REFRESH.split('\n')
+    assert len(lines) == 40 and snippet['content'] == '\n'.join(lines[26:31]), snippet['content']
+    assert (snippet['line_start'], snippet['line_end'], snippet['omitted_lines']) == (36, 40, 35)
+    assert snippet['full'] == '/chunk?id=auth%2Fsession.go%3ASession.Refresh%3A10'
+    for span in snippet['highlights']:
+        assert snippet['content'][span['start']:span['end']].lower() == span['term']
+        assert span['term'] in snippet['content'].split('\n')[span['line'] - 1].lower()
+    assert len(snippet['highlights']) == len(lexical['highlights'])
+    assert lexical['content'] == REFRESH and 'snippet' not in lexical, "the result itself is untouched"
+    
+    semantic = with_snippet(refresh_result([]), 2)['snippet']
+    assert semantic['content'] == '\n'.join(lines[:3]), "the top, up to the signature past its doc comment"
+    assert semantic['highlights'] == [] and semantic['line_start'] == 10
+    assert with_snippet(refresh_result([]), 10)['snippet']['content'] == '\n'.join(lines[:10])
+    assert result_record(refresh_result([]))['content'] == REFRESH and 'snippet' not in result_record(lexical), \
+        "without snippet_lines records are unchanged"
+    
+    schema = json_schema()
+    assert set(schema['$defs']['Snippet']['properties']) == {f for f in Snippet.__dataclass_fields__}
+    assert 'snippet' not in schema['$defs']['SearchResult']['required']
+    assert 'content' not in schema['$defs']['SearchResult']['required']
+    assert chunk_path('a b/c.go:F') == '/chunk?id=a%20b%2Fc.go%3AF'
+    print("✅ A snippet record has the matched lines, relative highlights and the path of the whole chunk")
+
+
+def build_rag(workdir, name):
+    rag = make_rag(workdir, name)
+    rag.add_chunks_batch([
+        CodeChunk(type='method', name='Refresh', content=REFRESH, filepath='auth/session.go', language='go',
+                  line_start=10, line_end=49, signature='func (s *Session) Refresh(ctx context.Context) error',
+                  parent='Session'),
+        CodeChunk(type='function', name='EvictCache', content='func EvictCache(c *Cache) { }',
+                  filepath='store/cache.go', language='go', line_start=1, line_end=1),
+    ])
+    rag._build_keyword_index()
+    return rag
+
+
+def test_search(workdir):
+    rag = build_rag(workdir, "snippets")
+    result = rag.search("refresh token", n_results=1, snippet_lines=5)[0]
+    assert result.chunk.content is None and isinstance(result.snippet, Snippet)
+    assert 'RefreshToken' in result.snippet.content and result.snippet.line_end - result.snippet.line_start == 4
+    assert result.highlights and all(span.line <= 5 for span in result.snippet.highlights)
+    
+    chunk = rag.get_chunk(result.chunk.id)
+    assert chunk['content'] == REFRESH and chunk['metadata']['name'] == 'Refresh'
+    assert rag.get_chunk('auth/session.go:Missing:1') is None
+    assert rag.search("refresh token", n_results=1)[0].chunk.content == REFRESH
+    print("✅ rag.search returns snippets, and get_chunk the whole chunk they came from")
+
+
+def test_server(workdir):
+    rag = build_rag(workdir, "snippets_server")
+    server = RAGServer(('127.0.0.1', 0), rag)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        status, body = request(url, '/search', {'query': 'refresh token', 'top_k': 1, 'snippet_lines': 5})
+        assert status == 200, body
+        record = json.loads(body)['results'][0]
+        assert 'content' not in record and 'RefreshToken' in record['snippet']['content'], record
+        status, body = request(url, record['snippet']['full'])
+        assert status == 200, body
+        chunk = json.loads(body)['chunk']
+        assert chunk['content'] == REFRESH and chunk['id'] == record['id'] and chunk['citation'] == record['citation']
+        
+        status, body = request(url, '/search', {'query': 'refresh token', 'top_k': 1, 'snippet_lines': 5},
+                               {'Accept': NDJSON_TYPE})
+        assert status == 200 and json.loads(body.splitlines()[0])['snippet'] == record['snippet']
+        paged = json.loads(request(url, '/search', {'query': 'refresh token', 'top_k': 1, 'offset': 0,
+                                                    'snippet_lines': 5})[1])
+        assert paged['results'][0]['snippet'] == record['snippet']
+        plain = json.loads(request(url, '/search', {'query': 'refresh token', 'top_k': 1})[1])['results'][0]
+        assert plain['content'] == REFRESH and 'snippet' not in plain
+        
+        for bad in (0, '5', True):
+            assert request(url, '/search', {'query': 'refresh', 'snippet_lines': bad})[0] == 400, bad
+        assert request(url, '/chunk')[0] == 400
+        assert request(url, '/chunk?id=auth%2Fsession.go%3AMissing')[0] == 404
+    finally:
+        server.shutdown()
+        server.server_close()
+    
+    server = RAGServer(('127.0.0.1', 0), rag, snippet_lines=3)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        record = json.loads(request(url, '/search', {'query': 'refresh token', 'top_k': 1})[1])['results'][0]
+        assert record['snippet']['content'].count('\n') == 2, "the server's default applies"
+        record = json.loads(request(url, '/search', {'query': 'refresh token', 'top_k': 1,
+                                                     'snippet_lines': None})[1])['results'][0]
+        assert record['content'] == REFRESH, "null asks for whole chunks"
+    finally:
+        server.shutdown()
+        server.server_close()
+    print("✅ POST /search returns snippets with snippet_lines, and GET /chunk the whole chunk")
+
+
+def main():
+    print("=" * 70)
+    print("RESULT SNIPPETS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_result_snippets_"))
+    tests = [
+        test_window,
+        test_records,
+        lambda: test_search(workdir),
+        lambda: test_server(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
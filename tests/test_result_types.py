@@ -0,0 +1,158 @@
+#!/usr/bin/env python3
+"""
+Test script for the typed search results
+SearchResult and its parts map the ranking's result dicts onto versioned
+types whose to_dict() is the /search record, and json_schema() describes
+those records. Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import make_rag
+from utils.result_format import result_record
+from utils.result_types import Chunk, Location, SearchResult, Span, SymbolRef, json_schema
+
+FULL_RESULT = {
+    'id': 'auth/token.go:ValidateToken:3#0',
+    'content': 'func ValidateToken(t string) error {\n    return check(t)\n}',
+    'metadata': {'filepath': 'auth/token.go', 'repo': '', 'name': 'ValidateToken', 'type': 'function',
+                 'language': 'go', 'kind': 'source', 'line_start': 3, 'line_end': 5, 'byte_start': 20,
+                 'byte_end': 80, 'symbol_id': 'internal, not in the record'},
+    'rrf_score': 0.03, 'rerank_score': 0.9, 'vector_score': 0.7, 'bm25_score': 4.2, 'relevance': 0.6,
+    'vector_rank': 1, 'bm25_rank': 2,
+    'surrounding': {'imports': 'import "errors"', 'enclosing': 'type Token struct {', 'enclosing_name': 'Token',
+                    'enclosing_filepath': 'auth/token.go', 'enclosing_lines': [1, 2]},
+    'neighbors': {'previous': None, 'next': {'id': 'n', 'symbol_id': 's', 'name': 'Refresh',
+                                             'qualified_name': 'Refresh', 'type': 'function',
+                                             'filepath': 'auth/token.go', 'line_start': 7, 'line_end': 9}},
+    'duplicates': [{'filepath': 'copy/token.go', 'line_start': 3, 'line_end': 5, 'name': 'ValidateToken',
+                    'location': 'copy/token.go'}],
+    'highlights': [{'start': 5, 'end': 18, 'line': 1, 'term': 'validatetoken'}],
+    'explain': {'relevance': 0.6},
+}
+
+EXPECTED_RECORD = {
+    'id': 'auth/token.go:ValidateToken:3#0', 'score': 0.9, 'vector_score': 0.7, 'bm25_score': 4.2,
+    'rerank_score': 0.9, 'relevance': 0.6, 'filepath': 'auth/token.go', 'repo': None, 'name': 'ValidateToken',
+    'type': 'function', 'language': 'go', 'kind': 'source', 'generated': False, 'last_change': None, 'line_start': 3,
+    'line_end': 5, 'byte_start': 20, 'byte_end': 80, 'citation': 'auth/token.go#L3-L5', 'content': FULL_RESULT['content'],
+    'surrounding': FULL_RESULT['surrounding'], 'neighbors': FULL_RESULT['neighbors'],
+    'duplicates': FULL_RESULT['duplicates'], 'highlights': FULL_RESULT['highlights'],
+    'explain': {'relevance': 0.6},
+}
+
+
+def conforms(value, schema, definitions, path='result'):
+    """Problems with value against a subset of JSON Schema (the keywords json_schema uses)"""
+    if '$ref' in schema:
+        return conforms(value, definitions[schema['$ref'].split('/')[-1]], definitions, path)
+    if 'anyOf' in schema:
+        options = [conforms(value, option, definitions, path) for option in schema['anyOf']]
+        return [] if any(not problems for problems in options) else options[0]
+    kind = schema['type']
+    checks = {'string': str, 'integer': int, 'number': (int, float), 'boolean': bool, 'null': type(None),
+              'array': list, 'object': dict}
+    if not isinstance(value, checks[kind]) or (kind in ('integer', 'number') and isinstance(value, bool)):
+        return [f"{path}: {value!r} is not {kind}"]
+    problems = []
+    if kind == 'array':
+        for i, item in enumerate(value):
+            problems += conforms(item, schema['items'], definitions, f"{path}[{i}]")
+    if kind == 'object' and 'properties' in schema:
+        problems += [f"{path}: missing {key}" for key in schema['required'] if key not in value]
+        problems += [f"{path}: unexpected {key}" for key in value if key not in schema['properties']]
+        for key, item in value.items():
+            if key in schema['properties']:
+                problems += conforms(item, schema['properties'][key], definitions, f"{path}.{key}")
+    return problems
+
+
+def test_record():
+    result = SearchResult.from_result(FULL_RESULT)
+    assert isinstance(result.chunk, Chunk) and result.chunk.citation == 'auth/token.go#L3-L5'
+    assert result.neighbors.previous is None and isinstance(result.neighbors.next, SymbolRef)
+    assert result.highlights == [Span(start=5, end=18, line=1, term='validatetoken')]
+    assert result.duplicates == [Location(location='copy/token.go', filepath='copy/token.go', line_start=3,
+                                          line_end=5, name='ValidateToken')]
+    
+    record = result.to_dict()
+    assert record == EXPECTED_RECORD, record
+    assert list(record) == list(EXPECTED_RECORD), "records keep their key order"
+    assert list(record['duplicates'][0]) == ['filepath', 'line_start', 'line_end', 'name', 'location']
+    assert result_record(FULL_RESULT) == record
+    
+    bare = dict(FULL_RESULT, surrounding={'imports': ''}, neighbors=None, duplicates=[], highlights=[])
+    bare.pop('explain')
+    record = SearchResult.from_result(bare).to_dict()
+    assert record['surrounding'] == {'imports': ''} and record['neighbors'] is None and 'explain' not in record
+    print("✅ A typed result serializes to exactly the /search record")
+
+
+def test_schema():
+    schema = json_schema()
+    definitions = schema['$defs']
+    assert not conforms({'query': 'q', 'results': [EXPECTED_RECORD]}, schema, definitions)
+    
+    result = definitions['SearchResult']
+    assert 'chunk' not in result['properties'] and 'filepath' in result['required']
+    assert 'explain' not in result['required'] and 'content' not in definitions['SymbolRef']['required']
+    assert all(p['description'] for d in definitions.values() for p in d['properties'].values()), \
+        "every field is documented"
+    
+    broken = dict(EXPECTED_RECORD, line_start='3')
+    assert conforms(broken, result, definitions) == ["result.line_start: '3' is not integer"]
+    print("✅ The JSON Schema describes every field of the records")
+
+
+def test_search(workdir):
+    rag = make_rag(workdir, "typed")
+    rag.add_chunks_batch([
+        CodeChunk(type='function', name='ValidateToken', content='func ValidateToken(token string) error { }',
+                  filepath='auth/token.go', language='go', line_start=1, line_end=1),
+        CodeChunk(type='function', name='EvictCache', content='func EvictCache(c *Cache) { }',
+                  filepath='store/cache.go', language='go', line_start=1, line_end=1),
+    ])
+    rag._build_keyword_index()
+    typed = rag.search("validate token", n_results=2, languages=['go'])
+    assert typed and all(isinstance(r, SearchResult) for r in typed)
+    assert typed[0].chunk.name == 'ValidateToken' and typed[0].highlights
+    plain = rag.retrieve_context("validate token", n_results=2, languages=['go'])
+    assert [r.to_dict() for r in typed] == [result_record(r) for r in plain]
+    print("✅ rag.search returns typed results matching retrieve_context's records")
+
+
+def main():
+    print("=" * 70)
+    print("RESULT TYPES TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_result_types_"))
+    tests = [
+        test_record,
+        test_schema,
+        lambda: test_search(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
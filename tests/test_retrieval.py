@@ -0,0 +1,282 @@
+#!/usr/bin/env python3
+"""
+Test script for retrieval options: filters (languages, symbol kinds, path globs)
+MMR diversity reranking, embedding modes, call-graph and interface method lookups
+Uses a small deterministic embedder so no embedding model is needed
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, link_go_packages
+from chunkers.base_chunker import CodeChunk
+from embedders import EmbeddingError
+from helpers import make_chroma_rag
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+
+def build_rag(db_path):
+    rag = make_chroma_rag(db_path, "test_search_filters")
+    chunks = GoChunker().extract_chunks((SAMPLES / "complex.go").read_text(), "go/complex.go")
+    chunks.append(CodeChunk(
+        type="function", name="authenticate",
+        content="def authenticate(username, password):\n    return check(username, password)",
+        filepath="py/auth.py", language="python", line_start=1, line_end=2
+    ))
+    rag.add_chunks_batch(chunks)
+    rag._build_keyword_index()
+    return rag
+
+
+def test_kind_and_language(rag):
+    results = rag.retrieve_context("authenticate", n_results=5, languages=['go'], kinds=['method'])
+    names = [r['metadata']['name'] for r in results]
+    assert results and names[0] == 'Authenticate', names
+    assert all(r['metadata']['type'] == 'method' and r['metadata']['language'] == 'go' for r in results)
+    assert 'Authenticator' not in names
+    print("✅ Kind and language filters applied before ranking")
+
+
+def test_type_kind(rag):
+    results = rag.retrieve_context("authenticate", n_results=5, languages=['go'], kinds=['interface'])
+    assert [r['metadata']['name'] for r in results] == ['Authenticator']
+    print("✅ Interface kind filter")
+
+
+def test_path_globs(rag):
+    results = rag.retrieve_context("authenticate", n_results=10, path_globs=['py/*'])
+    assert results and all(r['metadata']['filepath'].startswith('py/') for r in results)
+    print("✅ Path glob filter")
+
+
+def test_no_match(rag):
+    assert rag.retrieve_context("authenticate", path_globs=['nowhere/*']) == []
+    assert rag.retrieve_context("authenticate", languages=['cobol']) == []
+    print("✅ Filters matching nothing return an empty list")
+
+
+def test_constant_search(rag):
+    results = rag.retrieve_context("forbidden status code", n_results=3)
+    top = results[0]['metadata']
+    assert (top['name'], top['type']) == ('Forbidden', 'const'), [r['metadata']['name'] for r in results]
+    assert top['signature'] == 'const Forbidden StatusCode = 2'
+    print("✅ A constant of an iota group is found by its name and type")
+
+
+def test_mmr_diversity():
+    db_path = tempfile.mkdtemp(prefix="rag_mmr_")
+    rag = make_chroma_rag(db_path, "test_mmr")
+    helper = "func retryRequest(client *http.Client, req *http.Request) error { return retry(client, req) }"
+    chunks = [
+        CodeChunk(type="function", name="retryRequest", content=helper,
+                  filepath=f"copy{i}/retry.go", language="go", line_start=1, line_end=1)
+        for i in range(5)
+    ]
+    for i, content in enumerate([
+        "func backoff(attempt int) time.Duration { return time.Second << attempt }",
+        "func isRetryable(err error) bool { return errors.Is(err, ErrTemporary) }",
+        "func newClient(timeout time.Duration) *http.Client { return &http.Client{Timeout: timeout} }",
+        "func logRequest(req *http.Request) { log.Printf(\"%s %s\", req.Method, req.URL) }",
+    ]):
+        chunks.append(CodeChunk(type="function", name=f"other{i}", content=content,
+                                filepath=f"net/other{i}.go", language="go", line_start=1, line_end=1))
+    rag.add_chunks_batch(chunks)
+    rag._build_keyword_index()
+    
+    plain = rag.retrieve_context("retry http request client", n_results=5)
+    assert [r['metadata']['name'] for r in plain].count('retryRequest') == 5
+    
+    diverse = rag.retrieve_context("retry http request client", n_results=5, mmr_lambda=0.5)
+    names = [r['metadata']['name'] for r in diverse]
+    shutil.rmtree(db_path, ignore_errors=True)
+    assert names[0] == 'retryRequest' and names.count('retryRequest') == 1, names
+    assert len(names) == 5 and all('embedding' not in r for r in diverse)
+    print("✅ MMR keeps one copy of a duplicated helper plus distinct results")
+
+
+def test_doc_embedding():
+    db_path = tempfile.mkdtemp(prefix="rag_doc_")
+    rag = make_chroma_rag(db_path, "test_doc", embed_doc_signature=True)
+    code = '''package acl
+
+// AddPermission adds a permission to the user.
+func AddPermission(u *User, p string) {
+    u.perms = append(u.perms, p)
+}
+
+// RemoveUser deletes the account and its sessions.
+func RemoveUser(id int) error {
+    return store.Delete(id)
+}
+'''
+    rag.add_chunks_batch(GoChunker().extract_chunks(code, "acl/acl.go"))
+    rag._build_keyword_index()
+    for weight in (0.0, 1.0):  # vector only, then keyword only
+        results = rag.retrieve_context("adds a permission", n_results=2, lexical_weight=weight)
+        assert results[0]['metadata']['name'] == 'AddPermission', (weight, results)
+        assert results[0]['content'].startswith('func AddPermission')
+    shutil.rmtree(db_path, ignore_errors=True)
+    print("✅ Doc comments anchor natural-language queries")
+
+
+def test_embedding_modes():
+    db_path = tempfile.mkdtemp(prefix="rag_modes_")
+    code = '''package cache
+
+// Evict drops the least recently used entry.
+func Evict(c *Cache) {
+    for key := range c.order { delete(c.items, key); c.order = c.order[1:]; break }
+}
+
+func Lookup(c *Cache, key string) (item Item, ok bool) {
+    item, ok = c.items[key]
+    return
+}
+'''
+    chunks = GoChunker().extract_chunks(code, "cache/cache.go")
+    
+    signature = make_chroma_rag(db_path, "sig", embedding_mode='signature')
+    signature.add_chunks_batch(chunks)
+    signature._build_keyword_index()
+    results = signature.retrieve_context("lookup key item ok", n_results=1, lexical_weight=0.0)
+    assert results[0]['metadata']['name'] == 'Lookup' and 'c.items[key]' in results[0]['content'], results
+    
+    # The index keeps its mode: reopening without one works, another mode is refused
+    assert make_chroma_rag(db_path, "sig").embedding_mode == 'signature'
+    try:
+        make_chroma_rag(db_path, "sig", embedding_mode='code').add_chunks_batch(chunks)
+        assert False, "mode mismatch not reported"
+    except EmbeddingError as e:
+        assert "embedding mode 'signature'" in str(e), str(e)
+    
+    dual = make_chroma_rag(db_path, "dual", embedding_mode='dual')
+    dual.add_chunks_batch(chunks)
+    dual._build_keyword_index()
+    assert dual.signature_store.count() == 2 and dual.collection.count() == len(chunks)
+    # The doc comment is only in the signature vectors, the loop body only in the code vectors
+    for query, vector_index in (("drops least recently used entry", 'signature'),
+                                ("drops least recently used entry", 'both'),
+                                ("range order delete items break", 'code')):
+        results = dual.retrieve_context(query, n_results=1, lexical_weight=0.0, vector_index=vector_index)
+        assert results[0]['metadata']['name'] == 'Evict', (vector_index, results)
+        assert results[0]['content'].startswith('func Evict'), results[0]['content']
+    
+    snapshot = Path(db_path) / "snapshot"
+    dual.save_index(str(snapshot))
+    restored = make_chroma_rag(db_path, "restored")
+    restored.load_index(str(snapshot))
+    assert restored.embedding_mode == 'dual' and restored.signature_store.count() == 2
+    
+    assert dual.move_file_chunks("cache/cache.go", "cache/lru.go") == len(chunks)
+    assert dual.signature_store.get(where={"filepath": "cache/lru.go"}, include=[])['ids']
+    assert dual.delete_file_chunks("cache/lru.go") == len(chunks) and dual.signature_store.count() == 0
+    shutil.rmtree(db_path, ignore_errors=True)
+    print("✅ Signature and dual embedding modes, kept per index and mirrored on updates")
+
+
+def test_call_lookups():
+    db_path = tempfile.mkdtemp(prefix="rag_calls_")
+    rag = make_chroma_rag(db_path, "test_calls")
+    code = '''package auth
+
+type Session struct{}
+
+func (s *Session) Refresh() {}
+
+func CreateSession() *Session {
+    s := &Session{}
+    s.Refresh()
+    return s
+}
+
+func Login() {
+    CreateSession()
+}
+'''
+    rag.add_chunks_batch(link_go_packages(GoChunker().extract_chunks(code, "auth/auth.go")))
+    callers = rag.find_callers("CreateSession")
+    assert [c['metadata']['name'] for c in callers] == ['Login'], callers
+    assert [c['metadata']['name'] for c in rag.find_callers("Session.Refresh")] == ['CreateSession']
+    callees = rag.find_callees("CreateSession")
+    assert [ref['name'] for ref in callees[0]['calls']] == ['Session.Refresh'], callees
+    shutil.rmtree(db_path, ignore_errors=True)
+    print("✅ Callers and callees looked up from call-graph metadata")
+
+
+def test_interface_methods():
+    db_path = tempfile.mkdtemp(prefix="rag_methods_")
+    rag = make_chroma_rag(db_path, "test_methods")
+    code = '''package auth
+
+type Closer interface {
+    Close() error
+}
+
+// Authenticator checks credentials.
+type Authenticator interface {
+    Closer
+    // Authenticate reports whether the token is valid.
+    Authenticate(token string) bool
+    Logout(user string) error
+}
+'''
+    rag.add_chunks_batch(link_go_packages(GoChunker().extract_chunks(code, "auth/auth.go")))
+    rag._build_keyword_index()
+    matches = rag.interface_method_set("auth.Authenticator")
+    assert len(matches) == 1, matches
+    methods = matches[0]['methods']
+    assert [(m['name'], m['signature'], m.get('via')) for m in methods] == [
+        ('Authenticate', 'Authenticate(token string) bool', None),
+        ('Logout', 'Logout(user string) error', None),
+        ('Close', 'Close() error', 'Authenticator.Closer'),
+    ], methods
+    assert rag.interface_method_set("other.Authenticator") == []
+    
+    results = rag.retrieve_context("authenticate token valid", n_results=1, kinds=['interface_method'])
+    assert results[0]['metadata']['name'] == 'Authenticate' and results[0]['metadata']['parent'] == 'Authenticator'
+    shutil.rmtree(db_path, ignore_errors=True)
+    print("✅ Interface method sets looked up, and interface methods searched on their own")
+
+
+def main():
+    print("=" * 70)
+    print("RETRIEVAL TEST")
+    print("=" * 70)
+    
+    db_path = tempfile.mkdtemp(prefix="rag_filters_")
+    rag = build_rag(db_path)
+    tests = [
+        lambda: test_kind_and_language(rag),
+        lambda: test_type_kind(rag),
+        lambda: test_path_globs(rag),
+        lambda: test_no_match(rag),
+        lambda: test_constant_search(rag),
+        test_mmr_diversity,
+        test_doc_embedding,
+        test_embedding_modes,
+        test_call_lookups,
+        test_interface_methods,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(db_path, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,135 @@
+#!/usr/bin/env python3
+"""
+Test script for the retrieval evaluation harness: query set loading, recall@k,
+MRR and nDCG@k of a ranking, and a run of an example query set against an index
+"""
+
+import json
+import math
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.retrieval_eval import EvalError, evaluate, load_queries, score_ranking, symbol_matches
+from utils.state_manager import StateManager
+
+SAMPLES = Path(__file__).parent.parent / "test_samples"
+
+
+def stored(filepath, name, parent=None, repo=None):
+    metadata = {'filepath': filepath, 'name': name}
+    if parent:
+        metadata['parent'] = parent
+    if repo:
+        metadata['repo'] = repo
+    return metadata
+
+
+def result(filepath, name):
+    return {'metadata': stored(filepath, name)}
+
+
+def test_metrics():
+    assert symbol_matches('CreateSession', stored('a.go', 'CreateSession', 'SessionManager'))
+    assert symbol_matches('SessionManager.CreateSession', stored('a.go', 'CreateSession', 'SessionManager'))
+    assert not symbol_matches('Store.CreateSession', stored('a.go', 'CreateSession', 'SessionManager'))
+    assert symbol_matches('a.go:CreateSession', stored('a.go', 'CreateSession', 'SessionManager'))
+    assert not symbol_matches('b.go:CreateSession', stored('a.go', 'CreateSession', 'SessionManager'))
+    assert symbol_matches('auth:a.go:Login', stored('a.go', 'Login', repo='auth'))
+    
+    ranked = [result('x.go', 'Other'), result('a.go', 'Login'), result('a.go', 'Login'),
+              result('b.go', 'Logout'), result('c.go', 'Noise')]
+    scores = score_ranking(ranked, ['Login', 'Logout', 'Missing'], [1, 3, 5])
+    assert scores['ranks'] == {'Login': 2, 'Logout': 4, 'Missing': None}, scores['ranks']
+    assert scores['reciprocal_rank'] == 0.5
+    assert (scores['recall@1'], scores['recall@3'], scores['recall@5']) == (0.0, 1 / 3, 2 / 3), scores
+    ideal = 1 + 1 / math.log2(3) + 1 / math.log2(4)
+    assert scores['ndcg@1'] == 0.0
+    assert math.isclose(scores['ndcg@5'], (1 / math.log2(3) + 1 / math.log2(5)) / ideal), scores['ndcg@5']
+    
+    perfect = score_ranking([result('a.go', 'Login')], ['Login'], [1, 10])
+    assert perfect['reciprocal_rank'] == perfect['recall@1'] == perfect['ndcg@10'] == 1.0, perfect
+    print("✅ Recall, reciprocal rank and nDCG count each expected symbol once, at its first rank")
+
+
+def test_load_queries(workdir):
+    for name in ('comprehensive.jsonl', 'adaptive_test.jsonl'):
+        assert len(load_queries(str(SAMPLES / "eval" / name))) >= 10, name
+    
+    path = workdir / "queries.jsonl"
+    path.write_text('{"query": "open a store", "expected": "Store.Open", "languages": "go"}\n\n'
+                    '{"query": "close it", "expected": ["Close", "Close"]}\n')
+    assert load_queries(str(path)) == [
+        {'query': 'open a store', 'expected': ['Store.Open'], 'filters': {'languages': ['go']}},
+        {'query': 'close it', 'expected': ['Close'], 'filters': {}},
+    ], load_queries(str(path))
+    
+    for line, message in (('{"query": "x"', 'not valid JSON'), ('{"query": "x", "expected": []}', "'expected'"),
+                          ('{"expected": ["X"]}', "'query'"), ('{"query": "x", "expected": "X", "top": 3}', 'top')):
+        path.write_text('{"query": "fine", "expected": "Fine"}\n' + line + '\n')
+        try:
+            load_queries(str(path))
+            assert False, f"{line} should be rejected"
+        except EvalError as e:
+            assert f"{path}:2" in str(e) and message in str(e), e
+    print("✅ Query sets load with their filters; malformed lines are reported with their line number")
+
+
+def test_evaluate(workdir):
+    rag = make_rag(workdir, "eval")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db")))
+    indexer.index_directory(str(SAMPLES / "adaptive_test"), parallel=False)
+    
+    queries = load_queries(str(SAMPLES / "eval" / "adaptive_test.jsonl"))
+    report = evaluate(rag, queries, k_values=[5, 1])
+    assert report['queries'] == len(queries) and report['k'] == [1, 5], report
+    assert 0.5 < report['mrr'] <= 1.0 and report['recall@1'] <= report['recall@5'], report
+    assert all(0.0 <= report[metric] <= 1.0 for metric in ('recall@1', 'recall@5', 'ndcg@1', 'ndcg@5'))
+    assert [q['query'] for q in report['per_query']] == [q['query'] for q in queries]
+    json.dumps(report)
+    
+    keyword_only = evaluate(rag, queries, k_values=[5], lexical_weight=1.0)
+    assert keyword_only['mrr'] != report['mrr'] or keyword_only['ndcg@5'] != report['ndcg@5'], \
+        "search options should change the ranking being scored"
+    
+    posts = [{'query': 'posts table', 'expected': ['posts'], 'filters': {'kinds': ['table']}}]
+    assert evaluate(rag, posts, [1])['recall@1'] == 1.0
+    assert evaluate(rag, posts, [1], kinds=['section'])['recall@1'] == 1.0, "a query's own filters win"
+    print(f"✅ Example query set scored against an index: MRR {report['mrr']:.2f}, recall@5 {report['recall@5']:.2f}")
+
+
+def main():
+    print("=" * 70)
+    print("RETRIEVAL EVAL TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_eval_"))
+    
+    tests = [
+        test_metrics,
+        lambda: test_load_queries(workdir),
+        lambda: test_evaluate(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
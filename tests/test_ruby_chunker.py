@@ -0,0 +1,319 @@
+#!/usr/bin/env python3
+"""
+Test script for the Ruby chunker
+Sources are parsed by tree-sitter-ruby
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import RubyChunker
+from helpers import make_chroma_rag
+
+
+INVOICE = '''# frozen_string_literal: true
+
+require 'json'
+require_relative 'base'
+
+=begin
+Billing helpers shared by
+every account type.
+=end
+module Billing
+  VERSION = '1.2.0'
+  RATES = {
+    standard: 0.2,
+    reduced: 0.05,
+  }.freeze
+  private_constant :RATES
+  
+  # A money amount
+  Money = Struct.new(:amount, :currency) do
+    def to_s
+      "#{amount} #{currency}"
+    end
+  end
+  
+  # Invoices of an account
+  #
+  # @deprecated Use Statements instead
+  class Invoice < ActiveRecord::Base
+    include Comparable, Enumerable
+    extend Forwardable
+    prepend Auditing::Trail
+    has_many :lines, -> { order(:position) }
+    
+    attr_accessor :number, :due_on
+    attr_writer :note
+    
+    SQL = <<~SQL
+      SELECT * FROM invoices
+      WHERE paid = false -- end
+    SQL
+    
+    # Invoices past their due date
+    def self.overdue(now = Time.now, *rest, limit:, offset: 0, **opts, &block)
+      where("due_on < ?", now).each do |invoice|
+        next if invoice.paid?
+        yield invoice if block_given?
+      end
+    end
+    
+    def total = lines.sum(&:amount)
+    
+    def <=>(other)
+      number <=> other.number
+    end
+    
+    def number=(value)
+      @number = value.to_s unless value.nil?
+    end
+    
+    def body
+      words = %w[end def class]
+      pattern = /end\\s+def/
+      while words.any? do
+        words.pop
+      end
+      x = if words.empty? then 1 else 2 end
+      begin
+        risky
+      rescue StandardError => e
+        log e
+      end
+      "#{pattern} end" + 'end'
+    end
+    
+    protected
+    
+    def compare_key
+      [number, due_on]
+    end
+    
+    private def helper
+      @helper ||= begin
+        1
+      end
+    end
+    
+    class << self
+      attr_reader :default_currency
+      
+      def by_number(number)
+        find_by(number: number)
+      end
+      
+      private
+      
+      def cache
+        @cache ||= {}
+      end
+    end
+  end
+  
+  module Formatting
+    module_function
+    
+    def currency(amount)
+      format('%.2f', amount)
+    end
+  end
+  
+  class Account::Admin < Account
+  end
+end
+
+def helper_function(a, b = 2)
+  a + b
+end
+
+__END__
+def not_code
+end
+'''
+
+CONCERN = '''module Concerns::Payable
+  extend ActiveSupport::Concern
+  
+  included do
+    def paid?
+      !paid_at.nil?
+    end
+  end
+  
+  class_methods do
+    def unpaid
+      where(paid_at: nil)
+    end
+  end
+end
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_types():
+    """Modules and classes with superclasses, mixins, accessors and docs"""
+    chunks = RubyChunker().extract_chunks(INVOICE, 'lib/billing.rb')
+    
+    billing = by_name(chunks, 'Billing', 'module')
+    assert billing.doc == 'Billing helpers shared by\nevery account type.', billing.doc
+    assert billing.metadata['constants'] == ['VERSION', 'RATES'], billing.metadata
+    assert (billing.line_start, billing.line_end) == (10, 112)
+    
+    invoice = by_name(chunks, 'Invoice', 'class')
+    assert invoice.signature == 'class Invoice < ActiveRecord::Base' and invoice.namespace == 'Billing'
+    assert invoice.metadata['superclass'] == 'ActiveRecord::Base'
+    assert invoice.metadata['includes'] == ['Comparable', 'Enumerable']
+    assert invoice.metadata['extends'] == ['Forwardable'] and invoice.metadata['prepends'] == ['Auditing::Trail']
+    assert invoice.metadata['accessors'] == [
+        {'name': 'number', 'kind': 'accessor'}, {'name': 'due_on', 'kind': 'accessor'},
+        {'name': 'note', 'kind': 'writer'}, {'name': 'default_currency', 'kind': 'reader', 'class_method': True},
+    ], invoice.metadata['accessors']
+    assert invoice.metadata['associations'] == [{'macro': 'has_many', 'name': 'lines'}]
+    assert invoice.doc == 'Invoices of an account\n\n@deprecated Use Statements instead', invoice.doc
+    assert invoice.metadata['deprecated'] == 'Use Statements instead'
+    assert (invoice.line_start, invoice.line_end) == (28, 100), "'end' in strings, heredocs and regexes closes nothing"
+    
+    admin = by_name(chunks, 'Admin', 'class')
+    assert admin.namespace == 'Billing::Account' and admin.metadata['superclass'] == 'Account'
+    
+    money = by_name(chunks, 'Money')
+    assert money.type == 'class' and money.metadata['superclass'] == 'Struct' and money.doc == 'A money amount'
+    assert [a['name'] for a in money.metadata['accessors']] == ['amount', 'currency']
+    assert by_name(chunks, 'to_s').parent == 'Billing::Money'
+    print("✅ Modules and classes extracted with superclasses, mixins and accessors")
+
+
+def test_methods():
+    """Instance, class, operator, setter and endless methods with their parameters"""
+    chunks = RubyChunker().extract_chunks(INVOICE, 'lib/billing.rb')
+    invoice = by_name(chunks, 'Invoice', 'class')
+    assert invoice.metadata['methods'] == ['self.overdue', 'total', '<=>', 'number=', 'body', 'compare_key',
+                                           'helper', 'self.by_number', 'self.cache'], invoice.metadata['methods']
+    
+    overdue = by_name(chunks, 'overdue')
+    assert overdue.qualified_name == 'Billing::Invoice.overdue' and overdue.metadata['class_method']
+    assert overdue.signature == 'def self.overdue(now = Time.now, *rest, limit:, offset: 0, **opts, &block)'
+    assert overdue.metadata['params'] == [
+        {'name': 'now', 'default': 'Time.now'}, {'name': 'rest', 'kind': 'rest'},
+        {'name': 'limit', 'kind': 'keyword'}, {'name': 'offset', 'kind': 'keyword', 'default': '0'},
+        {'name': 'opts', 'kind': 'keyword_rest'}, {'name': 'block', 'kind': 'block'},
+    ], overdue.metadata['params']
+    assert overdue.doc == 'Invoices past their due date' and (overdue.line_start, overdue.line_end) == (43, 48)
+    
+    total = by_name(chunks, 'total')
+    assert total.metadata['endless'] and (total.line_start, total.line_end) == (50, 50)
+    assert by_name(chunks, 'number=').metadata['params'] == [{'name': 'value'}]
+    assert (by_name(chunks, 'body').line_start, by_name(chunks, 'body').line_end) == (60, 73)
+    
+    # Visibility sections, inline private def and class << self
+    assert by_name(chunks, 'compare_key').metadata['visibility'] == 'protected'
+    helper = by_name(chunks, 'helper')
+    assert helper.metadata['visibility'] == 'private' and helper.signature == 'private def helper'
+    assert 'visibility' not in by_name(chunks, 'by_number').metadata and by_name(chunks, 'by_number').metadata['class_method']
+    assert by_name(chunks, 'cache').metadata == {'params': [], 'class_method': True, 'visibility': 'private'}
+    
+    assert by_name(chunks, 'currency').metadata['module_function']
+    function = by_name(chunks, 'helper_function')
+    assert function.type == 'function' and function.parent is None
+    assert not any(c.name == 'not_code' for c in chunks), "code after __END__ is data"
+    print("✅ Methods extracted with parameters, visibility and class methods")
+
+
+def test_constants():
+    """Constants span their whole value, heredocs included"""
+    chunks = RubyChunker().extract_chunks(INVOICE, 'lib/billing.rb')
+    rates = by_name(chunks, 'RATES')
+    assert rates.type == 'constant' and (rates.line_start, rates.line_end) == (12, 15)
+    assert rates.parent == 'Billing' and rates.metadata['visibility'] == 'private'
+    assert 'visibility' not in by_name(chunks, 'VERSION').metadata
+    sql = by_name(chunks, 'SQL')
+    assert sql.parent == 'Billing::Invoice' and sql.content.endswith('-- end\n    SQL'), sql.content
+    print("✅ Constants extracted")
+
+
+def test_concerns_and_errors():
+    """Methods in included/class_methods blocks belong to the module; a missing 'end' is reported"""
+    chunker = RubyChunker()
+    chunks = chunker.extract_chunks(CONCERN, 'app/models/concerns/payable.rb')
+    payable = by_name(chunks, 'Payable', 'module')
+    assert payable.namespace == 'Concerns' and payable.metadata['extends'] == ['ActiveSupport::Concern']
+    assert payable.metadata['methods'] == ['paid?', 'self.unpaid'], payable.metadata['methods']
+    assert by_name(chunks, 'paid?').parent == 'Concerns::Payable'
+    assert not chunker.diagnostics
+    
+    chunks = chunker.extract_chunks("class Open\n  def run\n    work\n  end\n", 'open.rb')
+    assert [c.name for c in chunks] == ['run', 'Open'], [c.name for c in chunks]
+    assert chunker.diagnostics == [{'kind': 'parse_error', 'line': 1, 'message': "missing 'end' for class Open"}]
+    print("✅ Concern blocks handled and unbalanced files reported")
+
+
+def test_sample_file():
+    """The comprehensive sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'complex.rb'
+    chunker = RubyChunker()
+    chunks = chunker.extract_chunks(sample.read_text(), 'comprehensive/complex.rb')
+    assert not chunker.diagnostics, chunker.diagnostics
+    
+    admin = by_name(chunks, 'AdminUser', 'class')
+    assert admin.metadata['superclass'] == 'User' and admin.metadata['includes'] == ['Authenticator']
+    assert admin.doc == 'AdminUser with mixed-in authenticator'
+    assert by_name(chunks, 'valid_password?').metadata['visibility'] == 'private'
+    assert by_name(chunks, 'get_instance').metadata['class_method']
+    assert by_name(chunks, 'get_message').qualified_name == 'Authentication::StatusCode.get_message'
+    assert by_name(chunks, 'StatusCode', 'module').metadata['constants'] == ['SUCCESS', 'UNAUTHORIZED',
+                                                                             'FORBIDDEN', 'NOT_FOUND']
+    print("✅ Sample file parsed")
+
+
+def test_search(workdir):
+    """Ruby modules are types to the kind filter"""
+    rag = make_chroma_rag(workdir / "db", "test_ruby")
+    rag.add_chunks_batch(RubyChunker().extract_chunks(INVOICE, 'lib/billing.rb'))
+    rag._build_keyword_index()
+    
+    results = rag.retrieve_context("billing formatting", n_results=30, filter_expr="kind=type")
+    names = sorted(r['metadata']['name'] for r in results)
+    assert names == ['Admin', 'Billing', 'Formatting', 'Invoice', 'Money'], names
+    results = rag.retrieve_context("invoice", n_results=30, filter_expr='name="Billing::Invoice.*"')
+    assert 'overdue' in {r['metadata']['name'] for r in results}
+    print("✅ Ruby symbols found by kind and name")
+
+
+def main():
+    print("=" * 70)
+    print("RUBY CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_ruby_"))
+    
+    tests = [
+        test_types, test_methods, test_constants, test_concerns_and_errors, test_sample_file,
+        lambda: test_search(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,192 @@
+#!/usr/bin/env python3
+"""
+Test script for the Rust chunker
+Items are parsed by tree-sitter-rust; macros and nested comments stay opaque
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import RustChunker
+
+
+MODULES = '''//! Networking crate.
+
+macro_rules! log_call {
+    ($name:expr) => { println!("{}", $name) };
+}
+
+lazy_static! {
+    static ref TABLE: Vec<u8> = vec![1, 2, 3];
+}
+
+/* outer /* nested */ still a comment: fn fake() {} */
+static NAME: &str = r#"raw "string" with } brace"#;
+
+pub mod net {
+    //! Network primitives.
+    
+    /// A connection.
+    #[derive(Debug, Clone)]
+    pub struct Conn<'a, T: Read> where T: Send {
+        inner: &'a mut T,
+        pub buf: Vec<Vec<u8>>,
+    }
+    
+    pub(crate) mod http {
+        /// Parses a request.
+        #[deprecated(note = "use parse_v2")]
+        pub async fn parse<'a, R>(input: &'a str, reader: R) -> Result<&'a str, Error>
+        where
+            R: Iterator<Item = Vec<u8>> + 'a,
+        {
+            let brace = '}';
+            log_call!("parse");
+            Ok(input)
+        }
+    }
+}
+'''
+
+IMPLS = '''pub trait Shape: fmt::Debug {
+    fn area(&self) -> f64;
+    /// Default name.
+    fn name(&self) -> String { String::from("shape") }
+}
+
+pub struct Circle(pub f64);
+
+impl Circle {
+    pub fn new(radius: f64) -> Self { Circle(radius) }
+}
+
+impl<T: Clone> Shape for Wrapper<T> where T: fmt::Debug {
+    fn area(&mut self) -> f64 { 0.0 }
+}
+
+pub enum Event<T> {
+    Open { id: u32 },
+    Data(T),
+    #[allow(unused)]
+    Close,
+}
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_modules_and_docs():
+    """mod hierarchy becomes the namespace; /// and //! docs populate doc"""
+    chunks = RustChunker().extract_chunks(MODULES, 'src/lib.rs')
+    
+    crate = by_name(chunks, 'crate')
+    assert crate.type == 'module' and crate.doc == 'Networking crate.', crate
+    net = by_name(chunks, 'net')
+    assert net.type == 'module' and net.doc == 'Network primitives.', net.doc
+    
+    conn = by_name(chunks, 'Conn')
+    assert conn.namespace == 'net' and conn.doc == 'A connection.'
+    assert conn.metadata['derives'] == ['Debug', 'Clone']
+    assert [f['name'] for f in conn.metadata['fields']] == ['inner', 'buf']
+    
+    parse = by_name(chunks, 'parse')
+    assert parse.type == 'function' and parse.namespace == 'net::http', parse.namespace
+    assert parse.doc == 'Parses a request.'
+    assert parse.metadata['deprecated'] == 'use parse_v2'
+    assert parse.content.rstrip().endswith('}') and 'Ok(input)' in parse.content
+    print("✅ Modules, namespaces and doc comments extracted")
+
+
+def test_signatures():
+    """Generic parameters and where clauses are part of the signature"""
+    chunks = RustChunker().extract_chunks(MODULES, 'src/lib.rs')
+    parse = by_name(chunks, 'parse')
+    assert parse.signature == ("pub async fn parse<'a, R>(input: &'a str, reader: R) -> Result<&'a str, Error> "
+                               "where R: Iterator<Item = Vec<u8>> + 'a"), parse.signature
+    assert parse.metadata['generics'] == "<'a, R>"
+    assert parse.metadata['returns'] == "Result<&'a str, Error>"
+    
+    conn = by_name(chunks, 'Conn')
+    assert conn.signature == "pub struct Conn<'a, T: Read> where T: Send", conn.signature
+    print("✅ Generics and where clauses kept in signatures")
+
+
+def test_macros_do_not_derail():
+    """Macro definitions and invocations are skipped as token trees"""
+    chunks = RustChunker().extract_chunks(MODULES, 'src/lib.rs')
+    names = [c.name for c in chunks]
+    assert 'log_call' in names and by_name(chunks, 'log_call').type == 'macro'
+    assert 'fake' not in names and 'TABLE' not in names
+    print("✅ Macros and nested comments don't break parsing")
+
+
+def test_impls_and_traits():
+    """Methods are attached to their impl target; trait methods to the trait"""
+    chunks = RustChunker().extract_chunks(IMPLS, 'src/shapes.rs')
+    
+    new = by_name(chunks, 'new')
+    assert new.type == 'method' and new.parent_class == 'Circle', new
+    
+    trait_impl = [c for c in chunks if c.type == 'impl' and c.metadata.get('trait')][0]
+    assert trait_impl.name == 'Wrapper' and trait_impl.metadata['trait'] == 'Shape'
+    assert trait_impl.metadata['where'] == 'where T: fmt::Debug'
+    assert trait_impl.signature == 'impl<T: Clone> Shape for Wrapper<T> where T: fmt::Debug'
+    
+    area = [c for c in chunks if c.name == 'area' and c.parent == 'Wrapper'][0]
+    assert area.metadata['impl_trait'] == 'Shape' and area.metadata['self_param'] == '&mut self'
+    
+    shape = by_name(chunks, 'Shape', 'trait')
+    assert shape.metadata['methods'] == ['area', 'name']
+    assert shape.metadata['supertraits'] == ['fmt::Debug']
+    name = by_name(chunks, 'name')
+    assert name.parent == 'Shape' and name.metadata['has_default'] and name.doc == 'Default name.'
+    
+    assert by_name(chunks, 'Circle', 'struct').metadata['fields'] == [{'name': '0', 'type': 'f64', 'pub': True}]
+    assert by_name(chunks, 'Event').metadata['variants'] == ['Open', 'Data', 'Close']
+    print("✅ impl blocks, trait methods, tuple structs and enums extracted")
+
+
+def test_sample_file():
+    """The comprehensive sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'comprehensive' / 'complex.rs'
+    chunks = RustChunker().extract_chunks(sample.read_text(), 'comprehensive/complex.rs')
+    
+    authenticate = [c for c in chunks if c.name == 'authenticate' and c.parent == 'AdminUser'][0]
+    assert authenticate.metadata['impl_trait'] == 'Authenticator'
+    session = by_name(chunks, 'authenticate_and_create_session')
+    assert session.metadata['generics'] == '<T: Authenticator + Clone>'
+    assert session.doc == 'Complex function with error handling'
+    assert by_name(chunks, 'StatusCode', 'enum').metadata['variants'][0] == 'Success'
+    print("✅ Sample file parsed")
+
+
+def main():
+    print("=" * 70)
+    print("RUST CHUNKER TEST")
+    print("=" * 70)
+    
+    tests = [
+        test_modules_and_docs, test_signatures, test_macros_do_not_derail,
+        test_impls_and_traits, test_sample_file
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,242 @@
+#!/usr/bin/env python3
+"""
+Test script for saved searches
+Named query templates from the config file are expanded with their parameters
+into /search fields, run from the command line (search --saved) and the HTTP
+server, listed by config searches and checked by config validate. Uses a
+small deterministic embedder
+"""
+
+import argparse
+import json
+import os
+import shutil
+import subprocess
+import sys
+import tempfile
+import threading
+import urllib.error
+import urllib.request
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from helpers import make_rag
+from server import RAGServer
+from utils.config_file import file_settings, read_config_file
+from utils.saved_searches import (SavedSearchError, describe_saved_search, expand_saved_search,
+                                  saved_search_problems, template_params)
+
+CLI = Path(__file__).parent.parent / 'cli.py'
+
+TEAM_TOML = '''
+[saved_searches.error-handling]
+description = "How a package handles and wraps errors"
+query = "error handling in {package}"
+top_k = 3
+languages = ["go"]
+path_globs = ["{package}/*"]
+
+[saved_searches.error-handling.params]
+package = "auth"
+
+[saved_searches.callers]
+query = "calls to {symbol}"
+kinds = ["function", "method"]
+'''
+
+SEARCHES = {
+    'error-handling': {'description': 'How a package handles and wraps errors', 'query': 'error handling in {package}',
+                       'params': {'package': 'auth'}, 'top_k': 3, 'languages': ['go'], 'path_globs': ['{package}/*']},
+    'callers': {'query': 'calls to {symbol}', 'kinds': ['function', 'method']},
+}
+
+
+def request(url, path, body=None):
+    data = json.dumps(body).encode() if body is not None else None
+    req = urllib.request.Request(url + path, data=data, method='POST' if data is not None else 'GET',
+                                 headers={'Content-Type': 'application/json'})
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            return response.status, json.loads(response.read())
+    except urllib.error.HTTPError as e:
+        return e.code, json.loads(e.read())
+
+
+def run_cli(workdir, *arguments):
+    env = {key: value for key, value in os.environ.items() if not key.startswith('CODE_RAG_')}
+    return subprocess.run([sys.executable, str(CLI), '--config', str(workdir / 'team.toml'), *arguments],
+                          cwd=workdir, env=env, capture_output=True, text=True)
+
+
+def test_expansion():
+    assert template_params("errors in {package} and {{braces}}, {package} again, {depth}") == ['package', 'depth']
+    for bad in ("unbalanced {package", "positional {}", "attribute {pkg.name}", "spec {n:>4}"):
+        try:
+            template_params(bad)
+            assert False, f"no error for {bad!r}"
+        except ValueError:
+            pass
+    
+    search = expand_saved_search(SEARCHES, 'error-handling')
+    assert search == {'query': 'error handling in auth', 'top_k': 3, 'languages': ['go'],
+                      'path_globs': ['auth/*']}, search
+    search = expand_saved_search(SEARCHES, 'error-handling', {'package': 'billing'})
+    assert search['query'] == 'error handling in billing' and search['path_globs'] == ['billing/*']
+    assert SEARCHES['error-handling']['path_globs'] == ['{package}/*'], "the saved search is left as it was"
+    
+    for name, params, message in (('nope', None, "Unknown saved search 'nope' (saved: callers, error-handling)"),
+                                  ('callers', None, "Saved search 'callers' needs a value for 'symbol'"),
+                                  ('callers', {'symbol': 'Open', 'depth': '2'},
+                                   "Saved search 'callers' has no parameter 'depth' (parameters: symbol)")):
+        try:
+            expand_saved_search(SEARCHES, name, params)
+            assert False, f"no error for {name} {params}"
+        except SavedSearchError as e:
+            assert str(e) == message, str(e)
+    assert expand_saved_search(SEARCHES, 'callers', {'symbol': 42})['query'] == 'calls to 42'
+    
+    described = describe_saved_search(SEARCHES['callers'])
+    assert described == {'query': 'calls to {symbol}', 'description': '', 'params': {'symbol': None},
+                         'fields': {'kinds': ['function', 'method']}}, described
+    print("✅ Saved searches fill their placeholders from parameters and defaults")
+
+
+def test_problems():
+    problems = saved_search_problems({
+        'typo': {'query': 'x', 'langauges': ['go']},
+        'types': {'query': 'x', 'top_k': '3', 'languages': 'go', 'exclude_tests': 'yes'},
+        'unused': {'query': 'errors in {package}', 'params': {'package': 'auth', 'depth': 2}},
+        'template': {'query': 'errors in {package'},
+        'filter': {'query': 'x {package}', 'filter': 'language=go AND (path:{package}/*'},
+        'empty': {'description': 'no query'},
+        'table': 'error handling',
+        'good': SEARCHES['error-handling'],
+    })
+    expected = [
+        "saved_searches.typo.langauges: unknown key",
+        "saved_searches.types.top_k: expected an integer, got '3'",
+        "saved_searches.types.languages: expected a list of strings, got 'go'",
+        "saved_searches.types.exclude_tests: expected true/false, got 'yes'",
+        "saved_searches.unused.params.depth: not used by any placeholder",
+        "saved_searches.template: ",
+        "saved_searches.filter.filter: ",
+        "saved_searches.empty.query: a saved search needs a query template",
+        "saved_searches.table: expected a table of",
+    ]
+    assert len(problems) == len(expected), problems
+    for problem, start in zip(problems, expected):
+        assert problem.startswith(start), (problem, start)
+    print("✅ Unknown keys, wrong types, unused parameters and bad templates are reported")
+
+
+def test_config_and_cli(workdir):
+    (workdir / 'team.toml').write_text(TEAM_TOML)
+    settings, problems = file_settings(read_config_file(workdir / 'team.toml'), CONFIG)
+    assert not problems and settings['saved_searches'] == SEARCHES, (settings, problems)
+    
+    listed = run_cli(workdir, 'config', 'searches', '--format', 'json')
+    assert listed.returncode == 0, listed.stderr
+    listing = json.loads(listed.stdout)
+    assert listing['problems'] == [] and sorted(listing['saved_searches']) == ['callers', 'error-handling']
+    assert listing['saved_searches']['error-handling']['params'] == {'package': 'auth'}
+    assert run_cli(workdir, 'config', 'validate', '--offline').returncode == 0
+    
+    (workdir / 'team.toml').write_text(TEAM_TOML + '\n[saved_searches.broken]\nquery = "in {package"\n')
+    checked = run_cli(workdir, 'config', 'validate', '--offline')
+    assert checked.returncode == 1 and 'saved_searches.broken' in checked.stdout + checked.stderr, checked.stdout
+    
+    # Options given on the command line replace the saved ones
+    saved = CONFIG.saved_searches
+    CONFIG.saved_searches = SEARCHES
+    try:
+        args = argparse.Namespace(saved='error-handling', param=['package=billing'], query=None,
+                                  n_results=None, language=None, type='function', path=None, repo=None,
+                                  uses=None, filter=None, scope=None, exclude_tests=False, exclude_text=False,
+                                  exclude_generated=False)
+        cli.apply_saved_search(args)
+        assert (args.query, args.n_results, args.language, args.type, args.path) == \
+            ('error handling in billing', 3, 'go', 'function', ['billing/*']), args
+        try:
+            cli.apply_saved_search(argparse.Namespace(saved='callers', param=['symbol']))
+            assert False, "a parameter without a value, but no error"
+        except ValueError as e:
+            assert '--param takes NAME=VALUE' in str(e), e
+    finally:
+        CONFIG.saved_searches = saved
+    print("✅ Saved searches load from the config file, are listed and validated, and run with --saved")
+
+
+def test_server(workdir):
+    rag = make_rag(workdir, "saved")
+    rag.add_chunks_batch([
+        CodeChunk(type='function', name='ValidateToken', content='func ValidateToken(t string) error { return wrap(err) }',
+                  filepath='auth/token.go', language='go', line_start=1, line_end=1),
+        CodeChunk(type='function', name='Charge', content='func Charge(c Card) error { return wrap(err) }',
+                  filepath='billing/charge.go', language='go', line_start=1, line_end=1),
+        CodeChunk(type='function', name='charge', content='def charge(card): raise ChargeError("error")',
+                  filepath='billing/charge.py', language='python', line_start=1, line_end=1),
+    ])
+    rag._build_keyword_index()
+    server = RAGServer(('127.0.0.1', 0), rag, saved_searches=SEARCHES)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        status, body = request(url, '/search', {'saved': 'error-handling', 'params': {'package': 'billing'}})
+        assert status == 200, body
+        assert [r['filepath'] for r in body['results']] == ['billing/charge.go'], body['results']
+        assert body['query'] == 'error handling in billing'
+        explicit = request(url, '/search', {'query': 'error handling in billing', 'top_k': 3, 'languages': ['go'],
+                                            'path_globs': ['billing/*']})[1]
+        assert explicit == body, "a saved search runs as the request it stands for"
+        
+        body = request(url, '/search', {'saved': 'error-handling', 'params': {'package': 'billing'},
+                                        'languages': None})[1]
+        assert {r['filepath'] for r in body['results']} == {'billing/charge.go', 'billing/charge.py'}
+        
+        status, body = request(url, '/search', {'saved': 'callers'})
+        assert status == 400 and "needs a value for 'symbol'" in body['error'], body
+        assert request(url, '/search', {'saved': 'callers', 'params': ['Open']})[0] == 400
+        assert request(url, '/search', {'saved': 'nope'})[0] == 400
+        
+        status, body = request(url, '/searches')
+        assert status == 200 and body['saved_searches']['callers']['params'] == {'symbol': None}, body
+    finally:
+        server.shutdown()
+        server.server_close()
+    print("✅ POST /search runs saved searches by name, and GET /searches lists them")
+
+
+def main():
+    print("=" * 70)
+    print("SAVED SEARCHES TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_saved_searches_"))
+    tests = [
+        test_expansion,
+        test_problems,
+        lambda: test_config_and_cli(workdir),
+        lambda: test_server(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
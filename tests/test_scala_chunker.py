@@ -0,0 +1,329 @@
+#!/usr/bin/env python3
+"""
+Test script for the Scala chunker
+Sources are parsed by tree-sitter-scala
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import ScalaChunker
+from config import CONFIG
+from helpers import make_chroma_rag
+
+
+JOBS = '''package com.example
+package jobs
+
+import scala.concurrent.{ExecutionContext, Future}
+import cats.*
+
+/** A batch job.
+  *
+  * @tparam F the effect it runs in
+  */
+abstract class Job[F[_]: Monad](val name: String)(implicit ec: ExecutionContext) extends Runnable with Serializable {
+  self: Logging =>
+  
+  type Output <: Product
+  val retries: Int
+  var attempts = 0
+  
+  /** Runs the job. */
+  def run(args: Array[String]): F[Output]
+  
+  protected def log(msg: => String) {
+    println(s"[${name.map { c => s"}$c" }}] $msg")
+  }
+  
+  @deprecated("use run", "2.1")
+  def execute(): Unit = run(Array.empty) /* { nested /* comment */ } */
+  
+  def this(name: String, retries: Int) = this(name)(ExecutionContext.global)
+  
+  object Metrics {
+    val counter = new Counter { def inc(): Unit = () }
+  }
+}
+
+case class Event(id: Long, kind: String, tags: String*) extends Record
+
+object Event {
+  implicit val ordering: Ordering[Event] = Ordering.by(_.id)
+  implicit def fromJson(json: String): Event = parse(json)
+  def apply(kind: String): Event =
+    Event(0L, kind)
+}
+
+implicit class EventOps(event: Event) {
+  def isError: Boolean = event.kind == "error"
+}
+
+sealed trait Result[+A]
+case object Pending extends Result[Nothing]
+type Handler = Event => Unit
+'''
+
+SCALA3 = '''package com.example.stats
+
+/** Orders values. */
+trait Ord[T]:
+  def compare(x: T, y: T): Int
+  extension (x: T) def < (y: T): Boolean = compare(x, y) < 0
+
+given intOrd: Ord[Int] with
+  def compare(x: Int, y: Int) =
+    if x < y then -1
+    else if x > y then 1
+    else 0
+
+given [T](using ord: Ord[T]): Ord[List[T]] with {
+  def compare(xs: List[T], ys: List[T]): Int = 0
+}
+
+given global: ExecutionContext = ExecutionContext.global
+
+/** Makes text louder. */
+extension (s: String) def shout: String = s.toUpperCase + "!"
+
+extension [T](xs: List[T])
+  def second: T = xs(1)
+  def penultimate(using Ord[T]): T =
+    xs.init.last
+
+enum Color(val rgb: Int):
+  case Red extends Color(0xFF0000)
+  case Green, Blue extends Color(0x00FF00)
+  def hex: String = f"#$rgb%06x"
+end Color
+
+object Stats:
+  /** Mean of the values. */
+  def mean[T: Numeric](xs: Seq[T]): Double =
+    val n = implicitly[Numeric[T]]
+    n.toDouble(xs.sum) / xs.size
+  end mean
+  
+  inline def square(x: Int): Int = x * x
+  lazy val cache = scala.collection.mutable.Map.empty[String, Double]
+  opaque type Score = Double
+  private[stats] val (lo, hi) = (0, 100)
+  
+  class Window[K, V: Ordering : Show](size: Int):
+    def push(v: V): Unit = ()
+end Stats
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_classes():
+    """Classes, traits and objects with their parameters, parents and members"""
+    chunks = ScalaChunker().extract_chunks(JOBS, 'src/Jobs.scala')
+    assert all(c.namespace == 'com.example.jobs' for c in chunks), {c.namespace for c in chunks}
+    
+    job = by_name(chunks, 'Job', 'class')
+    assert job.signature == ('abstract class Job[F[_]: Monad](val name: String)(implicit ec: ExecutionContext) '
+                             'extends Runnable with Serializable'), job.signature
+    assert job.doc == 'A batch job.\n\n@tparam F the effect it runs in', job.doc
+    assert job.metadata['context_bounds'] == ['F[_]: Monad'] and job.metadata['modifiers'] == ['abstract']
+    assert job.metadata['params'] == [{'name': 'name', 'type': 'String', 'property': 'val'},
+                                      {'name': 'ec', 'type': 'ExecutionContext', 'implicit': True}]
+    assert job.metadata['supertypes'] == ['Runnable', 'Serializable'] and job.metadata['self_type'] == 'self: Logging'
+    assert job.metadata['methods'] == ['run', 'log', 'execute', 'Job'], job.metadata['methods']
+    assert job.metadata['properties'] == ['name', 'retries', 'attempts'], job.metadata['properties']
+    assert (job.line_start, job.line_end) == (11, 33), (job.line_start, job.line_end)
+    
+    run = by_name(chunks, 'run')
+    assert run.type == 'method' and run.parent == 'Job' and run.doc == 'Runs the job.'
+    assert run.metadata['abstract'] and run.metadata['returns'] == 'F[Output]'
+    assert by_name(chunks, 'retries').metadata['abstract'] and by_name(chunks, 'attempts').metadata['mutable']
+    assert by_name(chunks, 'Output').metadata['bounds'] == '<: Product'
+    log = by_name(chunks, 'log')
+    assert log.metadata['params'] == [{'name': 'msg', 'type': '=> String'}] and log.content.endswith('}')
+    assert by_name(chunks, 'execute').metadata['deprecated'] == 'use run'
+    assert by_name(chunks, 'Job', 'method').metadata['constructor']
+    
+    metrics = by_name(chunks, 'Metrics')
+    assert metrics.type == 'object' and metrics.qualified_name == 'Job.Metrics'
+    assert by_name(chunks, 'counter').qualified_name == 'Job.Metrics.counter'
+    assert 'inc' not in [c.name for c in chunks], "members of an anonymous class stay in their initializer"
+    print("✅ Classes, traits and nested objects extracted with parents, parameters and Scaladoc")
+
+
+def test_case_classes():
+    """Case classes list their parameters as properties; a same-named object is their companion"""
+    chunks = ScalaChunker().extract_chunks(JOBS, 'src/Jobs.scala')
+    
+    event = by_name(chunks, 'Event', 'class')
+    assert event.metadata['modifiers'] == ['case'] and event.metadata['supertypes'] == ['Record']
+    assert event.metadata['properties'] == ['id', 'kind', 'tags'], event.metadata
+    assert event.metadata['params'][2] == {'name': 'tags', 'type': 'String', 'property': 'val', 'vararg': True}
+    companion = by_name(chunks, 'Event', 'object')
+    assert companion.metadata['companion'] and companion.metadata['methods'] == ['fromJson', 'apply']
+    assert by_name(chunks, 'apply').qualified_name == 'Event.apply' and by_name(chunks, 'apply').line_end == 41
+    
+    pending = by_name(chunks, 'Pending')
+    assert pending.type == 'object' and pending.metadata['modifiers'] == ['case']
+    assert 'companion' not in pending.metadata
+    assert by_name(chunks, 'Result').type == 'trait'
+    assert by_name(chunks, 'Handler').metadata['aliased'] == 'Event => Unit'
+    print("✅ Case classes, case objects and companion objects extracted")
+
+
+def test_implicits():
+    """Implicit and given definitions are tagged with their keyword"""
+    jobs = ScalaChunker().extract_chunks(JOBS, 'src/Jobs.scala')
+    assert {c.name: c.metadata['implicit'] for c in jobs if 'implicit' in c.metadata} == {
+        'ordering': 'implicit', 'fromJson': 'implicit', 'EventOps': 'implicit'}
+    assert by_name(jobs, 'EventOps').metadata['receiver'] == 'Event'
+    
+    chunks = ScalaChunker().extract_chunks(SCALA3, 'src/Stats.scala')
+    int_ord = by_name(chunks, 'intOrd')
+    assert int_ord.type == 'object' and int_ord.metadata['implicit'] == 'given'
+    assert int_ord.metadata['given_type'] == 'Ord[Int]' and int_ord.signature == 'given intOrd: Ord[Int]'
+    assert int_ord.metadata['methods'] == ['compare'] and int_ord.line_end == 12
+    assert by_name(chunks, 'compare', 'method').parent == 'Ord'
+    
+    anonymous = by_name(chunks, 'given_Ord_List')
+    assert anonymous.metadata['given_type'] == 'Ord[List[T]]' and anonymous.metadata['type_params'] == '[T]'
+    assert anonymous.metadata['params'] == [{'name': 'ord', 'type': 'Ord[T]', 'implicit': True}]
+    global_ec = by_name(chunks, 'global')
+    assert global_ec.type == 'property' and global_ec.metadata['given_type'] == 'ExecutionContext'
+    print("✅ implicit and given definitions tagged; anonymous givens named given_Ord_List")
+
+
+def test_extensions():
+    """Scala 3 extension methods record their receiver and are named after it"""
+    chunks = ScalaChunker().extract_chunks(SCALA3, 'src/Stats.scala')
+    
+    shout = by_name(chunks, 'shout')
+    assert shout.type == 'function' and shout.qualified_name == 'String.shout' and shout.parent_class == 'String'
+    assert shout.signature == 'extension (s: String) def shout: String', shout.signature
+    assert shout.doc == 'Makes text louder.', shout.doc
+    
+    second = by_name(chunks, 'second')
+    assert second.metadata['receiver'] == 'List[T]' and second.qualified_name == 'List.second'
+    penultimate = by_name(chunks, 'penultimate')
+    assert penultimate.metadata['params'] == [{'type': 'Ord[T]', 'implicit': True}]
+    assert (penultimate.line_start, penultimate.line_end) == (25, 26)
+    
+    less = by_name(chunks, '<')
+    assert less.parent == 'Ord' and less.metadata['receiver'] == 'T', "extensions in a trait stay in the trait"
+    print("✅ Extension methods found by their receiver (String.shout, List.second)")
+
+
+def test_indentation_syntax():
+    """Braceless bodies end at the first line back at their column, end markers included"""
+    chunks = ScalaChunker().extract_chunks(SCALA3, 'src/Stats.scala')
+    
+    color = by_name(chunks, 'Color')
+    assert color.type == 'enum' and color.metadata['constants'] == ['Red', 'Green', 'Blue']
+    assert color.metadata['methods'] == ['hex'] and color.content.endswith('end Color')
+    
+    stats = by_name(chunks, 'Stats')
+    assert (stats.line_start, stats.line_end) == (34, 48) and stats.content.endswith('end Stats')
+    mean = by_name(chunks, 'mean')
+    assert mean.signature == 'def mean[T: Numeric](xs: Seq[T]): Double' and mean.doc == 'Mean of the values.'
+    assert mean.metadata['context_bounds'] == ['T: Numeric'] and mean.content.endswith('end mean')
+    assert by_name(chunks, 'square').metadata['modifiers'] == ['inline']
+    assert by_name(chunks, 'cache').metadata['modifiers'] == ['lazy']
+    assert by_name(chunks, 'Score').metadata['modifiers'] == ['opaque']
+    lo = by_name(chunks, 'lo')
+    assert lo.metadata['names'] == ['lo', 'hi'] and lo.metadata['modifiers'] == ['private[stats]']
+    window = by_name(chunks, 'Window')
+    assert window.qualified_name == 'Stats.Window' and window.metadata['context_bounds'] == ['V: Ordering', 'V: Show']
+    assert by_name(chunks, 'push').qualified_name == 'Stats.Window.push'
+    assert stats.metadata['methods'] == ['mean', 'square'] and stats.metadata['properties'] == ['cache', 'lo']
+    print("✅ Scala 3 indentation syntax, enums and end markers parsed")
+
+
+def test_packages():
+    """Package blocks and package objects"""
+    code = '''package com.example {
+  package object util {
+    val Version = "1.0"
+  }
+  package jobs {
+    object Main { def main(args: Array[String]): Unit = () }
+  }
+}
+'''
+    chunks = ScalaChunker().extract_chunks(code, 'src/Main.scala')
+    assert [(c.namespace, c.qualified_name) for c in chunks] == [
+        ('com.example', 'util'), ('com.example', 'util.Version'),
+        ('com.example.jobs', 'Main'), ('com.example.jobs', 'Main.main'),
+    ], [(c.namespace, c.qualified_name) for c in chunks]
+    assert chunks[0].metadata['package_object'] and chunks[0].signature == 'package object util'
+    print("✅ Package blocks and package objects extracted")
+
+
+def test_sample_file():
+    """The multi-language sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'all_languages' / 'UserService.scala'
+    chunks = ScalaChunker().extract_chunks(sample.read_text(), 'all_languages/UserService.scala')
+    
+    assert by_name(chunks, 'Repository').metadata['methods'] == ['findById', 'findAll', 'save']
+    assert by_name(chunks, 'userSerializer').metadata['implicit'] == 'implicit'
+    assert by_name(chunks, 'UserService', 'object').metadata['companion']
+    apply = by_name(chunks, 'apply')
+    assert apply.qualified_name == 'UserService.apply' and apply.metadata['params'][1]['implicit']
+    assert by_name(chunks, 'toJson', 'method').qualified_name in ('JsonSerializer.toJson', 'Converters.UserOps.toJson')
+    validate = by_name(chunks, 'validate')
+    assert (validate.line_start, validate.line_end) == (107, 108), (validate.line_start, validate.line_end)
+    assert CONFIG.file_types['scala'].parser_type == 'treesitter'
+    print("✅ Sample file parsed")
+
+
+def test_implicit_search(workdir):
+    """--filter implicit=... finds implicit and given instances"""
+    rag = make_chroma_rag(workdir / "db", "test_scala")
+    rag.add_chunks_batch(ScalaChunker().extract_chunks(JOBS, 'src/Jobs.scala')
+                         + ScalaChunker().extract_chunks(SCALA3, 'src/Stats.scala'))
+    rag._build_keyword_index()
+    
+    names = [r['metadata']['name'] for r in rag.retrieve_context("ordering of events", n_results=20,
+                                                                  filter_expr="implicit=true")]
+    assert sorted(names) == ['EventOps', 'fromJson', 'given_Ord_List', 'global', 'intOrd', 'ordering'], names
+    givens = rag.retrieve_context("ordering", n_results=20, filter_expr="implicit=given AND kind=type")
+    assert sorted(r['metadata']['name'] for r in givens) == ['given_Ord_List', 'intOrd'], givens
+    plain = rag.retrieve_context("event", n_results=50, filter_expr="implicit=false AND name=Event")
+    assert plain and all(r['metadata']['name'] == 'Event' for r in plain), plain
+    print("✅ --filter implicit=true|given selects implicit and given definitions")
+
+
+def main():
+    print("=" * 70)
+    print("SCALA CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_scala_"))
+    
+    tests = [
+        test_classes, test_case_classes, test_implicits, test_extensions, test_indentation_syntax,
+        test_packages, test_sample_file, lambda: test_implicit_search(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
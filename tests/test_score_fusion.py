@@ -0,0 +1,191 @@
+#!/usr/bin/env python3
+"""
+Test script for configurable score fusion
+Hybrid search fuses the vector and BM25 rankings by reciprocal rank (tunable k),
+by a weighted sum of normalized scores, or with a custom Fuser; min_score keeps
+comparing relevance whichever fusion ranks the results. Uses a small
+deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from config import CONFIG
+from helpers import make_rag
+from utils.score_fusion import Fuser, RrfFuser, WeightedFuser, create_fuser
+
+SOURCE = '''package session
+
+// Expire drops the sessions idle for longer than the timeout
+func Expire(store *Store, timeout int) int {
+    dropped := 0
+    for id, s := range store.sessions {
+        if s.idle > timeout {
+            delete(store.sessions, id)
+            dropped++
+        }
+    }
+    return dropped
+}
+
+// SignIn opens a session for the user
+func SignIn(user string) *Session {
+    return open(user)
+}
+
+// Refresh extends the session timeout of the user
+func Refresh(s *Session, timeout int) {
+    s.deadline = now() + timeout
+}
+
+// Render draws the account page
+func Render(a *Account) string {
+    return page(a)
+}
+'''
+
+
+class LexicalFirst(Fuser):
+    """Ranks keyword hits above every vector-only hit, each signal ignoring its weight"""
+    
+    name = 'lexical-first'
+    
+    def contributions(self, rankings, weights):
+        fused = {}
+        for rank, hit in enumerate(rankings['vector'], 1):
+            fused.setdefault(hit['id'], {})['vector'] = 1.0 / (1000 + rank)
+        for rank, hit in enumerate(rankings['lexical'], 1):
+            fused.setdefault(hit['id'], {})['lexical'] = 1.0 / rank
+        return fused
+
+
+HITS = {
+    'vector': [{'id': 'a', 'score': 0.9, 'relevance': 0.9}, {'id': 'b', 'score': 0.6, 'relevance': 0.6},
+               {'id': 'c', 'score': 0.3, 'relevance': 0.3}],
+    'lexical': [{'id': 'c', 'score': 12.0, 'relevance': 1.0}, {'id': 'd', 'score': 3.0, 'relevance': 0.25}],
+}
+
+
+def test_fusers():
+    rrf = RrfFuser(k=10).contributions(HITS, {'vector': 0.5, 'lexical': 0.5})
+    assert rrf['a'] == {'vector': 0.5 / 11} and rrf['c'] == {'vector': 0.5 / 13, 'lexical': 0.5 / 11}, rrf
+    
+    weights = {'vector': 0.25, 'lexical': 0.75}
+    minmax = WeightedFuser('minmax').contributions(HITS, weights)
+    assert minmax['a'] == {'vector': 0.25} and minmax['c'] == {'vector': 0.0, 'lexical': 0.75}, minmax
+    assert abs(minmax['b']['vector'] - 0.125) < 1e-9 and minmax['d'] == {'lexical': 0.0}, minmax
+    by_max = WeightedFuser('max').contributions(HITS, weights)
+    assert abs(by_max['c']['vector'] - 0.25 / 3) < 1e-9 and by_max['d'] == {'lexical': 0.75 * 0.25}, by_max
+    absolute = WeightedFuser('absolute').contributions(HITS, weights)
+    assert absolute['c'] == {'vector': 0.25 * 0.3, 'lexical': 0.75}, absolute
+    # A signal with one hit (or all tied) gives it the full weight
+    single = WeightedFuser('minmax').contributions({'vector': [], 'lexical': HITS['lexical'][:1]}, weights)
+    assert single == {'c': {'lexical': 0.75}}, single
+    
+    assert create_fuser().parameters() == {'k': CONFIG.rrf_k}
+    assert repr(create_fuser('weighted', normalization='max')) == "WeightedFuser(normalization='max')"
+    for method, kwargs in (('borda', {}), ('rrf', {'k': 0}), ('weighted', {'normalization': 'zscore'})):
+        try:
+            create_fuser(method, **kwargs)
+        except ValueError:
+            continue
+        raise AssertionError(f"{method} {kwargs} accepted")
+    print("✅ RRF and weighted sums (minmax, max, absolute) give each signal its weighted share")
+
+
+def test_search_fusion(rag):
+    query = dict(n_results=4, lexical_weight=0.5)
+    default = rag.retrieve_context("session timeout", **query)
+    rrf = rag.retrieve_context("session timeout", fusion='rrf', **query)
+    assert [r['id'] for r in default] == [r['id'] for r in rrf], "rrf is the default"
+    
+    # Weighted with absolute normalization: the fused score is the relevance
+    absolute = rag.retrieve_context("session timeout", fusion=WeightedFuser('absolute'), **query)
+    assert all(abs(r['rrf_score'] - r['relevance']) < 1e-9 for r in absolute), absolute
+    assert [r['id'] for r in absolute] == [r['id'] for r in sorted(absolute, key=lambda r: -r['relevance'])]
+    
+    # A larger k flattens the lead of top ranks
+    sharp = rag.retrieve_context("session timeout", fusion=RrfFuser(k=1), **query)
+    flat = rag.retrieve_context("session timeout", fusion=RrfFuser(k=1000), **query)
+    spread = lambda results: results[0]['rrf_score'] / results[-1]['rrf_score']
+    assert spread(sharp) > spread(flat), (spread(sharp), spread(flat))
+    
+    # Searches with different fusions do not share cache entries; streaming fuses the same way
+    custom = rag.retrieve_context("session timeout", fusion=LexicalFirst(), **query)
+    assert all(r['bm25_rank'] is not None for r in custom[:2]) and custom[0]['bm25_rank'] == 1, custom
+    streamed = list(rag.iter_context("session timeout", fusion=LexicalFirst(), **query))
+    assert [r['id'] for r in streamed] == [r['id'] for r in custom]
+    print("✅ Searches fuse with a named method, a configured fuser or a custom one")
+
+
+def test_min_score(rag):
+    """min_score keeps the same results whichever fusion ranks them"""
+    passed = {}
+    for fusion in ('rrf', WeightedFuser('minmax'), WeightedFuser('max'), LexicalFirst()):
+        results = rag.retrieve_context("session timeout", n_results=4, lexical_weight=0.5,
+                                       fusion=fusion, min_score=0.3)
+        assert results and all(r['relevance'] >= 0.3 for r in results), results
+        passed[repr(fusion)] = {r['id'] for r in results}
+    assert len({frozenset(ids) for ids in passed.values()}) == 1, passed
+    print("✅ min_score compares relevance, the same cut under every fusion")
+
+
+def test_configured_fuser(workdir):
+    CONFIG.fusion_method, CONFIG.fusion_normalization = 'weighted', 'absolute'
+    try:
+        rag = make_rag(workdir, "configured")
+    finally:
+        CONFIG.fusion_method, CONFIG.fusion_normalization = 'rrf', 'minmax'
+    assert isinstance(rag.fuser, WeightedFuser) and rag.fuser.normalization == 'absolute'
+    rag.add_chunks_batch(GoChunker().extract_chunks(SOURCE, "session.go"))
+    rag._build_keyword_index()
+    results = rag.retrieve_context("session timeout", n_results=3, lexical_weight=0.5, explain=True)
+    fused = results[0]['explain']['fused']
+    assert fused['method'] == 'weighted' and fused['normalization'] == 'absolute', fused
+    assert abs(fused['vector'] + fused['lexical'] - fused['score']) < 1e-9, fused
+    
+    custom = make_rag(workdir, "configured", fuser=LexicalFirst())
+    fused = custom.retrieve_context("session timeout", n_results=1, explain=True)[0]['explain']['fused']
+    assert fused['method'] == 'lexical-first' and 'k' not in fused, fused
+    print("✅ CONFIG.fusion_method picks the fuser, and explain records its settings")
+
+
+def main():
+    print("=" * 70)
+    print("SCORE FUSION TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_score_fusion_"))
+    rag = make_rag(workdir, "fusion")
+    rag.add_chunks_batch(GoChunker().extract_chunks(SOURCE, "session.go"))
+    rag._build_keyword_index()
+    
+    tests = [
+        test_fusers,
+        lambda: test_search_fusion(rag),
+        lambda: test_min_score(rag),
+        lambda: test_configured_fuser(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,139 @@
+#!/usr/bin/env python3
+"""
+Test script for search explanations
+With explain on, each result says how it was scored: its raw vector and BM25
+scores, their shares of the fused score, the query terms its BM25 score came
+from (with the chunk fields they were found in) and the filters it passed.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from config import CONFIG
+from helpers import make_rag
+from utils.search_explain import matched_filters, term_contributions
+
+SOURCE = '''package session
+
+// Expire drops the sessions idle for longer than the timeout
+func Expire(store *Store, timeout int) int {
+    dropped := 0
+    for id, s := range store.sessions {
+        if s.idle > timeout {
+            delete(store.sessions, id)
+            dropped++
+        }
+    }
+    return dropped
+}
+
+// SignIn opens a session for the user
+func SignIn(user string) *Session {
+    return open(user)
+}
+
+// Render draws the account page
+func Render(a *Account) string {
+    return page(a)
+}
+'''
+
+
+def test_helpers():
+    metadata = {'language': 'go', 'type': 'function', 'kind': 'source', 'filepath': 'auth/session.go',
+                'metadata': '{"imports": [{"path": "sync", "name": "sync", "symbols": ["RWMutex"]}]}'}
+    filters = {'languages': ['go', 'python'], 'kinds': ['function'], 'path_globs': ['auth/*', 'net/*'],
+               'uses': ['sync', 'errors'], 'repos': [], 'exclude_tests': True, 'min_score': 0.3}
+    assert matched_filters(metadata, filters, 0.42) == {
+        'languages': 'go', 'kinds': 'function', 'exclude_tests': 'source', 'path_globs': ['auth/*'],
+        'uses': ['sync'], 'min_score': 0.42}, matched_filters(metadata, filters, 0.42)
+    assert matched_filters(metadata, {'min_score': None, 'exclude_tests': False}) == {}
+    
+    fields = {'name': ['sign', 'in'], 'doc': ['session'], 'body': ['session', 'session', 'user']}
+    terms = term_contributions({'session': 1.5, 'sign': 0.5, 'login': 0.0, 'user': 0.25}, fields, ['sign'], limit=2)
+    assert terms == [{'term': 'session', 'score': 1.5, 'fields': {'doc': 1, 'body': 2}, 'expansion': False},
+                     {'term': 'sign', 'score': 0.5, 'fields': {'name': 1}, 'expansion': True}], terms
+    print("✅ Filters report what they matched; terms are listed by contribution with their fields")
+
+
+def test_explain(rag):
+    plain = rag.retrieve_context("session timeout", n_results=3, lexical_weight=0.5)
+    assert all('explain' not in r for r in plain)
+    
+    results = rag.retrieve_context("idle timeout", n_results=3, lexical_weight=0.5, explain=True,
+                                   languages=['go'], path_globs=['*.go'], min_score=0.0)
+    top = results[0]
+    explanation = top['explain']
+    assert top['metadata']['name'] == 'Expire', top['metadata']['name']
+    assert explanation['vector'] == {'score': top['vector_score'], 'distance': top['distance'],
+                                     'rank': top['vector_rank']}
+    fused = explanation['fused']
+    assert abs(fused['vector'] + fused['lexical'] - top['rrf_score']) < 1e-9 and fused['score'] == top['rrf_score']
+    assert fused['vector'] == 0.5 / (CONFIG.rrf_k + top['vector_rank'])
+    
+    lexical = explanation['lexical']
+    assert lexical['score'] == top['bm25_score'] and lexical['rank'] == top['bm25_rank']
+    assert abs(sum(t['score'] for t in lexical['terms']) - top['bm25_score']) < 1e-9, "term scores do not add up"
+    timeout = next(t for t in lexical['terms'] if t['term'] == 'timeout')
+    assert timeout['fields'] == {'body': 2, 'doc': 1} and not timeout['expansion'], timeout
+    assert explanation['filters'] == {'languages': 'go', 'path_globs': ['*.go'], 'min_score': top['relevance']}
+    
+    render = next(r for r in results if r['metadata']['name'] == 'Render')
+    assert render['explain']['lexical'] is None and render['explain']['fused']['lexical'] == 0.0
+    print("✅ Each result explains its vector and BM25 scores, their fusion and the filters it passed")
+
+
+def test_explain_expansion(rag):
+    results = rag.retrieve_context("login", n_results=1, lexical_weight=1.0, expand_query=True, explain=True)
+    explanation = results[0]['explain']
+    assert results[0]['metadata']['name'] == 'SignIn'
+    terms = {t['term']: t for t in explanation['lexical']['terms']}
+    assert 'login' not in terms and terms['signin']['expansion'], terms
+    assert terms['signin']['fields'] == {'body': 1, 'doc': 1, 'name': 1}, terms['signin']
+    assert abs(sum(t['score'] for t in terms.values()) - results[0]['bm25_score']) < 1e-9
+    assert explanation['vector'] is None and explanation['fused']['vector'] == 0.0
+    
+    diverse = rag.retrieve_context("session", n_results=2, mmr_lambda=0.7, explain=True)
+    assert all(r['explain']['mmr_score'] == r['mmr_score'] for r in diverse)
+    print("✅ Expansion terms are marked and counted at their weight; MMR scores are included")
+
+
+def main():
+    print("=" * 70)
+    print("SEARCH EXPLAIN TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_explain_"))
+    rag = make_rag(workdir, "explain")
+    rag.add_chunks_batch(GoChunker().extract_chunks(SOURCE, "session.go"))
+    rag._build_keyword_index()
+    
+    tests = [
+        test_helpers,
+        lambda: test_explain(rag),
+        lambda: test_explain_expansion(rag),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,137 @@
+#!/usr/bin/env python3
+"""
+Test script for searching within a directory subtree (scope)
+A scope covers the files under a directory and nothing beside it (internal/auth
+is not internal/authz); it resolves to the files in scope before the vector
+search, which only looks at their chunks. Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.path_scope import in_scope, normalize_scope, normalize_scopes, paths_in_scope
+from utils.state_manager import StateManager
+
+
+FILES = {
+    'internal/auth/session.go': "package auth\n\n// ValidateToken checks a session token\nfunc ValidateToken(token string) bool { return token != \"\" }\n",
+    'internal/auth/jwt/jwt.go': "package jwt\n\n// ParseToken decodes a signed token\nfunc ParseToken(token string) string { return token }\n",
+    'internal/authz/policy.go': "package authz\n\n// AllowToken checks a token against the policy\nfunc AllowToken(token string) bool { return true }\n",
+    'cmd/server/main.go': "package main\n\n// RefreshToken renews the token of the server\nfunc RefreshToken(token string) string { return token }\n",
+}
+
+
+class QuerySpy:
+    """Wraps a store and records the where clause of each vector query"""
+    
+    def __init__(self, store):
+        self.store = store
+        self.wheres = []
+    
+    def query(self, **kwargs):
+        self.wheres.append(kwargs.get('where'))
+        return self.store.query(**kwargs)
+    
+    def __getattr__(self, name):
+        return getattr(self.store, name)
+
+
+def files_of(results):
+    return {result['metadata']['filepath'] for result in results}
+
+
+def test_scope_paths():
+    assert normalize_scope('./internal/auth/') == 'internal/auth'
+    assert normalize_scope('internal\\auth') == 'internal/auth'
+    assert normalize_scope('.') == '' and normalize_scopes(['internal', '']) == []
+    for bad in ('/etc', '../outside', 'internal/../../x'):
+        try:
+            normalize_scope(bad)
+            assert False, f"'{bad}' was accepted"
+        except ValueError:
+            pass
+    
+    paths = sorted(FILES) + ['internal/auth', 'internal/auth-old/x.go']
+    paths.sort()
+    assert paths_in_scope(paths, 'internal/auth') == ['internal/auth', 'internal/auth/jwt/jwt.go',
+                                                      'internal/auth/session.go'], paths_in_scope(paths, 'internal/auth')
+    assert paths_in_scope(paths, 'internal/au') == []
+    assert in_scope('internal/auth/session.go', ['internal']) and not in_scope('internal/authz/policy.go', ['internal/auth'])
+    print("✅ Scopes are relative directories; a prefix of a name is not a subtree")
+
+
+def test_scoped_search(workdir: Path):
+    source = workdir / "tree"
+    for rel_path, text in FILES.items():
+        (source / rel_path).parent.mkdir(parents=True, exist_ok=True)
+        (source / rel_path).write_text(text)
+    rag = make_rag(workdir, "scope")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "scope-state.db")))
+    indexer.index_directory(str(source), parallel=False)
+    
+    everywhere = files_of(rag.retrieve_context("token", n_results=20))
+    assert {'internal/authz/policy.go', 'cmd/server/main.go'} <= everywhere, everywhere
+    
+    scoped = files_of(rag.retrieve_context("token", n_results=20, scope="internal/auth"))
+    assert scoped == {'internal/auth/session.go', 'internal/auth/jwt/jwt.go'}, scoped
+    both = files_of(rag.retrieve_context("token", n_results=20, scope=["internal/auth/jwt", "cmd"]))
+    assert both == {'internal/auth/jwt/jwt.go', 'cmd/server/main.go'}, both
+    narrowed = files_of(rag.retrieve_context("token", n_results=20, scope="internal", path_globs=["*policy*"]))
+    assert narrowed == {'internal/authz/policy.go'}, narrowed
+    assert rag.retrieve_context("token", scope="internal/nothing") == []
+    
+    # The vector store is asked for the files in scope only
+    spy = QuerySpy(rag.collection)
+    rag.collection = spy
+    rag.retrieve_context("session token", n_results=3, scope="internal/auth/", lexical_weight=0.0)
+    assert spy.wheres[-1] == {'filepath': {'$in': ['internal/auth/jwt/jwt.go', 'internal/auth/session.go']}}, spy.wheres
+    rag.collection = spy.store
+    
+    explained = rag.retrieve_context("token", n_results=1, scope=["internal/auth"], explain=True)
+    assert explained[0]['explain']['filters']['scope'] == ['internal/auth'], explained[0]['explain']['filters']
+    
+    # A file indexed later is in scope at once (the paths are re-read per index version)
+    (source / 'internal/auth/oauth.go').write_text("package auth\n\n// ExchangeToken trades a code for a token\nfunc ExchangeToken(code string) string { return code }\n")
+    indexer.update_index(str(source), parallel=False)
+    scoped = files_of(rag.retrieve_context("token", n_results=20, scope="internal/auth"))
+    assert 'internal/auth/oauth.go' in scoped, scoped
+    
+    try:
+        rag.retrieve_context("token", scope="../elsewhere")
+        assert False, "a scope outside the root was accepted"
+    except ValueError:
+        pass
+    print("✅ Scoped searches only return, and only vector-search, files under the scope")
+
+
+def main():
+    print("=" * 70)
+    print("SEARCH SCOPE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="search_scope_"))
+    tests = [test_scope_paths, lambda: test_scoped_search(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,185 @@
+#!/usr/bin/env python3
+"""
+Test script for secret scanning during indexing
+Hardcoded keys, tokens and passwords are found by pattern and entropy, then
+redacted in the indexed text or their chunks skipped, while the files on disk
+stay as they were; ordinary code is left alone.
+The secrets below are assembled at run time, so the file itself holds none.
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from indexer import ChromeIndexer
+from utils.secret_scan import find_secrets, redact_secrets, scan_chunk, shannon_entropy
+from utils.state_manager import StateManager
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+AWS_KEY = 'AKIA' + 'Q3EXAMPLE7KEYID2'
+GITHUB_TOKEN = 'ghp_' + 'x7Kd9Qm2Lp4Rt8Vz' * 3
+PEM = '-----BEGIN RSA ' + 'PRIVATE KEY-----\nMIIEowIBAAKCAQEA7x\n-----END RSA ' + 'PRIVATE KEY-----'
+PASSWORD = 'hunter2' + '-correct'
+RANDOM = 'q8ZrT2xY9pLm' + 'W4vB7nK3sJ6d'
+
+SOURCE = f'''package cloud
+
+// Region is the default deployment region
+const Region = "eu-west-1"
+
+// NewClient connects with the service account
+func NewClient() *Client {{
+    return &Client{{
+        KeyID:  "{AWS_KEY}",
+        Seed:   "{RANDOM}",
+    }}
+}}
+
+// Login signs in the operator
+func Login(c *Client) error {{
+    password := "{PASSWORD}"
+    return c.auth(password)
+}}
+
+// Render draws the status page
+func Render(c *Client) string {{
+    return c.page("token_key")
+}}
+'''
+
+
+class RecordingRAG:
+    """Stands in for the vector database and records the chunks inserted"""
+    
+    embedder = 'recording'
+    
+    def __init__(self):
+        self.inserted = []
+    
+    def validate_embedder(self):
+        return 1
+    
+    def add_chunks_batch(self, chunks):
+        self.inserted.extend(chunks)
+        return len(chunks)
+    
+    def delete_file_chunks(self, filepath, repo=None):
+        return 0
+    
+    def record_source_root(self, path, repo=None):
+        pass
+
+
+def kinds(text):
+    return [finding.kind for finding in find_secrets(text)]
+
+
+def test_patterns():
+    assert kinds(f'key = "{AWS_KEY}"') == ['aws_access_key']
+    assert kinds(f"token: {GITHUB_TOKEN}") == ['github_token']
+    assert kinds(f"const key = `{PEM}`") == ['private_key']
+    assert kinds(f'password := "{PASSWORD}"') == ['credential']
+    assert kinds(f'DB_PASSWORD="{PASSWORD}"') == ['credential']
+    assert kinds(f'seed: "{RANDOM}"') == ['high_entropy']
+    assert shannon_entropy(RANDOM) > 4.0 > shannon_entropy('a1a1a1a1a1a1a1a1a1a1a1a1')
+    assert kinds('seed: "a1a1a1a1a1a1a1a1a1a1a1a1"') == [] and kinds('id: "12345678901234567890"') == []
+    print("✅ Key formats, credential assignments and high-entropy literals are found")
+
+
+def test_not_secrets():
+    for text in ['token_key: "access_token"', 'api_key = os.environ["API_KEY"]',
+                 'password = "${DB_PASSWORD}"', 'name := "SessionManagerFactoryImpl"',
+                 'max_tokens = 512', 'path = "github.com/pkg/errors/wrap"']:
+        assert kinds(text) == [], f"{text!r}: {kinds(text)}"
+    for path in sorted(SAMPLES.iterdir()):
+        findings = find_secrets(path.read_text(encoding='utf-8', errors='ignore'))
+        assert not findings, f"{path.name}: {findings}"
+    print("✅ Names, placeholders and the sample sources are not taken for secrets")
+
+
+def test_redact():
+    text = f'KeyID: "{AWS_KEY}", Seed: "{RANDOM}"'
+    redacted = redact_secrets(text, find_secrets(text))
+    assert redacted == 'KeyID: "[REDACTED:aws_access_key]", Seed: "[REDACTED:high_entropy]"', redacted
+    
+    chunk = next(c for c in GoChunker().extract_chunks(SOURCE, "cloud/client.go") if c.name == 'Login')
+    records = scan_chunk(chunk, redact=True)
+    assert records == [{'filepath': 'cloud/client.go', 'line': chunk.line_start + 1, 'kind': 'credential',
+                        'field': 'content'}], records
+    assert PASSWORD not in chunk.content and '[REDACTED:credential]' in chunk.content
+    print("✅ Redaction replaces each secret with a marker and reports its line, not its text")
+
+
+def run(workdir, mode):
+    source = workdir / mode
+    (source / "cloud").mkdir(parents=True)
+    (source / "cloud" / "client.go").write_text(SOURCE)
+    rag = RecordingRAG()
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / f"{mode}.db")), secrets=mode)
+    stats = indexer.index_directory(str(source), parallel=False)
+    return source, rag, indexer, stats
+
+
+def test_index_redact(workdir):
+    source, rag, indexer, stats = run(workdir, 'redact')
+    stored = {chunk.name: chunk for chunk in rag.inserted}
+    assert {'Region', 'NewClient', 'Login', 'Render'} <= set(stored), sorted(stored)
+    text = ' '.join(chunk.content + (chunk.signature or '') for chunk in rag.inserted)
+    assert AWS_KEY not in text and RANDOM not in text and PASSWORD not in text
+    assert stored['Render'].content.count('REDACTED') == 0 and '"eu-west-1"' in stored['Region'].content
+    assert stats['secrets_found'] == 3 and stats['chunks_redacted'] == 2 and stats['chunks_skipped_secrets'] == 0
+    assert sorted(f['kind'] for f in stats['secret_findings']) == ['aws_access_key', 'credential', 'high_entropy']
+    assert AWS_KEY in (source / "cloud" / "client.go").read_text(), "the source file was changed"
+    print(f"✅ Indexing with 'redact' stores no secret and reports {stats['secrets_found']} of them")
+
+
+def test_index_skip(workdir):
+    source, rag, indexer, stats = run(workdir, 'skip')
+    assert sorted(chunk.name for chunk in rag.inserted if chunk.type != 'package') == ['Region', 'Render'], \
+        [chunk.name for chunk in rag.inserted]
+    assert stats['chunks_skipped_secrets'] == 2 and stats['chunks_redacted'] == 0
+    assert not indexer.state_manager.should_process(str(source / "cloud" / "client.go")), \
+        "a file with skipped chunks was not recorded as indexed"
+    
+    _, rag, _, stats = run(workdir, 'off')
+    assert stats['secrets_found'] == 0 and any(AWS_KEY in chunk.content for chunk in rag.inserted)
+    print("✅ Indexing with 'skip' leaves out the chunks holding secrets; 'off' indexes them as they are")
+
+
+def main():
+    print("=" * 70)
+    print("SECRET SCAN TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_secrets_"))
+    
+    tests = [
+        test_patterns,
+        test_not_secrets,
+        test_redact,
+        lambda: test_index_redact(workdir),
+        lambda: test_index_skip(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
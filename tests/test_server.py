@@ -0,0 +1,269 @@
+#!/usr/bin/env python3
+"""
+Test script for the HTTP query server
+Serves a small temporary index with a deterministic embedder on localhost
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+import threading
+import time
+import urllib.error
+import urllib.request
+from concurrent.futures import ThreadPoolExecutor
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import HashEmbedder, make_chroma_rag
+from indexer import ChromeIndexer
+from server import RAGServer
+from utils.state_manager import StateManager
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+
+def request(url, path, body=None):
+    data = json.dumps(body).encode() if body is not None else None
+    req = urllib.request.Request(url + path, data=data, method='POST' if data is not None else 'GET',
+                                 headers={'Content-Type': 'application/json'})
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            return response.status, json.loads(response.read())
+    except urllib.error.HTTPError as e:
+        return e.code, json.loads(e.read())
+
+
+def test_health(url, _):
+    status, body = request(url, '/health')
+    assert status == 200 and body['status'] == 'ok' and body['chunks'] > 0, body
+    print("✅ GET /health reports the loaded index")
+
+
+def test_schema(url, _):
+    status, schema = request(url, '/schema')
+    assert status == 200 and schema['title'] == 'SearchResponse', schema
+    result = schema['$defs']['SearchResult']
+    body = request(url, '/search', {'query': 'authenticate', 'top_k': 3})[1]
+    for record in body['results']:
+        assert set(result['required']) <= set(record) <= set(result['properties']), sorted(record)
+    print("✅ GET /schema describes the records /search returns")
+
+
+def test_search(url, _):
+    status, body = request(url, '/search', {'query': 'authenticate', 'top_k': 3,
+                                            'languages': ['go'], 'kinds': ['method']})
+    assert status == 200, body
+    top = body['results'][0]
+    assert top['name'] == 'Authenticate' and top['filepath'] == 'complex.go', top
+    assert top['line_start'] < top['line_end'] and top['score'] is not None
+    assert all(r['type'] == 'method' for r in body['results'])
+    
+    source = (SAMPLES / "complex.go").read_bytes()
+    assert source[top['byte_start']:top['byte_end']].decode('utf-8') == top['content']
+    assert top['citation'] == f"complex.go#L{top['line_start']}-L{top['line_end']}"
+    print("✅ POST /search returns ranked chunks with scores, line/byte ranges and citations")
+
+
+def test_search_explain(url, _):
+    query = {'query': 'authenticate', 'top_k': 2, 'languages': ['go']}
+    assert all('explain' not in r for r in request(url, '/search', query)[1]['results'])
+    status, body = request(url, '/search?explain=true', query)
+    assert status == 200, body
+    explanation = body['results'][0]['explain']
+    assert explanation['filters'] == {'languages': 'go'}, explanation['filters']
+    assert explanation['vector']['rank'] == 1 and explanation['fused']['score'] == body['results'][0]['score']
+    assert request(url, '/search', dict(query, explain=True))[1] == body
+    assert request(url, '/search?explain=maybe', query)[0] == 400
+    print("✅ POST /search?explain=true adds how each result was scored")
+
+
+def test_search_filter(url, _):
+    expression = "(language=go OR language=rust) AND kind=method AND NOT name:*ogout"
+    status, body = request(url, '/search', {'query': 'authenticate logout', 'top_k': 10, 'filter': expression})
+    assert status == 200 and body['results'], body
+    assert {r['language'] for r in body['results']} <= {'go', 'rust'}, body['results']
+    assert all(r['type'] == 'method' and r['name'].lower() != 'logout' for r in body['results']), body['results']
+    
+    tree = {'and': [{'language': ['go', 'rust']}, {'kind': 'method'}, {'not': {'name': '*ogout'}}]}
+    assert request(url, '/search', {'query': 'authenticate logout', 'top_k': 10, 'filter': tree})[1] == body
+    
+    status, body = request(url, '/search', {'query': 'x', 'filter': 'language=go AND (kind=method'})
+    assert status == 400 and "Expected ')'" in body['error'] and 'position' in body['error'], body
+    status, body = request(url, '/search', {'query': 'x', 'filter': {'and': [{'lang': 'go'}]}})
+    assert status == 400 and "Unknown filter field 'lang'" in body['error'], body
+    
+    plain = request(url, '/search', {'query': 'authenticate logout', 'top_k': 10})[1]['results']
+    status, body = request(url, '/search', {'query': 'authenticate logout', 'top_k': 10,
+                                            'boost_kinds': {'type': 100}})
+    assert status == 200 and body['results'][0]['type'] in ('struct', 'class', 'interface', 'enum', 'record'), body
+    assert len(body['results']) == len(plain), "boosting reorders without filtering"
+    for bad in ({'type': -1}, ['type'], {'type': 'high'}):
+        status, body = request(url, '/search', {'query': 'x', 'boost_kinds': bad})
+        assert status == 400 and "'boost_kinds'" in body['error'], body
+    
+    status, body = request(url, '/search', {'query': 'authenticate', 'scope': 'complex.go'})
+    assert status == 200 and body['results'], body
+    assert request(url, '/search', {'query': 'authenticate', 'scope': ['nowhere']})[1]['results'] == []
+    for bad in ('../outside', 3, ['ok', 1]):
+        status, body = request(url, '/search', {'query': 'x', 'scope': bad})
+        assert status == 400 and 'cope' in body['error'], body
+    
+    status, body = request(url, '/search', {'query': 'authenticate', 'kinds': ['method'], 'with_neighbors': 'ids'})
+    assert status == 200 and body['results'][0]['neighbors'], body
+    status, body = request(url, '/search', {'query': 'x', 'with_neighbors': 'all'})
+    assert status == 400 and "'with_neighbors'" in body['error'], body
+    print("✅ POST /search takes a boolean filter as a string or JSON tree, kind boosts, scopes and neighbors; bad ones are a 400")
+
+
+def stream(url, body):
+    req = urllib.request.Request(url + '/search', data=json.dumps(body).encode(), method='POST',
+                                 headers={'Content-Type': 'application/json', 'Accept': 'application/x-ndjson'})
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            lines = [json.loads(line) for line in response if line.strip()]
+            return response.status, response.headers['Content-Type'], lines
+    except urllib.error.HTTPError as e:
+        return e.code, e.headers['Content-Type'], [json.loads(e.read())]
+
+
+def test_stream_search(url, _):
+    query = {'query': 'user password login', 'top_k': 4}
+    status, content_type, lines = stream(url, query)
+    assert status == 200 and content_type == 'application/x-ndjson', (status, content_type)
+    assert lines == request(url, '/search', query)[1]['results'], lines
+    assert len(lines) == 4 and all(line['content'] != "Content not stored in RAM" for line in lines)
+    
+    status, _, lines = stream(url, {'query': 'user', 'path_globs': ['nowhere/*']})
+    assert status == 200 and lines == [], lines
+    status, content_type, lines = stream(url, {'top_k': 3})
+    assert status == 400 and content_type == 'application/json' and 'error' in lines[0]
+    print("✅ POST /search streams NDJSON results when asked to")
+
+
+class GatedRAG:
+    """Yields one result, then holds the rest back until the client has read it"""
+    
+    def __init__(self):
+        self.first_read = threading.Event()
+    
+    def iter_context(self, query, n_results=5, **filters):
+        for i in range(n_results):
+            if i == 1:
+                assert self.first_read.wait(5), "first line was not flushed to the client"
+            yield {'id': f'c{i}', 'content': f'chunk {i}', 'rrf_score': 1.0 / (i + 1),
+                   'metadata': {'filepath': 'a.go', 'name': f'F{i}', 'line_start': i + 1, 'line_end': i + 1}}
+
+
+def test_stream_flushes(url, _):
+    rag = GatedRAG()
+    server = RAGServer(('127.0.0.1', 0), rag)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    try:
+        req = urllib.request.Request(f"http://127.0.0.1:{server.server_address[1]}/search",
+                                     data=json.dumps({'query': 'x', 'top_k': 3}).encode(), method='POST',
+                                     headers={'Accept': 'application/x-ndjson'})
+        with urllib.request.urlopen(req, timeout=10) as response:
+            first = json.loads(response.readline())
+            rag.first_read.set()
+            rest = [json.loads(line) for line in response]
+    finally:
+        server.shutdown()
+    assert first['name'] == 'F0' and [r['name'] for r in rest] == ['F1', 'F2'], (first, rest)
+    print("✅ Streamed results reach the client before the search has finished")
+
+
+def test_lookup(url, _):
+    status, body = request(url, '/lookup', {'name': 'sessionmanager'})
+    assert status == 200, body
+    names = [(symbol['name'], symbol['match']) for symbol in body['symbols']]
+    assert names[:2] == [('SessionManager', 'exact'), ('NewSessionManager', 'prefix')], names
+    top = body['symbols'][0]
+    assert top['kind'] == 'type' and top['filepath'] == 'complex.go' and top['line_start'] == 63, top
+    assert top['citation'] == f"complex.go#L63-L{top['line_end']}"
+    
+    status, body = request(url, '/lookup', {'name': 'authentcate', 'kinds': ['method']})
+    assert [(s['qualified_name'], s['match']) for s in body['symbols']] == [('AdminUser.Authenticate', 'fuzzy')], body
+    assert request(url, '/lookup', {'name': ''})[0] == 400
+    print("✅ POST /lookup finds symbols by name without an embedding call")
+
+
+def test_embed(url, _):
+    status, body = request(url, '/embed', {'text': 'authenticate user'})
+    assert status == 200, body
+    assert body['dimensions'] == len(body['embedding']) == 256 and body['model'] == 'test-hash', body
+    assert body['embedding'] == HashEmbedder().embed(['authenticate user'])[0]
+    assert request(url, '/embed', {'text': ' '})[0] == 400
+    print("✅ POST /embed returns the query vector searches compare with the index")
+
+
+def test_bad_request(url, _):
+    assert request(url, '/search', {'top_k': 3})[0] == 400
+    assert request(url, '/search', {'query': 'x', 'languages': 'go'})[0] == 400
+    assert request(url, '/nope', {})[0] == 404
+    print("✅ Invalid requests rejected with 400/404")
+
+
+def test_concurrent_searches(url, _):
+    with ThreadPoolExecutor(max_workers=8) as pool:
+        statuses = list(pool.map(lambda q: request(url, '/search', {'query': q})[0],
+                                 ['user', 'login', 'admin', 'password'] * 4))
+    assert statuses == [200] * 16, statuses
+    print("✅ Concurrent searches served")
+
+
+def test_reindex(url, source):
+    (Path(source) / "extra.go").write_text("package main\n\nfunc RotateCredentials() error {\n\treturn nil\n}\n")
+    status, body = request(url, '/reindex', {})
+    assert status == 202, body
+    for _ in range(100):
+        health = request(url, '/health')[1]['reindex']
+        if not health['running'] and health['last']:
+            break
+        time.sleep(0.1)
+    assert health['last']['status'] == 'ok' and health['last']['stats']['chunks_added'] >= 1, health
+    names = [r['name'] for r in request(url, '/search', {'query': 'RotateCredentials', 'top_k': 3})[1]['results']]
+    assert 'RotateCredentials' in names, names
+    print("✅ POST /reindex updates the index in the background")
+
+
+def main():
+    print("=" * 70)
+    print("HTTP SERVER TEST")
+    print("=" * 70)
+    
+    workdir = tempfile.mkdtemp(prefix="rag_server_")
+    source = Path(workdir) / "src"
+    source.mkdir()
+    shutil.copy(SAMPLES / "complex.go", source / "complex.go")
+    
+    rag = make_chroma_rag(Path(workdir) / "db", "test_server")
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(Path(workdir) / "state.db")))
+    indexer.update_index(str(source), parallel=False)
+    
+    server = RAGServer(('127.0.0.1', 0), rag, source_path=str(source), allow_reindex=True, indexer=indexer)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    
+    tests = [test_health, test_schema, test_search, test_search_explain, test_search_filter, test_stream_search, test_stream_flushes, test_lookup, test_embed, test_bad_request, test_concurrent_searches, test_reindex]
+    failed = 0
+    for test in tests:
+        try:
+            test(url, str(source))
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    server.shutdown()
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
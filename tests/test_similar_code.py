@@ -0,0 +1,190 @@
+#!/usr/bin/env python3
+"""
+Test script for similar code
+similar_to finds the chunks nearest to an indexed chunk by its stored vector,
+without embedding anything: the chunk and its other parts are left out,
+near-duplicates are marked (or dropped), and in a deduplicated index the
+exact copies of the chunk come first. Covers rag.similar_to, the similar
+command's validation and POST /similar. Uses a small deterministic embedder
+that counts its calls
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+import threading
+import urllib.error
+import urllib.request
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+import cli
+from chunkers.base_chunker import CodeChunk
+from config import CONFIG
+from helpers import make_rag
+from server import RAGServer
+
+LOGIN = 'func Login(user string, password string) error { session := openSession(user); return session.check(password) }'
+
+
+def chunk(name, content, filepath, line=1, **fields):
+    return CodeChunk(type='function', name=name, content=content, filepath=filepath, language='go',
+                     line_start=line, line_end=line, **fields)
+
+
+CHUNKS = [
+    chunk('Login', LOGIN, 'auth/login.go', 12),
+    # A copy under another name, a related function, and unrelated code
+    chunk('AdminLogin', LOGIN.replace('func Login', 'func AdminLogin'), 'admin/login.go', 3),
+    chunk('Logout', 'func Logout(user string) error { session := openSession(user); return session.close() }',
+          'auth/logout.go', 5),
+    chunk('ParseURL', 'func ParseURL(raw string) (*URL, error) { return url.Parse(raw) }', 'web/url.go', 1),
+    chunk('RenderPage', 'func RenderPage(page Page) string { return template.Render(page) }', 'web/page.go', 1),
+]
+
+
+def build_rag(workdir, name, chunks=CHUNKS, **options):
+    rag = make_rag(workdir, name, **options)
+    rag.add_chunks_batch(chunks)
+    rag._build_keyword_index()
+    return rag
+
+
+def request(url, path, body):
+    req = urllib.request.Request(url + path, data=json.dumps(body).encode(), method='POST',
+                                 headers={'Content-Type': 'application/json'})
+    try:
+        with urllib.request.urlopen(req, timeout=10) as response:
+            return response.status, json.loads(response.read().decode())
+    except urllib.error.HTTPError as e:
+        return e.code, json.loads(e.read().decode())
+
+
+def test_similar(workdir):
+    rag = build_rag(workdir, "similar")
+    calls = rag.embedder.calls
+    results = rag.similar_to('auth/login.go:Login:12', k=3)
+    assert rag.embedder.calls == calls, "the stored vector is used, nothing is embedded"
+    names = [r['metadata']['name'] for r in results]
+    assert names[:2] == ['AdminLogin', 'Logout'] and 'Login' not in names, names
+    scores = [r['vector_score'] for r in results]
+    assert scores == sorted(scores, reverse=True) and all(0 < s <= 1 for s in scores)
+    assert results[0]['near_duplicate'] and not results[1]['near_duplicate'], scores
+    assert results[0]['content'].startswith('func AdminLogin')
+    
+    related = rag.similar_to('auth/login.go:Login:12', k=3, exclude_near_duplicates=True)
+    assert [r['metadata']['name'] for r in related][:1] == ['Logout'] and len(related) == 3, \
+        "near-duplicates make room for the next closest"
+    assert not rag.similar_to('auth/login.go:Login:12', k=2, near_duplicate_similarity=1.0)[0]['near_duplicate']
+    found = rag.similar_to('auth/login.go:Login:12', k=5, filter_expr='path:web/*')
+    assert sorted(r['metadata']['name'] for r in found) == ['ParseURL', 'RenderPage'], found
+    assert len(rag.similar_to('auth/login.go:Login:12', k=10)) == 4, "everything but the chunk itself"
+    assert rag.similar_to('auth/missing.go:Nope:1') is None
+    try:
+        rag.similar_to('auth/login.go:Login:12', k=0)
+        assert False, "k=0, but no error"
+    except ValueError:
+        pass
+    print("✅ similar_to ranks other chunks by the chunk's stored vector and marks near-duplicates")
+
+
+def test_parts(workdir):
+    body = '\n'.join(f'    step{n}(session, user)' for n in range(40))
+    parts = [chunk('Sync', f'func Sync(session Session, user string) {{\n{body}', 'auth/sync.go', 1,
+                   symbol_id='auth/sync.go:Sync:1', part_index=0, part_count=2),
+             chunk('Sync', f'{body}\n}}', 'auth/sync.go', 41, symbol_id='auth/sync.go:Sync:1', part_index=1,
+                   part_count=2)]
+    rag = build_rag(workdir, "similar_parts", CHUNKS + parts)
+    results = rag.similar_to('auth/sync.go:Sync:1', k=5)
+    assert results is not None and 'Sync' not in [r['metadata']['name'] for r in results], \
+        "a symbol_id compares its first part, and its other parts are not similar code"
+    assert rag.similar_to('auth/sync.go:Sync:1#1', k=5)[0]['metadata']['name'] != 'Sync'
+    print("✅ A symbol_id compares its first part, and a symbol's own parts are left out")
+
+
+def test_dedup(workdir):
+    copies = [chunk('Login', LOGIN, 'vendor/a/login.go', 12), chunk('Login', LOGIN, 'vendor/b/login.go', 12)]
+    rag = build_rag(workdir, "similar_dedup", CHUNKS + copies, dedup='exact')
+    results = rag.similar_to('auth/login.go:Login:12', k=4)
+    assert [r['metadata']['filepath'] for r in results[:2]] == ['vendor/a/login.go', 'vendor/b/login.go']
+    assert all(r['vector_score'] == 1.0 and r['near_duplicate'] for r in results[:2])
+    assert results[2]['metadata']['name'] == 'AdminLogin'
+    
+    # A duplicate has no vector of its own: it compares by the stored copy, which is one of its copies
+    results = rag.similar_to('vendor/b/login.go:Login:12', k=3)
+    assert [r['metadata']['filepath'] for r in results[:2]] == ['auth/login.go', 'vendor/a/login.go'], results
+    assert 'duplicates' not in results[0]
+    assert rag.similar_to('vendor/b/login.go:Login:12', k=3, exclude_near_duplicates=True)[0]['metadata']['name'] \
+        == 'Logout'
+    print("✅ In a deduplicated index the exact copies come first, for the stored chunk and its duplicates")
+
+
+def test_server(workdir):
+    rag = build_rag(workdir, "similar_server")
+    server = RAGServer(('127.0.0.1', 0), rag)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        status, body = request(url, '/similar', {'id': 'auth/login.go:Login:12', 'top_k': 2})
+        assert status == 200, body
+        assert [r['name'] for r in body['results']] == ['AdminLogin', 'Logout'], body
+        assert body['results'][0]['near_duplicate'] and body['results'][0]['vector_score'] > 0.9
+        assert body['results'][0]['citation'] == 'admin/login.go#L3-L3'
+        status, body = request(url, '/similar', {'id': 'auth/login.go:Login:12', 'top_k': 1,
+                                                 'exclude_near_duplicates': True, 'filter': 'path:auth/*'})
+        assert status == 200 and [r['name'] for r in body['results']] == ['Logout'], body
+        
+        assert request(url, '/similar', {'id': 'auth/nope.go:X:1'})[0] == 404
+        for bad in ({}, {'id': 'a', 'top_k': 0}, {'id': 'a', 'exclude_near_duplicates': 'yes'},
+                    {'id': 'a', 'filter': 'language='}):
+            assert request(url, '/similar', bad)[0] == 400, bad
+    finally:
+        server.shutdown()
+        server.server_close()
+    print("✅ POST /similar returns similar chunks as result records")
+
+
+def test_settings():
+    saved = CONFIG.near_duplicate_similarity
+    CONFIG.near_duplicate_similarity = 1.5
+    try:
+        problems = cli.setting_problems()
+    finally:
+        CONFIG.near_duplicate_similarity = saved
+    assert any(p.startswith('near_duplicate_similarity:') for p in problems), problems
+    print("✅ config validate reports a near-duplicate similarity out of range")
+
+
+def main():
+    print("=" * 70)
+    print("SIMILAR CODE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_similar_code_"))
+    tests = [
+        lambda: test_similar(workdir),
+        lambda: test_parts(workdir),
+        lambda: test_dedup(workdir),
+        lambda: test_server(workdir),
+        test_settings,
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,213 @@
+#!/usr/bin/env python3
+"""
+Test script for the SQL chunker
+Statements are split on the tokens tree-sitter-sql lexes.
+The search test uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import SqlChunker
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+
+MIGRATION = '''-- +goose Up
+-- Accounts that can sign in
+CREATE TABLE IF NOT EXISTS auth.users (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    motto VARCHAR(100) DEFAULT 'it''s; fine',
+    org_id INT REFERENCES orgs(id) ON DELETE CASCADE,
+    team_id INT,
+    CONSTRAINT fk_team FOREIGN KEY (team_id) REFERENCES teams (id)
+);
+
+CREATE UNIQUE INDEX idx_users_email ON auth.users USING btree (lower(email));
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION touch_user(uid BIGINT) RETURNS void AS $body$
+BEGIN
+    UPDATE auth.users SET motto = 'a; b' WHERE id = uid; -- ; in a comment
+    INSERT INTO audit_log (user_id) VALUES (uid);
+END;
+$body$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE MATERIALIZED VIEW active_users AS
+SELECT u.id FROM auth.users u JOIN sessions s ON s.user_id = u.id
+WHERE EXTRACT(YEAR FROM s.started_at) > 2020;
+
+CREATE TYPE mood AS ENUM ('sad', 'ok');
+
+-- +goose Down
+DROP TABLE auth.users;
+'''
+
+QUERIES = '''-- name: GetUser :one
+-- Fetch a user by id
+SELECT * FROM auth.users WHERE id = $1;
+
+-- name: ListRecentPosts :many
+WITH recent AS (SELECT * FROM posts WHERE created_at > now() - interval '1 day')
+SELECT r.* FROM recent r, auth.users u WHERE r.user_id = u.id;
+
+BEGIN;
+INSERT INTO posts (user_id, title) VALUES (1, 'hello; world');
+COMMIT;
+'''
+
+MYSQL = '''DELIMITER //
+-- Adds a user unless the name is taken
+CREATE PROCEDURE add_user(IN p_name VARCHAR(50))
+BEGIN
+    IF p_name IS NULL THEN
+        SELECT 'missing';
+    END IF;
+    CASE WHEN p_name = '' THEN SELECT 1; ELSE SELECT 2; END CASE;
+    INSERT INTO users (name) VALUES (p_name);
+END //
+DELIMITER ;
+
+SELECT COUNT(*) FROM users;
+'''
+
+
+def by_name(chunks, name):
+    return next(c for c in chunks if c.name == name)
+
+
+def test_tables():
+    chunks = SqlChunker().extract_chunks(MIGRATION, 'migrations/001_users.sql')
+    users = by_name(chunks, 'users')
+    assert users.type == 'table' and users.namespace == 'auth', (users.type, users.namespace)
+    assert (users.line_start, users.line_end) == (3, 10), (users.line_start, users.line_end)
+    assert users.signature == 'CREATE TABLE auth.users (id, email, motto, org_id, team_id)', users.signature
+    assert users.doc == 'Accounts that can sign in', users.doc
+    columns = {c['name']: c for c in users.metadata['columns']}
+    assert columns['email'] == {'name': 'email', 'type': 'TEXT', 'not_null': True, 'unique': True}, columns['email']
+    assert columns['motto']['default'] == "'it''s; fine'"
+    assert users.metadata['primary_key'] == ['id']
+    assert users.metadata['references'] == ['orgs', 'teams'], users.metadata
+    print("✅ CREATE TABLE chunks carry columns, keys and references")
+
+
+def test_objects():
+    chunks = SqlChunker().extract_chunks(MIGRATION, 'migrations/001_users.sql')
+    touch = by_name(chunks, 'touch_user')
+    assert touch.type == 'function' and (touch.line_start, touch.line_end) == (15, 20)
+    assert touch.signature == 'CREATE FUNCTION touch_user(uid BIGINT) RETURNS void', touch.signature
+    assert touch.metadata['language'] == 'plpgsql' and touch.metadata['tables'] == ['auth.users', 'audit_log']
+    index = by_name(chunks, 'idx_users_email')
+    assert index.metadata['table'] == 'auth.users' and index.metadata['unique'] and index.metadata['columns'] == ['lower(email)']
+    view = by_name(chunks, 'active_users')
+    assert view.type == 'view' and view.metadata['materialized'], view.metadata
+    assert view.metadata['tables'] == ['auth.users', 'sessions'], view.metadata['tables']
+    assert by_name(chunks, 'mood').metadata['values'] == ['sad', 'ok']
+    assert by_name(chunks, 'DROP TABLE auth.users').metadata['migration'] == 'down'
+    assert by_name(chunks, 'users').metadata['migration'] == 'up'
+    print("✅ Functions, indexes, views and types are named chunks")
+
+
+def test_named_queries():
+    chunks = SqlChunker().extract_chunks(QUERIES, 'queries/users.sql')
+    assert [c.name for c in chunks] == ['GetUser', 'ListRecentPosts', 'INSERT posts'], [c.name for c in chunks]
+    get_user = by_name(chunks, 'GetUser')
+    assert get_user.type == 'query' and get_user.metadata['command'] == 'one'
+    assert get_user.doc == 'Fetch a user by id' and get_user.line_start == 3
+    recent = by_name(chunks, 'ListRecentPosts')
+    assert recent.metadata['tables'] == ['posts', 'auth.users'], "CTE names are not tables"
+    assert (recent.line_start, recent.line_end) == (6, 7)
+    print("✅ sqlc-style comments name the query below them")
+
+
+def test_procedure_bodies():
+    chunker = SqlChunker()
+    chunks = chunker.extract_chunks(MYSQL, 'procedures.sql')
+    assert [(c.name, c.line_start, c.line_end) for c in chunks] == [('add_user', 3, 10), ('SELECT users', 13, 13)], \
+        [(c.name, c.line_start, c.line_end) for c in chunks]
+    assert by_name(chunks, 'add_user').doc == 'Adds a user unless the name is taken'
+
+    # Without DELIMITER, BEGIN ... END keeps the body's semicolons inside the procedure
+    chunks = chunker.extract_chunks(MYSQL.replace('DELIMITER //\n', '').replace('END //', 'END;')
+                                    .replace('DELIMITER ;\n', ''), 'procedures.sql')
+    assert [c.name for c in chunks] == ['add_user', 'SELECT users'], [c.name for c in chunks]
+    assert chunker.diagnostics == []
+    print("✅ Procedure bodies stay whole")
+
+
+def test_unterminated():
+    chunker = SqlChunker()
+    chunks = chunker.extract_chunks("CREATE TABLE a (id INT);\nSELECT 'never closed FROM a;\n", 'broken.sql')
+    assert [(c.type, c.line_start) for c in chunks] == [('table', 1), ('statement', 2)], [c.name for c in chunks]
+    assert chunker.diagnostics and chunker.diagnostics[0]['line'] == 2, chunker.diagnostics
+    print("✅ Unterminated literals are reported, earlier statements kept")
+
+
+def test_sample_file():
+    sample = Path(__file__).parent.parent / 'test_samples' / 'data' / 'schema.sql'
+    chunks = SqlChunker().extract_chunks(sample.read_text(), 'data/schema.sql')
+    assert [(c.type, c.name) for c in chunks] == [
+        ('table', 'users'), ('index', 'idx_users_email'), ('function', 'get_user_count'),
+        ('statement', 'SELECT users'), ('statement', 'INSERT users'),
+    ], [(c.type, c.name) for c in chunks]
+    assert by_name(chunks, 'get_user_count').metadata['tables'] == ['users'], "SELECT INTO a variable is not a table"
+    print("✅ Sample file parsed")
+
+
+def test_table_search():
+    workdir = Path(tempfile.mkdtemp(prefix="sql_"))
+    try:
+        source = workdir / "db"
+        source.mkdir()
+        (source / "001_users.sql").write_text(MIGRATION)
+        (source / "queries.sql").write_text(QUERIES)
+        rag = make_rag(workdir, "sql")
+        ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db"))).index_directory(str(source), parallel=False)
+        rag._build_keyword_index()
+
+        found = [(r['metadata']['type'], r['metadata']['name']) for r in rag.retrieve_context("users table schema", n_results=3)]
+        assert ('table', 'users') in found, found
+        found = [r['metadata']['name'] for r in rag.retrieve_context("users table schema", n_results=3, kinds=["table"],
+                                                                      lexical_weight=1.0)]
+        assert found[0] == 'users', found
+        found = [r['metadata']['name'] for r in rag.retrieve_context("motto", n_results=3, kinds=["table"],
+                                                                      lexical_weight=1.0)]
+        assert found == ['users'], "column names are indexed"
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    print("✅ Searching for a table's schema finds its CREATE TABLE")
+
+
+def main():
+    print("=" * 70)
+    print("SQL CHUNKER TEST")
+    print("=" * 70)
+
+    tests = [
+        test_tables, test_objects, test_named_queries, test_procedure_bodies,
+        test_unterminated, test_sample_file, test_table_search
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
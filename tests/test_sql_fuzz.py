@@ -0,0 +1,138 @@
+#!/usr/bin/env python3
+"""
+Fuzz test for the SQL statement splitter and the statement analyses
+Truncated and randomly mutated SQL must split quickly into statements whose
+chunks match the source: the splitting and naming run on tree-sitter-sql's
+tokens, but are written by hand
+"""
+
+import random
+import sys
+import time
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import SqlChunker
+
+
+SAMPLE = (Path(__file__).parent.parent / 'test_samples' / 'data' / 'schema.sql').read_text() + '''
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION touch(uid BIGINT) RETURNS void AS $body$
+BEGIN
+    UPDATE users SET motto = 'a; b' WHERE id = uid;
+END;
+$body$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DELIMITER //
+CREATE PROCEDURE add_user(IN p_name VARCHAR(50))
+BEGIN
+    IF p_name IS NULL THEN SELECT 'missing'; END IF;
+    INSERT INTO users (name) VALUES (p_name);
+END //
+DELIMITER ;
+
+-- name: ListRecent :many
+WITH recent AS (SELECT * FROM posts) SELECT r.* FROM recent r, users u WHERE r.user_id = u.id;
+'''
+
+# Pieces that open or close literals, comments, bodies and blocks: where splitters go wrong
+FRAGMENTS = ["'", '"', '`', '$$', '$body$', '--', '/*', '*/', ';', '\n', '(', ')', 'BEGIN', 'END',
+             'CASE', 'CREATE', 'TABLE', 'FUNCTION', 'DELIMITER', '-- +goose StatementBegin\n', 'é']
+
+# No input may take longer than this to chunk
+MAX_SECONDS = 1.0
+
+
+def mutations(source, generator, count):
+    """Copies of source with random slices deleted, duplicated or replaced by fragments"""
+    for _ in range(count):
+        text = source
+        for _ in range(generator.randint(1, 8)):
+            start = generator.randrange(len(text) + 1)
+            end = min(len(text), start + generator.randint(0, 40))
+            action = generator.choice(('delete', 'duplicate', 'insert'))
+            if action == 'delete':
+                text = text[:start] + text[end:]
+            elif action == 'duplicate':
+                text = text[:end] + text[start:end] + text[end:]
+            else:
+                text = text[:start] + ' '.join(generator.choice(FRAGMENTS) for _ in range(3)) + text[start:]
+        yield text
+
+
+def check(chunker, text):
+    """Chunk text, asserting the chunks are sound and the work is quick"""
+    started = time.monotonic()
+    chunks = chunker.extract_chunks(text, 'fuzz.sql')
+    
+    lines = text.split('\n')
+    previous = 0
+    for chunk in chunks:
+        assert 1 <= chunk.line_start <= chunk.line_end <= len(lines), (chunk.name, chunk.line_start, chunk.line_end)
+        assert chunk.line_start >= previous, "statements come in source order"
+        assert chunk.content and chunk.content in text, chunk.name
+        assert chunk.name, chunk.content
+        previous = chunk.line_start
+    assert all(d['kind'] == 'parse_error' for d in chunker.diagnostics), chunker.diagnostics
+    
+    elapsed = time.monotonic() - started
+    assert elapsed < MAX_SECONDS, f"{elapsed:.2f}s for {len(text)} characters"
+    return chunks
+
+
+def test_sample():
+    chunker = SqlChunker()
+    names = [c.name for c in check(chunker, SAMPLE)]
+    assert 'touch' in names and 'add_user' in names and 'ListRecent' in names, names
+    print("✅ The sample splits into its statements")
+
+
+def test_truncated_sources():
+    chunker = SqlChunker()
+    for end in range(0, len(SAMPLE), 7):
+        check(chunker, SAMPLE[:end])
+    print("✅ Every prefix of the sample splits")
+
+
+def test_mutated_sources():
+    chunker = SqlChunker()
+    generator = random.Random(53)
+    for text in mutations(SAMPLE, generator, 300):
+        check(chunker, text)
+    print("✅ Randomly mutated sources split, and the analyses terminate on them")
+
+
+def test_random_text():
+    chunker = SqlChunker()
+    generator = random.Random(7)
+    alphabet = FRAGMENTS + list('abcxyz019 \t+-*/%<>=!.,:_') + ['SELECT', 'FROM', 'INTO', 'UPDATE', 'JOIN']
+    for _ in range(300):
+        check(chunker, ' '.join(generator.choice(alphabet) for _ in range(generator.randint(0, 80))))
+    print("✅ Random token soup splits")
+
+
+def main():
+    print("=" * 70)
+    print("SQL STATEMENT FUZZ TEST")
+    print("=" * 70)
+    
+    tests = [test_sample, test_truncated_sources, test_mutated_sources, test_random_text]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
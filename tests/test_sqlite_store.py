@@ -0,0 +1,153 @@
+#!/usr/bin/env python3
+"""
+Test script for the single-file SQLite vector store
+Uses a small deterministic embedder so no embedding model is needed
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import HashEmbedder
+from rag import ChromeRAGSystem
+from stores import SqliteStore, StoreError
+from stores.sqlite_store import to_sql_filter
+
+
+def sample_chunks():
+    return [
+        CodeChunk(type='function', name='ParseURL', content='func ParseURL(raw string) (*URL, error) { parse url }',
+                  filepath='net/url.go', language='go', line_start=10, line_end=40),
+        CodeChunk(type='method', name='String', content='func (u *URL) String() string { format url }',
+                  filepath='net/url.go', language='go', line_start=42, line_end=60, parent_class='URL'),
+        CodeChunk(type='function', name='parse_url', content='def parse_url(raw):\n    return urlsplit(raw)',
+                  filepath='tools/url.py', language='python', line_start=1, line_end=2),
+        CodeChunk(type='class', name='URLParser', content='class URLParser:\n    parse url',
+                  filepath='tools/url.py', language='python', line_start=4, line_end=5),
+        CodeChunk(type='function', name='Run', content='func Run() { part one }', filepath='cmd/run.go',
+                  language='go', line_start=1, line_end=10, symbol_id='cmd/run.go:Run', part_index=0, part_count=2),
+        CodeChunk(type='function', name='Run', content='func Run() { part two }', filepath='cmd/run.go',
+                  language='go', line_start=11, line_end=20, symbol_id='cmd/run.go:Run', part_index=1, part_count=2),
+    ]
+
+
+def build_rag(path, name='code'):
+    rag = ChromeRAGSystem(collection_name=name, embedder=HashEmbedder(size=64, normalize=False), store=SqliteStore(path, name))
+    rag.add_chunks_batch(sample_chunks())
+    rag._build_keyword_index()
+    return rag
+
+
+def test_filter_translation():
+    sql, params = to_sql_filter({'$and': [{'language': 'go'}, {'type': {'$in': ['function', 'method']}}]})
+    assert sql == ("(json_extract(metadata, '$.\"language\"') = ? AND "
+                   "json_extract(metadata, '$.\"type\"') IN (?,?))"), sql
+    assert params == ['go', 'function', 'method']
+    assert to_sql_filter({}) == ('1', [])
+    try:
+        to_sql_filter({'name': {'$like': 'URL%'}})
+        assert False, "unsupported operator accepted"
+    except StoreError:
+        pass
+    print("✅ Chroma-style filters translated to SQL")
+
+
+def test_filtered_search(path):
+    rag = build_rag(path)
+    results = rag.retrieve_context("parse url", n_results=5, languages=['go'], kinds=['function', 'method'],
+                                   lexical_weight=0.0)
+    assert results and all(r['metadata']['language'] == 'go' for r in results)
+    assert results[0]['metadata']['name'] == 'ParseURL', [r['metadata']['name'] for r in results]
+    assert all(r['vector_score'] is not None and r['vector_score'] <= 1.0 + 1e-9 for r in results)
+    
+    results = rag.retrieve_context("parse url", n_results=5, path_globs=['tools/*'], mmr_lambda=0.5)
+    assert {r['metadata']['filepath'] for r in results} == {'tools/url.py'}
+    assert rag.retrieve_context("parse url", languages=['cobol']) == []
+    print("✅ Filtered top-k search")
+
+
+def test_symbols_and_files(path):
+    rag = build_rag(path)
+    full = rag.retrieve_full_symbol('cmd/run.go:Run')
+    assert full['parts'] == 2 and 'part one' in full['content'] and 'part two' in full['content']
+    assert [s['metadata']['parent_class'] for s in rag.retrieve_symbol('String')] == ['URL']
+    
+    assert rag.move_file_chunks('tools/url.py', 'tools/urls.py') == 2
+    assert rag.collection.get(where={'filepath': 'tools/urls.py'}, include=['metadatas'])['documents'] is None
+    assert rag.delete_file_chunks('net/url.go') == 2
+    assert rag.collection.count() == 4
+    
+    # Moved chunks are re-inserted (at the end) under ids derived from the new path
+    page = rag.collection.get(limit=2, offset=1, include=['embeddings'])
+    assert page['ids'] == ['cmd/run.go:Run#1', 'tools/urls.py:parse_url:1'], page['ids']
+    assert len(page['embeddings'][0]) == 64
+    many = rag.collection.get(ids=[f'missing_{i}' for i in range(1200)] + ['cmd/run.go:Run#0'], include=[])
+    assert many['ids'] == ['cmd/run.go:Run#0']
+    
+    try:
+        rag.collection.add(['cmd/run.go:Run#0'], ['x'], [{}], [[0.0] * 64])
+        assert False, "duplicate id accepted"
+    except StoreError:
+        pass
+    print("✅ Symbols reassembled; file chunks moved and deleted")
+
+
+def test_single_file_portability(workdir):
+    original = workdir / 'index.db'
+    rag = build_rag(str(original))
+    assert rag.collection.metadata['embedding_dimensions'] == 64
+    
+    # The file is the whole index: a copy opens with its vectors and metadata
+    copy = workdir / 'copy.db'
+    shutil.copy(original, copy)
+    reopened = ChromeRAGSystem(collection_name='code', embedder=HashEmbedder(size=64, normalize=False), store=SqliteStore(str(copy), 'code'))
+    assert reopened.collection.count() == 6
+    assert reopened.collection.metadata['embedding_model'] == 'test-hash'
+    assert reopened.retrieve_context("urlsplit raw", n_results=1, languages=['python'])[0]['metadata']['name'] == 'parse_url'
+    
+    # Collections in one file stay apart, and snapshots round-trip through the store
+    other = build_rag(str(copy), name='other')
+    other.clear_collection()
+    assert other.collection.count() == 0 and reopened.collection.count() == 6
+    manifest = reopened.save_index(str(workdir / 'snapshot'))
+    other.load_index(str(workdir / 'snapshot'))
+    assert manifest['count'] == 6 and other.collection.count() == 6
+    print("✅ Single-file index copies, shares collections and snapshots")
+
+
+def main():
+    print("=" * 70)
+    print("SQLITE STORE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="sqlite_store_"))
+    tests = [
+        test_filter_translation,
+        lambda: test_filtered_search(str(workdir / 'search.db')),
+        lambda: test_symbols_and_files(str(workdir / 'files.db')),
+        lambda: test_single_file_portability(workdir),
+    ]
+    failed = 0
+    try:
+        for test in tests:
+            try:
+                test()
+            except AssertionError as e:
+                failed += 1
+                print(f"❌ Test failed: {e}")
+    finally:
+        shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,199 @@
+#!/usr/bin/env python3
+"""
+Test script for the surrounding context of search results
+Checks import extraction and type headers, then searches a small indexed Go
+package and a Python class whose sources are re-read from disk
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from chunkers.base_chunker import CodeChunk
+from helpers import make_chroma_rag
+from utils.context_packer import pack_context
+from utils.surrounding_context import declaration_header, enclosing_type_name, file_imports
+
+GO_USER = '''package auth
+
+import (
+    "crypto/subtle"
+    "errors"
+)
+
+// AdminUser is an operator account.
+type AdminUser struct {
+    Name  string
+    Token []byte
+}
+
+var errDenied = errors.New("denied")
+'''
+
+GO_AUTH = '''package auth
+
+import "crypto/subtle"
+
+func (u *AdminUser) Authenticate(token []byte) error {
+    if subtle.ConstantTimeCompare(u.Token, token) != 1 {
+        return errDenied
+    }
+    return nil
+}
+'''
+
+PY_CACHE = '''import os
+from typing import (
+    Dict,
+    Optional,
+)
+
+
+class LruCache(Base,
+               metaclass=Registry):
+    """Least recently used cache"""
+    
+    def __init__(self):
+        self.items = {}
+    
+    def evict(self):
+        self.items.popitem()
+'''
+
+
+def write_sources(root):
+    (root / "auth").mkdir(parents=True)
+    (root / "auth" / "user.go").write_text(GO_USER)
+    (root / "auth" / "auth.go").write_text(GO_AUTH)
+    (root / "cache.py").write_text(PY_CACHE)
+
+
+def python_chunks():
+    """Chunks as the Python chunker emits them: the class, then its methods"""
+    return [
+        CodeChunk(type='class', name='LruCache', content="\n".join(PY_CACHE.splitlines()[7:]),
+                  filepath='cache.py', language='python', line_start=8, line_end=16),
+        CodeChunk(type='method', name='evict', content="    def evict(self):\n        self.items.popitem()",
+                  filepath='cache.py', language='python', line_start=15, line_end=16,
+                  parent_class='LruCache', parent='LruCache'),
+    ]
+
+
+def build_rag(root, db_path, source_root=None):
+    rag = make_chroma_rag(db_path, "surrounding", source_root=source_root)
+    chunker = GoChunker()
+    for name in ("user.go", "auth.go"):
+        rag.add_chunks_batch(chunker.extract_chunks((root / "auth" / name).read_text(), f"auth/{name}"))
+    rag.add_chunks_batch(python_chunks())
+    rag._build_keyword_index()
+    return rag
+
+
+def test_imports():
+    assert file_imports(GO_USER, 'go') == 'import (\n    "crypto/subtle"\n    "errors"\n)'
+    assert file_imports(GO_AUTH, 'go') == 'import "crypto/subtle"'
+    assert file_imports(PY_CACHE, 'python') == 'import os\nfrom typing import (\n    Dict,\n    Optional,\n)'
+    assert file_imports('#include <map>\n#include "base/bind.h"\nint x;', 'cpp') == '#include <map>\n#include "base/bind.h"'
+    assert file_imports("import { a } from './a';\nconst b = require('b');\nexport {}", 'typescript') == \
+        "import { a } from './a';\nconst b = require('b');"
+    assert file_imports('use std::io;\npub use crate::x::{A, B};\nfn main() {}', 'rust') == \
+        'use std::io;\npub use crate::x::{A, B};'
+    assert file_imports('import os', 'gn') == ''
+    print("✅ Imports extracted per language")
+
+
+def test_headers():
+    lines = PY_CACHE.splitlines()
+    assert declaration_header(lines, 8, 16, nested=True, max_lines=30) == \
+        'class LruCache(Base,\n               metaclass=Registry):\n                   ...'
+    go = GO_USER.splitlines()
+    assert declaration_header(go, 9, 12, nested=False, max_lines=30) == \
+        'type AdminUser struct {\n    Name  string\n    Token []byte\n}'
+    assert declaration_header(go, 9, 12, nested=False, max_lines=2) == \
+        'type AdminUser struct {\n    Name  string\n    ...'
+    assert enclosing_type_name({'parent_class': 'Outer.Inner'}) == 'Inner'
+    assert enclosing_type_name({'parent': 'net::Socket'}) == 'Socket'
+    assert enclosing_type_name({'parent_class': ''}) is None
+    print("✅ Type headers: class declaration lines, or a whole struct up to the limit")
+
+
+def test_go_method(rag):
+    results = rag.retrieve_context("authenticate token compare", n_results=1, kinds=['method'],
+                                   with_surrounding=True)
+    assert results[0]['metadata']['name'] == 'Authenticate', results
+    surrounding = results[0]['surrounding']
+    assert surrounding['imports'] == 'import "crypto/subtle"', surrounding
+    assert surrounding['enclosing'] == 'type AdminUser struct {\n    Name  string\n    Token []byte\n}', surrounding
+    assert surrounding['enclosing_filepath'] == 'auth/user.go' and surrounding['enclosing_lines'] == [9, 12]
+    
+    packed, _ = pack_context(results, 500)
+    assert 'import "crypto/subtle"\n\ntype AdminUser struct {' in packed, packed
+    assert 'surrounding' not in rag.retrieve_context("authenticate token compare", n_results=1)[0]
+    print("✅ A Go method comes with its file's imports and its struct from another file")
+
+
+def test_python_method(rag):
+    results = rag.retrieve_context("evict items popitem", n_results=1, kinds=['method'],
+                                   with_surrounding=True)
+    surrounding = results[0]['surrounding']
+    assert surrounding['enclosing'].startswith('class LruCache(Base,\n'), surrounding
+    assert surrounding['enclosing'].endswith('...') and 'def __init__' not in surrounding['enclosing']
+    assert surrounding['imports'].startswith('import os\nfrom typing import (')
+    
+    streamed = list(rag.iter_context("evict items popitem", n_results=1, kinds=['method'],
+                                     with_surrounding=True))
+    assert streamed[0]['surrounding'] == surrounding
+    print("✅ A Python method gets its class declaration lines, not the whole class")
+
+
+def test_missing_source(root, db_path):
+    rag = make_chroma_rag(db_path, "surrounding", source_root=str(root / "elsewhere"))
+    results = rag.retrieve_context("authenticate token compare", n_results=2, with_surrounding=True)
+    assert results and all('surrounding' not in r for r in results)
+    
+    # Without an explicit root, the directory recorded at index time is used
+    rag = make_chroma_rag(db_path, "surrounding")
+    rag.record_source_root(str(root))
+    assert rag.retrieve_context("authenticate token compare", n_results=1, with_surrounding=True)[0]['surrounding']
+    print("✅ Unreadable sources are skipped; the recorded source root is the default")
+
+
+def main():
+    print("=" * 70)
+    print("SURROUNDING CONTEXT TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="surrounding_"))
+    root = workdir / "src"
+    write_sources(root)
+    db_path = str(workdir / "db")
+    rag = build_rag(root, db_path, source_root=str(root))
+    tests = [
+        test_imports,
+        test_headers,
+        lambda: test_go_method(rag),
+        lambda: test_python_method(rag),
+        lambda: test_missing_source(root, db_path),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
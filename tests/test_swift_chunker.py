@@ -0,0 +1,278 @@
+#!/usr/bin/env python3
+"""
+Test script for the Swift chunker
+Sources are parsed by tree-sitter-swift
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import SwiftChunker, link_swift_extensions
+from helpers import make_chroma_rag
+
+
+MODEL = '''import Foundation
+@testable import Accounts
+
+/// A signed-in account.
+///
+/// Created by ``AccountStore``.
+public struct User: Codable, Hashable {
+    public let id: UUID
+    public private(set) var name: String
+    var nickname: String? {
+        didSet { print("renamed \\(oldValue ?? "-")") }
+    }
+    
+    /// Display name, "\\(name)" unless a nickname is set
+    var displayName: String {
+        nickname ?? name
+    }
+}
+
+/** Things that can sign a user in. */
+public protocol Authenticating: AnyObject {
+    associatedtype Credential
+    var isSignedIn: Bool { get }
+    func signIn(with credential: Credential) async throws -> User
+}
+
+enum LoginState: Equatable {
+    case signedOut
+    case signingIn(progress: Double), failed(Error)
+    indirect case nested(LoginState)
+}
+
+enum Tier: Int, CaseIterable {
+    case free = 0, pro = 10
+}
+
+#if DEBUG
+let debugBanner = "debug"
+#endif
+
+@available(*, deprecated, message: "Use Cache.store(_:for:)")
+func cache<Key: Hashable, Value>(_ value: Value, for key: Key) throws -> [Key: Value] where Value: Codable {
+    let s = """
+    func notADeclaration() {}
+    """
+    return [key: value]
+}
+'''
+
+AUTH = '''/// Password sign-in for users.
+extension User: Authenticating {
+    typealias Credential = String
+    
+    var isSignedIn: Bool { true }
+    
+    mutating func signIn(with credential: String) async throws -> User {
+        self
+    }
+    
+    static func == (lhs: User, rhs: User) -> Bool { lhs.id == rhs.id }
+}
+
+extension Array where Element == User {
+    func sortedByName() -> [User] { sorted { $0.name < $1.name } }
+}
+
+extension String: Identifiable {
+    public var id: String { self }
+}
+
+final class KeychainAuthenticator: NSObject, Authenticating {
+    private weak var delegate: AnyObject?
+    
+    override init() {
+        super.init()
+    }
+    
+    subscript(index: Int) -> String {
+        get { "" }
+        set { }
+    }
+}
+
+class PasswordAuthenticator: Authenticating {
+    class func make() -> PasswordAuthenticator { PasswordAuthenticator() }
+    deinit {}
+}
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def test_types_and_properties():
+    """Types, their conformances, properties and docs"""
+    chunks = SwiftChunker().extract_chunks(MODEL, 'Sources/User.swift')
+    
+    user = by_name(chunks, 'User')
+    assert user.type == 'struct' and user.signature == 'public struct User: Codable, Hashable', user.signature
+    assert user.doc == 'A signed-in account.\n\nCreated by ``AccountStore``.', user.doc
+    assert user.metadata['implements'] == ['Codable', 'Hashable']
+    assert user.metadata['properties'] == ['id', 'name', 'nickname', 'displayName']
+    
+    name = by_name(chunks, 'name')
+    assert name.qualified_name == 'User.name' and name.metadata['modifiers'] == ['public', 'private(set)']
+    assert by_name(chunks, 'nickname').metadata['accessors'] == ['didSet']
+    assert 'computed' not in by_name(chunks, 'nickname').metadata
+    display = by_name(chunks, 'displayName')
+    assert display.metadata['computed'] and display.metadata['accessors'] == ['get']
+    assert display.doc == 'Display name, "\\(name)" unless a nickname is set', display.doc
+    
+    protocol = by_name(chunks, 'Authenticating')
+    assert protocol.doc == 'Things that can sign a user in.'
+    assert protocol.metadata['inherits'] == ['AnyObject'] and 'implements' not in protocol.metadata
+    assert protocol.metadata['associated_types'] == ['Credential']
+    assert by_name(chunks, 'isSignedIn').metadata['accessors'] == ['get']
+    assert by_name(chunks, 'signIn').metadata['abstract']
+    assert by_name(chunks, 'debugBanner').type == 'constant'
+    assert not any(c.name == 'notADeclaration' for c in chunks)
+    print("✅ Types, properties and docs extracted")
+
+
+def test_enums():
+    """Enum cases with associated values and raw values"""
+    chunks = SwiftChunker().extract_chunks(MODEL, 'Sources/User.swift')
+    
+    state = by_name(chunks, 'LoginState')
+    assert state.metadata['cases'] == [
+        {'name': 'signedOut'},
+        {'name': 'signingIn', 'associated_values': ['progress: Double']},
+        {'name': 'failed', 'associated_values': ['Error']},
+        {'name': 'nested', 'associated_values': ['LoginState'], 'indirect': True},
+    ], state.metadata['cases']
+    
+    tier = by_name(chunks, 'Tier')
+    assert tier.metadata['raw_type'] == 'Int' and tier.metadata['implements'] == ['CaseIterable']
+    assert tier.metadata['cases'] == [{'name': 'free', 'raw_value': '0'}, {'name': 'pro', 'raw_value': '10'}]
+    print("✅ Enum cases extracted")
+
+
+def test_functions():
+    """Generic parameters and where clauses are part of the signature"""
+    chunks = SwiftChunker().extract_chunks(MODEL, 'Sources/User.swift')
+    
+    cache = by_name(chunks, 'cache')
+    assert cache.type == 'function'
+    assert cache.signature == ('func cache<Key: Hashable, Value>(_ value: Value, for key: Key) throws '
+                               '-> [Key: Value] where Value: Codable'), cache.signature
+    assert cache.metadata['type_params'] == '<Key: Hashable, Value>'
+    assert cache.metadata['where'] == 'where Value: Codable'
+    assert cache.metadata['params'] == [
+        {'name': 'value', 'type': 'Value', 'label': '_'},
+        {'name': 'key', 'type': 'Key', 'label': 'for'},
+    ]
+    assert cache.metadata['returns'] == '[Key: Value]' and cache.metadata['effects'] == ['throws']
+    assert cache.metadata['deprecated'] == 'Use Cache.store(_:for:)'
+    
+    signin = by_name(chunks, 'signIn')
+    assert signin.qualified_name == 'Authenticating.signIn'
+    assert signin.metadata['effects'] == ['async', 'throws'] and signin.metadata['returns'] == 'User'
+    print("✅ Function signatures extracted")
+
+
+def test_extensions():
+    """Extension members belong to the extended type, conformances to its implements"""
+    chunks = SwiftChunker().extract_chunks(AUTH, 'Sources/User+Auth.swift')
+    
+    extensions = [c for c in chunks if c.type == 'extension']
+    assert [c.metadata['extended_type'] for c in extensions] == ['User', 'Array', 'String']
+    auth = extensions[0]
+    assert auth.doc == 'Password sign-in for users.'
+    assert auth.metadata['implements'] == ['Authenticating']
+    assert auth.metadata['methods'] == ['signIn', '==']
+    assert by_name(chunks, 'signIn').qualified_name == 'User.signIn'
+    assert by_name(chunks, '==').metadata['operator']
+    assert extensions[1].metadata['where'] == 'where Element == User'
+    assert by_name(chunks, 'sortedByName').qualified_name == 'Array.sortedByName'
+    
+    keychain = by_name(chunks, 'KeychainAuthenticator')
+    assert keychain.metadata['superclass'] == 'NSObject'
+    assert keychain.metadata['implements'] == ['Authenticating']
+    assert keychain.metadata['methods'] == ['init', 'subscript']
+    assert by_name(chunks, 'subscript').metadata['params'] == [{'name': 'index', 'type': 'Int'}]
+    assert by_name(chunks, 'make').metadata['modifiers'] == ['class']
+    
+    models = SwiftChunker().extract_chunks(MODEL, 'Sources/User.swift')
+    linked = link_swift_extensions(models + chunks)
+    user = by_name(linked, 'User', 'struct')
+    assert user.metadata['implements'] == ['Codable', 'Hashable', 'Authenticating'], user.metadata['implements']
+    assert user.metadata['methods'] == ['signIn', '==']
+    assert user.metadata['extensions'][0]['filepath'] == 'Sources/User+Auth.swift'
+    assert 'implements' not in auth.metadata and auth.metadata['extends']['line'] == user.line_start
+    # String is not indexed: the extension keeps its conformance
+    assert extensions[2].metadata['implements'] == ['Identifiable']
+    # Authenticating is an indexed protocol, not a superclass
+    password = by_name(linked, 'PasswordAuthenticator')
+    assert 'superclass' not in password.metadata and password.metadata['implements'] == ['Authenticating']
+    print("✅ Extensions linked to their types")
+
+
+def test_sample_file():
+    """The multi-language sample parses completely"""
+    sample = Path(__file__).parent.parent / 'test_samples' / 'all_languages' / 'UserService.swift'
+    chunks = SwiftChunker().extract_chunks(sample.read_text(), 'all_languages/UserService.swift')
+    
+    assert by_name(chunks, 'CodingKeys').qualified_name == 'User.CodingKeys'
+    assert by_name(chunks, 'CodingKeys').metadata['implements'] == ['CodingKey']
+    assert [c['name'] for c in by_name(chunks, 'Result').metadata['cases']] == ['success', 'failure', 'loading']
+    assert by_name(chunks, 'hasRole').qualified_name == 'User.hasRole'
+    assert by_name(chunks, 'UserService').type == 'actor'
+    builder = by_name(chunks, 'createUsers')
+    assert builder.metadata['params'] == [{'name': 'builder', 'type': '() -> [User]'}], builder.metadata['params']
+    assert by_name(chunks, 'buildBlock').metadata['params'][0]['variadic']
+    print("✅ Sample file parsed")
+
+
+def test_implementations(workdir):
+    """Conformances added by extensions are found as implementations"""
+    rag = make_chroma_rag(workdir / "db", "test_swift")
+    rag.add_chunks_batch(link_swift_extensions(
+        SwiftChunker().extract_chunks(MODEL, 'Sources/User.swift')
+        + SwiftChunker().extract_chunks(AUTH, 'Sources/User+Auth.swift')
+    ))
+    
+    names = sorted(r['metadata']['name'] for r in rag.find_implementations('Authenticating', language='swift'))
+    assert names == ['KeychainAuthenticator', 'PasswordAuthenticator', 'User'], names
+    names = [r['metadata']['name'] for r in rag.find_implementations('Identifiable', language='swift')]
+    assert names == ['String'], names
+    print("✅ Implementations found across extensions")
+
+
+def main():
+    print("=" * 70)
+    print("SWIFT CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_swift_"))
+    
+    tests = [
+        test_types_and_properties, test_enums, test_functions, test_extensions, test_sample_file,
+        lambda: test_implementations(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,128 @@
+#!/usr/bin/env python3
+"""
+Test script for go-to-symbol name lookup
+Exact, prefix and fuzzy matching over symbol names, and the lookup over an index.
+Uses a small deterministic embedder that counts its calls
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers.base_chunker import CodeChunk
+from helpers import HashEmbedder, make_rag
+from rag import symbol_kind
+from utils.symbol_lookup import edit_distance, lookup_symbols, name_keys
+
+
+def symbol(name, chunk_type='function', filepath='session/manager.go', line=1, **fields):
+    return dict({'name': name, 'type': chunk_type, 'filepath': filepath, 'language': 'go',
+                 'line_start': line, 'line_end': line + 5, 'symbol_id': f"{filepath}:{name}:{line}"}, **fields)
+
+
+METADATAS = [
+    symbol('NewSessionManager', line=1),
+    symbol('SessionManager', 'struct', line=10),
+    symbol('SessionManagr', 'var', filepath='session/typo.go', line=1),
+    symbol('Sessions', 'var', filepath='session/typo.go', line=3),
+    symbol('Close', 'method', line=20, parent='SessionManager'),
+    symbol('manager.go', 'file', line=1),
+]
+
+
+def names(query, **options):
+    return [(s['name'], s['match']) for s in lookup_symbols(query, METADATAS, kind_of=symbol_kind, **options)]
+
+
+def test_identifier_keys():
+    assert name_keys('NewSessionManager') == ['newsessionmanager', 'sessionmanager', 'manager']
+    assert name_keys('new_session_manager') == ['newsessionmanager', 'sessionmanager', 'manager']
+    assert edit_distance('sesion', 'session', 2) == 1
+    assert edit_distance('sesison', 'session', 2) == 1, "a transposition is one edit"
+    assert edit_distance('abc', 'xyzxyz', 1) == 2, "distances past the limit stop early"
+    print("✅ Names are keyed by every identifier word; edits allow transpositions")
+
+
+def test_ranking():
+    assert names('sessionmanager') == [('SessionManager', 'exact'), ('NewSessionManager', 'prefix'),
+                                       ('Close', 'prefix'), ('SessionManagr', 'fuzzy')], names('sessionmanager')
+    assert names('Session_Manager')[0] == ('SessionManager', 'exact')
+    assert names('sess')[0] == ('Sessions', 'prefix')
+    assert ('manager.go', 'prefix') not in names('manager'), "file chunks are not symbols"
+    
+    fuzzy = lookup_symbols('sesionmanagr', METADATAS)
+    assert all(s['match'] == 'fuzzy' for s in fuzzy) and fuzzy[0]['name'] == 'SessionManagr', fuzzy
+    assert fuzzy[0]['distance'] == 1
+    assert names('zz') == [] and names('xyzzy') == []
+    print("✅ Exact matches rank over prefix matches over fuzzy ones")
+
+
+def test_qualified_and_filters():
+    assert names('sessionmanager.close') == [('Close', 'exact')]
+    assert lookup_symbols('close', METADATAS)[0]['qualified_name'] == 'SessionManager.Close'
+    assert names('session', kinds=['type']) == [('SessionManager', 'prefix')]
+    assert names('session', kinds=['struct']) == [('SessionManager', 'prefix')]
+    assert names('session', languages=['python']) == []
+    assert len(names('s', limit=2)) == 2
+    print("✅ Qualified names match; kind, language and limit filters apply")
+
+
+def test_index_lookup(workdir):
+    embedder = HashEmbedder()
+    rag = make_rag(workdir, "lookup", embedder=embedder)
+    part = dict(type='function', name='NewSessionManager', filepath='session/manager.go', language='go',
+                symbol_id='session/manager.go:NewSessionManager', part_count=2)
+    rag.add_chunks_batch([
+        CodeChunk(content='func NewSessionManager() { part one }', line_start=1, line_end=20, part_index=0, **part),
+        CodeChunk(content='func NewSessionManager() { part two }', line_start=21, line_end=40, part_index=1, **part),
+        CodeChunk(type='method', name='Close', content='func (m *SessionManager) Close() {}', parent='SessionManager',
+                  filepath='session/manager.go', language='go', line_start=42, line_end=44),
+    ])
+    
+    calls = embedder.calls
+    found = rag.lookup('sessionmanager')
+    assert [(s['name'], s['match']) for s in found] == [('NewSessionManager', 'prefix'), ('Close', 'prefix')], found
+    assert (found[0]['line_start'], found[0]['line_end']) == (1, 40), "parts of a split symbol are one symbol"
+    assert found[1]['qualified_name'] == 'SessionManager.Close' and found[1]['kind'] == 'method'
+    assert embedder.calls == calls, "lookup should not embed anything"
+    
+    rag.add_chunks_batch([CodeChunk(type='struct', name='SessionManager', content='type SessionManager struct {}',
+                                    filepath='session/manager.go', language='go', line_start=50, line_end=52)])
+    assert rag.lookup('sessionmanager')[0]['name'] == 'SessionManager', "new chunks are found at once"
+    print("✅ Index lookup merges split symbols, sees new chunks and never embeds")
+
+
+def main():
+    print("=" * 70)
+    print("SYMBOL LOOKUP TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="lookup_"))
+    
+    tests = [
+        test_identifier_keys,
+        test_ranking,
+        test_qualified_and_filters,
+        lambda: test_index_lookup(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
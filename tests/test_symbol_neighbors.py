@@ -0,0 +1,154 @@
+#!/usr/bin/env python3
+"""
+Test script for the neighboring symbols of search results
+Checks adjacency on line ranges (enclosing types and split symbols included),
+then searches a small indexed Go file and Python class with neighbors attached
+by id and with their bodies
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker
+from chunkers.base_chunker import CodeChunk
+from helpers import make_rag
+from utils.result_format import result_record
+from utils.symbol_neighbors import adjacent_symbols, group_symbols
+
+GO_CACHE = '''package cache
+
+import "time"
+
+// expired reports whether an entry is past its deadline
+func expired(e *entry, now time.Time) bool {
+    return now.After(e.deadline)
+}
+
+type entry struct {
+    value    string
+    deadline time.Time
+}
+
+// Get returns a cached value unless it expired
+func (c *Cache) Get(key string) (string, bool) {
+    e, ok := c.items[key]
+    if !ok || expired(e, time.Now()) {
+        return "", false
+    }
+    return e.value, true
+}
+
+func (c *Cache) evict(key string) {
+    delete(c.items, key)
+}
+'''
+
+
+def python_chunks():
+    """A class with two methods, then a module function"""
+    return [
+        CodeChunk(type='class', name='Store', content="class Store:\n    ...", filepath='store.py',
+                  language='python', line_start=1, line_end=10),
+        CodeChunk(type='method', name='load', content="    def load(self):\n        return read_rows()",
+                  filepath='store.py', language='python', line_start=3, line_end=5, parent='Store'),
+        CodeChunk(type='method', name='save', content="    def save(self, rows):\n        write_rows(rows)",
+                  filepath='store.py', language='python', line_start=7, line_end=10, parent='Store'),
+        CodeChunk(type='function', name='read_rows', content="def read_rows():\n    return []",
+                  filepath='store.py', language='python', line_start=12, line_end=13),
+    ]
+
+
+def stored(id, symbol_id, start, end, part_index=0):
+    return {'id': id, 'content': '', 'metadata': {'symbol_id': symbol_id, 'name': symbol_id, 'line_start': start,
+                                                  'line_end': end, 'part_index': part_index}}
+
+
+def build_rag(workdir):
+    rag = make_rag(workdir, 'neighbors')
+    rag.add_chunks_batch(GoChunker().extract_chunks(GO_CACHE, 'cache/cache.go') + python_chunks())
+    rag._build_keyword_index()
+    return rag
+
+
+def find(results, name):
+    return next(r for r in results if r['metadata'].get('name') == name)
+
+
+def test_adjacency():
+    symbols = group_symbols([stored('b1', 'big', 31, 50, part_index=1), stored('s', 'small', 51, 55),
+                             stored('f', 'first', 1, 8), stored('b0', 'big', 10, 30)])
+    previous, following = adjacent_symbols(symbols, {'symbol_id': 'small', 'line_start': 51, 'line_end': 55})
+    assert [p['id'] for p in previous] == ['b0', 'b1'] and following is None, (previous, following)
+    previous, following = adjacent_symbols(symbols, {'symbol_id': 'big', 'line_start': 31, 'line_end': 50})
+    assert previous[0]['id'] == 'f' and following[0]['id'] == 's', "a part stands for its whole symbol"
+    print("✅ Neighbors are the nearest symbols before and after, split symbols counted once")
+
+
+def test_ids(rag):
+    results = rag.retrieve_context("cached value expired deadline", n_results=10, with_neighbors='ids')
+    neighbors = find(results, 'Get')['neighbors']
+    assert neighbors['previous']['name'] == 'entry' and neighbors['next']['name'] == 'evict', neighbors
+    assert neighbors['next']['qualified_name'] == 'Cache.evict' and neighbors['next']['line_start'] == 24
+    assert 'content' not in neighbors['previous'] and neighbors['previous']['id']
+    assert find(results, 'expired')['neighbors']['previous'] is None
+    assert result_record(find(results, 'Get'))['neighbors'] == neighbors
+    
+    assert 'neighbors' not in rag.retrieve_context("cached value expired", n_results=3)[0]
+    print("✅ Results list the symbols right before and after them by id and lines")
+
+
+def test_nested(rag):
+    results = rag.retrieve_context("rows store load save read", n_results=10, languages=['python'],
+                                   with_neighbors='ids')
+    names = {r['metadata']['name']: {side: (n or {}).get('name') for side, n in r['neighbors'].items()}
+             for r in results}
+    assert names['load'] == {'previous': None, 'next': 'save'}, names
+    assert names['save'] == {'previous': 'load', 'next': 'read_rows'}, names
+    assert names['Store'] == {'previous': None, 'next': 'read_rows'}, "a class's own methods are not its neighbors"
+    print("✅ Enclosing and enclosed symbols are not neighbors")
+
+
+def test_bodies(rag):
+    results = rag.retrieve_context("cached value expired deadline", n_results=10, with_neighbors='bodies')
+    neighbors = find(results, 'Get')['neighbors']
+    assert neighbors['next']['content'].startswith('func (c *Cache) evict(key string) {'), neighbors['next']
+    streamed = find(list(rag.iter_context("cached value expired deadline", n_results=10, with_neighbors='bodies')), 'Get')
+    assert streamed['neighbors'] == neighbors
+    try:
+        rag.retrieve_context("cached value", with_neighbors='all')
+        assert False, "an unknown mode should be refused"
+    except ValueError:
+        pass
+    print("✅ With bodies, neighbors carry their code, streamed searches too")
+
+
+def main():
+    print("=" * 70)
+    print("SYMBOL NEIGHBORS TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="neighbors_"))
+    rag = build_rag(workdir)
+    tests = [test_adjacency, lambda: test_ids(rag), lambda: test_nested(rag), lambda: test_bodies(rag)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,139 @@
+#!/usr/bin/env python3
+"""
+Test script for symbol references
+Everything that refers to a symbol, for impact analysis before a rename: the
+call graph, type and doc links, and code that names it otherwise, each with
+the kind of reference and the lines that write the name.
+Uses a small deterministic embedder
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import GoChunker, link_go_packages
+from chunkers.base_chunker import qualified_name
+from helpers import make_rag
+from utils.symbol_references import mention_lines, reference_kinds, type_mentions
+
+MODELS = '''package models
+
+// User is an account holder
+type User struct {
+    Name string
+}
+
+// AdminUser is a User with permissions
+type AdminUser struct {
+    User
+    Permissions []string
+}
+
+func NewAdminUser(name string) *AdminUser {
+    return &AdminUser{User: User{Name: name}}
+}
+
+func (u *User) Rename(name string) {
+    u.Name = name
+}
+
+type Directory interface {
+    Find(name string) (*User, bool)
+}
+'''
+
+SERVICE = '''package service
+
+import "example.com/app/models"
+
+type Registry struct {
+    owner models.User
+}
+
+func Register(u models.User) {
+    u.Rename("admin")
+}
+
+func Owner() models.User {
+    return models.User{}
+}
+
+var Default models.User
+'''
+
+
+def test_helpers():
+    assert type_mentions('map[string][]*models.User', 'User') and type_mentions('SessionManager[User]', 'User')
+    assert not type_mentions('*AdminUser', 'User') and not type_mentions('other.User', 'models.User')
+    
+    extra = {'params': [{'name': 'u', 'type': 'User'}], 'results': [{'name': '', 'type': 'error'}],
+             'calls': [{'name': 'User.Rename', 'resolved': True}]}
+    assert reference_kinds(extra, 'func Save(u User) error', 'User') == ['param']
+    assert reference_kinds(extra, 'func Save(u User) error', 'Rename') == ['call']
+    assert reference_kinds({}, 'x := User{}\nreturn x', 'User') == ['code']
+    assert reference_kinds({}, 'x := AdminUser{}', 'User') == []
+    assert mention_lines('a := models.User{}\nb := User{}', 'models.User') == [0]
+    assert mention_lines('a := models.User{}\nb := User{}', 'User') == [0, 1]
+    print("✅ Type expressions, linked metadata and code mentions name the symbol")
+
+
+def test_references(rag):
+    refs = {qualified_name(r['metadata']): r for r in rag.find_references('User')}
+    kinds = {name: r['reference_kinds'] for name, r in refs.items()}
+    assert kinds['AdminUser'] == ['embed', 'doc'], kinds
+    assert kinds['NewAdminUser'] == ['code'], kinds
+    assert kinds['User.Rename'] == ['receiver'], kinds
+    assert kinds['Directory'] == ['code'] and kinds['Registry'] == ['field'], kinds
+    assert kinds['Register'] == ['param'] and kinds['Owner'] == ['result'], kinds
+    assert kinds['Default'] == ['type'], kinds
+    assert 'User' not in kinds, "the definition itself is not a reference"
+    
+    new_admin = refs['NewAdminUser']
+    assert new_admin['lines'] == [new_admin['metadata']['line_start'] + 1], new_admin['lines']
+    assert refs['Owner']['lines'] == [13, 14], refs['Owner']['lines']
+    
+    names = [r['metadata']['name'] for r in rag.find_references('models.User')]
+    assert names == ['Registry', 'Register', 'Owner', 'Default'], names
+    assert len(rag.find_references('User', n_results=2)) == 2
+    assert [r['metadata']['name'] for r in rag.find_references('User.Rename')] == ['Register']
+    assert rag.find_references('Rename')[0]['reference_kinds'] == ['call']
+    assert rag.find_references('Nothing') == []
+    print("✅ References list every symbol a rename affects, with the kind of reference and its lines")
+
+
+def main():
+    print("=" * 70)
+    print("SYMBOL REFERENCES TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_references_"))
+    rag = make_rag(workdir, "references")
+    chunker = GoChunker()
+    chunks = chunker.extract_chunks(MODELS, "models/models.go") + chunker.extract_chunks(SERVICE, "service/service.go")
+    rag.add_chunks_batch(link_go_packages(chunks))
+    
+    tests = [
+        test_helpers,
+        lambda: test_references(rag),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,121 @@
+#!/usr/bin/env python3
+"""
+Test script for the plain-text fallback: token-bounded, overlapping blocks of
+lines for files no parser handles, tagged kind 'text' and filterable in search
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import TextChunker, assign_byte_ranges, assign_symbol_ids, get_token_counter, stitch_parts
+from config import CONFIG
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+
+
+LOG = "\n".join(f"2026-03-{i % 28 + 1:02d} 12:00:{i % 60:02d} worker-{i % 4} retried job {i} after timeout"
+                for i in range(300)) + "\n"
+
+
+def test_blocks():
+    chunks = TextChunker(max_tokens=128, overlap_tokens=24).extract_chunks(LOG, 'logs/worker.log')
+    counter = get_token_counter()
+    
+    assert len(chunks) > 5, len(chunks)
+    assert all(counter.count(c.content) <= 128 for c in chunks)
+    assert all(c.type == 'text' and c.kind == 'text' and c.name == 'worker.log' for c in chunks)
+    assert chunks[0].line_start == 1 and chunks[-1].line_end == 300, (chunks[0].line_start, chunks[-1].line_end)
+    assert all(b.line_start <= a.line_end for a, b in zip(chunks, chunks[1:])), "blocks should overlap"
+    
+    lines = LOG.split('\n')
+    for chunk in assign_byte_ranges(chunks, LOG):
+        assert chunk.content == '\n'.join(lines[chunk.line_start - 1:chunk.line_end])
+        assert LOG.encode()[chunk.byte_start:chunk.byte_end].decode() == chunk.content
+    
+    stored = [{'content': c.content, 'metadata': c.to_dict()} for c in reversed(chunks)]
+    assert stitch_parts(stored) == LOG.rstrip('\n')
+    
+    assert len(TextChunker(max_tokens=0).extract_chunks(LOG, 'worker.log')) == 1
+    assert TextChunker().extract_chunks("\n  \n", 'empty.txt') == []
+    print(f"✅ {len(chunks)} overlapping blocks within budget, with exact line and byte ranges")
+
+
+def test_symbol_ids():
+    chunks = assign_symbol_ids(TextChunker(max_tokens=128).extract_chunks(LOG, 'logs/worker.log'))
+    assert {c.symbol_id for c in chunks} == {'logs/worker.log:worker.log'}, {c.symbol_id for c in chunks}
+    assert len({c.chunk_id() for c in chunks}) == len(chunks)
+    print("✅ Blocks are parts of one chunk per file")
+
+
+def test_index_and_filter(workdir):
+    source = workdir / "src"
+    (source / "deploy").mkdir(parents=True)
+    (source / "deploy" / "rollout.conf").write_text(
+        "# Rollout settings\nwave_size = 10\npause_between_waves = 15m\nabort_on_error_rate = 0.02\n")
+    (source / "deploy" / "rollout.go").write_text(
+        "package deploy\n\n// Rollout ships a release in waves\nfunc Rollout(waveSize int) error {\n"
+        "    return shipWaves(waveSize)\n}\n")
+    (source / "deploy" / "notes.sql").write_text("-- Rollout notes: no statements yet\n-- wave size tuning pending\n")
+    
+    saved = CONFIG.text_fallback
+    CONFIG.text_fallback = True
+    try:
+        rag = make_rag(workdir, "text")
+        indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "state.db")))
+        stats = indexer.index_directory(str(source), parallel=False)
+    finally:
+        CONFIG.text_fallback = saved
+    assert stats['files_by_type']['text'] == 1 and stats['chunks_by_type']['text'] == 2, stats
+    notes = rag.collection.get(where={"filepath": "deploy/notes.sql"})['metadatas']
+    assert [(m['language'], m['kind']) for m in notes] == [('sql', 'text')], notes
+    
+    def found(**filters):
+        return [(r['metadata']['filepath'], r['metadata']['kind'])
+                for r in rag.retrieve_context("rollout wave size", n_results=10, **filters)]
+    
+    assert ('deploy/rollout.conf', 'text') in found() and ('deploy/rollout.go', 'source') in found(), found()
+    assert set(found(kinds=['text'])) == {('deploy/rollout.conf', 'text'), ('deploy/notes.sql', 'text')}, \
+        found(kinds=['text'])
+    assert all(kind != 'text' for _, kind in found(exclude_text=True)), found(exclude_text=True)
+    assert ('deploy/rollout.go', 'source') in found(exclude_text=True, exclude_tests=True)
+    
+    result = rag.retrieve_context("wave size", n_results=1, kinds=['text'], explain=True)[0]
+    assert result['explain']['filters'] == {'kinds': 'text'}, result['explain']['filters']
+    print("✅ Unparsed files are indexed as text blocks that searches can select or leave out")
+
+
+def main():
+    print("=" * 70)
+    print("TEXT CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_text_"))
+    
+    tests = [
+        test_blocks,
+        test_symbol_ids,
+        lambda: test_index_and_filter(workdir),
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,171 @@
+#!/usr/bin/env python3
+"""
+Test script for token-budget-aware splitting of oversized chunks
+"""
+
+import sys
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import CodeChunk, assign_byte_ranges, split_oversized_chunks, stitch_parts, get_token_counter
+from chunkers.token_splitter import OVERLAP_STRATEGIES, parse_overlap_strategy
+
+
+def make_go_function(calls: int) -> CodeChunk:
+    """A function of multi-line calls, each a statement spread over four lines"""
+    body = "func Sync(ctx context.Context, store *Store) error {\n" + "".join(
+        f"\tif err := store.Put(ctx,\n\t\t\"key_{i}\",\n\t\tvalue_{i}); err != nil {{\n"
+        f"\t\treturn err\n\t}}\n" for i in range(calls)
+    ) + "\treturn nil\n}"
+    return CodeChunk(
+        type='function', name='Sync', content=body, filepath='sync.go', language='go',
+        line_start=1, line_end=1 + body.count('\n'), signature='func Sync(ctx context.Context, store *Store) error'
+    )
+
+
+def body_lines(part: CodeChunk) -> list:
+    return part.content[part.metadata['header_chars']:].split('\n')
+
+
+def make_function(lines: int) -> CodeChunk:
+    body = "def process(records, limit=10):\n" + "\n".join(
+        f"    total_{i} = transform(records[{i}], limit) + offset_{i}" for i in range(lines)
+    ) + "\n    return total_0"
+    return CodeChunk(
+        type='function', name='process', content=body, filepath='big.py', language='python',
+        line_start=5, line_end=5 + body.count('\n'), signature='def process(records, limit=10)'
+    )
+
+
+def test_small_chunk_untouched():
+    chunk = make_function(3)
+    assert split_oversized_chunks([chunk], max_tokens=512) == [chunk]
+    print("✅ Small chunk left as is")
+
+
+def test_split_respects_budget():
+    chunk = make_function(400)
+    parts = split_oversized_chunks([chunk], max_tokens=256, overlap_tokens=32)
+    counter = get_token_counter()
+    
+    assert len(parts) > 1, len(parts)
+    assert all(counter.count(p.content) <= 256 for p in parts)
+    assert all(p.content.startswith(chunk.signature) for p in parts)
+    assert len({p.symbol_id for p in parts}) == 1
+    assert [p.part_index for p in parts] == list(range(len(parts)))
+    assert all(p.part_count == len(parts) for p in parts)
+    # Overlap: each part starts before the previous one ended
+    assert all(b.line_start <= a.line_end for a, b in zip(parts, parts[1:]))
+    print(f"✅ Split into {len(parts)} parts within budget "
+          f"({'tiktoken' if counter.exact else 'estimated'} counts)")
+
+
+def test_stitch_round_trip():
+    chunk = make_function(400)
+    chunk.content += "\n" + "x" * 4000  # one line larger than the whole budget
+    parts = split_oversized_chunks([chunk], max_tokens=256, overlap_tokens=32)
+    stored = [{'content': p.content, 'metadata': p.to_dict()} for p in reversed(parts)]
+    
+    assert stitch_parts(stored) == chunk.content
+    print("✅ Parts stitch back to the original source")
+
+
+def test_byte_ranges():
+    """Every part maps back to the exact bytes (and lines) of the file it covers"""
+    chunk = make_function(400)
+    chunk.content = chunk.content.replace('transform', 'transformé')  # multi-byte characters
+    source = "# -*- coding: utf-8 -*-\n# héader\n\n\n" + chunk.content + "\n"
+    encoded = source.encode('utf-8')
+    lines = source.split('\n')
+    
+    [located] = assign_byte_ranges([chunk], source)
+    assert encoded[located.byte_start:located.byte_end].decode('utf-8') == chunk.content
+    
+    parts = split_oversized_chunks([located], max_tokens=256, overlap_tokens=32)
+    assert len(parts) > 1
+    for part in parts:
+        body = part.content[part.metadata['header_chars']:]
+        assert encoded[part.byte_start:part.byte_end].decode('utf-8') == body
+        assert '\n'.join(lines[part.line_start - 1:part.line_end]).strip() == body.strip()
+    assert parts[0].byte_start == located.byte_start and parts[-1].byte_end == located.byte_end
+    assert all(p.to_dict()['byte_start'] == p.byte_start for p in parts)
+    
+    # Content that is not a verbatim slice of the file falls back to its whole lines
+    rewritten = CodeChunk(type='function', name='f', content='def f(): ...', filepath='x.py',
+                          language='python', line_start=2, line_end=2)
+    assign_byte_ranges([rewritten], source)
+    assert encoded[rewritten.byte_start:rewritten.byte_end].decode('utf-8') == '# héader'
+    print("✅ Byte ranges cover the source of every part")
+
+
+def test_overlap_strategies():
+    """Every strategy stays within budget, overlaps and stitches back; only 'semantic' reads statements"""
+    chunk = make_go_function(60)
+    counter = get_token_counter()
+    default = split_oversized_chunks([chunk], max_tokens=200, overlap_tokens=40)
+    fixed = split_oversized_chunks([chunk], max_tokens=200, overlap_tokens=40, overlap_strategy='fixed_token')
+    assert [p.content for p in default] == [p.content for p in fixed], "fixed_token is the default"
+    
+    for strategy in OVERLAP_STRATEGIES:
+        parts = split_oversized_chunks([chunk], max_tokens=200, overlap_tokens=40, overlap_strategy=strategy)
+        assert len(parts) > 2 and all(counter.count(p.content) <= 200 for p in parts), strategy
+        assert all(b.line_start <= a.line_end for a, b in zip(parts, parts[1:])), strategy
+        stored = [{'content': p.content, 'metadata': p.to_dict()} for p in parts]
+        assert stitch_parts(stored) == chunk.content, strategy
+    
+    # Semantic parts start and end on statements, never inside a call spread over lines
+    inside_call = lambda line: line.startswith('\t\t"key_') or line.startswith('\t\tvalue_')
+    semantic = split_oversized_chunks([chunk], max_tokens=200, overlap_tokens=40, overlap_strategy='semantic')
+    for part in semantic[1:]:
+        assert not inside_call(body_lines(part)[0]), body_lines(part)[:2]
+    for part in semantic[:-1]:
+        assert body_lines(part)[-1] in ('\t}', '\t\treturn err'), body_lines(part)[-2:]
+    assert any(inside_call(body_lines(p)[0]) for p in fixed[1:]), "fixed_token overlaps mid-statement"
+    
+    # Line-aligned overlaps carry whole lines, however long
+    long_lines = make_function(400)
+    long_lines.content += "\n" + "x" * 4000
+    for part in split_oversized_chunks([long_lines], max_tokens=256, overlap_tokens=32,
+                                       overlap_strategy='line_aligned')[1:]:
+        first = body_lines(part)[0]
+        assert first.startswith('    total_') or first.startswith('x'), first
+    print("✅ fixed_token, line_aligned and semantic overlaps all stitch back; semantic ones follow statements")
+
+
+def test_parse_overlap_strategy():
+    assert parse_overlap_strategy(None) == 'fixed_token'
+    assert parse_overlap_strategy('Line-Aligned') == 'line_aligned'
+    assert parse_overlap_strategy(' semantic ') == 'semantic'
+    try:
+        parse_overlap_strategy('sentence')
+        assert False, "unknown strategy accepted"
+    except ValueError:
+        pass
+    print("✅ Overlap strategy settings are normalized and validated")
+
+
+def main():
+    print("=" * 70)
+    print("TOKEN SPLITTER TEST")
+    print("=" * 70)
+    
+    tests = [test_small_chunk_untouched, test_split_respects_budget, test_stitch_round_trip, test_byte_ranges,
+             test_overlap_strategies, test_parse_overlap_strategy]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ {test.__name__} failed: {e}")
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
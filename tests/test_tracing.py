@@ -0,0 +1,222 @@
+#!/usr/bin/env python3
+"""
+Test script for timing spans of the indexing and search pipelines
+Each stage (crawl, parse, link, embed, upsert; search, vector_search,
+keyword_search, rerank) is recorded with its item count and duration, totals
+per stage land in the run's stats, and every span reaches the tracer's hooks,
+including parses timed in worker processes. Uses fake OpenTelemetry and
+Prometheus objects and a small deterministic embedder
+"""
+
+import io
+import logging
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import make_rag
+from indexer import ChromeIndexer
+from utils.logger import console
+from utils.state_manager import StateManager
+from utils.tracing import (INDEX_STAGES, SEARCH_STAGES, Tracer, format_timings, log_hook, opentelemetry_hook,
+                           prometheus_hook)
+
+
+class FakeOtelSpan:
+    def __init__(self, log, name, start_time, attributes):
+        self.log, self.name, self.start_time, self.attributes = log, name, start_time, attributes
+    
+    def end(self, end_time=None):
+        self.log.append((self.name, self.start_time, end_time, self.attributes))
+
+
+class FakeOtelTracer:
+    """Records the spans started through the OpenTelemetry tracer API"""
+    
+    def __init__(self):
+        self.ended = []
+    
+    def start_span(self, name, start_time=None, attributes=None):
+        return FakeOtelSpan(self.ended, name, start_time, attributes)
+
+
+class FakeMetric:
+    """Prometheus histogram or counter with labels, recording what each label set got"""
+    
+    def __init__(self):
+        self.values = {}
+    
+    def labels(self, **labels):
+        metric, key = self, tuple(sorted(labels.items()))
+        
+        class Child:
+            def observe(self, value):
+                metric.values.setdefault(key, []).append(value)
+            
+            def inc(self, value=1):
+                metric.values.setdefault(key, []).append(value)
+        return Child()
+
+
+GO_FILES = {
+    'session/session.go': "package session\n\n// Expire drops idle sessions\nfunc Expire(timeout int) int { return timeout }\n",
+    'session/store.go': "package session\n\n// Store keeps sessions by token\ntype Store struct{ tokens map[string]int }\n",
+}
+
+
+def write_tree(root: Path, files):
+    for rel_path, text in files.items():
+        (root / rel_path).parent.mkdir(parents=True, exist_ok=True)
+        (root / rel_path).write_text(text)
+
+
+def test_spans_and_summary():
+    spans = []
+    tracer = Tracer(hooks=[spans.append])
+    with tracer.span('crawl') as span:
+        span.items = 3
+    with tracer.span('embed', items=4, model='hash'):
+        pass
+    try:
+        with tracer.span('embed', items=2):
+            raise RuntimeError("backend down")
+    except RuntimeError:
+        pass
+    tracer.record('parse', 0.25, items=5, start=100.0, filepath='a.go')
+    
+    assert [span.name for span in spans] == ['crawl', 'embed', 'embed', 'parse']
+    assert spans[1].attributes == {'model': 'hash'} and spans[2].attributes == {'error': 'RuntimeError'}
+    assert spans[3].start == 100.0 and spans[3].end == 100.25
+    assert all(span.duration >= 0 for span in spans)
+    
+    summary = tracer.summary()
+    assert list(summary) == ['crawl', 'parse', 'embed'], list(summary)  # pipeline order
+    assert summary['embed']['calls'] == 2 and summary['embed']['items'] == 6
+    assert summary['parse'] == {'calls': 1, 'items': 5, 'total': 0.25, 'max': 0.25, 'mean': 0.25}
+    assert list(tracer.summary(['parse', 'pack'])) == ['parse']
+    rows = format_timings(summary)
+    assert rows["Time (parse)"] == "1 calls, 5 items, 0.25s total, 250.0ms mean, 250.0ms max", rows
+    
+    tracer.reset(['crawl'])
+    assert list(tracer.summary()) == ['parse', 'embed']
+    tracer.reset()
+    assert tracer.summary() == {}
+    
+    # A failing hook is logged; the stage and the other hooks carry on
+    def broken(span):
+        raise ValueError("exporter offline")
+    tracer = Tracer(hooks=[broken, spans.append])
+    with tracer.span('upsert', items=1):
+        pass
+    assert spans[-1].name == 'upsert' and tracer.summary()['upsert']['calls'] == 1
+    tracer.remove_hook(broken)
+    print("✅ Spans record items, attributes and errors; summaries total them per stage")
+
+
+def test_backend_hooks():
+    tracer = Tracer()
+    otel = FakeOtelTracer()
+    histogram, items = FakeMetric(), FakeMetric()
+    stream = io.StringIO()
+    logger = logging.getLogger('tracing_test')
+    logger.addHandler(logging.StreamHandler(stream))
+    logger.setLevel(logging.DEBUG)
+    for hook in (opentelemetry_hook(otel), prometheus_hook(histogram, items), log_hook(logger, logging.INFO)):
+        tracer.add_hook(hook)
+    
+    tracer.record('vector_search', 0.5, items=10, start=12.0, store='sqlite', filters=['go'])
+    name, start, end, attributes = otel.ended[0]
+    assert name == 'code_rag.vector_search' and start == 12 * 10 ** 9 and end == int(12.5 * 10 ** 9)
+    assert attributes == {'store': 'sqlite', 'filters': "['go']", 'items': 10}, attributes
+    assert histogram.values == {(('stage', 'vector_search'),): [0.5]} and items.values[(('stage', 'vector_search'),)] == [10]
+    assert "span vector_search 500.0ms items=10 store=sqlite" in stream.getvalue(), stream.getvalue()
+    print("✅ Hooks send spans to OpenTelemetry, Prometheus and logs without importing either")
+
+
+def test_pipeline_timings(workdir: Path):
+    source = workdir / "tree"
+    write_tree(source, dict(GO_FILES, **{'tools/report.py': "def report(sessions):\n    \"\"\"Print the session count\"\"\"\n    print(len(sessions))\n"}))
+    rag = make_rag(workdir, "tracing")
+    spans = []
+    rag.tracer.add_hook(spans.append)
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "tracing-state.db")), verbose=True)
+    assert indexer.tracer is rag.tracer
+    
+    saved = console.file
+    console.file = output = io.StringIO()
+    try:
+        stats = indexer.index_directory(str(source), parallel=False)
+    finally:
+        console.file = saved
+    timings = stats['timings']
+    assert list(timings) == list(INDEX_STAGES), list(timings)
+    assert timings['crawl']['calls'] == 1 and timings['crawl']['items'] == 3
+    assert timings['parse']['calls'] == 3
+    assert timings['link']['calls'] == 1
+    assert timings['embed']['items'] == timings['upsert']['items'] == stats['chunks_created'], timings
+    assert "Time (parse)" in output.getvalue() and "Time (upsert)" in output.getvalue()
+    parsed = {span.attributes['filepath'] for span in spans if span.name == 'parse'}
+    assert parsed == {'session/session.go', 'session/store.go', 'tools/report.py'}, parsed
+    
+    rag._build_keyword_index()
+    rag.retrieve_context("idle sessions", n_results=2)
+    rag.retrieve_context("idle sessions", n_results=2)  # answered from the query cache
+    rag.retrieve_context("session token", n_results=2, mmr_lambda=0.5)
+    searched = rag.tracer.summary(SEARCH_STAGES)
+    assert searched['search']['calls'] == 3 and searched['vector_search']['calls'] == 2, searched
+    assert searched['keyword_search']['calls'] == 2 and searched['rerank']['calls'] == 1
+    cached = [span.attributes['cached'] for span in spans if span.name == 'search']
+    assert cached == [False, True, False], cached
+    assert [span.attributes['method'] for span in spans if span.name == 'rerank'] == ['mmr']
+    
+    # A new run starts its index totals over; search totals are left alone
+    (source / 'tools/report.py').write_text("def report(sessions):\n    return len(sessions)\n")
+    stats = indexer.update_index(str(source), parallel=False, report=False)
+    assert stats['timings']['parse']['calls'] == 1 and 'link' not in stats['timings'], stats['timings']
+    assert rag.tracer.summary(['search'])['search']['calls'] == 3
+    print("✅ Indexing and search record every stage, with totals in the run's stats")
+
+
+def test_parallel_parse_spans(workdir: Path):
+    source = workdir / "many"
+    write_tree(source, {f'pkg/mod{i}.py': f"def handler_{i}(request):\n    return request\n" for i in range(12)})
+    rag = make_rag(workdir, "tracing_parallel", db="parallel.db")
+    spans = []
+    rag.tracer.add_hook(spans.append)
+    indexer = ChromeIndexer(rag, state_manager=StateManager(str(workdir / "parallel-state.db")))
+    stats = indexer.index_directory(str(source), parallel=True, workers=2)
+    parses = [span for span in spans if span.name == 'parse']
+    assert len(parses) == 12 and stats['timings']['parse']['calls'] == 12, stats['timings']
+    assert all(span.duration > 0 and span.attributes['language'] == 'python' for span in parses)
+    print("✅ Parses timed in worker processes reach the hooks of the parent")
+
+
+def main():
+    print("=" * 70)
+    print("PIPELINE TRACING TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="tracing_"))
+    tests = [test_spans_and_summary, test_backend_hooks, lambda: test_pipeline_timings(workdir),
+             lambda: test_parallel_parse_spans(workdir)]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except Exception as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,159 @@
+#!/usr/bin/env python3
+"""
+Test script for the start-up warm-up
+warm_up embeds a probe, queries each vector collection and optionally runs a
+sample search, timing each step; the HTTP server runs it in the background and
+answers /health with a 503 until it is done
+"""
+
+import json
+import shutil
+import sys
+import tempfile
+import threading
+import urllib.error
+import urllib.request
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from embedders import KeywordOnlyEmbedder
+from helpers import HashEmbedder, make_chroma_rag
+from indexer import ChromeIndexer
+from server import RAGServer
+from utils.state_manager import StateManager
+from utils.warmup import PROBE_TEXT, warm_up
+
+SAMPLES = Path(__file__).parent.parent / "test_samples" / "comprehensive"
+
+
+class GatedEmbedder(HashEmbedder):
+    """Hashed-token vectors; embed waits while the gate is closed, and fails when told to"""
+    
+    def __init__(self):
+        super().__init__()
+        self.gate = threading.Event()
+        self.gate.set()
+        self.fail = False
+    
+    def embed(self, texts):
+        self.gate.wait(10)
+        if self.fail:
+            raise ConnectionError("embedder unreachable")
+        return super().embed(texts)
+
+
+def health(url):
+    try:
+        with urllib.request.urlopen(url + '/health', timeout=10) as response:
+            return response.status, json.loads(response.read())
+    except urllib.error.HTTPError as e:
+        return e.code, json.loads(e.read())
+
+
+def build_rag(workdir, name, embedder):
+    rag = make_chroma_rag(workdir / name, name, embedder)
+    ChromeIndexer(rag, state_manager=StateManager(str(workdir / f"{name}.db"))).update_index(
+        str(workdir / "src"), parallel=False)
+    return rag
+
+
+def test_steps(workdir):
+    embedder = GatedEmbedder()
+    rag = build_rag(workdir, 'steps', embedder)
+    embedder.texts.clear()
+    
+    report = warm_up(rag)
+    assert report['status'] == 'ok', report
+    assert set(report['steps']) == {'embedder', 'vector_index'}, report
+    assert report['seconds'] >= sum(report['steps'].values()) - 0.01, report
+    assert len(embedder.texts) == 1 and PROBE_TEXT in embedder.texts[0], embedder.texts
+    
+    report = warm_up(rag, query="authenticate user")
+    assert set(report['steps']) == {'embedder', 'vector_index', 'query'}, report
+    assert any('authenticate user' in text for text in embedder.texts), "the sample search ran"
+    print("✅ warm_up embeds a probe, queries the collection and runs the sample search, timing each step")
+
+
+def test_keyword_only(workdir):
+    rag = build_rag(workdir, 'keyword', KeywordOnlyEmbedder())
+    report = warm_up(rag, query="authenticate")
+    assert report['status'] == 'ok' and set(report['steps']) == {'query'}, report
+    print("✅ A keyword-only index has no embedder or vectors to warm up, only the sample search")
+
+
+def test_failure(workdir):
+    embedder = GatedEmbedder()
+    rag = build_rag(workdir, 'failure', embedder)
+    embedder.fail = True
+    report = warm_up(rag)
+    assert report['status'] == 'error' and 'embedder unreachable' in report['error'], report
+    assert 'seconds' in report and 'embedder' not in report['steps'], report
+    print("✅ An unreachable embedder fails the warm-up with its error instead of raising")
+
+
+def test_server(workdir):
+    embedder = GatedEmbedder()
+    rag = build_rag(workdir, 'server', embedder)
+    embedder.gate.clear()
+    server = RAGServer(('127.0.0.1', 0), rag, warmup=True, warmup_query="authenticate")
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        assert server.warming and not server.wait_warm(0.1)
+        status, body = health(url)
+        assert status == 503 and body == {'status': 'warming'}, (status, body)
+        
+        embedder.gate.set()
+        assert server.wait_warm(10), "the warm-up finished"
+        status, body = health(url)
+        assert status == 200 and body['status'] == 'ok', (status, body)
+        warmup = body['warmup']
+        assert warmup['status'] == 'ok' and set(warmup['steps']) == {'embedder', 'vector_index', 'query'}, warmup
+    finally:
+        embedder.gate.set()
+        server.shutdown()
+        server.server_close()
+    
+    server = RAGServer(('127.0.0.1', 0), rag)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    try:
+        status, body = health(f"http://127.0.0.1:{server.server_address[1]}")
+        assert status == 200 and body['warmup'] is None and not server.warming, body
+    finally:
+        server.shutdown()
+        server.server_close()
+    print("✅ /health answers 503 while the server warms up, then reports the warm-up's duration")
+
+
+def main():
+    print("=" * 70)
+    print("WARM-UP TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_warmup_"))
+    (workdir / "src").mkdir()
+    shutil.copy(SAMPLES / "complex.go", workdir / "src" / "complex.go")
+    
+    tests = [
+        lambda: test_steps(workdir), lambda: test_keyword_only(workdir), lambda: test_failure(workdir),
+        lambda: test_server(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
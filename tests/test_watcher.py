@@ -0,0 +1,214 @@
+#!/usr/bin/env python3
+"""
+Test script for watch mode: events are fed to the watcher by hand, so the
+debouncing, filtering and scoped updates run without watchdog; the live
+observer is exercised too when watchdog is installed
+"""
+
+import os
+import shutil
+import sys
+import tempfile
+import threading
+import time
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from helpers import HashEmbedder, make_rag
+from indexer import ChromeIndexer
+from utils.state_manager import StateManager
+from watcher import IndexWatcher
+
+try:
+    import watchdog  # noqa: F401
+    HAVE_WATCHDOG = True
+except ImportError:
+    HAVE_WATCHDOG = False
+
+
+def go_file(package, name, body='return 1'):
+    return f"package {package}\n\nfunc {name}() int {{\n    {body}\n}}\n"
+
+
+def make_tree(root: Path):
+    (root / "auth").mkdir(parents=True)
+    (root / "store").mkdir()
+    (root / "gen").mkdir()
+    (root / ".gitignore").write_text("gen/\n")
+    (root / "auth" / "login.go").write_text(go_file("auth", "Login"))
+    (root / "auth" / "logout.go").write_text(go_file("auth", "Logout"))
+    (root / "store" / "open.go").write_text(go_file("store", "Open"))
+
+
+class Harness:
+    """A tree indexed once, with a watcher whose updates are collected"""
+    
+    def __init__(self, workdir: Path, debounce: float = 0.0):
+        self.root = workdir / "src"
+        make_tree(self.root)
+        self.embedder = HashEmbedder()
+        self.rag = make_rag(workdir, "watch", embedder=self.embedder)
+        self.indexer = ChromeIndexer(self.rag, state_manager=StateManager(str(workdir / "state.db")))
+        self.indexer.update_index(str(self.root), parallel=False, report=False)
+        self.updates = []
+        self.watcher = IndexWatcher(self.indexer, str(self.root), debounce=debounce,
+                                    on_update=self.updates.append, parallel=False)
+    
+    def save(self, relative, content):
+        path = self.root / relative
+        path.write_text(content)
+        self.watcher.notify(str(path))
+    
+    def names(self, filepath):
+        found = self.rag.collection.get(where={"filepath": filepath})
+        return sorted(m['name'] for m in found['metadatas'] if m['type'] != 'package')
+    
+    def embedded(self):
+        texts, self.embedder.texts = self.embedder.texts, []
+        return texts
+
+
+def test_save_reembeds_only_that_file(h):
+    h.embedded()
+    h.save("store/open.go", go_file("store", "OpenReadOnly"))
+    stats = h.watcher.flush()
+    assert stats['paths_updated'] == ['store/open.go'] and not stats['paths_added'], stats
+    assert h.names('store/open.go') == ['OpenReadOnly']
+    texts = h.embedded()
+    assert texts and all('OpenReadOnly' in t for t in texts), texts
+    assert h.watcher.flush() is None, "nothing should be pending after a flush"
+    print("✅ A save re-embeds only the saved file")
+
+
+def test_atomic_save(h):
+    # Write a temporary file, then rename it over the original (vim, JetBrains, VS Code)
+    temporary = h.root / "store" / "open.go.tmp4913"
+    temporary.write_text(go_file("store", "OpenShared"))
+    h.watcher.notify(str(temporary))
+    os.replace(temporary, h.root / "store" / "open.go")
+    h.watcher.notify(str(temporary))
+    h.watcher.notify(str(h.root / "store" / "open.go"))
+    
+    stats = h.watcher.flush()
+    assert stats['paths_updated'] == ['store/open.go'], stats
+    assert not (stats['paths_added'] or stats['paths_removed'] or stats['paths_moved']), stats
+    assert h.names('store/open.go') == ['OpenShared']
+    print("✅ Atomic save is one update of the original file")
+
+
+def test_rename_keeps_embeddings(h):
+    h.embedded()
+    os.rename(h.root / "store" / "open.go", h.root / "store" / "opener.go")
+    h.watcher.notify(str(h.root / "store" / "open.go"))
+    h.watcher.notify(str(h.root / "store" / "opener.go"))
+    stats = h.watcher.flush()
+    assert stats['paths_moved'] == [('store/open.go', 'store/opener.go')], stats
+    assert h.names('store/opener.go') == ['OpenShared'] and h.names('store/open.go') == []
+    assert h.embedded() == [], "a rename should not re-embed"
+    print("✅ A rename moves the chunks without re-embedding")
+
+
+def test_linked_package(h):
+    h.save("auth/login.go", go_file("auth", "Login", "return Logout()"))
+    stats = h.watcher.flush()
+    assert stats['paths_updated'] == ['auth/login.go'] and stats['files_relinked'] == 1, stats
+    print("✅ A Go file change relinks the rest of its package")
+
+
+def test_ignored_paths(h):
+    (h.root / ".git").mkdir()
+    h.watcher.notify(str(h.root / ".git" / "index"))
+    h.watcher.notify(str(h.root / "store" / ".opener.go.swp"))
+    h.watcher.notify(str(h.root.parent / "elsewhere.go"))
+    assert not h.watcher.pending(), "excluded, unindexed and outside paths should be dropped"
+    
+    h.save("gen/api.go", go_file("gen", "Generated"))
+    stats = h.watcher.flush()
+    assert not stats['paths_added'] and h.names('gen/api.go') == [], stats
+    
+    # A changed .gitignore re-evaluates its directory
+    (h.root / ".gitignore").write_text("gen/\nauth/logout.go\n")
+    h.watcher.notify(str(h.root / ".gitignore"))
+    stats = h.watcher.flush()
+    assert stats['paths_removed'] == ['auth/logout.go'], stats
+    print("✅ Ignore rules apply to events; a .gitignore change is picked up")
+
+
+def test_deleted_directory(h):
+    shutil.rmtree(h.root / "store")
+    h.watcher.notify(str(h.root / "store"), is_directory=True)
+    stats = h.watcher.flush()
+    assert stats['paths_removed'] == ['store/opener.go'], stats
+    print("✅ Deleting a directory purges its files")
+
+
+def test_debounce(workdir):
+    h = Harness(workdir, debounce=0.2)
+    h.save("store/open.go", go_file("store", "OpenOne"))
+    time.sleep(0.1)
+    h.save("store/open.go", go_file("store", "OpenTwo"))
+    assert h.watcher.pending() and not h.watcher.due()
+    time.sleep(0.25)
+    assert h.watcher.due()
+    h.watcher.flush()
+    assert len(h.updates) == 1 and h.names('store/open.go') == ['OpenTwo']
+    print("✅ A burst of saves is indexed once, after the debounce interval")
+
+
+def test_live_observer(workdir):
+    h = Harness(workdir, debounce=0.1)
+    stop = threading.Event()
+    thread = threading.Thread(target=h.watcher.run, kwargs={'stop': stop}, daemon=True)
+    thread.start()
+    time.sleep(0.5)
+    (h.root / "auth" / "session.go").write_text(go_file("auth", "NewSession"))
+    deadline = time.monotonic() + 10
+    while not any('auth/session.go' in u['paths_added'] for u in h.updates) and time.monotonic() < deadline:
+        time.sleep(0.05)
+    stop.set()
+    thread.join(5)
+    assert h.names('auth/session.go') == ['NewSession'], h.updates
+    print("✅ watchdog events trigger updates")
+
+
+def main():
+    print("=" * 70)
+    print("WATCH MODE TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="watcher_"))
+    (workdir / "shared").mkdir()
+    h = Harness(workdir / "shared")
+    tests = [
+        lambda: test_save_reembeds_only_that_file(h),
+        lambda: test_atomic_save(h),
+        lambda: test_rename_keeps_embeddings(h),
+        lambda: test_linked_package(h),
+        lambda: test_ignored_paths(h),
+        lambda: test_deleted_directory(h),
+        lambda: test_debounce(Path(tempfile.mkdtemp(dir=workdir))),
+    ]
+    if HAVE_WATCHDOG:
+        tests.append(lambda: test_live_observer(Path(tempfile.mkdtemp(dir=workdir))))
+    else:
+        print("⚠️  watchdog not installed: skipping the live observer test")
+    
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except AssertionError as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,296 @@
+#!/usr/bin/env python3
+"""
+Test script for the Zig chunker
+Sources are parsed by tree-sitter-zig
+"""
+
+import shutil
+import sys
+import tempfile
+from pathlib import Path
+sys.path.insert(0, str(Path(__file__).parent.parent))
+
+from chunkers import ZigChunker
+from config import CONFIG
+from helpers import make_chroma_rag
+
+
+COLLECTIONS = '''//! Collections for the render pipeline.
+//! Everything here allocates through an explicit allocator.
+const std = @import("std");
+const Allocator = std.mem.Allocator;
+const assert = @import("std").debug.assert;
+
+/// Most items a list holds before it reallocates.
+pub const max_items: usize = 1024;
+
+var instances: u32 = 0;
+
+/// A 2D point.
+pub const Point = struct {
+    /// Horizontal position
+    x: f32 = 0,
+    y: f32 = 0,
+    
+    const Self = @This();
+    
+    /// Distance between two points.
+    pub fn distance(self: Self, other: Self) f32 {
+        const dx = self.x - other.x;
+        const dy = self.y - other.y;
+        return @sqrt(dx * dx + dy * dy);
+    }
+    
+    fn scale(self: *Self, factor: f32) void {
+        self.x *= factor;
+        self.y *= factor;
+    }
+};
+
+pub const Color = enum(u8) {
+    red,
+    green = 4,
+    blue,
+    
+    pub fn isWarm(self: Color) bool {
+        return self == .red;
+    }
+};
+
+const Shape = union(enum) {
+    circle: f32,
+    rect: Point,
+    none,
+};
+
+pub const Flags = packed struct(u8) {
+    visible: bool,
+    dirty: bool,
+    _pad: u6 = 0,
+};
+
+pub const ParseError = error{ InvalidChar, Overflow };
+
+const Handle = opaque {};
+
+/// A growable list of T.
+pub fn List(comptime T: type) type {
+    return struct {
+        items: []T,
+        allocator: Allocator,
+        
+        const Self = @This();
+        
+        pub fn init(allocator: Allocator) Self {
+            return .{ .items = &[_]T{}, .allocator = allocator };
+        }
+        
+        pub fn append(self: *Self, item: T) !void {
+            _ = item;
+            _ = self;
+        }
+    };
+}
+
+/// Parses a number of any integer type.
+pub fn parseInt(comptime T: type, text: []const u8, base: u8) ParseError!T {
+    _ = text;
+    _ = base;
+    return 0;
+}
+
+fn oops() error{Oops}!void {
+    return error.Oops;
+}
+
+export fn render_frame(width: c_int, height: c_int) callconv(.C) c_int {
+    return width * height;
+}
+
+extern "c" fn printf(format: [*:0]const u8, ...) c_int;
+
+inline fn square(x: anytype) @TypeOf(x) {
+    const text =
+        \\\\ not a } brace
+    ;
+    _ = text;
+    return x * x;
+}
+
+test "point distance" {
+    const a = Point{ .x = 0, .y = 0 };
+    try std.testing.expectEqual(@as(f32, 0), a.distance(a));
+}
+
+test parseInt {
+    try std.testing.expectEqual(@as(u8, 0), try parseInt(u8, "0", 10));
+}
+
+test {
+    _ = Point;
+}
+'''
+
+
+def by_name(chunks, name, type=None):
+    return next(c for c in chunks if c.name == name and (type is None or c.type == type))
+
+
+def lines(chunk):
+    return chunk.line_start, chunk.line_end
+
+
+def test_functions():
+    """fn declarations with pub, qualifiers, comptime parameters and return types"""
+    chunks = ZigChunker().extract_chunks(COLLECTIONS, 'src/collections.zig')
+    
+    parse = by_name(chunks, 'parseInt')
+    assert parse.type == 'function' and parse.metadata['pub'] is True
+    assert parse.signature == 'pub fn parseInt(comptime T: type, text: []const u8, base: u8) ParseError!T', \
+        parse.signature
+    assert parse.metadata['params'] == [{'name': 'T', 'type': 'type', 'comptime': True},
+                                        {'name': 'text', 'type': '[]const u8'}, {'name': 'base', 'type': 'u8'}]
+    assert parse.metadata['returns'] == 'ParseError!T' and lines(parse) == (79, 83)
+    
+    oops = by_name(chunks, 'oops')
+    assert oops.metadata['pub'] is False and oops.metadata['returns'] == 'error{Oops}!void' and lines(oops) == (85, 87)
+    render = by_name(chunks, 'render_frame')
+    assert render.metadata['qualifiers'] == ['export'] and render.metadata['callconv'] == '.C'
+    assert render.metadata['returns'] == 'c_int'
+    printf = by_name(chunks, 'printf')
+    assert printf.metadata['library'] == 'c' and printf.metadata['params'][-1]['name'] == '...'
+    assert lines(printf) == (93, 93)
+    square = by_name(chunks, 'square')
+    assert square.metadata['params'] == [{'name': 'x', 'type': 'anytype'}] and lines(square) == (95, 101), \
+        "a brace in a multiline string closes nothing"
+    print("✅ Functions record pub, qualifiers, comptime parameters and return types")
+
+
+def test_container_types():
+    """Structs, enums, unions, opaques and error sets bound to consts are types"""
+    chunks = ZigChunker().extract_chunks(COLLECTIONS, 'src/collections.zig')
+    
+    point = by_name(chunks, 'Point')
+    assert point.type == 'struct' and point.metadata['pub'] is True and point.metadata['binding'] == 'const'
+    assert point.signature == 'pub const Point = struct' and lines(point) == (13, 31)
+    assert point.metadata['fields'] == [{'name': 'x', 'type': 'f32', 'default': '0', 'doc': 'Horizontal position'},
+                                        {'name': 'y', 'type': 'f32', 'default': '0'}], point.metadata['fields']
+    assert point.metadata['methods'] == ['distance', 'scale']
+    distance = by_name(chunks, 'distance')
+    assert distance.type == 'method' and distance.qualified_name == 'Point.distance' and distance.parent_class == 'Point'
+    assert by_name(chunks, 'scale').metadata['pub'] is False
+    assert 'Self' not in [c.name for c in chunks], "constants inside a type stay part of it"
+    
+    color = by_name(chunks, 'Color')
+    assert color.type == 'enum' and color.metadata['tag'] == 'u8'
+    assert color.metadata['variants'] == ['red', 'green', 'blue'] and color.metadata['methods'] == ['isWarm']
+    shape = by_name(chunks, 'Shape')
+    assert shape.type == 'union' and shape.metadata['tag'] == 'enum' and shape.metadata['pub'] is False
+    assert [f['type'] for f in shape.metadata['fields']] == ['f32', 'Point', 'void']
+    flags = by_name(chunks, 'Flags')
+    assert flags.metadata['layout'] == 'packed' and flags.signature == 'pub const Flags = packed struct(u8)'
+    errors = by_name(chunks, 'ParseError')
+    assert errors.type == 'type' and errors.metadata['container'] == 'error_set'
+    assert errors.metadata['errors'] == ['InvalidChar', 'Overflow']
+    assert by_name(chunks, 'Handle').metadata['container'] == 'opaque'
+    print("✅ Container types bound to consts are typed chunks with their fields, variants and methods")
+
+
+def test_generic_types():
+    """A fn returning the struct it builds is a generic type owning that struct's members"""
+    chunks = ZigChunker().extract_chunks(COLLECTIONS, 'src/collections.zig')
+    generic = by_name(chunks, 'List')
+    assert generic.type == 'function' and generic.metadata['generic_type'] == 'struct'
+    assert generic.signature == 'pub fn List(comptime T: type) type' and lines(generic) == (60, 76)
+    assert [f['name'] for f in generic.metadata['fields']] == ['items', 'allocator']
+    assert generic.metadata['methods'] == ['init', 'append']
+    append = by_name(chunks, 'append')
+    assert append.qualified_name == 'List.append' and append.metadata['returns'] == '!void'
+    print("✅ Generic type functions own the members of the struct they return")
+
+
+def test_declarations():
+    """Top-level const and var declarations, imports recorded"""
+    chunks = ZigChunker().extract_chunks(COLLECTIONS, 'src/collections.zig')
+    std = by_name(chunks, 'std')
+    assert std.type == 'constant' and std.metadata['import'] == 'std'
+    assert by_name(chunks, 'assert').metadata == {'pub': False, 'binding': 'const', 'import': 'std',
+                                                   'member': 'debug.assert'}
+    max_items = by_name(chunks, 'max_items')
+    assert max_items.metadata['pub'] is True and max_items.metadata['declared_type'] == 'usize'
+    assert max_items.metadata['value'] == '1024' and max_items.signature == 'pub const max_items: usize'
+    instances = by_name(chunks, 'instances')
+    assert instances.type == 'variable' and instances.metadata['binding'] == 'var'
+    print("✅ Top-level constants and variables are indexed with their types and imports")
+
+
+def test_tests_and_docs():
+    """test blocks are test chunks; /// and //! comments fill the doc field"""
+    chunks = ZigChunker().extract_chunks(COLLECTIONS, 'src/collections.zig')
+    test = by_name(chunks, 'point distance')
+    assert test.kind == 'test' and test.signature == 'test "point distance"' and lines(test) == (103, 106)
+    decltest = by_name(chunks, 'parseInt', 'function')
+    assert decltest.kind == 'source'
+    assert [c.metadata.get('decltest') for c in chunks if c.kind == 'test'] == [None, True], \
+        "decltests are kept, anonymous test blocks left out"
+    
+    module = by_name(chunks, 'collections', 'module')
+    assert module.doc == 'Collections for the render pipeline.\nEverything here allocates through an explicit allocator.'
+    assert by_name(chunks, 'List').doc == 'A growable list of T.'
+    assert by_name(chunks, 'max_items').doc == 'Most items a list holds before it reallocates.'
+    assert by_name(chunks, 'Color').doc is None
+    
+    chunker = ZigChunker()
+    chunker.extract_chunks('pub fn broken() void {\n    if (x) {\n        return;\n}\n', 'broken.zig')
+    assert chunker.diagnostics and "missing '}' for fn broken" in chunker.diagnostics[0]['message'], \
+        chunker.diagnostics
+    assert CONFIG.file_types['zig'].parser_type == 'treesitter'
+    print("✅ Test blocks, doc comments and unclosed bodies are handled")
+
+
+def test_search(workdir):
+    """Zig types are found by qualified name, and tests by kind"""
+    rag = make_chroma_rag(workdir / "db", "test_zig")
+    rag.add_chunks_batch(ZigChunker().extract_chunks(COLLECTIONS, 'src/collections.zig'))
+    rag._build_keyword_index()
+    
+    names = [r['metadata']['name'] for r in rag.retrieve_context("point", n_results=20, filter_expr="name=Point.*")]
+    assert sorted(names) == ['distance', 'scale'], names
+    results = rag.retrieve_context("distance between two points", n_results=3, languages=['zig'],
+                                   filter_expr="kind=method")
+    assert results[0]['metadata']['name'] == 'distance', [r['metadata']['name'] for r in results]
+    names = [r['metadata']['name'] for r in rag.retrieve_context("point", n_results=20, filter_expr="kind=test")]
+    assert sorted(names) == ['parseInt', 'point distance'], names
+    print("✅ Zig symbols are searchable by owning type and kind")
+
+
+def main():
+    print("=" * 70)
+    print("ZIG CHUNKER TEST")
+    print("=" * 70)
+    
+    workdir = Path(tempfile.mkdtemp(prefix="rag_zig_"))
+    
+    tests = [
+        test_functions, test_container_types, test_generic_types, test_declarations, test_tests_and_docs,
+        lambda: test_search(workdir)
+    ]
+    failed = 0
+    for test in tests:
+        try:
+            test()
+        except (Exception, AssertionError) as e:
+            failed += 1
+            print(f"❌ Test failed: {e}")
+    shutil.rmtree(workdir, ignore_errors=True)
+    
+    print()
+    if failed:
+        print(f"❌ {failed}/{len(tests)} tests failed")
+        return 1
+    print(f"🎉 All {len(tests)} tests passed!")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
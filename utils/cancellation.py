@@ -0,0 +1,95 @@
+#!/usr/bin/env python3
+"""
+Cancellation and progress reporting for indexing runs
+A CancelToken is shared by the run and whoever may stop it (Ctrl+C, a
+timeout, a server shutting down). The indexer checks it between files and
+between insert batches, so a run stops at the next one, and a file's chunks
+are either all in the index or none are. Progress is reported by passing
+the run's IndexProgress to a callback as it changes.
+"""
+
+import signal
+import threading
+import time
+from contextlib import contextmanager
+from dataclasses import asdict, dataclass
+from typing import Callable, Dict, Optional
+
+
+# Stages of an indexing run, in order; a run ends 'done' or 'cancelled'
+PROGRESS_STAGES = ('discovering', 'parsing', 'linking', 'storing', 'done', 'cancelled')
+
+
+class CancelToken:
+    """Thread-safe cancellation flag, optionally set by itself after a timeout"""
+    
+    def __init__(self, timeout: Optional[float] = None):
+        """
+        Args:
+            timeout: Seconds after which the token counts as cancelled (None = never)
+        """
+        self._event = threading.Event()
+        self._reason: Optional[str] = None
+        self.deadline = time.monotonic() + timeout if timeout else None
+    
+    def cancel(self, reason: str = 'cancelled'):
+        """Ask the run to stop; the first reason given is kept"""
+        if not self._event.is_set():
+            self._reason = reason
+            self._event.set()
+    
+    @property
+    def cancelled(self) -> bool:
+        if not self._event.is_set() and self.deadline is not None and time.monotonic() >= self.deadline:
+            self.cancel('timed out')
+        return self._event.is_set()
+    
+    @property
+    def reason(self) -> Optional[str]:
+        """Why the token was cancelled, or None"""
+        return self._reason if self.cancelled else None
+
+
+@dataclass
+class IndexProgress:
+    """Where an indexing run is (counts cover the files of this run)"""
+    stage: str = 'discovering'
+    files_total: int = 0         # files to parse, known once discovery is done
+    files_parsed: int = 0        # including files that failed to parse
+    chunks_produced: int = 0
+    chunks_embedded: int = 0     # embedded and stored
+    current_file: Optional[str] = None
+    
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+# Receives the run's IndexProgress (updated in place) after every file parsed and batch stored
+ProgressCallback = Callable[[IndexProgress], None]
+
+
+@contextmanager
+def cancel_on_interrupt(token: CancelToken):
+    """
+    Turn the first Ctrl+C into token.cancel() while the block runs
+    A second Ctrl+C raises KeyboardInterrupt as usual. Does nothing outside the main thread
+    """
+    if threading.current_thread() is not threading.main_thread():
+        yield token
+        return
+    
+    def interrupt(signum, frame):
+        if token.cancelled:
+            raise KeyboardInterrupt
+        token.cancel('interrupted')
+    
+    previous = signal.signal(signal.SIGINT, interrupt)
+    try:
+        yield token
+    finally:
+        signal.signal(signal.SIGINT, previous)
+
+
+def ignore_interrupts():
+    """Pool initializer: Ctrl+C is handled by the parent, which stops the workers"""
+    signal.signal(signal.SIGINT, signal.SIG_IGN)
@@ -0,0 +1,94 @@
+#!/usr/bin/env python3
+"""
+Deduplication of identical chunks across files
+Vendored and generated code repeats the same functions in many places. With
+dedup on, chunks whose bodies hash alike are stored once: the first one stored
+keeps the embedding and lists every other place the body appears in its
+'duplicates' metadata, so the index holds one vector per distinct body and a
+search returns one result for it, annotated with where else it is.
+'exact' compares the chunk text as is; 'normalized' also ignores whitespace
+and comments. With code normalization on, the text compared is the normalized
+code that is embedded, so 'exact' already treats reformatted copies as one.
+"""
+
+import hashlib
+import json
+import re
+from typing import Dict, List, Optional
+
+from chunkers.base_chunker import repo_filepath
+from utils.code_normalization import strip_comments
+
+
+# 'off' stores every chunk with its own embedding
+DEDUP_MODES = ('off', 'exact', 'normalized')
+
+# Location fields shown for each place a duplicate appears
+LOCATION_FIELDS = ('filepath', 'repo', 'line_start', 'line_end', 'name', 'parent')
+
+
+def normalize_body(content: str, language: str) -> str:
+    """
+    Chunk text without comments, with every run of whitespace collapsed to one space
+    String literals are kept as written, so '//' or '#' inside them is not a comment
+    """
+    return re.sub(r'\s+', ' ', strip_comments(content, language)).strip()
+
+
+def content_hash(content: str, language: str, mode: str) -> str:
+    """
+    Hash identifying a chunk body under a dedup mode; hashes of different modes never match,
+    so an index switched between them just stops finding earlier duplicates
+    """
+    body = normalize_body(content, language) if mode == 'normalized' else content
+    digest = hashlib.sha1(f"{language}\0{body}".encode('utf-8')).hexdigest()
+    return f"{mode}:{digest}"
+
+
+def parse_duplicates(metadata: Optional[Dict]) -> List[Dict]:
+    """The duplicate entries a stored chunk lists: each the stored metadata of another place, with its 'id'"""
+    value = (metadata or {}).get('duplicates')
+    if not value:
+        return []
+    try:
+        entries = json.loads(value) if isinstance(value, str) else value
+    except ValueError:
+        return []
+    return entries if isinstance(entries, list) else []
+
+
+def set_duplicates(metadata: Dict, entries: List[Dict]) -> Dict:
+    """
+    Stored metadata listing entries as its duplicates (values must stay scalars for the stores,
+    and an emptied list is still written, since store updates merge into the stored metadata)
+    """
+    return dict(metadata, duplicates=json.dumps(entries, default=str) if entries else '',
+                duplicate_count=len(entries))
+
+
+def duplicate_entry(chunk_id: str, metadata: Dict, content: str, representative_content: str) -> Dict:
+    """
+    How a stored chunk lists another place its body appears: that chunk's own metadata,
+    so it can take over if the stored one is removed, and its text only where it differs
+    """
+    entry = {key: value for key, value in metadata.items()
+             if key not in ('content', 'duplicates', 'duplicate_count')}
+    entry['id'] = chunk_id
+    if content != representative_content:
+        entry['content'] = content
+    return entry
+
+
+def in_file(entry: Dict, filepath: str, repo: Optional[str] = None) -> bool:
+    """True if a duplicate entry is a chunk of the file"""
+    return entry.get('filepath') == filepath and (not repo or entry.get('repo') == repo)
+
+
+def locations(metadata: Optional[Dict]) -> List[Dict]:
+    """The other places a stored chunk's body appears, as file locations"""
+    found = []
+    for entry in parse_duplicates(metadata):
+        location = {key: entry[key] for key in LOCATION_FIELDS if entry.get(key) not in (None, '')}
+        location['location'] = repo_filepath(entry.get('repo') or None, entry.get('filepath', ''))
+        found.append(location)
+    return found
@@ -0,0 +1,139 @@
+#!/usr/bin/env python3
+"""
+Ingestion of chunks parsed elsewhere
+Chunks arrive as JSON lines in the export schema (utils/jsonl_export.py), so a
+tool with its own parser for a language can hand its symbols to the embedding
+and retrieval pipeline, and an export can be loaded into another index. Each
+record needs:
+
+    filepath        path relative to the indexed root
+    language        language of the file
+    kind            symbol kind (function, method, type, ...), or the chunk type
+    name            symbol name ('' for an unnamed block)
+    body            the code of the chunk
+    line_start      first line, 1-based
+    line_end        last line, inclusive
+
+Every other export field is optional (chunk_type, test, qualified_name,
+symbol_id, part_index, part_count, repo, namespace, signature, doc, byte_start,
+byte_end, metadata, schema_version). Ids and embeddings are not read: ids
+derive from symbol_id, or from path, qualified name and first line without
+one, and chunks are embedded with the index's own model.
+"""
+
+import json
+from typing import Dict, Iterator, Optional, TextIO, Tuple
+
+from chunkers.base_chunker import CodeChunk
+from utils.jsonl_export import EXPORT_SCHEMA_VERSION
+
+REQUIRED_FIELDS = {
+    'filepath': str,
+    'language': str,
+    'kind': str,
+    'name': str,
+    'body': str,
+    'line_start': int,
+    'line_end': int,
+}
+
+OPTIONAL_FIELDS = {
+    'schema_version': int,
+    'chunk_type': str,
+    'test': bool,
+    'qualified_name': str,
+    'symbol_id': str,
+    'part_index': int,
+    'part_count': int,
+    'repo': str,
+    'namespace': str,
+    'signature': str,
+    'doc': str,
+    'byte_start': int,
+    'byte_end': int,
+    'metadata': dict,
+}
+
+
+def record_chunk(record) -> CodeChunk:
+    """
+    The chunk a record describes
+    
+    Raises:
+        ValueError: naming the first problem with the record (a missing or
+            mistyped field, an inverted line range, a newer schema version)
+    """
+    if not isinstance(record, dict):
+        raise ValueError(f"expected a JSON object, got {type(record).__name__}")
+    for field, kind in REQUIRED_FIELDS.items():
+        if field not in record:
+            raise ValueError(f"missing '{field}'")
+        _check_type(record, field, kind)
+    for field, kind in OPTIONAL_FIELDS.items():
+        if record.get(field) is not None:
+            _check_type(record, field, kind)
+    if not record['filepath'].strip():
+        raise ValueError("'filepath' is empty")
+    if record['line_start'] < 1 or record['line_end'] < record['line_start']:
+        raise ValueError(f"bad line range {record['line_start']}-{record['line_end']}")
+    if record.get('schema_version', EXPORT_SCHEMA_VERSION) > EXPORT_SCHEMA_VERSION:
+        raise ValueError(f"schema_version {record['schema_version']} is newer than {EXPORT_SCHEMA_VERSION}")
+    part_index, part_count = record.get('part_index') or 0, record.get('part_count') or 1
+    if not 0 <= part_index < part_count:
+        raise ValueError(f"bad part {part_index} of {part_count}")
+    
+    # The enclosing symbol is what qualified_name adds in front of the name
+    qualified = record.get('qualified_name') or ''
+    suffix = '.' + record['name']
+    parent = qualified[:-len(suffix)] if record['name'] and qualified.endswith(suffix) else None
+    has_bytes = record.get('byte_start') is not None and record.get('byte_end') is not None
+    return CodeChunk(
+        type=record.get('chunk_type') or record['kind'],
+        name=record['name'],
+        content=record['body'],
+        filepath=record['filepath'],
+        language=record['language'],
+        line_start=record['line_start'],
+        line_end=record['line_end'],
+        signature=record.get('signature') or None,
+        namespace=record.get('namespace') or None,
+        parent=parent,
+        doc=record.get('doc') or None,
+        metadata=record.get('metadata') or None,
+        symbol_id=record.get('symbol_id') or None,
+        part_index=part_index,
+        part_count=part_count,
+        byte_start=record['byte_start'] if has_bytes else None,
+        byte_end=record['byte_end'] if has_bytes else None,
+        kind='test' if record.get('test') else 'source',
+        repo=record.get('repo') or None,
+    )
+
+
+def read_records(reader: TextIO) -> Iterator[Tuple[int, Optional[CodeChunk], Optional[str]]]:
+    """
+    The chunks of JSON lines, one at a time
+    
+    Yields:
+        (line number, chunk, None) for a valid record and (line number, None,
+        problem) for a malformed one; blank lines are skipped
+    """
+    for number, line in enumerate(reader, 1):
+        if not line.strip():
+            continue
+        try:
+            record = json.loads(line)
+        except json.JSONDecodeError as e:
+            yield number, None, f"invalid JSON: {e.msg}"
+            continue
+        try:
+            yield number, record_chunk(record), None
+        except ValueError as e:
+            yield number, None, str(e)
+
+
+def _check_type(record: Dict, field: str, kind: type):
+    value = record[field]
+    # bool is an int subclass, but a line number of true is not one
+    if not isinstance(value, kind) or (kind is int and isinstance(value, bool)):
+        raise ValueError(f"'{field}' must be {kind.__name__}, got {type(value).__name__}")
@@ -0,0 +1,181 @@
+#!/usr/bin/env python3
+"""
+Post-processing of chunks before they are embedded
+A ChunkProcessor sees every chunk the parsers produce, after the size and
+exclude_symbols filters and before the secret scan and the embedder, and can
+add to its metadata (who owns it, the tickets its comments cite), rewrite the
+text it is embedded from (metadata['embedding_text'], still formatted by the
+document template) or drop it. Processors run in order, each on what the one
+before returned; one that raises leaves the chunk to the next and the error goes
+into the indexing summary. CONFIG.chunk_processors names them: 'codeowners' for
+the CodeOwnersProcessor below, or 'package.module:Class' for one of your own,
+built without arguments.
+"""
+
+import importlib
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+from chunkers.base_chunker import CodeChunk
+from utils.ignore_rules import IgnorePattern
+
+
+# Metadata key whose text is embedded instead of the chunk's code (or doc and signature)
+EMBEDDING_TEXT_KEY = 'embedding_text'
+
+# Where CODEOWNERS is looked for in an indexed root, the first one found being used (as GitHub does)
+CODEOWNERS_LOCATIONS = ('.github/CODEOWNERS', 'CODEOWNERS', 'docs/CODEOWNERS')
+
+
+class ChunkProcessor:
+    """
+    One step of chunk post-processing
+    
+    Subclasses override process, and start when they read something from the
+    indexed tree (start is called once per run, in the indexing process, before
+    the first chunk).
+    """
+    
+    @property
+    def name(self) -> str:
+        """Name the processor's errors are reported under"""
+        return type(self).__name__
+    
+    def start(self, root: Path):
+        """Prepare for a run over the tree at root (chunk file paths are relative to it)"""
+    
+    def process(self, chunk: CodeChunk) -> Optional[CodeChunk]:
+        """
+        The chunk to index in place of chunk (usually chunk itself, changed), or None to drop it
+        Raise to report a problem; the chunk goes on to the next processor
+        """
+        return chunk
+
+
+def run_processors(processors: List[ChunkProcessor], chunks: List[CodeChunk]) -> Tuple[List[CodeChunk], List[str]]:
+    """
+    Chunks after every processor has run on them, in order
+    
+    Returns:
+        (chunks kept, one 'path:line: processor: error' per exception raised)
+    """
+    if not processors:
+        return chunks, []
+    kept, errors = [], []
+    for chunk in chunks:
+        for processor in processors:
+            try:
+                processed = processor.process(chunk)
+            except Exception as e:
+                errors.append(f"{chunk.location}:{chunk.line_start}: {processor.name}: {e}")
+                continue
+            if processed is None:
+                chunk = None
+                break
+            chunk = processed
+        if chunk is not None:
+            kept.append(chunk)
+    return kept, errors
+
+
+def load_processor(spec: str) -> ChunkProcessor:
+    """
+    Processor named by a CONFIG.chunk_processors entry: a built-in name (BUILTIN_PROCESSORS)
+    or 'package.module:Class', a ChunkProcessor subclass built without arguments
+    ValueError if it does not name one
+    """
+    if spec in BUILTIN_PROCESSORS:
+        return BUILTIN_PROCESSORS[spec]()
+    module_name, _, class_name = spec.partition(':')
+    if not module_name or not class_name:
+        raise ValueError(f"Unknown chunk processor: {spec} (expected one of: "
+                         f"{', '.join(BUILTIN_PROCESSORS)}, or 'package.module:Class')")
+    try:
+        factory = getattr(importlib.import_module(module_name), class_name)
+    except (ImportError, AttributeError) as e:
+        raise ValueError(f"Cannot load chunk processor {spec}: {e}") from e
+    if not callable(getattr(factory, 'process', None)):
+        raise ValueError(f"Chunk processor {spec} has no process method")
+    return factory()
+
+
+class CodeOwnersRule:
+    """One CODEOWNERS line: a gitignore-style pattern and the owners of what it matches"""
+    
+    def __init__(self, pattern: str, owners: List[str]):
+        self.source = pattern
+        self.owners = owners
+        self._pattern = IgnorePattern(pattern)
+    
+    def matches(self, path: str) -> bool:
+        """
+        True if the pattern matches the file, or one of the directories it is in
+        ('docs/*' only covers the files right in docs, not those in its subdirectories)
+        """
+        if self._pattern.matches(path, is_dir=False):
+            return True
+        if self.source.endswith('/*'):
+            return False
+        parts = path.split('/')
+        return any(self._pattern.matches('/'.join(parts[:i]), is_dir=True) for i in range(1, len(parts)))
+
+
+def parse_codeowners(text: str) -> List[CodeOwnersRule]:
+    """Rules of a CODEOWNERS file in order, skipping blanks, comments and GitLab [Section] headers"""
+    rules = []
+    for line in text.splitlines():
+        line = line.split(' #', 1)[0].strip()
+        if not line or line.startswith('#') or line.startswith('['):
+            continue
+        pattern, *owners = line.split()
+        rules.append(CodeOwnersRule(pattern, owners))
+    return rules
+
+
+def file_owners(rules: List[CodeOwnersRule], path: str) -> List[str]:
+    """Owners of a '/'-separated path relative to the root: the last matching rule's ([] if none)"""
+    owners = []
+    for rule in rules:
+        if rule.matches(path):
+            owners = rule.owners
+    return owners
+
+
+class CodeOwnersProcessor(ChunkProcessor):
+    """
+    Chunk ownership from a CODEOWNERS file: metadata['owners'], the owners of the
+    last rule matching the chunk's file (as GitHub and GitLab decide it)
+    """
+    
+    def __init__(self, path: Optional[str] = None):
+        """
+        Args:
+            path: CODEOWNERS file to read (default: the first of CODEOWNERS_LOCATIONS
+                in each indexed root; a root without one leaves owners out)
+        """
+        self.path = path
+        self.rules: List[CodeOwnersRule] = []
+        self._owners: Dict[str, List[str]] = {}
+    
+    def start(self, root: Path):
+        self._owners = {}
+        candidates = [Path(self.path)] if self.path else [root / location for location in CODEOWNERS_LOCATIONS]
+        for candidate in candidates:
+            if candidate.is_file():
+                self.rules = parse_codeowners(candidate.read_text(encoding='utf-8', errors='ignore'))
+                return
+        self.rules = []
+    
+    def process(self, chunk: CodeChunk) -> Optional[CodeChunk]:
+        if chunk.filepath not in self._owners:
+            self._owners[chunk.filepath] = file_owners(self.rules, chunk.filepath)
+        owners = self._owners[chunk.filepath]
+        if owners:
+            chunk.metadata = dict(chunk.metadata or {}, owners=owners)
+        return chunk
+
+
+# Processors CONFIG.chunk_processors can name without an import path
+BUILTIN_PROCESSORS = {
+    'codeowners': CodeOwnersProcessor,
+}
@@ -0,0 +1,209 @@
+#!/usr/bin/env python3
+"""
+Normalization of code before it is embedded
+Copies of the same logic that differ only in layout get different vectors, so a
+reformatted copy ranks apart from the original and near-identical code is hard
+to match across files. Normalization rewrites only the text that is embedded
+(and hashed for dedup); the stored and displayed code stays as written:
+
+- 'whitespace' drops trailing whitespace and runs of blank lines, expands tabs
+  and re-indents with four spaces per indentation level. Go code gets a gofmt-like
+  layout instead, re-rendered from its tokens with tab indentation and one
+  spacing rule, so 'a+b' and 'a + b' embed alike
+- 'comments' does the same and drops comments too, so the vector is computed
+  from the code alone
+
+'off' embeds the code as written.
+"""
+
+import re
+from typing import Dict, List, Optional, Tuple
+
+from chunkers.go_lexer import tokenize_go
+
+
+# Normalization levels, least first
+NORMALIZATIONS = ('off', 'whitespace', 'comments')
+
+# Comment syntax per language
+LINE_COMMENTS = {
+    'python': '#', 'bash': '#', 'gn': '#', 'sql': '--', 'ruby': '#', 'elixir': '#', 'yaml': '#',
+}
+BLOCK_COMMENT_LANGUAGES = {'cpp', 'c', 'javascript', 'typescript', 'go', 'rust', 'java', 'mojom', 'sql',
+                           'csharp', 'kotlin', 'swift', 'scala', 'php', 'protobuf', 'json', 'dart'}
+
+# Spaces per indentation level of normalized code (Go is indented with tabs)
+INDENT_WIDTH = 4
+
+# Go tokens written without a space before or after them
+GO_OPENERS = ('(', '[', '{')
+GO_CLOSERS = (')', ']', '}')
+GO_NO_SPACE_BEFORE = {')', ']', '}', ',', ';', '.', ':', '++', '--', '...'}
+GO_NO_SPACE_AFTER = {'(', '[', '{', '.'}
+GO_UNARY = {'-', '+', '!', '^', '*', '&', '<-'}
+# Tokens that end an operand: an operator after one of them is binary
+GO_OPERAND_ENDS = {'ident', 'number', 'string', 'rune'}
+
+
+def strip_comments(content: str, language: str) -> str:
+    """
+    Code without its comments, line breaks kept (a block comment spanning lines leaves
+    its newlines); string literals are kept as written, so '//' or '#' inside them is
+    not a comment
+    """
+    line_comment = LINE_COMMENTS.get(language, '//' if language in BLOCK_COMMENT_LANGUAGES else None)
+    block_comments = language in BLOCK_COMMENT_LANGUAGES
+    out = []
+    i = 0
+    while i < len(content):
+        ch = content[i]
+        if ch in '"\'`':
+            end = i + 1
+            while end < len(content) and content[end] != ch and content[end] != '\n':
+                end += 2 if content[end] == '\\' else 1
+            out.append(content[i:end + 1])
+            i = end + 1
+        elif line_comment and content.startswith(line_comment, i):
+            end = content.find('\n', i)
+            i = len(content) if end < 0 else end
+        elif block_comments and content.startswith('/*', i):
+            end = content.find('*/', i + 2)
+            end = len(content) if end < 0 else end + 2
+            out.append('\n' * content.count('\n', i, end) or ' ')
+            i = end
+        else:
+            out.append(ch)
+            i += 1
+    return ''.join(out)
+
+
+def normalize_code(content: str, language: str, level: str = 'whitespace') -> str:
+    """The text embedded for a chunk's code under a normalization level"""
+    if level == 'off' or not content:
+        return content
+    if language == 'go':
+        return go_layout(content, keep_comments=level != 'comments')
+    lines = content.expandtabs(INDENT_WIDTH).split('\n')
+    if level == 'comments':
+        # Lines that held only a comment go entirely
+        stripped = strip_comments(content, language).expandtabs(INDENT_WIDTH).split('\n')
+        lines = [line for line, original in zip(stripped, lines) if line.strip() or not original.strip()]
+    return '\n'.join(reindent(lines))
+
+
+def reindent(lines: List[str]) -> List[str]:
+    """
+    Lines without trailing whitespace or runs of blank lines, re-indented with
+    INDENT_WIDTH spaces per level: each deeper indentation than the line above opens a
+    level, whatever its width, so two-space, four-space and tab-indented copies agree
+    """
+    kept = []
+    for line in (line.rstrip() for line in lines):
+        if line or (kept and kept[-1]):
+            kept.append(line)
+    while kept and not kept[-1]:
+        kept.pop()
+    
+    result = []
+    widths: List[int] = []  # indentation of each open level
+    for line in kept:
+        if not line:
+            result.append('')
+            continue
+        width = len(line) - len(line.lstrip(' '))
+        while widths and width < widths[-1]:
+            widths.pop()
+        if not widths or width > widths[-1]:
+            widths.append(width)
+        result.append(' ' * (INDENT_WIDTH * (len(widths) - 1)) + line.lstrip(' '))
+    return result
+
+
+def go_layout(content: str, keep_comments: bool = True) -> str:
+    """
+    Go code re-rendered from its tokens: the original line breaks (at most one blank
+    line in a row), one tab per level of brackets opened on earlier lines, and spaces
+    between tokens by one fixed rule. Not gofmt itself, but the same tokens laid out
+    on the same lines always give the same text
+    """
+    tokens, comments = tokenize_go(content)
+    items: List[Tuple[int, int, str, str]] = []  # (start, end, kind, text)
+    for tok in tokens:
+        if tok.kind == ';' and tok.value != ';':
+            continue  # a semicolon the tokenizer inserted at a line break
+        items.append((tok.start, tok.end, tok.kind, tok.value))
+    if keep_comments:
+        items.extend((c.start, c.end, 'comment', c.text) for c in comments)
+    items.sort()
+    
+    lines: List[str] = []
+    current: List[str] = []
+    indent: Optional[int] = None  # tabs of the line being rendered, once known
+    opened: List[int] = []  # index of the line each open bracket is on
+    previous: Optional[Tuple[str, str]] = None  # (kind, text) of the token before on the line
+    previous_end = 0
+    attach = False  # the token before was a unary operator
+    
+    for start, end, kind, text in items:
+        if previous is not None and '\n' in content[previous_end:start]:
+            lines.append(_line(current, indent, opened, len(lines)))
+            # One blank line at most, and none just inside a block
+            if re.search(r'\n[ \t]*\n', content[previous_end:start]) and previous[1] not in GO_OPENERS:
+                lines.append('')
+            current, indent, previous = [], None, None
+        
+        closer = kind == 'op' and text in GO_CLOSERS
+        if closer and not current and lines and not lines[-1]:
+            lines.pop()
+        if closer and opened:
+            opened.pop()
+        if indent is None and not closer:
+            indent = _levels(opened, len(lines))  # closing brackets leading the line count as closed
+        if previous is not None and not (attach or previous[1] in GO_NO_SPACE_AFTER
+                                         or _attached(previous, kind, text)):
+            current.append(' ')
+        attach = kind == 'op' and text in GO_UNARY and (previous is None or _ends_operator(previous))
+        current.append(text)
+        if kind == 'op' and text in GO_OPENERS:
+            opened.append(len(lines))
+        # [] of a slice type: what follows is the element type ([]*T, []int)
+        empty = text == ']' and previous is not None and previous[1] == '['
+        previous, previous_end = ('type' if empty else kind, text), end
+    
+    if current:
+        lines.append(_line(current, indent, opened, len(lines)))
+    return '\n'.join(lines).strip('\n')
+
+
+def _attached(previous: Tuple[str, str], kind: str, text: str) -> bool:
+    """True if a Go token is written right after the one before it"""
+    if kind in ('op', ';') and text in GO_NO_SPACE_BEFORE:
+        return True
+    if kind == 'op' and text in ('(', '['):
+        # Calls and indexes (f(x), a[i][j], func() {...}()); a result list keeps its space
+        return previous[0] == 'ident' or previous[1] in (']', '}')
+    # Types after brackets: []*T, map[K]V, [N]T
+    return previous[1] == ']' and (kind in ('ident', 'keyword') or previous[0] == 'type' and text == '*')
+
+
+def _ends_operator(previous: Tuple[str, str]) -> bool:
+    """True if a Go token leaves an operand to come (an operator, opening bracket, keyword or [])"""
+    kind, text = previous
+    return kind == 'type' or not (kind in GO_OPERAND_ENDS or text in GO_CLOSERS)
+
+
+def _levels(opened: List[int], line: int) -> int:
+    """Indentation of a line: one level per earlier line with a bracket still open"""
+    return len({index for index in opened if index < line})
+
+
+def _line(parts: List[str], indent: Optional[int], opened: List[int], line: int) -> str:
+    text = ''.join(parts).rstrip()
+    if not text:
+        return ''
+    return '\t' * (_levels(opened, line) if indent is None else indent) + text
+
+
+def normalization_for(language: str, level: str, overrides: Optional[Dict[str, str]] = None) -> str:
+    """The level applied to a language: its override, else the general one"""
+    return (overrides or {}).get(language, level)
@@ -0,0 +1,153 @@
+#!/usr/bin/env python3
+"""
+Identifier-aware tokenization for the keyword (BM25) index
+Identifiers are kept whole and also split on camelCase/snake_case boundaries,
+so a query for "create session" matches CreateSession and create_session.
+Which boundaries are split, whether kebab-case words are one identifier, the
+minimum word length and the words left out are TokenizerRules, with defaults
+per language (see tokenizer_rules)
+"""
+
+import re
+from dataclasses import dataclass, fields, replace
+from typing import Dict, FrozenSet, List, Optional
+
+
+# Words in source text: identifiers (including $-prefixed ones) and numbers
+WORD_PATTERN = re.compile(r'[A-Za-z_$][A-Za-z0-9_$]*|\d+')
+
+# The same, with kebab-case words (admin-user, max-age) as one identifier
+KEBAB_WORD_PATTERN = re.compile(r'[A-Za-z_$][A-Za-z0-9_$]*(?:-[A-Za-z_$][A-Za-z0-9_$]*)*|\d+')
+
+# Parts of one identifier: HTTPServer -> HTTP, Server; parseURL2 -> parse, URL, 2
+PART_PATTERN = re.compile(r'[A-Z]+(?=[A-Z][a-z])|[A-Z]?[a-z]+|[A-Z]+|\d+')
+
+# The same, digits staying with the word before them: parseURL2 -> parse, URL2
+DIGIT_PART_PATTERN = re.compile(r'[A-Z]+(?=[A-Z][a-z])|[A-Z]?[a-z]+\d*|[A-Z]+\d*|\d+')
+
+# Words 'english' stands for in a stopword list
+ENGLISH_STOPWORDS = frozenset({
+    'a', 'an', 'and', 'are', 'as', 'at', 'be', 'by', 'for', 'from', 'in', 'is', 'it', 'of', 'on', 'or',
+    'that', 'the', 'this', 'to', 'was', 'were', 'will', 'with',
+})
+
+
+@dataclass(frozen=True)
+class TokenizerRules:
+    """How identifiers are split into the words of the keyword index"""
+    # camelCase and PascalCase boundaries (CreateSession -> create, session)
+    split_case: bool = True
+    # Underscores and $ (create_session, MAX_RETRIES -> max, retries; else one word each)
+    split_underscores: bool = True
+    # Letters and digits (parseURL2 -> parse, url, 2; else parse, url2)
+    split_digits: bool = True
+    # kebab-case words are one identifier, kept whole and split on hyphens (admin-user)
+    kebab_case: bool = False
+    # The whole identifier is a word too, besides its parts
+    keep_whole: bool = True
+    # Words shorter than this are left out
+    min_length: int = 1
+    # Words left out, lowercase
+    stopwords: FrozenSet[str] = frozenset()
+
+
+# Rule settings, with the type each takes
+RULE_TYPES = {field.name: (list if field.name == 'stopwords' else field.type) for field in fields(TokenizerRules)}
+
+# Languages whose identifiers are kebab-case as often as not (keys, properties, commands)
+LANGUAGE_RULES: Dict[str, Dict] = {
+    language: {'kebab_case': True}
+    for language in ('css', 'html', 'yaml', 'json', 'toml', 'markdown', 'text', 'bash')
+}
+
+
+def tokenizer_rules(language: Optional[str] = None) -> TokenizerRules:
+    """
+    Rules for a language's text: the language's defaults (LANGUAGE_RULES) overridden by
+    CONFIG.tokenizer_rules, then by CONFIG.language_tokenizer_rules[language]; without a
+    language, the rules queries are tokenized with (kebab-case words also kept whole, so
+    they match the languages that keep them)
+    """
+    from config import CONFIG
+    settings = dict(LANGUAGE_RULES.get(language, {})) if language else {}
+    settings.update(CONFIG.tokenizer_rules)
+    if language:
+        settings.update(CONFIG.language_tokenizer_rules.get(language) or {})
+    else:
+        settings['kebab_case'] = True
+    return make_rules(settings)
+
+
+def make_rules(settings: Dict) -> TokenizerRules:
+    """
+    TokenizerRules from settings (see RULE_TYPES); 'english' in stopwords stands for
+    ENGLISH_STOPWORDS
+    
+    Raises:
+        ValueError: If a setting is unknown or has a bad value (see rule_problems)
+    """
+    problems = rule_problems(settings)
+    if problems:
+        raise ValueError('; '.join(problems))
+    settings = dict(settings)
+    if 'stopwords' in settings:
+        words = {word.lower() for word in settings['stopwords']}
+        if 'english' in words:
+            words = (words - {'english'}) | ENGLISH_STOPWORDS
+        settings['stopwords'] = frozenset(words)
+    return replace(TokenizerRules(), **settings)
+
+
+def rule_problems(settings: Dict) -> List[str]:
+    """What is wrong with tokenizer rule settings"""
+    if not isinstance(settings, dict):
+        return [f"expected a table of {', '.join(RULE_TYPES)}"]
+    problems = []
+    for key, value in settings.items():
+        expected = RULE_TYPES.get(key)
+        if expected is None:
+            problems.append(f"unknown rule {key} (expected one of: {', '.join(RULE_TYPES)})")
+        elif not isinstance(value, expected) or (expected is int and isinstance(value, bool)):
+            problems.append(f"{key}: expected {expected.__name__}, got {type(value).__name__}")
+        elif key == 'min_length' and value < 1:
+            problems.append(f"min_length: must be at least 1, got {value}")
+        elif key == 'stopwords' and not all(isinstance(word, str) for word in value):
+            problems.append("stopwords: expected a list of words")
+    return problems
+
+
+def split_identifier(identifier: str, rules: Optional[TokenizerRules] = None) -> List[str]:
+    """Split an identifier into lowercase words on case, underscore (and hyphen) boundaries"""
+    rules = rules or TokenizerRules()
+    separators = ('_$' if rules.split_underscores else '') + '-'
+    pieces = re.split(f'[{re.escape(separators)}]+', identifier)
+    parts = []
+    for piece in pieces:
+        if '_' in piece.strip('_'):
+            words = [piece]  # snake_case kept whole
+        elif rules.split_case:
+            words = (PART_PATTERN if rules.split_digits else DIGIT_PART_PATTERN).findall(piece)
+        elif rules.split_digits:
+            words = re.findall(r'\D+|\d+', piece)
+        else:
+            words = [piece]
+        parts.extend(word.lower() for word in words if word.strip('_$'))
+    return parts
+
+
+def tokenize_code(text: str, rules: Optional[TokenizerRules] = None) -> List[str]:
+    """
+    Tokenize code or a query for keyword matching
+    
+    Each identifier yields its lowercased whole form followed by its parts
+    (when it has more than one), e.g. "kMaxRetries" -> kmaxretries, k, max, retries;
+    words shorter than the rules' minimum length or among their stopwords are left out
+    """
+    rules = rules or TokenizerRules()
+    tokens = []
+    for word in (KEBAB_WORD_PATTERN if rules.kebab_case else WORD_PATTERN).findall(text):
+        parts = split_identifier(word, rules)
+        words = ([word.lower()] if rules.keep_whole or len(parts) < 2 else []) + (parts if len(parts) > 1 else [])
+        tokens.extend(token for token in words
+                      if len(token) >= rules.min_length and token not in rules.stopwords)
+    return tokens
@@ -0,0 +1,324 @@
+#!/usr/bin/env python3
+"""
+Settings from a config file and the environment
+A TOML or YAML file sets CONFIG attributes, grouped in sections ([store],
+[embedder], [chunking], [languages.<name>], [embedding_models.<name>],
+[query_embedders.<name>], [saved_searches.<name>]) or by their own name
+([settings]); CODE_RAG_<ATTRIBUTE> environment variables set them too.
+Command-line flags override the file, and the environment overrides both
+(see cli.py).
+
+    roots = ["src", "proto=../proto"]
+    ignore = ["*.pb.go", "third_party/"]
+    
+    [store]
+    backend = "sqlite"
+    sqlite_path = "./index.db"
+    
+    [embedder]
+    backend = "ollama"
+    model = "nomic-embed-text"
+    
+    [languages.markdown]
+    max_tokens = 1024
+    
+    [embedding_models.code]
+    backend = "ollama"
+    model = "jina-embeddings-v2-base-code"
+"""
+
+import json
+import os
+from pathlib import Path
+from typing import Dict, List, Mapping, Optional, Tuple
+
+
+# Files looked for in the working directory when no config file is named
+CONFIG_FILE_NAMES = ('code-rag.toml', 'code-rag.yaml', 'code-rag.yml')
+
+# Environment variable naming the config file, and prefix of the variables setting attributes
+CONFIG_ENV = 'CODE_RAG_CONFIG'
+ENV_PREFIX = 'CODE_RAG_'
+
+# Section keys, by section, and the CONFIG attribute each one sets
+SECTIONS = {
+    'store': {
+        'backend': 'vector_store',
+        'db_path': 'db_path',
+        'collection': 'collection_name',
+        'sqlite_path': 'sqlite_path',
+        'qdrant_url': 'qdrant_url',
+        'qdrant_api_key': 'qdrant_api_key',
+        'pgvector_dsn': 'pgvector_dsn',
+        'metric': 'distance_metric',
+        'quantization': 'vector_quantization',
+        'batch_size': 'batch_size',
+    },
+    'embedder': {
+        'backend': 'embedding_backend',
+        'model': 'ollama_model',
+        'url': 'ollama_base_url',
+        'timeout': 'ollama_timeout',
+        'batch_size': 'embedding_batch_size',
+        'requests_per_minute': 'embedding_requests_per_minute',
+        'max_concurrency': 'embedding_max_concurrency',
+        'workers': 'embedding_workers',
+        'cache_path': 'embedding_cache_path',
+        'mode': 'embedding_mode',
+        'normalize': 'normalize_embeddings',
+        'reduce_dimensions': 'reduce_dimensions',
+    },
+    'chunking': {
+        'max_tokens': 'max_tokens',
+        'token_overlap': 'token_overlap',
+        'overlap_strategy': 'overlap_strategy',
+        'granularity': 'granularity',
+        'min_chunk_lines': 'min_chunk_lines',
+        'min_chunk_tokens': 'min_chunk_tokens',
+        'exclude_symbols': 'exclude_symbols',
+        'code_normalization': 'code_normalization',
+        'dedup': 'dedup',
+        'max_file_bytes': 'max_file_bytes',
+    },
+}
+
+# Top-level keys and the CONFIG attribute each one sets
+TOP_LEVEL = {'roots': 'index_roots', 'ignore': 'ignore_patterns'}
+
+# Keys of a [languages.<name>] section: chunking overrides, the code normalization and
+# the keyword tokenizer rules
+LANGUAGE_KEYS = ('max_tokens', 'token_overlap', 'overlap_strategy', 'granularity', 'min_chunk_lines',
+                 'min_chunk_tokens', 'code_normalization', 'tokenizer_rules')
+
+
+class ConfigFileError(Exception):
+    """Raised when a config file cannot be read"""
+
+
+def find_config_file(path: Optional[str] = None, environ: Optional[Mapping[str, str]] = None) -> Optional[Path]:
+    """
+    The config file to load: the one named (--config), else CODE_RAG_CONFIG,
+    else the first of CONFIG_FILE_NAMES in the working directory, else None
+    
+    Raises:
+        ConfigFileError: If a named file does not exist
+    """
+    environ = os.environ if environ is None else environ
+    named = path or environ.get(CONFIG_ENV)
+    if named:
+        if not Path(named).is_file():
+            raise ConfigFileError(f"Config file not found: {named}")
+        return Path(named)
+    return next((Path(name) for name in CONFIG_FILE_NAMES if Path(name).is_file()), None)
+
+
+def read_config_file(path: Path) -> Dict:
+    """
+    Parse a TOML (.toml) or YAML (.yaml, .yml) config file
+    
+    Raises:
+        ConfigFileError: If the file is malformed or its parser is not installed
+    """
+    text = Path(path).read_text(encoding='utf-8')
+    if Path(path).suffix in ('.yaml', '.yml'):
+        try:
+            import yaml
+        except ImportError:
+            raise ConfigFileError("YAML config files need the PyYAML package: pip install pyyaml")
+        try:
+            data = yaml.safe_load(text)
+        except yaml.YAMLError as e:
+            raise ConfigFileError(f"{path}: {e}")
+    else:
+        try:
+            import tomllib
+        except ImportError:  # Python < 3.11
+            try:
+                import tomli as tomllib
+            except ImportError:
+                raise ConfigFileError("TOML config files need Python 3.11 or the tomli package: pip install tomli")
+        try:
+            data = tomllib.loads(text)
+        except tomllib.TOMLDecodeError as e:
+            raise ConfigFileError(f"{path}: {e}")
+    if data is None:
+        return {}
+    if not isinstance(data, dict):
+        raise ConfigFileError(f"{path}: expected a table of settings, got {type(data).__name__}")
+    return data
+
+
+def file_settings(data: Dict, config) -> Tuple[Dict[str, object], List[str]]:
+    """
+    CONFIG attributes a parsed config file sets, and what is wrong with it
+    (unknown sections and keys, values of the wrong type)
+    """
+    settings: Dict[str, object] = {}
+    problems: List[str] = []
+    
+    def put(key: str, attribute: str, value):
+        problem = _type_problem(config, attribute, value)
+        if problem:
+            problems.append(f"{key}: {problem}")
+        else:
+            settings[attribute] = value
+    
+    for key, value in data.items():
+        if key in TOP_LEVEL:
+            put(key, TOP_LEVEL[key], [value] if isinstance(value, str) else value)
+        elif key in SECTIONS or key in ('languages', 'settings', 'embedding_models', 'query_embedders',
+                                        'saved_searches'):
+            if not isinstance(value, dict):
+                problems.append(f"{key}: expected a section, got {type(value).__name__}")
+            elif key in ('embedding_models', 'query_embedders', 'saved_searches'):
+                put(key, key, value)  # each entry is checked with the other settings
+            elif key == 'languages':
+                _language_settings(value, config, settings, problems)
+            elif key == 'settings':
+                for name, item in value.items():
+                    if _is_setting(config, name):
+                        put(f"settings.{name}", name, item)
+                    else:
+                        problems.append(f"settings.{name}: unknown setting")
+            else:
+                for name, item in value.items():
+                    if name in SECTIONS[key]:
+                        put(f"{key}.{name}", SECTIONS[key][name], item)
+                    else:
+                        problems.append(f"{key}.{name}: unknown key (expected one of: {', '.join(SECTIONS[key])})")
+        else:
+            problems.append(f"{key}: unknown key or section")
+    return settings, problems
+
+
+def env_settings(config, environ: Optional[Mapping[str, str]] = None) -> Tuple[Dict[str, object], List[str]]:
+    """
+    CONFIG attributes CODE_RAG_<ATTRIBUTE> variables set (CODE_RAG_VECTOR_STORE=sqlite),
+    and the variables whose value does not fit the attribute; values are read
+    by the type of the attribute's default: numbers, true/false, JSON or
+    comma-separated lists, JSON objects
+    """
+    environ = os.environ if environ is None else environ
+    settings: Dict[str, object] = {}
+    problems: List[str] = []
+    for variable, text in environ.items():
+        if not variable.startswith(ENV_PREFIX) or variable == CONFIG_ENV:
+            continue
+        attribute = variable[len(ENV_PREFIX):].lower()
+        if not _is_setting(config, attribute):
+            continue  # not ours (CODE_RAG_HOME of a wrapper script, ...)
+        try:
+            value = _parse_env_value(text, getattr(config, attribute))
+        except ValueError as e:
+            problems.append(f"{variable}: {e}")
+            continue
+        problem = _type_problem(config, attribute, value)
+        if problem:
+            problems.append(f"{variable}: {problem}")
+        else:
+            settings[attribute] = value
+    return settings, problems
+
+
+def apply_settings(config, settings: Dict[str, object]):
+    """Set CONFIG attributes; per-language overrides merge into the ones already set"""
+    for attribute, value in settings.items():
+        current = getattr(config, attribute)
+        if attribute in ('language_chunking', 'language_code_normalization', 'language_tokenizer_rules') \
+                and isinstance(current, dict):
+            merged = {language: dict(options) if isinstance(options, dict) else options
+                      for language, options in current.items()}
+            for language, options in value.items():
+                if isinstance(options, dict) and isinstance(merged.get(language), dict):
+                    merged[language].update(options)
+                else:
+                    merged[language] = options
+            value = merged
+        setattr(config, attribute, value)
+
+
+def _language_settings(section: Dict, config, settings: Dict[str, object], problems: List[str]):
+    """[languages.<name>] sections: chunking overrides, code normalization and tokenizer rules per language"""
+    chunking: Dict[str, Dict] = {}
+    normalization: Dict[str, str] = {}
+    tokenizer: Dict[str, Dict] = {}
+    for language, options in section.items():
+        if not isinstance(options, dict):
+            problems.append(f"languages.{language}: expected a section, got {type(options).__name__}")
+            continue
+        for key, value in options.items():
+            if key not in LANGUAGE_KEYS:
+                problems.append(f"languages.{language}.{key}: unknown key (expected one of: {', '.join(LANGUAGE_KEYS)})")
+                continue
+            problem = _type_problem(config, key, value)
+            if problem:
+                problems.append(f"languages.{language}.{key}: {problem}")
+            elif key == 'code_normalization':
+                normalization[language] = value
+            elif key == 'tokenizer_rules':
+                tokenizer[language] = value
+            else:
+                chunking.setdefault(language, {})[key] = value
+    if chunking:
+        settings['language_chunking'] = chunking
+    if normalization:
+        settings['language_code_normalization'] = normalization
+    if tokenizer:
+        settings['language_tokenizer_rules'] = tokenizer
+
+
+def _is_setting(config, name: str) -> bool:
+    """True if a name is a plain CONFIG setting (not a method or the file type tables)"""
+    return not name.startswith('_') and name not in ('file_types', 'QUERIES') and hasattr(config, name) \
+        and not callable(getattr(config, name))
+
+
+def _type_problem(config, attribute: str, value) -> Optional[str]:
+    """Why a value cannot be assigned to a CONFIG attribute, judged by its default; None if it can"""
+    default = getattr(config, attribute)
+    if default is None or value is None:
+        return None
+    if isinstance(default, bool):
+        fits = isinstance(value, bool)
+    elif isinstance(default, float):
+        fits = isinstance(value, (int, float)) and not isinstance(value, bool)
+    elif isinstance(default, int):
+        fits = isinstance(value, int) and not isinstance(value, bool)
+    else:
+        fits = isinstance(value, type(default))
+    return None if fits else f"expected {_type_name(type(default))}, got {value!r}"
+
+
+def _type_name(kind: type) -> str:
+    return {bool: 'true/false', int: 'an integer', float: 'a number', str: 'a string',
+            list: 'a list', dict: 'a table'}.get(kind, kind.__name__)
+
+
+def _parse_env_value(text: str, default):
+    """An environment variable's value, read as the type of the attribute's default"""
+    if isinstance(default, bool):
+        if text.lower() in ('1', 'true', 'yes', 'on'):
+            return True
+        if text.lower() in ('0', 'false', 'no', 'off'):
+            return False
+        raise ValueError(f"expected true or false, got {text!r}")
+    if isinstance(default, int):
+        try:
+            return int(text)
+        except ValueError:
+            raise ValueError(f"expected an integer, got {text!r}")
+    if isinstance(default, float):
+        try:
+            return float(text)
+        except ValueError:
+            raise ValueError(f"expected a number, got {text!r}")
+    if isinstance(default, str):
+        return text
+    if isinstance(default, list) and not text.lstrip().startswith('['):
+        return [item.strip() for item in text.split(',') if item.strip()]
+    try:
+        return json.loads(text)
+    except ValueError:
+        if default is None:
+            return text  # a string setting without a default (qdrant_api_key)
+        raise ValueError(f"expected JSON, got {text!r}")
@@ -0,0 +1,89 @@
+#!/usr/bin/env python3
+"""
+Context-window packing of retrieved chunks for LLM prompts
+Selects the best-scoring chunks that fit a token budget and formats them
+under file path / symbol headers; a chunk that doesn't fit is skipped whole
+"""
+
+from typing import Dict, List, Optional, Tuple
+
+from chunkers.base_chunker import qualified_name
+from chunkers.token_splitter import get_token_counter
+from config import CONFIG
+
+
+def pack_context(results: List[Dict], max_tokens: int, merge_files: bool = False,
+                 encoding: Optional[str] = None) -> Tuple[str, List[Dict]]:
+    """
+    Pack search results into a single prompt string within a token budget
+    
+    Chunks are taken greedily by score (mmr_score, then rrf_score; unscored results
+    keep their order). A chunk that would overflow the budget is skipped, never cut
+    mid-symbol, and smaller chunks after it may still fit.
+    
+    Args:
+        results: Results from ChromeRAGSystem.retrieve_context (content + metadata)
+        max_tokens: Token budget for the packed string
+        merge_files: Combine chunks from the same file into one block, in line order
+        encoding: Tokenizer encoding used to count tokens (default: CONFIG.tokenizer_encoding)
+    
+    Returns:
+        (packed string, included results in the order they appear in it)
+    """
+    counter = get_token_counter(encoding or CONFIG.tokenizer_encoding)
+    ranked = sorted(results, key=lambda r: -_score(r))
+    
+    selected = []
+    for result in ranked:
+        trial = selected + [result]
+        if counter.count(_render(trial, merge_files)[0]) <= max_tokens:
+            selected = trial
+    
+    return _render(selected, merge_files)
+
+
+def _score(result: Dict) -> float:
+    score = result.get('mmr_score')
+    if score is None:
+        score = result.get('rrf_score')
+    return score or 0.0
+
+
+def _render(results: List[Dict], merge_files: bool) -> Tuple[str, List[Dict]]:
+    """Format results as prompt text; returns the text and the results in text order"""
+    if not merge_files:
+        blocks = [_file_header(r) + _symbol_block(r) for r in results]
+        return "\n\n".join(blocks), list(results)
+    
+    # One block per file, files ordered by their best chunk, symbols by line
+    by_file: Dict[str, List[Dict]] = {}
+    for result in results:
+        by_file.setdefault(result['metadata'].get('filepath', 'unknown'), []).append(result)
+    
+    blocks, ordered = [], []
+    for filepath, chunks in by_file.items():
+        chunks = sorted(chunks, key=lambda r: r['metadata'].get('line_start', 0))
+        blocks.append(f"### {filepath}\n" + "\n".join(_symbol_block(r) for r in chunks))
+        ordered.extend(chunks)
+    return "\n\n".join(blocks), ordered
+
+
+def _file_header(result: Dict) -> str:
+    return f"### {result['metadata'].get('filepath', 'unknown')}\n"
+
+
+def _symbol_block(result: Dict) -> str:
+    metadata = result['metadata']
+    name = qualified_name(metadata) or 'unknown'
+    lines = f"{metadata.get('line_start', '?')}-{metadata.get('line_end', '?')}"
+    return (
+        f"// {name} ({metadata.get('type', 'unknown')}, lines {lines})\n"
+        f"```{metadata.get('language', '')}\n{_surrounding(result)}{result['content'].rstrip()}\n```"
+    )
+
+
+def _surrounding(result: Dict) -> str:
+    """Imports and enclosing type header of a result fetched with its surrounding context"""
+    surrounding = result.get('surrounding') or {}
+    parts = [surrounding[key] for key in ('imports', 'enclosing') if surrounding.get(key)]
+    return "".join(f"{part}\n\n" for part in parts)
@@ -0,0 +1,61 @@
+#!/usr/bin/env python3
+"""
+Moving an index to another embedding model
+Every stored chunk is embedded again from its stored code and metadata (the
+source is not re-parsed) into a staging collection next to the live one. The
+live collection keeps answering searches with its old vectors until the last
+chunk is embedded; the staging collection then replaces it in one step, so a
+search sees either the old model's vectors or the new model's, never a mix.
+"""
+
+from dataclasses import asdict, dataclass
+from typing import Callable, Dict
+
+from chunkers.base_chunker import CodeChunk, parse_metadata
+
+# Appended to a collection's name for the collection its new vectors are staged in
+MIGRATION_SUFFIX = '_migrating'
+
+# Stages of a migration, in order; it ends 'done' or 'cancelled'
+MIGRATION_STAGES = ('embedding', 'swapping', 'done', 'cancelled')
+
+
+@dataclass
+class MigrationProgress:
+    """Where a migration is (chunks count those of every collection of the index)"""
+    stage: str = 'embedding'
+    chunks_total: int = 0
+    chunks_embedded: int = 0
+    
+    def to_dict(self) -> Dict:
+        return asdict(self)
+
+
+# Receives the migration's MigrationProgress (updated in place) after every batch
+MigrationCallback = Callable[[MigrationProgress], None]
+
+
+def stored_chunk(document: str, metadata: Dict) -> CodeChunk:
+    """A chunk rebuilt from its stored document and metadata, for the text it is embedded from"""
+    return CodeChunk(
+        type=metadata.get('type', ''),
+        name=metadata.get('name', ''),
+        content=document or '',
+        filepath=metadata.get('filepath', ''),
+        language=metadata.get('language', ''),
+        line_start=int(metadata.get('line_start', 0)),
+        line_end=int(metadata.get('line_end', 0)),
+        signature=metadata.get('signature') or None,
+        namespace=metadata.get('namespace') or None,
+        parent_class=metadata.get('parent_class') or None,
+        parent=metadata.get('parent') or None,
+        doc=metadata.get('doc') or None,
+        metadata=parse_metadata(metadata.get('metadata')),
+        symbol_id=metadata.get('symbol_id') or None,
+        part_index=int(metadata.get('part_index', 0)),
+        part_count=int(metadata.get('part_count', 1)),
+        kind=metadata.get('kind', 'source'),
+        repo=metadata.get('repo') or None,
+        modified=metadata.get('modified'),
+        generated=bool(metadata.get('generated'))
+    )
@@ -0,0 +1,72 @@
+#!/usr/bin/env python3
+"""
+Pipelined embedding of indexing batches
+Parsing and embedding otherwise take turns: every batch waits for the
+embedder before the next file is parsed. The pipeline embeds batches on
+worker threads while the caller goes on parsing, and stores them on the
+caller's thread in the order they were submitted, whatever order their
+embeddings finish in, so the index and its bookkeeping are the same as a run
+that embeds each batch in line. How many requests reach the backend at once
+is still up to its rate limiter (see RateLimitedEmbedder); a worker past that
+limit only waits for a free slot.
+"""
+
+from collections import deque
+from concurrent.futures import Future, ThreadPoolExecutor
+from typing import Any, Callable, Deque, Tuple
+
+
+class EmbeddingPipeline:
+    """Embeds batches on worker threads and hands them to store() in submission order"""
+    
+    def __init__(self, embed: Callable[[Any], Any], store: Callable[[Any, Any], None], workers: int,
+                 max_pending: int = 0):
+        """
+        Args:
+            embed: Called on a worker thread with a batch; returns what store() needs
+                besides the batch (its vectors)
+            store: Called on the submitting thread with each batch and its embed() result
+            workers: Batches embedded at the same time (at least 1)
+            max_pending: Batches held in the pipeline before submit() waits for the
+                oldest to be stored (default: twice the workers), which bounds memory
+        """
+        self.workers = max(1, workers)
+        self.max_pending = max_pending or 2 * self.workers
+        self._embed = embed
+        self._store = store
+        self._pool = ThreadPoolExecutor(max_workers=self.workers, thread_name_prefix='embed')
+        self._pending: Deque[Tuple[Any, Future]] = deque()
+    
+    def submit(self, batch):
+        """
+        Start embedding a batch, and store the batches ahead of it that are done
+        
+        Raises:
+            Whatever embed() or store() raised for an earlier batch
+        """
+        self._pending.append((batch, self._pool.submit(self._embed, batch)))
+        self._drain(self.max_pending)
+    
+    def flush(self):
+        """Wait for every submitted batch and store it"""
+        self._drain(0)
+    
+    def cancel(self):
+        """Drop the batches not stored yet; embeddings already running are waited for"""
+        for _, future in self._pending:
+            future.cancel()
+        self._pending.clear()
+        self._pool.shutdown(wait=True)
+    
+    def close(self):
+        """Stop the workers (after flush(), or cancel() to drop what is left)"""
+        self._pool.shutdown(wait=True)
+    
+    def __len__(self) -> int:
+        return len(self._pending)
+    
+    def _drain(self, keep: int):
+        """Store finished batches from the oldest on, waiting on the oldest while more than keep are pending"""
+        while self._pending and (len(self._pending) > keep or self._pending[0][1].done()):
+            batch, future = self._pending.popleft()
+            self._store(batch, future.result())
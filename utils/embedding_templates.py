@@ -0,0 +1,79 @@
+#!/usr/bin/env python3
+"""
+Templates of the text sent to the embedder
+Models trained with task prefixes embed best with them: nomic-embed-text wants
+'search_document: ' before what is indexed and 'search_query: ' before what is
+searched for. A document template formats a chunk's embedding text from its
+fields, a query template the text of a search; the defaults send the text
+unchanged. Templates are str.format strings ('{{' for a brace).
+"""
+
+from string import Formatter
+from typing import Dict, List
+
+from chunkers.base_chunker import CodeChunk
+
+DEFAULT_DOCUMENT_TEMPLATE = '{body}'
+DEFAULT_QUERY_TEMPLATE = '{query}'
+
+# Placeholders of each kind of template, and what they stand for
+DOCUMENT_FIELDS = {
+    'language': 'language the chunk is indexed as',
+    'kind': 'chunk type (function, method, struct, ...)',
+    'symbol': 'qualified name (AdminUser.Authenticate)',
+    'doc': 'doc comment',
+    'signature': 'signature',
+    'body': 'the text the embedding mode embeds: the code, or the doc comment and signature',
+}
+QUERY_FIELDS = {
+    'query': 'the search text',
+}
+
+
+def template_problems(template: str, fields: Dict[str, str]) -> List[str]:
+    """What is wrong with a template: a syntax error or placeholders it has no value for"""
+    if not isinstance(template, str):
+        return [f"expected a string, got {template!r}"]
+    try:
+        parsed = list(Formatter().parse(template))
+    except ValueError as e:
+        return [str(e)]
+    problems = []
+    for _, name, spec, conversion in parsed:
+        if name is None:
+            continue
+        if name not in fields:
+            problems.append(f"unknown placeholder {{{name}}} (expected: "
+                            f"{', '.join('{' + field + '}' for field in fields)})")
+        elif spec or conversion:
+            problems.append(f"{{{name}}} takes no format spec or conversion")
+    return problems
+
+
+def check_template(template: str, fields: Dict[str, str], setting: str) -> str:
+    """The template, or ValueError naming the setting if it has a problem"""
+    problems = template_problems(template, fields)
+    if problems:
+        raise ValueError(f"{setting}: {'; '.join(problems)}")
+    return template
+
+
+def render_document(template: str, chunk: CodeChunk, body: str) -> str:
+    """A chunk's text as sent to the embedder; body is what the embedding mode embeds"""
+    if template == DEFAULT_DOCUMENT_TEMPLATE:
+        return body
+    return template.format_map({
+        'language': chunk.language or '',
+        'kind': chunk.type or '',
+        'symbol': chunk.qualified_name or '',
+        'doc': chunk.doc or '',
+        'signature': chunk.signature or '',
+        'body': body,
+    })
+
+
+def render_query(template: str, query: str) -> str:
+    """A search's text as sent to the embedder"""
+    if template == DEFAULT_QUERY_TEMPLATE:
+        return query
+    return template.format_map({'query': query})
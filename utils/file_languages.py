@@ -0,0 +1,262 @@
+#!/usr/bin/env python3
+"""
+Which language a file is indexed as
+CONFIG.file_types maps extensions (and a few file names: Makefile, BUILD) to
+languages. On top of it, path patterns and extra extensions or file names can
+route files to another language (.gohtml as html, vendored .inc as cpp), a
+#! line names the language of an extensionless script, and files nothing
+maps can be indexed as plain text instead of being skipped. With content
+detection on, the text of files nothing maps decides: a #! line, an editor
+modeline or the constructs of a language, with a confidence below which the
+file is indexed as plain text.
+"""
+
+import os
+import re
+from dataclasses import dataclass
+from fnmatch import fnmatch
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+from config import CONFIG
+
+
+# Language of files indexed as plain text (see chunkers/text_chunker.py)
+TEXT_LANGUAGE = 'text'
+
+# Only the first line is read for a #! line
+SHEBANG_BYTES = 256
+
+# Content detection reads the head of the file
+DETECTION_BYTES = 16 * 1024
+
+# Editor modelines in the first or last lines of that head: Emacs -*- mode: python -*-
+# (or -*- python -*-) and vim: set ft=python / vi: filetype=sh
+EMACS_MODELINE = re.compile(r'-\*-\s*(?:.*?\bmode:\s*)?([\w+#-]+)\s*(?:;.*?)?-\*-', re.IGNORECASE)
+VIM_MODELINE = re.compile(r'\b(?:vim?|ex):.*?\b(?:ft|filetype|syntax)=([\w+#-]+)')
+MODELINE_LINES = 5
+
+# Modeline names that are not the language's name in CONFIG.file_types
+MODELINE_NAMES = {
+    'sh': 'bash', 'shell-script': 'bash', 'zsh': 'bash', 'ksh': 'bash', 'py': 'python', 'python3': 'python',
+    'js': 'javascript', 'node': 'javascript', 'ts': 'typescript', 'rb': 'ruby', 'c++': 'cpp', 'objc': 'cpp',
+    'makefile': 'make', 'cs': 'csharp', 'c#': 'csharp', 'rs': 'rust', 'golang': 'go', 'pl': 'perl',
+    'cperl': 'perl', 'md': 'markdown', 'yml': 'yaml', 'tex': 'latex', 'elisp': 'commonlisp', 'lisp': 'commonlisp',
+}
+
+# Constructs that give a language away, each counting once however often it occurs
+CONTENT_HINTS: Dict[str, Tuple[str, ...]] = {
+    'python': (r'^\s*def \w+\(.*\)\s*(->.*)?:\s*$', r'^\s*(from [\w.]+ )?import [\w., ]+$',
+               r'^\s*class \w+(\(.*\))?:\s*$', r'^if __name__ == [\'"]__main__[\'"]:', r'\bself\.\w+',
+               r'^\s*(elif|except|with) .*:\s*$'),
+    'go': (r'^package \w+\s*$', r'^func (\(\w+ \*?\w+\) )?\w+\(', r'^import (\(|")', r'\w+ := ',
+           r'\bfmt\.\w+\(', r'\bif err != nil \{'),
+    'bash': (r'^\s*(if|while|until) \[\[? ', r'^\s*fi\s*$', r'^\s*(done|esac)\s*$', r'^\s*(function )?\w+\(\)\s*\{',
+             r'^\s*(export|local|readonly) \w+=', r'^\s*set -[euxo]', r'"\$\{?\w+\}?"'),
+    'ruby': (r'^\s*def \w+[?!]?(\(.*\))?\s*$', r'^\s*require(_relative)? [\'"]', r'\.each do \|',
+             r'^\s*(module|class) [A-Z][\w:]*(\s*<\s*[\w:]+)?\s*$', r'^\s*attr_(reader|writer|accessor) :',
+             r'^\s*puts '),
+    'javascript': (r'^\s*(const|let|var) \w+ = ', r'\bfunction\s*\w*\s*\(', r'\) => \{', r'\brequire\([\'"]',
+                   r'\bmodule\.exports\b', r'\bconsole\.log\(', r'^import .* from [\'"]'),
+    'perl': (r'^use (strict|warnings);', r'^\s*my [$@%]\w+', r'^sub \w+\s*\{', r'\$_\b', r'=~ [ms]?/'),
+    'php': (r'^<\?php', r'\$this->', r'^\s*(public|private|protected) (static )?function ',
+            r'^namespace [\w\\]+;', r'^\s*echo \$'),
+    'c': (r'^#include [<"]', r'^#define \w+', r'^(static )?(int|void|char|unsigned|size_t) \*?\w+\(',
+          r'\b(printf|malloc|free|memcpy)\('),
+    'cpp': (r'\bstd::\w+', r'^\s*template\s*<', r'^namespace \w+( \{)?$', r'^\s*class \w+( : public \w+)? ?\{?$',
+            r'\b(public|private|protected):$'),
+    'java': (r'^package [\w.]+;', r'^import [\w.]+(\.\*)?;', r'\bSystem\.out\.print', r'^\s*@Override\s*$',
+             r'^\s*public (final |abstract )?(class|interface|enum) \w+'),
+    'lua': (r'^\s*local function \w+', r'^\s*local \w+ = ', r'\bthen\s*$', r'^\s*end\)?\s*$', r'\bpairs\('),
+    'sql': (r'(?i)^\s*create (table|view|index|function)\b', r'(?i)^\s*select .+ from ',
+            r'(?i)^\s*insert into ', r'(?i)^\s*alter table '),
+    'html': (r'(?i)^\s*<!doctype html', r'(?i)<html\b', r'(?i)</(body|head|div)>'),
+    'dockerfile': (r'^FROM \S+', r'^RUN ', r'^(COPY|ADD) \S+ \S+', r'^(CMD|ENTRYPOINT) \['),
+    'make': (r'^\.PHONY:', r'^[\w./-]+:( [^=].*)?$', r'^\t\S', r'\$\([A-Z_]+\)'),
+}
+COMPILED_HINTS = {lang: [re.compile(hint, re.MULTILINE) for hint in hints] for lang, hints in CONTENT_HINTS.items()}
+
+# Distinct hints of one language, with none of another, for full confidence
+CONFIDENT_HINTS = 3
+
+
+@dataclass(frozen=True)
+class Detection:
+    """A language guessed from a file's content"""
+    language: Optional[str]  # best guess, None when nothing in the text points to one
+    confidence: float  # 1.0 for a #! line or modeline, else from the hints found (see detect_language)
+
+
+def shebang_interpreter(path: Path) -> Optional[str]:
+    """
+    Interpreter a script's #! line runs, without its directory and version:
+    'python' for #!/usr/bin/env python3.11, 'bash' for #!/bin/bash -e
+    """
+    try:
+        with open(path, 'rb') as f:
+            line = f.read(SHEBANG_BYTES).split(b'\n', 1)[0].decode('utf-8', errors='ignore')
+    except OSError:
+        return None
+    return line_interpreter(line)
+
+
+def line_interpreter(line: str) -> Optional[str]:
+    """Interpreter of a #! line (see shebang_interpreter), None for any other line"""
+    if not line.startswith('#!'):
+        return None
+    
+    words = line[2:].split()
+    if words and os.path.basename(words[0]) == 'env':
+        # env's own options and VAR=value assignments come before the command
+        words = [word for word in words[1:] if not word.startswith('-') and '=' not in word]
+    if not words:
+        return None
+    return re.sub(r'[\d.]+$', '', os.path.basename(words[0])) or None
+
+
+def modeline_language(lines: List[str]) -> Optional[str]:
+    """Language an Emacs or vim modeline in the first or last lines names, if it is a known one"""
+    for line in lines[:MODELINE_LINES] + lines[-MODELINE_LINES:]:
+        match = EMACS_MODELINE.search(line) or VIM_MODELINE.search(line)
+        if match:
+            name = match.group(1).lower()
+            lang = MODELINE_NAMES.get(name, name)
+            if lang in CONFIG.file_types:
+                return lang
+    return None
+
+
+def detect_language(path: Path, shebangs: Optional[Dict[str, str]] = None) -> Optional[Detection]:
+    """
+    Language of a file from its content: the interpreter of its #! line (one of
+    shebangs, default CONFIG.shebang_languages) or a modeline, with confidence 1.0,
+    else the language with the most distinct CONTENT_HINTS in the head of the file.
+    Its confidence grows with the hints found, to 1.0 at CONFIDENT_HINTS, and shrinks
+    with those of the runner-up: best == second gives 0.
+    
+    Returns:
+        The detection, with language None and confidence 0.0 for text nothing points
+        to a language in; None for a binary, empty or unreadable file
+    """
+    try:
+        with open(path, 'rb') as f:
+            head = f.read(DETECTION_BYTES)
+    except OSError:
+        return None
+    if not head.strip() or b'\0' in head:
+        return None
+    text = head.decode('utf-8', errors='ignore')
+    lines = text.split('\n')
+    
+    lang = (CONFIG.shebang_languages if shebangs is None else shebangs).get(line_interpreter(lines[0]))
+    lang = lang or modeline_language(lines)
+    if lang:
+        return Detection(lang, 1.0)
+    
+    scores = sorted(((sum(1 for hint in hints if hint.search(text)), lang)
+                     for lang, hints in COMPILED_HINTS.items() if lang in CONFIG.file_types), reverse=True)
+    if not scores or not scores[0][0]:
+        return Detection(None, 0.0)
+    (best, lang), second = scores[0], scores[1][0] if len(scores) > 1 else 0
+    confidence = (best - second) / best * min(1.0, best / CONFIDENT_HINTS)
+    return Detection(lang, round(confidence, 2))
+
+
+def tag_detection(chunks: list, detection: Optional[Detection]) -> list:
+    """Record the language detected from the file's content, and its confidence, in each chunk's metadata"""
+    if detection is None:
+        return chunks
+    for chunk in chunks:
+        chunk.metadata = dict(chunk.metadata or {}, detected_language=detection.language,
+                              language_confidence=detection.confidence)
+    return chunks
+
+
+class LanguageMap:
+    """Decides the language of each discovered file, or None to leave it out"""
+    
+    def __init__(self, extensions: Optional[Dict[str, str]] = None, paths: Optional[Dict[str, str]] = None,
+                 shebangs: Optional[Dict[str, str]] = None, plain_text: Optional[bool] = None,
+                 detect: Optional[bool] = None, min_confidence: Optional[float] = None):
+        """
+        Args:
+            extensions: Extension ('.gohtml') or file name ('Jenkinsfile') -> language,
+                in place of CONFIG.file_types (default: CONFIG.extension_languages)
+            paths: Glob pattern on the path under the indexed root -> language,
+                winning over extensions (default: CONFIG.path_languages)
+            shebangs: Interpreter of an extensionless script's #! line -> language
+                (default: CONFIG.shebang_languages)
+            plain_text: Index files nothing else maps as 'text'
+                (default: CONFIG.text_fallback)
+            detect: Decide the language of files nothing maps from their content, and
+                index those it is not confident about as 'text' (default: CONFIG.detect_languages)
+            min_confidence: Lowest confidence of a detected language that is used
+                (default: CONFIG.language_confidence)
+        
+        Raises:
+            ValueError: If a mapping names a language that is not in CONFIG.file_types,
+                or min_confidence is not between 0 and 1
+        """
+        self.extensions = dict(CONFIG.extension_languages if extensions is None else extensions)
+        self.paths = dict(CONFIG.path_languages if paths is None else paths)
+        self.shebangs = dict(CONFIG.shebang_languages if shebangs is None else shebangs)
+        self.plain_text = CONFIG.text_fallback if plain_text is None else plain_text
+        self.detect = CONFIG.detect_languages if detect is None else detect
+        self.min_confidence = CONFIG.language_confidence if min_confidence is None else min_confidence
+        # What content detection found for each file it decided (by path), for its chunks' metadata
+        self.detections: Dict[str, Detection] = {}
+        
+        for setting, mapping in (('extension_languages', self.extensions), ('path_languages', self.paths),
+                                 ('shebang_languages', self.shebangs)):
+            unknown = sorted({lang for lang in mapping.values()
+                              if lang not in CONFIG.file_types and lang != TEXT_LANGUAGE})
+            if unknown:
+                raise ValueError(f"Unknown language in {setting}: {', '.join(unknown)} "
+                                 f"(expected one of CONFIG.file_types or '{TEXT_LANGUAGE}')")
+        if not 0 <= self.min_confidence <= 1:
+            raise ValueError(f"language_confidence must be between 0 and 1, got {self.min_confidence}")
+        
+        # Built-in mapping: the first file type listing an extension or name wins
+        self.builtin: Dict[str, str] = {}
+        for lang, ft_config in CONFIG.file_types.items():
+            for extension in ft_config.extensions:
+                self.builtin.setdefault(extension, lang)
+    
+    def language(self, path: Path, relative: Optional[str] = None) -> Optional[str]:
+        """
+        Language of a file: from the first path pattern matching it, then its file
+        name or extension (overrides before the built-in mapping), then for an
+        extensionless file its #! line; with detection on, any file nothing maps
+        gets the language detected from its content, or 'text' below min_confidence.
+        'text' if nothing matched and plain text is on, else None
+        
+        Args:
+            path: The file (read for its #! line or content only when nothing else maps it)
+            relative: Its path under the indexed root, for the path patterns
+                (default: path itself)
+        """
+        relative = Path(relative if relative is not None else path).as_posix()
+        for pattern, lang in self.paths.items():
+            if fnmatch(relative, pattern):
+                return lang
+        
+        for key in (path.name, path.suffix):
+            if not key:
+                continue
+            lang = self.extensions.get(key) or self.builtin.get(key)
+            if lang:
+                return lang
+        
+        self.detections.pop(str(path), None)
+        if self.detect:
+            detection = detect_language(path, self.shebangs)
+            if detection is not None:
+                self.detections[str(path)] = detection
+                if detection.language and detection.confidence >= self.min_confidence:
+                    return detection.language
+                return TEXT_LANGUAGE
+        elif not path.suffix:
+            lang = self.shebangs.get(shebang_interpreter(path))
+            if lang:
+                return lang
+        return TEXT_LANGUAGE if self.plain_text else None
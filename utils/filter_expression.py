@@ -0,0 +1,326 @@
+#!/usr/bin/env python3
+"""
+Boolean filter expressions for search
+The flat search filters (languages, kinds, path_globs, ...) are ANDed together;
+an expression selects chunks by any combination of the same fields:
+
+    (language=go OR language=rust) AND NOT kind=test AND path:*/auth/*
+
+Terms are field=value (or field:value, the same; field!=value negates) and
+combine with NOT, AND and OR, binding in that order, with parentheses to group.
+Values are fnmatch patterns ('*' also matches across directories) and may be
+quoted ("...") to hold spaces, parentheses or = : ! characters. The same
+expression can be given as a tree of JSON objects:
+
+    {"and": [{"or": [{"language": "go"}, {"language": "rust"}]},
+             {"not": {"kind": "test"}}, {"path": "*/auth/*"}]}
+
+where a leaf {field: value} may list several values, any of which matches.
+"""
+
+import re
+from dataclasses import dataclass
+from fnmatch import fnmatchcase
+from typing import Dict, List, Tuple, Union
+
+from chunkers.base_chunker import parse_metadata, qualified_name
+from chunkers.config_chunks import matches_resource
+from chunkers.go_errors import uses_pattern
+from chunkers.go_imports import uses_dependency
+from chunkers.go_struct_tags import matches_tag
+from chunkers.go_tests import TEST_KINDS
+from chunkers.html_chunker import matches_template
+from chunkers.scala_chunker import matches_implicit
+from utils.git_blame import matches_author, matches_commit
+
+
+# Fields a term can test, and what each is matched against
+FILTER_FIELDS = {
+    'language': 'language the chunk is indexed as',
+    'kind': "symbol kind (function, method, type, ...) or chunk kind (source, test, benchmark, fuzz, example, text)",
+    'type': 'chunk type as stored (struct, class, interface_method, ...)',
+    'path': 'file path under the indexed root',
+    'repo': 'repository label of a multi-repo index',
+    'name': 'symbol name, or its qualified name (AdminUser.Authenticate)',
+    'uses': 'imported package or member the chunk uses (sync.RWMutex)',
+    'returns_error': 'true for Go functions and methods returning an error, false for those that do not',
+    'errors': 'error-handling pattern of a Go function (new, wrap, join, type, sentinel, check), '
+              'or the call, error type or sentinel it uses (fmt.Errorf, NotFoundError, ErrNotFound)',
+    'generated': 'true for chunks of generated files (see CONFIG.generated_code), false for the rest',
+    'author': "name or email of the chunk's last author (CONFIG.git_blame), ignoring case",
+    'commit': 'SHA (or a prefix of it) of the commit that last changed the chunk (CONFIG.git_blame)',
+    'resource': 'Kind/name of a Kubernetes manifest (Deployment/auth-service), or its kind alone',
+    'tag': 'name a Go struct tag maps a field of the chunk to (username), or key:name for one tag '
+           'namespace (json:username)',
+    'template': 'name of a Go template the chunk defines, includes or declares as a block, or that '
+                'Go code executes (user/profile)',
+    'implicit': 'true for Scala implicit and given definitions, false for the rest; or the keyword '
+                '(implicit, given)',
+}
+
+# Chunk kinds a kind term matches directly instead of through the symbol types
+CHUNK_KIND_VALUES = ('source',) + TEST_KINDS + ('text',)
+
+KEYWORDS = ('AND', 'OR', 'NOT')
+
+TOKEN_PATTERN = re.compile(r'\s*(?:(?P<paren>[()])|(?P<op>!=|=|:)|"(?P<quoted>(?:[^"\\]|\\.)*)"|(?P<word>[^\s()"=:!]+))')
+
+# Values printed without quotes in the canonical form
+BARE_VALUE = re.compile(r'[^\s()"=:!]+')
+
+# Bounds on what a filter may hold, so a hostile one cannot exhaust the parser's stack:
+# characters of a filter string, and parentheses, NOTs and and/or/not objects nested in one another
+MAX_FILTER_LENGTH = 4096
+MAX_FILTER_DEPTH = 32
+
+
+class FilterError(ValueError):
+    """Raised for filter expressions that do not parse"""
+
+
+@dataclass(frozen=True)
+class Term:
+    """field=value: a chunk whose field matches one of the patterns"""
+    field: str
+    patterns: Tuple[str, ...]
+    
+    def matches(self, metadata: Dict, symbol_kinds: Dict[str, List[str]]) -> bool:
+        return any(self._matches(metadata, pattern, symbol_kinds) for pattern in self.patterns)
+    
+    def _matches(self, metadata: Dict, pattern: str, symbol_kinds: Dict[str, List[str]]) -> bool:
+        if self.field == 'kind':
+            if pattern in CHUNK_KIND_VALUES:
+                return metadata.get('kind', 'source') == pattern
+            return metadata.get('type') in symbol_kinds.get(pattern, [pattern])
+        if self.field == 'name':
+            return fnmatchcase(metadata.get('name', ''), pattern) or fnmatchcase(qualified_name(metadata), pattern)
+        if self.field == 'uses':
+            return uses_dependency(parse_metadata(metadata.get('metadata')).get('imports', []), pattern)
+        if self.field == 'returns_error':
+            # Only functions the Go chunker looked at have it: other chunks match neither value
+            value = parse_metadata(metadata.get('metadata')).get('returns_error')
+            return isinstance(value, bool) and fnmatchcase(str(value).lower(), pattern.lower())
+        if self.field == 'generated':
+            return fnmatchcase(str(bool(metadata.get('generated'))).lower(), pattern.lower())
+        if self.field == 'author':
+            return matches_author(parse_metadata(metadata.get('metadata')).get('last_change'), pattern)
+        if self.field == 'commit':
+            return matches_commit(parse_metadata(metadata.get('metadata')).get('last_change'), pattern)
+        if self.field == 'resource':
+            return matches_resource(parse_metadata(metadata.get('metadata')), pattern)
+        if self.field == 'tag':
+            return matches_tag(parse_metadata(metadata.get('metadata')).get('fields') or [], pattern)
+        if self.field == 'template':
+            return matches_template(parse_metadata(metadata.get('metadata')), pattern)
+        if self.field == 'implicit':
+            return matches_implicit(parse_metadata(metadata.get('metadata')), pattern)
+        if self.field == 'errors':
+            return uses_pattern(parse_metadata(metadata.get('metadata')).get('error_handling'),
+                                lambda value: fnmatchcase(value, pattern))
+        value = metadata.get('filepath' if self.field == 'path' else self.field)
+        return fnmatchcase(value or '', pattern)
+    
+    def __str__(self):
+        terms = [f"{self.field}={_quote(pattern)}" for pattern in self.patterns]
+        return terms[0] if len(terms) == 1 else f"({' OR '.join(terms)})"
+
+
+@dataclass(frozen=True)
+class Not:
+    """NOT operand"""
+    operand: 'FilterExpression'
+    
+    def matches(self, metadata: Dict, symbol_kinds: Dict[str, List[str]]) -> bool:
+        return not self.operand.matches(metadata, symbol_kinds)
+    
+    def __str__(self):
+        return f"NOT ({self.operand})" if isinstance(self.operand, (And, Or)) else f"NOT {self.operand}"
+
+
+@dataclass(frozen=True)
+class And:
+    """All operands match"""
+    operands: Tuple['FilterExpression', ...]
+    
+    def matches(self, metadata: Dict, symbol_kinds: Dict[str, List[str]]) -> bool:
+        return all(operand.matches(metadata, symbol_kinds) for operand in self.operands)
+    
+    def __str__(self):
+        return ' AND '.join(f"({o})" if isinstance(o, Or) else str(o) for o in self.operands)
+
+
+@dataclass(frozen=True)
+class Or:
+    """Any operand matches"""
+    operands: Tuple['FilterExpression', ...]
+    
+    def matches(self, metadata: Dict, symbol_kinds: Dict[str, List[str]]) -> bool:
+        return any(operand.matches(metadata, symbol_kinds) for operand in self.operands)
+    
+    def __str__(self):
+        return ' OR '.join(str(operand) for operand in self.operands)
+
+
+FilterExpression = Union[Term, Not, And, Or]
+
+
+def parse_filter(expression: Union[str, Dict, 'FilterExpression']) -> 'FilterExpression':
+    """
+    Parse a filter expression, as a string or a JSON tree (see the module docs)
+    
+    The result is hashable and compares by value; str() gives its canonical
+    string form. An expression already parsed is returned as it is.
+    
+    Raises:
+        FilterError: With what is wrong and where (the position in a string,
+            the path to the node in a tree)
+    """
+    if isinstance(expression, (Term, Not, And, Or)):
+        return expression
+    if isinstance(expression, dict):
+        return _parse_tree(expression, 'filter', 0)
+    if not isinstance(expression, str):
+        raise FilterError("A filter must be a string or a JSON object")
+    if len(expression) > MAX_FILTER_LENGTH:
+        raise FilterError("filter nested too deeply")
+    return _Parser(expression).parse()
+
+
+def _quote(value: str) -> str:
+    if BARE_VALUE.fullmatch(value) and value.upper() not in KEYWORDS:
+        return value
+    escaped = value.replace('\\', '\\\\').replace('"', '\\"')
+    return f'"{escaped}"'
+
+
+def _unknown_field(field: str) -> str:
+    return f"Unknown filter field '{field}' (expected one of: {', '.join(FILTER_FIELDS)})"
+
+
+def _term(field: str, values: List[str], where: str) -> Term:
+    if field not in FILTER_FIELDS:
+        raise FilterError(f"{where}: {_unknown_field(field)}")
+    if not values or not all(isinstance(v, str) and v for v in values):
+        raise FilterError(f"{where}: '{field}' needs a non-empty value or list of them")
+    return Term(field, tuple(values))
+
+
+class _Parser:
+    """Recursive descent over the tokens of a filter string"""
+    
+    def __init__(self, text: str):
+        self.text = text
+        self.tokens = []  # (kind, value, position)
+        position = 0
+        while text[position:].strip():
+            match = TOKEN_PATTERN.match(text, position)
+            if not match:
+                start = len(text) - len(text[position:].lstrip())
+                if text[start] == '"':
+                    raise self._error("Unterminated quoted value", start)
+                raise self._error(f"Unexpected character '{text[start]}'", start)
+            start = match.start(match.lastgroup)
+            if match.lastgroup == 'quoted':
+                start -= 1
+                value = re.sub(r'\\(.)', r'\1', match.group('quoted'))
+                self.tokens.append(('value', value, start))
+            elif match.lastgroup == 'word' and match.group('word').upper() in KEYWORDS:
+                self.tokens.append(('keyword', match.group('word').upper(), start))
+            else:
+                kind = 'value' if match.lastgroup == 'word' else match.lastgroup
+                self.tokens.append((kind, match.group(match.lastgroup), start))
+            position = match.end()
+        self.index = 0
+        self.depth = 0
+    
+    def _error(self, message: str, position: int) -> FilterError:
+        return FilterError(f"{message} at position {position} of filter: {self.text}")
+    
+    def _peek(self):
+        return self.tokens[self.index] if self.index < len(self.tokens) else ('end', None, len(self.text))
+    
+    def _next(self):
+        token = self._peek()
+        self.index += 1
+        return token
+    
+    def parse(self) -> 'FilterExpression':
+        if not self.tokens:
+            raise self._error("Empty filter", 0)
+        expression = self._or()
+        kind, value, position = self._peek()
+        if kind != 'end':
+            raise self._error(f"Expected AND, OR or the end of the filter, found '{value}'", position)
+        return expression
+    
+    def _or(self):
+        operands = [self._and()]
+        while self._peek()[:2] == ('keyword', 'OR'):
+            self._next()
+            operands.append(self._and())
+        return operands[0] if len(operands) == 1 else Or(tuple(operands))
+    
+    def _and(self):
+        operands = [self._not()]
+        while self._peek()[:2] == ('keyword', 'AND'):
+            self._next()
+            operands.append(self._not())
+        return operands[0] if len(operands) == 1 else And(tuple(operands))
+    
+    def _not(self):
+        if self._peek()[:2] == ('keyword', 'NOT'):
+            self._next()
+            self._enter()
+            operand = self._not()
+            self.depth -= 1
+            return Not(operand)
+        return self._atom()
+    
+    def _enter(self):
+        self.depth += 1
+        if self.depth > MAX_FILTER_DEPTH:
+            raise FilterError("filter nested too deeply")
+    
+    def _atom(self):
+        kind, value, position = self._next()
+        if kind == 'paren' and value == '(':
+            self._enter()
+            expression = self._or()
+            self.depth -= 1
+            kind, value, close = self._next()
+            if (kind, value) != ('paren', ')'):
+                raise self._error(f"Expected ')' to close the '(' at position {position}", close)
+            return expression
+        if kind != 'value':
+            found = 'the end of the filter' if kind == 'end' else f"'{value}'"
+            raise self._error(f"Expected a field=value term, found {found}", position)
+        
+        field = value
+        if field not in FILTER_FIELDS:
+            raise self._error(_unknown_field(field), position)
+        kind, op, op_position = self._next()
+        if kind != 'op':
+            raise self._error(f"Expected '=', ':' or '!=' after '{field}'", op_position)
+        kind, pattern, value_position = self._next()
+        if kind != 'value':
+            raise self._error(f"Expected a value after '{field}{op}'", value_position)
+        term = Term(field, (pattern,))
+        return Not(term) if op == '!=' else term
+
+
+def _parse_tree(node, where: str, depth: int) -> 'FilterExpression':
+    if depth > MAX_FILTER_DEPTH:
+        raise FilterError("filter nested too deeply")
+    if not isinstance(node, dict) or len(node) != 1:
+        raise FilterError(f"{where}: expected an object with one key: and, or, not or a field name")
+    (key, value), = node.items()
+    if key in ('and', 'or'):
+        if not isinstance(value, list) or not value:
+            raise FilterError(f"{where}.{key}: expected a non-empty list of filters")
+        operands = tuple(_parse_tree(operand, f"{where}.{key}[{i}]", depth + 1) for i, operand in enumerate(value))
+        if len(operands) == 1:
+            return operands[0]
+        return And(operands) if key == 'and' else Or(operands)
+    if key == 'not':
+        return Not(_parse_tree(value, f"{where}.not", depth + 1))
+    return _term(key, value if isinstance(value, list) else [value], where)
@@ -0,0 +1,138 @@
+#!/usr/bin/env python3
+"""
+Detection of generated code
+A file is generated when the comments at its top say so, by the convention of
+its language, or when its name is one code generators write. Go has a strict
+rule (https://go.dev/s/generatedcode): a line matching
+
+    // Code generated ... DO NOT EDIT.
+
+before the package clause. Other languages have no single rule, so their
+header comments are searched for the usual markers (@generated, "Generated by
+the protocol buffer compiler.  DO NOT EDIT!", <auto-generated>). File names
+catch generator output without such a header (*.pb.go, *_pb2.py, *.designer.cs).
+"""
+
+import re
+from fnmatch import fnmatchcase
+from pathlib import Path
+from typing import Iterator, Optional, Tuple
+
+# What indexing does with generated files (see CONFIG.generated_code)
+GENERATED_MODES = ('tag', 'skip', 'off')
+
+# Bytes of a file read for its header when deciding which files to index
+GENERATED_SNIFF_BYTES = 8192
+
+# Leading lines searched for a header marker (a license block often comes first)
+HEADER_LINES = 40
+
+GO_GENERATED_HEADER = re.compile(r'^// Code generated .* DO NOT EDIT\.$')
+
+GENERATED_MARKERS = re.compile(
+    r'@generated\b|\b(?:code|auto-?)generated\b|<auto-generated|\bgenerated (?:by|from)\b|\bdo not edit\b',
+    re.IGNORECASE
+)
+
+# How header comments start, by language (the rest use C-style comments)
+HASH_COMMENTS = ('#',)
+COMMENT_PREFIXES = {
+    'python': HASH_COMMENTS + ('"""', "'''"),
+    'ruby': HASH_COMMENTS,
+    'elixir': HASH_COMMENTS,
+    'bash': HASH_COMMENTS,
+    'gn': HASH_COMMENTS,
+    'yaml': HASH_COMMENTS,
+    'toml': HASH_COMMENTS,
+    'sql': ('--', '/*', '*'),
+    'lua': ('--',),
+    'markdown': ('<!--',),
+    'html': ('<!--',),
+    'xml': ('<!--', '<?xml'),
+    'php': ('<?php', '//', '#', '/*', '*'),
+}
+C_COMMENTS = ('//', '/*', '*', '#!')
+BLOCK_COMMENTS = {'/*': '*/', '"""': '"""', "'''": "'''", '<!--': '-->'}
+
+# File names generators write, by language (fnmatch patterns on the file name)
+GENERATED_NAMES = {
+    'go': ('*.pb.go', '*.pb.gw.go', 'zz_generated*.go'),
+    'python': ('*_pb2.py', '*_pb2_grpc.py', '*_pb2.pyi'),
+    'cpp': ('*.pb.h', '*.pb.cc', 'moc_*.cpp'),
+    'c': ('*.pb-c.h', '*.pb-c.c'),
+    'javascript': ('*_pb.js', '*_grpc_pb.js'),
+    'typescript': ('*_pb.ts', '*_pb.d.ts'),
+    'csharp': ('*.designer.cs', '*.Designer.cs', '*.g.cs', '*.g.i.cs'),
+    'swift': ('*.pb.swift', '*.grpc.swift'),
+    'dart': ('*.g.dart', '*.freezed.dart', '*.pb.dart'),
+}
+
+
+def header_lines(code: str, language: str) -> Iterator[str]:
+    """The comment lines at the top of a file, up to its first line of code"""
+    prefixes = COMMENT_PREFIXES.get(language, C_COMMENTS)
+    closing = None  # end of the block comment (or docstring) the line is in
+    for line in code[:GENERATED_SNIFF_BYTES].splitlines()[:HEADER_LINES]:
+        stripped = line.strip()
+        if closing is None and stripped and not stripped.startswith(prefixes):
+            return
+        if not stripped:
+            continue
+        yield stripped
+        if closing is not None:
+            if closing in stripped:
+                closing = None
+            continue
+        opener = next((o for o in BLOCK_COMMENTS if stripped.startswith(o)), None)
+        if opener and BLOCK_COMMENTS[opener] not in stripped[len(opener):]:
+            closing = BLOCK_COMMENTS[opener]
+
+
+def generated_reason(rel_path: str, code: str, language: str, patterns: Tuple[str, ...] = ()) -> Optional[str]:
+    """
+    Why a file is generated code, or None for hand-written code
+    
+    Args:
+        rel_path: Path under the indexed root
+        code: The file's content, or at least its first GENERATED_SNIFF_BYTES
+        language: Language the file is indexed as
+        patterns: Extra globs (CONFIG.generated_patterns): one with a '/' matches the
+            path under the root ('*' crosses directories), others the file name
+    
+    Returns:
+        'header' when its header comment marks it, else the name pattern it matches
+    """
+    if language == 'go':
+        if any(GO_GENERATED_HEADER.match(line) for line in header_lines(code, language)):
+            return 'header'
+    elif any(GENERATED_MARKERS.search(line) for line in header_lines(code, language)):
+        return 'header'
+    path = Path(rel_path).as_posix()
+    name = path.rsplit('/', 1)[-1]
+    for pattern in GENERATED_NAMES.get(language, ()) + tuple(patterns):
+        if fnmatchcase(path if '/' in pattern else name, pattern):
+            return pattern
+    return None
+
+
+def read_head(path: Path) -> str:
+    """The first GENERATED_SNIFF_BYTES of a file as text ('' when unreadable)"""
+    try:
+        with open(path, 'rb') as f:
+            return f.read(GENERATED_SNIFF_BYTES).decode('utf-8', errors='ignore')
+    except OSError:
+        return ''
+
+
+def check_generated_weight(value) -> float:
+    """The generated_weight of a search as a float; ValueError unless it is a positive number"""
+    if isinstance(value, bool) or not isinstance(value, (int, float)) or value <= 0:
+        raise ValueError(f"'generated_weight' must be a positive number, got {value!r}")
+    return float(value)
+
+
+def tag_generated(chunks: list) -> list:
+    """Mark chunks as coming from a generated file"""
+    for chunk in chunks:
+        chunk.generated = True
+    return chunks
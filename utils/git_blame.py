@@ -0,0 +1,126 @@
+#!/usr/bin/env python3
+"""
+Who last changed a symbol, from git blame
+With CONFIG.git_blame on, each file of a git work tree is blamed once when it
+is indexed, and every chunk records the most recent commit among its lines as
+its 'last_change' metadata: the commit SHA, its author and email, the author
+date and the commit's summary line. Lines not committed yet are left out, so a
+symbol whose every line is uncommitted has none. Blame runs a git process per
+file and walks the file's history, which is why it is off by default.
+"""
+
+import subprocess
+from datetime import datetime, timezone
+from fnmatch import fnmatchcase
+from pathlib import Path
+from typing import Dict, List, Optional
+
+# Seconds one file's blame may take before it is given up on
+BLAME_TIMEOUT = 60
+
+# What git blame reports for lines not committed yet
+UNCOMMITTED = '0' * 40
+
+
+def blame_lines(root: str, rel_path: str) -> Optional[List[Optional[Dict]]]:
+    """
+    The commit that last changed each line of a file, from one `git blame --porcelain` run
+    
+    Args:
+        root: Directory of the indexed tree (anywhere in a git work tree)
+        rel_path: Path of the file under root
+    
+    Returns:
+        One entry per line (index 0 for line 1): {'commit', 'author', 'email', 'time'
+        (author date), 'committed' (commit date), 'summary'}, or None for a line not committed yet; None when the file is
+        not tracked, root is not in a work tree, or git is not installed
+    """
+    try:
+        output = subprocess.run(['git', 'blame', '--porcelain', '--', Path(rel_path).as_posix()], cwd=root,
+                                capture_output=True, text=True, errors='replace', check=True,
+                                timeout=BLAME_TIMEOUT).stdout
+    except (OSError, subprocess.CalledProcessError, subprocess.TimeoutExpired):
+        return None
+    return parse_porcelain(output)
+
+
+def parse_porcelain(output: str) -> List[Optional[Dict]]:
+    """The per-line commits of `git blame --porcelain` output (see blame_lines)"""
+    commits: Dict[str, Dict] = {}
+    lines: List[Optional[Dict]] = []
+    current = None
+    for line in output.splitlines():
+        if line.startswith('\t'):
+            # The line's content closes its entry; a header line starts the next
+            lines.append(None if current == UNCOMMITTED else commits[current])
+            current = None
+            continue
+        key, _, value = line.partition(' ')
+        if current is None:
+            current = key
+            commits.setdefault(current, {'commit': current})
+        elif key == 'author':
+            commits[current]['author'] = value
+        elif key == 'author-mail':
+            commits[current]['email'] = value.strip('<>')
+        elif key == 'author-time':
+            commits[current]['time'] = int(value)
+        elif key == 'committer-time':
+            commits[current]['committed'] = int(value)
+        elif key == 'summary':
+            commits[current]['summary'] = value
+    return lines
+
+
+def last_change(lines: List[Optional[Dict]], line_start: int, line_end: int) -> Optional[Dict]:
+    """
+    The most recent commit among lines line_start..line_end (1-based, inclusive),
+    by commit date (a rebased commit keeps its older author date)
+    
+    Returns:
+        {'commit', 'author', 'email', 'date', 'summary'}, date as ISO 8601 UTC, or
+        None when no line of the range is committed
+    """
+    commits = [entry for entry in lines[max(line_start - 1, 0):line_end] if entry is not None]
+    if not commits:
+        return None
+    latest = max(commits, key=lambda entry: (entry.get('committed', 0), entry.get('time', 0)))
+    return {
+        'commit': latest['commit'],
+        'author': latest.get('author', ''),
+        'email': latest.get('email', ''),
+        'date': datetime.fromtimestamp(latest.get('time', 0), timezone.utc).strftime('%Y-%m-%dT%H:%M:%SZ'),
+        'summary': latest.get('summary', ''),
+    }
+
+
+def tag_last_changes(chunks: list, lines: List[Optional[Dict]]) -> list:
+    """Record each chunk's last change in its metadata, from the blame of its file"""
+    for chunk in chunks:
+        change = last_change(lines, chunk.line_start, chunk.line_end)
+        if change is not None:
+            chunk.metadata = dict(chunk.metadata or {}, last_change=change)
+    return chunks
+
+
+def matches_author(change: Optional[Dict], pattern: str) -> bool:
+    """True if the author's name or email of a last change matches the glob, ignoring case"""
+    pattern = pattern.lower()
+    return isinstance(change, dict) and any(
+        fnmatchcase(str(change.get(key, '')).lower(), pattern) for key in ('author', 'email'))
+
+
+def matches_commit(change: Optional[Dict], pattern: str) -> bool:
+    """True if the commit of a last change starts with the SHA prefix, or matches it as a glob"""
+    commit = change.get('commit', '') if isinstance(change, dict) else ''
+    pattern = pattern.lower()
+    return bool(commit) and (commit.startswith(pattern) or fnmatchcase(commit, pattern))
+
+
+def describe_change(change: Dict) -> str:
+    """One line for a last change: 'Alice <alice@example.com> in 1a2b3c4 (2024-05-01): subject'"""
+    author = change.get('author', '')
+    if change.get('email'):
+        author += f" <{change['email']}>"
+    summary = f": {change['summary']}" if change.get('summary') else ''
+    return f"{author} in {change['commit'][:7]} ({change.get('date', '')[:10]}){summary}"
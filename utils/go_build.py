@@ -0,0 +1,206 @@
+#!/usr/bin/env python3
+"""
+Go build constraints for file discovery
+Decides whether a .go file is part of the build for a target GOOS/GOARCH,
+from its file name (name_linux_amd64.go) and its //go:build or legacy
+// +build lines, and recognizes _test.go files
+"""
+
+import re
+from pathlib import Path
+from typing import Iterable, List, Optional
+
+
+KNOWN_OS = {
+    'aix', 'android', 'darwin', 'dragonfly', 'freebsd', 'hurd', 'illumos', 'ios', 'js',
+    'linux', 'nacl', 'netbsd', 'openbsd', 'plan9', 'solaris', 'wasip1', 'windows', 'zos',
+}
+KNOWN_ARCH = {
+    '386', 'amd64', 'amd64p32', 'arm', 'armbe', 'arm64', 'arm64be', 'loong64', 'mips',
+    'mipsle', 'mips64', 'mips64le', 'mips64p32', 'mips64p32le', 'ppc', 'ppc64', 'ppc64le',
+    'riscv', 'riscv64', 's390', 's390x', 'sparc', 'sparc64', 'wasm',
+}
+UNIX_OS = {
+    'aix', 'android', 'darwin', 'dragonfly', 'freebsd', 'hurd', 'illumos', 'ios', 'linux',
+    'netbsd', 'openbsd', 'solaris',
+}
+# GOOS values that also satisfy another OS's tag (and file name suffix)
+IMPLIED_OS = {'android': 'linux', 'illumos': 'solaris', 'ios': 'darwin'}
+
+# Constraints must appear before the package clause; reading this much is always enough
+HEADER_BYTES = 65536
+
+TOKEN_PATTERN = re.compile(r'\s*(\(|\)|!|&&|\|\||[\w.]+)')
+
+
+def is_go_test_file(path) -> bool:
+    """True for Go test files (name_test.go)"""
+    return Path(path).name.endswith('_test.go')
+
+
+def build_constraint_lines(code: str) -> List[str]:
+    """
+    The //go:build line (or, without one, the // +build lines) of a Go file
+    Only comments before the package clause count, as for the go tool
+    """
+    go_build, plus_build = [], []
+    in_block = False
+    for line in code.split('\n'):
+        stripped = line.strip()
+        if in_block:
+            in_block = '*/' not in stripped
+            continue
+        if not stripped:
+            continue
+        if stripped.startswith('/*'):
+            in_block = '*/' not in stripped[2:]
+            continue
+        if not stripped.startswith('//'):
+            break
+        if re.match(r'//go:build(\s|$)', stripped):
+            go_build.append(stripped[len('//go:build'):].strip())
+        elif re.match(r'//\s*\+build(\s|$)', stripped):
+            plus_build.append(stripped.split('+build', 1)[1].strip())
+    
+    if go_build:
+        return go_build[:1]
+    # Legacy syntax: spaces are OR, commas AND, lines are ANDed
+    return [
+        ' || '.join('(' + ' && '.join(option.split(',')) + ')' for option in line.split())
+        for line in plus_build if line
+    ]
+
+
+class GoBuildContext:
+    """
+    Target platform and tags Go files are matched against
+    
+    A dimension left unset (goos or goarch) does not exclude anything: tags of
+    that dimension count as satisfied whether negated or not.
+    """
+    
+    def __init__(self, goos: Optional[str] = None, goarch: Optional[str] = None,
+                 tags: Optional[Iterable[str]] = None, include_tests: bool = True):
+        """
+        Args:
+            goos: Target operating system (linux, darwin, windows, ...)
+            goarch: Target architecture (amd64, arm64, ...)
+            tags: Extra build tags that are satisfied (e.g. cgo, integration)
+            include_tests: Keep _test.go files
+        """
+        self.goos = goos or None
+        self.goarch = goarch or None
+        self.tags = set(tags or [])
+        self.include_tests = include_tests
+    
+    @property
+    def constrained(self) -> bool:
+        """True if build constraints are evaluated at all"""
+        return bool(self.goos or self.goarch or self.tags)
+    
+    def includes(self, path) -> bool:
+        """True if a Go file belongs to the target build (reads the file's header if needed)"""
+        path = Path(path)
+        if not self.include_tests and is_go_test_file(path):
+            return False
+        if not self.constrained:
+            return True
+        if not self.matches_file_name(path.name):
+            return False
+        try:
+            with open(path, 'r', encoding='utf-8', errors='ignore') as f:
+                header = f.read(HEADER_BYTES)
+        except OSError:
+            return True
+        return self.matches_code(header)
+    
+    def matches_file_name(self, name: str) -> bool:
+        """Check the implicit _GOOS, _GOARCH and _GOOS_GOARCH file name constraints"""
+        stem = name[:-3] if name.endswith('.go') else name
+        if stem.endswith('_test'):
+            stem = stem[:-len('_test')]
+        parts = stem.split('_')[1:]  # the first element is never a constraint
+        if len(parts) >= 2 and parts[-2] in KNOWN_OS and parts[-1] in KNOWN_ARCH:
+            return self._tag(parts[-2]) is not False and self._tag(parts[-1]) is not False
+        if parts and (parts[-1] in KNOWN_OS or parts[-1] in KNOWN_ARCH):
+            return self._tag(parts[-1]) is not False
+        return True
+    
+    def matches_code(self, code: str) -> bool:
+        """Evaluate the file's //go:build (or // +build) constraints"""
+        return all(self.evaluate(line) is not False for line in build_constraint_lines(code))
+    
+    def evaluate(self, expression: str) -> Optional[bool]:
+        """
+        Evaluate a //go:build expression: True, False, or None when it only
+        depends on a dimension the context leaves unset (malformed expressions
+        also give None, so they never exclude a file)
+        """
+        tokens = TOKEN_PATTERN.findall(expression)
+        if ''.join(tokens).replace(' ', '') != expression.replace(' ', '').replace('\t', ''):
+            return None
+        position = 0
+        
+        def parse_or():
+            nonlocal position
+            values = [parse_and()]
+            while position < len(tokens) and tokens[position] == '||':
+                position += 1
+                values.append(parse_and())
+            if True in values:
+                return True
+            return None if None in values else False
+        
+        def parse_and():
+            nonlocal position
+            values = [parse_not()]
+            while position < len(tokens) and tokens[position] == '&&':
+                position += 1
+                values.append(parse_not())
+            if False in values:
+                return False
+            return None if None in values else True
+        
+        def parse_not():
+            nonlocal position
+            if position >= len(tokens):
+                raise ValueError("unexpected end of expression")
+            token = tokens[position]
+            position += 1
+            if token == '!':
+                value = parse_not()
+                return None if value is None else not value
+            if token == '(':
+                value = parse_or()
+                if position >= len(tokens) or tokens[position] != ')':
+                    raise ValueError("missing ')'")
+                position += 1
+                return value
+            if token in (')', '&&', '||'):
+                raise ValueError(f"unexpected '{token}'")
+            return self._tag(token)
+        
+        try:
+            value = parse_or()
+        except ValueError:
+            return None
+        return value if position == len(tokens) else None
+    
+    def _tag(self, tag: str) -> Optional[bool]:
+        """Whether one build tag is satisfied (None: depends on an unset dimension)"""
+        if tag in self.tags:
+            return True
+        if tag in KNOWN_OS or tag == 'unix':
+            if self.goos is None:
+                return None
+            if tag == 'unix':
+                return self.goos in UNIX_OS
+            return tag == self.goos or IMPLIED_OS.get(self.goos) == tag
+        if tag in KNOWN_ARCH:
+            return None if self.goarch is None else tag == self.goarch
+        # Release tags (go1.21) and the default compiler always hold
+        return bool(re.fullmatch(r'go1\.\d+', tag)) or tag == 'gc'
+    
+    def __repr__(self) -> str:
+        return (f"GoBuildContext(goos={self.goos!r}, goarch={self.goarch!r}, "
+                f"tags={sorted(self.tags)!r}, include_tests={self.include_tests})")
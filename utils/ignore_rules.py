@@ -0,0 +1,192 @@
+#!/usr/bin/env python3
+"""
+Ignore rules for file discovery
+Implements .gitignore semantics (nested files, negation, anchoring, '**',
+directory-only patterns) and filters out binary and oversized files
+"""
+
+import re
+from pathlib import Path
+from typing import List, Optional
+
+
+# Bytes read to decide whether a file is binary
+BINARY_SNIFF_BYTES = 8192
+
+
+class IgnorePattern:
+    """One .gitignore line, matched against paths relative to the indexed root"""
+    
+    def __init__(self, pattern: str, base: str = ''):
+        """
+        Args:
+            pattern: Pattern text (already stripped of comments and trailing spaces)
+            base: Directory of the .gitignore it came from, relative to the root ('' for the root)
+        """
+        self.source = pattern
+        self.base = base
+        self.negated = pattern.startswith('!')
+        if self.negated:
+            pattern = pattern[1:]
+        elif pattern.startswith('\\'):
+            pattern = pattern[1:]  # \! and \# match literally
+        
+        self.dir_only = pattern.endswith('/')
+        pattern = pattern.rstrip('/')
+        # A slash at the start or in the middle anchors the pattern to its .gitignore's directory
+        self.anchored = '/' in pattern
+        self.regex = re.compile(_translate(pattern.lstrip('/')) + r'\Z')
+    
+    def matches(self, path: str, is_dir: bool) -> bool:
+        """True if the pattern matches a '/'-separated path relative to the root"""
+        if self.dir_only and not is_dir:
+            return False
+        if self.base:
+            if not path.startswith(self.base + '/'):
+                return False
+            path = path[len(self.base) + 1:]
+        if self.anchored:
+            return bool(self.regex.match(path))
+        return bool(self.regex.match(path.rsplit('/', 1)[-1]))
+
+
+def _translate(pattern: str) -> str:
+    """Regex for a gitignore glob: '*' and '?' stay within a path segment, '**' spans segments"""
+    regex = ''
+    i = 0
+    while i < len(pattern):
+        char = pattern[i]
+        if pattern.startswith('**/', i):
+            regex += '(?:.*/)?'
+            i += 3
+            continue
+        if pattern.startswith('/**', i) and i + 3 == len(pattern):
+            regex += '/.*'
+            i += 3
+            continue
+        if pattern.startswith('**', i):
+            regex += '.*'
+            i += 2
+            continue
+        if char == '*':
+            regex += '[^/]*'
+        elif char == '?':
+            regex += '[^/]'
+        elif char == '[':
+            end = pattern.find(']', i + 1)
+            if end == -1:
+                regex += re.escape(char)
+            else:
+                body = pattern[i + 1:end]
+                if body.startswith('!'):
+                    body = '^' + body[1:]
+                regex += f'[{body}]'
+                i = end
+        elif char == '\\' and i + 1 < len(pattern):
+            i += 1
+            regex += re.escape(pattern[i])
+        else:
+            regex += re.escape(char)
+        i += 1
+    return regex
+
+
+def parse_ignore_lines(lines: List[str], base: str = '') -> List[IgnorePattern]:
+    """Patterns from the lines of a .gitignore, skipping blanks and comments"""
+    patterns = []
+    for line in lines:
+        line = line.rstrip('\n')
+        if not line.endswith('\\ '):
+            line = line.rstrip()
+        if not line or line.startswith('#'):
+            continue
+        patterns.append(IgnorePattern(line, base))
+    return patterns
+
+
+class IgnoreRules:
+    """
+    The ignore patterns in effect during a directory walk
+    
+    Patterns are checked in order (extra patterns, then .gitignore files from
+    the root down) and the last match wins, so a nested '!keep.go' re-includes
+    what an outer '*.go' excluded. As in git, a file inside an ignored directory
+    can't be re-included: the walk never enters that directory.
+    """
+    
+    def __init__(self, root: Path, extra_patterns: Optional[List[str]] = None,
+                 use_gitignore: bool = True):
+        """
+        Args:
+            root: Directory being indexed; paths are matched relative to it
+            extra_patterns: Additional gitignore-style patterns (e.g. from --ignore)
+            use_gitignore: Read .gitignore files found during the walk
+        """
+        self.root = root
+        self.use_gitignore = use_gitignore
+        self.patterns: List[IgnorePattern] = parse_ignore_lines(extra_patterns or [])
+        self._loaded = set()
+    
+    def enter(self, directory: Path):
+        """Load the .gitignore of a directory the walk is entering"""
+        if not self.use_gitignore or directory in self._loaded:
+            return
+        self._loaded.add(directory)
+        gitignore = directory / '.gitignore'
+        if not gitignore.is_file():
+            return
+        
+        base = self._relative(directory)
+        try:
+            lines = gitignore.read_text(encoding='utf-8', errors='ignore').splitlines()
+        except OSError:
+            return
+        self.patterns.extend(parse_ignore_lines(lines, base))
+    
+    def is_ignored(self, path: Path, is_dir: bool = False) -> bool:
+        """True if the last matching pattern for the path excludes it"""
+        relative = self._relative(path)
+        ignored = False
+        for pattern in self.patterns:
+            if pattern.matches(relative, is_dir):
+                ignored = not pattern.negated
+        return ignored
+    
+    def _relative(self, path: Path) -> str:
+        try:
+            relative = path.relative_to(self.root)
+        except ValueError:
+            return path.as_posix()
+        return '' if relative == Path('.') else relative.as_posix()
+
+
+# What happens to files over the size cap: 'skip' leaves them out, 'stream' indexes them
+# as plain text read a window at a time (see TextChunker.stream_chunks)
+LARGE_FILE_MODES = ('skip', 'stream')
+
+
+def skip_reason(path: Path, max_bytes: int) -> Optional[str]:
+    """
+    Why a file should not be indexed ('too large' or 'binary'), or None
+    
+    Args:
+        path: File to check
+        max_bytes: Size cap in bytes (0 disables the cap)
+    """
+    try:
+        if max_bytes and path.stat().st_size > max_bytes:
+            return 'too large'
+        with open(path, 'rb') as f:
+            if b'\0' in f.read(BINARY_SNIFF_BYTES):
+                return 'binary'
+    except OSError:
+        return None  # unreadable files are reported when they are processed
+    return None
+
+
+def split_patterns(values: Optional[List[str]]) -> List[str]:
+    """Flatten repeated and comma-separated pattern arguments"""
+    patterns: List[str] = []
+    for value in values or []:
+        patterns.extend(p.strip() for p in value.split(',') if p.strip())
+    return patterns
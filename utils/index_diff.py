@@ -0,0 +1,201 @@
+#!/usr/bin/env python3
+"""
+Symbol-level diff of two index snapshots
+Chunk ids come from stable symbol ids (path:QualifiedName, see assign_symbol_ids),
+so the same symbol has the same id in snapshots of two commits. Symbols only in
+the new snapshot were added, only in the old one removed; symbols in both whose
+body hashes differ were modified. Line moves alone are not changes, and a
+renamed symbol shows as removed and added. Types that list their members
+(interface methods, class methods) also report the members gained and lost:
+
+    ~ interface  Store              +Close
+    + function   ParseConfig
+    - method     Session.Refresh
+"""
+
+import fnmatch
+from collections import defaultdict
+from typing import Dict, List, Optional
+
+from chunkers.base_chunker import parse_metadata, qualified_name, repo_filepath
+from utils.chunk_dedup import content_hash, parse_duplicates
+from utils.index_snapshot import read_chunks, read_manifest
+
+
+# Change statuses, in the order they are listed within a file
+STATUSES = ('modified', 'added', 'removed')
+
+# Marker of each status in text output
+STATUS_MARKERS = {'added': '+', 'removed': '-', 'modified': '~'}
+
+
+def snapshot_symbols(path: str, ignore_formatting: bool = False) -> Dict[str, Dict]:
+    """
+    Symbols of a snapshot by symbol id: location, signature, members and body hash
+    
+    The parts of a split symbol are joined back into one body, and the chunks a
+    deduplicated index lists as duplicates of a stored one count as symbols of
+    their own files.
+    
+    Args:
+        path: Snapshot directory
+        ignore_formatting: Hash bodies without comments and whitespace runs, so
+            reformatting a symbol does not modify it
+    """
+    parts = defaultdict(list)
+    for chunk_id, document, metadata in read_chunks(path):
+        for entry_id, body, entry in [(chunk_id, document, metadata)] + [
+                (duplicate.get('id', ''), duplicate.get('content', document), duplicate)
+                for duplicate in parse_duplicates(metadata)]:
+            symbol_id = entry.get('symbol_id') or entry_id.split('#', 1)[0]
+            parts[symbol_id].append((int(entry.get('part_index') or 0), body, entry))
+    
+    mode = 'normalized' if ignore_formatting else 'exact'
+    symbols = {}
+    for symbol_id, pieces in parts.items():
+        pieces.sort(key=lambda piece: piece[0])
+        first, last = pieces[0][2], pieces[-1][2]
+        body = '\n'.join(body for _, body, _ in pieces)
+        symbols[symbol_id] = {
+            'symbol_id': symbol_id,
+            'name': qualified_name(first),
+            'type': first.get('type', ''),
+            'filepath': first.get('filepath', ''),
+            'repo': first.get('repo', ''),
+            'language': first.get('language', ''),
+            'line_start': first.get('line_start'),
+            'line_end': last.get('line_end'),
+            'signature': first.get('signature', ''),
+            'members': member_names(first),
+            'hash': content_hash(body, first.get('language', ''), mode),
+        }
+    return symbols
+
+
+def member_names(metadata: Dict) -> List[str]:
+    """Names of the members a type chunk lists in its metadata (methods), in order"""
+    names = []
+    for member in parse_metadata(metadata.get('metadata')).get('methods') or []:
+        name = member.get('name') if isinstance(member, dict) else member
+        if isinstance(name, str) and name and name not in names:
+            names.append(name)
+    return names
+
+
+class DiffIndex:
+    """Symbols added, removed and modified from one index snapshot to another"""
+    
+    def __init__(self, old: str, new: str, ignore_formatting: bool = False,
+                 paths: Optional[List[str]] = None):
+        """
+        Args:
+            old: Snapshot directory of the earlier index
+            new: Snapshot directory of the later index
+            ignore_formatting: Changes to comments and whitespace alone do not modify a symbol
+            paths: Only symbols of files matching one of these globs (fnmatch, against
+                the repo-qualified path)
+        
+        Raises:
+            SnapshotError: If either directory is not a readable snapshot
+        """
+        self.old_manifest = read_manifest(old)
+        self.new_manifest = read_manifest(new)
+        before = snapshot_symbols(old, ignore_formatting)
+        after = snapshot_symbols(new, ignore_formatting)
+        if paths:
+            before = {key: symbol for key, symbol in before.items() if _matches(symbol, paths)}
+            after = {key: symbol for key, symbol in after.items() if _matches(symbol, paths)}
+        
+        self.changes: List[Dict] = []
+        for symbol_id in sorted(after.keys() - before.keys()):
+            self.changes.append(dict(after[symbol_id], status='added'))
+        for symbol_id in sorted(before.keys() - after.keys()):
+            self.changes.append(dict(before[symbol_id], status='removed'))
+        for symbol_id in sorted(before.keys() & after.keys()):
+            old_symbol, new_symbol = before[symbol_id], after[symbol_id]
+            if old_symbol['hash'] != new_symbol['hash']:
+                self.changes.append(_modification(old_symbol, new_symbol))
+        self.changes.sort(key=_order)
+    
+    @property
+    def added(self) -> List[Dict]:
+        return [change for change in self.changes if change['status'] == 'added']
+    
+    @property
+    def removed(self) -> List[Dict]:
+        return [change for change in self.changes if change['status'] == 'removed']
+    
+    @property
+    def modified(self) -> List[Dict]:
+        return [change for change in self.changes if change['status'] == 'modified']
+    
+    def by_file(self) -> Dict[str, List[Dict]]:
+        """Changes grouped by repo-qualified file path, files and symbols in order"""
+        files: Dict[str, List[Dict]] = {}
+        for change in self.changes:
+            files.setdefault(_location(change), []).append(change)
+        return files
+    
+    def summary(self) -> Dict[str, int]:
+        """Number of changes by status, and of files with any"""
+        counts = {status: 0 for status in STATUSES}
+        for change in self.changes:
+            counts[change['status']] += 1
+        counts['files'] = len({_location(change) for change in self.changes})
+        return counts
+    
+    def to_dict(self) -> Dict:
+        """The whole diff as JSON-ready data: the snapshots compared, counts and changes by file"""
+        return {
+            'old': _describe(self.old_manifest),
+            'new': _describe(self.new_manifest),
+            'summary': self.summary(),
+            'files': [{'filepath': location, 'changes': [_public(change) for change in changes]}
+                      for location, changes in self.by_file().items()],
+        }
+
+
+def describe_change(change: Dict) -> str:
+    """One line for a change: marker, symbol type and name, and what happened to its members"""
+    line = f"{STATUS_MARKERS[change['status']]} {change['type']:<10} {change['name']}"
+    details = [f"+{name}" for name in change.get('members_added', [])]
+    details += [f"-{name}" for name in change.get('members_removed', [])]
+    if change.get('old_signature') is not None:
+        details.append("signature changed")
+    return f"{line}  {', '.join(details)}" if details else line
+
+
+def _modification(old: Dict, new: Dict) -> Dict:
+    """A modified symbol: its new state, with what changed against the old one"""
+    change = dict(new, status='modified', old_line_start=old['line_start'])
+    if old['signature'] != new['signature']:
+        change['old_signature'] = old['signature']
+    gained = [name for name in new['members'] if name not in old['members']]
+    lost = [name for name in old['members'] if name not in new['members']]
+    if gained:
+        change['members_added'] = gained
+    if lost:
+        change['members_removed'] = lost
+    return change
+
+
+def _order(change: Dict):
+    return (_location(change), STATUSES.index(change['status']), change['line_start'] or 0, change['name'])
+
+
+def _location(symbol: Dict) -> str:
+    return repo_filepath(symbol['repo'] or None, symbol['filepath'])
+
+
+def _matches(symbol: Dict, patterns: List[str]) -> bool:
+    return any(fnmatch.fnmatch(_location(symbol), pattern) for pattern in patterns)
+
+
+def _public(change: Dict) -> Dict:
+    """A change as reported: without the body hash and the member lists the summary covers"""
+    return {key: value for key, value in change.items() if key not in ('hash', 'members')}
+
+
+def _describe(manifest: Dict) -> Dict:
+    """What identifies a snapshot in a diff report"""
+    return {key: manifest.get(key) for key in ('collection', 'created', 'count', 'embedding_model')}
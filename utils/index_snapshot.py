@@ -0,0 +1,296 @@
+#!/usr/bin/env python3
+"""
+On-disk index snapshots
+A snapshot directory holds everything needed to restore an index without
+re-parsing or re-embedding:
+
+    manifest.json   embedding model, dimension, distance metric, chunk count, format
+                    version, and the SHA-256 of the two data files
+    vectors.f32     all vectors, little-endian float32, row-major
+    chunks.jsonl    one line per chunk: id, document and metadata
+    signatures/     the signature vectors of a dual index, as a nested snapshot
+    models/<name>/  the vectors of each extra embedding model, as nested snapshots
+                    (the manifest lists them under 'embedding_models', with their
+                    model and dimension)
+
+A snapshot is written into a directory beside its path and renamed into place
+once every file is on disk (staged_snapshot), so a save that dies part way
+leaves the previous snapshot as it was. Reading checks the manifest, the
+vector blob's length (dimension x count), the number of chunks and the
+checksums before any row is returned.
+"""
+
+import hashlib
+import json
+import os
+import shutil
+import sys
+from array import array
+from contextlib import contextmanager
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import Dict, Iterable, Iterator, List, Optional, Tuple
+
+
+FORMAT_VERSION = 1
+MANIFEST_FILE = 'manifest.json'
+VECTORS_FILE = 'vectors.f32'
+CHUNKS_FILE = 'chunks.jsonl'
+SIGNATURES_DIR = 'signatures'
+MODELS_DIR = 'models'
+
+# Everything a snapshot directory may hold; another directory is never replaced by a save
+SNAPSHOT_ENTRIES = {MANIFEST_FILE, VECTORS_FILE, CHUNKS_FILE, SIGNATURES_DIR, MODELS_DIR}
+
+# Appended to a collection's name for the collection a snapshot is loaded into
+# before it replaces the live one
+LOAD_SUFFIX = '_loading'
+
+
+class SnapshotError(Exception):
+    """Raised for unreadable snapshots or snapshots incompatible with the current embedder"""
+
+
+def write_snapshot(path: str, rows: Iterable[Tuple[str, str, Dict, List[float]]],
+                   model_name: str, dimensions: int, extra: Optional[Dict] = None) -> Dict:
+    """
+    Write a snapshot directory
+    
+    Args:
+        path: Target directory (created if missing)
+        rows: (id, document, metadata, vector) tuples
+        model_name: Embedding model that produced the vectors
+        dimensions: Vector length
+        extra: Optional additional manifest fields
+    
+    Returns:
+        The manifest that was written
+    """
+    target = Path(path)
+    target.mkdir(parents=True, exist_ok=True)
+    count = 0
+    vectors_hash, chunks_hash = hashlib.sha256(), hashlib.sha256()
+    
+    # Binary, so the checksum is of the bytes on disk on every platform
+    with open(target / VECTORS_FILE, 'wb') as vectors_file, open(target / CHUNKS_FILE, 'wb') as chunks_file:
+        for chunk_id, document, metadata, vector in rows:
+            if len(vector) != dimensions:
+                raise SnapshotError(f"Vector for {chunk_id} has {len(vector)} dimensions, expected {dimensions}")
+            values = array('f', vector)
+            if sys.byteorder == 'big':
+                values.byteswap()
+            data = values.tobytes()
+            vectors_file.write(data)
+            vectors_hash.update(data)
+            line = (json.dumps({'id': chunk_id, 'document': document, 'metadata': metadata}) + '\n').encode('utf-8')
+            chunks_file.write(line)
+            chunks_hash.update(line)
+            count += 1
+        _sync(vectors_file)
+        _sync(chunks_file)
+    
+    manifest = {
+        'format_version': FORMAT_VERSION,
+        'embedding_model': model_name,
+        'dimensions': dimensions,
+        'count': count,
+        'created': datetime.now(timezone.utc).isoformat(),
+        'checksums': {VECTORS_FILE: vectors_hash.hexdigest(), CHUNKS_FILE: chunks_hash.hexdigest()},
+    }
+    manifest.update(extra or {})
+    # Manifest last: a directory without one is an incomplete snapshot
+    with open(target / MANIFEST_FILE, 'w', encoding='utf-8') as f:
+        json.dump(manifest, f, indent=2)
+        _sync(f)
+    return manifest
+
+
+@contextmanager
+def staged_snapshot(path: str) -> Iterator[str]:
+    """
+    A directory to write the snapshot for path into
+    
+    It lies beside path and is renamed to it once the block completes, every
+    file synced to disk: the snapshot at path is the previous one or the new
+    one whole, never a partly written one. A block that raises (or a process
+    that dies in it) leaves path as it was.
+    
+    Raises:
+        SnapshotError: If path is a directory holding something other than a snapshot
+    """
+    target = Path(path).resolve()
+    staging, previous = _side_paths(target)
+    recover_snapshot(path)
+    if target.is_dir() and not set(os.listdir(target)) <= SNAPSHOT_ENTRIES:
+        raise SnapshotError(f"{path} holds files that are not part of an index snapshot; "
+                            f"save to an empty or new directory")
+    if target.exists() and not target.is_dir():
+        raise SnapshotError(f"{path} is a file, not a snapshot directory")
+    if staging.exists():
+        shutil.rmtree(staging)  # left by a save that died
+    staging.mkdir(parents=True)
+    try:
+        yield str(staging)
+    except BaseException:
+        shutil.rmtree(staging, ignore_errors=True)
+        raise
+    
+    # Directories cannot be renamed over one another: the old one steps aside first,
+    # and recover_snapshot puts it back if the process dies between the two renames
+    if target.exists():
+        os.replace(target, previous)
+    os.replace(staging, target)
+    _sync_directory(target.parent)
+    shutil.rmtree(previous, ignore_errors=True)
+
+
+def recover_snapshot(path: str):
+    """
+    Finish a save that died while swapping directories: the previous snapshot
+    goes back to path if the new one never arrived, and is removed if it did
+    """
+    target = Path(path).resolve()
+    _, previous = _side_paths(target)
+    if not previous.exists():
+        return
+    try:
+        if target.exists():
+            shutil.rmtree(previous)
+        else:
+            os.replace(previous, target)
+    except OSError:
+        pass  # read-only location: read_manifest reports the snapshot missing
+
+
+def read_manifest(path: str) -> Dict:
+    """Read and sanity-check a snapshot manifest"""
+    recover_snapshot(path)
+    manifest_path = Path(path) / MANIFEST_FILE
+    if not manifest_path.exists():
+        raise SnapshotError(f"No index snapshot at {path} (missing {MANIFEST_FILE})")
+    try:
+        with open(manifest_path, 'r', encoding='utf-8') as f:
+            manifest = json.load(f)
+    except (OSError, UnicodeDecodeError, ValueError) as e:
+        raise SnapshotError(f"{manifest_path} is unreadable or corrupt: {e}") from e
+    if not isinstance(manifest, dict):
+        raise SnapshotError(f"{manifest_path} is corrupt: expected an object")
+    if manifest.get('format_version') != FORMAT_VERSION:
+        raise SnapshotError(f"Unsupported snapshot format version: {manifest.get('format_version')}")
+    for key, minimum in (('dimensions', 1), ('count', 0)):
+        value = manifest.get(key)
+        if not isinstance(value, int) or isinstance(value, bool) or value < minimum:
+            raise SnapshotError(f"{manifest_path} is corrupt: '{key}' is {value!r}")
+    if not isinstance(manifest.get('embedding_model'), str):
+        raise SnapshotError(f"{manifest_path} is corrupt: no 'embedding_model'")
+    return manifest
+
+
+def read_snapshot(path: str) -> Iterator[Tuple[str, str, Dict, List[float]]]:
+    """
+    Iterate over the (id, document, metadata, vector) rows of a snapshot
+    Raises SnapshotError if the vector blob or the chunks do not match the manifest
+    """
+    manifest = read_manifest(path)
+    dimensions = int(manifest['dimensions'])
+    vectors_path = Path(path) / VECTORS_FILE
+    
+    # Checked eagerly (this is not a generator) so callers can fail before touching their data
+    expected_size = manifest['count'] * dimensions * array('f').itemsize
+    if not vectors_path.exists() or os.path.getsize(vectors_path) != expected_size:
+        raise SnapshotError(f"{vectors_path} is missing, truncated or corrupt "
+                            f"(expected {manifest['count']} vectors of {dimensions} dimensions)")
+    _verify_file(path, manifest, VECTORS_FILE)
+    _verify_chunks(path, manifest)
+    
+    return _iter_rows(path, dimensions)
+
+
+def read_chunks(path: str) -> Iterator[Tuple[str, str, Dict]]:
+    """Iterate over the (id, document, metadata) rows of a snapshot, without its vectors"""
+    manifest = read_manifest(path)
+    _verify_chunks(path, manifest)
+    return _iter_chunks(path)
+
+
+def _verify_chunks(path: str, manifest: Dict):
+    """Raise unless the chunks file holds the manifest's chunk count and checksum"""
+    chunks_path = Path(path) / CHUNKS_FILE
+    if not chunks_path.exists():
+        raise SnapshotError(f"{chunks_path} is missing")
+    with open(chunks_path, 'rb') as f:
+        lines = sum(1 for _ in f)
+    if lines != manifest['count']:
+        raise SnapshotError(f"{chunks_path} holds {lines} chunks, but the manifest records {manifest['count']}")
+    _verify_file(path, manifest, CHUNKS_FILE)
+
+
+def _verify_file(path: str, manifest: Dict, name: str):
+    """Raise if a data file does not match its recorded checksum (snapshots from before them are not checked)"""
+    expected = (manifest.get('checksums') or {}).get(name)
+    if not expected:
+        return
+    digest = hashlib.sha256()
+    with open(Path(path) / name, 'rb') as f:
+        for block in iter(lambda: f.read(1 << 20), b''):
+            digest.update(block)
+    if digest.hexdigest() != expected:
+        raise SnapshotError(f"{Path(path) / name} does not match its checksum: the snapshot is corrupt")
+
+
+def _parse_row(chunks_path: Path, number: int, line: str) -> Dict:
+    """A chunks.jsonl record; a corrupt one is reported with its line number"""
+    try:
+        row = json.loads(line)
+    except ValueError as e:
+        raise SnapshotError(f"{chunks_path}:{number}: corrupt chunk record ({e})") from e
+    if not isinstance(row, dict) or not {'id', 'document', 'metadata'} <= row.keys():
+        raise SnapshotError(f"{chunks_path}:{number}: corrupt chunk record")
+    return row
+
+
+def _iter_chunks(path: str) -> Iterator[Tuple[str, str, Dict]]:
+    chunks_path = Path(path) / CHUNKS_FILE
+    with open(chunks_path, 'r', encoding='utf-8') as chunks_file:
+        for number, line in enumerate(chunks_file, 1):
+            row = _parse_row(chunks_path, number, line)
+            yield row['id'], row['document'], row['metadata']
+
+
+def _iter_rows(path: str, dimensions: int) -> Iterator[Tuple[str, str, Dict, List[float]]]:
+    """Stream rows from a validated snapshot"""
+    vectors_path = Path(path) / VECTORS_FILE
+    chunks_path = Path(path) / CHUNKS_FILE
+    with open(vectors_path, 'rb') as vectors_file, open(chunks_path, 'r', encoding='utf-8') as chunks_file:
+        for number, line in enumerate(chunks_file, 1):
+            row = _parse_row(chunks_path, number, line)
+            values = array('f')
+            values.fromfile(vectors_file, dimensions)
+            if sys.byteorder == 'big':
+                values.byteswap()
+            yield row['id'], row['document'], row['metadata'], values.tolist()
+
+
+def _side_paths(target: Path) -> Tuple[Path, Path]:
+    """Beside a snapshot directory: the one a save writes, and the one it replaces while swapping"""
+    return target.parent / f".{target.name}.saving", target.parent / f".{target.name}.previous"
+
+
+def _sync(f):
+    """Flush a file to disk"""
+    f.flush()
+    os.fsync(f.fileno())
+
+
+def _sync_directory(path: Path):
+    """Flush a directory's entries (its renames) to disk where the platform allows it"""
+    try:
+        fd = os.open(path, os.O_RDONLY)
+    except OSError:
+        return
+    try:
+        os.fsync(fd)
+    except OSError:
+        pass  # Windows cannot sync directories
+    finally:
+        os.close(fd)
@@ -0,0 +1,87 @@
+#!/usr/bin/env python3
+"""
+What an index holds, from its chunks' metadata
+Counts the stored chunks by type, kind, language and repository, and lists
+the indexed files with their chunk counts, for the stats and files commands,
+GET /stats and GET /files. Only the metadata the store already keeps is read
+(no vectors, no code), so "why isn't my file showing up" takes one pass over it.
+In a deduplicated index a body stored once for several places counts once,
+and its other places still count as chunks of their own files.
+"""
+
+from collections import Counter, defaultdict
+from fnmatch import fnmatch
+from typing import Dict, Iterable, List, Optional
+
+from chunkers.base_chunker import repo_filepath
+from utils.chunk_dedup import parse_duplicates
+
+
+def count_chunks(metadatas: Iterable[Dict]) -> Dict:
+    """
+    Chunk totals of an index
+    
+    Returns:
+        {'total_chunks', 'unique_files', 'duplicate_chunks' (places a stored body also
+        appears, not stored again), 'chunks_by_type', 'chunks_by_kind',
+        'chunks_by_language', 'chunks_by_repo' (labeled repositories only)}
+    """
+    by_type, by_kind, by_language, by_repo = Counter(), Counter(), Counter(), Counter()
+    files = set()
+    total = duplicates = 0
+    for metadata in metadatas:
+        total += 1
+        by_type[metadata.get('type', 'unknown')] += 1
+        by_kind[metadata.get('kind', 'source')] += 1
+        by_language[metadata.get('language', 'unknown')] += 1
+        if metadata.get('repo'):
+            by_repo[metadata['repo']] += 1
+        files.add(repo_filepath(metadata.get('repo') or None, metadata.get('filepath', '')))
+        for entry in parse_duplicates(metadata):
+            duplicates += 1
+            files.add(repo_filepath(entry.get('repo') or None, entry.get('filepath', '')))
+    return {
+        'total_chunks': total,
+        'unique_files': len(files),
+        'duplicate_chunks': duplicates,
+        'chunks_by_type': dict(by_type),
+        'chunks_by_kind': dict(by_kind),
+        'chunks_by_language': dict(by_language),
+        'chunks_by_repo': dict(by_repo),
+    }
+
+
+def indexed_files(metadatas: Iterable[Dict], repo: Optional[str] = None,
+                  path_glob: Optional[str] = None) -> List[Dict]:
+    """
+    The indexed files, sorted by location
+    
+    Args:
+        metadatas: Stored metadata of every chunk
+        repo: Only files of this repository label
+        path_glob: Only files whose path under their root matches this glob ('internal/*')
+    
+    Returns:
+        One entry per file: 'location' (see repo_filepath), 'filepath', 'repo' (None
+        for an unlabeled root), 'language', 'chunks' (every chunk of the file, its parts
+        counted one by one) and 'duplicates' (those of them stored once elsewhere)
+    """
+    files = {}
+    counts = defaultdict(lambda: [0, 0])
+    
+    def add(metadata: Dict, duplicate: bool):
+        label, filepath = metadata.get('repo') or None, metadata.get('filepath', '')
+        if (repo and label != repo) or (path_glob and not fnmatch(filepath, path_glob)):
+            return
+        location = repo_filepath(label, filepath)
+        files.setdefault(location, {'location': location, 'filepath': filepath, 'repo': label,
+                                    'language': metadata.get('language', 'unknown')})
+        counts[location][0] += 1
+        counts[location][1] += duplicate
+    
+    for metadata in metadatas:
+        add(metadata, False)
+        for entry in parse_duplicates(metadata):
+            add(entry, True)
+    return [dict(files[location], chunks=counts[location][0], duplicates=counts[location][1])
+            for location in sorted(files)]
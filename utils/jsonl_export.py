@@ -0,0 +1,97 @@
+#!/usr/bin/env python3
+"""
+JSONL export of an index for external tools
+One self-contained JSON object per chunk, in a documented schema that does not
+follow the internal metadata layout, so notebooks and other vector databases
+can load the corpus directly. Unlike a snapshot it is not meant to be loaded back.
+
+Every record carries schema_version; fields are only ever added within a version:
+
+    schema_version  EXPORT_SCHEMA_VERSION
+    id              chunk id in the index
+    symbol_id       shared by all parts of a split symbol (part_index of part_count)
+    filepath        path relative to the indexed root
+    repo            label of the repository in a multi-repo index, '' if none
+    language        language of the file
+    kind            symbol kind (function, method, type, interface, const, var, or the chunk type)
+    chunk_type      type the chunker emitted (struct, class, interface_method, ...)
+    test            true for chunks of test files (kind test, benchmark, fuzz or example)
+    name            symbol name; qualified_name includes the enclosing symbol
+    namespace       package or namespace, '' if none
+    signature       declaration signature, '' if none
+    doc             doc comment, '' if none
+    body            the code of the chunk
+    line_start      first line, 1-based
+    line_end        last line, inclusive
+    byte_start      UTF-8 offset of the body in the file, or null
+    byte_end        exclusive end offset, or null
+    metadata        language-specific details (calls, implements, fields, ...)
+    embedding       the vector, only when requested (with embedding_model)
+"""
+
+import json
+from typing import Dict, Iterable, List, Optional, TextIO
+
+from chunkers.base_chunker import parse_metadata, qualified_name
+from chunkers.go_tests import TEST_KINDS
+
+
+EXPORT_SCHEMA_VERSION = 1
+
+
+def export_record(chunk_id: str, document: str, stored: Dict, kind: str,
+                  embedding: Optional[List[float]] = None, embedding_model: Optional[str] = None) -> Dict:
+    """
+    The export record of one stored chunk
+    
+    Args:
+        chunk_id: Id of the chunk in the store
+        document: Stored document (the chunk's code)
+        stored: Stored metadata (CodeChunk.to_dict)
+        kind: Symbol kind of the chunk type
+        embedding: Vector to include, if any
+        embedding_model: Model the vector came from
+    """
+    record = {
+        'schema_version': EXPORT_SCHEMA_VERSION,
+        'id': chunk_id,
+        'symbol_id': stored.get('symbol_id', ''),
+        'part_index': stored.get('part_index', 0),
+        'part_count': stored.get('part_count', 1),
+        'filepath': stored.get('filepath', ''),
+        'repo': stored.get('repo', ''),
+        'language': stored.get('language', ''),
+        'kind': kind,
+        'chunk_type': stored.get('type', ''),
+        'test': stored.get('kind') in TEST_KINDS,
+        'name': stored.get('name', ''),
+        'qualified_name': qualified_name(stored),
+        'namespace': stored.get('namespace', ''),
+        'signature': stored.get('signature', ''),
+        'doc': stored.get('doc', ''),
+        'body': document,
+        'line_start': stored.get('line_start'),
+        'line_end': stored.get('line_end'),
+        'byte_start': stored.get('byte_start'),
+        'byte_end': stored.get('byte_end'),
+        'metadata': parse_metadata(stored.get('metadata')),
+    }
+    if embedding is not None:
+        record['embedding'] = [float(x) for x in embedding]
+        record['embedding_model'] = embedding_model
+    return record
+
+
+def write_jsonl(writer: TextIO, records: Iterable[Dict]) -> int:
+    """
+    Write records as JSON lines
+    
+    Returns:
+        Number of records written
+    """
+    count = 0
+    for record in records:
+        writer.write(json.dumps(record, ensure_ascii=False, default=str))
+        writer.write('\n')
+        count += 1
+    return count
@@ -1,47 +1,122 @@
 #!/usr/bin/env python3
 """
 Professional logging system with colored output and progress tracking
+
+Log records go to stderr, so the results a command prints on stdout can be piped
+cleanly. Structured fields are passed as the record's extra
+(logger.info("Parsed", extra={'file': path, 'phase': 'parsing', 'chunks': 12})):
+the text format appends them as key=value, the json format writes one object per
+line with them alongside the time, level and message.
 """
 
+import json
 import logging
 import sys
+from datetime import datetime, timezone
 from pathlib import Path
-from typing import Optional
+from typing import Dict, Optional
 from rich.console import Console
 from rich.logging import RichHandler
+from rich.markup import escape
 from rich.progress import Progress, SpinnerColumn, TextColumn, BarColumn, TaskProgressColumn, TimeRemainingColumn
 
 
-# Global console for rich output
+# Global console for rich output (command results)
 console = Console()
 
+# Console the log handler writes to, kept off stdout
+log_console = Console(stderr=True)
+
+# Formats of the log handler: rich text lines or JSON objects
+LOG_FORMATS = ('text', 'json')
+
+# Attributes every LogRecord has; anything else on a record came in through extra
+_RECORD_ATTRIBUTES = set(vars(logging.LogRecord('', 0, '', 0, '', None, None))) | {'message', 'asctime'}
+
+
+def record_fields(record: logging.LogRecord) -> Dict:
+    """Structured fields of a record: the keys it was given through extra"""
+    return {key: value for key, value in vars(record).items()
+            if key not in _RECORD_ATTRIBUTES and not key.startswith('_')}
+
+
+class TextFormatter(logging.Formatter):
+    """The message followed by its fields as key=value"""
+    
+    def __init__(self, fmt: Optional[str] = None, markup: bool = False):
+        """
+        Args:
+            fmt: Format of the message part (default: the message alone)
+            markup: Escape the fields for a handler that renders rich markup
+        """
+        super().__init__(fmt)
+        self.markup = markup
+    
+    def format(self, record: logging.LogRecord) -> str:
+        message = super().format(record)
+        fields = ''.join(f" {key}={_text_value(value)}" for key, value in record_fields(record).items())
+        return message + (escape(fields) if self.markup else fields)
 
-def setup_logger(name: str = "chrome_rag", log_file: Optional[str] = None, level: str = "INFO") -> logging.Logger:
+
+class JsonFormatter(logging.Formatter):
+    """One JSON object per record: time, level, logger, message, then its fields"""
+    
+    def format(self, record: logging.LogRecord) -> str:
+        entry = {
+            'time': datetime.fromtimestamp(record.created, timezone.utc).isoformat(timespec='milliseconds'),
+            'level': record.levelname,
+            'logger': record.name,
+            'message': record.getMessage(),
+        }
+        entry.update(record_fields(record))
+        if record.exc_info:
+            entry['exception'] = self.formatException(record.exc_info)
+        return json.dumps(entry, ensure_ascii=False, default=str)
+
+
+def _text_value(value) -> str:
+    """A field value as it reads in a text line: scalars as is, collections as JSON"""
+    if isinstance(value, (dict, list, tuple)):
+        return json.dumps(value, ensure_ascii=False, default=str)
+    if isinstance(value, float):
+        return f"{value:.3f}"
+    return str(value)
+
+
+def setup_logger(name: str = "chrome_rag", log_file: Optional[str] = None, level: str = "INFO",
+                 log_format: str = "text") -> logging.Logger:
     """
-    Setup a logger with rich console output and optional file logging
+    Setup a logger writing to stderr, and optionally to a file
     
     Args:
         name: Logger name
-        log_file: Optional file path for logging
+        log_file: Optional file path for logging (every level, in the same format)
         level: Logging level (DEBUG, INFO, WARNING, ERROR)
+        log_format: 'text' (colored lines) or 'json' (one object per line)
     
     Returns:
         Configured logger
     """
+    if log_format not in LOG_FORMATS:
+        raise ValueError(f"Unknown log format '{log_format}' (expected one of: {', '.join(LOG_FORMATS)})")
     logger = logging.getLogger(name)
     logger.setLevel(getattr(logging, level.upper()))
     
     # Remove existing handlers
     logger.handlers.clear()
     
-    # Rich console handler
-    console_handler = RichHandler(
-        console=console,
-        show_time=True,
-        show_path=False,
-        markup=True,
-        rich_tracebacks=True
-    )
+    if log_format == 'json':
+        console_handler = logging.StreamHandler(sys.stderr)
+        console_handler.setFormatter(JsonFormatter())
+    else:
+        console_handler = RichHandler(
+            console=log_console,
+            show_time=True,
+            show_path=False,
+            markup=True,
+            rich_tracebacks=True
+        )
+        console_handler.setFormatter(TextFormatter(markup=True))
     console_handler.setLevel(getattr(logging, level.upper()))
     logger.addHandler(console_handler)
     
@@ -52,10 +127,10 @@ def setup_logger(name: str = "chrome_rag", log_file: Optional[str] = None, level
         
         file_handler = logging.FileHandler(log_file)
         file_handler.setLevel(logging.DEBUG)
-        file_formatter = logging.Formatter(
-            '%(asctime)s - %(name)s - %(levelname)s - %(message)s'
-        )
-        file_handler.setFormatter(file_formatter)
+        if log_format == 'json':
+            file_handler.setFormatter(JsonFormatter())
+        else:
+            file_handler.setFormatter(TextFormatter('%(asctime)s - %(name)s - %(levelname)s - %(message)s'))
         logger.addHandler(file_handler)
     
     return logger
@@ -70,52 +145,53 @@ def get_logger(name: str = "chrome_rag") -> logging.Logger:
 
 
 def create_progress_bar() -> Progress:
-    """Create a rich progress bar for tracking long operations"""
+    """Create a rich progress bar for tracking long operations (on stderr, with the logs)"""
     return Progress(
         SpinnerColumn(),
         TextColumn("[progress.description]{task.description}"),
         BarColumn(),
         TaskProgressColumn(),
         TimeRemainingColumn(),
-        console=console
+        console=log_console
     )
 
 
 def print_success(message: str):
-    """Print a success message"""
-    console.print(f"[green]✓[/green] {message}")
+    """Print a success message (on stderr, with the logs)"""
+    log_console.print(f"[green]✓[/green] {message}")
 
 
 def print_error(message: str):
-    """Print an error message"""
-    console.print(f"[red]✗[/red] {message}")
+    """Print an error message (on stderr, with the logs)"""
+    log_console.print(f"[red]✗[/red] {message}")
 
 
 def print_warning(message: str):
-    """Print a warning message"""
-    console.print(f"[yellow]⚠[/yellow] {message}")
+    """Print a warning message (on stderr, with the logs)"""
+    log_console.print(f"[yellow]⚠[/yellow] {message}")
 
 
 def print_info(message: str):
-    """Print an info message"""
-    console.print(f"[blue]ℹ[/blue] {message}")
+    """Print an info message (on stderr, with the logs)"""
+    log_console.print(f"[blue]ℹ[/blue] {message}")
 
 
-def print_header(message: str):
-    """Print a header message"""
-    console.print(f"\n[bold cyan]{message}[/bold cyan]")
-    console.print("[cyan]" + "=" * len(message) + "[/cyan]\n")
+def print_header(message: str, stderr: bool = False):
+    """Print a header message (on stderr when stdout carries machine-readable output)"""
+    out = log_console if stderr else console
+    out.print(f"\n[bold cyan]{message}[/bold cyan]")
+    out.print("[cyan]" + "=" * len(message) + "[/cyan]\n")
 
 
-def print_stats(stats: dict):
-    """Print statistics in a formatted table"""
+def print_stats(stats: dict, title: str = "Indexing Statistics", stderr: bool = False):
+    """Print statistics in a formatted table (on stderr when stdout carries machine-readable output)"""
     from rich.table import Table
     
-    table = Table(title="Indexing Statistics", show_header=True, header_style="bold magenta")
+    table = Table(title=title, show_header=True, header_style="bold magenta")
     table.add_column("Metric", style="cyan", no_wrap=True)
     table.add_column("Value", style="green")
     
     for key, value in stats.items():
         table.add_row(key, str(value))
     
-    console.print(table)
+    (log_console if stderr else console).print(table)
@@ -0,0 +1,60 @@
+#!/usr/bin/env python3
+"""
+Where the query's terms appear in a search result
+A result the keyword index matched gets 'highlights': spans of its content
+holding a query term, found the way the index tokenizes code (whole
+identifiers, or their camelCase/snake_case parts), so a UI can emphasize
+them. Results only the vector search found have no literal match to show.
+"""
+
+import re
+from typing import Dict, Iterable, List, Set, Tuple
+
+from utils.code_tokenizer import PART_PATTERN, WORD_PATTERN
+
+
+# Spans listed per result, first ones first
+MAX_HIGHLIGHTS = 100
+
+
+def highlight_spans(content: str, terms: Iterable[str], limit: int = MAX_HIGHLIGHTS) -> List[Dict]:
+    """
+    Spans of content that match one of terms
+    
+    Args:
+        content: A result's code
+        terms: Lowercase query terms, as tokenize_code gives them (expansion terms included)
+        limit: Most spans returned
+    
+    Returns:
+        [{'start', 'end', 'line', 'term'}]: character offsets into content (end
+        exclusive), the line of content the span is on (from 1) and the term it
+        matched, in order of appearance. An identifier matching as a whole is one
+        span; otherwise each of its parts that matches is one.
+    """
+    terms = set(terms)
+    spans = []
+    if not terms:
+        return spans
+    for word in WORD_PATTERN.finditer(content):
+        if word.group().lower() in terms:
+            matches = [(word.start(), word.end())]
+        else:
+            matches = _part_matches(word, terms)
+        for start, end in matches:
+            spans.append({'start': start, 'end': end, 'line': content.count('\n', 0, start) + 1,
+                          'term': content[start:end].lower()})
+            if len(spans) >= limit:
+                return spans
+    return spans
+
+
+def _part_matches(word: re.Match, terms: Set[str]) -> List[Tuple[int, int]]:
+    """Offsets of the parts of an identifier that are terms (none for a single-part identifier)"""
+    parts = [(piece.start() + part.start(), piece.start() + part.end())
+             for piece in re.finditer(r'[^_$]+', word.group())
+             for part in PART_PATTERN.finditer(piece.group())]
+    if len(parts) < 2:
+        return []
+    text = word.group()
+    return [(word.start() + start, word.start() + end) for start, end in parts if text[start:end].lower() in terms]
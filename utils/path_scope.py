@@ -0,0 +1,76 @@
+#!/usr/bin/env python3
+"""
+Search scopes: directory subtrees of the indexed root
+A scope such as 'internal/auth' covers the files under that directory (and a
+file of that exact path), but not 'internal/authz'. Scopes resolve against the
+sorted list of indexed paths by binary search, so a scope costs the files it
+covers rather than a scan of the index, and the vector store then searches
+only the chunks of those files.
+"""
+
+from bisect import bisect_left
+from typing import Iterable, List, Optional, Set, Union
+
+
+def normalize_scope(scope: str) -> str:
+    """
+    A scope as a path relative to the indexed root, without './' or trailing
+    '/' ('' for the whole index)
+    
+    Raises:
+        ValueError: For absolute paths or paths leaving the root
+    """
+    if not isinstance(scope, str):
+        raise ValueError(f"Scope must be a path, got {scope!r}")
+    path = scope.strip().replace('\\', '/')
+    if path.startswith('/'):
+        raise ValueError(f"Scope must be a path relative to the indexed root, got '{scope}'")
+    parts = [part for part in path.split('/') if part not in ('', '.')]
+    if '..' in parts:
+        raise ValueError(f"Scope must stay inside the indexed root, got '{scope}'")
+    return '/'.join(parts)
+
+
+def normalize_scopes(scope: Union[str, Iterable[str], None]) -> List[str]:
+    """Scopes given as one path or several, normalized; [] if none restricts the search"""
+    if scope is None:
+        return []
+    scopes = [normalize_scope(scope)] if isinstance(scope, str) else [normalize_scope(s) for s in scope]
+    # The whole index covers every other scope
+    return [] if '' in scopes else sorted(set(scopes))
+
+
+def paths_in_scope(paths: List[str], scope: str) -> List[str]:
+    """
+    The paths of a sorted list that lie in a normalized scope
+    
+    Args:
+        paths: Indexed file paths, sorted and unique
+        scope: Output of normalize_scope
+    """
+    if not scope:
+        return list(paths)
+    found = []
+    # The file itself, if the scope names one
+    index = bisect_left(paths, scope)
+    if index < len(paths) and paths[index] == scope:
+        found.append(scope)
+    # Everything under the directory sorts together, after 'scope/'
+    prefix = scope + '/'
+    index = bisect_left(paths, prefix)
+    while index < len(paths) and paths[index].startswith(prefix):
+        found.append(paths[index])
+        index += 1
+    return found
+
+
+def resolve_scopes(paths: List[str], scopes: List[str]) -> Optional[Set[str]]:
+    """The paths in any of the scopes (None when scopes is empty: no restriction)"""
+    if not scopes:
+        return None
+    return {path for scope in scopes for path in paths_in_scope(paths, scope)}
+
+
+def in_scope(path: str, scopes: List[str]) -> bool:
+    """True if a file path lies in one of the normalized scopes (or there are none)"""
+    return not scopes or any(not scope or path == scope or path.startswith(scope + '/') for scope in scopes)
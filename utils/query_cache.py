@@ -0,0 +1,98 @@
+#!/usr/bin/env python3
+"""
+LRU cache of ranked search results
+Entries are keyed by the normalized query, the result count, the search options
+and the index version, so a change to the index never serves stale results:
+the version moves on and the old entries are never looked up again.
+"""
+
+import copy
+import threading
+import time
+from collections import OrderedDict
+from typing import Dict, Hashable, List, Optional, Tuple
+
+
+def normalize_query(query: str) -> str:
+    """Queries that differ only in case and spacing share a cache entry"""
+    return " ".join(query.lower().split())
+
+
+def freeze(value) -> Hashable:
+    """A search option as a hashable key part (lists become tuples)"""
+    if isinstance(value, (list, tuple)):
+        return tuple(freeze(v) for v in value)
+    if isinstance(value, dict):
+        return tuple(sorted((k, freeze(v)) for k, v in value.items()))
+    return value
+
+
+class QueryCache:
+    """Thread-safe LRU of result lists with a time to live"""
+    
+    def __init__(self, max_entries: int = 256, ttl: float = 300.0):
+        """
+        Args:
+            max_entries: Most result sets kept (0 disables the cache)
+            ttl: Seconds an entry stays valid (0 = until evicted or the index changes)
+        """
+        self.max_entries = max(0, max_entries)
+        self.ttl = max(0.0, ttl)
+        self._entries: 'OrderedDict[Hashable, Tuple[float, List[Dict]]]' = OrderedDict()
+        self._lock = threading.Lock()
+        self.hits = 0
+        self.misses = 0
+        self.evictions = 0
+        self.expirations = 0
+    
+    @property
+    def enabled(self) -> bool:
+        return self.max_entries > 0
+    
+    def get(self, key: Hashable) -> Optional[List[Dict]]:
+        """Copy of the cached results, or None on a miss (counted either way)"""
+        with self._lock:
+            entry = self._entries.get(key)
+            if entry is not None and self.ttl and time.monotonic() - entry[0] > self.ttl:
+                del self._entries[key]
+                self.expirations += 1
+                entry = None
+            if entry is None:
+                self.misses += 1
+                return None
+            self._entries.move_to_end(key)
+            self.hits += 1
+            results = entry[1]
+        return copy.deepcopy(results)
+    
+    def put(self, key: Hashable, results: List[Dict]):
+        """Store a copy of a result list, evicting the least recently used entries"""
+        if not self.enabled:
+            return
+        stored = copy.deepcopy(results)
+        with self._lock:
+            self._entries[key] = (time.monotonic(), stored)
+            self._entries.move_to_end(key)
+            while len(self._entries) > self.max_entries:
+                self._entries.popitem(last=False)
+                self.evictions += 1
+    
+    def clear(self):
+        with self._lock:
+            self._entries.clear()
+    
+    def stats(self) -> Dict:
+        """Hit/miss counters and occupancy"""
+        with self._lock:
+            lookups = self.hits + self.misses
+            return {
+                'enabled': self.enabled,
+                'entries': len(self._entries),
+                'max_entries': self.max_entries,
+                'ttl': self.ttl,
+                'hits': self.hits,
+                'misses': self.misses,
+                'hit_rate': self.hits / lookups if lookups else 0.0,
+                'evictions': self.evictions,
+                'expirations': self.expirations,
+            }
@@ -0,0 +1,124 @@
+#!/usr/bin/env python3
+"""
+Query expansion with identifier variants and synonyms
+Plain-English queries use words the code abbreviates or spells as one identifier:
+"sign in" is SignIn, "authentication" is auth. Expansion adds the joined forms of
+adjacent query words and the synonyms of each word, as the lowercase whole tokens
+tokenize_code produces for identifiers, so they match in BM25 and pull the query
+embedding towards the code's vocabulary.
+"""
+
+import json
+from typing import Dict, Iterable, List, Set
+
+from utils.code_tokenizer import WORD_PATTERN, tokenize_code
+
+
+# Words code uses interchangeably: each word of a group expands to the others
+SYNONYM_GROUPS = [
+    ['auth', 'authenticate', 'authentication', 'authn'],
+    ['authz', 'authorize', 'authorization'],
+    ['login', 'signin', 'sign_in', 'logon', 'log_in'],
+    ['logout', 'signout', 'sign_out', 'logoff', 'log_out'],
+    ['signup', 'sign_up', 'register', 'registration'],
+    ['password', 'passwd', 'pwd'],
+    ['user', 'usr'],
+    ['config', 'configuration', 'cfg', 'conf', 'settings'],
+    ['delete', 'remove', 'del', 'rm', 'erase'],
+    ['create', 'new', 'make'],
+    ['initialize', 'initialise', 'init', 'setup'],
+    ['error', 'err', 'failure'],
+    ['message', 'msg'],
+    ['request', 'req'],
+    ['response', 'resp', 'reply'],
+    ['context', 'ctx'],
+    ['database', 'db'],
+    ['number', 'num'],
+    ['string', 'str'],
+    ['buffer', 'buf'],
+    ['directory', 'dir', 'folder'],
+    ['temporary', 'temp', 'tmp'],
+    ['argument', 'arg', 'args'],
+    ['parameter', 'param', 'params'],
+    ['connection', 'conn'],
+    ['manager', 'mgr'],
+    ['source', 'src'],
+    ['destination', 'dest', 'dst'],
+    ['length', 'len'],
+    ['index', 'idx'],
+    ['value', 'val'],
+    ['function', 'func', 'fn'],
+    ['callback', 'cb'],
+    ['image', 'img'],
+    ['attribute', 'attr'],
+    ['reference', 'ref'],
+    ['information', 'info'],
+    ['specification', 'spec'],
+    ['environment', 'env'],
+    ['allocate', 'alloc', 'allocation'],
+    ['synchronize', 'sync'],
+    ['asynchronous', 'async'],
+    ['previous', 'prev'],
+    ['current', 'curr', 'cur'],
+    ['maximum', 'max'],
+    ['minimum', 'min'],
+    ['utility', 'util', 'utils'],
+    ['validate', 'verify', 'check'],
+    ['retrieve', 'fetch'],
+]
+
+
+def build_synonyms(groups: Iterable[Iterable[str]]) -> Dict[str, Set[str]]:
+    """
+    Word -> its synonyms, from groups of interchangeable words
+    Groups sharing a word are merged, so a user group extends the built-in one it overlaps
+    """
+    merged: List[Set[str]] = []
+    for group in groups:
+        words = {word.strip().lower() for word in group if word and word.strip()}
+        if len(words) < 2:
+            continue
+        overlapping = [existing for existing in merged if existing & words]
+        for existing in overlapping:
+            words |= existing
+            merged.remove(existing)
+        merged.append(words)
+    return {word: group - {word} for group in merged for word in group}
+
+
+def load_synonym_groups(path: str) -> List[List[str]]:
+    """Synonym groups from a JSON file: a list of word lists"""
+    with open(path, encoding='utf-8') as f:
+        groups = json.load(f)
+    if not isinstance(groups, list) or not all(
+            isinstance(group, list) and all(isinstance(word, str) for word in group) for group in groups):
+        raise ValueError(f"{path}: expected a JSON list of word lists")
+    return groups
+
+
+def expand_query(query: str, synonyms: Dict[str, Set[str]]) -> List[str]:
+    """
+    Terms to add to a query: adjacent words joined (user name -> username, user_name)
+    and the synonyms of its words and joined pairs, none the query already has
+    
+    Returns:
+        Lowercase whole tokens, in the order they were found
+    """
+    words = [word.lower() for word in WORD_PATTERN.findall(query)]
+    tokens = tokenize_code(query)
+    present = set(tokens)
+    terms = []
+    
+    def add(term: str):
+        if term not in present:
+            present.add(term)
+            terms.append(term)
+    
+    for first, second in zip(words, words[1:]):
+        if first.isalpha() and second.isalpha():
+            add(first + second)
+            add(f"{first}_{second}")
+    for word in list(dict.fromkeys(tokens)) + list(terms):
+        for synonym in sorted(synonyms.get(word, ())):
+            add(synonym)
+    return terms
@@ -0,0 +1,97 @@
+#!/usr/bin/env python3
+"""
+Symbols related through the call graph
+Two functions are related when they call the same helpers (shared callees) or
+are called from the same places (shared callers, such as the steps of one
+workflow), however differently they are worded. This complements similar_to,
+which compares the embeddings. Only calls resolved to indexed functions and
+methods count: calls recorded by name only (interfaces, func values, packages
+outside the index such as fmt) would relate nearly every function to every
+other.
+"""
+
+from collections import defaultdict
+from typing import Dict, Iterable, List, Optional, Set, Tuple
+
+
+# Ways two symbols can be related: calling the same functions, being called by the same ones
+RELATION_KINDS = ('callees', 'callers')
+
+
+def call_target(ref: Dict) -> Optional[str]:
+    """
+    Key of the indexed function a resolved call reference points to (its file and
+    first line, as symbol_key gives it), None for a call recorded by name only
+    """
+    if not isinstance(ref, dict) or not ref.get('resolved'):
+        return None
+    if ref.get('filepath') and ref.get('line') is not None:
+        return f"{ref['filepath']}:{ref['line']}"
+    return ref.get('symbol_id')
+
+
+def symbol_key(metadata: Dict) -> str:
+    """Key of an indexed function or method, matching call_target of the calls to it"""
+    return f"{metadata.get('filepath', '')}:{metadata.get('line_start', '')}"
+
+
+def call_graph(calls: Dict[str, Iterable[Dict]]) -> Tuple[Dict[str, Set[str]], Dict[str, Set[str]]]:
+    """
+    The callees and the callers of each symbol
+    
+    Args:
+        calls: 'calls' references of each symbol, by symbol key
+    
+    Returns:
+        (callees, callers), each a set of symbol keys per symbol key; a symbol
+        calling itself counts as neither
+    """
+    callees: Dict[str, Set[str]] = defaultdict(set)
+    callers: Dict[str, Set[str]] = defaultdict(set)
+    for key, refs in calls.items():
+        for ref in refs:
+            target = call_target(ref)
+            if target and target != key:
+                callees[key].add(target)
+                callers[target].add(key)
+    return dict(callees), dict(callers)
+
+
+def related_symbols(callees: Dict[str, Set[str]], callers: Dict[str, Set[str]], targets: Set[str],
+                    kinds: Iterable[str] = RELATION_KINDS) -> List[Tuple[str, Set[str], Set[str]]]:
+    """
+    Symbols sharing callees or callers with the target symbols
+    
+    Args:
+        callees: Callees of each symbol (see call_graph)
+        callers: Callers of each symbol
+        targets: Keys of the symbols looked up (all definitions of the name)
+        kinds: RELATION_KINDS to count
+    
+    Returns:
+        (symbol key, shared callees, shared callers) of every other symbol sharing
+        any, the most shared first
+    
+    Raises:
+        ValueError: If a kind is not one of RELATION_KINDS
+    """
+    kinds = tuple(kinds)
+    unknown = [kind for kind in kinds if kind not in RELATION_KINDS]
+    if unknown:
+        raise ValueError(f"Unknown relation: {', '.join(unknown)} (expected one of: {', '.join(RELATION_KINDS)})")
+    
+    shared: Dict[str, Tuple[Set[str], Set[str]]] = defaultdict(lambda: (set(), set()))
+    for target in targets:
+        if 'callees' in kinds:
+            # Whoever else calls one of the target's callees shares it
+            for callee in callees.get(target, ()):
+                for other in callers.get(callee, ()):
+                    shared[other][0].add(callee)
+        if 'callers' in kinds:
+            for caller in callers.get(target, ()):
+                for other in callees.get(caller, ()):
+                    shared[other][1].add(caller)
+    
+    found = [(key, same_callees, same_callers) for key, (same_callees, same_callers) in shared.items()
+             if key not in targets]
+    return sorted(found, key=lambda item: -(len(item[1]) + len(item[2])))
@@ -0,0 +1,111 @@
+#!/usr/bin/env python3
+"""
+Search results as records and snippets, shared by the CLI and the HTTP server
+A result record is what POST /search and `search --format json` return: a flat,
+JSON-ready dict with stable keys (the to_dict() of a utils.result_types.SearchResult).
+Snippets trim a result's code to the lines around the first one matching the query;
+a record's snippet (snippet_lines) is the window of lines holding the most matches,
+with a reference to the whole chunk.
+"""
+
+from bisect import bisect_right
+from typing import Dict, List, Optional, Set, Tuple
+from urllib.parse import quote
+
+from utils.code_tokenizer import tokenize_code
+from utils.result_types import SearchResult, to_record
+
+
+def result_record(result: Dict, snippet_lines: Optional[int] = None) -> Dict:
+    """
+    Search result as returned by /search; 'explain' is only there when it was asked for
+    With snippet_lines, the record has a 'snippet' of at most that many lines
+    instead of its 'content' (see with_snippet)
+    """
+    if snippet_lines is not None:
+        result = with_snippet(result, snippet_lines)
+    return SearchResult.from_result(result).to_dict()
+
+
+def chunk_record(result: Dict) -> Dict:
+    """A chunk as GET /chunk returns it: the chunk fields of its search result record"""
+    return to_record(SearchResult.from_result(result).chunk)
+
+
+def chunk_path(chunk_id: str) -> str:
+    """The GET request that returns a chunk"""
+    return '/chunk?id=' + quote(chunk_id, safe='')
+
+
+def with_snippet(result: Dict, max_lines: int) -> Dict:
+    """
+    A copy of a result whose content is replaced by a 'snippet' of at most
+    max_lines lines: the window holding the most highlights, centered on them,
+    or for a result without highlights (found by its vector) the first
+    lines, at least its whole signature. The stored chunk is untouched.
+    """
+    content = result['content']
+    lines = content.split('\n')
+    highlights = result.get('highlights', [])
+    matched = sorted(span['line'] - 1 for span in highlights)
+    first, end = snippet_window(len(lines), matched, max(max_lines, 1),
+                                signature_lines(lines, result['metadata'].get('signature') or ''))
+    offset = sum(len(line) + 1 for line in lines[:first])
+    shown = '\n'.join(lines[first:end])
+    line_start = result['metadata'].get('line_start')
+    return dict(result, content=None, snippet={
+        'content': shown,
+        'line_start': line_start + first if line_start is not None else None,
+        'line_end': line_start + end - 1 if line_start is not None else None,
+        'omitted_lines': len(lines) - (end - first),
+        'highlights': [dict(span, start=span['start'] - offset, end=span['end'] - offset, line=span['line'] - first)
+                       for span in highlights if first < span['line'] <= end],
+        'full': chunk_path(result['id']),
+    })
+
+
+def signature_lines(lines: List[str], signature: str) -> int:
+    """Lines from the top of content to the end of its signature (past a doc comment above it)"""
+    signature = signature.strip().split('\n')
+    start = next((i for i, line in enumerate(lines) if signature[0] and signature[0] in line), 0)
+    return start + len(signature)
+
+
+def snippet_window(count: int, matched: List[int], max_lines: int, header_lines: int = 1) -> Tuple[int, int]:
+    """
+    (first, end) line indexes of a snippet of count lines: the max_lines lines
+    holding the most of the matched line indexes (sorted, one per match; the first
+    such run, centered on it), or without matches the first max_lines lines, or
+    header_lines if more
+    """
+    if not matched:
+        return 0, min(count, max(max_lines, header_lines))
+    if count <= max_lines:
+        return 0, count
+    # For each match, the matches a window starting at it would hold
+    runs = [(bisect_right(matched, line + max_lines - 1) - i, i) for i, line in enumerate(matched)]
+    held, i = max(runs, key=lambda run: (run[0], -run[1]))
+    low, high = matched[i], matched[i + held - 1]
+    first = (low + high + 1) // 2 - max_lines // 2
+    first = max(0, high - max_lines + 1, min(first, low, count - max_lines))
+    return first, first + max_lines
+
+
+def matching_lines(content: str, terms: Set[str]) -> List[int]:
+    """Indexes of the lines of content with a token among terms (lowercase, as tokenize_code gives them)"""
+    return [i for i, line in enumerate(content.splitlines()) if terms.intersection(tokenize_code(line))]
+
+
+def snippet(content: str, matches: List[int], max_lines: int) -> Tuple[int, str]:
+    """
+    At most max_lines lines of content, starting a little above the first match
+    (from the top when nothing matched)
+    
+    Returns:
+        (index of the first line shown, the lines shown)
+    """
+    lines = content.splitlines()
+    if len(lines) <= max_lines:
+        return 0, content
+    first = max(0, min((matches[0] if matches else 0) - max_lines // 4, len(lines) - max_lines))
+    return first, '\n'.join(lines[first:first + max_lines])
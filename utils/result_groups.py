@@ -0,0 +1,90 @@
+#!/usr/bin/env python3
+"""
+Search results grouped by file or by owning type
+Grouping is presentation only: it runs on the ranked results of a search,
+never changes which results are returned or their order, and each group
+points back into that one ranking. Groups are ordered by their best result,
+so the first group holds the top result and reading the groups top to bottom
+follows the ranking:
+
+    results = rag.retrieve_context("authenticate a user", n_results=10)
+    for group in group_results(results, 'type'):
+        print(group['group'], group['score'], [results[i]['metadata']['name'] for i in group['positions']])
+
+By 'type', the methods and fields of AdminUser go together with AdminUser
+itself (a type is its own group), wherever in the package they are defined;
+results that belong to no type (free functions, text) are grouped by file.
+"""
+
+from typing import Dict, List, Optional
+
+from chunkers.base_chunker import qualified_name, repo_filepath
+from utils.result_types import ranking_score
+
+# What results can be grouped by
+GROUP_MODES = ('file', 'type')
+
+
+def group_key(result: Dict, by: str) -> Dict:
+    """
+    The group a result goes into, as {'by', 'group', 'repo'}: its file (repo:path
+    in a multi-repo index), or by 'type' the type it is a member of or is
+    """
+    # rag imports the utils modules, not the other way round
+    from rag import TYPE_CHUNK_TYPES
+    
+    metadata = result['metadata']
+    repo = metadata.get('repo') or None
+    if by == 'type':
+        owner = metadata.get('parent') or (qualified_name(metadata) if metadata.get('type') in TYPE_CHUNK_TYPES
+                                            else None)
+        if owner:
+            return {'by': 'type', 'group': owner, 'repo': repo, 'language': metadata.get('language')}
+    return {'by': 'file', 'group': repo_filepath(repo, metadata.get('filepath') or ''), 'repo': repo}
+
+
+def group_results(results: List[Dict], by: str) -> List[Dict]:
+    """
+    Results grouped by 'file' or 'type' (GROUP_MODES), in the order of their
+    best result; each group is {'group', 'by', 'repo', 'positions', 'count',
+    'files', 'score', 'best_score'}:
+        
+        positions: indexes into results of the group's results, in rank order
+        files: files they are in (a type's members can span several)
+        score: sum of their scores, the aggregate a group can be compared by
+        best_score: score of the group's first result
+    
+    ValueError for an unknown mode
+    """
+    if by not in GROUP_MODES:
+        raise ValueError(f"Unknown grouping: {by} (expected one of: {', '.join(GROUP_MODES)})")
+    groups: Dict[tuple, Dict] = {}
+    for position, result in enumerate(results):
+        key = group_key(result, by)
+        identity = (key['by'], key['repo'], key.get('language'), key['group'])
+        group = groups.get(identity)
+        if group is None:
+            group = groups[identity] = {'group': key['group'], 'by': key['by'], 'repo': key['repo'],
+                                        'positions': [], 'count': 0, 'files': [], 'score': None,
+                                        'best_score': ranking_score(result)}
+        group['positions'].append(position)
+        group['count'] += 1
+        location = repo_filepath(key['repo'], result['metadata'].get('filepath') or '')
+        if location not in group['files']:
+            group['files'].append(location)
+        score = ranking_score(result)
+        if score is not None:
+            group['score'] = score + (group['score'] or 0.0)
+    return list(groups.values())
+
+
+def describe_group(group: Dict, rank: Optional[int] = None) -> str:
+    """One-line header of a group: what it is, how many results and its scores"""
+    what = f"{group['group']} ({'type' if group['by'] == 'type' else 'file'})"
+    count = f"{group['count']} result{'s' if group['count'] != 1 else ''}"
+    parts = [f"{rank}. {what}" if rank is not None else what, count]
+    if group['score'] is not None:
+        parts.append(f"score {group['score']:.4f} (best {group['best_score']:.4f})")
+    if group['by'] == 'type' and len(group['files']) > 1:
+        parts.append(f"in {len(group['files'])} files")
+    return '  '.join(parts)
@@ -0,0 +1,75 @@
+#!/usr/bin/env python3
+"""
+Limits on how much one search may ask for
+A search asking for more results (top k) than CONFIG.max_top_k, or a larger
+candidate pool (candidate_k, rerank_candidates) than CONFIG.max_candidate_k,
+is rejected with a ResultLimitError before anything is ranked; the servers
+answer it as a bad request. The HTTP server also refuses to send a response
+body larger than CONFIG.max_response_bytes. 0 or None disables a limit.
+A filter expression in a request is bounded too, by MAX_FILTER_LENGTH and
+MAX_FILTER_DEPTH of utils/filter_expression.py, before it is parsed.
+"""
+
+import json
+from typing import Optional
+
+
+class ResultLimitError(ValueError):
+    """A search, or its response, over one of the result limits"""
+    pass
+
+
+def check_result_limits(n_results: int, candidate_k: Optional[int] = None,
+                        rerank_candidates: Optional[int] = None, name: str = 'n_results'):
+    """
+    Raises:
+        ResultLimitError: If n_results is above CONFIG.max_top_k, or candidate_k or
+            rerank_candidates above CONFIG.max_candidate_k (name is how the count
+            of results is called in the message: 'top_k', 'page_size', 'k')
+    """
+    from config import CONFIG
+    
+    if CONFIG.max_top_k and n_results > CONFIG.max_top_k:
+        raise ResultLimitError(f"{name} ({n_results}) is above the limit of {CONFIG.max_top_k} results (max_top_k)")
+    for key, value in (('candidate_k', candidate_k), ('rerank_candidates', rerank_candidates)):
+        if CONFIG.max_candidate_k and value is not None and value > CONFIG.max_candidate_k:
+            raise ResultLimitError(f"{key} ({value}) is above the limit of {CONFIG.max_candidate_k} "
+                                   f"candidates (max_candidate_k)")
+
+
+def check_response_size(size: int):
+    """
+    Raises:
+        ResultLimitError: If a response body of size bytes is above CONFIG.max_response_bytes
+    """
+    from config import CONFIG
+    
+    if CONFIG.max_response_bytes and size > CONFIG.max_response_bytes:
+        raise ResultLimitError(f"Response of {size} bytes is above the limit of {CONFIG.max_response_bytes} "
+                               f"bytes (max_response_bytes); ask for fewer results or shorter snippets")
+
+
+def check_filter_limits(expression):
+    """
+    Raises:
+        ResultLimitError: If a filter string is longer than MAX_FILTER_LENGTH, or a
+            JSON filter tree serializes longer than that or nests deeper than MAX_FILTER_DEPTH
+    """
+    from utils.filter_expression import MAX_FILTER_DEPTH, MAX_FILTER_LENGTH
+    
+    if isinstance(expression, str):
+        if len(expression) > MAX_FILTER_LENGTH:
+            raise ResultLimitError(f"'filter' is longer than the limit of {MAX_FILTER_LENGTH} characters")
+        return
+    # Walked without recursion: the tree is what may be too deep for the stack
+    stack = [(expression, 0)]
+    while stack:
+        value, depth = stack.pop()
+        if isinstance(value, dict):
+            if depth > MAX_FILTER_DEPTH:
+                raise ResultLimitError(f"'filter' nests deeper than the limit of {MAX_FILTER_DEPTH} levels")
+            stack.extend((child, depth + 1) for child in value.values())
+        elif isinstance(value, list):
+            stack.extend((child, depth) for child in value)  # the operands of an and/or, one level down
+    if len(json.dumps(expression)) > MAX_FILTER_LENGTH:
+        raise ResultLimitError(f"'filter' is longer than the limit of {MAX_FILTER_LENGTH} characters")
@@ -0,0 +1,78 @@
+#!/usr/bin/env python3
+"""
+Pages of search results
+A paged search ranks its candidates once, up to CONFIG.page_depth of them,
+and serves each page as a slice of that one ranking, so walking through the
+pages never drops or repeats a result. The ranking is kept in the page cache
+under the search and the index version; a cursor names the search, the index
+version it was ranked at and where the next page starts:
+
+    page = rag.retrieve_page("parse a url", page_size=20, languages=["go"])
+    page = rag.retrieve_page("parse a url", page_size=20, languages=["go"], cursor=page['next_cursor'])
+
+A cursor is only good for the search it came from, and it goes stale when the
+index changes (a re-index, an incremental update): the ranking it pointed
+into no longer exists, so the search has to start again from the first page.
+"""
+
+import base64
+import binascii
+import hashlib
+import json
+from typing import Dict, Tuple
+
+from utils.query_cache import freeze, normalize_query
+
+
+class CursorError(ValueError):
+    """Raised for a cursor that is malformed or belongs to another search"""
+
+
+class StaleCursorError(CursorError):
+    """Raised for a cursor issued before the index last changed"""
+
+
+def search_fingerprint(query: str, options: Dict) -> str:
+    """
+    Identity of a paged search: its normalized query and the options it was given
+    (those left at None or False do not count, as for the query cache)
+    """
+    options = {name: value for name, value in options.items() if value is not None and value is not False}
+    key = repr((normalize_query(query), freeze(options)))
+    return hashlib.sha256(key.encode('utf-8')).hexdigest()[:16]
+
+
+def encode_cursor(search: str, version: str, offset: int) -> str:
+    """Opaque cursor for the page of a search starting at offset"""
+    data = json.dumps({'s': search, 'v': version, 'o': offset}, separators=(',', ':'))
+    return base64.urlsafe_b64encode(data.encode('utf-8')).decode('ascii').rstrip('=')
+
+
+def decode_cursor(cursor: str, search: str, version: str) -> int:
+    """
+    Offset a cursor points at, checked against the search and the index version
+    
+    Raises:
+        CursorError: If the cursor does not parse or was issued for another search
+        StaleCursorError: If the index changed since the cursor was issued
+    """
+    search_id, cursor_version, offset = _parse(cursor)
+    if search_id != search:
+        raise CursorError("The cursor belongs to another search (give the query and filters it came with)")
+    if cursor_version != version:
+        raise StaleCursorError("The index changed since the cursor was issued; start again from the first page")
+    return offset
+
+
+def _parse(cursor: str) -> Tuple[str, str, int]:
+    if not isinstance(cursor, str) or not cursor:
+        raise CursorError("A cursor is the 'next_cursor' string of a page")
+    try:
+        data = json.loads(base64.urlsafe_b64decode(cursor + '=' * (-len(cursor) % 4)))
+        search, version, offset = data['s'], data['v'], data['o']
+    except (binascii.Error, UnicodeDecodeError, ValueError, TypeError, KeyError):
+        raise CursorError(f"Malformed cursor: {cursor!r}")
+    if not isinstance(search, str) or not isinstance(version, str) \
+            or not isinstance(offset, int) or isinstance(offset, bool) or offset < 0:
+        raise CursorError(f"Malformed cursor: {cursor!r}")
+    return search, version, offset
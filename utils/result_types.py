@@ -0,0 +1,295 @@
+#!/usr/bin/env python3
+"""
+Typed search results, the public shape of what a search returns
+ChromeRAGSystem.search returns SearchResult objects, and POST /search and
+`search --format json` return their to_dict() records, so a library caller
+and an HTTP client see the same fields. The ranking code works on plain dicts
+of its own; SearchResult.from_result is the one place those are mapped onto
+these types, so internal changes do not leak into the types. json_schema()
+describes a record for clients generating their own types.
+
+Fields are only ever added within a RESULT_SCHEMA_VERSION; renaming or
+removing one bumps it. 'explain' is a debugging aid and not covered.
+"""
+
+from dataclasses import dataclass, field, fields, is_dataclass
+from typing import Any, ClassVar, Dict, List, Optional, Tuple, Union, get_args, get_origin, get_type_hints
+
+from chunkers.base_chunker import parse_metadata
+
+RESULT_SCHEMA_VERSION = 1
+
+JSON_TYPES = {str: 'string', int: 'integer', float: 'number', bool: 'boolean'}
+
+
+def doc(text: str, **options):
+    """A field with its description, as json_schema() reports it"""
+    return field(metadata={'description': text}, **options)
+
+
+@dataclass
+class Span:
+    """Where a query term occurs in a result's content"""
+    start: int = doc("Character offset of the match in content")
+    end: int = doc("Character offset just past the match")
+    line: int = doc("Line of content the match is on, from 1")
+    term: str = doc("Query term (or expansion term) matched")
+
+
+@dataclass
+class Snippet:
+    """The lines of a result shown in place of its content (snippet_lines)"""
+    content: str = doc("The lines shown, around the query's matches or from the top")
+    line_start: Optional[int] = doc("File line of the first line shown, from 1")
+    line_end: Optional[int] = doc("File line of the last line shown")
+    omitted_lines: int = doc("Lines of the chunk not shown")
+    highlights: List[Span] = doc("Matches within the lines shown, offsets and lines relative to them")
+    full: str = doc("Path of the GET request that returns the whole chunk (/chunk?id=...)")
+
+
+@dataclass
+class SymbolRef:
+    """A symbol a result refers to, such as the one defined before it in its file"""
+    id: str = doc("Id of the symbol's first chunk")
+    symbol_id: Optional[str] = doc("Identity shared by all chunks of the symbol")
+    name: Optional[str] = doc("Symbol name")
+    qualified_name: Optional[str] = doc("Name qualified by its parent (AdminUser.Authenticate)")
+    type: Optional[str] = doc("Chunk type (function, method, struct, ...)")
+    filepath: Optional[str] = doc("File path under the indexed root")
+    line_start: Optional[int] = doc("First line of the symbol, from 1")
+    line_end: Optional[int] = doc("Last line of the symbol")
+    content: Optional[str] = doc("Code of the symbol (only when bodies were asked for)", default=None)
+    
+    OMITTED_WHEN_NONE: ClassVar[Tuple[str, ...]] = ('content',)
+
+
+@dataclass
+class Neighbors:
+    """The symbols defined right before and after a result in its file"""
+    previous: Optional[SymbolRef] = doc("Symbol before the result, None at the top of the file")
+    next: Optional[SymbolRef] = doc("Symbol after the result, None at the end of the file")
+
+
+@dataclass
+class Location:
+    """Another place the body of a result appears (deduplicated indexes)"""
+    location: str = doc("Path of the file, prefixed with 'repo:' in a multi-repo index")
+    filepath: Optional[str] = doc("File path under the indexed root", default=None)
+    repo: Optional[str] = doc("Repository label", default=None)
+    line_start: Optional[int] = doc("First line, from 1", default=None)
+    line_end: Optional[int] = doc("Last line", default=None)
+    name: Optional[str] = doc("Symbol name", default=None)
+    parent: Optional[str] = doc("Qualified name of the enclosing symbol", default=None)
+    
+    OMITTED_WHEN_NONE: ClassVar[Tuple[str, ...]] = ('filepath', 'repo', 'line_start', 'line_end', 'name', 'parent')
+    # Key order of the records, which list the location last
+    ORDER: ClassVar[Tuple[str, ...]] = ('filepath', 'repo', 'line_start', 'line_end', 'name', 'parent', 'location')
+
+
+@dataclass
+class Surrounding:
+    """Context of a result read from its source file"""
+    imports: str = doc("Import statements of the file, one per line")
+    enclosing: Optional[str] = doc("Declaration header of the type a member belongs to", default=None)
+    enclosing_name: Optional[str] = doc("Name of that type", default=None)
+    enclosing_filepath: Optional[str] = doc("File the type is declared in", default=None)
+    enclosing_lines: Optional[List[int]] = doc("First and last line of the type", default=None)
+    
+    OMITTED_WHEN_NONE: ClassVar[Tuple[str, ...]] = ('enclosing', 'enclosing_name', 'enclosing_filepath',
+                                                    'enclosing_lines')
+
+
+@dataclass
+class Change:
+    """The commit that last changed a chunk's lines, from git blame (CONFIG.git_blame)"""
+    commit: str = doc("Commit SHA")
+    author: str = doc("Author name")
+    email: str = doc("Author email")
+    date: str = doc("Author date, ISO 8601 UTC")
+    summary: str = doc("First line of the commit message")
+
+
+@dataclass
+class Chunk:
+    """The indexed code a result is"""
+    id: str = doc("Chunk id")
+    filepath: Optional[str] = doc("File path under the indexed root")
+    repo: Optional[str] = doc("Repository label, None in a single-repository index")
+    name: Optional[str] = doc("Symbol name")
+    type: Optional[str] = doc("Chunk type (function, method, struct, text, ...)")
+    language: Optional[str] = doc("Language the file was indexed as")
+    kind: str = doc("Chunk kind: source, test, benchmark, fuzz, example or text")
+    generated: bool = doc("True for code of a generated file")
+    last_change: Optional[Change] = doc("Last commit to change it; None unless indexed with git blame")
+    line_start: Optional[int] = doc("First line, from 1")
+    line_end: Optional[int] = doc("Last line")
+    byte_start: Optional[int] = doc("UTF-8 offset of the content in the file")
+    byte_end: Optional[int] = doc("UTF-8 offset just past the content")
+    citation: str = doc("path#L<start>-L<end>")
+    content: Optional[str] = doc("The code; left out when a snippet is returned instead", default=None)
+    
+    OMITTED_WHEN_NONE: ClassVar[Tuple[str, ...]] = ('content',)
+
+
+@dataclass
+class SearchResult:
+    """One ranked search result"""
+    chunk: Chunk = doc("The code found")
+    score: Optional[float] = doc("Score the results are ordered by (MMR, rerank or fused score)", default=None)
+    vector_score: Optional[float] = doc("Vector similarity, None when the vector search did not return it",
+                                        default=None)
+    bm25_score: Optional[float] = doc("BM25 score, None when the keyword index did not return it", default=None)
+    rerank_score: Optional[float] = doc("Cross-encoder score, None without reranking", default=None)
+    relevance: Optional[float] = doc("Vector and BM25 match blended by the lexical weight, 0 to 1", default=None)
+    surrounding: Optional[Surrounding] = doc("Context read from the source (with_surrounding)", default=None)
+    neighbors: Optional[Neighbors] = doc("Symbols before and after it (with_neighbors)", default=None)
+    duplicates: List[Location] = doc("Other places the same body appears", default_factory=list)
+    highlights: List[Span] = doc("Where the query's terms occur in content", default_factory=list)
+    snippet: Optional[Snippet] = doc("Lines of content around its matches (snippet_lines); content is then "
+                                     "left out", default=None)
+    explain: Optional[Dict[str, Any]] = doc("How it was scored (explain); not part of the stable schema",
+                                            default=None)
+    
+    OMITTED_WHEN_NONE: ClassVar[Tuple[str, ...]] = ('snippet', 'explain')
+    # Record key order: the chunk's fields come after the scores, except its id
+    SCORES: ClassVar[Tuple[str, ...]] = ('score', 'vector_score', 'bm25_score', 'rerank_score', 'relevance')
+    
+    @classmethod
+    def from_result(cls, result: Dict) -> 'SearchResult':
+        """The typed form of a result of ChromeRAGSystem.retrieve_context"""
+        metadata = result['metadata']
+        surrounding, neighbors = result.get('surrounding'), result.get('neighbors')
+        change = parse_metadata(metadata.get('metadata')).get('last_change')
+        return cls(
+            chunk=Chunk(
+                id=result['id'],
+                filepath=metadata.get('filepath'),
+                repo=metadata.get('repo') or None,
+                name=metadata.get('name'),
+                type=metadata.get('type'),
+                language=metadata.get('language'),
+                kind=metadata.get('kind', 'source'),
+                generated=bool(metadata.get('generated')),
+                last_change=_build(Change, change) if isinstance(change, dict) else None,
+                line_start=metadata.get('line_start'),
+                line_end=metadata.get('line_end'),
+                byte_start=metadata.get('byte_start'),
+                byte_end=metadata.get('byte_end'),
+                citation=citation(metadata),
+                content=result.get('content'),
+            ),
+            score=ranking_score(result),
+            vector_score=result.get('vector_score'),
+            bm25_score=result.get('bm25_score'),
+            rerank_score=result.get('rerank_score'),
+            relevance=result.get('relevance'),
+            surrounding=None if surrounding is None else _build(Surrounding, surrounding),
+            neighbors=None if neighbors is None else Neighbors(
+                previous=_build(SymbolRef, neighbors['previous']) if neighbors.get('previous') else None,
+                next=_build(SymbolRef, neighbors['next']) if neighbors.get('next') else None,
+            ),
+            duplicates=[_build(Location, entry) for entry in result.get('duplicates', [])],
+            highlights=[_build(Span, span) for span in result.get('highlights', [])],
+            snippet=_snippet(result['snippet']) if result.get('snippet') else None,
+            explain=result.get('explain'),
+        )
+    
+    def to_dict(self) -> Dict:
+        """The record POST /search returns"""
+        record = to_record(self)
+        chunk = record.pop('chunk')
+        ordered = {'id': chunk.pop('id')}
+        ordered.update((key, record.pop(key)) for key in self.SCORES)
+        ordered.update(chunk)
+        ordered.update(record)
+        return ordered
+
+
+def ranking_score(result: Dict) -> Optional[float]:
+    """Score a result of ChromeRAGSystem.retrieve_context was ranked by (MMR, rerank or fused score)"""
+    return result.get('mmr_score', result.get('rerank_score', result.get('rrf_score')))
+
+
+def citation(metadata: Dict) -> str:
+    """Clickable location of a result: path#L<start>-L<end>"""
+    return f"{metadata.get('filepath')}#L{metadata.get('line_start')}-L{metadata.get('line_end')}"
+
+
+def to_record(value):
+    """A result type (or list of them) as JSON-ready dicts; fields a type omits when None are left out"""
+    if isinstance(value, list):
+        return [to_record(item) for item in value]
+    if not is_dataclass(value):
+        return value
+    omitted = getattr(value, 'OMITTED_WHEN_NONE', ())
+    record = {f.name: to_record(getattr(value, f.name)) for f in fields(value)
+              if not (f.name in omitted and getattr(value, f.name) is None)}
+    order = getattr(value, 'ORDER', None)
+    return {key: record[key] for key in order if key in record} if order else record
+
+
+def json_schema() -> Dict:
+    """JSON Schema (draft 2020-12) of a /search response: {"query", "results": [SearchResult record]}"""
+    definitions = {}
+    for cls in (Span, Snippet, SymbolRef, Neighbors, Location, Surrounding, Change):
+        definitions[cls.__name__] = _object_schema(cls)
+    result = _object_schema(SearchResult)
+    chunk = _object_schema(Chunk)
+    result['properties'].pop('chunk')
+    result['required'].remove('chunk')
+    # Records are flat: the chunk's fields sit next to the scores
+    result['properties'] = dict(chunk['properties'], **result['properties'])
+    result['required'] = chunk['required'] + result['required']
+    definitions['SearchResult'] = result
+    return {
+        '$schema': 'https://json-schema.org/draft/2020-12/schema',
+        'title': 'SearchResponse',
+        'description': f"POST /search response, result schema version {RESULT_SCHEMA_VERSION}",
+        'type': 'object',
+        'properties': {
+            'query': {'type': 'string'},
+            'results': {'type': 'array', 'items': {'$ref': '#/$defs/SearchResult'}},
+        },
+        'required': ['query', 'results'],
+        '$defs': definitions,
+    }
+
+
+def _build(cls, data: Dict):
+    """An instance of a result type from a dict, ignoring keys it has no field for"""
+    names = {f.name for f in fields(cls)}
+    return cls(**{key: value for key, value in data.items() if key in names})
+
+
+def _snippet(data: Dict) -> Snippet:
+    snippet = _build(Snippet, data)
+    snippet.highlights = [_build(Span, span) for span in snippet.highlights]
+    return snippet
+
+
+def _object_schema(cls) -> Dict:
+    hints = get_type_hints(cls)
+    omitted = getattr(cls, 'OMITTED_WHEN_NONE', ())
+    properties = {}
+    for f in fields(cls):
+        properties[f.name] = dict(_type_schema(hints[f.name]), description=f.metadata.get('description', ''))
+    return {
+        'type': 'object',
+        'description': cls.__doc__,
+        'properties': properties,
+        'required': [f.name for f in fields(cls) if f.name not in omitted],
+    }
+
+
+def _type_schema(hint) -> Dict:
+    origin, args = get_origin(hint), get_args(hint)
+    if origin is Union:
+        inner = _type_schema(next(arg for arg in args if arg is not type(None)))
+        return {'anyOf': [inner, {'type': 'null'}]}
+    if origin is list:
+        return {'type': 'array', 'items': _type_schema(args[0])}
+    if origin is dict:
+        return {'type': 'object'}
+    if is_dataclass(hint):
+        return {'$ref': f'#/$defs/{hint.__name__}'}
+    return {'type': JSON_TYPES[hint]}
@@ -0,0 +1,175 @@
+#!/usr/bin/env python3
+"""
+Retrieval quality evaluation against the current index
+A query set is a JSON lines file of (query, expected symbols) pairs; each query
+is run through ChromeRAGSystem.retrieve_context and its ranking scored with
+recall@k, MRR and nDCG@k, so embedders, chunk sizes and hybrid weights can be
+compared by numbers instead of by eye. Relevance is binary: a result is a hit
+if it is one of the query's expected symbols, and each symbol counts once, at
+the first rank it is found (the other parts of a split symbol add nothing).
+"""
+
+import json
+import math
+from typing import Dict, List, Optional, Sequence
+
+from chunkers.base_chunker import qualified_name, repo_filepath
+
+# retrieve_context filters a query set line may carry, applied to its query only
+QUERY_FILTERS = ('languages', 'kinds', 'path_globs', 'scope', 'repos', 'uses')
+
+# Cutoffs recall and nDCG are reported at by default
+DEFAULT_K = (1, 5, 10)
+
+
+class EvalError(Exception):
+    """Raised for unreadable or malformed query sets"""
+
+
+def load_queries(path: str) -> List[Dict]:
+    """
+    Read a query set: one JSON object per line (blank lines are skipped), as
+        
+        {"query": "create a user session", "expected": ["SessionManager.CreateSession"]}
+    
+    'expected' lists the symbols a good ranking returns, or is a single symbol. A
+    symbol is a name ('NewSessionManager', any symbol of that name matches), a
+    qualified name ('SessionManager.CreateSession') or either after the file it
+    is in ('complex.go:SessionManager.CreateSession', 'repo:path:Name' in a
+    multi-repo index). A line may also carry filters for its query (QUERY_FILTERS,
+    lists as retrieve_context takes them), e.g. "languages": ["go"].
+    
+    Raises:
+        EvalError: If the file cannot be read or a line is not a valid query
+    """
+    try:
+        with open(path, encoding='utf-8') as f:
+            lines = f.read().splitlines()
+    except OSError as e:
+        raise EvalError(f"Cannot read query set {path}: {e}")
+    
+    queries = []
+    for number, line in enumerate(lines, 1):
+        if not line.strip():
+            continue
+        where = f"{path}:{number}"
+        try:
+            entry = json.loads(line)
+        except json.JSONDecodeError as e:
+            raise EvalError(f"{where}: not valid JSON ({e})")
+        if not isinstance(entry, dict) or not isinstance(entry.get('query'), str) or not entry['query'].strip():
+            raise EvalError(f"{where}: expected an object with a non-empty 'query' string")
+        
+        expected = entry.get('expected')
+        if isinstance(expected, str):
+            expected = [expected]
+        if not expected or not isinstance(expected, list) or not all(isinstance(s, str) and s for s in expected):
+            raise EvalError(f"{where}: 'expected' must be a symbol name or a non-empty list of them")
+        
+        filters = {}
+        for key in QUERY_FILTERS:
+            if key in entry:
+                value = entry[key]
+                if isinstance(value, str):
+                    value = [value]
+                if not isinstance(value, list) or not all(isinstance(v, str) for v in value):
+                    raise EvalError(f"{where}: '{key}' must be a string or a list of strings")
+                filters[key] = value
+        unknown = sorted(set(entry) - {'query', 'expected'} - set(QUERY_FILTERS))
+        if unknown:
+            raise EvalError(f"{where}: unknown field(s) {', '.join(unknown)} "
+                            f"(expected query, expected, {', '.join(QUERY_FILTERS)})")
+        
+        queries.append({'query': entry['query'], 'expected': list(dict.fromkeys(expected)), 'filters': filters})
+    
+    if not queries:
+        raise EvalError(f"Query set {path} has no queries")
+    return queries
+
+
+def symbol_matches(expected: str, metadata: Dict) -> bool:
+    """Whether a stored chunk is the expected symbol (see load_queries for the forms)"""
+    location, _, name = expected.rpartition(':')
+    if location and location != repo_filepath(metadata.get('repo'), metadata.get('filepath', '')):
+        return False
+    if '.' in name:
+        return qualified_name(metadata) == name
+    return metadata.get('name') == name
+
+
+def score_ranking(ranked: List[Dict], expected: Sequence[str], k_values: Sequence[int]) -> Dict:
+    """
+    Metrics of one query's ranking
+    
+    Args:
+        ranked: Its results, best first, with their 'metadata'
+        expected: The symbols it should return
+        k_values: Cutoffs for recall and nDCG
+    
+    Returns:
+        'ranks': rank (1-based) each expected symbol was first found at, or None;
+        'reciprocal_rank', and 'recall@k' and 'ndcg@k' for each k
+    """
+    ranks = {symbol: None for symbol in expected}
+    for rank, result in enumerate(ranked, 1):
+        for symbol in ranks:
+            if ranks[symbol] is None and symbol_matches(symbol, result.get('metadata') or {}):
+                ranks[symbol] = rank
+                break
+    
+    found = sorted(rank for rank in ranks.values() if rank is not None)
+    scores = {'ranks': ranks, 'reciprocal_rank': 1.0 / found[0] if found else 0.0}
+    for k in k_values:
+        hits = [rank for rank in found if rank <= k]
+        scores[f'recall@{k}'] = len(hits) / len(expected)
+        dcg = sum(1.0 / math.log2(rank + 1) for rank in hits)
+        ideal = sum(1.0 / math.log2(rank + 1) for rank in range(1, min(len(expected), k) + 1))
+        scores[f'ndcg@{k}'] = dcg / ideal
+    return scores
+
+
+def evaluate(rag, queries: List[Dict], k_values: Sequence[int] = DEFAULT_K, **search_options) -> Dict:
+    """
+    Run a query set against an index and average its metrics
+    
+    Args:
+        rag: ChromeRAGSystem to search
+        queries: Queries from load_queries
+        k_values: Cutoffs for recall and nDCG; the largest is the number of results
+            asked for, so MRR only sees that many
+        **search_options: retrieve_context options applied to every query
+            (lexical_weight, expand_query, rerank, ...); a query's own filters win
+    
+    Returns:
+        'queries' (their count), 'k', 'mrr', 'recall@k' and 'ndcg@k' averaged over
+        the queries, and 'per_query': each query's metrics with its 'query' and
+        'expected' symbols
+    """
+    k_values = sorted(set(k_values))
+    if not k_values or k_values[0] < 1:
+        raise ValueError("k values must be positive")
+    
+    per_query = []
+    for entry in queries:
+        options = dict(search_options, **entry.get('filters', {}))
+        ranked = rag.retrieve_context(entry['query'], n_results=k_values[-1], **options)
+        scores = score_ranking(ranked, entry['expected'], k_values)
+        per_query.append(dict(scores, query=entry['query'], expected=entry['expected']))
+    
+    count = len(per_query)
+    report = {
+        'queries': count,
+        'k': k_values,
+        'mrr': sum(q['reciprocal_rank'] for q in per_query) / count if count else 0.0,
+    }
+    for k in k_values:
+        for metric in (f'recall@{k}', f'ndcg@{k}'):
+            report[metric] = sum(q[metric] for q in per_query) / count if count else 0.0
+    report['per_query'] = per_query
+    return report
+
+
+def first_rank(scores: Dict) -> Optional[int]:
+    """Rank of the best-placed expected symbol of a query, or None if none was found"""
+    found = [rank for rank in scores['ranks'].values() if rank is not None]
+    return min(found) if found else None
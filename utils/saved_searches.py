@@ -0,0 +1,209 @@
+#!/usr/bin/env python3
+"""
+Saved searches
+Named, parameterized searches kept in the config file, so a team runs its
+common queries the same way. Each one is a query template with {placeholders},
+the defaults of its parameters, and the /search fields it sets (top_k and the
+filters); placeholders work in the filters too:
+
+    [saved_searches.error-handling]
+    description = "How a package handles and wraps errors"
+    query = "error handling in {package}"
+    top_k = 10
+    languages = ["go"]
+    filter = "returns_error=true AND path:*/{package}/*"
+    
+    [saved_searches.error-handling.params]
+    package = "auth"        # default; a parameter without one must be given
+
+`search --saved error-handling --param package=billing` (or {"saved":
+"error-handling", "params": {"package": "billing"}} in a /search request)
+fills in the placeholders; options given with it replace the saved ones.
+"""
+
+import string
+from typing import Dict, List, Mapping, Optional
+
+from utils.filter_expression import FilterError, parse_filter
+
+# Keys of a saved search and the types they take; all but description and
+# params are /search request fields
+SAVED_SEARCH_FIELDS = {
+    'query': (str,),
+    'description': (str,),
+    'params': (dict,),
+    'top_k': (int,),
+    'languages': (list,),
+    'kinds': (list,),
+    'path_globs': (list,),
+    'repos': (list,),
+    'uses': (list,),
+    'filter': (str,),
+    'scope': (str, list),
+    'exclude_tests': (bool,),
+    'exclude_text': (bool,),
+    'exclude_generated': (bool,),
+    'query_embedder': (str,),
+}
+
+# Fields whose strings may hold placeholders
+TEMPLATE_FIELDS = ('query', 'path_globs', 'filter', 'scope', 'repos', 'uses')
+
+
+class SavedSearchError(ValueError):
+    """Raised for an unknown saved search, a malformed one, or parameters that do not fit it"""
+
+
+def template_params(text: str) -> List[str]:
+    """
+    Names of the {placeholders} of a template, in order of first use ({{ and }} are literal braces)
+    
+    Raises:
+        ValueError: For unbalanced braces or a placeholder that is not a plain name ({0}, {a.b}, {x:>4})
+    """
+    names = []
+    for _, name, spec, conversion in string.Formatter().parse(text):
+        if name is None:
+            continue
+        if not name.isidentifier() or spec or conversion:
+            raise ValueError(f"placeholder {{{name}{'!' + conversion if conversion else ''}"
+                             f"{':' + spec if spec else ''}}} must be a plain name")
+        if name not in names:
+            names.append(name)
+    return names
+
+
+def search_params(spec: Dict) -> List[str]:
+    """Every parameter a saved search's templates use, in order of first use"""
+    names = []
+    for text in _template_strings(spec):
+        names.extend(name for name in template_params(text) if name not in names)
+    return names
+
+
+def saved_search_problems(searches: Mapping) -> List[str]:
+    """What is wrong with saved searches: unknown keys, wrong types, bad templates and filters"""
+    problems = []
+    for name, spec in searches.items():
+        prefix = f"saved_searches.{name}"
+        found = len(problems)
+        if not isinstance(spec, dict):
+            problems.append(f"{prefix}: expected a table of {', '.join(SAVED_SEARCH_FIELDS)}")
+            continue
+        for key, value in spec.items():
+            if key not in SAVED_SEARCH_FIELDS:
+                problems.append(f"{prefix}.{key}: unknown key (expected one of: {', '.join(SAVED_SEARCH_FIELDS)})")
+            elif not _fits(value, SAVED_SEARCH_FIELDS[key]):
+                problems.append(f"{prefix}.{key}: expected {_describe(SAVED_SEARCH_FIELDS[key])}, got {value!r}")
+        if not isinstance(spec.get('query'), str) or not spec['query'].strip():
+            problems.append(f"{prefix}.query: a saved search needs a query template")
+            continue
+        if isinstance(spec.get('top_k'), int) and spec['top_k'] < 1:
+            problems.append(f"{prefix}.top_k: must be at least 1, got {spec['top_k']}")
+        try:
+            used = search_params(spec)
+        except (TypeError, ValueError) as e:
+            problems.append(f"{prefix}: {e}")
+            continue
+        defaults = spec.get('params') if isinstance(spec.get('params'), dict) else {}
+        for param, default in defaults.items():
+            if param not in used:
+                problems.append(f"{prefix}.params.{param}: not used by any placeholder")
+            if not _is_param_value(default):
+                problems.append(f"{prefix}.params.{param}: expected a string or a number, got {default!r}")
+        if isinstance(spec.get('filter'), str) and len(problems) == found:
+            # Checked with every placeholder filled in
+            try:
+                parse_filter(_fill(spec['filter'], {param: str(defaults.get(param, 'x')) for param in used}))
+            except FilterError as e:
+                problems.append(f"{prefix}.filter: {e}")
+    return problems
+
+
+def expand_saved_search(searches: Mapping, name: str, params: Optional[Mapping] = None) -> Dict:
+    """
+    The /search request fields of a saved search with its placeholders filled in
+    
+    Args:
+        searches: Saved searches by name (CONFIG.saved_searches)
+        name: The one to run
+        params: Parameter values; parameters not given take their defaults
+    
+    Raises:
+        SavedSearchError: If the search is unknown or malformed, a parameter is not
+            one of its own, or a parameter without a default is missing
+    """
+    if name not in searches:
+        known = ', '.join(sorted(searches)) or 'none'
+        raise SavedSearchError(f"Unknown saved search '{name}' (saved: {known})")
+    spec = searches[name]
+    problems = saved_search_problems({name: spec})
+    if problems:
+        raise SavedSearchError(problems[0])
+    
+    used = search_params(spec)
+    params = dict(params or {})
+    unknown = [param for param in params if param not in used]
+    if unknown:
+        raise SavedSearchError(f"Saved search '{name}' has no parameter '{unknown[0]}' "
+                               f"(parameters: {', '.join(used) or 'none'})")
+    for param, value in params.items():
+        if not _is_param_value(value):
+            raise SavedSearchError(f"Parameter '{param}' must be a string or a number, got {value!r}")
+    values = {param: str(value) for param, value in dict(spec.get('params') or {}, **params).items()}
+    missing = [param for param in used if param not in values]
+    if missing:
+        raise SavedSearchError(f"Saved search '{name}' needs a value for "
+                               + ', '.join(f"'{param}'" for param in missing))
+    
+    request = {}
+    for key, value in spec.items():
+        if key in ('description', 'params'):
+            continue
+        if key in TEMPLATE_FIELDS:
+            value = _fill(value, values) if isinstance(value, str) else [_fill(item, values) for item in value]
+        request[key] = list(value) if isinstance(value, list) else value
+    return request
+
+
+def describe_saved_search(spec: Dict) -> Dict:
+    """A saved search as listed: its query, description, parameters (default or None) and other fields"""
+    defaults = spec.get('params') or {}
+    return {
+        'query': spec.get('query'),
+        'description': spec.get('description', ''),
+        'params': {param: defaults.get(param) for param in search_params(spec)},
+        'fields': {key: value for key, value in spec.items() if key not in ('query', 'description', 'params')},
+    }
+
+
+def _template_strings(spec: Dict) -> List[str]:
+    texts = []
+    for key in TEMPLATE_FIELDS:
+        value = spec.get(key)
+        if isinstance(value, str):
+            texts.append(value)
+        elif isinstance(value, list):
+            texts.extend(item for item in value if isinstance(item, str))
+    return texts
+
+
+def _fill(text: str, values: Mapping[str, str]) -> str:
+    return text.format_map(values)
+
+
+def _fits(value, kinds: tuple) -> bool:
+    if isinstance(value, bool) and bool not in kinds:
+        return False
+    if not isinstance(value, kinds):
+        return False
+    return not isinstance(value, list) or all(isinstance(item, str) for item in value)
+
+
+def _is_param_value(value) -> bool:
+    return isinstance(value, (str, int, float)) and not isinstance(value, bool)
+
+
+def _describe(kinds: tuple) -> str:
+    names = {str: 'a string', int: 'an integer', bool: 'true/false', list: 'a list of strings', dict: 'a table'}
+    return ' or '.join(names[kind] for kind in kinds)
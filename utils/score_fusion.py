@@ -0,0 +1,157 @@
+#!/usr/bin/env python3
+"""
+Score fusion for hybrid search: how the vector and keyword rankings of a search
+are combined into one fused score
+Reciprocal rank fusion ('rrf') only looks at ranks, so scores on different
+scales need no calibration; a weighted sum ('weighted') adds the normalized
+scores themselves, so a clear winner of one retriever outranks a result both
+found barely. Either way each signal counts by its weight (1 - lexical weight
+for the vector ranking, the lexical weight for BM25). A custom Fuser can be
+given to ChromeRAGSystem or to a single search.
+
+The fused score orders results; it is not what min_score compares. min_score
+keeps results whose 'relevance' (the vector similarity and the matched share of
+the query's BM25 weight, blended by the lexical weight) reaches it, whatever the
+fusion. With the weighted sum and 'absolute' normalization the fused score is
+that relevance (before boosts).
+"""
+
+from abc import ABC, abstractmethod
+from collections import defaultdict
+from typing import Dict, List, Optional
+
+
+# Fusion methods, and how a weighted sum brings each signal's scores to 0-1:
+# 'minmax' maps the signal's lowest candidate to 0 and its highest to 1, 'max' divides by
+# the highest, 'absolute' takes the scale-free scores relevance is made of (the vector
+# similarity, the share of the query's BM25 weight)
+FUSION_METHODS = ('rrf', 'weighted')
+SCORE_NORMALIZATIONS = ('minmax', 'max', 'absolute')
+
+# The signals a search fuses
+SIGNALS = ('vector', 'lexical')
+
+
+class Fuser(ABC):
+    """
+    Combines the rankings of a search's retrievers into one score per result
+    
+    Each ranking is a list of hits, best first, each a dict with the result's 'id',
+    its 'score' from that retriever (the vector similarity, the BM25 score) and its
+    'relevance', the same on a 0-1 scale shared by every query (the similarity, the
+    share of the query's BM25 weight)
+    """
+    
+    # Method name, as recorded in explain records
+    name = ''
+    
+    @abstractmethod
+    def contributions(self, rankings: Dict[str, List[Dict]],
+                      weights: Dict[str, float]) -> Dict[str, Dict[str, float]]:
+        """
+        What each signal adds to the fused score of each result
+        
+        Args:
+            rankings: Hits of each signal (see SIGNALS), best first
+            weights: Weight of each signal
+        
+        Returns:
+            {result id: {signal: contribution}}; the fused score is the sum.
+            Signals that missed a result add nothing to it
+        """
+        pass
+    
+    def parameters(self) -> Dict:
+        """Settings of the fusion, as recorded in explain records"""
+        return {}
+    
+    def __repr__(self) -> str:
+        settings = ', '.join(f"{key}={value!r}" for key, value in self.parameters().items())
+        return f"{self.__class__.__name__}({settings})"
+
+
+class RrfFuser(Fuser):
+    """Weighted reciprocal rank fusion: sum of weight / (k + rank)"""
+    
+    name = 'rrf'
+    
+    def __init__(self, k: int = 60):
+        """
+        Args:
+            k: Rank constant; larger values flatten the difference between top ranks
+        """
+        if isinstance(k, bool) or not isinstance(k, (int, float)) or k <= 0:
+            raise ValueError(f"rrf_k must be a positive number, got {k!r}")
+        self.k = k
+    
+    def contributions(self, rankings: Dict[str, List[Dict]],
+                      weights: Dict[str, float]) -> Dict[str, Dict[str, float]]:
+        fused: Dict[str, Dict[str, float]] = defaultdict(dict)
+        for signal, hits in rankings.items():
+            for rank, hit in enumerate(hits, 1):
+                fused[hit['id']][signal] = weights.get(signal, 0.0) / (self.k + rank)
+        return dict(fused)
+    
+    def parameters(self) -> Dict:
+        return {'k': self.k}
+
+
+class WeightedFuser(Fuser):
+    """Weighted sum of each signal's scores, normalized to a 0-1 scale"""
+    
+    name = 'weighted'
+    
+    def __init__(self, normalization: str = 'minmax'):
+        """
+        Args:
+            normalization: How scores are brought to 0-1 (one of SCORE_NORMALIZATIONS)
+        """
+        if normalization not in SCORE_NORMALIZATIONS:
+            raise ValueError(f"Unknown score normalization '{normalization}' "
+                             f"(expected one of: {', '.join(SCORE_NORMALIZATIONS)})")
+        self.normalization = normalization
+    
+    def contributions(self, rankings: Dict[str, List[Dict]],
+                      weights: Dict[str, float]) -> Dict[str, Dict[str, float]]:
+        fused: Dict[str, Dict[str, float]] = defaultdict(dict)
+        for signal, hits in rankings.items():
+            for hit, score in zip(hits, self.normalize(hits)):
+                fused[hit['id']][signal] = weights.get(signal, 0.0) * score
+        return dict(fused)
+    
+    def normalize(self, hits: List[Dict]) -> List[float]:
+        """The scores of one signal's hits on a 0-1 scale, in order"""
+        if self.normalization == 'absolute':
+            return [min(max(hit['relevance'] or 0.0, 0.0), 1.0) for hit in hits]
+        scores = [hit['score'] or 0.0 for hit in hits]
+        if not scores:
+            return []
+        high, low = max(scores), min(scores)
+        if self.normalization == 'max':
+            return [score / high if high > 0 else 0.0 for score in scores]
+        # One hit, or all tied: each is as good as the best
+        if high == low:
+            return [1.0] * len(scores)
+        return [(score - low) / (high - low) for score in scores]
+    
+    def parameters(self) -> Dict:
+        return {'normalization': self.normalization}
+
+
+def create_fuser(method: Optional[str] = None, k: Optional[int] = None,
+                 normalization: Optional[str] = None) -> Fuser:
+    """
+    Build a fuser from its method name (defaults come from CONFIG: fusion_method,
+    rrf_k, fusion_normalization)
+    
+    Raises:
+        ValueError: If the method or normalization is unknown, or k is not positive
+    """
+    from config import CONFIG
+    
+    method = method or CONFIG.fusion_method
+    if method == 'rrf':
+        return RrfFuser(CONFIG.rrf_k if k is None else k)
+    if method == 'weighted':
+        return WeightedFuser(normalization or CONFIG.fusion_normalization)
+    raise ValueError(f"Unknown fusion method '{method}' (expected one of: {', '.join(FUSION_METHODS)})")
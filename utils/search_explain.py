@@ -0,0 +1,94 @@
+#!/usr/bin/env python3
+"""
+Why a search result ranked where it did
+With explain on, each result of a search carries an 'explain' record: its raw
+vector and BM25 scores and ranks, how they were fused, the filters it passed
+(and with what value), and the query terms its BM25 score came from, with the
+fields of the chunk they were found in. It is meant for tuning lexical weights,
+keyword field weights and synonyms, not for agents, so it is computed only for
+the results returned.
+"""
+
+from fnmatch import fnmatch
+from typing import Dict, List, Optional
+
+from chunkers.base_chunker import parse_metadata
+from chunkers.go_imports import uses_dependency
+from utils.path_scope import in_scope
+
+
+# Query terms listed per result, highest contribution first
+EXPLAIN_TERMS = 10
+
+
+def matched_filters(metadata: Dict, filters: Dict, relevance: Optional[float] = None) -> Dict:
+    """
+    What each search filter in effect matched in a result
+    
+    Args:
+        metadata: Stored metadata of the result
+        filters: The search's filters, by retrieve_context argument name
+            (languages, kinds, path_globs, scope, repos, uses, exclude_tests, exclude_text,
+            exclude_generated, filter_expr, min_score);
+            those left out or empty were not in effect
+        relevance: The result's relevance, checked against min_score
+    
+    Returns:
+        {filter: the value it matched}: the language, symbol type, repository or
+        chunk kind; the path patterns, scopes and dependencies matched; the filter
+        expression, in its canonical form; the relevance that reached min_score
+    """
+    matched = {}
+    if filters.get('languages'):
+        matched['languages'] = metadata.get('language')
+    if filters.get('kinds'):
+        kind = metadata.get('kind')
+        matched['kinds'] = kind if kind != 'source' and kind in filters['kinds'] \
+            else metadata.get('type')
+    if filters.get('exclude_tests'):
+        matched['exclude_tests'] = metadata.get('kind', 'source')
+    if filters.get('exclude_text'):
+        matched['exclude_text'] = metadata.get('kind', 'source')
+    if filters.get('exclude_generated'):
+        matched['exclude_generated'] = bool(metadata.get('generated'))
+    if filters.get('path_globs'):
+        matched['path_globs'] = [pattern for pattern in filters['path_globs']
+                                 if fnmatch(metadata.get('filepath', ''), pattern)]
+    if filters.get('scope'):
+        matched['scope'] = [scope for scope in filters['scope'] if in_scope(metadata.get('filepath', ''), [scope])]
+    if filters.get('repos'):
+        matched['repos'] = metadata.get('repo')
+    if filters.get('uses'):
+        imports = parse_metadata(metadata.get('metadata')).get('imports', [])
+        matched['uses'] = [dependency for dependency in filters['uses'] if uses_dependency(imports, dependency)]
+    if filters.get('filter_expr') is not None:
+        matched['filter_expr'] = str(filters['filter_expr'])
+    if filters.get('min_score') is not None:
+        matched['min_score'] = relevance
+    return matched
+
+
+def term_contributions(term_scores: Dict[str, float], fields: Dict[str, List[str]],
+                       expansion: List[str], limit: int = EXPLAIN_TERMS) -> List[Dict]:
+    """
+    The query terms behind a result's BM25 score, highest contribution first
+    
+    Args:
+        term_scores: Each term's share of the result's BM25 score (expansion terms
+            already scaled by their weight)
+        fields: The result's keyword tokens by field (name, doc, body)
+        expansion: Terms query expansion added, rather than the query's own
+        limit: Most terms listed
+    
+    Returns:
+        {'term', 'score', 'fields': {field: occurrences}, 'expansion'} per term that
+        counted, the occurrences before keyword field weights are applied
+    """
+    contributions = []
+    for term, score in term_scores.items():
+        if score <= 0:
+            continue
+        counts = {field: tokens.count(term) for field, tokens in fields.items() if term in tokens}
+        contributions.append({'term': term, 'score': score, 'fields': counts, 'expansion': term in expansion})
+    contributions.sort(key=lambda entry: (-entry['score'], entry['term']))
+    return contributions[:limit]
@@ -0,0 +1,140 @@
+#!/usr/bin/env python3
+"""
+Secret scanning of chunks before they are embedded and stored
+Old code sometimes holds hardcoded credentials, which should not end up in a
+vector store (possibly an external service). Chunks are scanned with patterns
+for well-known key formats (AWS, GitHub, Slack, Google, Stripe, JWTs, PEM
+private keys), credential assignments (password = "..."), and high-entropy
+string literals. 'redact' replaces each match in the indexed text with a
+[REDACTED:kind] marker; 'skip' leaves the chunk out of the index. The files on
+disk are never changed.
+"""
+
+import math
+import re
+from collections import Counter
+from dataclasses import dataclass
+from typing import Dict, List, Optional
+
+
+# 'off' indexes chunks as they are
+SECRET_MODES = ('off', 'redact', 'skip')
+
+# Well-known secret formats: kind -> pattern of the whole secret
+SECRET_PATTERNS = {
+    'private_key': re.compile(
+        r'-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----.*?(?:-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----|\Z)',
+        re.DOTALL),
+    'aws_access_key': re.compile(r'\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b'),
+    'github_token': re.compile(r'\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b'),
+    'slack_token': re.compile(r'\bxox[abprs]-[A-Za-z0-9-]{10,}'),
+    'google_api_key': re.compile(r'\bAIza[0-9A-Za-z_-]{35}'),
+    'stripe_key': re.compile(r'\b[rs]k_(?:live|test)_[0-9A-Za-z]{16,}\b'),
+    'jwt': re.compile(r'\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}'),
+}
+
+# Credentials assigned to a name ending in what they are (authToken, DB_PASSWORD, api_key):
+# only the quoted value is a secret
+ASSIGNMENT_PATTERN = re.compile(
+    r'(?i)(?:api[_-]?key|secret|token|passw(?:or)?d|pwd|credentials?|private[_-]?key)(?:[_-]?(?:key|value|id))?["\']?'
+    r'\s*(?::=|=>|==|[:=])\s*[bru]?["\']([^"\'\s]{8,})["\']'
+)
+
+# Values of such assignments that are names or placeholders, not credentials
+# ("access_token", "${TOKEN}", "<password>")
+PLACEHOLDER_PATTERN = re.compile(r'^[a-z_.-]+$|[<>${}%]')
+
+# String literals long enough to be random keys, by the alphabet they are written in; keys
+# mix letters and digits, identifiers (SessionManagerFactory) and numbers do not
+STRING_LITERAL = re.compile(r'["\'`]([A-Za-z0-9+/=_-]+)["\'`]')
+HEX_STRING = re.compile(r'^[0-9a-fA-F]+$')
+MIXED = re.compile(r'(?=.*[0-9])(?=.*[A-Za-z])')
+
+
+@dataclass
+class Finding:
+    """A secret in a text: its kind and where it is (start inclusive, end exclusive)"""
+    kind: str
+    start: int
+    end: int
+
+
+def shannon_entropy(text: str) -> float:
+    """Bits of entropy per character of text"""
+    if not text:
+        return 0.0
+    counts = Counter(text)
+    return -sum(n / len(text) * math.log2(n / len(text)) for n in counts.values())
+
+
+def find_secrets(text: str, min_length: int = 20, min_entropy: Optional[Dict[str, float]] = None) -> List[Finding]:
+    """
+    Secrets in a text, in order and without overlaps (the earliest, then longest, match wins)
+    
+    Args:
+        text: Chunk text to scan
+        min_length: Shortest string literal checked for entropy
+        min_entropy: Entropy a literal needs to count as a secret, by alphabet
+            ('hex' or 'base64'); an alphabet left out is not checked
+    """
+    if min_entropy is None:
+        min_entropy = {'base64': 4.0, 'hex': 3.0}
+    candidates = []
+    for kind, pattern in SECRET_PATTERNS.items():
+        candidates.extend(Finding(kind, m.start(), m.end()) for m in pattern.finditer(text))
+    for m in ASSIGNMENT_PATTERN.finditer(text):
+        if not PLACEHOLDER_PATTERN.search(m.group(1)):
+            candidates.append(Finding('credential', m.start(1), m.end(1)))
+    for m in STRING_LITERAL.finditer(text):
+        literal = m.group(1)
+        if len(literal) < min_length or not MIXED.match(literal):
+            continue
+        threshold = min_entropy.get('hex' if HEX_STRING.match(literal) else 'base64')
+        if threshold is not None and shannon_entropy(literal) >= threshold:
+            candidates.append(Finding('high_entropy', m.start(1), m.end(1)))
+    
+    findings = []
+    for finding in sorted(candidates, key=lambda f: (f.start, -f.end)):
+        if not findings or finding.start >= findings[-1].end:
+            findings.append(finding)
+    return findings
+
+
+def redact_secrets(text: str, findings: List[Finding]) -> str:
+    """text with each finding (from find_secrets) replaced by a [REDACTED:kind] marker"""
+    for finding in reversed(findings):
+        text = f"{text[:finding.start]}[REDACTED:{finding.kind}]{text[finding.end:]}"
+    return text
+
+
+def scan_chunk(chunk, redact: bool = False, **options) -> List[Dict]:
+    """
+    Secrets in the indexed text of a chunk: its content, doc comment, signature,
+    prepended context and string metadata values
+    
+    Args:
+        chunk: CodeChunk to scan
+        redact: Replace the secrets found in the chunk's fields
+        **options: min_length and min_entropy of find_secrets
+    
+    Returns:
+        One record per secret: 'filepath', 'line' (1-based), 'kind' and 'field';
+        never the secret itself
+    """
+    fields = {name: getattr(chunk, name) for name in ('content', 'doc', 'signature', 'context')}
+    fields.update({f"metadata.{key}": value for key, value in (chunk.metadata or {}).items()})
+    records = []
+    for field, text in fields.items():
+        if not isinstance(text, str) or not text:
+            continue
+        findings = find_secrets(text, **options)
+        for finding in findings:
+            line = chunk.line_start + (text.count('\n', 0, finding.start) if field == 'content' else 0)
+            records.append({'filepath': chunk.location, 'line': line, 'kind': finding.kind, 'field': field})
+        if findings and redact:
+            redacted = redact_secrets(text, findings)
+            if field.startswith('metadata.'):
+                chunk.metadata[field[len('metadata.'):]] = redacted
+            else:
+                setattr(chunk, field, redacted)
+    return records
@@ -6,9 +6,10 @@ Tracks file modification times and hashes to skip unchanged files
 
 import sqlite3
 import hashlib
+import json
 import os
 from pathlib import Path
-from typing import Optional, Tuple, Set
+from typing import Optional, Tuple, Set, Dict, List
 from .logger import get_logger
 
 class StateManager:
@@ -21,7 +22,7 @@ class StateManager:
         self.db_path = db_path
         self.logger = get_logger()
         self._init_db()
-        
+    
     def _init_db(self):
         """Initialize the SQLite database"""
         try:
@@ -31,12 +32,17 @@ class StateManager:
                         filepath TEXT PRIMARY KEY,
                         mtime REAL,
                         file_hash TEXT,
-                        last_indexed TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                        last_indexed TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        diagnostics TEXT
                     )
                 """)
+                # State databases from before diagnostics were recorded
+                columns = {row[1] for row in conn.execute("PRAGMA table_info(files)")}
+                if 'diagnostics' not in columns:
+                    conn.execute("ALTER TABLE files ADD COLUMN diagnostics TEXT")
         except Exception as e:
             self.logger.error(f"Failed to initialize state database: {e}")
-
+    
     def should_process(self, filepath: str) -> bool:
         """
         Check if a file needs to be processed
@@ -46,7 +52,7 @@ class StateManager:
             path = Path(filepath)
             if not path.exists():
                 return False
-                
+            
             current_mtime = path.stat().st_mtime
             
             with sqlite3.connect(self.db_path) as conn:
@@ -61,29 +67,95 @@ class StateManager:
                 
                 last_mtime = row[0]
                 return current_mtime > last_mtime
-                
+        
         except Exception as e:
             self.logger.warning(f"Error checking state for {filepath}: {e}")
             return True  # Process on error to be safe
-
-    def mark_processed(self, filepath: str):
-        """Mark a file as successfully processed"""
+    
+    def mark_processed(self, filepath: str, file_hash: Optional[str] = None,
+                       diagnostics: Optional[List[Dict]] = None):
+        """
+        Mark a file as successfully processed, recording its content hash
+        and the diagnostics of its parse (e.g. parse_error for a partial extraction)
+        """
         try:
             path = Path(filepath)
             current_mtime = path.stat().st_mtime
-            
-            # Calculate simple hash for verification (optional, can be slow for large files)
-            # For now relying on mtime is faster and usually sufficient
+            file_hash = file_hash or self.compute_hash(filepath)
             
             with sqlite3.connect(self.db_path) as conn:
                 conn.execute("""
-                    INSERT OR REPLACE INTO files (filepath, mtime, last_indexed)
-                    VALUES (?, ?, CURRENT_TIMESTAMP)
-                """, (str(filepath), current_mtime))
-                
+                    INSERT OR REPLACE INTO files (filepath, mtime, file_hash, last_indexed, diagnostics)
+                    VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?)
+                """, (str(filepath), current_mtime, file_hash, json.dumps(diagnostics) if diagnostics else None))
+        
         except Exception as e:
             self.logger.error(f"Failed to update state for {filepath}: {e}")
-
+    
+    @staticmethod
+    def compute_hash(filepath: str) -> str:
+        """SHA-256 of a file's bytes"""
+        digest = hashlib.sha256()
+        with open(filepath, 'rb') as f:
+            for block in iter(lambda: f.read(1 << 20), b''):
+                digest.update(block)
+        return digest.hexdigest()
+    
+    def current_hash(self, filepath: str) -> str:
+        """
+        Content hash of a file on disk
+        Reuses the recorded hash when the mtime is unchanged, to avoid reading every file
+        """
+        try:
+            current_mtime = Path(filepath).stat().st_mtime
+            with sqlite3.connect(self.db_path) as conn:
+                row = conn.execute(
+                    "SELECT mtime, file_hash FROM files WHERE filepath = ?", (str(filepath),)
+                ).fetchone()
+            if row and row[1] and row[0] == current_mtime:
+                return row[1]
+        except Exception as e:
+            self.logger.warning(f"Error checking state for {filepath}: {e}")
+        return self.compute_hash(filepath)
+    
+    def get_file_hashes(self, root: Optional[str] = None) -> Dict[str, str]:
+        """Recorded content hash per file, optionally limited to files under root"""
+        try:
+            with sqlite3.connect(self.db_path) as conn:
+                rows = conn.execute("SELECT filepath, file_hash FROM files").fetchall()
+        except Exception:
+            return {}
+        
+        if root is None:
+            return {path: file_hash or '' for path, file_hash in rows}
+        prefix = root.rstrip(os.sep) + os.sep
+        return {path: file_hash or '' for path, file_hash in rows if path.startswith(prefix)}
+    
+    def get_diagnostics(self, root: Optional[str] = None) -> Dict[str, List[Dict]]:
+        """Diagnostics recorded for files that were only partly parsed, optionally under root"""
+        try:
+            with sqlite3.connect(self.db_path) as conn:
+                rows = conn.execute(
+                    "SELECT filepath, diagnostics FROM files WHERE diagnostics IS NOT NULL"
+                ).fetchall()
+        except Exception:
+            return {}
+        
+        prefix = root.rstrip(os.sep) + os.sep if root is not None else ''
+        return {path: json.loads(diagnostics) for path, diagnostics in rows if path.startswith(prefix)}
+    
+    def move_file(self, old_path: str, new_path: str):
+        """Record that a file moved without changing"""
+        try:
+            mtime = Path(new_path).stat().st_mtime
+            with sqlite3.connect(self.db_path) as conn:
+                conn.execute(
+                    "UPDATE files SET filepath = ?, mtime = ?, last_indexed = CURRENT_TIMESTAMP WHERE filepath = ?",
+                    (str(new_path), mtime, str(old_path))
+                )
+        except Exception as e:
+            self.logger.error(f"Failed to move state for {old_path}: {e}")
+    
     def get_all_indexed_files(self) -> Set[str]:
         """Get set of all currently indexed file paths"""
         try:
@@ -92,7 +164,7 @@ class StateManager:
                 return {row[0] for row in cursor.fetchall()}
         except Exception:
             return set()
-
+    
     def remove_file(self, filepath: str):
         """Remove a file from state (e.g. if deleted)"""
         try:
@@ -100,7 +172,7 @@ class StateManager:
                 conn.execute("DELETE FROM files WHERE filepath = ?", (str(filepath),))
         except Exception as e:
             self.logger.error(f"Failed to remove state for {filepath}: {e}")
-
+    
     def clear(self):
         """Clear all state"""
         try:
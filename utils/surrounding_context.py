@@ -0,0 +1,106 @@
+#!/usr/bin/env python3
+"""
+Surrounding context of a search result, re-read from the source file
+The imports of the file and the header of the type a method belongs to, so a
+small method is understandable on its own without storing any of it in the index
+"""
+
+import re
+from typing import Dict, List, Optional
+
+# Import statements by language, matched at the start of a line
+_C_INCLUDE = re.compile(r'^[ \t]*#[ \t]*(?:include|import)[ \t]*[<"][^>"\n]+[>"]', re.MULTILINE)
+_JVM_IMPORT = re.compile(r'^import[ \t]+[\w.*]+(?:[ \t]+as[ \t]+\w+)?;?', re.MULTILINE)
+_JS_IMPORT = re.compile(
+    r'^(?:import\b[^;\'"]*?[\'"][^\'"\n]+[\'"];?'
+    r'|(?:const|let|var)[ \t]+[^=\n]+=[ \t]*require\([^)\n]*\);?)',
+    re.MULTILINE
+)
+IMPORT_PATTERNS = {
+    'go': re.compile(r'^import[ \t]*(?:\([^)]*\)|[^\n]*)', re.MULTILINE),
+    'python': re.compile(r'^(?:from[ \t]+\S+[ \t]+import[ \t]*(?:\([^)]*\)|[^\n]*)|import[ \t][^\n]*)', re.MULTILINE),
+    'java': _JVM_IMPORT,
+    'kotlin': _JVM_IMPORT,
+    'scala': _JVM_IMPORT,
+    'javascript': _JS_IMPORT,
+    'typescript': _JS_IMPORT,
+    'rust': re.compile(r'^(?:pub(?:\([^)]*\))?[ \t]+)?use[ \t][^;]*;', re.MULTILINE),
+    'csharp': re.compile(r'^using[ \t]+[\w.=\s]+;', re.MULTILINE),
+    'c': _C_INCLUDE,
+    'cpp': _C_INCLUDE,
+    'objc': _C_INCLUDE,
+    'mojom': re.compile(r'^import[ \t]+"[^"\n]+";', re.MULTILINE),
+    'ruby': re.compile(r'^require(?:_relative)?[ \t(]+[\'"][^\'"\n]+[\'"]\)?', re.MULTILINE),
+    # Written in module bodies, so indented under defmodule (or a nested one)
+    'elixir': re.compile(r'(?:^|(?<=^  )|(?<=^    )|(?<=^\t))(?:alias|import|require|use)[ \t]+[A-Z][\w.]*'
+                         r'(?:\.\{[^}]*\})?[^\n]*', re.MULTILINE),
+    'php': re.compile(r'^(?:use[ \t]+[^;{]+(?:\{[^}]*\})?;|(?:require|include)(?:_once)?\b[^;\n]*;)', re.MULTILINE),
+    'swift': re.compile(r'^(?:@\w+[ \t]+)*import[ \t]+(?:(?:typealias|struct|class|enum|protocol|let|var|func)[ \t]+)?[\w.]+',
+                        re.MULTILINE),
+    'protobuf': re.compile(r'^import[ \t]+(?:(?:public|weak)[ \t]+)?"[^"\n]+";', re.MULTILINE),
+    'dart': re.compile(r'^(?:import|export|part)[ \t]+(?:of[ \t]+)?[\'"][^;]*;', re.MULTILINE),
+}
+
+# Marker for declaration lines that were left out
+ELLIPSIS = '...'
+
+
+def file_imports(source: str, language: str) -> str:
+    """
+    The import statements of a file, one per line, in file order
+    
+    Args:
+        source: File content
+        language: Language of the file, as stored in chunk metadata
+    
+    Returns:
+        The imports, or '' if there are none or the language is not known
+    """
+    pattern = IMPORT_PATTERNS.get(language)
+    if pattern is None:
+        return ''
+    return "\n".join(match.group(0).rstrip() for match in pattern.finditer(source))
+
+
+def declaration_header(lines: List[str], line_start: int, line_end: int, nested: bool,
+                       max_lines: int) -> str:
+    """
+    Header of a type declaration
+    
+    A type whose methods are declared inside it (a class) is cut after the line
+    that opens its body, so the header is its declaration line(s); a type whose
+    methods are declared apart from it (a Go struct) is kept whole, for its fields.
+    Either way at most max_lines lines are kept.
+    
+    Args:
+        lines: Lines of the file
+        line_start: First line of the declaration (1-based)
+        line_end: Last line of the declaration (1-based, inclusive)
+        nested: The method is inside the declaration
+        max_lines: Most lines of the declaration to keep
+    
+    Returns:
+        The header text, ending with '...' where lines were left out
+    """
+    declaration = lines[max(line_start, 1) - 1:max(line_end, line_start)]
+    if not declaration:
+        return ''
+    
+    keep = len(declaration)
+    if nested:
+        keep = next((i + 1 for i, line in enumerate(declaration) if line.rstrip().endswith(('{', ':'))), 1)
+    keep = min(keep, max(max_lines, 1))
+    
+    header = declaration[:keep]
+    if keep < len(declaration):
+        indent = re.match(r'[ \t]*', header[-1]).group(0)
+        header.append(indent + ('    ' if nested else '') + ELLIPSIS)
+    return "\n".join(line.rstrip() for line in header)
+
+
+def enclosing_type_name(metadata: Dict) -> Optional[str]:
+    """Name of the type a chunk is a member of ('Outer.Inner' -> 'Inner'), or None"""
+    parent = metadata.get('parent_class') or metadata.get('parent')
+    if not parent:
+        return None
+    return re.split(r'::|\.', parent)[-1] or None
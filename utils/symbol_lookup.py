@@ -0,0 +1,171 @@
+#!/usr/bin/env python3
+"""
+"Go to symbol" over the names of indexed chunks, without embedding anything
+A query matches a symbol exactly, as a prefix, or within a small edit distance,
+ranked in that order. Names are compared lowercased with separators removed, and
+every identifier word starts a candidate too, so "sessionmanager" and
+"session_manager" find NewSessionManager as a prefix match.
+"""
+
+import re
+from typing import Dict, Iterable, List, Optional, Tuple
+
+from chunkers.base_chunker import parse_metadata, qualified_name
+from utils.code_tokenizer import split_identifier
+
+
+# How a symbol matched, best first
+MATCH_KINDS = ('exact', 'prefix', 'fuzzy')
+
+# Chunk types that are not symbols (whole files, prose, top-level code)
+NON_SYMBOL_TYPES = {'file', 'module', 'block', 'paragraph', 'text'}
+
+# Queries shorter than this only match exactly or as a prefix
+MIN_FUZZY_LENGTH = 3
+
+
+def normalize_name(name: str) -> str:
+    """Lowercase a name and drop everything but letters and digits: Session_Manager -> sessionmanager"""
+    return re.sub(r'[^0-9a-z]+', '', name.lower())
+
+
+def name_keys(name: str) -> List[str]:
+    """
+    Normalized forms a name can be found by: the whole name, then its last component
+    from each identifier word on (NewSessionManager -> newsessionmanager, sessionmanager, manager)
+    """
+    words = split_identifier(name.split('.')[-1])
+    keys = [normalize_name(name)] + [''.join(words[start:]) for start in range(len(words))]
+    return [key for key in dict.fromkeys(keys) if key]
+
+
+def fuzzy_limit(query: str) -> int:
+    """Edits a query may be away from a name: one per four characters, at most three"""
+    if len(query) < MIN_FUZZY_LENGTH:
+        return 0
+    return min(3, max(1, len(query) // 4))
+
+
+def edit_distance(a: str, b: str, limit: int) -> int:
+    """Levenshtein distance with adjacent transpositions, or limit + 1 once it exceeds limit"""
+    if abs(len(a) - len(b)) > limit:
+        return limit + 1
+    previous, current = None, list(range(len(b) + 1))
+    for i in range(1, len(a) + 1):
+        before, previous, current = previous, current, [i] + [0] * len(b)
+        for j in range(1, len(b) + 1):
+            cost = 0 if a[i - 1] == b[j - 1] else 1
+            current[j] = min(previous[j] + 1, current[j - 1] + 1, previous[j - 1] + cost)
+            if i > 1 and j > 1 and a[i - 1] == b[j - 2] and a[i - 2] == b[j - 1]:
+                current[j] = min(current[j], before[j - 2] + 1)
+        if min(current) > limit:
+            return limit + 1
+    return current[-1]
+
+
+def match_name(query: str, name: str, keys: List[str]) -> Optional[Tuple[int, int, int, int]]:
+    """
+    How well a normalized query matches a name: (tier, edits, unmatched, word) where tier
+    indexes MATCH_KINDS, edits counts fuzzy edits, unmatched the characters of the name
+    left over and word the identifier word the match starts at; lower is better throughout.
+    None if it does not match
+    """
+    if query == keys[0] or query == normalize_name(name.split('.')[-1]):
+        return 0, 0, 0, 0
+    for position, key in enumerate(keys):
+        if key.startswith(query):
+            return 1, 0, len(key) - len(query), position
+    
+    limit = fuzzy_limit(query)
+    if not limit:
+        return None
+    best = None
+    for position, key in enumerate(keys):
+        # Against the whole key, and against the start of a longer one (a mistyped prefix)
+        distances = [edit_distance(query, key, limit)]
+        if len(key) > len(query) + 1:
+            distances.extend(edit_distance(query, key[:length], limit)
+                             for length in (len(query) - 1, len(query), len(query) + 1))
+        distance = min(distances)
+        if distance <= limit and (best is None or distance < best[1]):
+            best = (2, distance, abs(len(key) - len(query)), position)
+    return best
+
+
+def lookup_symbols(query: str, metadatas: Iterable[Dict], limit: int = 20, kinds: Optional[List[str]] = None,
+                   languages: Optional[List[str]] = None, repos: Optional[List[str]] = None,
+                   kind_of=None) -> List[Dict]:
+    """
+    Symbols whose name matches the query, best first
+    
+    Args:
+        query: Symbol name, or part of one
+        metadatas: Stored chunk metadata to search
+        limit: Maximum number of symbols
+        kinds: Only these symbol kinds (function, method, type, ...)
+        languages: Only these languages
+        repos: Only these repository labels
+        kind_of: Symbol kind of a chunk type (default: the chunk type)
+    
+    Returns:
+        One entry per symbol (parts of a split symbol are merged) with its name,
+        kind, location and how it matched ('match', and 'distance' for fuzzy matches);
+        Go aliases also carry 'alias_of', the type they name
+    """
+    normalized = normalize_name(query)
+    if not normalized:
+        return []
+    kind_of = kind_of or (lambda chunk_type: chunk_type)
+    
+    symbols: Dict[str, Tuple[Tuple, Dict]] = {}
+    for metadata in metadatas:
+        name = metadata.get('name') or ''
+        chunk_type = metadata.get('type', '')
+        if not name or chunk_type in NON_SYMBOL_TYPES:
+            continue
+        kind = kind_of(chunk_type)
+        if kinds and kind not in kinds and chunk_type not in kinds:
+            continue
+        if languages and metadata.get('language') not in languages:
+            continue
+        if repos and (metadata.get('repo') or '') not in repos:
+            continue
+        
+        key = metadata.get('symbol_id') or f"{metadata.get('filepath')}:{name}:{metadata.get('line_start')}"
+        if key in symbols:
+            # Another part of a split symbol: widen the line range
+            found = symbols[key][1]
+            found['line_start'] = min(found['line_start'], metadata.get('line_start', found['line_start']))
+            found['line_end'] = max(found['line_end'], metadata.get('line_end', found['line_end']))
+            continue
+        
+        qualified = qualified_name(metadata)
+        scores = [match_name(normalized, candidate, name_keys(candidate))
+                  for candidate in dict.fromkeys((name, qualified)) if name_keys(candidate)]
+        scores = [score for score in scores if score is not None]
+        if not scores:
+            continue
+        score = min(scores)
+        rank = score + (len(name), name, metadata.get('filepath') or '', metadata.get('line_start') or 0)
+        symbols[key] = rank, {
+            'name': name,
+            'qualified_name': qualified,
+            'kind': kind,
+            'type': chunk_type,
+            'language': metadata.get('language'),
+            'filepath': metadata.get('filepath'),
+            'repo': metadata.get('repo') or None,
+            'line_start': metadata.get('line_start'),
+            'line_end': metadata.get('line_end'),
+            'symbol_id': metadata.get('symbol_id'),
+            'match': MATCH_KINDS[score[0]],
+            'distance': score[1],
+        }
+        if chunk_type == 'alias':
+            # A Go alias leads on to the type it names
+            alias_of = parse_metadata(metadata.get('metadata')).get('alias_of')
+            if alias_of:
+                symbols[key][1]['alias_of'] = alias_of
+    
+    ranked = sorted(symbols.values(), key=lambda item: item[0])
+    return [symbol for _, symbol in ranked[:max(0, limit)]]
@@ -0,0 +1,75 @@
+#!/usr/bin/env python3
+"""
+Neighboring symbols of a search result
+The symbols defined just before and just after a result in its file, found from
+the line ranges of the stored chunks: the helpers sitting next to a function are
+often what explains it, and listing them saves a search per helper. Symbols that
+enclose the result (its class) or that it encloses (its methods) are not
+neighbors; a symbol split into parts counts once, over the lines of all parts.
+"""
+
+from typing import Dict, List, Optional, Tuple
+
+from chunkers.base_chunker import qualified_name
+from chunkers.token_splitter import stitch_parts
+
+# What a search attaches for each neighbor: 'ids' its location and identity,
+# 'bodies' its code as well
+NEIGHBOR_MODES = ('ids', 'bodies')
+
+
+def group_symbols(chunks: List[Dict]) -> List[List[Dict]]:
+    """Stored chunks of a file grouped by symbol (the parts of a split symbol together), in order"""
+    groups: Dict[str, List[Dict]] = {}
+    for chunk in chunks:
+        metadata = chunk['metadata']
+        groups.setdefault(metadata.get('symbol_id') or chunk['id'], []).append(chunk)
+    for parts in groups.values():
+        parts.sort(key=lambda p: int(p['metadata'].get('part_index', 0)))
+    return sorted(groups.values(), key=lambda parts: _lines(parts))
+
+
+def adjacent_symbols(symbols: List[List[Dict]], metadata: Dict) -> Tuple[Optional[List[Dict]], Optional[List[Dict]]]:
+    """
+    The symbols right before and right after the one a chunk belongs to
+    
+    Args:
+        symbols: The file's symbols, as group_symbols gives them
+        metadata: Stored metadata of the chunk
+    
+    Returns:
+        (previous, next): the parts of each neighbor, None where there is none
+    """
+    own = [parts for parts in symbols if parts[0]['metadata'].get('symbol_id') == metadata.get('symbol_id')]
+    start, end = _lines(own[0]) if own else (int(metadata.get('line_start', 0)), int(metadata.get('line_end', 0)))
+    before = [parts for parts in symbols if _lines(parts)[1] < start]
+    after = [parts for parts in symbols if _lines(parts)[0] > end]
+    # On a tie the outer symbol wins: a type over its last or first method
+    previous = max(before, key=lambda parts: (_lines(parts)[1], -_lines(parts)[0]), default=None)
+    following = min(after, key=lambda parts: (_lines(parts)[0], -_lines(parts)[1]), default=None)
+    return previous, following
+
+
+def neighbor_entry(parts: List[Dict], bodies: bool = False) -> Dict:
+    """A neighbor as attached to a result: its first chunk's id, name and lines, and its code with bodies"""
+    metadata = parts[0]['metadata']
+    start, end = _lines(parts)
+    entry = {
+        'id': parts[0]['id'],
+        'symbol_id': metadata.get('symbol_id'),
+        'name': metadata.get('name'),
+        'qualified_name': qualified_name(metadata),
+        'type': metadata.get('type'),
+        'filepath': metadata.get('filepath'),
+        'line_start': start,
+        'line_end': end,
+    }
+    if bodies:
+        entry['content'] = stitch_parts(parts) if len(parts) > 1 else parts[0]['content']
+    return entry
+
+
+def _lines(parts: List[Dict]) -> Tuple[int, int]:
+    """First and last line of a symbol over all its parts"""
+    return (min(int(p['metadata'].get('line_start', 0)) for p in parts),
+            max(int(p['metadata'].get('line_end', 0)) for p in parts))
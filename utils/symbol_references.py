@@ -0,0 +1,99 @@
+#!/usr/bin/env python3
+"""
+How an indexed chunk refers to a symbol
+Combines the call graph and the type and doc links the Go linker records
+(calls, embedded and field types, parameter and result types, receivers,
+implemented interfaces, instantiations, doc links) with the places the name
+is written in the chunk's code, for impact analysis before a rename. Matching
+is by name, as for callers: 'User' matches pkg.User and AdminUser.User, a
+qualified name only itself.
+"""
+
+import re
+from typing import Dict, Iterable, List, Optional
+
+
+# Kinds of reference, in the order they are listed
+REFERENCE_KINDS = (
+    'call',           # calls the function or method
+    'embed',          # embeds the type (struct field or interface element)
+    'implements',     # implements the interface
+    'receiver',       # method declared on the type, or required by the interface
+    'field',          # struct field of the type
+    'param',          # parameter of the type
+    'result',         # result of the type
+    'type',           # constant, var, alias or type parameter constraint of the type
+    'instantiation',  # instantiates the generic, or uses the type as a type argument
+    'assign',         # sets the package-level variable
+    'doc',            # doc comment links to or mentions the symbol
+    'code',           # none of the above, but the name is written in the code
+)
+
+# Type names in a type expression: User, *pkg.User, map[string][]User, SessionManager[User]
+TYPE_NAME_PATTERN = re.compile(r'[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)?')
+
+
+def names_symbol(name: str, symbol_name: str) -> bool:
+    """True if a recorded name is the symbol: 'Open' matches 'Store.Open' and 'db.Open'"""
+    if '.' in symbol_name:
+        return name == symbol_name
+    return name.split('.')[-1] == symbol_name
+
+
+def type_mentions(type_text: Optional[str], symbol_name: str) -> bool:
+    """True if a type expression names the symbol (*AdminUser, []User, SessionManager[User])"""
+    return any(names_symbol(name, symbol_name) for name in TYPE_NAME_PATTERN.findall(type_text or ''))
+
+
+def reference_kinds(extra: Dict, content: str, symbol_name: str) -> List[str]:
+    """
+    How a chunk refers to a symbol, from its linked metadata and its code
+    
+    Args:
+        extra: The chunk's parsed metadata (parse_metadata of its 'metadata' field)
+        content: The chunk's code
+        symbol_name: Name of the symbol, optionally qualified (pkg.User, Store.Open)
+    
+    Returns:
+        The REFERENCE_KINDS that apply, in that order; 'code' only when the name is
+        written in the code but no linked reference explains it. Empty if the chunk
+        does not refer to the symbol.
+    """
+    fields = [field for field in extra.get('fields', []) if isinstance(field, dict)]
+    found = {
+        'call': _any_ref(extra.get('calls'), symbol_name),
+        'embed': any(field.get('embedded') and type_mentions(field.get('type'), symbol_name) for field in fields)
+                 or any(type_mentions(embedded, symbol_name) for embedded in extra.get('embeds', [])),
+        'implements': any(names_symbol(name, symbol_name) for name in extra.get('implements', [])),
+        'receiver': type_mentions(extra.get('receiver_type'), symbol_name)
+                    or names_symbol(extra.get('interface') or '', symbol_name),
+        'field': any(not field.get('embedded') and type_mentions(field.get('type'), symbol_name) for field in fields),
+        'param': any(type_mentions(param.get('type'), symbol_name) for param in extra.get('params', [])),
+        'result': any(type_mentions(result.get('type'), symbol_name) for result in extra.get('results', [])),
+        'type': any(type_mentions(extra.get(key), symbol_name) for key in ('type', 'inferred_type', 'underlying'))
+                or _any_ref([extra.get('alias_of')], symbol_name)
+                or any(type_mentions(param.get('constraint'), symbol_name) for param in extra.get('type_params', [])),
+        'instantiation': any(type_mentions(record.get('instance'), symbol_name)
+                             for record in extra.get('instantiations', [])),
+        'assign': any(names_symbol(name, symbol_name) for name in extra.get('assigns', [])),
+        'doc': _any_ref(extra.get('doc_refs'), symbol_name),
+    }
+    kinds = [kind for kind in REFERENCE_KINDS if found.get(kind)]
+    if not kinds and mention_lines(content, symbol_name):
+        kinds.append('code')
+    return kinds
+
+
+def mention_lines(content: str, symbol_name: str) -> List[int]:
+    """
+    Indexes of the lines of content that write the symbol's name as a whole word,
+    a qualified name as written (pkg.User)
+    """
+    before = r'(?<![\w$.])' if '.' in symbol_name else r'(?<![\w$])'
+    pattern = re.compile(before + re.escape(symbol_name) + r'(?![\w$])')
+    return [i for i, line in enumerate(content.splitlines()) if pattern.search(line)]
+
+
+def _any_ref(refs: Optional[Iterable], symbol_name: str) -> bool:
+    """True if a list of symbol references has one naming the symbol"""
+    return any(isinstance(ref, dict) and names_symbol(ref.get('name', ''), symbol_name) for ref in refs or [])
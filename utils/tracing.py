@@ -0,0 +1,185 @@
+#!/usr/bin/env python3
+"""
+Timing spans for the indexing and search pipelines
+A Tracer times each stage of a run (crawl, parse, link, embed, upsert when
+indexing; vector_search, keyword_search, rerank, pack when searching) and keeps
+per-stage counts and latencies for the run summary. Every finished span is also
+passed to the tracer's hooks, plain callables, so spans can be sent to
+OpenTelemetry, Prometheus or a log without this package depending on either:
+
+    rag.tracer.add_hook(opentelemetry_hook())
+    rag.tracer.add_hook(prometheus_hook(Histogram('code_rag_stage_seconds', '...', ['stage'])))
+    rag.tracer.add_hook(log_hook())
+"""
+
+import logging
+import threading
+import time
+from contextlib import contextmanager
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, Iterable, Iterator, List, Optional
+
+from utils.logger import get_logger
+
+
+# Stages of an indexing run, and of a search, in pipeline order
+INDEX_STAGES = ('crawl', 'parse', 'link', 'embed', 'upsert')
+SEARCH_STAGES = ('search', 'vector_search', 'keyword_search', 'rerank', 'pack')
+
+
+@dataclass
+class Span:
+    """One timed stage: when it started (epoch seconds), how long it took and what it covered"""
+    name: str
+    start: float
+    duration: float = 0.0
+    items: int = 0          # files crawled or parsed, texts embedded, chunks stored, results ranked
+    attributes: Dict[str, Any] = field(default_factory=dict)
+    
+    @property
+    def end(self) -> float:
+        return self.start + self.duration
+
+
+# Receives every finished span; errors it raises are logged, never passed to the pipeline
+SpanHook = Callable[[Span], None]
+
+
+class Tracer:
+    """Thread-safe span recorder with per-stage totals and hooks for external backends"""
+    
+    def __init__(self, hooks: Optional[List[SpanHook]] = None):
+        """
+        Args:
+            hooks: Called with each finished span, in order
+        """
+        self.logger = get_logger()
+        self._hooks: List[SpanHook] = list(hooks or [])
+        self._lock = threading.Lock()
+        self._stages: Dict[str, Dict] = {}
+    
+    def add_hook(self, hook: SpanHook):
+        with self._lock:
+            self._hooks.append(hook)
+    
+    def remove_hook(self, hook: SpanHook):
+        with self._lock:
+            if hook in self._hooks:
+                self._hooks.remove(hook)
+    
+    @contextmanager
+    def span(self, name: str, items: int = 0, **attributes) -> Iterator[Span]:
+        """
+        Time the block as a stage; the span it yields can be given its item count
+        and attributes as they become known. A block that raises is recorded with
+        the exception's type as its 'error' attribute
+        """
+        span = Span(name, time.time(), items=items, attributes=attributes)
+        started = time.perf_counter()
+        try:
+            yield span
+        except BaseException as e:
+            span.attributes['error'] = type(e).__name__
+            raise
+        finally:
+            span.duration = time.perf_counter() - started
+            self._finish(span)
+    
+    def record(self, name: str, duration: float, items: int = 0, start: Optional[float] = None,
+               **attributes) -> Span:
+        """Record a stage timed elsewhere (in a worker process); start defaults to duration ago"""
+        span = Span(name, time.time() - duration if start is None else start, duration, items, attributes)
+        self._finish(span)
+        return span
+    
+    def summary(self, stages: Optional[Iterable[str]] = None) -> Dict[str, Dict]:
+        """
+        Per-stage totals since the last reset: calls, items, and total, mean and
+        max duration in seconds; pipeline stages first, only stages that ran
+        """
+        with self._lock:
+            names = [name for name in INDEX_STAGES + SEARCH_STAGES if name in self._stages]
+            names += sorted(set(self._stages) - set(names))
+            selected = names if stages is None else [name for name in names if name in set(stages)]
+            return {name: dict(self._stages[name], mean=self._stages[name]['total'] / self._stages[name]['calls'])
+                    for name in selected}
+    
+    def reset(self, stages: Optional[Iterable[str]] = None):
+        """Forget the totals of these stages (all by default)"""
+        with self._lock:
+            if stages is None:
+                self._stages = {}
+            for name in stages or ():
+                self._stages.pop(name, None)
+    
+    def _finish(self, span: Span):
+        with self._lock:
+            totals = self._stages.setdefault(span.name, {'calls': 0, 'items': 0, 'total': 0.0, 'max': 0.0})
+            totals['calls'] += 1
+            totals['items'] += span.items
+            totals['total'] += span.duration
+            totals['max'] = max(totals['max'], span.duration)
+            hooks = list(self._hooks)
+        for hook in hooks:
+            try:
+                hook(span)
+            except Exception as e:
+                self.logger.warning(f"Span hook {getattr(hook, '__name__', hook)!r} failed: {e}")
+
+
+def format_timings(summary: Dict[str, Dict]) -> Dict[str, str]:
+    """A Tracer summary as rows of the statistics table"""
+    return {f"Time ({name})": f"{totals['calls']} calls, {totals['items']} items, {totals['total']:.2f}s total, "
+                              f"{totals['mean'] * 1000:.1f}ms mean, {totals['max'] * 1000:.1f}ms max"
+            for name, totals in summary.items()}
+
+
+def log_hook(logger: Optional[logging.Logger] = None, level: int = logging.DEBUG) -> SpanHook:
+    """Hook writing one log line per span"""
+    logger = logger or get_logger()
+    
+    def hook(span: Span):
+        attributes = ''.join(f" {key}={value}" for key, value in span.attributes.items())
+        logger.log(level, f"span {span.name} {span.duration * 1000:.1f}ms items={span.items}{attributes}",
+                   extra={'stage': span.name, 'duration': span.duration, 'items': span.items,
+                          'attributes': dict(span.attributes)})
+    return hook
+
+
+def opentelemetry_hook(tracer=None, prefix: str = 'code_rag.') -> SpanHook:
+    """
+    Hook turning spans into OpenTelemetry spans, with their own start and end times
+    
+    Args:
+        tracer: OpenTelemetry tracer (default: opentelemetry.trace.get_tracer('code_rag'))
+        prefix: Put before each stage name
+    
+    Raises:
+        ImportError: Without a tracer, if opentelemetry-api is not installed
+    """
+    if tracer is None:
+        from opentelemetry import trace
+        tracer = trace.get_tracer('code_rag')
+    
+    def hook(span: Span):
+        attributes = {key: value if isinstance(value, (str, bool, int, float)) else str(value)
+                      for key, value in span.attributes.items()}
+        attributes['items'] = span.items
+        otel_span = tracer.start_span(prefix + span.name, start_time=int(span.start * 1e9), attributes=attributes)
+        otel_span.end(end_time=int(span.end * 1e9))
+    return hook
+
+
+def prometheus_hook(histogram, items=None) -> SpanHook:
+    """
+    Hook observing span durations in a Prometheus histogram labelled by 'stage'
+    
+    Args:
+        histogram: prometheus_client Histogram with a 'stage' label, in seconds
+        items: Optional Counter with a 'stage' label, increased by each span's items
+    """
+    def hook(span: Span):
+        histogram.labels(stage=span.name).observe(span.duration)
+        if items is not None and span.items:
+            items.labels(stage=span.name).inc(span.items)
+    return hook
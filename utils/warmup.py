@@ -0,0 +1,59 @@
+#!/usr/bin/env python3
+"""
+Warm-up of a RAG system before it serves searches
+Without it the first search after start-up pays for loading the store's vector
+index (Chroma's HNSW segments, a Qdrant or pgvector connection), reaching the
+embedder (an Ollama round trip, a model loaded into memory) and whatever the
+ranking touches for the first time. warm_up does all of it up front: one probe
+embedding, one nearest-neighbour query per collection searched and, when a
+sample query is given, a whole search, timing each step. The servers run it
+when they start (CONFIG.warmup, CONFIG.warmup_query); the HTTP server's /health
+answers 503 "warming" until it is done.
+"""
+
+import time
+from typing import Dict, Optional
+
+
+# Text of the probe embedding
+PROBE_TEXT = "warm up"
+
+
+def warm_up(rag, query: Optional[str] = None) -> Dict:
+    """
+    Load the indexes and reach the embedder of rag, so the first real search is fast
+    
+    Args:
+        rag: ChromeRAGSystem to warm up
+        query: Sample search run last (None: none)
+    
+    Returns:
+        {'status': 'ok' or 'error', 'seconds': total, 'steps': {step: seconds}} of the
+        steps run ('embedder', 'vector_index', 'query'; a keyword-only index has no
+        embedder to reach and no vectors to load, an empty one no vectors), with the
+        'error' that stopped a failed warm-up
+    """
+    started = time.perf_counter()
+    steps = {}
+    report = {'status': 'ok', 'steps': steps}
+    try:
+        if not rag.keyword_only:
+            step = time.perf_counter()
+            vector = rag.embed_query(PROBE_TEXT)
+            steps['embedder'] = round(time.perf_counter() - step, 3)
+        
+        if not rag.keyword_only and rag.collection.count():
+            step = time.perf_counter()
+            stores = [rag.collection] + ([rag.signature_store] if rag.embedding_mode == 'dual' else [])
+            for store in stores:
+                store.query(query_embeddings=[vector], n_results=1, include=['distances'])
+            steps['vector_index'] = round(time.perf_counter() - step, 3)
+        
+        if query:
+            step = time.perf_counter()
+            rag.retrieve_context(query, n_results=1)
+            steps['query'] = round(time.perf_counter() - step, 3)
+    except Exception as e:
+        report.update(status='error', error=str(e))
+    report['seconds'] = round(time.perf_counter() - started, 3)
+    return report
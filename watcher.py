@@ -0,0 +1,183 @@
+#!/usr/bin/env python3
+"""
+Watch mode: keep the index current while files are edited
+Filesystem notifications (watchdog) mark paths dirty; once edits have been
+quiet for the debounce interval, the dirty paths go through a scoped
+incremental update, so only the files that changed are re-embedded
+"""
+
+import os
+import threading
+import time
+from pathlib import Path
+from typing import Callable, Dict, Optional, Set
+
+from config import CONFIG
+from utils.logger import print_error, print_info, print_success, print_warning
+
+
+class WatchError(Exception):
+    """Raised when the filesystem cannot be watched"""
+
+
+class IndexWatcher:
+    """
+    Debounced incremental re-indexing of a source tree
+    
+    Events only record which paths changed; what to do with them is decided
+    from the disk when the batch is flushed. An editor's atomic save (write a
+    temporary file, rename it over the original) therefore ends up as one
+    update of the original file, and a rename shows up as a move whose chunks
+    keep their embeddings.
+    """
+    
+    def __init__(self, indexer, source_path: str, debounce: Optional[float] = None,
+                 on_update: Optional[Callable[[Dict], None]] = None, **update_options):
+        """
+        Args:
+            indexer: ChromeIndexer whose ignore rules and state the updates use
+            source_path: Root directory that was indexed
+            debounce: Seconds without events before a batch is indexed (default: CONFIG.watch_debounce)
+            on_update: Called with the statistics of each update (default: print a short log)
+            **update_options: Passed on to ChromeIndexer.update_index (file_types, max_tokens, ...)
+        """
+        self.indexer = indexer
+        self.source_path = source_path
+        self.root = Path(source_path).resolve()
+        self.debounce = CONFIG.watch_debounce if debounce is None else debounce
+        self.on_update = on_update or log_update
+        self.update_options = update_options
+        
+        self._dirty: Set[str] = set()
+        self._last_event = 0.0
+        self._lock = threading.Lock()
+    
+    def notify(self, path: str, is_directory: bool = False):
+        """
+        Record a changed path (created, modified, deleted, or either end of a move)
+        
+        Paths outside the tree, inside excluded directories (.git, node_modules, ...)
+        and of files that are never indexed are dropped here; a changed .gitignore
+        marks its whole directory, since it may include or exclude any file below.
+        """
+        try:
+            relative = Path(os.path.abspath(path)).relative_to(self.root)
+        except ValueError:
+            return
+        directories = relative.parts if is_directory else relative.parts[:-1]
+        if self.indexer.use_default_ignores and any(CONFIG.should_exclude_dir(d) for d in directories):
+            return
+        
+        if not is_directory and relative.name == '.gitignore':
+            relative = relative.parent
+        elif not is_directory and not self._indexable(relative):
+            return  # editor swap files, backups, temporary save files
+        
+        with self._lock:
+            self._dirty.add(str(Path(self.source_path) / relative) if relative.parts else self.source_path)
+            self._last_event = time.monotonic()
+    
+    def _indexable(self, relative: Path) -> bool:
+        """
+        True if a file has a language to be indexed as; a deleted extensionless
+        file is kept too, since its #! line can no longer be read
+        """
+        path = self.root / relative
+        if not relative.suffix and not path.exists():
+            return True
+        return self.indexer.languages.language(path, relative) is not None
+    
+    def pending(self) -> bool:
+        """True if changed paths are waiting to be indexed"""
+        with self._lock:
+            return bool(self._dirty)
+    
+    def due(self) -> bool:
+        """True if changes are pending and no event arrived for the debounce interval"""
+        with self._lock:
+            return bool(self._dirty) and time.monotonic() - self._last_event >= self.debounce
+    
+    def flush(self) -> Optional[Dict]:
+        """
+        Index the pending paths now
+        
+        Returns:
+            Statistics of the update (see ChromeIndexer.update_index), or None if nothing was pending
+        """
+        with self._lock:
+            paths = sorted(self._dirty)
+            self._dirty.clear()
+        if not paths:
+            return None
+        
+        stats = self.indexer.update_index(self.source_path, paths=paths, report=False, **self.update_options)
+        self.on_update(stats)
+        return stats
+    
+    def run(self, stop: Optional[threading.Event] = None, poll_interval: float = 0.1):
+        """
+        Watch the tree until interrupted (or until stop is set)
+        
+        Raises:
+            WatchError: If watchdog is not installed or the tree cannot be watched
+        """
+        try:
+            from watchdog.events import FileSystemEventHandler
+            from watchdog.observers import Observer
+        except ImportError:
+            raise WatchError("Watch mode needs the watchdog package: pip install watchdog")
+        
+        watcher = self
+        
+        class Handler(FileSystemEventHandler):
+            def on_any_event(self, event):
+                if event.event_type in ('opened', 'closed_no_write'):
+                    return  # reads
+                watcher.notify(event.src_path, event.is_directory)
+                if getattr(event, 'dest_path', ''):
+                    watcher.notify(event.dest_path, event.is_directory)
+        
+        observer = Observer()
+        try:
+            observer.schedule(Handler(), str(self.root), recursive=True)
+            observer.start()
+        except OSError as e:
+            raise WatchError(f"Cannot watch {self.root}: {e}")
+        
+        print_info(f"Watching {self.source_path} for changes (Ctrl+C to stop)")
+        stop = stop or threading.Event()
+        try:
+            while not stop.wait(poll_interval):
+                if self.due():
+                    try:
+                        self.flush()
+                    except Exception as e:
+                        # A failed batch must not end the watch; the next save retries the file
+                        print_error(f"Update failed: {e}")
+        except KeyboardInterrupt:
+            pass
+        finally:
+            observer.stop()
+            observer.join()
+        if self.pending():
+            self.flush()
+
+
+def log_update(stats: Dict):
+    """One line per added, updated, moved or removed file, and per file with syntax errors"""
+    counts = stats.get('chunks_by_file', {})
+    for path in stats['paths_added']:
+        print_success(f"added {path} ({counts.get(path, 0)} chunks)")
+    for path in stats['paths_updated']:
+        print_success(f"updated {path} ({counts.get(path, 0)} chunks)")
+    for old_path, new_path in stats['paths_moved']:
+        print_success(f"moved {old_path} -> {new_path}")
+    for path in stats['paths_removed']:
+        print_success(f"removed {path}")
+    if stats['files_relinked']:
+        print_info(f"relinked {stats['files_relinked']} unchanged file(s) of the same package")
+    for partial in stats.get('files_partial', []):
+        first = partial['diagnostics'][0]
+        print_warning(f"{partial['filepath']}:{first['line']}: {first['message']} (indexed what parsed)")
+    for error in stats['errors']:
+        print_warning(error)
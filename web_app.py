@@ -120,7 +120,7 @@ def main():
                                 <span class="metadata-tag">📄 {meta.get('filepath')}</span>
                                 <span class="metadata-tag">🏷️ {meta.get('type')}</span>
                                 <span class="metadata-tag">💻 {meta.get('language')}</span>
-                                <span class="metadata-tag">📊 Similarity: {1 - result['distance']:.3f}</span>
+                                <span class="metadata-tag">📊 Score: {result.get('rrf_score', 0):.4f}</span>
                             </p>
                         </div>
                         """, unsafe_allow_html=True)